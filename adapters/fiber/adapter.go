@@ -1 +1,206 @@
 package fiber
+
+import (
+	"github.com/gofiber/fiber/v3"
+	"github.com/gofiber/fiber/v3/middleware/cors"
+	"github.com/lborres/kuta"
+	"github.com/lborres/kuta/pkg/clientip"
+	"github.com/lborres/kuta/services"
+)
+
+const (
+	defaultCookieName = "auth_token"
+)
+
+// Options customizes how the Fiber adapter mounts routes and reads requests.
+// Zero values fall back to kuta's defaults.
+type Options struct {
+	// CookieName is the cookie checked for the session token when no
+	// Authorization header is present. Defaults to "auth_token".
+	CookieName string
+
+	// SetCookie makes sign-up, sign-in and refresh set the session token as
+	// an HttpOnly cookie named CookieName, and sign-out clear it.
+	SetCookie bool
+
+	// CookieDomain is the Domain attribute of the session cookie
+	CookieDomain string
+
+	// CookieSameSite is the SameSite attribute of the session cookie:
+	// "Lax" (default), "Strict" or "None".
+	CookieSameSite string
+
+	// CookieInsecure drops the Secure attribute so the cookie works over
+	// plain HTTP. Only meant for local development.
+	CookieInsecure bool
+
+	// TokenHeader, when set, sends the session token of sign-up, sign-in and
+	// refresh responses in this response header (e.g.
+	// kuta.DefaultTokenHeader) and leaves it out of the JSON body.
+	TokenHeader string
+
+	// OAuthRedirectURL is where the browser is sent once an OAuth callback
+	// has set the session cookie, e.g. the app's home page. Without it, or
+	// without SetCookie, the callback responds with the sign-in result.
+	OAuthRedirectURL string
+
+	// BasePath overrides kuta.Config.BasePath for this adapter
+	BasePath string
+
+	// RouteGroups mounts the endpoints under several prefixes, each with its
+	// own middleware (fiber.Handler values). Replaces BasePath when set.
+	RouteGroups []kuta.RouteGroup
+
+	// CORS enables cross-origin requests to the auth routes. Credentials are
+	// allowed exactly when SetCookie is on.
+	CORS *kuta.CORSConfig
+
+	// TrustedProxies lists IPs or CIDRs of load balancers / reverse proxies
+	// whose ProxyHeader and X-Real-IP headers are honored when recording the
+	// client IP on sessions.
+	TrustedProxies []string
+
+	// TrustProxy honors forwarding headers from any peer. Only enable this
+	// when the app is reachable exclusively through a proxy that sets them.
+	TrustProxy bool
+
+	// ProxyHeader is the header holding the client IP chain.
+	// Defaults to "X-Forwarded-For".
+	ProxyHeader string
+
+	// ResponseEnvelope shapes response bodies, e.g. kuta.DataEnvelope for
+	// {"data": ..., "error": ...}. Defaults to kuta.BareEnvelope.
+	ResponseEnvelope kuta.ResponseEnvelope
+
+	// Transforms registers per-endpoint request decoders and response
+	// encoders, keyed by OperationID, e.g. kuta.SnakeCaseDecoder. Set
+	// Transforms.FieldNaming to kuta.FieldNamingSnakeCase to switch every
+	// endpoint to snake_case keys.
+	Transforms kuta.PayloadTransforms
+
+	// MaxBodySize bounds the request bodies of the auth endpoints in bytes.
+	// Larger ones are refused with 413. Defaults to kuta.DefaultMaxBodySize.
+	// Fiber's own BodyLimit applies first, so bodies over it never reach
+	// kuta; keep it at least as large.
+	MaxBodySize int64
+
+	resolver *clientip.Resolver
+}
+
+// resolve fills in defaults and builds the client IP resolver
+func (o Options) resolve() (Options, error) {
+	if o.CookieName == "" {
+		o.CookieName = defaultCookieName
+	}
+	if o.ProxyHeader == "" {
+		o.ProxyHeader = fiber.HeaderXForwardedFor
+	}
+	if o.CookieSameSite == "" {
+		o.CookieSameSite = fiber.CookieSameSiteLaxMode
+	}
+	if o.MaxBodySize <= 0 {
+		o.MaxBodySize = kuta.DefaultMaxBodySize
+	}
+
+	if o.CORS != nil {
+		if err := o.CORS.Validate(o.SetCookie); err != nil {
+			return o, err
+		}
+	}
+
+	resolver, err := clientip.NewResolver(clientip.Config{
+		TrustedProxies:  o.TrustedProxies,
+		TrustAllProxies: o.TrustProxy,
+		Header:          o.ProxyHeader,
+	})
+	if err != nil {
+		return o, err
+	}
+	o.resolver = resolver
+
+	return o, nil
+}
+
+// corsHandler builds the CORS middleware for the auth route group
+func (o Options) corsHandler() fiber.Handler {
+	allowHeaders := append([]string{
+		fiber.HeaderAuthorization,
+		fiber.HeaderContentType,
+		HeaderProof,
+		kuta.IdempotencyKeyHeader,
+	}, o.CORS.AllowedHeaders...)
+
+	// Browsers hide response headers from cross-origin scripts unless exposed
+	var exposeHeaders []string
+	if o.TokenHeader != "" {
+		exposeHeaders = []string{o.TokenHeader}
+	}
+
+	return cors.New(cors.Config{
+		AllowOrigins:     o.CORS.AllowedOrigins,
+		AllowMethods:     []string{fiber.MethodGet, fiber.MethodPost, fiber.MethodPut, fiber.MethodPatch, fiber.MethodDelete},
+		AllowHeaders:     allowHeaders,
+		ExposeHeaders:    exposeHeaders,
+		AllowCredentials: o.SetCookie,
+		MaxAge:           int(o.CORS.MaxAge.Seconds()),
+	})
+}
+
+// envelope returns the configured response envelope or the bare default
+func (o Options) envelope() kuta.ResponseEnvelope {
+	if o.ResponseEnvelope == nil {
+		return kuta.BareEnvelope{}
+	}
+	return o.ResponseEnvelope
+}
+
+// handlerOptions configures the handlers kuta shares across adapters
+func (o Options) handlerOptions() services.HandlerOptions {
+	return services.HandlerOptions{
+		CookieName:       o.CookieName,
+		SetCookie:        o.SetCookie,
+		CookieDomain:     o.CookieDomain,
+		CookieSameSite:   o.CookieSameSite,
+		CookieInsecure:   o.CookieInsecure,
+		TokenHeader:      o.TokenHeader,
+		Envelope:         o.envelope(),
+		Transforms:       o.Transforms,
+		OAuthRedirectURL: o.OAuthRedirectURL,
+		Bind: func(ctx *kuta.RequestContext, operationID string, out any) error {
+			return o.bind(ctx.Native.(fiber.Ctx), operationID, out)
+		},
+		FormImage: func(ctx *kuta.RequestContext, field string) (*kuta.ImageUpload, error) {
+			return multipartImage(ctx.Native.(fiber.Ctx), field)
+		},
+	}
+}
+
+// bind decodes the request body with the operation's decoder, falling back
+// to Fiber's content-type based binding
+func (o Options) bind(c fiber.Ctx, operationID string, out any) error {
+	if decoder, ok := o.Transforms.Decoder(operationID); ok {
+		return decoder(c.Body(), out)
+	}
+	return c.Bind().Body(out)
+}
+
+// fail sends an error response through the envelope
+func (o Options) fail(c fiber.Ctx, status int, message string) error {
+	return c.Status(status).JSON(o.envelope().Failure(status, message))
+}
+
+// authError maps kuta errors to an enveloped error response
+func (o Options) authError(c fiber.Ctx, err error) error {
+	status, body := kuta.ErrorBody(o.envelope(), err)
+	return c.Status(status).JSON(body)
+}
+
+// clientIP returns the IP address recorded on sessions for this request
+func (o Options) clientIP(c fiber.Ctx) string {
+	if o.resolver == nil {
+		return c.IP()
+	}
+	return o.resolver.ClientIP(c.RequestCtx().RemoteIP().String(), func(name string) string {
+		return c.Get(name)
+	})
+}
@@ -0,0 +1,308 @@
+package fiber
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v3"
+	"github.com/lborres/kuta"
+	"github.com/lborres/kuta/pkg/clientip"
+	"github.com/lborres/kuta/services"
+)
+
+// Requirement: Options fall back to kuta defaults for unset fields.
+func TestOptions_Resolve(t *testing.T) {
+	opts, err := Options{}.resolve()
+	if err != nil {
+		t.Fatalf("resolve() error = %v", err)
+	}
+	if opts.CookieName != defaultCookieName {
+		t.Errorf("CookieName = %q, want %q", opts.CookieName, defaultCookieName)
+	}
+	if opts.ProxyHeader != fiber.HeaderXForwardedFor {
+		t.Errorf("ProxyHeader = %q, want %q", opts.ProxyHeader, fiber.HeaderXForwardedFor)
+	}
+
+	custom, _ := Options{CookieName: "sid", ProxyHeader: "X-Real-IP"}.resolve()
+	if custom.CookieName != "sid" || custom.ProxyHeader != "X-Real-IP" {
+		t.Errorf("resolve() should keep explicit values; got %+v", custom)
+	}
+
+	if _, err := (Options{TrustedProxies: []string{"nope"}}).resolve(); !errors.Is(err, clientip.ErrInvalidProxy) {
+		t.Errorf("resolve() with invalid proxy error = %v, want ErrInvalidProxy", err)
+	}
+}
+
+// Requirement: clientIP only trusts forwarding headers from trusted proxies.
+func TestOptions_ClientIP(t *testing.T) {
+	tests := []struct {
+		name   string
+		opts   Options
+		header string
+		want   string
+	}{
+		{name: "ignores forwarded header by default", opts: Options{}, header: "203.0.113.7", want: "0.0.0.0"},
+		{name: "uses forwarded address when all proxies are trusted", opts: Options{TrustProxy: true}, header: "203.0.113.7, 10.0.0.1", want: "203.0.113.7"},
+		{name: "uses forwarded address from a trusted proxy", opts: Options{TrustedProxies: []string{"0.0.0.0"}}, header: "203.0.113.7", want: "203.0.113.7"},
+		{name: "ignores forwarded address from an untrusted proxy", opts: Options{TrustedProxies: []string{"10.0.0.0/8"}}, header: "203.0.113.7", want: "0.0.0.0"},
+		{name: "falls back to remote address when header is missing", opts: Options{TrustProxy: true}, header: "", want: "0.0.0.0"},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			// Arrange
+			opts, err := test.opts.resolve()
+			if err != nil {
+				t.Fatalf("resolve() error = %v", err)
+			}
+			app := fiber.New()
+			app.Get("/ip", func(c fiber.Ctx) error {
+				return c.SendString(opts.clientIP(c))
+			})
+			req := httptest.NewRequest("GET", "/ip", nil)
+			if test.header != "" {
+				req.Header.Set(fiber.HeaderXForwardedFor, test.header)
+			}
+
+			// Act
+			resp, err := app.Test(req)
+			if err != nil {
+				t.Fatalf("app.Test() error = %v", err)
+			}
+			body, _ := io.ReadAll(resp.Body)
+
+			// Assert
+			if string(body) != test.want {
+				t.Errorf("clientIP() = %q, want %q", string(body), test.want)
+			}
+		})
+	}
+}
+
+// Requirement: CORS configs that browsers reject or that expose cookies to any origin fail fast.
+func TestOptions_Resolve_CORS(t *testing.T) {
+	tests := []struct {
+		name    string
+		opts    Options
+		wantErr bool
+	}{
+		{name: "explicit origin with cookies", opts: Options{SetCookie: true, CORS: &kuta.CORSConfig{AllowedOrigins: []string{"https://app.example.com"}}}},
+		{name: "wildcard without cookies", opts: Options{CORS: &kuta.CORSConfig{AllowedOrigins: []string{"*"}}}},
+		{name: "wildcard with cookies", opts: Options{SetCookie: true, CORS: &kuta.CORSConfig{AllowedOrigins: []string{"*"}}}, wantErr: true},
+		{name: "no origins", opts: Options{CORS: &kuta.CORSConfig{}}, wantErr: true},
+		{name: "origin with path", opts: Options{CORS: &kuta.CORSConfig{AllowedOrigins: []string{"https://app.example.com/login"}}}, wantErr: true},
+		{name: "origin with trailing slash", opts: Options{CORS: &kuta.CORSConfig{AllowedOrigins: []string{"https://app.example.com/"}}}, wantErr: true},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			_, err := test.opts.resolve()
+			if test.wantErr != errors.Is(err, kuta.ErrInvalidCORSConfig) {
+				t.Errorf("resolve() error = %v, wantErr %v", err, test.wantErr)
+			}
+		})
+	}
+}
+
+// Requirement: CORS headers are sent on auth routes only, with credentials matching the cookie mode.
+func TestAdapter_CORS(t *testing.T) {
+	tests := []struct {
+		name            string
+		origin          string
+		path            string
+		setCookie       bool
+		wantAllowOrigin string
+		wantCredentials string
+	}{
+		{name: "allowed origin with cookies", origin: "https://app.example.com", path: "/api/auth/session", setCookie: true, wantAllowOrigin: "https://app.example.com", wantCredentials: "true"},
+		{name: "allowed origin without cookies", origin: "https://app.example.com", path: "/api/auth/session", wantAllowOrigin: "https://app.example.com"},
+		{name: "unknown origin", origin: "https://evil.example.com", path: "/api/auth/session", setCookie: true},
+		{name: "route outside auth group", origin: "https://app.example.com", path: "/other", setCookie: true},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			// Arrange
+			app := fiber.New()
+			adapter := New(app, Options{
+				SetCookie: test.setCookie,
+				CORS:      &kuta.CORSConfig{AllowedOrigins: []string{"https://app.example.com"}},
+			})
+			if err := adapter.RegisterRoutes(&mockAuthProvider{}, "/api/auth", 0); err != nil {
+				t.Fatalf("RegisterRoutes() error = %v", err)
+			}
+			app.Options("/other", func(c fiber.Ctx) error { return c.SendStatus(http.StatusNoContent) })
+			req := httptest.NewRequest(http.MethodOptions, test.path, nil)
+			req.Header.Set(fiber.HeaderOrigin, test.origin)
+			req.Header.Set(fiber.HeaderAccessControlRequestMethod, http.MethodGet)
+
+			// Act
+			resp, err := app.Test(req)
+			if err != nil {
+				t.Fatalf("app.Test() error = %v", err)
+			}
+
+			// Assert
+			if got := resp.Header.Get(fiber.HeaderAccessControlAllowOrigin); got != test.wantAllowOrigin {
+				t.Errorf("Access-Control-Allow-Origin = %q, want %q", got, test.wantAllowOrigin)
+			}
+			if got := resp.Header.Get(fiber.HeaderAccessControlAllowCredentials); got != test.wantCredentials {
+				t.Errorf("Access-Control-Allow-Credentials = %q, want %q", got, test.wantCredentials)
+			}
+		})
+	}
+}
+
+// Requirement: Route groups mount endpoints under several prefixes with their own middleware.
+func TestAdapter_RouteGroups(t *testing.T) {
+	blockInternal := func(c fiber.Ctx) error {
+		if c.Get("X-Internal") != "yes" {
+			return c.SendStatus(http.StatusForbidden)
+		}
+		return c.Next()
+	}
+
+	tests := []struct {
+		name       string
+		path       string
+		internal   bool
+		wantStatus int
+	}{
+		{name: "public group", path: "/api/auth/sign-out", wantStatus: http.StatusUnauthorized},
+		{name: "internal group without header", path: "/internal/auth/sign-out", wantStatus: http.StatusForbidden},
+		{name: "internal group with header", path: "/internal/auth/sign-out", internal: true, wantStatus: http.StatusUnauthorized},
+		{name: "operation not included in internal group", path: "/internal/auth/sign-in", internal: true, wantStatus: http.StatusNotFound},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			// Arrange
+			app := fiber.New()
+			adapter := New(app, Options{RouteGroups: []kuta.RouteGroup{
+				{Prefix: "/api/auth"},
+				{Prefix: "/internal/auth", Middleware: []interface{}{fiber.Handler(blockInternal)}, Include: []string{"signOut"}},
+			}})
+			if err := adapter.RegisterRoutes(&mockAuthProvider{}, "/ignored", 0); err != nil {
+				t.Fatalf("RegisterRoutes() error = %v", err)
+			}
+			req := httptest.NewRequest(http.MethodPost, test.path, nil)
+			if test.internal {
+				req.Header.Set("X-Internal", "yes")
+			}
+
+			// Act
+			resp, err := app.Test(req)
+			if err != nil {
+				t.Fatalf("app.Test() error = %v", err)
+			}
+
+			// Assert
+			if resp.StatusCode != test.wantStatus {
+				t.Errorf("status = %d, want %d", resp.StatusCode, test.wantStatus)
+			}
+		})
+	}
+}
+
+// Requirement: Route group middleware must be a fiber.Handler.
+func TestAdapter_RouteGroups_InvalidMiddleware(t *testing.T) {
+	adapter := New(fiber.New(), Options{RouteGroups: []kuta.RouteGroup{
+		{Prefix: "/api/auth", Middleware: []interface{}{"not a handler"}},
+	}})
+
+	if err := adapter.RegisterRoutes(&mockAuthProvider{}, "/api/auth", 0); err == nil {
+		t.Error("RegisterRoutes() should reject non-fiber middleware")
+	}
+}
+
+// Requirement: Every built-in endpoint, including token refresh, is mounted
+// by RegisterRoutes.
+func TestAdapter_RegisterRoutes_MountsBaseEndpoints(t *testing.T) {
+	// Arrange
+	app := fiber.New()
+	mock := &mockAuthProvider{
+		signUpResult:  &kuta.SignUpResult{},
+		signInResult:  &kuta.SignInResult{Session: &kuta.Session{}},
+		refreshResult: &kuta.RefreshResult{Session: &kuta.Session{}},
+	}
+	if err := New(app).RegisterRoutes(mock, "/api/auth", 0); err != nil {
+		t.Fatalf("RegisterRoutes() error = %v", err)
+	}
+
+	for _, endpoint := range services.BaseEndpoints() {
+		t.Run(endpoint.Metadata.OperationID, func(t *testing.T) {
+			req := httptest.NewRequest(endpoint.Method, "/api/auth"+endpoint.Path, strings.NewReader(`{"refreshToken":"rt"}`))
+			req.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+
+			// Act
+			resp, err := app.Test(req)
+			if err != nil {
+				t.Fatalf("app.Test() error = %v", err)
+			}
+
+			// Assert
+			if resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusMethodNotAllowed {
+				t.Errorf("%s %s not mounted: status %d", endpoint.Method, endpoint.Path, resp.StatusCode)
+			}
+		})
+	}
+
+	if mock.refreshToken != "rt" {
+		t.Errorf("expected refresh route to reach the provider, got token %q", mock.refreshToken)
+	}
+}
+
+// Requirement: The protected middleware lets draining sessions read but not mutate.
+func TestAdapter_ProtectedMiddleware_DrainingSession(t *testing.T) {
+	revokedAt := time.Now()
+
+	tests := []struct {
+		name       string
+		method     string
+		draining   bool
+		wantStatus int
+	}{
+		{name: "active session mutates", method: http.MethodPost, wantStatus: http.StatusOK},
+		{name: "draining session reads", method: http.MethodGet, draining: true, wantStatus: http.StatusOK},
+		{name: "draining session mutates", method: http.MethodPost, draining: true, wantStatus: http.StatusUnauthorized},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// Arrange
+			session := &kuta.Session{ID: "s1", ExpiresAt: time.Now().Add(time.Hour)}
+			if test.draining {
+				session.RevokedAt = &revokedAt
+			}
+			mock := &mockAuthProvider{getSessionData: &kuta.SessionData{User: &kuta.User{ID: "u1"}, Session: session}}
+			app := fiber.New()
+			adapter := New(app, Options{})
+			protected := adapter.BuildProtectedMiddleware(mock).(func(fiber.Ctx) error)
+			app.Add([]string{test.method}, "/resource", protected, func(c fiber.Ctx) error {
+				return c.SendStatus(http.StatusOK)
+			})
+			req := httptest.NewRequest(test.method, "/resource", nil)
+			req.Header.Set(fiber.HeaderAuthorization, "Bearer tok")
+
+			// Act
+			resp, err := app.Test(req)
+			if err != nil {
+				t.Fatalf("app.Test() error = %v", err)
+			}
+
+			// Assert
+			if resp.StatusCode != test.wantStatus {
+				t.Errorf("status = %d, want %d", resp.StatusCode, test.wantStatus)
+			}
+		})
+	}
+}
@@ -0,0 +1,48 @@
+package fiber
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/gofiber/fiber/v3"
+	"github.com/lborres/kuta"
+)
+
+var _ kuta.ConfigDiagnoser = (*Adapter)(nil)
+
+// DiagnoseConfig checks the cookie and CORS options for combinations
+// browsers reject or that silently drop the session cookie
+func (a *Adapter) DiagnoseConfig() []kuta.Diagnostic {
+	var report kuta.DiagnosticsReport
+	o := a.opts
+
+	if a.optsErr != nil {
+		check := kuta.DiagnosticHTTP
+		if errors.Is(a.optsErr, kuta.ErrInvalidCORSConfig) {
+			check = kuta.DiagnosticCORS
+		}
+		report.Add(check, kuta.SeverityError, "%v", a.optsErr)
+	}
+
+	if !o.SetCookie {
+		return report.Diagnostics
+	}
+
+	sameSite := strings.ToLower(o.CookieSameSite)
+	switch sameSite {
+	case "lax", "strict", "none":
+	default:
+		report.Add(kuta.DiagnosticCookie, kuta.SeverityError, "CookieSameSite %q is not Lax, Strict or None", o.CookieSameSite)
+	}
+	if sameSite == "none" && o.CookieInsecure {
+		report.Add(kuta.DiagnosticCookie, kuta.SeverityError, "browsers reject SameSite=None cookies without Secure; unset CookieInsecure")
+	} else if o.CookieInsecure {
+		report.Add(kuta.DiagnosticCookie, kuta.SeverityWarning, "CookieInsecure sends the session cookie over plain HTTP; only use it in development")
+	}
+	if o.CORS != nil && sameSite != strings.ToLower(fiber.CookieSameSiteNoneMode) {
+		report.Add(kuta.DiagnosticCORS, kuta.SeverityWarning,
+			"cross-site requests won't carry a SameSite=%s session cookie; use None if the allowed origins are on another site", o.CookieSameSite)
+	}
+
+	return report.Diagnostics
+}
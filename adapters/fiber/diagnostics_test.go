@@ -0,0 +1,66 @@
+package fiber
+
+import (
+	"testing"
+
+	"github.com/gofiber/fiber/v3"
+	"github.com/lborres/kuta"
+)
+
+// Requirement: The adapter flags cookie and CORS options that browsers
+// reject or that keep the session cookie off cross-site requests.
+func TestAdapter_DiagnoseConfig(t *testing.T) {
+	tests := []struct {
+		name string
+		opts Options
+		want []kuta.Diagnostic
+	}{
+		{name: "defaults", opts: Options{}},
+		{name: "secure cookie", opts: Options{SetCookie: true}},
+		{
+			name: "none without secure",
+			opts: Options{SetCookie: true, CookieSameSite: "None", CookieInsecure: true},
+			want: []kuta.Diagnostic{{Check: kuta.DiagnosticCookie, Severity: kuta.SeverityError}},
+		},
+		{
+			name: "insecure cookie",
+			opts: Options{SetCookie: true, CookieInsecure: true},
+			want: []kuta.Diagnostic{{Check: kuta.DiagnosticCookie, Severity: kuta.SeverityWarning}},
+		},
+		{
+			name: "unknown same site",
+			opts: Options{SetCookie: true, CookieSameSite: "Loose"},
+			want: []kuta.Diagnostic{{Check: kuta.DiagnosticCookie, Severity: kuta.SeverityError}},
+		},
+		{
+			name: "cross-site cors with lax cookie",
+			opts: Options{SetCookie: true, CORS: &kuta.CORSConfig{AllowedOrigins: []string{"https://app.example.com"}}},
+			want: []kuta.Diagnostic{{Check: kuta.DiagnosticCORS, Severity: kuta.SeverityWarning}},
+		},
+		{
+			name: "invalid cors",
+			opts: Options{CORS: &kuta.CORSConfig{}},
+			want: []kuta.Diagnostic{{Check: kuta.DiagnosticCORS, Severity: kuta.SeverityError}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			adapter := New(fiber.New(), tt.opts)
+
+			// Act
+			got := adapter.DiagnoseConfig()
+
+			// Assert
+			if len(got) != len(tt.want) {
+				t.Fatalf("DiagnoseConfig() = %+v, want %+v", got, tt.want)
+			}
+			for i := range got {
+				if got[i].Check != tt.want[i].Check || got[i].Severity != tt.want[i].Severity {
+					t.Errorf("diagnostic %d = %+v, want %+v", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
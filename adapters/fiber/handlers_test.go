@@ -1,11 +1,21 @@
 package fiber
 
 import (
-	"errors"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"mime"
+	"mime/multipart"
 	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/gofiber/fiber/v3"
 	"github.com/lborres/kuta"
+	"github.com/lborres/kuta/services"
 )
 
 // mockAuthProvider is a test fake implementing kuta.AuthProvider interface
@@ -18,6 +28,10 @@ type mockAuthProvider struct {
 	signInInput      kuta.SignInInput
 	signInErr        error
 	signInResult     *kuta.SignInResult
+	continueCalled   bool
+	continueInput    kuta.ContinueSignInInput
+	continueErr      error
+	continueResult   *kuta.SignInResult
 	signOutCalled    bool
 	signOutToken     string
 	signOutErr       error
@@ -29,33 +43,46 @@ type mockAuthProvider struct {
 	refreshToken     string
 	refreshErr       error
 	refreshResult    *kuta.RefreshResult
+	ipAddress        string
+	userAgent        string
 }
 
-func (m *mockAuthProvider) SignUp(input kuta.SignUpInput, ipAddress, userAgent string) (*kuta.SignUpResult, error) {
+func (m *mockAuthProvider) SignUp(ctx context.Context, input kuta.SignUpInput, ipAddress, userAgent string) (*kuta.SignUpResult, error) {
 	m.signUpCalled = true
 	m.signUpInput = input
+	m.ipAddress, m.userAgent = ipAddress, userAgent
 	if m.signUpErr != nil {
 		return nil, m.signUpErr
 	}
 	return m.signUpResult, nil
 }
 
-func (m *mockAuthProvider) SignIn(input kuta.SignInInput, ipAddress, userAgent string) (*kuta.SignInResult, error) {
+func (m *mockAuthProvider) SignIn(ctx context.Context, input kuta.SignInInput, ipAddress, userAgent string) (*kuta.SignInResult, error) {
 	m.signInCalled = true
 	m.signInInput = input
+	m.ipAddress, m.userAgent = ipAddress, userAgent
 	if m.signInErr != nil {
 		return nil, m.signInErr
 	}
 	return m.signInResult, nil
 }
 
-func (m *mockAuthProvider) SignOut(token string) error {
+func (m *mockAuthProvider) ContinueSignIn(ctx context.Context, input kuta.ContinueSignInInput) (*kuta.SignInResult, error) {
+	m.continueCalled = true
+	m.continueInput = input
+	if m.continueErr != nil {
+		return nil, m.continueErr
+	}
+	return m.continueResult, nil
+}
+
+func (m *mockAuthProvider) SignOut(ctx context.Context, token string) error {
 	m.signOutCalled = true
 	m.signOutToken = token
 	return m.signOutErr
 }
 
-func (m *mockAuthProvider) GetSession(token string) (*kuta.SessionData, error) {
+func (m *mockAuthProvider) GetSession(ctx context.Context, token string) (*kuta.SessionData, error) {
 	m.getSessionCalled = true
 	m.getSessionToken = token
 	if m.getSessionErr != nil {
@@ -64,7 +91,7 @@ func (m *mockAuthProvider) GetSession(token string) (*kuta.SessionData, error) {
 	return m.getSessionData, nil
 }
 
-func (m *mockAuthProvider) Refresh(token string) (*kuta.RefreshResult, error) {
+func (m *mockAuthProvider) Refresh(ctx context.Context, token string) (*kuta.RefreshResult, error) {
 	m.refreshCalled = true
 	m.refreshToken = token
 	if m.refreshErr != nil {
@@ -73,295 +100,703 @@ func (m *mockAuthProvider) Refresh(token string) (*kuta.RefreshResult, error) {
 	return m.refreshResult, nil
 }
 
-// Requirement: Handler factories return functions matching the framework-agnostic signature
-func TestHandlerFactories_ReturnCorrectSignature(t *testing.T) {
+// baseHandler mounts the shared handler of a base endpoint on a Fiber route
+func baseHandler(operationID string, auth kuta.AuthProvider, opts Options) fiber.Handler {
+	handler := services.BaseHandlers(opts.handlerOptions())[operationID]
+	return func(c fiber.Ctx) error {
+		return handler(&kuta.RequestContext{Request: request{c: c}, Response: response{c: c}, Native: c, Auth: auth})
+	}
+}
+
+// Requirement: Sign-up binds the JSON body, passes the client's IP and user
+// agent to the provider, and responds 201 with the session cookie set.
+func TestHandleSignUpFiber_CallsAuthProviderSignUp(t *testing.T) {
 	tests := []struct {
-		name    string
-		factory func(kuta.AuthProvider) func(*kuta.RequestContext) error
+		name       string
+		body       any
+		signUpErr  error
+		wantCalled bool
+		wantStatus int
+		wantCookie bool
+		wantError  string
 	}{
 		{
-			name:    "handleSignUpFiber returns framework-agnostic handler",
-			factory: handleSignUpFiber,
+			name:       "creates user and session",
+			body:       map[string]string{"email": "a@b.c", "password": "pw", "name": "Ada"},
+			wantCalled: true,
+			wantStatus: http.StatusCreated,
+			wantCookie: true,
 		},
 		{
-			name:    "handleSignInFiber returns framework-agnostic handler",
-			factory: handleSignInFiber,
+			name:       "maps provider error to status",
+			body:       map[string]string{"email": "a@b.c", "password": "pw"},
+			signUpErr:  kuta.ErrUserExists,
+			wantCalled: true,
+			wantStatus: http.StatusConflict,
+			wantError:  kuta.ErrUserExists.Error(),
 		},
 		{
-			name:    "handleSignOutFiber returns framework-agnostic handler",
-			factory: handleSignOutFiber,
-		},
-		{
-			name:    "handleGetSessionFiber returns framework-agnostic handler",
-			factory: handleGetSessionFiber,
-		},
-		{
-			name:    "handleRefreshFiber returns framework-agnostic handler",
-			factory: handleRefreshFiber,
+			name:       "rejects malformed body",
+			body:       `{"email":`,
+			wantStatus: http.StatusBadRequest,
+			wantError:  "invalid request body",
 		},
 	}
 
 	for _, test := range tests {
-		test := test
 		t.Run(test.name, func(t *testing.T) {
 			// Arrange
-			mock := &mockAuthProvider{}
+			mock := &mockAuthProvider{
+				signUpResult: &kuta.SignUpResult{
+					User:    &kuta.User{ID: "u1", Email: "a@b.c"},
+					Session: &kuta.Session{ExpiresAt: time.Now().Add(time.Hour)},
+					Token:   "tok",
+				},
+				signUpErr: test.signUpErr,
+			}
+			server := newTestServer(t, mock, Options{SetCookie: true})
 
 			// Act
-			handler := test.factory(mock)
+			resp := server.do(testRequest{
+				Method:  http.MethodPost,
+				Path:    "/sign-up",
+				Body:    test.body,
+				Headers: map[string]string{fiber.HeaderUserAgent: "test-agent"},
+			})
 
 			// Assert
-			if handler == nil {
-				t.Fatalf("Handler factory should return non-nil function")
+			if resp.Status != test.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", resp.Status, test.wantStatus, resp.Body)
+			}
+			if mock.signUpCalled != test.wantCalled {
+				t.Errorf("provider called = %v, want %v", mock.signUpCalled, test.wantCalled)
+			}
+			if test.wantCalled && (mock.signUpInput.Email != "a@b.c" || mock.userAgent != "test-agent" || mock.ipAddress == "") {
+				t.Errorf("provider got input %+v from %q/%q", mock.signUpInput, mock.ipAddress, mock.userAgent)
+			}
+			if got := resp.cookie(defaultCookieName) != nil; got != test.wantCookie {
+				t.Errorf("session cookie set = %v, want %v", got, test.wantCookie)
+			}
+			if test.wantError != "" {
+				var body struct{ Error string }
+				resp.decode(t, &body)
+				if body.Error != test.wantError {
+					t.Errorf("error = %q, want %q", body.Error, test.wantError)
+				}
+			} else {
+				var body kuta.SignUpResult
+				resp.decode(t, &body)
+				if body.User == nil || body.User.Email != "a@b.c" || body.Token != "tok" {
+					t.Errorf("unexpected body %s", resp.Body)
+				}
 			}
 		})
 	}
 }
 
-// Requirement: handleSignUpFiber calls authProvider.SignUp when provided valid context
-func TestHandleSignUpFiber_CallsAuthProviderSignUp(t *testing.T) {
+// Requirement: Sign-in responds with the session and sets a secure,
+// HTTP-only cookie; failures carry the provider's status and no cookie.
+func TestHandleSignInFiber_CallsAuthProviderSignIn(t *testing.T) {
 	tests := []struct {
 		name       string
-		setupMock  func(*mockAuthProvider)
-		wantCalled bool
+		body       any
+		signInErr  error
+		wantStatus int
+		wantCookie bool
 	}{
 		{
-			name: "handler is successfully created from factory",
-			setupMock: func(m *mockAuthProvider) {
-				m.signUpResult = &kuta.SignUpResult{}
-			},
-			wantCalled: false,
+			name:       "issues session cookie",
+			body:       map[string]string{"email": "a@b.c", "password": "pw"},
+			wantStatus: http.StatusOK,
+			wantCookie: true,
 		},
 		{
-			name: "handler factory accepts authProvider without error",
-			setupMock: func(m *mockAuthProvider) {
-				m.signUpErr = kuta.ErrInvalidCredentials
-			},
-			wantCalled: false,
+			name:       "invalid credentials",
+			body:       map[string]string{"email": "a@b.c", "password": "wrong"},
+			signInErr:  kuta.ErrInvalidCredentials,
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name:       "rate limited",
+			body:       map[string]string{"email": "a@b.c", "password": "pw"},
+			signInErr:  kuta.ErrRateLimited,
+			wantStatus: http.StatusTooManyRequests,
+		},
+		{
+			name:       "malformed body",
+			body:       "not json",
+			wantStatus: http.StatusBadRequest,
 		},
 	}
 
 	for _, test := range tests {
-		test := test
 		t.Run(test.name, func(t *testing.T) {
 			// Arrange
-			mock := &mockAuthProvider{}
-			test.setupMock(mock)
+			mock := &mockAuthProvider{
+				signInResult: &kuta.SignInResult{
+					User:    &kuta.User{ID: "u1"},
+					Session: &kuta.Session{ExpiresAt: time.Now().Add(time.Hour)},
+					Token:   "tok",
+				},
+				signInErr: test.signInErr,
+			}
+			server := newTestServer(t, mock, Options{SetCookie: true})
 
-			// Act: Create the handler (factory pattern)
-			handler := handleSignUpFiber(mock)
+			// Act
+			resp := server.do(testRequest{Method: http.MethodPost, Path: "/sign-in", Body: test.body})
 
-			// Assert: Handler was created successfully
-			if handler == nil {
-				t.Errorf("handleSignUpFiber factory should return non-nil handler")
+			// Assert
+			if resp.Status != test.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", resp.Status, test.wantStatus, resp.Body)
+			}
+			cookie := resp.cookie(defaultCookieName)
+			if got := cookie != nil; got != test.wantCookie {
+				t.Fatalf("session cookie set = %v, want %v", got, test.wantCookie)
+			}
+			if cookie != nil && (cookie.Value != "tok" || !cookie.HttpOnly || !cookie.Secure) {
+				t.Errorf("unexpected cookie %+v", cookie)
+			}
+			if test.wantStatus == http.StatusOK && mock.signInInput.Password != "pw" {
+				t.Errorf("provider got input %+v", mock.signInInput)
 			}
 		})
 	}
 }
 
-// Requirement: handleSignInFiber returns handler from factory
-func TestHandleSignInFiber_CallsAuthProviderSignIn(t *testing.T) {
+// Requirement: Sign-out takes the token from the bearer header or the
+// session cookie and clears the cookie.
+func TestHandleSignOutFiber_CallsAuthProviderSignOut(t *testing.T) {
 	tests := []struct {
-		name      string
-		setupMock func(*mockAuthProvider)
+		name       string
+		headers    map[string]string
+		cookies    []*http.Cookie
+		signOutErr error
+		wantStatus int
+		wantToken  string
 	}{
 		{
-			name: "handler is successfully created from factory",
-			setupMock: func(m *mockAuthProvider) {
-				m.signInResult = &kuta.SignInResult{}
-			},
+			name:       "bearer token",
+			headers:    map[string]string{fiber.HeaderAuthorization: "Bearer tok"},
+			wantStatus: http.StatusOK,
+			wantToken:  "tok",
+		},
+		{
+			name:       "cookie token",
+			cookies:    []*http.Cookie{{Name: defaultCookieName, Value: "cookie-tok"}},
+			wantStatus: http.StatusOK,
+			wantToken:  "cookie-tok",
 		},
 		{
-			name: "handler factory works even when mock would return error",
-			setupMock: func(m *mockAuthProvider) {
-				m.signInErr = kuta.ErrInvalidCredentials
-			},
+			name:       "invalid token",
+			headers:    map[string]string{fiber.HeaderAuthorization: "Bearer tok"},
+			signOutErr: kuta.ErrInvalidToken,
+			wantStatus: http.StatusUnauthorized,
+			wantToken:  "tok",
+		},
+		{
+			name:       "missing token",
+			wantStatus: http.StatusUnauthorized,
 		},
 	}
 
 	for _, test := range tests {
-		test := test
 		t.Run(test.name, func(t *testing.T) {
 			// Arrange
-			mock := &mockAuthProvider{}
-			test.setupMock(mock)
+			mock := &mockAuthProvider{signOutErr: test.signOutErr}
+			server := newTestServer(t, mock, Options{SetCookie: true})
 
-			// Act: Create the handler (factory pattern)
-			handler := handleSignInFiber(mock)
+			// Act
+			resp := server.do(testRequest{Method: http.MethodPost, Path: "/sign-out", Headers: test.headers, Cookies: test.cookies})
 
-			// Assert: Handler was created successfully
-			if handler == nil {
-				t.Errorf("handleSignInFiber factory should return non-nil handler")
+			// Assert
+			if resp.Status != test.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", resp.Status, test.wantStatus, resp.Body)
+			}
+			if mock.signOutToken != test.wantToken {
+				t.Errorf("provider got token %q, want %q", mock.signOutToken, test.wantToken)
+			}
+			cleared := resp.cookie(defaultCookieName)
+			if test.wantStatus == http.StatusOK && (cleared == nil || cleared.Value != "") {
+				t.Errorf("expected session cookie to be cleared, got %+v", cleared)
 			}
 		})
 	}
 }
 
-// Requirement: handleSignOutFiber returns handler from factory
-func TestHandleSignOutFiber_CallsAuthProviderSignOut(t *testing.T) {
+// Requirement: Get-session returns the user and session for a valid token.
+func TestHandleGetSessionFiber_CallsAuthProviderGetSession(t *testing.T) {
 	tests := []struct {
-		name      string
-		setupMock func(*mockAuthProvider)
+		name          string
+		headers       map[string]string
+		getSessionErr error
+		wantStatus    int
 	}{
 		{
-			name:      "handler is successfully created from factory",
-			setupMock: func(m *mockAuthProvider) {},
+			name:       "valid token",
+			headers:    map[string]string{fiber.HeaderAuthorization: "Bearer tok"},
+			wantStatus: http.StatusOK,
 		},
 		{
-			name: "handler factory works even when mock would return error",
-			setupMock: func(m *mockAuthProvider) {
-				m.signOutErr = kuta.ErrInvalidToken
-			},
+			name:          "expired session",
+			headers:       map[string]string{fiber.HeaderAuthorization: "Bearer tok"},
+			getSessionErr: kuta.ErrSessionExpired,
+			wantStatus:    http.StatusUnauthorized,
+		},
+		{
+			name:       "malformed authorization header",
+			headers:    map[string]string{fiber.HeaderAuthorization: "Basic abc"},
+			wantStatus: http.StatusUnauthorized,
 		},
 	}
 
 	for _, test := range tests {
-		test := test
 		t.Run(test.name, func(t *testing.T) {
 			// Arrange
-			mock := &mockAuthProvider{}
-			test.setupMock(mock)
+			mock := &mockAuthProvider{
+				getSessionData: &kuta.SessionData{User: &kuta.User{ID: "u1", Email: "a@b.c"}, Session: &kuta.Session{ID: "s1"}},
+				getSessionErr:  test.getSessionErr,
+			}
+			server := newTestServer(t, mock, Options{})
 
-			// Act: Create the handler (factory pattern)
-			handler := handleSignOutFiber(mock)
+			// Act
+			resp := server.do(testRequest{Method: http.MethodGet, Path: "/session", Headers: test.headers})
 
-			// Assert: Handler was created successfully
-			if handler == nil {
-				t.Errorf("handleSignOutFiber factory should return non-nil handler")
+			// Assert
+			if resp.Status != test.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", resp.Status, test.wantStatus, resp.Body)
+			}
+			if test.wantStatus != http.StatusOK {
+				return
+			}
+			var body kuta.SessionData
+			resp.decode(t, &body)
+			if body.User == nil || body.User.Email != "a@b.c" || body.Session == nil || body.Session.ID != "s1" {
+				t.Errorf("unexpected body %s", resp.Body)
+			}
+			if mock.getSessionToken != "tok" {
+				t.Errorf("provider got token %q, want tok", mock.getSessionToken)
 			}
 		})
 	}
 }
 
-// Requirement: handleGetSessionFiber returns handler from factory
-func TestHandleGetSessionFiber_CallsAuthProviderGetSession(t *testing.T) {
+// Requirement: Refresh returns the rotated tokens and replaces the session
+// cookie.
+func TestHandleRefreshFiber_CallsAuthProviderRefresh(t *testing.T) {
 	tests := []struct {
-		name      string
-		setupMock func(*mockAuthProvider)
+		name       string
+		refreshErr error
+		wantStatus int
+		wantCookie bool
 	}{
-		{
-			name: "handler is successfully created from factory",
-			setupMock: func(m *mockAuthProvider) {
-				m.getSessionData = &kuta.SessionData{}
-			},
-		},
-		{
-			name: "handler factory works even when mock would return error",
-			setupMock: func(m *mockAuthProvider) {
-				m.getSessionErr = kuta.ErrSessionExpired
-			},
-		},
+		{name: "rotates tokens", wantStatus: http.StatusOK, wantCookie: true},
+		{name: "reused token", refreshErr: kuta.ErrInvalidToken, wantStatus: http.StatusUnauthorized},
 	}
 
 	for _, test := range tests {
-		test := test
 		t.Run(test.name, func(t *testing.T) {
 			// Arrange
-			mock := &mockAuthProvider{}
-			test.setupMock(mock)
+			mock := &mockAuthProvider{
+				refreshResult: &kuta.RefreshResult{
+					Session:      &kuta.Session{ExpiresAt: time.Now().Add(time.Hour)},
+					Token:        "new-tok",
+					RefreshToken: "new-rt",
+				},
+				refreshErr: test.refreshErr,
+			}
+			server := newTestServer(t, mock, Options{SetCookie: true})
 
-			// Act: Create the handler (factory pattern)
-			handler := handleGetSessionFiber(mock)
+			// Act
+			resp := server.do(testRequest{Method: http.MethodPost, Path: "/refresh", Body: kuta.RefreshRequest{RefreshToken: "rt"}})
 
-			// Assert: Handler was created successfully
-			if handler == nil {
-				t.Errorf("handleGetSessionFiber factory should return non-nil handler")
+			// Assert
+			if resp.Status != test.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", resp.Status, test.wantStatus, resp.Body)
+			}
+			if got := resp.cookie(defaultCookieName) != nil; got != test.wantCookie {
+				t.Errorf("session cookie set = %v, want %v", got, test.wantCookie)
+			}
+			if test.wantStatus != http.StatusOK {
+				return
+			}
+			var body kuta.RefreshResult
+			resp.decode(t, &body)
+			if body.Token != "new-tok" || body.RefreshToken != "new-rt" {
+				t.Errorf("unexpected body %s", resp.Body)
 			}
 		})
 	}
 }
 
-// Requirement: handleRefreshFiber returns handler from factory
-func TestHandleRefreshFiber_CallsAuthProviderRefresh(t *testing.T) {
+// Requirement: the refresh handler reads the refresh token from the JSON body.
+func TestHandleRefreshFiber_ReadsRefreshTokenFromBody(t *testing.T) {
 	tests := []struct {
-		name      string
-		setupMock func(*mockAuthProvider)
+		name       string
+		body       string
+		wantStatus int
+		wantToken  string
 	}{
-		{
-			name: "handler is successfully created from factory",
-			setupMock: func(m *mockAuthProvider) {
-				m.refreshResult = &kuta.RefreshResult{}
-			},
-		},
-		{
-			name: "handler factory works even when mock would return error",
-			setupMock: func(m *mockAuthProvider) {
-				m.refreshErr = kuta.ErrInvalidToken
-			},
-		},
+		{name: "passes refresh token to provider", body: `{"refreshToken":"rt-123"}`, wantStatus: http.StatusOK, wantToken: "rt-123"},
+		{name: "rejects missing refresh token", body: `{}`, wantStatus: http.StatusUnauthorized},
 	}
 
 	for _, test := range tests {
 		test := test
 		t.Run(test.name, func(t *testing.T) {
 			// Arrange
-			mock := &mockAuthProvider{}
-			test.setupMock(mock)
+			mock := &mockAuthProvider{refreshResult: &kuta.RefreshResult{Session: &kuta.Session{}}}
+			app := fiber.New()
+			app.Post("/refresh", baseHandler(kuta.OperationRefreshToken, mock, Options{}))
+			req := httptest.NewRequest("POST", "/refresh", strings.NewReader(test.body))
+			req.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
 
-			// Act: Create the handler (factory pattern)
-			handler := handleRefreshFiber(mock)
+			// Act
+			resp, err := app.Test(req)
+			if err != nil {
+				t.Fatalf("app.Test() error = %v", err)
+			}
 
-			// Assert: Handler was created successfully
-			if handler == nil {
-				t.Errorf("handleRefreshFiber factory should return non-nil handler")
+			// Assert
+			if resp.StatusCode != test.wantStatus {
+				t.Errorf("status = %d, want %d", resp.StatusCode, test.wantStatus)
+			}
+			if mock.refreshToken != test.wantToken {
+				t.Errorf("provider got refresh token %q, want %q", mock.refreshToken, test.wantToken)
 			}
 		})
 	}
 }
 
-// Requirement: mapErrorToStatus maps authentication errors to correct HTTP status codes
-func TestMapErrorToStatus_ErrorMapping(t *testing.T) {
+// Requirement: Responses and errors go through the configured envelope
+func TestHandlers_ResponseEnvelope(t *testing.T) {
 	tests := []struct {
 		name       string
-		err        error
+		envelope   kuta.ResponseEnvelope
+		refreshErr error
 		wantStatus int
+		wantBody   string
+	}{
+		{name: "bare error", refreshErr: kuta.ErrRefreshTokenNotFound, wantStatus: http.StatusUnauthorized, wantBody: `{"error":"refresh token not found"}`},
+		{name: "data envelope success", envelope: kuta.DataEnvelope{}, wantStatus: http.StatusOK, wantBody: `{"data":{"session":{`},
+		{name: "data envelope error", envelope: kuta.DataEnvelope{}, refreshErr: kuta.ErrRefreshTokenNotFound, wantStatus: http.StatusUnauthorized, wantBody: `{"data":null,"error":{"message":"refresh token not found","code":401}}`},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// Arrange
+			mock := &mockAuthProvider{refreshResult: &kuta.RefreshResult{Session: &kuta.Session{}}, refreshErr: test.refreshErr}
+			app := fiber.New()
+			app.Post("/refresh", baseHandler(kuta.OperationRefreshToken, mock, Options{ResponseEnvelope: test.envelope}))
+			req := httptest.NewRequest("POST", "/refresh", strings.NewReader(`{"refreshToken":"rt-123"}`))
+			req.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+
+			// Act
+			resp, err := app.Test(req)
+			if err != nil {
+				t.Fatalf("app.Test() error = %v", err)
+			}
+			body, _ := io.ReadAll(resp.Body)
+
+			// Assert
+			if resp.StatusCode != test.wantStatus {
+				t.Errorf("status = %d, want %d", resp.StatusCode, test.wantStatus)
+			}
+			if !strings.HasPrefix(string(body), test.wantBody) {
+				t.Errorf("body = %s, want prefix %s", body, test.wantBody)
+			}
+		})
+	}
+}
+
+// Requirement: Per-endpoint decoders and encoders reshape payloads without
+// touching the auth provider's input or output types
+func TestHandlers_PayloadTransforms(t *testing.T) {
+	// Arrange
+	mock := &mockAuthProvider{signInResult: &kuta.SignInResult{Session: &kuta.Session{}, Token: "tok"}}
+	opts := Options{Transforms: kuta.PayloadTransforms{
+		Decoders: map[string]kuta.RequestDecoder{kuta.OperationSignIn: kuta.SnakeCaseDecoder},
+		Encoders: map[string]kuta.ResponseEncoder{kuta.OperationSignIn: func(body any) (any, error) {
+			return map[string]any{"access_token": body.(*kuta.SignInResult).Token}, nil
+		}},
+	}}
+	app := fiber.New()
+	app.Post("/sign-in", baseHandler(kuta.OperationSignIn, mock, opts))
+	req := httptest.NewRequest("POST", "/sign-in", strings.NewReader(`{"email":"a@b.c","password":"pw","public_key":"pk"}`))
+	req.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+
+	// Act
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatalf("app.Test() error = %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+
+	// Assert
+	if mock.signInInput.PublicKey != "pk" {
+		t.Errorf("expected decoder to map public_key, got %+v", mock.signInInput)
+	}
+	if string(body) != `{"access_token":"tok"}` {
+		t.Errorf("body = %s", body)
+	}
+}
+
+// Requirement: With TokenHeader set, the session token is sent in the header
+// and left out of the JSON body
+func TestHandlers_TokenHeader(t *testing.T) {
+	tests := []struct {
+		name       string
+		header     string
+		wantHeader string
+		wantInBody bool
+	}{
+		{name: "token in body by default", wantInBody: true},
+		{name: "token in header", header: kuta.DefaultTokenHeader, wantHeader: "tok"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// Arrange
+			result := &kuta.SignInResult{Session: &kuta.Session{}, Token: "tok"}
+			mock := &mockAuthProvider{signInResult: result}
+			app := fiber.New()
+			app.Post("/sign-in", baseHandler(kuta.OperationSignIn, mock, Options{TokenHeader: test.header}))
+			req := httptest.NewRequest("POST", "/sign-in", strings.NewReader(`{"email":"a@b.c","password":"pw"}`))
+			req.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+
+			// Act
+			resp, err := app.Test(req)
+			if err != nil {
+				t.Fatalf("app.Test() error = %v", err)
+			}
+			body, _ := io.ReadAll(resp.Body)
+
+			// Assert
+			if got := resp.Header.Get(kuta.DefaultTokenHeader); got != test.wantHeader {
+				t.Errorf("token header = %q, want %q", got, test.wantHeader)
+			}
+			if got := strings.Contains(string(body), `"token":"tok"`); got != test.wantInBody {
+				t.Errorf("token in body = %v, want %v: %s", got, test.wantInBody, body)
+			}
+			if result.Token != "tok" {
+				t.Error("expected provider result to be left untouched")
+			}
+		})
+	}
+}
+
+// Requirement: A challenged sign-in responds with the challenge and no
+// session cookie, and the continue endpoint passes the answer through.
+func TestHandlers_SignInChallenge(t *testing.T) {
+	challenge := &kuta.AuthChallenge{Type: kuta.ChallengeTwoFactor, Token: "challenge-tok"}
+
+	tests := []struct {
+		name       string
+		path       string
+		body       string
+		wantStatus int
+		wantCookie bool
+		wantInBody string
 	}{
 		{
-			name:       "maps ErrInvalidCredentials to 401",
-			err:        kuta.ErrInvalidCredentials,
-			wantStatus: http.StatusUnauthorized,
-		},
-		{
-			name:       "maps ErrUserNotFound to 401",
-			err:        kuta.ErrUserNotFound,
-			wantStatus: http.StatusUnauthorized,
+			name:       "sign-in returns challenge",
+			path:       "/sign-in",
+			body:       `{"email":"a@b.c","password":"pw"}`,
+			wantStatus: http.StatusOK,
+			wantInBody: `"type":"two_factor"`,
 		},
 		{
-			name:       "maps ErrInvalidToken to 401",
-			err:        kuta.ErrInvalidToken,
-			wantStatus: http.StatusUnauthorized,
+			name:       "continue issues session",
+			path:       "/sign-in/continue",
+			body:       `{"challengeToken":"challenge-tok","response":"123456"}`,
+			wantStatus: http.StatusOK,
+			wantCookie: true,
 		},
 		{
-			name:       "maps ErrSessionExpired to 401",
-			err:        kuta.ErrSessionExpired,
+			name:       "continue without token",
+			path:       "/sign-in/continue",
+			body:       `{"response":"123456"}`,
 			wantStatus: http.StatusUnauthorized,
 		},
-		{
-			name:       "maps ErrEmailRequired to 400",
-			err:        kuta.ErrEmailRequired,
-			wantStatus: http.StatusBadRequest,
-		},
-		{
-			name:       "maps ErrPasswordRequired to 400",
-			err:        kuta.ErrPasswordRequired,
-			wantStatus: http.StatusBadRequest,
-		},
-		{
-			name:       "defaults unknown errors to 500",
-			err:        errors.New("unknown error"),
-			wantStatus: http.StatusInternalServerError,
-		},
 	}
 
 	for _, test := range tests {
-		test := test
 		t.Run(test.name, func(t *testing.T) {
+			// Arrange
+			mock := &mockAuthProvider{
+				signInResult:   &kuta.SignInResult{Challenge: challenge},
+				continueResult: &kuta.SignInResult{Session: &kuta.Session{ExpiresAt: time.Now().Add(time.Hour)}, Token: "tok"},
+			}
+			opts := Options{SetCookie: true, CookieName: "auth_token"}
+			app := fiber.New()
+			app.Post("/sign-in", baseHandler(kuta.OperationSignIn, mock, opts))
+			app.Post("/sign-in/continue", baseHandler(kuta.OperationContinueSignIn, mock, opts))
+			req := httptest.NewRequest("POST", test.path, strings.NewReader(test.body))
+			req.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+
+			// Act
+			resp, err := app.Test(req)
+			if err != nil {
+				t.Fatalf("app.Test() error = %v", err)
+			}
+			body, _ := io.ReadAll(resp.Body)
+
+			// Assert
+			if resp.StatusCode != test.wantStatus {
+				t.Errorf("status = %d, want %d: %s", resp.StatusCode, test.wantStatus, body)
+			}
+			if got := resp.Header.Get(fiber.HeaderSetCookie) != ""; got != test.wantCookie {
+				t.Errorf("session cookie set = %v, want %v", got, test.wantCookie)
+			}
+			if !strings.Contains(string(body), test.wantInBody) {
+				t.Errorf("body = %s, want it to contain %s", body, test.wantInBody)
+			}
+			if test.wantCookie && mock.continueInput.Response != "123456" {
+				t.Errorf("response passed = %q, want 123456", mock.continueInput.Response)
+			}
+		})
+	}
+}
+
+// rejectingAuthProvider refuses every request, so a fuzzed request can only
+// get an error response
+func rejectingAuthProvider() *mockAuthProvider {
+	return &mockAuthProvider{
+		signUpErr:     kuta.ErrUserExists,
+		signInErr:     kuta.ErrInvalidCredentials,
+		continueErr:   kuta.ErrChallengeNotFound,
+		signOutErr:    kuta.ErrSessionNotFound,
+		getSessionErr: kuta.ErrSessionNotFound,
+		refreshErr:    kuta.ErrRefreshTokenNotFound,
+	}
+}
+
+// validHeaderValue reports whether v could be sent as a header value, i.e.
+// has no control characters other than tab
+func validHeaderValue(v string) bool {
+	return !strings.ContainsFunc(v, func(r rune) bool {
+		return (r < ' ' && r != '\t') || r == 0x7f
+	})
+}
+
+// expectClientError fails t unless resp is a 4xx with a JSON error body
+func expectClientError(t *testing.T, resp testResponse) {
+	t.Helper()
+	if resp.Status < 400 || resp.Status > 499 {
+		t.Fatalf("status = %d, want 4xx: %s", resp.Status, resp.Body)
+	}
+	var body map[string]any
+	if err := json.Unmarshal(resp.Body, &body); err != nil || body["error"] == nil {
+		t.Fatalf("body = %q, want a JSON error", resp.Body)
+	}
+}
+
+// fuzzBodyPaths are the endpoints reading a request body
+var fuzzBodyPaths = []string{"/sign-up", "/sign-in", "/sign-in/continue", "/refresh"}
+
+// Requirement: Whatever body and content type a request has, the handlers
+// answer with a structured 4xx error rather than panicking.
+func FuzzHandlers_Body(f *testing.F) {
+	f.Add(uint8(0), "application/json", []byte(`{"email":"a@b.c","password":"pw"}`))
+	f.Add(uint8(1), "application/json", []byte(`{"email":`))
+	f.Add(uint8(1), "application/json", []byte(`{"email":["a"],"password":{}}`))
+	f.Add(uint8(2), "application/json", []byte(`null`))
+	f.Add(uint8(3), "application/json", []byte(`{"refreshToken":"\u0000"}`))
+	f.Add(uint8(3), "", []byte(`[]`))
+	f.Add(uint8(0), "application/x-www-form-urlencoded", []byte("email=%zz&password"))
+	f.Add(uint8(0), "multipart/form-data; boundary=x", []byte("--x\r\nContent-Disposition: form-data; name=\"imageUpload\"; filename=\"a.png\"\r\n\r\n\x89PNG\r\n--x--\r\n"))
+	f.Add(uint8(1), "multipart/form-data", []byte("--"))
+	f.Add(uint8(2), "text/plain; charset=", []byte{0xff, 0xfe})
+
+	f.Fuzz(func(t *testing.T, path uint8, contentType string, body []byte) {
+		if !validHeaderValue(contentType) {
+			t.Skip()
+		}
+		// fasthttp refuses malformed multipart bodies before routing
+		if mediaType, params, err := mime.ParseMediaType(contentType); err == nil && mediaType == fiber.MIMEMultipartForm {
+			if _, err := multipart.NewReader(bytes.NewReader(body), params["boundary"]).ReadForm(int64(len(body))); err != nil {
+				t.Skip()
+			}
+		}
+
+		// Arrange
+		server := newTestServer(t, rejectingAuthProvider(), Options{SetCookie: true})
+
+		// Act
+		resp := server.do(testRequest{
+			Method:  http.MethodPost,
+			Path:    fuzzBodyPaths[int(path)%len(fuzzBodyPaths)],
+			Body:    string(body),
+			Headers: map[string]string{"Content-Type": contentType},
+		})
+
+		// Assert
+		expectClientError(t, resp)
+	})
+}
+
+// Requirement: Malformed Authorization headers and session cookies never
+// panic and are refused with a structured 4xx error.
+func FuzzHandlers_Token(f *testing.F) {
+	f.Add("Bearer ", "")
+	f.Add("Bearer", "tok")
+	f.Add("bearer tok", "")
+	f.Add("Basic dXNlcjpwYXNz", "")
+	f.Add("Bearer  tok with spaces", "")
+	f.Add("Bearer tok\xff", "\"quoted\";")
+	f.Add(strings.Repeat("Bearer ", 250), "")
+
+	f.Fuzz(func(t *testing.T, authorization, cookie string) {
+		// fasthttp refuses headers over its read buffer before routing
+		if !validHeaderValue(authorization) || !validHeaderValue(cookie) || len(authorization)+len(cookie) > 2048 {
+			t.Skip()
+		}
+
+		// Arrange
+		server := newTestServer(t, rejectingAuthProvider(), Options{})
+		headers := map[string]string{"Authorization": authorization, "Cookie": "auth_token=" + cookie}
+
+		for _, endpoint := range []struct{ method, path string }{
+			{http.MethodGet, "/session"},
+			{http.MethodPost, "/sign-out"},
+		} {
+			// Act
+			resp := server.do(testRequest{Method: endpoint.method, Path: endpoint.path, Headers: headers})
+
+			// Assert
+			expectClientError(t, resp)
+		}
+	})
+}
+
+// Requirement: Request bodies over MaxBodySize are refused with a structured
+// 413 error, whether their Content-Length declares it or not.
+func TestHandlers_MaxBodySize(t *testing.T) {
+	body := `{"email":"a@b.c","password":"` + strings.Repeat("a", 100) + `"}`
+
+	tests := []struct {
+		name        string
+		maxBodySize int64
+		chunked     bool
+		wantStatus  int
+	}{
+		{name: "within the limit", maxBodySize: 1024, wantStatus: http.StatusUnauthorized},
+		{name: "declared length over the limit", maxBodySize: 64, wantStatus: http.StatusRequestEntityTooLarge},
+		{name: "chunked body over the limit", maxBodySize: 64, chunked: true, wantStatus: http.StatusRequestEntityTooLarge},
+		{name: "chunked body within the limit", maxBodySize: 1024, chunked: true, wantStatus: http.StatusUnauthorized},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// Arrange
+			server := newTestServer(t, rejectingAuthProvider(), Options{MaxBodySize: test.maxBodySize})
+
 			// Act
-			status := mapErrorToStatus(test.err)
+			resp := server.do(testRequest{Method: http.MethodPost, Path: "/sign-in", Body: body, Chunked: test.chunked})
 
 			// Assert
-			if status != test.wantStatus {
-				t.Errorf("mapErrorToStatus should map error to %d; got %d", test.wantStatus, status)
+			expectClientError(t, resp)
+			if resp.Status != test.wantStatus {
+				t.Errorf("status = %d, want %d: %s", resp.Status, test.wantStatus, resp.Body)
 			}
 		})
 	}
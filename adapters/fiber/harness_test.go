@@ -0,0 +1,119 @@
+package fiber
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v3"
+	"github.com/lborres/kuta"
+)
+
+const testBasePath = "/api/auth"
+
+// testServer mounts the built-in endpoints on an in-memory Fiber app through
+// RegisterRoutes, so tests go through routing, binding, cookies and the
+// response envelope exactly as a real request would.
+type testServer struct {
+	t   *testing.T
+	app *fiber.App
+}
+
+// testRequest is a request to a testServer. Path is relative to the auth
+// base path; Body is sent as JSON unless it is already a string.
+type testRequest struct {
+	Method  string
+	Path    string
+	Body    any
+	Headers map[string]string
+	Cookies []*http.Cookie
+	// Chunked sends Body without a Content-Length
+	Chunked bool
+}
+
+// testResponse is a fully read response from a testServer
+type testResponse struct {
+	Status  int
+	Header  http.Header
+	Cookies []*http.Cookie
+	Body    []byte
+}
+
+func newTestServer(t *testing.T, auth kuta.AuthProvider, opts Options) *testServer {
+	t.Helper()
+	app := fiber.New()
+	if err := New(app, opts).RegisterRoutes(auth, testBasePath, 0); err != nil {
+		t.Fatalf("RegisterRoutes() error = %v", err)
+	}
+	return &testServer{t: t, app: app}
+}
+
+func (s *testServer) do(r testRequest) testResponse {
+	s.t.Helper()
+
+	var body io.Reader
+	switch b := r.Body.(type) {
+	case nil:
+	case string:
+		body = strings.NewReader(b)
+	default:
+		data, err := json.Marshal(b)
+		if err != nil {
+			s.t.Fatalf("marshal request body: %v", err)
+		}
+		body = strings.NewReader(string(data))
+	}
+
+	req := httptest.NewRequest(r.Method, testBasePath+r.Path, body)
+	if r.Chunked {
+		req.ContentLength = -1
+		req.TransferEncoding = []string{"chunked"}
+	}
+	if body != nil {
+		req.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+	}
+	for name, value := range r.Headers {
+		req.Header.Set(name, value)
+	}
+	for _, cookie := range r.Cookies {
+		req.AddCookie(cookie)
+	}
+
+	resp, err := s.app.Test(req)
+	if err != nil {
+		s.t.Fatalf("app.Test() error = %v", err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		s.t.Fatalf("read response body: %v", err)
+	}
+
+	return testResponse{
+		Status:  resp.StatusCode,
+		Header:  resp.Header,
+		Cookies: resp.Cookies(),
+		Body:    data,
+	}
+}
+
+// decode unmarshals the JSON body into out
+func (r testResponse) decode(t *testing.T, out any) {
+	t.Helper()
+	if err := json.Unmarshal(r.Body, out); err != nil {
+		t.Fatalf("decode response body %s: %v", r.Body, err)
+	}
+}
+
+// cookie returns the cookie set by the response, or nil
+func (r testResponse) cookie(name string) *http.Cookie {
+	for _, cookie := range r.Cookies {
+		if cookie.Name == name {
+			return cookie
+		}
+	}
+	return nil
+}
@@ -6,23 +6,43 @@ import (
 )
 
 // BuildProtectedMiddleware creates a Fiber middleware that validates auth tokens
-// and stores user/session data in the context for downstream handlers.
+// and stores user/session data in the context for downstream handlers. When
+// the provider has access tokens enabled, personal access tokens are accepted
+// too; their requests have the *kuta.AccessToken in Locals("access_token")
+// instead of a session.
 func (a *Adapter) BuildProtectedMiddleware(authProvider kuta.AuthProvider) interface{} {
 	return func(c fiber.Ctx) error {
 		// Extract and validate token from Authorization header
-		token := extractToken(c)
+		token := extractToken(c, a.opts.CookieName)
 		if token == "" {
-			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
-				"error": kuta.ErrMissingAuthHeader.Error(),
-			})
+			return a.opts.fail(c, fiber.StatusUnauthorized, kuta.ErrMissingAuthHeader.Error())
+		}
+
+		if tokens, ok := accessTokens(authProvider, token); ok {
+			data, err := tokens.VerifyAccessToken(c.Context(), token)
+			if err != nil {
+				return a.opts.fail(c, fiber.StatusUnauthorized, err.Error())
+			}
+
+			c.Locals("user", data.User)
+			c.Locals("access_token", data.AccessToken)
+			return c.Next()
 		}
 
 		// Validate token and retrieve session data
-		sessionData, err := authProvider.GetSession(token)
+		sessionData, err := authProvider.GetSession(c.Context(), token)
 		if err != nil {
-			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
-				"error": err.Error(),
-			})
+			return a.opts.fail(c, fiber.StatusUnauthorized, err.Error())
+		}
+
+		// Key-bound sessions must also prove possession of the private key
+		if err := checkProof(c, authProvider, sessionData.Session); err != nil {
+			return a.opts.fail(c, fiber.StatusUnauthorized, err.Error())
+		}
+
+		// Revoked sessions still draining may only read
+		if sessionData.Session.Draining() && !idempotentMethod(c.Method()) {
+			return a.opts.fail(c, fiber.StatusUnauthorized, kuta.ErrSessionDraining.Error())
 		}
 
 		// Store user and session in context for downstream handlers
@@ -32,3 +52,24 @@ func (a *Adapter) BuildProtectedMiddleware(authProvider kuta.AuthProvider) inter
 		return c.Next()
 	}
 }
+
+// accessTokens returns the provider to verify token with when it is a
+// personal access token and access tokens are enabled
+func accessTokens(auth kuta.AuthProvider, token string) (kuta.AccessTokenProvider, bool) {
+	if !kuta.IsAccessToken(token) {
+		return nil, false
+	}
+	tokens, ok := auth.(kuta.AccessTokenProvider)
+	return tokens, ok && tokens.AccessTokensEnabled()
+}
+
+// idempotentMethod reports whether requests with method only read, so
+// draining sessions may still make them
+func idempotentMethod(method string) bool {
+	switch method {
+	case fiber.MethodGet, fiber.MethodHead, fiber.MethodOptions:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,58 @@
+package fiber
+
+import (
+	"bytes"
+	"io"
+	"net/url"
+
+	"github.com/gofiber/fiber/v3"
+	"github.com/lborres/kuta"
+)
+
+// request implements kuta.Request over a Fiber context
+type request struct {
+	c    fiber.Ctx
+	opts Options
+}
+
+var _ kuta.Request = request{}
+
+func (q request) Method() string {
+	return q.c.Method()
+}
+
+func (q request) Path() string {
+	return q.c.Path()
+}
+
+// Query parses the query string like net/url, skipping malformed pairs
+func (q request) Query() url.Values {
+	values, _ := url.ParseQuery(string(q.c.Request().URI().QueryString()))
+	return values
+}
+
+func (q request) PathValue(name string) string {
+	return q.c.Params(name)
+}
+
+func (q request) Header(name string) string {
+	return q.c.Get(name)
+}
+
+// Body reads Fiber's buffered body, which is only valid until the handler
+// returns
+func (q request) Body() io.Reader {
+	return bytes.NewReader(q.c.Body())
+}
+
+func (q request) ClientIP() string {
+	return q.opts.clientIP(q.c)
+}
+
+func (q request) UserAgent() string {
+	return q.c.Get(fiber.HeaderUserAgent)
+}
+
+func (q request) Cookie(name string) string {
+	return q.c.Cookies(name)
+}
@@ -0,0 +1,62 @@
+package fiber
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gofiber/fiber/v3"
+	"github.com/lborres/kuta"
+)
+
+// response implements kuta.Response over a Fiber context
+type response struct {
+	c fiber.Ctx
+}
+
+var _ kuta.Response = response{}
+
+func (s response) SetHeader(name, value string) {
+	s.c.Set(name, value)
+}
+
+// SetCookie maps a negative MaxAge, which deletes the cookie, to an expiry in
+// the past: Fiber leaves out the Max-Age attribute unless it is positive
+func (s response) SetCookie(cookie *http.Cookie) {
+	expires, maxAge := cookie.Expires, cookie.MaxAge
+	if maxAge < 0 {
+		expires, maxAge = time.Unix(0, 0), 0
+	}
+	s.c.Cookie(&fiber.Cookie{
+		Name:     cookie.Name,
+		Value:    cookie.Value,
+		Path:     cookie.Path,
+		Domain:   cookie.Domain,
+		Expires:  expires,
+		MaxAge:   maxAge,
+		Secure:   cookie.Secure,
+		HTTPOnly: cookie.HttpOnly,
+		SameSite: sameSiteName(cookie.SameSite),
+	})
+}
+
+func (s response) Status(code int) {
+	s.c.Status(code)
+}
+
+func (s response) JSON(v any) error {
+	return s.c.JSON(v)
+}
+
+// sameSiteName is the Fiber name of a net/http SameSite mode
+func sameSiteName(mode http.SameSite) string {
+	switch mode {
+	case http.SameSiteLaxMode:
+		return fiber.CookieSameSiteLaxMode
+	case http.SameSiteStrictMode:
+		return fiber.CookieSameSiteStrictMode
+	case http.SameSiteNoneMode:
+		return fiber.CookieSameSiteNoneMode
+	default:
+		return ""
+	}
+}
@@ -1,6 +1,7 @@
 package fiber
 
 import (
+	"fmt"
 	"time"
 
 	"github.com/gofiber/fiber/v3"
@@ -11,81 +12,79 @@ import (
 type Adapter struct {
 	app     *fiber.App
 	handler kuta.AuthProvider
+	opts    Options
+	optsErr error // reported by RegisterRoutes since New can't fail
 }
 
 var _ kuta.HTTPProvider = (*Adapter)(nil)
 
-func New(app *fiber.App) *Adapter {
-	return &Adapter{app: app}
+// New creates a Fiber adapter. An optional Options value customizes cookie
+// name, base path and client IP extraction.
+func New(app *fiber.App, opts ...Options) *Adapter {
+	var o Options
+	if len(opts) > 0 {
+		o = opts[0]
+	}
+	resolved, err := o.resolve()
+	return &Adapter{app: app, opts: resolved, optsErr: err}
 }
 
 func (a *Adapter) RegisterRoutes(service kuta.AuthProvider, basePath string, _ time.Duration) error {
-	a.handler = service
-
-	// Create endpoint registry with our handler factories
-	registry := services.NewEndpointRegistry()
-
-	// Wire handler factories to endpoints
-	endpoints := registry.Endpoints()
-	for i, endpoint := range endpoints {
-		switch endpoint.Metadata.OperationID {
-		case "signUpWithEmailAndPassword":
-			endpoints[i].Handler = handleSignUpFiber(service)
-		case "signInWithEmailAndPassword":
-			endpoints[i].Handler = handleSignInFiber(service)
-		case "signOut":
-			endpoints[i].Handler = handleSignOutFiber(service)
-		case "getSession":
-			endpoints[i].Handler = handleGetSessionFiber(service)
-		case "refreshToken":
-			endpoints[i].Handler = handleRefreshFiber(service)
-		}
+	if a.optsErr != nil {
+		return a.optsErr
 	}
 
-	// Register all endpoints with Fiber
-	api := a.app.Group(basePath)
-
-	for _, endpoint := range endpoints {
-		if endpoint.Handler == nil {
-			continue // Skip endpoints without handlers
-		}
+	a.handler = service
 
-		// Convert the framework-agnostic handler to a Fiber handler
-		fiberHandler := a.adaptHandler(endpoint)
+	if a.opts.BasePath != "" {
+		basePath = a.opts.BasePath
+	}
 
-		// Register based on HTTP method
-		switch endpoint.Method {
-		case "GET":
-			api.Get(endpoint.Path, fiberHandler)
-		case "POST":
-			api.Post(endpoint.Path, fiberHandler)
-		case "PUT":
-			api.Put(endpoint.Path, fiberHandler)
-		case "DELETE":
-			api.Delete(endpoint.Path, fiberHandler)
-		case "PATCH":
-			api.Patch(endpoint.Path, fiberHandler)
-		}
+	groups := a.opts.RouteGroups
+	if len(groups) == 0 {
+		groups = []kuta.RouteGroup{{Prefix: basePath}}
+	}
+	registry, err := services.BuiltinRoutes(service, a.opts.handlerOptions(), groups)
+	if err != nil {
+		return err
 	}
 
-	// Check if handler supports dynamic endpoint registration (plugins)
-	if provider, ok := service.(kuta.EndpointProvider); ok {
-		// Use dynamic endpoint registration for plugins
-		return a.registerDynamicEndpoints(provider, basePath)
+	// Register every group's endpoints with Fiber
+	for _, group := range registry.Groups() {
+		if err := a.mountGroup(registry, group); err != nil {
+			return err
+		}
 	}
 
 	return nil
 }
 
-// registerDynamicEndpoints registers endpoints provided by an EndpointProvider
-func (a *Adapter) registerDynamicEndpoints(provider kuta.EndpointProvider, basePath string) error {
-	api := a.app.Group(basePath)
-	endpoints := provider.GetEndpoints()
+// mountGroup registers the endpoints of one route group under its prefix
+func (a *Adapter) mountGroup(registry *services.EndpointRegistry, group kuta.RouteGroup) error {
+	endpoints, err := registry.GroupEndpoints(group)
+	if err != nil {
+		return err
+	}
+
+	api := a.app.Group(group.Prefix)
+	if a.opts.CORS != nil {
+		api.Use(a.opts.corsHandler())
+	}
+	for _, middleware := range group.Middleware {
+		handler, ok := middleware.(fiber.Handler)
+		if !ok {
+			return fmt.Errorf("route group %s: middleware must be a fiber.Handler, got %T", group.Prefix, middleware)
+		}
+		api.Use(handler)
+	}
 
 	for _, endpoint := range endpoints {
-		ep := endpoint // capture loop variable
+		if endpoint.Handler == nil {
+			continue // Skip endpoints without handlers
+		}
+
 		// Convert the framework-agnostic handler to a Fiber handler
-		fiberHandler := a.adaptHandler(&ep)
+		fiberHandler := a.adaptHandler(endpoint)
 
 		// Register based on HTTP method
 		switch endpoint.Method {
@@ -108,10 +107,18 @@ func (a *Adapter) registerDynamicEndpoints(provider kuta.EndpointProvider, baseP
 // adaptHandler converts a framework-agnostic endpoint handler to a Fiber handler
 func (a *Adapter) adaptHandler(endpoint *kuta.Endpoint) fiber.Handler {
 	return func(c fiber.Ctx) error {
+		// fasthttp has read the body by now, up to Fiber's BodyLimit
+		if int64(len(c.Request().Body())) > a.opts.MaxBodySize {
+			return a.opts.authError(c, kuta.ErrBodyTooLarge)
+		}
+
 		// Create RequestContext
 		ctx := &kuta.RequestContext{
-			Request: c,
-			Auth:    a.handler,
+			Request:  request{c: c, opts: a.opts},
+			Response: response{c: c},
+			Native:   c,
+			Auth:     a.handler,
+			Context:  c.Context(),
 		}
 
 		// Call the endpoint handler
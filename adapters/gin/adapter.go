@@ -0,0 +1,245 @@
+package gin
+
+import (
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lborres/kuta"
+	"github.com/lborres/kuta/pkg/clientip"
+	"github.com/lborres/kuta/services"
+)
+
+const (
+	defaultCookieName = "auth_token"
+)
+
+// Options customizes how the Gin adapter mounts routes and reads requests.
+// Zero values fall back to kuta's defaults.
+type Options struct {
+	// CookieName is the cookie checked for the session token when no
+	// Authorization header is present. Defaults to "auth_token".
+	CookieName string
+
+	// SetCookie makes sign-up, sign-in and refresh set the session token as
+	// an HttpOnly cookie named CookieName, and sign-out clear it.
+	SetCookie bool
+
+	// CookieDomain is the Domain attribute of the session cookie
+	CookieDomain string
+
+	// CookieSameSite is the SameSite attribute of the session cookie:
+	// "Lax" (default), "Strict" or "None".
+	CookieSameSite string
+
+	// CookieInsecure drops the Secure attribute so the cookie works over
+	// plain HTTP. Only meant for local development.
+	CookieInsecure bool
+
+	// TokenHeader, when set, sends the session token of sign-up, sign-in and
+	// refresh responses in this response header (e.g.
+	// kuta.DefaultTokenHeader) and leaves it out of the JSON body.
+	TokenHeader string
+
+	// OAuthRedirectURL is where the browser is sent once an OAuth callback
+	// has set the session cookie, e.g. the app's home page. Without it, or
+	// without SetCookie, the callback responds with the sign-in result.
+	OAuthRedirectURL string
+
+	// BasePath overrides kuta.Config.BasePath for this adapter
+	BasePath string
+
+	// RouteGroups mounts the endpoints under several prefixes, each with its
+	// own middleware (gin.HandlerFunc values). Replaces BasePath when set.
+	RouteGroups []kuta.RouteGroup
+
+	// CORS enables cross-origin requests to the auth routes. Credentials are
+	// allowed exactly when SetCookie is on.
+	CORS *kuta.CORSConfig
+
+	// TrustedProxies lists IPs or CIDRs of load balancers / reverse proxies
+	// whose ProxyHeader and X-Real-IP headers are honored when recording the
+	// client IP on sessions. Gin's own trusted proxy settings are not used.
+	TrustedProxies []string
+
+	// TrustProxy honors forwarding headers from any peer. Only enable this
+	// when the app is reachable exclusively through a proxy that sets them.
+	TrustProxy bool
+
+	// ProxyHeader is the header holding the client IP chain.
+	// Defaults to "X-Forwarded-For".
+	ProxyHeader string
+
+	// ResponseEnvelope shapes response bodies, e.g. kuta.DataEnvelope for
+	// {"data": ..., "error": ...}. Defaults to kuta.BareEnvelope.
+	ResponseEnvelope kuta.ResponseEnvelope
+
+	// Transforms registers per-endpoint request decoders and response
+	// encoders, keyed by OperationID, e.g. kuta.SnakeCaseDecoder. Set
+	// Transforms.FieldNaming to kuta.FieldNamingSnakeCase to switch every
+	// endpoint to snake_case keys.
+	Transforms kuta.PayloadTransforms
+
+	// MaxBodySize bounds the request bodies of the auth endpoints in bytes.
+	// Larger ones are refused with 413 before they are decoded. Defaults to
+	// kuta.DefaultMaxBodySize.
+	MaxBodySize int64
+
+	resolver *clientip.Resolver
+}
+
+// resolve fills in defaults and builds the client IP resolver
+func (o Options) resolve() (Options, error) {
+	if o.CookieName == "" {
+		o.CookieName = defaultCookieName
+	}
+	if o.ProxyHeader == "" {
+		o.ProxyHeader = "X-Forwarded-For"
+	}
+	if o.CookieSameSite == "" {
+		o.CookieSameSite = "Lax"
+	}
+	if o.MaxBodySize <= 0 {
+		o.MaxBodySize = kuta.DefaultMaxBodySize
+	}
+
+	if o.CORS != nil {
+		if err := o.CORS.Validate(o.SetCookie); err != nil {
+			return o, err
+		}
+	}
+
+	resolver, err := clientip.NewResolver(clientip.Config{
+		TrustedProxies:  o.TrustedProxies,
+		TrustAllProxies: o.TrustProxy,
+		Header:          o.ProxyHeader,
+	})
+	if err != nil {
+		return o, err
+	}
+	o.resolver = resolver
+
+	return o, nil
+}
+
+// allowedOrigin reports whether CORS allows requests from origin
+func (o Options) allowedOrigin(origin string) bool {
+	for _, allowed := range o.CORS.AllowedOrigins {
+		if allowed == "*" || allowed == origin {
+			return true
+		}
+	}
+	return false
+}
+
+// corsHandler builds the CORS middleware for the auth route group. It
+// answers preflight requests itself, ahead of any route group middleware.
+func (o Options) corsHandler() gin.HandlerFunc {
+	allowHeaders := strings.Join(append([]string{"Authorization", "Content-Type", HeaderProof, kuta.IdempotencyKeyHeader}, o.CORS.AllowedHeaders...), ", ")
+
+	return func(c *gin.Context) {
+		header := c.Writer.Header()
+		header.Add("Vary", "Origin")
+
+		origin := c.GetHeader("Origin")
+		allowed := origin != "" && o.allowedOrigin(origin)
+		if allowed {
+			header.Set("Access-Control-Allow-Origin", origin)
+			if o.SetCookie {
+				header.Set("Access-Control-Allow-Credentials", "true")
+			}
+			// Browsers hide response headers from cross-origin scripts unless exposed
+			if o.TokenHeader != "" {
+				header.Set("Access-Control-Expose-Headers", o.TokenHeader)
+			}
+		}
+
+		if c.Request.Method != http.MethodOptions {
+			c.Next()
+			return
+		}
+
+		if allowed {
+			header.Set("Access-Control-Allow-Methods", "GET, POST, PUT, PATCH, DELETE")
+			header.Set("Access-Control-Allow-Headers", allowHeaders)
+			if o.CORS.MaxAge > 0 {
+				header.Set("Access-Control-Max-Age", strconv.Itoa(int(o.CORS.MaxAge.Seconds())))
+			}
+		}
+		c.AbortWithStatus(http.StatusNoContent)
+	}
+}
+
+// envelope returns the configured response envelope or the bare default
+func (o Options) envelope() kuta.ResponseEnvelope {
+	if o.ResponseEnvelope == nil {
+		return kuta.BareEnvelope{}
+	}
+	return o.ResponseEnvelope
+}
+
+// handlerOptions configures the handlers kuta shares across adapters
+func (o Options) handlerOptions() services.HandlerOptions {
+	return services.HandlerOptions{
+		CookieName:       o.CookieName,
+		SetCookie:        o.SetCookie,
+		CookieDomain:     o.CookieDomain,
+		CookieSameSite:   o.CookieSameSite,
+		CookieInsecure:   o.CookieInsecure,
+		TokenHeader:      o.TokenHeader,
+		Envelope:         o.envelope(),
+		Transforms:       o.Transforms,
+		OAuthRedirectURL: o.OAuthRedirectURL,
+		Bind: func(ctx *kuta.RequestContext, operationID string, out any) error {
+			// Read the body through ctx.Request, which the idempotency
+			// wrapper serves from its buffer
+			c := ctx.Native.(*gin.Context)
+			c.Request.Body = io.NopCloser(ctx.Request.Body())
+			return o.bind(c, operationID, out)
+		},
+		FormImage: func(ctx *kuta.RequestContext, field string) (*kuta.ImageUpload, error) {
+			return multipartImage(ctx.Native.(*gin.Context), field)
+		},
+	}
+}
+
+// bind decodes the request body with the operation's decoder, falling back
+// to Gin's content-type based binding
+func (o Options) bind(c *gin.Context, operationID string, out any) error {
+	if decoder, ok := o.Transforms.Decoder(operationID); ok {
+		body, err := c.GetRawData()
+		if err != nil {
+			return err
+		}
+		return decoder(body, out)
+	}
+	return c.ShouldBind(out)
+}
+
+// fail sends an error response through the envelope
+func (o Options) fail(c *gin.Context, status int, message string) error {
+	c.JSON(status, o.envelope().Failure(status, message))
+	return nil
+}
+
+// authError maps kuta errors to an enveloped error response
+func (o Options) authError(c *gin.Context, err error) error {
+	status, body := kuta.ErrorBody(o.envelope(), err)
+	c.JSON(status, body)
+	return nil
+}
+
+// clientIP returns the IP address recorded on sessions for this request
+func (o Options) clientIP(c *gin.Context) string {
+	remoteIP, _, err := net.SplitHostPort(c.Request.RemoteAddr)
+	if err != nil {
+		remoteIP = c.Request.RemoteAddr
+	}
+	if o.resolver == nil {
+		return remoteIP
+	}
+	return o.resolver.ClientIP(remoteIP, c.GetHeader)
+}
@@ -0,0 +1,72 @@
+package gin
+
+import (
+	"io"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lborres/kuta"
+)
+
+// HeaderProof carries the proof-of-possession for key-bound sessions
+const HeaderProof = kuta.ProofHeader
+
+// multipartImage reads an image file sent as a multipart form field. It
+// returns nil when the request is not multipart or has no such field.
+func multipartImage(c *gin.Context, field string) (*kuta.ImageUpload, error) {
+	if c.ContentType() != gin.MIMEMultipartPOSTForm {
+		return nil, nil
+	}
+	form, err := c.MultipartForm()
+	if err != nil {
+		return nil, err
+	}
+	files := form.File[field]
+	if len(files) == 0 {
+		return nil, nil
+	}
+
+	header := files[0]
+	file, err := header.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(file)
+	if err != nil {
+		return nil, err
+	}
+	return &kuta.ImageUpload{ContentType: header.Header.Get("Content-Type"), Data: data}, nil
+}
+
+// extractToken extracts the authentication token from the request.
+// Checks Authorization header (Bearer token) first, then falls back to cookie.
+func extractToken(c *gin.Context, cookieName string) string {
+	// Try Bearer token first
+	if token, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer "); ok && token != "" {
+		return token
+	}
+
+	// Fall back to cookie
+	token, err := c.Cookie(cookieName)
+	if err != nil {
+		return ""
+	}
+	return token
+}
+
+// checkProof enforces proof-of-possession for key-bound sessions when the
+// auth provider supports it
+func checkProof(c *gin.Context, authProvider kuta.AuthProvider, session *kuta.Session) error {
+	verifier, ok := authProvider.(kuta.ProofVerifier)
+	if !ok {
+		return nil
+	}
+
+	proof, err := kuta.ParseRequestProof(c.GetHeader(HeaderProof), c.Request.Method, c.Request.URL.Path)
+	if err != nil {
+		return err
+	}
+	return verifier.VerifyProof(session, proof)
+}
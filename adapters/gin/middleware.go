@@ -0,0 +1,89 @@
+package gin
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lborres/kuta"
+)
+
+// BuildProtectedMiddleware creates a gin.HandlerFunc that validates auth
+// tokens and stores the user and session in the context (under "user" and
+// "session") for downstream handlers. When the provider has access tokens
+// enabled, personal access tokens are accepted too; their requests have the
+// *kuta.AccessToken under "access_token" instead of a session.
+func (a *Adapter) BuildProtectedMiddleware(authProvider kuta.AuthProvider) interface{} {
+	return gin.HandlerFunc(func(c *gin.Context) {
+		// Extract and validate token from Authorization header
+		token := extractToken(c, a.opts.CookieName)
+		if token == "" {
+			a.unauthorized(c, kuta.ErrMissingAuthHeader.Error())
+			return
+		}
+
+		if tokens, ok := accessTokens(authProvider, token); ok {
+			data, err := tokens.VerifyAccessToken(c.Request.Context(), token)
+			if err != nil {
+				a.unauthorized(c, err.Error())
+				return
+			}
+
+			c.Set("user", data.User)
+			c.Set("access_token", data.AccessToken)
+			c.Next()
+			return
+		}
+
+		// Validate token and retrieve session data
+		sessionData, err := authProvider.GetSession(c.Request.Context(), token)
+		if err != nil {
+			a.unauthorized(c, err.Error())
+			return
+		}
+
+		// Key-bound sessions must also prove possession of the private key
+		if err := checkProof(c, authProvider, sessionData.Session); err != nil {
+			a.unauthorized(c, err.Error())
+			return
+		}
+
+		// Revoked sessions still draining may only read
+		if sessionData.Session.Draining() && !idempotentMethod(c.Request.Method) {
+			a.unauthorized(c, kuta.ErrSessionDraining.Error())
+			return
+		}
+
+		// Store user and session in context for downstream handlers
+		c.Set("user", sessionData.User)
+		c.Set("session", sessionData.Session)
+
+		c.Next()
+	})
+}
+
+// unauthorized rejects the request and stops the handler chain
+func (a *Adapter) unauthorized(c *gin.Context, message string) {
+	_ = a.opts.fail(c, http.StatusUnauthorized, message)
+	c.Abort()
+}
+
+// accessTokens returns the provider to verify token with when it is a
+// personal access token and access tokens are enabled
+func accessTokens(auth kuta.AuthProvider, token string) (kuta.AccessTokenProvider, bool) {
+	if !kuta.IsAccessToken(token) {
+		return nil, false
+	}
+	tokens, ok := auth.(kuta.AccessTokenProvider)
+	return tokens, ok && tokens.AccessTokensEnabled()
+}
+
+// idempotentMethod reports whether requests with method only read, so
+// draining sessions may still make them
+func idempotentMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,57 @@
+package gin
+
+import (
+	"io"
+	"net/url"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lborres/kuta"
+)
+
+// request implements kuta.Request over a Gin context
+type request struct {
+	c    *gin.Context
+	opts Options
+}
+
+var _ kuta.Request = request{}
+
+func (q request) Method() string {
+	return q.c.Request.Method
+}
+
+func (q request) Path() string {
+	return q.c.Request.URL.Path
+}
+
+func (q request) Query() url.Values {
+	return q.c.Request.URL.Query()
+}
+
+func (q request) PathValue(name string) string {
+	return q.c.Param(name)
+}
+
+func (q request) Header(name string) string {
+	return q.c.GetHeader(name)
+}
+
+func (q request) Body() io.Reader {
+	return q.c.Request.Body
+}
+
+func (q request) ClientIP() string {
+	return q.opts.clientIP(q.c)
+}
+
+func (q request) UserAgent() string {
+	return q.c.Request.UserAgent()
+}
+
+func (q request) Cookie(name string) string {
+	value, err := q.c.Cookie(name)
+	if err != nil {
+		return ""
+	}
+	return value
+}
@@ -0,0 +1,32 @@
+package gin
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lborres/kuta"
+)
+
+// response implements kuta.Response over a Gin context
+type response struct {
+	c *gin.Context
+}
+
+var _ kuta.Response = response{}
+
+func (s response) SetHeader(name, value string) {
+	s.c.Header(name, value)
+}
+
+func (s response) SetCookie(cookie *http.Cookie) {
+	http.SetCookie(s.c.Writer, cookie)
+}
+
+func (s response) Status(code int) {
+	s.c.Status(code)
+}
+
+func (s response) JSON(v any) error {
+	s.c.JSON(s.c.Writer.Status(), v)
+	return nil
+}
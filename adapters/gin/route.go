@@ -0,0 +1,133 @@
+package gin
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lborres/kuta"
+	"github.com/lborres/kuta/services"
+)
+
+type Adapter struct {
+	router  gin.IRouter
+	handler kuta.AuthProvider
+	opts    Options
+	optsErr error // reported by RegisterRoutes since New can't fail
+}
+
+var _ kuta.HTTPProvider = (*Adapter)(nil)
+
+// New creates a Gin adapter that mounts the auth endpoints on router, a
+// *gin.Engine or a *gin.RouterGroup. An optional Options value customizes
+// cookie name, base path and client IP extraction.
+func New(router gin.IRouter, opts ...Options) *Adapter {
+	var o Options
+	if len(opts) > 0 {
+		o = opts[0]
+	}
+	resolved, err := o.resolve()
+	return &Adapter{router: router, opts: resolved, optsErr: err}
+}
+
+func (a *Adapter) RegisterRoutes(service kuta.AuthProvider, basePath string, _ time.Duration) error {
+	if a.optsErr != nil {
+		return a.optsErr
+	}
+
+	a.handler = service
+
+	if a.opts.BasePath != "" {
+		basePath = a.opts.BasePath
+	}
+
+	groups := a.opts.RouteGroups
+	if len(groups) == 0 {
+		groups = []kuta.RouteGroup{{Prefix: basePath}}
+	}
+	registry, err := services.BuiltinRoutes(service, a.opts.handlerOptions(), groups)
+	if err != nil {
+		return err
+	}
+
+	for _, group := range registry.Groups() {
+		if err := a.mountGroup(registry, group); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// mountGroup registers the endpoints of one route group under its prefix
+func (a *Adapter) mountGroup(registry *services.EndpointRegistry, group kuta.RouteGroup) error {
+	endpoints, err := registry.GroupEndpoints(group)
+	if err != nil {
+		return err
+	}
+
+	api := a.router.Group(group.Prefix)
+	if a.opts.CORS != nil {
+		api.Use(a.opts.corsHandler())
+	}
+	for _, middleware := range group.Middleware {
+		switch handler := middleware.(type) {
+		case gin.HandlerFunc:
+			api.Use(handler)
+		case func(*gin.Context):
+			api.Use(handler)
+		default:
+			return fmt.Errorf("route group %s: middleware must be a gin.HandlerFunc, got %T", group.Prefix, middleware)
+		}
+	}
+
+	// Gin only runs group middleware on matched routes, so each path needs an
+	// OPTIONS route for the CORS middleware to answer preflights on
+	preflight := make(map[string]bool)
+
+	for _, endpoint := range endpoints {
+		if endpoint.Handler == nil {
+			continue // Skip endpoints without handlers
+		}
+
+		api.Handle(endpoint.Method, endpoint.Path, a.adaptHandler(endpoint))
+
+		if a.opts.CORS != nil && !preflight[endpoint.Path] {
+			preflight[endpoint.Path] = true
+			api.OPTIONS(endpoint.Path, func(c *gin.Context) {
+				c.Status(http.StatusNoContent)
+			})
+		}
+	}
+
+	return nil
+}
+
+// adaptHandler converts a framework-agnostic endpoint handler to a Gin handler
+func (a *Adapter) adaptHandler(endpoint *kuta.Endpoint) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.Request.ContentLength > a.opts.MaxBodySize {
+			_ = a.opts.authError(c, kuta.ErrBodyTooLarge)
+			return
+		}
+		c.Request.Body = kuta.LimitBody(c.Request.Body, a.opts.MaxBodySize)
+
+		ctx := &kuta.RequestContext{
+			Request:  request{c: c, opts: a.opts},
+			Response: response{c: c},
+			Native:   c,
+			Auth:     a.handler,
+			Context:  c.Request.Context(),
+		}
+
+		// Handlers write their own error responses, so errors returned are
+		// unexpected and answered with a bare 500
+		if err := endpoint.Handler(ctx); err != nil {
+			_ = c.Error(err)
+			if !c.Writer.Written() {
+				c.AbortWithStatus(http.StatusInternalServerError)
+			}
+		}
+	}
+}
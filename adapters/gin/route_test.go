@@ -0,0 +1,145 @@
+package gin
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/lborres/kuta"
+)
+
+// wiringAuthProvider adds account linking, OAuth sign-in and idempotency to
+// the mock auth provider, recording what reaches it. The handlers behind
+// them are tested in services; these only check the adapter's wiring.
+type wiringAuthProvider struct {
+	*mockAuthProvider
+	unlinked  string
+	callback  kuta.OAuthCallback
+	responses map[string]*kuta.RecordedResponse
+}
+
+func (p *wiringAuthProvider) ListAccounts(context.Context, string) ([]*kuta.Account, error) {
+	return nil, nil
+}
+
+func (p *wiringAuthProvider) StartOAuthLink(context.Context, string, string) (*kuta.OAuthStart, error) {
+	return nil, kuta.ErrUnknownProvider
+}
+
+func (p *wiringAuthProvider) UnlinkAccount(ctx context.Context, userID, accountID string) error {
+	p.unlinked = accountID
+	return nil
+}
+
+func (p *wiringAuthProvider) OAuthEnabled() bool { return true }
+
+func (p *wiringAuthProvider) StartOAuth(string) (*kuta.OAuthStart, error) {
+	return nil, kuta.ErrUnknownProvider
+}
+
+func (p *wiringAuthProvider) CompleteOAuth(ctx context.Context, providerID string, callback kuta.OAuthCallback, ipAddress, userAgent string) (*kuta.SignInResult, error) {
+	p.callback = callback
+	return &kuta.SignInResult{User: &kuta.User{ID: "u1"}, Session: &kuta.Session{ID: "s1", ExpiresAt: time.Now().Add(time.Hour)}, Token: "tok"}, nil
+}
+
+func (p *wiringAuthProvider) IdempotencyEnabled() bool { return true }
+
+func (p *wiringAuthProvider) BeginIdempotent(operationID, key string, _ []byte) (*kuta.RecordedResponse, error) {
+	return p.responses[operationID+":"+key], nil
+}
+
+func (p *wiringAuthProvider) FinishIdempotent(operationID, key string, _ []byte, response *kuta.RecordedResponse) error {
+	p.responses[operationID+":"+key] = response
+	return nil
+}
+
+// Requirement: RegisterRoutes mounts the endpoints the auth provider
+// supports with path parameters, form bodies and idempotent retries reaching
+// the shared handlers, and leaves the others unmounted.
+func TestRegisterRoutes_Wiring(t *testing.T) {
+	signUp := testRequest{
+		Method:  http.MethodPost,
+		Path:    "/sign-up",
+		Body:    kuta.SignUpRequest{Email: "a@b.c", Password: "password123"},
+		Headers: map[string]string{kuta.IdempotencyKeyHeader: "key-1"},
+	}
+
+	tests := []struct {
+		name         string
+		before       *testRequest
+		request      testRequest
+		wantStatus   int
+		wantUnlinked string
+		wantCode     string
+		wantSignUp   bool
+	}{
+		{
+			name:         "path parameter",
+			request:      testRequest{Method: http.MethodPost, Path: "/accounts/acc-2/unlink", Headers: map[string]string{"Authorization": "Bearer tok"}},
+			wantStatus:   http.StatusOK,
+			wantUnlinked: "acc-2",
+		},
+		{
+			name: "form body",
+			request: testRequest{
+				Method:  http.MethodPost,
+				Path:    "/callback/apple",
+				Body:    `code=c1&state=s1`,
+				Headers: map[string]string{"Content-Type": "application/x-www-form-urlencoded"},
+			},
+			wantStatus: http.StatusOK,
+			wantCode:   "c1",
+		},
+		{
+			name:       "idempotent request",
+			request:    signUp,
+			wantStatus: http.StatusCreated,
+			wantSignUp: true,
+		},
+		{
+			name:       "idempotent retry replayed",
+			before:     &signUp,
+			request:    signUp,
+			wantStatus: http.StatusCreated,
+		},
+		{
+			name:       "unsupported endpoint not mounted",
+			request:    testRequest{Method: http.MethodPost, Path: "/change-password", Body: kuta.ChangePasswordRequest{}},
+			wantStatus: http.StatusNotFound,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// Arrange
+			mock := &mockAuthProvider{
+				getSessionData: &kuta.SessionData{User: &kuta.User{ID: "u1"}, Session: &kuta.Session{ID: "s1"}},
+				signUpResult:   &kuta.SignUpResult{User: &kuta.User{ID: "u1"}, Session: &kuta.Session{ExpiresAt: time.Now().Add(time.Hour)}, Token: "tok"},
+			}
+			auth := &wiringAuthProvider{mockAuthProvider: mock, responses: map[string]*kuta.RecordedResponse{}}
+			server := newTestServer(t, auth, Options{})
+			if test.before != nil {
+				server.do(*test.before)
+				mock.signUpCalled = false
+			}
+
+			// Act
+			resp := server.do(test.request)
+
+			// Assert
+			if resp.Status != test.wantStatus {
+				t.Fatalf("status = %d, want %d (body %s)", resp.Status, test.wantStatus, resp.Body)
+			}
+			if auth.unlinked != test.wantUnlinked {
+				t.Errorf("unlinked %q, want %q", auth.unlinked, test.wantUnlinked)
+			}
+			if auth.callback.Code != test.wantCode {
+				t.Errorf("callback code = %q, want %q", auth.callback.Code, test.wantCode)
+			}
+			if mock.signUpCalled != test.wantSignUp {
+				t.Errorf("signed up = %v, want %v", mock.signUpCalled, test.wantSignUp)
+			}
+		})
+	}
+}
@@ -0,0 +1,181 @@
+package gin
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lborres/kuta"
+)
+
+// tokenAuthProvider adds personal access tokens to the mock auth provider.
+// Sessions can't be looked up with access tokens, "kuta_pat_good" verifies
+// as u1's token, and only u1's token t-1 can be revoked.
+type tokenAuthProvider struct {
+	*mockAuthProvider
+	enabled bool
+	userID  string
+	input   kuta.CreateAccessTokenInput
+	revoked string
+}
+
+func (p *tokenAuthProvider) GetSession(ctx context.Context, token string) (*kuta.SessionData, error) {
+	if kuta.IsAccessToken(token) {
+		return nil, kuta.ErrInvalidToken
+	}
+	return p.mockAuthProvider.GetSession(ctx, token)
+}
+
+func (p *tokenAuthProvider) AccessTokensEnabled() bool { return p.enabled }
+
+func (p *tokenAuthProvider) CreateAccessToken(ctx context.Context, userID string, input kuta.CreateAccessTokenInput) (*kuta.CreateAccessTokenResult, error) {
+	p.userID, p.input = userID, input
+	if input.Name == "" {
+		return nil, kuta.ErrNameRequired
+	}
+	return &kuta.CreateAccessTokenResult{
+		AccessToken: &kuta.AccessToken{ID: "t-1", UserID: userID, Name: input.Name, Scopes: input.Scopes},
+		Token:       kuta.AccessTokenPrefix + "secret",
+	}, nil
+}
+
+func (p *tokenAuthProvider) ListAccessTokens(ctx context.Context, userID string) ([]*kuta.AccessToken, error) {
+	p.userID = userID
+	return []*kuta.AccessToken{{ID: "t-1", UserID: userID, Name: "cli"}}, nil
+}
+
+func (p *tokenAuthProvider) RevokeAccessToken(ctx context.Context, userID, tokenID string) error {
+	p.userID = userID
+	if tokenID != "t-1" {
+		return kuta.ErrAccessTokenNotFound
+	}
+	p.revoked = tokenID
+	return nil
+}
+
+func (p *tokenAuthProvider) VerifyAccessToken(ctx context.Context, token string) (*kuta.AccessTokenData, error) {
+	if token != "kuta_pat_good" {
+		return nil, kuta.ErrInvalidToken
+	}
+	return &kuta.AccessTokenData{
+		User:        &kuta.User{ID: "u1"},
+		AccessToken: &kuta.AccessToken{ID: "t-1", UserID: "u1", Scopes: []string{"read"}},
+	}, nil
+}
+
+// Requirement: The /me/tokens endpoints let a signed-in session list, create
+// and revoke its user's personal access tokens. Access tokens themselves
+// can't manage tokens, and the endpoints aren't mounted without access
+// tokens enabled.
+func TestHandleAccessTokens(t *testing.T) {
+	signedIn := func() *mockAuthProvider {
+		return &mockAuthProvider{getSessionData: &kuta.SessionData{User: &kuta.User{ID: "u1"}, Session: &kuta.Session{ID: "s1"}}}
+	}
+
+	tests := []struct {
+		name        string
+		disabled    bool
+		method      string
+		path        string
+		body        any
+		token       string
+		wantStatus  int
+		wantRevoked string
+	}{
+		{name: "lists tokens", method: http.MethodGet, path: "/me/tokens", token: "tok", wantStatus: http.StatusOK},
+		{name: "creates a token", method: http.MethodPost, path: "/me/tokens", body: `{"name":"cli","scopes":["read"]}`, token: "tok", wantStatus: http.StatusCreated},
+		{name: "rejects a nameless token", method: http.MethodPost, path: "/me/tokens", body: `{"scopes":["read"]}`, token: "tok", wantStatus: http.StatusBadRequest},
+		{name: "revokes a token", method: http.MethodDelete, path: "/me/tokens/t-1", token: "tok", wantStatus: http.StatusOK, wantRevoked: "t-1"},
+		{name: "unknown token", method: http.MethodDelete, path: "/me/tokens/t-2", token: "tok", wantStatus: http.StatusNotFound},
+		{name: "missing token", method: http.MethodGet, path: "/me/tokens", wantStatus: http.StatusUnauthorized},
+		{name: "access token can't create tokens", method: http.MethodPost, path: "/me/tokens", body: `{"name":"cli"}`, token: "kuta_pat_good", wantStatus: http.StatusUnauthorized},
+		{name: "not mounted when disabled", disabled: true, method: http.MethodGet, path: "/me/tokens", token: "tok", wantStatus: http.StatusNotFound},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// Arrange
+			auth := &tokenAuthProvider{mockAuthProvider: signedIn(), enabled: !test.disabled}
+			server := newTestServer(t, auth, Options{})
+			headers := map[string]string{}
+			if test.token != "" {
+				headers["Authorization"] = "Bearer " + test.token
+			}
+
+			// Act
+			resp := server.do(testRequest{Method: test.method, Path: test.path, Body: test.body, Headers: headers})
+
+			// Assert
+			if resp.Status != test.wantStatus {
+				t.Fatalf("status = %d, want %d (body %s)", resp.Status, test.wantStatus, resp.Body)
+			}
+			if auth.revoked != test.wantRevoked {
+				t.Errorf("revoked %q, want %q", auth.revoked, test.wantRevoked)
+			}
+			switch test.wantStatus {
+			case http.StatusOK, http.StatusCreated:
+				if auth.userID != "u1" {
+					t.Errorf("managed the tokens of %q, want u1", auth.userID)
+				}
+			}
+			if test.wantStatus == http.StatusCreated {
+				var body kuta.CreateAccessTokenResult
+				resp.decode(t, &body)
+				if body.Token != kuta.AccessTokenPrefix+"secret" || body.AccessToken.Name != "cli" || len(auth.input.Scopes) != 1 {
+					t.Errorf("unexpected body %s for input %+v", resp.Body, auth.input)
+				}
+			}
+		})
+	}
+}
+
+// Requirement: The protected middleware accepts personal access tokens when
+// they are enabled, handing downstream handlers the user and the access token
+// instead of a session.
+func TestAdapter_ProtectedMiddleware_AccessTokens(t *testing.T) {
+	tests := []struct {
+		name       string
+		enabled    bool
+		token      string
+		wantStatus int
+		wantToken  bool
+	}{
+		{name: "valid access token", enabled: true, token: "kuta_pat_good", wantStatus: http.StatusOK, wantToken: true},
+		{name: "unknown access token", enabled: true, token: "kuta_pat_bad", wantStatus: http.StatusUnauthorized},
+		{name: "access tokens disabled", token: "kuta_pat_good", wantStatus: http.StatusUnauthorized},
+		{name: "session token", enabled: true, token: "tok", wantStatus: http.StatusOK},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// Arrange
+			session := &kuta.SessionData{User: &kuta.User{ID: "u1"}, Session: &kuta.Session{ID: "s1"}}
+			auth := &tokenAuthProvider{mockAuthProvider: &mockAuthProvider{getSessionData: session}, enabled: test.enabled}
+			engine := gin.New()
+			protected := New(engine).BuildProtectedMiddleware(auth).(gin.HandlerFunc)
+			var gotUser *kuta.User
+			var gotToken *kuta.AccessToken
+			engine.Handle(http.MethodPost, "/resource", protected, func(c *gin.Context) {
+				gotUser = c.MustGet("user").(*kuta.User)
+				gotToken, _ = c.Value("access_token").(*kuta.AccessToken)
+				c.Status(http.StatusOK)
+			})
+			req := httptest.NewRequest(http.MethodPost, "/resource", nil)
+			req.Header.Set("Authorization", "Bearer "+test.token)
+			recorder := httptest.NewRecorder()
+
+			// Act
+			engine.ServeHTTP(recorder, req)
+
+			// Assert
+			if recorder.Code != test.wantStatus {
+				t.Fatalf("status = %d, want %d", recorder.Code, test.wantStatus)
+			}
+			if test.wantStatus == http.StatusOK && (gotUser == nil || gotUser.ID != "u1" || (gotToken != nil) != test.wantToken) {
+				t.Errorf("context user = %+v, access token = %+v", gotUser, gotToken)
+			}
+		})
+	}
+}
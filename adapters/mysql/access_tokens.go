@@ -0,0 +1,90 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/lborres/kuta"
+)
+
+var _ kuta.AccessTokenStorage = (*Adapter)(nil)
+
+const accessTokenColumns = `id, user_id, name, token_hash, scopes, expires_at, last_used_at, created_at`
+
+func scanAccessToken(row rowScanner) (*kuta.AccessToken, error) {
+	token := &kuta.AccessToken{}
+	err := row.Scan(&token.ID, &token.UserID, &token.Name, &token.TokenHash, jsonColumn{&token.Scopes},
+		&token.ExpiresAt, &token.LastUsedAt, &token.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return token, nil
+}
+
+func (a *Adapter) CreateAccessToken(ctx context.Context, token *kuta.AccessToken) error {
+	query := `INSERT INTO access_tokens (` + accessTokenColumns + `)
+	          VALUES (?, ?, ?, ?, ?, ?, ?, ?)`
+
+	scopes, err := jsonList(token.Scopes)
+	if err != nil {
+		return err
+	}
+	_, err = a.db.ExecContext(ctx, query, token.ID, token.UserID, token.Name, token.TokenHash, scopes,
+		token.ExpiresAt, token.LastUsedAt, token.CreatedAt)
+	return err
+}
+
+func (a *Adapter) GetAccessTokenByHash(ctx context.Context, tokenHash string) (*kuta.AccessToken, error) {
+	query := `SELECT ` + accessTokenColumns + ` FROM access_tokens WHERE token_hash = ?`
+
+	token, err := scanAccessToken(a.db.QueryRowContext(ctx, query, tokenHash))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, kuta.ErrAccessTokenNotFound
+		}
+		return nil, err
+	}
+	return token, nil
+}
+
+func (a *Adapter) ListUserAccessTokens(ctx context.Context, userID string) ([]*kuta.AccessToken, error) {
+	query := `SELECT ` + accessTokenColumns + ` FROM access_tokens WHERE user_id = ?
+	          ORDER BY created_at DESC, id`
+
+	rows, err := a.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tokens := []*kuta.AccessToken{}
+	for rows.Next() {
+		token, err := scanAccessToken(rows)
+		if err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, token)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return tokens, nil
+}
+
+func (a *Adapter) DeleteAccessToken(ctx context.Context, userID, id string) error {
+	n, err := affected(a.db.ExecContext(ctx, `DELETE FROM access_tokens WHERE id = ? AND user_id = ?`, id, userID))
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return kuta.ErrAccessTokenNotFound
+	}
+	return nil
+}
+
+func (a *Adapter) TouchAccessToken(ctx context.Context, id string, usedAt time.Time) error {
+	_, err := a.db.ExecContext(ctx, `UPDATE access_tokens SET last_used_at = ? WHERE id = ?`, usedAt, id)
+	return err
+}
@@ -0,0 +1,138 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/lborres/kuta"
+)
+
+const accountColumns = `id, user_id, provider_id, account_id, password, access_token, refresh_token, expires_at, profile_data, created_at, updated_at`
+
+func scanAccount(row rowScanner) (*kuta.Account, error) {
+	acc := &kuta.Account{}
+	err := row.Scan(
+		&acc.ID, &acc.UserID, &acc.ProviderID, &acc.AccountID, &acc.Password, &acc.AccessToken, &acc.RefreshToken, &acc.ExpiresAt, jsonColumn{&acc.ProfileData}, &acc.CreatedAt, &acc.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return acc, nil
+}
+
+func (a *Adapter) CreateAccount(ctx context.Context, acc *kuta.Account) error {
+	query := `INSERT INTO accounts (` + accountColumns + `)
+	          VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+
+	profileData, err := jsonValue(acc.ProfileData)
+	if err != nil {
+		return err
+	}
+
+	createdAt := now()
+	_, err = a.db.ExecContext(ctx, query,
+		acc.ID, acc.UserID, acc.ProviderID, acc.AccountID, acc.Password, acc.AccessToken, acc.RefreshToken, acc.ExpiresAt, profileData, createdAt, createdAt,
+	)
+	if err != nil {
+		if isDuplicateEntry(err) {
+			return kuta.ErrAccountLinked
+		}
+		return err
+	}
+
+	acc.CreatedAt = createdAt
+	acc.UpdatedAt = createdAt
+	return nil
+}
+
+func (a *Adapter) GetAccountByID(ctx context.Context, id string) (*kuta.Account, error) {
+	query := `SELECT ` + accountColumns + ` FROM accounts WHERE id = ?`
+
+	acc, err := scanAccount(a.db.QueryRowContext(ctx, query, id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, kuta.ErrUserNotFound
+		}
+		return nil, err
+	}
+	return acc, nil
+}
+
+func (a *Adapter) GetAccountByProvider(ctx context.Context, providerID, accountID string) (*kuta.Account, error) {
+	query := `SELECT ` + accountColumns + ` FROM accounts WHERE provider_id = ? AND account_id = ?`
+
+	acc, err := scanAccount(a.db.QueryRowContext(ctx, query, providerID, accountID))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, kuta.ErrUserNotFound
+		}
+		return nil, err
+	}
+	return acc, nil
+}
+
+func (a *Adapter) GetAccountByUserAndProvider(ctx context.Context, userID, providerID string) ([]*kuta.Account, error) {
+	return a.queryAccounts(ctx, `SELECT `+accountColumns+` FROM accounts WHERE user_id = ? AND provider_id = ?`, userID, providerID)
+}
+
+func (a *Adapter) UpdateAccount(ctx context.Context, acc *kuta.Account) error {
+	query := `UPDATE accounts SET account_id = ?, password = ?, access_token = ?, refresh_token = ?, expires_at = ?, profile_data = ?, updated_at = ?
+	          WHERE id = ?`
+
+	profileData, err := jsonValue(acc.ProfileData)
+	if err != nil {
+		return err
+	}
+
+	updatedAt := now()
+	result, err := a.db.ExecContext(ctx, query,
+		acc.AccountID, acc.Password, acc.AccessToken, acc.RefreshToken, acc.ExpiresAt, profileData, updatedAt, acc.ID,
+	)
+	if err != nil {
+		return err
+	}
+	if err := a.requireRow(ctx, result, kuta.ErrUserNotFound, `SELECT 1 FROM accounts WHERE id = ?`, acc.ID); err != nil {
+		return err
+	}
+
+	acc.UpdatedAt = updatedAt
+	return nil
+}
+
+func (a *Adapter) GetExpiringAccounts(ctx context.Context, before time.Time, limit int) ([]*kuta.Account, error) {
+	query := `SELECT ` + accountColumns + `
+	          FROM accounts
+	          WHERE refresh_token IS NOT NULL AND expires_at < ?
+	          ORDER BY expires_at
+	          LIMIT ?`
+	return a.queryAccounts(ctx, query, before, limit)
+}
+
+func (a *Adapter) DeleteAccount(ctx context.Context, id string) error {
+	_, err := a.db.ExecContext(ctx, `DELETE FROM accounts WHERE id = ?`, id)
+	return err
+}
+
+// queryAccounts runs query, which selects accountColumns
+func (a *Adapter) queryAccounts(ctx context.Context, query string, args ...any) ([]*kuta.Account, error) {
+	rows, err := a.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var accounts []*kuta.Account
+	for rows.Next() {
+		acc, err := scanAccount(rows)
+		if err != nil {
+			return nil, err
+		}
+		accounts = append(accounts, acc)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return accounts, nil
+}
@@ -0,0 +1,41 @@
+package mysql
+
+import (
+	"database/sql"
+	"errors"
+	"testing"
+
+	"github.com/lborres/kuta"
+)
+
+// Requirement: CreateAccount reports a provider account that is already
+// stored as ErrAccountLinked and passes other errors on.
+func TestAdapter_CreateAccount_Errors(t *testing.T) {
+	other := errors.New("Error 2013: Lost connection to MySQL server during query")
+
+	tests := []struct {
+		name string
+		err  error
+		want error
+	}{
+		{name: "duplicate entry", err: errors.New("Error 1062 (23000): Duplicate entry 'google-123' for key 'accounts.idx_accounts_provider_live'"), want: kuta.ErrAccountLinked},
+		{name: "other error", err: other, want: other},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// Arrange
+			db := sql.OpenDB(failingConnector{failingDriver{err: test.err}})
+			defer db.Close()
+			adapter := New(db)
+
+			// Act
+			err := adapter.CreateAccount(t.Context(), &kuta.Account{ID: "acc-1", UserID: "user-1", ProviderID: "google", AccountID: "123"})
+
+			// Assert
+			if !errors.Is(err, test.want) {
+				t.Errorf("CreateAccount() error = %v, want %v", err, test.want)
+			}
+		})
+	}
+}
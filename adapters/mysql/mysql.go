@@ -0,0 +1,164 @@
+// Package mysql stores kuta's data in MySQL 8.0+ or MariaDB 10.5+ through
+// database/sql, after applying the migrations in migrations/mysql. The app
+// picks the driver; with github.com/go-sql-driver/mysql the DSN must set
+// parseTime=true, and timestamps are kept in UTC:
+//
+//	db, err := sql.Open("mysql", "user:pass@tcp(localhost:3306)/app?parseTime=true")
+//	storage := mysql.New(db)
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/lborres/kuta"
+)
+
+const (
+	// mysqlDuplicateEntry is the error number of ER_DUP_ENTRY
+	mysqlDuplicateEntry = "Error 1062"
+
+	// pingTimeout bounds Ping so config checks fail fast on an unreachable
+	// database
+	pingTimeout = 5 * time.Second
+)
+
+type Adapter struct {
+	db *sql.DB
+}
+
+var (
+	_ kuta.StorageProvider    = (*Adapter)(nil)
+	_ kuta.Pinger             = (*Adapter)(nil)
+	_ kuta.CapabilityReporter = (*Adapter)(nil)
+)
+
+func New(db *sql.DB) *Adapter {
+	return &Adapter{db: db}
+}
+
+// Ping checks the database is reachable, giving up after pingTimeout
+func (a *Adapter) Ping() error {
+	ctx, cancel := context.WithTimeout(context.Background(), pingTimeout)
+	defer cancel()
+	return a.db.PingContext(ctx)
+}
+
+// Capabilities reports that InnoDB supports every optional feature
+func (a *Adapter) Capabilities() kuta.StorageCapabilities {
+	return kuta.StorageCapabilities{Transactions: true, SoftDelete: true, Search: true}
+}
+
+// now is the current time as DATETIME(6) columns keep it. Timestamps are
+// set here rather than with NOW(), which follows the session time zone.
+func now() time.Time {
+	return time.Now().UTC().Truncate(time.Microsecond)
+}
+
+// rowScanner is a *sql.Row or *sql.Rows
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+// affected returns how many rows the statement behind result affected
+func affected(result sql.Result, err error) (int, error) {
+	if err != nil {
+		return 0, err
+	}
+	n, err := result.RowsAffected()
+	return int(n), err
+}
+
+// requireRow returns notFound when an UPDATE affected no row and check, a
+// SELECT, finds none either. MySQL counts the rows an UPDATE changed rather
+// than matched, so one that rewrote a row's current values affects none.
+func (a *Adapter) requireRow(ctx context.Context, result sql.Result, notFound error, check string, args ...any) error {
+	if n, err := result.RowsAffected(); err != nil || n > 0 {
+		return err
+	}
+	var found bool
+	if err := a.db.QueryRowContext(ctx, `SELECT EXISTS(`+check+`)`, args...).Scan(&found); err != nil {
+		return err
+	}
+	if !found {
+		return notFound
+	}
+	return nil
+}
+
+// isDuplicateEntry reports whether err is a MySQL unique key violation
+func isDuplicateEntry(err error) bool {
+	return err != nil && strings.HasPrefix(err.Error(), mysqlDuplicateEntry)
+}
+
+// placeholders returns n comma-separated "?" for an IN list
+func placeholders(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?, ", n), ", ")
+}
+
+// qualified prefixes every column of a column list with table, for joins
+func qualified(table, columns string) string {
+	return table + "." + strings.ReplaceAll(columns, ", ", ", "+table+".")
+}
+
+// stringArgs converts values to query arguments
+func stringArgs(values []string) []any {
+	args := make([]any, len(values))
+	for i, v := range values {
+		args[i] = v
+	}
+	return args
+}
+
+// jsonColumn scans a JSON column into dest, leaving it untouched for NULL
+type jsonColumn struct{ dest any }
+
+func (c jsonColumn) Scan(src any) error {
+	switch v := src.(type) {
+	case nil:
+		return nil
+	case []byte:
+		return json.Unmarshal(v, c.dest)
+	case string:
+		return json.Unmarshal([]byte(v), c.dest)
+	default:
+		return fmt.Errorf("mysql: cannot scan %T into a JSON column", src)
+	}
+}
+
+// jsonValue encodes v for a JSON column, or NULL when v is nil
+func jsonValue(v any) (any, error) {
+	if rv := reflect.ValueOf(v); !rv.IsValid() || (rv.Kind() == reflect.Pointer || rv.Kind() == reflect.Map) && rv.IsNil() {
+		return nil, nil
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
+
+// jsonList encodes list for a NOT NULL JSON array column
+func jsonList(list []string) (string, error) {
+	if list == nil {
+		list = []string{}
+	}
+	data, err := json.Marshal(list)
+	return string(data), err
+}
+
+// jsonPath is the JSON path of key in a JSON object column
+func jsonPath(key string) string {
+	quoted, _ := json.Marshal(key)
+	return "$." + string(quoted)
+}
+
+// escapeLike escapes LIKE wildcards so user input is matched literally
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
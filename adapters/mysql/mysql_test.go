@@ -0,0 +1,75 @@
+package mysql
+
+import (
+	"reflect"
+	"testing"
+)
+
+// Requirement: JSON columns round-trip through jsonValue and jsonColumn, and
+// NULL maps to a nil value both ways.
+func TestJSONColumn(t *testing.T) {
+	tests := []struct {
+		name  string
+		value map[string]string
+		src   any
+		want  map[string]string
+	}{
+		{name: "object as bytes", value: map[string]string{"theme": "dark"}, want: map[string]string{"theme": "dark"}},
+		{name: "object as string", src: `{"theme":"dark"}`, want: map[string]string{"theme": "dark"}},
+		{name: "nil map", value: nil, want: nil},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// Arrange
+			src := test.src
+			if src == nil {
+				encoded, err := jsonValue(test.value)
+				if err != nil {
+					t.Fatalf("jsonValue() error = %v", err)
+				}
+				if encoded != nil {
+					src = []byte(encoded.(string))
+				}
+			}
+
+			// Act
+			var got map[string]string
+			err := jsonColumn{&got}.Scan(src)
+
+			// Assert
+			if err != nil {
+				t.Fatalf("Scan() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("Scan() = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+// Requirement: Query helpers build IN lists, quote JSON path keys, match
+// LIKE wildcards in user input literally and qualify columns for joins.
+func TestQueryHelpers(t *testing.T) {
+	tests := []struct {
+		name string
+		got  string
+		want string
+	}{
+		{name: "single placeholder", got: placeholders(1), want: "?"},
+		{name: "placeholders", got: placeholders(3), want: "?, ?, ?"},
+		{name: "json path", got: jsonPath("cart"), want: `$."cart"`},
+		{name: "json path with quotes", got: jsonPath(`a"b.c`), want: `$."a\"b.c"`},
+		{name: "like wildcards", got: escapeLike(`50%_off\`), want: `50\%\_off\\`},
+		{name: "qualified columns", got: qualified("s", "id, user_id"), want: "s.id, s.user_id"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// Assert
+			if test.got != test.want {
+				t.Errorf("got %q, want %q", test.got, test.want)
+			}
+		})
+	}
+}
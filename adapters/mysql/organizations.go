@@ -0,0 +1,177 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/lborres/kuta"
+)
+
+var _ kuta.OrganizationStorage = (*Adapter)(nil)
+
+// organizationQuerier is the *sql.DB or *sql.Tx organization queries run on
+type organizationQuerier interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// SaveOrganizationMember keeps the creation time of an existing membership
+func (a *Adapter) SaveOrganizationMember(ctx context.Context, member *kuta.OrganizationMember) error {
+	saved, err := saveOrganizationMember(ctx, a.db, member.OrganizationID, member.UserID, member.Roles, member.CreatedAt)
+	if err != nil {
+		return err
+	}
+	member.CreatedAt = saved.CreatedAt
+	return nil
+}
+
+// saveOrganizationMember upserts the membership and reads it back, since
+// MySQL has no RETURNING
+func saveOrganizationMember(ctx context.Context, q organizationQuerier, organizationID, userID string, roles []string, createdAt time.Time) (*kuta.OrganizationMember, error) {
+	query := `INSERT INTO organization_members (organization_id, user_id, roles, created_at)
+	          VALUES (?, ?, ?, ?)
+	          ON DUPLICATE KEY UPDATE roles = VALUES(roles)`
+
+	list, err := jsonList(roles)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := q.ExecContext(ctx, query, organizationID, userID, list, createdAt); err != nil {
+		return nil, err
+	}
+
+	member := &kuta.OrganizationMember{}
+	err = q.QueryRowContext(ctx, `SELECT organization_id, user_id, roles, created_at
+	          FROM organization_members WHERE organization_id = ? AND user_id = ?`, organizationID, userID).
+		Scan(&member.OrganizationID, &member.UserID, jsonColumn{&member.Roles}, &member.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return member, nil
+}
+
+func (a *Adapter) RemoveOrganizationMember(ctx context.Context, organizationID, userID string) error {
+	_, err := a.db.ExecContext(ctx, `DELETE FROM organization_members WHERE organization_id = ? AND user_id = ?`, organizationID, userID)
+	return err
+}
+
+func (a *Adapter) GetOrganizationMember(ctx context.Context, organizationID, userID string) (*kuta.OrganizationMember, error) {
+	query := `SELECT organization_id, user_id, roles, created_at
+	          FROM organization_members WHERE organization_id = ? AND user_id = ?`
+
+	member := &kuta.OrganizationMember{}
+	err := a.db.QueryRowContext(ctx, query, organizationID, userID).Scan(&member.OrganizationID, &member.UserID, jsonColumn{&member.Roles}, &member.CreatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, kuta.ErrNotOrganizationMember
+		}
+		return nil, err
+	}
+	return member, nil
+}
+
+func (a *Adapter) ListUserOrganizations(ctx context.Context, userID string) ([]*kuta.OrganizationMember, error) {
+	query := `SELECT organization_id, user_id, roles, created_at
+	          FROM organization_members WHERE user_id = ?
+	          ORDER BY created_at, organization_id`
+
+	rows, err := a.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	members := []*kuta.OrganizationMember{}
+	for rows.Next() {
+		member := &kuta.OrganizationMember{}
+		if err := rows.Scan(&member.OrganizationID, &member.UserID, jsonColumn{&member.Roles}, &member.CreatedAt); err != nil {
+			return nil, err
+		}
+		members = append(members, member)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return members, nil
+}
+
+func (a *Adapter) SavePendingOrganizationMember(ctx context.Context, pending *kuta.PendingOrganizationMember) error {
+	query := `INSERT INTO organization_invites (id, organization_id, email, roles, invited_by, expires_at, created_at)
+	          VALUES (?, ?, ?, ?, ?, ?, ?)`
+
+	roles, err := jsonList(pending.Roles)
+	if err != nil {
+		return err
+	}
+	_, err = a.db.ExecContext(ctx, query, pending.InviteID, pending.OrganizationID, pending.Email, roles,
+		pending.InvitedBy, pending.ExpiresAt, pending.CreatedAt)
+	return err
+}
+
+// ActivatePendingOrganizationMember deletes the invite and upserts the
+// membership in one transaction. The invite row stays locked until then,
+// so an invite activates at most once.
+func (a *Adapter) ActivatePendingOrganizationMember(ctx context.Context, inviteID, userID string) (*kuta.OrganizationMember, error) {
+	tx, err := a.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var organizationID string
+	var roles []string
+	err = tx.QueryRowContext(ctx, `SELECT organization_id, roles FROM organization_invites WHERE id = ? FOR UPDATE`, inviteID).
+		Scan(&organizationID, jsonColumn{&roles})
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, kuta.ErrVerificationTokenNotFound
+		}
+		return nil, err
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM organization_invites WHERE id = ?`, inviteID); err != nil {
+		return nil, err
+	}
+
+	member, err := saveOrganizationMember(ctx, tx, organizationID, userID, roles, now())
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return member, nil
+}
+
+func (a *Adapter) ListPendingOrganizationMembers(ctx context.Context, organizationID string) ([]*kuta.PendingOrganizationMember, error) {
+	query := `SELECT id, organization_id, email, roles, invited_by, expires_at, created_at
+	          FROM organization_invites WHERE organization_id = ? AND expires_at > ?
+	          ORDER BY created_at, id`
+
+	rows, err := a.db.QueryContext(ctx, query, organizationID, now())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	pending := []*kuta.PendingOrganizationMember{}
+	for rows.Next() {
+		invite := &kuta.PendingOrganizationMember{}
+		if err := rows.Scan(&invite.InviteID, &invite.OrganizationID, &invite.Email, jsonColumn{&invite.Roles},
+			&invite.InvitedBy, &invite.ExpiresAt, &invite.CreatedAt); err != nil {
+			return nil, err
+		}
+		pending = append(pending, invite)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return pending, nil
+}
+
+func (a *Adapter) DeletePendingOrganizationMember(ctx context.Context, inviteID string) error {
+	_, err := a.db.ExecContext(ctx, `DELETE FROM organization_invites WHERE id = ?`, inviteID)
+	return err
+}
@@ -0,0 +1,117 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/lborres/kuta"
+)
+
+func (a *Adapter) CreateRefreshToken(ctx context.Context, token *kuta.RefreshToken) error {
+	query := `INSERT INTO refresh_tokens (id, user_id, session_id, parent_id, token_hash, ip_address, user_agent, public_key, expires_at, authenticated_at, created_at)
+	          VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+
+	createdAt := now()
+	_, err := a.db.ExecContext(ctx, query,
+		token.ID, token.UserID, token.SessionID, token.ParentID, token.TokenHash, token.IPAddress, token.UserAgent, token.PublicKey, token.ExpiresAt, token.AuthenticatedAt, createdAt,
+	)
+	if err != nil {
+		return err
+	}
+
+	token.CreatedAt = createdAt
+	return nil
+}
+
+// refreshTokenColumns is the column list refresh token queries select, in
+// the order scanRefreshToken reads them
+const refreshTokenColumns = `id, user_id, session_id, parent_id, token_hash, ip_address, user_agent, public_key, expires_at, authenticated_at, revoked_at, rotated_at, created_at`
+
+func scanRefreshToken(row rowScanner) (*kuta.RefreshToken, error) {
+	token := &kuta.RefreshToken{}
+	err := row.Scan(
+		&token.ID, &token.UserID, &token.SessionID, &token.ParentID, &token.TokenHash, &token.IPAddress, &token.UserAgent, &token.PublicKey, &token.ExpiresAt, &token.AuthenticatedAt, &token.RevokedAt, &token.RotatedAt, &token.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return token, nil
+}
+
+func (a *Adapter) GetRefreshTokenByHash(ctx context.Context, tokenHash string) (*kuta.RefreshToken, error) {
+	query := `SELECT ` + refreshTokenColumns + `
+	          FROM refresh_tokens WHERE token_hash = ?`
+
+	token, err := scanRefreshToken(a.db.QueryRowContext(ctx, query, tokenHash))
+
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, kuta.ErrRefreshTokenNotFound
+		}
+		return nil, err
+	}
+
+	return token, nil
+}
+
+func (a *Adapter) RevokeRefreshToken(ctx context.Context, id string) error {
+	n, err := affected(a.db.ExecContext(ctx,
+		`UPDATE refresh_tokens SET revoked_at = ? WHERE id = ? AND revoked_at IS NULL`, now(), id))
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return kuta.ErrRefreshTokenNotFound
+	}
+	return nil
+}
+
+func (a *Adapter) RotateRefreshToken(ctx context.Context, id string) error {
+	retiredAt := now()
+	n, err := affected(a.db.ExecContext(ctx,
+		`UPDATE refresh_tokens SET revoked_at = ?, rotated_at = ? WHERE id = ? AND revoked_at IS NULL`, retiredAt, retiredAt, id))
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return kuta.ErrRefreshTokenNotFound
+	}
+	return nil
+}
+
+func (a *Adapter) RevokeSessionRefreshTokens(ctx context.Context, sessionID string) (int, error) {
+	return affected(a.db.ExecContext(ctx,
+		`UPDATE refresh_tokens SET revoked_at = ? WHERE session_id = ? AND revoked_at IS NULL`, now(), sessionID))
+}
+
+func (a *Adapter) RevokeUserRefreshTokens(ctx context.Context, userID string) (int, error) {
+	return affected(a.db.ExecContext(ctx,
+		`UPDATE refresh_tokens SET revoked_at = ? WHERE user_id = ? AND revoked_at IS NULL`, now(), userID))
+}
+
+func (a *Adapter) GetActiveUserRefreshTokens(ctx context.Context, userID string) ([]*kuta.RefreshToken, error) {
+	query := `SELECT ` + refreshTokenColumns + `
+	          FROM refresh_tokens
+	          WHERE user_id = ? AND revoked_at IS NULL AND expires_at > ?
+	          ORDER BY created_at`
+
+	rows, err := a.db.QueryContext(ctx, query, userID, now())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tokens []*kuta.RefreshToken
+	for rows.Next() {
+		token, err := scanRefreshToken(rows)
+		if err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, token)
+	}
+	return tokens, rows.Err()
+}
+
+func (a *Adapter) DeleteExpiredRefreshTokens(ctx context.Context) (int, error) {
+	return affected(a.db.ExecContext(ctx, `DELETE FROM refresh_tokens WHERE expires_at < ?`, now()))
+}
@@ -0,0 +1,88 @@
+package mysql
+
+import (
+	"context"
+	"strings"
+
+	"github.com/lborres/kuta"
+)
+
+var _ kuta.RoleStorage = (*Adapter)(nil)
+
+func (a *Adapter) AssignRole(ctx context.Context, userID, role string) error {
+	query := `INSERT IGNORE INTO user_roles (user_id, role) VALUES (?, ?)`
+
+	_, err := a.db.ExecContext(ctx, query, userID, role)
+	return err
+}
+
+func (a *Adapter) RevokeRole(ctx context.Context, userID, role string) error {
+	_, err := a.db.ExecContext(ctx, `DELETE FROM user_roles WHERE user_id = ? AND role = ?`, userID, role)
+	return err
+}
+
+func (a *Adapter) GetUserRoles(ctx context.Context, userID string) ([]string, error) {
+	return a.queryNames(ctx, `SELECT role FROM user_roles WHERE user_id = ? ORDER BY role`, userID)
+}
+
+// SetRolePermissions replaces the permissions of role in one transaction,
+// so concurrent readers see either the old or the new set
+func (a *Adapter) SetRolePermissions(ctx context.Context, role string, permissions []string) error {
+	tx, err := a.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if len(permissions) == 0 {
+		_, err = tx.ExecContext(ctx, `DELETE FROM role_permissions WHERE role = ?`, role)
+		if err != nil {
+			return err
+		}
+		return tx.Commit()
+	}
+
+	list := placeholders(len(permissions))
+	args := append([]any{role}, stringArgs(permissions)...)
+	if _, err := tx.ExecContext(ctx, `DELETE FROM role_permissions WHERE role = ? AND permission NOT IN (`+list+`)`, args...); err != nil {
+		return err
+	}
+
+	values := make([]any, 0, 2*len(permissions))
+	for _, permission := range permissions {
+		values = append(values, role, permission)
+	}
+	rows := strings.TrimSuffix(strings.Repeat("(?, ?), ", len(permissions)), ", ")
+	if _, err := tx.ExecContext(ctx, `INSERT IGNORE INTO role_permissions (role, permission) VALUES `+rows, values...); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (a *Adapter) GetRolePermissions(ctx context.Context, role string) ([]string, error) {
+	return a.queryNames(ctx, `SELECT permission FROM role_permissions WHERE role = ? ORDER BY permission`, role)
+}
+
+// queryNames runs query, which selects a single text column, and returns the
+// values, or an empty list when there are none
+func (a *Adapter) queryNames(ctx context.Context, query string, args ...any) ([]string, error) {
+	rows, err := a.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	names := []string{}
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		names = append(names, name)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return names, nil
+}
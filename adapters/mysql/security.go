@@ -0,0 +1,62 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/lborres/kuta"
+)
+
+func (a *Adapter) GetUserSecurity(ctx context.Context, userID string) (*kuta.UserSecurity, error) {
+	query := `SELECT user_id, two_factor_enabled, allowed_providers, max_sessions,
+	                 notify_new_sign_in, notify_password_changed, notify_anomalies, updated_at
+	          FROM user_security WHERE user_id = ?`
+
+	s := &kuta.UserSecurity{}
+	err := a.db.QueryRowContext(ctx, query, userID).Scan(
+		&s.UserID, &s.TwoFactorEnabled, jsonColumn{&s.AllowedProviders}, &s.MaxSessions,
+		&s.Notifications.NewSignIn, &s.Notifications.PasswordChanged, &s.Notifications.Anomalies, &s.UpdatedAt,
+	)
+
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, kuta.ErrUserSecurityNotFound
+		}
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// UpsertUserSecurity uses VALUES() rather than a row alias so the statement
+// also runs on MariaDB
+func (a *Adapter) UpsertUserSecurity(ctx context.Context, s *kuta.UserSecurity) error {
+	query := `INSERT INTO user_security (user_id, two_factor_enabled, allowed_providers, max_sessions,
+	                                     notify_new_sign_in, notify_password_changed, notify_anomalies, updated_at)
+	          VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+	          ON DUPLICATE KEY UPDATE
+	            two_factor_enabled = VALUES(two_factor_enabled),
+	            allowed_providers = VALUES(allowed_providers),
+	            max_sessions = VALUES(max_sessions),
+	            notify_new_sign_in = VALUES(notify_new_sign_in),
+	            notify_password_changed = VALUES(notify_password_changed),
+	            notify_anomalies = VALUES(notify_anomalies),
+	            updated_at = VALUES(updated_at)`
+
+	providers, err := jsonList(s.AllowedProviders)
+	if err != nil {
+		return err
+	}
+
+	updatedAt := now()
+	_, err = a.db.ExecContext(ctx, query,
+		s.UserID, s.TwoFactorEnabled, providers, s.MaxSessions,
+		s.Notifications.NewSignIn, s.Notifications.PasswordChanged, s.Notifications.Anomalies, updatedAt,
+	)
+	if err != nil {
+		return err
+	}
+
+	s.UpdatedAt = updatedAt
+	return nil
+}
@@ -0,0 +1,292 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/lborres/kuta"
+)
+
+// sessionColumns is the column list session queries select, in the order
+// scanSession reads them
+const sessionColumns = `id, user_id, token_hash, ip_address, user_agent, public_key, expires_at, revoked_at, impersonator_id, impersonation_started_at, data, active_organization_id, entitlements, created_at, updated_at`
+
+// scanSession reads a row selected with sessionColumns, followed by extra
+func scanSession(row rowScanner, extra ...any) (*kuta.Session, error) {
+	session := &kuta.Session{}
+	var impersonatorID *string
+	var impersonationStartedAt *time.Time
+	dest := append([]any{
+		&session.ID, &session.UserID, &session.TokenHash, &session.IPAddress, &session.UserAgent, &session.PublicKey, &session.ExpiresAt, &session.RevokedAt, &impersonatorID, &impersonationStartedAt, jsonColumn{&session.Data}, &session.ActiveOrganizationID, jsonColumn{&session.Entitlements}, &session.CreatedAt, &session.UpdatedAt,
+	}, extra...)
+
+	if err := row.Scan(dest...); err != nil {
+		return nil, err
+	}
+
+	if impersonatorID != nil && impersonationStartedAt != nil {
+		session.Impersonation = &kuta.Impersonation{ActorUserID: *impersonatorID, StartedAt: *impersonationStartedAt}
+	}
+	return session, nil
+}
+
+func (a *Adapter) CreateSession(ctx context.Context, session *kuta.Session) error {
+	query := `INSERT INTO sessions (` + sessionColumns + `)
+	          VALUES (?, ?, ?, ?, ?, ?, ?, NULL, ?, ?, ?, ?, ?, ?, ?)`
+
+	var impersonatorID *string
+	var impersonationStartedAt *time.Time
+	if session.Impersonation != nil {
+		impersonatorID = &session.Impersonation.ActorUserID
+		impersonationStartedAt = &session.Impersonation.StartedAt
+	}
+
+	data := session.Data
+	if data == nil {
+		data = map[string]string{}
+	}
+	dataValue, err := jsonValue(data)
+	if err != nil {
+		return err
+	}
+	entitlements, err := jsonValue(session.Entitlements)
+	if err != nil {
+		return err
+	}
+
+	createdAt := now()
+	_, err = a.db.ExecContext(ctx, query,
+		session.ID, session.UserID, session.TokenHash, session.IPAddress, session.UserAgent, session.PublicKey, session.ExpiresAt, impersonatorID, impersonationStartedAt, dataValue, session.ActiveOrganizationID, entitlements, createdAt, createdAt,
+	)
+	if err != nil {
+		return err
+	}
+
+	session.CreatedAt = createdAt
+	session.UpdatedAt = createdAt
+	return nil
+}
+
+func (a *Adapter) GetSessionByHash(ctx context.Context, tokenHash string) (*kuta.Session, error) {
+	query := `SELECT ` + sessionColumns + ` FROM sessions WHERE token_hash = ?`
+
+	session, err := scanSession(a.db.QueryRowContext(ctx, query, tokenHash))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, kuta.ErrSessionNotFound
+		}
+		return nil, err
+	}
+	return session, nil
+}
+
+func (a *Adapter) GetSessionWithUserByHash(ctx context.Context, tokenHash string) (*kuta.Session, *kuta.User, error) {
+	query := `SELECT ` + qualified("s", sessionColumns) + `, ` + qualified("u", userColumns) + `
+	          FROM sessions s
+	          LEFT JOIN users u ON u.id = s.user_id AND u.deleted_at IS NULL
+	          WHERE s.token_hash = ?`
+
+	// The user's columns are all NULL when the join finds no user
+	var userID, email, name, status *string
+	var emailVerified *bool
+	var image *string
+	var createdAt, updatedAt *time.Time
+	session, err := scanSession(a.db.QueryRowContext(ctx, query, tokenHash),
+		&userID, &email, &emailVerified, &name, &image, &status, &createdAt, &updatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil, kuta.ErrSessionNotFound
+		}
+		return nil, nil, err
+	}
+	if userID == nil {
+		return session, nil, nil
+	}
+
+	user := &kuta.User{ID: *userID, Email: *email, EmailVerified: *emailVerified, Name: *name, Image: image, Status: *status, CreatedAt: *createdAt, UpdatedAt: *updatedAt}
+	return session, user, nil
+}
+
+func (a *Adapter) GetSessionsByHashes(ctx context.Context, tokenHashes []string) ([]*kuta.Session, error) {
+	if len(tokenHashes) == 0 {
+		return nil, nil
+	}
+	query := `SELECT ` + sessionColumns + `
+	          FROM sessions WHERE token_hash IN (` + placeholders(len(tokenHashes)) + `)`
+	return a.querySessions(ctx, query, stringArgs(tokenHashes)...)
+}
+
+func (a *Adapter) GetSessionByID(ctx context.Context, id string) (*kuta.Session, error) {
+	query := `SELECT ` + sessionColumns + ` FROM sessions WHERE id = ?`
+
+	session, err := scanSession(a.db.QueryRowContext(ctx, query, id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, kuta.ErrSessionNotFound
+		}
+		return nil, err
+	}
+	return session, nil
+}
+
+func (a *Adapter) GetUserSessions(ctx context.Context, userID string) ([]*kuta.Session, error) {
+	return a.querySessions(ctx, `SELECT `+sessionColumns+` FROM sessions WHERE user_id = ? ORDER BY created_at DESC`, userID)
+}
+
+func (a *Adapter) UpdateSession(ctx context.Context, session *kuta.Session) error {
+	query := `UPDATE sessions SET token_hash = ?, ip_address = ?, user_agent = ?, expires_at = ?, revoked_at = ?, active_organization_id = ?, entitlements = ?, updated_at = ?
+	          WHERE id = ?`
+
+	entitlements, err := jsonValue(session.Entitlements)
+	if err != nil {
+		return err
+	}
+
+	updatedAt := now()
+	result, err := a.db.ExecContext(ctx, query,
+		session.TokenHash, session.IPAddress, session.UserAgent, session.ExpiresAt, session.RevokedAt, session.ActiveOrganizationID, entitlements, updatedAt, session.ID,
+	)
+	if err != nil {
+		return err
+	}
+	if err := a.requireRow(ctx, result, kuta.ErrSessionNotFound, `SELECT 1 FROM sessions WHERE id = ?`, session.ID); err != nil {
+		return err
+	}
+
+	session.UpdatedAt = updatedAt
+	return nil
+}
+
+func (a *Adapter) DeleteSessionByID(ctx context.Context, id string) error {
+	_, err := a.db.ExecContext(ctx, `DELETE FROM sessions WHERE id = ?`, id)
+	return err
+}
+
+func (a *Adapter) DeleteSessionByHash(ctx context.Context, tokenHash string) error {
+	_, err := a.db.ExecContext(ctx, `DELETE FROM sessions WHERE token_hash = ?`, tokenHash)
+	return err
+}
+
+func (a *Adapter) DeleteUserSessions(ctx context.Context, userID string) (int, error) {
+	return affected(a.db.ExecContext(ctx, `DELETE FROM sessions WHERE user_id = ?`, userID))
+}
+
+func (a *Adapter) DeleteExpiredSessions(ctx context.Context) (int, error) {
+	return affected(a.db.ExecContext(ctx, `DELETE FROM sessions WHERE expires_at < ?`, now()))
+}
+
+func (a *Adapter) SearchSessions(ctx context.Context, filter kuta.SessionFilter) ([]*kuta.Session, int, error) {
+	var conditions []string
+	var args []any
+	addCondition := func(clause string, value any) {
+		conditions = append(conditions, clause)
+		args = append(args, value)
+	}
+
+	if filter.UserID != "" {
+		addCondition("user_id = ?", filter.UserID)
+	}
+	if filter.IPAddress != "" {
+		addCondition("ip_address = ?", filter.IPAddress)
+	}
+	if filter.UserAgent != "" {
+		// Case-insensitive under the default utf8mb4 collations
+		addCondition("user_agent LIKE CONCAT('%', ?, '%')", escapeLike(filter.UserAgent))
+	}
+	if !filter.CreatedAfter.IsZero() {
+		addCondition("created_at >= ?", filter.CreatedAfter)
+	}
+	if !filter.CreatedBefore.IsZero() {
+		addCondition("created_at < ?", filter.CreatedBefore)
+	}
+
+	where := ""
+	if len(conditions) > 0 {
+		where = "WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	query := fmt.Sprintf(`SELECT `+sessionColumns+`, COUNT(*) OVER()
+	          FROM sessions %s ORDER BY created_at DESC LIMIT ? OFFSET ?`, where)
+
+	rows, err := a.db.QueryContext(ctx, query, append(args, filter.Limit, filter.Offset)...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	var sessions []*kuta.Session
+	total := 0
+	for rows.Next() {
+		session, err := scanSession(rows, &total)
+		if err != nil {
+			return nil, 0, err
+		}
+		sessions = append(sessions, session)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	// COUNT(*) OVER() has no row to ride on when the page is past the end
+	if len(sessions) == 0 && filter.Offset > 0 {
+		countQuery := fmt.Sprintf(`SELECT COUNT(*) FROM sessions %s`, where)
+		if err := a.db.QueryRowContext(ctx, countQuery, args...).Scan(&total); err != nil {
+			return nil, 0, err
+		}
+	}
+
+	return sessions, total, nil
+}
+
+func (a *Adapter) DeleteSessionsByIDs(ctx context.Context, ids []string) (int, error) {
+	if len(ids) == 0 {
+		return 0, nil
+	}
+	query := `DELETE FROM sessions WHERE id IN (` + placeholders(len(ids)) + `)`
+	return affected(a.db.ExecContext(ctx, query, stringArgs(ids)...))
+}
+
+// SetSessionValue sets the key inside the data column, so concurrent writes
+// to different keys don't overwrite each other
+func (a *Adapter) SetSessionValue(ctx context.Context, sessionID, key, value string) error {
+	result, err := a.db.ExecContext(ctx, `UPDATE sessions SET data = JSON_SET(data, ?, ?) WHERE id = ?`, jsonPath(key), value, sessionID)
+	if err != nil {
+		return err
+	}
+	return a.requireRow(ctx, result, kuta.ErrSessionNotFound, `SELECT 1 FROM sessions WHERE id = ?`, sessionID)
+}
+
+func (a *Adapter) DeleteSessionValue(ctx context.Context, sessionID, key string) error {
+	result, err := a.db.ExecContext(ctx, `UPDATE sessions SET data = JSON_REMOVE(data, ?) WHERE id = ?`, jsonPath(key), sessionID)
+	if err != nil {
+		return err
+	}
+	return a.requireRow(ctx, result, kuta.ErrSessionNotFound, `SELECT 1 FROM sessions WHERE id = ?`, sessionID)
+}
+
+// querySessions runs query, which selects sessionColumns
+func (a *Adapter) querySessions(ctx context.Context, query string, args ...any) ([]*kuta.Session, error) {
+	rows, err := a.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var sessions []*kuta.Session
+	for rows.Next() {
+		session, err := scanSession(rows)
+		if err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, session)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return sessions, nil
+}
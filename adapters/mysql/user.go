@@ -0,0 +1,197 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/lborres/kuta"
+)
+
+var _ kuta.DeletedUserReader = (*Adapter)(nil)
+
+const userColumns = `id, email, email_verified, name, image, status, created_at, updated_at`
+
+func scanUser(row rowScanner, extra ...any) (*kuta.User, error) {
+	user := &kuta.User{}
+	dest := append([]any{&user.ID, &user.Email, &user.EmailVerified, &user.Name, &user.Image, &user.Status, &user.CreatedAt, &user.UpdatedAt}, extra...)
+	if err := row.Scan(dest...); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// insertUser inserts user with an "active" status unless it has one. With
+// ignoreExisting, a taken email leaves the table untouched and inserted is
+// false.
+func (a *Adapter) insertUser(ctx context.Context, user *kuta.User, ignoreExisting bool) (inserted bool, err error) {
+	query := `INSERT INTO users (` + userColumns + `) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`
+	if ignoreExisting {
+		query += ` ON DUPLICATE KEY UPDATE id = id`
+	}
+
+	status := user.Status
+	if status == "" {
+		status = kuta.UserStatusActive
+	}
+	createdAt := now()
+
+	n, err := affected(a.db.ExecContext(ctx, query, user.ID, user.Email, user.EmailVerified, user.Name, user.Image, status, createdAt, createdAt))
+	// ON DUPLICATE KEY UPDATE affects no rows when the email is taken
+	if err != nil || n == 0 {
+		return false, err
+	}
+
+	user.Status = status
+	user.CreatedAt = createdAt
+	user.UpdatedAt = createdAt
+	return true, nil
+}
+
+func (a *Adapter) CreateUser(ctx context.Context, user *kuta.User) error {
+	if _, err := a.insertUser(ctx, user, false); err != nil {
+		if isDuplicateEntry(err) {
+			return kuta.ErrUserExists
+		}
+		return err
+	}
+	return nil
+}
+
+func (a *Adapter) CreateUserIfNotExists(ctx context.Context, user *kuta.User) error {
+	inserted, err := a.insertUser(ctx, user, true)
+	if err != nil {
+		return err
+	}
+	if !inserted {
+		return kuta.ErrUserExists
+	}
+	return nil
+}
+
+func (a *Adapter) GetUserByID(ctx context.Context, id string) (*kuta.User, error) {
+	return a.getUser(ctx, `SELECT `+userColumns+` FROM users WHERE id = ? AND deleted_at IS NULL`, id)
+}
+
+// GetUserByIDIncludingDeleted also finds soft-deleted users
+func (a *Adapter) GetUserByIDIncludingDeleted(ctx context.Context, id string) (*kuta.User, error) {
+	return a.getUser(ctx, `SELECT `+userColumns+` FROM users WHERE id = ?`, id)
+}
+
+func (a *Adapter) getUser(ctx context.Context, query, id string) (*kuta.User, error) {
+	user, err := scanUser(a.db.QueryRowContext(ctx, query, id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, kuta.ErrUserNotFound
+		}
+		return nil, err
+	}
+	return user, nil
+}
+
+func (a *Adapter) GetUserByEmail(ctx context.Context, email string) (*kuta.User, error) {
+	query := `SELECT ` + userColumns + ` FROM users WHERE email = ? AND deleted_at IS NULL`
+
+	user, err := scanUser(a.db.QueryRowContext(ctx, query, email))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, kuta.ErrUserNotFound
+		}
+		return nil, err
+	}
+	return user, nil
+}
+
+func (a *Adapter) UpdateUser(ctx context.Context, user *kuta.User) error {
+	query := `UPDATE users SET email = ?, email_verified = ?, name = ?, image = ?, updated_at = ? WHERE id = ? AND deleted_at IS NULL`
+
+	updatedAt := now()
+	result, err := a.db.ExecContext(ctx, query, user.Email, user.EmailVerified, user.Name, user.Image, updatedAt, user.ID)
+	if err != nil {
+		if isDuplicateEntry(err) {
+			return kuta.ErrUserExists
+		}
+		return err
+	}
+	if err := a.requireRow(ctx, result, kuta.ErrUserNotFound, `SELECT 1 FROM users WHERE id = ? AND deleted_at IS NULL`, user.ID); err != nil {
+		return err
+	}
+	user.UpdatedAt = updatedAt
+	return nil
+}
+
+func (a *Adapter) DeleteUser(ctx context.Context, id string) error {
+	_, err := a.db.ExecContext(ctx, `DELETE FROM users WHERE id = ? AND deleted_at IS NULL`, id)
+	return err
+}
+
+func (a *Adapter) SoftDeleteUser(ctx context.Context, id string) error {
+	deletedAt := now()
+	n, err := affected(a.db.ExecContext(ctx, `UPDATE users SET deleted_at = ?, updated_at = ? WHERE id = ? AND deleted_at IS NULL`, deletedAt, deletedAt, id))
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return kuta.ErrUserNotFound
+	}
+	return nil
+}
+
+func (a *Adapter) RestoreUser(ctx context.Context, id string, deletedAfter time.Time) error {
+	n, err := affected(a.db.ExecContext(ctx, `UPDATE users SET deleted_at = NULL, updated_at = ? WHERE id = ? AND deleted_at >= ?`, now(), id, deletedAfter))
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return kuta.ErrUserNotFound
+	}
+	return nil
+}
+
+func (a *Adapter) ListUsersByStatus(ctx context.Context, status string, limit, offset int) ([]*kuta.User, int, error) {
+	query := `SELECT ` + userColumns + `, COUNT(*) OVER()
+	          FROM users WHERE status = ? AND deleted_at IS NULL
+	          ORDER BY created_at LIMIT ? OFFSET ?`
+
+	rows, err := a.db.QueryContext(ctx, query, status, limit, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	var users []*kuta.User
+	total := 0
+	for rows.Next() {
+		user, err := scanUser(rows, &total)
+		if err != nil {
+			return nil, 0, err
+		}
+		users = append(users, user)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	// COUNT(*) OVER() has no row to ride on when the page is past the end
+	if len(users) == 0 && offset > 0 {
+		countQuery := `SELECT COUNT(*) FROM users WHERE status = ? AND deleted_at IS NULL`
+		if err := a.db.QueryRowContext(ctx, countQuery, status).Scan(&total); err != nil {
+			return nil, 0, err
+		}
+	}
+
+	return users, total, nil
+}
+
+func (a *Adapter) SetUserStatus(ctx context.Context, id, from, to string) error {
+	result, err := a.db.ExecContext(ctx, `UPDATE users SET status = ?, updated_at = ? WHERE id = ? AND status = ? AND deleted_at IS NULL`, to, now(), id, from)
+	if err != nil {
+		return err
+	}
+	return a.requireRow(ctx, result, kuta.ErrUserNotFound, `SELECT 1 FROM users WHERE id = ? AND status = ? AND deleted_at IS NULL`, id, from)
+}
+
+func (a *Adapter) PurgeDeletedUsers(ctx context.Context, deletedBefore time.Time) (int, error) {
+	return affected(a.db.ExecContext(ctx, `DELETE FROM users WHERE deleted_at IS NOT NULL AND deleted_at < ?`, deletedBefore))
+}
@@ -0,0 +1,73 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"testing"
+
+	"github.com/lborres/kuta"
+)
+
+// failingDriver is a database/sql driver whose statements all fail with err,
+// worded like the MySQL driver words its errors
+type failingDriver struct {
+	err error
+}
+
+func (d failingDriver) Open(string) (driver.Conn, error) {
+	return failingConn(d), nil
+}
+
+type failingConn struct {
+	err error
+}
+
+func (c failingConn) Prepare(string) (driver.Stmt, error) { return nil, c.err }
+func (c failingConn) Close() error                        { return nil }
+func (c failingConn) Begin() (driver.Tx, error)           { return nil, c.err }
+
+func (c failingConn) ExecContext(context.Context, string, []driver.NamedValue) (driver.Result, error) {
+	return nil, c.err
+}
+
+// failingConnector opens failingDriver connections for sql.OpenDB
+type failingConnector struct {
+	driver failingDriver
+}
+
+func (c failingConnector) Connect(context.Context) (driver.Conn, error) { return c.driver.Open("") }
+func (c failingConnector) Driver() driver.Driver                        { return c.driver }
+
+// Requirement: UpdateUser reports an email taken by another user as
+// ErrUserExists and passes other errors on.
+func TestAdapter_UpdateUser_Errors(t *testing.T) {
+	other := errors.New("Error 2013: Lost connection to MySQL server during query")
+
+	tests := []struct {
+		name string
+		err  error
+		want error
+	}{
+		{name: "duplicate entry", err: errors.New("Error 1062 (23000): Duplicate entry 'b@example.com' for key 'users.email'"), want: kuta.ErrUserExists},
+		{name: "other error", err: other, want: other},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// Arrange
+			db := sql.OpenDB(failingConnector{failingDriver{err: test.err}})
+			defer db.Close()
+			adapter := New(db)
+
+			// Act
+			err := adapter.UpdateUser(t.Context(), &kuta.User{ID: "user-1", Email: "b@example.com"})
+
+			// Assert
+			if !errors.Is(err, test.want) {
+				t.Errorf("UpdateUser() error = %v, want %v", err, test.want)
+			}
+		})
+	}
+}
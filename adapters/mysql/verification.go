@@ -0,0 +1,74 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/lborres/kuta"
+)
+
+func (a *Adapter) CreateVerificationToken(ctx context.Context, token *kuta.VerificationToken) error {
+	query := `INSERT INTO verification_tokens (id, user_id, identifier, purpose, token_hash, expires_at, created_at)
+	          VALUES (?, ?, ?, ?, ?, ?, ?)`
+
+	createdAt := now()
+	_, err := a.db.ExecContext(ctx, query,
+		token.ID, token.UserID, token.Identifier, token.Purpose, token.TokenHash, token.ExpiresAt, createdAt,
+	)
+	if err != nil {
+		return err
+	}
+
+	token.CreatedAt = createdAt
+	return nil
+}
+
+// ConsumeVerificationToken marks the token consumed before reading it back.
+// Only the caller whose UPDATE affected the row gets the token, so it is
+// still consumed at most once.
+func (a *Adapter) ConsumeVerificationToken(ctx context.Context, tokenHash, purpose string) (*kuta.VerificationToken, error) {
+	consumedAt := now()
+	n, err := affected(a.db.ExecContext(ctx,
+		`UPDATE verification_tokens SET consumed_at = ?
+		 WHERE token_hash = ? AND purpose = ? AND consumed_at IS NULL AND expires_at > ?`, consumedAt, tokenHash, purpose, consumedAt))
+	if err != nil {
+		return nil, err
+	}
+	if n == 0 {
+		return nil, kuta.ErrVerificationTokenNotFound
+	}
+
+	query := `SELECT id, user_id, identifier, purpose, token_hash, expires_at, consumed_at, created_at
+	          FROM verification_tokens WHERE token_hash = ?`
+
+	token := &kuta.VerificationToken{}
+	err = a.db.QueryRowContext(ctx, query, tokenHash).Scan(
+		&token.ID, &token.UserID, &token.Identifier, &token.Purpose, &token.TokenHash, &token.ExpiresAt, &token.ConsumedAt, &token.CreatedAt,
+	)
+
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, kuta.ErrVerificationTokenNotFound
+		}
+		return nil, err
+	}
+
+	return token, nil
+}
+
+func (a *Adapter) RevokeUserVerificationTokens(ctx context.Context, userID string, purposes ...string) (int, error) {
+	if len(purposes) == 0 {
+		return 0, nil
+	}
+	revokedAt := now()
+	args := append([]any{revokedAt, userID}, stringArgs(purposes)...)
+	return affected(a.db.ExecContext(ctx,
+		`UPDATE verification_tokens SET consumed_at = ?
+		 WHERE user_id = ? AND purpose IN (`+placeholders(len(purposes))+`) AND consumed_at IS NULL AND expires_at > ?`, append(args, revokedAt)...))
+}
+
+func (a *Adapter) PurgeVerificationTokens(ctx context.Context, expiredBefore, consumedBefore time.Time) (int, error) {
+	return affected(a.db.ExecContext(ctx,
+		`DELETE FROM verification_tokens WHERE expires_at < ? OR consumed_at < ?`, expiredBefore, consumedBefore))
+}
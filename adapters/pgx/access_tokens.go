@@ -0,0 +1,92 @@
+package pgx
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/lborres/kuta"
+)
+
+var _ kuta.AccessTokenStorage = (*Adapter)(nil)
+
+const accessTokenColumns = `id, user_id, name, token_hash, scopes, expires_at, last_used_at, created_at`
+
+// queryAccessTokenByHash runs on every request made with a personal access
+// token; see hotStatements
+const queryAccessTokenByHash = `SELECT ` + accessTokenColumns + ` FROM public.access_tokens WHERE token_hash = $1`
+
+func scanAccessToken(row pgx.Row) (*kuta.AccessToken, error) {
+	token := &kuta.AccessToken{}
+	err := row.Scan(&token.ID, &token.UserID, &token.Name, &token.TokenHash, &token.Scopes,
+		&token.ExpiresAt, &token.LastUsedAt, &token.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return token, nil
+}
+
+func (a *Adapter) CreateAccessToken(ctx context.Context, token *kuta.AccessToken) error {
+	query := `INSERT INTO public.access_tokens (` + accessTokenColumns + `)
+	          VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`
+
+	scopes := token.Scopes
+	if scopes == nil {
+		scopes = []string{}
+	}
+	_, err := a.pool.Exec(ctx, query, token.ID, token.UserID, token.Name, token.TokenHash, scopes,
+		token.ExpiresAt, token.LastUsedAt, token.CreatedAt)
+	return err
+}
+
+func (a *Adapter) GetAccessTokenByHash(ctx context.Context, tokenHash string) (*kuta.AccessToken, error) {
+	token, err := scanAccessToken(a.pool.QueryRow(ctx, queryAccessTokenByHash, tokenHash))
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, kuta.ErrAccessTokenNotFound
+		}
+		return nil, err
+	}
+	return token, nil
+}
+
+func (a *Adapter) ListUserAccessTokens(ctx context.Context, userID string) ([]*kuta.AccessToken, error) {
+	query := `SELECT ` + accessTokenColumns + ` FROM public.access_tokens WHERE user_id = $1
+	          ORDER BY created_at DESC, id`
+
+	rows, err := a.pool.Query(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tokens := []*kuta.AccessToken{}
+	for rows.Next() {
+		token, err := scanAccessToken(rows)
+		if err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, token)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return tokens, nil
+}
+
+func (a *Adapter) DeleteAccessToken(ctx context.Context, userID, id string) error {
+	tag, err := a.pool.Exec(ctx, `DELETE FROM public.access_tokens WHERE id = $1 AND user_id = $2`, id, userID)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return kuta.ErrAccessTokenNotFound
+	}
+	return nil
+}
+
+func (a *Adapter) TouchAccessToken(ctx context.Context, id string, usedAt time.Time) error {
+	_, err := a.pool.Exec(ctx, `UPDATE public.access_tokens SET last_used_at = $2 WHERE id = $1`, id, usedAt)
+	return err
+}
@@ -8,20 +8,18 @@ import (
 	"github.com/lborres/kuta"
 )
 
-func (a *Adapter) CreateAccount(acc *kuta.Account) error {
-	ctx := context.Background()
-
-	query := `INSERT INTO public.accounts (id, user_id, provider_id, account_id, password, access_token, refresh_token, expires_at)
-	          VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+func (a *Adapter) CreateAccount(ctx context.Context, acc *kuta.Account) error {
+	query := `INSERT INTO public.accounts (id, user_id, provider_id, account_id, password, access_token, refresh_token, expires_at, profile_data)
+	          VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
 	          RETURNING created_at, updated_at`
 
 	var createdAt, updatedAt time.Time
 	err := a.pool.QueryRow(ctx, query,
-		acc.ID, acc.UserID, acc.ProviderID, acc.AccountID, acc.Password, acc.AccessToken, acc.RefreshToken, acc.ExpiresAt,
+		acc.ID, acc.UserID, acc.ProviderID, acc.AccountID, acc.Password, acc.AccessToken, acc.RefreshToken, acc.ExpiresAt, acc.ProfileData,
 	).Scan(&createdAt, &updatedAt)
 
 	if err != nil {
-		return err
+		return createAccountError(err)
 	}
 
 	acc.CreatedAt = createdAt
@@ -29,15 +27,35 @@ func (a *Adapter) CreateAccount(acc *kuta.Account) error {
 	return nil
 }
 
-func (a *Adapter) GetAccountByID(id string) (*kuta.Account, error) {
-	ctx := context.Background()
-	query := `SELECT id, user_id, provider_id, account_id, password, access_token, refresh_token, expires_at, created_at, updated_at
-	          FROM public.accounts WHERE id = $1`
+// createAccountError maps the errors of CreateAccount: a unique violation
+// means the provider account belongs to a user already
+func createAccountError(err error) error {
+	if isUniqueViolation(err) {
+		return kuta.ErrAccountLinked
+	}
+	return err
+}
+
+// accountColumns is the column list account queries select, in the order
+// scanAccount reads them
+const accountColumns = `id, user_id, provider_id, account_id, password, access_token, refresh_token, expires_at, profile_data, created_at, updated_at`
 
+func scanAccount(row pgx.Row) (*kuta.Account, error) {
 	acc := &kuta.Account{}
-	err := a.pool.QueryRow(ctx, query, id).Scan(
-		&acc.ID, &acc.UserID, &acc.ProviderID, &acc.AccountID, &acc.Password, &acc.AccessToken, &acc.RefreshToken, &acc.ExpiresAt, &acc.CreatedAt, &acc.UpdatedAt,
+	err := row.Scan(
+		&acc.ID, &acc.UserID, &acc.ProviderID, &acc.AccountID, &acc.Password, &acc.AccessToken, &acc.RefreshToken, &acc.ExpiresAt, &acc.ProfileData, &acc.CreatedAt, &acc.UpdatedAt,
 	)
+	if err != nil {
+		return nil, err
+	}
+	return acc, nil
+}
+
+func (a *Adapter) GetAccountByID(ctx context.Context, id string) (*kuta.Account, error) {
+	query := `SELECT ` + accountColumns + `
+	          FROM public.accounts WHERE id = $1`
+
+	acc, err := scanAccount(a.pool.QueryRow(ctx, query, id))
 
 	if err != nil {
 		if err == pgx.ErrNoRows {
@@ -49,9 +67,24 @@ func (a *Adapter) GetAccountByID(id string) (*kuta.Account, error) {
 	return acc, nil
 }
 
-func (a *Adapter) GetAccountByUserAndProvider(userID, providerID string) ([]*kuta.Account, error) {
-	ctx := context.Background()
-	query := `SELECT id, user_id, provider_id, account_id, password, access_token, refresh_token, expires_at, created_at, updated_at
+func (a *Adapter) GetAccountByProvider(ctx context.Context, providerID, accountID string) (*kuta.Account, error) {
+	query := `SELECT ` + accountColumns + `
+	          FROM public.accounts WHERE provider_id = $1 AND account_id = $2 AND deleted_at IS NULL`
+
+	acc, err := scanAccount(a.pool.QueryRow(ctx, query, providerID, accountID))
+
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, kuta.ErrUserNotFound
+		}
+		return nil, err
+	}
+
+	return acc, nil
+}
+
+func (a *Adapter) GetAccountByUserAndProvider(ctx context.Context, userID, providerID string) ([]*kuta.Account, error) {
+	query := `SELECT ` + accountColumns + `
 	          FROM public.accounts WHERE user_id = $1 AND provider_id = $2`
 
 	rows, err := a.pool.Query(ctx, query, userID, providerID)
@@ -62,31 +95,22 @@ func (a *Adapter) GetAccountByUserAndProvider(userID, providerID string) ([]*kut
 
 	var accounts []*kuta.Account
 	for rows.Next() {
-		acc := &kuta.Account{}
-		err := rows.Scan(
-			&acc.ID, &acc.UserID, &acc.ProviderID, &acc.AccountID, &acc.Password, &acc.AccessToken, &acc.RefreshToken, &acc.ExpiresAt, &acc.CreatedAt, &acc.UpdatedAt,
-		)
+		acc, err := scanAccount(rows)
 		if err != nil {
 			return nil, err
 		}
 		accounts = append(accounts, acc)
 	}
-
-	if err = rows.Err(); err != nil {
-		return nil, err
-	}
-
-	return accounts, nil
+	return accounts, rows.Err()
 }
 
-func (a *Adapter) UpdateAccount(acc *kuta.Account) error {
-	ctx := context.Background()
-	query := `UPDATE public.accounts SET account_id = $1, password = $2, access_token = $3, refresh_token = $4, expires_at = $5, updated_at = now()
-	          WHERE id = $6 RETURNING updated_at`
+func (a *Adapter) UpdateAccount(ctx context.Context, acc *kuta.Account) error {
+	query := `UPDATE public.accounts SET account_id = $1, password = $2, access_token = $3, refresh_token = $4, expires_at = $5, profile_data = $6, updated_at = now()
+	          WHERE id = $7 RETURNING updated_at`
 
 	var updatedAt time.Time
 	err := a.pool.QueryRow(ctx, query,
-		acc.AccountID, acc.Password, acc.AccessToken, acc.RefreshToken, acc.ExpiresAt, acc.ID,
+		acc.AccountID, acc.Password, acc.AccessToken, acc.RefreshToken, acc.ExpiresAt, acc.ProfileData, acc.ID,
 	).Scan(&updatedAt)
 
 	if err != nil {
@@ -100,8 +124,31 @@ func (a *Adapter) UpdateAccount(acc *kuta.Account) error {
 	return nil
 }
 
-func (a *Adapter) DeleteAccount(id string) error {
-	ctx := context.Background()
+func (a *Adapter) GetExpiringAccounts(ctx context.Context, before time.Time, limit int) ([]*kuta.Account, error) {
+	query := `SELECT ` + accountColumns + `
+	          FROM public.accounts
+	          WHERE refresh_token IS NOT NULL AND expires_at < $1 AND deleted_at IS NULL
+	          ORDER BY expires_at
+	          LIMIT $2`
+
+	rows, err := a.pool.Query(ctx, query, before, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var accounts []*kuta.Account
+	for rows.Next() {
+		acc, err := scanAccount(rows)
+		if err != nil {
+			return nil, err
+		}
+		accounts = append(accounts, acc)
+	}
+	return accounts, rows.Err()
+}
+
+func (a *Adapter) DeleteAccount(ctx context.Context, id string) error {
 	_, err := a.pool.Exec(ctx, `DELETE FROM public.accounts WHERE id = $1`, id)
 	if err != nil {
 		return err
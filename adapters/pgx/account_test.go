@@ -0,0 +1,38 @@
+package pgx
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/lborres/kuta"
+)
+
+// Requirement: CreateAccount reports a provider account that is already
+// stored as ErrAccountLinked, passing other errors on.
+func TestCreateAccountError(t *testing.T) {
+	other := errors.New("connection reset")
+
+	tests := []struct {
+		name string
+		err  error
+		want error
+	}{
+		{name: "unique violation", err: &pgconn.PgError{Code: pgUniqueViolation}, want: kuta.ErrAccountLinked},
+		{name: "wrapped unique violation", err: fmt.Errorf("insert: %w", &pgconn.PgError{Code: pgUniqueViolation}), want: kuta.ErrAccountLinked},
+		{name: "other error", err: other, want: other},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// Act
+			got := createAccountError(test.err)
+
+			// Assert
+			if !errors.Is(got, test.want) {
+				t.Errorf("createAccountError() = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
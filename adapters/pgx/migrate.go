@@ -0,0 +1,87 @@
+package pgx
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/lborres/kuta"
+	"github.com/lborres/kuta/migrations"
+)
+
+// migrationLockKey is the session advisory lock Migrate holds, so instances
+// starting together apply the migrations one at a time ("kuta" in ASCII)
+const migrationLockKey = 0x6b757461
+
+var _ kuta.Migrator = (*Adapter)(nil)
+
+// Migrate applies the bundled postgres migrations the database hasn't
+// applied yet, recording them in public.kuta_schema_migrations. The
+// optional session layouts aren't applied; see Options.
+func (a *Adapter) Migrate(ctx context.Context) error {
+	list, err := migrations.Load(migrations.Postgres)
+	if err != nil {
+		return err
+	}
+
+	conn, err := a.pool.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Release()
+
+	if _, err := conn.Exec(ctx, `SELECT pg_advisory_lock($1)`, migrationLockKey); err != nil {
+		return err
+	}
+	defer conn.Exec(context.WithoutCancel(ctx), `SELECT pg_advisory_unlock($1)`, migrationLockKey)
+
+	_, err = migrations.Up(ctx, migrationExecutor{conn.Conn()}, list)
+	return err
+}
+
+// migrationExecutor applies migrations on one connection
+type migrationExecutor struct {
+	conn *pgx.Conn
+}
+
+func (e migrationExecutor) Applied(ctx context.Context) (map[int64]bool, error) {
+	_, err := e.conn.Exec(ctx, `CREATE TABLE IF NOT EXISTS public.kuta_schema_migrations (
+	          version bigint PRIMARY KEY,
+	          name text NOT NULL,
+	          applied_at timestamptz NOT NULL DEFAULT now())`)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := e.conn.Query(ctx, `SELECT version FROM public.kuta_schema_migrations`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := make(map[int64]bool)
+	for rows.Next() {
+		var version int64
+		if err := rows.Scan(&version); err != nil {
+			return nil, err
+		}
+		applied[version] = true
+	}
+	return applied, rows.Err()
+}
+
+// Apply runs the migration file, which manages its own transaction, and
+// then records it. Recording is idempotent, so a crash in between only
+// reruns a migration that creates nothing twice.
+func (e migrationExecutor) Apply(ctx context.Context, migration migrations.Migration) error {
+	// Without arguments the file runs over the simple protocol, which
+	// allows several statements
+	if _, err := e.conn.Exec(ctx, migration.SQL); err != nil {
+		// A failing statement leaves the file's transaction open
+		_, _ = e.conn.Exec(context.WithoutCancel(ctx), `ROLLBACK`)
+		return err
+	}
+	_, err := e.conn.Exec(ctx,
+		`INSERT INTO public.kuta_schema_migrations (version, name) VALUES ($1, $2) ON CONFLICT (version) DO NOTHING`,
+		migration.Version, migration.Name)
+	return err
+}
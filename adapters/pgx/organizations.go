@@ -0,0 +1,137 @@
+package pgx
+
+import (
+	"context"
+	"github.com/jackc/pgx/v5"
+	"github.com/lborres/kuta"
+)
+
+var _ kuta.OrganizationStorage = (*Adapter)(nil)
+
+// SaveOrganizationMember keeps the creation time of an existing membership
+func (a *Adapter) SaveOrganizationMember(ctx context.Context, member *kuta.OrganizationMember) error {
+	query := `INSERT INTO public.organization_members (organization_id, user_id, roles, created_at)
+	          VALUES ($1, $2, $3, $4)
+	          ON CONFLICT (organization_id, user_id) DO UPDATE SET roles = EXCLUDED.roles
+	          RETURNING created_at`
+
+	roles := member.Roles
+	if roles == nil {
+		roles = []string{}
+	}
+	return a.pool.QueryRow(ctx, query, member.OrganizationID, member.UserID, roles, member.CreatedAt).Scan(&member.CreatedAt)
+}
+
+func (a *Adapter) RemoveOrganizationMember(ctx context.Context, organizationID, userID string) error {
+	_, err := a.pool.Exec(ctx, `DELETE FROM public.organization_members WHERE organization_id = $1 AND user_id = $2`, organizationID, userID)
+	return err
+}
+
+func (a *Adapter) GetOrganizationMember(ctx context.Context, organizationID, userID string) (*kuta.OrganizationMember, error) {
+	query := `SELECT organization_id, user_id, roles, created_at
+	          FROM public.organization_members WHERE organization_id = $1 AND user_id = $2`
+
+	member := &kuta.OrganizationMember{}
+	err := a.pool.QueryRow(ctx, query, organizationID, userID).Scan(&member.OrganizationID, &member.UserID, &member.Roles, &member.CreatedAt)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, kuta.ErrNotOrganizationMember
+		}
+		return nil, err
+	}
+	return member, nil
+}
+
+func (a *Adapter) ListUserOrganizations(ctx context.Context, userID string) ([]*kuta.OrganizationMember, error) {
+	query := `SELECT organization_id, user_id, roles, created_at
+	          FROM public.organization_members WHERE user_id = $1
+	          ORDER BY created_at, organization_id`
+
+	rows, err := a.pool.Query(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	members := []*kuta.OrganizationMember{}
+	for rows.Next() {
+		member := &kuta.OrganizationMember{}
+		if err := rows.Scan(&member.OrganizationID, &member.UserID, &member.Roles, &member.CreatedAt); err != nil {
+			return nil, err
+		}
+		members = append(members, member)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return members, nil
+}
+
+func (a *Adapter) SavePendingOrganizationMember(ctx context.Context, pending *kuta.PendingOrganizationMember) error {
+	query := `INSERT INTO public.organization_invites (id, organization_id, email, roles, invited_by, expires_at, created_at)
+	          VALUES ($1, $2, $3, $4, $5, $6, $7)`
+
+	roles := pending.Roles
+	if roles == nil {
+		roles = []string{}
+	}
+	_, err := a.pool.Exec(ctx, query, pending.InviteID, pending.OrganizationID, pending.Email, roles,
+		pending.InvitedBy, pending.ExpiresAt, pending.CreatedAt)
+	return err
+}
+
+// ActivatePendingOrganizationMember deletes the invite and upserts the
+// membership in a single statement, so an invite activates at most once
+func (a *Adapter) ActivatePendingOrganizationMember(ctx context.Context, inviteID, userID string) (*kuta.OrganizationMember, error) {
+	query := `WITH pending AS (
+	            DELETE FROM public.organization_invites WHERE id = $1
+	            RETURNING organization_id, roles
+	          )
+	          INSERT INTO public.organization_members (organization_id, user_id, roles, created_at)
+	          SELECT organization_id, $2, roles, now() FROM pending
+	          ON CONFLICT (organization_id, user_id) DO UPDATE SET roles = EXCLUDED.roles
+	          RETURNING organization_id, user_id, roles, created_at`
+
+	member := &kuta.OrganizationMember{}
+	err := a.pool.QueryRow(ctx, query, inviteID, userID).Scan(&member.OrganizationID, &member.UserID, &member.Roles, &member.CreatedAt)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, kuta.ErrVerificationTokenNotFound
+		}
+		return nil, err
+	}
+	return member, nil
+}
+
+func (a *Adapter) ListPendingOrganizationMembers(ctx context.Context, organizationID string) ([]*kuta.PendingOrganizationMember, error) {
+	query := `SELECT id, organization_id, email, roles, invited_by, expires_at, created_at
+	          FROM public.organization_invites WHERE organization_id = $1 AND expires_at > now()
+	          ORDER BY created_at, id`
+
+	rows, err := a.pool.Query(ctx, query, organizationID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	pending := []*kuta.PendingOrganizationMember{}
+	for rows.Next() {
+		invite := &kuta.PendingOrganizationMember{}
+		if err := rows.Scan(&invite.InviteID, &invite.OrganizationID, &invite.Email, &invite.Roles,
+			&invite.InvitedBy, &invite.ExpiresAt, &invite.CreatedAt); err != nil {
+			return nil, err
+		}
+		pending = append(pending, invite)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return pending, nil
+}
+
+func (a *Adapter) DeletePendingOrganizationMember(ctx context.Context, inviteID string) error {
+	_, err := a.pool.Exec(ctx, `DELETE FROM public.organization_invites WHERE id = $1`, inviteID)
+	return err
+}
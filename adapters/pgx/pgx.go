@@ -1,18 +1,91 @@
 package pgx
 
 import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/lborres/kuta"
 )
 
+const (
+	// pgUniqueViolation is the SQLSTATE for unique_violation
+	pgUniqueViolation = "23505"
+	// pgUndefinedTable is the SQLSTATE for undefined_table
+	pgUndefinedTable = "42P01"
+
+	// defaultPartitionDaysAhead is how many daily session partitions are kept
+	// ready ahead of today when PartitionedSessions is enabled
+	defaultPartitionDaysAhead = 7
+
+	// pingTimeout bounds Ping so config checks fail fast on an unreachable
+	// database
+	pingTimeout = 5 * time.Second
+)
+
+// Options configures the adapter
+type Options struct {
+	// PartitionedSessions enables partition-aware cleanup. Only set it after
+	// applying migrations/postgres/optional/26101605_partition_sessions.
+	PartitionedSessions bool
+	// PartitionDaysAhead is how many future daily partitions
+	// DeleteExpiredSessions pre-creates. Defaults to 7.
+	PartitionDaysAhead int
+	// PrefixShardedSessions routes session token lookups straight to the
+	// shard holding the token hash's first character. Only set it after
+	// applying migrations/postgres/optional/26101622_shard_sessions_by_token_prefix,
+	// which can't be combined with PartitionedSessions.
+	PrefixShardedSessions bool
+}
+
 type Adapter struct {
 	pool *pgxpool.Pool
+	opts Options
 }
 
-var _ kuta.StorageProvider = (*Adapter)(nil)
+var (
+	_ kuta.StorageProvider    = (*Adapter)(nil)
+	_ kuta.Pinger             = (*Adapter)(nil)
+	_ kuta.CapabilityReporter = (*Adapter)(nil)
+)
+
+func New(pool *pgxpool.Pool, opts ...Options) *Adapter {
+	var o Options
+	if len(opts) > 0 {
+		o = opts[0]
+	}
+	if o.PartitionDaysAhead <= 0 {
+		o.PartitionDaysAhead = defaultPartitionDaysAhead
+	}
 
-func New(pool *pgxpool.Pool) *Adapter {
 	return &Adapter{
 		pool: pool,
+		opts: o,
 	}
 }
+
+// Ping checks the database is reachable, giving up after pingTimeout
+func (a *Adapter) Ping() error {
+	ctx, cancel := context.WithTimeout(context.Background(), pingTimeout)
+	defer cancel()
+	return a.pool.Ping(ctx)
+}
+
+// Capabilities reports that Postgres supports every optional feature
+func (a *Adapter) Capabilities() kuta.StorageCapabilities {
+	return kuta.StorageCapabilities{Transactions: true, SoftDelete: true, Search: true}
+}
+
+// isUniqueViolation reports whether err is a Postgres unique constraint violation
+func isUniqueViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == pgUniqueViolation
+}
+
+// isUndefinedTable reports whether err is a Postgres missing table error
+func isUndefinedTable(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == pgUndefinedTable
+}
@@ -0,0 +1,71 @@
+package pgx
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const (
+	defaultStatementCacheCapacity = 512
+)
+
+// hotStatements are prepared on every new connection. They are prepared
+// under their own SQL text as name, which pgx matches on Query, so the
+// adapter methods use them without any change when the pool is configured
+// with ConfigurePool and fall back to the statement cache otherwise.
+var hotStatements = []string{
+	querySessionByHash,
+	querySessionWithUserByHash,
+	queryUserByID,
+	queryAccessTokenByHash,
+}
+
+// NewPool creates a pool configured with ConfigurePool
+func NewPool(ctx context.Context, connString string) (*pgxpool.Pool, error) {
+	cfg, err := pgxpool.ParseConfig(connString)
+	if err != nil {
+		return nil, err
+	}
+	ConfigurePool(cfg)
+	return pgxpool.NewWithConfig(ctx, cfg)
+}
+
+// ConfigurePool tunes cfg for kuta's queries: statements are cached per
+// connection and the per-request lookups are prepared up front.
+//
+// Behind a transaction-pooling proxy such as PgBouncer, prepared statements
+// don't survive across transactions; set cfg.ConnConfig.DefaultQueryExecMode
+// to pgx.QueryExecModeExec (or SimpleProtocol) before calling ConfigurePool
+// and it will leave statement preparation off.
+func ConfigurePool(cfg *pgxpool.Config) {
+	conn := cfg.ConnConfig
+	if conn.DefaultQueryExecMode != pgx.QueryExecModeCacheStatement {
+		return
+	}
+
+	if conn.StatementCacheCapacity == 0 {
+		conn.StatementCacheCapacity = defaultStatementCacheCapacity
+	}
+
+	afterConnect := cfg.AfterConnect
+	cfg.AfterConnect = func(ctx context.Context, c *pgx.Conn) error {
+		if afterConnect != nil {
+			if err := afterConnect(ctx, c); err != nil {
+				return err
+			}
+		}
+		for _, sql := range hotStatements {
+			if _, err := c.Prepare(ctx, sql, sql); err != nil {
+				// Tables may not exist yet if this pool also runs the migrations
+				if isUndefinedTable(err) {
+					continue
+				}
+				return fmt.Errorf("prepare kuta statement: %w", err)
+			}
+		}
+		return nil
+	}
+}
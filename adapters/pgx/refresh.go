@@ -0,0 +1,121 @@
+package pgx
+
+import (
+	"context"
+	"github.com/jackc/pgx/v5"
+	"github.com/lborres/kuta"
+)
+
+func (a *Adapter) CreateRefreshToken(ctx context.Context, token *kuta.RefreshToken) error {
+	query := `INSERT INTO public.refresh_tokens (id, user_id, session_id, parent_id, token_hash, ip_address, user_agent, public_key, expires_at, authenticated_at)
+	          VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+	          RETURNING created_at`
+
+	return a.pool.QueryRow(ctx, query,
+		token.ID, token.UserID, token.SessionID, token.ParentID, token.TokenHash, token.IPAddress, token.UserAgent, token.PublicKey, token.ExpiresAt, token.AuthenticatedAt,
+	).Scan(&token.CreatedAt)
+}
+
+// refreshTokenColumns is the column list refresh token queries select, in
+// the order scanRefreshToken reads them
+const refreshTokenColumns = `id, user_id, session_id, parent_id, token_hash, ip_address, user_agent, public_key, expires_at, authenticated_at, revoked_at, rotated_at, created_at`
+
+func scanRefreshToken(row pgx.Row) (*kuta.RefreshToken, error) {
+	token := &kuta.RefreshToken{}
+	err := row.Scan(
+		&token.ID, &token.UserID, &token.SessionID, &token.ParentID, &token.TokenHash, &token.IPAddress, &token.UserAgent, &token.PublicKey, &token.ExpiresAt, &token.AuthenticatedAt, &token.RevokedAt, &token.RotatedAt, &token.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return token, nil
+}
+
+func (a *Adapter) GetRefreshTokenByHash(ctx context.Context, tokenHash string) (*kuta.RefreshToken, error) {
+	query := `SELECT ` + refreshTokenColumns + `
+	          FROM public.refresh_tokens WHERE token_hash = $1`
+
+	token, err := scanRefreshToken(a.pool.QueryRow(ctx, query, tokenHash))
+
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, kuta.ErrRefreshTokenNotFound
+		}
+		return nil, err
+	}
+
+	return token, nil
+}
+
+func (a *Adapter) RevokeRefreshToken(ctx context.Context, id string) error {
+	tag, err := a.pool.Exec(ctx,
+		`UPDATE public.refresh_tokens SET revoked_at = now() WHERE id = $1 AND revoked_at IS NULL`, id)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return kuta.ErrRefreshTokenNotFound
+	}
+	return nil
+}
+
+func (a *Adapter) RotateRefreshToken(ctx context.Context, id string) error {
+	tag, err := a.pool.Exec(ctx,
+		`UPDATE public.refresh_tokens SET revoked_at = now(), rotated_at = now() WHERE id = $1 AND revoked_at IS NULL`, id)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return kuta.ErrRefreshTokenNotFound
+	}
+	return nil
+}
+
+func (a *Adapter) RevokeSessionRefreshTokens(ctx context.Context, sessionID string) (int, error) {
+	tag, err := a.pool.Exec(ctx,
+		`UPDATE public.refresh_tokens SET revoked_at = now() WHERE session_id = $1 AND revoked_at IS NULL`, sessionID)
+	if err != nil {
+		return 0, err
+	}
+	return int(tag.RowsAffected()), nil
+}
+
+func (a *Adapter) RevokeUserRefreshTokens(ctx context.Context, userID string) (int, error) {
+	tag, err := a.pool.Exec(ctx,
+		`UPDATE public.refresh_tokens SET revoked_at = now() WHERE user_id = $1 AND revoked_at IS NULL`, userID)
+	if err != nil {
+		return 0, err
+	}
+	return int(tag.RowsAffected()), nil
+}
+
+func (a *Adapter) GetActiveUserRefreshTokens(ctx context.Context, userID string) ([]*kuta.RefreshToken, error) {
+	query := `SELECT ` + refreshTokenColumns + `
+	          FROM public.refresh_tokens
+	          WHERE user_id = $1 AND revoked_at IS NULL AND expires_at > now()
+	          ORDER BY created_at`
+
+	rows, err := a.pool.Query(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tokens []*kuta.RefreshToken
+	for rows.Next() {
+		token, err := scanRefreshToken(rows)
+		if err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, token)
+	}
+	return tokens, rows.Err()
+}
+
+func (a *Adapter) DeleteExpiredRefreshTokens(ctx context.Context) (int, error) {
+	tag, err := a.pool.Exec(ctx, `DELETE FROM public.refresh_tokens WHERE expires_at < now()`)
+	if err != nil {
+		return 0, err
+	}
+	return int(tag.RowsAffected()), nil
+}
@@ -0,0 +1,70 @@
+package pgx
+
+import (
+	"context"
+	"github.com/lborres/kuta"
+)
+
+var _ kuta.RoleStorage = (*Adapter)(nil)
+
+func (a *Adapter) AssignRole(ctx context.Context, userID, role string) error {
+	query := `INSERT INTO public.user_roles (user_id, role) VALUES ($1, $2)
+	          ON CONFLICT (user_id, role) DO NOTHING`
+
+	_, err := a.pool.Exec(ctx, query, userID, role)
+	return err
+}
+
+func (a *Adapter) RevokeRole(ctx context.Context, userID, role string) error {
+	_, err := a.pool.Exec(ctx, `DELETE FROM public.user_roles WHERE user_id = $1 AND role = $2`, userID, role)
+	return err
+}
+
+func (a *Adapter) GetUserRoles(ctx context.Context, userID string) ([]string, error) {
+	return a.queryNames(ctx, `SELECT role FROM public.user_roles WHERE user_id = $1 ORDER BY role`, userID)
+}
+
+// SetRolePermissions replaces the permissions of role in one statement, so
+// concurrent readers see either the old or the new set
+func (a *Adapter) SetRolePermissions(ctx context.Context, role string, permissions []string) error {
+	query := `WITH removed AS (
+	            DELETE FROM public.role_permissions WHERE role = $1 AND NOT (permission = ANY($2))
+	          )
+	          INSERT INTO public.role_permissions (role, permission)
+	          SELECT $1, unnest($2::text[])
+	          ON CONFLICT (role, permission) DO NOTHING`
+
+	if permissions == nil {
+		permissions = []string{}
+	}
+	_, err := a.pool.Exec(ctx, query, role, permissions)
+	return err
+}
+
+func (a *Adapter) GetRolePermissions(ctx context.Context, role string) ([]string, error) {
+	return a.queryNames(ctx, `SELECT permission FROM public.role_permissions WHERE role = $1 ORDER BY permission`, role)
+}
+
+// queryNames runs query, which selects a single text column, and returns the
+// values, or an empty list when there are none
+func (a *Adapter) queryNames(ctx context.Context, query string, args ...any) ([]string, error) {
+	rows, err := a.pool.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	names := []string{}
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		names = append(names, name)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return names, nil
+}
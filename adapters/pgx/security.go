@@ -0,0 +1,53 @@
+package pgx
+
+import (
+	"context"
+	"github.com/jackc/pgx/v5"
+	"github.com/lborres/kuta"
+)
+
+func (a *Adapter) GetUserSecurity(ctx context.Context, userID string) (*kuta.UserSecurity, error) {
+	query := `SELECT user_id, two_factor_enabled, allowed_providers, max_sessions,
+	                 notify_new_sign_in, notify_password_changed, notify_anomalies, updated_at
+	          FROM public.user_security WHERE user_id = $1`
+
+	s := &kuta.UserSecurity{}
+	err := a.pool.QueryRow(ctx, query, userID).Scan(
+		&s.UserID, &s.TwoFactorEnabled, &s.AllowedProviders, &s.MaxSessions,
+		&s.Notifications.NewSignIn, &s.Notifications.PasswordChanged, &s.Notifications.Anomalies, &s.UpdatedAt,
+	)
+
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, kuta.ErrUserSecurityNotFound
+		}
+		return nil, err
+	}
+
+	return s, nil
+}
+
+func (a *Adapter) UpsertUserSecurity(ctx context.Context, s *kuta.UserSecurity) error {
+	query := `INSERT INTO public.user_security (user_id, two_factor_enabled, allowed_providers, max_sessions,
+	                                            notify_new_sign_in, notify_password_changed, notify_anomalies)
+	          VALUES ($1, $2, $3, $4, $5, $6, $7)
+	          ON CONFLICT (user_id) DO UPDATE SET
+	            two_factor_enabled = EXCLUDED.two_factor_enabled,
+	            allowed_providers = EXCLUDED.allowed_providers,
+	            max_sessions = EXCLUDED.max_sessions,
+	            notify_new_sign_in = EXCLUDED.notify_new_sign_in,
+	            notify_password_changed = EXCLUDED.notify_password_changed,
+	            notify_anomalies = EXCLUDED.notify_anomalies,
+	            updated_at = now()
+	          RETURNING updated_at`
+
+	providers := s.AllowedProviders
+	if providers == nil {
+		providers = []string{}
+	}
+
+	return a.pool.QueryRow(ctx, query,
+		s.UserID, s.TwoFactorEnabled, providers, s.MaxSessions,
+		s.Notifications.NewSignIn, s.Notifications.PasswordChanged, s.Notifications.Anomalies,
+	).Scan(&s.UpdatedAt)
+}
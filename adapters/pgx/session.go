@@ -2,23 +2,85 @@ package pgx
 
 import (
 	"context"
+	"fmt"
+	"strings"
 	"time"
 
 	"github.com/jackc/pgx/v5"
 	"github.com/lborres/kuta"
 )
 
-func (a *Adapter) CreateSession(session *kuta.Session) error {
-	ctx := context.Background()
+// sessionColumns is the column list session queries select, in the order
+// scanSession reads them
+const sessionColumns = `id, user_id, token_hash, ip_address, user_agent, public_key, expires_at, revoked_at, impersonator_id, impersonation_started_at, data, active_organization_id, entitlements, created_at, updated_at`
 
-	query := `INSERT INTO public.sessions (id, user_id, token_hash, ip_address, user_agent, expires_at)
-	          VALUES ($1, $2, $3, $4, $5, $6)
+// scanSession reads a row selected with sessionColumns, followed by extra
+func scanSession(row pgx.Row, extra ...any) (*kuta.Session, error) {
+	session := &kuta.Session{}
+	var impersonatorID *string
+	var impersonationStartedAt *time.Time
+	dest := append([]any{
+		&session.ID, &session.UserID, &session.TokenHash, &session.IPAddress, &session.UserAgent, &session.PublicKey, &session.ExpiresAt, &session.RevokedAt, &impersonatorID, &impersonationStartedAt, &session.Data, &session.ActiveOrganizationID, &session.Entitlements, &session.CreatedAt, &session.UpdatedAt,
+	}, extra...)
+
+	if err := row.Scan(dest...); err != nil {
+		return nil, err
+	}
+
+	if impersonatorID != nil && impersonationStartedAt != nil {
+		session.Impersonation = &kuta.Impersonation{ActorUserID: *impersonatorID, StartedAt: *impersonationStartedAt}
+	}
+	return session, nil
+}
+
+// tokenPrefix is the shard key of tokenHash under PrefixShardedSessions:
+// its first character when that is lowercase hex, which it is for every
+// hash kuta issues, and "" (the catch-all shard) otherwise
+func tokenPrefix(tokenHash string) string {
+	if tokenHash == "" {
+		return ""
+	}
+	if c := tokenHash[0]; ('0' <= c && c <= '9') || ('a' <= c && c <= 'f') {
+		return tokenHash[:1]
+	}
+	return ""
+}
+
+// sessionsTable is the table holding tokenHash. Under PrefixShardedSessions
+// that is its shard, so the lookup skips partition routing entirely.
+func (a *Adapter) sessionsTable(tokenHash string) string {
+	if a.opts.PrefixShardedSessions {
+		if prefix := tokenPrefix(tokenHash); prefix != "" {
+			return "public.sessions_s" + prefix
+		}
+	}
+	return "public.sessions"
+}
+
+func (a *Adapter) CreateSession(ctx context.Context, session *kuta.Session) error {
+	query := `INSERT INTO public.sessions (id, user_id, token_hash, ip_address, user_agent, public_key, expires_at, impersonator_id, impersonation_started_at, data, active_organization_id, entitlements%s)
+	          VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, COALESCE($10, '{}'::jsonb), $11, $12%s)
 	          RETURNING created_at, updated_at`
 
+	var impersonatorID *string
+	var impersonationStartedAt *time.Time
+	if session.Impersonation != nil {
+		impersonatorID = &session.Impersonation.ActorUserID
+		impersonationStartedAt = &session.Impersonation.StartedAt
+	}
+
+	args := []any{
+		session.ID, session.UserID, session.TokenHash, session.IPAddress, session.UserAgent, session.PublicKey, session.ExpiresAt, impersonatorID, impersonationStartedAt, session.Data, session.ActiveOrganizationID, session.Entitlements,
+	}
+	if a.opts.PrefixShardedSessions {
+		query = fmt.Sprintf(query, ", token_prefix", ", $13")
+		args = append(args, tokenPrefix(session.TokenHash))
+	} else {
+		query = fmt.Sprintf(query, "", "")
+	}
+
 	var createdAt, updatedAt time.Time
-	err := a.pool.QueryRow(ctx, query,
-		session.ID, session.UserID, session.TokenHash, session.IPAddress, session.UserAgent, session.ExpiresAt,
-	).Scan(&createdAt, &updatedAt)
+	err := a.pool.QueryRow(ctx, query, args...).Scan(&createdAt, &updatedAt)
 
 	if err != nil {
 		return err
@@ -29,15 +91,16 @@ func (a *Adapter) CreateSession(session *kuta.Session) error {
 	return nil
 }
 
-func (a *Adapter) GetSessionByHash(tokenHash string) (*kuta.Session, error) {
-	ctx := context.Background()
-	query := `SELECT id, user_id, token_hash, ip_address, user_agent, expires_at, created_at, updated_at
+// querySessionByHash runs on every authenticated request; see hotStatements
+const querySessionByHash = `SELECT ` + sessionColumns + `
 	          FROM public.sessions WHERE token_hash = $1`
 
-	session := &kuta.Session{}
-	err := a.pool.QueryRow(ctx, query, tokenHash).Scan(
-		&session.ID, &session.UserID, &session.TokenHash, &session.IPAddress, &session.UserAgent, &session.ExpiresAt, &session.CreatedAt, &session.UpdatedAt,
-	)
+func (a *Adapter) GetSessionByHash(ctx context.Context, tokenHash string) (*kuta.Session, error) {
+	query := querySessionByHash
+	if a.opts.PrefixShardedSessions {
+		query = `SELECT ` + sessionColumns + ` FROM ` + a.sessionsTable(tokenHash) + ` WHERE token_hash = $1`
+	}
+	session, err := scanSession(a.pool.QueryRow(ctx, query, tokenHash))
 
 	if err != nil {
 		if err == pgx.ErrNoRows {
@@ -49,15 +112,92 @@ func (a *Adapter) GetSessionByHash(tokenHash string) (*kuta.Session, error) {
 	return session, nil
 }
 
-func (a *Adapter) GetSessionByID(id string) (*kuta.Session, error) {
-	ctx := context.Background()
-	query := `SELECT id, user_id, token_hash, ip_address, user_agent, expires_at, created_at, updated_at
+// qualified prefixes every column of a column list with table, for joins
+func qualified(table, columns string) string {
+	return table + "." + strings.ReplaceAll(columns, ", ", ", "+table+".")
+}
+
+// sessionWithUserQuery selects sessionColumns and the user's columns from
+// the sessions table, joining the user unless it is deleted
+func sessionWithUserQuery(sessionsTable string) string {
+	return `SELECT ` + qualified("s", sessionColumns) + `, u.id, u.email, u.email_verified, u.name, u.image, u.status, u.created_at, u.updated_at
+	          FROM ` + sessionsTable + ` s
+	          LEFT JOIN public.users u ON u.id = s.user_id AND u.deleted_at IS NULL
+	          WHERE s.token_hash = $1`
+}
+
+// querySessionWithUserByHash backs GetSession; see hotStatements
+var querySessionWithUserByHash = sessionWithUserQuery("public.sessions")
+
+func (a *Adapter) GetSessionWithUserByHash(ctx context.Context, tokenHash string) (*kuta.Session, *kuta.User, error) {
+	query := querySessionWithUserByHash
+	if a.opts.PrefixShardedSessions {
+		query = sessionWithUserQuery(a.sessionsTable(tokenHash))
+	}
+
+	// The user's columns are all NULL when the join finds no user
+	var userID, email, name, status *string
+	var emailVerified *bool
+	var image *string
+	var createdAt, updatedAt *time.Time
+	session, err := scanSession(a.pool.QueryRow(ctx, query, tokenHash),
+		&userID, &email, &emailVerified, &name, &image, &status, &createdAt, &updatedAt)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, nil, kuta.ErrSessionNotFound
+		}
+		return nil, nil, err
+	}
+	if userID == nil {
+		return session, nil, nil
+	}
+
+	user := &kuta.User{ID: *userID, Email: *email, EmailVerified: *emailVerified, Name: *name, Image: image, Status: *status, CreatedAt: *createdAt, UpdatedAt: *updatedAt}
+	return session, user, nil
+}
+
+func (a *Adapter) GetSessionsByHashes(ctx context.Context, tokenHashes []string) ([]*kuta.Session, error) {
+	query := `SELECT ` + sessionColumns + `
+	          FROM public.sessions WHERE token_hash = ANY($1)`
+	args := []any{tokenHashes}
+
+	// Naming the prefixes lets Postgres skip the shards none of them are on
+	if a.opts.PrefixShardedSessions {
+		prefixes := make([]string, len(tokenHashes))
+		for i, tokenHash := range tokenHashes {
+			prefixes[i] = tokenPrefix(tokenHash)
+		}
+		query += ` AND token_prefix = ANY($2)`
+		args = append(args, prefixes)
+	}
+
+	rows, err := a.pool.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var sessions []*kuta.Session
+	for rows.Next() {
+		session, err := scanSession(rows)
+		if err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, session)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return sessions, nil
+}
+
+func (a *Adapter) GetSessionByID(ctx context.Context, id string) (*kuta.Session, error) {
+	query := `SELECT ` + sessionColumns + `
 	          FROM public.sessions WHERE id = $1`
 
-	session := &kuta.Session{}
-	err := a.pool.QueryRow(ctx, query, id).Scan(
-		&session.ID, &session.UserID, &session.TokenHash, &session.IPAddress, &session.UserAgent, &session.ExpiresAt, &session.CreatedAt, &session.UpdatedAt,
-	)
+	session, err := scanSession(a.pool.QueryRow(ctx, query, id))
 
 	if err != nil {
 		if err == pgx.ErrNoRows {
@@ -69,9 +209,8 @@ func (a *Adapter) GetSessionByID(id string) (*kuta.Session, error) {
 	return session, nil
 }
 
-func (a *Adapter) GetUserSessions(userID string) ([]*kuta.Session, error) {
-	ctx := context.Background()
-	query := `SELECT id, user_id, token_hash, ip_address, user_agent, expires_at, created_at, updated_at
+func (a *Adapter) GetUserSessions(ctx context.Context, userID string) ([]*kuta.Session, error) {
+	query := `SELECT ` + sessionColumns + `
 	          FROM public.sessions WHERE user_id = $1 ORDER BY created_at DESC`
 
 	rows, err := a.pool.Query(ctx, query, userID)
@@ -82,10 +221,7 @@ func (a *Adapter) GetUserSessions(userID string) ([]*kuta.Session, error) {
 
 	var sessions []*kuta.Session
 	for rows.Next() {
-		session := &kuta.Session{}
-		err := rows.Scan(
-			&session.ID, &session.UserID, &session.TokenHash, &session.IPAddress, &session.UserAgent, &session.ExpiresAt, &session.CreatedAt, &session.UpdatedAt,
-		)
+		session, err := scanSession(rows)
 		if err != nil {
 			return nil, err
 		}
@@ -99,15 +235,23 @@ func (a *Adapter) GetUserSessions(userID string) ([]*kuta.Session, error) {
 	return sessions, nil
 }
 
-func (a *Adapter) UpdateSession(session *kuta.Session) error {
-	ctx := context.Background()
-	query := `UPDATE public.sessions SET token_hash = $1, ip_address = $2, user_agent = $3, expires_at = $4, updated_at = now()
-	          WHERE id = $5 RETURNING updated_at`
+func (a *Adapter) UpdateSession(ctx context.Context, session *kuta.Session) error {
+	query := `UPDATE public.sessions SET token_hash = $1, ip_address = $2, user_agent = $3, expires_at = $4, revoked_at = $5, active_organization_id = $6, entitlements = $7, updated_at = now()%s
+	          WHERE id = $8 RETURNING updated_at`
+
+	args := []any{
+		session.TokenHash, session.IPAddress, session.UserAgent, session.ExpiresAt, session.RevokedAt, session.ActiveOrganizationID, session.Entitlements, session.ID,
+	}
+	// A rotated token hash may move the row to another shard
+	if a.opts.PrefixShardedSessions {
+		query = fmt.Sprintf(query, ", token_prefix = $9")
+		args = append(args, tokenPrefix(session.TokenHash))
+	} else {
+		query = fmt.Sprintf(query, "")
+	}
 
 	var updatedAt time.Time
-	err := a.pool.QueryRow(ctx, query,
-		session.TokenHash, session.IPAddress, session.UserAgent, session.ExpiresAt, session.ID,
-	).Scan(&updatedAt)
+	err := a.pool.QueryRow(ctx, query, args...).Scan(&updatedAt)
 
 	if err != nil {
 		if err == pgx.ErrNoRows {
@@ -120,8 +264,7 @@ func (a *Adapter) UpdateSession(session *kuta.Session) error {
 	return nil
 }
 
-func (a *Adapter) DeleteSessionByID(id string) error {
-	ctx := context.Background()
+func (a *Adapter) DeleteSessionByID(ctx context.Context, id string) error {
 	_, err := a.pool.Exec(ctx, `DELETE FROM public.sessions WHERE id = $1`, id)
 	if err != nil {
 		return err
@@ -129,17 +272,15 @@ func (a *Adapter) DeleteSessionByID(id string) error {
 	return nil
 }
 
-func (a *Adapter) DeleteSessionByHash(tokenHash string) error {
-	ctx := context.Background()
-	_, err := a.pool.Exec(ctx, `DELETE FROM public.sessions WHERE token_hash = $1`, tokenHash)
+func (a *Adapter) DeleteSessionByHash(ctx context.Context, tokenHash string) error {
+	_, err := a.pool.Exec(ctx, `DELETE FROM `+a.sessionsTable(tokenHash)+` WHERE token_hash = $1`, tokenHash)
 	if err != nil {
 		return err
 	}
 	return nil
 }
 
-func (a *Adapter) DeleteUserSessions(userID string) (int, error) {
-	ctx := context.Background()
+func (a *Adapter) DeleteUserSessions(ctx context.Context, userID string) (int, error) {
 	tag, err := a.pool.Exec(ctx, `DELETE FROM public.sessions WHERE user_id = $1`, userID)
 	if err != nil {
 		return 0, err
@@ -147,11 +288,141 @@ func (a *Adapter) DeleteUserSessions(userID string) (int, error) {
 	return int(tag.RowsAffected()), nil
 }
 
-func (a *Adapter) DeleteExpiredSessions() (int, error) {
-	ctx := context.Background()
+func (a *Adapter) DeleteExpiredSessions(ctx context.Context) (int, error) {
+	if a.opts.PartitionedSessions {
+		return a.deleteExpiredPartitionedSessions(ctx)
+	}
+
 	tag, err := a.pool.Exec(ctx, `DELETE FROM public.sessions WHERE expires_at < now()`)
 	if err != nil {
 		return 0, err
 	}
 	return int(tag.RowsAffected()), nil
 }
+
+// deleteExpiredPartitionedSessions drops whole daily partitions that have
+// expired, deletes the few expired rows left in today's and the default
+// partition, then makes sure upcoming partitions exist.
+func (a *Adapter) deleteExpiredPartitionedSessions(ctx context.Context) (int, error) {
+	var dropped int64
+	err := a.pool.QueryRow(ctx, `SELECT public.kuta_drop_expired_session_partitions()`).Scan(&dropped)
+	if err != nil {
+		return 0, err
+	}
+
+	tag, err := a.pool.Exec(ctx, `DELETE FROM public.sessions WHERE expires_at < now()`)
+	if err != nil {
+		return 0, err
+	}
+
+	_, err = a.pool.Exec(ctx, `SELECT public.kuta_ensure_session_partitions($1)`, a.opts.PartitionDaysAhead)
+	if err != nil {
+		return 0, err
+	}
+
+	return int(dropped) + int(tag.RowsAffected()), nil
+}
+
+func (a *Adapter) SearchSessions(ctx context.Context, filter kuta.SessionFilter) ([]*kuta.Session, int, error) {
+	var conditions []string
+	var args []any
+	addCondition := func(clause string, value any) {
+		args = append(args, value)
+		conditions = append(conditions, fmt.Sprintf(clause, len(args)))
+	}
+
+	if filter.UserID != "" {
+		addCondition("user_id = $%d", filter.UserID)
+	}
+	if filter.IPAddress != "" {
+		addCondition("ip_address = $%d", filter.IPAddress)
+	}
+	if filter.UserAgent != "" {
+		addCondition("user_agent ILIKE '%%' || $%d || '%%'", escapeLike(filter.UserAgent))
+	}
+	if !filter.CreatedAfter.IsZero() {
+		addCondition("created_at >= $%d", filter.CreatedAfter)
+	}
+	if !filter.CreatedBefore.IsZero() {
+		addCondition("created_at < $%d", filter.CreatedBefore)
+	}
+
+	where := ""
+	if len(conditions) > 0 {
+		where = "WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	args = append(args, filter.Limit, filter.Offset)
+	query := fmt.Sprintf(`SELECT `+sessionColumns+`, count(*) OVER()
+	          FROM public.sessions %s ORDER BY created_at DESC LIMIT $%d OFFSET $%d`, where, len(args)-1, len(args))
+
+	rows, err := a.pool.Query(ctx, query, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	var sessions []*kuta.Session
+	total := 0
+	for rows.Next() {
+		session, err := scanSession(rows, &total)
+		if err != nil {
+			return nil, 0, err
+		}
+		sessions = append(sessions, session)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	// count(*) OVER() has no row to ride on when the page is past the end
+	if len(sessions) == 0 && filter.Offset > 0 {
+		countQuery := fmt.Sprintf(`SELECT count(*) FROM public.sessions %s`, where)
+		if err := a.pool.QueryRow(ctx, countQuery, args[:len(args)-2]...).Scan(&total); err != nil {
+			return nil, 0, err
+		}
+	}
+
+	return sessions, total, nil
+}
+
+func (a *Adapter) DeleteSessionsByIDs(ctx context.Context, ids []string) (int, error) {
+	tag, err := a.pool.Exec(ctx, `DELETE FROM public.sessions WHERE id = ANY($1)`, ids)
+	if err != nil {
+		return 0, err
+	}
+	return int(tag.RowsAffected()), nil
+}
+
+// SetSessionValue merges the key into the data column, so concurrent writes
+// to different keys don't overwrite each other
+func (a *Adapter) SetSessionValue(ctx context.Context, sessionID, key, value string) error {
+	tag, err := a.pool.Exec(ctx,
+		`UPDATE public.sessions SET data = data || jsonb_build_object($2::text, $3::text) WHERE id = $1`,
+		sessionID, key, value,
+	)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return kuta.ErrSessionNotFound
+	}
+	return nil
+}
+
+func (a *Adapter) DeleteSessionValue(ctx context.Context, sessionID, key string) error {
+	tag, err := a.pool.Exec(ctx, `UPDATE public.sessions SET data = data - $2::text WHERE id = $1`, sessionID, key)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return kuta.ErrSessionNotFound
+	}
+	return nil
+}
+
+// escapeLike escapes LIKE wildcards so user input is matched literally
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
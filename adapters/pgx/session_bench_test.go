@@ -0,0 +1,95 @@
+package pgx
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/lborres/kuta"
+)
+
+// Benchmarks need a migrated database:
+//
+//	KUTA_TEST_DATABASE_URL=postgres://... go test -run '^$' -bench . ./adapters/pgx
+func benchmarkDatabaseURL(b *testing.B) string {
+	url := os.Getenv("KUTA_TEST_DATABASE_URL")
+	if url == "" {
+		b.Skip("KUTA_TEST_DATABASE_URL not set")
+	}
+	return url
+}
+
+// seedSession inserts a user and session and returns the session's token hash
+func seedSession(b *testing.B, adapter *Adapter) string {
+	b.Helper()
+	suffix := fmt.Sprintf("%016d", time.Now().UnixNano()%1e16)
+	id := "bench_" + suffix // 22 chars, valid nanoid
+
+	user := &kuta.User{ID: id, Email: "bench-" + suffix + "@example.com", Name: "bench"}
+	if err := adapter.CreateUser(b.Context(), user); err != nil {
+		b.Fatalf("CreateUser() error = %v", err)
+	}
+	b.Cleanup(func() { _ = adapter.DeleteUser(b.Context(), id) })
+
+	session := &kuta.Session{ID: id, UserID: id, TokenHash: "bench-" + suffix, ExpiresAt: time.Now().Add(time.Hour)}
+	if err := adapter.CreateSession(b.Context(), session); err != nil {
+		b.Fatalf("CreateSession() error = %v", err)
+	}
+	return session.TokenHash
+}
+
+func benchmarkGetSessionByHash(b *testing.B, pool *pgxpool.Pool) {
+	adapter := New(pool)
+	tokenHash := seedSession(b, adapter)
+
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			if _, err := adapter.GetSessionByHash(b.Context(), tokenHash); err != nil {
+				b.Error(err)
+				return
+			}
+		}
+	})
+}
+
+func BenchmarkGetSessionByHash(b *testing.B) {
+	url := benchmarkDatabaseURL(b)
+	ctx := context.Background()
+
+	b.Run("default pool", func(b *testing.B) {
+		pool, err := pgxpool.New(ctx, url)
+		if err != nil {
+			b.Fatalf("pgxpool.New() error = %v", err)
+		}
+		defer pool.Close()
+		benchmarkGetSessionByHash(b, pool)
+	})
+
+	b.Run("configured pool", func(b *testing.B) {
+		pool, err := NewPool(ctx, url)
+		if err != nil {
+			b.Fatalf("NewPool() error = %v", err)
+		}
+		defer pool.Close()
+		benchmarkGetSessionByHash(b, pool)
+	})
+
+	b.Run("exec mode", func(b *testing.B) {
+		cfg, err := pgxpool.ParseConfig(url)
+		if err != nil {
+			b.Fatalf("ParseConfig() error = %v", err)
+		}
+		cfg.ConnConfig.DefaultQueryExecMode = pgx.QueryExecModeExec
+		pool, err := pgxpool.NewWithConfig(ctx, cfg)
+		if err != nil {
+			b.Fatalf("NewWithConfig() error = %v", err)
+		}
+		defer pool.Close()
+		benchmarkGetSessionByHash(b, pool)
+	})
+}
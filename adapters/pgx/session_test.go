@@ -0,0 +1,68 @@
+package pgx
+
+import (
+	"strings"
+	"testing"
+)
+
+// Requirement: Under PrefixShardedSessions, token lookups go to the shard
+// named after the hash's first hex character; other hashes, and adapters
+// without sharding, use the sessions table.
+func TestAdapter_SessionsTable(t *testing.T) {
+	tests := []struct {
+		name      string
+		sharded   bool
+		tokenHash string
+		want      string
+	}{
+		{name: "digit prefix", sharded: true, tokenHash: "3fa9", want: "public.sessions_s3"},
+		{name: "letter prefix", sharded: true, tokenHash: "e01b", want: "public.sessions_se"},
+		{name: "uppercase prefix", sharded: true, tokenHash: "E01B", want: "public.sessions"},
+		{name: "hex then non-hex", sharded: true, tokenHash: "bench-1", want: "public.sessions_sb"},
+		{name: "quote prefix", sharded: true, tokenHash: "'; DROP", want: "public.sessions"},
+		{name: "empty hash", sharded: true, tokenHash: "", want: "public.sessions"},
+		{name: "not sharded", tokenHash: "3fa9", want: "public.sessions"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// Arrange
+			adapter := New(nil, Options{PrefixShardedSessions: test.sharded})
+
+			// Act
+			got := adapter.sessionsTable(test.tokenHash)
+
+			// Assert
+			if got != test.want {
+				t.Errorf("sessionsTable(%q) = %q, want %q", test.tokenHash, got, test.want)
+			}
+		})
+	}
+}
+
+// Requirement: The joined session lookup qualifies every session column, so
+// none is ambiguous with the user's, and reads the given sessions table.
+func TestSessionWithUserQuery(t *testing.T) {
+	tests := []struct {
+		name  string
+		table string
+		want  []string
+	}{
+		{name: "sessions table", table: "public.sessions", want: []string{"SELECT s.id, s.user_id, s.token_hash,", "s.updated_at, u.id,", "FROM public.sessions s"}},
+		{name: "shard", table: "public.sessions_s3", want: []string{"FROM public.sessions_s3 s"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// Act
+			got := sessionWithUserQuery(test.table)
+
+			// Assert
+			for _, want := range test.want {
+				if !strings.Contains(got, want) {
+					t.Errorf("sessionWithUserQuery(%q) = %q, want it to contain %q", test.table, got, want)
+				}
+			}
+		})
+	}
+}
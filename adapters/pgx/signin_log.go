@@ -0,0 +1,77 @@
+package pgx
+
+import (
+	"context"
+	"time"
+
+	"github.com/lborres/kuta"
+)
+
+var _ kuta.SignInLog = (*Adapter)(nil)
+
+func (a *Adapter) RecordSignIn(ctx context.Context, record *kuta.SignInRecord) error {
+	query := `INSERT INTO public.sign_in_attempts (id, user_id, email, provider_id, success, reason, ip_address, user_agent, created_at)
+	          VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)`
+
+	var userID *string
+	if record.UserID != "" {
+		userID = &record.UserID
+	}
+
+	_, err := a.pool.Exec(ctx, query,
+		record.ID, userID, record.Email, record.ProviderID, record.Success, record.Reason,
+		record.IPAddress, record.UserAgent, record.CreatedAt,
+	)
+	return err
+}
+
+func (a *Adapter) ListSignInRecords(ctx context.Context, userID string, limit, offset int) ([]*kuta.SignInRecord, int, error) {
+	query := `SELECT id, user_id, email, provider_id, success, reason, ip_address, user_agent, created_at, count(*) OVER()
+	          FROM public.sign_in_attempts WHERE user_id = $1
+	          ORDER BY created_at DESC LIMIT $2 OFFSET $3`
+
+	rows, err := a.pool.Query(ctx, query, userID, limit, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	records := []*kuta.SignInRecord{}
+	total := 0
+	for rows.Next() {
+		record := &kuta.SignInRecord{}
+		var recordUserID *string
+		if err := rows.Scan(
+			&record.ID, &recordUserID, &record.Email, &record.ProviderID, &record.Success, &record.Reason,
+			&record.IPAddress, &record.UserAgent, &record.CreatedAt, &total,
+		); err != nil {
+			return nil, 0, err
+		}
+		if recordUserID != nil {
+			record.UserID = *recordUserID
+		}
+		records = append(records, record)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	// count(*) OVER() has no row to ride on when the page is past the end
+	if len(records) == 0 && offset > 0 {
+		countQuery := `SELECT count(*) FROM public.sign_in_attempts WHERE user_id = $1`
+		if err := a.pool.QueryRow(ctx, countQuery, userID).Scan(&total); err != nil {
+			return nil, 0, err
+		}
+	}
+
+	return records, total, nil
+}
+
+func (a *Adapter) PurgeSignInRecords(ctx context.Context, cutoff time.Time) (int, error) {
+	tag, err := a.pool.Exec(ctx, `DELETE FROM public.sign_in_attempts WHERE created_at < $1`, cutoff)
+	if err != nil {
+		return 0, err
+	}
+	return int(tag.RowsAffected()), nil
+}
@@ -8,15 +8,40 @@ import (
 	"github.com/lborres/kuta"
 )
 
-func (a *Adapter) CreateUser(user *kuta.User) error {
-	ctx := context.Background()
+var _ kuta.DeletedUserReader = (*Adapter)(nil)
 
-	query := `INSERT INTO public.users (id, email, email_verified, name, image) VALUES ($1, $2, $3, $4, $5) RETURNING id, created_at, updated_at`
+func (a *Adapter) CreateUser(ctx context.Context, user *kuta.User) error {
+	query := `INSERT INTO public.users (id, email, email_verified, name, image, status) VALUES ($1, $2, $3, $4, $5, COALESCE(NULLIF($6, ''), 'active')) RETURNING id, status, created_at, updated_at`
 	var id string
 	var createdAt, updatedAt time.Time
 
-	err := a.pool.QueryRow(ctx, query, user.ID, user.Email, user.EmailVerified, user.Name, user.Image).Scan(&id, &createdAt, &updatedAt)
+	err := a.pool.QueryRow(ctx, query, user.ID, user.Email, user.EmailVerified, user.Name, user.Image, user.Status).Scan(&id, &user.Status, &createdAt, &updatedAt)
 	if err != nil {
+		if isUniqueViolation(err) {
+			return kuta.ErrUserExists
+		}
+		return err
+	}
+
+	user.ID = id
+	user.CreatedAt = createdAt
+	user.UpdatedAt = updatedAt
+	return nil
+}
+
+func (a *Adapter) CreateUserIfNotExists(ctx context.Context, user *kuta.User) error {
+	query := `INSERT INTO public.users (id, email, email_verified, name, image, status) VALUES ($1, $2, $3, $4, $5, COALESCE(NULLIF($6, ''), 'active'))
+	          ON CONFLICT (email) WHERE deleted_at IS NULL DO NOTHING
+	          RETURNING id, status, created_at, updated_at`
+	var id string
+	var createdAt, updatedAt time.Time
+
+	err := a.pool.QueryRow(ctx, query, user.ID, user.Email, user.EmailVerified, user.Name, user.Image, user.Status).Scan(&id, &user.Status, &createdAt, &updatedAt)
+	if err != nil {
+		// ON CONFLICT DO NOTHING returns no row when the email is taken
+		if err == pgx.ErrNoRows || isUniqueViolation(err) {
+			return kuta.ErrUserExists
+		}
 		return err
 	}
 
@@ -26,13 +51,22 @@ func (a *Adapter) CreateUser(user *kuta.User) error {
 	return nil
 }
 
-func (a *Adapter) GetUserByID(id string) (*kuta.User, error) {
-	ctx := context.Background()
-	q := `SELECT id, email, email_verified, name, image, created_at, updated_at FROM public.users WHERE id = $1`
+// queryUserByID backs GetSession; see hotStatements
+const queryUserByID = `SELECT id, email, email_verified, name, image, status, created_at, updated_at FROM public.users WHERE id = $1 AND deleted_at IS NULL`
+
+func (a *Adapter) GetUserByID(ctx context.Context, id string) (*kuta.User, error) {
+	return a.getUser(ctx, queryUserByID, id)
+}
+
+// GetUserByIDIncludingDeleted also finds soft-deleted users
+func (a *Adapter) GetUserByIDIncludingDeleted(ctx context.Context, id string) (*kuta.User, error) {
+	return a.getUser(ctx, `SELECT id, email, email_verified, name, image, status, created_at, updated_at FROM public.users WHERE id = $1`, id)
+}
 
+func (a *Adapter) getUser(ctx context.Context, query, id string) (*kuta.User, error) {
 	user := &kuta.User{}
 	var image *string
-	err := a.pool.QueryRow(ctx, q, id).Scan(&user.ID, &user.Email, &user.EmailVerified, &user.Name, &image, &user.CreatedAt, &user.UpdatedAt)
+	err := a.pool.QueryRow(ctx, query, id).Scan(&user.ID, &user.Email, &user.EmailVerified, &user.Name, &image, &user.Status, &user.CreatedAt, &user.UpdatedAt)
 	if err != nil {
 		if err == pgx.ErrNoRows {
 			return nil, kuta.ErrUserNotFound
@@ -43,13 +77,12 @@ func (a *Adapter) GetUserByID(id string) (*kuta.User, error) {
 	return user, nil
 }
 
-func (a *Adapter) GetUserByEmail(email string) (*kuta.User, error) {
-	ctx := context.Background()
-	q := `SELECT id, email, email_verified, name, image, created_at, updated_at FROM public.users WHERE email = $1`
+func (a *Adapter) GetUserByEmail(ctx context.Context, email string) (*kuta.User, error) {
+	q := `SELECT id, email, email_verified, name, image, status, created_at, updated_at FROM public.users WHERE email = $1 AND deleted_at IS NULL`
 
 	user := &kuta.User{}
 	var image *string
-	err := a.pool.QueryRow(ctx, q, email).Scan(&user.ID, &user.Email, &user.EmailVerified, &user.Name, &image, &user.CreatedAt, &user.UpdatedAt)
+	err := a.pool.QueryRow(ctx, q, email).Scan(&user.ID, &user.Email, &user.EmailVerified, &user.Name, &image, &user.Status, &user.CreatedAt, &user.UpdatedAt)
 	if err != nil {
 		if err == pgx.ErrNoRows {
 			return nil, kuta.ErrUserNotFound
@@ -60,26 +93,140 @@ func (a *Adapter) GetUserByEmail(email string) (*kuta.User, error) {
 	return user, nil
 }
 
-func (a *Adapter) UpdateUser(user *kuta.User) error {
-	ctx := context.Background()
-	q := `UPDATE public.users SET email = $1, email_verified = $2, name = $3, image = $4, updated_at = now() WHERE id = $5 RETURNING updated_at`
+func (a *Adapter) UpdateUser(ctx context.Context, user *kuta.User) error {
+	q := `UPDATE public.users SET email = $1, email_verified = $2, name = $3, image = $4, updated_at = now() WHERE id = $5 AND deleted_at IS NULL RETURNING updated_at`
 	var updatedAt time.Time
 	err := a.pool.QueryRow(ctx, q, user.Email, user.EmailVerified, user.Name, user.Image, user.ID).Scan(&updatedAt)
 	if err != nil {
-		if err == pgx.ErrNoRows {
-			return kuta.ErrUserNotFound
+		return updateUserError(err)
+	}
+	user.UpdatedAt = updatedAt
+	return nil
+}
+
+// updateUserError maps the errors of UpdateUser: no row means the user is
+// gone, and a unique violation that another user has the new email
+func updateUserError(err error) error {
+	switch {
+	case err == pgx.ErrNoRows:
+		return kuta.ErrUserNotFound
+	case isUniqueViolation(err):
+		return kuta.ErrUserExists
+	default:
+		return err
+	}
+}
+
+func (a *Adapter) DeleteUser(ctx context.Context, id string) error {
+	_, err := a.pool.Exec(ctx, `DELETE FROM public.users WHERE id = $1 AND deleted_at IS NULL`, id)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// SoftDeleteUser marks the user and their accounts deleted in one
+// statement, so neither their email nor their provider accounts keep others
+// from signing up while the user waits to be purged
+func (a *Adapter) SoftDeleteUser(ctx context.Context, id string) error {
+	q := `WITH deleted AS (
+	          UPDATE public.users SET deleted_at = now(), updated_at = now()
+	          WHERE id = $1 AND deleted_at IS NULL
+	          RETURNING id, deleted_at
+	      ), accounts AS (
+	          UPDATE public.accounts SET deleted_at = deleted.deleted_at
+	          FROM deleted WHERE public.accounts.user_id = deleted.id
+	      )
+	      SELECT count(*) FROM deleted`
+
+	var count int
+	if err := a.pool.QueryRow(ctx, q, id).Scan(&count); err != nil {
+		return err
+	}
+	if count == 0 {
+		return kuta.ErrUserNotFound
+	}
+	return nil
+}
+
+// RestoreUser brings back the user and their accounts; it fails with
+// ErrUserExists when their email or a provider account was taken meanwhile
+func (a *Adapter) RestoreUser(ctx context.Context, id string, deletedAfter time.Time) error {
+	q := `WITH restored AS (
+	          UPDATE public.users SET deleted_at = NULL, updated_at = now()
+	          WHERE id = $1 AND deleted_at >= $2
+	          RETURNING id
+	      ), accounts AS (
+	          UPDATE public.accounts SET deleted_at = NULL
+	          FROM restored WHERE public.accounts.user_id = restored.id
+	      )
+	      SELECT count(*) FROM restored`
+
+	var count int
+	if err := a.pool.QueryRow(ctx, q, id, deletedAfter).Scan(&count); err != nil {
+		if isUniqueViolation(err) {
+			return kuta.ErrUserExists
 		}
 		return err
 	}
-	user.UpdatedAt = updatedAt
+	if count == 0 {
+		return kuta.ErrUserNotFound
+	}
 	return nil
 }
 
-func (a *Adapter) DeleteUser(id string) error {
-	ctx := context.Background()
-	_, err := a.pool.Exec(ctx, `DELETE FROM public.users WHERE id = $1`, id)
+func (a *Adapter) ListUsersByStatus(ctx context.Context, status string, limit, offset int) ([]*kuta.User, int, error) {
+	query := `SELECT id, email, email_verified, name, image, status, created_at, updated_at, count(*) OVER()
+	          FROM public.users WHERE status = $1 AND deleted_at IS NULL
+	          ORDER BY created_at LIMIT $2 OFFSET $3`
+
+	rows, err := a.pool.Query(ctx, query, status, limit, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	var users []*kuta.User
+	total := 0
+	for rows.Next() {
+		user := &kuta.User{}
+		err := rows.Scan(&user.ID, &user.Email, &user.EmailVerified, &user.Name, &user.Image, &user.Status, &user.CreatedAt, &user.UpdatedAt, &total)
+		if err != nil {
+			return nil, 0, err
+		}
+		users = append(users, user)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	// count(*) OVER() has no row to ride on when the page is past the end
+	if len(users) == 0 && offset > 0 {
+		countQuery := `SELECT count(*) FROM public.users WHERE status = $1 AND deleted_at IS NULL`
+		if err := a.pool.QueryRow(ctx, countQuery, status).Scan(&total); err != nil {
+			return nil, 0, err
+		}
+	}
+
+	return users, total, nil
+}
+
+func (a *Adapter) SetUserStatus(ctx context.Context, id, from, to string) error {
+	tag, err := a.pool.Exec(ctx, `UPDATE public.users SET status = $1, updated_at = now() WHERE id = $2 AND status = $3 AND deleted_at IS NULL`, to, id, from)
 	if err != nil {
 		return err
 	}
+	if tag.RowsAffected() == 0 {
+		return kuta.ErrUserNotFound
+	}
 	return nil
 }
+
+func (a *Adapter) PurgeDeletedUsers(ctx context.Context, deletedBefore time.Time) (int, error) {
+	tag, err := a.pool.Exec(ctx, `DELETE FROM public.users WHERE deleted_at IS NOT NULL AND deleted_at < $1`, deletedBefore)
+	if err != nil {
+		return 0, err
+	}
+	return int(tag.RowsAffected()), nil
+}
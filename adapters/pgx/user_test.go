@@ -0,0 +1,40 @@
+package pgx
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/lborres/kuta"
+)
+
+// Requirement: UpdateUser reports a missing user as ErrUserNotFound and an
+// email taken by another user as ErrUserExists, passing other errors on.
+func TestUpdateUserError(t *testing.T) {
+	other := errors.New("connection reset")
+
+	tests := []struct {
+		name string
+		err  error
+		want error
+	}{
+		{name: "no row", err: pgx.ErrNoRows, want: kuta.ErrUserNotFound},
+		{name: "unique violation", err: &pgconn.PgError{Code: pgUniqueViolation}, want: kuta.ErrUserExists},
+		{name: "wrapped unique violation", err: fmt.Errorf("update: %w", &pgconn.PgError{Code: pgUniqueViolation}), want: kuta.ErrUserExists},
+		{name: "other error", err: other, want: other},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// Act
+			got := updateUserError(test.err)
+
+			// Assert
+			if !errors.Is(got, test.want) {
+				t.Errorf("updateUserError() = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
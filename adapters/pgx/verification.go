@@ -0,0 +1,58 @@
+package pgx
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/lborres/kuta"
+)
+
+func (a *Adapter) CreateVerificationToken(ctx context.Context, token *kuta.VerificationToken) error {
+	query := `INSERT INTO public.verification_tokens (id, user_id, identifier, purpose, token_hash, expires_at)
+	          VALUES ($1, $2, $3, $4, $5, $6)
+	          RETURNING created_at`
+
+	return a.pool.QueryRow(ctx, query,
+		token.ID, token.UserID, token.Identifier, token.Purpose, token.TokenHash, token.ExpiresAt,
+	).Scan(&token.CreatedAt)
+}
+
+func (a *Adapter) ConsumeVerificationToken(ctx context.Context, tokenHash, purpose string) (*kuta.VerificationToken, error) {
+	query := `UPDATE public.verification_tokens SET consumed_at = now()
+	          WHERE token_hash = $1 AND purpose = $2 AND consumed_at IS NULL AND expires_at > now()
+	          RETURNING id, user_id, identifier, purpose, token_hash, expires_at, consumed_at, created_at`
+
+	token := &kuta.VerificationToken{}
+	err := a.pool.QueryRow(ctx, query, tokenHash, purpose).Scan(
+		&token.ID, &token.UserID, &token.Identifier, &token.Purpose, &token.TokenHash, &token.ExpiresAt, &token.ConsumedAt, &token.CreatedAt,
+	)
+
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, kuta.ErrVerificationTokenNotFound
+		}
+		return nil, err
+	}
+
+	return token, nil
+}
+
+func (a *Adapter) RevokeUserVerificationTokens(ctx context.Context, userID string, purposes ...string) (int, error) {
+	tag, err := a.pool.Exec(ctx,
+		`UPDATE public.verification_tokens SET consumed_at = now()
+		 WHERE user_id = $1 AND purpose = ANY($2) AND consumed_at IS NULL AND expires_at > now()`, userID, purposes)
+	if err != nil {
+		return 0, err
+	}
+	return int(tag.RowsAffected()), nil
+}
+
+func (a *Adapter) PurgeVerificationTokens(ctx context.Context, expiredBefore, consumedBefore time.Time) (int, error) {
+	tag, err := a.pool.Exec(ctx,
+		`DELETE FROM public.verification_tokens WHERE expires_at < $1 OR consumed_at < $2`, expiredBefore, consumedBefore)
+	if err != nil {
+		return 0, err
+	}
+	return int(tag.RowsAffected()), nil
+}
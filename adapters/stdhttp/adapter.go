@@ -0,0 +1,316 @@
+package stdhttp
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/lborres/kuta"
+	"github.com/lborres/kuta/pkg/clientip"
+	"github.com/lborres/kuta/services"
+)
+
+const (
+	defaultCookieName = "auth_token"
+)
+
+// Options customizes how the net/http adapter mounts routes and reads
+// requests. Zero values fall back to kuta's defaults.
+type Options struct {
+	// CookieName is the cookie checked for the session token when no
+	// Authorization header is present. Defaults to "auth_token".
+	CookieName string
+
+	// SetCookie makes sign-up, sign-in and refresh set the session token as
+	// an HttpOnly cookie named CookieName, and sign-out clear it.
+	SetCookie bool
+
+	// CookieDomain is the Domain attribute of the session cookie
+	CookieDomain string
+
+	// CookieSameSite is the SameSite attribute of the session cookie:
+	// "Lax" (default), "Strict" or "None".
+	CookieSameSite string
+
+	// CookieInsecure drops the Secure attribute so the cookie works over
+	// plain HTTP. Only meant for local development.
+	CookieInsecure bool
+
+	// TokenHeader, when set, sends the session token of sign-up, sign-in and
+	// refresh responses in this response header (e.g.
+	// kuta.DefaultTokenHeader) and leaves it out of the JSON body.
+	TokenHeader string
+
+	// OAuthRedirectURL is where the browser is sent once an OAuth callback
+	// has set the session cookie, e.g. the app's home page. Without it, or
+	// without SetCookie, the callback responds with the sign-in result.
+	OAuthRedirectURL string
+
+	// BasePath overrides kuta.Config.BasePath for this adapter
+	BasePath string
+
+	// RouteGroups mounts the endpoints under several prefixes, each with its
+	// own middleware (func(http.Handler) http.Handler values, applied in
+	// order). Replaces BasePath when set.
+	RouteGroups []kuta.RouteGroup
+
+	// CORS enables cross-origin requests to the auth routes. Credentials are
+	// allowed exactly when SetCookie is on.
+	CORS *kuta.CORSConfig
+
+	// TrustedProxies lists IPs or CIDRs of load balancers / reverse proxies
+	// whose ProxyHeader and X-Real-IP headers are honored when recording the
+	// client IP on sessions.
+	TrustedProxies []string
+
+	// TrustProxy honors forwarding headers from any peer. Only enable this
+	// when the app is reachable exclusively through a proxy that sets them.
+	TrustProxy bool
+
+	// ProxyHeader is the header holding the client IP chain.
+	// Defaults to "X-Forwarded-For".
+	ProxyHeader string
+
+	// ResponseEnvelope shapes response bodies, e.g. kuta.DataEnvelope for
+	// {"data": ..., "error": ...}. Defaults to kuta.BareEnvelope.
+	ResponseEnvelope kuta.ResponseEnvelope
+
+	// Transforms registers per-endpoint request decoders and response
+	// encoders, keyed by OperationID, e.g. kuta.SnakeCaseDecoder. Set
+	// Transforms.FieldNaming to kuta.FieldNamingSnakeCase to switch every
+	// endpoint to snake_case keys.
+	Transforms kuta.PayloadTransforms
+
+	// MaxBodySize bounds the request bodies of the auth endpoints in bytes.
+	// Larger ones are refused with 413 before they are decoded. Defaults to
+	// kuta.DefaultMaxBodySize.
+	MaxBodySize int64
+
+	resolver *clientip.Resolver
+}
+
+// resolve fills in defaults and builds the client IP resolver
+func (o Options) resolve() (Options, error) {
+	if o.CookieName == "" {
+		o.CookieName = defaultCookieName
+	}
+	if o.ProxyHeader == "" {
+		o.ProxyHeader = "X-Forwarded-For"
+	}
+	if o.CookieSameSite == "" {
+		o.CookieSameSite = "Lax"
+	}
+	if o.MaxBodySize <= 0 {
+		o.MaxBodySize = kuta.DefaultMaxBodySize
+	}
+
+	if o.CORS != nil {
+		if err := o.CORS.Validate(o.SetCookie); err != nil {
+			return o, err
+		}
+	}
+
+	resolver, err := clientip.NewResolver(clientip.Config{
+		TrustedProxies:  o.TrustedProxies,
+		TrustAllProxies: o.TrustProxy,
+		Header:          o.ProxyHeader,
+	})
+	if err != nil {
+		return o, err
+	}
+	o.resolver = resolver
+
+	return o, nil
+}
+
+// allowedOrigin reports whether CORS allows requests from origin
+func (o Options) allowedOrigin(origin string) bool {
+	for _, allowed := range o.CORS.AllowedOrigins {
+		if allowed == "*" || allowed == origin {
+			return true
+		}
+	}
+	return false
+}
+
+// setCORSHeaders adds the CORS response headers for an allowed origin and
+// reports whether it was allowed
+func (o Options) setCORSHeaders(w http.ResponseWriter, r *http.Request) bool {
+	header := w.Header()
+	header.Add("Vary", "Origin")
+
+	origin := r.Header.Get("Origin")
+	if origin == "" || !o.allowedOrigin(origin) {
+		return false
+	}
+
+	header.Set("Access-Control-Allow-Origin", origin)
+	if o.SetCookie {
+		header.Set("Access-Control-Allow-Credentials", "true")
+	}
+	// Browsers hide response headers from cross-origin scripts unless exposed
+	if o.TokenHeader != "" {
+		header.Set("Access-Control-Expose-Headers", o.TokenHeader)
+	}
+	return true
+}
+
+// corsHandler adds the CORS headers to responses of the auth routes, ahead
+// of any route group middleware
+func (o Options) corsHandler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		o.setCORSHeaders(w, r)
+		next.ServeHTTP(w, r)
+	})
+}
+
+// corsPreflight answers the browser's OPTIONS preflight for the auth routes
+func (o Options) corsPreflight() http.Handler {
+	allowHeaders := append([]string{"Authorization", "Content-Type", HeaderProof, kuta.IdempotencyKeyHeader}, o.CORS.AllowedHeaders...)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if o.setCORSHeaders(w, r) {
+			header := w.Header()
+			header.Set("Access-Control-Allow-Methods", "GET, POST, PUT, PATCH, DELETE")
+			header.Set("Access-Control-Allow-Headers", strings.Join(allowHeaders, ", "))
+			if o.CORS.MaxAge > 0 {
+				header.Set("Access-Control-Max-Age", strconv.Itoa(int(o.CORS.MaxAge.Seconds())))
+			}
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
+
+// envelope returns the configured response envelope or the bare default
+func (o Options) envelope() kuta.ResponseEnvelope {
+	if o.ResponseEnvelope == nil {
+		return kuta.BareEnvelope{}
+	}
+	return o.ResponseEnvelope
+}
+
+// handlerOptions configures the handlers kuta shares across adapters
+func (o Options) handlerOptions() services.HandlerOptions {
+	return services.HandlerOptions{
+		CookieName:       o.CookieName,
+		SetCookie:        o.SetCookie,
+		CookieDomain:     o.CookieDomain,
+		CookieSameSite:   o.CookieSameSite,
+		CookieInsecure:   o.CookieInsecure,
+		TokenHeader:      o.TokenHeader,
+		Envelope:         o.envelope(),
+		Transforms:       o.Transforms,
+		OAuthRedirectURL: o.OAuthRedirectURL,
+		Bind: func(ctx *kuta.RequestContext, operationID string, out any) error {
+			// Read the body through ctx.Request, which the idempotency
+			// wrapper serves from its buffer
+			r := ctx.Native.(*Exchange).Request
+			r.Body = io.NopCloser(ctx.Request.Body())
+			return o.bind(r, operationID, out)
+		},
+		FormImage: func(ctx *kuta.RequestContext, field string) (*kuta.ImageUpload, error) {
+			return multipartImage(ctx.Native.(*Exchange).Request, field)
+		},
+	}
+}
+
+// bind decodes the request body with the operation's decoder, falling back
+// to JSON or, for form posts, the fields' form tags
+func (o Options) bind(r *http.Request, operationID string, out any) error {
+	if decoder, ok := o.Transforms.Decoder(operationID); ok {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			return err
+		}
+		return decoder(body, out)
+	}
+
+	contentType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	switch contentType {
+	case "multipart/form-data":
+		if err := r.ParseMultipartForm(o.MaxBodySize); err != nil {
+			return err
+		}
+		return bindForm(r, out)
+	case "application/x-www-form-urlencoded":
+		if err := r.ParseForm(); err != nil {
+			return err
+		}
+		return bindForm(r, out)
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		return err
+	}
+	if len(body) == 0 {
+		return nil
+	}
+	return json.Unmarshal(body, out)
+}
+
+// bindForm sets the string fields of the struct out points to from the
+// form values named by their form tags
+func bindForm(r *http.Request, out any) error {
+	v := reflect.ValueOf(out)
+	if v.Kind() != reflect.Pointer || v.Elem().Kind() != reflect.Struct {
+		return errors.New("form binding needs a pointer to a struct")
+	}
+	v = v.Elem()
+	for i := range v.NumField() {
+		field := v.Type().Field(i)
+		name := field.Tag.Get("form")
+		if name == "" || name == "-" || !r.Form.Has(name) {
+			continue
+		}
+		value := r.Form.Get(name)
+		switch target := v.Field(i); {
+		case target.Kind() == reflect.String:
+			target.SetString(value)
+		case target.Kind() == reflect.Pointer && target.Type().Elem().Kind() == reflect.String:
+			target.Set(reflect.ValueOf(&value))
+		}
+	}
+	return nil
+}
+
+// fail sends an error response through the envelope
+func (o Options) fail(w http.ResponseWriter, status int, message string) error {
+	return writeJSON(w, status, o.envelope().Failure(status, message))
+}
+
+// authError maps kuta errors to an enveloped error response
+func (o Options) authError(w http.ResponseWriter, err error) error {
+	status, body := kuta.ErrorBody(o.envelope(), err)
+	return writeJSON(w, status, body)
+}
+
+// clientIP returns the IP address recorded on sessions for this request
+func (o Options) clientIP(r *http.Request) string {
+	remoteIP, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		remoteIP = r.RemoteAddr
+	}
+	if o.resolver == nil {
+		return remoteIP
+	}
+	return o.resolver.ClientIP(remoteIP, r.Header.Get)
+}
+
+// writeJSON sends body as JSON with status
+func writeJSON(w http.ResponseWriter, status int, body any) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_, err = w.Write(data)
+	return err
+}
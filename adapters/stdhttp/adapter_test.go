@@ -0,0 +1,281 @@
+package stdhttp
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lborres/kuta"
+	"github.com/lborres/kuta/pkg/clientip"
+	"github.com/lborres/kuta/services"
+)
+
+// Requirement: Options fall back to kuta defaults for unset fields.
+func TestOptions_Resolve(t *testing.T) {
+	opts, err := Options{}.resolve()
+	if err != nil {
+		t.Fatalf("resolve() error = %v", err)
+	}
+	if opts.CookieName != defaultCookieName {
+		t.Errorf("CookieName = %q, want %q", opts.CookieName, defaultCookieName)
+	}
+	if opts.ProxyHeader != "X-Forwarded-For" {
+		t.Errorf("ProxyHeader = %q, want %q", opts.ProxyHeader, "X-Forwarded-For")
+	}
+
+	custom, _ := Options{CookieName: "sid", ProxyHeader: "X-Real-IP"}.resolve()
+	if custom.CookieName != "sid" || custom.ProxyHeader != "X-Real-IP" {
+		t.Errorf("resolve() should keep explicit values; got %+v", custom)
+	}
+
+	if _, err := (Options{TrustedProxies: []string{"nope"}}).resolve(); !errors.Is(err, clientip.ErrInvalidProxy) {
+		t.Errorf("resolve() with invalid proxy error = %v, want ErrInvalidProxy", err)
+	}
+}
+
+// Requirement: clientIP only trusts forwarding headers from trusted proxies.
+func TestOptions_ClientIP(t *testing.T) {
+	tests := []struct {
+		name   string
+		opts   Options
+		header string
+		want   string
+	}{
+		{name: "ignores forwarded header by default", opts: Options{}, header: "203.0.113.7", want: "192.0.2.1"},
+		{name: "uses forwarded address when all proxies are trusted", opts: Options{TrustProxy: true}, header: "203.0.113.7, 10.0.0.1", want: "203.0.113.7"},
+		{name: "uses forwarded address from a trusted proxy", opts: Options{TrustedProxies: []string{"192.0.2.0/24"}}, header: "203.0.113.7", want: "203.0.113.7"},
+		{name: "ignores forwarded address from an untrusted proxy", opts: Options{TrustedProxies: []string{"10.0.0.0/8"}}, header: "203.0.113.7", want: "192.0.2.1"},
+		{name: "falls back to remote address when header is missing", opts: Options{TrustProxy: true}, header: "", want: "192.0.2.1"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// Arrange
+			opts, err := test.opts.resolve()
+			if err != nil {
+				t.Fatalf("resolve() error = %v", err)
+			}
+			// httptest requests come from 192.0.2.1:1234
+			req := httptest.NewRequest(http.MethodGet, "/ip", nil)
+			if test.header != "" {
+				req.Header.Set("X-Forwarded-For", test.header)
+			}
+
+			// Act
+			got := opts.clientIP(req)
+
+			// Assert
+			if got != test.want {
+				t.Errorf("clientIP() = %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+// Requirement: CORS headers are sent on auth routes only, with credentials
+// matching the cookie mode, including on responses rejected by the route.
+func TestAdapter_CORS(t *testing.T) {
+	tests := []struct {
+		name            string
+		method          string
+		origin          string
+		path            string
+		setCookie       bool
+		wantStatus      int
+		wantAllowOrigin string
+		wantCredentials string
+	}{
+		{name: "allowed origin with cookies", method: http.MethodOptions, origin: "https://app.example.com", path: "/api/auth/session", setCookie: true, wantStatus: http.StatusNoContent, wantAllowOrigin: "https://app.example.com", wantCredentials: "true"},
+		{name: "allowed origin without cookies", method: http.MethodOptions, origin: "https://app.example.com", path: "/api/auth/session", wantStatus: http.StatusNoContent, wantAllowOrigin: "https://app.example.com"},
+		{name: "unknown origin", method: http.MethodOptions, origin: "https://evil.example.com", path: "/api/auth/session", setCookie: true, wantStatus: http.StatusNoContent},
+		{name: "route outside auth group", method: http.MethodOptions, origin: "https://app.example.com", path: "/other", setCookie: true, wantStatus: http.StatusNoContent},
+		{name: "error response", method: http.MethodGet, origin: "https://app.example.com", path: "/api/auth/session", setCookie: true, wantStatus: http.StatusUnauthorized, wantAllowOrigin: "https://app.example.com", wantCredentials: "true"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// Arrange
+			mux := http.NewServeMux()
+			adapter := New(mux, Options{
+				SetCookie: test.setCookie,
+				CORS:      &kuta.CORSConfig{AllowedOrigins: []string{"https://app.example.com"}},
+			})
+			if err := adapter.RegisterRoutes(&mockAuthProvider{}, "/api/auth", 0); err != nil {
+				t.Fatalf("RegisterRoutes() error = %v", err)
+			}
+			mux.HandleFunc("OPTIONS /other", func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusNoContent) })
+			req := httptest.NewRequest(test.method, test.path, nil)
+			req.Header.Set("Origin", test.origin)
+			req.Header.Set("Access-Control-Request-Method", http.MethodGet)
+			recorder := httptest.NewRecorder()
+
+			// Act
+			mux.ServeHTTP(recorder, req)
+
+			// Assert
+			if recorder.Code != test.wantStatus {
+				t.Errorf("status = %d, want %d", recorder.Code, test.wantStatus)
+			}
+			if got := recorder.Header().Get("Access-Control-Allow-Origin"); got != test.wantAllowOrigin {
+				t.Errorf("Access-Control-Allow-Origin = %q, want %q", got, test.wantAllowOrigin)
+			}
+			if got := recorder.Header().Get("Access-Control-Allow-Credentials"); got != test.wantCredentials {
+				t.Errorf("Access-Control-Allow-Credentials = %q, want %q", got, test.wantCredentials)
+			}
+		})
+	}
+}
+
+// Requirement: Route groups mount endpoints under several prefixes with their own middleware.
+func TestAdapter_RouteGroups(t *testing.T) {
+	blockInternal := func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get("X-Internal") != "yes" {
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+
+	tests := []struct {
+		name       string
+		path       string
+		internal   bool
+		wantStatus int
+	}{
+		{name: "public group", path: "/api/auth/sign-out", wantStatus: http.StatusUnauthorized},
+		{name: "internal group without header", path: "/internal/auth/sign-out", wantStatus: http.StatusForbidden},
+		{name: "internal group with header", path: "/internal/auth/sign-out", internal: true, wantStatus: http.StatusUnauthorized},
+		{name: "operation not included in internal group", path: "/internal/auth/sign-in", internal: true, wantStatus: http.StatusNotFound},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// Arrange
+			mux := http.NewServeMux()
+			adapter := New(mux, Options{RouteGroups: []kuta.RouteGroup{
+				{Prefix: "/api/auth"},
+				{Prefix: "/internal/auth", Middleware: []interface{}{blockInternal}, Include: []string{"signOut"}},
+			}})
+			if err := adapter.RegisterRoutes(&mockAuthProvider{}, "/ignored", 0); err != nil {
+				t.Fatalf("RegisterRoutes() error = %v", err)
+			}
+			req := httptest.NewRequest(http.MethodPost, test.path, nil)
+			if test.internal {
+				req.Header.Set("X-Internal", "yes")
+			}
+			recorder := httptest.NewRecorder()
+
+			// Act
+			mux.ServeHTTP(recorder, req)
+
+			// Assert
+			if recorder.Code != test.wantStatus {
+				t.Errorf("status = %d, want %d", recorder.Code, test.wantStatus)
+			}
+		})
+	}
+}
+
+// Requirement: Route group middleware must be a func(http.Handler) http.Handler.
+func TestAdapter_RouteGroups_InvalidMiddleware(t *testing.T) {
+	adapter := New(http.NewServeMux(), Options{RouteGroups: []kuta.RouteGroup{
+		{Prefix: "/api/auth", Middleware: []interface{}{"not a handler"}},
+	}})
+
+	if err := adapter.RegisterRoutes(&mockAuthProvider{}, "/api/auth", 0); err == nil {
+		t.Error("RegisterRoutes() should reject middleware that doesn't wrap an http.Handler")
+	}
+}
+
+// Requirement: Every built-in endpoint, including token refresh, is mounted
+// by RegisterRoutes.
+func TestAdapter_RegisterRoutes_MountsBaseEndpoints(t *testing.T) {
+	// Arrange
+	mux := http.NewServeMux()
+	mock := &mockAuthProvider{
+		signUpResult:  &kuta.SignUpResult{},
+		signInResult:  &kuta.SignInResult{Session: &kuta.Session{}},
+		refreshResult: &kuta.RefreshResult{Session: &kuta.Session{}},
+	}
+	if err := New(mux).RegisterRoutes(mock, "/api/auth", 0); err != nil {
+		t.Fatalf("RegisterRoutes() error = %v", err)
+	}
+
+	for _, endpoint := range services.BaseEndpoints() {
+		t.Run(endpoint.Metadata.OperationID, func(t *testing.T) {
+			req := httptest.NewRequest(endpoint.Method, "/api/auth"+endpoint.Path, strings.NewReader(`{"refreshToken":"rt"}`))
+			req.Header.Set("Content-Type", "application/json")
+			recorder := httptest.NewRecorder()
+
+			// Act
+			mux.ServeHTTP(recorder, req)
+
+			// Assert
+			if recorder.Code == http.StatusNotFound || recorder.Code == http.StatusMethodNotAllowed {
+				t.Errorf("%s %s not mounted: status %d", endpoint.Method, endpoint.Path, recorder.Code)
+			}
+		})
+	}
+
+	if mock.refreshToken != "rt" {
+		t.Errorf("expected refresh route to reach the provider, got token %q", mock.refreshToken)
+	}
+}
+
+// Requirement: The protected middleware lets draining sessions read but not
+// mutate, and hands the authenticated user to the wrapped handler.
+func TestAdapter_ProtectedMiddleware(t *testing.T) {
+	revokedAt := time.Now()
+
+	tests := []struct {
+		name       string
+		method     string
+		token      string
+		draining   bool
+		wantStatus int
+	}{
+		{name: "active session mutates", method: http.MethodPost, token: "tok", wantStatus: http.StatusOK},
+		{name: "draining session reads", method: http.MethodGet, token: "tok", draining: true, wantStatus: http.StatusOK},
+		{name: "draining session mutates", method: http.MethodPost, token: "tok", draining: true, wantStatus: http.StatusUnauthorized},
+		{name: "missing token", method: http.MethodGet, wantStatus: http.StatusUnauthorized},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// Arrange
+			session := &kuta.Session{ID: "s1", ExpiresAt: time.Now().Add(time.Hour)}
+			if test.draining {
+				session.RevokedAt = &revokedAt
+			}
+			mock := &mockAuthProvider{getSessionData: &kuta.SessionData{User: &kuta.User{ID: "u1"}, Session: session}}
+			protected := New(http.NewServeMux()).BuildProtectedMiddleware(mock).(func(http.Handler) http.Handler)
+			var gotUser *kuta.User
+			var gotSession *kuta.Session
+			handler := protected(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				gotUser, gotSession = UserFromContext(r.Context()), SessionFromContext(r.Context())
+				w.WriteHeader(http.StatusOK)
+			}))
+			req := httptest.NewRequest(test.method, "/resource", nil)
+			if test.token != "" {
+				req.Header.Set("Authorization", "Bearer "+test.token)
+			}
+			recorder := httptest.NewRecorder()
+
+			// Act
+			handler.ServeHTTP(recorder, req)
+
+			// Assert
+			if recorder.Code != test.wantStatus {
+				t.Fatalf("status = %d, want %d", recorder.Code, test.wantStatus)
+			}
+			if test.wantStatus == http.StatusOK && (gotUser == nil || gotUser.ID != "u1" || gotSession != session) {
+				t.Errorf("context user = %+v, session = %+v", gotUser, gotSession)
+			}
+		})
+	}
+}
@@ -0,0 +1,66 @@
+package stdhttp
+
+import (
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/lborres/kuta"
+)
+
+// HeaderProof carries the proof-of-possession for key-bound sessions
+const HeaderProof = kuta.ProofHeader
+
+// multipartImage reads an image file sent as a multipart form field. It
+// returns nil when the request is not multipart or has no such field.
+func multipartImage(r *http.Request, field string) (*kuta.ImageUpload, error) {
+	if r.MultipartForm == nil {
+		return nil, nil
+	}
+	files := r.MultipartForm.File[field]
+	if len(files) == 0 {
+		return nil, nil
+	}
+
+	header := files[0]
+	file, err := header.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(file)
+	if err != nil {
+		return nil, err
+	}
+	return &kuta.ImageUpload{ContentType: header.Header.Get("Content-Type"), Data: data}, nil
+}
+
+// extractToken extracts the authentication token from the request.
+// Checks Authorization header (Bearer token) first, then falls back to cookie.
+func extractToken(r *http.Request, cookieName string) string {
+	if token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok && token != "" {
+		return token
+	}
+
+	cookie, err := r.Cookie(cookieName)
+	if err != nil {
+		return ""
+	}
+	return cookie.Value
+}
+
+// checkProof enforces proof-of-possession for key-bound sessions when the
+// auth provider supports it
+func checkProof(r *http.Request, authProvider kuta.AuthProvider, session *kuta.Session) error {
+	verifier, ok := authProvider.(kuta.ProofVerifier)
+	if !ok {
+		return nil
+	}
+
+	proof, err := kuta.ParseRequestProof(r.Header.Get(HeaderProof), r.Method, r.URL.Path)
+	if err != nil {
+		return err
+	}
+	return verifier.VerifyProof(session, proof)
+}
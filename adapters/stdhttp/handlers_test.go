@@ -0,0 +1,555 @@
+package stdhttp
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lborres/kuta"
+)
+
+// mockAuthProvider is a test fake implementing kuta.AuthProvider interface
+type mockAuthProvider struct {
+	signUpCalled    bool
+	signUpInput     kuta.SignUpInput
+	signUpErr       error
+	signUpResult    *kuta.SignUpResult
+	signInInput     kuta.SignInInput
+	signInErr       error
+	signInResult    *kuta.SignInResult
+	continueInput   kuta.ContinueSignInInput
+	continueErr     error
+	continueResult  *kuta.SignInResult
+	signOutToken    string
+	signOutErr      error
+	getSessionToken string
+	getSessionErr   error
+	getSessionData  *kuta.SessionData
+	refreshToken    string
+	refreshErr      error
+	refreshResult   *kuta.RefreshResult
+	ipAddress       string
+	userAgent       string
+}
+
+func (m *mockAuthProvider) SignUp(ctx context.Context, input kuta.SignUpInput, ipAddress, userAgent string) (*kuta.SignUpResult, error) {
+	m.signUpCalled = true
+	m.signUpInput = input
+	m.ipAddress, m.userAgent = ipAddress, userAgent
+	if m.signUpErr != nil {
+		return nil, m.signUpErr
+	}
+	return m.signUpResult, nil
+}
+
+func (m *mockAuthProvider) SignIn(ctx context.Context, input kuta.SignInInput, ipAddress, userAgent string) (*kuta.SignInResult, error) {
+	m.signInInput = input
+	m.ipAddress, m.userAgent = ipAddress, userAgent
+	if m.signInErr != nil {
+		return nil, m.signInErr
+	}
+	return m.signInResult, nil
+}
+
+func (m *mockAuthProvider) ContinueSignIn(ctx context.Context, input kuta.ContinueSignInInput) (*kuta.SignInResult, error) {
+	m.continueInput = input
+	if m.continueErr != nil {
+		return nil, m.continueErr
+	}
+	return m.continueResult, nil
+}
+
+func (m *mockAuthProvider) SignOut(ctx context.Context, token string) error {
+	m.signOutToken = token
+	return m.signOutErr
+}
+
+func (m *mockAuthProvider) GetSession(ctx context.Context, token string) (*kuta.SessionData, error) {
+	m.getSessionToken = token
+	if m.getSessionErr != nil {
+		return nil, m.getSessionErr
+	}
+	return m.getSessionData, nil
+}
+
+func (m *mockAuthProvider) Refresh(ctx context.Context, token string) (*kuta.RefreshResult, error) {
+	m.refreshToken = token
+	if m.refreshErr != nil {
+		return nil, m.refreshErr
+	}
+	return m.refreshResult, nil
+}
+
+// Requirement: Sign-up binds a JSON or form body, passes the client's IP and
+// user agent to the provider, and responds 201 with the session cookie set.
+func TestHandleSignUp(t *testing.T) {
+	tests := []struct {
+		name        string
+		body        any
+		contentType string
+		signUpErr   error
+		wantCalled  bool
+		wantStatus  int
+		wantCookie  bool
+		wantError   string
+	}{
+		{
+			name:       "creates user and session",
+			body:       map[string]string{"email": "a@b.c", "password": "pw", "name": "Ada"},
+			wantCalled: true,
+			wantStatus: http.StatusCreated,
+			wantCookie: true,
+		},
+		{
+			name:        "form post",
+			body:        "email=a%40b.c&password=pw&name=Ada",
+			contentType: "application/x-www-form-urlencoded",
+			wantCalled:  true,
+			wantStatus:  http.StatusCreated,
+			wantCookie:  true,
+		},
+		{
+			name:       "maps provider error to status",
+			body:       map[string]string{"email": "a@b.c", "password": "pw"},
+			signUpErr:  kuta.ErrUserExists,
+			wantCalled: true,
+			wantStatus: http.StatusConflict,
+			wantError:  kuta.ErrUserExists.Error(),
+		},
+		{
+			name:       "rejects malformed body",
+			body:       `{"email":`,
+			wantStatus: http.StatusBadRequest,
+			wantError:  "invalid request body",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// Arrange
+			mock := &mockAuthProvider{
+				signUpResult: &kuta.SignUpResult{
+					User:    &kuta.User{ID: "u1", Email: "a@b.c"},
+					Session: &kuta.Session{ExpiresAt: time.Now().Add(time.Hour)},
+					Token:   "tok",
+				},
+				signUpErr: test.signUpErr,
+			}
+			server := newTestServer(t, mock, Options{SetCookie: true})
+			headers := map[string]string{"User-Agent": "test-agent"}
+			if test.contentType != "" {
+				headers["Content-Type"] = test.contentType
+			}
+
+			// Act
+			resp := server.do(testRequest{Method: http.MethodPost, Path: "/sign-up", Body: test.body, Headers: headers})
+
+			// Assert
+			if resp.Status != test.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", resp.Status, test.wantStatus, resp.Body)
+			}
+			if mock.signUpCalled != test.wantCalled {
+				t.Errorf("provider called = %v, want %v", mock.signUpCalled, test.wantCalled)
+			}
+			if test.wantCalled && (mock.signUpInput.Email != "a@b.c" || mock.userAgent != "test-agent" || mock.ipAddress == "") {
+				t.Errorf("provider got input %+v from %q/%q", mock.signUpInput, mock.ipAddress, mock.userAgent)
+			}
+			if got := resp.cookie(defaultCookieName) != nil; got != test.wantCookie {
+				t.Errorf("session cookie set = %v, want %v", got, test.wantCookie)
+			}
+			if test.wantError != "" {
+				var body struct{ Error string }
+				resp.decode(t, &body)
+				if body.Error != test.wantError {
+					t.Errorf("error = %q, want %q", body.Error, test.wantError)
+				}
+			}
+		})
+	}
+}
+
+// Requirement: Sign-in responds with the session and sets a secure,
+// HTTP-only cookie; failures carry the provider's status and no cookie.
+func TestHandleSignIn(t *testing.T) {
+	tests := []struct {
+		name       string
+		body       any
+		signInErr  error
+		wantStatus int
+		wantCookie bool
+	}{
+		{
+			name:       "issues session cookie",
+			body:       map[string]string{"email": "a@b.c", "password": "pw"},
+			wantStatus: http.StatusOK,
+			wantCookie: true,
+		},
+		{
+			name:       "invalid credentials",
+			body:       map[string]string{"email": "a@b.c", "password": "wrong"},
+			signInErr:  kuta.ErrInvalidCredentials,
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name:       "malformed body",
+			body:       "not json",
+			wantStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// Arrange
+			mock := &mockAuthProvider{
+				signInResult: &kuta.SignInResult{
+					User:    &kuta.User{ID: "u1"},
+					Session: &kuta.Session{ExpiresAt: time.Now().Add(time.Hour)},
+					Token:   "tok",
+				},
+				signInErr: test.signInErr,
+			}
+			server := newTestServer(t, mock, Options{SetCookie: true})
+
+			// Act
+			resp := server.do(testRequest{Method: http.MethodPost, Path: "/sign-in", Body: test.body})
+
+			// Assert
+			if resp.Status != test.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", resp.Status, test.wantStatus, resp.Body)
+			}
+			cookie := resp.cookie(defaultCookieName)
+			if got := cookie != nil; got != test.wantCookie {
+				t.Fatalf("session cookie set = %v, want %v", got, test.wantCookie)
+			}
+			if cookie != nil && (cookie.Value != "tok" || !cookie.HttpOnly || !cookie.Secure || cookie.SameSite != http.SameSiteLaxMode) {
+				t.Errorf("unexpected cookie %+v", cookie)
+			}
+			if test.wantStatus == http.StatusOK && mock.signInInput.Password != "pw" {
+				t.Errorf("provider got input %+v", mock.signInInput)
+			}
+		})
+	}
+}
+
+// Requirement: Sign-out and get-session take the token from the bearer header
+// or the session cookie; sign-out clears the cookie.
+func TestHandleSignOutAndGetSession(t *testing.T) {
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		headers    map[string]string
+		cookies    []*http.Cookie
+		wantStatus int
+		wantToken  string
+	}{
+		{
+			name:       "sign out with bearer token",
+			method:     http.MethodPost,
+			path:       "/sign-out",
+			headers:    map[string]string{"Authorization": "Bearer tok"},
+			wantStatus: http.StatusOK,
+			wantToken:  "tok",
+		},
+		{
+			name:       "sign out with cookie",
+			method:     http.MethodPost,
+			path:       "/sign-out",
+			cookies:    []*http.Cookie{{Name: defaultCookieName, Value: "cookie-tok"}},
+			wantStatus: http.StatusOK,
+			wantToken:  "cookie-tok",
+		},
+		{
+			name:       "sign out without token",
+			method:     http.MethodPost,
+			path:       "/sign-out",
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name:       "get session",
+			method:     http.MethodGet,
+			path:       "/session",
+			headers:    map[string]string{"Authorization": "Bearer tok"},
+			wantStatus: http.StatusOK,
+			wantToken:  "tok",
+		},
+		{
+			name:       "get session with malformed authorization header",
+			method:     http.MethodGet,
+			path:       "/session",
+			headers:    map[string]string{"Authorization": "Basic abc"},
+			wantStatus: http.StatusUnauthorized,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// Arrange
+			mock := &mockAuthProvider{
+				getSessionData: &kuta.SessionData{User: &kuta.User{ID: "u1", Email: "a@b.c"}, Session: &kuta.Session{ID: "s1"}},
+			}
+			server := newTestServer(t, mock, Options{SetCookie: true})
+
+			// Act
+			resp := server.do(testRequest{Method: test.method, Path: test.path, Headers: test.headers, Cookies: test.cookies})
+
+			// Assert
+			if resp.Status != test.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", resp.Status, test.wantStatus, resp.Body)
+			}
+			if got := mock.signOutToken + mock.getSessionToken; got != test.wantToken {
+				t.Errorf("provider got token %q, want %q", got, test.wantToken)
+			}
+			if test.path == "/sign-out" && test.wantStatus == http.StatusOK {
+				if cleared := resp.cookie(defaultCookieName); cleared == nil || cleared.Value != "" {
+					t.Errorf("expected session cookie to be cleared, got %+v", cleared)
+				}
+			}
+			if test.path == "/session" && test.wantStatus == http.StatusOK {
+				var body kuta.SessionData
+				resp.decode(t, &body)
+				if body.User == nil || body.User.Email != "a@b.c" || body.Session == nil || body.Session.ID != "s1" {
+					t.Errorf("unexpected body %s", resp.Body)
+				}
+			}
+		})
+	}
+}
+
+// Requirement: Refresh reads the refresh token from the body, returns the
+// rotated tokens through the envelope and moves the session token to
+// TokenHeader when configured.
+func TestHandleRefresh(t *testing.T) {
+	tests := []struct {
+		name       string
+		opts       Options
+		body       any
+		refreshErr error
+		wantStatus int
+		wantBody   string
+		wantHeader string
+	}{
+		{
+			name:       "bare body",
+			body:       kuta.RefreshRequest{RefreshToken: "rt"},
+			wantStatus: http.StatusOK,
+			wantBody:   `{"session":{`,
+		},
+		{
+			name:       "data envelope error",
+			opts:       Options{ResponseEnvelope: kuta.DataEnvelope{}},
+			body:       kuta.RefreshRequest{RefreshToken: "rt"},
+			refreshErr: kuta.ErrRefreshTokenNotFound,
+			wantStatus: http.StatusUnauthorized,
+			wantBody:   `{"data":null,"error":{"message":"refresh token not found","code":401}}`,
+		},
+		{
+			name:       "token header",
+			opts:       Options{TokenHeader: kuta.DefaultTokenHeader},
+			body:       kuta.RefreshRequest{RefreshToken: "rt"},
+			wantStatus: http.StatusOK,
+			wantHeader: "new-tok",
+		},
+		{
+			name:       "missing refresh token",
+			body:       map[string]string{},
+			wantStatus: http.StatusUnauthorized,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// Arrange
+			mock := &mockAuthProvider{
+				refreshResult: &kuta.RefreshResult{Session: &kuta.Session{ExpiresAt: time.Now().Add(time.Hour)}, Token: "new-tok"},
+				refreshErr:    test.refreshErr,
+			}
+			server := newTestServer(t, mock, test.opts)
+
+			// Act
+			resp := server.do(testRequest{Method: http.MethodPost, Path: "/refresh", Body: test.body})
+
+			// Assert
+			if resp.Status != test.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", resp.Status, test.wantStatus, resp.Body)
+			}
+			if !strings.HasPrefix(string(resp.Body), test.wantBody) {
+				t.Errorf("body = %s, want prefix %s", resp.Body, test.wantBody)
+			}
+			if got := resp.Header.Get(kuta.DefaultTokenHeader); got != test.wantHeader {
+				t.Errorf("token header = %q, want %q", got, test.wantHeader)
+			}
+			if test.wantHeader != "" && strings.Contains(string(resp.Body), `"token":"new-tok"`) {
+				t.Errorf("token left in body: %s", resp.Body)
+			}
+		})
+	}
+}
+
+// Requirement: A challenged sign-in responds with the challenge and no
+// session cookie, and the continue endpoint passes the answer through.
+func TestHandlers_SignInChallenge(t *testing.T) {
+	// Arrange
+	mock := &mockAuthProvider{
+		signInResult:   &kuta.SignInResult{Challenge: &kuta.AuthChallenge{Type: kuta.ChallengeTwoFactor, Token: "challenge-tok"}},
+		continueResult: &kuta.SignInResult{Session: &kuta.Session{ExpiresAt: time.Now().Add(time.Hour)}, Token: "tok"},
+	}
+	server := newTestServer(t, mock, Options{SetCookie: true})
+
+	// Act
+	challenged := server.do(testRequest{Method: http.MethodPost, Path: "/sign-in", Body: `{"email":"a@b.c","password":"pw"}`})
+	continued := server.do(testRequest{Method: http.MethodPost, Path: "/sign-in/continue", Body: `{"challengeToken":"challenge-tok","response":"123456"}`})
+
+	// Assert
+	if challenged.Status != http.StatusOK || challenged.cookie(defaultCookieName) != nil ||
+		!strings.Contains(string(challenged.Body), `"type":"two_factor"`) {
+		t.Errorf("sign-in = %d %s, want the challenge without a cookie", challenged.Status, challenged.Body)
+	}
+	if continued.Status != http.StatusOK || continued.cookie(defaultCookieName) == nil {
+		t.Errorf("continue = %d %s, want the session cookie", continued.Status, continued.Body)
+	}
+	if mock.continueInput.ChallengeToken != "challenge-tok" || mock.continueInput.Response != "123456" {
+		t.Errorf("provider got %+v", mock.continueInput)
+	}
+}
+
+// rejectingAuthProvider refuses every request, so a fuzzed request can only
+// get an error response
+func rejectingAuthProvider() *mockAuthProvider {
+	return &mockAuthProvider{
+		signUpErr:     kuta.ErrUserExists,
+		signInErr:     kuta.ErrInvalidCredentials,
+		continueErr:   kuta.ErrChallengeNotFound,
+		signOutErr:    kuta.ErrSessionNotFound,
+		getSessionErr: kuta.ErrSessionNotFound,
+		refreshErr:    kuta.ErrRefreshTokenNotFound,
+	}
+}
+
+// validHeaderValue reports whether v could be sent as a header value, i.e.
+// has no control characters other than tab
+func validHeaderValue(v string) bool {
+	return !strings.ContainsFunc(v, func(r rune) bool {
+		return (r < ' ' && r != '\t') || r == 0x7f
+	})
+}
+
+// expectClientError fails t unless resp is a 4xx with a JSON error body
+func expectClientError(t *testing.T, resp testResponse) {
+	t.Helper()
+	if resp.Status < 400 || resp.Status > 499 {
+		t.Fatalf("status = %d, want 4xx: %s", resp.Status, resp.Body)
+	}
+	var body map[string]any
+	if err := json.Unmarshal(resp.Body, &body); err != nil || body["error"] == nil {
+		t.Fatalf("body = %q, want a JSON error", resp.Body)
+	}
+}
+
+// fuzzBodyPaths are the endpoints reading a request body
+var fuzzBodyPaths = []string{"/sign-up", "/sign-in", "/sign-in/continue", "/refresh"}
+
+// Requirement: Whatever body and content type a request has, the handlers
+// answer with a structured 4xx error rather than panicking.
+func FuzzHandlers_Body(f *testing.F) {
+	f.Add(uint8(0), "application/json", []byte(`{"email":"a@b.c","password":"pw"}`))
+	f.Add(uint8(1), "application/json", []byte(`{"email":`))
+	f.Add(uint8(1), "application/json", []byte(`{"email":["a"],"password":{}}`))
+	f.Add(uint8(2), "application/json", []byte(`null`))
+	f.Add(uint8(3), "application/json", []byte(`{"refreshToken":"\u0000"}`))
+	f.Add(uint8(3), "", []byte(`[]`))
+	f.Add(uint8(0), "application/x-www-form-urlencoded", []byte("email=%zz&password"))
+	f.Add(uint8(0), "multipart/form-data; boundary=x", []byte("--x\r\nContent-Disposition: form-data; name=\"imageUpload\"; filename=\"a.png\"\r\n\r\n\x89PNG\r\n--x--\r\n"))
+	f.Add(uint8(1), "multipart/form-data", []byte("--"))
+	f.Add(uint8(2), "text/plain; charset=", []byte{0xff, 0xfe})
+
+	f.Fuzz(func(t *testing.T, path uint8, contentType string, body []byte) {
+		if !validHeaderValue(contentType) {
+			t.Skip()
+		}
+
+		// Arrange
+		server := newTestServer(t, rejectingAuthProvider(), Options{SetCookie: true})
+
+		// Act
+		resp := server.do(testRequest{
+			Method:  http.MethodPost,
+			Path:    fuzzBodyPaths[int(path)%len(fuzzBodyPaths)],
+			Body:    string(body),
+			Headers: map[string]string{"Content-Type": contentType},
+		})
+
+		// Assert
+		expectClientError(t, resp)
+	})
+}
+
+// Requirement: Malformed Authorization headers and session cookies never
+// panic and are refused with a structured 4xx error.
+func FuzzHandlers_Token(f *testing.F) {
+	f.Add("Bearer ", "")
+	f.Add("Bearer", "tok")
+	f.Add("bearer tok", "")
+	f.Add("Basic dXNlcjpwYXNz", "")
+	f.Add("Bearer  tok with spaces", "")
+	f.Add("Bearer tok\xff", "\"quoted\";")
+	f.Add(strings.Repeat("Bearer ", 1000), "")
+
+	f.Fuzz(func(t *testing.T, authorization, cookie string) {
+		if !validHeaderValue(authorization) || !validHeaderValue(cookie) {
+			t.Skip()
+		}
+
+		// Arrange
+		server := newTestServer(t, rejectingAuthProvider(), Options{})
+		headers := map[string]string{"Authorization": authorization, "Cookie": "auth_token=" + cookie}
+
+		for _, endpoint := range []struct{ method, path string }{
+			{http.MethodGet, "/session"},
+			{http.MethodPost, "/sign-out"},
+		} {
+			// Act
+			resp := server.do(testRequest{Method: endpoint.method, Path: endpoint.path, Headers: headers})
+
+			// Assert
+			expectClientError(t, resp)
+		}
+	})
+}
+
+// Requirement: Request bodies over MaxBodySize are refused with a structured
+// 413 error, whether their Content-Length declares it or not.
+func TestHandlers_MaxBodySize(t *testing.T) {
+	body := `{"email":"a@b.c","password":"` + strings.Repeat("a", 100) + `"}`
+
+	tests := []struct {
+		name        string
+		maxBodySize int64
+		chunked     bool
+		wantStatus  int
+	}{
+		{name: "within the limit", maxBodySize: 1024, wantStatus: http.StatusUnauthorized},
+		{name: "declared length over the limit", maxBodySize: 64, wantStatus: http.StatusRequestEntityTooLarge},
+		{name: "chunked body over the limit", maxBodySize: 64, chunked: true, wantStatus: http.StatusRequestEntityTooLarge},
+		{name: "chunked body within the limit", maxBodySize: 1024, chunked: true, wantStatus: http.StatusUnauthorized},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// Arrange
+			server := newTestServer(t, rejectingAuthProvider(), Options{MaxBodySize: test.maxBodySize})
+
+			// Act
+			resp := server.do(testRequest{Method: http.MethodPost, Path: "/sign-in", Body: body, Chunked: test.chunked})
+
+			// Assert
+			expectClientError(t, resp)
+			if resp.Status != test.wantStatus {
+				t.Errorf("status = %d, want %d: %s", resp.Status, test.wantStatus, resp.Body)
+			}
+		})
+	}
+}
@@ -0,0 +1,115 @@
+package stdhttp
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/lborres/kuta"
+)
+
+// contextKey keys the values the protected middleware stores in the request
+// context
+type contextKey int
+
+const (
+	userKey contextKey = iota
+	sessionKey
+	accessTokenKey
+)
+
+// BuildProtectedMiddleware creates a func(http.Handler) http.Handler that
+// validates auth tokens and stores the user and session in the request
+// context for downstream handlers (see UserFromContext and
+// SessionFromContext). When the provider has access tokens enabled,
+// personal access tokens are accepted too; their requests have the access
+// token (see AccessTokenFromContext) instead of a session.
+func (a *Adapter) BuildProtectedMiddleware(authProvider kuta.AuthProvider) interface{} {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			token := extractToken(r, a.opts.CookieName)
+			if token == "" {
+				_ = a.opts.fail(w, http.StatusUnauthorized, kuta.ErrMissingAuthHeader.Error())
+				return
+			}
+
+			if tokens, ok := accessTokens(authProvider, token); ok {
+				data, err := tokens.VerifyAccessToken(r.Context(), token)
+				if err != nil {
+					_ = a.opts.fail(w, http.StatusUnauthorized, err.Error())
+					return
+				}
+
+				ctx := context.WithValue(r.Context(), userKey, data.User)
+				ctx = context.WithValue(ctx, accessTokenKey, data.AccessToken)
+				next.ServeHTTP(w, r.WithContext(ctx))
+				return
+			}
+
+			// Validate token and retrieve session data
+			sessionData, err := authProvider.GetSession(r.Context(), token)
+			if err != nil {
+				_ = a.opts.fail(w, http.StatusUnauthorized, err.Error())
+				return
+			}
+
+			// Key-bound sessions must also prove possession of the private key
+			if err := checkProof(r, authProvider, sessionData.Session); err != nil {
+				_ = a.opts.fail(w, http.StatusUnauthorized, err.Error())
+				return
+			}
+
+			// Revoked sessions still draining may only read
+			if sessionData.Session.Draining() && !idempotentMethod(r.Method) {
+				_ = a.opts.fail(w, http.StatusUnauthorized, kuta.ErrSessionDraining.Error())
+				return
+			}
+
+			ctx := context.WithValue(r.Context(), userKey, sessionData.User)
+			ctx = context.WithValue(ctx, sessionKey, sessionData.Session)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// UserFromContext returns the user the protected middleware authenticated,
+// or nil outside protected handlers
+func UserFromContext(ctx context.Context) *kuta.User {
+	user, _ := ctx.Value(userKey).(*kuta.User)
+	return user
+}
+
+// SessionFromContext returns the session the protected middleware
+// authenticated, or nil outside protected handlers
+func SessionFromContext(ctx context.Context) *kuta.Session {
+	session, _ := ctx.Value(sessionKey).(*kuta.Session)
+	return session
+}
+
+// AccessTokenFromContext returns the personal access token the protected
+// middleware authenticated, or nil for requests made with a session token.
+// Handlers check its scopes with HasScope.
+func AccessTokenFromContext(ctx context.Context) *kuta.AccessToken {
+	token, _ := ctx.Value(accessTokenKey).(*kuta.AccessToken)
+	return token
+}
+
+// accessTokens returns the provider to verify token with when it is a
+// personal access token and access tokens are enabled
+func accessTokens(auth kuta.AuthProvider, token string) (kuta.AccessTokenProvider, bool) {
+	if !kuta.IsAccessToken(token) {
+		return nil, false
+	}
+	tokens, ok := auth.(kuta.AccessTokenProvider)
+	return tokens, ok && tokens.AccessTokensEnabled()
+}
+
+// idempotentMethod reports whether requests with method only read, so
+// draining sessions may still make them
+func idempotentMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return true
+	default:
+		return false
+	}
+}
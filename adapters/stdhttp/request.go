@@ -0,0 +1,57 @@
+package stdhttp
+
+import (
+	"io"
+	"net/http"
+	"net/url"
+
+	"github.com/lborres/kuta"
+)
+
+// request implements kuta.Request over a net/http request
+type request struct {
+	r    *http.Request
+	opts Options
+}
+
+var _ kuta.Request = request{}
+
+func (q request) Method() string {
+	return q.r.Method
+}
+
+func (q request) Path() string {
+	return q.r.URL.Path
+}
+
+func (q request) Query() url.Values {
+	return q.r.URL.Query()
+}
+
+func (q request) PathValue(name string) string {
+	return q.r.PathValue(name)
+}
+
+func (q request) Header(name string) string {
+	return q.r.Header.Get(name)
+}
+
+func (q request) Body() io.Reader {
+	return q.r.Body
+}
+
+func (q request) ClientIP() string {
+	return q.opts.clientIP(q.r)
+}
+
+func (q request) UserAgent() string {
+	return q.r.UserAgent()
+}
+
+func (q request) Cookie(name string) string {
+	cookie, err := q.r.Cookie(name)
+	if err != nil {
+		return ""
+	}
+	return cookie.Value
+}
@@ -0,0 +1,88 @@
+package stdhttp
+
+import (
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/lborres/kuta"
+)
+
+// seenRequest is what a plugin endpoint read through kuta.Request
+type seenRequest struct {
+	header, cookie, missingCookie, userAgent, clientIP, body string
+}
+
+// pluginAuthProvider adds a plugin endpoint that records what it reads
+type pluginAuthProvider struct {
+	*mockAuthProvider
+	seen seenRequest
+}
+
+func (p *pluginAuthProvider) GetEndpoints() []kuta.Endpoint {
+	return []kuta.Endpoint{{
+		Path:   "/plugin",
+		Method: http.MethodPost,
+		Handler: func(ctx *kuta.RequestContext) error {
+			body, err := io.ReadAll(ctx.Request.Body())
+			if err != nil {
+				return err
+			}
+			p.seen = seenRequest{
+				header:        ctx.Request.Header("X-Plugin"),
+				cookie:        ctx.Request.Cookie("theme"),
+				missingCookie: ctx.Request.Cookie("missing"),
+				userAgent:     ctx.Request.UserAgent(),
+				clientIP:      ctx.Request.ClientIP(),
+				body:          string(body),
+			}
+			ctx.Native.(*Exchange).Writer.WriteHeader(http.StatusNoContent)
+			return nil
+		},
+		Metadata: kuta.EndpointMetadata{OperationID: "plugin"},
+	}}
+}
+
+// Requirement: Plugin endpoints read headers, cookies, the user agent, the
+// client IP and the body through RequestContext.Request, with forwarding
+// headers honored only from trusted proxies.
+func TestRequestContext_Request(t *testing.T) {
+	tests := []struct {
+		name         string
+		opts         Options
+		wantClientIP string
+	}{
+		{name: "direct client", opts: Options{}, wantClientIP: "192.0.2.1"},
+		{name: "trusted proxy", opts: Options{TrustProxy: true}, wantClientIP: "203.0.113.7"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// Arrange
+			auth := &pluginAuthProvider{mockAuthProvider: &mockAuthProvider{}}
+			server := newTestServer(t, auth, test.opts)
+
+			// Act
+			resp := server.do(testRequest{
+				Method: http.MethodPost,
+				Path:   "/plugin",
+				Body:   "payload",
+				Headers: map[string]string{
+					"X-Plugin":        "yes",
+					"User-Agent":      "plugin-test/1.0",
+					"X-Forwarded-For": "203.0.113.7",
+				},
+				Cookies: []*http.Cookie{{Name: "theme", Value: "dark"}},
+			})
+
+			// Assert
+			if resp.Status != http.StatusNoContent {
+				t.Fatalf("status = %d, want %d (body %s)", resp.Status, http.StatusNoContent, resp.Body)
+			}
+			want := seenRequest{header: "yes", cookie: "dark", userAgent: "plugin-test/1.0", clientIP: test.wantClientIP, body: "payload"}
+			if auth.seen != want {
+				t.Errorf("seen = %+v, want %+v", auth.seen, want)
+			}
+		})
+	}
+}
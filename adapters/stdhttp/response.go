@@ -0,0 +1,46 @@
+package stdhttp
+
+import (
+	"net/http"
+
+	"github.com/lborres/kuta"
+)
+
+// response implements kuta.Response over a net/http response writer
+type response struct {
+	w       http.ResponseWriter
+	status  int
+	written bool
+}
+
+var _ kuta.Response = (*response)(nil)
+
+func (s *response) SetHeader(name, value string) {
+	s.w.Header().Set(name, value)
+}
+
+func (s *response) SetCookie(cookie *http.Cookie) {
+	http.SetCookie(s.w, cookie)
+}
+
+func (s *response) Status(code int) {
+	s.status = code
+}
+
+func (s *response) JSON(v any) error {
+	s.written = true
+	status := s.status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	return writeJSON(s.w, status, v)
+}
+
+// finish sends the status set without a body. The writer can't hold a
+// status back like Fiber and Gin do, so it is written once the handler is
+// done with the response.
+func (s *response) finish() {
+	if !s.written && s.status != 0 {
+		s.w.WriteHeader(s.status)
+	}
+}
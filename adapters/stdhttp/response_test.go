@@ -0,0 +1,82 @@
+package stdhttp
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/lborres/kuta"
+)
+
+// responseAuthProvider adds a plugin endpoint answering through
+// RequestContext.Response with status and body, each left out when unset
+type responseAuthProvider struct {
+	*mockAuthProvider
+	status int
+	body   any
+}
+
+func (p *responseAuthProvider) GetEndpoints() []kuta.Endpoint {
+	return []kuta.Endpoint{{
+		Path:   "/plugin",
+		Method: http.MethodGet,
+		Handler: func(ctx *kuta.RequestContext) error {
+			ctx.Response.SetHeader("X-Plugin", "yes")
+			ctx.Response.SetCookie(&http.Cookie{Name: "theme", Value: "dark", Path: "/", HttpOnly: true, SameSite: http.SameSiteLaxMode})
+			ctx.Response.SetCookie(&http.Cookie{Name: "old", Path: "/", MaxAge: -1})
+			if p.status != 0 {
+				ctx.Response.Status(p.status)
+			}
+			if p.body == nil {
+				return nil
+			}
+			return ctx.Response.JSON(p.body)
+		},
+		Metadata: kuta.EndpointMetadata{OperationID: "plugin"},
+	}}
+}
+
+// Requirement: Plugin endpoints answer through RequestContext.Response:
+// headers, cookies (including deleting one), the status and a JSON body, or
+// the status alone.
+func TestRequestContext_Response(t *testing.T) {
+	tests := []struct {
+		name       string
+		status     int
+		body       any
+		wantStatus int
+		wantBody   string
+	}{
+		{name: "json body", status: http.StatusCreated, body: map[string]string{"hello": "world"}, wantStatus: http.StatusCreated, wantBody: `{"hello":"world"}`},
+		{name: "default status", body: map[string]string{"hello": "world"}, wantStatus: http.StatusOK, wantBody: `{"hello":"world"}`},
+		{name: "status only", status: http.StatusNoContent, wantStatus: http.StatusNoContent},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// Arrange
+			auth := &responseAuthProvider{mockAuthProvider: &mockAuthProvider{}, status: test.status, body: test.body}
+			server := newTestServer(t, auth, Options{})
+
+			// Act
+			resp := server.do(testRequest{Method: http.MethodGet, Path: "/plugin"})
+
+			// Assert
+			if resp.Status != test.wantStatus {
+				t.Fatalf("status = %d, want %d (body %s)", resp.Status, test.wantStatus, resp.Body)
+			}
+			if got := string(resp.Body); got != test.wantBody && got != test.wantBody+"\n" {
+				t.Errorf("body = %q, want %q", got, test.wantBody)
+			}
+			if resp.Header.Get("X-Plugin") != "yes" {
+				t.Errorf("X-Plugin = %q, want yes", resp.Header.Get("X-Plugin"))
+			}
+			if cookie := resp.cookie("theme"); cookie == nil || cookie.Value != "dark" || !cookie.HttpOnly || cookie.SameSite != http.SameSiteLaxMode {
+				t.Errorf("theme cookie = %+v, want dark, HttpOnly and SameSite=Lax", cookie)
+			}
+			if cookie := resp.cookie("old"); cookie == nil || (cookie.MaxAge >= 0 && !cookie.Expires.Before(time.Now())) {
+				t.Errorf("old cookie = %+v, want it deleted", cookie)
+			}
+		})
+	}
+}
@@ -0,0 +1,151 @@
+package stdhttp
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/lborres/kuta"
+	"github.com/lborres/kuta/services"
+)
+
+// Exchange is the RequestContext.Native of endpoints mounted by the
+// adapter, for plugin endpoint handlers needing more of the request or
+// response than RequestContext.Request and Response offer
+type Exchange struct {
+	Writer  http.ResponseWriter
+	Request *http.Request
+}
+
+type Adapter struct {
+	mux     *http.ServeMux
+	handler kuta.AuthProvider
+	opts    Options
+	optsErr error // reported by RegisterRoutes since New can't fail
+}
+
+var _ kuta.HTTPProvider = (*Adapter)(nil)
+
+// New creates a net/http adapter that mounts the auth endpoints on mux. An
+// optional Options value customizes cookie name, base path and client IP
+// extraction.
+func New(mux *http.ServeMux, opts ...Options) *Adapter {
+	var o Options
+	if len(opts) > 0 {
+		o = opts[0]
+	}
+	resolved, err := o.resolve()
+	return &Adapter{mux: mux, opts: resolved, optsErr: err}
+}
+
+func (a *Adapter) RegisterRoutes(service kuta.AuthProvider, basePath string, _ time.Duration) error {
+	if a.optsErr != nil {
+		return a.optsErr
+	}
+
+	a.handler = service
+
+	if a.opts.BasePath != "" {
+		basePath = a.opts.BasePath
+	}
+
+	groups := a.opts.RouteGroups
+	if len(groups) == 0 {
+		groups = []kuta.RouteGroup{{Prefix: basePath}}
+	}
+	registry, err := services.BuiltinRoutes(service, a.opts.handlerOptions(), groups)
+	if err != nil {
+		return err
+	}
+
+	for _, group := range registry.Groups() {
+		if err := a.mountGroup(registry, group); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// mountGroup registers the endpoints of one route group under its prefix
+func (a *Adapter) mountGroup(registry *services.EndpointRegistry, group kuta.RouteGroup) error {
+	endpoints, err := registry.GroupEndpoints(group)
+	if err != nil {
+		return err
+	}
+
+	middleware := make([]func(http.Handler) http.Handler, 0, len(group.Middleware))
+	for _, m := range group.Middleware {
+		wrap, ok := m.(func(http.Handler) http.Handler)
+		if !ok {
+			return fmt.Errorf("route group %s: middleware must be a func(http.Handler) http.Handler, got %T", group.Prefix, m)
+		}
+		middleware = append(middleware, wrap)
+	}
+
+	prefix := strings.TrimSuffix(group.Prefix, "/")
+	if a.opts.CORS != nil {
+		a.mux.Handle(http.MethodOptions+" "+prefix+"/", a.opts.corsPreflight())
+	}
+
+	for _, endpoint := range endpoints {
+		if endpoint.Handler == nil {
+			continue // Skip endpoints without handlers
+		}
+
+		var handler http.Handler = a.adaptHandler(endpoint)
+		// The first middleware runs first
+		for i := len(middleware) - 1; i >= 0; i-- {
+			handler = middleware[i](handler)
+		}
+		if a.opts.CORS != nil {
+			handler = a.opts.corsHandler(handler)
+		}
+
+		a.mux.Handle(endpoint.Method+" "+prefix+muxPath(endpoint.Path), handler)
+	}
+
+	return nil
+}
+
+// muxPath converts the ":name" parameters of endpoint paths to ServeMux's
+// "{name}" wildcards
+func muxPath(path string) string {
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		if name, ok := strings.CutPrefix(segment, ":"); ok {
+			segments[i] = "{" + name + "}"
+		}
+	}
+	return strings.Join(segments, "/")
+}
+
+// adaptHandler converts a framework-agnostic endpoint handler to an
+// http.Handler
+func (a *Adapter) adaptHandler(endpoint *kuta.Endpoint) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.ContentLength > a.opts.MaxBodySize {
+			_ = a.opts.authError(w, kuta.ErrBodyTooLarge)
+			return
+		}
+		r.Body = kuta.LimitBody(r.Body, a.opts.MaxBodySize)
+
+		res := &response{w: w}
+		ctx := &kuta.RequestContext{
+			Request:  request{r: r, opts: a.opts},
+			Response: res,
+			Native:   &Exchange{Writer: w, Request: r},
+			Auth:     a.handler,
+			Context:  r.Context(),
+		}
+
+		if err := endpoint.Handler(ctx); err != nil {
+			// Handlers write their own error responses, so errors returned
+			// are unexpected, as with Fiber's default error handler
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+		res.finish()
+	})
+}
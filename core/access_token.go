@@ -0,0 +1,97 @@
+package core
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"time"
+)
+
+// AccessTokenPrefix starts every personal access token, so they can't be
+// mistaken for session tokens and secret scanners can recognise them
+const AccessTokenPrefix = "kuta_pat_"
+
+// AccessToken is a long-lived personal access token a user created for a
+// CLI or script. Unlike sessions, access tokens are created explicitly,
+// named, limited to Scopes and survive sign-out.
+type AccessToken struct {
+	ID        string `json:"id"`
+	UserID    string `json:"userId"`
+	Name      string `json:"name"`
+	TokenHash string `json:"-"` // Never expose in JSON (security!)
+	// Scopes are app-defined permissions the token is limited to, sorted
+	Scopes []string `json:"scopes"`
+	// ExpiresAt is nil for tokens that don't expire
+	ExpiresAt  *time.Time `json:"expiresAt,omitempty"`
+	LastUsedAt *time.Time `json:"lastUsedAt,omitempty"`
+	CreatedAt  time.Time  `json:"createdAt"`
+}
+
+// HasScope reports whether the token was granted scope
+func (t *AccessToken) HasScope(scope string) bool {
+	_, found := slices.BinarySearch(t.Scopes, scope)
+	return found
+}
+
+// Expired reports whether the token has expired at now
+func (t *AccessToken) Expired(now time.Time) bool {
+	return t.ExpiresAt != nil && !now.Before(*t.ExpiresAt)
+}
+
+// IsAccessToken reports whether token looks like a personal access token
+// rather than a session token
+func IsAccessToken(token string) bool {
+	return strings.HasPrefix(token, AccessTokenPrefix)
+}
+
+// AccessTokenStorage persists personal access tokens by the hash of their
+// value
+type AccessTokenStorage interface {
+	CreateAccessToken(ctx context.Context, token *AccessToken) error
+	// GetAccessTokenByHash returns ErrAccessTokenNotFound for unknown
+	// hashes. Expired tokens are returned; callers check Expired.
+	GetAccessTokenByHash(ctx context.Context, tokenHash string) (*AccessToken, error)
+	// ListUserAccessTokens returns the user's tokens, newest first
+	ListUserAccessTokens(ctx context.Context, userID string) ([]*AccessToken, error)
+	// DeleteAccessToken deletes the user's token, returning
+	// ErrAccessTokenNotFound when the user has no token with id
+	DeleteAccessToken(ctx context.Context, userID, id string) error
+	// TouchAccessToken records that the token was used at usedAt
+	TouchAccessToken(ctx context.Context, id string, usedAt time.Time) error
+}
+
+// CreateAccessTokenInput describes a new personal access token
+type CreateAccessTokenInput struct {
+	Name   string
+	Scopes []string
+	// ExpiresAt must be in the future; nil creates a token that doesn't
+	// expire
+	ExpiresAt *time.Time
+}
+
+// CreateAccessTokenResult is a new personal access token. Token is the raw
+// value, returned only this once.
+type CreateAccessTokenResult struct {
+	AccessToken *AccessToken `json:"accessToken"`
+	Token       string       `json:"token"`
+}
+
+// AccessTokenData is a verified personal access token and its user
+type AccessTokenData struct {
+	User        *User        `json:"user"`
+	AccessToken *AccessToken `json:"accessToken"`
+}
+
+// AccessTokenProvider manages the signed-in user's personal access tokens.
+// Adapters mount the /me/tokens endpoints when it is enabled.
+type AccessTokenProvider interface {
+	// AccessTokensEnabled reports whether the access token endpoints should
+	// be mounted
+	AccessTokensEnabled() bool
+	CreateAccessToken(ctx context.Context, userID string, input CreateAccessTokenInput) (*CreateAccessTokenResult, error)
+	ListAccessTokens(ctx context.Context, userID string) ([]*AccessToken, error)
+	RevokeAccessToken(ctx context.Context, userID, tokenID string) error
+	// VerifyAccessToken returns the token and its user, or ErrInvalidToken
+	// for unknown tokens and ErrAccessTokenExpired for expired ones
+	VerifyAccessToken(ctx context.Context, token string) (*AccessTokenData, error)
+}
@@ -1,19 +1,111 @@
 package core
 
-import "time"
+import (
+	"context"
+	"strings"
+	"time"
+)
 
 // Account represents an authentication method
 //
 // This is the "credential" - how someone proves who they are
 type Account struct {
-	ID           string     `json:"id"`
-	UserID       string     `json:"userId"`
-	ProviderID   string     `json:"providerId"` // "credential", "google", "github"
-	AccountID    string     `json:"accountId"`
-	Password     *string    `json:"-"` // Never expose in JSON
-	AccessToken  *string    `json:"-"` // Never expose in JSON
-	RefreshToken *string    `json:"-"` // Never expose in JSON
-	ExpiresAt    *time.Time `json:"expiresAt,omitempty"`
-	CreatedAt    time.Time  `json:"createdAt"`
-	UpdatedAt    time.Time  `json:"updatedAt"`
+	ID           string         `json:"id"`
+	UserID       string         `json:"userId"`
+	ProviderID   string         `json:"providerId"` // "credential", "google", "github"
+	AccountID    string         `json:"accountId"`
+	Password     *string        `json:"-"` // Never expose in JSON
+	AccessToken  *string        `json:"-"` // Never expose in JSON
+	RefreshToken *string        `json:"-"` // Never expose in JSON
+	ExpiresAt    *time.Time     `json:"expiresAt,omitempty"`
+	ProfileData  map[string]any `json:"-"` // Raw provider profile; expose through Profile()
+	CreatedAt    time.Time      `json:"createdAt"`
+	UpdatedAt    time.Time      `json:"updatedAt"`
+}
+
+// sensitiveProfileKeys are substrings of profile keys Profile leaves out
+var sensitiveProfileKeys = []string{"token", "secret", "password"}
+
+// Profile returns a copy of ProfileData without keys that look like
+// credentials ("access_token", "client_secret", ...), at any nesting level.
+// It returns nil when there is no profile data.
+func (a *Account) Profile() map[string]any {
+	if a.ProfileData == nil {
+		return nil
+	}
+	return sanitizeProfile(a.ProfileData)
+}
+
+func sanitizeProfile(data map[string]any) map[string]any {
+	clean := make(map[string]any, len(data))
+	for key, value := range data {
+		if isSensitiveProfileKey(key) {
+			continue
+		}
+		clean[key] = sanitizeProfileValue(value)
+	}
+	return clean
+}
+
+func sanitizeProfileValue(value any) any {
+	switch v := value.(type) {
+	case map[string]any:
+		return sanitizeProfile(v)
+	case []any:
+		items := make([]any, len(v))
+		for i, item := range v {
+			items[i] = sanitizeProfileValue(item)
+		}
+		return items
+	default:
+		return v
+	}
+}
+
+func isSensitiveProfileKey(key string) bool {
+	key = strings.ToLower(key)
+	for _, sensitive := range sensitiveProfileKeys {
+		if strings.Contains(key, sensitive) {
+			return true
+		}
+	}
+	return false
+}
+
+// AccountLinker lets signed-in users manage the accounts they sign in with.
+// Adapters mount the account endpoints when the auth provider implements it,
+// and the link endpoint when OAuth sign-in is enabled too.
+type AccountLinker interface {
+	// ListAccounts returns the user's accounts with the providers the auth
+	// provider knows: email and password, passkeys and OAuth providers
+	ListAccounts(ctx context.Context, userID string) ([]*Account, error)
+	// StartOAuthLink begins linking providerID to the user. The provider's
+	// callback then adds the provider account to the user, or refuses with
+	// ErrAccountLinked when it belongs to another user.
+	StartOAuthLink(ctx context.Context, userID, providerID string) (*OAuthStart, error)
+	// UnlinkAccount removes one of the user's accounts, returning
+	// ErrAccountNotFound when the user has no account with accountID and
+	// ErrLastAccount when it is the only one left to sign in with
+	UnlinkAccount(ctx context.Context, userID, accountID string) error
+}
+
+// ProviderTokens is the result of refreshing an OAuth provider access token
+type ProviderTokens struct {
+	AccessToken string
+	// RefreshToken is empty when the provider keeps the old one valid
+	RefreshToken string
+	ExpiresAt    time.Time
+}
+
+// TokenRefresher exchanges an OAuth provider refresh token for a new access
+// token. One is registered per provider ID ("google", "github", ...).
+type TokenRefresher interface {
+	RefreshToken(refreshToken string) (*ProviderTokens, error)
+}
+
+// TokenRefresherFunc adapts a plain function to TokenRefresher
+type TokenRefresherFunc func(refreshToken string) (*ProviderTokens, error)
+
+func (f TokenRefresherFunc) RefreshToken(refreshToken string) (*ProviderTokens, error) {
+	return f(refreshToken)
 }
@@ -0,0 +1,68 @@
+package core
+
+import "context"
+
+// Outcomes of password reset and verification email requests, reported in
+// Metadata["outcome"] of EventPasswordResetRequested and
+// EventVerificationRequested
+const (
+	EmailRequestSent            = "sent"
+	EmailRequestUnknownEmail    = "unknown_email"
+	EmailRequestNoPassword      = "no_password"      // the user signs in with providers only
+	EmailRequestAlreadyVerified = "already_verified" // no verification email is needed
+	EmailRequestFailed          = "failed"           // Metadata["error"] says why
+)
+
+// AccountEmailProvider lets users reset a forgotten password and verify
+// their email address with single-use tokens sent by email. Adapters mount
+// its endpoints when the auth provider implements it and
+// AccountEmailsEnabled reports true.
+//
+// Under enumeration protection ForgotPassword and ResendVerification
+// succeed whether or not the email is registered, and only events report
+// what they did.
+type AccountEmailProvider interface {
+	AccountEmailsEnabled() bool
+	// ForgotPassword emails a password reset token to the user registered
+	// with email
+	ForgotPassword(ctx context.Context, email, ipAddress string) error
+	// ResetPassword sets a new password with a token from ForgotPassword
+	ResetPassword(ctx context.Context, input ResetPasswordInput, ipAddress string) error
+	// ResendVerification emails an email verification token to the user
+	// registered with email, unless their email is verified already
+	ResendVerification(ctx context.Context, email, ipAddress string) error
+	// VerifyEmail marks the email a token from ResendVerification was sent
+	// to verified
+	VerifyEmail(ctx context.Context, token, ipAddress string) error
+}
+
+// ResetPasswordInput sets a new password with a password reset token
+type ResetPasswordInput struct {
+	Token       string
+	NewPassword string
+}
+
+// EmailRequest is the body of POST /forgot-password and
+// POST /resend-verification
+type EmailRequest struct {
+	Email string `json:"email"`
+}
+
+// ResetPasswordRequest is the body of POST /reset-password
+type ResetPasswordRequest struct {
+	Token       string `json:"token"`
+	NewPassword string `json:"newPassword"`
+}
+
+// Input converts the request into AccountEmailProvider input
+func (r ResetPasswordRequest) Input() ResetPasswordInput {
+	return ResetPasswordInput{
+		Token:       r.Token,
+		NewPassword: r.NewPassword,
+	}
+}
+
+// VerifyEmailRequest is the body of POST /verify-email
+type VerifyEmailRequest struct {
+	Token string `json:"token"`
+}
@@ -0,0 +1,59 @@
+package core
+
+import (
+	"reflect"
+	"testing"
+)
+
+// Requirement: Profile drops credential-looking keys at any depth and never
+// hands out the stored map.
+func TestAccount_Profile(t *testing.T) {
+	tests := []struct {
+		name string
+		data map[string]any
+		want map[string]any
+	}{
+		{
+			name: "nil profile data",
+			data: nil,
+			want: nil,
+		},
+		{
+			name: "drops top-level secrets",
+			data: map[string]any{"avatar_url": "https://a", "locale": "en", "access_token": "x", "id_token": "y"},
+			want: map[string]any{"avatar_url": "https://a", "locale": "en"},
+		},
+		{
+			name: "drops nested secrets",
+			data: map[string]any{
+				"settings": map[string]any{"Client_Secret": "s", "theme": "dark"},
+				"emails":   []any{map[string]any{"email": "a@b.c", "password": "p"}},
+			},
+			want: map[string]any{
+				"settings": map[string]any{"theme": "dark"},
+				"emails":   []any{map[string]any{"email": "a@b.c"}},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// Arrange
+			account := &Account{ProfileData: test.data}
+
+			// Act
+			got := account.Profile()
+
+			// Assert
+			if !reflect.DeepEqual(got, test.want) {
+				t.Fatalf("expected %v, got %v", test.want, got)
+			}
+			if got != nil {
+				got["injected"] = true
+				if _, ok := account.ProfileData["injected"]; ok {
+					t.Error("expected Profile to return a copy")
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,39 @@
+package core
+
+import (
+	"context"
+	"time"
+)
+
+// SessionFilter narrows session searches. Zero-valued fields are ignored.
+type SessionFilter struct {
+	UserID        string    `json:"userId,omitempty"`
+	IPAddress     string    `json:"ipAddress,omitempty"`
+	UserAgent     string    `json:"userAgent,omitempty"` // case-insensitive substring match
+	CreatedAfter  time.Time `json:"createdAfter,omitempty"`
+	CreatedBefore time.Time `json:"createdBefore,omitempty"`
+	Limit         int       `json:"limit,omitempty"`
+	Offset        int       `json:"offset,omitempty"`
+}
+
+// SessionPage is one page of a session search, newest first
+type SessionPage struct {
+	Sessions []*Session `json:"sessions"`
+	Total    int        `json:"total"`
+	Limit    int        `json:"limit"`
+	Offset   int        `json:"offset"`
+}
+
+// AdminAuthorizer decides whether a signed-in user may call admin operations
+type AdminAuthorizer func(data *SessionData) bool
+
+// AdminProvider provides administrative operations for HTTP adapters
+type AdminProvider interface {
+	// AdminEnabled reports whether admin endpoints should be mounted
+	AdminEnabled() bool
+	// AuthorizeAdmin returns the caller's session data if the token belongs
+	// to an administrator, ErrForbidden otherwise.
+	AuthorizeAdmin(ctx context.Context, token string) (*SessionData, error)
+	ListSessions(ctx context.Context, filter SessionFilter) (*SessionPage, error)
+	RevokeSessions(ctx context.Context, sessionIDs []string) (int, error)
+}
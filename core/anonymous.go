@@ -0,0 +1,23 @@
+package core
+
+import "context"
+
+// AnonymousEmailDomain is the domain of the placeholder emails anonymous
+// users are created with. It is reserved (RFC 2606), so no mail is ever
+// delivered there and no real user can sign up with one.
+const AnonymousEmailDomain = "anonymous.invalid"
+
+// AnonymousSessionProvider issues guest sessions. Adapters mount
+// POST /sign-in/anonymous when the auth provider implements it and
+// AnonymousEnabled is true.
+//
+// A guest session belongs to a placeholder user with UserStatusAnonymous,
+// so carts, onboarding answers and session values can hang off it. Signing
+// up with the guest session's token (SignUpInput.AnonymousToken) turns that
+// user into the new account and keeps the session ID, under a fresh token.
+type AnonymousSessionProvider interface {
+	AnonymousEnabled() bool
+	// CreateAnonymous creates an anonymous user and a session for it.
+	// Guest sessions get no refresh token; they end at their expiry.
+	CreateAnonymous(ctx context.Context, ipAddress, userAgent string) (*SignUpResult, error)
+}
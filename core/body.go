@@ -0,0 +1,50 @@
+package core
+
+import "io"
+
+// DefaultMaxBodySize is the request body limit of the auth endpoints unless
+// an adapter is configured otherwise. It matches Fiber's default body limit.
+const DefaultMaxBodySize int64 = 4 << 20
+
+// LimitBody caps body at limit bytes: reading past the limit fails with
+// ErrBodyTooLarge, so decoding an oversized payload stops there instead of
+// buffering it whole. Adapters wrap request bodies sent without a
+// Content-Length with it; declared lengths over the limit are refused before
+// reading.
+func LimitBody(body io.ReadCloser, limit int64) io.ReadCloser {
+	return &limitedBody{body: body, remaining: limit}
+}
+
+type limitedBody struct {
+	body      io.ReadCloser
+	remaining int64
+	err       error
+}
+
+func (b *limitedBody) Read(p []byte) (int, error) {
+	if b.err != nil {
+		return 0, b.err
+	}
+	if len(p) == 0 {
+		return 0, nil
+	}
+	// Read one byte past the limit to tell a body of exactly limit bytes
+	// from a longer one
+	if int64(len(p))-1 > b.remaining {
+		p = p[:b.remaining+1]
+	}
+	n, err := b.body.Read(p)
+	if int64(n) <= b.remaining {
+		b.remaining -= int64(n)
+		b.err = err
+		return n, err
+	}
+	n = int(b.remaining)
+	b.remaining = 0
+	b.err = ErrBodyTooLarge
+	return n, b.err
+}
+
+func (b *limitedBody) Close() error {
+	return b.body.Close()
+}
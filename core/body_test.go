@@ -0,0 +1,44 @@
+package core
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+// Requirement: LimitBody passes bodies up to the limit through whole and
+// fails with ErrBodyTooLarge once a body goes over it.
+func TestLimitBody(t *testing.T) {
+	tests := []struct {
+		name    string
+		body    string
+		limit   int64
+		want    string
+		wantErr error
+	}{
+		{name: "under the limit", body: "abc", limit: 4, want: "abc"},
+		{name: "exactly the limit", body: "abcd", limit: 4, want: "abcd"},
+		{name: "over the limit", body: "abcde", limit: 4, want: "abcd", wantErr: ErrBodyTooLarge},
+		{name: "empty body", body: "", limit: 0, want: ""},
+		{name: "zero limit", body: "a", limit: 0, want: "", wantErr: ErrBodyTooLarge},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// Arrange
+			body := LimitBody(io.NopCloser(strings.NewReader(test.body)), test.limit)
+
+			// Act
+			got, err := io.ReadAll(body)
+
+			// Assert
+			if !errors.Is(err, test.wantErr) {
+				t.Fatalf("ReadAll() error = %v, want %v", err, test.wantErr)
+			}
+			if string(got) != test.want {
+				t.Errorf("ReadAll() = %q, want %q", got, test.want)
+			}
+		})
+	}
+}
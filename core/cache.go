@@ -34,3 +34,19 @@ type CacheStats struct {
 	Size      int           `json:"size"`
 	TTL       time.Duration `json:"ttl"`
 }
+
+// ConsistencyStats counts sampled comparisons of cached sessions against
+// storage. Repairs are cache entries that were stale (e.g. the session was
+// revoked elsewhere) and got evicted or refreshed.
+type ConsistencyStats struct {
+	Checks  int64 `json:"consistencyChecks"`
+	Repairs int64 `json:"consistencyRepairs"`
+}
+
+// SessionCodec serializes sessions for caches that store bytes outside the
+// process (Redis, memcached, ...). Unlike Session's JSON form, encodings
+// must keep TokenHash.
+type SessionCodec interface {
+	Marshal(session *Session) ([]byte, error)
+	Unmarshal(data []byte) (*Session, error)
+}
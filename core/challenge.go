@@ -0,0 +1,73 @@
+package core
+
+import (
+	"context"
+	"time"
+)
+
+// Built-in challenge types. Custom SignInChallengers may use their own.
+const (
+	ChallengeTwoFactor     = "two_factor"
+	ChallengeDeviceTrust   = "device_trust"
+	ChallengeCaptcha       = "captcha"
+	ChallengePasswordReset = "password_reset"
+)
+
+// AuthChallenge is a step the client must complete before a sign-in issues a
+// session. The client answers it by sending Token and its response to
+// ContinueSignIn, which returns either the next challenge or the session.
+type AuthChallenge struct {
+	Type      string    `json:"type"`
+	Token     string    `json:"token"` // The raw challenge token (not the hash)
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+// SignInAttempt describes the sign-in a challenge is being considered for
+type SignInAttempt struct {
+	IPAddress string
+	UserAgent string
+	// Fingerprint identifies the client (see pkg/fingerprint), e.g. so a
+	// device trust challenge can recognise devices seen before
+	Fingerprint string
+}
+
+// SignInChallenger is one step of a multi-step sign-in (2FA, device trust,
+// CAPTCHA, forced password reset, ...). Challengers run in the order they
+// are configured, after the password has been verified.
+type SignInChallenger interface {
+	// Type identifies the challenge to clients, e.g. ChallengeTwoFactor
+	Type() string
+	// Required reports whether user must pass this challenge to sign in
+	Required(ctx context.Context, user *User, attempt SignInAttempt) (bool, error)
+	// Verify checks the client's response, returning ErrChallengeFailed
+	// when it is wrong
+	Verify(ctx context.Context, user *User, response string) error
+}
+
+// PendingSignIn is a sign-in whose password was verified but which still has
+// challenges to pass
+type PendingSignIn struct {
+	UserID     string
+	ProviderID string   // What the user signed in with, for the sign-in log
+	Challenges []string // Remaining challenge types, current first
+	Attempts   int      // Failed responses to the current challenge
+	IPAddress  string
+	UserAgent  string
+	PublicKey  string
+	ExpiresAt  time.Time
+}
+
+// PendingSignInStore keeps pending sign-ins between steps, keyed by the hash
+// of their challenge token. Implementations backed by a shared cache let a
+// sign-in continue on another instance.
+type PendingSignInStore interface {
+	SavePendingSignIn(tokenHash string, pending *PendingSignIn) error
+	// TakePendingSignIn returns and removes a pending sign-in, or
+	// ErrChallengeNotFound when there is none or it expired
+	TakePendingSignIn(tokenHash string) (*PendingSignIn, error)
+}
+
+type ContinueSignInInput struct {
+	ChallengeToken string
+	Response       string
+}
@@ -0,0 +1,24 @@
+package core
+
+import "time"
+
+// Jobs run by the cleanup worker, as named in CleanupStats
+const (
+	CleanupExpiredSessions      = "expired_sessions"
+	CleanupExpiredRefreshTokens = "expired_refresh_tokens"
+	CleanupVerificationTokens   = "verification_tokens"
+	CleanupDeletedUsers         = "deleted_users"
+	CleanupSignInRecords        = "sign_in_records"
+	CleanupAnonymousUsers       = "anonymous_users"
+)
+
+// CleanupStats counts the work done by one cleanup job
+type CleanupStats struct {
+	Job      string `json:"job"`
+	Runs     int64  `json:"runs"`
+	Failures int64  `json:"failures"`
+	// Purged is the total number of rows removed
+	Purged    int64     `json:"purged"`
+	LastRun   time.Time `json:"lastRun"`
+	LastError string    `json:"lastError,omitempty"`
+}
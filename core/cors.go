@@ -0,0 +1,51 @@
+package core
+
+import (
+	"fmt"
+	"net/url"
+	"time"
+)
+
+// CORSConfig describes which browser origins may call the auth endpoints.
+// Adapters apply it to the auth route group only.
+//
+// Whether credentials (cookies) are allowed is not configured here: adapters
+// derive it from their cookie settings, so the two can't drift apart.
+type CORSConfig struct {
+	// AllowedOrigins lists exact origins such as "https://app.example.com",
+	// without a trailing "/" since browsers never send one. "*" allows any
+	// origin, which is rejected when cookies are in use.
+	AllowedOrigins []string
+
+	// AllowedHeaders are request headers allowed in addition to the ones
+	// kuta itself reads (Authorization, Content-Type, proof header).
+	AllowedHeaders []string
+
+	// MaxAge is how long browsers may cache preflight responses
+	MaxAge time.Duration
+}
+
+// Validate reports configurations browsers would reject or that would expose
+// credentialed endpoints to every origin.
+func (c CORSConfig) Validate(credentials bool) error {
+	if len(c.AllowedOrigins) == 0 {
+		return fmt.Errorf("%w: no allowed origins", ErrInvalidCORSConfig)
+	}
+
+	for _, origin := range c.AllowedOrigins {
+		if origin == "*" {
+			if credentials {
+				return fmt.Errorf("%w: wildcard origin cannot be used with cookies", ErrInvalidCORSConfig)
+			}
+			continue
+		}
+
+		u, err := url.Parse(origin)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" ||
+			u.Path != "" || u.RawQuery != "" || u.ForceQuery || u.Fragment != "" {
+			return fmt.Errorf("%w: %q is not an origin (scheme://host[:port])", ErrInvalidCORSConfig, origin)
+		}
+	}
+
+	return nil
+}
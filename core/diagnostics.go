@@ -0,0 +1,75 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Areas a Diagnostic can be about
+const (
+	DiagnosticSecret   = "secret"
+	DiagnosticDatabase = "database"
+	DiagnosticCache    = "cache"
+	DiagnosticHTTP     = "http"
+	DiagnosticCookie   = "cookie"
+	DiagnosticCORS     = "cors"
+	DiagnosticSession  = "session"
+	DiagnosticIDs      = "ids"
+)
+
+// DiagnosticSeverity tells whether a Diagnostic stops kuta from starting
+type DiagnosticSeverity string
+
+const (
+	// SeverityError is a problem New would fail on, or one that breaks auth
+	// at runtime, e.g. an unreachable database
+	SeverityError DiagnosticSeverity = "error"
+	// SeverityWarning is a setting that works but is likely a mistake
+	SeverityWarning DiagnosticSeverity = "warning"
+)
+
+// Diagnostic is one finding of a config check
+type Diagnostic struct {
+	Check    string             `json:"check"`
+	Severity DiagnosticSeverity `json:"severity"`
+	Message  string             `json:"message"`
+}
+
+// DiagnosticsReport is the outcome of a config dry run
+type DiagnosticsReport struct {
+	Diagnostics []Diagnostic `json:"diagnostics"`
+}
+
+// Add records a finding
+func (r *DiagnosticsReport) Add(check string, severity DiagnosticSeverity, format string, args ...any) {
+	r.Diagnostics = append(r.Diagnostics, Diagnostic{Check: check, Severity: severity, Message: fmt.Sprintf(format, args...)})
+}
+
+// OK reports whether the report has no errors. Warnings don't count.
+func (r DiagnosticsReport) OK() bool {
+	return r.Err() == nil
+}
+
+// Err joins the error findings into one error, or returns nil if there are
+// none, e.g. to fail a CI step
+func (r DiagnosticsReport) Err() error {
+	var errs []error
+	for _, d := range r.Diagnostics {
+		if d.Severity == SeverityError {
+			errs = append(errs, fmt.Errorf("%s: %s", d.Check, d.Message))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Pinger is implemented by storage and cache providers that can check their
+// backend is reachable
+type Pinger interface {
+	Ping() error
+}
+
+// ConfigDiagnoser is implemented by HTTP adapters that can check their own
+// settings, such as cookie and CORS options, for a config dry run
+type ConfigDiagnoser interface {
+	DiagnoseConfig() []Diagnostic
+}
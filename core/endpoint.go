@@ -1,5 +1,12 @@
 package core
 
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/url"
+)
+
 // EndpointProvider provides a list of endpoints to register dynamically
 type EndpointProvider interface {
 	GetEndpoints() []Endpoint
@@ -12,6 +19,40 @@ type Endpoint struct {
 	Metadata EndpointMetadata
 }
 
+// OperationIDs of the built-in endpoints, used to select endpoints in route
+// groups and payload transforms
+const (
+	OperationSignUp                   = "signUpWithEmailAndPassword"
+	OperationSignIn                   = "signInWithEmailAndPassword"
+	OperationContinueSignIn           = "continueSignIn"
+	OperationSignOut                  = "signOut"
+	OperationGetSession               = "getSession"
+	OperationRefreshToken             = "refreshToken"
+	OperationAdminListSessions        = "adminListSessions"
+	OperationAdminRevokeSessions      = "adminRevokeSessions"
+	OperationListProviders            = "listProviders"
+	OperationOAuthSignIn              = "signInWithOAuth"
+	OperationOAuthCallback            = "oauthCallback"
+	OperationOAuthCallbackPost        = "oauthCallbackFormPost"
+	OperationListActivity             = "listActivity"
+	OperationSwitchOrganization       = "switchOrganization"
+	OperationAcceptOrganizationInvite = "acceptOrganizationInvite"
+	OperationListAccessTokens         = "listAccessTokens"
+	OperationCreateAccessToken        = "createAccessToken"
+	OperationRevokeAccessToken        = "revokeAccessToken"
+	OperationChangePassword           = "changePassword"
+	OperationListAccounts             = "listAccounts"
+	OperationUnlinkAccount            = "unlinkAccount"
+	OperationLinkOAuthAccount         = "linkOAuthAccount"
+	OperationSetupTOTP                = "setupTOTP"
+	OperationVerifyTOTP               = "verifyTOTP"
+	OperationSignInAnonymous          = "signInAnonymously"
+	OperationForgotPassword           = "forgotPassword"
+	OperationResetPassword            = "resetPassword"
+	OperationResendVerification       = "resendVerification"
+	OperationVerifyEmail              = "verifyEmail"
+)
+
 type EndpointMetadata struct {
 	OperationID string
 	Description string
@@ -19,10 +60,66 @@ type EndpointMetadata struct {
 	Responses   map[int]interface{}
 }
 
+// RouteGroup mounts endpoints under a path prefix with its own middleware,
+// so the same endpoints can be exposed under several prefixes
+// (e.g. public "/api/auth" and "/internal/auth" behind an extra check).
+type RouteGroup struct {
+	Prefix string
+
+	// Middleware runs before every endpoint in the group. Values are
+	// framework-specific (e.g. fiber.Handler) and checked by the adapter.
+	Middleware []interface{}
+
+	// Include limits the group to these OperationIDs. Empty means all.
+	Include []string
+}
+
+// Request reads the incoming request the same way in every adapter
+type Request interface {
+	Method() string
+	// Path is the URL path, without the query string
+	Path() string
+	// Query is the parsed query string
+	Query() url.Values
+	// PathValue returns the value of the endpoint path's ":name" parameter
+	PathValue(name string) string
+	// Header returns the first value of the named header, or ""
+	Header(name string) string
+	// Body reads the request body. It can be read once.
+	Body() io.Reader
+	// ClientIP is the client's address, read from forwarding headers only
+	// when the adapter trusts the proxy that sent them
+	ClientIP() string
+	UserAgent() string
+	// Cookie returns the value of the named cookie, or ""
+	Cookie(name string) string
+}
+
+// Response writes the response the same way in every adapter. Headers and
+// cookies must be set before the body is written.
+type Response interface {
+	SetHeader(name, value string)
+	SetCookie(cookie *http.Cookie)
+	// Status sets the status code, 200 unless set. A response without a
+	// body is sent with it once the handler returns.
+	Status(code int)
+	// JSON writes v as the JSON body
+	JSON(v any) error
+}
+
 type RequestContext struct {
-	// Framework-agnostic context
-	Request interface{} // could be *http.Request, fiber.Ctx, etc
-	Auth    AuthProvider
+	// Request is the incoming request
+	Request Request
+	// Response is the response to it
+	Response Response
+	// Native is the framework's own request object: *stdhttp.Exchange,
+	// fiber.Ctx or *gin.Context, for what Request and Response don't cover
+	Native any
+	Auth   AuthProvider
+
+	// Context is the request's context. Handlers pass it to every Auth call
+	// so storage queries stop when the client goes away.
+	Context context.Context
 }
 
 // ErrorResponse represents an error response structure
@@ -30,4 +127,6 @@ type ErrorResponse struct {
 	Error   string `json:"error"`
 	Message string `json:"message,omitempty"`
 	Code    int    `json:"code,omitempty"`
+	// Errors lists the invalid fields of a validation error
+	Errors []FieldError `json:"errors,omitempty"`
 }
@@ -0,0 +1,34 @@
+package core
+
+import "slices"
+
+// Entitlements are what the user's plan allows. They are resolved by the
+// app's EntitlementResolver when a session is issued or refreshed and kept
+// with the session, so APIs can authorize by plan without a billing lookup
+// per request.
+type Entitlements struct {
+	Plan string `json:"plan,omitempty"`
+	// Features are the feature flags the plan enables
+	Features []string `json:"features,omitempty"`
+}
+
+// HasFeature reports whether feature is enabled. It is false for nil
+// Entitlements, so sessions without any can be checked directly.
+func (e *Entitlements) HasFeature(feature string) bool {
+	return e != nil && slices.Contains(e.Features, feature)
+}
+
+// EntitlementResolver resolves the entitlements of a session's user, e.g.
+// from the app's billing records. session is the one being issued, with
+// ActiveOrganizationID set when it has one, for plans held by organizations.
+// Returning an error refuses the session.
+type EntitlementResolver interface {
+	ResolveEntitlements(user *User, session *Session) (*Entitlements, error)
+}
+
+// EntitlementResolverFunc adapts a plain function to EntitlementResolver
+type EntitlementResolverFunc func(user *User, session *Session) (*Entitlements, error)
+
+func (f EntitlementResolverFunc) ResolveEntitlements(user *User, session *Session) (*Entitlements, error) {
+	return f(user, session)
+}
@@ -0,0 +1,80 @@
+package core
+
+// ResponseEnvelope shapes the JSON bodies of auth endpoint responses, so they
+// can follow an app's existing API conventions. HTTP adapters pass every
+// response through it.
+type ResponseEnvelope interface {
+	// Success wraps the body of a successful response
+	Success(status int, data any) any
+	// Failure builds the body of an error response
+	Failure(status int, message string) any
+}
+
+// FieldFailureEnvelope is implemented by envelopes that report the field
+// errors of a ValidationError. Envelopes without it only get the message.
+type FieldFailureEnvelope interface {
+	// FieldFailure builds the body of a validation error response
+	FieldFailure(status int, message string, fields []FieldError) any
+}
+
+// BareEnvelope sends bodies as-is and errors as {"error": "..."}.
+// Adapters use it when no envelope is configured.
+type BareEnvelope struct{}
+
+func (BareEnvelope) Success(_ int, data any) any {
+	return data
+}
+
+func (BareEnvelope) Failure(_ int, message string) any {
+	return ErrorResponse{Error: message}
+}
+
+// FieldFailure sends {"error": "...", "errors": [{field, code, message}]}
+func (BareEnvelope) FieldFailure(_ int, message string, fields []FieldError) any {
+	return ErrorResponse{Error: message, Errors: fields}
+}
+
+// DataEnvelope wraps every body as {"data": ..., "error": ...} with exactly
+// one of the two set.
+type DataEnvelope struct{}
+
+// EnvelopedResponse is the body DataEnvelope produces
+type EnvelopedResponse struct {
+	Data  any            `json:"data"`
+	Error *EnvelopeError `json:"error"`
+}
+
+// EnvelopeError describes a failure inside an EnvelopedResponse
+type EnvelopeError struct {
+	Message string       `json:"message"`
+	Code    int          `json:"code"`
+	Fields  []FieldError `json:"fields,omitempty"`
+}
+
+func (DataEnvelope) Success(_ int, data any) any {
+	return EnvelopedResponse{Data: data}
+}
+
+func (DataEnvelope) Failure(status int, message string) any {
+	return EnvelopedResponse{Error: &EnvelopeError{Message: message, Code: status}}
+}
+
+func (DataEnvelope) FieldFailure(status int, message string, fields []FieldError) any {
+	return EnvelopedResponse{Error: &EnvelopeError{Message: message, Code: status, Fields: fields}}
+}
+
+// ErrorBody returns the status and enveloped body of an error response,
+// mapping err through ErrorStatus. Validation errors carry their field errors
+// when the envelope supports them. A nil envelope means BareEnvelope.
+func ErrorBody(envelope ResponseEnvelope, err error) (int, any) {
+	if envelope == nil {
+		envelope = BareEnvelope{}
+	}
+	status := ErrorStatus(err)
+	if fields := FieldErrors(err); fields != nil {
+		if fieldEnvelope, ok := envelope.(FieldFailureEnvelope); ok {
+			return status, fieldEnvelope.FieldFailure(status, err.Error(), fields)
+		}
+	}
+	return status, envelope.Failure(status, err.Error())
+}
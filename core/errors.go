@@ -5,9 +5,37 @@ import "errors"
 // Authentication Related Errors
 var (
 	// User errors
-	ErrUserExists         = errors.New("user already exists")       // 409 Conflict
-	ErrUserNotFound       = errors.New("user not found")            // 404 Not Found
-	ErrInvalidCredentials = errors.New("invalid email or password") // 401 Unauthorized
+	ErrUserExists         = errors.New("user already exists")                               // 409 Conflict
+	ErrUserNotFound       = errors.New("user not found")                                    // 404 Not Found
+	ErrInvalidCredentials = errors.New("invalid email or password")                         // 401 Unauthorized
+	ErrTooManyAttempts    = errors.New("too many failed sign-in attempts, try again later") // 429 Too Many Requests
+	ErrRateLimited        = errors.New("too many requests, try again later")                // 429 Too Many Requests
+
+	ErrUserSecurityNotFound = errors.New("user security settings not found")
+
+	ErrApprovalPending  = errors.New("account is awaiting approval")           // 403 Forbidden
+	ErrProviderDisabled = errors.New("sign-in with this provider is disabled") // 403 Forbidden
+	ErrAccountRejected  = errors.New("account was not approved")               // 403 Forbidden
+
+	ErrChallengeNotFound = errors.New("sign-in challenge not found or expired") // 401
+	ErrChallengeFailed   = errors.New("sign-in challenge failed")               // 401
+
+	ErrUnknownProvider   = errors.New("unknown sign-in provider")            // 404
+	ErrInvalidOAuthState = errors.New("sign-in state is invalid or expired") // 400
+	ErrOAuthFailed       = errors.New("sign-in with the provider failed")    // 401
+	// ErrMembershipRequired refuses users outside the groups an
+	// AfterSignInHook requires
+	ErrMembershipRequired = errors.New("not a member of a required group") // 403
+
+	ErrAccountNotFound = errors.New("account not found")                          // 404
+	ErrAccountLinked   = errors.New("provider account is linked to another user") // 409
+	ErrLastAccount     = errors.New("the last way to sign in can't be unlinked")  // 409
+
+	ErrEmailAlreadyVerified = errors.New("email is already verified") // 409
+
+	ErrTwoFactorEnabled     = errors.New("two-factor authentication is already enabled") // 409
+	ErrTOTPNotSetUp         = errors.New("authenticator app is not set up")              // 404
+	ErrInvalidTwoFactorCode = errors.New("invalid two-factor code")                      // 400
 )
 
 // Session errors
@@ -17,26 +45,76 @@ var (
 	ErrSessionNotFound   = errors.New("session not found")            // 401
 	ErrSessionExpired    = errors.New("session expired")              // 401
 	ErrCacheNotFound     = errors.New("session not found in cache")
+	ErrProofRequired     = errors.New("proof of possession required")                // 401
+	ErrInvalidProof      = errors.New("invalid proof of possession")                 // 401
+	ErrSessionDraining   = errors.New("session revoked, read-only until it expires") // 401
+
+	ErrRefreshTokenNotFound      = errors.New("refresh token not found")                       // 401
+	ErrVerificationTokenNotFound = errors.New("verification token not found, used or expired") // 400
+	ErrBatchTooLarge             = errors.New("too many tokens in batch")                      // 400
+	ErrAccessTokenNotFound       = errors.New("access token not found")                        // 404
+	ErrAccessTokenExpired        = errors.New("access token expired")                          // 401
+)
+
+// Idempotency errors
+var (
+	// ErrIdempotencyKeyReused refuses a request whose Idempotency-Key was
+	// used for a different request
+	ErrIdempotencyKeyReused = errors.New("idempotency key was used for a different request") // 422
+	// ErrIdempotencyInProgress answers a retry that arrives while the first
+	// request with its key is still being handled
+	ErrIdempotencyInProgress = errors.New("a request with this idempotency key is in progress") // 409
+	ErrInvalidIdempotencyKey = errors.New("invalid idempotency key")                            // 400
+)
+
+// Authorization errors
+var (
+	ErrForbidden = errors.New("forbidden") // 403
+	// ErrImpersonating is for actions an impersonated session may not take;
+	// see SessionData.Impersonated
+	ErrImpersonating = errors.New("not allowed while impersonating") // 403
+	// ErrAnonymousUser refuses managing the account of a guest session,
+	// whose user is purged once its sessions end; see User.Anonymous
+	ErrAnonymousUser = errors.New("not allowed for guest users, sign up first") // 403
+	// ErrNotOrganizationMember refuses switching to, or acting in, an
+	// organization the user doesn't belong to
+	ErrNotOrganizationMember = errors.New("not a member of the organization") // 403
 )
 
 // Validation errors (client input)
 var (
-	ErrInvalidAuthHeader = errors.New("invalid authorization format, expected 'Bearer <token>'") // 401
-	ErrEmailRequired     = errors.New("email is required")                                       // 400
-	ErrPasswordRequired  = errors.New("password is required")                                    // 400
-	ErrPasswordTooShort  = errors.New("password is too short")                                   // 400
-	ErrPasswordTooLong   = errors.New("password is too long")                                    // 400
-	ErrInvalidEmail      = errors.New("invalid email format")                                    // 400
+	ErrInvalidAuthHeader    = errors.New("invalid authorization format, expected 'Bearer <token>'") // 401
+	ErrBodyTooLarge         = errors.New("request body too large")                                  // 413
+	ErrEmailRequired        = errors.New("email is required")                                       // 400
+	ErrPasswordRequired     = errors.New("password is required")                                    // 400
+	ErrPasswordTooShort     = errors.New("password is too short")                                   // 400
+	ErrPasswordTooLong      = errors.New("password is too long")                                    // 400
+	ErrInvalidEmail         = errors.New("invalid email format")                                    // 400
+	ErrInvalidPublicKey     = errors.New("invalid public key")                                      // 400
+	ErrInvalidImage         = errors.New("invalid image")                                           // 400
+	ErrInvalidSessionValue  = errors.New("invalid session value")                                   // 400
+	ErrRoleRequired         = errors.New("role is required")                                        // 400
+	ErrOrganizationRequired = errors.New("organization is required")                                // 400
+	ErrNameRequired         = errors.New("name is required")                                        // 400
+	ErrInvalidScope         = errors.New("invalid scope")                                           // 400
+	ErrInvalidExpiry        = errors.New("expiry must be in the future")                            // 400
+	// ErrValidationFailed is matched by every ValidationError
+	ErrValidationFailed = errors.New("validation failed") // 400
 )
 
 // Config errors (server-side configuration)
 var (
-	ErrDBAdapterRequired   = errors.New("database adapter is required") // 500
-	ErrHTTPAdapterRequired = errors.New("adapter is required")          // 500
-	ErrSecretRequired      = errors.New("secret is required")           // 500
-	ErrSecretTooShort      = errors.New("secret too short")             // 500
+	ErrDBAdapterRequired   = errors.New("database adapter is required")  // 500
+	ErrHTTPAdapterRequired = errors.New("adapter is required")           // 500
+	ErrSecretRequired      = errors.New("secret is required")            // 500
+	ErrSecretTooShort      = errors.New("secret too short")              // 500
+	ErrInvalidCORSConfig   = errors.New("invalid CORS configuration")    // 500
+	ErrInvalidCacheConfig  = errors.New("invalid cache configuration")   // 500
+	ErrInvalidIDConfig     = errors.New("invalid ID configuration")      // 500
+	ErrStorageUnsupported  = errors.New("storage does not support this") // 500
 )
 
 var (
-	ErrNotImplemented = errors.New("not implemented") // 501
+	ErrNotImplemented = errors.New("not implemented")               // 501
+	ErrServiceBusy    = errors.New("service busy, try again later") // 503
 )
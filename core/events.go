@@ -0,0 +1,114 @@
+package core
+
+import "time"
+
+// EventType identifies an authentication event
+type EventType string
+
+const (
+	EventUserSignedUp     EventType = "user.signed_up"
+	EventSignUpEmailTaken EventType = "user.sign_up_email_taken" // sign-up attempted with a registered email
+	EventUserSignedIn     EventType = "user.signed_in"
+	EventUserSignInFailed EventType = "user.sign_in_failed"
+	EventSignInLockedOut  EventType = "user.sign_in_locked_out" // email + IP pair hit the throttle lockout
+	EventSignInChallenged EventType = "user.sign_in_challenged" // Metadata["challenge"] is the challenge type
+	EventPasswordChanged  EventType = "user.password_changed"
+
+	// EventPasswordResetRequested and EventVerificationRequested report
+	// what ForgotPassword and ResendVerification did, whatever they
+	// responded: Metadata["outcome"] is one of the EmailRequest* constants.
+	// EventPasswordReset and EventEmailVerified follow the tokens' use.
+	EventPasswordResetRequested EventType = "user.password_reset_requested"
+	EventPasswordReset          EventType = "user.password_reset"
+	EventVerificationRequested  EventType = "user.verification_requested"
+	EventEmailVerified          EventType = "user.email_verified"
+
+	// Approval queue decisions. Metadata["actorUserId"] is the admin who
+	// decided, Metadata["reason"] the reason given for a rejection.
+	EventUserPendingApproval EventType = "user.pending_approval"
+	EventUserApproved        EventType = "user.approved"
+	EventUserRejected        EventType = "user.rejected"
+
+	// EventImpersonationStarted is emitted when an admin opens a session as
+	// another user: UserID is the impersonated user, Metadata["actorUserId"]
+	// the admin
+	EventImpersonationStarted EventType = "session.impersonation_started"
+
+	// EventSessionRevoked records why sessions were ended before expiring:
+	// Metadata["reason"] is one of the RevokeReason* constants. Bulk
+	// revocations set UserID and Metadata["count"] instead of SessionID.
+	EventSessionRevoked EventType = "session.revoked"
+
+	// EventAccountLinked and EventAccountUnlinked report accounts added to
+	// and removed from a user; Metadata["providerId"] is the account's
+	// provider
+	EventAccountLinked   EventType = "account.linked"
+	EventAccountUnlinked EventType = "account.unlinked"
+
+	// EventTwoFactorEnabled is emitted when a user verifies their
+	// authenticator app. EventBackupCodeUsed reports a backup code answering
+	// a sign-in challenge; Metadata["remaining"] is how many are left.
+	EventTwoFactorEnabled EventType = "user.two_factor_enabled"
+	EventBackupCodeUsed   EventType = "user.backup_code_used"
+
+	// EventAnonymousSignedIn is emitted when a guest session is created.
+	// Signing up from one emits EventUserSignedUp with its SessionID and
+	// Metadata["upgraded"] set.
+	EventAnonymousSignedIn EventType = "user.anonymous_signed_in"
+
+	EventProviderTokenRefreshFailed EventType = "account.provider_token_refresh_failed"
+	// EventProviderTokenRevokeFailed reports provider tokens that could not
+	// be revoked when their account went away, so the grant may need
+	// removing at the provider by hand
+	EventProviderTokenRevokeFailed EventType = "account.provider_token_revoke_failed"
+
+	// EventSecurityAnomaly flags suspicious activity such as sign-ins from
+	// too many places at once; Metadata["reason"] says which check fired
+	EventSecurityAnomaly EventType = "security.anomaly"
+)
+
+// Reasons reported with EventSessionRevoked
+const (
+	RevokeReasonSignOut        = "sign_out"
+	RevokeReasonAdmin          = "admin_revoke"
+	RevokeReasonPasswordChange = "password_change"
+	RevokeReasonUserDeleted    = "user_deleted"
+	// RevokeReasonRefreshReuse means a spent refresh token was presented
+	// again, so every session and refresh token of the user was revoked
+	RevokeReasonRefreshReuse = "refresh_token_reuse"
+	// RevokeReasonDeviceLimit means a sign-in went past
+	// SessionConfig.MaxRefreshDevices and the oldest device was signed out
+	RevokeReasonDeviceLimit = "device_limit"
+	// RevokeReasonUnspecified is used when the application destroys
+	// sessions without giving a reason
+	RevokeReasonUnspecified = "unspecified"
+)
+
+// Event describes something that happened during an authentication flow.
+//
+// Events are how kuta reports outcomes it deliberately hides from HTTP
+// responses (e.g. enumeration-safe sign-up), so handlers typically send
+// emails or forward them to audit/SIEM pipelines.
+type Event struct {
+	Type       EventType      `json:"type"`
+	UserID     string         `json:"userId,omitempty"`
+	Email      string         `json:"email,omitempty"`
+	SessionID  string         `json:"sessionId,omitempty"`
+	IPAddress  string         `json:"ipAddress,omitempty"`
+	UserAgent  string         `json:"userAgent,omitempty"`
+	OccurredAt time.Time      `json:"occurredAt"`
+	Metadata   map[string]any `json:"metadata,omitempty"`
+}
+
+// EventHandler receives authentication events.
+// Implementations must be safe for concurrent use and should not block.
+type EventHandler interface {
+	HandleEvent(event Event)
+}
+
+// EventHandlerFunc adapts a plain function to EventHandler
+type EventHandlerFunc func(event Event)
+
+func (f EventHandlerFunc) HandleEvent(event Event) {
+	f(event)
+}
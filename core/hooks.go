@@ -0,0 +1,58 @@
+package core
+
+import (
+	"fmt"
+	"slices"
+)
+
+// AfterSignInInput describes an authenticated sign-in about to be issued a
+// session
+type AfterSignInInput struct {
+	// User is nil for an OAuth sign-in that would create the user, so a
+	// refused first sign-in leaves nothing behind
+	User       *User
+	ProviderID string
+	// Identity is what the provider reported, for OAuth sign-ins
+	Identity  *OAuthIdentity
+	IPAddress string
+	UserAgent string
+}
+
+// AfterSignInHook runs once a user has authenticated, before challenges and
+// before the session is issued. Returning an error refuses the sign-in with
+// that error; wrap ErrForbidden or ErrMembershipRequired to answer 403.
+type AfterSignInHook func(input AfterSignInInput) error
+
+// RequireMembership refuses sign-ins with providerID unless the user belongs
+// to one of groupIDs there, e.g. a Discord guild or Slack workspace. Sign-ins
+// with other providers pass.
+func RequireMembership(providerID string, groupIDs ...string) AfterSignInHook {
+	return func(input AfterSignInInput) error {
+		if input.ProviderID != providerID {
+			return nil
+		}
+		if input.Identity != nil && slices.ContainsFunc(groupIDs, func(id string) bool {
+			return input.Identity.Membership(id) != nil
+		}) {
+			return nil
+		}
+		return fmt.Errorf("%w at %s", ErrMembershipRequired, providerID)
+	}
+}
+
+// RequireRole refuses sign-ins with providerID unless the user has one of
+// roleIDs in groupID there, e.g. a Discord guild role
+func RequireRole(providerID, groupID string, roleIDs ...string) AfterSignInHook {
+	return func(input AfterSignInInput) error {
+		if input.ProviderID != providerID {
+			return nil
+		}
+		if input.Identity != nil {
+			if membership := input.Identity.Membership(groupID); membership != nil &&
+				slices.ContainsFunc(membership.Roles, func(role string) bool { return slices.Contains(roleIDs, role) }) {
+				return nil
+			}
+		}
+		return fmt.Errorf("%w at %s", ErrMembershipRequired, providerID)
+	}
+}
@@ -0,0 +1,21 @@
+package core
+
+// IDConfig controls how IDs for one kind of entity are generated.
+// Zero values keep the defaults: 22 characters from A-Za-z0-9_-.
+//
+// The bundled Postgres migrations constrain IDs to that default format
+// (the public.nanoid domain), so changing either field needs a matching
+// schema change.
+type IDConfig struct {
+	// Alphabet is the set of ASCII characters IDs are drawn from (8 to 255).
+	Alphabet string
+	// Length is the number of characters per ID.
+	Length int
+}
+
+// EntityIDConfig configures ID generation separately per entity
+type EntityIDConfig struct {
+	User    IDConfig
+	Session IDConfig
+	Account IDConfig
+}
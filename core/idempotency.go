@@ -0,0 +1,58 @@
+package core
+
+import (
+	"net/http"
+	"time"
+)
+
+// IdempotencyKeyHeader names the request header clients set to make a retry
+// of sign-up, sign-in or refresh return the first attempt's response instead
+// of running again
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+// MaxIdempotencyKeyLength bounds Idempotency-Key values, in bytes
+const MaxIdempotencyKeyLength = 255
+
+// IdempotentOperations are the OperationIDs that honor IdempotencyKeyHeader:
+// retrying them blindly would create a duplicate user, a second session, or
+// fail because the refresh token was already used
+var IdempotentOperations = []string{OperationSignUp, OperationSignIn, OperationRefreshToken}
+
+// RecordedResponse is a response kept for replaying to retries
+type RecordedResponse struct {
+	Status int         `json:"status"`
+	Header http.Header `json:"header,omitempty"`
+	Body   []byte      `json:"body"`
+}
+
+// IdempotencyStore keeps idempotency keys and the responses recorded for
+// them. Keys are hashes and values are sealed, so neither reveals tokens.
+// Implementations backed by a shared cache (Redis, memcached, ...) make
+// retries replay across instances; Reserve must then be atomic.
+type IdempotencyStore interface {
+	// Reserve claims key for ttl when it is free, returning "". Otherwise it
+	// returns the value recorded for key, or ErrIdempotencyInProgress while
+	// the key is claimed but nothing was recorded yet.
+	Reserve(key string, ttl time.Duration) (string, error)
+	// Record stores value for key, keeping it for ttl
+	Record(key, value string, ttl time.Duration) error
+	// Release frees a claimed key, so the request can be retried
+	Release(key string) error
+}
+
+// IdempotentRequests deduplicates retried requests. Adapters wrap the
+// IdempotentOperations with it when it is enabled.
+type IdempotentRequests interface {
+	// IdempotencyEnabled reports whether Idempotency-Key is honored
+	IdempotencyEnabled() bool
+	// BeginIdempotent claims key for a request to operationID with body. It
+	// returns the response recorded for an earlier request with the same key
+	// and body, ErrIdempotencyKeyReused when the key came with another body,
+	// and ErrIdempotencyInProgress while the earlier request is still
+	// running. When it returns nil, nil the request should run and then be
+	// passed to FinishIdempotent.
+	BeginIdempotent(operationID, key string, body []byte) (*RecordedResponse, error)
+	// FinishIdempotent records response for retries, or releases the key
+	// when response is nil so a retry runs again
+	FinishIdempotent(operationID, key string, body []byte, response *RecordedResponse) error
+}
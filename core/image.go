@@ -0,0 +1,21 @@
+package core
+
+// ImageUpload is raw image data sent with a sign-up or profile update. In
+// JSON, Data is base64-encoded.
+type ImageUpload struct {
+	ContentType string `json:"contentType,omitempty"`
+	Data        []byte `json:"data"`
+}
+
+// ImageStore persists uploaded user images (S3, GCS, local disk, ...) and
+// returns the URL stored in User.Image. An empty URL leaves User.Image as is.
+type ImageStore interface {
+	StoreImage(userID string, image ImageUpload) (url string, err error)
+}
+
+// NoopImageStore discards uploads; it is used when no ImageStore is configured
+type NoopImageStore struct{}
+
+func (NoopImageStore) StoreImage(string, ImageUpload) (string, error) {
+	return "", nil
+}
@@ -0,0 +1,46 @@
+package core
+
+import "time"
+
+// Email message types. Each is rendered from its own template; see
+// EmailRenderer.
+const (
+	EmailVerification       = "verification"
+	EmailPasswordReset      = "password_reset"
+	EmailSecurityAlert      = "security_alert"
+	EmailOrganizationInvite = "organization_invite"
+)
+
+// EmailData is what email templates are rendered with
+type EmailData struct {
+	AppName string
+	User    *User
+	// URL is the link the email asks the user to follow, e.g. to verify
+	// their address
+	URL string
+	// Code is a one-time code, for apps that don't send links
+	Code      string
+	ExpiresAt time.Time
+	// Details carries values specific to the message type, e.g. the IP
+	// address and user agent of a security alert
+	Details map[string]string
+}
+
+// EmailMessage is a rendered email, ready to send
+type EmailMessage struct {
+	Type    string
+	To      string
+	Subject string
+	HTML    string
+	Text    string // plaintext alternative of HTML
+}
+
+// EmailRenderer turns a message type and its data into an email
+type EmailRenderer interface {
+	RenderEmail(messageType string, data EmailData) (*EmailMessage, error)
+}
+
+// Mailer delivers rendered emails (SMTP, a provider API, a queue, ...)
+type Mailer interface {
+	SendEmail(message *EmailMessage) error
+}
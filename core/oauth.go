@@ -0,0 +1,132 @@
+package core
+
+import (
+	"context"
+	"time"
+)
+
+// OAuthProvider signs users in with an external identity provider. Accounts
+// it creates use ID() as their ProviderID.
+type OAuthProvider interface {
+	// ID names the provider in URLs and accounts, e.g. "apple"
+	ID() string
+	// AuthCodeURL is where the user is sent to sign in. The provider must
+	// send state back unchanged to redirectURI. codeChallenge is the S256
+	// PKCE challenge (RFC 7636) for providers that support it; its verifier
+	// comes back in OAuthCallback.CodeVerifier.
+	AuthCodeURL(state, redirectURI, codeChallenge string) string
+	// Exchange trades the callback for the user's identity at the provider.
+	// Errors are reported to clients as ErrOAuthFailed.
+	Exchange(callback OAuthCallback) (*OAuthIdentity, error)
+}
+
+// OAuthTokenRevoker is implemented by OAuth providers with a token
+// revocation endpoint (RFC 7009). When an account is unlinked or its user
+// deleted, its tokens are revoked so the grant doesn't outlive it.
+type OAuthTokenRevoker interface {
+	// RevokeToken revokes token, which tokenTypeHint says is a
+	// "refresh_token" or an "access_token"
+	RevokeToken(token, tokenTypeHint string) error
+}
+
+// OAuthCallback is what the provider sent to the callback endpoint, as query
+// parameters or, with form_post response mode, as a form
+type OAuthCallback struct {
+	Code  string
+	State string
+	// StateCookie is the state the browser that started the sign-in kept in
+	// its state cookie. It must equal State, so a callback started by
+	// someone else can't sign the browser in to their account.
+	StateCookie string
+	// Error is set when the user denied access or the provider failed
+	Error string
+	// RedirectURI is the callback URL AuthCodeURL was given, which token
+	// endpoints usually want repeated
+	RedirectURI string
+	// CodeVerifier is the PKCE verifier of the challenge AuthCodeURL was
+	// given, for the token request
+	CodeVerifier string
+	// Params holds every parameter received, for providers that send more
+	// than code and state
+	Params map[string]string
+}
+
+// OAuthIdentity is a user as the provider knows them
+type OAuthIdentity struct {
+	// AccountID is the provider's stable ID for the user
+	AccountID     string
+	Email         string
+	EmailVerified bool
+	// Name and Image fill in new users. Empty values never overwrite what
+	// is stored, since some providers only send them on first sign-in.
+	Name  string
+	Image string
+
+	AccessToken  string
+	RefreshToken string
+	ExpiresAt    *time.Time
+	// Profile is kept as the account's ProfileData
+	Profile map[string]any
+
+	// Memberships are the groups the user belongs to at the provider, for
+	// AfterSignIn hooks to gate access on
+	Memberships []OAuthMembership
+}
+
+// OAuthMembership is a group the user belongs to at the provider, such as a
+// Discord guild or Slack workspace
+type OAuthMembership struct {
+	ID   string `json:"id"`
+	Name string `json:"name,omitempty"`
+	// Roles are the user's role IDs in the group, when the provider
+	// reports them
+	Roles []string `json:"roles,omitempty"`
+}
+
+// Membership returns the user's membership of groupID, or nil
+func (i *OAuthIdentity) Membership(groupID string) *OAuthMembership {
+	for j := range i.Memberships {
+		if i.Memberships[j].ID == groupID {
+			return &i.Memberships[j]
+		}
+	}
+	return nil
+}
+
+// OAuthStart is where to send the user to begin signing in with a provider
+type OAuthStart struct {
+	URL   string `json:"url"`
+	State string `json:"state"`
+}
+
+// OAuthState is an OAuth sign-in waiting for the provider's callback
+type OAuthState struct {
+	ProviderID string
+	// CodeVerifier is the PKCE verifier of the challenge sent to the
+	// provider, so a code intercepted on its way back can't be redeemed
+	CodeVerifier string
+	// LinkUserID is the signed-in user the provider account is being linked
+	// to, or empty for a sign-in
+	LinkUserID string
+	ExpiresAt  time.Time
+}
+
+// OAuthStateStore keeps OAuth sign-ins between the redirect to the provider
+// and its callback, keyed by the hash of their state. Implementations backed
+// by a shared cache let the callback land on another instance.
+type OAuthStateStore interface {
+	SaveOAuthState(stateHash string, state *OAuthState) error
+	// TakeOAuthState returns and removes a state, or ErrInvalidOAuthState
+	// when there is none or it expired. Only one of concurrent takes may
+	// succeed, so a callback can't be replayed.
+	TakeOAuthState(stateHash string) (*OAuthState, error)
+}
+
+// OAuthSignIn is implemented by auth providers with OAuth providers
+// configured. Adapters mount the sign-in and callback endpoints when
+// OAuthEnabled reports true.
+type OAuthSignIn interface {
+	OAuthEnabled() bool
+	StartOAuth(providerID string) (*OAuthStart, error)
+	CompleteOAuth(ctx context.Context, providerID string, callback OAuthCallback, ipAddress, userAgent string) (*SignInResult, error)
+}
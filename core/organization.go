@@ -0,0 +1,114 @@
+package core
+
+import (
+	"context"
+	"time"
+)
+
+// OrganizationMember is a user's membership in an organization. kuta only
+// tracks memberships; organizations themselves (names, billing, ...) are
+// kept by the app and referenced by ID.
+type OrganizationMember struct {
+	OrganizationID string `json:"organizationId"`
+	UserID         string `json:"userId"`
+	// Roles are the user's roles within the organization. Their permissions
+	// apply while the organization is the session's active one, on top of
+	// the user's own roles.
+	Roles     []string  `json:"roles"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// PendingOrganizationMember is someone invited to an organization who
+// hasn't accepted yet. The invite is accepted with the verification token
+// (purpose VerificationInvite) whose ID is InviteID.
+type PendingOrganizationMember struct {
+	InviteID       string   `json:"inviteId"`
+	OrganizationID string   `json:"organizationId"`
+	Email          string   `json:"email"`
+	Roles          []string `json:"roles"`
+	// InvitedBy is the user ID of whoever sent the invite, if anyone
+	InvitedBy string    `json:"invitedBy,omitempty"`
+	ExpiresAt time.Time `json:"expiresAt"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// OrganizationStorage persists organization memberships and pending invites
+type OrganizationStorage interface {
+	// SaveOrganizationMember adds the membership, or replaces the roles of
+	// an existing one
+	SaveOrganizationMember(ctx context.Context, member *OrganizationMember) error
+	// RemoveOrganizationMember ends the membership. Removing a user who
+	// isn't a member is not an error.
+	RemoveOrganizationMember(ctx context.Context, organizationID, userID string) error
+	// GetOrganizationMember returns ErrNotOrganizationMember when the user
+	// isn't a member of the organization
+	GetOrganizationMember(ctx context.Context, organizationID, userID string) (*OrganizationMember, error)
+	// ListUserOrganizations returns the user's memberships, oldest first
+	ListUserOrganizations(ctx context.Context, userID string) ([]*OrganizationMember, error)
+
+	// SavePendingOrganizationMember stores an invite
+	SavePendingOrganizationMember(ctx context.Context, pending *PendingOrganizationMember) error
+	// ActivatePendingOrganizationMember turns the pending member of inviteID
+	// into a membership of userID in one step, replacing the roles of an
+	// existing membership. It returns ErrVerificationTokenNotFound when the
+	// invite is gone.
+	ActivatePendingOrganizationMember(ctx context.Context, inviteID, userID string) (*OrganizationMember, error)
+	// ListPendingOrganizationMembers returns the organization's unexpired
+	// invites, oldest first
+	ListPendingOrganizationMembers(ctx context.Context, organizationID string) ([]*PendingOrganizationMember, error)
+	// DeletePendingOrganizationMember withdraws an invite. Deleting an
+	// unknown invite is not an error.
+	DeletePendingOrganizationMember(ctx context.Context, inviteID string) error
+}
+
+// InviteOrganizationMemberInput invites Email to an organization
+type InviteOrganizationMemberInput struct {
+	OrganizationID string
+	Email          string
+	Roles          []string
+	// InvitedBy is the user ID of whoever sends the invite, if anyone
+	InvitedBy string
+}
+
+// OrganizationInviteResult is an issued invite. Token is the raw invite
+// token, already emailed when a Mailer is configured.
+type OrganizationInviteResult struct {
+	Pending *PendingOrganizationMember `json:"pending"`
+	Token   string                     `json:"-"`
+}
+
+// AcceptOrganizationInviteInput accepts an invite. Name and Password are
+// only used when the invited email has no account yet: the account is
+// created with them, and without a Password it has no credential sign-in.
+type AcceptOrganizationInviteInput struct {
+	Token    string
+	Name     string
+	Password string
+}
+
+// AcceptOrganizationInviteResult is an accepted invite. Users whose account
+// was created by accepting are signed in, with Session, Token and
+// RefreshToken set.
+type AcceptOrganizationInviteResult struct {
+	Member       *OrganizationMember `json:"member"`
+	User         *User               `json:"user"`
+	Session      *Session            `json:"session,omitempty"`
+	Token        string              `json:"token,omitempty"`
+	RefreshToken string              `json:"refreshToken,omitempty"`
+}
+
+// OrganizationProvider switches the active organization of sessions and
+// accepts organization invites. Adapters mount its endpoints when it is
+// enabled.
+type OrganizationProvider interface {
+	// OrganizationsEnabled reports whether the organization endpoints
+	// should be mounted
+	OrganizationsEnabled() bool
+	// SwitchOrganization makes organizationID the active organization of the
+	// token's session, or clears it when organizationID is empty. The user
+	// must be a member of the organization.
+	SwitchOrganization(ctx context.Context, token, organizationID string) (*SessionData, error)
+	// AcceptOrganizationInvite activates the invite's membership, creating
+	// the invited user's account if needed
+	AcceptOrganizationInvite(ctx context.Context, input AcceptOrganizationInviteInput, ipAddress, userAgent string) (*AcceptOrganizationInviteResult, error)
+}
@@ -0,0 +1,35 @@
+package core
+
+// PasskeyProviderID is the Account.ProviderID of passkey (WebAuthn) credentials
+const PasskeyProviderID = "passkey"
+
+// Security upgrade prompts suggested by DefaultUpgradePrompts
+const (
+	UpgradeAddPasskey      = "add_passkey"
+	UpgradeEnableTwoFactor = "enable_two_factor"
+)
+
+// SecurityPosture tells front-ends which strong authentication methods the
+// user has enrolled and which upgrades to suggest after sign-in
+type SecurityPosture struct {
+	HasPasskey       bool     `json:"hasPasskey"`
+	TwoFactorEnabled bool     `json:"twoFactorEnabled"`
+	UpgradePrompts   []string `json:"upgradePrompts,omitempty"`
+}
+
+// UpgradePromptPolicy decides which security upgrades to suggest to a user
+// given what they have enrolled
+type UpgradePromptPolicy func(user *User, posture SecurityPosture) []string
+
+// DefaultUpgradePrompts suggests a passkey to users without one, and a second
+// factor to users who have neither
+func DefaultUpgradePrompts(_ *User, posture SecurityPosture) []string {
+	if posture.HasPasskey {
+		return nil
+	}
+	prompts := []string{UpgradeAddPasskey}
+	if !posture.TwoFactorEnabled {
+		prompts = append(prompts, UpgradeEnableTwoFactor)
+	}
+	return prompts
+}
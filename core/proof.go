@@ -0,0 +1,50 @@
+package core
+
+import (
+	"strconv"
+	"strings"
+)
+
+// RequestProof is a client's proof-of-possession for a single request.
+// The signature covers "METHOD\nPATH\nTIMESTAMP" (see crypto.ProofMessage).
+//
+// Proofs carry no nonce and aren't remembered, so one captured along with its
+// token can be replayed against the same method and path until its timestamp
+// leaves SessionConfig.ProofMaxSkew. What a proof guarantees is that a stolen
+// token alone stops working once that window has passed.
+type RequestProof struct {
+	Method    string
+	Path      string
+	Timestamp int64 // unix seconds
+	Signature string
+}
+
+// ProofVerifier verifies proof-of-possession for sessions bound to a key.
+// Adapters use it, when the auth provider implements it, alongside token checks.
+type ProofVerifier interface {
+	// VerifyProof returns nil for sessions without a bound key, ErrProofRequired
+	// when a bound session's request has no proof, and ErrInvalidProof otherwise.
+	VerifyProof(session *Session, proof *RequestProof) error
+}
+
+// ProofHeader carries the proof-of-possession for key-bound sessions
+const ProofHeader = "X-Kuta-Proof"
+
+// ParseRequestProof reads a ProofHeader value, "<unix-timestamp>.<signature>",
+// for a request to method and path. An empty header means no proof was sent.
+func ParseRequestProof(header, method, path string) (*RequestProof, error) {
+	if header == "" {
+		return nil, nil
+	}
+	ts, sig, found := strings.Cut(header, ".")
+	timestamp, err := strconv.ParseInt(ts, 10, 64)
+	if !found || err != nil {
+		return nil, ErrInvalidProof
+	}
+	return &RequestProof{
+		Method:    method,
+		Path:      path,
+		Timestamp: timestamp,
+		Signature: sig,
+	}, nil
+}
@@ -0,0 +1,44 @@
+package core
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// Requirement: Any proof header either parses into the request's proof or
+// is refused with ErrInvalidProof, never panicking.
+func FuzzParseRequestProof(f *testing.F) {
+	f.Add("")
+	f.Add("1700000000.c2lnbmF0dXJl")
+	f.Add("1700000000")
+	f.Add(".sig")
+	f.Add("-1.sig")
+	f.Add("99999999999999999999.sig")
+	f.Add("1700000000.a.b")
+	f.Add(" 1700000000.sig")
+
+	f.Fuzz(func(t *testing.T, header string) {
+		// Act
+		proof, err := ParseRequestProof(header, "GET", "/api/auth/session")
+
+		// Assert
+		switch {
+		case header == "":
+			if proof != nil || err != nil {
+				t.Fatalf("ParseRequestProof(%q) = %+v, %v, want no proof", header, proof, err)
+			}
+		case err != nil:
+			if !errors.Is(err, ErrInvalidProof) || proof != nil {
+				t.Fatalf("ParseRequestProof(%q) = %+v, %v, want %v", header, proof, err, ErrInvalidProof)
+			}
+		default:
+			ts, sig, _ := strings.Cut(header, ".")
+			timestamp, _ := strconv.ParseInt(ts, 10, 64)
+			if proof.Method != "GET" || proof.Path != "/api/auth/session" || proof.Timestamp != timestamp || proof.Signature != sig {
+				t.Fatalf("ParseRequestProof(%q) = %+v", header, proof)
+			}
+		}
+	})
+}
@@ -0,0 +1,43 @@
+package core
+
+// CredentialProviderID is the Account.ProviderID of email and password
+// accounts
+const CredentialProviderID = "credential"
+
+// ProviderStatus says whether sign-in with a provider is currently allowed
+type ProviderStatus struct {
+	ID      string `json:"id"`
+	Enabled bool   `json:"enabled"`
+}
+
+// Kinds of sign-in method reported by provider discovery
+const (
+	ProviderTypeCredential = "credential"
+	ProviderTypeOAuth      = "oauth"
+	ProviderTypePasskey    = "passkey"
+	ProviderTypeMagicLink  = "magic_link"
+)
+
+// ProviderInfo describes a sign-in method so clients can build their login
+// screen from the server's configuration
+type ProviderInfo struct {
+	ID   string `json:"id"`
+	Type string `json:"type"` // one of the ProviderType* constants
+	// Name is the label to show, e.g. "GitHub"
+	Name    string `json:"name"`
+	IconURL string `json:"iconUrl,omitempty"`
+	// SignInPath is the endpoint that starts sign-in, relative to the auth
+	// base path
+	SignInPath string `json:"signInPath,omitempty"`
+}
+
+// ProvidersResponse is the body of the providers discovery endpoint
+type ProvidersResponse struct {
+	Providers []ProviderInfo `json:"providers"`
+}
+
+// ProviderDiscovery lists the enabled sign-in methods. Adapters mount the
+// providers endpoint when the auth provider implements it.
+type ProviderDiscovery interface {
+	Providers() []ProviderInfo
+}
@@ -0,0 +1,32 @@
+package core
+
+import "time"
+
+// RateLimiter admits requests per key at a configured rate. Implementations
+// backed by a shared store (Redis, ...) make the limit hold across instances.
+type RateLimiter interface {
+	// Allow spends one request for key and reports whether it was admitted
+	Allow(key string) (RateLimitDecision, error)
+}
+
+// RateLimitDecision is the outcome of RateLimiter.Allow
+type RateLimitDecision struct {
+	Allowed bool
+	// Remaining is how many more requests key may make right away
+	Remaining int
+	// RetryAfter is how long until key may make a request again, when it
+	// was refused
+	RetryAfter time.Duration
+}
+
+// RateLimitConfig configures request rate limiting per client IP. Zero
+// values use the defaults.
+type RateLimitConfig struct {
+	// Limit is how many requests are allowed per Window. Defaults to 20.
+	Limit int
+	// Window is the period Limit applies to. Defaults to 1 minute.
+	Window time.Duration
+	// Burst is how many requests may be made at once after a quiet period.
+	// Only the token bucket limiter uses it. Defaults to Limit.
+	Burst int
+}
@@ -0,0 +1,53 @@
+package core
+
+import (
+	"context"
+	"time"
+)
+
+// RefreshToken is a long-lived, single-use credential exchanged for a new
+// session. Each rotation revokes the presented token and issues a child whose
+// ParentID points back at it, so a token family forms an audit trail.
+type RefreshToken struct {
+	ID        string    `json:"id"`
+	UserID    string    `json:"userId"`
+	SessionID string    `json:"sessionId"` // session issued alongside this token
+	ParentID  *string   `json:"parentId,omitempty"`
+	TokenHash string    `json:"-"` // Never expose in JSON (security!)
+	IPAddress string    `json:"ipAddress"`
+	UserAgent string    `json:"userAgent"`
+	PublicKey string    `json:"publicKey,omitempty"` // carried over to refreshed sessions
+	ExpiresAt time.Time `json:"expiresAt"`
+	// AuthenticatedAt is when the user signed in to start this token's
+	// rotation chain. Rotation carries it over, so it bounds the chain's
+	// absolute lifetime.
+	AuthenticatedAt time.Time  `json:"authenticatedAt"`
+	RevokedAt       *time.Time `json:"revokedAt,omitempty"`
+	// RotatedAt is set along with RevokedAt when the token was exchanged for
+	// a new one, rather than revoked by sign-out or an admin
+	RotatedAt *time.Time `json:"rotatedAt,omitempty"`
+	CreatedAt time.Time  `json:"createdAt"`
+}
+
+// RefreshTokenStorage defines refresh-token database operations.
+//
+// Revoked tokens are kept (not deleted) so rotation lineage stays auditable
+// and reuse of a rotated token can be detected.
+type RefreshTokenStorage interface {
+	CreateRefreshToken(ctx context.Context, token *RefreshToken) error
+	GetRefreshTokenByHash(ctx context.Context, tokenHash string) (*RefreshToken, error)
+	// RevokeRefreshToken marks an active token revoked. It returns
+	// ErrRefreshTokenNotFound when the token is missing or already revoked,
+	// which makes rotation single-use under concurrency.
+	RevokeRefreshToken(ctx context.Context, id string) error
+	// RotateRefreshToken is RevokeRefreshToken for a token being exchanged
+	// by Refresh, also setting RotatedAt so a second exchange is told apart
+	// from presenting a token that was merely revoked
+	RotateRefreshToken(ctx context.Context, id string) error
+	RevokeSessionRefreshTokens(ctx context.Context, sessionID string) (int, error)
+	RevokeUserRefreshTokens(ctx context.Context, userID string) (int, error)
+	DeleteExpiredRefreshTokens(ctx context.Context) (int, error)
+	// GetActiveUserRefreshTokens returns the user's refresh tokens that are
+	// neither revoked nor expired, oldest first
+	GetActiveUserRefreshTokens(ctx context.Context, userID string) ([]*RefreshToken, error)
+}
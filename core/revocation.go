@@ -0,0 +1,20 @@
+package core
+
+// Revocation announces cached sessions that are no longer valid, so every
+// instance sharing a database can evict them instead of waiting for the
+// cache TTL.
+type Revocation struct {
+	Origin      string   `json:"origin"` // instance that published it
+	TokenHashes []string `json:"tokenHashes,omitempty"`
+	All         bool     `json:"all,omitempty"` // evict every cached session
+}
+
+// RevocationBus broadcasts revocations between instances, e.g. over Redis
+// pub/sub or a message queue. Publish is best effort: a lost message only
+// delays eviction until the cache entry expires.
+type RevocationBus interface {
+	Publish(revocation Revocation) error
+	// Subscribe registers handler for revocations published by any instance,
+	// including this one.
+	Subscribe(handler func(Revocation))
+}
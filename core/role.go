@@ -0,0 +1,23 @@
+package core
+
+import "context"
+
+// RoleStorage persists the roles granted to users and the permissions each
+// role carries, for role-based access checks
+type RoleStorage interface {
+	// AssignRole grants role to the user. Granting a role the user already
+	// has is not an error.
+	AssignRole(ctx context.Context, userID, role string) error
+	// RevokeRole takes role away from the user. Revoking a role the user
+	// doesn't have is not an error.
+	RevokeRole(ctx context.Context, userID, role string) error
+	// GetUserRoles returns the user's roles, sorted, and an empty list when
+	// they have none
+	GetUserRoles(ctx context.Context, userID string) ([]string, error)
+	// SetRolePermissions replaces the permissions of role. An empty list
+	// leaves the role without permissions.
+	SetRolePermissions(ctx context.Context, role string, permissions []string) error
+	// GetRolePermissions returns the permissions of role, sorted, and an
+	// empty list for roles without any
+	GetRolePermissions(ctx context.Context, role string) ([]string, error)
+}
@@ -0,0 +1,122 @@
+package core
+
+import "time"
+
+// Request and response bodies of the base endpoints. They are referenced from
+// EndpointMetadata so generators and validators work from concrete types;
+// successful responses reuse the *Result types returned by AuthProvider.
+
+// SignUpRequest is the body of POST /sign-up
+type SignUpRequest struct {
+	Email       string       `json:"email" form:"email"`
+	Password    string       `json:"password" form:"password"`
+	Name        string       `json:"name" form:"name"`
+	Image       *string      `json:"image,omitempty" form:"image"`
+	ImageUpload *ImageUpload `json:"imageUpload,omitempty" form:"-"`
+}
+
+// Input converts the request into AuthProvider input
+func (r SignUpRequest) Input() SignUpInput {
+	return SignUpInput{
+		Email:       r.Email,
+		Password:    r.Password,
+		Name:        r.Name,
+		Image:       r.Image,
+		ImageUpload: r.ImageUpload,
+	}
+}
+
+// SignInRequest is the body of POST /sign-in
+type SignInRequest struct {
+	Email     string `json:"email"`
+	Password  string `json:"password"`
+	PublicKey string `json:"publicKey,omitempty"`
+}
+
+// Input converts the request into AuthProvider input
+func (r SignInRequest) Input() SignInInput {
+	return SignInInput{
+		Email:     r.Email,
+		Password:  r.Password,
+		PublicKey: r.PublicKey,
+	}
+}
+
+// RefreshRequest is the body of POST /refresh
+type ContinueSignInRequest struct {
+	ChallengeToken string `json:"challengeToken"`
+	Response       string `json:"response"`
+}
+
+// Input converts the request into AuthProvider input
+func (r ContinueSignInRequest) Input() ContinueSignInInput {
+	return ContinueSignInInput{
+		ChallengeToken: r.ChallengeToken,
+		Response:       r.Response,
+	}
+}
+
+type RefreshRequest struct {
+	RefreshToken string `json:"refreshToken"`
+}
+
+// SwitchOrganizationRequest is the body of POST /organizations/switch. An
+// empty OrganizationID clears the active organization.
+type SwitchOrganizationRequest struct {
+	OrganizationID string `json:"organizationId"`
+}
+
+// AcceptOrganizationInviteRequest is the body of POST
+// /organizations/invites/accept. Name and Password are only used when the
+// invited email has no account yet.
+type AcceptOrganizationInviteRequest struct {
+	Token    string `json:"token" form:"token"`
+	Name     string `json:"name" form:"name"`
+	Password string `json:"password" form:"password"`
+}
+
+// Input converts the request into OrganizationProvider input
+func (r AcceptOrganizationInviteRequest) Input() AcceptOrganizationInviteInput {
+	return AcceptOrganizationInviteInput{
+		Token:    r.Token,
+		Name:     r.Name,
+		Password: r.Password,
+	}
+}
+
+// CreateAccessTokenRequest is the body of POST /me/tokens. A nil ExpiresAt
+// creates a token that doesn't expire.
+type CreateAccessTokenRequest struct {
+	Name      string     `json:"name"`
+	Scopes    []string   `json:"scopes"`
+	ExpiresAt *time.Time `json:"expiresAt,omitempty"`
+}
+
+// Input converts the request into AccessTokenProvider input
+func (r CreateAccessTokenRequest) Input() CreateAccessTokenInput {
+	return CreateAccessTokenInput{
+		Name:      r.Name,
+		Scopes:    r.Scopes,
+		ExpiresAt: r.ExpiresAt,
+	}
+}
+
+// ChangePasswordRequest is the body of POST /change-password
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"currentPassword"`
+	NewPassword     string `json:"newPassword"`
+}
+
+// Input converts the request into PasswordChanger input. The handler fills
+// in KeepSessionID with the session making the change.
+func (r ChangePasswordRequest) Input() ChangePasswordInput {
+	return ChangePasswordInput{
+		CurrentPassword: r.CurrentPassword,
+		NewPassword:     r.NewPassword,
+	}
+}
+
+// MessageResponse is returned by endpoints that have no data to send back
+type MessageResponse struct {
+	Message string `json:"message"`
+}
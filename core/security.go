@@ -0,0 +1,55 @@
+package core
+
+import (
+	"context"
+	"slices"
+	"time"
+)
+
+// UserSecurity holds a user's security settings, kept apart from User so
+// per-user security posture has one home as it grows
+type UserSecurity struct {
+	UserID           string `json:"userId"`
+	TwoFactorEnabled bool   `json:"twoFactorEnabled"`
+	// AllowedProviders limits which providers ("credential", "google", ...)
+	// the user may sign in with. Empty allows all.
+	AllowedProviders []string `json:"allowedProviders,omitempty"`
+	// MaxSessions caps concurrent sessions. Zero means unlimited.
+	MaxSessions   int                     `json:"maxSessions,omitempty"`
+	Notifications NotificationPreferences `json:"notifications"`
+	UpdatedAt     time.Time               `json:"updatedAt"`
+}
+
+// NotificationPreferences says which security events the user wants to be
+// told about
+type NotificationPreferences struct {
+	NewSignIn       bool `json:"newSignIn"`
+	PasswordChanged bool `json:"passwordChanged"`
+	Anomalies       bool `json:"anomalies"`
+}
+
+// DefaultUserSecurity returns the settings of a user who never changed them
+func DefaultUserSecurity(userID string) *UserSecurity {
+	return &UserSecurity{
+		UserID: userID,
+		Notifications: NotificationPreferences{
+			NewSignIn:       true,
+			PasswordChanged: true,
+			Anomalies:       true,
+		},
+	}
+}
+
+// AllowsProvider reports whether the user may sign in with providerID
+func (s *UserSecurity) AllowsProvider(providerID string) bool {
+	return len(s.AllowedProviders) == 0 || slices.Contains(s.AllowedProviders, providerID)
+}
+
+// UserSecurityStorage defines user security settings database operations
+type UserSecurityStorage interface {
+	// GetUserSecurity returns ErrUserSecurityNotFound when the user has no
+	// stored settings.
+	GetUserSecurity(ctx context.Context, userID string) (*UserSecurity, error)
+	// UpsertUserSecurity creates or replaces the settings of s.UserID.
+	UpsertUserSecurity(ctx context.Context, s *UserSecurity) error
+}
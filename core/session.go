@@ -1,6 +1,7 @@
 package core
 
 import (
+	"context"
 	"time"
 )
 
@@ -11,9 +12,30 @@ type Session struct {
 	TokenHash string    `json:"-"` // Never expose in JSON (security!)
 	IPAddress string    `json:"ipAddress"`
 	UserAgent string    `json:"userAgent"`
+	PublicKey string    `json:"publicKey,omitempty"` // Proof-of-possession key bound at sign-in
 	ExpiresAt time.Time `json:"expiresAt"`
-	CreatedAt time.Time `json:"createdAt"`
-	UpdatedAt time.Time `json:"updatedAt"`
+	// Impersonation is set when an admin opened the session as this user
+	Impersonation *Impersonation `json:"impersonation,omitempty"`
+	// RevokedAt is set while a softly revoked session drains; see Draining
+	RevokedAt *time.Time `json:"revokedAt,omitempty"`
+	// ActiveOrganizationID is the organization the user is acting in, set
+	// with SwitchOrganization. Empty when none is selected.
+	ActiveOrganizationID string `json:"activeOrganizationId,omitempty"`
+	// Entitlements are the user's plan and feature flags as resolved when
+	// the session was issued. Nil without an EntitlementResolver.
+	Entitlements *Entitlements `json:"entitlements,omitempty"`
+	// Data holds app values stored with SetValue. It may contain secrets
+	// such as CSRF keys, so it is never exposed in JSON.
+	Data      map[string]string `json:"-"`
+	CreatedAt time.Time         `json:"createdAt"`
+	UpdatedAt time.Time         `json:"updatedAt"`
+}
+
+// Draining reports whether the session was revoked with a grace period that
+// hasn't ended yet. Draining sessions may still be used for idempotent reads
+// but not for mutations.
+func (s *Session) Draining() bool {
+	return s.RevokedAt != nil
 }
 
 // SessionData combines user and session info
@@ -21,10 +43,65 @@ type Session struct {
 type SessionData struct {
 	User    *User    `json:"user"`
 	Session *Session `json:"session"`
+
+	// Security is set when security posture reporting is enabled
+	Security *SecurityPosture `json:"security,omitempty"`
+
+	// Impersonation is set when an admin is signed in as User, so clients
+	// can show a banner
+	Impersonation *Impersonation `json:"impersonation,omitempty"`
+
+	// ActiveOrganizationID is the session's active organization, repeated
+	// here so clients don't have to dig into Session
+	ActiveOrganizationID string `json:"activeOrganizationId,omitempty"`
+
+	// Entitlements are the session's entitlements, repeated here like
+	// ActiveOrganizationID
+	Entitlements *Entitlements `json:"entitlements,omitempty"`
+}
+
+// Impersonated reports whether the session was opened by an admin acting as
+// the user. Backends can use it to refuse actions such as changing the
+// password, e.g. with ErrImpersonating.
+func (d *SessionData) Impersonated() bool {
+	return d.Impersonation != nil
+}
+
+// Impersonation records who opened an impersonated session and when
+type Impersonation struct {
+	ActorUserID string    `json:"actorUserId"`
+	StartedAt   time.Time `json:"startedAt"`
 }
 
 type SessionConfig struct {
 	MaxAge time.Duration
+
+	// RefreshMaxAge is how long a refresh token stays valid. Every refresh
+	// issues a new token with a fresh lifetime. Defaults to 30 days.
+	RefreshMaxAge time.Duration
+
+	// ProofMaxSkew is how far a proof-of-possession timestamp may drift from
+	// the server clock, and so how long a captured proof can be replayed for.
+	// Defaults to 1 minute.
+	ProofMaxSkew time.Duration
+
+	// RefreshAbsoluteMaxAge is how long a chain of rotated refresh tokens
+	// may last from sign-in. Past it refresh fails with ErrSessionExpired
+	// and the user has to sign in again. Zero means rotation can continue
+	// indefinitely.
+	RefreshAbsoluteMaxAge time.Duration
+
+	// MaxRefreshDevices caps how many refresh token chains (devices) a user
+	// may hold. Signing in beyond it revokes the oldest chain and its
+	// session. Zero means no cap.
+	MaxRefreshDevices int
+}
+
+// VerifyResult is the outcome of verifying one token in a batch.
+// Exactly one of Session and Err is set.
+type VerifyResult struct {
+	Session *Session
+	Err     error
 }
 
 type CreateSessionResult struct {
@@ -34,11 +111,87 @@ type CreateSessionResult struct {
 
 // AuthProvider provides authentication operations for HTTP adapters
 type AuthProvider interface {
-	SignUp(input SignUpInput, ipAddress, userAgent string) (*SignUpResult, error)
-	SignIn(input SignInInput, ipAddress, userAgent string) (*SignInResult, error)
-	SignOut(token string) error
-	GetSession(token string) (*SessionData, error)
-	Refresh(token string) (*RefreshResult, error)
+	SignUp(ctx context.Context, input SignUpInput, ipAddress, userAgent string) (*SignUpResult, error)
+	SignIn(ctx context.Context, input SignInInput, ipAddress, userAgent string) (*SignInResult, error)
+	ContinueSignIn(ctx context.Context, input ContinueSignInInput) (*SignInResult, error)
+	SignOut(ctx context.Context, token string) error
+	GetSession(ctx context.Context, token string) (*SessionData, error)
+	Refresh(ctx context.Context, refreshToken string) (*RefreshResult, error)
+}
+
+// AuthService is the full application-facing API of the session manager:
+// the AuthProvider flows plus session and user management that HTTP adapters
+// don't need.
+type AuthService interface {
+	AuthProvider
+
+	Verify(ctx context.Context, token string) (*Session, error)
+	VerifyBatch(ctx context.Context, tokens []string) ([]VerifyResult, error)
+	DestroyBySessionID(ctx context.Context, sessionID string) error
+	DestroyAllUserSessions(ctx context.Context, userID string) (int, error)
+	// RevokeSession and RevokeUserSessions destroy (or, with a revocation
+	// grace period, drain) sessions like the two above and record reason (a
+	// RevokeReason* constant or the app's own) in an EventSessionRevoked
+	RevokeSession(ctx context.Context, sessionID, reason string) error
+	RevokeUserSessions(ctx context.Context, userID, reason string) (int, error)
+
+	UpdateUser(ctx context.Context, userID string, input UpdateUserInput) (*User, error)
+	// ChangePassword replaces the user's password after checking the
+	// current one, revoking their other sessions unless configured not to
+	ChangePassword(ctx context.Context, userID string, input ChangePasswordInput) error
+	GetUserSecurity(ctx context.Context, userID string) (*UserSecurity, error)
+	UpdateUserSecurity(ctx context.Context, settings *UserSecurity) error
+	DeleteUser(ctx context.Context, userID string) error
+	RestoreUser(ctx context.Context, userID string) error
+	PurgeDeletedUsers(ctx context.Context) (int, error)
+	// GetRecentAttempts returns up to limit of the user's logged sign-in
+	// attempts, newest first, or ErrNotImplemented when no SignInLog is
+	// configured
+	GetRecentAttempts(ctx context.Context, userID string, limit int) ([]*SignInRecord, error)
+	// ListActivity returns a page of the user's logged sign-in attempts,
+	// newest first, or ErrNotImplemented when no SignInLog is configured
+	ListActivity(ctx context.Context, userID string, limit, offset int) (*ActivityPage, error)
+
+	// Role management, all returning ErrNotImplemented when no RoleStorage
+	// is configured. AssignRole fails with ErrUserNotFound for unknown
+	// users.
+	AssignRole(ctx context.Context, userID, role string) error
+	RevokeRole(ctx context.Context, userID, role string) error
+	GetUserRoles(ctx context.Context, userID string) ([]string, error)
+	SetRolePermissions(ctx context.Context, role string, permissions []string) error
+	GetRolePermissions(ctx context.Context, role string) ([]string, error)
+	// GetUserPermissions returns the permissions of all the user's roles,
+	// sorted and without duplicates
+	GetUserPermissions(ctx context.Context, userID string) ([]string, error)
+	HasPermission(ctx context.Context, userID, permission string) (bool, error)
+
+	// Organization memberships, returning ErrNotImplemented when no
+	// OrganizationStorage is configured
+	AddOrganizationMember(ctx context.Context, organizationID, userID string, roles []string) error
+	RemoveOrganizationMember(ctx context.Context, organizationID, userID string) error
+	ListUserOrganizations(ctx context.Context, userID string) ([]*OrganizationMember, error)
+	// InviteOrganizationMember emails a single-use invite token; accepting
+	// it through OrganizationProvider makes the invitee a member
+	InviteOrganizationMember(ctx context.Context, input InviteOrganizationMemberInput) (*OrganizationInviteResult, error)
+	ListPendingOrganizationMembers(ctx context.Context, organizationID string) ([]*PendingOrganizationMember, error)
+	RevokeOrganizationInvite(ctx context.Context, inviteID string) error
+	// GetSessionPermissions is GetUserPermissions plus the permissions of
+	// the user's roles in the session's active organization
+	GetSessionPermissions(ctx context.Context, session *Session) ([]string, error)
+	SessionHasPermission(ctx context.Context, session *Session, permission string) (bool, error)
+
+	// RefreshEntitlements resolves the entitlements of the user's sessions
+	// again, e.g. after a plan change. It returns ErrNotImplemented when no
+	// EntitlementResolver is configured.
+	RefreshEntitlements(ctx context.Context, userID string) error
+
+	// Personal access tokens, returning ErrNotImplemented when no
+	// AccessTokenStorage is configured. APIs authenticate CLI and script
+	// callers with VerifyAccessToken.
+	CreateAccessToken(ctx context.Context, userID string, input CreateAccessTokenInput) (*CreateAccessTokenResult, error)
+	ListAccessTokens(ctx context.Context, userID string) ([]*AccessToken, error)
+	RevokeAccessToken(ctx context.Context, userID, tokenID string) error
+	VerifyAccessToken(ctx context.Context, token string) (*AccessTokenData, error)
 }
 
 type SignUpInput struct {
@@ -46,26 +199,100 @@ type SignUpInput struct {
 	Password string
 	Name     string
 	Image    *string
+
+	// ImageUpload is handed to the configured ImageStore and replaces Image
+	// with the returned URL
+	ImageUpload *ImageUpload
+
+	// AnonymousToken is the token of the client's guest session, if any.
+	// A live one is upgraded into the new account's session, keeping its ID.
+	AnonymousToken string
+}
+
+// ChangePasswordInput is a password change by a signed-in user
+type ChangePasswordInput struct {
+	CurrentPassword string
+	NewPassword     string
+
+	// KeepSessionID is left signed in when the user's sessions are revoked,
+	// typically the session making the change. Empty revokes them all.
+	KeepSessionID string
+}
+
+// PasswordChanger lets signed-in users change their own password. Adapters
+// mount the change-password endpoint when the auth provider implements it.
+type PasswordChanger interface {
+	ChangePassword(ctx context.Context, userID string, input ChangePasswordInput) error
+}
+
+// UpdateUserInput changes a user's profile. Nil fields are left unchanged.
+type UpdateUserInput struct {
+	Name        *string
+	Image       *string
+	ImageUpload *ImageUpload
+	// Email changes the user's address. The new address is unverified, and
+	// verification and password reset links sent out before no longer work.
+	Email *string
 }
 
 type SignUpResult struct {
-	User    *User    `json:"user"`
-	Session *Session `json:"session"`
-	Token   string   `json:"token"` // The raw token (not the hash)
+	User         *User    `json:"user"`
+	Session      *Session `json:"session"`
+	Token        string   `json:"token,omitempty"`        // The raw token (not the hash)
+	RefreshToken string   `json:"refreshToken,omitempty"` // Exchanged for a new session via Refresh
+
+	// RequiresEmailVerification is set when the email must be verified
+	// before the account is fully usable
+	RequiresEmailVerification bool `json:"requiresEmailVerification"`
+	// RequiresApproval is set when an admin must approve the account before
+	// it can sign in
+	RequiresApproval bool `json:"requiresApproval"`
+	// NextStep is the onboarding step the client should show next, one of
+	// the NextStep* constants; empty when onboarding is complete
+	NextStep string `json:"nextStep,omitempty"`
+}
+
+// Onboarding steps reported in SignUpResult.NextStep
+const (
+	NextStepVerifyEmail    = "verify_email"
+	NextStepSignIn         = "sign_in" // no session was issued; sign in to get one
+	NextStepSetupTwoFactor = "setup_two_factor"
+	NextStepAwaitApproval  = "await_approval" // an admin must approve the account first
+)
+
+// OnboardingConfig says which steps new users go through after sign-up
+type OnboardingConfig struct {
+	RequireEmailVerification bool
+	RequireTwoFactorSetup    bool
+	// RequireApproval puts new users in an approval queue: they can't sign
+	// in until an admin approves them
+	RequireApproval bool
 }
 
 type SignInInput struct {
 	Email    string
 	Password string
+
+	// PublicKey optionally binds the session to a client keypair
+	// (base64url PKIX, Ed25519 or ECDSA P-256). Requests using the session
+	// must then carry a proof signed with the private key.
+	PublicKey string
 }
 
 type SignInResult struct {
-	User    *User    `json:"user"`
-	Session *Session `json:"session"`
-	Token   string   `json:"token"` // The raw token (not the hash)
+	User         *User            `json:"user"`
+	Session      *Session         `json:"session"`
+	Token        string           `json:"token,omitempty"`        // The raw token (not the hash)
+	RefreshToken string           `json:"refreshToken,omitempty"` // Exchanged for a new session via Refresh
+	Security     *SecurityPosture `json:"security,omitempty"`     // Set when security posture reporting is enabled
+
+	// Challenge is set instead of Session and tokens when the sign-in needs
+	// another step; complete it through ContinueSignIn
+	Challenge *AuthChallenge `json:"challenge,omitempty"`
 }
 
 type RefreshResult struct {
-	Session *Session `json:"session"`
-	Token   string   `json:"token"` // The raw token (not the hash)
+	Session      *Session `json:"session"`
+	Token        string   `json:"token,omitempty"` // The raw token (not the hash)
+	RefreshToken string   `json:"refreshToken"`    // Replaces the token that was presented
 }
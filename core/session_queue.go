@@ -0,0 +1,15 @@
+package core
+
+// SessionQueue holds sessions that were issued but not yet written to
+// storage, in deferred session persistence mode. A queue is used by one
+// instance; it must keep its sessions across restarts for them to survive a
+// crash before they were persisted.
+type SessionQueue interface {
+	// Put queues session, replacing a queued session with the same ID
+	Put(session *Session) error
+	// Pending returns the queued sessions, oldest first
+	Pending() ([]*Session, error)
+	// Remove drops the sessions with ids from the queue, ignoring ids that
+	// aren't queued
+	Remove(ids ...string) error
+}
@@ -0,0 +1,52 @@
+package core
+
+import (
+	"context"
+	"time"
+)
+
+// SignInRecord is one logged sign-in attempt, successful or not. Attempts
+// that stop at a challenge are logged once the challenge is answered.
+type SignInRecord struct {
+	ID string `json:"id"`
+	// UserID is empty when the attempt matched no user
+	UserID     string `json:"userId,omitempty"`
+	Email      string `json:"email,omitempty"`
+	ProviderID string `json:"providerId"`
+	Success    bool   `json:"success"`
+	// Reason is why a failed attempt was refused, e.g. "invalid credentials"
+	Reason    string    `json:"reason,omitempty"`
+	IPAddress string    `json:"ipAddress"`
+	UserAgent string    `json:"userAgent"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// SignInLog persists sign-in attempts for "recent activity" views and
+// lockout decisions
+type SignInLog interface {
+	RecordSignIn(ctx context.Context, record *SignInRecord) error
+	// ListSignInRecords returns up to limit of the user's attempts, newest
+	// first, skipping the offset newest, along with how many attempts of the
+	// user are logged in total
+	ListSignInRecords(ctx context.Context, userID string, limit, offset int) ([]*SignInRecord, int, error)
+	// PurgeSignInRecords removes attempts made before cutoff and returns
+	// how many it removed
+	PurgeSignInRecords(ctx context.Context, cutoff time.Time) (int, error)
+}
+
+// ActivityPage is one page of a user's recent sign-in activity, newest first
+type ActivityPage struct {
+	SignIns []*SignInRecord `json:"signIns"`
+	Total   int             `json:"total"`
+	Limit   int             `json:"limit"`
+	Offset  int             `json:"offset"`
+}
+
+// ActivityProvider serves the signed-in user's own recent activity, for
+// "security activity" pages. Adapters mount the activity endpoint when it is
+// enabled.
+type ActivityProvider interface {
+	// ActivityEnabled reports whether the activity endpoint should be mounted
+	ActivityEnabled() bool
+	ListActivity(ctx context.Context, userID string, limit, offset int) (*ActivityPage, error)
+}
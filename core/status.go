@@ -0,0 +1,133 @@
+package core
+
+import (
+	"errors"
+	"net/http"
+	"sync"
+)
+
+// ErrorStatusFunc maps an error to an HTTP status code. ok is false when the
+// function doesn't recognise err.
+type ErrorStatusFunc func(err error) (status int, ok bool)
+
+// errorStatuses is the built-in mapping, checked in order with errors.Is so
+// wrapped errors map like the sentinel they wrap.
+var errorStatuses = []struct {
+	err    error
+	status int
+}{
+	// ErrUserNotFound is reported like bad credentials so responses don't
+	// reveal which emails are registered
+	{ErrInvalidCredentials, http.StatusUnauthorized},
+	{ErrUserNotFound, http.StatusUnauthorized},
+	{ErrMissingAuthHeader, http.StatusUnauthorized},
+	{ErrInvalidAuthHeader, http.StatusUnauthorized},
+	{ErrInvalidToken, http.StatusUnauthorized},
+	{ErrSessionNotFound, http.StatusUnauthorized},
+	{ErrSessionExpired, http.StatusUnauthorized},
+	{ErrRefreshTokenNotFound, http.StatusUnauthorized},
+	{ErrProofRequired, http.StatusUnauthorized},
+	{ErrInvalidProof, http.StatusUnauthorized},
+	{ErrSessionDraining, http.StatusUnauthorized},
+	{ErrChallengeNotFound, http.StatusUnauthorized},
+	{ErrChallengeFailed, http.StatusUnauthorized},
+	{ErrOAuthFailed, http.StatusUnauthorized},
+	{ErrAccessTokenExpired, http.StatusUnauthorized},
+
+	{ErrEmailRequired, http.StatusBadRequest},
+	{ErrPasswordRequired, http.StatusBadRequest},
+	{ErrPasswordTooShort, http.StatusBadRequest},
+	{ErrPasswordTooLong, http.StatusBadRequest},
+	{ErrInvalidEmail, http.StatusBadRequest},
+	{ErrInvalidPublicKey, http.StatusBadRequest},
+	{ErrInvalidImage, http.StatusBadRequest},
+	{ErrInvalidSessionValue, http.StatusBadRequest},
+	{ErrRoleRequired, http.StatusBadRequest},
+	{ErrOrganizationRequired, http.StatusBadRequest},
+	{ErrNameRequired, http.StatusBadRequest},
+	{ErrInvalidScope, http.StatusBadRequest},
+	{ErrInvalidExpiry, http.StatusBadRequest},
+	{ErrInvalidIdempotencyKey, http.StatusBadRequest},
+	{ErrValidationFailed, http.StatusBadRequest},
+	{ErrBatchTooLarge, http.StatusBadRequest},
+	{ErrVerificationTokenNotFound, http.StatusBadRequest},
+	{ErrInvalidOAuthState, http.StatusBadRequest},
+	{ErrInvalidTwoFactorCode, http.StatusBadRequest},
+	{ErrUnknownProvider, http.StatusNotFound},
+	{ErrAccessTokenNotFound, http.StatusNotFound},
+	{ErrAccountNotFound, http.StatusNotFound},
+	{ErrTOTPNotSetUp, http.StatusNotFound},
+
+	{ErrUserExists, http.StatusConflict},
+	{ErrIdempotencyInProgress, http.StatusConflict},
+	{ErrAccountLinked, http.StatusConflict},
+	{ErrLastAccount, http.StatusConflict},
+	{ErrTwoFactorEnabled, http.StatusConflict},
+	{ErrEmailAlreadyVerified, http.StatusConflict},
+	{ErrBodyTooLarge, http.StatusRequestEntityTooLarge},
+	{ErrIdempotencyKeyReused, http.StatusUnprocessableEntity},
+	{ErrForbidden, http.StatusForbidden},
+	{ErrImpersonating, http.StatusForbidden},
+	{ErrAnonymousUser, http.StatusForbidden},
+	{ErrNotOrganizationMember, http.StatusForbidden},
+	{ErrApprovalPending, http.StatusForbidden},
+	{ErrAccountRejected, http.StatusForbidden},
+	{ErrProviderDisabled, http.StatusForbidden},
+	{ErrMembershipRequired, http.StatusForbidden},
+	{ErrTooManyAttempts, http.StatusTooManyRequests},
+	{ErrRateLimited, http.StatusTooManyRequests},
+	{ErrNotImplemented, http.StatusNotImplemented},
+	{ErrServiceBusy, http.StatusServiceUnavailable},
+}
+
+var (
+	customStatusMu sync.RWMutex
+	customStatuses []ErrorStatusFunc
+)
+
+// RegisterErrorStatus maps target (and errors wrapping it) to status in
+// ErrorStatus. Registrations take precedence over the built-in mapping, so
+// apps can both add their own errors and override kuta's defaults.
+func RegisterErrorStatus(target error, status int) {
+	RegisterErrorStatusFunc(func(err error) (int, bool) {
+		if errors.Is(err, target) {
+			return status, true
+		}
+		return 0, false
+	})
+}
+
+// RegisterErrorStatusFunc adds fn to the mappings consulted by ErrorStatus,
+// ahead of those registered earlier.
+func RegisterErrorStatusFunc(fn ErrorStatusFunc) {
+	customStatusMu.Lock()
+	defer customStatusMu.Unlock()
+	customStatuses = append(customStatuses, fn)
+}
+
+// ErrorStatus returns the HTTP status code for err: 200 for nil, the first
+// registered or built-in match otherwise, and 500 for unknown errors.
+// Adapters and plugins should use it so the same error maps identically
+// everywhere.
+func ErrorStatus(err error) int {
+	if err == nil {
+		return http.StatusOK
+	}
+
+	customStatusMu.RLock()
+	for i := len(customStatuses) - 1; i >= 0; i-- {
+		if status, ok := customStatuses[i](err); ok {
+			customStatusMu.RUnlock()
+			return status
+		}
+	}
+	customStatusMu.RUnlock()
+
+	for _, mapping := range errorStatuses {
+		if errors.Is(err, mapping.err) {
+			return mapping.status
+		}
+	}
+
+	return http.StatusInternalServerError
+}
@@ -0,0 +1,126 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+// Requirement: ErrorStatus maps kuta errors to correct HTTP status codes
+func TestErrorStatus(t *testing.T) {
+	tests := []struct {
+		name       string
+		err        error
+		wantStatus int
+	}{
+		{
+			name:       "maps ErrInvalidCredentials to 401",
+			err:        ErrInvalidCredentials,
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name:       "maps ErrUserNotFound to 401",
+			err:        ErrUserNotFound,
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name:       "maps ErrInvalidToken to 401",
+			err:        ErrInvalidToken,
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name:       "maps ErrSessionExpired to 401",
+			err:        ErrSessionExpired,
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name:       "maps ErrEmailRequired to 400",
+			err:        ErrEmailRequired,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "maps ErrPasswordRequired to 400",
+			err:        ErrPasswordRequired,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "maps ErrUserExists to 409",
+			err:        ErrUserExists,
+			wantStatus: http.StatusConflict,
+		},
+		{
+			name:       "maps ErrTooManyAttempts to 429",
+			err:        ErrTooManyAttempts,
+			wantStatus: http.StatusTooManyRequests,
+		},
+		{
+			name:       "maps ErrServiceBusy to 503",
+			err:        ErrServiceBusy,
+			wantStatus: http.StatusServiceUnavailable,
+		},
+		{
+			name:       "maps wrapped errors like the sentinel",
+			err:        fmt.Errorf("sign in: %w", ErrSessionExpired),
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name:       "maps nil to 200",
+			err:        nil,
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "defaults unknown errors to 500",
+			err:        errors.New("unknown error"),
+			wantStatus: http.StatusInternalServerError,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			// Act
+			status := ErrorStatus(test.err)
+
+			// Assert
+			if status != test.wantStatus {
+				t.Errorf("ErrorStatus should map error to %d; got %d", test.wantStatus, status)
+			}
+		})
+	}
+}
+
+// Requirement: App-registered mappings apply to wrapped errors and take
+// precedence over the built-in ones.
+func TestRegisterErrorStatus(t *testing.T) {
+	// Arrange
+	errPaymentRequired := errors.New("payment required")
+	RegisterErrorStatus(errPaymentRequired, http.StatusPaymentRequired)
+	errOverride := fmt.Errorf("quota: %w", ErrTooManyAttempts)
+	RegisterErrorStatusFunc(func(err error) (int, bool) {
+		return http.StatusTeapot, err == errOverride
+	})
+
+	tests := []struct {
+		name       string
+		err        error
+		wantStatus int
+	}{
+		{name: "registered error", err: errPaymentRequired, wantStatus: http.StatusPaymentRequired},
+		{name: "wrapped registered error", err: fmt.Errorf("billing: %w", errPaymentRequired), wantStatus: http.StatusPaymentRequired},
+		{name: "override of a built-in", err: errOverride, wantStatus: http.StatusTeapot},
+		{name: "built-in still applies", err: ErrTooManyAttempts, wantStatus: http.StatusTooManyRequests},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// Act
+			status := ErrorStatus(test.err)
+
+			// Assert
+			if status != test.wantStatus {
+				t.Errorf("expected %d, got %d", test.wantStatus, status)
+			}
+		})
+	}
+}
@@ -1,38 +1,140 @@
 package core
 
+import (
+	"context"
+	"time"
+)
+
 // SessionStorage defines session-related database operations
 type SessionStorage interface {
-	CreateSession(session *Session) error
-	GetSessionByHash(tokenHash string) (*Session, error)
-	GetSessionByID(id string) (*Session, error)
-	GetUserSessions(userID string) ([]*Session, error)
-	UpdateSession(session *Session) error
-	DeleteSessionByID(id string) error
-	DeleteSessionByHash(tokenHash string) error
-	DeleteUserSessions(userID string) (int, error)
-	DeleteExpiredSessions() (int, error)
+	CreateSession(ctx context.Context, session *Session) error
+	GetSessionByHash(ctx context.Context, tokenHash string) (*Session, error)
+	// GetSessionsByHashes returns the sessions found for tokenHashes in any
+	// order; missing hashes are simply absent from the result.
+	GetSessionsByHashes(ctx context.Context, tokenHashes []string) ([]*Session, error)
+	GetSessionByID(ctx context.Context, id string) (*Session, error)
+	GetUserSessions(ctx context.Context, userID string) ([]*Session, error)
+	UpdateSession(ctx context.Context, session *Session) error
+	DeleteSessionByID(ctx context.Context, id string) error
+	DeleteSessionByHash(ctx context.Context, tokenHash string) error
+	DeleteUserSessions(ctx context.Context, userID string) (int, error)
+	DeleteExpiredSessions(ctx context.Context) (int, error)
+
+	// SearchSessions returns sessions matching filter, newest first, along
+	// with the total number of matches ignoring Limit/Offset.
+	SearchSessions(ctx context.Context, filter SessionFilter) ([]*Session, int, error)
+	DeleteSessionsByIDs(ctx context.Context, ids []string) (int, error)
+
+	// SetSessionValue stores value under key in the session's Data, and
+	// DeleteSessionValue removes it. Both return ErrSessionNotFound for an
+	// unknown session and leave other keys untouched.
+	SetSessionValue(ctx context.Context, sessionID, key, value string) error
+	DeleteSessionValue(ctx context.Context, sessionID, key string) error
 }
 
 // UserStorage defines user-related database operations
+//
+// Lookups and updates must ignore soft-deleted users (DeletedAt set) and
+// report them as ErrUserNotFound.
 type UserStorage interface {
-	CreateUser(u *User) error
-	GetUserByID(id string) (*User, error)
-	GetUserByEmail(email string) (*User, error)
-	UpdateUser(u *User) error
-	DeleteUser(id string) error
+	CreateUser(ctx context.Context, u *User) error
+	// CreateUserIfNotExists atomically creates a user unless one with the same
+	// email already exists, in which case it returns ErrUserExists.
+	CreateUserIfNotExists(ctx context.Context, u *User) error
+	GetUserByID(ctx context.Context, id string) (*User, error)
+	GetUserByEmail(ctx context.Context, email string) (*User, error)
+	UpdateUser(ctx context.Context, u *User) error
+	DeleteUser(ctx context.Context, id string) error
+
+	// SoftDeleteUser marks a user as deleted without removing the row.
+	SoftDeleteUser(ctx context.Context, id string) error
+	// RestoreUser clears DeletedAt for a user soft-deleted at or after deletedAfter.
+	RestoreUser(ctx context.Context, id string, deletedAfter time.Time) error
+	// PurgeDeletedUsers permanently removes users soft-deleted before deletedBefore.
+	PurgeDeletedUsers(ctx context.Context, deletedBefore time.Time) (int, error)
+
+	// ListUsersByStatus returns users with the given status, oldest first,
+	// and how many there are in total.
+	ListUsersByStatus(ctx context.Context, status string, limit, offset int) ([]*User, int, error)
+	// SetUserStatus changes the status of a user, returning ErrUserNotFound
+	// unless it currently has status from.
+	SetUserStatus(ctx context.Context, id, from, to string) error
 }
 
 // AccountStorage defines account-related database operations
 type AccountStorage interface {
-	CreateAccount(a *Account) error
-	GetAccountByID(id string) (*Account, error)
-	GetAccountByUserAndProvider(userID, providerID string) ([]*Account, error)
-	UpdateAccount(a *Account) error
-	DeleteAccount(id string) error
+	// CreateAccount stores a, returning ErrAccountLinked when the provider
+	// account a.ProviderID and a.AccountID name is already another account's.
+	CreateAccount(ctx context.Context, a *Account) error
+	GetAccountByID(ctx context.Context, id string) (*Account, error)
+	GetAccountByUserAndProvider(ctx context.Context, userID, providerID string) ([]*Account, error)
+	// GetAccountByProvider finds the account a provider knows by accountID,
+	// returning ErrUserNotFound if there is none.
+	GetAccountByProvider(ctx context.Context, providerID, accountID string) (*Account, error)
+	UpdateAccount(ctx context.Context, a *Account) error
+	DeleteAccount(ctx context.Context, id string) error
+
+	// GetExpiringAccounts returns up to limit accounts holding a refresh
+	// token whose access token expires before the given time, soonest first.
+	GetExpiringAccounts(ctx context.Context, before time.Time, limit int) ([]*Account, error)
 }
 
 type StorageProvider interface {
 	UserStorage
 	AccountStorage
 	SessionStorage
+	RefreshTokenStorage
+	UserSecurityStorage
+	VerificationTokenStorage
+
+	// GetSessionWithUserByHash returns the session for tokenHash along with
+	// its user in one round trip, for the lookup every authenticated request
+	// makes. The user is nil when it has been deleted or soft-deleted.
+	GetSessionWithUserByHash(ctx context.Context, tokenHash string) (*Session, *User, error)
+}
+
+// StorageCapabilities lists optional behaviour a storage provider may lack.
+// Features that depend on a missing capability are degraded or, when
+// explicitly configured, refused at startup.
+type StorageCapabilities struct {
+	// Transactions is set when multi-row changes, such as activating an
+	// organization invite, are applied atomically
+	Transactions bool
+	// SoftDelete is set when SoftDeleteUser keeps the row, so RestoreUser
+	// can bring the user back
+	SoftDelete bool
+	// Search is set when SearchSessions filters and pages sessions across
+	// all users
+	Search bool
+}
+
+// CapabilityReporter is implemented by storage providers that report their
+// StorageCapabilities
+type CapabilityReporter interface {
+	Capabilities() StorageCapabilities
+}
+
+// CapabilitiesOf returns what storage reports, assuming providers that
+// don't implement CapabilityReporter support everything
+func CapabilitiesOf(storage any) StorageCapabilities {
+	if reporter, ok := storage.(CapabilityReporter); ok {
+		return reporter.Capabilities()
+	}
+	return StorageCapabilities{Transactions: true, SoftDelete: true, Search: true}
+}
+
+// DeletedUserReader is implemented by storage providers that can read the
+// soft-deleted users UserStorage lookups hide. Sharded storage uses it to
+// find which shard holds a deleted user.
+type DeletedUserReader interface {
+	// GetUserByIDIncludingDeleted returns the user with id, soft-deleted or
+	// not, or ErrUserNotFound
+	GetUserByIDIncludingDeleted(ctx context.Context, id string) (*User, error)
+}
+
+// Migrator is implemented by storage providers that can create and upgrade
+// their own schema with the bundled migrations
+type Migrator interface {
+	// Migrate applies the migrations the database hasn't applied yet
+	Migrate(ctx context.Context) error
 }
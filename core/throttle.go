@@ -0,0 +1,37 @@
+package core
+
+import "time"
+
+// AttemptStore counts failed attempts per key over a sliding window.
+// Implementations backed by a shared cache (Redis, memcached, ...) make
+// throttling hold across instances.
+type AttemptStore interface {
+	// RecordFailure adds a failure for key and returns how many failures
+	// fall within the last window, including this one.
+	RecordFailure(key string, window time.Duration) (int, error)
+	// Failures returns how many failures for key fall within the last window.
+	Failures(key string, window time.Duration) (int, error)
+	// Reset forgets all failures for key.
+	Reset(key string) error
+}
+
+// ThrottleConfig configures sign-in throttling per email + IP address pair.
+//
+// Keying on the pair slows credential stuffing against one account without
+// locking out everyone behind a shared NAT, and without letting an attacker
+// lock a victim out from their own network. Zero values use the defaults.
+type ThrottleConfig struct {
+	// Window is how long a failed attempt counts. Defaults to 15 minutes.
+	Window time.Duration
+	// FreeAttempts is how many failures are allowed before delays start.
+	// Defaults to 3.
+	FreeAttempts int
+	// BaseDelay is the delay after the first throttled failure; it doubles
+	// with each further failure. Defaults to 1 second.
+	BaseDelay time.Duration
+	// MaxDelay caps the delay. Defaults to 30 seconds.
+	MaxDelay time.Duration
+	// LockoutAttempts is how many failures reject sign-in outright until
+	// the window has passed. Defaults to 10.
+	LockoutAttempts int
+}
@@ -0,0 +1,28 @@
+package core
+
+// DefaultTokenHeader is the conventional response header for delivering the
+// session token outside the JSON body
+const DefaultTokenHeader = "X-Session-Token"
+
+// DetachSessionToken splits the session token off a sign-up, sign-in or
+// refresh result, returning it with a copy of the result that omits it, so
+// adapters can send the token in a response header instead of the body.
+// Other values are returned unchanged with an empty token.
+func DetachSessionToken(result any) (string, any) {
+	switch r := result.(type) {
+	case *SignUpResult:
+		detached := *r
+		detached.Token = ""
+		return r.Token, &detached
+	case *SignInResult:
+		detached := *r
+		detached.Token = ""
+		return r.Token, &detached
+	case *RefreshResult:
+		detached := *r
+		detached.Token = ""
+		return r.Token, &detached
+	default:
+		return "", result
+	}
+}
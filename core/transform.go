@@ -0,0 +1,131 @@
+package core
+
+import (
+	"encoding/json"
+	"strings"
+	"unicode"
+)
+
+// RequestDecoder decodes a raw request body into out, the endpoint's request
+// type (SignUpRequest, SignInRequest, ...)
+type RequestDecoder func(body []byte, out any) error
+
+// ResponseEncoder rewrites a successful response body before it is enveloped
+// and serialized, e.g. to rename keys or add computed fields
+type ResponseEncoder func(body any) (any, error)
+
+// FieldNaming is the key style of request and response bodies
+type FieldNaming string
+
+const (
+	// FieldNamingCamelCase uses kuta's JSON tags as is, e.g. "userId"
+	FieldNamingCamelCase FieldNaming = "camelCase"
+	// FieldNamingSnakeCase renames every key to snake_case, e.g. "user_id"
+	FieldNamingSnakeCase FieldNaming = "snake_case"
+)
+
+// PayloadTransforms customizes payload shapes per endpoint, keyed by
+// OperationID. Endpoints without an entry use plain JSON in the FieldNaming
+// style.
+type PayloadTransforms struct {
+	Decoders map[string]RequestDecoder
+	Encoders map[string]ResponseEncoder
+
+	// FieldNaming applies to every endpoint without its own decoder or
+	// encoder, so apps with snake_case APIs can adopt kuta's models
+	// unchanged. Defaults to FieldNamingCamelCase.
+	FieldNaming FieldNaming
+}
+
+// Decoder returns the request decoder registered for operationID, or the
+// one FieldNaming calls for, if any
+func (t PayloadTransforms) Decoder(operationID string) (RequestDecoder, bool) {
+	if decoder, ok := t.Decoders[operationID]; ok {
+		return decoder, true
+	}
+	if t.FieldNaming == FieldNamingSnakeCase {
+		return SnakeCaseDecoder, true
+	}
+	return nil, false
+}
+
+// Encode applies the response encoder registered for operationID, or the one
+// FieldNaming calls for, if any
+func (t PayloadTransforms) Encode(operationID string, body any) (any, error) {
+	if encoder, ok := t.Encoders[operationID]; ok {
+		return encoder(body)
+	}
+	if t.FieldNaming == FieldNamingSnakeCase {
+		return SnakeCaseEncoder(body)
+	}
+	return body, nil
+}
+
+// SnakeCaseDecoder decodes snake_case JSON keys into kuta's camelCase request types
+func SnakeCaseDecoder(body []byte, out any) error {
+	var raw any
+	if err := json.Unmarshal(body, &raw); err != nil {
+		return err
+	}
+	converted, err := json.Marshal(renameKeys(raw, snakeToCamel))
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(converted, out)
+}
+
+// SnakeCaseEncoder rewrites response keys from camelCase to snake_case
+func SnakeCaseEncoder(body any) (any, error) {
+	encoded, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+	var raw any
+	if err := json.Unmarshal(encoded, &raw); err != nil {
+		return nil, err
+	}
+	return renameKeys(raw, camelToSnake), nil
+}
+
+// renameKeys renames the object keys of a decoded JSON value at any depth
+func renameKeys(value any, rename func(string) string) any {
+	switch v := value.(type) {
+	case map[string]any:
+		renamed := make(map[string]any, len(v))
+		for key, item := range v {
+			renamed[rename(key)] = renameKeys(item, rename)
+		}
+		return renamed
+	case []any:
+		for i, item := range v {
+			v[i] = renameKeys(item, rename)
+		}
+		return v
+	default:
+		return v
+	}
+}
+
+func camelToSnake(s string) string {
+	var b strings.Builder
+	for i, r := range s {
+		if unicode.IsUpper(r) {
+			if i > 0 {
+				b.WriteByte('_')
+			}
+			r = unicode.ToLower(r)
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+func snakeToCamel(s string) string {
+	parts := strings.Split(s, "_")
+	for i := 1; i < len(parts); i++ {
+		if parts[i] != "" {
+			parts[i] = strings.ToUpper(parts[i][:1]) + parts[i][1:]
+		}
+	}
+	return strings.Join(parts, "")
+}
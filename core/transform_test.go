@@ -0,0 +1,93 @@
+package core
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+// Requirement: Snake-case transforms map between snake_case payloads and
+// kuta's camelCase types at any depth.
+func TestSnakeCaseTransforms(t *testing.T) {
+	// Arrange
+	body := []byte(`{"email":"a@b.c","password":"pw","public_key":"pk"}`)
+	response := SessionData{User: &User{ID: "u1", EmailVerified: true}}
+
+	// Act
+	var req SignInRequest
+	decodeErr := SnakeCaseDecoder(body, &req)
+	encoded, encodeErr := SnakeCaseEncoder(response)
+
+	// Assert
+	if decodeErr != nil || req.PublicKey != "pk" || req.Email != "a@b.c" {
+		t.Errorf("SnakeCaseDecoder = %+v, %v", req, decodeErr)
+	}
+	if encodeErr != nil {
+		t.Fatalf("SnakeCaseEncoder error: %v", encodeErr)
+	}
+	user := encoded.(map[string]any)["user"].(map[string]any)
+	if user["email_verified"] != true || user["id"] != "u1" {
+		t.Errorf("expected snake_case user keys, got %v", user)
+	}
+	if _, ok := user["emailVerified"]; ok {
+		t.Error("expected camelCase key to be renamed")
+	}
+	if got := camelToSnake("createdAt"); got != "created_at" {
+		t.Errorf("camelToSnake = %q", got)
+	}
+}
+
+// Requirement: FieldNaming switches endpoints without their own transform
+// to snake_case, while per-endpoint encoders still take precedence.
+func TestPayloadTransforms_FieldNaming(t *testing.T) {
+	custom := func(body any) (any, error) { return "custom", nil }
+	tests := []struct {
+		name        string
+		naming      FieldNaming
+		operationID string
+		wantKey     string
+		wantDecoder bool
+	}{
+		{name: "camelCase by default", operationID: OperationGetSession, wantKey: "userId"},
+		{name: "explicit camelCase", naming: FieldNamingCamelCase, operationID: OperationGetSession, wantKey: "userId"},
+		{name: "snake_case", naming: FieldNamingSnakeCase, operationID: OperationGetSession, wantKey: "user_id", wantDecoder: true},
+		{name: "endpoint encoder wins", naming: FieldNamingSnakeCase, operationID: OperationSignIn, wantDecoder: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			transforms := PayloadTransforms{
+				Encoders:    map[string]ResponseEncoder{OperationSignIn: custom},
+				FieldNaming: tt.naming,
+			}
+			session := &Session{ID: "s1", UserID: "u1"}
+
+			// Act
+			encoded, err := transforms.Encode(tt.operationID, session)
+			_, hasDecoder := transforms.Decoder(tt.operationID)
+
+			// Assert
+			if err != nil {
+				t.Fatalf("Encode error: %v", err)
+			}
+			if hasDecoder != tt.wantDecoder {
+				t.Errorf("Decoder found = %v, want %v", hasDecoder, tt.wantDecoder)
+			}
+			if tt.wantKey == "" {
+				if encoded != "custom" {
+					t.Errorf("Encode = %v, want the endpoint encoder's output", encoded)
+				}
+				return
+			}
+			raw, err := json.Marshal(encoded)
+			if err != nil {
+				t.Fatalf("Marshal error: %v", err)
+			}
+			var keys map[string]any
+			_ = json.Unmarshal(raw, &keys)
+			if keys[tt.wantKey] != "u1" {
+				t.Errorf("expected key %q in %s", tt.wantKey, raw)
+			}
+		})
+	}
+}
@@ -0,0 +1,50 @@
+package core
+
+import "context"
+
+// TOTPProviderID is the Account.ProviderID of authenticator app (TOTP)
+// enrollments. The account holds the secret in AccessToken, encrypted like
+// provider tokens, and the hashes of unused backup codes in Password.
+// It is a second factor, not a way to sign in, so it isn't listed with the
+// user's accounts.
+const TOTPProviderID = "totp"
+
+// TwoFactorConfig enables two-factor authentication with authenticator apps
+type TwoFactorConfig struct {
+	// Issuer names the app in authenticator apps. Defaults to "kuta".
+	Issuer string
+	// BackupCodes is how many single-use backup codes enrollment issues,
+	// for users who lose their authenticator. Defaults to 10.
+	BackupCodes int
+}
+
+// TOTPSetup is what a user needs to add kuta to an authenticator app
+type TOTPSetup struct {
+	// URI is the otpauth:// URI, usually shown as a QR code
+	URI string `json:"uri"`
+	// Secret is the base32 secret, for typing into the app by hand
+	Secret string `json:"secret"`
+	// BackupCodes each answer one two-factor challenge in place of a code.
+	// They are only shown here.
+	BackupCodes []string `json:"backupCodes"`
+}
+
+// VerifyTOTPRequest is the body of POST /2fa/totp/verify
+type VerifyTOTPRequest struct {
+	Code string `json:"code"`
+}
+
+// TwoFactorProvider manages two-factor enrollment. Adapters mount the 2FA
+// endpoints when the auth provider implements it and TOTPEnabled is true.
+// Enrolled users then get a ChallengeTwoFactor challenge at sign-in, which
+// they answer with a code from the app or a backup code.
+type TwoFactorProvider interface {
+	TOTPEnabled() bool
+	// SetupTOTP starts enrolling an authenticator app, replacing an
+	// enrollment that was never verified. It returns ErrTwoFactorEnabled
+	// when 2FA is already on.
+	SetupTOTP(ctx context.Context, userID string) (*TOTPSetup, error)
+	// VerifyTOTP turns 2FA on once the user proves the app is set up by
+	// sending a current code
+	VerifyTOTP(ctx context.Context, userID, code string) error
+}
@@ -6,11 +6,41 @@ import "time"
 //
 // This is the "identity" - who someone is
 type User struct {
-	ID            string    `json:"id"`
-	Email         string    `json:"email"`
-	EmailVerified bool      `json:"emailVerified"`
-	Name          string    `json:"name"`
-	Image         *string   `json:"image,omitempty"`
-	CreatedAt     time.Time `json:"createdAt"`
-	UpdatedAt     time.Time `json:"updatedAt"`
+	ID            string  `json:"id"`
+	Email         string  `json:"email"`
+	EmailVerified bool    `json:"emailVerified"`
+	Name          string  `json:"name"`
+	Image         *string `json:"image,omitempty"`
+	// Status is one of the UserStatus* constants; empty means active
+	Status    string     `json:"status,omitempty"`
+	CreatedAt time.Time  `json:"createdAt"`
+	UpdatedAt time.Time  `json:"updatedAt"`
+	DeletedAt *time.Time `json:"deletedAt,omitempty"` // Set when soft-deleted
+}
+
+// User statuses. Users awaiting approval or rejected can't sign in.
+// Anonymous users stand behind guest sessions until they sign up.
+const (
+	UserStatusActive    = "active"
+	UserStatusPending   = "pending_approval"
+	UserStatusRejected  = "rejected"
+	UserStatusAnonymous = "anonymous"
+)
+
+// Active reports whether the user may sign in
+func (u *User) Active() bool {
+	return u.Status == "" || u.Status == UserStatusActive
+}
+
+// Anonymous reports whether the user is the placeholder of a guest session
+func (u *User) Anonymous() bool {
+	return u.Status == UserStatusAnonymous
+}
+
+// UserPage is one page of users
+type UserPage struct {
+	Users  []*User `json:"users"`
+	Total  int     `json:"total"`
+	Limit  int     `json:"limit"`
+	Offset int     `json:"offset"`
 }
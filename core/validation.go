@@ -0,0 +1,92 @@
+package core
+
+import (
+	"errors"
+	"strings"
+)
+
+// Field error codes, in "<field>.<problem>" form
+const (
+	CodeEmailRequired        = "email.required"
+	CodeEmailInvalid         = "email.invalid"
+	CodePasswordRequired     = "password.required"
+	CodePasswordTooShort     = "password.too_short"
+	CodePasswordTooLong      = "password.too_long"
+	CodePublicKeyInvalid     = "publicKey.invalid"
+	CodeRoleRequired         = "role.required"
+	CodeOrganizationRequired = "organizationId.required"
+	CodeNameRequired         = "name.required"
+	CodeScopeInvalid         = "scopes.invalid"
+	CodeExpiresAtInvalid     = "expiresAt.invalid"
+)
+
+// FieldError describes one invalid field of a request. Field is the JSON
+// path of the field, e.g. "email" or "newPassword".
+type FieldError struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+
+	// Err is the sentinel error the problem is reported as elsewhere, e.g.
+	// ErrEmailRequired, so errors.Is keeps working on ValidationError
+	Err error `json:"-"`
+}
+
+// NewFieldError builds a FieldError for field whose message is err's
+func NewFieldError(field, code string, err error) FieldError {
+	return FieldError{Field: field, Code: code, Message: err.Error(), Err: err}
+}
+
+// ValidationError lists every invalid field of a request, so front-ends can
+// show all problems at once and attach them to form fields. It matches
+// ErrValidationFailed and the Err of each field with errors.Is.
+type ValidationError struct {
+	Fields []FieldError
+}
+
+func (e *ValidationError) Error() string {
+	messages := make([]string, len(e.Fields))
+	for i, field := range e.Fields {
+		messages[i] = field.Message
+	}
+	return strings.Join(messages, "; ")
+}
+
+func (e *ValidationError) Unwrap() []error {
+	errs := []error{ErrValidationFailed}
+	for _, field := range e.Fields {
+		if field.Err != nil {
+			errs = append(errs, field.Err)
+		}
+	}
+	return errs
+}
+
+// Validator collects field errors while a request is checked
+type Validator struct {
+	fields []FieldError
+}
+
+// Add records a problem with field
+func (v *Validator) Add(field, code string, err error) {
+	v.fields = append(v.fields, NewFieldError(field, code, err))
+}
+
+// Err returns a *ValidationError with the recorded problems, or nil if
+// there were none
+func (v *Validator) Err() error {
+	if len(v.fields) == 0 {
+		return nil
+	}
+	return &ValidationError{Fields: v.fields}
+}
+
+// FieldErrors returns the field errors carried by err, if it is or wraps a
+// *ValidationError
+func FieldErrors(err error) []FieldError {
+	var validation *ValidationError
+	if errors.As(err, &validation) {
+		return validation.Fields
+	}
+	return nil
+}
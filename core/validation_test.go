@@ -0,0 +1,61 @@
+package core
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+)
+
+// Requirement: Validation errors list every invalid field, keep matching
+// their sentinel errors, and reach the response body through envelopes that
+// support field errors.
+func TestValidationError_ErrorBody(t *testing.T) {
+	var v Validator
+	v.Add("email", CodeEmailInvalid, ErrInvalidEmail)
+	v.Add("password", CodePasswordTooShort, ErrPasswordTooShort)
+	err := v.Err()
+
+	tests := []struct {
+		name       string
+		envelope   ResponseEnvelope
+		wantFields func(body any) []FieldError
+	}{
+		{
+			name:       "bare envelope",
+			envelope:   BareEnvelope{},
+			wantFields: func(body any) []FieldError { return body.(ErrorResponse).Errors },
+		},
+		{
+			name:       "data envelope",
+			envelope:   DataEnvelope{},
+			wantFields: func(body any) []FieldError { return body.(EnvelopedResponse).Error.Fields },
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Act
+			status, body := ErrorBody(tt.envelope, err)
+
+			// Assert
+			if status != http.StatusBadRequest {
+				t.Errorf("status = %d, want 400", status)
+			}
+			fields := tt.wantFields(body)
+			if len(fields) != 2 || fields[0].Field != "email" || fields[1].Code != CodePasswordTooShort {
+				t.Errorf("fields = %+v", fields)
+			}
+		})
+	}
+
+	if !errors.Is(err, ErrInvalidEmail) || !errors.Is(err, ErrPasswordTooShort) || !errors.Is(err, ErrValidationFailed) {
+		t.Errorf("expected %v to match its field and validation sentinels", err)
+	}
+	if got := err.Error(); got != "invalid email format; password is too short" {
+		t.Errorf("Error() = %q", got)
+	}
+	var none Validator
+	if none.Err() != nil {
+		t.Error("expected nil error without field errors")
+	}
+}
@@ -0,0 +1,31 @@
+package core
+
+import "time"
+
+// OriginStore tracks the distinct origins (IP addresses, ASNs) seen per key
+// over a sliding window. Implementations backed by a shared cache make
+// velocity tracking hold across instances.
+type OriginStore interface {
+	// RecordOrigin notes origin for key and returns how many distinct
+	// origins fall within the last window, including this one.
+	RecordOrigin(key, origin string, window time.Duration) (int, error)
+}
+
+// ASNResolver maps an IP address to its autonomous system number (e.g. via a
+// GeoLite2 ASN database). It returns "" when the ASN is unknown.
+type ASNResolver func(ipAddress string) string
+
+// VelocityConfig configures per-user sign-in velocity tracking. When a user
+// signs in from more distinct IPs or networks within Window than allowed, an
+// EventSecurityAnomaly is emitted. Zero values use the defaults.
+type VelocityConfig struct {
+	// Window is how long a sign-in origin counts. Defaults to 1 hour.
+	Window time.Duration
+	// MaxDistinctIPs is how many IP addresses are normal. Defaults to 5.
+	MaxDistinctIPs int
+	// MaxDistinctASNs is how many networks are normal. Defaults to 3.
+	// Only checked when ASNResolver is set.
+	MaxDistinctASNs int
+	// ASNResolver enables tracking networks in addition to IP addresses
+	ASNResolver ASNResolver
+}
@@ -0,0 +1,47 @@
+package core
+
+import (
+	"context"
+	"time"
+)
+
+// Purposes of verification tokens
+const (
+	VerificationEmail         = "email_verification"
+	VerificationPasswordReset = "password_reset"
+	VerificationInvite        = "invite"
+)
+
+// VerificationToken is a single-use token sent out of band, usually by email,
+// to verify an address, reset a password or accept an invite
+type VerificationToken struct {
+	ID     string  `json:"id"`
+	UserID *string `json:"userId,omitempty"` // nil for invites to people without an account
+	// Identifier is what the token vouches for, e.g. an email address
+	Identifier string     `json:"identifier"`
+	Purpose    string     `json:"purpose"`
+	TokenHash  string     `json:"-"` // Never expose in JSON (security!)
+	ExpiresAt  time.Time  `json:"expiresAt"`
+	ConsumedAt *time.Time `json:"consumedAt,omitempty"`
+	CreatedAt  time.Time  `json:"createdAt"`
+}
+
+// VerificationTokenStorage defines verification token database operations.
+//
+// Consumed tokens are kept for a while so a second click on an emailed link
+// can be told apart from a forged one; PurgeVerificationTokens removes them.
+type VerificationTokenStorage interface {
+	CreateVerificationToken(ctx context.Context, token *VerificationToken) error
+	// ConsumeVerificationToken marks the unexpired, unconsumed token with
+	// tokenHash and purpose consumed and returns it. It returns
+	// ErrVerificationTokenNotFound otherwise, which makes tokens single-use
+	// under concurrency.
+	ConsumeVerificationToken(ctx context.Context, tokenHash, purpose string) (*VerificationToken, error)
+	// RevokeUserVerificationTokens marks the user's unexpired, unconsumed
+	// tokens with one of purposes consumed, so they can no longer be used,
+	// and returns how many it revoked.
+	RevokeUserVerificationTokens(ctx context.Context, userID string, purposes ...string) (int, error)
+	// PurgeVerificationTokens deletes tokens that expired before
+	// expiredBefore or were consumed before consumedBefore.
+	PurgeVerificationTokens(ctx context.Context, expiredBefore, consumedBefore time.Time) (int, error)
+}
@@ -0,0 +1,106 @@
+package kuta
+
+import (
+	"github.com/lborres/kuta/core"
+	"github.com/lborres/kuta/services"
+)
+
+type (
+	Diagnostic         = core.Diagnostic
+	DiagnosticSeverity = core.DiagnosticSeverity
+	DiagnosticsReport  = core.DiagnosticsReport
+	Pinger             = core.Pinger
+	ConfigDiagnoser    = core.ConfigDiagnoser
+)
+
+const (
+	DiagnosticSecret   = core.DiagnosticSecret
+	DiagnosticDatabase = core.DiagnosticDatabase
+	DiagnosticCache    = core.DiagnosticCache
+	DiagnosticHTTP     = core.DiagnosticHTTP
+	DiagnosticCookie   = core.DiagnosticCookie
+	DiagnosticCORS     = core.DiagnosticCORS
+	DiagnosticSession  = core.DiagnosticSession
+	DiagnosticIDs      = core.DiagnosticIDs
+
+	SeverityError   = core.SeverityError
+	SeverityWarning = core.SeverityWarning
+)
+
+// minSecretDistinctChars flags secrets like "aaaa..." that pass the length
+// check with little entropy
+const minSecretDistinctChars = 10
+
+// ValidateConfig dry-runs config without building a Kuta: it checks the
+// secret, pings the database and cache when they implement Pinger, checks
+// the storage supports the configured features, and asks the HTTP adapter
+// about its cookie and CORS options when it implements ConfigDiagnoser.
+// Use report.Err() to fail CI or startup on errors while only logging
+// warnings.
+func ValidateConfig(config Config) DiagnosticsReport {
+	var report DiagnosticsReport
+
+	checkSecret(&report, config)
+
+	if config.Database == nil {
+		report.Add(DiagnosticDatabase, SeverityError, "%v", core.ErrDBAdapterRequired)
+	} else if pinger, ok := config.Database.(Pinger); ok {
+		if err := pinger.Ping(); err != nil {
+			report.Add(DiagnosticDatabase, SeverityError, "unreachable: %v", err)
+		}
+	}
+
+	if err := checkStorageCapabilities(config); err != nil {
+		report.Add(DiagnosticDatabase, SeverityError, "%v", err)
+	}
+
+	if cacheProvider, err := resolveCache(config); err != nil {
+		report.Add(DiagnosticCache, SeverityError, "%v", err)
+	} else if pinger, ok := cacheProvider.(Pinger); ok {
+		if err := pinger.Ping(); err != nil {
+			report.Add(DiagnosticCache, SeverityError, "unreachable: %v", err)
+		}
+	}
+
+	if config.HTTP == nil {
+		report.Add(DiagnosticHTTP, SeverityError, "%v", core.ErrHTTPAdapterRequired)
+	} else if diagnoser, ok := config.HTTP.(ConfigDiagnoser); ok {
+		report.Diagnostics = append(report.Diagnostics, diagnoser.DiagnoseConfig()...)
+	}
+
+	if config.SessionConfig != nil && config.SessionConfig.MaxAge <= 0 {
+		report.Add(DiagnosticSession, SeverityError, "SessionConfig.MaxAge must be positive, sessions would expire immediately")
+	}
+
+	if _, err := services.NewIDGenerators(config.IDs); err != nil {
+		report.Add(DiagnosticIDs, SeverityError, "%v", err)
+	}
+
+	return report
+}
+
+func checkSecret(report *DiagnosticsReport, config Config) {
+	switch {
+	case config.Secret == "":
+		report.Add(DiagnosticSecret, SeverityError, "%v", core.ErrSecretRequired)
+		return
+	case len(config.Secret) < defaultSecretLen:
+		report.Add(DiagnosticSecret, SeverityError, "%v - minimum of %d characters", core.ErrSecretTooShort, defaultSecretLen)
+	case distinctChars(config.Secret) < minSecretDistinctChars:
+		report.Add(DiagnosticSecret, SeverityWarning, "secret uses fewer than %d distinct characters; generate a random one", minSecretDistinctChars)
+	}
+
+	for i, previous := range config.PreviousSecrets {
+		if previous == config.Secret {
+			report.Add(DiagnosticSecret, SeverityWarning, "PreviousSecrets[%d] is the current secret", i)
+		}
+	}
+}
+
+func distinctChars(s string) int {
+	seen := make(map[rune]struct{})
+	for _, r := range s {
+		seen[r] = struct{}{}
+	}
+	return len(seen)
+}
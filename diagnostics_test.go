@@ -0,0 +1,110 @@
+package kuta
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lborres/kuta/core"
+	"github.com/lborres/kuta/services"
+)
+
+type pingingStorage struct {
+	*services.FakeStorageProvider
+	err error
+}
+
+func (s pingingStorage) Ping() error { return s.err }
+
+type diagnosingHTTP struct {
+	diagnostics []Diagnostic
+}
+
+func (diagnosingHTTP) RegisterRoutes(core.AuthProvider, string, time.Duration) error { return nil }
+func (diagnosingHTTP) BuildProtectedMiddleware(core.AuthProvider) interface{}        { return nil }
+func (h diagnosingHTTP) DiagnoseConfig() []Diagnostic                                { return h.diagnostics }
+
+// Requirement: ValidateConfig reports secret, storage, cache, HTTP adapter and
+// session problems with a check name and severity, without building Kuta.
+func TestValidateConfig(t *testing.T) {
+	const secret = "0123456789abcdefghijklmnopqrstuvwxyz"
+	valid := func() Config {
+		return Config{
+			Secret:   secret,
+			Database: pingingStorage{FakeStorageProvider: services.NewFakeStorageProvider()},
+			HTTP:     diagnosingHTTP{},
+		}
+	}
+
+	tests := []struct {
+		name         string
+		modify       func(c *Config)
+		wantCheck    string
+		wantSeverity DiagnosticSeverity
+	}{
+		{name: "valid", modify: func(c *Config) {}},
+		{name: "missing secret", modify: func(c *Config) { c.Secret = "" }, wantCheck: DiagnosticSecret, wantSeverity: SeverityError},
+		{name: "short secret", modify: func(c *Config) { c.Secret = "short" }, wantCheck: DiagnosticSecret, wantSeverity: SeverityError},
+		{name: "low entropy secret", modify: func(c *Config) { c.Secret = strings.Repeat("ab", 20) }, wantCheck: DiagnosticSecret, wantSeverity: SeverityWarning},
+		{name: "secret reused as previous", modify: func(c *Config) { c.PreviousSecrets = []string{secret} }, wantCheck: DiagnosticSecret, wantSeverity: SeverityWarning},
+		{name: "missing database", modify: func(c *Config) { c.Database = nil }, wantCheck: DiagnosticDatabase, wantSeverity: SeverityError},
+		{
+			name: "unreachable database",
+			modify: func(c *Config) {
+				c.Database = pingingStorage{FakeStorageProvider: services.NewFakeStorageProvider(), err: errors.New("connection refused")}
+			},
+			wantCheck:    DiagnosticDatabase,
+			wantSeverity: SeverityError,
+		},
+		{
+			name:         "conflicting cache settings",
+			modify:       func(c *Config) { c.DisableCache = true; c.CacheProvider = services.NewFakeCache() },
+			wantCheck:    DiagnosticCache,
+			wantSeverity: SeverityError,
+		},
+		{
+			name: "adapter findings are included",
+			modify: func(c *Config) {
+				c.HTTP = diagnosingHTTP{diagnostics: []Diagnostic{{Check: DiagnosticCookie, Severity: SeverityWarning, Message: "insecure"}}}
+			},
+			wantCheck:    DiagnosticCookie,
+			wantSeverity: SeverityWarning,
+		},
+		{
+			name:         "non-positive session max age",
+			modify:       func(c *Config) { c.SessionConfig = &core.SessionConfig{} },
+			wantCheck:    DiagnosticSession,
+			wantSeverity: SeverityError,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			config := valid()
+			tt.modify(&config)
+
+			// Act
+			report := ValidateConfig(config)
+
+			// Assert
+			if tt.wantCheck == "" {
+				if len(report.Diagnostics) != 0 {
+					t.Fatalf("expected no diagnostics, got %+v", report.Diagnostics)
+				}
+				return
+			}
+			if len(report.Diagnostics) != 1 {
+				t.Fatalf("expected one diagnostic, got %+v", report.Diagnostics)
+			}
+			got := report.Diagnostics[0]
+			if got.Check != tt.wantCheck || got.Severity != tt.wantSeverity {
+				t.Errorf("diagnostic = %+v, want check %q severity %q", got, tt.wantCheck, tt.wantSeverity)
+			}
+			if wantOK := tt.wantSeverity == SeverityWarning; report.OK() != wantOK {
+				t.Errorf("OK() = %v, want %v (err %v)", report.OK(), wantOK, report.Err())
+			}
+		})
+	}
+}
@@ -1,84 +1,451 @@
 package kuta
 
 import (
+	"context"
 	"fmt"
+	"io"
 	"time"
 
 	"github.com/lborres/kuta/core"
 	"github.com/lborres/kuta/pkg/cache"
 	"github.com/lborres/kuta/pkg/crypto"
+	"github.com/lborres/kuta/pkg/mailtemplate"
+	"github.com/lborres/kuta/pkg/ratelimit"
+	"github.com/lborres/kuta/pkg/revocation"
 	"github.com/lborres/kuta/services"
 )
 
 type (
-	StorageProvider  = core.StorageProvider
-	AuthProvider     = core.AuthProvider
-	Cache            = core.Cache
-	HTTPProvider     = core.HTTPProvider
-	EndpointProvider = core.EndpointProvider
-	Endpoint         = core.Endpoint
-	RequestContext   = core.RequestContext
-	EndpointMetadata = core.EndpointMetadata
-
-	// SessionManager = services.SessionManager
-
-	PasswordHandler = crypto.PasswordHandler
+	StorageProvider          = core.StorageProvider
+	StorageCapabilities      = core.StorageCapabilities
+	CapabilityReporter       = core.CapabilityReporter
+	DeletedUserReader        = core.DeletedUserReader
+	Migrator                 = core.Migrator
+	RefreshTokenStorage      = core.RefreshTokenStorage
+	AuthProvider             = core.AuthProvider
+	AuthService              = core.AuthService
+	Cache                    = core.Cache
+	HTTPProvider             = core.HTTPProvider
+	EndpointProvider         = core.EndpointProvider
+	Endpoint                 = core.Endpoint
+	Request                  = core.Request
+	Response                 = core.Response
+	RequestContext           = core.RequestContext
+	EndpointMetadata         = core.EndpointMetadata
+	RouteGroup               = core.RouteGroup
+	EventHandler             = core.EventHandler
+	EventHandlerFunc         = core.EventHandlerFunc
+	AdminProvider            = core.AdminProvider
+	AdminAuthorizer          = core.AdminAuthorizer
+	ProviderDiscovery        = core.ProviderDiscovery
+	OAuthProvider            = core.OAuthProvider
+	AfterSignInHook          = core.AfterSignInHook
+	OAuthSignIn              = core.OAuthSignIn
+	OAuthStateStore          = core.OAuthStateStore
+	OAuthTokenRevoker        = core.OAuthTokenRevoker
+	ProofVerifier            = core.ProofVerifier
+	TokenRefresher           = core.TokenRefresher
+	TokenRefresherFunc       = core.TokenRefresherFunc
+	ErrorStatusFunc          = core.ErrorStatusFunc
+	SessionCodec             = core.SessionCodec
+	RevocationBus            = core.RevocationBus
+	AttemptStore             = core.AttemptStore
+	OriginStore              = core.OriginStore
+	RateLimiter              = core.RateLimiter
+	RateLimitDecision        = core.RateLimitDecision
+	RedisClient              = ratelimit.RedisClient
+	RedisEvalFunc            = ratelimit.RedisEvalFunc
+	SignInChallenger         = core.SignInChallenger
+	PendingSignInStore       = core.PendingSignInStore
+	SessionQueue             = core.SessionQueue
+	IdempotencyStore         = core.IdempotencyStore
+	IdempotentRequests       = core.IdempotentRequests
+	RecordedResponse         = core.RecordedResponse
+	SignInLog                = core.SignInLog
+	ActivityProvider         = core.ActivityProvider
+	RoleStorage              = core.RoleStorage
+	OrganizationStorage      = core.OrganizationStorage
+	OrganizationProvider     = core.OrganizationProvider
+	AccessTokenStorage       = core.AccessTokenStorage
+	AccessTokenProvider      = core.AccessTokenProvider
+	PasswordChanger          = core.PasswordChanger
+	AccountLinker            = core.AccountLinker
+	TwoFactorProvider        = core.TwoFactorProvider
+	AnonymousSessionProvider = core.AnonymousSessionProvider
+	AccountEmailProvider     = core.AccountEmailProvider
+	EntitlementResolver      = core.EntitlementResolver
+	EntitlementResolverFunc  = core.EntitlementResolverFunc
+	ASNResolver              = core.ASNResolver
+	ImageStore               = core.ImageStore
+	Mailer                   = core.Mailer
+	EmailRenderer            = core.EmailRenderer
+	EmailTemplate            = mailtemplate.Template
+	UpgradePromptPolicy      = core.UpgradePromptPolicy
+	NoopImageStore           = core.NoopImageStore
+	ResponseEnvelope         = core.ResponseEnvelope
+	BareEnvelope             = core.BareEnvelope
+	DataEnvelope             = core.DataEnvelope
+	RequestDecoder           = core.RequestDecoder
+	ResponseEncoder          = core.ResponseEncoder
+
+	SessionManager = services.SessionManager
+
+	PasswordHandler  = crypto.PasswordHandler
+	PasswordVerifier = crypto.PasswordVerifier
+	HashObserver     = crypto.HashObserver
+	HashMetrics      = crypto.HashMetrics
+
+	PasswordUpgradePolicy = crypto.PasswordUpgradePolicy
 )
 
 type (
-	SessionConfig = core.SessionConfig
-	CacheConfig   = core.CacheConfig
+	SessionConfig   = core.SessionConfig
+	CacheConfig     = core.CacheConfig
+	CORSConfig      = core.CORSConfig
+	ThrottleConfig  = core.ThrottleConfig
+	VelocityConfig  = core.VelocityConfig
+	RateLimitConfig = core.RateLimitConfig
+	TwoFactorConfig = core.TwoFactorConfig
+	IDConfig        = core.IDConfig
+	EntityIDConfig  = core.EntityIDConfig
+
+	OnboardingConfig = core.OnboardingConfig
 )
 
 type (
-	User          = core.User
-	Account       = core.Account
-	Session       = core.Session
-	SessionData   = core.SessionData
-	CacheStats    = core.CacheStats
-	ErrorResponse = core.ErrorResponse
+	User              = core.User
+	Account           = core.Account
+	Session           = core.Session
+	RefreshToken      = core.RefreshToken
+	VerificationToken = core.VerificationToken
+	SessionData       = core.SessionData
+	CacheStats        = core.CacheStats
+	ConsistencyStats  = core.ConsistencyStats
+	Impersonation     = core.Impersonation
+	CleanupStats      = core.CleanupStats
+	ErrorResponse     = core.ErrorResponse
+	Event             = core.Event
+	EventType         = core.EventType
+	SessionFilter     = core.SessionFilter
+	SessionPage       = core.SessionPage
+	UserPage          = core.UserPage
+	ProviderStatus    = core.ProviderStatus
+	ProviderInfo      = core.ProviderInfo
+	ProvidersResponse = core.ProvidersResponse
+	OAuthCallback     = core.OAuthCallback
+	OAuthIdentity     = core.OAuthIdentity
+	OAuthStart        = core.OAuthStart
+	TOTPSetup         = core.TOTPSetup
+	OAuthMembership   = core.OAuthMembership
+	OAuthState        = core.OAuthState
+	AfterSignInInput  = core.AfterSignInInput
+	RequestProof      = core.RequestProof
+	Revocation        = core.Revocation
+	ProviderTokens    = core.ProviderTokens
+	ImageUpload       = core.ImageUpload
+	EmailData         = core.EmailData
+	EmailMessage      = core.EmailMessage
+
+	UserSecurity                   = core.UserSecurity
+	NotificationPreferences        = core.NotificationPreferences
+	SecurityPosture                = core.SecurityPosture
+	AuthChallenge                  = core.AuthChallenge
+	SignInAttempt                  = core.SignInAttempt
+	PendingSignIn                  = core.PendingSignIn
+	SignInRecord                   = core.SignInRecord
+	ActivityPage                   = core.ActivityPage
+	OrganizationMember             = core.OrganizationMember
+	Entitlements                   = core.Entitlements
+	PendingOrganizationMember      = core.PendingOrganizationMember
+	InviteOrganizationMemberInput  = core.InviteOrganizationMemberInput
+	OrganizationInviteResult       = core.OrganizationInviteResult
+	AcceptOrganizationInviteInput  = core.AcceptOrganizationInviteInput
+	AcceptOrganizationInviteResult = core.AcceptOrganizationInviteResult
+	AccessToken                    = core.AccessToken
+	CreateAccessTokenInput         = core.CreateAccessTokenInput
+	CreateAccessTokenResult        = core.CreateAccessTokenResult
+	AccessTokenData                = core.AccessTokenData
+
+	EnvelopedResponse = core.EnvelopedResponse
+	EnvelopeError     = core.EnvelopeError
+	PayloadTransforms = core.PayloadTransforms
+	FieldNaming       = core.FieldNaming
+
+	FieldError           = core.FieldError
+	ValidationError      = core.ValidationError
+	Validator            = core.Validator
+	FieldFailureEnvelope = core.FieldFailureEnvelope
 )
 
 type (
-	SignUpInput   = core.SignUpInput
-	SignUpResult  = core.SignUpResult
-	SignInInput   = core.SignInInput
-	SignInResult  = core.SignInResult
-	RefreshResult = core.RefreshResult
+	SignUpInput         = core.SignUpInput
+	SignUpResult        = core.SignUpResult
+	SignInInput         = core.SignInInput
+	SignInResult        = core.SignInResult
+	ContinueSignInInput = core.ContinueSignInInput
+	RefreshResult       = core.RefreshResult
+	VerifyResult        = core.VerifyResult
+
+	UpdateUserInput     = core.UpdateUserInput
+	ChangePasswordInput = core.ChangePasswordInput
+	ResetPasswordInput  = core.ResetPasswordInput
+
+	SignUpRequest                   = core.SignUpRequest
+	SignInRequest                   = core.SignInRequest
+	ContinueSignInRequest           = core.ContinueSignInRequest
+	RefreshRequest                  = core.RefreshRequest
+	SwitchOrganizationRequest       = core.SwitchOrganizationRequest
+	AcceptOrganizationInviteRequest = core.AcceptOrganizationInviteRequest
+	CreateAccessTokenRequest        = core.CreateAccessTokenRequest
+	ChangePasswordRequest           = core.ChangePasswordRequest
+	MessageResponse                 = core.MessageResponse
+	VerifyTOTPRequest               = core.VerifyTOTPRequest
+	EmailRequest                    = core.EmailRequest
+	ResetPasswordRequest            = core.ResetPasswordRequest
+	VerifyEmailRequest              = core.VerifyEmailRequest
+)
+
+const (
+	DefaultTokenHeader   = core.DefaultTokenHeader
+	DefaultMaxBodySize   = core.DefaultMaxBodySize
+	PasskeyProviderID    = core.PasskeyProviderID
+	TOTPProviderID       = core.TOTPProviderID
+	AnonymousEmailDomain = core.AnonymousEmailDomain
+	AccessTokenPrefix    = core.AccessTokenPrefix
+
+	CredentialProviderID = core.CredentialProviderID
+
+	CodeEmailRequired        = core.CodeEmailRequired
+	CodeEmailInvalid         = core.CodeEmailInvalid
+	CodePasswordRequired     = core.CodePasswordRequired
+	CodePasswordTooShort     = core.CodePasswordTooShort
+	CodePasswordTooLong      = core.CodePasswordTooLong
+	CodePublicKeyInvalid     = core.CodePublicKeyInvalid
+	CodeRoleRequired         = core.CodeRoleRequired
+	CodeOrganizationRequired = core.CodeOrganizationRequired
+	CodeNameRequired         = core.CodeNameRequired
+	CodeScopeInvalid         = core.CodeScopeInvalid
+	CodeExpiresAtInvalid     = core.CodeExpiresAtInvalid
+
+	FieldNamingCamelCase = core.FieldNamingCamelCase
+	FieldNamingSnakeCase = core.FieldNamingSnakeCase
+
+	ProviderTypeCredential = core.ProviderTypeCredential
+	ProviderTypeOAuth      = core.ProviderTypeOAuth
+	ProviderTypePasskey    = core.ProviderTypePasskey
+	ProviderTypeMagicLink  = core.ProviderTypeMagicLink
+
+	UpgradeAddPasskey      = core.UpgradeAddPasskey
+	UpgradeEnableTwoFactor = core.UpgradeEnableTwoFactor
+
+	NextStepVerifyEmail    = core.NextStepVerifyEmail
+	NextStepSignIn         = core.NextStepSignIn
+	NextStepSetupTwoFactor = core.NextStepSetupTwoFactor
+	NextStepAwaitApproval  = core.NextStepAwaitApproval
+
+	UserStatusActive    = core.UserStatusActive
+	UserStatusPending   = core.UserStatusPending
+	UserStatusRejected  = core.UserStatusRejected
+	UserStatusAnonymous = core.UserStatusAnonymous
+
+	ChallengeTwoFactor     = core.ChallengeTwoFactor
+	ChallengeDeviceTrust   = core.ChallengeDeviceTrust
+	ChallengeCaptcha       = core.ChallengeCaptcha
+	ChallengePasswordReset = core.ChallengePasswordReset
+
+	VerificationEmail         = core.VerificationEmail
+	VerificationPasswordReset = core.VerificationPasswordReset
+	VerificationInvite        = core.VerificationInvite
+
+	EmailVerification       = core.EmailVerification
+	EmailPasswordReset      = core.EmailPasswordReset
+	EmailSecurityAlert      = core.EmailSecurityAlert
+	EmailOrganizationInvite = core.EmailOrganizationInvite
+
+	RevokeReasonSignOut        = core.RevokeReasonSignOut
+	RevokeReasonAdmin          = core.RevokeReasonAdmin
+	RevokeReasonPasswordChange = core.RevokeReasonPasswordChange
+	RevokeReasonUserDeleted    = core.RevokeReasonUserDeleted
+	RevokeReasonRefreshReuse   = core.RevokeReasonRefreshReuse
+	RevokeReasonUnspecified    = core.RevokeReasonUnspecified
+
+	EmailRequestSent            = core.EmailRequestSent
+	EmailRequestUnknownEmail    = core.EmailRequestUnknownEmail
+	EmailRequestNoPassword      = core.EmailRequestNoPassword
+	EmailRequestAlreadyVerified = core.EmailRequestAlreadyVerified
+	EmailRequestFailed          = core.EmailRequestFailed
+
+	OperationSignUp                   = core.OperationSignUp
+	OperationSignIn                   = core.OperationSignIn
+	OperationContinueSignIn           = core.OperationContinueSignIn
+	OperationSignOut                  = core.OperationSignOut
+	OperationGetSession               = core.OperationGetSession
+	OperationRefreshToken             = core.OperationRefreshToken
+	OperationAdminListSessions        = core.OperationAdminListSessions
+	OperationAdminRevokeSessions      = core.OperationAdminRevokeSessions
+	OperationListProviders            = core.OperationListProviders
+	OperationOAuthSignIn              = core.OperationOAuthSignIn
+	OperationOAuthCallback            = core.OperationOAuthCallback
+	OperationOAuthCallbackPost        = core.OperationOAuthCallbackPost
+	OperationListActivity             = core.OperationListActivity
+	OperationSwitchOrganization       = core.OperationSwitchOrganization
+	OperationAcceptOrganizationInvite = core.OperationAcceptOrganizationInvite
+	OperationListAccessTokens         = core.OperationListAccessTokens
+	OperationCreateAccessToken        = core.OperationCreateAccessToken
+	OperationRevokeAccessToken        = core.OperationRevokeAccessToken
+	OperationChangePassword           = core.OperationChangePassword
+	OperationListAccounts             = core.OperationListAccounts
+	OperationUnlinkAccount            = core.OperationUnlinkAccount
+	OperationLinkOAuthAccount         = core.OperationLinkOAuthAccount
+	OperationSetupTOTP                = core.OperationSetupTOTP
+	OperationVerifyTOTP               = core.OperationVerifyTOTP
+	OperationSignInAnonymous          = core.OperationSignInAnonymous
+	OperationForgotPassword           = core.OperationForgotPassword
+	OperationResetPassword            = core.OperationResetPassword
+	OperationResendVerification       = core.OperationResendVerification
+	OperationVerifyEmail              = core.OperationVerifyEmail
+
+	IdempotencyKeyHeader    = core.IdempotencyKeyHeader
+	ProofHeader             = core.ProofHeader
+	MaxIdempotencyKeyLength = core.MaxIdempotencyKeyLength
 )
 
 const (
 	defaultBasePath  = "/api/auth"
 	defaultSecretLen = 32
+
+	defaultCacheTTL     = 5 * time.Minute
+	defaultCacheMaxSize = 500
 )
 
 // Constructors & helpers (convenience re-exports)
 var (
 	NewInMemoryCache = cache.NewInMemoryCache
-	NewArgon2        = crypto.NewArgon2
+
+	NewInMemoryAttemptStore        = cache.NewInMemoryAttemptStore
+	NewInMemoryOriginStore         = cache.NewInMemoryOriginStore
+	NewInMemoryPendingSignInStore  = cache.NewInMemoryPendingSignInStore
+	NewInMemoryOAuthStateStore     = cache.NewInMemoryOAuthStateStore
+	NewInMemorySignInLog           = cache.NewInMemorySignInLog
+	NewInMemoryRoleStorage         = cache.NewInMemoryRoleStorage
+	NewInMemoryOrganizationStorage = cache.NewInMemoryOrganizationStorage
+	NewInMemoryAccessTokenStorage  = cache.NewInMemoryAccessTokenStorage
+	NewInMemorySessionQueue        = cache.NewInMemorySessionQueue
+	NewInMemoryIdempotencyStore    = cache.NewInMemoryIdempotencyStore
+	NewArgon2                      = crypto.NewArgon2
+
+	NewTokenBucketRateLimiter = ratelimit.NewTokenBucket
+	NewRedisRateLimiter       = ratelimit.NewRedis
+
+	NewLimitedPasswordHandler      = crypto.NewLimitedPasswordHandler
+	NewInstrumentedPasswordHandler = crypto.NewInstrumentedPasswordHandler
+	NewHashMetrics                 = crypto.NewHashMetrics
+	NewMultiPasswordHandler        = crypto.NewMultiPasswordHandler
+
+	NewLocalRevocationBus = revocation.NewLocalBus
+
+	IsAccessToken  = core.IsAccessToken
+	CapabilitiesOf = core.CapabilitiesOf
+
+	ErrorStatus             = core.ErrorStatus
+	RegisterErrorStatus     = core.RegisterErrorStatus
+	RegisterErrorStatusFunc = core.RegisterErrorStatusFunc
+	ErrorBody               = core.ErrorBody
+	NewFieldError           = core.NewFieldError
+	FieldErrors             = core.FieldErrors
+
+	RequireMembership = core.RequireMembership
+	RequireRole       = core.RequireRole
+
+	SnakeCaseDecoder = core.SnakeCaseDecoder
+	SnakeCaseEncoder = core.SnakeCaseEncoder
+
+	DetachSessionToken = core.DetachSessionToken
+	ParseRequestProof  = core.ParseRequestProof
+	LimitBody          = core.LimitBody
+
+	IdempotentOperations = core.IdempotentOperations
+
+	DefaultUserSecurity   = core.DefaultUserSecurity
+	DefaultUpgradePrompts = core.DefaultUpgradePrompts
 )
 
 var (
 	ErrUserExists         = core.ErrUserExists
 	ErrUserNotFound       = core.ErrUserNotFound
 	ErrInvalidCredentials = core.ErrInvalidCredentials
+	ErrTooManyAttempts    = core.ErrTooManyAttempts
+	ErrRateLimited        = core.ErrRateLimited
+
+	ErrUserSecurityNotFound = core.ErrUserSecurityNotFound
+
+	ErrApprovalPending = core.ErrApprovalPending
+	ErrAccountRejected = core.ErrAccountRejected
+
+	ErrProviderDisabled = core.ErrProviderDisabled
+
+	ErrChallengeNotFound = core.ErrChallengeNotFound
+	ErrChallengeFailed   = core.ErrChallengeFailed
+
+	ErrUnknownProvider   = core.ErrUnknownProvider
+	ErrInvalidOAuthState = core.ErrInvalidOAuthState
+	ErrOAuthFailed       = core.ErrOAuthFailed
+
+	ErrMembershipRequired = core.ErrMembershipRequired
+
+	ErrAccountNotFound = core.ErrAccountNotFound
+	ErrAccountLinked   = core.ErrAccountLinked
+	ErrLastAccount     = core.ErrLastAccount
+
+	ErrEmailAlreadyVerified = core.ErrEmailAlreadyVerified
+
+	ErrTwoFactorEnabled     = core.ErrTwoFactorEnabled
+	ErrTOTPNotSetUp         = core.ErrTOTPNotSetUp
+	ErrInvalidTwoFactorCode = core.ErrInvalidTwoFactorCode
+
+	ErrIdempotencyKeyReused  = core.ErrIdempotencyKeyReused
+	ErrIdempotencyInProgress = core.ErrIdempotencyInProgress
+	ErrInvalidIdempotencyKey = core.ErrInvalidIdempotencyKey
 )
 
 var (
-	ErrMissingAuthHeader = core.ErrMissingAuthHeader
-	ErrInvalidToken      = core.ErrInvalidToken
-	ErrSessionNotFound   = core.ErrSessionNotFound
-	ErrSessionExpired    = core.ErrSessionExpired
-	ErrCacheNotFound     = core.ErrCacheNotFound
+	ErrMissingAuthHeader     = core.ErrMissingAuthHeader
+	ErrInvalidToken          = core.ErrInvalidToken
+	ErrSessionNotFound       = core.ErrSessionNotFound
+	ErrSessionExpired        = core.ErrSessionExpired
+	ErrCacheNotFound         = core.ErrCacheNotFound
+	ErrForbidden             = core.ErrForbidden
+	ErrImpersonating         = core.ErrImpersonating
+	ErrAnonymousUser         = core.ErrAnonymousUser
+	ErrNotOrganizationMember = core.ErrNotOrganizationMember
+	ErrProofRequired         = core.ErrProofRequired
+	ErrInvalidProof          = core.ErrInvalidProof
+	ErrSessionDraining       = core.ErrSessionDraining
+	ErrAccessTokenExpired    = core.ErrAccessTokenExpired
+
+	ErrRefreshTokenNotFound      = core.ErrRefreshTokenNotFound
+	ErrVerificationTokenNotFound = core.ErrVerificationTokenNotFound
+	ErrAccessTokenNotFound       = core.ErrAccessTokenNotFound
+	ErrBatchTooLarge             = core.ErrBatchTooLarge
 )
 
 var (
-	ErrInvalidAuthHeader = core.ErrInvalidAuthHeader
-	ErrEmailRequired     = core.ErrEmailRequired
-	ErrPasswordRequired  = core.ErrPasswordRequired
-	ErrPasswordTooShort  = core.ErrPasswordTooShort
-	ErrPasswordTooLong   = core.ErrPasswordTooLong
-	ErrInvalidEmail      = core.ErrInvalidEmail
+	ErrInvalidAuthHeader    = core.ErrInvalidAuthHeader
+	ErrBodyTooLarge         = core.ErrBodyTooLarge
+	ErrEmailRequired        = core.ErrEmailRequired
+	ErrPasswordRequired     = core.ErrPasswordRequired
+	ErrPasswordTooShort     = core.ErrPasswordTooShort
+	ErrPasswordTooLong      = core.ErrPasswordTooLong
+	ErrInvalidEmail         = core.ErrInvalidEmail
+	ErrInvalidPublicKey     = core.ErrInvalidPublicKey
+	ErrInvalidImage         = core.ErrInvalidImage
+	ErrInvalidSessionValue  = core.ErrInvalidSessionValue
+	ErrRoleRequired         = core.ErrRoleRequired
+	ErrOrganizationRequired = core.ErrOrganizationRequired
+	ErrNameRequired         = core.ErrNameRequired
+	ErrInvalidScope         = core.ErrInvalidScope
+	ErrInvalidExpiry        = core.ErrInvalidExpiry
+	ErrValidationFailed     = core.ErrValidationFailed
 )
 
 var (
@@ -86,15 +453,24 @@ var (
 	ErrHTTPAdapterRequired = core.ErrHTTPAdapterRequired
 	ErrSecretRequired      = core.ErrSecretRequired
 	ErrSecretTooShort      = core.ErrSecretTooShort
+	ErrInvalidCORSConfig   = core.ErrInvalidCORSConfig
+	ErrInvalidCacheConfig  = core.ErrInvalidCacheConfig
+	ErrInvalidIDConfig     = core.ErrInvalidIDConfig
+	ErrStorageUnsupported  = core.ErrStorageUnsupported
 )
 
 var (
 	ErrNotImplemented = core.ErrNotImplemented
+	ErrServiceBusy    = core.ErrServiceBusy
 )
 
 // Exposes Kuta properties for user to configure
 type Config struct {
 	Secret string
+	// PreviousSecrets are secrets rotated out of Secret. Account provider
+	// tokens encrypted under them stay readable and are re-encrypted under
+	// Secret the next time the account is written.
+	PreviousSecrets []string
 
 	Database core.StorageProvider
 
@@ -105,14 +481,296 @@ type Config struct {
 	PasswordHandler crypto.PasswordHandler
 	BasePath        string
 
+	// IDs customizes the alphabet and length of generated user, session and
+	// account IDs. The bundled migrations expect the defaults.
+	IDs core.EntityIDConfig
+
+	// MaxConcurrentPasswordHashes caps how many password hashes/verifications
+	// run at once (each Argon2 call uses 64MB by default). Zero means no limit.
+	MaxConcurrentPasswordHashes int
+	// PasswordHashQueueTimeout is how long a request waits for a hashing slot
+	// before failing with ErrServiceBusy. Zero waits indefinitely.
+	PasswordHashQueueTimeout time.Duration
+	// PasswordHashObserver is told how long every password hash/verification
+	// took, excluding time queued for a slot. Use NewHashMetrics for a
+	// ready-made histogram with Prometheus output.
+	PasswordHashObserver crypto.HashObserver
+	// PasswordUpgrade enables rehash-on-login: successful sign-ins replace
+	// hashes made with outdated algorithms or parameters, at a rate the
+	// policy limits. Disabled when nil.
+	PasswordUpgrade *crypto.PasswordUpgradePolicy
+	// PasswordMinLength and PasswordMaxLength bound new passwords, in
+	// characters. Violations are reported as field errors
+	// ("password.too_short", "password.too_long"). Zero means no bound.
+	PasswordMinLength int
+	PasswordMaxLength int
+
+	// CacheProvider replaces the default in-memory session cache.
+	// CacheConfig customizes the default cache instead; setting both is an
+	// error. DisableCache turns caching off and ignores CacheConfig.
 	CacheProvider core.Cache
+	CacheConfig   *core.CacheConfig
 	DisableCache  bool
+
+	// CacheWarmupSessions is how many of the newest sessions New preloads
+	// into the cache, avoiding a burst of cache misses after a deploy.
+	// Warm-up is best effort: failures are ignored, so call Kuta.WarmCache
+	// instead to handle them. Zero disables it.
+	CacheWarmupSessions int
+
+	// UserCacheTTL caches user lookups by ID, which every session lookup
+	// makes, for this long. Users changed through another instance may be
+	// served as they were until then. Zero disables it.
+	UserCacheTTL time.Duration
+
+	// SessionQueue turns on deferred session persistence for sign-in rates
+	// the Database can't keep up with: new sessions go to the session cache
+	// and this queue, and Kuta.RunSessionPersistence, run in a goroutine,
+	// writes them to the Database every SessionPersistInterval (default 1
+	// second). Use a durable queue such as sessionqueue.Open, one per
+	// instance, and a shared CacheProvider when several instances serve the
+	// same users. Needs the session cache; unused with StatelessSessions.
+	SessionQueue core.SessionQueue
+	// SessionPersistInterval is how often queued sessions are written
+	SessionPersistInterval time.Duration
+
+	// CacheConsistencySampleRate is the fraction (0 to 1) of cache hits that
+	// are re-checked against storage, evicting entries for sessions revoked
+	// elsewhere. Zero disables the checks.
+	CacheConsistencySampleRate float64
+
+	// RevocationBus shares session revocations between instances so their
+	// caches drop revoked sessions immediately instead of after the TTL.
+	RevocationBus core.RevocationBus
+
+	// DeletedUserRetention is how long soft-deleted users can be restored
+	// before they are eligible for purging. Defaults to 30 days.
+	DeletedUserRetention time.Duration
+
+	// PreventEnumeration makes sign-up, forgot-password and
+	// resend-verification respond identically whether or not the email is
+	// registered. The real outcome is delivered to EventHandler only.
+	PreventEnumeration bool
+
+	// RevokeSessionsOnPasswordChange makes ChangePassword revoke the user's
+	// other sessions, since a leaked password is the usual reason to change
+	// it. Defaults to true.
+	RevokeSessionsOnPasswordChange *bool
+
+	// LoginThrottle slows down and then locks out repeated failed sign-ins
+	// for the same email + IP address pair. Disabled when nil.
+	LoginThrottle *core.ThrottleConfig
+	// AttemptStore counts failed sign-ins for LoginThrottle. Defaults to an
+	// in-memory store; use a shared one when running several instances.
+	AttemptStore core.AttemptStore
+
+	// RateLimit caps sign-up and sign-in requests per client IP address.
+	// Disabled when nil.
+	RateLimit *core.RateLimitConfig
+	// RateLimitRedis stores RateLimit windows in Redis so the limit holds
+	// across instances. When nil, an in-memory token bucket is used.
+	RateLimitRedis ratelimit.RedisClient
+
+	// IdempotencyKeyTTL makes sign-up, sign-in and refresh honor the
+	// Idempotency-Key header: a retry with the same key and body within this
+	// long gets the first response back instead of running again. Disabled
+	// when zero, unless IdempotencyStore is set, which defaults it to 24 hours.
+	IdempotencyKeyTTL time.Duration
+	// IdempotencyStore keeps the responses, sealed with a key derived from
+	// Secret. Defaults to an in-memory store; use a shared one when running
+	// several instances.
+	IdempotencyStore core.IdempotencyStore
+
+	// SignInVelocity emits security.anomaly events when a user signs in from
+	// unusually many IPs or networks within a window. Disabled when nil.
+	SignInVelocity *core.VelocityConfig
+	// OriginStore tracks sign-in origins for SignInVelocity. Defaults to an
+	// in-memory store; use a shared one when running several instances.
+	OriginStore core.OriginStore
+
+	// SignInChallenges are extra sign-in steps (2FA, device trust, CAPTCHA,
+	// ...) run in order after the password check. Sign-ins that need one
+	// return an AuthChallenge, answered via /sign-in/continue.
+	SignInChallenges []core.SignInChallenger
+	// PendingSignInStore keeps sign-ins between challenge steps. Defaults to
+	// an in-memory store; use a shared one when running several instances.
+	PendingSignInStore core.PendingSignInStore
+	// ChallengeTTL is how long a challenge can be answered. Defaults to 5 minutes.
+	ChallengeTTL time.Duration
+
+	// TwoFactor lets users enroll an authenticator app through
+	// POST /2fa/totp/setup and /2fa/totp/verify. Once verified, their
+	// sign-ins return a "two_factor" challenge, answered with a code from
+	// the app or a backup code, before any SignInChallenges. Secrets are
+	// stored sealed with a key derived from Secret. Disabled when nil.
+	TwoFactor *core.TwoFactorConfig
+
+	// AnonymousSessions lets clients start a guest session through
+	// POST /sign-in/anonymous, e.g. to keep a cart before signing up.
+	// Signing up with the guest session's token turns it into the new
+	// account's session under the same ID. Not supported with
+	// StatelessSessions.
+	AnonymousSessions bool
+
+	// SignInLog records every sign-in attempt for GetRecentAttempts and
+	// the GET /me/activity endpoint, e.g. the pgx adapter or
+	// NewInMemorySignInLog(). Attempts aren't logged when nil.
+	SignInLog core.SignInLog
+	// SignInLogRetention is how long logged attempts are kept before
+	// cleanup purges them. Defaults to 90 days.
+	SignInLogRetention time.Duration
+
+	// RoleStorage keeps the roles of users and the permissions of roles,
+	// managed through AssignRole, SetRolePermissions and friends on
+	// Kuta.Auth(), e.g. the pgx adapter or NewInMemoryRoleStorage().
+	// Role checks return ErrNotImplemented when nil.
+	RoleStorage core.RoleStorage
+	// RoleCacheTTL is how long role and permission lookups are cached. Changes
+	// made through another instance show up here once this has passed.
+	// Defaults to 1 minute.
+	RoleCacheTTL time.Duration
+
+	// OrganizationStorage keeps which organizations users belong to and
+	// their roles there, e.g. the pgx adapter or
+	// NewInMemoryOrganizationStorage(). When set, sessions can switch their
+	// active organization through POST /organizations/switch, and
+	// SessionHasPermission adds the user's roles in it.
+	OrganizationStorage core.OrganizationStorage
+	// OrganizationInviteURL is the app page that accepts organization
+	// invites. Invite emails link to it with the token as the "token" query
+	// parameter, for the page to send to POST /organizations/invites/accept.
+	// Without it the token is emailed as a code.
+	OrganizationInviteURL string
+	// OrganizationInviteTTL is how long invites can be accepted. Defaults to
+	// 7 days.
+	OrganizationInviteTTL time.Duration
+
+	// EntitlementResolver resolves the user's plan and feature flags when a
+	// session is issued, refreshed or switches organization. They are kept
+	// with the session and reported in SessionData.Entitlements, so APIs can
+	// authorize by plan without a lookup per request. Call
+	// Kuta.Auth().RefreshEntitlements after a plan change.
+	EntitlementResolver core.EntitlementResolver
+
+	// AccessTokenStorage keeps personal access tokens, e.g. the pgx adapter
+	// or NewInMemoryAccessTokenStorage(). When set, signed-in users manage
+	// their tokens through the /me/tokens endpoints, and the protected
+	// middleware accepts them in place of a session token.
+	AccessTokenStorage core.AccessTokenStorage
+	// AccessTokenScopes are the scopes access tokens may be granted. Any
+	// scope is allowed when empty.
+	AccessTokenScopes []string
+
+	// RevocationGrace lets revoked sessions drain instead of ending at once:
+	// for this long they still work for idempotent (GET, HEAD, OPTIONS)
+	// requests but not mutations. Sign-out and user deletion are immediate.
+	// Disabled when zero.
+	RevocationGrace time.Duration
+
+	// EventHandler receives authentication events (sign-ups, sign-ins, failures)
+	EventHandler core.EventHandler
+
+	// AdminAuthorizer enables the admin API for users it accepts.
+	// Admin endpoints are not mounted when nil.
+	AdminAuthorizer core.AdminAuthorizer
+
+	// OAuthProviders enable sign-in with OAuth providers such as
+	// oauth.Google, oauth.GitHub, oauth.Apple or any OpenID Connect IdP
+	// with oauth.OIDC, through the /sign-in/{provider} and
+	// /callback/{provider} endpoints
+	OAuthProviders []core.OAuthProvider
+	// OAuthCallbackURL is the public URL of the auth endpoints, e.g.
+	// "https://example.com/api/auth". Providers redirect to
+	// OAuthCallbackURL + "/callback/{provider}", which must be registered
+	// with them.
+	OAuthCallbackURL string
+	// OAuthStateStore keeps OAuth sign-ins until the provider's callback.
+	// Defaults to an in-memory store; use a shared one when running several
+	// instances.
+	OAuthStateStore core.OAuthStateStore
+
+	// AfterSignIn hooks may refuse authenticated sign-ins before a session
+	// is issued, e.g. RequireMembership("discord", guildID) to only admit
+	// members of a Discord guild
+	AfterSignIn []AfterSignInHook
+
+	// ProviderTokenRefreshers refresh OAuth provider access tokens, keyed by
+	// provider ID. When set, run Kuta.RunProviderTokenRefresher in a goroutine
+	// to keep Account access tokens valid.
+	ProviderTokenRefreshers map[string]core.TokenRefresher
+	// ProviderTokenRefreshInterval is how often expiring tokens are looked
+	// for. Defaults to 1 minute.
+	ProviderTokenRefreshInterval time.Duration
+	// ProviderTokenRefreshLead is how long before expiry a token is
+	// refreshed. Defaults to 5 minutes.
+	ProviderTokenRefreshLead time.Duration
+
+	// CleanupInterval is how often Kuta.RunCleanup purges expired sessions
+	// and refresh tokens, used or expired verification tokens, deleted users
+	// past DeletedUserRetention and sign-in attempts past
+	// SignInLogRetention. Defaults to 1 hour.
+	CleanupInterval time.Duration
+	// ConsumedTokenRetention is how long used verification tokens are kept
+	// before cleanup purges them. Defaults to 24 hours.
+	ConsumedTokenRetention time.Duration
+
+	// ImageStore stores user images uploaded with sign-up or UpdateUser and
+	// returns their URL. Uploads are discarded when nil.
+	ImageStore core.ImageStore
+	// MaxImageSize caps image uploads in bytes. Defaults to 5 MiB.
+	MaxImageSize int
+
+	// DisabledProviders lists providers ("credential", "google", ...) whose
+	// sign-in starts switched off. Use Kuta.SetProviderEnabled to change
+	// them at runtime.
+	DisabledProviders []string
+	// Providers sets the display metadata the providers endpoint reports,
+	// and lists sign-in methods the app serves itself
+	Providers []ProviderInfo
+
+	// Mailer sends verification, password reset and security alert emails.
+	// Emails are off when nil. With a Mailer, users can reset a forgotten
+	// password through POST /forgot-password and /reset-password, and
+	// verify their email through POST /resend-verification and
+	// /verify-email.
+	Mailer core.Mailer
+	// PasswordResetURL and EmailVerificationURL are the app pages those
+	// emails link to, with the token as the "token" query parameter, for
+	// the page to send to /reset-password or /verify-email. Without one the
+	// token is emailed as a code.
+	PasswordResetURL     string
+	EmailVerificationURL string
+	// EmailTemplates overrides the default email templates by message type
+	// (EmailVerification, ...). Ignored when EmailRenderer is set.
+	EmailTemplates map[string]EmailTemplate
+	// EmailRenderer replaces the built-in template rendering altogether
+	EmailRenderer core.EmailRenderer
+	// AppName is shown in emails
+	AppName string
+
+	// Onboarding sets which steps sign-up responses tell new users to take
+	// next (RequiresEmailVerification, NextStep)
+	Onboarding core.OnboardingConfig
+
+	// ReportSecurityPosture adds whether the user has passkeys or 2FA
+	// enrolled, and which upgrades to suggest, to sign-in and session
+	// responses.
+	ReportSecurityPosture bool
+	// UpgradePrompts chooses the suggested upgrades. Defaults to
+	// DefaultUpgradePrompts; setting it implies ReportSecurityPosture.
+	UpgradePrompts core.UpgradePromptPolicy
+
+	// StatelessSessions keeps the whole session in an encrypted token (derived
+	// from Secret) instead of the sessions table. Sessions can't be revoked
+	// before they expire in this mode, except by deleting their user.
+	StatelessSessions bool
 }
 
 type Kuta struct {
-	Protected    interface{}
-	authProvider core.AuthProvider
-	httpAdapter  core.HTTPProvider
+	Protected   interface{}
+	sessions    *services.SessionManager
+	storage     core.StorageProvider
+	httpAdapter core.HTTPProvider
 }
 
 func New(config Config) (*Kuta, error) {
@@ -128,15 +786,15 @@ func New(config Config) (*Kuta, error) {
 	if config.HTTP == nil {
 		return nil, core.ErrHTTPAdapterRequired
 	}
+	if err := checkStorageCapabilities(config); err != nil {
+		return nil, err
+	}
 
 	// Set Defaults
 
-	cacheProvider := config.CacheProvider
-	if cacheProvider == nil && !config.DisableCache {
-		cacheProvider = cache.NewInMemoryCache(core.CacheConfig{
-			TTL:     5 * time.Minute,
-			MaxSize: 500,
-		})
+	cacheProvider, err := resolveCache(config)
+	if err != nil {
+		return nil, err
 	}
 
 	sessionConfig := config.SessionConfig
@@ -150,21 +808,164 @@ func New(config Config) (*Kuta, error) {
 	if passwordHandler == nil {
 		passwordHandler = crypto.NewArgon2()
 	}
+	if config.PasswordHashObserver != nil {
+		passwordHandler = crypto.NewInstrumentedPasswordHandler(passwordHandler, config.PasswordHashObserver)
+	}
+	if config.MaxConcurrentPasswordHashes > 0 {
+		passwordHandler = crypto.NewLimitedPasswordHandler(passwordHandler, config.MaxConcurrentPasswordHashes, config.PasswordHashQueueTimeout)
+	}
 
 	basePath := config.BasePath
 	if basePath == "" {
 		basePath = defaultBasePath
 	}
 
-	sessionService := services.NewSessionManager(*sessionConfig, config.Database, cacheProvider, passwordHandler)
+	ids, err := services.NewIDGenerators(config.IDs)
+	if err != nil {
+		return nil, err
+	}
+
+	accountTokens, err := crypto.NewEnvelope(config.Secret, services.AccountTokenPurpose, config.PreviousSecrets...)
+	if err != nil {
+		return nil, err
+	}
+
+	oauthStates := config.OAuthStateStore
+	if oauthStates == nil {
+		oauthStates = cache.NewInMemoryOAuthStateStore()
+	}
+
+	opts := []services.Option{
+		services.WithIDGenerators(ids),
+		services.WithAccountTokenEncryption(accountTokens),
+		services.WithDeletedUserRetention(config.DeletedUserRetention),
+		services.WithEnumerationProtection(config.PreventEnumeration),
+		services.WithEventHandler(config.EventHandler),
+		services.WithAdminAuthorizer(config.AdminAuthorizer),
+		services.WithCacheConsistencyChecks(config.CacheConsistencySampleRate),
+		services.WithRevocationBus(config.RevocationBus),
+		services.WithOAuth(oauthStates, config.OAuthCallbackURL, config.OAuthProviders...),
+		services.WithAfterSignIn(config.AfterSignIn...),
+		services.WithProviderTokenRefresh(config.ProviderTokenRefreshers, config.ProviderTokenRefreshInterval, config.ProviderTokenRefreshLead),
+		services.WithImageStore(config.ImageStore, config.MaxImageSize),
+		services.WithCleanup(config.CleanupInterval, config.ConsumedTokenRetention),
+		services.WithSignInLog(config.SignInLog, config.SignInLogRetention),
+		services.WithRoles(config.RoleStorage, config.RoleCacheTTL),
+		services.WithUserCache(config.UserCacheTTL),
+		services.WithDeferredSessions(config.SessionQueue, config.SessionPersistInterval),
+		services.WithOrganizations(config.OrganizationStorage),
+		services.WithOrganizationInvites(config.OrganizationInviteURL, config.OrganizationInviteTTL),
+		services.WithAccountEmails(config.PasswordResetURL, config.EmailVerificationURL),
+		services.WithEntitlements(config.EntitlementResolver),
+		services.WithAccessTokens(config.AccessTokenStorage, config.AccessTokenScopes...),
+		services.WithDisabledProviders(config.DisabledProviders...),
+		services.WithProviderInfo(config.Providers...),
+		services.WithOnboarding(config.Onboarding),
+		services.WithRevocationGrace(config.RevocationGrace),
+		services.WithPasswordLength(config.PasswordMinLength, config.PasswordMaxLength),
+	}
+
+	if config.LoginThrottle != nil {
+		attempts := config.AttemptStore
+		if attempts == nil {
+			attempts = cache.NewInMemoryAttemptStore()
+		}
+		opts = append(opts, services.WithLoginThrottle(attempts, *config.LoginThrottle))
+	}
+
+	if config.IdempotencyKeyTTL > 0 || config.IdempotencyStore != nil {
+		store := config.IdempotencyStore
+		if store == nil {
+			store = cache.NewInMemoryIdempotencyStore()
+		}
+		sealer, err := crypto.NewSealer(config.Secret, services.IdempotencySealerPurpose)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, services.WithIdempotency(store, sealer, config.IdempotencyKeyTTL))
+	}
+
+	if config.Mailer != nil {
+		renderer := config.EmailRenderer
+		if renderer == nil {
+			renderer, err = mailtemplate.New(config.EmailTemplates)
+			if err != nil {
+				return nil, err
+			}
+		}
+		opts = append(opts, services.WithMailer(config.Mailer, renderer, config.AppName))
+	}
+
+	if config.RevokeSessionsOnPasswordChange != nil {
+		opts = append(opts, services.WithPasswordChangeRevocation(*config.RevokeSessionsOnPasswordChange))
+	}
+
+	if config.RateLimit != nil {
+		var limiter core.RateLimiter = ratelimit.NewTokenBucket(*config.RateLimit)
+		if config.RateLimitRedis != nil {
+			limiter = ratelimit.NewRedis(config.RateLimitRedis, *config.RateLimit)
+		}
+		opts = append(opts, services.WithRateLimiter(limiter))
+	}
+
+	if config.ReportSecurityPosture || config.UpgradePrompts != nil {
+		opts = append(opts, services.WithSecurityPosture(config.UpgradePrompts))
+	}
+
+	if config.PasswordUpgrade != nil {
+		opts = append(opts, services.WithPasswordUpgrade(*config.PasswordUpgrade))
+	}
+
+	if config.SignInVelocity != nil {
+		origins := config.OriginStore
+		if origins == nil {
+			origins = cache.NewInMemoryOriginStore()
+		}
+		opts = append(opts, services.WithSignInVelocity(origins, *config.SignInVelocity))
+	}
+
+	if config.TwoFactor != nil {
+		sealer, err := crypto.NewSealer(config.Secret, services.TOTPSealerPurpose)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, services.WithTwoFactor(*config.TwoFactor, sealer))
+	}
+
+	if config.AnonymousSessions {
+		opts = append(opts, services.WithAnonymousSessions())
+	}
+
+	if len(config.SignInChallenges) > 0 || config.TwoFactor != nil {
+		pending := config.PendingSignInStore
+		if pending == nil {
+			pending = cache.NewInMemoryPendingSignInStore()
+		}
+		opts = append(opts, services.WithSignInChallenges(pending, config.ChallengeTTL, config.SignInChallenges...))
+	}
+
+	if config.StatelessSessions {
+		sealer, err := crypto.NewSealer(config.Secret, services.StatelessSealerPurpose)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, services.WithStatelessSessions(sealer))
+	}
+
+	sessionService := services.NewSessionManager(*sessionConfig, config.Database, cacheProvider, passwordHandler, opts...)
+
+	if config.CacheWarmupSessions > 0 {
+		_, _ = sessionService.WarmCache(context.Background(), config.CacheWarmupSessions)
+	}
 
 	if err := config.HTTP.RegisterRoutes(sessionService, basePath, sessionConfig.MaxAge); err != nil {
 		return nil, err
 	}
 
 	k := &Kuta{
-		authProvider: sessionService,
-		httpAdapter:  config.HTTP,
+		sessions:    sessionService,
+		storage:     config.Database,
+		httpAdapter: config.HTTP,
 
 		// Set exported Protected field to the framework-specific middleware value
 		Protected: config.HTTP.BuildProtectedMiddleware(sessionService),
@@ -172,3 +973,153 @@ func New(config Config) (*Kuta, error) {
 
 	return k, nil
 }
+
+// checkStorageCapabilities refuses features explicitly configured on a
+// storage that can't support them. Features that are merely on by default
+// degrade instead.
+func checkStorageCapabilities(config Config) error {
+	capabilities := core.CapabilitiesOf(config.Database)
+	if config.DeletedUserRetention > 0 && !capabilities.SoftDelete {
+		return fmt.Errorf("%w: DeletedUserRetention needs soft deletes", core.ErrStorageUnsupported)
+	}
+	if config.CacheWarmupSessions > 0 && !capabilities.Search {
+		return fmt.Errorf("%w: CacheWarmupSessions needs session search", core.ErrStorageUnsupported)
+	}
+	if config.OrganizationStorage != nil && config.OrganizationInviteURL != "" && !core.CapabilitiesOf(config.OrganizationStorage).Transactions {
+		return fmt.Errorf("%w: OrganizationInviteURL needs an OrganizationStorage with transactions", core.ErrStorageUnsupported)
+	}
+	return nil
+}
+
+// resolveCache picks the session cache from the cache-related Config fields:
+// DisableCache wins, then CacheProvider, then a default in-memory cache
+// customized by CacheConfig.
+func resolveCache(config Config) (core.Cache, error) {
+	if config.DisableCache {
+		if config.CacheProvider != nil {
+			return nil, fmt.Errorf("%w: CacheProvider is set but DisableCache is true", core.ErrInvalidCacheConfig)
+		}
+		if config.SessionQueue != nil {
+			return nil, fmt.Errorf("%w: SessionQueue needs the session cache", core.ErrInvalidCacheConfig)
+		}
+		return nil, nil
+	}
+
+	if config.CacheProvider != nil {
+		if config.CacheConfig != nil {
+			return nil, fmt.Errorf("%w: CacheConfig only applies to the default cache, not CacheProvider", core.ErrInvalidCacheConfig)
+		}
+		return config.CacheProvider, nil
+	}
+
+	cacheConfig := core.CacheConfig{
+		TTL:     defaultCacheTTL,
+		MaxSize: defaultCacheMaxSize,
+	}
+	if config.CacheConfig != nil {
+		if config.CacheConfig.TTL < 0 || config.CacheConfig.MaxSize < 0 {
+			return nil, fmt.Errorf("%w: TTL and MaxSize must not be negative", core.ErrInvalidCacheConfig)
+		}
+		if config.CacheConfig.TTL > 0 {
+			cacheConfig.TTL = config.CacheConfig.TTL
+		}
+		if config.CacheConfig.MaxSize > 0 {
+			cacheConfig.MaxSize = config.CacheConfig.MaxSize
+		}
+	}
+
+	return cache.NewInMemoryCache(cacheConfig), nil
+}
+
+// Auth returns the application-facing API for signing users in and out and
+// managing their sessions outside of the mounted HTTP routes
+func (k *Kuta) Auth() AuthService {
+	return k.sessions
+}
+
+// SessionManager returns the underlying session manager, including admin and
+// proof-of-possession operations not covered by AuthService
+func (k *Kuta) SessionManager() *SessionManager {
+	return k.sessions
+}
+
+// RunProviderTokenRefresher refreshes expiring OAuth provider access tokens
+// until ctx is done. It returns immediately when no ProviderTokenRefreshers
+// are configured.
+func (k *Kuta) RunProviderTokenRefresher(ctx context.Context) {
+	k.sessions.RunProviderTokenRefresher(ctx)
+}
+
+// RunSessionPersistence writes sessions queued in SessionQueue to the
+// Database every SessionPersistInterval until ctx is done, then once more.
+// It returns immediately when no SessionQueue is configured.
+func (k *Kuta) RunSessionPersistence(ctx context.Context) {
+	k.sessions.RunSessionPersistence(ctx)
+}
+
+// WarmCache preloads up to limit of the newest live sessions into the
+// session cache and returns how many were loaded
+func (k *Kuta) WarmCache(ctx context.Context, limit int) (int, error) {
+	return k.sessions.WarmCache(ctx, limit)
+}
+
+// Migrate creates or upgrades the database schema with the bundled
+// migrations, when the storage adapter implements Migrator. Call it before
+// serving requests. It returns ErrNotImplemented for adapters that don't.
+func (k *Kuta) Migrate(ctx context.Context) error {
+	migrator, ok := k.storage.(core.Migrator)
+	if !ok {
+		return core.ErrNotImplemented
+	}
+	return migrator.Migrate(ctx)
+}
+
+// RunCleanup purges stale sessions, tokens and deleted users every
+// CleanupInterval until ctx is done. Run it in a goroutine.
+func (k *Kuta) RunCleanup(ctx context.Context) {
+	k.sessions.RunCleanup(ctx)
+}
+
+// CleanupStats reports the runs, failures and purged rows of each cleanup job
+func (k *Kuta) CleanupStats() []CleanupStats {
+	return k.sessions.CleanupStats()
+}
+
+// WriteCleanupMetrics writes the cleanup stats in the Prometheus text
+// exposition format, e.g. from a /metrics handler
+func (k *Kuta) WriteCleanupMetrics(w io.Writer) error {
+	return k.sessions.WriteCleanupMetrics(w)
+}
+
+// SetProviderEnabled turns sign-in with a provider on or off at runtime
+func (k *Kuta) SetProviderEnabled(providerID string, enabled bool) {
+	k.sessions.SetProviderEnabled(providerID, enabled)
+}
+
+// ProviderStatuses lists the known sign-in providers and whether each is
+// enabled
+func (k *Kuta) ProviderStatuses() []ProviderStatus {
+	return k.sessions.ProviderStatuses()
+}
+
+// SetValue stores value under key in the session; see GetValue
+func (k *Kuta) SetValue(ctx context.Context, sessionID, key, value string) error {
+	return k.sessions.SetValue(ctx, sessionID, key, value)
+}
+
+// GetValue returns the value stored under key in the session, and whether
+// one was set. Session values are a place for per-session app state such as
+// CSRF secrets, and are never included in session responses.
+func (k *Kuta) GetValue(ctx context.Context, sessionID, key string) (string, bool, error) {
+	return k.sessions.GetValue(ctx, sessionID, key)
+}
+
+// DeleteValue removes key from the session's values
+func (k *Kuta) DeleteValue(ctx context.Context, sessionID, key string) error {
+	return k.sessions.DeleteValue(ctx, sessionID, key)
+}
+
+// ConsistencyStats reports the cache consistency checks run so far
+func (k *Kuta) ConsistencyStats() ConsistencyStats {
+	return k.sessions.ConsistencyStats()
+}
@@ -0,0 +1,197 @@
+package kuta
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/lborres/kuta/core"
+	"github.com/lborres/kuta/pkg/cache"
+	"github.com/lborres/kuta/services"
+)
+
+// Requirement: DisableCache wins, CacheProvider replaces the default cache,
+// CacheConfig customizes the default, and conflicting settings are rejected.
+func TestResolveCache(t *testing.T) {
+	custom := cache.NewInMemoryCache(core.CacheConfig{})
+
+	tests := []struct {
+		name       string
+		config     Config
+		wantErr    error
+		wantNil    bool
+		wantCustom bool
+		wantTTL    time.Duration
+	}{
+		{
+			name:    "defaults",
+			wantTTL: defaultCacheTTL,
+		},
+		{
+			name:    "disabled",
+			config:  Config{DisableCache: true},
+			wantNil: true,
+		},
+		{
+			name:    "disabled ignores cache config",
+			config:  Config{DisableCache: true, CacheConfig: &core.CacheConfig{TTL: time.Minute}},
+			wantNil: true,
+		},
+		{
+			name:    "disabled with provider",
+			config:  Config{DisableCache: true, CacheProvider: custom},
+			wantErr: core.ErrInvalidCacheConfig,
+		},
+		{
+			name:    "disabled with session queue",
+			config:  Config{DisableCache: true, SessionQueue: cache.NewInMemorySessionQueue()},
+			wantErr: core.ErrInvalidCacheConfig,
+		},
+		{
+			name:       "custom provider",
+			config:     Config{CacheProvider: custom},
+			wantCustom: true,
+		},
+		{
+			name:    "provider with cache config",
+			config:  Config{CacheProvider: custom, CacheConfig: &core.CacheConfig{TTL: time.Minute}},
+			wantErr: core.ErrInvalidCacheConfig,
+		},
+		{
+			name:    "cache config customizes default",
+			config:  Config{CacheConfig: &core.CacheConfig{TTL: time.Minute}},
+			wantTTL: time.Minute,
+		},
+		{
+			name:    "negative cache config",
+			config:  Config{CacheConfig: &core.CacheConfig{MaxSize: -1}},
+			wantErr: core.ErrInvalidCacheConfig,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// Act
+			got, err := resolveCache(test.config)
+
+			// Assert
+			if test.wantErr != nil {
+				if !errors.Is(err, test.wantErr) {
+					t.Fatalf("expected %v, got %v", test.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			switch {
+			case test.wantNil:
+				if got != nil {
+					t.Errorf("expected no cache, got %T", got)
+				}
+			case test.wantCustom:
+				if got != custom {
+					t.Errorf("expected the configured provider, got %T", got)
+				}
+			default:
+				stats := got.(core.CacheWithStats).Stats()
+				if stats.TTL != test.wantTTL {
+					t.Errorf("expected TTL %v, got %v", test.wantTTL, stats.TTL)
+				}
+				if got.(*cache.InMemoryCache) == custom {
+					t.Error("expected a new default cache")
+				}
+			}
+		})
+	}
+}
+
+// nonTransactionalOrganizations is an OrganizationStorage that can't
+// activate invites atomically
+type nonTransactionalOrganizations struct {
+	core.OrganizationStorage
+}
+
+func (nonTransactionalOrganizations) Capabilities() core.StorageCapabilities {
+	return core.StorageCapabilities{SoftDelete: true, Search: true}
+}
+
+// Requirement: Features explicitly configured on storage that can't support
+// them are refused at startup; storage that doesn't report capabilities is
+// assumed to support everything.
+func TestCheckStorageCapabilities(t *testing.T) {
+	limited := services.FakeLimitedStorage{FakeStorageProvider: services.NewFakeStorageProvider()}
+	full := services.NewFakeStorageProvider()
+	organizations := cache.NewInMemoryOrganizationStorage()
+
+	tests := []struct {
+		name    string
+		config  Config
+		wantErr error
+	}{
+		{name: "defaults on limited storage", config: Config{Database: limited}},
+		{name: "retention without soft delete", config: Config{Database: limited, DeletedUserRetention: time.Hour}, wantErr: core.ErrStorageUnsupported},
+		{name: "retention on unreported storage", config: Config{Database: full, DeletedUserRetention: time.Hour}},
+		{name: "warm-up without search", config: Config{Database: limited, CacheWarmupSessions: 10}, wantErr: core.ErrStorageUnsupported},
+		{name: "invite URL without transactions", config: Config{Database: full, OrganizationStorage: nonTransactionalOrganizations{organizations}, OrganizationInviteURL: "https://app.test/invites"}, wantErr: core.ErrStorageUnsupported},
+		{name: "organizations without transactions", config: Config{Database: full, OrganizationStorage: nonTransactionalOrganizations{organizations}}},
+		{name: "invite URL with transactions", config: Config{Database: full, OrganizationStorage: organizations, OrganizationInviteURL: "https://app.test/invites"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// Act
+			err := checkStorageCapabilities(test.config)
+
+			// Assert
+			if !errors.Is(err, test.wantErr) {
+				t.Errorf("checkStorageCapabilities() error = %v, want %v", err, test.wantErr)
+			}
+		})
+	}
+}
+
+// migratingStorage counts Migrate calls
+type migratingStorage struct {
+	*services.FakeStorageProvider
+	calls int
+}
+
+func (m *migratingStorage) Migrate(ctx context.Context) error {
+	m.calls++
+	return nil
+}
+
+// Requirement: Migrate runs the storage adapter's migrations, and is
+// ErrNotImplemented for adapters that can't migrate themselves.
+func TestKuta_Migrate(t *testing.T) {
+	migrating := &migratingStorage{FakeStorageProvider: services.NewFakeStorageProvider()}
+
+	tests := []struct {
+		name    string
+		storage core.StorageProvider
+		wantErr error
+	}{
+		{name: "migrating storage", storage: migrating},
+		{name: "other storage", storage: services.NewFakeStorageProvider(), wantErr: core.ErrNotImplemented},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// Arrange
+			k := &Kuta{storage: test.storage}
+
+			// Act
+			err := k.Migrate(context.Background())
+
+			// Assert
+			if !errors.Is(err, test.wantErr) {
+				t.Errorf("Migrate() error = %v, want %v", err, test.wantErr)
+			}
+		})
+	}
+	if migrating.calls != 1 {
+		t.Errorf("Migrate() called the adapter %d times, want 1", migrating.calls)
+	}
+}
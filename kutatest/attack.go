@@ -0,0 +1,253 @@
+// Package kutatest helps applications test their kuta configuration.
+//
+// AttackSimulator replays brute-force patterns against an auth provider so
+// tests can assert that login throttling and rate limiting stop them:
+//
+//	k, _ := kuta.New(kuta.Config{
+//		// ...
+//		LoginThrottle: &kuta.ThrottleConfig{FreeAttempts: 10, LockoutAttempts: 10},
+//	})
+//	sim := kutatest.NewAttackSimulator(k.Auth())
+//	report := sim.PasswordGuessing(t.Context(), "victim@example.com", "203.0.113.1", 50)
+//	report.ExpectBlockedWithin(t, 10)
+//	report.ExpectNoBreach(t)
+//
+// Throttled sign-ins really sleep, so keep ThrottleConfig.FreeAttempts at or
+// above LockoutAttempts, or BaseDelay and MaxDelay short, in tests.
+package kutatest
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/lborres/kuta/core"
+)
+
+// Outcome classifies how the auth provider answered one attempt
+type Outcome int
+
+const (
+	// Rejected attempts were refused as wrong credentials or unknown tokens
+	Rejected Outcome = iota
+	// Succeeded attempts signed in or resolved a session
+	Succeeded
+	// Challenged attempts had the right password but were stopped by a
+	// sign-in challenge, e.g. two-factor authentication
+	Challenged
+	// Throttled attempts were refused by the login throttle's lockout
+	Throttled
+	// RateLimited attempts were refused by the client IP's rate limit
+	RateLimited
+	// Failed attempts ended with any other error
+	Failed
+)
+
+func (o Outcome) String() string {
+	switch o {
+	case Rejected:
+		return "rejected"
+	case Succeeded:
+		return "succeeded"
+	case Challenged:
+		return "challenged"
+	case Throttled:
+		return "throttled"
+	case RateLimited:
+		return "rate limited"
+	default:
+		return "failed"
+	}
+}
+
+// Blocked reports whether the attempt was stopped by brute-force protection
+// rather than answered
+func (o Outcome) Blocked() bool {
+	return o == Throttled || o == RateLimited
+}
+
+// Credential is an email and password pair, as found in a leaked list
+type Credential struct {
+	Email    string
+	Password string
+}
+
+// Attempt is one request of a simulated attack and how it was answered
+type Attempt struct {
+	Credential
+	IPAddress string
+	// Token is the guessed session or refresh token of token guessing
+	Token   string
+	Outcome Outcome
+	Err     error
+}
+
+// Report lists the attempts of a simulated attack in the order they were made
+type Report struct {
+	Attempts []Attempt
+}
+
+// Count returns how many attempts ended with outcome
+func (r *Report) Count(outcome Outcome) int {
+	n := 0
+	for _, attempt := range r.Attempts {
+		if attempt.Outcome == outcome {
+			n++
+		}
+	}
+	return n
+}
+
+// FirstBlocked returns the 1-based number of the first blocked attempt, or 0
+// if none was
+func (r *Report) FirstBlocked() int {
+	for i, attempt := range r.Attempts {
+		if attempt.Outcome.Blocked() {
+			return i + 1
+		}
+	}
+	return 0
+}
+
+// ExpectBlockedWithin fails t unless the attack was blocked by attempt n at
+// the latest and every attempt after the first blocked one was blocked too
+func (r *Report) ExpectBlockedWithin(t testing.TB, n int) {
+	t.Helper()
+	first := r.FirstBlocked()
+	if first == 0 || first > n {
+		t.Errorf("attack not blocked within %d attempts: %s", n, r)
+		return
+	}
+	for i, attempt := range r.Attempts[first:] {
+		if !attempt.Outcome.Blocked() {
+			t.Errorf("attempt %d %s after attempt %d was blocked: %v", first+i+1, attempt.Outcome, first, attempt.Err)
+			return
+		}
+	}
+}
+
+// ExpectNotBlocked fails t if any attempt was blocked, e.g. to check that
+// legitimate traffic stays under the limits
+func (r *Report) ExpectNotBlocked(t testing.TB) {
+	t.Helper()
+	if first := r.FirstBlocked(); first != 0 {
+		t.Errorf("attempt %d blocked: %s", first, r)
+	}
+}
+
+// ExpectNoBreach fails t if any attempt signed in or resolved a session
+func (r *Report) ExpectNoBreach(t testing.TB) {
+	t.Helper()
+	for i, attempt := range r.Attempts {
+		if attempt.Outcome == Succeeded {
+			t.Errorf("attempt %d succeeded (email %q, IP %q)", i+1, attempt.Email, attempt.IPAddress)
+		}
+	}
+}
+
+// String summarizes the report by outcome
+func (r *Report) String() string {
+	return fmt.Sprintf("%d attempts: %d rejected, %d succeeded, %d challenged, %d throttled, %d rate limited, %d failed",
+		len(r.Attempts), r.Count(Rejected), r.Count(Succeeded), r.Count(Challenged),
+		r.Count(Throttled), r.Count(RateLimited), r.Count(Failed))
+}
+
+// AttackSimulator drives brute-force patterns against an auth provider, such
+// as Kuta.Auth() or a SessionManager, calling it like an HTTP adapter would
+type AttackSimulator struct {
+	auth core.AuthProvider
+
+	// UserAgent is sent with every sign-in. Defaults to "kutatest".
+	UserAgent string
+}
+
+// NewAttackSimulator returns a simulator attacking auth
+func NewAttackSimulator(auth core.AuthProvider) *AttackSimulator {
+	return &AttackSimulator{auth: auth, UserAgent: "kutatest"}
+}
+
+// PasswordGuessing tries attempts wrong passwords for email from a single IP
+// address, which the login throttle should lock out
+func (s *AttackSimulator) PasswordGuessing(ctx context.Context, email, ipAddress string, attempts int) *Report {
+	report := &Report{}
+	for i := range attempts {
+		credential := Credential{Email: email, Password: fmt.Sprintf("guess-%d", i)}
+		report.Attempts = append(report.Attempts, s.signIn(ctx, credential, ipAddress))
+	}
+	return report
+}
+
+// CredentialStuffing tries each credential once, cycling through ipAddresses
+// the way botnets spread a leaked list over many clients. Spread over enough
+// addresses it stays under a per-IP rate limit, which a test can check too.
+func (s *AttackSimulator) CredentialStuffing(ctx context.Context, credentials []Credential, ipAddresses []string) *Report {
+	if len(ipAddresses) == 0 {
+		ipAddresses = []string{""}
+	}
+	report := &Report{}
+	for i, credential := range credentials {
+		report.Attempts = append(report.Attempts, s.signIn(ctx, credential, ipAddresses[i%len(ipAddresses)]))
+	}
+	return report
+}
+
+// TokenGuessing presents attempts random tokens shaped like kuta's as
+// session tokens, then as refresh tokens. None should ever be accepted.
+func (s *AttackSimulator) TokenGuessing(ctx context.Context, attempts int) (*Report, error) {
+	report := &Report{}
+	for range attempts {
+		token, err := randomToken()
+		if err != nil {
+			return nil, err
+		}
+		_, err = s.auth.GetSession(ctx, token)
+		report.Attempts = append(report.Attempts, Attempt{Token: token, Outcome: classify(err), Err: err})
+
+		_, err = s.auth.Refresh(ctx, token)
+		report.Attempts = append(report.Attempts, Attempt{Token: token, Outcome: classify(err), Err: err})
+	}
+	return report, nil
+}
+
+func (s *AttackSimulator) signIn(ctx context.Context, credential Credential, ipAddress string) Attempt {
+	attempt := Attempt{Credential: credential, IPAddress: ipAddress}
+	input := core.SignInInput{Email: credential.Email, Password: credential.Password}
+	result, err := s.auth.SignIn(ctx, input, ipAddress, s.UserAgent)
+	attempt.Outcome, attempt.Err = classify(err), err
+	if err == nil && result.Challenge != nil {
+		attempt.Outcome = Challenged
+	}
+	return attempt
+}
+
+// classify maps the error of an attempt to its outcome
+func classify(err error) Outcome {
+	switch {
+	case err == nil:
+		return Succeeded
+	case errors.Is(err, core.ErrTooManyAttempts):
+		return Throttled
+	case errors.Is(err, core.ErrRateLimited):
+		return RateLimited
+	case errors.Is(err, core.ErrInvalidCredentials),
+		errors.Is(err, core.ErrInvalidToken),
+		errors.Is(err, core.ErrSessionNotFound),
+		errors.Is(err, core.ErrSessionExpired),
+		errors.Is(err, core.ErrRefreshTokenNotFound):
+		return Rejected
+	default:
+		return Failed
+	}
+}
+
+// randomToken returns a token of the length and encoding kuta issues
+func randomToken() (string, error) {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(raw), nil
+}
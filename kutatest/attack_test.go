@@ -0,0 +1,120 @@
+package kutatest_test
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/lborres/kuta/core"
+	"github.com/lborres/kuta/kutatest"
+	"github.com/lborres/kuta/pkg/cache"
+	"github.com/lborres/kuta/pkg/crypto"
+	"github.com/lborres/kuta/pkg/ratelimit"
+	"github.com/lborres/kuta/services"
+)
+
+// newManager returns a SessionManager with one user, victim@example.com
+func newManager(t *testing.T, opts ...services.Option) *services.SessionManager {
+	t.Helper()
+	passwords := &crypto.Argon2{Memory: 1024, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32}
+	manager := services.NewSessionManager(core.SessionConfig{MaxAge: time.Hour}, services.NewFakeStorageProvider(), nil, passwords, opts...)
+	if _, err := manager.SignUp(t.Context(), core.SignUpInput{Email: "victim@example.com", Password: "CorrectPass123!"}, "", ""); err != nil {
+		t.Fatalf("SignUp error: %v", err)
+	}
+	return manager
+}
+
+// leaked returns n wrong credentials for victim@example.com followed by the
+// right one
+func leaked(n int) []kutatest.Credential {
+	credentials := make([]kutatest.Credential, 0, n+1)
+	for i := range n {
+		credentials = append(credentials, kutatest.Credential{Email: "victim@example.com", Password: fmt.Sprintf("leaked-%d", i)})
+	}
+	return append(credentials, kutatest.Credential{Email: "victim@example.com", Password: "CorrectPass123!"})
+}
+
+// Requirement: The simulator reports when brute-force protection kicks in,
+// and whether an attack got through before it did.
+func TestAttackSimulator(t *testing.T) {
+	throttle := core.ThrottleConfig{FreeAttempts: 5, LockoutAttempts: 5}
+
+	tests := []struct {
+		name             string
+		opts             []services.Option
+		attack           func(sim *kutatest.AttackSimulator) *kutatest.Report
+		wantFirstBlocked int
+		wantOutcome      kutatest.Outcome
+		wantSucceeded    int
+	}{
+		{
+			name: "password guessing is locked out",
+			opts: []services.Option{services.WithLoginThrottle(cache.NewInMemoryAttemptStore(), throttle)},
+			attack: func(sim *kutatest.AttackSimulator) *kutatest.Report {
+				return sim.PasswordGuessing(t.Context(), "victim@example.com", "203.0.113.1", 8)
+			},
+			wantFirstBlocked: 6,
+			wantOutcome:      kutatest.Throttled,
+		},
+		{
+			name: "password guessing without protection",
+			attack: func(sim *kutatest.AttackSimulator) *kutatest.Report {
+				return sim.PasswordGuessing(t.Context(), "victim@example.com", "203.0.113.1", 8)
+			},
+		},
+		{
+			name: "credential stuffing from one address is rate limited",
+			opts: []services.Option{services.WithRateLimiter(ratelimit.NewTokenBucket(core.RateLimitConfig{Limit: 4, Window: time.Hour}))},
+			attack: func(sim *kutatest.AttackSimulator) *kutatest.Report {
+				return sim.CredentialStuffing(t.Context(), leaked(7), []string{"203.0.113.1"})
+			},
+			wantFirstBlocked: 5,
+			wantOutcome:      kutatest.RateLimited,
+		},
+		{
+			name: "credential stuffing spread over addresses gets through",
+			opts: []services.Option{services.WithRateLimiter(ratelimit.NewTokenBucket(core.RateLimitConfig{Limit: 4, Window: time.Hour}))},
+			attack: func(sim *kutatest.AttackSimulator) *kutatest.Report {
+				return sim.CredentialStuffing(t.Context(), leaked(7), []string{"203.0.113.1", "203.0.113.2", "203.0.113.3"})
+			},
+			wantSucceeded: 1,
+		},
+		{
+			name: "token guessing",
+			attack: func(sim *kutatest.AttackSimulator) *kutatest.Report {
+				report, err := sim.TokenGuessing(t.Context(), 5)
+				if err != nil {
+					t.Fatalf("TokenGuessing error: %v", err)
+				}
+				return report
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// Arrange
+			sim := kutatest.NewAttackSimulator(newManager(t, test.opts...))
+
+			// Act
+			report := test.attack(sim)
+
+			// Assert
+			if got := report.FirstBlocked(); got != test.wantFirstBlocked {
+				t.Fatalf("FirstBlocked() = %d, want %d: %s", got, test.wantFirstBlocked, report)
+			}
+			if test.wantFirstBlocked != 0 {
+				report.ExpectBlockedWithin(t, test.wantFirstBlocked)
+				if got := report.Attempts[test.wantFirstBlocked-1].Outcome; got != test.wantOutcome {
+					t.Errorf("blocked attempt outcome = %s, want %s", got, test.wantOutcome)
+				}
+			}
+			if got := report.Count(kutatest.Succeeded); got != test.wantSucceeded {
+				t.Errorf("Count(Succeeded) = %d, want %d: %s", got, test.wantSucceeded, report)
+			}
+			if got := report.Count(kutatest.Failed); got != 0 {
+				t.Errorf("Count(Failed) = %d, want 0: %s", got, report)
+			}
+		})
+	}
+}
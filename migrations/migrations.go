@@ -0,0 +1,105 @@
+// Package migrations embeds kuta's SQL migrations and applies the ones a
+// database hasn't applied yet. Storage adapters use it to implement
+// core.Migrator; the SQL files can also be applied with any migration tool.
+package migrations
+
+import (
+	"context"
+	"embed"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+//go:embed postgres/*.sql mysql/*.sql
+var files embed.FS
+
+// Dialect selects the bundled migrations of one database
+type Dialect string
+
+const (
+	Postgres Dialect = "postgres"
+	MySQL    Dialect = "mysql"
+)
+
+// Migration is one versioned schema change
+type Migration struct {
+	// Version is the numeric prefix of the file name, e.g. 26101601
+	Version int64
+	// Name is the rest of the file name, e.g. "add_users_deleted_at"
+	Name string
+	// SQL is the up migration
+	SQL string
+}
+
+// Load returns the dialect's migrations, oldest first. The optional
+// postgres migrations rewrite existing tables, so they aren't included and
+// stay a deliberate manual step.
+func Load(dialect Dialect) ([]Migration, error) {
+	paths, err := fs.Glob(files, string(dialect)+"/*.up.sql")
+	if err != nil {
+		return nil, err
+	}
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("migrations: unknown dialect %q", dialect)
+	}
+
+	migrations := make([]Migration, 0, len(paths))
+	for _, p := range paths {
+		base := strings.TrimSuffix(path.Base(p), ".up.sql")
+		prefix, name, ok := strings.Cut(base, "_")
+		version, err := strconv.ParseInt(prefix, 10, 64)
+		if !ok || err != nil {
+			return nil, fmt.Errorf("migrations: %s has no version prefix", p)
+		}
+		sql, err := files.ReadFile(p)
+		if err != nil {
+			return nil, err
+		}
+		migrations = append(migrations, Migration{Version: version, Name: name, SQL: string(sql)})
+	}
+
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+	return migrations, nil
+}
+
+// Executor applies migrations to one database
+type Executor interface {
+	// Applied creates the table recording applied versions if needed and
+	// returns the versions recorded in it
+	Applied(ctx context.Context) (map[int64]bool, error)
+	// Apply runs the migration and records its version
+	Apply(ctx context.Context, migration Migration) error
+}
+
+// Up applies the migrations exec hasn't recorded yet, oldest first, and
+// returns how many it applied. It stops at the first failure.
+//
+// The bundled migrations only create what is missing, so databases migrated
+// by hand before are brought under version tracking without changes.
+func Up(ctx context.Context, exec Executor, migrations []Migration) (int, error) {
+	applied, err := exec.Applied(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("migrations: %w", err)
+	}
+
+	count := 0
+	for _, migration := range migrations {
+		if applied[migration.Version] {
+			continue
+		}
+		if err := ctx.Err(); err != nil {
+			return count, err
+		}
+		if err := exec.Apply(ctx, migration); err != nil {
+			return count, fmt.Errorf("migrations: %d_%s: %w", migration.Version, migration.Name, err)
+		}
+		count++
+	}
+	return count, nil
+}
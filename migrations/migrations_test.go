@@ -0,0 +1,113 @@
+package migrations
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// recordingExecutor applies migrations in memory, failing the version in
+// failOn
+type recordingExecutor struct {
+	applied map[int64]bool
+	order   []int64
+	failOn  int64
+}
+
+func (e *recordingExecutor) Applied(ctx context.Context) (map[int64]bool, error) {
+	return e.applied, nil
+}
+
+func (e *recordingExecutor) Apply(ctx context.Context, migration Migration) error {
+	if migration.Version == e.failOn {
+		return errors.New("syntax error")
+	}
+	e.applied[migration.Version] = true
+	e.order = append(e.order, migration.Version)
+	return nil
+}
+
+// Requirement: Each dialect's migrations load oldest first with their
+// version and name, without the optional postgres migrations.
+func TestLoad(t *testing.T) {
+	tests := []struct {
+		name      string
+		dialect   Dialect
+		wantFirst string
+		wantErr   bool
+	}{
+		{name: "postgres", dialect: Postgres, wantFirst: "define_nanoid"},
+		{name: "mysql", dialect: MySQL, wantFirst: "create_auth_tables"},
+		{name: "unknown dialect", dialect: "oracle", wantErr: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// Act
+			migrations, err := Load(test.dialect)
+
+			// Assert
+			if (err != nil) != test.wantErr {
+				t.Fatalf("Load() error = %v, want error %v", err, test.wantErr)
+			}
+			if test.wantErr {
+				return
+			}
+			if migrations[0].Name != test.wantFirst {
+				t.Errorf("first migration = %s, want %s", migrations[0].Name, test.wantFirst)
+			}
+			for i, migration := range migrations {
+				if i > 0 && migration.Version <= migrations[i-1].Version {
+					t.Errorf("%d_%s is out of order", migration.Version, migration.Name)
+				}
+				if strings.Contains(migration.Name, "partition") || migration.SQL == "" {
+					t.Errorf("unexpected migration %d_%s", migration.Version, migration.Name)
+				}
+			}
+		})
+	}
+}
+
+// Requirement: Up applies only the migrations not yet recorded, in order,
+// and stops at the first failure.
+func TestUp(t *testing.T) {
+	migrations := []Migration{{Version: 1, Name: "a"}, {Version: 2, Name: "b"}, {Version: 3, Name: "c"}}
+	tests := []struct {
+		name      string
+		applied   map[int64]bool
+		failOn    int64
+		wantCount int
+		wantOrder []int64
+		wantErr   bool
+	}{
+		{name: "fresh database", applied: map[int64]bool{}, wantCount: 3, wantOrder: []int64{1, 2, 3}},
+		{name: "partly migrated", applied: map[int64]bool{1: true}, wantCount: 2, wantOrder: []int64{2, 3}},
+		{name: "up to date", applied: map[int64]bool{1: true, 2: true, 3: true}},
+		{name: "failure", applied: map[int64]bool{}, failOn: 2, wantCount: 1, wantOrder: []int64{1}, wantErr: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// Arrange
+			exec := &recordingExecutor{applied: test.applied, failOn: test.failOn}
+
+			// Act
+			count, err := Up(context.Background(), exec, migrations)
+
+			// Assert
+			if (err != nil) != test.wantErr {
+				t.Fatalf("Up() error = %v, want error %v", err, test.wantErr)
+			}
+			if count != test.wantCount || len(exec.order) != len(test.wantOrder) {
+				t.Fatalf("Up() applied %v (count %d), want %v", exec.order, count, test.wantOrder)
+			}
+			for i, version := range test.wantOrder {
+				if exec.order[i] != version {
+					t.Errorf("Up() applied %v, want %v", exec.order, test.wantOrder)
+					break
+				}
+			}
+		})
+	}
+}
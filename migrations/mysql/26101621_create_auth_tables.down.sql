@@ -0,0 +1,16 @@
+SELECT GET_LOCK('kuta_26101621', 60);
+
+DROP TABLE IF EXISTS access_tokens;
+DROP TABLE IF EXISTS organization_invites;
+DROP TABLE IF EXISTS organization_members;
+DROP TABLE IF EXISTS role_permissions;
+DROP TABLE IF EXISTS user_roles;
+DROP TABLE IF EXISTS sign_in_attempts;
+DROP TABLE IF EXISTS verification_tokens;
+DROP TABLE IF EXISTS user_security;
+DROP TABLE IF EXISTS refresh_tokens;
+DROP TABLE IF EXISTS sessions;
+DROP TABLE IF EXISTS accounts;
+DROP TABLE IF EXISTS users;
+
+SELECT RELEASE_LOCK('kuta_26101621');
@@ -0,0 +1,181 @@
+-- Migration: create the auth tables for MySQL 8.0+ / MariaDB 10.5+. One
+-- migration holds the schema the postgres migrations reached by 26101620.
+-- Timestamps are DATETIME(6) in UTC and lists are JSON arrays. Token hashes
+-- are hex SHA-256, so they fit VARCHAR(64).
+
+SELECT GET_LOCK('kuta_26101621', 60);
+
+CREATE TABLE IF NOT EXISTS users (
+  id VARCHAR(64) NOT NULL PRIMARY KEY,
+  email VARCHAR(255) NOT NULL,
+  email_verified BOOLEAN NOT NULL DEFAULT FALSE,
+  name VARCHAR(255) NOT NULL,
+  image TEXT,
+  status VARCHAR(32) NOT NULL DEFAULT 'active',
+  created_at DATETIME(6) NOT NULL,
+  updated_at DATETIME(6) NOT NULL,
+  deleted_at DATETIME(6),
+  UNIQUE KEY uq_users_email (email),
+  KEY idx_users_deleted_at (deleted_at),
+  KEY idx_users_status_created_at (status, created_at)
+) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4 COLLATE = utf8mb4_unicode_ci;
+
+CREATE TABLE IF NOT EXISTS accounts (
+  id VARCHAR(64) NOT NULL PRIMARY KEY,
+  user_id VARCHAR(64) NOT NULL,
+  provider_id VARCHAR(64) NOT NULL,
+  account_id VARCHAR(255) NOT NULL,
+  password TEXT,
+  access_token TEXT,
+  refresh_token TEXT,
+  expires_at DATETIME(6),
+  profile_data JSON,
+  created_at DATETIME(6) NOT NULL,
+  updated_at DATETIME(6) NOT NULL,
+  UNIQUE KEY uq_accounts_provider_account (provider_id, account_id),
+  KEY idx_accounts_user_id (user_id),
+  KEY idx_accounts_expires_at (expires_at),
+  CONSTRAINT fk_accounts_user FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4 COLLATE = utf8mb4_unicode_ci;
+
+CREATE TABLE IF NOT EXISTS sessions (
+  id VARCHAR(64) NOT NULL PRIMARY KEY,
+  user_id VARCHAR(64) NOT NULL,
+  token_hash VARCHAR(64) NOT NULL,
+  ip_address VARCHAR(64),
+  user_agent TEXT,
+  public_key TEXT NOT NULL,
+  expires_at DATETIME(6) NOT NULL,
+  revoked_at DATETIME(6),
+  impersonator_id VARCHAR(64),
+  impersonation_started_at DATETIME(6),
+  data JSON NOT NULL,
+  active_organization_id VARCHAR(64) NOT NULL DEFAULT '',
+  entitlements JSON,
+  created_at DATETIME(6) NOT NULL,
+  updated_at DATETIME(6) NOT NULL,
+  UNIQUE KEY uq_sessions_token_hash (token_hash),
+  KEY idx_sessions_user_id (user_id),
+  KEY idx_sessions_created_at (created_at),
+  KEY idx_sessions_ip_address (ip_address),
+  KEY idx_sessions_expires_at (expires_at),
+  CONSTRAINT fk_sessions_user FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE,
+  CONSTRAINT fk_sessions_impersonator FOREIGN KEY (impersonator_id) REFERENCES users (id) ON DELETE CASCADE
+) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4 COLLATE = utf8mb4_unicode_ci;
+
+CREATE TABLE IF NOT EXISTS refresh_tokens (
+  id VARCHAR(64) NOT NULL PRIMARY KEY,
+  user_id VARCHAR(64) NOT NULL,
+  session_id VARCHAR(64) NOT NULL,
+  parent_id VARCHAR(64),
+  token_hash VARCHAR(64) NOT NULL,
+  ip_address VARCHAR(64),
+  user_agent TEXT,
+  public_key TEXT NOT NULL,
+  expires_at DATETIME(6) NOT NULL,
+  authenticated_at DATETIME(6) NOT NULL,
+  revoked_at DATETIME(6),
+  created_at DATETIME(6) NOT NULL,
+  UNIQUE KEY uq_refresh_tokens_token_hash (token_hash),
+  KEY idx_refresh_tokens_user_active (user_id, revoked_at, created_at),
+  KEY idx_refresh_tokens_session_id (session_id),
+  KEY idx_refresh_tokens_expires_at (expires_at),
+  CONSTRAINT fk_refresh_tokens_user FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE,
+  CONSTRAINT fk_refresh_tokens_parent FOREIGN KEY (parent_id) REFERENCES refresh_tokens (id) ON DELETE SET NULL
+) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4 COLLATE = utf8mb4_unicode_ci;
+
+CREATE TABLE IF NOT EXISTS user_security (
+  user_id VARCHAR(64) NOT NULL PRIMARY KEY,
+  two_factor_enabled BOOLEAN NOT NULL DEFAULT FALSE,
+  allowed_providers JSON NOT NULL,
+  max_sessions INT NOT NULL DEFAULT 0,
+  notify_new_sign_in BOOLEAN NOT NULL DEFAULT TRUE,
+  notify_password_changed BOOLEAN NOT NULL DEFAULT TRUE,
+  notify_anomalies BOOLEAN NOT NULL DEFAULT TRUE,
+  updated_at DATETIME(6) NOT NULL,
+  CONSTRAINT fk_user_security_user FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4 COLLATE = utf8mb4_unicode_ci;
+
+CREATE TABLE IF NOT EXISTS verification_tokens (
+  id VARCHAR(64) NOT NULL PRIMARY KEY,
+  user_id VARCHAR(64),
+  identifier VARCHAR(255) NOT NULL,
+  purpose VARCHAR(64) NOT NULL,
+  token_hash VARCHAR(64) NOT NULL,
+  expires_at DATETIME(6) NOT NULL,
+  consumed_at DATETIME(6),
+  created_at DATETIME(6) NOT NULL,
+  UNIQUE KEY uq_verification_tokens_token_hash (token_hash),
+  KEY idx_verification_tokens_user_id (user_id),
+  KEY idx_verification_tokens_expires_at (expires_at),
+  KEY idx_verification_tokens_consumed_at (consumed_at),
+  CONSTRAINT fk_verification_tokens_user FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4 COLLATE = utf8mb4_unicode_ci;
+
+CREATE TABLE IF NOT EXISTS sign_in_attempts (
+  id VARCHAR(64) NOT NULL PRIMARY KEY,
+  user_id VARCHAR(64),
+  email VARCHAR(255) NOT NULL DEFAULT '',
+  provider_id VARCHAR(64) NOT NULL,
+  success BOOLEAN NOT NULL,
+  reason VARCHAR(255) NOT NULL DEFAULT '',
+  ip_address VARCHAR(64) NOT NULL DEFAULT '',
+  user_agent TEXT NOT NULL,
+  created_at DATETIME(6) NOT NULL,
+  KEY idx_sign_in_attempts_user_created (user_id, created_at),
+  KEY idx_sign_in_attempts_created_at (created_at),
+  CONSTRAINT fk_sign_in_attempts_user FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4 COLLATE = utf8mb4_unicode_ci;
+
+CREATE TABLE IF NOT EXISTS user_roles (
+  user_id VARCHAR(64) NOT NULL,
+  role VARCHAR(191) NOT NULL,
+  created_at DATETIME(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6),
+  PRIMARY KEY (user_id, role),
+  CONSTRAINT fk_user_roles_user FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4 COLLATE = utf8mb4_unicode_ci;
+
+CREATE TABLE IF NOT EXISTS role_permissions (
+  role VARCHAR(191) NOT NULL,
+  permission VARCHAR(191) NOT NULL,
+  created_at DATETIME(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6),
+  PRIMARY KEY (role, permission)
+) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4 COLLATE = utf8mb4_unicode_ci;
+
+CREATE TABLE IF NOT EXISTS organization_members (
+  organization_id VARCHAR(64) NOT NULL,
+  user_id VARCHAR(64) NOT NULL,
+  roles JSON NOT NULL,
+  created_at DATETIME(6) NOT NULL,
+  PRIMARY KEY (organization_id, user_id),
+  KEY idx_organization_members_user_id (user_id),
+  CONSTRAINT fk_organization_members_user FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4 COLLATE = utf8mb4_unicode_ci;
+
+CREATE TABLE IF NOT EXISTS organization_invites (
+  id VARCHAR(64) NOT NULL PRIMARY KEY,
+  organization_id VARCHAR(64) NOT NULL,
+  email VARCHAR(255) NOT NULL,
+  roles JSON NOT NULL,
+  invited_by VARCHAR(64) NOT NULL DEFAULT '',
+  expires_at DATETIME(6) NOT NULL,
+  created_at DATETIME(6) NOT NULL,
+  KEY idx_organization_invites_organization_id (organization_id),
+  CONSTRAINT fk_organization_invites_token FOREIGN KEY (id) REFERENCES verification_tokens (id) ON DELETE CASCADE
+) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4 COLLATE = utf8mb4_unicode_ci;
+
+CREATE TABLE IF NOT EXISTS access_tokens (
+  id VARCHAR(64) NOT NULL PRIMARY KEY,
+  user_id VARCHAR(64) NOT NULL,
+  name VARCHAR(255) NOT NULL,
+  token_hash VARCHAR(64) NOT NULL,
+  scopes JSON NOT NULL,
+  expires_at DATETIME(6),
+  last_used_at DATETIME(6),
+  created_at DATETIME(6) NOT NULL,
+  UNIQUE KEY uq_access_tokens_token_hash (token_hash),
+  KEY idx_access_tokens_user_id (user_id),
+  CONSTRAINT fk_access_tokens_user FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4 COLLATE = utf8mb4_unicode_ci;
+
+SELECT RELEASE_LOCK('kuta_26101621');
@@ -0,0 +1,5 @@
+SELECT GET_LOCK('kuta_26101625', 60);
+
+ALTER TABLE refresh_tokens DROP COLUMN rotated_at;
+
+SELECT RELEASE_LOCK('kuta_26101625');
@@ -0,0 +1,9 @@
+-- Migration: refresh tokens record when they were rotated, so presenting a
+-- rotated token again can be told apart from presenting one revoked by
+-- sign-out or an admin.
+
+SELECT GET_LOCK('kuta_26101625', 60);
+
+ALTER TABLE refresh_tokens ADD COLUMN rotated_at DATETIME(6) AFTER revoked_at;
+
+SELECT RELEASE_LOCK('kuta_26101625');
@@ -0,0 +1,8 @@
+BEGIN;
+
+SELECT pg_advisory_xact_lock(26101601);
+
+DROP INDEX IF EXISTS public.idx_users_deleted_at;
+ALTER TABLE public.users DROP COLUMN IF EXISTS deleted_at;
+
+COMMIT;
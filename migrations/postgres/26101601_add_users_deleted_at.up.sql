@@ -0,0 +1,13 @@
+-- Migration: soft delete support for users
+-- Soft-deleted users keep their row (and email) until purged, so they can be
+-- restored within the configured retention window.
+
+BEGIN;
+
+SELECT pg_advisory_xact_lock(26101601);
+
+ALTER TABLE public.users ADD COLUMN IF NOT EXISTS deleted_at timestamptz;
+
+CREATE INDEX IF NOT EXISTS idx_users_deleted_at ON public.users(deleted_at) WHERE deleted_at IS NOT NULL;
+
+COMMIT;
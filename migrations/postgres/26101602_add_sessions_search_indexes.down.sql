@@ -0,0 +1,8 @@
+BEGIN;
+
+SELECT pg_advisory_xact_lock(26101602);
+
+DROP INDEX IF EXISTS public.idx_sessions_ip_address;
+DROP INDEX IF EXISTS public.idx_sessions_created_at;
+
+COMMIT;
@@ -0,0 +1,10 @@
+-- Migration: indexes backing admin session search (by creation window and IP)
+
+BEGIN;
+
+SELECT pg_advisory_xact_lock(26101602);
+
+CREATE INDEX IF NOT EXISTS idx_sessions_created_at ON public.sessions(created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_sessions_ip_address ON public.sessions(ip_address);
+
+COMMIT;
@@ -0,0 +1,10 @@
+-- Migration: proof-of-possession key bound to a session at sign-in
+-- Empty string means the session is a plain bearer token.
+
+BEGIN;
+
+SELECT pg_advisory_xact_lock(26101603);
+
+ALTER TABLE public.sessions ADD COLUMN IF NOT EXISTS public_key text NOT NULL DEFAULT '';
+
+COMMIT;
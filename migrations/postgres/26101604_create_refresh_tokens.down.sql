@@ -0,0 +1,7 @@
+BEGIN;
+
+SELECT pg_advisory_xact_lock(26101604);
+
+DROP TABLE IF EXISTS public.refresh_tokens;
+
+COMMIT;
@@ -0,0 +1,28 @@
+-- Migration: refresh tokens, kept apart from sessions
+-- session_id is not a foreign key: the session is deleted when its refresh
+-- token is rotated, and stateless sessions are never stored at all.
+-- parent_id links each rotated token to the one it replaced.
+
+BEGIN;
+
+SELECT pg_advisory_xact_lock(26101604);
+
+CREATE TABLE IF NOT EXISTS public.refresh_tokens (
+  id public.nanoid PRIMARY KEY DEFAULT gen_random_nanoid(),
+  user_id text NOT NULL REFERENCES public.users(id) ON DELETE CASCADE,
+  session_id text NOT NULL,
+  parent_id text REFERENCES public.refresh_tokens(id) ON DELETE SET NULL,
+  token_hash text NOT NULL UNIQUE,
+  ip_address text,
+  user_agent text,
+  public_key text NOT NULL DEFAULT '',
+  expires_at timestamptz NOT NULL,
+  revoked_at timestamptz,
+  created_at timestamptz NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_refresh_tokens_user_id ON public.refresh_tokens(user_id);
+CREATE INDEX IF NOT EXISTS idx_refresh_tokens_session_id ON public.refresh_tokens(session_id);
+CREATE INDEX IF NOT EXISTS idx_refresh_tokens_parent_id ON public.refresh_tokens(parent_id);
+
+COMMIT;
@@ -0,0 +1,7 @@
+BEGIN;
+
+SELECT pg_advisory_xact_lock(26101606);
+
+DROP INDEX IF EXISTS public.idx_accounts_expires_at;
+
+COMMIT;
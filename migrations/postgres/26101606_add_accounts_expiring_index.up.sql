@@ -0,0 +1,11 @@
+-- Migration: index backing the provider token refresh worker, which scans for
+-- accounts whose access token is about to expire
+
+BEGIN;
+
+SELECT pg_advisory_xact_lock(26101606);
+
+CREATE INDEX IF NOT EXISTS idx_accounts_expires_at ON public.accounts(expires_at)
+  WHERE refresh_token IS NOT NULL;
+
+COMMIT;
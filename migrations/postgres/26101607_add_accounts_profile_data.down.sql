@@ -0,0 +1,7 @@
+BEGIN;
+
+SELECT pg_advisory_xact_lock(26101607);
+
+ALTER TABLE public.accounts DROP COLUMN IF EXISTS profile_data;
+
+COMMIT;
@@ -0,0 +1,10 @@
+-- Migration: raw provider profile payloads (avatar, locale, ...) kept on the
+-- account so apps don't have to re-fetch them from the provider
+
+BEGIN;
+
+SELECT pg_advisory_xact_lock(26101607);
+
+ALTER TABLE public.accounts ADD COLUMN IF NOT EXISTS profile_data jsonb;
+
+COMMIT;
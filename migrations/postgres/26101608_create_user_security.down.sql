@@ -0,0 +1,7 @@
+BEGIN;
+
+SELECT pg_advisory_xact_lock(26101608);
+
+DROP TABLE IF EXISTS public.user_security;
+
+COMMIT;
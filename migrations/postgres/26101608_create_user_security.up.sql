@@ -0,0 +1,19 @@
+-- Migration: per-user security settings
+-- Users without a row use the defaults (see core.DefaultUserSecurity).
+
+BEGIN;
+
+SELECT pg_advisory_xact_lock(26101608);
+
+CREATE TABLE IF NOT EXISTS public.user_security (
+  user_id text PRIMARY KEY REFERENCES public.users(id) ON DELETE CASCADE,
+  two_factor_enabled boolean NOT NULL DEFAULT false,
+  allowed_providers text[] NOT NULL DEFAULT '{}',
+  max_sessions integer NOT NULL DEFAULT 0,
+  notify_new_sign_in boolean NOT NULL DEFAULT true,
+  notify_password_changed boolean NOT NULL DEFAULT true,
+  notify_anomalies boolean NOT NULL DEFAULT true,
+  updated_at timestamptz NOT NULL DEFAULT now()
+);
+
+COMMIT;
@@ -0,0 +1,7 @@
+BEGIN;
+
+SELECT pg_advisory_xact_lock(26101609);
+
+ALTER TABLE public.sessions DROP COLUMN IF EXISTS revoked_at;
+
+COMMIT;
@@ -0,0 +1,10 @@
+-- Migration: softly revoked sessions keep working for reads until their
+-- grace period ends; revoked_at marks them as draining
+
+BEGIN;
+
+SELECT pg_advisory_xact_lock(26101609);
+
+ALTER TABLE public.sessions ADD COLUMN IF NOT EXISTS revoked_at timestamptz;
+
+COMMIT;
@@ -0,0 +1,7 @@
+BEGIN;
+
+SELECT pg_advisory_xact_lock(26101610);
+
+DROP TABLE IF EXISTS public.verification_tokens;
+
+COMMIT;
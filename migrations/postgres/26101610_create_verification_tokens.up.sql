@@ -0,0 +1,25 @@
+-- Migration: single-use verification tokens (email verification, password
+-- reset, invites). user_id is null for invites to people without an account.
+-- Consumed tokens are kept until purged so reuse can be detected.
+
+BEGIN;
+
+SELECT pg_advisory_xact_lock(26101610);
+
+CREATE TABLE IF NOT EXISTS public.verification_tokens (
+  id public.nanoid PRIMARY KEY DEFAULT gen_random_nanoid(),
+  user_id text REFERENCES public.users(id) ON DELETE CASCADE,
+  identifier text NOT NULL,
+  purpose text NOT NULL,
+  token_hash text NOT NULL UNIQUE,
+  expires_at timestamptz NOT NULL,
+  consumed_at timestamptz,
+  created_at timestamptz NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_verification_tokens_user_id ON public.verification_tokens(user_id);
+CREATE INDEX IF NOT EXISTS idx_verification_tokens_expires_at ON public.verification_tokens(expires_at);
+CREATE INDEX IF NOT EXISTS idx_verification_tokens_consumed_at ON public.verification_tokens(consumed_at)
+  WHERE consumed_at IS NOT NULL;
+
+COMMIT;
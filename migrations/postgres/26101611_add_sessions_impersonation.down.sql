@@ -0,0 +1,9 @@
+BEGIN;
+
+SELECT pg_advisory_xact_lock(26101611);
+
+ALTER TABLE public.sessions
+  DROP COLUMN IF EXISTS impersonation_started_at,
+  DROP COLUMN IF EXISTS impersonator_id;
+
+COMMIT;
@@ -0,0 +1,12 @@
+-- Migration: sessions an admin opened as another user record the admin and
+-- when the impersonation started
+
+BEGIN;
+
+SELECT pg_advisory_xact_lock(26101611);
+
+ALTER TABLE public.sessions
+  ADD COLUMN IF NOT EXISTS impersonator_id text REFERENCES public.users(id) ON DELETE CASCADE,
+  ADD COLUMN IF NOT EXISTS impersonation_started_at timestamptz;
+
+COMMIT;
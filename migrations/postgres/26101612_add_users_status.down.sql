@@ -0,0 +1,8 @@
+BEGIN;
+
+SELECT pg_advisory_xact_lock(26101612);
+
+DROP INDEX IF EXISTS public.idx_users_pending_approval;
+ALTER TABLE public.users DROP COLUMN IF EXISTS status;
+
+COMMIT;
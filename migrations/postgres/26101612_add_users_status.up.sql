@@ -0,0 +1,14 @@
+-- Migration: users can wait in an approval queue before they may sign in;
+-- status is 'active', 'pending_approval' or 'rejected'
+
+BEGIN;
+
+SELECT pg_advisory_xact_lock(26101612);
+
+ALTER TABLE public.users ADD COLUMN IF NOT EXISTS status text NOT NULL DEFAULT 'active';
+
+-- The approval queue lists pending users oldest first
+CREATE INDEX IF NOT EXISTS idx_users_pending_approval ON public.users(created_at)
+  WHERE status = 'pending_approval' AND deleted_at IS NULL;
+
+COMMIT;
@@ -0,0 +1,8 @@
+BEGIN;
+
+SELECT pg_advisory_xact_lock(26101613);
+
+ALTER TABLE public.sessions
+  DROP COLUMN IF EXISTS data;
+
+COMMIT;
@@ -0,0 +1,11 @@
+-- Migration: sessions carry a small key-value store for app state such as
+-- CSRF secrets or the current onboarding step
+
+BEGIN;
+
+SELECT pg_advisory_xact_lock(26101613);
+
+ALTER TABLE public.sessions
+  ADD COLUMN IF NOT EXISTS data jsonb NOT NULL DEFAULT '{}';
+
+COMMIT;
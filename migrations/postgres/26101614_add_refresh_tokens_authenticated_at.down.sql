@@ -0,0 +1,10 @@
+BEGIN;
+
+SELECT pg_advisory_xact_lock(26101614);
+
+DROP INDEX IF EXISTS public.idx_refresh_tokens_user_active;
+
+ALTER TABLE public.refresh_tokens
+  DROP COLUMN IF EXISTS authenticated_at;
+
+COMMIT;
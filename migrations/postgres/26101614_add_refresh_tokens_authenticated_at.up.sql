@@ -0,0 +1,21 @@
+-- Migration: refresh tokens record when their rotation chain started, so
+-- chains can be given an absolute lifetime. Existing tokens count from their
+-- own creation.
+
+BEGIN;
+
+SELECT pg_advisory_xact_lock(26101614);
+
+ALTER TABLE public.refresh_tokens
+  ADD COLUMN IF NOT EXISTS authenticated_at timestamptz;
+
+UPDATE public.refresh_tokens SET authenticated_at = created_at WHERE authenticated_at IS NULL;
+
+ALTER TABLE public.refresh_tokens
+  ALTER COLUMN authenticated_at SET DEFAULT now(),
+  ALTER COLUMN authenticated_at SET NOT NULL;
+
+CREATE INDEX IF NOT EXISTS idx_refresh_tokens_user_active
+  ON public.refresh_tokens(user_id, created_at) WHERE revoked_at IS NULL;
+
+COMMIT;
@@ -0,0 +1,7 @@
+BEGIN;
+
+SELECT pg_advisory_xact_lock(26101615);
+
+DROP TABLE IF EXISTS public.sign_in_attempts;
+
+COMMIT;
@@ -0,0 +1,25 @@
+-- Migration: the sign-in attempt log behind GetRecentAttempts. user_id is
+-- null for attempts on emails that matched no user. Rows are purged by the
+-- cleanup job once past their retention.
+
+BEGIN;
+
+SELECT pg_advisory_xact_lock(26101615);
+
+CREATE TABLE IF NOT EXISTS public.sign_in_attempts (
+  id text PRIMARY KEY,
+  user_id text REFERENCES public.users(id) ON DELETE CASCADE,
+  email text NOT NULL DEFAULT '',
+  provider_id text NOT NULL,
+  success boolean NOT NULL,
+  reason text NOT NULL DEFAULT '',
+  ip_address text NOT NULL DEFAULT '',
+  user_agent text NOT NULL DEFAULT '',
+  created_at timestamptz NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_sign_in_attempts_user_created
+  ON public.sign_in_attempts(user_id, created_at DESC) WHERE user_id IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_sign_in_attempts_created_at ON public.sign_in_attempts(created_at);
+
+COMMIT;
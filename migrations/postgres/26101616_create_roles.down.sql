@@ -0,0 +1,8 @@
+BEGIN;
+
+SELECT pg_advisory_xact_lock(26101616);
+
+DROP TABLE IF EXISTS public.role_permissions;
+DROP TABLE IF EXISTS public.user_roles;
+
+COMMIT;
@@ -0,0 +1,23 @@
+-- Migration: user roles and the permissions each role carries, behind
+-- RoleStorage. Roles are free-form names; a role needs no row in
+-- role_permissions to be assigned.
+
+BEGIN;
+
+SELECT pg_advisory_xact_lock(26101616);
+
+CREATE TABLE IF NOT EXISTS public.user_roles (
+  user_id text NOT NULL REFERENCES public.users(id) ON DELETE CASCADE,
+  role text NOT NULL,
+  created_at timestamptz NOT NULL DEFAULT now(),
+  PRIMARY KEY (user_id, role)
+);
+
+CREATE TABLE IF NOT EXISTS public.role_permissions (
+  role text NOT NULL,
+  permission text NOT NULL,
+  created_at timestamptz NOT NULL DEFAULT now(),
+  PRIMARY KEY (role, permission)
+);
+
+COMMIT;
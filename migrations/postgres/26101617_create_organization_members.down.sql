@@ -0,0 +1,9 @@
+BEGIN;
+
+SELECT pg_advisory_xact_lock(26101617);
+
+ALTER TABLE public.sessions DROP COLUMN IF EXISTS active_organization_id;
+
+DROP TABLE IF EXISTS public.organization_members;
+
+COMMIT;
@@ -0,0 +1,22 @@
+-- Migration: organization memberships and the active organization of
+-- sessions. Organizations themselves live in the app's own tables, so
+-- organization_id references nothing here.
+
+BEGIN;
+
+SELECT pg_advisory_xact_lock(26101617);
+
+CREATE TABLE IF NOT EXISTS public.organization_members (
+  organization_id text NOT NULL,
+  user_id text NOT NULL REFERENCES public.users(id) ON DELETE CASCADE,
+  roles text[] NOT NULL DEFAULT '{}',
+  created_at timestamptz NOT NULL DEFAULT now(),
+  PRIMARY KEY (organization_id, user_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_organization_members_user_id ON public.organization_members(user_id);
+
+ALTER TABLE public.sessions
+  ADD COLUMN IF NOT EXISTS active_organization_id text NOT NULL DEFAULT '';
+
+COMMIT;
@@ -0,0 +1,7 @@
+BEGIN;
+
+SELECT pg_advisory_xact_lock(26101618);
+
+DROP TABLE IF EXISTS public.organization_invites;
+
+COMMIT;
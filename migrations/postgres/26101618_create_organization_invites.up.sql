@@ -0,0 +1,20 @@
+-- Migration: pending organization members. Each invite is accepted with the
+-- verification token sharing its id, and goes away with it when purged.
+
+BEGIN;
+
+SELECT pg_advisory_xact_lock(26101618);
+
+CREATE TABLE IF NOT EXISTS public.organization_invites (
+  id public.nanoid PRIMARY KEY REFERENCES public.verification_tokens(id) ON DELETE CASCADE,
+  organization_id text NOT NULL,
+  email text NOT NULL,
+  roles text[] NOT NULL DEFAULT '{}',
+  invited_by text NOT NULL DEFAULT '',
+  expires_at timestamptz NOT NULL,
+  created_at timestamptz NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_organization_invites_organization_id ON public.organization_invites(organization_id);
+
+COMMIT;
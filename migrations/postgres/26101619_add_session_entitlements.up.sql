@@ -0,0 +1,11 @@
+-- Migration: entitlements (plan, feature flags) resolved when a session is
+-- issued. Null for sessions issued without an EntitlementResolver.
+
+BEGIN;
+
+SELECT pg_advisory_xact_lock(26101619);
+
+ALTER TABLE public.sessions
+  ADD COLUMN IF NOT EXISTS entitlements jsonb;
+
+COMMIT;
@@ -0,0 +1,7 @@
+BEGIN;
+
+SELECT pg_advisory_xact_lock(26101620);
+
+DROP TABLE IF EXISTS public.access_tokens;
+
+COMMIT;
@@ -0,0 +1,21 @@
+-- Migration: personal access tokens. Only the SHA-256 hash of each token is
+-- stored; tokens go away with their user.
+
+BEGIN;
+
+SELECT pg_advisory_xact_lock(26101620);
+
+CREATE TABLE IF NOT EXISTS public.access_tokens (
+  id public.nanoid PRIMARY KEY,
+  user_id text NOT NULL REFERENCES public.users(id) ON DELETE CASCADE,
+  name text NOT NULL,
+  token_hash text NOT NULL UNIQUE,
+  scopes text[] NOT NULL DEFAULT '{}',
+  expires_at timestamptz,
+  last_used_at timestamptz,
+  created_at timestamptz NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_access_tokens_user_id ON public.access_tokens(user_id);
+
+COMMIT;
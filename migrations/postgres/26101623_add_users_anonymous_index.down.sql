@@ -0,0 +1,7 @@
+BEGIN;
+
+SELECT pg_advisory_xact_lock(26101623);
+
+DROP INDEX IF EXISTS public.idx_users_anonymous;
+
+COMMIT;
@@ -0,0 +1,12 @@
+-- Migration: guest sessions belong to users with status 'anonymous', which
+-- cleanup deletes once abandoned
+
+BEGIN;
+
+SELECT pg_advisory_xact_lock(26101623);
+
+-- Cleanup lists anonymous users oldest first
+CREATE INDEX IF NOT EXISTS idx_users_anonymous ON public.users(created_at)
+  WHERE status = 'anonymous' AND deleted_at IS NULL;
+
+COMMIT;
@@ -0,0 +1,16 @@
+-- Fails while a soft-deleted user shares its email or a provider account
+-- with a live one; purge or restore such users first.
+
+BEGIN;
+
+SELECT pg_advisory_xact_lock(26101624);
+
+DROP INDEX IF EXISTS public.idx_accounts_provider_live;
+ALTER TABLE public.accounts ADD CONSTRAINT accounts_provider_id_account_id_key UNIQUE (provider_id, account_id);
+
+DROP INDEX IF EXISTS public.idx_users_email_live;
+ALTER TABLE public.users ADD CONSTRAINT users_email_key UNIQUE (email);
+
+ALTER TABLE public.accounts DROP COLUMN IF EXISTS deleted_at;
+
+COMMIT;
@@ -0,0 +1,25 @@
+-- Migration: soft-deleted users give up their email and provider accounts,
+-- so they can be signed up or linked again while the deleted user waits to
+-- be purged. Accounts get their own deleted_at, set along with their user's,
+-- since a partial index can't look at another table.
+
+BEGIN;
+
+SELECT pg_advisory_xact_lock(26101624);
+
+ALTER TABLE public.accounts ADD COLUMN IF NOT EXISTS deleted_at timestamptz;
+
+UPDATE public.accounts
+   SET deleted_at = users.deleted_at
+  FROM public.users
+ WHERE users.id = accounts.user_id AND users.deleted_at IS NOT NULL;
+
+ALTER TABLE public.users DROP CONSTRAINT IF EXISTS users_email_key;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_users_email_live ON public.users(email)
+  WHERE deleted_at IS NULL;
+
+ALTER TABLE public.accounts DROP CONSTRAINT IF EXISTS accounts_provider_id_account_id_key;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_accounts_provider_live ON public.accounts(provider_id, account_id)
+  WHERE deleted_at IS NULL;
+
+COMMIT;
@@ -0,0 +1,8 @@
+BEGIN;
+
+SELECT pg_advisory_xact_lock(26101625);
+
+ALTER TABLE public.refresh_tokens
+  DROP COLUMN IF EXISTS rotated_at;
+
+COMMIT;
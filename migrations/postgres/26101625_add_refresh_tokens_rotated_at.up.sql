@@ -0,0 +1,13 @@
+-- Migration: refresh tokens record when they were rotated, so presenting a
+-- rotated token again can be told apart from presenting one revoked by
+-- sign-out or an admin. Tokens revoked before have no rotated_at and are
+-- just refused.
+
+BEGIN;
+
+SELECT pg_advisory_xact_lock(26101625);
+
+ALTER TABLE public.refresh_tokens
+  ADD COLUMN IF NOT EXISTS rotated_at timestamptz;
+
+COMMIT;
@@ -0,0 +1,120 @@
+-- Optional migration: range-partition public.sessions by expires_at (one
+-- partition per UTC day) so expired sessions are dropped a partition at a
+-- time instead of with large DELETEs. Apply it after the regular migrations
+-- and construct the pgx adapter with Options{PartitionedSessions: true}.
+--
+-- Trade-offs: the primary key and token_hash uniqueness include expires_at
+-- (a Postgres requirement for partitioned tables); token hashes are random
+-- 256-bit values so collisions across partitions are not a practical concern.
+
+BEGIN;
+
+SELECT pg_advisory_xact_lock(26101605);
+
+-- Move the old table and its index names out of the way so the
+-- partitioned table keeps the canonical names
+ALTER TABLE public.sessions RENAME TO sessions_unpartitioned;
+ALTER TABLE public.sessions_unpartitioned RENAME CONSTRAINT sessions_pkey TO sessions_unpartitioned_pkey;
+ALTER TABLE public.sessions_unpartitioned RENAME CONSTRAINT sessions_token_hash_key TO sessions_unpartitioned_token_hash_key;
+ALTER INDEX public.idx_sessions_user_id RENAME TO idx_sessions_unpartitioned_user_id;
+ALTER INDEX public.idx_sessions_created_at RENAME TO idx_sessions_unpartitioned_created_at;
+ALTER INDEX public.idx_sessions_ip_address RENAME TO idx_sessions_unpartitioned_ip_address;
+
+CREATE TABLE public.sessions (
+  id public.nanoid NOT NULL DEFAULT gen_random_nanoid(),
+  user_id text NOT NULL REFERENCES public.users(id) ON DELETE CASCADE,
+  token_hash text NOT NULL,
+  ip_address text,
+  user_agent text,
+  public_key text NOT NULL DEFAULT '',
+  expires_at timestamptz NOT NULL,
+  revoked_at timestamptz,
+  impersonator_id text REFERENCES public.users(id) ON DELETE CASCADE,
+  impersonation_started_at timestamptz,
+  data jsonb NOT NULL DEFAULT '{}',
+  active_organization_id text NOT NULL DEFAULT '',
+  entitlements jsonb,
+  created_at timestamptz NOT NULL DEFAULT now(),
+  updated_at timestamptz NOT NULL DEFAULT now(),
+  PRIMARY KEY (id, expires_at),
+  UNIQUE (token_hash, expires_at)
+) PARTITION BY RANGE (expires_at);
+
+-- Catches rows outside the pre-created daily partitions
+CREATE TABLE public.sessions_default PARTITION OF public.sessions DEFAULT;
+
+CREATE INDEX IF NOT EXISTS idx_sessions_user_id ON public.sessions(user_id);
+CREATE INDEX IF NOT EXISTS idx_sessions_created_at ON public.sessions(created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_sessions_ip_address ON public.sessions(ip_address);
+
+-- Creates daily partitions from today (UTC) through today + days_ahead.
+-- Rows already sitting in the default partition for a new day are moved
+-- into it, since Postgres refuses to attach a partition that would
+-- conflict with the default partition's contents.
+CREATE OR REPLACE FUNCTION public.kuta_ensure_session_partitions(days_ahead integer DEFAULT 7)
+RETURNS integer
+LANGUAGE plpgsql AS $$
+DECLARE
+  today date := (now() AT TIME ZONE 'UTC')::date;
+  day date;
+  lo timestamptz;
+  hi timestamptz;
+  part text;
+  created integer := 0;
+BEGIN
+  FOR i IN 0..days_ahead LOOP
+    day := today + i;
+    part := 'sessions_p' || to_char(day, 'YYYYMMDD');
+    CONTINUE WHEN to_regclass('public.' || part) IS NOT NULL;
+
+    lo := day::timestamp AT TIME ZONE 'UTC';
+    hi := (day + 1)::timestamp AT TIME ZONE 'UTC';
+
+    EXECUTE format('CREATE TABLE public.%I (LIKE public.sessions INCLUDING DEFAULTS)', part);
+    EXECUTE format(
+      'WITH moved AS (DELETE FROM public.sessions_default WHERE expires_at >= $1 AND expires_at < $2 RETURNING *)
+       INSERT INTO public.%I SELECT * FROM moved', part)
+      USING lo, hi;
+    EXECUTE format('ALTER TABLE public.sessions ATTACH PARTITION public.%I FOR VALUES FROM (%L) TO (%L)', part, lo, hi);
+    created := created + 1;
+  END LOOP;
+  RETURN created;
+END
+$$;
+
+-- Drops daily partitions whose whole day has passed and returns how many
+-- sessions they held.
+CREATE OR REPLACE FUNCTION public.kuta_drop_expired_session_partitions()
+RETURNS bigint
+LANGUAGE plpgsql AS $$
+DECLARE
+  today date := (now() AT TIME ZONE 'UTC')::date;
+  part text;
+  n bigint;
+  dropped bigint := 0;
+BEGIN
+  FOR part IN
+    SELECT c.relname
+    FROM pg_inherits i
+    JOIN pg_class c ON c.oid = i.inhrelid
+    WHERE i.inhparent = 'public.sessions'::regclass
+      AND c.relname ~ '^sessions_p[0-9]{8}$'
+  LOOP
+    CONTINUE WHEN to_date(substr(part, 11), 'YYYYMMDD') >= today;
+    EXECUTE format('SELECT count(*) FROM public.%I', part) INTO n;
+    EXECUTE format('DROP TABLE public.%I', part);
+    dropped := dropped + n;
+  END LOOP;
+  RETURN dropped;
+END
+$$;
+
+SELECT public.kuta_ensure_session_partitions(7);
+
+INSERT INTO public.sessions (id, user_id, token_hash, ip_address, user_agent, public_key, expires_at, revoked_at, impersonator_id, impersonation_started_at, data, active_organization_id, entitlements, created_at, updated_at)
+SELECT id, user_id, token_hash, ip_address, user_agent, public_key, expires_at, revoked_at, impersonator_id, impersonation_started_at, data, active_organization_id, entitlements, created_at, updated_at
+FROM public.sessions_unpartitioned;
+
+DROP TABLE public.sessions_unpartitioned;
+
+COMMIT;
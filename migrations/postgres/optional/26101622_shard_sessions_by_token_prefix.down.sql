@@ -0,0 +1,37 @@
+BEGIN;
+
+SELECT pg_advisory_xact_lock(26101622);
+
+CREATE TABLE public.sessions_unsharded (
+  id public.nanoid PRIMARY KEY DEFAULT gen_random_nanoid(),
+  user_id text NOT NULL REFERENCES public.users(id) ON DELETE CASCADE,
+  token_hash text NOT NULL UNIQUE,
+  ip_address text,
+  user_agent text,
+  public_key text NOT NULL DEFAULT '',
+  expires_at timestamptz NOT NULL,
+  revoked_at timestamptz,
+  impersonator_id text REFERENCES public.users(id) ON DELETE CASCADE,
+  impersonation_started_at timestamptz,
+  data jsonb NOT NULL DEFAULT '{}',
+  active_organization_id text NOT NULL DEFAULT '',
+  entitlements jsonb,
+  created_at timestamptz NOT NULL DEFAULT now(),
+  updated_at timestamptz NOT NULL DEFAULT now()
+);
+
+INSERT INTO public.sessions_unsharded (id, user_id, token_hash, ip_address, user_agent, public_key, expires_at, revoked_at, impersonator_id, impersonation_started_at, data, active_organization_id, entitlements, created_at, updated_at)
+SELECT id, user_id, token_hash, ip_address, user_agent, public_key, expires_at, revoked_at, impersonator_id, impersonation_started_at, data, active_organization_id, entitlements, created_at, updated_at
+FROM public.sessions;
+
+DROP TABLE public.sessions;
+
+ALTER TABLE public.sessions_unsharded RENAME TO sessions;
+ALTER TABLE public.sessions RENAME CONSTRAINT sessions_unsharded_pkey TO sessions_pkey;
+ALTER TABLE public.sessions RENAME CONSTRAINT sessions_unsharded_token_hash_key TO sessions_token_hash_key;
+
+CREATE INDEX IF NOT EXISTS idx_sessions_user_id ON public.sessions(user_id);
+CREATE INDEX IF NOT EXISTS idx_sessions_created_at ON public.sessions(created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_sessions_ip_address ON public.sessions(ip_address);
+
+COMMIT;
@@ -0,0 +1,73 @@
+-- Optional migration: list-partition public.sessions by the first hex
+-- character of token_hash into 16 shards (sessions_s0 .. sessions_sf) so
+-- each token_hash index stays a sixteenth of the table. Apply it after the
+-- regular migrations and construct the pgx adapter with
+-- Options{PrefixShardedSessions: true}, which routes token lookups straight
+-- to the right shard.
+--
+-- Trade-offs: the primary key and token_hash uniqueness include
+-- token_prefix (a Postgres requirement for partitioned tables), which is
+-- derived from token_hash so uniqueness is unchanged. Lookups by session ID
+-- probe every shard. It can't be combined with
+-- 26101605_partition_sessions.
+
+BEGIN;
+
+SELECT pg_advisory_xact_lock(26101622);
+
+-- Move the old table and its index names out of the way so the
+-- partitioned table keeps the canonical names
+ALTER TABLE public.sessions RENAME TO sessions_unsharded;
+ALTER TABLE public.sessions_unsharded RENAME CONSTRAINT sessions_pkey TO sessions_unsharded_pkey;
+ALTER TABLE public.sessions_unsharded RENAME CONSTRAINT sessions_token_hash_key TO sessions_unsharded_token_hash_key;
+ALTER INDEX public.idx_sessions_user_id RENAME TO idx_sessions_unsharded_user_id;
+ALTER INDEX public.idx_sessions_created_at RENAME TO idx_sessions_unsharded_created_at;
+ALTER INDEX public.idx_sessions_ip_address RENAME TO idx_sessions_unsharded_ip_address;
+
+CREATE TABLE public.sessions (
+  id public.nanoid NOT NULL DEFAULT gen_random_nanoid(),
+  user_id text NOT NULL REFERENCES public.users(id) ON DELETE CASCADE,
+  token_hash text NOT NULL,
+  token_prefix text NOT NULL,
+  ip_address text,
+  user_agent text,
+  public_key text NOT NULL DEFAULT '',
+  expires_at timestamptz NOT NULL,
+  revoked_at timestamptz,
+  impersonator_id text REFERENCES public.users(id) ON DELETE CASCADE,
+  impersonation_started_at timestamptz,
+  data jsonb NOT NULL DEFAULT '{}',
+  active_organization_id text NOT NULL DEFAULT '',
+  entitlements jsonb,
+  created_at timestamptz NOT NULL DEFAULT now(),
+  updated_at timestamptz NOT NULL DEFAULT now(),
+  PRIMARY KEY (id, token_prefix),
+  UNIQUE (token_hash, token_prefix)
+) PARTITION BY LIST (token_prefix);
+
+DO $$
+DECLARE
+  prefix text;
+BEGIN
+  FOREACH prefix IN ARRAY string_to_array('0123456789abcdef', NULL) LOOP
+    EXECUTE format('CREATE TABLE public.%I PARTITION OF public.sessions FOR VALUES IN (%L)', 'sessions_s' || prefix, prefix);
+  END LOOP;
+END$$;
+
+-- Catches token hashes that don't start with a lowercase hex character
+CREATE TABLE public.sessions_other PARTITION OF public.sessions DEFAULT;
+
+CREATE INDEX IF NOT EXISTS idx_sessions_id ON public.sessions(id);
+CREATE INDEX IF NOT EXISTS idx_sessions_user_id ON public.sessions(user_id);
+CREATE INDEX IF NOT EXISTS idx_sessions_created_at ON public.sessions(created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_sessions_ip_address ON public.sessions(ip_address);
+
+INSERT INTO public.sessions (id, user_id, token_hash, token_prefix, ip_address, user_agent, public_key, expires_at, revoked_at, impersonator_id, impersonation_started_at, data, active_organization_id, entitlements, created_at, updated_at)
+SELECT id, user_id, token_hash,
+       CASE WHEN left(token_hash, 1) ~ '^[0-9a-f]$' THEN left(token_hash, 1) ELSE '' END,
+       ip_address, user_agent, public_key, expires_at, revoked_at, impersonator_id, impersonation_started_at, data, active_organization_id, entitlements, created_at, updated_at
+FROM public.sessions_unsharded;
+
+DROP TABLE public.sessions_unsharded;
+
+COMMIT;
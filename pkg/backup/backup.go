@@ -0,0 +1,268 @@
+// Package backup streams the contents of a core.StorageProvider to JSON and
+// back, so deployments can move between storage adapters without
+// hand-written ETL.
+//
+// A backup is JSON Lines: a header, then one line per user holding the
+// user with their accounts, security settings, sessions and active refresh
+// tokens. Unlike the API's JSON it includes password hashes, provider
+// tokens and session token hashes, so store it like the database itself.
+package backup
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/lborres/kuta/core"
+)
+
+const (
+	// formatName and formatVersion identify backups in their header
+	formatName    = "kuta-backup"
+	formatVersion = 1
+
+	// pageSize is how many users Backup lists per storage call
+	pageSize = 500
+)
+
+var (
+	ErrInvalidBackup      = errors.New("backup: not a kuta backup")
+	ErrUnsupportedVersion = errors.New("backup: unsupported backup version")
+)
+
+// Options configures Backup
+type Options struct {
+	// Providers lists the OAuth provider IDs whose accounts are backed up,
+	// e.g. "google". Storage can only list accounts by provider, so
+	// accounts of providers missing here are left out. Credential, passkey
+	// and TOTP accounts are always included.
+	Providers []string
+}
+
+// Stats counts what a backup or restore went through
+type Stats struct {
+	Users         int `json:"users"`
+	Accounts      int `json:"accounts"`
+	Sessions      int `json:"sessions"`
+	RefreshTokens int `json:"refreshTokens"`
+}
+
+type header struct {
+	Format    string    `json:"format"`
+	Version   int       `json:"version"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// entry is one user and everything stored for them
+type entry struct {
+	User          *core.User         `json:"user"`
+	Accounts      []account          `json:"accounts,omitempty"`
+	Security      *core.UserSecurity `json:"security,omitempty"`
+	Sessions      []session          `json:"sessions,omitempty"`
+	RefreshTokens []refreshToken     `json:"refreshTokens,omitempty"`
+}
+
+// account, session and refreshToken add the fields their core types keep
+// out of JSON
+type account struct {
+	*core.Account
+	Password     *string        `json:"password,omitempty"`
+	AccessToken  *string        `json:"accessToken,omitempty"`
+	RefreshToken *string        `json:"refreshToken,omitempty"`
+	ProfileData  map[string]any `json:"profileData,omitempty"`
+}
+
+type session struct {
+	*core.Session
+	TokenHash string            `json:"tokenHash"`
+	Data      map[string]string `json:"data,omitempty"`
+}
+
+type refreshToken struct {
+	*core.RefreshToken
+	TokenHash string `json:"tokenHash"`
+}
+
+// Backup writes every user in storage to w. Soft-deleted users are left
+// out, and so are revoked refresh tokens, which only matter for reuse
+// detection. Users are paged by creation time, so back up a storage that
+// isn't being written to.
+func Backup(ctx context.Context, storage core.StorageProvider, w io.Writer, opts ...Options) (*Stats, error) {
+	var o Options
+	if len(opts) > 0 {
+		o = opts[0]
+	}
+	providers := append([]string{core.CredentialProviderID, core.PasskeyProviderID, core.TOTPProviderID}, o.Providers...)
+
+	encoder := json.NewEncoder(w)
+	if err := encoder.Encode(header{Format: formatName, Version: formatVersion, CreatedAt: time.Now()}); err != nil {
+		return nil, err
+	}
+
+	stats := &Stats{}
+	for _, status := range []string{core.UserStatusActive, core.UserStatusPending, core.UserStatusRejected} {
+		for offset := 0; ; offset += pageSize {
+			users, _, err := storage.ListUsersByStatus(ctx, status, pageSize, offset)
+			if err != nil {
+				return stats, err
+			}
+			for _, user := range users {
+				e, err := collect(ctx, storage, user, providers)
+				if err != nil {
+					return stats, fmt.Errorf("backup: user %s: %w", user.ID, err)
+				}
+				if err := encoder.Encode(e); err != nil {
+					return stats, err
+				}
+				stats.add(e)
+			}
+			if len(users) < pageSize {
+				break
+			}
+		}
+	}
+	return stats, nil
+}
+
+// collect reads everything stored for user
+func collect(ctx context.Context, storage core.StorageProvider, user *core.User, providers []string) (*entry, error) {
+	e := &entry{User: user}
+
+	for _, providerID := range providers {
+		accounts, err := storage.GetAccountByUserAndProvider(ctx, user.ID, providerID)
+		if err != nil {
+			return nil, err
+		}
+		for _, a := range accounts {
+			e.Accounts = append(e.Accounts, account{
+				Account:      a,
+				Password:     a.Password,
+				AccessToken:  a.AccessToken,
+				RefreshToken: a.RefreshToken,
+				ProfileData:  a.ProfileData,
+			})
+		}
+	}
+
+	security, err := storage.GetUserSecurity(ctx, user.ID)
+	switch {
+	case err == nil:
+		e.Security = security
+	case !errors.Is(err, core.ErrUserSecurityNotFound):
+		return nil, err
+	}
+
+	sessions, err := storage.GetUserSessions(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	for _, s := range sessions {
+		e.Sessions = append(e.Sessions, session{Session: s, TokenHash: s.TokenHash, Data: s.Data})
+	}
+
+	tokens, err := storage.GetActiveUserRefreshTokens(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	for _, t := range tokens {
+		// The revoked parent isn't backed up, and storage may reference it
+		copied := *t
+		copied.ParentID = nil
+		e.RefreshTokens = append(e.RefreshTokens, refreshToken{RefreshToken: &copied, TokenHash: t.TokenHash})
+	}
+	return e, nil
+}
+
+// Restore reads a backup written by Backup and creates its records in
+// storage, keeping their IDs. It is meant for an empty storage: a user that
+// already exists stops the restore with ErrUserExists. Timestamps the
+// storage assigns itself are those of the restore.
+func Restore(ctx context.Context, storage core.StorageProvider, r io.Reader) (*Stats, error) {
+	decoder := json.NewDecoder(r)
+	var h header
+	if err := decoder.Decode(&h); err != nil || h.Format != formatName {
+		return nil, ErrInvalidBackup
+	}
+	if h.Version != formatVersion {
+		return nil, fmt.Errorf("%w: %d", ErrUnsupportedVersion, h.Version)
+	}
+
+	stats := &Stats{}
+	for {
+		var e entry
+		err := decoder.Decode(&e)
+		if errors.Is(err, io.EOF) {
+			return stats, nil
+		}
+		if err != nil {
+			return stats, fmt.Errorf("backup: %w", err)
+		}
+		if e.User == nil {
+			return stats, ErrInvalidBackup
+		}
+		if err := restoreEntry(ctx, storage, &e); err != nil {
+			return stats, fmt.Errorf("backup: user %s: %w", e.User.ID, err)
+		}
+		stats.add(&e)
+	}
+}
+
+func restoreEntry(ctx context.Context, storage core.StorageProvider, e *entry) error {
+	for _, a := range e.Accounts {
+		if a.Account == nil {
+			return ErrInvalidBackup
+		}
+	}
+	for _, s := range e.Sessions {
+		if s.Session == nil {
+			return ErrInvalidBackup
+		}
+	}
+	for _, t := range e.RefreshTokens {
+		if t.RefreshToken == nil {
+			return ErrInvalidBackup
+		}
+	}
+
+	if err := storage.CreateUser(ctx, e.User); err != nil {
+		return err
+	}
+	for _, a := range e.Accounts {
+		a.Account.Password = a.Password
+		a.Account.AccessToken = a.AccessToken
+		a.Account.RefreshToken = a.RefreshToken
+		a.Account.ProfileData = a.ProfileData
+		if err := storage.CreateAccount(ctx, a.Account); err != nil {
+			return err
+		}
+	}
+	if e.Security != nil {
+		if err := storage.UpsertUserSecurity(ctx, e.Security); err != nil {
+			return err
+		}
+	}
+	for _, s := range e.Sessions {
+		s.Session.TokenHash = s.TokenHash
+		s.Session.Data = s.Data
+		if err := storage.CreateSession(ctx, s.Session); err != nil {
+			return err
+		}
+	}
+	for _, t := range e.RefreshTokens {
+		t.RefreshToken.TokenHash = t.TokenHash
+		if err := storage.CreateRefreshToken(ctx, t.RefreshToken); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *Stats) add(e *entry) {
+	s.Users++
+	s.Accounts += len(e.Accounts)
+	s.Sessions += len(e.Sessions)
+	s.RefreshTokens += len(e.RefreshTokens)
+}
@@ -0,0 +1,129 @@
+package backup
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lborres/kuta/core"
+	"github.com/lborres/kuta/services"
+)
+
+// newSourceStorage returns a storage holding a user with credential and
+// google accounts, security settings, a session and a rotated refresh token,
+// plus a pending user
+func newSourceStorage(t *testing.T) *services.FakeStorageProvider {
+	t.Helper()
+	storage := services.NewFakeStorageProvider()
+	now := time.Now()
+	password, accessToken := "$argon2id$hash", "ya29.token"
+	parentID := "refresh-0"
+
+	_ = storage.CreateUser(t.Context(), &core.User{ID: "user-1", Email: "a@example.com", Name: "Ada", CreatedAt: now})
+	_ = storage.CreateUser(t.Context(), &core.User{ID: "user-2", Email: "b@example.com", Status: core.UserStatusPending, CreatedAt: now})
+	_ = storage.CreateAccount(t.Context(), &core.Account{ID: "account-1", UserID: "user-1", ProviderID: core.CredentialProviderID, AccountID: "a@example.com", Password: &password})
+	_ = storage.CreateAccount(t.Context(), &core.Account{ID: "account-2", UserID: "user-1", ProviderID: "google", AccountID: "42", AccessToken: &accessToken, ProfileData: map[string]any{"locale": "en"}})
+	_ = storage.UpsertUserSecurity(t.Context(), &core.UserSecurity{UserID: "user-1", MaxSessions: 3})
+	_ = storage.CreateSession(t.Context(), &core.Session{ID: "session-1", UserID: "user-1", TokenHash: "session-hash", ExpiresAt: now.Add(time.Hour), Data: map[string]string{"csrf": "x"}})
+	if err := storage.CreateRefreshToken(t.Context(), &core.RefreshToken{ID: "refresh-1", UserID: "user-1", SessionID: "session-1", ParentID: &parentID, TokenHash: "refresh-hash", ExpiresAt: now.Add(time.Hour)}); err != nil {
+		t.Fatalf("CreateRefreshToken() error = %v", err)
+	}
+	return storage
+}
+
+// Requirement: A backup restored into empty storage brings back users with
+// their accounts of the listed providers, secrets included, security
+// settings, sessions and refresh tokens.
+func TestBackupRestore(t *testing.T) {
+	tests := []struct {
+		name         string
+		opts         Options
+		wantAccounts int
+	}{
+		{name: "with provider accounts", opts: Options{Providers: []string{"google"}}, wantAccounts: 2},
+		{name: "credential accounts only", wantAccounts: 1},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// Arrange
+			source := newSourceStorage(t)
+			target := services.NewFakeStorageProvider()
+			var buf bytes.Buffer
+
+			// Act
+			backedUp, backupErr := Backup(t.Context(), source, &buf, test.opts)
+			restored, restoreErr := Restore(t.Context(), target, &buf)
+
+			// Assert
+			if backupErr != nil || restoreErr != nil {
+				t.Fatalf("Backup() error = %v, Restore() error = %v", backupErr, restoreErr)
+			}
+			want := Stats{Users: 2, Accounts: test.wantAccounts, Sessions: 1, RefreshTokens: 1}
+			if *backedUp != want || *restored != want {
+				t.Errorf("stats = %+v backed up, %+v restored; want %+v", *backedUp, *restored, want)
+			}
+
+			pending, err := target.GetUserByID(t.Context(), "user-2")
+			if err != nil || pending.Status != core.UserStatusPending {
+				t.Errorf("pending user = %+v, %v; want it restored as pending", pending, err)
+			}
+			credential, err := target.GetAccountByID(t.Context(), "account-1")
+			if err != nil || credential.Password == nil || *credential.Password != "$argon2id$hash" {
+				t.Errorf("credential account = %+v, %v; want the password hash", credential, err)
+			}
+			if test.wantAccounts == 2 {
+				google, err := target.GetAccountByID(t.Context(), "account-2")
+				if err != nil || google.AccessToken == nil || google.ProfileData["locale"] != "en" {
+					t.Errorf("google account = %+v, %v; want its token and profile", google, err)
+				}
+			}
+			security, err := target.GetUserSecurity(t.Context(), "user-1")
+			if err != nil || security.MaxSessions != 3 {
+				t.Errorf("security = %+v, %v; want MaxSessions 3", security, err)
+			}
+			session, err := target.GetSessionByHash(t.Context(), "session-hash")
+			if err != nil || session.ID != "session-1" || session.Data["csrf"] != "x" {
+				t.Errorf("session = %+v, %v; want session-1 with its data", session, err)
+			}
+			token, err := target.GetRefreshTokenByHash(t.Context(), "refresh-hash")
+			if err != nil || token.ID != "refresh-1" || token.ParentID != nil {
+				t.Errorf("refresh token = %+v, %v; want refresh-1 without its revoked parent", token, err)
+			}
+		})
+	}
+}
+
+// Requirement: Restore rejects input that isn't a supported backup, and
+// stops at users that already exist.
+func TestRestore_Errors(t *testing.T) {
+	var valid bytes.Buffer
+	if _, err := Backup(t.Context(), newSourceStorage(t), &valid); err != nil {
+		t.Fatalf("Backup() error = %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		input   string
+		target  *services.FakeStorageProvider
+		wantErr error
+	}{
+		{name: "not a backup", input: `{"users": []}`, target: services.NewFakeStorageProvider(), wantErr: ErrInvalidBackup},
+		{name: "newer version", input: `{"format": "kuta-backup", "version": 2}`, target: services.NewFakeStorageProvider(), wantErr: ErrUnsupportedVersion},
+		{name: "existing user", input: valid.String(), target: newSourceStorage(t), wantErr: core.ErrUserExists},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// Act
+			_, err := Restore(t.Context(), test.target, strings.NewReader(test.input))
+
+			// Assert
+			if !errors.Is(err, test.wantErr) {
+				t.Errorf("Restore() error = %v, want %v", err, test.wantErr)
+			}
+		})
+	}
+}
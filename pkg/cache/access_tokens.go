@@ -0,0 +1,115 @@
+package cache
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lborres/kuta/core"
+)
+
+// InMemoryAccessTokenStorage implements core.AccessTokenStorage for a single
+// instance
+type InMemoryAccessTokenStorage struct {
+	mu     sync.RWMutex
+	tokens map[string]*core.AccessToken // token hash -> token
+	byID   map[string]string            // token ID -> token hash
+}
+
+var _ core.AccessTokenStorage = (*InMemoryAccessTokenStorage)(nil)
+
+// NewInMemoryAccessTokenStorage creates an access token storage without any
+// tokens
+func NewInMemoryAccessTokenStorage() *InMemoryAccessTokenStorage {
+	return &InMemoryAccessTokenStorage{
+		tokens: make(map[string]*core.AccessToken),
+		byID:   make(map[string]string),
+	}
+}
+
+// CreateAccessToken stores a copy of token
+func (s *InMemoryAccessTokenStorage) CreateAccessToken(ctx context.Context, token *core.AccessToken) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.tokens[token.TokenHash] = copyAccessToken(token)
+	s.byID[token.ID] = token.TokenHash
+	return nil
+}
+
+// GetAccessTokenByHash returns a copy of the token with tokenHash
+func (s *InMemoryAccessTokenStorage) GetAccessTokenByHash(ctx context.Context, tokenHash string) (*core.AccessToken, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	token, ok := s.tokens[tokenHash]
+	if !ok {
+		return nil, core.ErrAccessTokenNotFound
+	}
+	return copyAccessToken(token), nil
+}
+
+// ListUserAccessTokens returns copies of the user's tokens, newest first
+func (s *InMemoryAccessTokenStorage) ListUserAccessTokens(ctx context.Context, userID string) ([]*core.AccessToken, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	tokens := []*core.AccessToken{}
+	for _, token := range s.tokens {
+		if token.UserID == userID {
+			tokens = append(tokens, copyAccessToken(token))
+		}
+	}
+	slices.SortFunc(tokens, func(a, b *core.AccessToken) int {
+		if c := b.CreatedAt.Compare(a.CreatedAt); c != 0 {
+			return c
+		}
+		return strings.Compare(a.ID, b.ID)
+	})
+	return tokens, nil
+}
+
+// DeleteAccessToken deletes the user's token with id
+func (s *InMemoryAccessTokenStorage) DeleteAccessToken(ctx context.Context, userID, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	hash, ok := s.byID[id]
+	if !ok || s.tokens[hash].UserID != userID {
+		return core.ErrAccessTokenNotFound
+	}
+	delete(s.tokens, hash)
+	delete(s.byID, id)
+	return nil
+}
+
+// TouchAccessToken records that the token was used at usedAt. Unknown
+// tokens are ignored.
+func (s *InMemoryAccessTokenStorage) TouchAccessToken(ctx context.Context, id string, usedAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if token, ok := s.tokens[s.byID[id]]; ok {
+		token.LastUsedAt = &usedAt
+	}
+	return nil
+}
+
+func copyAccessToken(token *core.AccessToken) *core.AccessToken {
+	stored := *token
+	stored.Scopes = slices.Clone(token.Scopes)
+	if stored.Scopes == nil {
+		stored.Scopes = []string{}
+	}
+	if token.ExpiresAt != nil {
+		expiresAt := *token.ExpiresAt
+		stored.ExpiresAt = &expiresAt
+	}
+	if token.LastUsedAt != nil {
+		lastUsedAt := *token.LastUsedAt
+		stored.LastUsedAt = &lastUsedAt
+	}
+	return &stored
+}
@@ -0,0 +1,42 @@
+package cache
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/lborres/kuta/core"
+)
+
+// Requirement: Access tokens are found by hash, listed per user newest
+// first, only deleted by their owner, and record when they were last used.
+func TestInMemoryAccessTokenStorage(t *testing.T) {
+	// Arrange
+	storage := NewInMemoryAccessTokenStorage()
+	now := time.Now()
+	_ = storage.CreateAccessToken(t.Context(), &core.AccessToken{ID: "t-1", UserID: "u", Name: "cli", TokenHash: "h-1", Scopes: []string{"read"}, CreatedAt: now})
+	_ = storage.CreateAccessToken(t.Context(), &core.AccessToken{ID: "t-2", UserID: "u", Name: "ci", TokenHash: "h-2", CreatedAt: now.Add(time.Second)})
+	_ = storage.CreateAccessToken(t.Context(), &core.AccessToken{ID: "t-3", UserID: "other", Name: "cli", TokenHash: "h-3", CreatedAt: now})
+
+	// Act
+	touchErr := storage.TouchAccessToken(t.Context(), "t-1", now)
+	found, foundErr := storage.GetAccessTokenByHash(t.Context(), "h-1")
+	foreignErr := storage.DeleteAccessToken(t.Context(), "u", "t-3")
+	deleteErr := storage.DeleteAccessToken(t.Context(), "u", "t-2")
+	_, deletedErr := storage.GetAccessTokenByHash(t.Context(), "h-2")
+	listed, listErr := storage.ListUserAccessTokens(t.Context(), "u")
+
+	// Assert
+	if touchErr != nil || foundErr != nil || found.ID != "t-1" || found.LastUsedAt == nil || !found.LastUsedAt.Equal(now) {
+		t.Errorf("GetAccessTokenByHash() = %+v, %v; want t-1 used now", found, foundErr)
+	}
+	if !errors.Is(foreignErr, core.ErrAccessTokenNotFound) {
+		t.Errorf("DeleteAccessToken() of another user's token error = %v, want ErrAccessTokenNotFound", foreignErr)
+	}
+	if deleteErr != nil || !errors.Is(deletedErr, core.ErrAccessTokenNotFound) {
+		t.Errorf("DeleteAccessToken() = %v, lookup after = %v; want nil, ErrAccessTokenNotFound", deleteErr, deletedErr)
+	}
+	if listErr != nil || len(listed) != 1 || listed[0].ID != "t-1" {
+		t.Errorf("ListUserAccessTokens() = %+v, %v; want only t-1", listed, listErr)
+	}
+}
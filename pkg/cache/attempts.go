@@ -0,0 +1,97 @@
+package cache
+
+import (
+	"sync"
+	"time"
+
+	"github.com/lborres/kuta/core"
+)
+
+const (
+	// maxAttemptsPerKey bounds the timestamps kept per key; counts above it
+	// are reported as the cap, which is far past any lockout threshold
+	maxAttemptsPerKey = 100
+	// attemptSweepInterval is how many recorded failures pass between sweeps
+	// of keys nobody has touched since their window ended
+	attemptSweepInterval = 1024
+)
+
+// InMemoryAttemptStore implements core.AttemptStore for a single instance
+type InMemoryAttemptStore struct {
+	mu       sync.Mutex
+	attempts map[string][]time.Time
+	window   time.Duration // longest window seen, used when sweeping
+	records  int
+}
+
+var _ core.AttemptStore = (*InMemoryAttemptStore)(nil)
+
+// NewInMemoryAttemptStore creates an empty attempt store
+func NewInMemoryAttemptStore() *InMemoryAttemptStore {
+	return &InMemoryAttemptStore{
+		attempts: make(map[string][]time.Time),
+	}
+}
+
+// RecordFailure adds a failure for key and returns the count within window
+func (s *InMemoryAttemptStore) RecordFailure(key string, window time.Duration) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	s.window = max(s.window, window)
+	recent := prune(s.attempts[key], now.Add(-window))
+	if len(recent) >= maxAttemptsPerKey {
+		recent = recent[1:]
+	}
+	recent = append(recent, now)
+	s.attempts[key] = recent
+
+	s.records++
+	if s.records%attemptSweepInterval == 0 {
+		s.sweep(now)
+	}
+
+	return len(recent), nil
+}
+
+// Failures returns the count of failures for key within window
+func (s *InMemoryAttemptStore) Failures(key string, window time.Duration) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	recent := prune(s.attempts[key], time.Now().Add(-window))
+	if len(recent) == 0 {
+		delete(s.attempts, key)
+		return 0, nil
+	}
+	s.attempts[key] = recent
+	return len(recent), nil
+}
+
+// Reset forgets all failures for key
+func (s *InMemoryAttemptStore) Reset(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.attempts, key)
+	return nil
+}
+
+// sweep drops keys whose newest failure is older than the longest window
+func (s *InMemoryAttemptStore) sweep(now time.Time) {
+	cutoff := now.Add(-s.window)
+	for key, times := range s.attempts {
+		if len(times) == 0 || !times[len(times)-1].After(cutoff) {
+			delete(s.attempts, key)
+		}
+	}
+}
+
+// prune drops timestamps at or before cutoff; times is sorted oldest first
+func prune(times []time.Time, cutoff time.Time) []time.Time {
+	i := 0
+	for i < len(times) && !times[i].After(cutoff) {
+		i++
+	}
+	return times[i:]
+}
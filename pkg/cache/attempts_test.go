@@ -0,0 +1,47 @@
+package cache
+
+import (
+	"testing"
+	"time"
+)
+
+// Requirement: Failures are counted per key within the sliding window.
+func TestInMemoryAttemptStore_SlidingWindow(t *testing.T) {
+	// Arrange
+	store := NewInMemoryAttemptStore()
+	window := 50 * time.Millisecond
+
+	// Act
+	first, _ := store.RecordFailure("a", window)
+	second, _ := store.RecordFailure("a", window)
+	other, _ := store.RecordFailure("b", window)
+	time.Sleep(2 * window)
+	afterWindow, _ := store.Failures("a", window)
+
+	// Assert
+	if first != 1 || second != 2 {
+		t.Errorf("expected counts 1, 2; got %d, %d", first, second)
+	}
+	if other != 1 {
+		t.Errorf("expected keys to be independent, got %d", other)
+	}
+	if afterWindow != 0 {
+		t.Errorf("expected failures to expire after the window, got %d", afterWindow)
+	}
+}
+
+// Requirement: Reset forgets a key's failures.
+func TestInMemoryAttemptStore_Reset(t *testing.T) {
+	// Arrange
+	store := NewInMemoryAttemptStore()
+	_, _ = store.RecordFailure("a", time.Minute)
+
+	// Act
+	_ = store.Reset("a")
+	count, _ := store.Failures("a", time.Minute)
+
+	// Assert
+	if count != 0 {
+		t.Errorf("expected 0 failures after reset, got %d", count)
+	}
+}
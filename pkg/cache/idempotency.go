@@ -0,0 +1,75 @@
+package cache
+
+import (
+	"sync"
+	"time"
+
+	"github.com/lborres/kuta/core"
+)
+
+// idempotencySweepInterval is how many reservations pass between sweeps of
+// expired keys
+const idempotencySweepInterval = 256
+
+// idempotencyEntry is a claimed key, or a recorded one once value is set
+type idempotencyEntry struct {
+	value     string
+	expiresAt time.Time
+}
+
+// InMemoryIdempotencyStore implements core.IdempotencyStore for a single instance
+type InMemoryIdempotencyStore struct {
+	mu           sync.Mutex
+	entries      map[string]idempotencyEntry
+	reservations int
+}
+
+var _ core.IdempotencyStore = (*InMemoryIdempotencyStore)(nil)
+
+// NewInMemoryIdempotencyStore creates an empty idempotency store
+func NewInMemoryIdempotencyStore() *InMemoryIdempotencyStore {
+	return &InMemoryIdempotencyStore{
+		entries: make(map[string]idempotencyEntry),
+	}
+}
+
+// Reserve claims key for ttl, or returns what is recorded for it
+func (s *InMemoryIdempotencyStore) Reserve(key string, ttl time.Duration) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	s.reservations++
+	if s.reservations%idempotencySweepInterval == 0 {
+		for k, entry := range s.entries {
+			if !now.Before(entry.expiresAt) {
+				delete(s.entries, k)
+			}
+		}
+	}
+
+	if entry, ok := s.entries[key]; ok && now.Before(entry.expiresAt) {
+		if entry.value == "" {
+			return "", core.ErrIdempotencyInProgress
+		}
+		return entry.value, nil
+	}
+	s.entries[key] = idempotencyEntry{expiresAt: now.Add(ttl)}
+	return "", nil
+}
+
+// Record stores value for key until ttl has passed
+func (s *InMemoryIdempotencyStore) Record(key, value string, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.entries[key] = idempotencyEntry{value: value, expiresAt: time.Now().Add(ttl)}
+	return nil
+}
+
+// Release forgets key
+func (s *InMemoryIdempotencyStore) Release(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.entries, key)
+	return nil
+}
@@ -0,0 +1,65 @@
+package cache
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/lborres/kuta/core"
+)
+
+// Requirement: A key is claimed by its first reservation; later ones see it
+// in progress until a value is recorded, then get the value, and a released
+// or expired key can be claimed again.
+func TestInMemoryIdempotencyStore_Reserve(t *testing.T) {
+	tests := []struct {
+		name      string
+		claimTTL  time.Duration
+		then      func(s *InMemoryIdempotencyStore)
+		wantValue string
+		wantErr   error
+	}{
+		{name: "claimed", claimTTL: time.Minute, wantErr: core.ErrIdempotencyInProgress},
+		{
+			name:      "recorded",
+			claimTTL:  time.Minute,
+			then:      func(s *InMemoryIdempotencyStore) { _ = s.Record("key", "sealed", time.Hour) },
+			wantValue: "sealed",
+		},
+		{
+			name:     "released",
+			claimTTL: time.Minute,
+			then:     func(s *InMemoryIdempotencyStore) { _ = s.Release("key") },
+		},
+		{name: "claim expired", claimTTL: -time.Second},
+		{
+			name:     "record expired",
+			claimTTL: time.Minute,
+			then:     func(s *InMemoryIdempotencyStore) { _ = s.Record("key", "sealed", -time.Second) },
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// Arrange
+			store := NewInMemoryIdempotencyStore()
+			if value, err := store.Reserve("key", test.claimTTL); value != "" || err != nil {
+				t.Fatalf("first Reserve() = %q, %v", value, err)
+			}
+			if test.then != nil {
+				test.then(store)
+			}
+
+			// Act
+			value, err := store.Reserve("key", time.Minute)
+
+			// Assert
+			if !errors.Is(err, test.wantErr) {
+				t.Fatalf("Reserve() error = %v, want %v", err, test.wantErr)
+			}
+			if value != test.wantValue {
+				t.Errorf("Reserve() = %q, want %q", value, test.wantValue)
+			}
+		})
+	}
+}
@@ -0,0 +1,64 @@
+package cache
+
+import (
+	"sync"
+	"time"
+
+	"github.com/lborres/kuta/core"
+)
+
+// oauthSweepInterval is how many saves pass between sweeps of expired
+// states whose callback never came
+const oauthSweepInterval = 256
+
+// InMemoryOAuthStateStore implements core.OAuthStateStore for a single instance
+type InMemoryOAuthStateStore struct {
+	mu     sync.Mutex
+	states map[string]core.OAuthState
+	saves  int
+}
+
+var _ core.OAuthStateStore = (*InMemoryOAuthStateStore)(nil)
+
+// NewInMemoryOAuthStateStore creates an empty OAuth state store
+func NewInMemoryOAuthStateStore() *InMemoryOAuthStateStore {
+	return &InMemoryOAuthStateStore{
+		states: make(map[string]core.OAuthState),
+	}
+}
+
+// SaveOAuthState stores a copy of state under stateHash
+func (s *InMemoryOAuthStateStore) SaveOAuthState(stateHash string, state *core.OAuthState) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.states[stateHash] = *state
+
+	s.saves++
+	if s.saves%oauthSweepInterval == 0 {
+		now := time.Now()
+		for hash, st := range s.states {
+			if now.After(st.ExpiresAt) {
+				delete(s.states, hash)
+			}
+		}
+	}
+	return nil
+}
+
+// TakeOAuthState removes and returns the state stored under stateHash
+func (s *InMemoryOAuthStateStore) TakeOAuthState(stateHash string) (*core.OAuthState, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	state, ok := s.states[stateHash]
+	if !ok {
+		return nil, core.ErrInvalidOAuthState
+	}
+	delete(s.states, stateHash)
+
+	if time.Now().After(state.ExpiresAt) {
+		return nil, core.ErrInvalidOAuthState
+	}
+	return &state, nil
+}
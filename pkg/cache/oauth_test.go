@@ -0,0 +1,80 @@
+package cache
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/lborres/kuta/core"
+)
+
+// Requirement: An OAuth state can be taken once, and not after it expires.
+func TestInMemoryOAuthStateStore_TakeOnce(t *testing.T) {
+	tests := []struct {
+		name    string
+		ttl     time.Duration
+		takes   int
+		wantErr error
+	}{
+		{name: "first take", ttl: time.Minute, takes: 1},
+		{name: "second take", ttl: time.Minute, takes: 2, wantErr: core.ErrInvalidOAuthState},
+		{name: "expired", ttl: -time.Second, takes: 1, wantErr: core.ErrInvalidOAuthState},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// Arrange
+			store := NewInMemoryOAuthStateStore()
+			_ = store.SaveOAuthState("hash", &core.OAuthState{
+				ProviderID:   "apple",
+				CodeVerifier: "verifier",
+				ExpiresAt:    time.Now().Add(test.ttl),
+			})
+
+			// Act
+			var state *core.OAuthState
+			var err error
+			for range test.takes {
+				state, err = store.TakeOAuthState("hash")
+			}
+
+			// Assert
+			if !errors.Is(err, test.wantErr) {
+				t.Fatalf("error = %v, want %v", err, test.wantErr)
+			}
+			if test.wantErr == nil && (state.ProviderID != "apple" || state.CodeVerifier != "verifier") {
+				t.Errorf("state = %+v", state)
+			}
+		})
+	}
+}
+
+// Requirement: Of concurrent callbacks with the same state, only one gets it.
+func TestInMemoryOAuthStateStore_ConcurrentTake(t *testing.T) {
+	// Arrange
+	store := NewInMemoryOAuthStateStore()
+	_ = store.SaveOAuthState("hash", &core.OAuthState{ProviderID: "apple", ExpiresAt: time.Now().Add(time.Minute)})
+
+	// Act
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	taken := 0
+	for range 16 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := store.TakeOAuthState("hash"); err == nil {
+				mu.Lock()
+				taken++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	// Assert
+	if taken != 1 {
+		t.Errorf("successful takes = %d, want 1", taken)
+	}
+}
@@ -0,0 +1,179 @@
+package cache
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lborres/kuta/core"
+)
+
+// InMemoryOrganizationStorage implements core.OrganizationStorage for a
+// single instance
+type InMemoryOrganizationStorage struct {
+	mu      sync.RWMutex
+	members map[string]map[string]*core.OrganizationMember // user ID -> organization ID -> membership
+	pending map[string]*core.PendingOrganizationMember     // invite ID -> pending member
+}
+
+var _ core.OrganizationStorage = (*InMemoryOrganizationStorage)(nil)
+
+// NewInMemoryOrganizationStorage creates an organization storage without
+// any memberships
+func NewInMemoryOrganizationStorage() *InMemoryOrganizationStorage {
+	return &InMemoryOrganizationStorage{
+		members: make(map[string]map[string]*core.OrganizationMember),
+		pending: make(map[string]*core.PendingOrganizationMember),
+	}
+}
+
+// SaveOrganizationMember stores a copy of member, keeping the creation time
+// of an existing membership
+func (s *InMemoryOrganizationStorage) SaveOrganizationMember(ctx context.Context, member *core.OrganizationMember) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.saveMember(member)
+	return nil
+}
+
+// saveMember stores a copy of member and returns it. The caller holds the
+// write lock.
+func (s *InMemoryOrganizationStorage) saveMember(member *core.OrganizationMember) *core.OrganizationMember {
+	memberships := s.members[member.UserID]
+	if memberships == nil {
+		memberships = make(map[string]*core.OrganizationMember)
+		s.members[member.UserID] = memberships
+	}
+
+	stored := copyMember(member)
+	if existing, ok := memberships[member.OrganizationID]; ok {
+		stored.CreatedAt = existing.CreatedAt
+	}
+	memberships[member.OrganizationID] = stored
+	return copyMember(stored)
+}
+
+// RemoveOrganizationMember ends the user's membership
+func (s *InMemoryOrganizationStorage) RemoveOrganizationMember(ctx context.Context, organizationID, userID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	memberships := s.members[userID]
+	delete(memberships, organizationID)
+	if len(memberships) == 0 {
+		delete(s.members, userID)
+	}
+	return nil
+}
+
+// GetOrganizationMember returns a copy of the user's membership
+func (s *InMemoryOrganizationStorage) GetOrganizationMember(ctx context.Context, organizationID, userID string) (*core.OrganizationMember, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	member, ok := s.members[userID][organizationID]
+	if !ok {
+		return nil, core.ErrNotOrganizationMember
+	}
+	return copyMember(member), nil
+}
+
+// ListUserOrganizations returns copies of the user's memberships, oldest
+// first
+func (s *InMemoryOrganizationStorage) ListUserOrganizations(ctx context.Context, userID string) ([]*core.OrganizationMember, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	members := make([]*core.OrganizationMember, 0, len(s.members[userID]))
+	for _, member := range s.members[userID] {
+		members = append(members, copyMember(member))
+	}
+	slices.SortFunc(members, func(a, b *core.OrganizationMember) int {
+		if c := a.CreatedAt.Compare(b.CreatedAt); c != 0 {
+			return c
+		}
+		return strings.Compare(a.OrganizationID, b.OrganizationID)
+	})
+	return members, nil
+}
+
+// SavePendingOrganizationMember stores a copy of the invite
+func (s *InMemoryOrganizationStorage) SavePendingOrganizationMember(ctx context.Context, pending *core.PendingOrganizationMember) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.pending[pending.InviteID] = copyPending(pending)
+	return nil
+}
+
+// ActivatePendingOrganizationMember removes the invite and makes userID a
+// member with its roles, under a single lock
+func (s *InMemoryOrganizationStorage) ActivatePendingOrganizationMember(ctx context.Context, inviteID, userID string) (*core.OrganizationMember, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	pending, ok := s.pending[inviteID]
+	if !ok {
+		return nil, core.ErrVerificationTokenNotFound
+	}
+	delete(s.pending, inviteID)
+
+	return s.saveMember(&core.OrganizationMember{
+		OrganizationID: pending.OrganizationID,
+		UserID:         userID,
+		Roles:          pending.Roles,
+		CreatedAt:      time.Now(),
+	}), nil
+}
+
+// ListPendingOrganizationMembers returns copies of the organization's
+// unexpired invites, oldest first
+func (s *InMemoryOrganizationStorage) ListPendingOrganizationMembers(ctx context.Context, organizationID string) ([]*core.PendingOrganizationMember, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	now := time.Now()
+	pending := []*core.PendingOrganizationMember{}
+	for _, invite := range s.pending {
+		if invite.OrganizationID == organizationID && now.Before(invite.ExpiresAt) {
+			pending = append(pending, copyPending(invite))
+		}
+	}
+	slices.SortFunc(pending, func(a, b *core.PendingOrganizationMember) int {
+		if c := a.CreatedAt.Compare(b.CreatedAt); c != 0 {
+			return c
+		}
+		return strings.Compare(a.InviteID, b.InviteID)
+	})
+	return pending, nil
+}
+
+// DeletePendingOrganizationMember withdraws the invite
+func (s *InMemoryOrganizationStorage) DeletePendingOrganizationMember(ctx context.Context, inviteID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.pending, inviteID)
+	return nil
+}
+
+func copyMember(member *core.OrganizationMember) *core.OrganizationMember {
+	stored := *member
+	stored.Roles = slices.Clone(member.Roles)
+	if stored.Roles == nil {
+		stored.Roles = []string{}
+	}
+	return &stored
+}
+
+func copyPending(pending *core.PendingOrganizationMember) *core.PendingOrganizationMember {
+	stored := *pending
+	stored.Roles = slices.Clone(pending.Roles)
+	if stored.Roles == nil {
+		stored.Roles = []string{}
+	}
+	return &stored
+}
@@ -0,0 +1,73 @@
+package cache
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/lborres/kuta/core"
+)
+
+// Requirement: Memberships are stored per user and organization, saving
+// again replaces the roles but keeps the creation time, and removed or
+// unknown memberships report ErrNotOrganizationMember.
+func TestInMemoryOrganizationStorage(t *testing.T) {
+	// Arrange
+	storage := NewInMemoryOrganizationStorage()
+	start := time.Now()
+	_ = storage.SaveOrganizationMember(t.Context(), &core.OrganizationMember{OrganizationID: "org-2", UserID: "u", Roles: []string{"viewer"}, CreatedAt: start.Add(time.Second)})
+	_ = storage.SaveOrganizationMember(t.Context(), &core.OrganizationMember{OrganizationID: "org-1", UserID: "u", Roles: []string{"viewer"}, CreatedAt: start})
+	_ = storage.SaveOrganizationMember(t.Context(), &core.OrganizationMember{OrganizationID: "org-1", UserID: "u", Roles: []string{"admin"}, CreatedAt: start.Add(time.Hour)})
+	_ = storage.SaveOrganizationMember(t.Context(), &core.OrganizationMember{OrganizationID: "org-3", UserID: "u", CreatedAt: start.Add(2 * time.Second)})
+
+	// Act
+	member, memberErr := storage.GetOrganizationMember(t.Context(), "org-1", "u")
+	_ = storage.RemoveOrganizationMember(t.Context(), "org-3", "u")
+	listed, listErr := storage.ListUserOrganizations(t.Context(), "u")
+	_, unknownErr := storage.GetOrganizationMember(t.Context(), "org-3", "u")
+
+	// Assert
+	if memberErr != nil || len(member.Roles) != 1 || member.Roles[0] != "admin" || !member.CreatedAt.Equal(start) {
+		t.Errorf("GetOrganizationMember() = %+v, %v; want admin since the first save", member, memberErr)
+	}
+	if listErr != nil || len(listed) != 2 || listed[0].OrganizationID != "org-1" || listed[1].OrganizationID != "org-2" {
+		t.Errorf("ListUserOrganizations() = %+v, %v; want org-1, org-2", listed, listErr)
+	}
+	if !errors.Is(unknownErr, core.ErrNotOrganizationMember) {
+		t.Errorf("GetOrganizationMember() after removal error = %v, want ErrNotOrganizationMember", unknownErr)
+	}
+}
+
+// Requirement: Activating an invite makes the user a member with the
+// invite's roles exactly once, and expired or withdrawn invites aren't
+// listed.
+func TestInMemoryOrganizationStorage_PendingMembers(t *testing.T) {
+	// Arrange
+	storage := NewInMemoryOrganizationStorage()
+	now := time.Now()
+	_ = storage.SavePendingOrganizationMember(t.Context(), &core.PendingOrganizationMember{InviteID: "i-1", OrganizationID: "org-1", Email: "a@example.com", Roles: []string{"editor"}, ExpiresAt: now.Add(time.Hour), CreatedAt: now})
+	_ = storage.SavePendingOrganizationMember(t.Context(), &core.PendingOrganizationMember{InviteID: "i-2", OrganizationID: "org-1", Email: "b@example.com", ExpiresAt: now.Add(time.Hour), CreatedAt: now.Add(time.Second)})
+	_ = storage.SavePendingOrganizationMember(t.Context(), &core.PendingOrganizationMember{InviteID: "i-3", OrganizationID: "org-1", Email: "c@example.com", ExpiresAt: now.Add(-time.Second), CreatedAt: now})
+	_ = storage.SavePendingOrganizationMember(t.Context(), &core.PendingOrganizationMember{InviteID: "i-4", OrganizationID: "org-1", Email: "d@example.com", ExpiresAt: now.Add(time.Hour), CreatedAt: now})
+
+	// Act
+	member, activateErr := storage.ActivatePendingOrganizationMember(t.Context(), "i-1", "u")
+	_, againErr := storage.ActivatePendingOrganizationMember(t.Context(), "i-1", "u")
+	_ = storage.DeletePendingOrganizationMember(t.Context(), "i-4")
+	listed, listErr := storage.ListPendingOrganizationMembers(t.Context(), "org-1")
+	stored, storedErr := storage.GetOrganizationMember(t.Context(), "org-1", "u")
+
+	// Assert
+	if activateErr != nil || member.UserID != "u" || len(member.Roles) != 1 || member.Roles[0] != "editor" {
+		t.Errorf("ActivatePendingOrganizationMember() = %+v, %v; want u as editor", member, activateErr)
+	}
+	if !errors.Is(againErr, core.ErrVerificationTokenNotFound) {
+		t.Errorf("second ActivatePendingOrganizationMember() error = %v, want ErrVerificationTokenNotFound", againErr)
+	}
+	if storedErr != nil || stored.Roles[0] != "editor" {
+		t.Errorf("GetOrganizationMember() = %+v, %v; want the activated membership", stored, storedErr)
+	}
+	if listErr != nil || len(listed) != 1 || listed[0].InviteID != "i-2" {
+		t.Errorf("ListPendingOrganizationMembers() = %+v, %v; want only i-2", listed, listErr)
+	}
+}
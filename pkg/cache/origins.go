@@ -0,0 +1,82 @@
+package cache
+
+import (
+	"sync"
+	"time"
+
+	"github.com/lborres/kuta/core"
+)
+
+const (
+	// maxOriginsPerKey bounds the origins kept per key; counts above it are
+	// reported as the cap, which is far past any anomaly threshold
+	maxOriginsPerKey = 100
+	// originSweepInterval is how many recorded origins pass between sweeps
+	// of keys nobody has touched since their window ended
+	originSweepInterval = 1024
+)
+
+// InMemoryOriginStore implements core.OriginStore for a single instance
+type InMemoryOriginStore struct {
+	mu      sync.Mutex
+	origins map[string]map[string]time.Time // key -> origin -> last seen
+	window  time.Duration                   // longest window seen, used when sweeping
+	records int
+}
+
+var _ core.OriginStore = (*InMemoryOriginStore)(nil)
+
+// NewInMemoryOriginStore creates an empty origin store
+func NewInMemoryOriginStore() *InMemoryOriginStore {
+	return &InMemoryOriginStore{
+		origins: make(map[string]map[string]time.Time),
+	}
+}
+
+// RecordOrigin notes origin for key and returns the distinct origins within window
+func (s *InMemoryOriginStore) RecordOrigin(key, origin string, window time.Duration) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	s.window = max(s.window, window)
+	cutoff := now.Add(-window)
+
+	seen := s.origins[key]
+	if seen == nil {
+		seen = make(map[string]time.Time)
+		s.origins[key] = seen
+	}
+	for o, at := range seen {
+		if !at.After(cutoff) {
+			delete(seen, o)
+		}
+	}
+	if _, ok := seen[origin]; ok || len(seen) < maxOriginsPerKey {
+		seen[origin] = now
+	}
+
+	s.records++
+	if s.records%originSweepInterval == 0 {
+		s.sweep(now)
+	}
+
+	return len(seen), nil
+}
+
+// sweep drops keys whose newest origin is older than the longest window
+func (s *InMemoryOriginStore) sweep(now time.Time) {
+	cutoff := now.Add(-s.window)
+	for key, seen := range s.origins {
+		stale := true
+		for _, at := range seen {
+			if at.After(cutoff) {
+				stale = false
+				break
+			}
+		}
+		if stale {
+			delete(s.origins, key)
+		}
+	}
+}
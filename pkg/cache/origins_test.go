@@ -0,0 +1,32 @@
+package cache
+
+import (
+	"testing"
+	"time"
+)
+
+// Requirement: Distinct origins are counted per key within the sliding window.
+func TestInMemoryOriginStore_DistinctOrigins(t *testing.T) {
+	// Arrange
+	store := NewInMemoryOriginStore()
+	window := 50 * time.Millisecond
+
+	// Act
+	first, _ := store.RecordOrigin("u", "10.0.0.1", window)
+	repeat, _ := store.RecordOrigin("u", "10.0.0.1", window)
+	second, _ := store.RecordOrigin("u", "10.0.0.2", window)
+	other, _ := store.RecordOrigin("v", "10.0.0.3", window)
+	time.Sleep(2 * window)
+	afterWindow, _ := store.RecordOrigin("u", "10.0.0.4", window)
+
+	// Assert
+	if first != 1 || repeat != 1 || second != 2 {
+		t.Errorf("expected counts 1, 1, 2; got %d, %d, %d", first, repeat, second)
+	}
+	if other != 1 {
+		t.Errorf("expected keys to be independent, got %d", other)
+	}
+	if afterWindow != 1 {
+		t.Errorf("expected origins to expire after the window, got %d", afterWindow)
+	}
+}
@@ -0,0 +1,66 @@
+package cache
+
+import (
+	"sync"
+	"time"
+
+	"github.com/lborres/kuta/core"
+)
+
+// pendingSweepInterval is how many saves pass between sweeps of expired
+// pending sign-ins nobody continued
+const pendingSweepInterval = 256
+
+// InMemoryPendingSignInStore implements core.PendingSignInStore for a single instance
+type InMemoryPendingSignInStore struct {
+	mu      sync.Mutex
+	pending map[string]*core.PendingSignIn
+	saves   int
+}
+
+var _ core.PendingSignInStore = (*InMemoryPendingSignInStore)(nil)
+
+// NewInMemoryPendingSignInStore creates an empty pending sign-in store
+func NewInMemoryPendingSignInStore() *InMemoryPendingSignInStore {
+	return &InMemoryPendingSignInStore{
+		pending: make(map[string]*core.PendingSignIn),
+	}
+}
+
+// SavePendingSignIn stores a copy of pending under tokenHash
+func (s *InMemoryPendingSignInStore) SavePendingSignIn(tokenHash string, pending *core.PendingSignIn) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored := *pending
+	stored.Challenges = append([]string(nil), pending.Challenges...)
+	s.pending[tokenHash] = &stored
+
+	s.saves++
+	if s.saves%pendingSweepInterval == 0 {
+		now := time.Now()
+		for hash, p := range s.pending {
+			if now.After(p.ExpiresAt) {
+				delete(s.pending, hash)
+			}
+		}
+	}
+	return nil
+}
+
+// TakePendingSignIn removes and returns the pending sign-in stored under tokenHash
+func (s *InMemoryPendingSignInStore) TakePendingSignIn(tokenHash string) (*core.PendingSignIn, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	pending, ok := s.pending[tokenHash]
+	if !ok {
+		return nil, core.ErrChallengeNotFound
+	}
+	delete(s.pending, tokenHash)
+
+	if time.Now().After(pending.ExpiresAt) {
+		return nil, core.ErrChallengeNotFound
+	}
+	return pending, nil
+}
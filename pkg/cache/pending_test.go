@@ -0,0 +1,50 @@
+package cache
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/lborres/kuta/core"
+)
+
+// Requirement: A pending sign-in can be taken once, and not after it expires.
+func TestInMemoryPendingSignInStore_TakeOnce(t *testing.T) {
+	tests := []struct {
+		name    string
+		ttl     time.Duration
+		takes   int
+		wantErr error
+	}{
+		{name: "first take", ttl: time.Minute, takes: 1},
+		{name: "second take", ttl: time.Minute, takes: 2, wantErr: core.ErrChallengeNotFound},
+		{name: "expired", ttl: -time.Second, takes: 1, wantErr: core.ErrChallengeNotFound},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// Arrange
+			store := NewInMemoryPendingSignInStore()
+			_ = store.SavePendingSignIn("hash", &core.PendingSignIn{
+				UserID:     "user-1",
+				Challenges: []string{core.ChallengeTwoFactor},
+				ExpiresAt:  time.Now().Add(test.ttl),
+			})
+
+			// Act
+			var pending *core.PendingSignIn
+			var err error
+			for range test.takes {
+				pending, err = store.TakePendingSignIn("hash")
+			}
+
+			// Assert
+			if !errors.Is(err, test.wantErr) {
+				t.Fatalf("error = %v, want %v", err, test.wantErr)
+			}
+			if test.wantErr == nil && pending.UserID != "user-1" {
+				t.Errorf("UserID = %q, want user-1", pending.UserID)
+			}
+		})
+	}
+}
@@ -0,0 +1,93 @@
+package cache
+
+import (
+	"context"
+	"slices"
+	"sync"
+
+	"github.com/lborres/kuta/core"
+)
+
+// InMemoryRoleStorage implements core.RoleStorage for a single instance
+type InMemoryRoleStorage struct {
+	mu          sync.RWMutex
+	userRoles   map[string]map[string]struct{} // user ID -> roles
+	permissions map[string][]string            // role -> sorted permissions
+}
+
+var _ core.RoleStorage = (*InMemoryRoleStorage)(nil)
+
+// NewInMemoryRoleStorage creates a role storage without any roles
+func NewInMemoryRoleStorage() *InMemoryRoleStorage {
+	return &InMemoryRoleStorage{
+		userRoles:   make(map[string]map[string]struct{}),
+		permissions: make(map[string][]string),
+	}
+}
+
+// AssignRole grants role to the user
+func (s *InMemoryRoleStorage) AssignRole(ctx context.Context, userID, role string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	roles := s.userRoles[userID]
+	if roles == nil {
+		roles = make(map[string]struct{})
+		s.userRoles[userID] = roles
+	}
+	roles[role] = struct{}{}
+	return nil
+}
+
+// RevokeRole takes role away from the user
+func (s *InMemoryRoleStorage) RevokeRole(ctx context.Context, userID, role string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	roles := s.userRoles[userID]
+	delete(roles, role)
+	if len(roles) == 0 {
+		delete(s.userRoles, userID)
+	}
+	return nil
+}
+
+// GetUserRoles returns the user's roles, sorted
+func (s *InMemoryRoleStorage) GetUserRoles(ctx context.Context, userID string) ([]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	roles := make([]string, 0, len(s.userRoles[userID]))
+	for role := range s.userRoles[userID] {
+		roles = append(roles, role)
+	}
+	slices.Sort(roles)
+	return roles, nil
+}
+
+// SetRolePermissions stores a sorted copy of permissions for role
+func (s *InMemoryRoleStorage) SetRolePermissions(ctx context.Context, role string, permissions []string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(permissions) == 0 {
+		delete(s.permissions, role)
+		return nil
+	}
+	stored := slices.Clone(permissions)
+	slices.Sort(stored)
+	s.permissions[role] = slices.Compact(stored)
+	return nil
+}
+
+// GetRolePermissions returns a copy of the permissions of role
+func (s *InMemoryRoleStorage) GetRolePermissions(ctx context.Context, role string) ([]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	permissions := slices.Clone(s.permissions[role])
+	if permissions == nil {
+		permissions = []string{}
+	}
+	return permissions, nil
+}
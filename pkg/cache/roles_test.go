@@ -0,0 +1,54 @@
+package cache
+
+import (
+	"fmt"
+	"testing"
+)
+
+// Requirement: Roles are kept per user and permissions per role, both listed
+// sorted, with repeated assignments and revoking missing roles harmless.
+func TestInMemoryRoleStorage(t *testing.T) {
+	tests := []struct {
+		name            string
+		assign          []string
+		revoke          []string
+		permissions     []string
+		wantRoles       []string
+		wantPermissions []string
+	}{
+		{name: "no roles", wantRoles: []string{}, wantPermissions: []string{}},
+		{name: "sorted roles", assign: []string{"viewer", "admin", "viewer"}, wantRoles: []string{"admin", "viewer"}, wantPermissions: []string{}},
+		{name: "revoked roles", assign: []string{"admin", "viewer"}, revoke: []string{"admin", "owner"}, wantRoles: []string{"viewer"}, wantPermissions: []string{}},
+		{name: "sorted permissions", permissions: []string{"b", "a", "b"}, wantRoles: []string{}, wantPermissions: []string{"a", "b"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// Arrange
+			storage := NewInMemoryRoleStorage()
+			_ = storage.SetRolePermissions(t.Context(), "admin", []string{"stale"})
+
+			// Act
+			for _, role := range test.assign {
+				_ = storage.AssignRole(t.Context(), "u", role)
+			}
+			for _, role := range test.revoke {
+				_ = storage.RevokeRole(t.Context(), "u", role)
+			}
+			_ = storage.SetRolePermissions(t.Context(), "admin", test.permissions)
+			roles, rolesErr := storage.GetUserRoles(t.Context(), "u")
+			permissions, permissionsErr := storage.GetRolePermissions(t.Context(), "admin")
+
+			// Assert
+			if rolesErr != nil || permissionsErr != nil {
+				t.Fatalf("lookup errors = %v, %v", rolesErr, permissionsErr)
+			}
+			if roles == nil || permissions == nil {
+				t.Fatalf("lookups = %#v, %#v; want empty lists rather than nil", roles, permissions)
+			}
+			if fmt.Sprint(roles) != fmt.Sprint(test.wantRoles) || fmt.Sprint(permissions) != fmt.Sprint(test.wantPermissions) {
+				t.Errorf("roles = %v, permissions = %v; want %v, %v", roles, permissions, test.wantRoles, test.wantPermissions)
+			}
+		})
+	}
+}
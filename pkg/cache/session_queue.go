@@ -0,0 +1,80 @@
+package cache
+
+import (
+	"maps"
+	"sort"
+	"sync"
+
+	"github.com/lborres/kuta/core"
+)
+
+// InMemorySessionQueue implements core.SessionQueue in memory. Queued
+// sessions are lost when the process exits, so it suits tests and
+// deployments that can lose the sessions issued since the last persistence
+// run; use sessionqueue.Open for a queue that survives restarts.
+type InMemorySessionQueue struct {
+	mu       sync.Mutex
+	sessions map[string]queuedSession
+	seq      uint64
+}
+
+type queuedSession struct {
+	session *core.Session
+	seq     uint64 // order of the first Put
+}
+
+var _ core.SessionQueue = (*InMemorySessionQueue)(nil)
+
+// NewInMemorySessionQueue creates an empty session queue
+func NewInMemorySessionQueue() *InMemorySessionQueue {
+	return &InMemorySessionQueue{sessions: make(map[string]queuedSession)}
+}
+
+// Put queues a copy of session, keeping the place of a queued session with
+// the same ID
+func (q *InMemorySessionQueue) Put(session *core.Session) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	entry, ok := q.sessions[session.ID]
+	if !ok {
+		q.seq++
+		entry.seq = q.seq
+	}
+	entry.session = copySession(session)
+	q.sessions[session.ID] = entry
+	return nil
+}
+
+// Pending returns copies of the queued sessions, oldest first
+func (q *InMemorySessionQueue) Pending() ([]*core.Session, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	queued := make([]queuedSession, 0, len(q.sessions))
+	for _, entry := range q.sessions {
+		queued = append(queued, entry)
+	}
+	sort.Slice(queued, func(i, j int) bool { return queued[i].seq < queued[j].seq })
+
+	sessions := make([]*core.Session, len(queued))
+	for i, entry := range queued {
+		sessions[i] = copySession(entry.session)
+	}
+	return sessions, nil
+}
+
+func (q *InMemorySessionQueue) Remove(ids ...string) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for _, id := range ids {
+		delete(q.sessions, id)
+	}
+	return nil
+}
+
+func copySession(session *core.Session) *core.Session {
+	copied := *session
+	copied.Data = maps.Clone(session.Data)
+	return &copied
+}
@@ -0,0 +1,30 @@
+package cache
+
+import (
+	"testing"
+
+	"github.com/lborres/kuta/core"
+)
+
+// Requirement: Queued sessions come back oldest first; putting a queued
+// session again replaces it in place, and removed sessions are gone.
+func TestInMemorySessionQueue(t *testing.T) {
+	// Arrange
+	queue := NewInMemorySessionQueue()
+	_ = queue.Put(&core.Session{ID: "session-1", IPAddress: "10.0.0.1"})
+	_ = queue.Put(&core.Session{ID: "session-2"})
+	_ = queue.Put(&core.Session{ID: "session-3"})
+
+	// Act
+	_ = queue.Put(&core.Session{ID: "session-1", IPAddress: "10.0.0.2"})
+	_ = queue.Remove("session-2", "session-4")
+	pending, err := queue.Pending()
+
+	// Assert
+	if err != nil || len(pending) != 2 {
+		t.Fatalf("Pending() = %d sessions, %v; want 2", len(pending), err)
+	}
+	if pending[0].ID != "session-1" || pending[0].IPAddress != "10.0.0.2" || pending[1].ID != "session-3" {
+		t.Errorf("Pending() = %+v, %+v; want the updated session-1, then session-3", pending[0], pending[1])
+	}
+}
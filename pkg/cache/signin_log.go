@@ -0,0 +1,84 @@
+package cache
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/lborres/kuta/core"
+)
+
+// maxSignInRecordsPerUser bounds the attempts kept per user, so a flood of
+// failures against one account can't grow memory without limit. The oldest
+// attempts are dropped first.
+const maxSignInRecordsPerUser = 200
+
+// InMemorySignInLog implements core.SignInLog for a single instance. Attempts
+// that matched no user can't be queried, so they aren't kept.
+type InMemorySignInLog struct {
+	mu     sync.Mutex
+	byUser map[string][]*core.SignInRecord // oldest first
+}
+
+var _ core.SignInLog = (*InMemorySignInLog)(nil)
+
+// NewInMemorySignInLog creates an empty sign-in log
+func NewInMemorySignInLog() *InMemorySignInLog {
+	return &InMemorySignInLog{
+		byUser: make(map[string][]*core.SignInRecord),
+	}
+}
+
+// RecordSignIn stores a copy of record
+func (l *InMemorySignInLog) RecordSignIn(ctx context.Context, record *core.SignInRecord) error {
+	if record.UserID == "" {
+		return nil
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	stored := *record
+	records := append(l.byUser[record.UserID], &stored)
+	if len(records) > maxSignInRecordsPerUser {
+		records = append([]*core.SignInRecord(nil), records[len(records)-maxSignInRecordsPerUser:]...)
+	}
+	l.byUser[record.UserID] = records
+	return nil
+}
+
+// ListSignInRecords returns copies of up to limit of the user's attempts,
+// newest first, skipping the offset newest
+func (l *InMemorySignInLog) ListSignInRecords(ctx context.Context, userID string, limit, offset int) ([]*core.SignInRecord, int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	records := l.byUser[userID]
+	page := make([]*core.SignInRecord, 0, max(min(limit, len(records)-offset), 0))
+	for i := len(records) - 1 - offset; i >= 0 && len(page) < limit; i-- {
+		record := *records[i]
+		page = append(page, &record)
+	}
+	return page, len(records), nil
+}
+
+// PurgeSignInRecords removes attempts made before cutoff
+func (l *InMemorySignInLog) PurgeSignInRecords(ctx context.Context, cutoff time.Time) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	purged := 0
+	for userID, records := range l.byUser {
+		expired := 0
+		for expired < len(records) && records[expired].CreatedAt.Before(cutoff) {
+			expired++
+		}
+		purged += expired
+		if expired == len(records) {
+			delete(l.byUser, userID)
+			continue
+		}
+		l.byUser[userID] = records[expired:]
+	}
+	return purged, nil
+}
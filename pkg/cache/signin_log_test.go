@@ -0,0 +1,85 @@
+package cache
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/lborres/kuta/core"
+)
+
+// Requirement: Attempts are listed per user, newest first, a page at a time
+// with the user's total, and only for attempts that matched a user.
+func TestInMemorySignInLog_ListSignInRecords(t *testing.T) {
+	tests := []struct {
+		name      string
+		userID    string
+		limit     int
+		offset    int
+		wantIDs   []string
+		wantTotal int
+	}{
+		{name: "first page", userID: "u", limit: 3, wantIDs: []string{"4", "3", "2"}, wantTotal: 5},
+		{name: "last page", userID: "u", limit: 3, offset: 3, wantIDs: []string{"1", "0"}, wantTotal: 5},
+		{name: "past the end", userID: "u", limit: 3, offset: 9, wantTotal: 5},
+		{name: "other user", userID: "v", limit: 10, wantIDs: []string{"other"}, wantTotal: 1},
+		{name: "attempts without a user", userID: "", limit: 10},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// Arrange
+			log := NewInMemorySignInLog()
+			start := time.Now()
+			for i := range 5 {
+				_ = log.RecordSignIn(t.Context(), &core.SignInRecord{ID: fmt.Sprint(i), UserID: "u", CreatedAt: start.Add(time.Duration(i) * time.Second)})
+			}
+			_ = log.RecordSignIn(t.Context(), &core.SignInRecord{ID: "other", UserID: "v", CreatedAt: start})
+			_ = log.RecordSignIn(t.Context(), &core.SignInRecord{ID: "unknown", Email: "nobody@example.com", CreatedAt: start})
+
+			// Act
+			records, total, err := log.ListSignInRecords(t.Context(), test.userID, test.limit, test.offset)
+
+			// Assert
+			if err != nil || total != test.wantTotal {
+				t.Fatalf("ListSignInRecords() total = %d, %v; want %d", total, err, test.wantTotal)
+			}
+			var ids []string
+			for _, record := range records {
+				ids = append(ids, record.ID)
+			}
+			if fmt.Sprint(ids) != fmt.Sprint(test.wantIDs) {
+				t.Errorf("ListSignInRecords() = %v, want %v", ids, test.wantIDs)
+			}
+		})
+	}
+}
+
+// Requirement: The log keeps a bounded number of attempts per user and
+// purges attempts made before the cutoff.
+func TestInMemorySignInLog_Retention(t *testing.T) {
+	// Arrange
+	log := NewInMemorySignInLog()
+	old := time.Now().Add(-time.Hour)
+	for i := range maxSignInRecordsPerUser + 10 {
+		_ = log.RecordSignIn(t.Context(), &core.SignInRecord{ID: fmt.Sprint(i), UserID: "u", CreatedAt: old})
+	}
+	_ = log.RecordSignIn(t.Context(), &core.SignInRecord{ID: "fresh", UserID: "v", CreatedAt: time.Now()})
+
+	// Act
+	capped, _, _ := log.ListSignInRecords(t.Context(), "u", 2*maxSignInRecordsPerUser, 0)
+	purged, err := log.PurgeSignInRecords(t.Context(), time.Now().Add(-time.Minute))
+	afterPurge, _, _ := log.ListSignInRecords(t.Context(), "u", 10, 0)
+	fresh, _, _ := log.ListSignInRecords(t.Context(), "v", 10, 0)
+
+	// Assert
+	if len(capped) != maxSignInRecordsPerUser || capped[len(capped)-1].ID != "10" {
+		t.Errorf("kept %d attempts, oldest %s; want %d, oldest 10", len(capped), capped[len(capped)-1].ID, maxSignInRecordsPerUser)
+	}
+	if err != nil || purged != maxSignInRecordsPerUser {
+		t.Errorf("PurgeSignInRecords() = %d, %v; want %d", purged, err, maxSignInRecordsPerUser)
+	}
+	if len(afterPurge) != 0 || len(fresh) != 1 {
+		t.Errorf("after purge: u has %d, v has %d; want 0 and 1", len(afterPurge), len(fresh))
+	}
+}
@@ -0,0 +1,146 @@
+package clientip
+
+import (
+	"errors"
+	"fmt"
+	"net/netip"
+	"strings"
+)
+
+const (
+	HeaderXForwardedFor = "X-Forwarded-For"
+	HeaderXRealIP       = "X-Real-IP"
+)
+
+var (
+	ErrInvalidProxy = errors.New("invalid trusted proxy address")
+)
+
+// Config describes which peers are allowed to report the client IP
+type Config struct {
+	// TrustedProxies lists IPs or CIDRs of proxies whose forwarding headers
+	// are honored, e.g. "10.0.0.0/8" or "192.168.1.10".
+	TrustedProxies []string
+
+	// TrustAllProxies honors forwarding headers from any peer. Only safe when
+	// the app is unreachable except through a proxy that overwrites them.
+	TrustAllProxies bool
+
+	// Header is read before X-Real-IP. Defaults to X-Forwarded-For.
+	Header string
+}
+
+// Resolver determines the originating client IP of a request that may have
+// passed through one or more reverse proxies.
+type Resolver struct {
+	trusted  []netip.Prefix
+	trustAll bool
+	header   string
+}
+
+// NewResolver parses the trusted proxy list. With an empty Config the resolver
+// trusts no one and always returns the peer address.
+func NewResolver(c Config) (*Resolver, error) {
+	r := &Resolver{
+		trustAll: c.TrustAllProxies,
+		header:   c.Header,
+	}
+	if r.header == "" {
+		r.header = HeaderXForwardedFor
+	}
+
+	for _, proxy := range c.TrustedProxies {
+		proxy = strings.TrimSpace(proxy)
+		if strings.Contains(proxy, "/") {
+			prefix, err := netip.ParsePrefix(proxy)
+			if err != nil {
+				return nil, fmt.Errorf("%w %q: %v", ErrInvalidProxy, proxy, err)
+			}
+			r.trusted = append(r.trusted, prefix.Masked())
+			continue
+		}
+		addr, err := netip.ParseAddr(proxy)
+		if err != nil {
+			return nil, fmt.Errorf("%w %q: %v", ErrInvalidProxy, proxy, err)
+		}
+		addr = addr.Unmap()
+		r.trusted = append(r.trusted, netip.PrefixFrom(addr, addr.BitLen()))
+	}
+
+	return r, nil
+}
+
+// ClientIP returns the client IP for a request received from remoteAddr
+// ("ip" or "ip:port"). getHeader looks up request headers by name.
+//
+// Forwarding headers are only consulted when the peer is trusted. Forwarded-for
+// chains are walked right to left, skipping trusted proxies, so a client cannot
+// spoof its address by prepending entries.
+func (r *Resolver) ClientIP(remoteAddr string, getHeader func(name string) string) string {
+	peer, ok := parseAddr(remoteAddr)
+	if !ok {
+		return remoteAddr
+	}
+	if !r.isTrusted(peer) {
+		return peer.String()
+	}
+
+	if value := getHeader(r.header); value != "" {
+		if ip, ok := r.fromChain(value); ok {
+			return ip.String()
+		}
+	}
+
+	if value := getHeader(HeaderXRealIP); value != "" {
+		if ip, ok := parseAddr(strings.TrimSpace(value)); ok {
+			return ip.String()
+		}
+	}
+
+	return peer.String()
+}
+
+// fromChain picks the right-most untrusted address in a comma-separated chain
+func (r *Resolver) fromChain(value string) (netip.Addr, bool) {
+	hops := strings.Split(value, ",")
+
+	var last netip.Addr
+	found := false
+	for i := len(hops) - 1; i >= 0; i-- {
+		ip, ok := parseAddr(strings.TrimSpace(hops[i]))
+		if !ok {
+			// Anything left of garbage can't be trusted
+			break
+		}
+		last, found = ip, true
+		if !r.isTrusted(ip) {
+			return ip, true
+		}
+	}
+
+	// Every hop is trusted; the left-most one is the best we have
+	return last, found
+}
+
+func (r *Resolver) isTrusted(ip netip.Addr) bool {
+	if r.trustAll {
+		return true
+	}
+	for _, prefix := range r.trusted {
+		if prefix.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseAddr accepts "ip", "ip:port" and "[ipv6]:port"
+func parseAddr(s string) (netip.Addr, bool) {
+	if addr, err := netip.ParseAddr(s); err == nil {
+		return addr.Unmap(), true
+	}
+	if addrPort, err := netip.ParseAddrPort(s); err == nil {
+		return addrPort.Addr().Unmap(), true
+	}
+	return netip.Addr{}, false
+}
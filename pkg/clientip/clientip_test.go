@@ -0,0 +1,102 @@
+package clientip
+
+import (
+	"errors"
+	"testing"
+)
+
+func headers(h map[string]string) func(string) string {
+	return func(name string) string { return h[name] }
+}
+
+func TestNewResolverShouldRejectInvalidProxies(t *testing.T) {
+	tests := []string{"not-an-ip", "10.0.0.0/99", ""}
+
+	for _, proxy := range tests {
+		_, err := NewResolver(Config{TrustedProxies: []string{proxy}})
+		if !errors.Is(err, ErrInvalidProxy) {
+			t.Errorf("NewResolver(%q) error = %v, want ErrInvalidProxy", proxy, err)
+		}
+	}
+}
+
+func TestResolverClientIP(t *testing.T) {
+	tests := []struct {
+		name       string
+		config     Config
+		remoteAddr string
+		headers    map[string]string
+		want       string
+	}{
+		{
+			name:       "untrusted peer headers are ignored",
+			config:     Config{},
+			remoteAddr: "198.51.100.1:4321",
+			headers:    map[string]string{HeaderXForwardedFor: "203.0.113.7"},
+			want:       "198.51.100.1",
+		},
+		{
+			name:       "trusted peer uses forwarded client",
+			config:     Config{TrustedProxies: []string{"10.0.0.0/8"}},
+			remoteAddr: "10.0.0.2",
+			headers:    map[string]string{HeaderXForwardedFor: "203.0.113.7"},
+			want:       "203.0.113.7",
+		},
+		{
+			name:       "spoofed left-most entries are skipped",
+			config:     Config{TrustedProxies: []string{"10.0.0.0/8"}},
+			remoteAddr: "10.0.0.2:80",
+			headers:    map[string]string{HeaderXForwardedFor: "1.1.1.1, 203.0.113.7, 10.0.0.5"},
+			want:       "203.0.113.7",
+		},
+		{
+			name:       "trust all returns left-most address",
+			config:     Config{TrustAllProxies: true},
+			remoteAddr: "172.16.0.1",
+			headers:    map[string]string{HeaderXForwardedFor: "203.0.113.7, 172.16.0.9"},
+			want:       "203.0.113.7",
+		},
+		{
+			name:       "falls back to X-Real-IP",
+			config:     Config{TrustedProxies: []string{"10.0.0.2"}},
+			remoteAddr: "10.0.0.2",
+			headers:    map[string]string{HeaderXRealIP: "203.0.113.7"},
+			want:       "203.0.113.7",
+		},
+		{
+			name:       "garbage header falls back to peer",
+			config:     Config{TrustedProxies: []string{"10.0.0.2"}},
+			remoteAddr: "10.0.0.2",
+			headers:    map[string]string{HeaderXForwardedFor: "garbage"},
+			want:       "10.0.0.2",
+		},
+		{
+			name:       "ipv6 peer with port",
+			config:     Config{TrustedProxies: []string{"::1"}},
+			remoteAddr: "[::1]:8080",
+			headers:    map[string]string{HeaderXForwardedFor: "2001:db8::1"},
+			want:       "2001:db8::1",
+		},
+		{
+			name:       "custom header",
+			config:     Config{TrustAllProxies: true, Header: "CF-Connecting-IP"},
+			remoteAddr: "10.0.0.2",
+			headers:    map[string]string{"CF-Connecting-IP": "203.0.113.7"},
+			want:       "203.0.113.7",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r, err := NewResolver(test.config)
+			if err != nil {
+				t.Fatalf("NewResolver() error = %v", err)
+			}
+
+			got := r.ClientIP(test.remoteAddr, headers(test.headers))
+			if got != test.want {
+				t.Errorf("ClientIP() = %q, want %q", got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,187 @@
+// Package codec provides core.SessionCodec implementations for external
+// session caches.
+//
+// Every payload starts with a format byte and a schema version byte, and
+// each codec here decodes every format in the package. Instances can
+// therefore switch codecs (or schema versions) during a rolling upgrade
+// while sharing one cache: entries written by the old format stay readable
+// until they expire.
+package codec
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/lborres/kuta/core"
+	"github.com/shamaton/msgpack/v2"
+)
+
+const (
+	formatJSON    byte = 'j'
+	formatMsgpack byte = 'm'
+
+	// schemaVersion is bumped when wireSession changes incompatibly
+	schemaVersion byte = 1
+
+	headerLen = 2
+)
+
+var (
+	ErrUnknownFormat      = errors.New("codec: unknown session encoding")
+	ErrUnsupportedVersion = errors.New("codec: unsupported session schema version")
+)
+
+// wireSession is the versioned, serialized form of core.Session
+type wireSession struct {
+	ID        string    `json:"id" msgpack:"id"`
+	UserID    string    `json:"userId" msgpack:"userId"`
+	TokenHash string    `json:"tokenHash" msgpack:"tokenHash"`
+	IPAddress string    `json:"ipAddress" msgpack:"ipAddress"`
+	UserAgent string    `json:"userAgent" msgpack:"userAgent"`
+	PublicKey string    `json:"publicKey,omitempty" msgpack:"publicKey,omitempty"`
+	ExpiresAt time.Time `json:"expiresAt" msgpack:"expiresAt"`
+	// RevokedAt was added without a version bump: older entries simply
+	// decode as not revoked
+	RevokedAt *time.Time `json:"revokedAt,omitempty" msgpack:"revokedAt,omitempty"`
+	CreatedAt time.Time  `json:"createdAt" msgpack:"createdAt"`
+	UpdatedAt time.Time  `json:"updatedAt" msgpack:"updatedAt"`
+	// Impersonation was also added without a version bump
+	Impersonation *wireImpersonation `json:"impersonation,omitempty" msgpack:"impersonation,omitempty"`
+	// Data was also added without a version bump
+	Data map[string]string `json:"data,omitempty" msgpack:"data,omitempty"`
+	// ActiveOrganizationID was also added without a version bump
+	ActiveOrganizationID string `json:"activeOrganizationId,omitempty" msgpack:"activeOrganizationId,omitempty"`
+	// Entitlements were also added without a version bump
+	Entitlements *wireEntitlements `json:"entitlements,omitempty" msgpack:"entitlements,omitempty"`
+}
+
+type wireEntitlements struct {
+	Plan     string   `json:"plan,omitempty" msgpack:"plan,omitempty"`
+	Features []string `json:"features,omitempty" msgpack:"features,omitempty"`
+}
+
+type wireImpersonation struct {
+	ActorUserID string    `json:"actorUserId" msgpack:"actorUserId"`
+	StartedAt   time.Time `json:"startedAt" msgpack:"startedAt"`
+}
+
+func toWire(s *core.Session) wireSession {
+	var impersonation *wireImpersonation
+	if s.Impersonation != nil {
+		impersonation = &wireImpersonation{ActorUserID: s.Impersonation.ActorUserID, StartedAt: s.Impersonation.StartedAt}
+	}
+	var entitlements *wireEntitlements
+	if s.Entitlements != nil {
+		entitlements = &wireEntitlements{Plan: s.Entitlements.Plan, Features: s.Entitlements.Features}
+	}
+	return wireSession{
+		ID:        s.ID,
+		UserID:    s.UserID,
+		TokenHash: s.TokenHash,
+		IPAddress: s.IPAddress,
+		UserAgent: s.UserAgent,
+		PublicKey: s.PublicKey,
+		ExpiresAt: s.ExpiresAt,
+		RevokedAt: s.RevokedAt,
+		CreatedAt: s.CreatedAt,
+		UpdatedAt: s.UpdatedAt,
+
+		Impersonation: impersonation,
+		Data:          s.Data,
+
+		ActiveOrganizationID: s.ActiveOrganizationID,
+		Entitlements:         entitlements,
+	}
+}
+
+func (w wireSession) session() *core.Session {
+	var impersonation *core.Impersonation
+	if w.Impersonation != nil {
+		impersonation = &core.Impersonation{ActorUserID: w.Impersonation.ActorUserID, StartedAt: w.Impersonation.StartedAt}
+	}
+	var entitlements *core.Entitlements
+	if w.Entitlements != nil {
+		entitlements = &core.Entitlements{Plan: w.Entitlements.Plan, Features: w.Entitlements.Features}
+	}
+	return &core.Session{
+		ID:        w.ID,
+		UserID:    w.UserID,
+		TokenHash: w.TokenHash,
+		IPAddress: w.IPAddress,
+		UserAgent: w.UserAgent,
+		PublicKey: w.PublicKey,
+		ExpiresAt: w.ExpiresAt,
+		RevokedAt: w.RevokedAt,
+		CreatedAt: w.CreatedAt,
+		UpdatedAt: w.UpdatedAt,
+
+		Impersonation: impersonation,
+		Data:          w.Data,
+
+		ActiveOrganizationID: w.ActiveOrganizationID,
+		Entitlements:         entitlements,
+	}
+}
+
+// JSON encodes sessions as JSON. It is larger than Msgpack but readable
+// with standard tooling.
+type JSON struct{}
+
+var _ core.SessionCodec = JSON{}
+
+func (JSON) Marshal(session *core.Session) ([]byte, error) {
+	body, err := json.Marshal(toWire(session))
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte{formatJSON, schemaVersion}, body...), nil
+}
+
+func (JSON) Unmarshal(data []byte) (*core.Session, error) {
+	return decode(data)
+}
+
+// Msgpack encodes sessions as MessagePack, which is smaller and faster to
+// decode than JSON.
+type Msgpack struct{}
+
+var _ core.SessionCodec = Msgpack{}
+
+func (Msgpack) Marshal(session *core.Session) ([]byte, error) {
+	body, err := msgpack.Marshal(toWire(session))
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte{formatMsgpack, schemaVersion}, body...), nil
+}
+
+func (Msgpack) Unmarshal(data []byte) (*core.Session, error) {
+	return decode(data)
+}
+
+// decode reads a payload in any supported format
+func decode(data []byte) (*core.Session, error) {
+	if len(data) < headerLen {
+		return nil, ErrUnknownFormat
+	}
+	if data[1] != schemaVersion {
+		return nil, ErrUnsupportedVersion
+	}
+
+	var wire wireSession
+	var err error
+	switch data[0] {
+	case formatJSON:
+		err = json.Unmarshal(data[headerLen:], &wire)
+	case formatMsgpack:
+		err = msgpack.Unmarshal(data[headerLen:], &wire)
+	default:
+		return nil, ErrUnknownFormat
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return wire.session(), nil
+}
@@ -0,0 +1,106 @@
+package codec
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/lborres/kuta/core"
+)
+
+func testSession() *core.Session {
+	now := time.Now().UTC().Truncate(time.Millisecond)
+	return &core.Session{
+		ID:        "session-1",
+		UserID:    "user-1",
+		TokenHash: "hash-1",
+		IPAddress: "10.0.0.1",
+		UserAgent: "test-agent",
+		PublicKey: "key",
+		ExpiresAt: now.Add(time.Hour),
+		RevokedAt: &now,
+		CreatedAt: now,
+		UpdatedAt: now,
+
+		Impersonation: &core.Impersonation{ActorUserID: "admin-1", StartedAt: now},
+		Data:          map[string]string{"csrf": "secret"},
+
+		ActiveOrganizationID: "org-1",
+		Entitlements:         &core.Entitlements{Plan: "pro", Features: []string{"export"}},
+	}
+}
+
+// Requirement: Every codec decodes payloads written by every other codec,
+// keeping all session fields including TokenHash.
+func TestCodecs_RoundTrip(t *testing.T) {
+	codecs := map[string]core.SessionCodec{"json": JSON{}, "msgpack": Msgpack{}}
+
+	for writerName, writer := range codecs {
+		for readerName, reader := range codecs {
+			t.Run(writerName+" to "+readerName, func(t *testing.T) {
+				// Arrange
+				session := testSession()
+
+				// Act
+				data, err := writer.Marshal(session)
+				if err != nil {
+					t.Fatalf("Marshal error: %v", err)
+				}
+				got, err := reader.Unmarshal(data)
+
+				// Assert
+				if err != nil {
+					t.Fatalf("Unmarshal error: %v", err)
+				}
+				if got.ID != session.ID || got.UserID != session.UserID || got.TokenHash != session.TokenHash ||
+					got.IPAddress != session.IPAddress || got.UserAgent != session.UserAgent || got.PublicKey != session.PublicKey {
+					t.Errorf("fields differ: got %+v, want %+v", got, session)
+				}
+				if !got.ExpiresAt.Equal(session.ExpiresAt) || !got.CreatedAt.Equal(session.CreatedAt) || !got.UpdatedAt.Equal(session.UpdatedAt) {
+					t.Errorf("timestamps differ: got %+v, want %+v", got, session)
+				}
+				if got.RevokedAt == nil || !got.RevokedAt.Equal(*session.RevokedAt) {
+					t.Errorf("RevokedAt = %v, want %v", got.RevokedAt, session.RevokedAt)
+				}
+				if got.Impersonation == nil || got.Impersonation.ActorUserID != "admin-1" || !got.Impersonation.StartedAt.Equal(session.Impersonation.StartedAt) {
+					t.Errorf("Impersonation = %+v, want %+v", got.Impersonation, session.Impersonation)
+				}
+				if got.Data["csrf"] != "secret" {
+					t.Errorf("Data = %v, want %v", got.Data, session.Data)
+				}
+				if got.ActiveOrganizationID != session.ActiveOrganizationID {
+					t.Errorf("ActiveOrganizationID = %q, want %q", got.ActiveOrganizationID, session.ActiveOrganizationID)
+				}
+				if got.Entitlements == nil || got.Entitlements.Plan != "pro" || !got.Entitlements.HasFeature("export") {
+					t.Errorf("Entitlements = %+v, want %+v", got.Entitlements, session.Entitlements)
+				}
+			})
+		}
+	}
+}
+
+// Requirement: Unknown formats and schema versions are rejected so callers
+// can treat them as cache misses.
+func TestCodecs_RejectUnknownPayloads(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    []byte
+		wantErr error
+	}{
+		{name: "empty", data: nil, wantErr: ErrUnknownFormat},
+		{name: "unknown format", data: []byte{'x', schemaVersion, '{', '}'}, wantErr: ErrUnknownFormat},
+		{name: "future version", data: []byte{formatJSON, schemaVersion + 1, '{', '}'}, wantErr: ErrUnsupportedVersion},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// Act
+			_, err := JSON{}.Unmarshal(test.data)
+
+			// Assert
+			if !errors.Is(err, test.wantErr) {
+				t.Errorf("expected %v, got %v", test.wantErr, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,186 @@
+package crypto
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hkdf"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"strings"
+)
+
+const (
+	envelopePrefix = "kenv1"
+	dataKeyLen     = 32
+	keyIDLen       = 6
+)
+
+var (
+	ErrInvalidEnvelope = errors.New("envelope is malformed or was tampered with")
+	ErrUnknownKey      = errors.New("envelope was sealed with an unknown key")
+)
+
+// Envelope encrypts values with envelope encryption: every value gets its own
+// random data key, and only that data key is encrypted ("wrapped") with a key
+// derived from the secret.
+//
+// Rotating the secret therefore never requires decrypting the data itself.
+// Values sealed under a previous secret stay readable while it is passed to
+// NewEnvelope, get the new key whenever they are rewritten, and can be moved
+// over in bulk with Rewrap, which only re-encrypts the small data key.
+//
+// Sealed values look like "kenv1.<key-id>.<wrapped-data-key>.<ciphertext>".
+type Envelope struct {
+	primary *envelopeKey
+	keys    map[string]*envelopeKey
+}
+
+type envelopeKey struct {
+	id     string
+	sealer *Sealer
+}
+
+// NewEnvelope derives the current key from secret and read-only keys from
+// previous secrets, all for the given purpose.
+func NewEnvelope(secret, purpose string, previous ...string) (*Envelope, error) {
+	e := &Envelope{keys: make(map[string]*envelopeKey)}
+	for i, s := range append([]string{secret}, previous...) {
+		key, err := newEnvelopeKey(s, purpose)
+		if err != nil {
+			return nil, err
+		}
+		if i == 0 {
+			e.primary = key
+		}
+		if _, exists := e.keys[key.id]; !exists {
+			e.keys[key.id] = key
+		}
+	}
+	return e, nil
+}
+
+func newEnvelopeKey(secret, purpose string) (*envelopeKey, error) {
+	sealer, err := NewSealer(secret, purpose+":kek")
+	if err != nil {
+		return nil, err
+	}
+	// The key ID is derived separately so it reveals nothing about the key
+	id, err := hkdf.Key(sha256.New, []byte(secret), nil, "kuta:"+purpose+":kid", keyIDLen)
+	if err != nil {
+		return nil, err
+	}
+	return &envelopeKey{
+		id:     base64.RawURLEncoding.EncodeToString(id),
+		sealer: sealer,
+	}, nil
+}
+
+// IsSealed reports whether value looks like the output of Seal
+func IsSealed(value string) bool {
+	return strings.HasPrefix(value, envelopePrefix+".")
+}
+
+// Seal encrypts plaintext under a fresh data key wrapped by the current key
+func (e *Envelope) Seal(plaintext []byte) (string, error) {
+	dataKey := make([]byte, dataKeyLen)
+	if _, err := rand.Read(dataKey); err != nil {
+		return "", err
+	}
+
+	aead, err := newDataAEAD(dataKey)
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	ciphertext := aead.Seal(nonce, nonce, plaintext, nil)
+
+	wrapped, err := e.primary.sealer.Seal(dataKey)
+	if err != nil {
+		return "", err
+	}
+
+	return strings.Join([]string{
+		envelopePrefix,
+		e.primary.id,
+		wrapped,
+		base64.RawURLEncoding.EncodeToString(ciphertext),
+	}, "."), nil
+}
+
+// Open decrypts a value produced by Seal with the current or a previous key
+func (e *Envelope) Open(sealed string) ([]byte, error) {
+	parts, key, err := e.parse(sealed)
+	if err != nil {
+		return nil, err
+	}
+
+	dataKey, err := key.sealer.Open(parts[2])
+	if err != nil {
+		return nil, ErrInvalidEnvelope
+	}
+	aead, err := newDataAEAD(dataKey)
+	if err != nil {
+		return nil, ErrInvalidEnvelope
+	}
+
+	raw, err := base64.RawURLEncoding.DecodeString(parts[3])
+	if err != nil || len(raw) < aead.NonceSize() {
+		return nil, ErrInvalidEnvelope
+	}
+	nonce, ciphertext := raw[:aead.NonceSize()], raw[aead.NonceSize():]
+	plaintext, err := aead.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return nil, ErrInvalidEnvelope
+	}
+	return plaintext, nil
+}
+
+// Rewrap moves a value sealed under a previous key to the current key by
+// re-encrypting only its data key. It reports whether the value changed.
+func (e *Envelope) Rewrap(sealed string) (string, bool, error) {
+	parts, key, err := e.parse(sealed)
+	if err != nil {
+		return "", false, err
+	}
+	if key == e.primary {
+		return sealed, false, nil
+	}
+
+	dataKey, err := key.sealer.Open(parts[2])
+	if err != nil {
+		return "", false, ErrInvalidEnvelope
+	}
+	wrapped, err := e.primary.sealer.Seal(dataKey)
+	if err != nil {
+		return "", false, err
+	}
+
+	return strings.Join([]string{envelopePrefix, e.primary.id, wrapped, parts[3]}, "."), true, nil
+}
+
+// parse splits sealed and finds the key its data key is wrapped with
+func (e *Envelope) parse(sealed string) ([]string, *envelopeKey, error) {
+	parts := strings.Split(sealed, ".")
+	if len(parts) != 4 || parts[0] != envelopePrefix {
+		return nil, nil, ErrInvalidEnvelope
+	}
+
+	key, ok := e.keys[parts[1]]
+	if !ok {
+		return nil, nil, ErrUnknownKey
+	}
+	return parts, key, nil
+}
+
+func newDataAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,94 @@
+package crypto
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+const (
+	oldSecret = "this-is-the-old-secret-32-bytes!!"
+	newSecret = "this-is-the-new-secret-32-bytes!!"
+)
+
+// Requirement: Values survive a secret rotation while the old secret is
+// listed as previous, and Rewrap moves them to the new key without
+// touching the ciphertext.
+func TestEnvelope_Rotation(t *testing.T) {
+	// Arrange
+	before, err := NewEnvelope(oldSecret, "test")
+	if err != nil {
+		t.Fatalf("NewEnvelope error: %v", err)
+	}
+	sealed, err := before.Seal([]byte("provider-token"))
+	if err != nil {
+		t.Fatalf("Seal error: %v", err)
+	}
+	after, err := NewEnvelope(newSecret, "test", oldSecret)
+	if err != nil {
+		t.Fatalf("NewEnvelope error: %v", err)
+	}
+
+	// Act
+	opened, openErr := after.Open(sealed)
+	rewrapped, changed, rewrapErr := after.Rewrap(sealed)
+
+	// Assert
+	if openErr != nil || string(opened) != "provider-token" {
+		t.Fatalf("Open after rotation = %q, %v", opened, openErr)
+	}
+	if rewrapErr != nil || !changed {
+		t.Fatalf("Rewrap = %v, %v", changed, rewrapErr)
+	}
+	if lastPart(rewrapped) != lastPart(sealed) {
+		t.Error("expected Rewrap to keep the data ciphertext")
+	}
+	newOnly, _ := NewEnvelope(newSecret, "test")
+	if got, err := newOnly.Open(rewrapped); err != nil || string(got) != "provider-token" {
+		t.Errorf("expected rewrapped value to open without the old secret, got %q, %v", got, err)
+	}
+	if _, changed, _ := after.Rewrap(rewrapped); changed {
+		t.Error("expected no change when rewrapping a current value")
+	}
+}
+
+// Requirement: Tampered values and values from unknown keys are rejected.
+func TestEnvelope_OpenRejects(t *testing.T) {
+	envelope, _ := NewEnvelope(newSecret, "test")
+	sealed, _ := envelope.Seal([]byte("provider-token"))
+	foreign, _ := NewEnvelope(oldSecret, "test")
+	foreignSealed, _ := foreign.Seal([]byte("provider-token"))
+
+	tests := []struct {
+		name    string
+		value   string
+		wantErr error
+	}{
+		{name: "not an envelope", value: "plain-token", wantErr: ErrInvalidEnvelope},
+		{name: "tampered ciphertext", value: sealed[:len(sealed)-2] + flip(sealed[len(sealed)-2:]), wantErr: ErrInvalidEnvelope},
+		{name: "unknown key", value: foreignSealed, wantErr: ErrUnknownKey},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// Act
+			_, err := envelope.Open(test.value)
+
+			// Assert
+			if !errors.Is(err, test.wantErr) {
+				t.Errorf("expected %v, got %v", test.wantErr, err)
+			}
+		})
+	}
+}
+
+func lastPart(sealed string) string {
+	return sealed[strings.LastIndexByte(sealed, '.')+1:]
+}
+
+func flip(s string) string {
+	if s[0] == 'A' {
+		return "B" + s[1:]
+	}
+	return "A" + s[1:]
+}
@@ -0,0 +1,128 @@
+package crypto
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/bcrypt"
+	"golang.org/x/crypto/scrypt"
+)
+
+// PasswordVerifier checks passwords against hashes it can't necessarily
+// produce, such as those imported from another auth system
+type PasswordVerifier interface {
+	Verify(password, hash string) (bool, error)
+}
+
+var (
+	_ PasswordVerifier = Bcrypt{}
+	_ PasswordVerifier = Scrypt{}
+	_ PasswordVerifier = (*FirebaseScrypt)(nil)
+)
+
+// Bcrypt verifies bcrypt hashes ($2a$, $2b$ and $2y$), as stored by GoTrue
+// (Supabase) and most frameworks
+type Bcrypt struct{}
+
+func (Bcrypt) Verify(password, hash string) (bool, error) {
+	err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
+	if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// Scrypt verifies scrypt hashes in the PHC format written by
+// EncodeScryptHash, "$scrypt$ln=14,r=16,p=1$<salt>$<key>"
+type Scrypt struct{}
+
+// EncodeScryptHash encodes an scrypt key derived with N = 2^logN
+func EncodeScryptHash(salt, key []byte, logN, r, p int) string {
+	return fmt.Sprintf("$scrypt$ln=%d,r=%d,p=%d$%s$%s", logN, r, p,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key))
+}
+
+func (Scrypt) Verify(password, hash string) (bool, error) {
+	parts := strings.Split(hash, "$")
+	if len(parts) != 5 || parts[1] != "scrypt" {
+		return false, errors.New("invalid scrypt hash format")
+	}
+
+	var logN, r, p int
+	if _, err := fmt.Sscanf(parts[2], "ln=%d,r=%d,p=%d", &logN, &r, &p); err != nil {
+		return false, fmt.Errorf("invalid scrypt parameters: %w", err)
+	}
+	if logN < 1 || logN > 30 {
+		return false, fmt.Errorf("invalid scrypt cost ln=%d", logN)
+	}
+	salt, err := base64.RawStdEncoding.DecodeString(parts[3])
+	if err != nil {
+		return false, fmt.Errorf("invalid salt encoding: %w", err)
+	}
+	key, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return false, fmt.Errorf("invalid key encoding: %w", err)
+	}
+
+	computed, err := scrypt.Key([]byte(password), salt, 1<<logN, r, p, len(key))
+	if err != nil {
+		return false, err
+	}
+	return subtle.ConstantTimeCompare(key, computed) == 1, nil
+}
+
+// FirebaseScrypt verifies hashes exported from Firebase Authentication,
+// encoded by EncodeFirebaseScryptHash. The parameters are the project's
+// password hash parameters, shown in the Firebase console.
+type FirebaseScrypt struct {
+	SignerKey     []byte
+	SaltSeparator []byte
+	Rounds        int
+	MemCost       int
+}
+
+// EncodeFirebaseScryptHash encodes a Firebase password hash and its salt,
+// both as decoded from the export, as "$firebase-scrypt$<salt>$<hash>"
+func EncodeFirebaseScryptHash(salt, hash []byte) string {
+	return fmt.Sprintf("$firebase-scrypt$%s$%s",
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(hash))
+}
+
+// Verify derives an AES key from the password with scrypt and checks that
+// it encrypts the signer key to hash, as Firebase's modified scrypt does
+func (f *FirebaseScrypt) Verify(password, hash string) (bool, error) {
+	parts := strings.Split(hash, "$")
+	if len(parts) != 4 || parts[1] != "firebase-scrypt" {
+		return false, errors.New("invalid firebase-scrypt hash format")
+	}
+	if f.MemCost < 1 || f.MemCost > 30 {
+		return false, fmt.Errorf("invalid firebase-scrypt mem cost %d", f.MemCost)
+	}
+	salt, err := base64.RawStdEncoding.DecodeString(parts[2])
+	if err != nil {
+		return false, fmt.Errorf("invalid salt encoding: %w", err)
+	}
+	expected, err := base64.RawStdEncoding.DecodeString(parts[3])
+	if err != nil {
+		return false, fmt.Errorf("invalid hash encoding: %w", err)
+	}
+
+	key, err := scrypt.Key([]byte(password), append(salt, f.SaltSeparator...), 1<<f.MemCost, f.Rounds, 1, 32)
+	if err != nil {
+		return false, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return false, err
+	}
+	computed := make([]byte, len(f.SignerKey))
+	cipher.NewCTR(block, make([]byte, aes.BlockSize)).XORKeyStream(computed, f.SignerKey)
+	return subtle.ConstantTimeCompare(expected, computed) == 1, nil
+}
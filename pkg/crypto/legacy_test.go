@@ -0,0 +1,59 @@
+package crypto
+
+import (
+	"encoding/base64"
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+	"golang.org/x/crypto/scrypt"
+)
+
+// Requirement: Imported bcrypt, scrypt and Firebase scrypt hashes verify the
+// password they were made from and reject others.
+func TestPasswordVerifiers(t *testing.T) {
+	bcryptHash, err := bcrypt.GenerateFromPassword([]byte("user1password"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatalf("bcrypt error: %v", err)
+	}
+	scryptKey, err := scrypt.Key([]byte("user1password"), []byte("salt"), 1<<10, 8, 1, 32)
+	if err != nil {
+		t.Fatalf("scrypt error: %v", err)
+	}
+
+	// Firebase's published example parameters and hash
+	decode := base64.StdEncoding.DecodeString
+	signerKey, _ := decode("jxspr8Ki0RYycVU8zykbdLGjFQ3McFUH0uiiTvC8pVMXAn210wjLNmdZJzxUECKbm0QsEmYUSDzZvpjeJ9WmXA==")
+	separator, _ := decode("Bw==")
+	firebaseSalt, _ := decode("42xEC+ixf3L2lw==")
+	firebaseHash, _ := decode("lSrfV15cpx95/sZS2W9c9Kp6i/LVgQNDNC/qzrCnh1SAyZvqmZqAjTdn3aoItz+VHjoZilo78198JAdRuid5lQ==")
+	firebase := &FirebaseScrypt{SignerKey: signerKey, SaltSeparator: separator, Rounds: 8, MemCost: 14}
+
+	tests := []struct {
+		name     string
+		verifier PasswordVerifier
+		hash     string
+		wantErr  bool
+	}{
+		{name: "bcrypt", verifier: Bcrypt{}, hash: string(bcryptHash)},
+		{name: "scrypt", verifier: Scrypt{}, hash: EncodeScryptHash([]byte("salt"), scryptKey, 10, 8, 1)},
+		{name: "firebase scrypt", verifier: firebase, hash: EncodeFirebaseScryptHash(firebaseSalt, firebaseHash)},
+		{name: "malformed scrypt", verifier: Scrypt{}, hash: "$scrypt$ln=x$salt$key", wantErr: true},
+		{name: "malformed firebase scrypt", verifier: firebase, hash: "$firebase-scrypt$!!!", wantErr: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// Act
+			ok, err := test.verifier.Verify("user1password", test.hash)
+			wrong, _ := test.verifier.Verify("wrong-password", test.hash)
+
+			// Assert
+			if (err != nil) != test.wantErr {
+				t.Fatalf("Verify() error = %v, want error %v", err, test.wantErr)
+			}
+			if ok == test.wantErr || wrong {
+				t.Errorf("Verify() = %v for the password and %v for a wrong one", ok, wrong)
+			}
+		})
+	}
+}
@@ -0,0 +1,81 @@
+package crypto
+
+import (
+	"errors"
+	"runtime"
+	"time"
+)
+
+var (
+	ErrHashQueueTimeout = errors.New("timed out waiting for a password hashing slot")
+)
+
+// Ensure LimitedPasswordHandler implements PasswordHandler
+var _ PasswordHandler = (*LimitedPasswordHandler)(nil)
+
+// LimitedPasswordHandler caps how many Hash/Verify calls run at once. Each
+// Argon2 call holds its full memory cost (64MB by default) while running, so
+// an unbounded burst of sign-ins can exhaust memory; excess calls queue
+// instead and give up after the queue timeout.
+type LimitedPasswordHandler struct {
+	inner        PasswordHandler
+	slots        chan struct{}
+	queueTimeout time.Duration
+}
+
+// NewLimitedPasswordHandler wraps inner so at most maxConcurrent calls run at
+// once (runtime.NumCPU() when maxConcurrent <= 0). Callers wait up to
+// queueTimeout for a slot, or indefinitely when queueTimeout <= 0.
+func NewLimitedPasswordHandler(inner PasswordHandler, maxConcurrent int, queueTimeout time.Duration) *LimitedPasswordHandler {
+	if maxConcurrent <= 0 {
+		maxConcurrent = runtime.NumCPU()
+	}
+	return &LimitedPasswordHandler{
+		inner:        inner,
+		slots:        make(chan struct{}, maxConcurrent),
+		queueTimeout: queueTimeout,
+	}
+}
+
+func (l *LimitedPasswordHandler) Hash(password string) (string, error) {
+	if err := l.acquire(); err != nil {
+		return "", err
+	}
+	defer l.release()
+	return l.inner.Hash(password)
+}
+
+func (l *LimitedPasswordHandler) Verify(password, hash string) (bool, error) {
+	if err := l.acquire(); err != nil {
+		return false, err
+	}
+	defer l.release()
+	return l.inner.Verify(password, hash)
+}
+
+func (l *LimitedPasswordHandler) acquire() error {
+	// Fast path: a free slot needs no timer
+	select {
+	case l.slots <- struct{}{}:
+		return nil
+	default:
+	}
+
+	if l.queueTimeout <= 0 {
+		l.slots <- struct{}{}
+		return nil
+	}
+
+	timer := time.NewTimer(l.queueTimeout)
+	defer timer.Stop()
+	select {
+	case l.slots <- struct{}{}:
+		return nil
+	case <-timer.C:
+		return ErrHashQueueTimeout
+	}
+}
+
+func (l *LimitedPasswordHandler) release() {
+	<-l.slots
+}
@@ -0,0 +1,105 @@
+package crypto
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// blockingHandler records peak concurrency and blocks until released
+type blockingHandler struct {
+	running atomic.Int32
+	peak    atomic.Int32
+	release chan struct{}
+}
+
+func (b *blockingHandler) enter() {
+	n := b.running.Add(1)
+	for {
+		peak := b.peak.Load()
+		if n <= peak || b.peak.CompareAndSwap(peak, n) {
+			break
+		}
+	}
+	<-b.release
+	b.running.Add(-1)
+}
+
+func (b *blockingHandler) Hash(password string) (string, error) {
+	b.enter()
+	return "hash:" + password, nil
+}
+
+func (b *blockingHandler) Verify(password, hash string) (bool, error) {
+	b.enter()
+	return hash == "hash:"+password, nil
+}
+
+// Requirement: No more than maxConcurrent hashes run at the same time.
+func TestLimitedPasswordHandler_BoundsConcurrency(t *testing.T) {
+	// Arrange
+	inner := &blockingHandler{release: make(chan struct{})}
+	const limit, calls = 2, 6
+	limited := NewLimitedPasswordHandler(inner, limit, 0)
+
+	// Act
+	var wg sync.WaitGroup
+	for i := 0; i < calls; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := limited.Hash("pw"); err != nil {
+				t.Errorf("Hash error: %v", err)
+			}
+		}()
+	}
+	// Only release once every slot is taken, or the peak could stay below it
+	deadline := time.Now().Add(5 * time.Second)
+	for inner.running.Load() < limit {
+		if time.Now().After(deadline) {
+			t.Fatalf("only %d hashes running, want %d", inner.running.Load(), limit)
+		}
+		time.Sleep(time.Millisecond)
+	}
+	for i := 0; i < calls; i++ {
+		inner.release <- struct{}{}
+	}
+	wg.Wait()
+
+	// Assert
+	if peak := inner.peak.Load(); peak != limit {
+		t.Errorf("expected peak concurrency %d, got %d", limit, peak)
+	}
+}
+
+// Requirement: Callers give up with ErrHashQueueTimeout when no slot frees
+// up in time.
+func TestLimitedPasswordHandler_QueueTimeout(t *testing.T) {
+	// Arrange
+	inner := &blockingHandler{release: make(chan struct{})}
+	limited := NewLimitedPasswordHandler(inner, 1, 20*time.Millisecond)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_, _ = limited.Hash("holder")
+	}()
+	for inner.running.Load() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	// Act
+	_, err := limited.Verify("pw", "hash:pw")
+
+	// Assert
+	if !errors.Is(err, ErrHashQueueTimeout) {
+		t.Errorf("expected %v, got %v", ErrHashQueueTimeout, err)
+	}
+	inner.release <- struct{}{}
+	<-done
+	go func() { inner.release <- struct{}{} }()
+	if ok, err := limited.Verify("pw", "hash:pw"); err != nil || !ok {
+		t.Errorf("expected Verify to succeed once the slot is free, got %v, %v", ok, err)
+	}
+}
@@ -0,0 +1,200 @@
+package crypto
+
+import (
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Operations reported to a HashObserver
+const (
+	HashOpHash   = "hash"
+	HashOpVerify = "verify"
+)
+
+// DefaultHashDurationBuckets are histogram upper bounds in seconds, spread
+// around typical Argon2 timings (tens to hundreds of milliseconds)
+var DefaultHashDurationBuckets = []float64{0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5}
+
+// HashObserver is told about every password Hash and Verify call, e.g. to
+// feed a Prometheus HistogramVec. Implementations must be safe for
+// concurrent use.
+type HashObserver interface {
+	// ObserveHash reports how long op took and its error, if any. A
+	// password mismatch is not an error.
+	ObserveHash(op string, duration time.Duration, err error)
+}
+
+// Ensure InstrumentedPasswordHandler implements PasswordHandler
+var _ PasswordHandler = (*InstrumentedPasswordHandler)(nil)
+
+// InstrumentedPasswordHandler reports the duration and outcome of every call
+// to a HashObserver, so operators can tell when hashing parameters need
+// retuning for their hardware.
+type InstrumentedPasswordHandler struct {
+	inner    PasswordHandler
+	observer HashObserver
+}
+
+// NewInstrumentedPasswordHandler wraps inner so observer sees every call
+func NewInstrumentedPasswordHandler(inner PasswordHandler, observer HashObserver) *InstrumentedPasswordHandler {
+	return &InstrumentedPasswordHandler{inner: inner, observer: observer}
+}
+
+func (h *InstrumentedPasswordHandler) Hash(password string) (string, error) {
+	start := time.Now()
+	hash, err := h.inner.Hash(password)
+	h.observer.ObserveHash(HashOpHash, time.Since(start), err)
+	return hash, err
+}
+
+func (h *InstrumentedPasswordHandler) Verify(password, hash string) (bool, error) {
+	start := time.Now()
+	match, err := h.inner.Verify(password, hash)
+	h.observer.ObserveHash(HashOpVerify, time.Since(start), err)
+	return match, err
+}
+
+// Ensure HashMetrics implements HashObserver
+var _ HashObserver = (*HashMetrics)(nil)
+
+// HashMetrics is a HashObserver that keeps a duration histogram and a
+// failure counter per operation, and writes them in the Prometheus text
+// exposition format for apps without a metrics library.
+type HashMetrics struct {
+	buckets []float64
+
+	mu  sync.Mutex
+	ops map[string]*hashOpMetrics
+}
+
+type hashOpMetrics struct {
+	buckets  []uint64 // observations per bucket, not cumulative
+	count    uint64
+	sum      time.Duration
+	failures uint64
+}
+
+// HashOpStats is a snapshot of the metrics of one operation
+type HashOpStats struct {
+	Count    uint64
+	Failures uint64
+	Sum      time.Duration
+	// Buckets holds the cumulative count of observations at or below
+	// each upper bound, in seconds
+	Buckets []HistogramBucket
+}
+
+// HistogramBucket is one cumulative histogram bucket
+type HistogramBucket struct {
+	UpperBound float64
+	Count      uint64
+}
+
+// NewHashMetrics creates empty metrics with the given bucket upper bounds in
+// seconds (DefaultHashDurationBuckets when empty)
+func NewHashMetrics(buckets []float64) *HashMetrics {
+	if len(buckets) == 0 {
+		buckets = DefaultHashDurationBuckets
+	}
+	buckets = slices.Clone(buckets)
+	slices.Sort(buckets)
+	return &HashMetrics{
+		buckets: buckets,
+		ops:     make(map[string]*hashOpMetrics),
+	}
+}
+
+func (m *HashMetrics) ObserveHash(op string, duration time.Duration, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	metrics := m.ops[op]
+	if metrics == nil {
+		metrics = &hashOpMetrics{buckets: make([]uint64, len(m.buckets))}
+		m.ops[op] = metrics
+	}
+
+	seconds := duration.Seconds()
+	if i, _ := slices.BinarySearch(m.buckets, seconds); i < len(m.buckets) {
+		metrics.buckets[i]++
+	}
+	metrics.count++
+	metrics.sum += duration
+	if err != nil {
+		metrics.failures++
+	}
+}
+
+// Stats returns a snapshot of the metrics recorded for op
+func (m *HashMetrics) Stats(op string) HashOpStats {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stats := HashOpStats{Buckets: make([]HistogramBucket, len(m.buckets))}
+	metrics := m.ops[op]
+	var cumulative uint64
+	for i, bound := range m.buckets {
+		if metrics != nil {
+			cumulative += metrics.buckets[i]
+		}
+		stats.Buckets[i] = HistogramBucket{UpperBound: bound, Count: cumulative}
+	}
+	if metrics != nil {
+		stats.Count = metrics.count
+		stats.Failures = metrics.failures
+		stats.Sum = metrics.sum
+	}
+	return stats
+}
+
+// WritePrometheus writes the metrics as kuta_password_hash_duration_seconds
+// (histogram) and kuta_password_hash_failures_total (counter), labelled by
+// op, in the Prometheus text exposition format.
+func (m *HashMetrics) WritePrometheus(w io.Writer) error {
+	m.mu.Lock()
+	ops := make([]string, 0, len(m.ops))
+	for op := range m.ops {
+		ops = append(ops, op)
+	}
+	m.mu.Unlock()
+	slices.Sort(ops)
+
+	stats := make([]HashOpStats, len(ops))
+	for i, op := range ops {
+		stats[i] = m.Stats(op)
+	}
+
+	var err error
+	printf := func(format string, args ...any) {
+		if err == nil {
+			_, err = fmt.Fprintf(w, format, args...)
+		}
+	}
+
+	printf("# HELP kuta_password_hash_duration_seconds Duration of password hash and verify calls.\n")
+	printf("# TYPE kuta_password_hash_duration_seconds histogram\n")
+	for i, op := range ops {
+		for _, bucket := range stats[i].Buckets {
+			printf("kuta_password_hash_duration_seconds_bucket{op=%q,le=%q} %d\n", op, formatFloat(bucket.UpperBound), bucket.Count)
+		}
+		printf("kuta_password_hash_duration_seconds_bucket{op=%q,le=\"+Inf\"} %d\n", op, stats[i].Count)
+		printf("kuta_password_hash_duration_seconds_sum{op=%q} %s\n", op, formatFloat(stats[i].Sum.Seconds()))
+		printf("kuta_password_hash_duration_seconds_count{op=%q} %d\n", op, stats[i].Count)
+	}
+
+	printf("# HELP kuta_password_hash_failures_total Password hash and verify calls that returned an error.\n")
+	printf("# TYPE kuta_password_hash_failures_total counter\n")
+	for i, op := range ops {
+		printf("kuta_password_hash_failures_total{op=%q} %d\n", op, stats[i].Failures)
+	}
+
+	return err
+}
+
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
@@ -0,0 +1,105 @@
+package crypto
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+// stubHandler returns err from every call after sleeping for delay
+type stubHandler struct {
+	delay time.Duration
+	err   error
+}
+
+func (s stubHandler) Hash(password string) (string, error) {
+	time.Sleep(s.delay)
+	return "hash", s.err
+}
+
+func (s stubHandler) Verify(password, hash string) (bool, error) {
+	time.Sleep(s.delay)
+	return false, s.err
+}
+
+// Requirement: Instrumented handlers record every call's duration in the
+// histogram and count the calls that failed.
+func TestInstrumentedPasswordHandler_RecordsMetrics(t *testing.T) {
+	tests := []struct {
+		name         string
+		inner        stubHandler
+		call         func(h PasswordHandler)
+		op           string
+		wantFailures uint64
+		minSeconds   float64 // buckets below this must stay empty
+	}{
+		{
+			name:       "successful hash",
+			inner:      stubHandler{delay: 30 * time.Millisecond},
+			call:       func(h PasswordHandler) { _, _ = h.Hash("pw") },
+			op:         HashOpHash,
+			minSeconds: 0.03,
+		},
+		{
+			name:         "failed verify",
+			inner:        stubHandler{err: errors.New("boom")},
+			call:         func(h PasswordHandler) { _, _ = h.Verify("pw", "hash") },
+			op:           HashOpVerify,
+			wantFailures: 1,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// Arrange
+			metrics := NewHashMetrics(nil)
+			handler := NewInstrumentedPasswordHandler(test.inner, metrics)
+
+			// Act
+			test.call(handler)
+			stats := metrics.Stats(test.op)
+
+			// Assert
+			if stats.Count != 1 || stats.Failures != test.wantFailures {
+				t.Errorf("count = %d, failures = %d; want 1, %d", stats.Count, stats.Failures, test.wantFailures)
+			}
+			for _, bucket := range stats.Buckets {
+				if bucket.UpperBound < test.minSeconds && bucket.Count != 0 {
+					t.Errorf("bucket le=%g count = %d, want 0", bucket.UpperBound, bucket.Count)
+				}
+			}
+		})
+	}
+}
+
+// Requirement: Metrics are written in the Prometheus text exposition format.
+func TestHashMetrics_WritePrometheus(t *testing.T) {
+	// Arrange
+	metrics := NewHashMetrics([]float64{0.1, 1})
+	metrics.ObserveHash(HashOpHash, 50*time.Millisecond, nil)
+	metrics.ObserveHash(HashOpHash, 2*time.Second, errors.New("boom"))
+
+	// Act
+	var out strings.Builder
+	err := metrics.WritePrometheus(&out)
+
+	// Assert
+	if err != nil {
+		t.Fatalf("WritePrometheus error: %v", err)
+	}
+	for _, want := range []string{
+		"# TYPE kuta_password_hash_duration_seconds histogram\n",
+		`kuta_password_hash_duration_seconds_bucket{op="hash",le="0.1"} 1` + "\n",
+		`kuta_password_hash_duration_seconds_bucket{op="hash",le="1"} 1` + "\n",
+		`kuta_password_hash_duration_seconds_bucket{op="hash",le="+Inf"} 2` + "\n",
+		`kuta_password_hash_duration_seconds_sum{op="hash"} 2.05` + "\n",
+		`kuta_password_hash_duration_seconds_count{op="hash"} 2` + "\n",
+		"# TYPE kuta_password_hash_failures_total counter\n",
+		`kuta_password_hash_failures_total{op="hash"} 1` + "\n",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("output missing %q:\n%s", want, out.String())
+		}
+	}
+}
@@ -0,0 +1,44 @@
+package crypto
+
+// Ensure MultiPasswordHandler implements PasswordHandler
+var _ PasswordHandler = (*MultiPasswordHandler)(nil)
+
+// MultiPasswordHandler hashes new passwords with its primary handler and
+// verifies hashes of other algorithms with the verifier registered for
+// them, so hashes imported from another system keep working. Combine it
+// with a PasswordUpgradePolicy migrating those algorithms to replace them
+// as users sign in.
+type MultiPasswordHandler struct {
+	primary   PasswordHandler
+	verifiers map[string]PasswordVerifier
+}
+
+// NewMultiPasswordHandler returns a handler hashing with primary. Bcrypt
+// and Scrypt hashes are verified out of the box; verifiers adds or replaces
+// verifiers by algorithm id (see HashAlgorithm), e.g. "firebase-scrypt".
+func NewMultiPasswordHandler(primary PasswordHandler, verifiers map[string]PasswordVerifier) *MultiPasswordHandler {
+	m := &MultiPasswordHandler{
+		primary: primary,
+		verifiers: map[string]PasswordVerifier{
+			"bcrypt": Bcrypt{},
+			"scrypt": Scrypt{},
+		},
+	}
+	for algorithm, verifier := range verifiers {
+		m.verifiers[algorithm] = verifier
+	}
+	return m
+}
+
+func (m *MultiPasswordHandler) Hash(password string) (string, error) {
+	return m.primary.Hash(password)
+}
+
+// Verify checks password with the verifier registered for the hash's
+// algorithm, falling back to the primary handler
+func (m *MultiPasswordHandler) Verify(password, hash string) (bool, error) {
+	if verifier, ok := m.verifiers[HashAlgorithm(hash)]; ok {
+		return verifier.Verify(password, hash)
+	}
+	return m.primary.Verify(password, hash)
+}
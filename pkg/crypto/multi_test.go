@@ -0,0 +1,57 @@
+package crypto
+
+import (
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// fixedVerifier accepts every password
+type fixedVerifier struct{}
+
+func (fixedVerifier) Verify(password, hash string) (bool, error) {
+	return true, nil
+}
+
+// Requirement: New hashes come from the primary handler, which also
+// verifies them; other algorithms go to their registered verifier.
+func TestMultiPasswordHandler(t *testing.T) {
+	primary := &Argon2{Memory: 1024, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32}
+	handler := NewMultiPasswordHandler(primary, map[string]PasswordVerifier{"custom": fixedVerifier{}})
+	argon2Hash, err := handler.Hash("user1password")
+	if err != nil {
+		t.Fatalf("Hash() error = %v", err)
+	}
+	bcryptHash, err := bcrypt.GenerateFromPassword([]byte("user1password"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatalf("bcrypt error: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		password string
+		hash     string
+		want     bool
+	}{
+		{name: "primary hash", password: "user1password", hash: argon2Hash, want: true},
+		{name: "primary hash, wrong password", password: "wrong", hash: argon2Hash, want: false},
+		{name: "built-in bcrypt", password: "user1password", hash: string(bcryptHash), want: true},
+		{name: "registered verifier", password: "anything", hash: "$custom$whatever", want: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// Act
+			got, err := handler.Verify(test.password, test.hash)
+
+			// Assert
+			if err != nil || got != test.want {
+				t.Errorf("Verify() = %v, %v; want %v", got, err, test.want)
+			}
+		})
+	}
+
+	if HashAlgorithm(argon2Hash) != algorithmArgon2id {
+		t.Errorf("Hash() = %s, want an argon2id hash", argon2Hash)
+	}
+}
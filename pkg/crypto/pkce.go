@@ -0,0 +1,19 @@
+package crypto
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+)
+
+// GenerateCodeVerifier creates a PKCE code verifier (RFC 7636 section 4.1):
+// 32 random bytes encoded as 43 URL-safe characters
+func GenerateCodeVerifier() (string, error) {
+	return generateToken(DefaultTokenLength)
+}
+
+// CodeChallengeS256 derives the S256 code challenge sent with an
+// authorization request from its verifier
+func CodeChallengeS256(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
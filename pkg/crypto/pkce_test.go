@@ -0,0 +1,45 @@
+package crypto
+
+import "testing"
+
+// Requirement: Code verifiers are 43 characters, the RFC 7636 minimum, and
+// never repeat; challenges match the RFC's S256 example.
+func TestPKCE(t *testing.T) {
+	tests := []struct {
+		name          string
+		verifier      string
+		wantChallenge string
+	}{
+		{
+			name:          "RFC 7636 appendix B",
+			verifier:      "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk",
+			wantChallenge: "E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// Act
+			challenge := CodeChallengeS256(test.verifier)
+
+			// Assert
+			if challenge != test.wantChallenge {
+				t.Errorf("CodeChallengeS256() = %q, want %q", challenge, test.wantChallenge)
+			}
+		})
+	}
+
+	t.Run("generated verifiers", func(t *testing.T) {
+		// Act
+		first, err1 := GenerateCodeVerifier()
+		second, err2 := GenerateCodeVerifier()
+
+		// Assert
+		if err1 != nil || err2 != nil {
+			t.Fatalf("GenerateCodeVerifier() errors = %v, %v", err1, err2)
+		}
+		if len(first) != 43 || first == second {
+			t.Errorf("GenerateCodeVerifier() = %q, %q", first, second)
+		}
+	})
+}
@@ -0,0 +1,98 @@
+package crypto
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+)
+
+var (
+	ErrUnsupportedKey = errors.New("unsupported public key, expected Ed25519 or ECDSA P-256")
+	ErrInvalidProof   = errors.New("invalid proof signature")
+	ErrStaleProof     = errors.New("proof timestamp outside the allowed window")
+)
+
+// ParseProofKey decodes a base64url (unpadded) PKIX public key used for
+// proof-of-possession. Only Ed25519 and ECDSA P-256 keys are accepted.
+func ParseProofKey(encoded string) (any, error) {
+	der, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("invalid key encoding: %w", err)
+	}
+
+	key, err := x509.ParsePKIXPublicKey(der)
+	if err != nil {
+		return nil, fmt.Errorf("invalid public key: %w", err)
+	}
+
+	switch k := key.(type) {
+	case ed25519.PublicKey:
+		return k, nil
+	case *ecdsa.PublicKey:
+		if k.Curve != elliptic.P256() {
+			return nil, ErrUnsupportedKey
+		}
+		return k, nil
+	default:
+		return nil, ErrUnsupportedKey
+	}
+}
+
+// ProofMessage builds the canonical string a client signs for a request:
+// "METHOD\nPATH\nUNIX_TIMESTAMP"
+func ProofMessage(method, path string, timestamp int64) []byte {
+	return []byte(fmt.Sprintf("%s\n%s\n%d", method, path, timestamp))
+}
+
+// VerifyProof checks that signature (base64url) over the request was made by
+// the private half of encodedKey, and that timestamp is within maxSkew of now.
+//
+// ECDSA signatures may be ASN.1 DER (Go, OpenSSL) or raw r||s (WebCrypto).
+//
+// VerifyProof is stateless: the same proof verifies again until its timestamp
+// is more than maxSkew old.
+func VerifyProof(encodedKey, method, path string, timestamp int64, signature string, maxSkew time.Duration) error {
+	issued := time.Unix(timestamp, 0)
+	if d := time.Since(issued); d > maxSkew || d < -maxSkew {
+		return ErrStaleProof
+	}
+
+	key, err := ParseProofKey(encodedKey)
+	if err != nil {
+		return err
+	}
+
+	sig, err := base64.RawURLEncoding.DecodeString(signature)
+	if err != nil {
+		return ErrInvalidProof
+	}
+
+	msg := ProofMessage(method, path, timestamp)
+
+	switch k := key.(type) {
+	case ed25519.PublicKey:
+		if !ed25519.Verify(k, msg, sig) {
+			return ErrInvalidProof
+		}
+	case *ecdsa.PublicKey:
+		digest := sha256.Sum256(msg)
+		if len(sig) == 64 {
+			r := new(big.Int).SetBytes(sig[:32])
+			s := new(big.Int).SetBytes(sig[32:])
+			if !ecdsa.Verify(k, digest[:], r, s) {
+				return ErrInvalidProof
+			}
+		} else if !ecdsa.VerifyASN1(k, digest[:], sig) {
+			return ErrInvalidProof
+		}
+	}
+
+	return nil
+}
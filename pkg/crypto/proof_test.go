@@ -0,0 +1,99 @@
+package crypto
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"testing"
+	"time"
+)
+
+func encodeKey(t *testing.T, pub any) string {
+	t.Helper()
+	der, err := x509.MarshalPKIXPublicKey(pub)
+	if err != nil {
+		t.Fatalf("MarshalPKIXPublicKey() error = %v", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(der)
+}
+
+func TestParseProofKeyShouldAcceptSupportedKeys(t *testing.T) {
+	edPub, _, _ := ed25519.GenerateKey(rand.Reader)
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	ec384, _ := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+
+	tests := []struct {
+		name    string
+		encoded string
+		wantErr bool
+	}{
+		{name: "ed25519", encoded: encodeKey(t, edPub), wantErr: false},
+		{name: "ecdsa p-256", encoded: encodeKey(t, &ecKey.PublicKey), wantErr: false},
+		{name: "ecdsa p-384 rejected", encoded: encodeKey(t, &ec384.PublicKey), wantErr: true},
+		{name: "rsa rejected", encoded: encodeKey(t, &rsaKey.PublicKey), wantErr: true},
+		{name: "garbage rejected", encoded: "not-a-key", wantErr: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := ParseProofKey(test.encoded)
+			if (err != nil) != test.wantErr {
+				t.Errorf("ParseProofKey() error = %v, wantErr %v", err, test.wantErr)
+			}
+		})
+	}
+}
+
+func TestVerifyProof(t *testing.T) {
+	edPub, edPriv, _ := ed25519.GenerateKey(rand.Reader)
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	now := time.Now().Unix()
+
+	signEd := func(method, path string, ts int64) string {
+		return base64.RawURLEncoding.EncodeToString(ed25519.Sign(edPriv, ProofMessage(method, path, ts)))
+	}
+	signECRaw := func(method, path string, ts int64) string {
+		digest := sha256.Sum256(ProofMessage(method, path, ts))
+		r, s, _ := ecdsa.Sign(rand.Reader, ecKey, digest[:])
+		raw := make([]byte, 64)
+		r.FillBytes(raw[:32])
+		s.FillBytes(raw[32:])
+		return base64.RawURLEncoding.EncodeToString(raw)
+	}
+	signECDER := func(method, path string, ts int64) string {
+		digest := sha256.Sum256(ProofMessage(method, path, ts))
+		sig, _ := ecdsa.SignASN1(rand.Reader, ecKey, digest[:])
+		return base64.RawURLEncoding.EncodeToString(sig)
+	}
+
+	tests := []struct {
+		name      string
+		key       string
+		timestamp int64
+		signature string
+		wantErr   error
+	}{
+		{name: "valid ed25519 proof", key: encodeKey(t, edPub), timestamp: now, signature: signEd("GET", "/data", now), wantErr: nil},
+		{name: "valid ecdsa raw proof", key: encodeKey(t, &ecKey.PublicKey), timestamp: now, signature: signECRaw("GET", "/data", now), wantErr: nil},
+		{name: "valid ecdsa der proof", key: encodeKey(t, &ecKey.PublicKey), timestamp: now, signature: signECDER("GET", "/data", now), wantErr: nil},
+		{name: "signature for another path", key: encodeKey(t, edPub), timestamp: now, signature: signEd("GET", "/other", now), wantErr: ErrInvalidProof},
+		{name: "stale timestamp", key: encodeKey(t, edPub), timestamp: now - 600, signature: signEd("GET", "/data", now-600), wantErr: ErrStaleProof},
+		{name: "undecodable signature", key: encodeKey(t, edPub), timestamp: now, signature: "%%%", wantErr: ErrInvalidProof},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := VerifyProof(test.key, "GET", "/data", test.timestamp, test.signature, time.Minute)
+			if !errors.Is(err, test.wantErr) {
+				t.Errorf("VerifyProof() error = %v, want %v", err, test.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,70 @@
+package crypto
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hkdf"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+)
+
+var (
+	ErrInvalidSealed = errors.New("sealed value is malformed or was tampered with")
+)
+
+// Sealer encrypts and authenticates small payloads (AES-256-GCM) so they can
+// be handed to clients, e.g. as stateless session cookies.
+type Sealer struct {
+	aead cipher.AEAD
+}
+
+// NewSealer derives an encryption key from secret for the given purpose.
+// Different purposes yield independent keys from the same secret.
+func NewSealer(secret, purpose string) (*Sealer, error) {
+	key, err := hkdf.Key(sha256.New, []byte(secret), nil, "kuta:"+purpose, 32)
+	if err != nil {
+		return nil, err
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Sealer{aead: aead}, nil
+}
+
+// Seal encrypts plaintext and returns a URL-safe string
+func (s *Sealer) Seal(plaintext []byte) (string, error) {
+	nonce := make([]byte, s.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+
+	sealed := s.aead.Seal(nonce, nonce, plaintext, nil)
+	return base64.RawURLEncoding.EncodeToString(sealed), nil
+}
+
+// Open decrypts a value produced by Seal. Only the canonical encoding is
+// accepted, so a sealed value has exactly one spelling.
+func (s *Sealer) Open(sealed string) ([]byte, error) {
+	raw, err := base64.RawURLEncoding.Strict().DecodeString(sealed)
+	if err != nil || len(raw) < s.aead.NonceSize() {
+		return nil, ErrInvalidSealed
+	}
+
+	nonce, ciphertext := raw[:s.aead.NonceSize()], raw[s.aead.NonceSize():]
+	plaintext, err := s.aead.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return nil, ErrInvalidSealed
+	}
+
+	return plaintext, nil
+}
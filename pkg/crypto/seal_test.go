@@ -0,0 +1,58 @@
+package crypto
+
+import (
+	"encoding/base64"
+	"errors"
+	"testing"
+)
+
+func TestSealerShouldRoundTrip(t *testing.T) {
+	sealer, err := NewSealer("secretshouldbeatleast32charslong", "test")
+	if err != nil {
+		t.Fatalf("NewSealer() error = %v", err)
+	}
+
+	sealed, err := sealer.Seal([]byte("payload"))
+	if err != nil {
+		t.Fatalf("Seal() error = %v", err)
+	}
+
+	opened, err := sealer.Open(sealed)
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	if string(opened) != "payload" {
+		t.Errorf("Open() = %q, want %q", opened, "payload")
+	}
+}
+
+func TestSealerShouldRejectTamperedValues(t *testing.T) {
+	sealer, _ := NewSealer("secretshouldbeatleast32charslong", "test")
+	otherPurpose, _ := NewSealer("secretshouldbeatleast32charslong", "other")
+	sealed, _ := sealer.Seal([]byte("payload"))
+
+	// Flip a ciphertext byte rather than a base64 character, whose low bits
+	// may only be padding
+	raw, _ := base64.RawURLEncoding.DecodeString(sealed)
+	raw[len(raw)-1] ^= 0x01
+	tampered := base64.RawURLEncoding.EncodeToString(raw)
+
+	tests := []struct {
+		name   string
+		sealer *Sealer
+		value  string
+	}{
+		{name: "tampered ciphertext", sealer: sealer, value: tampered},
+		{name: "different purpose key", sealer: otherPurpose, value: sealed},
+		{name: "not base64", sealer: sealer, value: "%%%"},
+		{name: "too short", sealer: sealer, value: "AAAA"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if _, err := test.sealer.Open(test.value); !errors.Is(err, ErrInvalidSealed) {
+				t.Errorf("Open() error = %v, want ErrInvalidSealed", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,69 @@
+package crypto
+
+import (
+	"slices"
+	"strings"
+	"time"
+)
+
+const algorithmArgon2id = "argon2id"
+
+// PasswordUpgradePolicy drives rehash-on-login: after a successful sign-in,
+// a stored hash that uses an algorithm being migrated away from, or Argon2
+// parameters other than Target, is replaced with a fresh one. Rehashes are
+// rate limited so a parameter change can roll out over days instead of
+// costing one extra hash per sign-in on deploy day.
+type PasswordUpgradePolicy struct {
+	// Target is the Argon2 configuration hashes are upgraded to. Argon2id
+	// hashes made with different parameters are rehashed with it. When nil,
+	// only MigrateAlgorithms are upgraded, using the configured
+	// PasswordHandler.
+	Target *Argon2
+
+	// MigrateAlgorithms lists algorithms to move away from, by the id at the
+	// start of their hash, e.g. "argon2i" or "bcrypt" (see HashAlgorithm)
+	MigrateAlgorithms []string
+
+	// MaxRehashes caps how many hashes are upgraded per RehashWindow.
+	// Zero means no limit.
+	MaxRehashes int
+	// RehashWindow is the rate limit window. Defaults to 1 minute.
+	RehashWindow time.Duration
+}
+
+// NeedsUpgrade reports whether hash should be replaced under the policy
+func (p PasswordUpgradePolicy) NeedsUpgrade(hash string) bool {
+	algorithm := HashAlgorithm(hash)
+	if slices.Contains(p.MigrateAlgorithms, algorithm) {
+		return true
+	}
+	if p.Target == nil || algorithm != algorithmArgon2id {
+		return false
+	}
+
+	params, _, _, err := decodeArgon2Hash(hash)
+	if err != nil {
+		return false
+	}
+	return params.Memory != p.Target.Memory ||
+		params.Iterations != p.Target.Iterations ||
+		params.Parallelism != p.Target.Parallelism ||
+		params.KeyLength != p.Target.KeyLength
+}
+
+// HashAlgorithm returns the algorithm id of a PHC-style hash such as
+// "$argon2id$v=19$..." ("argon2id"). The bcrypt variants $2a$, $2b$ and $2y$
+// all map to "bcrypt". Returns "" when hash has no id.
+func HashAlgorithm(hash string) string {
+	rest, ok := strings.CutPrefix(hash, "$")
+	if !ok {
+		return ""
+	}
+	id, _, _ := strings.Cut(rest, "$")
+	switch id {
+	case "2a", "2b", "2y":
+		return "bcrypt"
+	default:
+		return id
+	}
+}
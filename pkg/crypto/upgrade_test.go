@@ -0,0 +1,43 @@
+package crypto
+
+import "testing"
+
+// Requirement: The upgrade policy flags hashes made with migrated algorithms
+// or Argon2 parameters other than the target.
+func TestPasswordUpgradePolicy_NeedsUpgrade(t *testing.T) {
+	target := &Argon2{Memory: 1024, Iterations: 2, Parallelism: 1, SaltLength: 16, KeyLength: 32}
+	current, err := target.Hash("pw")
+	if err != nil {
+		t.Fatalf("Hash error: %v", err)
+	}
+	weaker, err := (&Argon2{Memory: 1024, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32}).Hash("pw")
+	if err != nil {
+		t.Fatalf("Hash error: %v", err)
+	}
+	bcrypt := "$2b$10$N9qo8uLOickgx2ZMRZoMyeIjZAgcfl7p92ldGxad68LJZdL17lhWy"
+
+	tests := []struct {
+		name   string
+		policy PasswordUpgradePolicy
+		hash   string
+		want   bool
+	}{
+		{name: "matches target", policy: PasswordUpgradePolicy{Target: target}, hash: current, want: false},
+		{name: "other parameters", policy: PasswordUpgradePolicy{Target: target}, hash: weaker, want: true},
+		{name: "no target keeps parameters", policy: PasswordUpgradePolicy{}, hash: weaker, want: false},
+		{name: "migrated algorithm", policy: PasswordUpgradePolicy{MigrateAlgorithms: []string{"bcrypt"}}, hash: bcrypt, want: true},
+		{name: "unlisted algorithm", policy: PasswordUpgradePolicy{Target: target}, hash: bcrypt, want: false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// Act
+			got := test.policy.NeedsUpgrade(test.hash)
+
+			// Assert
+			if got != test.want {
+				t.Errorf("NeedsUpgrade() = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
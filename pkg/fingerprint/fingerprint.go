@@ -0,0 +1,124 @@
+// Package fingerprint derives a stable identifier for the client behind a
+// request. Risk scoring, trusted devices and session binding all use it, so
+// a client is recognised the same way everywhere.
+package fingerprint
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/netip"
+	"strings"
+)
+
+// version is mixed into every fingerprint; bump it when the definition
+// changes so old and new fingerprints never collide
+const version = "v1"
+
+const (
+	defaultIPv4PrefixBits = 24
+	defaultIPv6PrefixBits = 64
+)
+
+// HintHeaders are the User-Agent Client Hint headers included in a
+// fingerprint, in the order they are hashed. These low-entropy hints are
+// sent by supporting browsers without being requested.
+var HintHeaders = []string{"Sec-CH-UA", "Sec-CH-UA-Mobile", "Sec-CH-UA-Platform"}
+
+// Input is the request data a fingerprint is computed from
+type Input struct {
+	IPAddress string
+	UserAgent string
+	// ClientHints holds User-Agent Client Hint header values by header
+	// name. Only HintHeaders are used; missing hints are fine.
+	ClientHints map[string]string
+}
+
+// Options tunes how coarsely the IP address is matched. Zero values use the
+// defaults.
+type Options struct {
+	// IPv4PrefixBits is the IPv4 network size kept. Defaults to 24, so
+	// clients reassigned within their ISP's subnet keep their fingerprint.
+	IPv4PrefixBits int
+	// IPv6PrefixBits is the IPv6 network size kept. Defaults to 64, the
+	// usual allocation to one subscriber.
+	IPv6PrefixBits int
+}
+
+// FromHeaders builds an Input from the client IP and a header getter, such
+// as fiber's Ctx.Get
+func FromHeaders(ipAddress string, header func(name string) string) Input {
+	in := Input{
+		IPAddress: ipAddress,
+		UserAgent: header("User-Agent"),
+	}
+	for _, name := range HintHeaders {
+		if value := header(name); value != "" {
+			if in.ClientHints == nil {
+				in.ClientHints = make(map[string]string, len(HintHeaders))
+			}
+			in.ClientHints[name] = value
+		}
+	}
+	return in
+}
+
+// Compute returns the hex SHA-256 fingerprint of in. Inputs that only differ
+// in the host part of the IP address, surrounding whitespace or the case of
+// client hint names produce the same fingerprint.
+func Compute(in Input, opts ...Options) string {
+	var o Options
+	if len(opts) > 0 {
+		o = opts[0]
+	}
+
+	hints := make(map[string]string, len(in.ClientHints))
+	for name, value := range in.ClientHints {
+		hints[strings.ToLower(name)] = strings.TrimSpace(value)
+	}
+
+	var b strings.Builder
+	b.WriteString(version)
+	b.WriteByte('\n')
+	b.WriteString(IPPrefix(in.IPAddress, o))
+	b.WriteByte('\n')
+	b.WriteString(strings.TrimSpace(in.UserAgent))
+	for _, name := range HintHeaders {
+		b.WriteByte('\n')
+		b.WriteString(hints[strings.ToLower(name)])
+	}
+
+	sum := sha256.Sum256([]byte(b.String()))
+	return hex.EncodeToString(sum[:])
+}
+
+// IPPrefix returns the network of ipAddress that fingerprints keep, e.g.
+// "203.0.113.0/24". Returns "" for an unparseable address.
+func IPPrefix(ipAddress string, opts ...Options) string {
+	var o Options
+	if len(opts) > 0 {
+		o = opts[0]
+	}
+
+	addr, err := netip.ParseAddr(strings.TrimSpace(ipAddress))
+	if err != nil {
+		return ""
+	}
+	addr = addr.Unmap().WithZone("")
+
+	bits := o.IPv6PrefixBits
+	if bits <= 0 {
+		bits = defaultIPv6PrefixBits
+	}
+	if addr.Is4() {
+		bits = o.IPv4PrefixBits
+		if bits <= 0 {
+			bits = defaultIPv4PrefixBits
+		}
+	}
+
+	prefix, err := addr.Prefix(min(bits, addr.BitLen()))
+	if err != nil {
+		return ""
+	}
+	return prefix.String()
+}
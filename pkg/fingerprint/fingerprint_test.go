@@ -0,0 +1,93 @@
+package fingerprint
+
+import "testing"
+
+// Requirement: IP addresses are reduced to their network prefix.
+func TestIPPrefix(t *testing.T) {
+	tests := []struct {
+		name string
+		ip   string
+		opts Options
+		want string
+	}{
+		{name: "ipv4", ip: "203.0.113.42", want: "203.0.113.0/24"},
+		{name: "ipv4 custom bits", ip: "203.0.113.42", opts: Options{IPv4PrefixBits: 16}, want: "203.0.0.0/16"},
+		{name: "ipv4-mapped ipv6", ip: "::ffff:203.0.113.42", want: "203.0.113.0/24"},
+		{name: "ipv6", ip: "2001:db8:1:2:3:4:5:6", want: "2001:db8:1:2::/64"},
+		{name: "ipv6 with zone", ip: "fe80::1%eth0", want: "fe80::/64"},
+		{name: "invalid", ip: "not-an-ip", want: ""},
+		{name: "empty", ip: "", want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Act
+			got := IPPrefix(tt.ip, tt.opts)
+
+			// Assert
+			if got != tt.want {
+				t.Errorf("expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+// Requirement: Fingerprints are stable across host changes within the
+// prefix and across formatting, but change with the client's software.
+func TestCompute(t *testing.T) {
+	base := Input{
+		IPAddress:   "203.0.113.42",
+		UserAgent:   "Mozilla/5.0",
+		ClientHints: map[string]string{"Sec-CH-UA-Platform": `"macOS"`},
+	}
+
+	tests := []struct {
+		name string
+		in   Input
+		same bool
+	}{
+		{name: "identical", in: base, same: true},
+		{name: "same subnet", in: Input{IPAddress: "203.0.113.7", UserAgent: base.UserAgent, ClientHints: base.ClientHints}, same: true},
+		{name: "hint name case and whitespace", in: Input{IPAddress: base.IPAddress, UserAgent: " Mozilla/5.0 ", ClientHints: map[string]string{"sec-ch-ua-platform": ` "macOS" `}}, same: true},
+		{name: "unknown hints ignored", in: Input{IPAddress: base.IPAddress, UserAgent: base.UserAgent, ClientHints: map[string]string{"Sec-CH-UA-Platform": `"macOS"`, "X-Other": "1"}}, same: true},
+		{name: "other subnet", in: Input{IPAddress: "198.51.100.42", UserAgent: base.UserAgent, ClientHints: base.ClientHints}},
+		{name: "other user agent", in: Input{IPAddress: base.IPAddress, UserAgent: "curl/8.0", ClientHints: base.ClientHints}},
+		{name: "other platform", in: Input{IPAddress: base.IPAddress, UserAgent: base.UserAgent, ClientHints: map[string]string{"Sec-CH-UA-Platform": `"Windows"`}}},
+		{name: "no hints", in: Input{IPAddress: base.IPAddress, UserAgent: base.UserAgent}},
+	}
+
+	want := Compute(base)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Act
+			got := Compute(tt.in)
+
+			// Assert
+			if (got == want) != tt.same {
+				t.Errorf("expected same=%v, got %q vs %q", tt.same, got, want)
+			}
+		})
+	}
+}
+
+// Requirement: FromHeaders picks up the user agent and client hints only.
+func TestFromHeaders(t *testing.T) {
+	// Arrange
+	headers := map[string]string{
+		"User-Agent":         "Mozilla/5.0",
+		"Sec-CH-UA-Mobile":   "?0",
+		"Sec-CH-UA-Platform": `"Linux"`,
+		"Accept":             "*/*",
+	}
+
+	// Act
+	in := FromHeaders("203.0.113.42", func(name string) string { return headers[name] })
+
+	// Assert
+	if in.IPAddress != "203.0.113.42" || in.UserAgent != "Mozilla/5.0" {
+		t.Errorf("unexpected input %+v", in)
+	}
+	if len(in.ClientHints) != 2 || in.ClientHints["Sec-CH-UA-Mobile"] != "?0" {
+		t.Errorf("expected the two client hints sent, got %v", in.ClientHints)
+	}
+}
@@ -0,0 +1,120 @@
+package importer
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/lborres/kuta/core"
+	"github.com/lborres/kuta/pkg/crypto"
+)
+
+// Better Auth's scrypt parameters: N = 2^14, r = 16, p = 1
+const (
+	betterAuthScryptLogN = 14
+	betterAuthScryptR    = 16
+	betterAuthScryptP    = 1
+)
+
+// betterAuthExport is a dump of Better Auth's user and account tables,
+// with its default column names
+type betterAuthExport struct {
+	Users []struct {
+		ID            string    `json:"id"`
+		Name          string    `json:"name"`
+		Email         string    `json:"email"`
+		EmailVerified bool      `json:"emailVerified"`
+		Image         *string   `json:"image"`
+		CreatedAt     time.Time `json:"createdAt"`
+		UpdatedAt     time.Time `json:"updatedAt"`
+	} `json:"users"`
+	Accounts []struct {
+		ID                   string     `json:"id"`
+		UserID               string     `json:"userId"`
+		ProviderID           string     `json:"providerId"`
+		AccountID            string     `json:"accountId"`
+		Password             *string    `json:"password"`
+		AccessToken          *string    `json:"accessToken"`
+		RefreshToken         *string    `json:"refreshToken"`
+		AccessTokenExpiresAt *time.Time `json:"accessTokenExpiresAt"`
+		CreatedAt            time.Time  `json:"createdAt"`
+		UpdatedAt            time.Time  `json:"updatedAt"`
+	} `json:"accounts"`
+}
+
+// ReadBetterAuth reads a Better Auth export: a JSON object whose "users"
+// and "accounts" arrays hold the rows of its user and account tables.
+//
+// Better Auth normalizes passwords to NFKC before hashing, so users whose
+// password changes under NFKC have to reset it.
+func ReadBetterAuth(r io.Reader) ([]Record, error) {
+	var export betterAuthExport
+	if err := json.NewDecoder(r).Decode(&export); err != nil {
+		return nil, fmt.Errorf("importer: invalid Better Auth export: %w", err)
+	}
+
+	records := make([]Record, len(export.Users))
+	byID := make(map[string]*Record, len(export.Users))
+	for i, u := range export.Users {
+		records[i].User = &core.User{
+			ID:            u.ID,
+			Email:         u.Email,
+			EmailVerified: u.EmailVerified,
+			Name:          u.Name,
+			Image:         u.Image,
+			CreatedAt:     u.CreatedAt,
+			UpdatedAt:     u.UpdatedAt,
+		}
+		byID[u.ID] = &records[i]
+	}
+
+	for _, a := range export.Accounts {
+		record, ok := byID[a.UserID]
+		if !ok {
+			return nil, fmt.Errorf("importer: account %s belongs to unknown user %s", a.ID, a.UserID)
+		}
+
+		account := &core.Account{
+			ID:           a.ID,
+			ProviderID:   a.ProviderID,
+			AccountID:    a.AccountID,
+			AccessToken:  a.AccessToken,
+			RefreshToken: a.RefreshToken,
+			ExpiresAt:    a.AccessTokenExpiresAt,
+			CreatedAt:    a.CreatedAt,
+			UpdatedAt:    a.UpdatedAt,
+		}
+		if a.ProviderID == core.CredentialProviderID {
+			if a.Password == nil {
+				continue
+			}
+			hash, err := betterAuthHash(*a.Password)
+			if err != nil {
+				return nil, fmt.Errorf("importer: account %s: %w", a.ID, err)
+			}
+			// Better Auth keys credential accounts by user ID, kuta by email
+			account.AccountID = record.User.Email
+			account.Password = &hash
+		}
+		record.Accounts = append(record.Accounts, account)
+	}
+	return records, nil
+}
+
+// betterAuthHash re-encodes a Better Auth password hash, "<salt>:<key>" in
+// hex, as a PHC scrypt hash. The salt is used as the hex string itself.
+func betterAuthHash(password string) (string, error) {
+	salt, encodedKey, ok := strings.Cut(password, ":")
+	if !ok {
+		return "", errors.New("invalid Better Auth password hash")
+	}
+	key, err := hex.DecodeString(encodedKey)
+	if err != nil {
+		return "", fmt.Errorf("invalid Better Auth password hash: %w", err)
+	}
+	return crypto.EncodeScryptHash([]byte(salt), key, betterAuthScryptLogN, betterAuthScryptR, betterAuthScryptP), nil
+}
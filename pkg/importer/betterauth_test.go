@@ -0,0 +1,65 @@
+package importer
+
+import (
+	"encoding/hex"
+	"strings"
+	"testing"
+
+	"github.com/lborres/kuta/core"
+	"github.com/lborres/kuta/pkg/crypto"
+	"golang.org/x/crypto/scrypt"
+)
+
+// Requirement: Better Auth users keep their IDs and accounts, and their
+// scrypt passwords verify with the multi-algorithm handler. Accounts of
+// unknown users and malformed hashes are rejected.
+func TestReadBetterAuth(t *testing.T) {
+	salt := "0123456789abcdef0123456789abcdef"
+	key, err := scrypt.Key([]byte("user1password"), []byte(salt), 1<<14, 16, 1, 64)
+	if err != nil {
+		t.Fatalf("scrypt error: %v", err)
+	}
+	password := salt + ":" + hex.EncodeToString(key)
+
+	tests := []struct {
+		name    string
+		export  string
+		wantErr bool
+	}{
+		{name: "valid export", export: `{
+			"users": [{"id": "u1", "name": "Ada", "email": "ada@example.com", "emailVerified": true, "createdAt": "2024-01-02T03:04:05Z"}],
+			"accounts": [
+				{"id": "a1", "userId": "u1", "providerId": "credential", "accountId": "u1", "password": "` + password + `"},
+				{"id": "a2", "userId": "u1", "providerId": "github", "accountId": "42", "accessToken": "gho_x"}
+			]}`},
+		{name: "unknown user", export: `{"users": [], "accounts": [{"id": "a1", "userId": "u1", "providerId": "github"}]}`, wantErr: true},
+		{name: "malformed hash", export: `{"users": [{"id": "u1", "email": "ada@example.com"}],
+			"accounts": [{"id": "a1", "userId": "u1", "providerId": "credential", "password": "nosalt"}]}`, wantErr: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// Act
+			records, err := ReadBetterAuth(strings.NewReader(test.export))
+
+			// Assert
+			if (err != nil) != test.wantErr {
+				t.Fatalf("ReadBetterAuth() error = %v, want error %v", err, test.wantErr)
+			}
+			if test.wantErr {
+				return
+			}
+			if len(records) != 1 || records[0].User.ID != "u1" || !records[0].User.EmailVerified || len(records[0].Accounts) != 2 {
+				t.Fatalf("records = %+v, want u1 with two accounts", records)
+			}
+			credential := records[0].Accounts[0]
+			if credential.ProviderID != core.CredentialProviderID || credential.AccountID != "ada@example.com" {
+				t.Errorf("credential account = %+v, want it keyed by email", credential)
+			}
+			handler := crypto.NewMultiPasswordHandler(crypto.NewArgon2(), nil)
+			if ok, err := handler.Verify("user1password", *credential.Password); !ok || err != nil {
+				t.Errorf("Verify() imported hash %s = %v, %v; want true", *credential.Password, ok, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,86 @@
+package importer
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lborres/kuta/core"
+)
+
+// ReadCSV reads users from CSV with a header row. The email column is
+// required; id, name, email_verified (a boolean), password_hash and
+// created_at (RFC 3339) are optional, and other columns are ignored.
+// Password hashes are used as they are, typically bcrypt.
+func ReadCSV(r io.Reader) ([]Record, error) {
+	reader := csv.NewReader(r)
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("importer: invalid CSV header: %w", err)
+	}
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	if _, ok := columns["email"]; !ok {
+		return nil, errors.New("importer: CSV has no email column")
+	}
+
+	var records []Record
+	for line := 2; ; line++ {
+		row, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return records, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("importer: %w", err)
+		}
+		record, err := csvRecord(row, columns)
+		if err != nil {
+			return nil, fmt.Errorf("importer: line %d: %w", line, err)
+		}
+		records = append(records, record)
+	}
+}
+
+func csvRecord(row []string, columns map[string]int) (Record, error) {
+	field := func(name string) string {
+		if i, ok := columns[name]; ok && i < len(row) {
+			return strings.TrimSpace(row[i])
+		}
+		return ""
+	}
+
+	user := &core.User{
+		ID:    field("id"),
+		Email: field("email"),
+		Name:  field("name"),
+	}
+	if user.Email == "" {
+		return Record{}, core.ErrEmailRequired
+	}
+	if value := field("email_verified"); value != "" {
+		verified, err := strconv.ParseBool(value)
+		if err != nil {
+			return Record{}, fmt.Errorf("invalid email_verified: %w", err)
+		}
+		user.EmailVerified = verified
+	}
+	if value := field("created_at"); value != "" {
+		createdAt, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return Record{}, fmt.Errorf("invalid created_at: %w", err)
+		}
+		user.CreatedAt = createdAt
+	}
+
+	record := Record{User: user}
+	if hash := field("password_hash"); hash != "" {
+		record.Accounts = append(record.Accounts, credentialAccount(user, hash))
+	}
+	return record, nil
+}
@@ -0,0 +1,58 @@
+package importer
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/lborres/kuta/core"
+)
+
+// Requirement: CSV rows become users, with a credential account when they
+// carry a password hash; the email column is required.
+func TestReadCSV(t *testing.T) {
+	tests := []struct {
+		name         string
+		csv          string
+		wantRecords  int
+		wantAccounts int
+		wantErr      string
+	}{
+		{
+			name:         "bcrypt hashes",
+			csv:          "Email,Name,email_verified,password_hash,plan\nada@example.com,Ada,true,$2b$10$hash,pro\nbob@example.com,Bob,,,free\n",
+			wantRecords:  2,
+			wantAccounts: 1,
+		},
+		{name: "no email column", csv: "name\nAda\n", wantErr: "no email column"},
+		{name: "empty email", csv: "email,name\n,Ada\n", wantErr: core.ErrEmailRequired.Error()},
+		{name: "invalid boolean", csv: "email,email_verified\nada@example.com,maybe\n", wantErr: "invalid email_verified"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// Act
+			records, err := ReadCSV(strings.NewReader(test.csv))
+
+			// Assert
+			if test.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+					t.Fatalf("ReadCSV() error = %v, want %v", err, test.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ReadCSV() error = %v", err)
+			}
+			accounts := 0
+			for _, record := range records {
+				accounts += len(record.Accounts)
+			}
+			if len(records) != test.wantRecords || accounts != test.wantAccounts {
+				t.Fatalf("ReadCSV() = %d records with %d accounts, want %d with %d", len(records), accounts, test.wantRecords, test.wantAccounts)
+			}
+			if !records[0].User.EmailVerified || records[0].User.Name != "Ada" || *records[0].Accounts[0].Password != "$2b$10$hash" {
+				t.Errorf("first record = %+v, want Ada with her hash", records[0].User)
+			}
+		})
+	}
+}
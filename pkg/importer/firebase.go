@@ -0,0 +1,115 @@
+package importer
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lborres/kuta/core"
+	"github.com/lborres/kuta/pkg/crypto"
+)
+
+// firebaseExport is the JSON written by `firebase auth:export`
+type firebaseExport struct {
+	Users []struct {
+		LocalID          string `json:"localId"`
+		Email            string `json:"email"`
+		EmailVerified    bool   `json:"emailVerified"`
+		PasswordHash     string `json:"passwordHash"`
+		Salt             string `json:"salt"`
+		DisplayName      string `json:"displayName"`
+		PhotoURL         string `json:"photoUrl"`
+		Disabled         bool   `json:"disabled"`
+		CreatedAt        string `json:"createdAt"` // Unix milliseconds
+		ProviderUserInfo []struct {
+			ProviderID string `json:"providerId"`
+			RawID      string `json:"rawId"`
+		} `json:"providerUserInfo"`
+	} `json:"users"`
+}
+
+// ReadFirebase reads the JSON written by `firebase auth:export`. Password
+// hashes are encoded for crypto.FirebaseScrypt, which needs the project's
+// hash parameters to verify them. Provider IDs lose their ".com" suffix
+// ("google.com" becomes "google").
+//
+// Disabled users are imported as rejected, so they still can't sign in, and
+// users without an email (phone sign-in) are left out.
+func ReadFirebase(r io.Reader) ([]Record, error) {
+	var export firebaseExport
+	if err := json.NewDecoder(r).Decode(&export); err != nil {
+		return nil, fmt.Errorf("importer: invalid Firebase export: %w", err)
+	}
+
+	records := make([]Record, 0, len(export.Users))
+	for _, u := range export.Users {
+		if u.Email == "" {
+			continue
+		}
+		user := &core.User{
+			ID:            u.LocalID,
+			Email:         u.Email,
+			EmailVerified: u.EmailVerified,
+			Name:          u.DisplayName,
+		}
+		if u.PhotoURL != "" {
+			user.Image = &u.PhotoURL
+		}
+		if u.Disabled {
+			user.Status = core.UserStatusRejected
+		}
+		if u.CreatedAt != "" {
+			millis, err := strconv.ParseInt(u.CreatedAt, 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("importer: user %s: invalid createdAt: %w", u.LocalID, err)
+			}
+			user.CreatedAt = time.UnixMilli(millis)
+		}
+
+		record := Record{User: user}
+		if u.PasswordHash != "" {
+			hash, err := firebaseHash(u.PasswordHash, u.Salt)
+			if err != nil {
+				return nil, fmt.Errorf("importer: user %s: %w", u.LocalID, err)
+			}
+			record.Accounts = append(record.Accounts, credentialAccount(user, hash))
+		}
+		for _, info := range u.ProviderUserInfo {
+			// Email sign-in is the credential account added above
+			if info.ProviderID == "password" || info.ProviderID == "phone" {
+				continue
+			}
+			record.Accounts = append(record.Accounts, &core.Account{
+				ProviderID: strings.TrimSuffix(info.ProviderID, ".com"),
+				AccountID:  info.RawID,
+			})
+		}
+		records = append(records, record)
+	}
+	return records, nil
+}
+
+// firebaseHash decodes a Firebase password hash and salt, which exports
+// encode in either base64 alphabet
+func firebaseHash(passwordHash, salt string) (string, error) {
+	hash, err := decodeBase64(passwordHash)
+	if err != nil {
+		return "", fmt.Errorf("invalid passwordHash: %w", err)
+	}
+	saltBytes, err := decodeBase64(salt)
+	if err != nil {
+		return "", fmt.Errorf("invalid salt: %w", err)
+	}
+	return crypto.EncodeFirebaseScryptHash(saltBytes, hash), nil
+}
+
+func decodeBase64(s string) ([]byte, error) {
+	if decoded, err := base64.StdEncoding.DecodeString(s); err == nil {
+		return decoded, nil
+	}
+	return base64.URLEncoding.DecodeString(s)
+}
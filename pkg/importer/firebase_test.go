@@ -0,0 +1,52 @@
+package importer
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+
+	"github.com/lborres/kuta/core"
+	"github.com/lborres/kuta/pkg/crypto"
+)
+
+// Requirement: Firebase users keep their IDs, creation time and disabled
+// state, their passwords verify with the project's hash parameters, and
+// provider IDs drop the ".com" suffix.
+func TestReadFirebase(t *testing.T) {
+	// Firebase's published example parameters and hash
+	export := `{"users": [
+		{"localId": "u1", "email": "ada@example.com", "emailVerified": true, "displayName": "Ada", "createdAt": "1700000000000",
+		 "passwordHash": "lSrfV15cpx95/sZS2W9c9Kp6i/LVgQNDNC/qzrCnh1SAyZvqmZqAjTdn3aoItz+VHjoZilo78198JAdRuid5lQ==", "salt": "42xEC+ixf3L2lw==",
+		 "providerUserInfo": [{"providerId": "password", "rawId": "ada@example.com"}, {"providerId": "google.com", "rawId": "1234"}]},
+		{"localId": "u2", "email": "banned@example.com", "disabled": true},
+		{"localId": "u3", "phoneNumber": "+15550100"}
+	]}`
+	signerKey, _ := base64.StdEncoding.DecodeString("jxspr8Ki0RYycVU8zykbdLGjFQ3McFUH0uiiTvC8pVMXAn210wjLNmdZJzxUECKbm0QsEmYUSDzZvpjeJ9WmXA==")
+	handler := crypto.NewMultiPasswordHandler(crypto.NewArgon2(), map[string]crypto.PasswordVerifier{
+		"firebase-scrypt": &crypto.FirebaseScrypt{SignerKey: signerKey, SaltSeparator: []byte{7}, Rounds: 8, MemCost: 14},
+	})
+
+	// Act
+	records, err := ReadFirebase(strings.NewReader(export))
+
+	// Assert
+	if err != nil {
+		t.Fatalf("ReadFirebase() error = %v", err)
+	}
+	if len(records) != 2 {
+		t.Fatalf("records = %+v, want u1 and u2", records)
+	}
+	ada := records[0]
+	if ada.User.ID != "u1" || ada.User.CreatedAt.UnixMilli() != 1700000000000 || len(ada.Accounts) != 2 {
+		t.Fatalf("record = %+v, want u1 with its creation time and two accounts", ada)
+	}
+	if ok, err := handler.Verify("user1password", *ada.Accounts[0].Password); !ok || err != nil {
+		t.Errorf("Verify() imported hash = %v, %v; want true", ok, err)
+	}
+	if ada.Accounts[1].ProviderID != "google" || ada.Accounts[1].AccountID != "1234" {
+		t.Errorf("provider account = %+v, want google 1234", ada.Accounts[1])
+	}
+	if records[1].User.Status != core.UserStatusRejected || records[1].User.Active() {
+		t.Errorf("disabled user status = %q, want rejected", records[1].User.Status)
+	}
+}
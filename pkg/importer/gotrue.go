@@ -0,0 +1,107 @@
+package importer
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/lborres/kuta/core"
+)
+
+// goTrueExport is a dump of GoTrue's auth.users and auth.identities tables
+type goTrueExport struct {
+	Users []struct {
+		ID                string         `json:"id"`
+		Email             string         `json:"email"`
+		EncryptedPassword string         `json:"encrypted_password"`
+		EmailConfirmedAt  *time.Time     `json:"email_confirmed_at"`
+		RawUserMetaData   map[string]any `json:"raw_user_meta_data"`
+		CreatedAt         time.Time      `json:"created_at"`
+		UpdatedAt         time.Time      `json:"updated_at"`
+		DeletedAt         *time.Time     `json:"deleted_at"`
+	} `json:"users"`
+	Identities []struct {
+		UserID       string         `json:"user_id"`
+		Provider     string         `json:"provider"`
+		ProviderID   string         `json:"provider_id"`
+		IdentityData map[string]any `json:"identity_data"`
+		CreatedAt    time.Time      `json:"created_at"`
+		UpdatedAt    time.Time      `json:"updated_at"`
+	} `json:"identities"`
+}
+
+// ReadGoTrue reads a Supabase (GoTrue) export: a JSON object whose "users"
+// and "identities" arrays hold the rows of auth.users and auth.identities,
+// e.g. as produced by json_agg. Passwords are bcrypt hashes.
+//
+// Users without an email (phone sign-in) and deleted users are left out,
+// along with their identities.
+func ReadGoTrue(r io.Reader) ([]Record, error) {
+	var export goTrueExport
+	if err := json.NewDecoder(r).Decode(&export); err != nil {
+		return nil, fmt.Errorf("importer: invalid GoTrue export: %w", err)
+	}
+
+	records := make([]Record, 0, len(export.Users))
+	for _, u := range export.Users {
+		if u.Email == "" || u.DeletedAt != nil {
+			continue
+		}
+		user := &core.User{
+			ID:            u.ID,
+			Email:         u.Email,
+			EmailVerified: u.EmailConfirmedAt != nil,
+			Name:          metadataString(u.RawUserMetaData, "full_name", "name"),
+			CreatedAt:     u.CreatedAt,
+			UpdatedAt:     u.UpdatedAt,
+		}
+		if image := metadataString(u.RawUserMetaData, "avatar_url", "picture"); image != "" {
+			user.Image = &image
+		}
+
+		record := Record{User: user}
+		if u.EncryptedPassword != "" {
+			record.Accounts = append(record.Accounts, credentialAccount(user, u.EncryptedPassword))
+		}
+		records = append(records, record)
+	}
+
+	byID := make(map[string]*Record, len(records))
+	for i := range records {
+		byID[records[i].User.ID] = &records[i]
+	}
+	for _, identity := range export.Identities {
+		// Email sign-in is the credential account added above
+		if identity.Provider == "email" || identity.Provider == "phone" {
+			continue
+		}
+		record, ok := byID[identity.UserID]
+		if !ok {
+			continue
+		}
+		// Older GoTrue versions only have the provider's ID in identity_data
+		accountID := identity.ProviderID
+		if accountID == "" {
+			accountID = metadataString(identity.IdentityData, "sub")
+		}
+		record.Accounts = append(record.Accounts, &core.Account{
+			ProviderID:  identity.Provider,
+			AccountID:   accountID,
+			ProfileData: identity.IdentityData,
+			CreatedAt:   identity.CreatedAt,
+			UpdatedAt:   identity.UpdatedAt,
+		})
+	}
+	return records, nil
+}
+
+// metadataString returns the first of keys holding a non-empty string
+func metadataString(metadata map[string]any, keys ...string) string {
+	for _, key := range keys {
+		if value, ok := metadata[key].(string); ok && value != "" {
+			return value
+		}
+	}
+	return ""
+}
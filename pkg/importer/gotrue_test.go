@@ -0,0 +1,52 @@
+package importer
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/lborres/kuta/core"
+)
+
+// Requirement: GoTrue users get a credential account for their bcrypt hash
+// and one account per OAuth identity; phone-only and deleted users are left
+// out.
+func TestReadGoTrue(t *testing.T) {
+	export := `{
+		"users": [
+			{"id": "u1", "email": "ada@example.com", "encrypted_password": "$2a$10$hash", "email_confirmed_at": "2024-01-02T03:04:05.123456+00:00",
+			 "raw_user_meta_data": {"full_name": "Ada", "avatar_url": "https://img.test/ada.png"}, "created_at": "2024-01-01T00:00:00+00:00"},
+			{"id": "u2", "email": "", "encrypted_password": "$2a$10$hash"},
+			{"id": "u3", "email": "gone@example.com", "deleted_at": "2024-02-01T00:00:00+00:00"}
+		],
+		"identities": [
+			{"user_id": "u1", "provider": "email", "provider_id": "u1"},
+			{"user_id": "u1", "provider": "github", "identity_data": {"sub": "42"}},
+			{"user_id": "u3", "provider": "google", "provider_id": "7"}
+		]}`
+
+	// Act
+	records, err := ReadGoTrue(strings.NewReader(export))
+
+	// Assert
+	if err != nil {
+		t.Fatalf("ReadGoTrue() error = %v", err)
+	}
+	if len(records) != 1 {
+		t.Fatalf("records = %+v, want only u1", records)
+	}
+	user := records[0].User
+	if user.Name != "Ada" || user.Image == nil || !user.EmailVerified || user.CreatedAt.IsZero() {
+		t.Errorf("user = %+v, want name, image, verified email and creation time", user)
+	}
+	accounts := records[0].Accounts
+	if len(accounts) != 2 || accounts[0].ProviderID != core.CredentialProviderID || *accounts[0].Password != "$2a$10$hash" {
+		t.Fatalf("accounts = %+v, want the credential and github accounts", accounts)
+	}
+	if accounts[1].ProviderID != "github" || accounts[1].AccountID != "42" {
+		t.Errorf("identity account = %+v, want github 42", accounts[1])
+	}
+
+	if _, err := ReadGoTrue(strings.NewReader("[")); err == nil {
+		t.Error("ReadGoTrue() of invalid JSON succeeded")
+	}
+}
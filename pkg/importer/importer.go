@@ -0,0 +1,118 @@
+// Package importer bulk-loads users and their accounts exported from other
+// auth systems into a core.StorageProvider.
+//
+// Password hashes are kept as they are, re-encoded where needed so
+// crypto.HashAlgorithm identifies them. Before imported users sign in,
+// configure a crypto.MultiPasswordHandler that verifies their algorithms,
+// and optionally a PasswordUpgradePolicy migrating away from them.
+package importer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/lborres/kuta/core"
+	"github.com/lborres/kuta/pkg/crypto"
+)
+
+// Record is one user to import with their accounts. Empty user and account
+// IDs are generated, and accounts are linked to the user.
+type Record struct {
+	User     *core.User
+	Accounts []*core.Account
+}
+
+// Result reports what Import did
+type Result struct {
+	// Imported counts the users created
+	Imported int `json:"imported"`
+	// Skipped lists the emails already registered. Their accounts aren't
+	// imported, so an import can be run again after fixing a failure.
+	Skipped []string `json:"skipped"`
+}
+
+// Import creates the users of records and their accounts in storage, in
+// order. It stops at the first storage error and returns what was imported
+// until then; the user whose import failed is removed again.
+func Import(ctx context.Context, storage core.StorageProvider, records []Record) (*Result, error) {
+	ids, err := crypto.NewNanoID()
+	if err != nil {
+		return nil, err
+	}
+
+	result := &Result{Skipped: []string{}}
+	now := time.Now()
+	for _, record := range records {
+		user := record.User
+		if err := prepareUser(user, ids, now); err != nil {
+			return result, err
+		}
+
+		if err := storage.CreateUserIfNotExists(ctx, user); err != nil {
+			if errors.Is(err, core.ErrUserExists) {
+				result.Skipped = append(result.Skipped, user.Email)
+				continue
+			}
+			return result, fmt.Errorf("importer: %s: %w", user.Email, err)
+		}
+
+		for _, account := range record.Accounts {
+			if err := createAccount(ctx, storage, account, user, ids); err != nil {
+				// Cleanup: a user without their accounts may be unable to sign in
+				_ = storage.DeleteUser(ctx, user.ID)
+				return result, fmt.Errorf("importer: %s: %w", user.Email, err)
+			}
+		}
+		result.Imported++
+	}
+	return result, nil
+}
+
+// prepareUser fills in the ID and timestamps the export didn't have
+func prepareUser(user *core.User, ids *crypto.NanoIDGenerator, now time.Time) error {
+	if user.ID == "" {
+		id, err := ids.Generate()
+		if err != nil {
+			return err
+		}
+		user.ID = id
+	}
+	if user.CreatedAt.IsZero() {
+		user.CreatedAt = now
+	}
+	if user.UpdatedAt.IsZero() {
+		user.UpdatedAt = user.CreatedAt
+	}
+	return nil
+}
+
+func createAccount(ctx context.Context, storage core.StorageProvider, account *core.Account, user *core.User, ids *crypto.NanoIDGenerator) error {
+	if account.ID == "" {
+		id, err := ids.Generate()
+		if err != nil {
+			return err
+		}
+		account.ID = id
+	}
+	account.UserID = user.ID
+	if account.CreatedAt.IsZero() {
+		account.CreatedAt = user.CreatedAt
+	}
+	if account.UpdatedAt.IsZero() {
+		account.UpdatedAt = account.CreatedAt
+	}
+	return storage.CreateAccount(ctx, account)
+}
+
+// credentialAccount is the email and password account of user with the
+// given password hash
+func credentialAccount(user *core.User, hash string) *core.Account {
+	return &core.Account{
+		ProviderID: core.CredentialProviderID,
+		AccountID:  user.Email,
+		Password:   &hash,
+		CreatedAt:  user.CreatedAt,
+	}
+}
@@ -0,0 +1,82 @@
+package importer
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/lborres/kuta/core"
+	"github.com/lborres/kuta/services"
+)
+
+// failingAccounts is a storage whose account inserts fail
+type failingAccounts struct {
+	*services.FakeStorageProvider
+}
+
+func (failingAccounts) CreateAccount(ctx context.Context, a *core.Account) error {
+	return errors.New("disk full")
+}
+
+func newRecord(email string, accounts ...*core.Account) Record {
+	return Record{User: &core.User{Email: email}, Accounts: accounts}
+}
+
+// Requirement: Import creates users with generated IDs and linked accounts,
+// skips emails already registered, and removes a user whose accounts fail
+// to import before reporting the error.
+func TestImport(t *testing.T) {
+	tests := []struct {
+		name         string
+		failAccounts bool
+		wantImported int
+		wantSkipped  int
+		wantErr      bool
+	}{
+		{name: "imports new users", wantImported: 2, wantSkipped: 1},
+		{name: "account failure", failAccounts: true, wantErr: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// Arrange
+			fake := services.NewFakeStorageProvider()
+			_ = fake.CreateUser(t.Context(), &core.User{ID: "user-1", Email: "taken@example.com"})
+			var storage core.StorageProvider = fake
+			if test.failAccounts {
+				storage = failingAccounts{fake}
+			}
+			hash := "$2b$10$N9qo8uLOickgx2ZMRZoMyeIjZAgcfl7p92ldGxad68LJZdL17lhWy"
+			records := []Record{
+				newRecord("a@example.com", credentialAccount(&core.User{Email: "a@example.com"}, hash)),
+				newRecord("taken@example.com"),
+				newRecord("b@example.com"),
+			}
+
+			// Act
+			result, err := Import(t.Context(), storage, records)
+
+			// Assert
+			if (err != nil) != test.wantErr {
+				t.Fatalf("Import() error = %v, want error %v", err, test.wantErr)
+			}
+			if result.Imported != test.wantImported || len(result.Skipped) != test.wantSkipped {
+				t.Errorf("Import() = %+v, want %d imported and %d skipped", result, test.wantImported, test.wantSkipped)
+			}
+			user, err := fake.GetUserByEmail(t.Context(), "a@example.com")
+			if test.wantErr {
+				if !errors.Is(err, core.ErrUserNotFound) {
+					t.Errorf("failed import left user %+v behind", user)
+				}
+				return
+			}
+			if err != nil || user.ID == "" || user.CreatedAt.IsZero() {
+				t.Fatalf("imported user = %+v, %v; want an ID and a creation time", user, err)
+			}
+			accounts, _ := fake.GetAccountByUserAndProvider(t.Context(), user.ID, core.CredentialProviderID)
+			if len(accounts) != 1 || accounts[0].ID == "" || *accounts[0].Password != hash {
+				t.Errorf("credential accounts = %+v, want one carrying the hash", accounts)
+			}
+		})
+	}
+}
@@ -0,0 +1,46 @@
+package mailtemplate
+
+import "github.com/lborres/kuta/core"
+
+// defaults are deliberately plain so they read well in any client; override
+// them for branding
+var defaults = map[string]Template{
+	core.EmailVerification: {
+		Subject: `Verify your email{{with .AppName}} for {{.}}{{end}}`,
+		HTML: `<p>Hi{{with .User}}{{with .Name}} {{.}}{{end}}{{end}},</p>
+<p>Confirm your email address to finish setting up your account.</p>
+{{with .URL}}<p><a href="{{.}}">Verify email</a></p>{{end}}
+{{with .Code}}<p>Your verification code is <strong>{{.}}</strong>.</p>{{end}}
+{{if not .ExpiresAt.IsZero}}<p>This expires at {{.ExpiresAt.UTC.Format "2006-01-02 15:04 MST"}}.</p>{{end}}
+<p>If you didn't sign up, you can ignore this email.</p>`,
+	},
+	core.EmailPasswordReset: {
+		Subject: `Reset your password{{with .AppName}} for {{.}}{{end}}`,
+		HTML: `<p>Hi{{with .User}}{{with .Name}} {{.}}{{end}}{{end}},</p>
+<p>Someone asked to reset the password of your account.</p>
+{{with .URL}}<p><a href="{{.}}">Reset password</a></p>{{end}}
+{{with .Code}}<p>Your reset code is <strong>{{.}}</strong>.</p>{{end}}
+{{if not .ExpiresAt.IsZero}}<p>This expires at {{.ExpiresAt.UTC.Format "2006-01-02 15:04 MST"}}.</p>{{end}}
+<p>If it wasn't you, you can ignore this email; your password stays the same.</p>`,
+	},
+	core.EmailSecurityAlert: {
+		Subject: `Security alert{{with .AppName}} for your {{.}} account{{end}}`,
+		HTML: `<p>Hi{{with .User}}{{with .Name}} {{.}}{{end}}{{end}},</p>
+<p>We noticed unusual activity on your account.</p>
+{{with .Details}}<ul>
+{{with .ip_address}}<li>IP address: {{.}}</li>{{end}}
+{{with .user_agent}}<li>Device: {{.}}</li>{{end}}
+{{with .reason}}<li>Reason: {{.}}</li>{{end}}
+</ul>{{end}}
+<p>If this was you, no action is needed. Otherwise, change your password and sign out of other sessions.</p>`,
+	},
+	core.EmailOrganizationInvite: {
+		Subject: `You're invited{{with .AppName}} to join {{.}}{{end}}`,
+		HTML: `<p>Hi{{with .User}}{{with .Name}} {{.}}{{end}}{{end}},</p>
+<p>You've been invited to join an organization{{with .Details}}{{with .organization_id}} ({{.}}){{end}}{{end}}.</p>
+{{with .URL}}<p><a href="{{.}}">Accept invite</a></p>{{end}}
+{{with .Code}}<p>Your invite code is <strong>{{.}}</strong>.</p>{{end}}
+{{if not .ExpiresAt.IsZero}}<p>This expires at {{.ExpiresAt.UTC.Format "2006-01-02 15:04 MST"}}.</p>{{end}}
+<p>If you weren't expecting this, you can ignore this email.</p>`,
+	},
+}
@@ -0,0 +1,167 @@
+// Package mailtemplate renders kuta's emails from html/template and
+// text/template sources. Every message type ships with a plain default that
+// integrators can override, one message type at a time, to match their
+// branding.
+package mailtemplate
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"html"
+	htmltemplate "html/template"
+	"regexp"
+	"strings"
+	texttemplate "text/template"
+
+	"github.com/lborres/kuta/core"
+)
+
+var ErrUnknownTemplate = errors.New("mailtemplate: no template for message type")
+
+// Ensure Renderer implements EmailRenderer
+var _ core.EmailRenderer = (*Renderer)(nil)
+
+// Template holds the sources of one message type, all executed with a
+// core.EmailData. Subject and Text use text/template, HTML uses
+// html/template.
+//
+// When overriding a default, an empty Subject keeps the default one and an
+// empty Text is derived from the rendered HTML.
+type Template struct {
+	Subject string
+	HTML    string
+	Text    string
+}
+
+type compiled struct {
+	subject *texttemplate.Template
+	html    *htmltemplate.Template
+	text    *texttemplate.Template // nil when derived from html
+}
+
+// Renderer renders emails from parsed templates. It is safe for concurrent
+// use.
+type Renderer struct {
+	templates map[string]*compiled
+}
+
+// New parses the default templates with overrides applied on top. Overrides
+// may also add message types of their own.
+func New(overrides map[string]Template) (*Renderer, error) {
+	sources := make(map[string]Template, len(defaults)+len(overrides))
+	for messageType, tmpl := range defaults {
+		sources[messageType] = tmpl
+	}
+	for messageType, tmpl := range overrides {
+		if tmpl.Subject == "" {
+			tmpl.Subject = defaults[messageType].Subject
+		}
+		sources[messageType] = tmpl
+	}
+
+	r := &Renderer{templates: make(map[string]*compiled, len(sources))}
+	for messageType, tmpl := range sources {
+		c, err := compile(messageType, tmpl)
+		if err != nil {
+			return nil, err
+		}
+		r.templates[messageType] = c
+	}
+	return r, nil
+}
+
+// Default returns a Renderer with only the default templates
+func Default() *Renderer {
+	r, err := New(nil)
+	if err != nil {
+		panic(err) // the defaults are fixed, so this is a bug
+	}
+	return r
+}
+
+func compile(messageType string, tmpl Template) (*compiled, error) {
+	if tmpl.Subject == "" || tmpl.HTML == "" {
+		return nil, fmt.Errorf("mailtemplate: %s: subject and HTML are required", messageType)
+	}
+
+	c := &compiled{}
+	var err error
+	if c.subject, err = texttemplate.New(messageType + ".subject").Parse(tmpl.Subject); err != nil {
+		return nil, fmt.Errorf("mailtemplate: %s: %w", messageType, err)
+	}
+	if c.html, err = htmltemplate.New(messageType + ".html").Parse(tmpl.HTML); err != nil {
+		return nil, fmt.Errorf("mailtemplate: %s: %w", messageType, err)
+	}
+	if tmpl.Text != "" {
+		if c.text, err = texttemplate.New(messageType + ".text").Parse(tmpl.Text); err != nil {
+			return nil, fmt.Errorf("mailtemplate: %s: %w", messageType, err)
+		}
+	}
+	return c, nil
+}
+
+// RenderEmail renders the templates of messageType. The caller sets To.
+func (r *Renderer) RenderEmail(messageType string, data core.EmailData) (*core.EmailMessage, error) {
+	c, ok := r.templates[messageType]
+	if !ok {
+		return nil, fmt.Errorf("%w %q", ErrUnknownTemplate, messageType)
+	}
+
+	var subject, body, text bytes.Buffer
+	if err := c.subject.Execute(&subject, data); err != nil {
+		return nil, err
+	}
+	if err := c.html.Execute(&body, data); err != nil {
+		return nil, err
+	}
+
+	message := &core.EmailMessage{
+		Type:    messageType,
+		Subject: strings.Join(strings.Fields(subject.String()), " "),
+		HTML:    body.String(),
+	}
+	if c.text == nil {
+		message.Text = PlainText(message.HTML)
+		return message, nil
+	}
+	if err := c.text.Execute(&text, data); err != nil {
+		return nil, err
+	}
+	message.Text = text.String()
+	return message, nil
+}
+
+var (
+	blockTag    = regexp.MustCompile(`(?i)<\s*(br|/p|/div|/h[1-6]|/li|/tr)\b[^>]*>`)
+	linkTag     = regexp.MustCompile(`(?is)<a\b[^>]*\bhref="([^"]*)"[^>]*>(.*?)</a>`)
+	droppedTag  = regexp.MustCompile(`(?is)<(style|script|head)\b.*?</(style|script|head)>`)
+	anyTag      = regexp.MustCompile(`(?s)<[^>]*>`)
+	blankSpaces = regexp.MustCompile(`[ \t]+`)
+	blankLines  = regexp.MustCompile(`\n\s*\n+`)
+)
+
+// PlainText derives a plaintext alternative from an HTML email: tags are
+// removed, block ends become line breaks and links keep their URL.
+func PlainText(htmlBody string) string {
+	s := droppedTag.ReplaceAllString(htmlBody, "")
+	s = linkTag.ReplaceAllStringFunc(s, func(link string) string {
+		m := linkTag.FindStringSubmatch(link)
+		label := strings.TrimSpace(anyTag.ReplaceAllString(m[2], ""))
+		if label == "" || label == m[1] {
+			return m[1]
+		}
+		return label + " (" + m[1] + ")"
+	})
+	s = blockTag.ReplaceAllString(s, "\n")
+	s = anyTag.ReplaceAllString(s, "")
+	s = html.UnescapeString(s)
+
+	lines := strings.Split(s, "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimSpace(blankSpaces.ReplaceAllString(line, " "))
+	}
+	s = strings.Join(lines, "\n")
+	s = blankLines.ReplaceAllString(s, "\n\n")
+	return strings.TrimSpace(s) + "\n"
+}
@@ -0,0 +1,121 @@
+package mailtemplate
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lborres/kuta/core"
+)
+
+// Requirement: Every message type renders from the defaults, with a subject,
+// HTML body and plaintext alternative carrying the link and values.
+func TestRenderer_Defaults(t *testing.T) {
+	data := core.EmailData{
+		AppName:   "Acme",
+		User:      &core.User{Email: "user@example.com", Name: "Ada"},
+		URL:       "https://acme.test/verify?token=a&b=c",
+		Code:      "123456",
+		ExpiresAt: time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC),
+		Details:   map[string]string{"ip_address": "10.0.0.1"},
+	}
+
+	tests := []struct {
+		messageType string
+		wantSubject string
+		wantText    []string
+	}{
+		{messageType: core.EmailVerification, wantSubject: "Verify your email for Acme", wantText: []string{"Hi Ada,", "https://acme.test/verify?token=a&b=c", "123456", "2026-10-16 12:00 UTC"}},
+		{messageType: core.EmailPasswordReset, wantSubject: "Reset your password for Acme", wantText: []string{"Reset password (https://acme.test/verify?token=a&b=c)"}},
+		{messageType: core.EmailSecurityAlert, wantSubject: "Security alert for your Acme account", wantText: []string{"IP address: 10.0.0.1"}},
+		{messageType: core.EmailOrganizationInvite, wantSubject: "You're invited to join Acme", wantText: []string{"Accept invite (https://acme.test/verify?token=a&b=c)"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.messageType, func(t *testing.T) {
+			// Arrange
+			renderer := Default()
+
+			// Act
+			message, err := renderer.RenderEmail(test.messageType, data)
+
+			// Assert
+			if err != nil {
+				t.Fatalf("RenderEmail() error = %v", err)
+			}
+			if message.Subject != test.wantSubject || message.Type != test.messageType {
+				t.Errorf("subject = %q, type = %q, want %q, %q", message.Subject, message.Type, test.wantSubject, test.messageType)
+			}
+			if strings.Contains(message.Text, "<") {
+				t.Errorf("text contains markup: %q", message.Text)
+			}
+			for _, want := range test.wantText {
+				if !strings.Contains(message.Text, want) {
+					t.Errorf("text missing %q:\n%s", want, message.Text)
+				}
+			}
+		})
+	}
+}
+
+// Requirement: Overrides replace the templates of one message type, keep the
+// default subject when empty, escape HTML values and may add new types.
+func TestRenderer_Overrides(t *testing.T) {
+	renderer, err := New(map[string]Template{
+		core.EmailVerification: {HTML: `<h1>{{.AppName}}</h1><p>{{.Details.note}}</p>`},
+		"welcome":              {Subject: "Welcome {{.User.Name}}", HTML: "<p>Hi</p>", Text: "Hello {{.User.Name}}"},
+	})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	data := core.EmailData{AppName: "Acme", User: &core.User{Name: "Ada"}, Details: map[string]string{"note": "<b>x</b>"}}
+
+	tests := []struct {
+		messageType string
+		wantSubject string
+		wantHTML    string
+		wantText    string
+		wantErr     error
+	}{
+		{messageType: core.EmailVerification, wantSubject: "Verify your email for Acme", wantHTML: "<h1>Acme</h1><p>&lt;b&gt;x&lt;/b&gt;</p>", wantText: "Acme\n<b>x</b>\n"},
+		{messageType: "welcome", wantSubject: "Welcome Ada", wantHTML: "<p>Hi</p>", wantText: "Hello Ada"},
+		{messageType: core.EmailPasswordReset, wantSubject: "Reset your password for Acme"},
+		{messageType: "missing", wantErr: ErrUnknownTemplate},
+	}
+
+	for _, test := range tests {
+		t.Run(test.messageType, func(t *testing.T) {
+			// Act
+			message, err := renderer.RenderEmail(test.messageType, data)
+
+			// Assert
+			if !errors.Is(err, test.wantErr) {
+				t.Fatalf("RenderEmail() error = %v, want %v", err, test.wantErr)
+			}
+			if test.wantErr != nil {
+				return
+			}
+			if message.Subject != test.wantSubject {
+				t.Errorf("subject = %q, want %q", message.Subject, test.wantSubject)
+			}
+			if test.wantHTML != "" && message.HTML != test.wantHTML {
+				t.Errorf("HTML = %q, want %q", message.HTML, test.wantHTML)
+			}
+			if test.wantText != "" && message.Text != test.wantText {
+				t.Errorf("text = %q, want %q", message.Text, test.wantText)
+			}
+		})
+	}
+}
+
+// Requirement: Templates that don't parse are reported by New.
+func TestNew_InvalidTemplate(t *testing.T) {
+	// Act
+	_, err := New(map[string]Template{"broken": {Subject: "x", HTML: "{{.Oops"}})
+
+	// Assert
+	if err == nil || !strings.Contains(err.Error(), "broken") {
+		t.Errorf("New() error = %v, want a parse error naming the template", err)
+	}
+}
@@ -0,0 +1,243 @@
+package oauth
+
+import (
+	"crypto/ecdsa"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lborres/kuta/core"
+)
+
+const (
+	AppleProviderID = "apple"
+
+	appleIssuer    = "https://appleid.apple.com"
+	appleAuthURL   = "https://appleid.apple.com/auth/authorize"
+	appleTokenURL  = "https://appleid.apple.com/auth/token"
+	appleKeysURL   = "https://appleid.apple.com/auth/keys"
+	appleRevokeURL = "https://appleid.apple.com/auth/revoke"
+
+	// appleClientSecretTTL is how long a generated client secret is used.
+	// Apple accepts up to six months.
+	appleClientSecretTTL = 24 * time.Hour
+)
+
+// AppleConfig configures Sign in with Apple. The key ID and .p8 private key
+// come from a key with Sign in with Apple enabled in the developer account.
+type AppleConfig struct {
+	// ClientID is the Services ID, e.g. "com.example.web"
+	ClientID string
+	TeamID   string
+	KeyID    string
+	// PrivateKey is the contents of the .p8 file
+	PrivateKey []byte
+	// Scopes defaults to "name" and "email"
+	Scopes []string
+
+	// HTTPClient calls Apple's endpoints. Defaults to a client with a 10
+	// second timeout.
+	HTTPClient *http.Client
+	// AuthURL, TokenURL, KeysURL and RevokeURL override Apple's endpoints,
+	// for tests
+	AuthURL   string
+	TokenURL  string
+	KeysURL   string
+	RevokeURL string
+}
+
+// Apple signs users in with their Apple ID. It handles the ways Apple
+// differs from plain OAuth:
+//
+//   - the callback arrives as a form POST (response mode form_post), so
+//     the callback endpoint must accept POST
+//   - the client secret is a JWT signed with the developer's private key
+//     rather than a fixed string
+//   - the user's name, and the email as the user entered it, are sent only
+//     on the first sign-in, in a "user" form field next to the code
+//   - ID token claims like email_verified may be strings
+type Apple struct {
+	config AppleConfig
+	key    *ecdsa.PrivateKey
+	client *http.Client
+	keys   *keySet
+
+	mu                 sync.Mutex
+	secret             string
+	secretRenewedAfter time.Time
+}
+
+var (
+	_ core.OAuthProvider     = (*Apple)(nil)
+	_ core.OAuthTokenRevoker = (*Apple)(nil)
+)
+
+// NewApple creates the Apple provider, failing if the private key can't be
+// read
+func NewApple(config AppleConfig) (*Apple, error) {
+	if config.ClientID == "" || config.TeamID == "" || config.KeyID == "" {
+		return nil, errors.New("apple: ClientID, TeamID and KeyID are required")
+	}
+	key, err := parseECPrivateKey(config.PrivateKey)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(config.Scopes) == 0 {
+		config.Scopes = []string{"name", "email"}
+	}
+	if config.AuthURL == "" {
+		config.AuthURL = appleAuthURL
+	}
+	if config.TokenURL == "" {
+		config.TokenURL = appleTokenURL
+	}
+	if config.KeysURL == "" {
+		config.KeysURL = appleKeysURL
+	}
+	if config.RevokeURL == "" {
+		config.RevokeURL = appleRevokeURL
+	}
+
+	client := defaultHTTPClient(config.HTTPClient)
+	return &Apple{
+		config: config,
+		key:    key,
+		client: client,
+		keys:   newKeySet(config.KeysURL, client),
+	}, nil
+}
+
+func (a *Apple) ID() string {
+	return AppleProviderID
+}
+
+// AuthCodeURL asks for the form_post response mode, which Apple requires
+// whenever name or email are requested. Apple doesn't support PKCE, so
+// codeChallenge is not sent; the single-use state and the nonce derived from
+// it still guard the callback.
+func (a *Apple) AuthCodeURL(state, redirectURI, codeChallenge string) string {
+	query := url.Values{
+		"client_id":     {a.config.ClientID},
+		"redirect_uri":  {redirectURI},
+		"response_type": {"code"},
+		"response_mode": {"form_post"},
+		"scope":         {strings.Join(a.config.Scopes, " ")},
+		"state":         {state},
+	}
+	setNonce(query, codeChallenge)
+	return a.config.AuthURL + "?" + query.Encode()
+}
+
+// appleUser is the "user" form field of the first sign-in
+type appleUser struct {
+	Name struct {
+		FirstName string `json:"firstName"`
+		LastName  string `json:"lastName"`
+	} `json:"name"`
+	Email string `json:"email"`
+}
+
+// Exchange redeems the code and reads the user from the ID token. The name
+// comes from the "user" field when present; it isn't signed, so the email
+// in it is only used, unverified, when the ID token has none.
+func (a *Apple) Exchange(callback core.OAuthCallback) (*core.OAuthIdentity, error) {
+	if callback.Code == "" {
+		return nil, ErrMissingCode
+	}
+
+	secret, err := a.clientSecret()
+	if err != nil {
+		return nil, err
+	}
+	token, err := exchangeCode(a.client, a.config.TokenURL, url.Values{
+		"client_id":     {a.config.ClientID},
+		"client_secret": {secret},
+		"code":          {callback.Code},
+		"grant_type":    {"authorization_code"},
+		"redirect_uri":  {callback.RedirectURI},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if token.IDToken == "" {
+		return nil, errors.New("apple: token response has no ID token")
+	}
+
+	claims, err := verifyIDToken(token.IDToken, a.keys, a.config.ClientID, flowNonce(callback.CodeVerifier), exactIssuer(appleIssuer))
+	if err != nil {
+		return nil, err
+	}
+
+	identity := &core.OAuthIdentity{
+		AccountID:     claims.Subject,
+		Email:         claims.Email,
+		EmailVerified: bool(claims.EmailVerified),
+		AccessToken:   token.AccessToken,
+		RefreshToken:  token.RefreshToken,
+		ExpiresAt:     token.expiresAt(),
+		Profile:       claims.raw,
+	}
+
+	if raw := callback.Params["user"]; raw != "" {
+		var user appleUser
+		if err := json.Unmarshal([]byte(raw), &user); err == nil {
+			identity.Name = strings.TrimSpace(user.Name.FirstName + " " + user.Name.LastName)
+			if identity.Email == "" {
+				identity.Email = user.Email
+				identity.EmailVerified = false
+			}
+			if identity.Name != "" {
+				identity.Profile["name"] = identity.Name
+			}
+		}
+	}
+
+	return identity, nil
+}
+
+// RevokeToken ends the user's authorization of the app, which Apple
+// requires apps that offer account deletion to do
+func (a *Apple) RevokeToken(token, tokenTypeHint string) error {
+	secret, err := a.clientSecret()
+	if err != nil {
+		return err
+	}
+	return revokeToken(a.client, a.config.RevokeURL, url.Values{
+		"client_id":       {a.config.ClientID},
+		"client_secret":   {secret},
+		"token":           {token},
+		"token_type_hint": {tokenTypeHint},
+	})
+}
+
+// clientSecret returns the ES256 JWT Apple takes as client secret, signing
+// a new one when the current one is close to expiring
+func (a *Apple) clientSecret() (string, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	now := time.Now()
+	if a.secret != "" && now.Before(a.secretRenewedAfter) {
+		return a.secret, nil
+	}
+
+	secret, err := signES256(a.key, a.config.KeyID, map[string]any{
+		"iss": a.config.TeamID,
+		"iat": now.Unix(),
+		"exp": now.Add(appleClientSecretTTL).Unix(),
+		"aud": appleIssuer,
+		"sub": a.config.ClientID,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	a.secret = secret
+	a.secretRenewedAfter = now.Add(appleClientSecretTTL - time.Hour)
+	return secret, nil
+}
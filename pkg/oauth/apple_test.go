@@ -0,0 +1,290 @@
+package oauth
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lborres/kuta/core"
+)
+
+// verifyClientSecret checks the ES256 signature of a client secret JWT and
+// returns its claims
+func verifyClientSecret(key *ecdsa.PublicKey, secret string) (map[string]any, bool) {
+	parts := strings.Split(secret, ".")
+	if len(parts) != 3 {
+		return nil, false
+	}
+	signature, err := b64.DecodeString(parts[2])
+	if err != nil || len(signature) != 64 {
+		return nil, false
+	}
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	r := new(big.Int).SetBytes(signature[:32])
+	s := new(big.Int).SetBytes(signature[32:])
+	if !ecdsa.Verify(key, digest[:], r, s) {
+		return nil, false
+	}
+	var claims map[string]any
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, false
+	}
+	return claims, true
+}
+
+// newTestApple returns an Apple provider talking to a fake Apple that only
+// accepts client secrets signed with the provider's key. secretClaims
+// receives the claims of the last accepted secret.
+func newTestApple(t *testing.T, secretClaims *map[string]any) (*Apple, *fakeIdP) {
+	t.Helper()
+	clientKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generate EC key: %v", err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(clientKey)
+	if err != nil {
+		t.Fatalf("marshal EC key: %v", err)
+	}
+
+	idp := newFakeIdP(t)
+	idp.checkToken = func(form url.Values) bool {
+		claims, ok := verifyClientSecret(&clientKey.PublicKey, form.Get("client_secret"))
+		if ok && secretClaims != nil {
+			*secretClaims = claims
+		}
+		return ok
+	}
+	apple, err := NewApple(AppleConfig{
+		ClientID:   "com.example.web",
+		TeamID:     "TEAM123",
+		KeyID:      "KEY123",
+		PrivateKey: pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}),
+		TokenURL:   idp.server.URL + "/token",
+		KeysURL:    idp.server.URL + "/keys",
+		RevokeURL:  idp.server.URL + "/revoke",
+	})
+	if err != nil {
+		t.Fatalf("NewApple() error = %v", err)
+	}
+	return apple, idp
+}
+
+// Requirement: Exchange redeems the code with a client secret JWT signed by
+// the private key and reads the user from the verified ID token, taking the
+// name from the "user" field Apple only posts on the first sign-in.
+func TestApple_Exchange(t *testing.T) {
+	validClaims := func() map[string]any {
+		return map[string]any{
+			"iss":            appleIssuer,
+			"aud":            "com.example.web",
+			"sub":            "001234.abcd",
+			"exp":            time.Now().Add(time.Hour).Unix(),
+			"iat":            time.Now().Unix(),
+			"email":          "abc@privaterelay.appleid.com",
+			"email_verified": "true",
+		}
+	}
+
+	tests := []struct {
+		name          string
+		code          string
+		claims        func(map[string]any)
+		user          string
+		wantErr       error
+		wantAnyErr    bool
+		wantName      string
+		wantEmail     string
+		wantVerified  bool
+		wantAccountID string
+	}{
+		{
+			name:          "first sign-in with user field",
+			code:          "good-code",
+			user:          `{"name":{"firstName":"Jane","lastName":"Appleseed"},"email":"abc@privaterelay.appleid.com"}`,
+			wantName:      "Jane Appleseed",
+			wantEmail:     "abc@privaterelay.appleid.com",
+			wantVerified:  true,
+			wantAccountID: "001234.abcd",
+		},
+		{
+			name:          "later sign-in without user field",
+			code:          "good-code",
+			wantEmail:     "abc@privaterelay.appleid.com",
+			wantVerified:  true,
+			wantAccountID: "001234.abcd",
+		},
+		{
+			name:          "user field email is not trusted",
+			code:          "good-code",
+			claims:        func(c map[string]any) { delete(c, "email"); delete(c, "email_verified") },
+			user:          `{"name":{"firstName":"Jane"},"email":"jane@example.com"}`,
+			wantName:      "Jane",
+			wantEmail:     "jane@example.com",
+			wantAccountID: "001234.abcd",
+		},
+		{
+			name:    "ID token for another client",
+			code:    "good-code",
+			claims:  func(c map[string]any) { c["aud"] = "com.other.web" },
+			wantErr: ErrInvalidIDToken,
+		},
+		{
+			name:    "expired ID token",
+			code:    "good-code",
+			claims:  func(c map[string]any) { c["exp"] = time.Now().Add(-time.Hour).Unix() },
+			wantErr: ErrInvalidIDToken,
+		},
+		{
+			name:       "rejected code",
+			code:       "bad-code",
+			wantAnyErr: true,
+		},
+		{
+			name:    "missing code",
+			wantErr: ErrMissingCode,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// Arrange
+			var secretClaims map[string]any
+			apple, idp := newTestApple(t, &secretClaims)
+			idp.idTokenClaims = validClaims()
+			if test.claims != nil {
+				test.claims(idp.idTokenClaims)
+			}
+			callback := core.OAuthCallback{
+				Code:        test.code,
+				RedirectURI: "https://app.example/api/auth/callback/apple",
+				Params:      map[string]string{"code": test.code, "user": test.user},
+			}
+
+			// Act
+			identity, err := apple.Exchange(callback)
+
+			// Assert
+			if test.wantErr != nil || test.wantAnyErr {
+				if err == nil || (test.wantErr != nil && !errors.Is(err, test.wantErr)) {
+					t.Fatalf("Exchange() error = %v, want %v", err, test.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Exchange() error = %v", err)
+			}
+			if identity.AccountID != test.wantAccountID || identity.Name != test.wantName ||
+				identity.Email != test.wantEmail || identity.EmailVerified != test.wantVerified {
+				t.Errorf("Exchange() = %+v", identity)
+			}
+			if identity.AccessToken != "access" || identity.RefreshToken != "refresh" || identity.ExpiresAt == nil {
+				t.Errorf("tokens not returned: %+v", identity)
+			}
+			if secretClaims["iss"] != "TEAM123" || secretClaims["sub"] != "com.example.web" || secretClaims["aud"] != appleIssuer {
+				t.Errorf("client secret claims = %v", secretClaims)
+			}
+		})
+	}
+}
+
+// Requirement: The authorization URL asks for the form_post response mode
+// and the name and email scopes, with the nonce derived from the PKCE
+// challenge Apple itself doesn't take.
+func TestApple_AuthCodeURL(t *testing.T) {
+	// Arrange
+	apple, _ := newTestApple(t, nil)
+
+	// Act
+	raw := apple.AuthCodeURL("state-1", "https://app.example/api/auth/callback/apple", "challenge")
+
+	// Assert
+	u, err := url.Parse(raw)
+	if err != nil {
+		t.Fatalf("AuthCodeURL() = %q: %v", raw, err)
+	}
+	query := u.Query()
+	want := map[string]string{
+		"client_id":     "com.example.web",
+		"response_type": "code",
+		"response_mode": "form_post",
+		"scope":         "name email",
+		"state":         "state-1",
+		"redirect_uri":  "https://app.example/api/auth/callback/apple",
+		"nonce":         challengeNonce("challenge"),
+	}
+	for key, value := range want {
+		if query.Get(key) != value {
+			t.Errorf("%s = %q, want %q", key, query.Get(key), value)
+		}
+	}
+	if u.Host != "appleid.apple.com" {
+		t.Errorf("host = %q, want appleid.apple.com", u.Host)
+	}
+}
+
+// Requirement: NewApple fails on a private key that isn't a PKCS #8 ECDSA
+// key, instead of at the first sign-in.
+func TestNewApple_InvalidKey(t *testing.T) {
+	tests := []struct {
+		name string
+		key  []byte
+	}{
+		{name: "not PEM", key: []byte("not a key")},
+		{name: "garbage DER", key: pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: []byte{1, 2, 3}})},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// Act
+			_, err := NewApple(AppleConfig{ClientID: "c", TeamID: "t", KeyID: "k", PrivateKey: test.key})
+
+			// Assert
+			if !errors.Is(err, ErrInvalidPrivateKey) {
+				t.Errorf("NewApple() error = %v, want %v", err, ErrInvalidPrivateKey)
+			}
+		})
+	}
+}
+
+// Requirement: Apple revokes tokens at its revocation endpoint with the
+// generated client secret, and reports the endpoint's refusals.
+func TestApple_RevokeToken(t *testing.T) {
+	tests := []struct {
+		name    string
+		fails   bool
+		wantErr bool
+	}{
+		{name: "revoked"},
+		{name: "refused", fails: true, wantErr: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// Arrange
+			apple, idp := newTestApple(t, nil)
+			idp.revokeFails = test.fails
+
+			// Act
+			err := apple.RevokeToken("refresh-1", "refresh_token")
+
+			// Assert
+			if (err != nil) != test.wantErr {
+				t.Fatalf("RevokeToken() error = %v, wantErr %v", err, test.wantErr)
+			}
+			form := idp.revokeForm
+			if form.Get("token") != "refresh-1" || form.Get("token_type_hint") != "refresh_token" ||
+				form.Get("client_id") != "com.example.web" || form.Get("client_secret") == "" {
+				t.Errorf("revocation request = %v", form)
+			}
+		})
+	}
+}
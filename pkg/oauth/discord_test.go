@@ -0,0 +1,222 @@
+package oauth
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/lborres/kuta/core"
+)
+
+// newFakeDiscord serves Discord's token, user, guilds and guild member
+// endpoints for the access token "access", and its revocation endpoint for
+// the same token
+func newFakeDiscord(t *testing.T, user map[string]any) *httptest.Server {
+	t.Helper()
+	write := func(w http.ResponseWriter, v any) { _ = json.NewEncoder(w).Encode(v) }
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/oauth2/token/revoke" {
+			_ = r.ParseForm()
+			if r.PostForm.Get("token") != "access" || r.PostForm.Get("client_secret") != "secret" {
+				w.WriteHeader(http.StatusUnauthorized)
+				write(w, map[string]string{"error": "invalid_client"})
+			}
+			return
+		}
+		if r.URL.Path == "/oauth2/token" {
+			_ = r.ParseForm()
+			if r.PostForm.Get("code") != "good-code" {
+				w.WriteHeader(http.StatusBadRequest)
+				write(w, map[string]string{"error": "invalid_grant"})
+				return
+			}
+			write(w, map[string]any{"access_token": "access", "refresh_token": "refresh", "expires_in": 604800})
+			return
+		}
+		if r.Header.Get("Authorization") != "Bearer access" {
+			w.WriteHeader(http.StatusUnauthorized)
+			write(w, map[string]string{"message": "401: Unauthorized"})
+			return
+		}
+		switch r.URL.Path {
+		case "/users/@me":
+			write(w, user)
+		case "/users/@me/guilds":
+			write(w, []map[string]string{{"id": "guild-1", "name": "Makers"}, {"id": "guild-2", "name": "Gamers"}})
+		case "/users/@me/guilds/guild-1/member":
+			write(w, map[string]any{"roles": []string{"role-admin", "role-member"}})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+// Requirement: Discord reports the user's profile and guilds, with roles only
+// for RoleGuilds, and the email as verified only when Discord verified it.
+func TestDiscord_Exchange(t *testing.T) {
+	tests := []struct {
+		name         string
+		user         map[string]any
+		roleGuilds   []string
+		code         string
+		wantErr      bool
+		wantName     string
+		wantImage    string
+		wantVerified bool
+		wantRoles    []string
+	}{
+		{
+			name:         "verified user with roles",
+			user:         map[string]any{"id": "80351110224678912", "username": "nelly", "global_name": "Nelly", "avatar": "8342729096ea3675442027381ff50dfe", "email": "nelly@example.com", "verified": true},
+			roleGuilds:   []string{"guild-1"},
+			code:         "good-code",
+			wantName:     "Nelly",
+			wantImage:    discordCDNURL + "/avatars/80351110224678912/8342729096ea3675442027381ff50dfe.png",
+			wantVerified: true,
+			wantRoles:    []string{"role-admin", "role-member"},
+		},
+		{
+			name:     "unverified user without display name",
+			user:     map[string]any{"id": "80351110224678912", "username": "nelly", "email": "nelly@example.com", "verified": false},
+			code:     "good-code",
+			wantName: "nelly",
+		},
+		{
+			name:    "rejected code",
+			user:    map[string]any{"id": "80351110224678912"},
+			code:    "bad-code",
+			wantErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// Arrange
+			server := newFakeDiscord(t, test.user)
+			provider, err := NewDiscord(DiscordConfig{ClientID: "client", ClientSecret: "secret", RoleGuilds: test.roleGuilds, APIURL: server.URL})
+			if err != nil {
+				t.Fatalf("NewDiscord() error = %v", err)
+			}
+
+			// Act
+			identity, err := provider.Exchange(core.OAuthCallback{Code: test.code, RedirectURI: "https://app.example/cb"})
+
+			// Assert
+			if test.wantErr {
+				if err == nil {
+					t.Fatal("Exchange() error = nil, want error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Exchange() error = %v", err)
+			}
+			if identity.AccountID != "80351110224678912" || identity.Name != test.wantName ||
+				identity.Image != test.wantImage || identity.EmailVerified != test.wantVerified {
+				t.Errorf("Exchange() = %+v", identity)
+			}
+			if len(identity.Memberships) != 2 {
+				t.Fatalf("Memberships = %+v, want 2 guilds", identity.Memberships)
+			}
+			if got := identity.Membership("guild-1"); got == nil || got.Name != "Makers" || len(got.Roles) != len(test.wantRoles) {
+				t.Errorf("Membership(guild-1) = %+v, want roles %v", got, test.wantRoles)
+			}
+			if got := identity.Membership("guild-2"); got == nil || got.Roles != nil {
+				t.Errorf("Membership(guild-2) = %+v, want no roles", got)
+			}
+		})
+	}
+}
+
+// Requirement: Discord asks for guild member access only when roles are
+// needed.
+func TestDiscord_AuthCodeURL(t *testing.T) {
+	tests := []struct {
+		name       string
+		roleGuilds []string
+		wantScope  string
+	}{
+		{name: "guilds only", wantScope: "identify email guilds"},
+		{name: "with roles", roleGuilds: []string{"guild-1"}, wantScope: "identify email guilds guilds.members.read"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// Arrange
+			provider, err := NewDiscord(DiscordConfig{ClientID: "client", ClientSecret: "secret", RoleGuilds: test.roleGuilds})
+			if err != nil {
+				t.Fatalf("NewDiscord() error = %v", err)
+			}
+
+			// Act
+			authURL, err := url.Parse(provider.AuthCodeURL("state-1", "https://app.example/cb", ""))
+
+			// Assert
+			if err != nil {
+				t.Fatalf("AuthCodeURL() unparsable: %v", err)
+			}
+			query := authURL.Query()
+			if query.Get("scope") != test.wantScope || query.Get("state") != "state-1" || query.Get("redirect_uri") != "https://app.example/cb" {
+				t.Errorf("AuthCodeURL() query = %v", query)
+			}
+		})
+	}
+}
+
+// Requirement: Discord fails the sign-in when the API refuses the token, so
+// membership checks never run on a partial identity.
+func TestDiscord_Exchange_APIError(t *testing.T) {
+	// Arrange
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/oauth2/token" {
+			_ = json.NewEncoder(w).Encode(map[string]any{"access_token": "revoked"})
+			return
+		}
+		w.WriteHeader(http.StatusUnauthorized)
+		_ = json.NewEncoder(w).Encode(map[string]string{"message": "401: Unauthorized"})
+	}))
+	defer server.Close()
+	provider, _ := NewDiscord(DiscordConfig{ClientID: "client", ClientSecret: "secret", APIURL: server.URL})
+
+	// Act
+	_, err := provider.Exchange(core.OAuthCallback{Code: "good-code"})
+
+	// Assert
+	if err == nil || errors.Is(err, ErrMissingCode) {
+		t.Errorf("Exchange() error = %v, want API error", err)
+	}
+}
+
+// Requirement: Discord revokes tokens with the app's credentials and
+// reports refusals.
+func TestDiscord_RevokeToken(t *testing.T) {
+	tests := []struct {
+		name    string
+		token   string
+		wantErr bool
+	}{
+		{name: "revoked", token: "access"},
+		{name: "refused", token: "unknown", wantErr: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// Arrange
+			server := newFakeDiscord(t, nil)
+			provider, _ := NewDiscord(DiscordConfig{ClientID: "client", ClientSecret: "secret", APIURL: server.URL})
+
+			// Act
+			err := provider.RevokeToken(test.token, "access_token")
+
+			// Assert
+			if (err != nil) != test.wantErr {
+				t.Errorf("RevokeToken() error = %v, wantErr %v", err, test.wantErr)
+			}
+		})
+	}
+}
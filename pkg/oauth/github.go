@@ -0,0 +1,236 @@
+package oauth
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/lborres/kuta/core"
+)
+
+const (
+	GitHubProviderID = "github"
+
+	githubAuthURL  = "https://github.com/login/oauth/authorize"
+	githubTokenURL = "https://github.com/login/oauth/access_token"
+	githubAPIURL   = "https://api.github.com"
+)
+
+// GitHubConfig configures sign-in with GitHub, through an OAuth app or a
+// GitHub App
+type GitHubConfig struct {
+	ClientID     string
+	ClientSecret string
+	// Scopes defaults to "read:user" and "user:email". Add "read:org" to
+	// report the user's organizations as memberships. GitHub Apps ignore
+	// scopes and use the app's permissions instead.
+	Scopes []string
+
+	// HTTPClient calls GitHub. Defaults to a client with a 10 second
+	// timeout.
+	HTTPClient *http.Client
+	// AuthURL, TokenURL and APIURL override GitHub's endpoints, for GitHub
+	// Enterprise Server or tests
+	AuthURL  string
+	TokenURL string
+	APIURL   string
+}
+
+// GitHub signs users in with their GitHub account. GitHub has no ID token,
+// so the user comes from the REST API: the profile from /user, and the
+// email from /user/emails, where GitHub says whether it was verified. The
+// profile's public email is used, unverified, when the email scope wasn't
+// granted.
+type GitHub struct {
+	config GitHubConfig
+	client *http.Client
+}
+
+var (
+	_ core.OAuthProvider     = (*GitHub)(nil)
+	_ core.OAuthTokenRevoker = (*GitHub)(nil)
+	_ core.TokenRefresher    = (*GitHub)(nil)
+)
+
+// NewGitHub creates the GitHub provider
+func NewGitHub(config GitHubConfig) (*GitHub, error) {
+	if config.ClientID == "" || config.ClientSecret == "" {
+		return nil, errors.New("github: ClientID and ClientSecret are required")
+	}
+	if len(config.Scopes) == 0 {
+		config.Scopes = []string{"read:user", "user:email"}
+	}
+	if config.AuthURL == "" {
+		config.AuthURL = githubAuthURL
+	}
+	if config.TokenURL == "" {
+		config.TokenURL = githubTokenURL
+	}
+	config.APIURL = strings.TrimSuffix(config.APIURL, "/")
+	if config.APIURL == "" {
+		config.APIURL = githubAPIURL
+	}
+	return &GitHub{config: config, client: defaultHTTPClient(config.HTTPClient)}, nil
+}
+
+func (g *GitHub) ID() string {
+	return GitHubProviderID
+}
+
+func (g *GitHub) AuthCodeURL(state, redirectURI, codeChallenge string) string {
+	query := url.Values{
+		"client_id":    {g.config.ClientID},
+		"redirect_uri": {redirectURI},
+		"scope":        {strings.Join(g.config.Scopes, " ")},
+		"state":        {state},
+	}
+	setCodeChallenge(query, codeChallenge)
+	return g.config.AuthURL + "?" + query.Encode()
+}
+
+// githubUser is the /user response
+type githubUser struct {
+	ID        int64  `json:"id"`
+	Login     string `json:"login"`
+	Name      string `json:"name"`
+	Email     string `json:"email"`
+	AvatarURL string `json:"avatar_url"`
+}
+
+// githubEmail is an entry of the /user/emails response
+type githubEmail struct {
+	Email    string `json:"email"`
+	Primary  bool   `json:"primary"`
+	Verified bool   `json:"verified"`
+}
+
+type githubOrg struct {
+	ID    int64  `json:"id"`
+	Login string `json:"login"`
+}
+
+func (g *GitHub) Exchange(callback core.OAuthCallback) (*core.OAuthIdentity, error) {
+	if callback.Code == "" {
+		return nil, ErrMissingCode
+	}
+
+	form := url.Values{
+		"client_id":     {g.config.ClientID},
+		"client_secret": {g.config.ClientSecret},
+		"code":          {callback.Code},
+		"redirect_uri":  {callback.RedirectURI},
+	}
+	setCodeVerifier(form, callback.CodeVerifier)
+	token, err := exchangeCode(g.client, g.config.TokenURL, form)
+	if err != nil {
+		return nil, err
+	}
+
+	var profile map[string]any
+	if err := getJSON(g.client, g.config.APIURL+"/user", token.AccessToken, &profile); err != nil {
+		return nil, err
+	}
+	var user githubUser
+	if err := remarshal(profile, &user); err != nil {
+		return nil, err
+	}
+	if user.ID == 0 {
+		return nil, errors.New("github: /user returned no user ID")
+	}
+
+	identity := &core.OAuthIdentity{
+		AccountID:    strconv.FormatInt(user.ID, 10),
+		Email:        user.Email,
+		Name:         user.Name,
+		Image:        user.AvatarURL,
+		AccessToken:  token.AccessToken,
+		RefreshToken: token.RefreshToken,
+		ExpiresAt:    token.expiresAt(),
+		Profile:      profile,
+	}
+	if identity.Name == "" {
+		identity.Name = user.Login
+	}
+
+	if g.granted(token, "user:email", "user") {
+		var emails []githubEmail
+		if err := getJSON(g.client, g.config.APIURL+"/user/emails", token.AccessToken, &emails); err != nil {
+			return nil, err
+		}
+		for _, email := range emails {
+			if email.Primary {
+				identity.Email = email.Email
+				identity.EmailVerified = email.Verified
+			}
+		}
+	}
+
+	if g.granted(token, "read:org") {
+		var orgs []githubOrg
+		if err := getJSON(g.client, g.config.APIURL+"/user/orgs", token.AccessToken, &orgs); err != nil {
+			return nil, err
+		}
+		for _, org := range orgs {
+			identity.Memberships = append(identity.Memberships, core.OAuthMembership{ID: strconv.FormatInt(org.ID, 10), Name: org.Login})
+		}
+	}
+
+	return identity, nil
+}
+
+// granted reports whether the user granted one of scopes. The token
+// response lists what was granted, which the user may have narrowed.
+func (g *GitHub) granted(token *tokenResponse, scopes ...string) bool {
+	granted := strings.Split(token.Scope, ",")
+	if token.Scope == "" {
+		granted = g.config.Scopes
+	}
+	return slices.ContainsFunc(scopes, func(scope string) bool { return slices.Contains(granted, scope) })
+}
+
+// RefreshToken exchanges a refresh token for new tokens. Only GitHub Apps
+// with expiring user tokens issue refresh tokens; GitHub rotates them on use.
+func (g *GitHub) RefreshToken(refreshToken string) (*core.ProviderTokens, error) {
+	token, err := exchangeCode(g.client, g.config.TokenURL, url.Values{
+		"client_id":     {g.config.ClientID},
+		"client_secret": {g.config.ClientSecret},
+		"grant_type":    {"refresh_token"},
+		"refresh_token": {refreshToken},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return providerTokens(token), nil
+}
+
+// RevokeToken deletes the user's authorization of the app, revoking all of
+// its tokens. GitHub has no RFC 7009 endpoint; the grant is deleted through
+// the REST API with the app's credentials.
+func (g *GitHub) RevokeToken(token, _ string) error {
+	body, err := json.Marshal(map[string]string{"access_token": token})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodDelete, g.config.APIURL+"/applications/"+url.PathEscape(g.config.ClientID)+"/grant", strings.NewReader(string(body)))
+	if err != nil {
+		return err
+	}
+	req.SetBasicAuth(g.config.ClientID, g.config.ClientSecret)
+	req.Header.Set("Accept", "application/vnd.github+json")
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := g.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent {
+		return fmt.Errorf("github: grant deletion returned %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,204 @@
+package oauth
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/lborres/kuta/core"
+)
+
+// newFakeGitHub serves GitHub's token endpoint, granting scope, and the
+// user, emails and orgs endpoints for the access token "access". Deleting
+// the grant succeeds for the app's credentials and that token.
+func newFakeGitHub(t *testing.T, user map[string]any, scope string) *httptest.Server {
+	t.Helper()
+	write := func(w http.ResponseWriter, v any) { _ = json.NewEncoder(w).Encode(v) }
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/login/oauth/access_token" {
+			_ = r.ParseForm()
+			if r.PostForm.Get("code") != "good-code" {
+				// GitHub reports errors with a 200
+				write(w, map[string]string{"error": "bad_verification_code"})
+				return
+			}
+			write(w, map[string]any{"access_token": "access", "token_type": "bearer", "scope": scope})
+			return
+		}
+		if r.URL.Path == "/applications/client/grant" && r.Method == http.MethodDelete {
+			var body map[string]string
+			_ = json.NewDecoder(r.Body).Decode(&body)
+			if clientID, secret, _ := r.BasicAuth(); clientID != "client" || secret != "secret" || body["access_token"] != "access" {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		if r.Header.Get("Authorization") != "Bearer access" {
+			w.WriteHeader(http.StatusUnauthorized)
+			write(w, map[string]string{"message": "Bad credentials"})
+			return
+		}
+		switch r.URL.Path {
+		case "/user":
+			write(w, user)
+		case "/user/emails":
+			write(w, []map[string]any{
+				{"email": "octo@users.noreply.github.com", "primary": false, "verified": true},
+				{"email": "octo@example.com", "primary": true, "verified": true},
+			})
+		case "/user/orgs":
+			write(w, []map[string]any{{"id": 9919, "login": "github"}})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+// Requirement: GitHub reports the user's profile with their primary email,
+// verified as GitHub says, when the email scope was granted, and the public
+// profile email unverified otherwise; organizations are reported as
+// memberships with read:org.
+func TestGitHub_Exchange(t *testing.T) {
+	tests := []struct {
+		name            string
+		user            map[string]any
+		scopes          []string
+		grantedScope    string
+		code            string
+		wantErr         bool
+		wantName        string
+		wantEmail       string
+		wantVerified    bool
+		wantMemberships int
+	}{
+		{
+			name:         "primary email",
+			user:         map[string]any{"id": 583231, "login": "octocat", "name": "The Octocat", "avatar_url": "https://avatars.githubusercontent.com/u/583231"},
+			grantedScope: "read:user,user:email",
+			code:         "good-code",
+			wantName:     "The Octocat",
+			wantEmail:    "octo@example.com",
+			wantVerified: true,
+		},
+		{
+			name:         "email scope declined",
+			user:         map[string]any{"id": 583231, "login": "octocat", "email": "public@example.com"},
+			grantedScope: "read:user",
+			code:         "good-code",
+			wantName:     "octocat",
+			wantEmail:    "public@example.com",
+		},
+		{
+			name:            "organizations",
+			user:            map[string]any{"id": 583231, "login": "octocat"},
+			scopes:          []string{"read:user", "user:email", "read:org"},
+			grantedScope:    "read:org,read:user,user:email",
+			code:            "good-code",
+			wantName:        "octocat",
+			wantEmail:       "octo@example.com",
+			wantVerified:    true,
+			wantMemberships: 1,
+		},
+		{
+			name:    "rejected code",
+			user:    map[string]any{"id": 583231},
+			code:    "bad-code",
+			wantErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// Arrange
+			server := newFakeGitHub(t, test.user, test.grantedScope)
+			provider, err := NewGitHub(GitHubConfig{
+				ClientID:     "client",
+				ClientSecret: "secret",
+				Scopes:       test.scopes,
+				TokenURL:     server.URL + "/login/oauth/access_token",
+				APIURL:       server.URL,
+			})
+			if err != nil {
+				t.Fatalf("NewGitHub() error = %v", err)
+			}
+
+			// Act
+			identity, err := provider.Exchange(core.OAuthCallback{Code: test.code, RedirectURI: "https://app.example/cb"})
+
+			// Assert
+			if test.wantErr {
+				if err == nil {
+					t.Fatal("Exchange() error = nil, want error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Exchange() error = %v", err)
+			}
+			if identity.AccountID != "583231" || identity.Name != test.wantName ||
+				identity.Email != test.wantEmail || identity.EmailVerified != test.wantVerified {
+				t.Errorf("Exchange() = %+v", identity)
+			}
+			if len(identity.Memberships) != test.wantMemberships {
+				t.Errorf("Memberships = %+v, want %d", identity.Memberships, test.wantMemberships)
+			}
+		})
+	}
+}
+
+// Requirement: GitHub's authorization URL asks for the configured scopes
+// and carries the PKCE challenge.
+func TestGitHub_AuthCodeURL(t *testing.T) {
+	// Arrange
+	provider, err := NewGitHub(GitHubConfig{ClientID: "client", ClientSecret: "secret"})
+	if err != nil {
+		t.Fatalf("NewGitHub() error = %v", err)
+	}
+
+	// Act
+	authURL, err := url.Parse(provider.AuthCodeURL("state-1", "https://app.example/cb", "challenge"))
+
+	// Assert
+	if err != nil {
+		t.Fatalf("AuthCodeURL() unparsable: %v", err)
+	}
+	query := authURL.Query()
+	if query.Get("scope") != "read:user user:email" || query.Get("state") != "state-1" || query.Get("code_challenge") != "challenge" {
+		t.Errorf("AuthCodeURL() query = %v", query)
+	}
+}
+
+// Requirement: GitHub revokes by deleting the app's grant with the app's
+// credentials, and reports refusals.
+func TestGitHub_RevokeToken(t *testing.T) {
+	tests := []struct {
+		name    string
+		token   string
+		wantErr bool
+	}{
+		{name: "revoked", token: "access"},
+		{name: "refused", token: "unknown", wantErr: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// Arrange
+			server := newFakeGitHub(t, nil, "")
+			provider, _ := NewGitHub(GitHubConfig{ClientID: "client", ClientSecret: "secret", APIURL: server.URL})
+
+			// Act
+			err := provider.RevokeToken(test.token, "access_token")
+
+			// Assert
+			if (err != nil) != test.wantErr {
+				t.Errorf("RevokeToken() error = %v, wantErr %v", err, test.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,212 @@
+package oauth
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/lborres/kuta/core"
+)
+
+const (
+	GoogleProviderID = "google"
+
+	googleAuthURL   = "https://accounts.google.com/o/oauth2/v2/auth"
+	googleTokenURL  = "https://oauth2.googleapis.com/token"
+	googleKeysURL   = "https://www.googleapis.com/oauth2/v3/certs"
+	googleRevokeURL = "https://oauth2.googleapis.com/revoke"
+)
+
+// googleIssuers are the issuers Google's ID tokens carry, with and without
+// the scheme
+var googleIssuers = []string{"https://accounts.google.com", "accounts.google.com"}
+
+// ErrDomainNotAllowed is returned for Google users outside HostedDomain
+var ErrDomainNotAllowed = errors.New("domain not allowed")
+
+// GoogleConfig configures sign-in with Google
+type GoogleConfig struct {
+	// ClientID and ClientSecret are the OAuth client's credentials from the
+	// Google Cloud console
+	ClientID     string
+	ClientSecret string
+	// Scopes defaults to "openid", "email" and "profile"
+	Scopes []string
+	// HostedDomain restricts sign-in to users of a Google Workspace domain,
+	// e.g. "example.com"
+	HostedDomain string
+	// Offline asks for a refresh token, for apps that call Google APIs on
+	// the user's behalf. Google only sends one on the user's first consent.
+	Offline bool
+
+	// HTTPClient calls Google's endpoints. Defaults to a client with a 10
+	// second timeout.
+	HTTPClient *http.Client
+	// AuthURL, TokenURL, KeysURL and RevokeURL override Google's endpoints,
+	// for tests
+	AuthURL   string
+	TokenURL  string
+	KeysURL   string
+	RevokeURL string
+}
+
+// Google signs users in with their Google account through OpenID Connect.
+// The user is read from the ID token; the hd claim names the Workspace
+// domain of managed accounts, which HostedDomain checks. It also refreshes
+// and revokes the tokens it obtained, so it can be registered as the
+// "google" refresher in Config.ProviderTokenRefreshers.
+type Google struct {
+	config GoogleConfig
+	client *http.Client
+	keys   *keySet
+}
+
+var (
+	_ core.OAuthProvider     = (*Google)(nil)
+	_ core.OAuthTokenRevoker = (*Google)(nil)
+	_ core.TokenRefresher    = (*Google)(nil)
+)
+
+// NewGoogle creates the Google provider
+func NewGoogle(config GoogleConfig) (*Google, error) {
+	if config.ClientID == "" || config.ClientSecret == "" {
+		return nil, errors.New("google: ClientID and ClientSecret are required")
+	}
+	if len(config.Scopes) == 0 {
+		config.Scopes = []string{"openid", "email", "profile"}
+	}
+	if config.AuthURL == "" {
+		config.AuthURL = googleAuthURL
+	}
+	if config.TokenURL == "" {
+		config.TokenURL = googleTokenURL
+	}
+	if config.KeysURL == "" {
+		config.KeysURL = googleKeysURL
+	}
+	if config.RevokeURL == "" {
+		config.RevokeURL = googleRevokeURL
+	}
+
+	client := defaultHTTPClient(config.HTTPClient)
+	return &Google{
+		config: config,
+		client: client,
+		keys:   newKeySet(config.KeysURL, client),
+	}, nil
+}
+
+func (g *Google) ID() string {
+	return GoogleProviderID
+}
+
+// AuthCodeURL passes HostedDomain on as the hd parameter, which only
+// narrows Google's account chooser; the ID token's hd claim is what is
+// checked
+func (g *Google) AuthCodeURL(state, redirectURI, codeChallenge string) string {
+	query := url.Values{
+		"client_id":     {g.config.ClientID},
+		"redirect_uri":  {redirectURI},
+		"response_type": {"code"},
+		"scope":         {strings.Join(g.config.Scopes, " ")},
+		"state":         {state},
+	}
+	if g.config.HostedDomain != "" {
+		query.Set("hd", g.config.HostedDomain)
+	}
+	if g.config.Offline {
+		query.Set("access_type", "offline")
+	}
+	setCodeChallenge(query, codeChallenge)
+	setNonce(query, codeChallenge)
+	return g.config.AuthURL + "?" + query.Encode()
+}
+
+func (g *Google) Exchange(callback core.OAuthCallback) (*core.OAuthIdentity, error) {
+	if callback.Code == "" {
+		return nil, ErrMissingCode
+	}
+
+	form := url.Values{
+		"client_id":     {g.config.ClientID},
+		"client_secret": {g.config.ClientSecret},
+		"code":          {callback.Code},
+		"grant_type":    {"authorization_code"},
+		"redirect_uri":  {callback.RedirectURI},
+	}
+	setCodeVerifier(form, callback.CodeVerifier)
+	token, err := exchangeCode(g.client, g.config.TokenURL, form)
+	if err != nil {
+		return nil, err
+	}
+	if token.IDToken == "" {
+		return nil, errors.New("google: token response has no ID token, is the openid scope requested?")
+	}
+
+	claims, err := verifyIDToken(token.IDToken, g.keys, g.config.ClientID, flowNonce(callback.CodeVerifier), g.checkIssuer)
+	if err != nil {
+		return nil, err
+	}
+	if g.config.HostedDomain != "" {
+		if domain, _ := claims.raw["hd"].(string); !strings.EqualFold(domain, g.config.HostedDomain) {
+			return nil, fmt.Errorf("%w: %q", ErrDomainNotAllowed, domain)
+		}
+	}
+
+	picture, _ := claims.raw["picture"].(string)
+	return &core.OAuthIdentity{
+		AccountID:     claims.Subject,
+		Email:         claims.Email,
+		EmailVerified: claims.Email != "" && bool(claims.EmailVerified),
+		Name:          claims.Name,
+		Image:         picture,
+		AccessToken:   token.AccessToken,
+		RefreshToken:  token.RefreshToken,
+		ExpiresAt:     token.expiresAt(),
+		Profile:       claims.raw,
+	}, nil
+}
+
+func (g *Google) checkIssuer(claims *idTokenClaims) error {
+	for _, issuer := range googleIssuers {
+		if claims.Issuer == issuer {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: issuer %q", ErrInvalidIDToken, claims.Issuer)
+}
+
+// RefreshToken exchanges a refresh token for a new access token. Google
+// keeps the refresh token valid, so none comes back.
+func (g *Google) RefreshToken(refreshToken string) (*core.ProviderTokens, error) {
+	token, err := exchangeCode(g.client, g.config.TokenURL, url.Values{
+		"client_id":     {g.config.ClientID},
+		"client_secret": {g.config.ClientSecret},
+		"grant_type":    {"refresh_token"},
+		"refresh_token": {refreshToken},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return providerTokens(token), nil
+}
+
+// RevokeToken revokes the token along with the grant it belongs to
+func (g *Google) RevokeToken(token, _ string) error {
+	return revokeToken(g.client, g.config.RevokeURL, url.Values{"token": {token}})
+}
+
+// providerTokens converts a refresh response, dating tokens without an
+// expiry a day out so they are refreshed eventually
+func providerTokens(token *tokenResponse) *core.ProviderTokens {
+	tokens := &core.ProviderTokens{AccessToken: token.AccessToken, RefreshToken: token.RefreshToken}
+	if expiresAt := token.expiresAt(); expiresAt != nil {
+		tokens.ExpiresAt = *expiresAt
+	} else {
+		tokens.ExpiresAt = time.Now().Add(24 * time.Hour)
+	}
+	return tokens
+}
@@ -0,0 +1,223 @@
+package oauth
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/lborres/kuta/core"
+)
+
+// newTestGoogle returns a Google provider talking to idp
+func newTestGoogle(t *testing.T, idp *fakeIdP, hostedDomain string) *Google {
+	t.Helper()
+	provider, err := NewGoogle(GoogleConfig{
+		ClientID:     "client-id",
+		ClientSecret: "secret",
+		HostedDomain: hostedDomain,
+		TokenURL:     idp.server.URL + "/token",
+		KeysURL:      idp.server.URL + "/keys",
+		RevokeURL:    idp.server.URL + "/revoke",
+	})
+	if err != nil {
+		t.Fatalf("NewGoogle() error = %v", err)
+	}
+	return provider
+}
+
+// Requirement: Google reads the user from a verified ID token, accepts
+// both issuer spellings, reports the email as verified only when Google
+// says so, and with HostedDomain only admits users of that domain.
+func TestGoogle_Exchange(t *testing.T) {
+	tests := []struct {
+		name         string
+		hostedDomain string
+		claims       func(c map[string]any)
+		wantErr      error
+		wantVerified bool
+	}{
+		{
+			name:         "verified user",
+			wantVerified: true,
+		},
+		{
+			name:         "issuer without scheme",
+			claims:       func(c map[string]any) { c["iss"] = "accounts.google.com" },
+			wantVerified: true,
+		},
+		{
+			name:   "unverified email",
+			claims: func(c map[string]any) { c["email_verified"] = false },
+		},
+		{
+			name:         "hosted domain user",
+			hostedDomain: "example.com",
+			claims:       func(c map[string]any) { c["hd"] = "example.com" },
+			wantVerified: true,
+		},
+		{
+			name:         "consumer account with hosted domain",
+			hostedDomain: "example.com",
+			wantErr:      ErrDomainNotAllowed,
+		},
+		{
+			name:    "other issuer",
+			claims:  func(c map[string]any) { c["iss"] = "https://evil.example" },
+			wantErr: ErrInvalidIDToken,
+		},
+		{
+			name:    "nonce of another sign-in",
+			claims:  func(c map[string]any) { c["nonce"] = flowNonce("other-verifier") },
+			wantErr: ErrInvalidIDToken,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// Arrange
+			idp := newFakeIdP(t)
+			idp.idTokenClaims = map[string]any{
+				"iss":            "https://accounts.google.com",
+				"aud":            "client-id",
+				"sub":            "110169484474386276334",
+				"exp":            time.Now().Add(time.Hour).Unix(),
+				"iat":            time.Now().Unix(),
+				"email":          "ada@example.com",
+				"email_verified": true,
+				"name":           "Ada Lovelace",
+				"picture":        "https://lh3.googleusercontent.com/a/photo",
+				"nonce":          flowNonce("verifier"),
+			}
+			if test.claims != nil {
+				test.claims(idp.idTokenClaims)
+			}
+			provider := newTestGoogle(t, idp, test.hostedDomain)
+
+			// Act
+			identity, err := provider.Exchange(core.OAuthCallback{Code: "good-code", RedirectURI: "https://app.example/cb", CodeVerifier: "verifier"})
+
+			// Assert
+			if test.wantErr != nil {
+				if !errors.Is(err, test.wantErr) {
+					t.Fatalf("Exchange() error = %v, want %v", err, test.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Exchange() error = %v", err)
+			}
+			if identity.AccountID != "110169484474386276334" || identity.Name != "Ada Lovelace" ||
+				identity.Image != "https://lh3.googleusercontent.com/a/photo" || identity.EmailVerified != test.wantVerified {
+				t.Errorf("Exchange() = %+v", identity)
+			}
+			if identity.AccessToken != "access" || identity.RefreshToken != "refresh" || identity.ExpiresAt == nil {
+				t.Errorf("Exchange() tokens = %q, %q, %v", identity.AccessToken, identity.RefreshToken, identity.ExpiresAt)
+			}
+			if idp.tokenForm.Get("code_verifier") != "verifier" || idp.tokenForm.Get("client_secret") != "secret" {
+				t.Errorf("token request = %v", idp.tokenForm)
+			}
+		})
+	}
+}
+
+// Requirement: Google's authorization URL carries the PKCE challenge, and
+// the hosted domain and offline access when configured.
+func TestGoogle_AuthCodeURL(t *testing.T) {
+	tests := []struct {
+		name           string
+		config         GoogleConfig
+		wantHD         string
+		wantAccessType string
+	}{
+		{name: "defaults"},
+		{name: "hosted domain offline", config: GoogleConfig{HostedDomain: "example.com", Offline: true}, wantHD: "example.com", wantAccessType: "offline"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// Arrange
+			test.config.ClientID, test.config.ClientSecret = "client-id", "secret"
+			provider, err := NewGoogle(test.config)
+			if err != nil {
+				t.Fatalf("NewGoogle() error = %v", err)
+			}
+
+			// Act
+			authURL, err := url.Parse(provider.AuthCodeURL("state-1", "https://app.example/cb", "challenge"))
+
+			// Assert
+			if err != nil {
+				t.Fatalf("AuthCodeURL() unparsable: %v", err)
+			}
+			query := authURL.Query()
+			if query.Get("scope") != "openid email profile" || query.Get("code_challenge") != "challenge" ||
+				query.Get("hd") != test.wantHD || query.Get("access_type") != test.wantAccessType {
+				t.Errorf("AuthCodeURL() query = %v", query)
+			}
+		})
+	}
+}
+
+// Requirement: Google refreshes access tokens with the refresh token grant.
+func TestGoogle_RefreshToken(t *testing.T) {
+	// Arrange
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = r.ParseForm()
+		if r.PostForm.Get("grant_type") != "refresh_token" || r.PostForm.Get("refresh_token") != "refresh" {
+			w.WriteHeader(http.StatusBadRequest)
+			_ = json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{"access_token": "fresh", "expires_in": 3599})
+	}))
+	defer server.Close()
+	provider, _ := NewGoogle(GoogleConfig{ClientID: "client-id", ClientSecret: "secret", TokenURL: server.URL})
+
+	// Act
+	tokens, err := provider.RefreshToken("refresh")
+
+	// Assert
+	if err != nil {
+		t.Fatalf("RefreshToken() error = %v", err)
+	}
+	if tokens.AccessToken != "fresh" || tokens.RefreshToken != "" || time.Until(tokens.ExpiresAt) > time.Hour {
+		t.Errorf("RefreshToken() = %+v", tokens)
+	}
+}
+
+// Requirement: Google revokes tokens at its revocation endpoint and reports
+// refusals.
+func TestGoogle_RevokeToken(t *testing.T) {
+	tests := []struct {
+		name    string
+		fails   bool
+		wantErr bool
+	}{
+		{name: "revoked"},
+		{name: "refused", fails: true, wantErr: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// Arrange
+			idp := newFakeIdP(t)
+			idp.revokeFails = test.fails
+			provider := newTestGoogle(t, idp, "")
+
+			// Act
+			err := provider.RevokeToken("refresh", "refresh_token")
+
+			// Assert
+			if (err != nil) != test.wantErr {
+				t.Fatalf("RevokeToken() error = %v, wantErr %v", err, test.wantErr)
+			}
+			if idp.revokeForm.Get("token") != "refresh" {
+				t.Errorf("revocation request = %v", idp.revokeForm)
+			}
+		})
+	}
+}
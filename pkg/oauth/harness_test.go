@@ -0,0 +1,153 @@
+package oauth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+// fakeIdP serves a provider's token endpoint at any path ending in /token,
+// its key set at any path ending in /keys and its revocation endpoint at any
+// path ending in /revoke, and its discovery document and userinfo endpoint
+// for OpenID Connect. The token endpoint accepts requests checkToken
+// approves and answers with idTokenClaims signed by signingKey, or by
+// ecSigningKey after useES256. The
+// revocation endpoint records its form and fails when revokeFails is set.
+// The discovery document can be changed by editMetadata; userinfo answers
+// userInfo for the access token "access".
+type fakeIdP struct {
+	t             *testing.T
+	server        *httptest.Server
+	signingKey    *rsa.PrivateKey
+	ecSigningKey  *ecdsa.PrivateKey
+	idTokenClaims map[string]any
+	checkToken    func(form url.Values) bool
+	tokenForm     url.Values
+	revokeForm    url.Values
+	revokeFails   bool
+	editMetadata  func(metadata map[string]any)
+	userInfo      map[string]any
+}
+
+func newFakeIdP(t *testing.T) *fakeIdP {
+	t.Helper()
+	signingKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generate RSA key: %v", err)
+	}
+	f := &fakeIdP{t: t, signingKey: signingKey}
+
+	serveKeys := func(w http.ResponseWriter, r *http.Request) {
+		if f.ecSigningKey != nil {
+			point, _ := f.ecSigningKey.PublicKey.Bytes()
+			_ = json.NewEncoder(w).Encode(map[string]any{"keys": []map[string]string{{
+				"kty": "EC",
+				"kid": "test-key",
+				"crv": "P-256",
+				"x":   b64.EncodeToString(point[1:33]),
+				"y":   b64.EncodeToString(point[33:]),
+			}}})
+			return
+		}
+		pub := f.signingKey.PublicKey
+		_ = json.NewEncoder(w).Encode(map[string]any{"keys": []map[string]string{{
+			"kty": "RSA",
+			"kid": "test-key",
+			"n":   b64.EncodeToString(pub.N.Bytes()),
+			"e":   b64.EncodeToString(big.NewInt(int64(pub.E)).Bytes()),
+		}}})
+	}
+	serveToken := func(w http.ResponseWriter, r *http.Request) {
+		_ = r.ParseForm()
+		f.tokenForm = r.PostForm
+		if r.PostForm.Get("code") != "good-code" || (f.checkToken != nil && !f.checkToken(r.PostForm)) {
+			w.WriteHeader(http.StatusBadRequest)
+			_ = json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"access_token":  "access",
+			"refresh_token": "refresh",
+			"expires_in":    3600,
+			"id_token":      f.signIDToken(),
+		})
+	}
+	f.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/.well-known/openid-configuration":
+			metadata := map[string]any{
+				"issuer":                                f.server.URL,
+				"authorization_endpoint":                f.server.URL + "/authorize",
+				"token_endpoint":                        f.server.URL + "/token",
+				"userinfo_endpoint":                     f.server.URL + "/userinfo",
+				"jwks_uri":                              f.server.URL + "/keys",
+				"revocation_endpoint":                   f.server.URL + "/revoke",
+				"id_token_signing_alg_values_supported": []string{"RS256"},
+			}
+			if f.editMetadata != nil {
+				f.editMetadata(metadata)
+			}
+			_ = json.NewEncoder(w).Encode(metadata)
+		case r.URL.Path == "/userinfo":
+			if r.Header.Get("Authorization") != "Bearer access" {
+				w.WriteHeader(http.StatusUnauthorized)
+				_ = json.NewEncoder(w).Encode(map[string]string{"error": "invalid_token"})
+				return
+			}
+			_ = json.NewEncoder(w).Encode(f.userInfo)
+		case strings.HasSuffix(r.URL.Path, "/keys"):
+			serveKeys(w, r)
+		case strings.HasSuffix(r.URL.Path, "/token"):
+			serveToken(w, r)
+		case strings.HasSuffix(r.URL.Path, "/revoke"):
+			_ = r.ParseForm()
+			f.revokeForm = r.PostForm
+			if f.revokeFails {
+				w.WriteHeader(http.StatusBadRequest)
+				_ = json.NewEncoder(w).Encode(map[string]string{"error": "invalid_client"})
+			}
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(f.server.Close)
+	return f
+}
+
+// useES256 makes the IdP sign ID tokens with a P-256 key
+func (f *fakeIdP) useES256() {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		f.t.Fatalf("generate ECDSA key: %v", err)
+	}
+	f.ecSigningKey = key
+}
+
+func (f *fakeIdP) signIDToken() string {
+	if f.ecSigningKey != nil {
+		token, err := signES256(f.ecSigningKey, "test-key", f.idTokenClaims)
+		if err != nil {
+			f.t.Fatalf("sign ID token: %v", err)
+		}
+		return token
+	}
+	header, _ := json.Marshal(map[string]string{"alg": "RS256", "kid": "test-key"})
+	payload, _ := json.Marshal(f.idTokenClaims)
+	input := b64.EncodeToString(header) + "." + b64.EncodeToString(payload)
+	digest := sha256.Sum256([]byte(input))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, f.signingKey, crypto.SHA256, digest[:])
+	if err != nil {
+		f.t.Fatalf("sign ID token: %v", err)
+	}
+	return input + "." + b64.EncodeToString(signature)
+}
@@ -0,0 +1,340 @@
+package oauth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/subtle"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// clockSkew is the leeway given to ID token expiry and issue times
+	clockSkew = time.Minute
+	// keySetRefetchInterval limits how often an unknown key ID refetches
+	// the key set, so tokens with made-up IDs can't hammer the provider
+	keySetRefetchInterval = time.Minute
+)
+
+var b64 = base64.RawURLEncoding
+
+// parseECPrivateKey reads a PEM encoded PKCS #8 ECDSA key, the format of
+// Apple's .p8 files
+func parseECPrivateKey(pemBytes []byte) (*ecdsa.PrivateKey, error) {
+	block, _ := pem.Decode(pemBytes)
+	if block == nil {
+		return nil, fmt.Errorf("%w: no PEM block", ErrInvalidPrivateKey)
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidPrivateKey, err)
+	}
+	ecKey, ok := key.(*ecdsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("%w: expected an ECDSA key, got %T", ErrInvalidPrivateKey, key)
+	}
+	return ecKey, nil
+}
+
+// signES256 returns a JWT of claims signed with key
+func signES256(key *ecdsa.PrivateKey, keyID string, claims any) (string, error) {
+	header, err := json.Marshal(map[string]string{"alg": "ES256", "kid": keyID, "typ": "JWT"})
+	if err != nil {
+		return "", err
+	}
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+
+	signingInput := b64.EncodeToString(header) + "." + b64.EncodeToString(payload)
+	digest := sha256.Sum256([]byte(signingInput))
+	r, s, err := ecdsa.Sign(rand.Reader, key, digest[:])
+	if err != nil {
+		return "", err
+	}
+
+	// JWS wants the raw r || s, each padded to the curve size, not ASN.1
+	size := (key.Curve.Params().BitSize + 7) / 8
+	signature := make([]byte, 2*size)
+	r.FillBytes(signature[:size])
+	s.FillBytes(signature[size:])
+
+	return signingInput + "." + b64.EncodeToString(signature), nil
+}
+
+// idTokenClaims are the ID token claims the providers use
+type idTokenClaims struct {
+	Issuer    string   `json:"iss"`
+	Subject   string   `json:"sub"`
+	Audience  audience `json:"aud"`
+	ExpiresAt int64    `json:"exp"`
+	IssuedAt  int64    `json:"iat"`
+	Nonce     string   `json:"nonce"`
+
+	Email         string    `json:"email"`
+	EmailVerified looseBool `json:"email_verified"`
+	Name          string    `json:"name"`
+
+	// raw holds every claim, for the account profile
+	raw map[string]any
+}
+
+// audience is the aud claim, which may be a string or a list of them
+type audience []string
+
+func (a *audience) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*a = audience{single}
+		return nil
+	}
+	var list []string
+	if err := json.Unmarshal(data, &list); err != nil {
+		return err
+	}
+	*a = list
+	return nil
+}
+
+func (a audience) contains(clientID string) bool {
+	for _, aud := range a {
+		if aud == clientID {
+			return true
+		}
+	}
+	return false
+}
+
+// looseBool accepts true and "true", since Apple sends booleans as strings
+type looseBool bool
+
+func (b *looseBool) UnmarshalJSON(data []byte) error {
+	var value bool
+	if err := json.Unmarshal(data, &value); err == nil {
+		*b = looseBool(value)
+		return nil
+	}
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	value, err := strconv.ParseBool(s)
+	if err != nil {
+		return err
+	}
+	*b = looseBool(value)
+	return nil
+}
+
+// issuerCheck accepts or rejects the issuer of verified claims
+type issuerCheck func(claims *idTokenClaims) error
+
+// exactIssuer accepts tokens of a single issuer
+func exactIssuer(issuer string) issuerCheck {
+	return func(claims *idTokenClaims) error {
+		if claims.Issuer != issuer {
+			return fmt.Errorf("%w: issuer %q", ErrInvalidIDToken, claims.Issuer)
+		}
+		return nil
+	}
+}
+
+// verifyIDToken checks the RS256 or ES256 signature of token against keys,
+// that checkIssuer accepts its issuer and that it was issued for clientID,
+// to the flow that sent nonce, and hasn't expired. An empty nonce is for
+// flows that sent none.
+func verifyIDToken(token string, keys *keySet, clientID, nonce string, checkIssuer issuerCheck) (*idTokenClaims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("%w: malformed", ErrInvalidIDToken)
+	}
+
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, err
+	}
+	if header.Alg != "RS256" && header.Alg != "ES256" {
+		return nil, fmt.Errorf("%w: unsupported algorithm %q", ErrInvalidIDToken, header.Alg)
+	}
+
+	key, err := keys.get(header.Kid)
+	if err != nil {
+		return nil, err
+	}
+	signature, err := b64.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidIDToken, err)
+	}
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	if !verifySignature(header.Alg, key, digest[:], signature) {
+		return nil, fmt.Errorf("%w: bad signature", ErrInvalidIDToken)
+	}
+
+	var claims idTokenClaims
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, err
+	}
+	if err := decodeSegment(parts[1], &claims.raw); err != nil {
+		return nil, err
+	}
+
+	if err := checkIssuer(&claims); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	switch {
+	case !claims.Audience.contains(clientID):
+		return nil, fmt.Errorf("%w: not issued for this client", ErrInvalidIDToken)
+	case claims.Subject == "":
+		return nil, fmt.Errorf("%w: no subject", ErrInvalidIDToken)
+	case now.After(time.Unix(claims.ExpiresAt, 0).Add(clockSkew)):
+		return nil, fmt.Errorf("%w: expired", ErrInvalidIDToken)
+	case claims.IssuedAt != 0 && time.Unix(claims.IssuedAt, 0).After(now.Add(clockSkew)):
+		return nil, fmt.Errorf("%w: issued in the future", ErrInvalidIDToken)
+	case nonce != "" && subtle.ConstantTimeCompare([]byte(claims.Nonce), []byte(nonce)) != 1:
+		return nil, fmt.Errorf("%w: nonce doesn't match", ErrInvalidIDToken)
+	}
+	return &claims, nil
+}
+
+// verifySignature checks an RS256 or ES256 signature of digest. The key's
+// type must match alg, so an RSA key can't vouch for an ES256 token.
+func verifySignature(alg string, key crypto.PublicKey, digest, signature []byte) bool {
+	switch key := key.(type) {
+	case *rsa.PublicKey:
+		return alg == "RS256" && rsa.VerifyPKCS1v15(key, crypto.SHA256, digest, signature) == nil
+	case *ecdsa.PublicKey:
+		// JWS signs with the raw r || s, not ASN.1
+		if alg != "ES256" || len(signature) != 64 {
+			return false
+		}
+		r := new(big.Int).SetBytes(signature[:32])
+		s := new(big.Int).SetBytes(signature[32:])
+		return ecdsa.Verify(key, digest, r, s)
+	}
+	return false
+}
+
+func decodeSegment(segment string, out any) error {
+	data, err := b64.DecodeString(segment)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidIDToken, err)
+	}
+	if err := json.Unmarshal(data, out); err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidIDToken, err)
+	}
+	return nil
+}
+
+// keySet is a provider's JSON Web Key Set. Keys are fetched on first use and
+// again when a token names a key not seen yet, which is how providers
+// rotate them.
+type keySet struct {
+	url    string
+	client *http.Client
+
+	mu        sync.Mutex
+	keys      map[string]crypto.PublicKey
+	fetchedAt time.Time
+}
+
+func newKeySet(url string, client *http.Client) *keySet {
+	return &keySet{url: url, client: client}
+}
+
+func (s *keySet) get(keyID string) (crypto.PublicKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if key, ok := s.keys[keyID]; ok {
+		return key, nil
+	}
+	if time.Since(s.fetchedAt) < keySetRefetchInterval {
+		return nil, fmt.Errorf("%w: unknown key %q", ErrInvalidIDToken, keyID)
+	}
+
+	keys, err := s.fetch()
+	if err != nil {
+		return nil, err
+	}
+	s.keys = keys
+	s.fetchedAt = time.Now()
+
+	if key, ok := s.keys[keyID]; ok {
+		return key, nil
+	}
+	return nil, fmt.Errorf("%w: unknown key %q", ErrInvalidIDToken, keyID)
+}
+
+func (s *keySet) fetch() (map[string]crypto.PublicKey, error) {
+	req, err := http.NewRequest(http.MethodGet, s.url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var set struct {
+		Keys []struct {
+			Kty string `json:"kty"`
+			Kid string `json:"kid"`
+			N   string `json:"n"`
+			E   string `json:"e"`
+			Crv string `json:"crv"`
+			X   string `json:"x"`
+			Y   string `json:"y"`
+		} `json:"keys"`
+	}
+	status, err := doJSON(s.client, req, &set)
+	if err != nil {
+		return nil, fmt.Errorf("fetch signing keys: %w", err)
+	}
+	if status != http.StatusOK {
+		return nil, fmt.Errorf("fetch signing keys: %s returned %d", req.URL.Host, status)
+	}
+
+	keys := make(map[string]crypto.PublicKey, len(set.Keys))
+	for _, jwk := range set.Keys {
+		switch jwk.Kty {
+		case "RSA":
+			n, errN := b64.DecodeString(jwk.N)
+			e, errE := b64.DecodeString(jwk.E)
+			if errN != nil || errE != nil || len(e) == 0 {
+				continue
+			}
+			keys[jwk.Kid] = &rsa.PublicKey{
+				N: new(big.Int).SetBytes(n),
+				E: int(new(big.Int).SetBytes(e).Int64()),
+			}
+		case "EC":
+			// ES256 keys only; the point must be on the curve
+			x, errX := b64.DecodeString(jwk.X)
+			y, errY := b64.DecodeString(jwk.Y)
+			if jwk.Crv != "P-256" || errX != nil || errY != nil || len(x) != 32 || len(y) != 32 {
+				continue
+			}
+			key, err := ecdsa.ParseUncompressedPublicKey(elliptic.P256(), append(append([]byte{4}, x...), y...))
+			if err != nil {
+				continue
+			}
+			keys[jwk.Kid] = key
+		}
+	}
+	return keys, nil
+}
@@ -0,0 +1,236 @@
+package oauth
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+
+	"github.com/lborres/kuta/core"
+)
+
+const (
+	MicrosoftProviderID = "microsoft"
+
+	// Tenant values that admit users of more than one directory
+	MicrosoftTenantCommon        = "common"        // work, school and personal accounts
+	MicrosoftTenantOrganizations = "organizations" // work and school accounts only
+	MicrosoftTenantConsumers     = "consumers"     // personal Microsoft accounts only
+
+	microsoftLoginURL = "https://login.microsoftonline.com"
+	// microsoftConsumerTenantID is the directory personal Microsoft
+	// accounts belong to
+	microsoftConsumerTenantID = "9188040d-6c67-4c5b-b112-36a304b66dad"
+)
+
+// ErrTenantNotAllowed is returned for users of a directory the provider
+// isn't configured to admit
+var ErrTenantNotAllowed = errors.New("tenant not allowed")
+
+// MicrosoftConfig configures sign-in with Microsoft Entra ID (Azure AD)
+// through the v2.0 endpoints
+type MicrosoftConfig struct {
+	// ClientID and ClientSecret are the app registration's application ID
+	// and a client secret
+	ClientID     string
+	ClientSecret string
+	// Tenant is the directory users sign in to: a tenant ID for single-tenant
+	// apps, or MicrosoftTenantCommon (default), MicrosoftTenantOrganizations
+	// or MicrosoftTenantConsumers for multi-tenant ones
+	Tenant string
+	// AllowedTenants restricts a multi-tenant app to these tenant IDs, e.g.
+	// the directories of onboarded customers. Empty admits every tenant
+	// Tenant does.
+	AllowedTenants []string
+	// Scopes defaults to "openid", "email", "profile" and "offline_access"
+	Scopes []string
+
+	// HTTPClient calls Microsoft's endpoints. Defaults to a client with a 10
+	// second timeout.
+	HTTPClient *http.Client
+	// LoginURL replaces https://login.microsoftonline.com, for national
+	// clouds or tests
+	LoginURL string
+}
+
+// Microsoft signs users in with their Microsoft work, school or personal
+// account. ID tokens are accepted from the issuer of the user's own tenant,
+// which must be admitted by Tenant and AllowedTenants.
+//
+// Entra lets directory admins set any email on a user, so EmailVerified is
+// only reported for personal accounts and for tokens carrying the xms_edov
+// (email domain owner verified) optional claim. Without it, an existing
+// user is never linked by email.
+type Microsoft struct {
+	config   MicrosoftConfig
+	loginURL string
+	client   *http.Client
+	keys     *keySet
+}
+
+var _ core.OAuthProvider = (*Microsoft)(nil)
+
+// NewMicrosoft creates the Microsoft provider
+func NewMicrosoft(config MicrosoftConfig) (*Microsoft, error) {
+	if config.ClientID == "" || config.ClientSecret == "" {
+		return nil, errors.New("microsoft: ClientID and ClientSecret are required")
+	}
+	switch config.Tenant {
+	case "":
+		config.Tenant = MicrosoftTenantCommon
+	case MicrosoftTenantCommon, MicrosoftTenantOrganizations, MicrosoftTenantConsumers:
+	default:
+		if !isTenantID(config.Tenant) {
+			return nil, fmt.Errorf("microsoft: Tenant %q must be a tenant ID or common, organizations or consumers", config.Tenant)
+		}
+	}
+	for _, tenant := range config.AllowedTenants {
+		if !isTenantID(tenant) {
+			return nil, fmt.Errorf("microsoft: AllowedTenants entry %q is not a tenant ID", tenant)
+		}
+	}
+	if len(config.Scopes) == 0 {
+		config.Scopes = []string{"openid", "email", "profile", "offline_access"}
+	}
+
+	loginURL := strings.TrimSuffix(config.LoginURL, "/")
+	if loginURL == "" {
+		loginURL = microsoftLoginURL
+	}
+
+	client := defaultHTTPClient(config.HTTPClient)
+	return &Microsoft{
+		config:   config,
+		loginURL: loginURL,
+		client:   client,
+		keys:     newKeySet(loginURL+"/"+config.Tenant+"/discovery/v2.0/keys", client),
+	}, nil
+}
+
+func (m *Microsoft) ID() string {
+	return MicrosoftProviderID
+}
+
+func (m *Microsoft) AuthCodeURL(state, redirectURI, codeChallenge string) string {
+	query := url.Values{
+		"client_id":     {m.config.ClientID},
+		"redirect_uri":  {redirectURI},
+		"response_type": {"code"},
+		"response_mode": {"query"},
+		"scope":         {strings.Join(m.config.Scopes, " ")},
+		"state":         {state},
+	}
+	setCodeChallenge(query, codeChallenge)
+	setNonce(query, codeChallenge)
+	return m.endpoint("authorize") + "?" + query.Encode()
+}
+
+func (m *Microsoft) Exchange(callback core.OAuthCallback) (*core.OAuthIdentity, error) {
+	if callback.Code == "" {
+		return nil, ErrMissingCode
+	}
+
+	form := url.Values{
+		"client_id":     {m.config.ClientID},
+		"client_secret": {m.config.ClientSecret},
+		"code":          {callback.Code},
+		"grant_type":    {"authorization_code"},
+		"redirect_uri":  {callback.RedirectURI},
+		"scope":         {strings.Join(m.config.Scopes, " ")},
+	}
+	setCodeVerifier(form, callback.CodeVerifier)
+	token, err := exchangeCode(m.client, m.endpoint("token"), form)
+	if err != nil {
+		return nil, err
+	}
+	if token.IDToken == "" {
+		return nil, errors.New("microsoft: token response has no ID token, is the openid scope requested?")
+	}
+
+	claims, err := verifyIDToken(token.IDToken, m.keys, m.config.ClientID, flowNonce(callback.CodeVerifier), m.checkIssuer)
+	if err != nil {
+		return nil, err
+	}
+
+	tenantID, _ := claims.raw["tid"].(string)
+	domainVerified, _ := claims.raw["xms_edov"].(bool)
+	identity := &core.OAuthIdentity{
+		AccountID:     claims.Subject,
+		Email:         claims.Email,
+		EmailVerified: claims.Email != "" && (domainVerified || tenantID == microsoftConsumerTenantID),
+		Name:          claims.Name,
+		AccessToken:   token.AccessToken,
+		RefreshToken:  token.RefreshToken,
+		ExpiresAt:     token.expiresAt(),
+		Profile:       claims.raw,
+	}
+	// Work accounts only carry email when the app asks for the optional
+	// claim; the sign-in name is usually the same address
+	if identity.Email == "" {
+		if username, _ := claims.raw["preferred_username"].(string); strings.Contains(username, "@") {
+			identity.Email = username
+		}
+	}
+
+	return identity, nil
+}
+
+// checkIssuer accepts the v2.0 issuer of the tenant the token names, as long
+// as that tenant is admitted. Tokens from the multi-tenant endpoints carry
+// the user's own tenant, so the issuer differs from user to user.
+func (m *Microsoft) checkIssuer(claims *idTokenClaims) error {
+	tenantID, _ := claims.raw["tid"].(string)
+	if tenantID == "" {
+		return fmt.Errorf("%w: no tenant", ErrInvalidIDToken)
+	}
+	if claims.Issuer != m.loginURL+"/"+tenantID+"/v2.0" {
+		return fmt.Errorf("%w: issuer %q", ErrInvalidIDToken, claims.Issuer)
+	}
+
+	var admitted bool
+	switch m.config.Tenant {
+	case MicrosoftTenantCommon:
+		admitted = true
+	case MicrosoftTenantOrganizations:
+		admitted = tenantID != microsoftConsumerTenantID
+	case MicrosoftTenantConsumers:
+		admitted = tenantID == microsoftConsumerTenantID
+	default:
+		admitted = strings.EqualFold(tenantID, m.config.Tenant)
+	}
+	if len(m.config.AllowedTenants) > 0 {
+		admitted = admitted && slices.ContainsFunc(m.config.AllowedTenants, func(allowed string) bool {
+			return strings.EqualFold(allowed, tenantID)
+		})
+	}
+	if !admitted {
+		return fmt.Errorf("%w: %s", ErrTenantNotAllowed, tenantID)
+	}
+	return nil
+}
+
+func (m *Microsoft) endpoint(name string) string {
+	return m.loginURL + "/" + m.config.Tenant + "/oauth2/v2.0/" + name
+}
+
+// isTenantID reports whether s looks like a directory (tenant) ID, a GUID
+func isTenantID(s string) bool {
+	if len(s) != 36 {
+		return false
+	}
+	for i, c := range s {
+		switch i {
+		case 8, 13, 18, 23:
+			if c != '-' {
+				return false
+			}
+		default:
+			if !strings.ContainsRune("0123456789abcdefABCDEF", c) {
+				return false
+			}
+		}
+	}
+	return true
+}
@@ -0,0 +1,224 @@
+package oauth
+
+import (
+	"errors"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/lborres/kuta/core"
+)
+
+const (
+	contosoTenantID  = "11111111-2222-3333-4444-555555555555"
+	fabrikamTenantID = "66666666-7777-8888-9999-000000000000"
+)
+
+// Requirement: Microsoft accepts ID tokens from the issuer of the user's own
+// tenant when Tenant and AllowedTenants admit that tenant, and only reports
+// emails as verified for personal accounts or with the xms_edov claim.
+func TestMicrosoft_Exchange(t *testing.T) {
+	claimsFor := func(issuerBase, tenantID string) map[string]any {
+		return map[string]any{
+			"iss":   issuerBase + "/" + tenantID + "/v2.0",
+			"aud":   "app-id",
+			"sub":   "pairwise-sub",
+			"tid":   tenantID,
+			"exp":   time.Now().Add(time.Hour).Unix(),
+			"iat":   time.Now().Unix(),
+			"name":  "Ada Lovelace",
+			"email": "ada@contoso.com",
+		}
+	}
+
+	tests := []struct {
+		name         string
+		tenant       string
+		allowed      []string
+		tokenTenant  string
+		claims       func(c map[string]any, issuerBase string)
+		wantErr      error
+		wantEmail    string
+		wantVerified bool
+	}{
+		{
+			name:        "common admits any tenant",
+			tokenTenant: contosoTenantID,
+			wantEmail:   "ada@contoso.com",
+		},
+		{
+			name:         "domain owner verified email",
+			tokenTenant:  contosoTenantID,
+			claims:       func(c map[string]any, _ string) { c["xms_edov"] = true },
+			wantEmail:    "ada@contoso.com",
+			wantVerified: true,
+		},
+		{
+			name:         "personal account email is verified",
+			tokenTenant:  microsoftConsumerTenantID,
+			wantEmail:    "ada@contoso.com",
+			wantVerified: true,
+		},
+		{
+			name:        "falls back to preferred_username",
+			tokenTenant: contosoTenantID,
+			claims: func(c map[string]any, _ string) {
+				delete(c, "email")
+				c["preferred_username"] = "ada@contoso.onmicrosoft.com"
+			},
+			wantEmail: "ada@contoso.onmicrosoft.com",
+		},
+		{
+			name:        "organizations rejects personal accounts",
+			tenant:      MicrosoftTenantOrganizations,
+			tokenTenant: microsoftConsumerTenantID,
+			wantErr:     ErrTenantNotAllowed,
+		},
+		{
+			name:        "consumers rejects work accounts",
+			tenant:      MicrosoftTenantConsumers,
+			tokenTenant: contosoTenantID,
+			wantErr:     ErrTenantNotAllowed,
+		},
+		{
+			name:        "single tenant admits its own users",
+			tenant:      contosoTenantID,
+			tokenTenant: contosoTenantID,
+			wantEmail:   "ada@contoso.com",
+		},
+		{
+			name:        "single tenant rejects other tenants",
+			tenant:      contosoTenantID,
+			tokenTenant: fabrikamTenantID,
+			wantErr:     ErrTenantNotAllowed,
+		},
+		{
+			name:        "allow list rejects unlisted tenants",
+			tenant:      MicrosoftTenantOrganizations,
+			allowed:     []string{contosoTenantID},
+			tokenTenant: fabrikamTenantID,
+			wantErr:     ErrTenantNotAllowed,
+		},
+		{
+			name:        "issuer must match the token's tenant",
+			tokenTenant: contosoTenantID,
+			claims: func(c map[string]any, issuerBase string) {
+				c["iss"] = issuerBase + "/" + fabrikamTenantID + "/v2.0"
+			},
+			wantErr: ErrInvalidIDToken,
+		},
+		{
+			name:        "token without tenant",
+			tokenTenant: contosoTenantID,
+			claims:      func(c map[string]any, _ string) { delete(c, "tid") },
+			wantErr:     ErrInvalidIDToken,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// Arrange
+			idp := newFakeIdP(t)
+			provider, err := NewMicrosoft(MicrosoftConfig{
+				ClientID:       "app-id",
+				ClientSecret:   "secret",
+				Tenant:         test.tenant,
+				AllowedTenants: test.allowed,
+				LoginURL:       idp.server.URL,
+			})
+			if err != nil {
+				t.Fatalf("NewMicrosoft() error = %v", err)
+			}
+			idp.idTokenClaims = claimsFor(idp.server.URL, test.tokenTenant)
+			if test.claims != nil {
+				test.claims(idp.idTokenClaims, idp.server.URL)
+			}
+
+			// Act
+			identity, err := provider.Exchange(core.OAuthCallback{Code: "good-code", RedirectURI: "https://app.example/cb"})
+
+			// Assert
+			if test.wantErr != nil {
+				if !errors.Is(err, test.wantErr) {
+					t.Fatalf("Exchange() error = %v, want %v", err, test.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Exchange() error = %v", err)
+			}
+			if identity.AccountID != "pairwise-sub" || identity.Name != "Ada Lovelace" ||
+				identity.Email != test.wantEmail || identity.EmailVerified != test.wantVerified {
+				t.Errorf("Exchange() = %+v", identity)
+			}
+			if idp.tokenForm.Get("client_secret") != "secret" || idp.tokenForm.Get("redirect_uri") != "https://app.example/cb" {
+				t.Errorf("token request = %v", idp.tokenForm)
+			}
+		})
+	}
+}
+
+// Requirement: NewMicrosoft refuses tenants that can't be checked against
+// the tenant ID in ID tokens, such as domain names.
+func TestNewMicrosoft_Tenant(t *testing.T) {
+	tests := []struct {
+		name    string
+		tenant  string
+		allowed []string
+		wantErr bool
+	}{
+		{name: "default", tenant: ""},
+		{name: "keyword", tenant: MicrosoftTenantOrganizations},
+		{name: "tenant ID", tenant: contosoTenantID},
+		{name: "domain name", tenant: "contoso.onmicrosoft.com", wantErr: true},
+		{name: "allow list domain", tenant: MicrosoftTenantCommon, allowed: []string{"contoso.com"}, wantErr: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// Act
+			_, err := NewMicrosoft(MicrosoftConfig{ClientID: "app-id", ClientSecret: "secret", Tenant: test.tenant, AllowedTenants: test.allowed})
+
+			// Assert
+			if (err != nil) != test.wantErr {
+				t.Errorf("NewMicrosoft() error = %v, wantErr %v", err, test.wantErr)
+			}
+		})
+	}
+}
+
+// Requirement: Microsoft sends the PKCE challenge with the authorization
+// request and the verifier with the token request.
+func TestMicrosoft_PKCE(t *testing.T) {
+	// Arrange
+	idp := newFakeIdP(t)
+	idp.idTokenClaims = map[string]any{
+		"iss":   idp.server.URL + "/" + contosoTenantID + "/v2.0",
+		"aud":   "app-id",
+		"sub":   "pairwise-sub",
+		"tid":   contosoTenantID,
+		"exp":   time.Now().Add(time.Hour).Unix(),
+		"iat":   time.Now().Unix(),
+		"nonce": flowNonce("verifier"),
+	}
+	idp.checkToken = func(form url.Values) bool { return form.Get("code_verifier") == "verifier" }
+	provider, err := NewMicrosoft(MicrosoftConfig{ClientID: "app-id", ClientSecret: "secret", LoginURL: idp.server.URL})
+	if err != nil {
+		t.Fatalf("NewMicrosoft() error = %v", err)
+	}
+
+	// Act
+	authURL, parseErr := url.Parse(provider.AuthCodeURL("state-1", "https://app.example/cb", "challenge"))
+	_, err = provider.Exchange(core.OAuthCallback{Code: "good-code", RedirectURI: "https://app.example/cb", CodeVerifier: "verifier"})
+
+	// Assert
+	if parseErr != nil {
+		t.Fatalf("AuthCodeURL() unparsable: %v", parseErr)
+	}
+	if query := authURL.Query(); query.Get("code_challenge") != "challenge" || query.Get("code_challenge_method") != "S256" {
+		t.Errorf("AuthCodeURL() query = %v", query)
+	}
+	if err != nil {
+		t.Errorf("Exchange() error = %v", err)
+	}
+}
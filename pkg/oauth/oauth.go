@@ -0,0 +1,196 @@
+// Package oauth provides core.OAuthProvider implementations for sign-in with
+// external identity providers.
+package oauth
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+var (
+	ErrMissingCode       = errors.New("callback has no authorization code")
+	ErrInvalidPrivateKey = errors.New("invalid private key")
+	ErrInvalidIDToken    = errors.New("invalid ID token")
+)
+
+const (
+	// httpTimeout bounds calls to provider endpoints when no HTTPClient is
+	// configured
+	httpTimeout = 10 * time.Second
+	// maxResponseSize caps provider responses read into memory
+	maxResponseSize = 1 << 20
+)
+
+func defaultHTTPClient(client *http.Client) *http.Client {
+	if client != nil {
+		return client
+	}
+	return &http.Client{Timeout: httpTimeout}
+}
+
+// tokenResponse is the token endpoint response of RFC 6749 section 5.1,
+// with the ID token OpenID Connect adds
+type tokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int64  `json:"expires_in"`
+	RefreshToken string `json:"refresh_token"`
+	Scope        string `json:"scope"`
+	IDToken      string `json:"id_token"`
+
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description"`
+}
+
+// expiresAt converts ExpiresIn to a time, or nil when the provider sent none
+func (r *tokenResponse) expiresAt() *time.Time {
+	if r.ExpiresIn <= 0 {
+		return nil
+	}
+	t := time.Now().Add(time.Duration(r.ExpiresIn) * time.Second)
+	return &t
+}
+
+// setCodeChallenge adds the PKCE challenge of RFC 7636 to an authorization
+// request, when there is one
+func setCodeChallenge(query url.Values, codeChallenge string) {
+	if codeChallenge != "" {
+		query.Set("code_challenge", codeChallenge)
+		query.Set("code_challenge_method", "S256")
+	}
+}
+
+// setCodeVerifier adds the PKCE verifier to a token request, when there is one
+func setCodeVerifier(form url.Values, codeVerifier string) {
+	if codeVerifier != "" {
+		form.Set("code_verifier", codeVerifier)
+	}
+}
+
+// setNonce adds the OpenID Connect nonce to an authorization request. It is
+// derived from the PKCE challenge, which is new for every sign-in, so the
+// ID token can be tied to the flow without storing more than the verifier.
+func setNonce(query url.Values, codeChallenge string) {
+	if codeChallenge != "" {
+		query.Set("nonce", challengeNonce(codeChallenge))
+	}
+}
+
+// flowNonce returns the nonce setNonce sent for the flow of codeVerifier,
+// or "" when there was no PKCE challenge to derive it from
+func flowNonce(codeVerifier string) string {
+	if codeVerifier == "" {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(codeVerifier))
+	return challengeNonce(b64.EncodeToString(sum[:]))
+}
+
+func challengeNonce(codeChallenge string) string {
+	sum := sha256.Sum256([]byte("nonce:" + codeChallenge))
+	return b64.EncodeToString(sum[:])
+}
+
+// exchangeCode posts form to a token endpoint
+func exchangeCode(client *http.Client, tokenURL string, form url.Values) (*tokenResponse, error) {
+	req, err := http.NewRequest(http.MethodPost, tokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	var token tokenResponse
+	status, err := doJSON(client, req, &token)
+	if err != nil {
+		return nil, err
+	}
+	if token.Error != "" {
+		return nil, fmt.Errorf("token endpoint: %s %s", token.Error, token.ErrorDescription)
+	}
+	if status != http.StatusOK {
+		return nil, fmt.Errorf("token endpoint returned %d", status)
+	}
+	return &token, nil
+}
+
+// revokeToken posts form to a revocation endpoint (RFC 7009), which answers
+// 200 with an empty or arbitrary body on success
+func revokeToken(client *http.Client, revokeURL string, form url.Values) error {
+	req, err := http.NewRequest(http.MethodPost, revokeURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, maxResponseSize))
+
+	if resp.StatusCode != http.StatusOK {
+		var failure tokenResponse
+		if json.Unmarshal(body, &failure) == nil && failure.Error != "" {
+			return fmt.Errorf("revocation endpoint: %s %s", failure.Error, failure.ErrorDescription)
+		}
+		return fmt.Errorf("revocation endpoint returned %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// getJSON calls an API endpoint with the user's access token
+func getJSON(client *http.Client, endpoint, accessToken string, out any) error {
+	req, err := http.NewRequest(http.MethodGet, endpoint, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+	req.Header.Set("Accept", "application/json")
+
+	status, err := doJSON(client, req, out)
+	if err != nil {
+		return err
+	}
+	if status != http.StatusOK {
+		return fmt.Errorf("%s returned %d", req.URL.Path, status)
+	}
+	return nil
+}
+
+// doJSON sends req and decodes the JSON response body into out, whatever the
+// status, since error responses carry details in the body too
+func doJSON(client *http.Client, req *http.Request, out any) (int, error) {
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseSize))
+	if err != nil {
+		return resp.StatusCode, err
+	}
+	if err := json.Unmarshal(body, out); err != nil {
+		return resp.StatusCode, fmt.Errorf("%s returned %d with an unreadable body: %w", req.URL.Host, resp.StatusCode, err)
+	}
+	return resp.StatusCode, nil
+}
+
+// remarshal converts a decoded JSON object to a struct, for providers whose
+// profile is kept whole and also read field by field
+func remarshal(in map[string]any, out any) error {
+	data, err := json.Marshal(in)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, out)
+}
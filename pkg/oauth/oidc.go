@@ -0,0 +1,267 @@
+package oauth
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+
+	"github.com/lborres/kuta/core"
+)
+
+// discoveryPath is where issuers publish their OpenID Provider metadata
+const discoveryPath = "/.well-known/openid-configuration"
+
+// ErrDiscoveryFailed is returned by NewOIDC when the issuer's metadata can't
+// be fetched or doesn't describe an IdP the provider can use
+var ErrDiscoveryFailed = errors.New("OpenID Connect discovery failed")
+
+// OIDCConfig configures sign-in with any OpenID Connect identity provider,
+// such as Keycloak, Auth0 or Okta
+type OIDCConfig struct {
+	// ProviderID names the provider in URLs and accounts, e.g. "keycloak"
+	// for /sign-in/keycloak
+	ProviderID string
+	// Issuer is the IdP's issuer URL, e.g.
+	// "https://sso.example.com/realms/main" for a Keycloak realm or
+	// "https://example.us.auth0.com/". The endpoints are discovered from
+	// Issuer + "/.well-known/openid-configuration".
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	// Scopes defaults to "openid", "email" and "profile". "openid" is added
+	// when missing.
+	Scopes []string
+	// AuthParams are extra authorization request parameters, e.g.
+	// {"prompt": "login"} or Auth0's {"connection": "github"}
+	AuthParams map[string]string
+
+	// HTTPClient calls the IdP. Defaults to a client with a 10 second
+	// timeout.
+	HTTPClient *http.Client
+}
+
+// oidcMetadata is the part of the OpenID Provider metadata the provider uses
+type oidcMetadata struct {
+	Issuer                string   `json:"issuer"`
+	AuthorizationEndpoint string   `json:"authorization_endpoint"`
+	TokenEndpoint         string   `json:"token_endpoint"`
+	UserInfoEndpoint      string   `json:"userinfo_endpoint"`
+	JWKSURI               string   `json:"jwks_uri"`
+	RevocationEndpoint    string   `json:"revocation_endpoint"`
+	SigningAlgorithms     []string `json:"id_token_signing_alg_values_supported"`
+}
+
+// OIDC signs users in with an OpenID Connect identity provider found through
+// discovery. The user is read from the ID token, which must be signed with
+// RS256 or ES256 by a key of the issuer's key set; IdPs that leave the email out of
+// the ID token are asked at their userinfo endpoint. Emails count as verified
+// when the IdP says so in email_verified.
+type OIDC struct {
+	config   OIDCConfig
+	metadata oidcMetadata
+	client   *http.Client
+	keys     *keySet
+}
+
+var (
+	_ core.OAuthProvider     = (*OIDC)(nil)
+	_ core.OAuthTokenRevoker = (*OIDC)(nil)
+	_ core.TokenRefresher    = (*OIDC)(nil)
+)
+
+// NewOIDC creates an OpenID Connect provider, fetching the issuer's metadata
+func NewOIDC(config OIDCConfig) (*OIDC, error) {
+	switch {
+	case config.ProviderID == "" || config.Issuer == "":
+		return nil, errors.New("oidc: ProviderID and Issuer are required")
+	case config.ProviderID == core.CredentialProviderID || strings.ContainsAny(config.ProviderID, "/?#"):
+		return nil, fmt.Errorf("oidc: ProviderID %q can't be used", config.ProviderID)
+	case config.ClientID == "" || config.ClientSecret == "":
+		return nil, errors.New("oidc: ClientID and ClientSecret are required")
+	}
+	if len(config.Scopes) == 0 {
+		config.Scopes = []string{"openid", "email", "profile"}
+	} else if !slices.Contains(config.Scopes, "openid") {
+		config.Scopes = append([]string{"openid"}, config.Scopes...)
+	}
+
+	client := defaultHTTPClient(config.HTTPClient)
+	metadata, err := discover(client, config.Issuer)
+	if err != nil {
+		return nil, fmt.Errorf("oidc %s: %w", config.ProviderID, err)
+	}
+	return &OIDC{
+		config:   config,
+		metadata: *metadata,
+		client:   client,
+		keys:     newKeySet(metadata.JWKSURI, client),
+	}, nil
+}
+
+// discover fetches the metadata of issuer and checks that it belongs to
+// issuer and offers what sign-in needs
+func discover(client *http.Client, issuer string) (*oidcMetadata, error) {
+	req, err := http.NewRequest(http.MethodGet, strings.TrimSuffix(issuer, "/")+discoveryPath, nil)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrDiscoveryFailed, err)
+	}
+	req.Header.Set("Accept", "application/json")
+
+	var metadata oidcMetadata
+	status, err := doJSON(client, req, &metadata)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrDiscoveryFailed, err)
+	}
+	switch {
+	case status != http.StatusOK:
+		return nil, fmt.Errorf("%w: %s returned %d", ErrDiscoveryFailed, req.URL.Host, status)
+	// The metadata must name the issuer it was fetched for (OpenID Connect
+	// Discovery section 4.3); only a trailing slash may differ
+	case strings.TrimSuffix(metadata.Issuer, "/") != strings.TrimSuffix(issuer, "/"):
+		return nil, fmt.Errorf("%w: metadata is for issuer %q", ErrDiscoveryFailed, metadata.Issuer)
+	case metadata.AuthorizationEndpoint == "" || metadata.TokenEndpoint == "" || metadata.JWKSURI == "":
+		return nil, fmt.Errorf("%w: metadata lacks an authorization, token or key set endpoint", ErrDiscoveryFailed)
+	// RS256 is the default when the IdP lists nothing
+	case len(metadata.SigningAlgorithms) > 0 && !slices.Contains(metadata.SigningAlgorithms, "RS256") && !slices.Contains(metadata.SigningAlgorithms, "ES256"):
+		return nil, fmt.Errorf("%w: ID tokens aren't signed with RS256 or ES256 (%s)", ErrDiscoveryFailed, strings.Join(metadata.SigningAlgorithms, ", "))
+	}
+	return &metadata, nil
+}
+
+func (o *OIDC) ID() string {
+	return o.config.ProviderID
+}
+
+func (o *OIDC) AuthCodeURL(state, redirectURI, codeChallenge string) string {
+	query := url.Values{}
+	for name, value := range o.config.AuthParams {
+		query.Set(name, value)
+	}
+	query.Set("client_id", o.config.ClientID)
+	query.Set("redirect_uri", redirectURI)
+	query.Set("response_type", "code")
+	query.Set("scope", strings.Join(o.config.Scopes, " "))
+	query.Set("state", state)
+	setCodeChallenge(query, codeChallenge)
+	setNonce(query, codeChallenge)
+
+	separator := "?"
+	if strings.Contains(o.metadata.AuthorizationEndpoint, "?") {
+		separator = "&"
+	}
+	return o.metadata.AuthorizationEndpoint + separator + query.Encode()
+}
+
+func (o *OIDC) Exchange(callback core.OAuthCallback) (*core.OAuthIdentity, error) {
+	if callback.Code == "" {
+		return nil, ErrMissingCode
+	}
+
+	form := url.Values{
+		"client_id":     {o.config.ClientID},
+		"client_secret": {o.config.ClientSecret},
+		"code":          {callback.Code},
+		"grant_type":    {"authorization_code"},
+		"redirect_uri":  {callback.RedirectURI},
+	}
+	setCodeVerifier(form, callback.CodeVerifier)
+	token, err := exchangeCode(o.client, o.metadata.TokenEndpoint, form)
+	if err != nil {
+		return nil, err
+	}
+	if token.IDToken == "" {
+		return nil, fmt.Errorf("oidc %s: token response has no ID token", o.config.ProviderID)
+	}
+
+	claims, err := verifyIDToken(token.IDToken, o.keys, o.config.ClientID, flowNonce(callback.CodeVerifier), exactIssuer(o.metadata.Issuer))
+	if err != nil {
+		return nil, err
+	}
+
+	profile := claims.raw
+	if claims.Email == "" && o.metadata.UserInfoEndpoint != "" {
+		if profile, err = o.userInfo(token.AccessToken, claims); err != nil {
+			return nil, err
+		}
+	}
+
+	var user struct {
+		Email             string    `json:"email"`
+		EmailVerified     looseBool `json:"email_verified"`
+		Name              string    `json:"name"`
+		PreferredUsername string    `json:"preferred_username"`
+		Picture           string    `json:"picture"`
+	}
+	if err := remarshal(profile, &user); err != nil {
+		return nil, err
+	}
+	identity := &core.OAuthIdentity{
+		AccountID:     claims.Subject,
+		Email:         user.Email,
+		EmailVerified: user.Email != "" && bool(user.EmailVerified),
+		Name:          user.Name,
+		Image:         user.Picture,
+		AccessToken:   token.AccessToken,
+		RefreshToken:  token.RefreshToken,
+		ExpiresAt:     token.expiresAt(),
+		Profile:       profile,
+	}
+	if identity.Name == "" {
+		identity.Name = user.PreferredUsername
+	}
+	return identity, nil
+}
+
+// userInfo returns the ID token's claims completed with the userinfo
+// endpoint's, which must describe the same subject
+func (o *OIDC) userInfo(accessToken string, claims *idTokenClaims) (map[string]any, error) {
+	var info map[string]any
+	if err := getJSON(o.client, o.metadata.UserInfoEndpoint, accessToken, &info); err != nil {
+		return nil, err
+	}
+	if subject, _ := info["sub"].(string); subject != claims.Subject {
+		return nil, fmt.Errorf("oidc %s: userinfo is for another subject", o.config.ProviderID)
+	}
+
+	profile := make(map[string]any, len(claims.raw)+len(info))
+	for name, value := range info {
+		profile[name] = value
+	}
+	// The signed claims win over unsigned ones
+	for name, value := range claims.raw {
+		profile[name] = value
+	}
+	return profile, nil
+}
+
+// RefreshToken exchanges a refresh token for new tokens. Whether the IdP
+// rotates the refresh token is up to its configuration.
+func (o *OIDC) RefreshToken(refreshToken string) (*core.ProviderTokens, error) {
+	token, err := exchangeCode(o.client, o.metadata.TokenEndpoint, url.Values{
+		"client_id":     {o.config.ClientID},
+		"client_secret": {o.config.ClientSecret},
+		"grant_type":    {"refresh_token"},
+		"refresh_token": {refreshToken},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return providerTokens(token), nil
+}
+
+// RevokeToken revokes token at the IdP's revocation endpoint. IdPs that
+// publish none have nothing to revoke it at, so the token is only forgotten.
+func (o *OIDC) RevokeToken(token, tokenTypeHint string) error {
+	if o.metadata.RevocationEndpoint == "" {
+		return nil
+	}
+	return revokeToken(o.client, o.metadata.RevocationEndpoint, url.Values{
+		"client_id":       {o.config.ClientID},
+		"client_secret":   {o.config.ClientSecret},
+		"token":           {token},
+		"token_type_hint": {tokenTypeHint},
+	})
+}
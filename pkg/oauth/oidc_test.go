@@ -0,0 +1,254 @@
+package oauth
+
+import (
+	"errors"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/lborres/kuta/core"
+)
+
+// newTestOIDC returns an OIDC provider discovering idp
+func newTestOIDC(t *testing.T, idp *fakeIdP) *OIDC {
+	t.Helper()
+	provider, err := NewOIDC(OIDCConfig{
+		ProviderID:   "keycloak",
+		Issuer:       idp.server.URL,
+		ClientID:     "client-id",
+		ClientSecret: "secret",
+	})
+	if err != nil {
+		t.Fatalf("NewOIDC() error = %v", err)
+	}
+	return provider
+}
+
+// idTokenClaimsFor returns valid ID token claims from idp
+func idTokenClaimsFor(idp *fakeIdP) map[string]any {
+	return map[string]any{
+		"iss":                idp.server.URL,
+		"aud":                "client-id",
+		"sub":                "f1b2c3",
+		"exp":                time.Now().Add(time.Hour).Unix(),
+		"iat":                time.Now().Unix(),
+		"email":              "ada@example.com",
+		"email_verified":     true,
+		"preferred_username": "ada",
+		"nonce":              flowNonce("verifier"),
+	}
+}
+
+// Requirement: NewOIDC discovers the IdP's endpoints, and refuses metadata
+// for another issuer, without the endpoints sign-in needs, or for ID tokens
+// it can't verify.
+func TestNewOIDC_Discovery(t *testing.T) {
+	tests := []struct {
+		name         string
+		editMetadata func(metadata map[string]any)
+		wantErr      bool
+	}{
+		{name: "discovered"},
+		{name: "no algorithms listed", editMetadata: func(m map[string]any) { delete(m, "id_token_signing_alg_values_supported") }},
+		{name: "other issuer", editMetadata: func(m map[string]any) { m["issuer"] = "https://evil.example" }, wantErr: true},
+		{name: "no token endpoint", editMetadata: func(m map[string]any) { delete(m, "token_endpoint") }, wantErr: true},
+		{name: "ES256 only", editMetadata: func(m map[string]any) { m["id_token_signing_alg_values_supported"] = []string{"ES256"} }},
+		{name: "HS256 only", editMetadata: func(m map[string]any) { m["id_token_signing_alg_values_supported"] = []string{"HS256"} }, wantErr: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// Arrange
+			idp := newFakeIdP(t)
+			idp.editMetadata = test.editMetadata
+
+			// Act
+			_, err := NewOIDC(OIDCConfig{ProviderID: "keycloak", Issuer: idp.server.URL + "/", ClientID: "client-id", ClientSecret: "secret"})
+
+			// Assert
+			if test.wantErr {
+				if !errors.Is(err, ErrDiscoveryFailed) {
+					t.Errorf("NewOIDC() error = %v, want ErrDiscoveryFailed", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Errorf("NewOIDC() error = %v", err)
+			}
+		})
+	}
+}
+
+// Requirement: The OIDC provider reads the user from an ID token signed by
+// the discovered issuer for this client, asks the userinfo endpoint when the
+// token carries no email, and reports the email verified only when the IdP
+// says so.
+func TestOIDC_Exchange(t *testing.T) {
+	tests := []struct {
+		name         string
+		es256        bool
+		claims       func(c map[string]any)
+		userInfo     map[string]any
+		wantErr      bool
+		wantErrIs    error
+		wantEmail    string
+		wantVerified bool
+	}{
+		{
+			name:         "claims in the ID token",
+			wantEmail:    "ada@example.com",
+			wantVerified: true,
+		},
+		{
+			name:      "unverified email",
+			claims:    func(c map[string]any) { c["email_verified"] = false },
+			wantEmail: "ada@example.com",
+		},
+		{
+			name:         "email from userinfo",
+			claims:       func(c map[string]any) { delete(c, "email"); delete(c, "email_verified") },
+			userInfo:     map[string]any{"sub": "f1b2c3", "email": "ada@example.com", "email_verified": true},
+			wantEmail:    "ada@example.com",
+			wantVerified: true,
+		},
+		{
+			name:     "userinfo for another subject",
+			claims:   func(c map[string]any) { delete(c, "email") },
+			userInfo: map[string]any{"sub": "someone-else", "email": "eve@example.com", "email_verified": true},
+			wantErr:  true,
+		},
+		{
+			name:      "other issuer",
+			claims:    func(c map[string]any) { c["iss"] = "https://evil.example" },
+			wantErr:   true,
+			wantErrIs: ErrInvalidIDToken,
+		},
+		{
+			name:      "other client",
+			claims:    func(c map[string]any) { c["aud"] = "other-client" },
+			wantErr:   true,
+			wantErrIs: ErrInvalidIDToken,
+		},
+		{
+			name:      "nonce of another sign-in",
+			claims:    func(c map[string]any) { c["nonce"] = flowNonce("other-verifier") },
+			wantErr:   true,
+			wantErrIs: ErrInvalidIDToken,
+		},
+		{
+			name:      "no nonce",
+			claims:    func(c map[string]any) { delete(c, "nonce") },
+			wantErr:   true,
+			wantErrIs: ErrInvalidIDToken,
+		},
+		{
+			name:         "signed with ES256",
+			es256:        true,
+			wantEmail:    "ada@example.com",
+			wantVerified: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// Arrange
+			idp := newFakeIdP(t)
+			if test.es256 {
+				idp.useES256()
+			}
+			provider := newTestOIDC(t, idp)
+			idp.idTokenClaims = idTokenClaimsFor(idp)
+			if test.claims != nil {
+				test.claims(idp.idTokenClaims)
+			}
+			idp.userInfo = test.userInfo
+
+			// Act
+			identity, err := provider.Exchange(core.OAuthCallback{Code: "good-code", RedirectURI: "https://app.example/cb", CodeVerifier: "verifier"})
+
+			// Assert
+			if test.wantErr {
+				if err == nil || (test.wantErrIs != nil && !errors.Is(err, test.wantErrIs)) {
+					t.Fatalf("Exchange() error = %v, want %v", err, test.wantErrIs)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Exchange() error = %v", err)
+			}
+			if identity.AccountID != "f1b2c3" || identity.Name != "ada" || identity.Email != test.wantEmail || identity.EmailVerified != test.wantVerified {
+				t.Errorf("Exchange() = %+v", identity)
+			}
+			if idp.tokenForm.Get("code_verifier") != "verifier" || idp.tokenForm.Get("grant_type") != "authorization_code" {
+				t.Errorf("token request = %v", idp.tokenForm)
+			}
+		})
+	}
+}
+
+// Requirement: The authorization URL points at the discovered endpoint with
+// the openid scope, the PKCE challenge, the nonce derived from it and the
+// configured extra parameters.
+func TestOIDC_AuthCodeURL(t *testing.T) {
+	// Arrange
+	idp := newFakeIdP(t)
+	provider, err := NewOIDC(OIDCConfig{
+		ProviderID:   "auth0",
+		Issuer:       idp.server.URL,
+		ClientID:     "client-id",
+		ClientSecret: "secret",
+		Scopes:       []string{"email"},
+		AuthParams:   map[string]string{"connection": "github", "state": "overridden"},
+	})
+	if err != nil {
+		t.Fatalf("NewOIDC() error = %v", err)
+	}
+
+	// Act
+	authURL, err := url.Parse(provider.AuthCodeURL("state-1", "https://app.example/cb", "challenge"))
+
+	// Assert
+	if err != nil {
+		t.Fatalf("AuthCodeURL() unparsable: %v", err)
+	}
+	query := authURL.Query()
+	if authURL.Path != "/authorize" || query.Get("scope") != "openid email" || query.Get("state") != "state-1" ||
+		query.Get("code_challenge") != "challenge" || query.Get("nonce") != challengeNonce("challenge") || query.Get("connection") != "github" {
+		t.Errorf("AuthCodeURL() = %v", authURL)
+	}
+}
+
+// Requirement: Tokens are revoked at the discovered revocation endpoint, and
+// only forgotten when the IdP has none.
+func TestOIDC_RevokeToken(t *testing.T) {
+	tests := []struct {
+		name         string
+		noRevocation bool
+		wantRevoked  bool
+	}{
+		{name: "revoked", wantRevoked: true},
+		{name: "no revocation endpoint", noRevocation: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// Arrange
+			idp := newFakeIdP(t)
+			if test.noRevocation {
+				idp.editMetadata = func(m map[string]any) { delete(m, "revocation_endpoint") }
+			}
+			provider := newTestOIDC(t, idp)
+
+			// Act
+			err := provider.RevokeToken("refresh", "refresh_token")
+
+			// Assert
+			if err != nil {
+				t.Fatalf("RevokeToken() error = %v", err)
+			}
+			if revoked := idp.revokeForm.Get("token") == "refresh"; revoked != test.wantRevoked {
+				t.Errorf("revocation request = %v, want revoked %v", idp.revokeForm, test.wantRevoked)
+			}
+		})
+	}
+}
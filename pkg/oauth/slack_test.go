@@ -0,0 +1,159 @@
+package oauth
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/lborres/kuta/core"
+)
+
+// Requirement: Slack verifies the ID token from slack.com and reports the
+// workspace the user signed in to as their membership.
+func TestSlack_Exchange(t *testing.T) {
+	tests := []struct {
+		name           string
+		claims         func(c map[string]any)
+		wantErr        error
+		wantMembership bool
+	}{
+		{
+			name:           "workspace membership",
+			wantMembership: true,
+		},
+		{
+			name:   "token without workspace",
+			claims: func(c map[string]any) { delete(c, "https://slack.com/team_id") },
+		},
+		{
+			name:    "other issuer",
+			claims:  func(c map[string]any) { c["iss"] = "https://evil.example" },
+			wantErr: ErrInvalidIDToken,
+		},
+		{
+			name:    "other audience",
+			claims:  func(c map[string]any) { c["aud"] = "other-app" },
+			wantErr: ErrInvalidIDToken,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// Arrange
+			idp := newFakeIdP(t)
+			idp.idTokenClaims = map[string]any{
+				"iss":                           slackIssuer,
+				"aud":                           "client",
+				"sub":                           "U0R7JM",
+				"exp":                           time.Now().Add(time.Hour).Unix(),
+				"iat":                           time.Now().Unix(),
+				"email":                         "krane@example.com",
+				"email_verified":                true,
+				"name":                          "Krane",
+				"picture":                       "https://secure.gravatar.com/avatar/krane.png",
+				"https://slack.com/team_id":     "T0R7GR",
+				"https://slack.com/team_name":   "Kraneflannel",
+				"https://slack.com/team_domain": "kraneflannel",
+			}
+			if test.claims != nil {
+				test.claims(idp.idTokenClaims)
+			}
+			provider, err := NewSlack(SlackConfig{
+				ClientID:     "client",
+				ClientSecret: "secret",
+				TokenURL:     idp.server.URL + "/token",
+				KeysURL:      idp.server.URL + "/keys",
+			})
+			if err != nil {
+				t.Fatalf("NewSlack() error = %v", err)
+			}
+
+			// Act
+			identity, err := provider.Exchange(core.OAuthCallback{Code: "good-code", RedirectURI: "https://app.example/cb"})
+
+			// Assert
+			if test.wantErr != nil {
+				if !errors.Is(err, test.wantErr) {
+					t.Fatalf("Exchange() error = %v, want %v", err, test.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Exchange() error = %v", err)
+			}
+			if identity.AccountID != "U0R7JM" || identity.Email != "krane@example.com" || !identity.EmailVerified ||
+				identity.Name != "Krane" || identity.Image != "https://secure.gravatar.com/avatar/krane.png" {
+				t.Errorf("Exchange() = %+v", identity)
+			}
+			membership := identity.Membership("T0R7GR")
+			if (membership != nil) != test.wantMembership {
+				t.Fatalf("Membership(T0R7GR) = %+v, want present %v", membership, test.wantMembership)
+			}
+			if membership != nil && membership.Name != "Kraneflannel" {
+				t.Errorf("Membership(T0R7GR).Name = %q", membership.Name)
+			}
+		})
+	}
+}
+
+// Requirement: Slack passes the configured workspace hint to the consent
+// screen.
+func TestSlack_AuthCodeURL(t *testing.T) {
+	// Arrange
+	provider, err := NewSlack(SlackConfig{ClientID: "client", ClientSecret: "secret", Team: "T0R7GR"})
+	if err != nil {
+		t.Fatalf("NewSlack() error = %v", err)
+	}
+
+	// Act
+	authURL, err := url.Parse(provider.AuthCodeURL("state-1", "https://app.example/cb", ""))
+
+	// Assert
+	if err != nil {
+		t.Fatalf("AuthCodeURL() unparsable: %v", err)
+	}
+	query := authURL.Query()
+	if query.Get("team") != "T0R7GR" || query.Get("scope") != "openid email profile" || query.Get("state") != "state-1" {
+		t.Errorf("AuthCodeURL() query = %v", query)
+	}
+}
+
+// Requirement: Slack revokes a token by calling auth.revoke with it, and
+// reports the ok: false answers Slack gives with status 200.
+func TestSlack_RevokeToken(t *testing.T) {
+	tests := []struct {
+		name    string
+		token   string
+		wantErr bool
+	}{
+		{name: "revoked", token: "xoxp-good"},
+		{name: "refused", token: "xoxp-revoked", wantErr: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// Arrange
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Header.Get("Authorization") != "Bearer xoxp-good" {
+					_ = json.NewEncoder(w).Encode(map[string]any{"ok": false, "error": "invalid_auth"})
+					return
+				}
+				_ = json.NewEncoder(w).Encode(map[string]any{"ok": true, "revoked": true})
+			}))
+			defer server.Close()
+			provider, _ := NewSlack(SlackConfig{ClientID: "client", ClientSecret: "secret", RevokeURL: server.URL})
+
+			// Act
+			err := provider.RevokeToken(test.token, "access_token")
+
+			// Assert
+			if (err != nil) != test.wantErr {
+				t.Errorf("RevokeToken() error = %v, wantErr %v", err, test.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,109 @@
+package ratelimit
+
+import (
+	"hash/fnv"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/lborres/kuta/core"
+)
+
+const (
+	// tokenBucketShards spreads keys over independently locked maps so
+	// concurrent requests for different clients rarely contend
+	tokenBucketShards = 32
+	// tokenBucketSweepInterval is how many calls a shard serves between
+	// sweeps of buckets that have refilled completely
+	tokenBucketSweepInterval = 1024
+)
+
+// TokenBucket is an in-memory core.RateLimiter for a single instance. Each
+// key gets a bucket of Burst tokens that refills at Limit tokens per Window;
+// a request spends one token.
+type TokenBucket struct {
+	capacity float64
+	rate     float64 // tokens per second
+	shards   [tokenBucketShards]bucketShard
+	now      func() time.Time
+}
+
+type bucketShard struct {
+	mu      sync.Mutex
+	buckets map[string]*bucket
+	calls   int
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+var _ core.RateLimiter = (*TokenBucket)(nil)
+
+// NewTokenBucket creates an in-memory token bucket limiter
+func NewTokenBucket(config core.RateLimitConfig) *TokenBucket {
+	config = withDefaults(config)
+	tb := &TokenBucket{
+		capacity: float64(config.Burst),
+		rate:     float64(config.Limit) / config.Window.Seconds(),
+		now:      time.Now,
+	}
+	for i := range tb.shards {
+		tb.shards[i].buckets = make(map[string]*bucket)
+	}
+	return tb
+}
+
+func (tb *TokenBucket) Allow(key string) (core.RateLimitDecision, error) {
+	shard := tb.shardFor(key)
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	now := tb.now()
+	b, ok := shard.buckets[key]
+	if !ok {
+		b = &bucket{tokens: tb.capacity, last: now}
+		shard.buckets[key] = b
+	} else {
+		tb.refill(b, now)
+	}
+
+	shard.calls++
+	if shard.calls%tokenBucketSweepInterval == 0 {
+		tb.sweep(shard, now)
+	}
+
+	if b.tokens < 1 {
+		wait := (1 - b.tokens) / tb.rate
+		return core.RateLimitDecision{
+			RetryAfter: time.Duration(math.Ceil(wait * float64(time.Second))),
+		}, nil
+	}
+	b.tokens--
+	return core.RateLimitDecision{Allowed: true, Remaining: int(b.tokens)}, nil
+}
+
+func (tb *TokenBucket) shardFor(key string) *bucketShard {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(key))
+	return &tb.shards[h.Sum32()%tokenBucketShards]
+}
+
+func (tb *TokenBucket) refill(b *bucket, now time.Time) {
+	elapsed := now.Sub(b.last).Seconds()
+	if elapsed > 0 {
+		b.tokens = min(tb.capacity, b.tokens+elapsed*tb.rate)
+		b.last = now
+	}
+}
+
+// sweep drops buckets that would be full by now; a fresh bucket is the same
+func (tb *TokenBucket) sweep(shard *bucketShard, now time.Time) {
+	for key, b := range shard.buckets {
+		tb.refill(b, now)
+		if b.tokens >= tb.capacity {
+			delete(shard.buckets, key)
+		}
+	}
+}
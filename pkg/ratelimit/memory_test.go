@@ -0,0 +1,68 @@
+package ratelimit
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/lborres/kuta/core"
+)
+
+// Requirement: A key may spend Burst requests at once, is then refused with
+// a RetryAfter, and regains tokens at Limit per Window.
+func TestTokenBucket_Allow(t *testing.T) {
+	// Arrange
+	now := time.Unix(0, 0)
+	limiter := NewTokenBucket(core.RateLimitConfig{Limit: 2, Window: time.Second, Burst: 3})
+	limiter.now = func() time.Time { return now }
+
+	// Act
+	var burst []core.RateLimitDecision
+	for i := 0; i < 3; i++ {
+		decision, _ := limiter.Allow("a")
+		burst = append(burst, decision)
+	}
+	refused, _ := limiter.Allow("a")
+	other, _ := limiter.Allow("b")
+	now = now.Add(500 * time.Millisecond)
+	refilled, _ := limiter.Allow("a")
+
+	// Assert
+	for i, decision := range burst {
+		if !decision.Allowed || decision.Remaining != 2-i {
+			t.Errorf("request %d: expected allowed with %d remaining, got %+v", i+1, 2-i, decision)
+		}
+	}
+	if refused.Allowed || refused.RetryAfter != 500*time.Millisecond {
+		t.Errorf("expected refusal retrying after 500ms, got %+v", refused)
+	}
+	if !other.Allowed {
+		t.Error("expected other keys to have their own bucket")
+	}
+	if !refilled.Allowed {
+		t.Errorf("expected a token after refilling, got %+v", refilled)
+	}
+}
+
+// Requirement: Buckets that have refilled completely are swept, so idle
+// clients don't hold memory.
+func TestTokenBucket_Sweep(t *testing.T) {
+	// Arrange
+	now := time.Unix(0, 0)
+	limiter := NewTokenBucket(core.RateLimitConfig{Limit: 10, Window: time.Second})
+	limiter.now = func() time.Time { return now }
+	for i := 0; i < 1000; i++ {
+		_, _ = limiter.Allow(fmt.Sprintf("client-%d", i))
+	}
+
+	// Act
+	now = now.Add(time.Second)
+	for i := 0; i < tokenBucketSweepInterval; i++ {
+		_, _ = limiter.Allow("recent")
+	}
+
+	// Assert
+	if got := len(limiter.shardFor("recent").buckets); got != 1 {
+		t.Errorf("expected only the recent bucket to remain in its shard, got %d buckets", got)
+	}
+}
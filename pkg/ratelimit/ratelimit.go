@@ -0,0 +1,29 @@
+// Package ratelimit provides core.RateLimiter implementations: an in-memory
+// token bucket for single-instance apps and a Redis sliding window for apps
+// running several instances.
+package ratelimit
+
+import (
+	"time"
+
+	"github.com/lborres/kuta/core"
+)
+
+const (
+	defaultLimit  = 20
+	defaultWindow = time.Minute
+)
+
+// withDefaults fills in the zero values of config
+func withDefaults(config core.RateLimitConfig) core.RateLimitConfig {
+	if config.Limit <= 0 {
+		config.Limit = defaultLimit
+	}
+	if config.Window <= 0 {
+		config.Window = defaultWindow
+	}
+	if config.Burst <= 0 {
+		config.Burst = config.Limit
+	}
+	return config
+}
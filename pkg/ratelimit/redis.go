@@ -0,0 +1,141 @@
+package ratelimit
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"github.com/lborres/kuta/core"
+)
+
+const (
+	defaultRedisPrefix  = "kuta:ratelimit:"
+	defaultRedisTimeout = time.Second
+)
+
+// RedisClient runs a Lua script, as EVAL does. go-redis clients can be
+// adapted with RedisEvalFunc:
+//
+//	ratelimit.RedisEvalFunc(func(ctx context.Context, script string, keys []string, args ...any) (any, error) {
+//		return rdb.Eval(ctx, script, keys, args...).Result()
+//	})
+type RedisClient interface {
+	Eval(ctx context.Context, script string, keys []string, args ...any) (any, error)
+}
+
+// RedisEvalFunc adapts a function to RedisClient
+type RedisEvalFunc func(ctx context.Context, script string, keys []string, args ...any) (any, error)
+
+func (f RedisEvalFunc) Eval(ctx context.Context, script string, keys []string, args ...any) (any, error) {
+	return f(ctx, script, keys, args...)
+}
+
+// RedisOptions configures a Redis limiter
+type RedisOptions struct {
+	// Prefix is prepended to every key. Defaults to "kuta:ratelimit:".
+	Prefix string
+	// Timeout bounds each call to Redis. Defaults to 1 second.
+	Timeout time.Duration
+}
+
+// slidingWindowScript keeps one sorted set member per admitted request,
+// scored by its time in milliseconds. It uses the Redis clock so instances
+// with skewed clocks still share one window.
+//
+// KEYS[1] key, ARGV[1] window (ms), ARGV[2] limit, ARGV[3] unique member
+// suffix. Returns {admitted, remaining, retry after (ms)}.
+const slidingWindowScript = `
+local t = redis.call('TIME')
+local now = tonumber(t[1]) * 1000 + math.floor(tonumber(t[2]) / 1000)
+local window = tonumber(ARGV[1])
+local limit = tonumber(ARGV[2])
+redis.call('ZREMRANGEBYSCORE', KEYS[1], '-inf', now - window)
+local count = redis.call('ZCARD', KEYS[1])
+if count < limit then
+	redis.call('ZADD', KEYS[1], now, now .. '-' .. ARGV[3])
+	redis.call('PEXPIRE', KEYS[1], window)
+	return {1, limit - count - 1, 0}
+end
+local oldest = redis.call('ZRANGE', KEYS[1], 0, 0, 'WITHSCORES')
+return {0, 0, tonumber(oldest[2]) + window - now}
+`
+
+// Redis is a core.RateLimiter that admits at most Limit requests per key in
+// any sliding Window, shared by every instance using the same Redis.
+type Redis struct {
+	client  RedisClient
+	limit   int
+	window  time.Duration
+	prefix  string
+	timeout time.Duration
+}
+
+var _ core.RateLimiter = (*Redis)(nil)
+
+// NewRedis creates a sliding window limiter stored in Redis
+func NewRedis(client RedisClient, config core.RateLimitConfig, opts ...RedisOptions) *Redis {
+	config = withDefaults(config)
+
+	var o RedisOptions
+	if len(opts) > 0 {
+		o = opts[0]
+	}
+	if o.Prefix == "" {
+		o.Prefix = defaultRedisPrefix
+	}
+	if o.Timeout <= 0 {
+		o.Timeout = defaultRedisTimeout
+	}
+
+	return &Redis{
+		client:  client,
+		limit:   config.Limit,
+		window:  config.Window,
+		prefix:  o.Prefix,
+		timeout: o.Timeout,
+	}
+}
+
+func (r *Redis) Allow(key string) (core.RateLimitDecision, error) {
+	var suffix [8]byte
+	if _, err := rand.Read(suffix[:]); err != nil {
+		return core.RateLimitDecision{}, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
+	defer cancel()
+
+	reply, err := r.client.Eval(ctx, slidingWindowScript, []string{r.prefix + key},
+		r.window.Milliseconds(), r.limit, hex.EncodeToString(suffix[:]))
+	if err != nil {
+		return core.RateLimitDecision{}, err
+	}
+	return parseSlidingWindowReply(reply)
+}
+
+func parseSlidingWindowReply(reply any) (core.RateLimitDecision, error) {
+	values, ok := reply.([]any)
+	if !ok || len(values) != 3 {
+		return core.RateLimitDecision{}, fmt.Errorf("ratelimit: unexpected reply %v", reply)
+	}
+
+	var ints [3]int64
+	for i, v := range values {
+		switch n := v.(type) {
+		case int64:
+			ints[i] = n
+		case int:
+			ints[i] = int64(n)
+		default:
+			return core.RateLimitDecision{}, fmt.Errorf("ratelimit: unexpected reply %v", reply)
+		}
+	}
+
+	return core.RateLimitDecision{
+		Allowed:    ints[0] == 1,
+		Remaining:  int(ints[1]),
+		RetryAfter: time.Duration(ints[2]) * time.Millisecond,
+	}, nil
+}
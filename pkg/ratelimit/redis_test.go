@@ -0,0 +1,65 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/lborres/kuta/core"
+)
+
+// Requirement: The Redis limiter runs the sliding window script against the
+// prefixed key and maps its reply to a decision.
+func TestRedis_Allow(t *testing.T) {
+	tests := []struct {
+		name     string
+		reply    any
+		replyErr error
+		want     core.RateLimitDecision
+		wantErr  bool
+	}{
+		{
+			name:  "admitted",
+			reply: []any{int64(1), int64(4), int64(0)},
+			want:  core.RateLimitDecision{Allowed: true, Remaining: 4},
+		},
+		{
+			name:  "refused",
+			reply: []any{int64(0), int64(0), int64(1500)},
+			want:  core.RateLimitDecision{RetryAfter: 1500 * time.Millisecond},
+		},
+		{name: "malformed reply", reply: "OK", wantErr: true},
+		{name: "redis error", replyErr: errors.New("connection refused"), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			var gotKeys []string
+			var gotArgs []any
+			client := RedisEvalFunc(func(ctx context.Context, script string, keys []string, args ...any) (any, error) {
+				gotKeys, gotArgs = keys, args
+				return tt.reply, tt.replyErr
+			})
+			limiter := NewRedis(client, core.RateLimitConfig{Limit: 5, Window: time.Minute}, RedisOptions{Prefix: "test:"})
+
+			// Act
+			got, err := limiter.Allow("signin:10.0.0.1")
+
+			// Assert
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("expected error %v, got %v", tt.wantErr, err)
+			}
+			if got != tt.want {
+				t.Errorf("expected %+v, got %+v", tt.want, got)
+			}
+			if len(gotKeys) != 1 || gotKeys[0] != "test:signin:10.0.0.1" {
+				t.Errorf("expected prefixed key, got %v", gotKeys)
+			}
+			if len(gotArgs) != 3 || gotArgs[0] != int64(60000) || gotArgs[1] != 5 {
+				t.Errorf("expected window and limit arguments, got %v", gotArgs)
+			}
+		})
+	}
+}
@@ -0,0 +1,42 @@
+// Package revocation provides core.RevocationBus implementations.
+package revocation
+
+import (
+	"sync"
+
+	"github.com/lborres/kuta/core"
+)
+
+// LocalBus delivers revocations to subscribers in the same process. It suits
+// tests and apps running several SessionManagers side by side; deployments
+// with multiple processes need a networked bus (Redis, NATS, ...).
+type LocalBus struct {
+	mu       sync.RWMutex
+	handlers []func(core.Revocation)
+}
+
+var _ core.RevocationBus = (*LocalBus)(nil)
+
+// NewLocalBus creates an in-process revocation bus
+func NewLocalBus() *LocalBus {
+	return &LocalBus{}
+}
+
+// Publish delivers revocation synchronously to every subscriber
+func (b *LocalBus) Publish(revocation core.Revocation) error {
+	b.mu.RLock()
+	handlers := append([]func(core.Revocation){}, b.handlers...)
+	b.mu.RUnlock()
+
+	for _, handler := range handlers {
+		handler(revocation)
+	}
+	return nil
+}
+
+// Subscribe registers handler for all future revocations
+func (b *LocalBus) Subscribe(handler func(core.Revocation)) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.handlers = append(b.handlers, handler)
+}
@@ -0,0 +1,30 @@
+package revocation
+
+import (
+	"testing"
+
+	"github.com/lborres/kuta/core"
+)
+
+// Requirement: LocalBus delivers every published revocation to every subscriber.
+func TestLocalBus_PublishSubscribe(t *testing.T) {
+	// Arrange
+	bus := NewLocalBus()
+	var first, second []core.Revocation
+	bus.Subscribe(func(r core.Revocation) { first = append(first, r) })
+	bus.Subscribe(func(r core.Revocation) { second = append(second, r) })
+
+	// Act
+	err := bus.Publish(core.Revocation{Origin: "a", TokenHashes: []string{"h1"}})
+
+	// Assert
+	if err != nil {
+		t.Fatalf("Publish() error = %v", err)
+	}
+	if len(first) != 1 || len(second) != 1 {
+		t.Fatalf("subscribers received %d and %d revocations, want 1 each", len(first), len(second))
+	}
+	if first[0].Origin != "a" || first[0].TokenHashes[0] != "h1" {
+		t.Errorf("unexpected revocation %+v", first[0])
+	}
+}
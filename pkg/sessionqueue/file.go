@@ -0,0 +1,219 @@
+// Package sessionqueue provides a core.SessionQueue that survives restarts,
+// for deferred session persistence.
+//
+// The queue is an append-only file of JSON lines, each queueing a session or
+// removing some, synced to disk before Put and Remove return. It is
+// compacted when opened and whenever removed sessions make up most of it.
+// Like a backup, it holds session token hashes and data; keep it as safe as
+// the database.
+package sessionqueue
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/lborres/kuta/core"
+	"github.com/lborres/kuta/pkg/cache"
+)
+
+// compactAfter is how many records the file may hold beyond twice the
+// queued sessions before it is rewritten
+const compactAfter = 1024
+
+// ErrCorrupt is returned by Open for a file that isn't a session queue
+var ErrCorrupt = errors.New("sessionqueue: corrupt queue file")
+
+// File is a core.SessionQueue kept in a file. It is safe for concurrent
+// use, but only one File may have a path open at a time.
+type File struct {
+	mu      sync.Mutex
+	path    string
+	file    *os.File
+	index   *cache.InMemorySessionQueue
+	queued  map[string]bool
+	records int // lines in the file
+}
+
+var _ core.SessionQueue = (*File)(nil)
+
+// record is one line of the file: a queued session or removed IDs
+type record struct {
+	Put    *session `json:"put,omitempty"`
+	Remove []string `json:"remove,omitempty"`
+}
+
+// session adds the fields core.Session keeps out of JSON
+type session struct {
+	*core.Session
+	TokenHash string            `json:"tokenHash"`
+	Data      map[string]string `json:"data,omitempty"`
+}
+
+// Open opens the queue at path, creating it when missing, and loads the
+// sessions it holds. A line cut short by a crash while it was written is
+// dropped; the Put it belonged to hadn't returned.
+func Open(path string) (*File, error) {
+	q := &File{
+		path:   path,
+		index:  cache.NewInMemorySessionQueue(),
+		queued: make(map[string]bool),
+	}
+	if err := q.load(); err != nil {
+		return nil, err
+	}
+	if err := q.compact(); err != nil {
+		return nil, err
+	}
+	return q, nil
+}
+
+func (q *File) load() error {
+	f, err := os.Open(q.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	reader := bufio.NewReader(f)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if errors.Is(err, io.EOF) {
+			// Only a torn final write lacks its newline
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		var r record
+		if err := json.Unmarshal(line, &r); err != nil || (r.Put != nil && r.Put.Session == nil) {
+			return fmt.Errorf("%w: %s", ErrCorrupt, q.path)
+		}
+		q.apply(&r)
+	}
+}
+
+// apply updates the index with r. Callers hold mu or own q.
+func (q *File) apply(r *record) {
+	if r.Put != nil {
+		s := *r.Put.Session
+		s.TokenHash = r.Put.TokenHash
+		s.Data = r.Put.Data
+		_ = q.index.Put(&s)
+		q.queued[s.ID] = true
+	}
+	if len(r.Remove) > 0 {
+		_ = q.index.Remove(r.Remove...)
+		for _, id := range r.Remove {
+			delete(q.queued, id)
+		}
+	}
+}
+
+// append writes r to the file and syncs it, then applies it
+func (q *File) append(r *record) error {
+	line, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	if _, err := q.file.Write(append(line, '\n')); err != nil {
+		return err
+	}
+	if err := q.file.Sync(); err != nil {
+		return err
+	}
+	q.apply(r)
+	q.records++
+	if q.records > 2*len(q.queued)+compactAfter {
+		return q.compact()
+	}
+	return nil
+}
+
+// compact rewrites the file with only the queued sessions, replacing the
+// old one atomically
+func (q *File) compact() error {
+	sessions, err := q.index.Pending()
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(q.path), filepath.Base(q.path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	writer := bufio.NewWriter(tmp)
+	encoder := json.NewEncoder(writer)
+	for _, s := range sessions {
+		if err := encoder.Encode(record{Put: &session{Session: s, TokenHash: s.TokenHash, Data: s.Data}}); err != nil {
+			tmp.Close()
+			return err
+		}
+	}
+	if err := writer.Flush(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), q.path); err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(q.path, os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return err
+	}
+	if q.file != nil {
+		q.file.Close()
+	}
+	q.file = f
+	q.records = len(sessions)
+	return nil
+}
+
+// Put queues session, replacing a queued session with the same ID
+func (q *File) Put(s *core.Session) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.append(&record{Put: &session{Session: s, TokenHash: s.TokenHash, Data: s.Data}})
+}
+
+// Pending returns the queued sessions, oldest first
+func (q *File) Pending() ([]*core.Session, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.index.Pending()
+}
+
+// Remove drops the sessions with ids from the queue
+func (q *File) Remove(ids ...string) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if len(ids) == 0 {
+		return nil
+	}
+	return q.append(&record{Remove: ids})
+}
+
+// Close closes the file. Sessions still queued are loaded by the next Open.
+func (q *File) Close() error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.file.Close()
+}
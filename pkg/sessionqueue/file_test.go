@@ -0,0 +1,93 @@
+package sessionqueue
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/lborres/kuta/core"
+)
+
+// Requirement: Sessions queued in a file, with their token hash and data,
+// are there again when it is reopened, minus those removed and a line cut
+// short by a crash.
+func TestFile_Reopen(t *testing.T) {
+	tests := []struct {
+		name    string
+		trailer string
+		wantErr error
+	}{
+		{name: "clean"},
+		{name: "torn write", trailer: `{"put":{"id":"session-9"`},
+		{name: "corrupt", trailer: "not json\n", wantErr: ErrCorrupt},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// Arrange
+			path := filepath.Join(t.TempDir(), "sessions.queue")
+			queue, err := Open(path)
+			if err != nil {
+				t.Fatalf("Open() error = %v", err)
+			}
+			expiresAt := time.Now().Add(time.Hour).UTC().Truncate(time.Second)
+			_ = queue.Put(&core.Session{ID: "session-1", TokenHash: "hash-1", ExpiresAt: expiresAt, Data: map[string]string{"csrf": "x"}})
+			_ = queue.Put(&core.Session{ID: "session-2", TokenHash: "hash-2", ExpiresAt: expiresAt})
+			_ = queue.Remove("session-2")
+			_ = queue.Close()
+			f, _ := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0o600)
+			_, _ = f.WriteString(test.trailer)
+			_ = f.Close()
+
+			// Act
+			reopened, err := Open(path)
+
+			// Assert
+			if !errors.Is(err, test.wantErr) {
+				t.Fatalf("Open() error = %v, want %v", err, test.wantErr)
+			}
+			if test.wantErr != nil {
+				return
+			}
+			defer reopened.Close()
+			pending, err := reopened.Pending()
+			if err != nil || len(pending) != 1 {
+				t.Fatalf("Pending() = %d sessions, %v; want 1", len(pending), err)
+			}
+			s := pending[0]
+			if s.ID != "session-1" || s.TokenHash != "hash-1" || s.Data["csrf"] != "x" || !s.ExpiresAt.Equal(expiresAt) {
+				t.Errorf("session = %+v, want session-1 with its token hash and data", s)
+			}
+		})
+	}
+}
+
+// Requirement: The file is compacted once removed sessions make up most of
+// it, keeping the sessions still queued.
+func TestFile_Compacts(t *testing.T) {
+	// Arrange
+	path := filepath.Join(t.TempDir(), "sessions.queue")
+	queue, err := Open(path)
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	defer queue.Close()
+	_ = queue.Put(&core.Session{ID: "kept", TokenHash: "hash"})
+
+	// Act
+	for range compactAfter {
+		_ = queue.Put(&core.Session{ID: "churn", TokenHash: "churn"})
+		_ = queue.Remove("churn")
+	}
+
+	// Assert
+	if queue.records > compactAfter {
+		t.Errorf("file holds %d records, want it compacted", queue.records)
+	}
+	pending, err := queue.Pending()
+	if err != nil || len(pending) != 1 || pending[0].ID != "kept" {
+		t.Errorf("Pending() = %v, %v; want the kept session", pending, err)
+	}
+}
@@ -0,0 +1,121 @@
+package shard
+
+import "sync"
+
+const defaultIndexSize = 100_000
+
+// TokenIndex remembers which shard holds a session or refresh token hash.
+//
+// Entries are only hints: a missing or stale entry makes Storage fall back to
+// querying every shard and then repair the entry. That keeps lookups correct
+// while data is being moved between shards, and lets a shared index (Redis,
+// a dedicated table, ...) be dropped in for multi-instance deployments.
+type TokenIndex interface {
+	Lookup(tokenHash string) (shard int, ok bool)
+	Store(tokenHash string, shard int)
+	Remove(tokenHash string)
+}
+
+// MemoryIndex is a bounded in-process TokenIndex
+type MemoryIndex struct {
+	mu      sync.RWMutex
+	entries map[string]int
+	maxSize int
+}
+
+var _ TokenIndex = (*MemoryIndex)(nil)
+
+// NewMemoryIndex creates an index holding up to maxSize entries
+// (100,000 when maxSize <= 0).
+func NewMemoryIndex(maxSize int) *MemoryIndex {
+	if maxSize <= 0 {
+		maxSize = defaultIndexSize
+	}
+	return &MemoryIndex{
+		entries: make(map[string]int),
+		maxSize: maxSize,
+	}
+}
+
+// Lookup returns the shard recorded for tokenHash
+func (m *MemoryIndex) Lookup(tokenHash string) (int, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	shard, ok := m.entries[tokenHash]
+	return shard, ok
+}
+
+// Store records that tokenHash lives on shard. When the index is full an
+// arbitrary entry is dropped, which only costs a fan-out lookup later.
+func (m *MemoryIndex) Store(tokenHash string, shard int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.entries[tokenHash]; !ok && len(m.entries) >= m.maxSize {
+		for key := range m.entries {
+			delete(m.entries, key)
+			break
+		}
+	}
+	m.entries[tokenHash] = shard
+}
+
+// Remove forgets tokenHash
+func (m *MemoryIndex) Remove(tokenHash string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.entries, tokenHash)
+}
+
+// EmailIndex reserves each email for one user across all shards. A shard
+// only keeps emails unique among its own users, so two sign-ups with the
+// same email whose IDs hash to different shards would both succeed without
+// it.
+//
+// Unlike TokenIndex entries, reservations are authoritative, so deployments
+// running several instances need a shared implementation (Redis SET NX, a
+// table with a unique key, ...). Storage takes over a reservation whose user
+// no longer exists, so one left behind by a crash or a purge doesn't block
+// the email.
+type EmailIndex interface {
+	// Reserve records userID as the holder of email unless another user
+	// holds it already, and returns the holder
+	Reserve(email, userID string) (holder string)
+	// Release drops the reservation of email if userID holds it
+	Release(email, userID string)
+}
+
+// MemoryEmailIndex is an in-process EmailIndex. It holds an entry per
+// reserved email and starts empty on every restart, which Storage makes up
+// for by also checking every shard on sign-up.
+type MemoryEmailIndex struct {
+	mu      sync.Mutex
+	holders map[string]string
+}
+
+var _ EmailIndex = (*MemoryEmailIndex)(nil)
+
+// NewMemoryEmailIndex creates an empty email index
+func NewMemoryEmailIndex() *MemoryEmailIndex {
+	return &MemoryEmailIndex{holders: make(map[string]string)}
+}
+
+// Reserve records userID as the holder of email unless it is taken
+func (m *MemoryEmailIndex) Reserve(email, userID string) string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if holder, ok := m.holders[email]; ok {
+		return holder
+	}
+	m.holders[email] = userID
+	return userID
+}
+
+// Release drops the reservation of email if userID holds it
+func (m *MemoryEmailIndex) Release(email, userID string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.holders[email] == userID {
+		delete(m.holders, email)
+	}
+}
@@ -0,0 +1,871 @@
+// Package shard provides a core.StorageProvider that spreads users across
+// several underlying storage adapters.
+package shard
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"slices"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/lborres/kuta/core"
+)
+
+// ErrNoShards is returned by New when no shards are given
+var ErrNoShards = errors.New("shard: at least one shard is required")
+
+// ErrInvalidShardCounts is returned by New when Options.PreviousShardCounts
+// isn't increasing or doesn't stay below the number of shards
+var ErrInvalidShardCounts = errors.New("shard: previous shard counts must increase and stay below the number of shards")
+
+// maxReserveAttempts bounds how often reserveEmail takes over a stale
+// reservation before giving up
+const maxReserveAttempts = 3
+
+// Options configures a sharded Storage
+type Options struct {
+	// Index records which shard holds each session/refresh token hash.
+	// Defaults to NewMemoryIndex(0).
+	Index TokenIndex
+
+	// Emails reserves each email for one user across shards. Defaults to
+	// NewMemoryEmailIndex(), which only covers a single instance.
+	Emails EmailIndex
+
+	// PreviousShardCounts lists how many shards there were before shards
+	// were appended, oldest first: []int{2} after growing from two shards to
+	// three, []int{2, 3} after growing again to four.
+	PreviousShardCounts []int
+}
+
+// Storage routes every call to the shard owning the user involved.
+//
+// A user and all of their accounts, sessions and refresh tokens live on the
+// shard picked by rendezvous hashing of the user ID, so foreign keys keep
+// working inside each shard. Shards must not be reordered or removed once
+// they hold data.
+//
+// Rendezvous hashing means appending a shard only changes the shard of the
+// users that now hash to it, but their data stays where it was. List the
+// earlier shard counts in Options.PreviousShardCounts and calls for such a
+// user look for it on the shards it used to hash to, at the cost of one
+// lookup per shard tried; users whose shard didn't change cost nothing
+// extra. To drop the fallback, copy each user still on an old shard, with
+// its accounts, sessions, refresh tokens and security settings, to
+// ShardFor(user.ID), delete it from the old shard, and then remove the counts.
+//
+// Calls that don't carry a user ID (lookups by email, token hash or record
+// ID, and expiry sweeps) consult the TokenIndex where possible and otherwise
+// query every shard.
+type Storage struct {
+	shards   []core.StorageProvider
+	index    TokenIndex
+	emails   EmailIndex
+	previous []int
+}
+
+var (
+	_ core.StorageProvider    = (*Storage)(nil)
+	_ core.Pinger             = (*Storage)(nil)
+	_ core.CapabilityReporter = (*Storage)(nil)
+	_ core.DeletedUserReader  = (*Storage)(nil)
+)
+
+// New creates a sharded storage over shards
+func New(shards []core.StorageProvider, opts ...Options) (*Storage, error) {
+	if len(shards) == 0 {
+		return nil, ErrNoShards
+	}
+
+	var o Options
+	if len(opts) > 0 {
+		o = opts[0]
+	}
+	if o.Index == nil {
+		o.Index = NewMemoryIndex(0)
+	}
+	if o.Emails == nil {
+		o.Emails = NewMemoryEmailIndex()
+	}
+	for i, n := range o.PreviousShardCounts {
+		if n <= 0 || n >= len(shards) || (i > 0 && n <= o.PreviousShardCounts[i-1]) {
+			return nil, ErrInvalidShardCounts
+		}
+	}
+
+	return &Storage{
+		shards:   shards,
+		index:    o.Index,
+		emails:   o.Emails,
+		previous: o.PreviousShardCounts,
+	}, nil
+}
+
+// Ping checks every shard that implements core.Pinger, reporting which
+// shard failed
+func (s *Storage) Ping() error {
+	for i, shard := range s.shards {
+		if pinger, ok := shard.(core.Pinger); ok {
+			if err := pinger.Ping(); err != nil {
+				return fmt.Errorf("shard %d: %w", i, err)
+			}
+		}
+	}
+	return nil
+}
+
+// Capabilities returns what every shard supports
+func (s *Storage) Capabilities() core.StorageCapabilities {
+	capabilities := core.StorageCapabilities{Transactions: true, SoftDelete: true, Search: true}
+	for _, shard := range s.shards {
+		c := core.CapabilitiesOf(shard)
+		capabilities.Transactions = capabilities.Transactions && c.Transactions
+		capabilities.SoftDelete = capabilities.SoftDelete && c.SoftDelete
+		capabilities.Search = capabilities.Search && c.Search
+	}
+	return capabilities
+}
+
+// ShardFor returns the index of the shard userID hashes to, where new data
+// of the user is written
+func (s *Storage) ShardFor(userID string) int {
+	return rendezvous(userID, len(s.shards))
+}
+
+// rendezvous returns which of the first n shards key hashes to
+func rendezvous(key string, n int) int {
+	best, bestScore := 0, uint64(0)
+	for i := range n {
+		h := fnv.New64a()
+		h.Write([]byte(key))
+		h.Write([]byte{0})
+		h.Write([]byte(strconv.Itoa(i)))
+		if score := h.Sum64(); i == 0 || score > bestScore {
+			best, bestScore = i, score
+		}
+	}
+	return best
+}
+
+// homes returns the shards key has hashed to, current first, then going back
+// through Options.PreviousShardCounts
+func (s *Storage) homes(key string) []int {
+	homes := []int{s.ShardFor(key)}
+	for i := len(s.previous) - 1; i >= 0; i-- {
+		if home := rendezvous(key, s.previous[i]); !slices.Contains(homes, home) {
+			homes = append(homes, home)
+		}
+	}
+	return homes
+}
+
+// userShard returns the index of the shard holding userID: the one it
+// hashes to, or for a user created before shards were appended, the one it
+// hashed to back then. Users that exist nowhere get the one they hash to.
+// Soft-deleted users are found on shards implementing
+// core.DeletedUserReader, so their sessions and restore reach them.
+func (s *Storage) userShard(ctx context.Context, userID string) (int, error) {
+	homes := s.homes(userID)
+	if len(homes) == 1 {
+		return homes[0], nil
+	}
+	for _, i := range homes {
+		_, err := getUserIncludingDeleted(ctx, s.shards[i], userID)
+		if err == nil {
+			return i, nil
+		}
+		if !errors.Is(err, core.ErrUserNotFound) {
+			return -1, err
+		}
+	}
+	return homes[0], nil
+}
+
+// getUserIncludingDeleted finds a user, soft-deleted or not, on shards
+// that can read deleted users
+func getUserIncludingDeleted(ctx context.Context, shard core.StorageProvider, id string) (*core.User, error) {
+	if reader, ok := shard.(core.DeletedUserReader); ok {
+		return reader.GetUserByIDIncludingDeleted(ctx, id)
+	}
+	return shard.GetUserByID(ctx, id)
+}
+
+func (s *Storage) forUser(ctx context.Context, userID string) (core.StorageProvider, error) {
+	i, err := s.userShard(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	return s.shards[i], nil
+}
+
+// findFirst calls lookup on each shard until one succeeds. notFound is
+// returned when every shard reports it; any other error aborts the search.
+func findFirst[T any](s *Storage, notFound error, lookup func(core.StorageProvider) (T, error)) (T, int, error) {
+	var zero T
+	for i, shard := range s.shards {
+		v, err := lookup(shard)
+		if err == nil {
+			return v, i, nil
+		}
+		if !errors.Is(err, notFound) {
+			return zero, -1, err
+		}
+	}
+	return zero, -1, notFound
+}
+
+// sumAll calls op on every shard and adds up the counts
+func (s *Storage) sumAll(op func(core.StorageProvider) (int, error)) (int, error) {
+	total := 0
+	for _, shard := range s.shards {
+		n, err := op(shard)
+		if err != nil {
+			return total, err
+		}
+		total += n
+	}
+	return total, nil
+}
+
+// deleteAll calls op on every shard. Shards that don't hold the record may
+// report notFound; it is only returned when all of them do.
+func (s *Storage) deleteAll(notFound error, op func(core.StorageProvider) error) error {
+	missing := 0
+	for _, shard := range s.shards {
+		err := op(shard)
+		if errors.Is(err, notFound) {
+			missing++
+			continue
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if missing == len(s.shards) {
+		return notFound
+	}
+	return nil
+}
+
+// byHash looks tokenHash up on the indexed shard first, then on every shard,
+// keeping the index in sync with where it was found.
+func byHash[T any](s *Storage, tokenHash string, notFound error, lookup func(core.StorageProvider) (T, error)) (T, error) {
+	if i, ok := s.index.Lookup(tokenHash); ok && i < len(s.shards) {
+		v, err := lookup(s.shards[i])
+		if err == nil {
+			return v, nil
+		}
+		if !errors.Is(err, notFound) {
+			return v, err
+		}
+		// Stale hint, e.g. the user was moved to another shard
+	}
+
+	v, i, err := findFirst(s, notFound, lookup)
+	if err != nil {
+		if errors.Is(err, notFound) {
+			s.index.Remove(tokenHash)
+		}
+		return v, err
+	}
+	s.index.Store(tokenHash, i)
+	return v, nil
+}
+
+// ---- UserStorage ----
+
+// CreateUser reserves the user's email in the EmailIndex, then creates the
+// user on the shard its ID hashes to
+func (s *Storage) CreateUser(ctx context.Context, u *core.User) error {
+	if err := s.reserveEmail(ctx, u.Email, u.ID); err != nil {
+		return err
+	}
+	if err := s.shards[s.ShardFor(u.ID)].CreateUser(ctx, u); err != nil {
+		s.emails.Release(u.Email, u.ID)
+		return err
+	}
+	return nil
+}
+
+// CreateUserIfNotExists reserves the email in the EmailIndex, which settles
+// concurrent sign-ups with the same email whose IDs hash to different shards,
+// and checks the other shards for a user the index doesn't know about (one
+// created before it, or before an in-memory index was last emptied).
+func (s *Storage) CreateUserIfNotExists(ctx context.Context, u *core.User) error {
+	if err := s.reserveEmail(ctx, u.Email, u.ID); err != nil {
+		return err
+	}
+	if err := s.createUserIfNotExists(ctx, u); err != nil {
+		s.emails.Release(u.Email, u.ID)
+		return err
+	}
+	return nil
+}
+
+func (s *Storage) createUserIfNotExists(ctx context.Context, u *core.User) error {
+	owner := s.ShardFor(u.ID)
+	for i, shard := range s.shards {
+		if i == owner {
+			continue
+		}
+		_, err := shard.GetUserByEmail(ctx, u.Email)
+		if err == nil {
+			return core.ErrUserExists
+		}
+		if !errors.Is(err, core.ErrUserNotFound) {
+			return err
+		}
+	}
+	return s.shards[owner].CreateUserIfNotExists(ctx, u)
+}
+
+// reserveEmail reserves email for userID. A reservation held by a user that
+// no longer exists (deleted, or never created because its instance crashed)
+// is taken over; a live holder makes it fail with core.ErrUserExists.
+func (s *Storage) reserveEmail(ctx context.Context, email, userID string) error {
+	for range maxReserveAttempts {
+		holder := s.emails.Reserve(email, userID)
+		if holder == userID {
+			return nil
+		}
+		_, err := s.GetUserByID(ctx, holder)
+		if err == nil {
+			return core.ErrUserExists
+		}
+		if !errors.Is(err, core.ErrUserNotFound) {
+			return err
+		}
+		s.emails.Release(email, holder)
+	}
+	return core.ErrUserExists
+}
+
+func (s *Storage) GetUserByID(ctx context.Context, id string) (*core.User, error) {
+	shard, err := s.forUser(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return shard.GetUserByID(ctx, id)
+}
+
+func (s *Storage) GetUserByIDIncludingDeleted(ctx context.Context, id string) (*core.User, error) {
+	shard, err := s.forUser(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return getUserIncludingDeleted(ctx, shard, id)
+}
+
+func (s *Storage) GetUserByEmail(ctx context.Context, email string) (*core.User, error) {
+	user, _, err := findFirst(s, core.ErrUserNotFound, func(shard core.StorageProvider) (*core.User, error) {
+		return shard.GetUserByEmail(ctx, email)
+	})
+	return user, err
+}
+
+// UpdateUser moves the user's email reservation along with an email change
+func (s *Storage) UpdateUser(ctx context.Context, u *core.User) error {
+	i, err := s.userShard(ctx, u.ID)
+	if err != nil {
+		return err
+	}
+	current, err := s.shards[i].GetUserByID(ctx, u.ID)
+	if err != nil {
+		return err
+	}
+	if current.Email == u.Email {
+		return s.shards[i].UpdateUser(ctx, u)
+	}
+
+	if err := s.reserveEmail(ctx, u.Email, u.ID); err != nil {
+		return err
+	}
+	if err := s.shards[i].UpdateUser(ctx, u); err != nil {
+		s.emails.Release(u.Email, u.ID)
+		return err
+	}
+	s.emails.Release(current.Email, u.ID)
+	return nil
+}
+
+// DeleteUser also releases the user's email reservation
+func (s *Storage) DeleteUser(ctx context.Context, id string) error {
+	i, err := s.userShard(ctx, id)
+	if err != nil {
+		return err
+	}
+	user, err := getUserIncludingDeleted(ctx, s.shards[i], id)
+	if err != nil {
+		return err
+	}
+	if err := s.shards[i].DeleteUser(ctx, id); err != nil {
+		return err
+	}
+	s.emails.Release(user.Email, id)
+	return nil
+}
+
+// SoftDeleteUser keeps the user's email reserved until it is purged or taken
+// over by a new sign-up
+func (s *Storage) SoftDeleteUser(ctx context.Context, id string) error {
+	shard, err := s.forUser(ctx, id)
+	if err != nil {
+		return err
+	}
+	return shard.SoftDeleteUser(ctx, id)
+}
+
+// RestoreUser looks for the soft-deleted user on every shard its ID has
+// hashed to. A user whose email was taken over while it was deleted is
+// deleted again and the restore fails with core.ErrUserExists.
+func (s *Storage) RestoreUser(ctx context.Context, id string, deletedAfter time.Time) error {
+	for _, i := range s.homes(id) {
+		err := s.shards[i].RestoreUser(ctx, id, deletedAfter)
+		if errors.Is(err, core.ErrUserNotFound) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		user, err := s.shards[i].GetUserByID(ctx, id)
+		if err != nil {
+			return err
+		}
+		if err := s.reserveEmail(ctx, user.Email, id); err != nil {
+			_ = s.shards[i].SoftDeleteUser(ctx, id)
+			return err
+		}
+		return nil
+	}
+	return core.ErrUserNotFound
+}
+
+// ListUsersByStatus merges the oldest matching users from every shard. Each
+// shard is asked for offset+limit rows so the page can be cut after merging.
+func (s *Storage) ListUsersByStatus(ctx context.Context, status string, limit, offset int) ([]*core.User, int, error) {
+	var users []*core.User
+	total := 0
+	for _, shard := range s.shards {
+		result, n, err := shard.ListUsersByStatus(ctx, status, offset+limit, 0)
+		if err != nil {
+			return nil, 0, err
+		}
+		users = append(users, result...)
+		total += n
+	}
+
+	sort.SliceStable(users, func(i, j int) bool {
+		return users[i].CreatedAt.Before(users[j].CreatedAt)
+	})
+
+	if offset >= len(users) {
+		return nil, total, nil
+	}
+	users = users[offset:]
+	if limit > 0 && len(users) > limit {
+		users = users[:limit]
+	}
+	return users, total, nil
+}
+
+func (s *Storage) SetUserStatus(ctx context.Context, id, from, to string) error {
+	shard, err := s.forUser(ctx, id)
+	if err != nil {
+		return err
+	}
+	return shard.SetUserStatus(ctx, id, from, to)
+}
+
+func (s *Storage) PurgeDeletedUsers(ctx context.Context, deletedBefore time.Time) (int, error) {
+	return s.sumAll(func(shard core.StorageProvider) (int, error) {
+		return shard.PurgeDeletedUsers(ctx, deletedBefore)
+	})
+}
+
+// ---- AccountStorage ----
+
+func (s *Storage) CreateAccount(ctx context.Context, a *core.Account) error {
+	shard, err := s.forUser(ctx, a.UserID)
+	if err != nil {
+		return err
+	}
+	return shard.CreateAccount(ctx, a)
+}
+
+func (s *Storage) GetAccountByID(ctx context.Context, id string) (*core.Account, error) {
+	account, _, err := findFirst(s, core.ErrUserNotFound, func(shard core.StorageProvider) (*core.Account, error) {
+		return shard.GetAccountByID(ctx, id)
+	})
+	return account, err
+}
+
+func (s *Storage) GetAccountByUserAndProvider(ctx context.Context, userID, providerID string) ([]*core.Account, error) {
+	shard, err := s.forUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	return shard.GetAccountByUserAndProvider(ctx, userID, providerID)
+}
+
+func (s *Storage) GetAccountByProvider(ctx context.Context, providerID, accountID string) (*core.Account, error) {
+	account, _, err := findFirst(s, core.ErrUserNotFound, func(shard core.StorageProvider) (*core.Account, error) {
+		return shard.GetAccountByProvider(ctx, providerID, accountID)
+	})
+	return account, err
+}
+
+func (s *Storage) UpdateAccount(ctx context.Context, a *core.Account) error {
+	shard, err := s.forUser(ctx, a.UserID)
+	if err != nil {
+		return err
+	}
+	return shard.UpdateAccount(ctx, a)
+}
+
+func (s *Storage) DeleteAccount(ctx context.Context, id string) error {
+	return s.deleteAll(core.ErrUserNotFound, func(shard core.StorageProvider) error {
+		return shard.DeleteAccount(ctx, id)
+	})
+}
+
+// GetExpiringAccounts merges the soonest-expiring accounts of every shard
+func (s *Storage) GetExpiringAccounts(ctx context.Context, before time.Time, limit int) ([]*core.Account, error) {
+	var accounts []*core.Account
+	for _, shard := range s.shards {
+		result, err := shard.GetExpiringAccounts(ctx, before, limit)
+		if err != nil {
+			return nil, err
+		}
+		accounts = append(accounts, result...)
+	}
+
+	sort.SliceStable(accounts, func(i, j int) bool {
+		return accounts[i].ExpiresAt.Before(*accounts[j].ExpiresAt)
+	})
+	if len(accounts) > limit {
+		accounts = accounts[:limit]
+	}
+	return accounts, nil
+}
+
+// ---- SessionStorage ----
+
+func (s *Storage) CreateSession(ctx context.Context, session *core.Session) error {
+	i, err := s.userShard(ctx, session.UserID)
+	if err != nil {
+		return err
+	}
+	if err := s.shards[i].CreateSession(ctx, session); err != nil {
+		return err
+	}
+	s.index.Store(session.TokenHash, i)
+	return nil
+}
+
+func (s *Storage) GetSessionByHash(ctx context.Context, tokenHash string) (*core.Session, error) {
+	return byHash(s, tokenHash, core.ErrSessionNotFound, func(shard core.StorageProvider) (*core.Session, error) {
+		return shard.GetSessionByHash(ctx, tokenHash)
+	})
+}
+
+// GetSessionWithUserByHash asks the shard holding the session, which also
+// holds its user
+func (s *Storage) GetSessionWithUserByHash(ctx context.Context, tokenHash string) (*core.Session, *core.User, error) {
+	type found struct {
+		session *core.Session
+		user    *core.User
+	}
+	v, err := byHash(s, tokenHash, core.ErrSessionNotFound, func(shard core.StorageProvider) (found, error) {
+		session, user, err := shard.GetSessionWithUserByHash(ctx, tokenHash)
+		return found{session, user}, err
+	})
+	return v.session, v.user, err
+}
+
+// GetSessionsByHashes batches indexed hashes per shard and asks every shard
+// for the rest.
+func (s *Storage) GetSessionsByHashes(ctx context.Context, tokenHashes []string) ([]*core.Session, error) {
+	perShard := make([][]string, len(s.shards))
+	var unknown []string
+	for _, hash := range tokenHashes {
+		if i, ok := s.index.Lookup(hash); ok && i < len(s.shards) {
+			perShard[i] = append(perShard[i], hash)
+		} else {
+			unknown = append(unknown, hash)
+		}
+	}
+
+	var sessions []*core.Session
+	found := make(map[string]bool, len(tokenHashes))
+	for i, shard := range s.shards {
+		hashes := append(append([]string{}, perShard[i]...), unknown...)
+		if len(hashes) == 0 {
+			continue
+		}
+		result, err := shard.GetSessionsByHashes(ctx, hashes)
+		if err != nil {
+			return nil, err
+		}
+		for _, session := range result {
+			found[session.TokenHash] = true
+			s.index.Store(session.TokenHash, i)
+		}
+		sessions = append(sessions, result...)
+	}
+
+	// Indexed hashes that turned out to be stale are retried everywhere
+	var missed []string
+	for i := range s.shards {
+		for _, hash := range perShard[i] {
+			if !found[hash] {
+				missed = append(missed, hash)
+			}
+		}
+	}
+	if len(missed) == 0 {
+		return sessions, nil
+	}
+	for _, hash := range missed {
+		session, err := s.GetSessionByHash(ctx, hash)
+		if errors.Is(err, core.ErrSessionNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, session)
+	}
+	return sessions, nil
+}
+
+func (s *Storage) GetSessionByID(ctx context.Context, id string) (*core.Session, error) {
+	session, _, err := findFirst(s, core.ErrSessionNotFound, func(shard core.StorageProvider) (*core.Session, error) {
+		return shard.GetSessionByID(ctx, id)
+	})
+	return session, err
+}
+
+func (s *Storage) GetUserSessions(ctx context.Context, userID string) ([]*core.Session, error) {
+	shard, err := s.forUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	return shard.GetUserSessions(ctx, userID)
+}
+
+func (s *Storage) UpdateSession(ctx context.Context, session *core.Session) error {
+	i, err := s.userShard(ctx, session.UserID)
+	if err != nil {
+		return err
+	}
+	if err := s.shards[i].UpdateSession(ctx, session); err != nil {
+		return err
+	}
+	s.index.Store(session.TokenHash, i)
+	return nil
+}
+
+func (s *Storage) DeleteSessionByID(ctx context.Context, id string) error {
+	return s.deleteAll(core.ErrSessionNotFound, func(shard core.StorageProvider) error {
+		return shard.DeleteSessionByID(ctx, id)
+	})
+}
+
+func (s *Storage) DeleteSessionByHash(ctx context.Context, tokenHash string) error {
+	defer s.index.Remove(tokenHash)
+	return s.deleteAll(core.ErrSessionNotFound, func(shard core.StorageProvider) error {
+		return shard.DeleteSessionByHash(ctx, tokenHash)
+	})
+}
+
+func (s *Storage) DeleteUserSessions(ctx context.Context, userID string) (int, error) {
+	shard, err := s.forUser(ctx, userID)
+	if err != nil {
+		return 0, err
+	}
+	return shard.DeleteUserSessions(ctx, userID)
+}
+
+func (s *Storage) DeleteExpiredSessions(ctx context.Context) (int, error) {
+	return s.sumAll(func(shard core.StorageProvider) (int, error) {
+		return shard.DeleteExpiredSessions(ctx)
+	})
+}
+
+// SearchSessions merges the newest sessions from every shard. Each shard is
+// asked for Offset+Limit rows so the page can be cut after merging.
+func (s *Storage) SearchSessions(ctx context.Context, filter core.SessionFilter) ([]*core.Session, int, error) {
+	if filter.UserID != "" {
+		shard, err := s.forUser(ctx, filter.UserID)
+		if err != nil {
+			return nil, 0, err
+		}
+		return shard.SearchSessions(ctx, filter)
+	}
+
+	perShard := filter
+	perShard.Offset = 0
+	perShard.Limit = filter.Offset + filter.Limit
+
+	var sessions []*core.Session
+	total := 0
+	for _, shard := range s.shards {
+		result, n, err := shard.SearchSessions(ctx, perShard)
+		if err != nil {
+			return nil, 0, err
+		}
+		sessions = append(sessions, result...)
+		total += n
+	}
+
+	sort.SliceStable(sessions, func(i, j int) bool {
+		return sessions[i].CreatedAt.After(sessions[j].CreatedAt)
+	})
+
+	if filter.Offset >= len(sessions) {
+		return nil, total, nil
+	}
+	sessions = sessions[filter.Offset:]
+	if filter.Limit > 0 && len(sessions) > filter.Limit {
+		sessions = sessions[:filter.Limit]
+	}
+	return sessions, total, nil
+}
+
+func (s *Storage) DeleteSessionsByIDs(ctx context.Context, ids []string) (int, error) {
+	return s.sumAll(func(shard core.StorageProvider) (int, error) {
+		return shard.DeleteSessionsByIDs(ctx, ids)
+	})
+}
+
+// SetSessionValue tries every shard, like DeleteSessionByID, since only the
+// session ID is known
+func (s *Storage) SetSessionValue(ctx context.Context, sessionID, key, value string) error {
+	return s.deleteAll(core.ErrSessionNotFound, func(shard core.StorageProvider) error {
+		return shard.SetSessionValue(ctx, sessionID, key, value)
+	})
+}
+
+func (s *Storage) DeleteSessionValue(ctx context.Context, sessionID, key string) error {
+	return s.deleteAll(core.ErrSessionNotFound, func(shard core.StorageProvider) error {
+		return shard.DeleteSessionValue(ctx, sessionID, key)
+	})
+}
+
+// ---- RefreshTokenStorage ----
+
+func (s *Storage) CreateRefreshToken(ctx context.Context, token *core.RefreshToken) error {
+	i, err := s.userShard(ctx, token.UserID)
+	if err != nil {
+		return err
+	}
+	if err := s.shards[i].CreateRefreshToken(ctx, token); err != nil {
+		return err
+	}
+	s.index.Store(token.TokenHash, i)
+	return nil
+}
+
+func (s *Storage) GetRefreshTokenByHash(ctx context.Context, tokenHash string) (*core.RefreshToken, error) {
+	return byHash(s, tokenHash, core.ErrRefreshTokenNotFound, func(shard core.StorageProvider) (*core.RefreshToken, error) {
+		return shard.GetRefreshTokenByHash(ctx, tokenHash)
+	})
+}
+
+func (s *Storage) RevokeRefreshToken(ctx context.Context, id string) error {
+	_, _, err := findFirst(s, core.ErrRefreshTokenNotFound, func(shard core.StorageProvider) (struct{}, error) {
+		return struct{}{}, shard.RevokeRefreshToken(ctx, id)
+	})
+	return err
+}
+
+func (s *Storage) RotateRefreshToken(ctx context.Context, id string) error {
+	_, _, err := findFirst(s, core.ErrRefreshTokenNotFound, func(shard core.StorageProvider) (struct{}, error) {
+		return struct{}{}, shard.RotateRefreshToken(ctx, id)
+	})
+	return err
+}
+
+func (s *Storage) RevokeSessionRefreshTokens(ctx context.Context, sessionID string) (int, error) {
+	return s.sumAll(func(shard core.StorageProvider) (int, error) {
+		return shard.RevokeSessionRefreshTokens(ctx, sessionID)
+	})
+}
+
+func (s *Storage) RevokeUserRefreshTokens(ctx context.Context, userID string) (int, error) {
+	shard, err := s.forUser(ctx, userID)
+	if err != nil {
+		return 0, err
+	}
+	return shard.RevokeUserRefreshTokens(ctx, userID)
+}
+
+func (s *Storage) GetActiveUserRefreshTokens(ctx context.Context, userID string) ([]*core.RefreshToken, error) {
+	shard, err := s.forUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	return shard.GetActiveUserRefreshTokens(ctx, userID)
+}
+
+func (s *Storage) DeleteExpiredRefreshTokens(ctx context.Context) (int, error) {
+	return s.sumAll(func(shard core.StorageProvider) (int, error) {
+		return shard.DeleteExpiredRefreshTokens(ctx)
+	})
+}
+
+// ---- VerificationTokenStorage ----
+
+// Verification tokens may belong to no user yet (invites), so they are placed
+// by their token hash instead, which every lookup has at hand.
+
+func (s *Storage) CreateVerificationToken(ctx context.Context, token *core.VerificationToken) error {
+	return s.shards[s.ShardFor(token.TokenHash)].CreateVerificationToken(ctx, token)
+}
+
+// ConsumeVerificationToken also tries the shards the hash hashed to before
+// shards were appended
+func (s *Storage) ConsumeVerificationToken(ctx context.Context, tokenHash, purpose string) (*core.VerificationToken, error) {
+	for _, i := range s.homes(tokenHash) {
+		token, err := s.shards[i].ConsumeVerificationToken(ctx, tokenHash, purpose)
+		if !errors.Is(err, core.ErrVerificationTokenNotFound) {
+			return token, err
+		}
+	}
+	return nil, core.ErrVerificationTokenNotFound
+}
+
+func (s *Storage) RevokeUserVerificationTokens(ctx context.Context, userID string, purposes ...string) (int, error) {
+	return s.sumAll(func(shard core.StorageProvider) (int, error) {
+		return shard.RevokeUserVerificationTokens(ctx, userID, purposes...)
+	})
+}
+
+func (s *Storage) PurgeVerificationTokens(ctx context.Context, expiredBefore, consumedBefore time.Time) (int, error) {
+	return s.sumAll(func(shard core.StorageProvider) (int, error) {
+		return shard.PurgeVerificationTokens(ctx, expiredBefore, consumedBefore)
+	})
+}
+
+// ---- UserSecurityStorage ----
+
+func (s *Storage) GetUserSecurity(ctx context.Context, userID string) (*core.UserSecurity, error) {
+	shard, err := s.forUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	return shard.GetUserSecurity(ctx, userID)
+}
+
+func (s *Storage) UpsertUserSecurity(ctx context.Context, settings *core.UserSecurity) error {
+	shard, err := s.forUser(ctx, settings.UserID)
+	if err != nil {
+		return err
+	}
+	return shard.UpsertUserSecurity(ctx, settings)
+}
@@ -0,0 +1,464 @@
+package shard
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/lborres/kuta/core"
+	"github.com/lborres/kuta/services"
+)
+
+func newTestStorage(t *testing.T, n int) (*Storage, []*services.FakeStorageProvider) {
+	t.Helper()
+	fakes := make([]*services.FakeStorageProvider, n)
+	shards := make([]core.StorageProvider, n)
+	for i := range fakes {
+		fakes[i] = services.NewFakeStorageProvider()
+		shards[i] = fakes[i]
+	}
+	storage, err := New(shards)
+	if err != nil {
+		t.Fatalf("New error: %v", err)
+	}
+	return storage, fakes
+}
+
+// userOnShard returns a user ID that hashes to shard
+func userOnShard(t *testing.T, storage *Storage, shard int) string {
+	t.Helper()
+	for i := 0; i < 1000; i++ {
+		id := fmt.Sprintf("user-%d", i)
+		if storage.ShardFor(id) == shard {
+			return id
+		}
+	}
+	t.Fatalf("no user ID found for shard %d", shard)
+	return ""
+}
+
+// Requirement: New rejects an empty shard list.
+func TestNew_NoShards(t *testing.T) {
+	_, err := New(nil)
+
+	if !errors.Is(err, ErrNoShards) {
+		t.Errorf("expected %v, got %v", ErrNoShards, err)
+	}
+}
+
+// Requirement: Users and their sessions land on the shard their ID hashes to,
+// and lookups by email and token hash, with or without the user, find them
+// from anywhere.
+func TestStorage_RoutesByUserID(t *testing.T) {
+	// Arrange
+	storage, fakes := newTestStorage(t, 3)
+	userID := userOnShard(t, storage, 2)
+	user := &core.User{ID: userID, Email: "a@example.com"}
+	session := &core.Session{ID: "s1", UserID: userID, TokenHash: "hash-1", ExpiresAt: time.Now().Add(time.Hour)}
+
+	// Act
+	if err := storage.CreateUserIfNotExists(t.Context(), user); err != nil {
+		t.Fatalf("CreateUserIfNotExists error: %v", err)
+	}
+	if err := storage.CreateSession(t.Context(), session); err != nil {
+		t.Fatalf("CreateSession error: %v", err)
+	}
+
+	// Assert
+	if _, err := fakes[2].GetUserByID(t.Context(), userID); err != nil {
+		t.Errorf("expected user on shard 2: %v", err)
+	}
+	if _, err := fakes[2].GetSessionByHash(t.Context(), "hash-1"); err != nil {
+		t.Errorf("expected session on shard 2: %v", err)
+	}
+	if got, err := storage.GetUserByEmail(t.Context(), "a@example.com"); err != nil || got.ID != userID {
+		t.Errorf("GetUserByEmail = %v, %v", got, err)
+	}
+	if got, err := storage.GetSessionByHash(t.Context(), "hash-1"); err != nil || got.ID != "s1" {
+		t.Errorf("GetSessionByHash = %v, %v", got, err)
+	}
+	if got, gotUser, err := storage.GetSessionWithUserByHash(t.Context(), "hash-1"); err != nil || got.ID != "s1" || gotUser == nil || gotUser.ID != userID {
+		t.Errorf("GetSessionWithUserByHash = %v, %v, %v", got, gotUser, err)
+	}
+}
+
+// Requirement: An email already taken on another shard is rejected.
+func TestStorage_CreateUserIfNotExists_ChecksOtherShards(t *testing.T) {
+	// Arrange
+	storage, _ := newTestStorage(t, 2)
+	first := &core.User{ID: userOnShard(t, storage, 0), Email: "dup@example.com"}
+	second := &core.User{ID: userOnShard(t, storage, 1), Email: "dup@example.com"}
+	if err := storage.CreateUserIfNotExists(t.Context(), first); err != nil {
+		t.Fatalf("CreateUserIfNotExists error: %v", err)
+	}
+
+	// Act
+	err := storage.CreateUserIfNotExists(t.Context(), second)
+
+	// Assert
+	if !errors.Is(err, core.ErrUserExists) {
+		t.Errorf("expected %v, got %v", core.ErrUserExists, err)
+	}
+}
+
+// Requirement: Concurrent sign-ups with one email whose IDs hash to
+// different shards create a single user.
+func TestStorage_CreateUserIfNotExists_ConcurrentEmail(t *testing.T) {
+	// Arrange
+	storage, _ := newTestStorage(t, 2)
+	users := []*core.User{
+		{ID: userOnShard(t, storage, 0), Email: "race@example.com"},
+		{ID: userOnShard(t, storage, 1), Email: "race@example.com"},
+	}
+	errs := make([]error, len(users))
+	var wg sync.WaitGroup
+
+	// Act
+	for i, user := range users {
+		wg.Go(func() {
+			errs[i] = storage.CreateUserIfNotExists(t.Context(), user)
+		})
+	}
+	wg.Wait()
+
+	// Assert
+	created := 0
+	for i, err := range errs {
+		switch {
+		case err == nil:
+			created++
+		case !errors.Is(err, core.ErrUserExists):
+			t.Errorf("user %d: unexpected error %v", i, err)
+		}
+	}
+	if created != 1 {
+		t.Errorf("created %d users, want 1 (errors %v)", created, errs)
+	}
+}
+
+// Requirement: An email is reserved by its live user only: a deleted user's
+// email can be signed up with again, and changing to a taken email fails.
+func TestStorage_EmailReservation(t *testing.T) {
+	tests := []struct {
+		name    string
+		act     func(t *testing.T, storage *Storage, holder, other *core.User) error
+		wantErr error
+	}{
+		{
+			name: "after delete",
+			act: func(t *testing.T, storage *Storage, holder, other *core.User) error {
+				if err := storage.DeleteUser(t.Context(), holder.ID); err != nil {
+					t.Fatalf("DeleteUser error: %v", err)
+				}
+				return storage.CreateUserIfNotExists(t.Context(), other)
+			},
+		},
+		{
+			name: "after soft delete",
+			act: func(t *testing.T, storage *Storage, holder, other *core.User) error {
+				if err := storage.SoftDeleteUser(t.Context(), holder.ID); err != nil {
+					t.Fatalf("SoftDeleteUser error: %v", err)
+				}
+				return storage.CreateUser(t.Context(), other)
+			},
+		},
+		{
+			name: "email change to a taken email",
+			act: func(t *testing.T, storage *Storage, holder, other *core.User) error {
+				other.Email = "other@example.com"
+				if err := storage.CreateUser(t.Context(), other); err != nil {
+					t.Fatalf("CreateUser error: %v", err)
+				}
+				changed := *other
+				changed.Email = holder.Email
+				return storage.UpdateUser(t.Context(), &changed)
+			},
+			wantErr: core.ErrUserExists,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// Arrange
+			storage, _ := newTestStorage(t, 2)
+			holder := &core.User{ID: userOnShard(t, storage, 0), Email: "taken@example.com"}
+			other := &core.User{ID: userOnShard(t, storage, 1), Email: "taken@example.com"}
+			if err := storage.CreateUserIfNotExists(t.Context(), holder); err != nil {
+				t.Fatalf("CreateUserIfNotExists error: %v", err)
+			}
+
+			// Act
+			err := test.act(t, storage, holder, other)
+
+			// Assert
+			if !errors.Is(err, test.wantErr) {
+				t.Errorf("error = %v, want %v", err, test.wantErr)
+			}
+		})
+	}
+}
+
+// Requirement: After appending a shard, users created before keep working
+// from the shard they hashed to, new data of theirs is written there, and
+// their verification tokens can still be consumed.
+func TestStorage_PreviousShardCounts(t *testing.T) {
+	// Arrange
+	fakes := []*services.FakeStorageProvider{services.NewFakeStorageProvider(), services.NewFakeStorageProvider(), services.NewFakeStorageProvider()}
+	before, err := New([]core.StorageProvider{fakes[0], fakes[1]})
+	if err != nil {
+		t.Fatalf("New error: %v", err)
+	}
+	after, err := New([]core.StorageProvider{fakes[0], fakes[1], fakes[2]}, Options{PreviousShardCounts: []int{2}})
+	if err != nil {
+		t.Fatalf("New error: %v", err)
+	}
+	userID := userOnShard(t, after, 2)
+	oldShard := before.ShardFor(userID)
+	if err := before.CreateUser(t.Context(), &core.User{ID: userID, Email: "old@example.com"}); err != nil {
+		t.Fatalf("CreateUser error: %v", err)
+	}
+	tokenHash := ""
+	for i := 0; tokenHash == ""; i++ {
+		if hash := fmt.Sprintf("hash-%d", i); after.ShardFor(hash) != before.ShardFor(hash) {
+			tokenHash = hash
+		}
+	}
+	token := &core.VerificationToken{ID: "v1", UserID: &userID, TokenHash: tokenHash, Purpose: "verify-email", ExpiresAt: time.Now().Add(time.Hour)}
+	if err := before.CreateVerificationToken(t.Context(), token); err != nil {
+		t.Fatalf("CreateVerificationToken error: %v", err)
+	}
+	session := &core.Session{ID: "s1", UserID: userID, TokenHash: "session-hash", ExpiresAt: time.Now().Add(time.Hour)}
+
+	// Act
+	_, getErr := after.GetUserByID(t.Context(), userID)
+	createErr := after.CreateSession(t.Context(), session)
+	_, consumeErr := after.ConsumeVerificationToken(t.Context(), tokenHash, "verify-email")
+
+	// Assert
+	if getErr != nil || createErr != nil || consumeErr != nil {
+		t.Fatalf("GetUserByID error = %v, CreateSession error = %v, ConsumeVerificationToken error = %v", getErr, createErr, consumeErr)
+	}
+	if _, err := fakes[oldShard].GetSessionByHash(t.Context(), "session-hash"); err != nil {
+		t.Errorf("expected session on the user's old shard %d: %v", oldShard, err)
+	}
+	if sessions, err := after.GetUserSessions(t.Context(), userID); err != nil || len(sessions) != 1 {
+		t.Errorf("GetUserSessions = %v, %v", sessions, err)
+	}
+}
+
+// Requirement: A user created before a shard was appended is still found on
+// its old shard once soft-deleted, so its sessions, restore and hard delete
+// reach it.
+func TestStorage_PreviousShardCounts_SoftDeletedUser(t *testing.T) {
+	// Arrange
+	fakes := []*services.FakeStorageProvider{services.NewFakeStorageProvider(), services.NewFakeStorageProvider(), services.NewFakeStorageProvider()}
+	before, err := New([]core.StorageProvider{fakes[0], fakes[1]})
+	if err != nil {
+		t.Fatalf("New error: %v", err)
+	}
+	after, err := New([]core.StorageProvider{fakes[0], fakes[1], fakes[2]}, Options{PreviousShardCounts: []int{2}})
+	if err != nil {
+		t.Fatalf("New error: %v", err)
+	}
+	userID := userOnShard(t, after, 2)
+	oldShard := before.ShardFor(userID)
+	if err := before.CreateUser(t.Context(), &core.User{ID: userID, Email: "old@example.com"}); err != nil {
+		t.Fatalf("CreateUser error: %v", err)
+	}
+	session := &core.Session{ID: "s1", UserID: userID, TokenHash: "session-hash", ExpiresAt: time.Now().Add(time.Hour)}
+	if err := before.CreateSession(t.Context(), session); err != nil {
+		t.Fatalf("CreateSession error: %v", err)
+	}
+	if err := after.SoftDeleteUser(t.Context(), userID); err != nil {
+		t.Fatalf("SoftDeleteUser error: %v", err)
+	}
+
+	// Act
+	deleted, getErr := after.GetUserByIDIncludingDeleted(t.Context(), userID)
+	sessions, sessionsErr := after.GetUserSessions(t.Context(), userID)
+	restoreErr := after.RestoreUser(t.Context(), userID, time.Time{})
+	_ = after.SoftDeleteUser(t.Context(), userID)
+	deleteErr := after.DeleteUser(t.Context(), userID)
+
+	// Assert
+	if getErr != nil || deleted.DeletedAt == nil {
+		t.Errorf("GetUserByIDIncludingDeleted = %+v, %v, want the deleted user", deleted, getErr)
+	}
+	if sessionsErr != nil || len(sessions) != 1 {
+		t.Errorf("GetUserSessions = %v, %v, want the session on old shard %d", sessions, sessionsErr, oldShard)
+	}
+	if restoreErr != nil {
+		t.Errorf("RestoreUser error: %v", restoreErr)
+	}
+	if deleteErr != nil {
+		t.Fatalf("DeleteUser error: %v", deleteErr)
+	}
+	if _, err := fakes[oldShard].GetUserByIDIncludingDeleted(t.Context(), userID); !errors.Is(err, core.ErrUserNotFound) {
+		t.Errorf("user left on old shard %d after DeleteUser: %v", oldShard, err)
+	}
+}
+
+// Requirement: New rejects previous shard counts that don't increase or
+// reach the number of shards.
+func TestNew_InvalidPreviousShardCounts(t *testing.T) {
+	shards := []core.StorageProvider{services.NewFakeStorageProvider(), services.NewFakeStorageProvider(), services.NewFakeStorageProvider()}
+	for _, counts := range [][]int{{3}, {0}, {2, 2}, {2, 1}} {
+		if _, err := New(shards, Options{PreviousShardCounts: counts}); !errors.Is(err, ErrInvalidShardCounts) {
+			t.Errorf("counts %v: expected %v, got %v", counts, ErrInvalidShardCounts, err)
+		}
+	}
+}
+
+// Requirement: Token lookups survive a stale or missing index entry, e.g.
+// after sessions were moved between shards, and repair the index.
+func TestStorage_GetSessionByHash_IndexFallback(t *testing.T) {
+	tests := []struct {
+		name      string
+		hint      int
+		withHint  bool
+		wantShard int
+	}{
+		{name: "missing hint", wantShard: 1},
+		{name: "stale hint", hint: 0, withHint: true, wantShard: 1},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// Arrange
+			index := NewMemoryIndex(0)
+			fakes := []*services.FakeStorageProvider{services.NewFakeStorageProvider(), services.NewFakeStorageProvider()}
+			storage, err := New([]core.StorageProvider{fakes[0], fakes[1]}, Options{Index: index})
+			if err != nil {
+				t.Fatalf("New error: %v", err)
+			}
+			// Written straight to a shard, bypassing the index
+			session := &core.Session{ID: "s1", UserID: "u1", TokenHash: "hash-1", ExpiresAt: time.Now().Add(time.Hour)}
+			if err := fakes[1].CreateSession(t.Context(), session); err != nil {
+				t.Fatalf("CreateSession error: %v", err)
+			}
+			if test.withHint {
+				index.Store("hash-1", test.hint)
+			}
+
+			// Act
+			got, err := storage.GetSessionByHash(t.Context(), "hash-1")
+
+			// Assert
+			if err != nil || got.ID != "s1" {
+				t.Fatalf("GetSessionByHash = %v, %v", got, err)
+			}
+			if shard, ok := index.Lookup("hash-1"); !ok || shard != test.wantShard {
+				t.Errorf("expected index to point at shard %d, got %d (%v)", test.wantShard, shard, ok)
+			}
+		})
+	}
+}
+
+// Requirement: Batch lookups and searches merge results from every shard.
+func TestStorage_FanOutQueries(t *testing.T) {
+	// Arrange
+	storage, _ := newTestStorage(t, 3)
+	now := time.Now()
+	var hashes []string
+	for shard := 0; shard < 3; shard++ {
+		userID := userOnShard(t, storage, shard)
+		hash := fmt.Sprintf("hash-%d", shard)
+		hashes = append(hashes, hash)
+		session := &core.Session{
+			ID:        fmt.Sprintf("s%d", shard),
+			UserID:    userID,
+			TokenHash: hash,
+			ExpiresAt: now.Add(time.Hour),
+			CreatedAt: now.Add(time.Duration(shard) * time.Minute),
+		}
+		if err := storage.CreateSession(t.Context(), session); err != nil {
+			t.Fatalf("CreateSession error: %v", err)
+		}
+	}
+
+	// Act
+	batch, err := storage.GetSessionsByHashes(t.Context(), append(hashes, "missing"))
+	if err != nil {
+		t.Fatalf("GetSessionsByHashes error: %v", err)
+	}
+	page, total, err := storage.SearchSessions(t.Context(), core.SessionFilter{Limit: 2, Offset: 0})
+	if err != nil {
+		t.Fatalf("SearchSessions error: %v", err)
+	}
+
+	// Assert
+	if len(batch) != 3 {
+		t.Errorf("expected 3 sessions, got %d", len(batch))
+	}
+	if total != 3 {
+		t.Errorf("expected total 3, got %d", total)
+	}
+	if len(page) != 2 || page[0].ID != "s2" || page[1].ID != "s1" {
+		t.Errorf("expected newest two sessions [s2 s1], got %v", page)
+	}
+}
+
+// Requirement: Deletes by ID succeed when only one shard holds the record.
+func TestStorage_DeleteSessionByID(t *testing.T) {
+	// Arrange
+	storage, _ := newTestStorage(t, 2)
+	session := &core.Session{ID: "s1", UserID: userOnShard(t, storage, 1), TokenHash: "hash-1", ExpiresAt: time.Now().Add(time.Hour)}
+	if err := storage.CreateSession(t.Context(), session); err != nil {
+		t.Fatalf("CreateSession error: %v", err)
+	}
+
+	// Act
+	err := storage.DeleteSessionByID(t.Context(), "s1")
+
+	// Assert
+	if err != nil {
+		t.Fatalf("DeleteSessionByID error: %v", err)
+	}
+	if err := storage.DeleteSessionByID(t.Context(), "s1"); !errors.Is(err, core.ErrSessionNotFound) {
+		t.Errorf("expected %v on second delete, got %v", core.ErrSessionNotFound, err)
+	}
+}
+
+// Requirement: A sharded storage supports what every one of its shards
+// supports.
+func TestStorage_Capabilities(t *testing.T) {
+	tests := []struct {
+		name   string
+		shards []core.StorageProvider
+		want   core.StorageCapabilities
+	}{
+		{
+			name:   "unreported shards",
+			shards: []core.StorageProvider{services.NewFakeStorageProvider(), services.NewFakeStorageProvider()},
+			want:   core.StorageCapabilities{Transactions: true, SoftDelete: true, Search: true},
+		},
+		{
+			name: "one limited shard",
+			shards: []core.StorageProvider{
+				services.NewFakeStorageProvider(),
+				services.FakeLimitedStorage{FakeStorageProvider: services.NewFakeStorageProvider(), Supported: core.StorageCapabilities{Search: true}},
+			},
+			want: core.StorageCapabilities{Search: true},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// Arrange
+			storage, err := New(test.shards)
+			if err != nil {
+				t.Fatalf("New error: %v", err)
+			}
+
+			// Act
+			got := storage.Capabilities()
+
+			// Assert
+			if got != test.want {
+				t.Errorf("Capabilities() = %+v, want %+v", got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,115 @@
+// Package totp implements time-based one-time passwords (RFC 6238) as
+// authenticator apps such as Google Authenticator and 1Password compute
+// them: HMAC-SHA1, 6 digits, 30-second steps.
+package totp
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	// Digits is the length of a code
+	Digits = 6
+	// modulus is 10^Digits
+	modulus = 1_000_000
+	// Period is how long a code is valid for
+	Period = 30 * time.Second
+
+	// secretLength is the secret size in bytes, the HMAC-SHA1 output size
+	// RFC 4226 recommends
+	secretLength = 20
+	// skew is how many steps before and after the current one are accepted,
+	// for clocks that drift and users who type slowly
+	skew = 1
+)
+
+// encoding is the unpadded base32 authenticator apps expect secrets in
+var encoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// GenerateSecret returns a new random secret, base32-encoded
+func GenerateSecret() (string, error) {
+	secret := make([]byte, secretLength)
+	if _, err := rand.Read(secret); err != nil {
+		return "", err
+	}
+	return encoding.EncodeToString(secret), nil
+}
+
+// URI returns the otpauth:// URI authenticator apps enroll secret from,
+// usually shown as a QR code. issuer names the app and account the user.
+func URI(issuer, account, secret string) string {
+	label := url.PathEscape(issuer + ":" + account)
+	query := url.Values{}
+	query.Set("secret", secret)
+	query.Set("issuer", issuer)
+	query.Set("algorithm", "SHA1")
+	query.Set("digits", fmt.Sprint(Digits))
+	query.Set("period", fmt.Sprint(int(Period.Seconds())))
+	return "otpauth://totp/" + label + "?" + query.Encode()
+}
+
+// Code returns the code for secret at t
+func Code(secret string, t time.Time) (string, error) {
+	key, err := decodeSecret(secret)
+	if err != nil {
+		return "", err
+	}
+	return hotp(key, step(t)), nil
+}
+
+// Validate reports whether code is the code for secret at t or one step
+// either side of it
+func Validate(secret, code string, t time.Time) bool {
+	_, ok := Match(secret, code, t)
+	return ok
+}
+
+// Match is Validate that also returns the step code belongs to, so callers
+// can refuse a code used before by remembering the last step they accepted
+func Match(secret, code string, t time.Time) (uint64, bool) {
+	key, err := decodeSecret(secret)
+	if err != nil || len(code) != Digits {
+		return 0, false
+	}
+	current := step(t)
+	var matched uint64
+	valid := false
+	for offset := -skew; offset <= skew; offset++ {
+		// Every step is checked so the time taken doesn't reveal which matched
+		counter := current + uint64(offset)
+		if subtle.ConstantTimeCompare([]byte(code), []byte(hotp(key, counter))) == 1 {
+			matched, valid = counter, true
+		}
+	}
+	return matched, valid
+}
+
+func decodeSecret(secret string) ([]byte, error) {
+	return encoding.DecodeString(strings.ToUpper(strings.TrimRight(secret, "=")))
+}
+
+func step(t time.Time) uint64 {
+	return uint64(t.Unix()) / uint64(Period.Seconds())
+}
+
+// hotp computes the HOTP value (RFC 4226) of key for counter
+func hotp(key []byte, counter uint64) string {
+	var message [8]byte
+	binary.BigEndian.PutUint64(message[:], counter)
+	mac := hmac.New(sha1.New, key)
+	mac.Write(message[:])
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	return fmt.Sprintf("%0*d", Digits, value%modulus)
+}
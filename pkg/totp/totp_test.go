@@ -0,0 +1,133 @@
+package totp
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// rfcSecret is the SHA1 test key of RFC 6238 ("12345678901234567890")
+const rfcSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+// Requirement: Codes match the RFC 6238 test vectors, truncated to 6 digits.
+func TestCode(t *testing.T) {
+	tests := []struct {
+		name string
+		unix int64
+		want string
+	}{
+		{name: "t=59", unix: 59, want: "287082"},
+		{name: "t=1111111109", unix: 1111111109, want: "081804"},
+		{name: "t=1234567890", unix: 1234567890, want: "005924"},
+		{name: "t=20000000000", unix: 20000000000, want: "353130"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// Act
+			got, err := Code(rfcSecret, time.Unix(test.unix, 0))
+
+			// Assert
+			if err != nil {
+				t.Fatalf("Code() error = %v", err)
+			}
+			if got != test.want {
+				t.Errorf("Code() = %s, want %s", got, test.want)
+			}
+		})
+	}
+}
+
+// Requirement: Validate accepts the current code and those one step either
+// side, and nothing else.
+func TestValidate(t *testing.T) {
+	now := time.Unix(1111111109, 0)
+
+	tests := []struct {
+		name   string
+		secret string
+		codeAt time.Time
+		code   string
+		want   bool
+	}{
+		{name: "current step", secret: rfcSecret, codeAt: now, want: true},
+		{name: "previous step", secret: rfcSecret, codeAt: now.Add(-Period), want: true},
+		{name: "next step", secret: rfcSecret, codeAt: now.Add(Period), want: true},
+		{name: "two steps old", secret: rfcSecret, codeAt: now.Add(-2 * Period), want: false},
+		{name: "lowercase secret", secret: strings.ToLower(rfcSecret), codeAt: now, want: true},
+		{name: "wrong length", secret: rfcSecret, code: "12345", want: false},
+		{name: "malformed secret", secret: "not base32!", code: "123456", want: false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// Arrange
+			code := test.code
+			if code == "" {
+				code, _ = Code(rfcSecret, test.codeAt)
+			}
+
+			// Act
+			got := Validate(test.secret, code, now)
+
+			// Assert
+			if got != test.want {
+				t.Errorf("Validate() = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+// Requirement: Match returns the step of the code it accepts, whichever of
+// the three it is.
+func TestMatch(t *testing.T) {
+	now := time.Unix(1111111109, 0)
+
+	tests := []struct {
+		name     string
+		codeAt   time.Time
+		wantStep uint64
+	}{
+		{name: "current step", codeAt: now, wantStep: step(now)},
+		{name: "previous step", codeAt: now.Add(-Period), wantStep: step(now) - 1},
+		{name: "next step", codeAt: now.Add(Period), wantStep: step(now) + 1},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// Arrange
+			code, _ := Code(rfcSecret, test.codeAt)
+
+			// Act
+			got, ok := Match(rfcSecret, code, now)
+
+			// Assert
+			if !ok || got != test.wantStep {
+				t.Errorf("Match() = %d, %v; want %d, true", got, ok, test.wantStep)
+			}
+		})
+	}
+}
+
+// Requirement: Generated secrets are unpadded base32 that codes can be
+// computed from, and the URI carries them for authenticator apps.
+func TestGenerateSecretAndURI(t *testing.T) {
+	// Act
+	secret, err := GenerateSecret()
+	if err != nil {
+		t.Fatalf("GenerateSecret() error = %v", err)
+	}
+	uri := URI("Acme App", "alice@example.com", secret)
+
+	// Assert
+	if len(secret) != 32 || strings.Contains(secret, "=") {
+		t.Errorf("secret = %q, want 32 unpadded base32 characters", secret)
+	}
+	if _, err := Code(secret, time.Now()); err != nil {
+		t.Errorf("Code() error = %v", err)
+	}
+	want := "otpauth://totp/Acme%20App:alice@example.com?algorithm=SHA1&digits=6&issuer=Acme+App&period=30&secret=" + secret
+	if uri != want {
+		t.Errorf("URI() = %s, want %s", uri, want)
+	}
+}
@@ -0,0 +1,163 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/lborres/kuta/core"
+	"github.com/lborres/kuta/pkg/crypto"
+)
+
+// accessTokenTouchInterval is how stale LastUsedAt may get before a
+// verification records it again, so busy tokens don't write on every call
+const accessTokenTouchInterval = time.Minute
+
+// Ensure SessionManager implements AccessTokenProvider
+var _ core.AccessTokenProvider = (*SessionManager)(nil)
+
+// accessTokens is where personal access tokens are kept and which scopes
+// they may be granted
+type accessTokens struct {
+	store  core.AccessTokenStorage
+	scopes []string // sorted; nil allows any scope
+}
+
+// validate checks a new token's input and returns its normalized scopes
+func (a *accessTokens) validate(input core.CreateAccessTokenInput, now time.Time) ([]string, error) {
+	var v core.Validator
+	if strings.TrimSpace(input.Name) == "" {
+		v.Add("name", core.CodeNameRequired, core.ErrNameRequired)
+	}
+	scopes := normalizeNames(input.Scopes)
+	if a.scopes != nil {
+		for _, scope := range scopes {
+			if _, found := slices.BinarySearch(a.scopes, scope); !found {
+				v.Add("scopes", core.CodeScopeInvalid, core.ErrInvalidScope)
+				break
+			}
+		}
+	}
+	if input.ExpiresAt != nil && !input.ExpiresAt.After(now) {
+		v.Add("expiresAt", core.CodeExpiresAtInvalid, core.ErrInvalidExpiry)
+	}
+	return scopes, v.Err()
+}
+
+// AccessTokensEnabled reports whether an access token storage has been
+// configured
+func (sm *SessionManager) AccessTokensEnabled() bool {
+	return sm.accessTokens != nil
+}
+
+// CreateAccessToken creates a personal access token for the user. The raw
+// token is only returned here; the storage keeps its hash.
+func (sm *SessionManager) CreateAccessToken(ctx context.Context, userID string, input core.CreateAccessTokenInput) (*core.CreateAccessTokenResult, error) {
+	if sm.accessTokens == nil {
+		return nil, core.ErrNotImplemented
+	}
+
+	now := time.Now()
+	scopes, err := sm.accessTokens.validate(input, now)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := sm.accountOwner(ctx, userID); err != nil {
+		return nil, err
+	}
+
+	pair, err := crypto.GenerateHashedToken()
+	if err != nil {
+		return nil, err
+	}
+	id, err := sm.nanoid.Generate()
+	if err != nil {
+		return nil, err
+	}
+
+	// The prefix is part of the token, so it is hashed along with the rest
+	token := core.AccessTokenPrefix + pair.Token
+	accessToken := &core.AccessToken{
+		ID:        id,
+		UserID:    userID,
+		Name:      strings.TrimSpace(input.Name),
+		TokenHash: crypto.HashToken(token),
+		Scopes:    scopes,
+		ExpiresAt: input.ExpiresAt,
+		CreatedAt: now,
+	}
+	if err := sm.accessTokens.store.CreateAccessToken(ctx, accessToken); err != nil {
+		return nil, err
+	}
+
+	return &core.CreateAccessTokenResult{AccessToken: accessToken, Token: token}, nil
+}
+
+// ListAccessTokens returns the user's personal access tokens, newest first
+func (sm *SessionManager) ListAccessTokens(ctx context.Context, userID string) ([]*core.AccessToken, error) {
+	if sm.accessTokens == nil {
+		return nil, core.ErrNotImplemented
+	}
+	tokens, err := sm.accessTokens.store.ListUserAccessTokens(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if tokens == nil {
+		tokens = []*core.AccessToken{}
+	}
+	return tokens, nil
+}
+
+// RevokeAccessToken deletes one of the user's personal access tokens. It
+// returns ErrAccessTokenNotFound for tokens of other users.
+func (sm *SessionManager) RevokeAccessToken(ctx context.Context, userID, tokenID string) error {
+	if sm.accessTokens == nil {
+		return core.ErrNotImplemented
+	}
+	return sm.accessTokens.store.DeleteAccessToken(ctx, userID, tokenID)
+}
+
+// VerifyAccessToken returns the personal access token and its user.
+// Unknown tokens, and tokens of deleted users, are ErrInvalidToken.
+func (sm *SessionManager) VerifyAccessToken(ctx context.Context, token string) (*core.AccessTokenData, error) {
+	if sm.accessTokens == nil {
+		return nil, core.ErrNotImplemented
+	}
+	if !core.IsAccessToken(token) {
+		return nil, core.ErrInvalidToken
+	}
+
+	accessToken, err := sm.accessTokens.store.GetAccessTokenByHash(ctx, crypto.HashToken(token))
+	if err != nil {
+		if errors.Is(err, core.ErrAccessTokenNotFound) {
+			return nil, core.ErrInvalidToken
+		}
+		return nil, err
+	}
+	now := time.Now()
+	if accessToken.Expired(now) {
+		return nil, core.ErrAccessTokenExpired
+	}
+
+	user, err := sm.storage.GetUserByID(ctx, accessToken.UserID)
+	if err != nil {
+		if errors.Is(err, core.ErrUserNotFound) {
+			return nil, core.ErrInvalidToken
+		}
+		return nil, err
+	}
+	if err := checkUserStatus(user); err != nil {
+		return nil, err
+	}
+
+	// Best effort: a failed write shouldn't fail the caller's request
+	if accessToken.LastUsedAt == nil || now.Sub(*accessToken.LastUsedAt) >= accessTokenTouchInterval {
+		if err := sm.accessTokens.store.TouchAccessToken(ctx, accessToken.ID, now); err == nil {
+			accessToken.LastUsedAt = &now
+		}
+	}
+
+	return &core.AccessTokenData{User: user, AccessToken: accessToken}, nil
+}
@@ -0,0 +1,175 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lborres/kuta/core"
+	"github.com/lborres/kuta/pkg/cache"
+	"github.com/lborres/kuta/pkg/crypto"
+)
+
+// newAccessTokenTestManager returns a manager keeping access tokens that may
+// be granted "read" or "write", where user-1 is registered
+func newAccessTokenTestManager(storage *FakeStorageProvider) *SessionManager {
+	_ = storage.CreateUser(context.Background(), &core.User{ID: "user-1", Email: "a@example.com"})
+	return NewSessionManager(core.SessionConfig{MaxAge: time.Hour}, storage, nil, nil,
+		WithAccessTokens(cache.NewInMemoryAccessTokenStorage(), "write", "read"))
+}
+
+// Requirement: Access tokens are named, limited to the configured scopes and
+// expire in the future; only their hash is stored, and the raw token is
+// returned once.
+func TestSessionManager_CreateAccessToken(t *testing.T) {
+	past := time.Now().Add(-time.Hour)
+	future := time.Now().Add(time.Hour)
+
+	tests := []struct {
+		name    string
+		userID  string
+		input   core.CreateAccessTokenInput
+		wantErr error
+	}{
+		{name: "scoped token", userID: "user-1", input: core.CreateAccessTokenInput{Name: "cli", Scopes: []string{"write", "read", "read"}}},
+		{name: "expiring token", userID: "user-1", input: core.CreateAccessTokenInput{Name: "ci", ExpiresAt: &future}},
+		{name: "missing name", userID: "user-1", input: core.CreateAccessTokenInput{Name: " "}, wantErr: core.ErrNameRequired},
+		{name: "unknown scope", userID: "user-1", input: core.CreateAccessTokenInput{Name: "cli", Scopes: []string{"admin"}}, wantErr: core.ErrInvalidScope},
+		{name: "expiry in the past", userID: "user-1", input: core.CreateAccessTokenInput{Name: "cli", ExpiresAt: &past}, wantErr: core.ErrInvalidExpiry},
+		{name: "unknown user", userID: "user-2", input: core.CreateAccessTokenInput{Name: "cli"}, wantErr: core.ErrUserNotFound},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// Arrange
+			manager := newAccessTokenTestManager(NewFakeStorageProvider())
+
+			// Act
+			result, err := manager.CreateAccessToken(t.Context(), test.userID, test.input)
+
+			// Assert
+			if !errors.Is(err, test.wantErr) {
+				t.Fatalf("CreateAccessToken() error = %v, want %v", err, test.wantErr)
+			}
+			listed, _ := manager.ListAccessTokens(t.Context(), test.userID)
+			if test.wantErr != nil {
+				if len(listed) != 0 {
+					t.Errorf("rejected token was stored: %+v", listed)
+				}
+				return
+			}
+			if !core.IsAccessToken(result.Token) || strings.Contains(result.AccessToken.TokenHash, result.Token) {
+				t.Errorf("token = %q (hash %q), want a prefixed token stored hashed", result.Token, result.AccessToken.TokenHash)
+			}
+			if len(listed) != 1 || listed[0].ID != result.AccessToken.ID {
+				t.Errorf("ListAccessTokens() = %+v, want the new token", listed)
+			}
+			if len(test.input.Scopes) > 0 && (len(result.AccessToken.Scopes) != 2 || !result.AccessToken.HasScope("read")) {
+				t.Errorf("Scopes = %v, want [read write]", result.AccessToken.Scopes)
+			}
+		})
+	}
+
+	disabled := newTestSessionManager(NewFakeStorageProvider(), nil)
+	if _, err := disabled.CreateAccessToken(t.Context(), "user-1", tests[0].input); !errors.Is(err, core.ErrNotImplemented) {
+		t.Errorf("CreateAccessToken() without storage error = %v, want ErrNotImplemented", err)
+	}
+}
+
+// Requirement: Access tokens authenticate their user until they expire, are
+// revoked or the user is gone, survive sign-out, and record when they were
+// last used.
+func TestSessionManager_VerifyAccessToken(t *testing.T) {
+	tests := []struct {
+		name    string
+		setup   func(t *testing.T, manager *SessionManager, storage *FakeStorageProvider, result *core.CreateAccessTokenResult) string
+		wantErr error
+	}{
+		{
+			name: "valid token",
+			setup: func(t *testing.T, manager *SessionManager, storage *FakeStorageProvider, result *core.CreateAccessTokenResult) string {
+				return result.Token
+			},
+		},
+		{
+			name: "valid after signing out",
+			setup: func(t *testing.T, manager *SessionManager, storage *FakeStorageProvider, result *core.CreateAccessTokenResult) string {
+				session, err := manager.Create(t.Context(), "user-1", "127.0.0.1", "test-agent")
+				if err != nil {
+					t.Fatalf("Create() error = %v", err)
+				}
+				if err := manager.SignOut(t.Context(), session.Token); err != nil {
+					t.Fatalf("SignOut() error = %v", err)
+				}
+				return result.Token
+			},
+		},
+		{
+			name: "revoked token",
+			setup: func(t *testing.T, manager *SessionManager, storage *FakeStorageProvider, result *core.CreateAccessTokenResult) string {
+				if err := manager.RevokeAccessToken(t.Context(), "user-1", result.AccessToken.ID); err != nil {
+					t.Fatalf("RevokeAccessToken() error = %v", err)
+				}
+				return result.Token
+			},
+			wantErr: core.ErrInvalidToken,
+		},
+		{
+			name: "expired token",
+			setup: func(t *testing.T, manager *SessionManager, storage *FakeStorageProvider, result *core.CreateAccessTokenResult) string {
+				expiresAt := time.Now().Add(-time.Minute)
+				stored := *result.AccessToken
+				token := core.AccessTokenPrefix + "expired"
+				stored.ID, stored.TokenHash, stored.ExpiresAt = "expired", crypto.HashToken(token), &expiresAt
+				_ = manager.accessTokens.store.CreateAccessToken(t.Context(), &stored)
+				return token
+			},
+			wantErr: core.ErrAccessTokenExpired,
+		},
+		{
+			name: "deleted user",
+			setup: func(t *testing.T, manager *SessionManager, storage *FakeStorageProvider, result *core.CreateAccessTokenResult) string {
+				_ = storage.DeleteUser(t.Context(), "user-1")
+				return result.Token
+			},
+			wantErr: core.ErrInvalidToken,
+		},
+		{
+			name: "session token",
+			setup: func(t *testing.T, manager *SessionManager, storage *FakeStorageProvider, result *core.CreateAccessTokenResult) string {
+				session, _ := manager.Create(t.Context(), "user-1", "127.0.0.1", "test-agent")
+				return session.Token
+			},
+			wantErr: core.ErrInvalidToken,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// Arrange
+			storage := NewFakeStorageProvider()
+			manager := newAccessTokenTestManager(storage)
+			result, err := manager.CreateAccessToken(t.Context(), "user-1", core.CreateAccessTokenInput{Name: "cli", Scopes: []string{"read"}})
+			if err != nil {
+				t.Fatalf("CreateAccessToken() error = %v", err)
+			}
+			token := test.setup(t, manager, storage, result)
+
+			// Act
+			data, err := manager.VerifyAccessToken(t.Context(), token)
+
+			// Assert
+			if !errors.Is(err, test.wantErr) {
+				t.Fatalf("VerifyAccessToken() error = %v, want %v", err, test.wantErr)
+			}
+			if test.wantErr != nil {
+				return
+			}
+			if data.User.ID != "user-1" || !data.AccessToken.HasScope("read") || data.AccessToken.LastUsedAt == nil {
+				t.Errorf("VerifyAccessToken() = %+v, %+v; want user-1's used read token", data.User, data.AccessToken)
+			}
+		})
+	}
+}
@@ -0,0 +1,318 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/lborres/kuta/core"
+	"github.com/lborres/kuta/pkg/crypto"
+)
+
+const (
+	passwordResetTTL     = time.Hour
+	emailVerificationTTL = 24 * time.Hour
+)
+
+// Ensure SessionManager implements AccountEmailProvider
+var _ core.AccountEmailProvider = (*SessionManager)(nil)
+
+// accountEmails holds the app pages the tokens of ForgotPassword and
+// ResendVerification link to; the token is added as the "token" query
+// parameter, or emailed as a code when the page is empty
+type accountEmails struct {
+	resetURL  string
+	verifyURL string
+}
+
+// accountEmail describes one of the emails ForgotPassword and
+// ResendVerification send
+type accountEmail struct {
+	purpose     string // of the verification token
+	messageType string
+	event       core.EventType
+	link        string
+	ttl         time.Duration
+}
+
+// AccountEmailsEnabled reports whether password reset and verification
+// emails can be sent, which takes a Mailer
+func (sm *SessionManager) AccountEmailsEnabled() bool {
+	return sm.email != nil
+}
+
+// ForgotPassword emails a password reset token to the user registered with
+// email. Without enumeration protection it fails with ErrUserNotFound for
+// unknown emails and ErrAccountNotFound for users without a password.
+func (sm *SessionManager) ForgotPassword(ctx context.Context, email, ipAddress string) error {
+	if !sm.AccountEmailsEnabled() {
+		return core.ErrNotImplemented
+	}
+	if err := sm.allowRequest("forgot_password", ipAddress); err != nil {
+		return err
+	}
+	if err := validateEmailRequest(email); err != nil {
+		return err
+	}
+
+	user, outcome, err := sm.emailRequestUser(ctx, email)
+	if err != nil {
+		return err
+	}
+	if outcome == core.EmailRequestSent {
+		account, err := sm.credentialAccount(ctx, user.ID)
+		if err != nil {
+			return err
+		}
+		if account == nil {
+			outcome = core.EmailRequestNoPassword
+		}
+	}
+
+	return sm.sendAccountEmail(ctx, accountEmail{
+		purpose:     core.VerificationPasswordReset,
+		messageType: core.EmailPasswordReset,
+		event:       core.EventPasswordResetRequested,
+		link:        sm.accountEmails.resetURL,
+		ttl:         passwordResetTTL,
+	}, email, user, outcome, ipAddress)
+}
+
+// ResendVerification emails an email verification token to the user
+// registered with email. Without enumeration protection it fails with
+// ErrUserNotFound for unknown emails and ErrEmailAlreadyVerified for
+// verified ones.
+func (sm *SessionManager) ResendVerification(ctx context.Context, email, ipAddress string) error {
+	if !sm.AccountEmailsEnabled() {
+		return core.ErrNotImplemented
+	}
+	if err := sm.allowRequest("resend_verification", ipAddress); err != nil {
+		return err
+	}
+	if err := validateEmailRequest(email); err != nil {
+		return err
+	}
+
+	user, outcome, err := sm.emailRequestUser(ctx, email)
+	if err != nil {
+		return err
+	}
+	if outcome == core.EmailRequestSent && user.EmailVerified {
+		outcome = core.EmailRequestAlreadyVerified
+	}
+
+	return sm.sendAccountEmail(ctx, accountEmail{
+		purpose:     core.VerificationEmail,
+		messageType: core.EmailVerification,
+		event:       core.EventVerificationRequested,
+		link:        sm.accountEmails.verifyURL,
+		ttl:         emailVerificationTTL,
+	}, email, user, outcome, ipAddress)
+}
+
+// emailRequestUser returns the user registered with email, with outcome
+// EmailRequestSent, or EmailRequestUnknownEmail without one
+func (sm *SessionManager) emailRequestUser(ctx context.Context, email string) (*core.User, string, error) {
+	user, err := sm.storage.GetUserByEmail(ctx, email)
+	switch {
+	case err == nil:
+		return user, core.EmailRequestSent, nil
+	case errors.Is(err, core.ErrUserNotFound):
+		return nil, core.EmailRequestUnknownEmail, nil
+	default:
+		return nil, "", err
+	}
+}
+
+// sendAccountEmail emails user a new token for kind, voiding the ones sent
+// before, when outcome is EmailRequestSent. Whatever the outcome, the token
+// is generated and the email rendered, so under enumeration protection the
+// response gives nothing away, nor does its timing as long as the Mailer
+// queues emails rather than delivering them inline.
+func (sm *SessionManager) sendAccountEmail(ctx context.Context, kind accountEmail, email string, user *core.User, outcome, ipAddress string) error {
+	pair, err := crypto.GenerateHashedToken()
+	if err != nil {
+		return err
+	}
+	tokenID, err := sm.nanoid.Generate()
+	if err != nil {
+		return err
+	}
+
+	recipient := user
+	if recipient == nil {
+		recipient = &core.User{Email: email}
+	}
+	now := time.Now()
+	data := core.EmailData{ExpiresAt: now.Add(kind.ttl)}
+	if kind.link != "" {
+		data.URL = withQueryToken(kind.link, pair.Token)
+	} else {
+		data.Code = pair.Token
+	}
+	message, err := sm.renderEmail(kind.messageType, recipient, data)
+	if err != nil {
+		return err
+	}
+
+	event := core.Event{Type: kind.event, Email: email, IPAddress: ipAddress, Metadata: map[string]any{"outcome": outcome}}
+	if user != nil {
+		event.UserID = user.ID
+	}
+
+	if outcome != core.EmailRequestSent {
+		sm.emit(event)
+		if sm.preventEnumeration {
+			return nil
+		}
+		return emailRequestError(outcome)
+	}
+
+	err = sm.storeAndSend(ctx, kind, user, pair.Hash, tokenID, now, message)
+	if err != nil {
+		// A failure only some emails can have would tell them apart
+		if !sm.preventEnumeration {
+			return err
+		}
+		event.Metadata["outcome"] = core.EmailRequestFailed
+		event.Metadata["error"] = err.Error()
+	}
+	sm.emit(event)
+	return nil
+}
+
+// storeAndSend stores the token of an account email and sends it
+func (sm *SessionManager) storeAndSend(ctx context.Context, kind accountEmail, user *core.User, tokenHash, tokenID string, now time.Time, message *core.EmailMessage) error {
+	if _, err := sm.storage.RevokeUserVerificationTokens(ctx, user.ID, kind.purpose); err != nil {
+		return err
+	}
+	if err := sm.storage.CreateVerificationToken(ctx, &core.VerificationToken{
+		ID:         tokenID,
+		UserID:     &user.ID,
+		Identifier: user.Email,
+		Purpose:    kind.purpose,
+		TokenHash:  tokenHash,
+		ExpiresAt:  now.Add(kind.ttl),
+		CreatedAt:  now,
+	}); err != nil {
+		return err
+	}
+	return sm.email.mailer.SendEmail(message)
+}
+
+// emailRequestError is what an account email request that sent nothing
+// fails with when emails may be told apart
+func emailRequestError(outcome string) error {
+	switch outcome {
+	case core.EmailRequestNoPassword:
+		return core.ErrAccountNotFound
+	case core.EmailRequestAlreadyVerified:
+		return core.ErrEmailAlreadyVerified
+	default:
+		return core.ErrUserNotFound
+	}
+}
+
+// ResetPassword sets a new password with a token from ForgotPassword. The
+// token proves the user reads their email, which is marked verified. Every
+// session of the user is revoked with RevokeReasonPasswordChange, since
+// whoever knew the old password may be signed in.
+func (sm *SessionManager) ResetPassword(ctx context.Context, input core.ResetPasswordInput, ipAddress string) error {
+	if !sm.AccountEmailsEnabled() {
+		return core.ErrNotImplemented
+	}
+	if err := sm.allowRequest("reset_password", ipAddress); err != nil {
+		return err
+	}
+	if input.Token == "" {
+		return core.ErrVerificationTokenNotFound
+	}
+	// Checked before the token is consumed, so a rejected password doesn't
+	// burn the link
+	if err := sm.validateResetPassword(input); err != nil {
+		return err
+	}
+
+	user, err := sm.consumeAccountToken(ctx, input.Token, core.VerificationPasswordReset)
+	if err != nil {
+		return err
+	}
+	account, err := sm.credentialAccount(ctx, user.ID)
+	if err != nil {
+		return err
+	}
+	if account == nil {
+		return core.ErrAccountNotFound
+	}
+
+	hash, err := sm.passwords.Hash(input.NewPassword)
+	if err != nil {
+		return passwordError(err)
+	}
+	account.Password = &hash
+	account.UpdatedAt = time.Now()
+	if err := sm.storage.UpdateAccount(ctx, account); err != nil {
+		return err
+	}
+	if err := sm.voidVerificationTokens(ctx, user.ID, changePassword); err != nil {
+		return err
+	}
+	if err := sm.markEmailVerified(ctx, user); err != nil {
+		return err
+	}
+
+	sm.emit(core.Event{Type: core.EventPasswordReset, UserID: user.ID, Email: user.Email, IPAddress: ipAddress})
+	_, err = sm.RevokeUserSessions(ctx, user.ID, core.RevokeReasonPasswordChange)
+	return err
+}
+
+// VerifyEmail marks the email a token from ResendVerification was sent to
+// verified
+func (sm *SessionManager) VerifyEmail(ctx context.Context, token, ipAddress string) error {
+	if !sm.AccountEmailsEnabled() {
+		return core.ErrNotImplemented
+	}
+	if err := sm.allowRequest("verify_email", ipAddress); err != nil {
+		return err
+	}
+	if token == "" {
+		return core.ErrVerificationTokenNotFound
+	}
+
+	user, err := sm.consumeAccountToken(ctx, token, core.VerificationEmail)
+	if err != nil {
+		return err
+	}
+	if err := sm.markEmailVerified(ctx, user); err != nil {
+		return err
+	}
+
+	sm.emit(core.Event{Type: core.EventEmailVerified, UserID: user.ID, Email: user.Email, IPAddress: ipAddress})
+	return nil
+}
+
+// consumeAccountToken consumes a token sent by ForgotPassword or
+// ResendVerification and returns its user. Tokens sent to an address the
+// user no longer has are refused.
+func (sm *SessionManager) consumeAccountToken(ctx context.Context, token, purpose string) (*core.User, error) {
+	stored, err := sm.storage.ConsumeVerificationToken(ctx, crypto.HashToken(token), purpose)
+	if err != nil {
+		return nil, err
+	}
+	if stored.UserID == nil {
+		return nil, core.ErrVerificationTokenNotFound
+	}
+	user, err := sm.storage.GetUserByID(ctx, *stored.UserID)
+	if errors.Is(err, core.ErrUserNotFound) || (err == nil && user.Email != stored.Identifier) {
+		return nil, core.ErrVerificationTokenNotFound
+	}
+	return user, err
+}
+
+func (sm *SessionManager) markEmailVerified(ctx context.Context, user *core.User) error {
+	if user.EmailVerified {
+		return nil
+	}
+	user.EmailVerified = true
+	return sm.storage.UpdateUser(ctx, user)
+}
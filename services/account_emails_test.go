@@ -0,0 +1,237 @@
+package services
+
+import (
+	"errors"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/lborres/kuta/core"
+	"github.com/lborres/kuta/pkg/crypto"
+)
+
+// emailedToken matches the token of the links account emails carry
+var emailedToken = regexp.MustCompile(`token=([A-Za-z0-9_-]+)`)
+
+// newAccountEmailManager returns a manager sending account emails through
+// mailer and requiring 8-character passwords, where user@example.com signed
+// up with a password, and nopass@example.com and verified@example.com
+// (verified) have none
+func newAccountEmailManager(t *testing.T, mailer *FakeMailer, opts ...Option) (*SessionManager, *FakeStorageProvider, string) {
+	t.Helper()
+	storage := NewFakeStorageProvider()
+	passwords := &crypto.Argon2{Memory: 1024, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32}
+	opts = append([]Option{
+		WithMailer(mailer, nil, "Acme"),
+		WithAccountEmails("https://app.test/reset", "https://app.test/verify"),
+		WithPasswordLength(8, 0),
+	}, opts...)
+	manager := NewSessionManager(core.SessionConfig{MaxAge: time.Hour}, storage, nil, passwords, opts...)
+	if _, err := manager.SignUp(t.Context(), core.SignUpInput{Email: "user@example.com", Password: "CorrectPass123!"}, "", ""); err != nil {
+		t.Fatalf("SignUp error: %v", err)
+	}
+	// With enumeration protection sign-up doesn't return the user
+	user, err := storage.GetUserByEmail(t.Context(), "user@example.com")
+	if err != nil {
+		t.Fatalf("GetUserByEmail error: %v", err)
+	}
+	_ = storage.CreateUser(t.Context(), &core.User{ID: "user-nopass", Email: "nopass@example.com"})
+	_ = storage.CreateUser(t.Context(), &core.User{ID: "user-verified", Email: "verified@example.com", EmailVerified: true})
+	return manager, storage, user.ID
+}
+
+// lastEmailedToken returns the token of the last email mailer sent
+func lastEmailedToken(t *testing.T, mailer *FakeMailer) string {
+	t.Helper()
+	messages := mailer.Messages()
+	if len(messages) == 0 {
+		t.Fatal("no email sent")
+	}
+	match := emailedToken.FindStringSubmatch(messages[len(messages)-1].HTML)
+	if match == nil {
+		t.Fatalf("email doesn't link to a token: %s", messages[len(messages)-1].HTML)
+	}
+	return match[1]
+}
+
+// Requirement: Forgot-password and resend-verification email registered
+// users a token. Under enumeration protection every other email gets the
+// same answer, with what happened only told to event handlers; without it
+// the reason is returned.
+func TestSessionManager_AccountEmailRequests(t *testing.T) {
+	forgot := func(sm *SessionManager, email string) error {
+		return sm.ForgotPassword(t.Context(), email, "")
+	}
+	resend := func(sm *SessionManager, email string) error {
+		return sm.ResendVerification(t.Context(), email, "")
+	}
+
+	tests := []struct {
+		name        string
+		request     func(sm *SessionManager, email string) error
+		email       string
+		protect     bool
+		wantErr     error
+		wantOutcome string
+		wantType    string
+	}{
+		{name: "forgot password", request: forgot, email: "user@example.com", wantOutcome: core.EmailRequestSent, wantType: core.EmailPasswordReset},
+		{name: "forgot password, unknown email", request: forgot, email: "nobody@example.com", wantErr: core.ErrUserNotFound, wantOutcome: core.EmailRequestUnknownEmail},
+		{name: "forgot password, unknown email, protected", request: forgot, email: "nobody@example.com", protect: true, wantOutcome: core.EmailRequestUnknownEmail},
+		{name: "forgot password, no password", request: forgot, email: "nopass@example.com", wantErr: core.ErrAccountNotFound, wantOutcome: core.EmailRequestNoPassword},
+		{name: "forgot password, no password, protected", request: forgot, email: "nopass@example.com", protect: true, wantOutcome: core.EmailRequestNoPassword},
+		{name: "resend verification", request: resend, email: "user@example.com", protect: true, wantOutcome: core.EmailRequestSent, wantType: core.EmailVerification},
+		{name: "resend verification, unknown email, protected", request: resend, email: "nobody@example.com", protect: true, wantOutcome: core.EmailRequestUnknownEmail},
+		{name: "resend verification, verified", request: resend, email: "verified@example.com", wantErr: core.ErrEmailAlreadyVerified, wantOutcome: core.EmailRequestAlreadyVerified},
+		{name: "resend verification, verified, protected", request: resend, email: "verified@example.com", protect: true, wantOutcome: core.EmailRequestAlreadyVerified},
+		{name: "invalid email", request: forgot, email: "nobody", protect: true, wantErr: core.ErrInvalidEmail},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// Arrange
+			mailer := &FakeMailer{}
+			var events []core.Event
+			manager, _, _ := newAccountEmailManager(t, mailer,
+				WithEnumerationProtection(test.protect),
+				WithEventHandler(core.EventHandlerFunc(func(e core.Event) { events = append(events, e) })))
+			events = nil
+
+			// Act
+			err := test.request(manager, test.email)
+
+			// Assert
+			if !errors.Is(err, test.wantErr) {
+				t.Fatalf("error = %v, want %v", err, test.wantErr)
+			}
+			messages := mailer.Messages()
+			if test.wantType == "" {
+				if len(messages) != 0 {
+					t.Errorf("sent %d emails, want none", len(messages))
+				}
+			} else if len(messages) != 1 || messages[0].Type != test.wantType || messages[0].To != test.email {
+				t.Errorf("sent %+v, want one %s email to %s", messages, test.wantType, test.email)
+			}
+			if test.wantOutcome == "" {
+				if len(events) != 0 {
+					t.Errorf("events = %+v, want none", events)
+				}
+				return
+			}
+			if len(events) != 1 || events[0].Email != test.email || events[0].Metadata["outcome"] != test.wantOutcome {
+				t.Errorf("events = %+v, want one with outcome %s", events, test.wantOutcome)
+			}
+		})
+	}
+
+	disabled := newTestSessionManager(NewFakeStorageProvider(), nil)
+	if err := disabled.ForgotPassword(t.Context(), "user@example.com", ""); !errors.Is(err, core.ErrNotImplemented) {
+		t.Errorf("ForgotPassword() without a Mailer error = %v, want ErrNotImplemented", err)
+	}
+}
+
+// Requirement: A reset token sets a new password once, signing out every
+// session and verifying the email. A rejected password doesn't burn the
+// token, and asking again voids the tokens sent before.
+func TestSessionManager_ResetPassword(t *testing.T) {
+	tests := []struct {
+		name         string
+		password     string
+		requestAgain bool
+		wantErr      error
+	}{
+		{name: "new password", password: "NewPass123!"},
+		{name: "rejected password", password: "short", wantErr: core.ErrPasswordTooShort},
+		{name: "superseded token", password: "NewPass123!", requestAgain: true, wantErr: core.ErrVerificationTokenNotFound},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// Arrange
+			mailer := &FakeMailer{}
+			manager, storage, userID := newAccountEmailManager(t, mailer)
+			if err := manager.ForgotPassword(t.Context(), "user@example.com", ""); err != nil {
+				t.Fatalf("ForgotPassword error: %v", err)
+			}
+			token := lastEmailedToken(t, mailer)
+			if test.requestAgain {
+				_ = manager.ForgotPassword(t.Context(), "user@example.com", "")
+			}
+
+			// Act
+			err := manager.ResetPassword(t.Context(), core.ResetPasswordInput{Token: token, NewPassword: test.password}, "")
+
+			// Assert
+			if !errors.Is(err, test.wantErr) {
+				t.Fatalf("error = %v, want %v", err, test.wantErr)
+			}
+			sessions, _ := storage.GetUserSessions(t.Context(), userID)
+			user, _ := storage.GetUserByID(t.Context(), userID)
+			_, signInErr := manager.SignIn(t.Context(), core.SignInInput{Email: "user@example.com", Password: "NewPass123!"}, "", "")
+			if test.wantErr != nil {
+				if len(sessions) != 1 || user.EmailVerified || signInErr == nil {
+					t.Errorf("rejected reset left %d sessions, verified = %v, new password error = %v", len(sessions), user.EmailVerified, signInErr)
+				}
+				if errors.Is(test.wantErr, core.ErrPasswordTooShort) {
+					if err := manager.ResetPassword(t.Context(), core.ResetPasswordInput{Token: token, NewPassword: "NewPass123!"}, ""); err != nil {
+						t.Errorf("ResetPassword() after a rejected password error = %v", err)
+					}
+				}
+				return
+			}
+			if len(sessions) != 0 || !user.EmailVerified || signInErr != nil {
+				t.Errorf("reset left %d sessions, verified = %v, new password error = %v", len(sessions), user.EmailVerified, signInErr)
+			}
+			err = manager.ResetPassword(t.Context(), core.ResetPasswordInput{Token: token, NewPassword: "OtherPass123!"}, "")
+			if !errors.Is(err, core.ErrVerificationTokenNotFound) {
+				t.Errorf("ResetPassword() with a used token error = %v, want ErrVerificationTokenNotFound", err)
+			}
+		})
+	}
+}
+
+// Requirement: A verification token marks the email it was sent to
+// verified, once, and not after the user changes their email.
+func TestSessionManager_VerifyEmail(t *testing.T) {
+	tests := []struct {
+		name         string
+		changeEmail  bool
+		wantErr      error
+		wantVerified bool
+	}{
+		{name: "token", wantVerified: true},
+		{name: "email changed since", changeEmail: true, wantErr: core.ErrVerificationTokenNotFound},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// Arrange
+			mailer := &FakeMailer{}
+			manager, storage, userID := newAccountEmailManager(t, mailer)
+			if err := manager.ResendVerification(t.Context(), "user@example.com", ""); err != nil {
+				t.Fatalf("ResendVerification error: %v", err)
+			}
+			token := lastEmailedToken(t, mailer)
+			if test.changeEmail {
+				user, _ := storage.GetUserByID(t.Context(), userID)
+				user.Email = "changed@example.com"
+				_ = storage.UpdateUser(t.Context(), user)
+			}
+
+			// Act
+			err := manager.VerifyEmail(t.Context(), token, "")
+
+			// Assert
+			if !errors.Is(err, test.wantErr) {
+				t.Fatalf("error = %v, want %v", err, test.wantErr)
+			}
+			user, _ := storage.GetUserByID(t.Context(), userID)
+			if user.EmailVerified != test.wantVerified {
+				t.Errorf("EmailVerified = %v, want %v", user.EmailVerified, test.wantVerified)
+			}
+			if err := manager.VerifyEmail(t.Context(), token, ""); !errors.Is(err, core.ErrVerificationTokenNotFound) {
+				t.Errorf("VerifyEmail() again error = %v, want ErrVerificationTokenNotFound", err)
+			}
+		})
+	}
+}
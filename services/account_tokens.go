@@ -0,0 +1,132 @@
+package services
+
+import (
+	"context"
+	"time"
+
+	"github.com/lborres/kuta/core"
+	"github.com/lborres/kuta/pkg/crypto"
+)
+
+// AccountTokenPurpose derives the key wrapping account token data keys
+const AccountTokenPurpose = "account-tokens"
+
+// encryptedAccountStorage stores account provider tokens (OAuth access and
+// refresh tokens) envelope-encrypted and decrypts them on read. Values
+// written before encryption was enabled are passed through as-is and get
+// encrypted the next time the account is updated.
+type encryptedAccountStorage struct {
+	core.StorageProvider
+	envelope *crypto.Envelope
+}
+
+func (s *encryptedAccountStorage) CreateAccount(ctx context.Context, a *core.Account) error {
+	return s.write(ctx, a, s.StorageProvider.CreateAccount)
+}
+
+func (s *encryptedAccountStorage) UpdateAccount(ctx context.Context, a *core.Account) error {
+	return s.write(ctx, a, s.StorageProvider.UpdateAccount)
+}
+
+func (s *encryptedAccountStorage) GetAccountByID(ctx context.Context, id string) (*core.Account, error) {
+	account, err := s.StorageProvider.GetAccountByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return s.decrypt(account)
+}
+
+func (s *encryptedAccountStorage) GetAccountByUserAndProvider(ctx context.Context, userID, providerID string) ([]*core.Account, error) {
+	accounts, err := s.StorageProvider.GetAccountByUserAndProvider(ctx, userID, providerID)
+	if err != nil {
+		return nil, err
+	}
+	return s.decryptAll(accounts)
+}
+
+func (s *encryptedAccountStorage) GetAccountByProvider(ctx context.Context, providerID, accountID string) (*core.Account, error) {
+	account, err := s.StorageProvider.GetAccountByProvider(ctx, providerID, accountID)
+	if err != nil {
+		return nil, err
+	}
+	return s.decrypt(account)
+}
+
+func (s *encryptedAccountStorage) GetExpiringAccounts(ctx context.Context, before time.Time, limit int) ([]*core.Account, error) {
+	accounts, err := s.StorageProvider.GetExpiringAccounts(ctx, before, limit)
+	if err != nil {
+		return nil, err
+	}
+	return s.decryptAll(accounts)
+}
+
+// write hands storage a copy of a with encrypted tokens, then copies back
+// whatever storage filled in (timestamps) while keeping a's plaintext tokens.
+func (s *encryptedAccountStorage) write(ctx context.Context, a *core.Account, store func(context.Context, *core.Account) error) error {
+	stored := *a
+	var err error
+	if stored.AccessToken, err = s.seal(a.AccessToken); err != nil {
+		return err
+	}
+	if stored.RefreshToken, err = s.seal(a.RefreshToken); err != nil {
+		return err
+	}
+
+	if err := store(ctx, &stored); err != nil {
+		return err
+	}
+
+	// stored itself is left alone in case storage kept the pointer
+	result := stored
+	result.AccessToken, result.RefreshToken = a.AccessToken, a.RefreshToken
+	*a = result
+	return nil
+}
+
+// decrypt returns a copy of a with plaintext tokens, leaving a untouched for
+// storages that hand out their own records (in-memory fakes, caches)
+func (s *encryptedAccountStorage) decrypt(a *core.Account) (*core.Account, error) {
+	decrypted := *a
+	var err error
+	if decrypted.AccessToken, err = s.open(a.AccessToken); err != nil {
+		return nil, err
+	}
+	if decrypted.RefreshToken, err = s.open(a.RefreshToken); err != nil {
+		return nil, err
+	}
+	return &decrypted, nil
+}
+
+func (s *encryptedAccountStorage) decryptAll(accounts []*core.Account) ([]*core.Account, error) {
+	decrypted := make([]*core.Account, len(accounts))
+	for i, account := range accounts {
+		var err error
+		if decrypted[i], err = s.decrypt(account); err != nil {
+			return nil, err
+		}
+	}
+	return decrypted, nil
+}
+
+func (s *encryptedAccountStorage) seal(value *string) (*string, error) {
+	if value == nil {
+		return nil, nil
+	}
+	sealed, err := s.envelope.Seal([]byte(*value))
+	if err != nil {
+		return nil, err
+	}
+	return &sealed, nil
+}
+
+func (s *encryptedAccountStorage) open(value *string) (*string, error) {
+	if value == nil || !crypto.IsSealed(*value) {
+		return value, nil
+	}
+	plaintext, err := s.envelope.Open(*value)
+	if err != nil {
+		return nil, err
+	}
+	opened := string(plaintext)
+	return &opened, nil
+}
@@ -0,0 +1,53 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/lborres/kuta/core"
+	"github.com/lborres/kuta/pkg/crypto"
+)
+
+// Requirement: Account provider tokens are stored encrypted and read back in
+// plaintext; tokens written before encryption was enabled still read.
+func TestEncryptedAccountStorage(t *testing.T) {
+	// Arrange
+	envelope, err := crypto.NewEnvelope("this-is-a-test-secret-of-32-bytes!", AccountTokenPurpose)
+	if err != nil {
+		t.Fatalf("NewEnvelope error: %v", err)
+	}
+	inner := NewFakeStorageProvider()
+	manager := NewSessionManager(core.SessionConfig{MaxAge: time.Hour}, inner, nil, crypto.NewArgon2(), WithAccountTokenEncryption(envelope))
+
+	access, refresh, legacy := "access-token", "refresh-token", "legacy-plaintext"
+	account := &core.Account{ID: "acc-1", UserID: "user-1", ProviderID: "github", AccessToken: &access, RefreshToken: &refresh}
+	legacyAccount := &core.Account{ID: "acc-2", UserID: "user-1", ProviderID: "github", AccessToken: &legacy}
+
+	// Act
+	if err := manager.storage.CreateAccount(t.Context(), account); err != nil {
+		t.Fatalf("CreateAccount error: %v", err)
+	}
+	if err := inner.CreateAccount(t.Context(), legacyAccount); err != nil {
+		t.Fatalf("CreateAccount error: %v", err)
+	}
+	got, err := manager.storage.GetAccountByUserAndProvider(t.Context(), "user-1", "github")
+
+	// Assert
+	if err != nil {
+		t.Fatalf("GetAccountByUserAndProvider error: %v", err)
+	}
+	if *account.AccessToken != "access-token" {
+		t.Errorf("expected caller's account to keep plaintext tokens, got %q", *account.AccessToken)
+	}
+	raw, _ := inner.GetAccountByID(t.Context(), "acc-1")
+	if !crypto.IsSealed(*raw.AccessToken) || !crypto.IsSealed(*raw.RefreshToken) {
+		t.Errorf("expected stored tokens to be encrypted, got %q / %q", *raw.AccessToken, *raw.RefreshToken)
+	}
+	tokens := map[string]string{}
+	for _, a := range got {
+		tokens[a.ID] = *a.AccessToken
+	}
+	if tokens["acc-1"] != "access-token" || tokens["acc-2"] != "legacy-plaintext" {
+		t.Errorf("unexpected decrypted tokens: %v", tokens)
+	}
+}
@@ -0,0 +1,84 @@
+package services
+
+import (
+	"context"
+	"maps"
+	"slices"
+
+	"github.com/lborres/kuta/core"
+)
+
+// Ensure SessionManager implements AccountLinker
+var _ core.AccountLinker = (*SessionManager)(nil)
+
+// accountProviderIDs are the providers whose accounts users can sign in
+// with: email and password, passkeys, OAuth sign-in providers and providers
+// with token refreshers
+func (sm *SessionManager) accountProviderIDs() []string {
+	ids := []string{core.CredentialProviderID, core.PasskeyProviderID}
+	if sm.oauth != nil {
+		ids = append(ids, sm.oauth.order...)
+	}
+	if sm.providerRefresh != nil {
+		for _, id := range slices.Sorted(maps.Keys(sm.providerRefresh.refreshers)) {
+			if !slices.Contains(ids, id) {
+				ids = append(ids, id)
+			}
+		}
+	}
+	return ids
+}
+
+// ListAccounts returns the user's accounts, grouped by provider in the
+// order of accountProviderIDs. Accounts of providers that are no longer
+// configured are left out: they can't be signed in with.
+func (sm *SessionManager) ListAccounts(ctx context.Context, userID string) ([]*core.Account, error) {
+	accounts := []*core.Account{}
+	for _, providerID := range sm.accountProviderIDs() {
+		found, err := sm.storage.GetAccountByUserAndProvider(ctx, userID, providerID)
+		if err != nil {
+			return nil, err
+		}
+		accounts = append(accounts, found...)
+	}
+	return accounts, nil
+}
+
+// StartOAuthLink begins linking providerID to userID. It works like
+// StartOAuth, except that the callback adds the provider account to userID.
+func (sm *SessionManager) StartOAuthLink(ctx context.Context, userID, providerID string) (*core.OAuthStart, error) {
+	if _, err := sm.accountOwner(ctx, userID); err != nil {
+		return nil, err
+	}
+	return sm.startOAuth(providerID, userID)
+}
+
+// UnlinkAccount removes one of userID's accounts, as long as another one is
+// left to sign in with. Provider tokens of the account are revoked first, so
+// the grant doesn't outlive it.
+func (sm *SessionManager) UnlinkAccount(ctx context.Context, userID, accountID string) error {
+	accounts, err := sm.ListAccounts(ctx, userID)
+	if err != nil {
+		return err
+	}
+	i := slices.IndexFunc(accounts, func(a *core.Account) bool { return a.ID == accountID })
+	if i < 0 {
+		return core.ErrAccountNotFound
+	}
+	if len(accounts) == 1 {
+		return core.ErrLastAccount
+	}
+
+	account := accounts[i]
+	sm.revokeProviderTokens(ctx, account)
+	if err := sm.storage.DeleteAccount(ctx, account.ID); err != nil {
+		return err
+	}
+
+	sm.emit(core.Event{
+		Type:     core.EventAccountUnlinked,
+		UserID:   userID,
+		Metadata: map[string]any{"providerId": account.ProviderID},
+	})
+	return nil
+}
@@ -0,0 +1,121 @@
+package services
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/lborres/kuta/core"
+	"github.com/lborres/kuta/pkg/cache"
+	"github.com/lborres/kuta/pkg/crypto"
+)
+
+func newLinkingSessionManager(storage *FakeStorageProvider, provider core.OAuthProvider) *SessionManager {
+	passwords := &crypto.Argon2{Memory: 1024, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32}
+	return NewSessionManager(core.SessionConfig{MaxAge: time.Hour}, storage, nil, passwords,
+		WithOAuth(cache.NewInMemoryOAuthStateStore(), "https://app.example/api/auth", provider))
+}
+
+// Requirement: The callback of a link started by a signed-in user adds the
+// provider account to that user whatever email the provider reports, keeps
+// an account that is already theirs, and refuses one linked to another user.
+func TestSessionManager_StartOAuthLink(t *testing.T) {
+	user := &core.User{ID: "user-1", Email: "me@example.com"}
+	tests := []struct {
+		name         string
+		owner        string // user already holding the provider account
+		wantErr      error
+		wantAccounts int
+	}{
+		{name: "links a new provider account", wantAccounts: 2},
+		{name: "keeps an account already linked", owner: "user-1", wantAccounts: 2},
+		{name: "refuses another user's account", owner: "user-2", wantErr: core.ErrAccountLinked, wantAccounts: 1},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// Arrange
+			storage := NewFakeStorageProvider()
+			_ = storage.CreateUser(t.Context(), user)
+			_ = storage.CreateUser(t.Context(), &core.User{ID: "user-2", Email: "other@example.com"})
+			password := "hash"
+			_ = storage.CreateAccount(t.Context(), &core.Account{ID: "acc-credential", UserID: user.ID, ProviderID: core.CredentialProviderID, Password: &password})
+			if test.owner != "" {
+				_ = storage.CreateAccount(t.Context(), &core.Account{ID: "acc-linked", UserID: test.owner, ProviderID: "test", AccountID: "sub-1"})
+			}
+			provider := &fakeOAuthProvider{identity: core.OAuthIdentity{AccountID: "sub-1", Email: "unverified@example.com"}}
+			manager := newLinkingSessionManager(storage, provider)
+			start, err := manager.StartOAuthLink(t.Context(), user.ID, "test")
+			if err != nil {
+				t.Fatalf("StartOAuthLink() error = %v", err)
+			}
+
+			// Act
+			result, err := manager.CompleteOAuth(t.Context(), "test", core.OAuthCallback{Code: "code", State: start.State, StateCookie: start.State}, "", "")
+
+			// Assert
+			if !errors.Is(err, test.wantErr) {
+				t.Fatalf("CompleteOAuth() error = %v, want %v", err, test.wantErr)
+			}
+			if err == nil && result.User.ID != user.ID {
+				t.Errorf("signed in as %s, want %s", result.User.ID, user.ID)
+			}
+			accounts, err := manager.ListAccounts(t.Context(), user.ID)
+			if err != nil {
+				t.Fatalf("ListAccounts() error = %v", err)
+			}
+			if len(accounts) != test.wantAccounts {
+				t.Errorf("ListAccounts() returned %d accounts, want %d", len(accounts), test.wantAccounts)
+			}
+		})
+	}
+}
+
+// Requirement: Users unlink their own accounts, revoking the provider's
+// tokens, but never their last way to sign in.
+func TestSessionManager_UnlinkAccount(t *testing.T) {
+	tests := []struct {
+		name        string
+		accountID   string
+		onlyAccount bool
+		wantErr     error
+		wantRevoked bool
+	}{
+		{name: "unlinks an OAuth account", accountID: "acc-oauth", wantRevoked: true},
+		{name: "unlinks the password", accountID: "acc-credential"},
+		{name: "refuses the last account", accountID: "acc-oauth", onlyAccount: true, wantErr: core.ErrLastAccount},
+		{name: "refuses another user's account", accountID: "acc-other", wantErr: core.ErrAccountNotFound},
+		{name: "refuses an unknown account", accountID: "missing", wantErr: core.ErrAccountNotFound},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// Arrange
+			storage := NewFakeStorageProvider()
+			refreshToken := "refresh"
+			_ = storage.CreateAccount(t.Context(), &core.Account{ID: "acc-oauth", UserID: "user-1", ProviderID: "test", AccountID: "sub-1", RefreshToken: &refreshToken})
+			if !test.onlyAccount {
+				password := "hash"
+				_ = storage.CreateAccount(t.Context(), &core.Account{ID: "acc-credential", UserID: "user-1", ProviderID: core.CredentialProviderID, Password: &password})
+			}
+			_ = storage.CreateAccount(t.Context(), &core.Account{ID: "acc-other", UserID: "user-2", ProviderID: core.CredentialProviderID})
+			provider := &revokingOAuthProvider{}
+			manager := newLinkingSessionManager(storage, provider)
+
+			// Act
+			err := manager.UnlinkAccount(t.Context(), "user-1", test.accountID)
+
+			// Assert
+			if !errors.Is(err, test.wantErr) {
+				t.Fatalf("UnlinkAccount() error = %v, want %v", err, test.wantErr)
+			}
+			_, getErr := storage.GetAccountByID(t.Context(), test.accountID)
+			if deleted := getErr != nil; deleted != (test.wantErr == nil) && test.accountID != "missing" {
+				t.Errorf("account deleted = %v, want %v", deleted, test.wantErr == nil)
+			}
+			if revoked := len(provider.revoked) > 0; revoked != test.wantRevoked {
+				t.Errorf("revoked = %v, want %v", provider.revoked, test.wantRevoked)
+			}
+		})
+	}
+}
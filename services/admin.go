@@ -0,0 +1,126 @@
+package services
+
+import (
+	"context"
+	"errors"
+
+	"github.com/lborres/kuta/core"
+)
+
+const (
+	defaultSessionPageSize = 50
+	maxSessionPageSize     = 200
+)
+
+// Ensure SessionManager implements AdminProvider
+var _ core.AdminProvider = (*SessionManager)(nil)
+
+// AdminEnabled reports whether an AdminAuthorizer has been configured.
+func (sm *SessionManager) AdminEnabled() bool {
+	return sm.adminAuthorizer != nil
+}
+
+// AuthorizeAdmin resolves the session for token and checks it against the
+// configured AdminAuthorizer. Admin operations are disabled (ErrForbidden)
+// when no authorizer is configured.
+func (sm *SessionManager) AuthorizeAdmin(ctx context.Context, token string) (*core.SessionData, error) {
+	data, err := sm.GetSession(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+
+	if sm.adminAuthorizer == nil || !sm.adminAuthorizer(data) {
+		return nil, core.ErrForbidden
+	}
+
+	return data, nil
+}
+
+// ListSessions searches sessions across all users, newest first. It returns
+// ErrNotImplemented when storage doesn't support session search.
+func (sm *SessionManager) ListSessions(ctx context.Context, filter core.SessionFilter) (*core.SessionPage, error) {
+	if !sm.capabilities.Search {
+		return nil, core.ErrNotImplemented
+	}
+	if filter.Limit <= 0 {
+		filter.Limit = defaultSessionPageSize
+	}
+	if filter.Limit > maxSessionPageSize {
+		filter.Limit = maxSessionPageSize
+	}
+	if filter.Offset < 0 {
+		filter.Offset = 0
+	}
+
+	sessions, total, err := sm.storage.SearchSessions(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	if sessions == nil {
+		sessions = []*core.Session{}
+	}
+
+	return &core.SessionPage{
+		Sessions: sessions,
+		Total:    total,
+		Limit:    filter.Limit,
+		Offset:   filter.Offset,
+	}, nil
+}
+
+// RevokeSessions destroys the given sessions regardless of owner and returns
+// how many were removed.
+func (sm *SessionManager) RevokeSessions(ctx context.Context, sessionIDs []string) (int, error) {
+	if len(sessionIDs) == 0 {
+		return 0, nil
+	}
+
+	if sm.softRevoke() {
+		count := 0
+		for _, id := range sessionIDs {
+			userID, err := sm.drainBySessionID(ctx, id)
+			if errors.Is(err, core.ErrSessionNotFound) {
+				continue
+			}
+			if err != nil {
+				return count, err
+			}
+			count++
+			sm.emitSessionRevoked(userID, id, core.RevokeReasonAdmin)
+		}
+		return count, nil
+	}
+
+	for _, id := range sessionIDs {
+		if _, err := sm.storage.RevokeSessionRefreshTokens(ctx, id); err != nil {
+			return 0, err
+		}
+	}
+
+	// Owners are only needed for the revocation events
+	var revoked []*core.Session
+	if sm.events != nil {
+		for _, id := range sessionIDs {
+			if session, err := sm.storage.GetSessionByID(ctx, id); err == nil {
+				revoked = append(revoked, session)
+			}
+		}
+	}
+
+	count, err := sm.storage.DeleteSessionsByIDs(ctx, sessionIDs)
+	if err != nil {
+		return 0, err
+	}
+
+	// Same trade-off as DestroyAllUserSessions: clearing is cheaper than
+	// looking up every token hash first
+	if count > 0 {
+		sm.evictAllCached()
+	}
+
+	for _, session := range revoked {
+		sm.emitSessionRevoked(session.UserID, session.ID, core.RevokeReasonAdmin)
+	}
+
+	return count, nil
+}
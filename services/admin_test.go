@@ -0,0 +1,134 @@
+package services
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/lborres/kuta/core"
+)
+
+// Requirement: AuthorizeAdmin is forbidden unless an authorizer accepts the caller.
+func TestSessionManager_AuthorizeAdmin(t *testing.T) {
+	tests := []struct {
+		name       string
+		authorizer core.AdminAuthorizer
+		wantErr    error
+	}{
+		{name: "forbidden without authorizer", authorizer: nil, wantErr: core.ErrForbidden},
+		{name: "forbidden when authorizer rejects", authorizer: func(*core.SessionData) bool { return false }, wantErr: core.ErrForbidden},
+		{name: "allowed when authorizer accepts", authorizer: func(d *core.SessionData) bool { return d.User.ID == "admin" }, wantErr: nil},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// Arrange
+			storage := NewFakeStorageProvider()
+			_ = storage.CreateUser(t.Context(), &core.User{ID: "admin", Email: "admin@example.com"})
+			manager := NewSessionManager(core.SessionConfig{MaxAge: time.Hour}, storage, nil, nil, WithAdminAuthorizer(test.authorizer))
+			created, _ := manager.Create(t.Context(), "admin", "127.0.0.1", "test-agent")
+
+			// Act
+			_, err := manager.AuthorizeAdmin(t.Context(), created.Token)
+
+			// Assert
+			if !errors.Is(err, test.wantErr) {
+				t.Errorf("AuthorizeAdmin() error = %v, want %v", err, test.wantErr)
+			}
+			if manager.AdminEnabled() != (test.authorizer != nil) {
+				t.Errorf("AdminEnabled() = %v, want %v", manager.AdminEnabled(), test.authorizer != nil)
+			}
+		})
+	}
+}
+
+// Requirement: ListSessions filters across users and paginates newest first,
+// and is not implemented on storage without session search.
+func TestSessionManager_ListSessions(t *testing.T) {
+	now := time.Now()
+	seed := func(storage *FakeStorageProvider) {
+		sessions := []*core.Session{
+			{ID: "s1", UserID: "u1", TokenHash: "h1", IPAddress: "10.0.0.1", UserAgent: "Mozilla/5.0 Firefox", CreatedAt: now.Add(-3 * time.Hour)},
+			{ID: "s2", UserID: "u2", TokenHash: "h2", IPAddress: "10.0.0.1", UserAgent: "curl/8.0", CreatedAt: now.Add(-2 * time.Hour)},
+			{ID: "s3", UserID: "u2", TokenHash: "h3", IPAddress: "10.0.0.2", UserAgent: "Mozilla/5.0 Chrome", CreatedAt: now.Add(-1 * time.Hour)},
+		}
+		for _, s := range sessions {
+			_ = storage.CreateSession(t.Context(), s)
+		}
+	}
+
+	tests := []struct {
+		name      string
+		filter    core.SessionFilter
+		wantIDs   []string
+		wantTotal int
+	}{
+		{name: "no filter returns all newest first", filter: core.SessionFilter{}, wantIDs: []string{"s3", "s2", "s1"}, wantTotal: 3},
+		{name: "filters by IP", filter: core.SessionFilter{IPAddress: "10.0.0.1"}, wantIDs: []string{"s2", "s1"}, wantTotal: 2},
+		{name: "filters by user agent substring", filter: core.SessionFilter{UserAgent: "mozilla"}, wantIDs: []string{"s3", "s1"}, wantTotal: 2},
+		{name: "filters by creation window", filter: core.SessionFilter{CreatedAfter: now.Add(-150 * time.Minute), CreatedBefore: now.Add(-30 * time.Minute)}, wantIDs: []string{"s3", "s2"}, wantTotal: 2},
+		{name: "paginates with limit and offset", filter: core.SessionFilter{Limit: 1, Offset: 1}, wantIDs: []string{"s2"}, wantTotal: 3},
+		{name: "offset past the end returns empty page", filter: core.SessionFilter{Offset: 10}, wantIDs: []string{}, wantTotal: 3},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// Arrange
+			storage := NewFakeStorageProvider()
+			seed(storage)
+			manager := newTestSessionManager(storage, nil)
+
+			// Act
+			page, err := manager.ListSessions(t.Context(), test.filter)
+
+			// Assert
+			if err != nil {
+				t.Fatalf("ListSessions() error = %v", err)
+			}
+			if page.Total != test.wantTotal {
+				t.Errorf("ListSessions() total = %d, want %d", page.Total, test.wantTotal)
+			}
+			if len(page.Sessions) != len(test.wantIDs) {
+				t.Fatalf("ListSessions() returned %d sessions, want %d", len(page.Sessions), len(test.wantIDs))
+			}
+			for i, id := range test.wantIDs {
+				if page.Sessions[i].ID != id {
+					t.Errorf("ListSessions()[%d] = %q, want %q", i, page.Sessions[i].ID, id)
+				}
+			}
+		})
+	}
+
+	noSearch := newTestSessionManager(FakeLimitedStorage{FakeStorageProvider: NewFakeStorageProvider()}, nil)
+	if _, err := noSearch.ListSessions(t.Context(), core.SessionFilter{}); !errors.Is(err, core.ErrNotImplemented) {
+		t.Errorf("ListSessions() without search error = %v, want ErrNotImplemented", err)
+	}
+}
+
+// Requirement: RevokeSessions deletes sessions in bulk and invalidates the cache.
+func TestSessionManager_RevokeSessions(t *testing.T) {
+	// Arrange
+	storage := NewFakeStorageProvider()
+	cache := NewFakeCache()
+	manager := newTestSessionManager(storage, cache)
+	first, _ := manager.Create(t.Context(), "u1", "10.0.0.1", "agent")
+	second, _ := manager.Create(t.Context(), "u2", "10.0.0.2", "agent")
+	kept, _ := manager.Create(t.Context(), "u3", "10.0.0.3", "agent")
+
+	// Act
+	count, err := manager.RevokeSessions(t.Context(), []string{first.Session.ID, second.Session.ID, "missing"})
+
+	// Assert
+	if err != nil {
+		t.Fatalf("RevokeSessions() error = %v", err)
+	}
+	if count != 2 {
+		t.Errorf("RevokeSessions() count = %d, want 2", count)
+	}
+	if _, err := manager.Verify(t.Context(), first.Token); err == nil {
+		t.Error("revoked session should no longer verify")
+	}
+	if _, err := manager.Verify(t.Context(), kept.Token); err != nil {
+		t.Errorf("untouched session should still verify: %v", err)
+	}
+}
@@ -0,0 +1,271 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/lborres/kuta/core"
+	"github.com/lborres/kuta/pkg/crypto"
+)
+
+// anonymousPurgeBatch is how many anonymous users a cleanup pass reads at a time
+const anonymousPurgeBatch = 500
+
+// Ensure SessionManager implements AnonymousSessionProvider
+var _ core.AnonymousSessionProvider = (*SessionManager)(nil)
+
+// WithAnonymousSessions lets clients start guest sessions with
+// CreateAnonymous and sign up from them. Stateless sessions can't be
+// upgraded in place, so guest sessions stay off in stateless mode.
+func WithAnonymousSessions() Option {
+	return func(sm *SessionManager) {
+		sm.anonymous = true
+	}
+}
+
+// AnonymousEnabled reports whether guest sessions can be created
+func (sm *SessionManager) AnonymousEnabled() bool {
+	return sm.anonymous && sm.sealer == nil
+}
+
+// CreateAnonymous creates a placeholder user with UserStatusAnonymous and a
+// session for it. The session gets no refresh token; once it expires the
+// cleanup worker deletes the user.
+func (sm *SessionManager) CreateAnonymous(ctx context.Context, ipAddress, userAgent string) (*core.SignUpResult, error) {
+	if !sm.AnonymousEnabled() {
+		return nil, core.ErrNotImplemented
+	}
+	if err := sm.allowRequest("anonymous", ipAddress); err != nil {
+		return nil, err
+	}
+
+	userID, err := sm.ids.users.generate()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	user := &core.User{
+		ID:        userID,
+		Email:     userID + "@" + core.AnonymousEmailDomain,
+		Status:    core.UserStatusAnonymous,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if err := sm.storage.CreateUser(ctx, user); err != nil {
+		return nil, err
+	}
+
+	sessionResult, err := sm.Create(ctx, userID, ipAddress, userAgent)
+	if err != nil {
+		_ = sm.storage.DeleteUser(ctx, userID)
+		return nil, err
+	}
+
+	sm.emit(core.Event{
+		Type:      core.EventAnonymousSignedIn,
+		UserID:    userID,
+		SessionID: sessionResult.Session.ID,
+		IPAddress: ipAddress,
+		UserAgent: userAgent,
+	})
+
+	return &core.SignUpResult{
+		User:    user,
+		Session: sessionResult.Session,
+		Token:   sessionResult.Token,
+	}, nil
+}
+
+// guestSession returns the live guest session of token and its anonymous
+// user, or nil when a sign-up should ignore it. With enumeration protection
+// or approval, new sign-ups get no session, so neither does an upgrade.
+func (sm *SessionManager) guestSession(ctx context.Context, token string) (*core.Session, *core.User) {
+	if token == "" || !sm.AnonymousEnabled() || sm.preventEnumeration || sm.requiresApproval() {
+		return nil, nil
+	}
+
+	session, user, err := sm.verify(ctx, token, true)
+	if err != nil || session.Draining() || session.Impersonation != nil {
+		return nil, nil
+	}
+	if user == nil {
+		if user, err = sm.storage.GetUserByID(ctx, session.UserID); err != nil {
+			return nil, nil
+		}
+	}
+	if !user.Anonymous() {
+		return nil, nil
+	}
+	return session, user
+}
+
+// accountOwner returns the user managing their account with userID. Guest
+// users are refused: providers, access tokens or 2FA they set up would be
+// purged along with them, so they sign up first.
+func (sm *SessionManager) accountOwner(ctx context.Context, userID string) (*core.User, error) {
+	user, err := sm.storage.GetUserByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if user.Anonymous() {
+		return nil, core.ErrAnonymousUser
+	}
+	return user, nil
+}
+
+// upgradeAnonymous turns the anonymous user behind a guest session into the
+// account signing up. The session keeps its ID, and with it everything an
+// application hung off it, but gets a new token: the guest token stops
+// working, so one planted on the client can't ride into the account.
+func (sm *SessionManager) upgradeAnonymous(ctx context.Context, input core.SignUpInput, session *core.Session, user *core.User, ipAddress, userAgent string) (*core.SignUpResult, error) {
+	hashedPassword, err := sm.passwords.Hash(input.Password)
+	if err != nil {
+		return nil, passwordError(err)
+	}
+
+	image := input.Image
+	if input.ImageUpload != nil {
+		url, err := sm.storeImage(user.ID, input.ImageUpload)
+		if err != nil {
+			return nil, err
+		}
+		if url != nil {
+			image = url
+		}
+	}
+
+	// Claiming the user first makes concurrent sign-ups from one guest
+	// session race for it; the loser finds the session gone
+	if err := sm.storage.SetUserStatus(ctx, user.ID, core.UserStatusAnonymous, core.UserStatusActive); err != nil {
+		if errors.Is(err, core.ErrUserNotFound) {
+			return nil, core.ErrSessionNotFound
+		}
+		return nil, err
+	}
+	guest := *user
+	restore := func() {
+		_ = sm.storage.UpdateUser(ctx, &guest)
+		_ = sm.storage.SetUserStatus(ctx, user.ID, core.UserStatusActive, core.UserStatusAnonymous)
+	}
+
+	now := time.Now()
+	user.Email = input.Email
+	user.Name = input.Name
+	user.Image = image
+	user.Status = core.UserStatusActive
+	user.UpdatedAt = now
+	if err := sm.storage.UpdateUser(ctx, user); err != nil {
+		restore()
+		return nil, err
+	}
+
+	accountID, err := sm.ids.accounts.generate()
+	if err != nil {
+		restore()
+		return nil, err
+	}
+	account := &core.Account{
+		ID:         accountID,
+		UserID:     user.ID,
+		ProviderID: core.CredentialProviderID,
+		AccountID:  input.Email,
+		Password:   &hashedPassword,
+		CreatedAt:  now,
+		UpdatedAt:  now,
+	}
+	if err := sm.storage.CreateAccount(ctx, account); err != nil {
+		restore()
+		return nil, err
+	}
+
+	pair, err := crypto.GenerateHashedToken()
+	if err != nil {
+		return nil, err
+	}
+	upgraded := *session
+	upgraded.TokenHash = pair.Hash
+	upgraded.IPAddress = ipAddress
+	upgraded.UserAgent = userAgent
+	upgraded.ExpiresAt = now.Add(sm.config.MaxAge)
+	if err := sm.resolveEntitlements(ctx, &upgraded, user); err != nil {
+		return nil, err
+	}
+	if err := sm.storage.UpdateSession(ctx, &upgraded); err != nil {
+		return nil, err
+	}
+	sm.evictCached(session.TokenHash)
+	if sm.cache != nil {
+		_ = sm.cache.Set(pair.Hash, &upgraded)
+	}
+
+	refreshToken, err := sm.issueRefreshToken(ctx, &upgraded, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	sm.emit(core.Event{
+		Type:      core.EventUserSignedUp,
+		UserID:    user.ID,
+		Email:     user.Email,
+		SessionID: upgraded.ID,
+		IPAddress: ipAddress,
+		UserAgent: userAgent,
+		Metadata:  map[string]any{"upgraded": true},
+	})
+
+	return sm.withOnboarding(&core.SignUpResult{
+		User:         user,
+		Session:      &upgraded,
+		Token:        pair.Token,
+		RefreshToken: refreshToken,
+	}), nil
+}
+
+// purgeAnonymousUsers deletes anonymous users older than a session's max age
+// that have no live session left, i.e. guests who never signed up
+func (sm *SessionManager) purgeAnonymousUsers(ctx context.Context, now time.Time) (int, error) {
+	createdBefore := now.Add(-sm.config.MaxAge)
+	purged, offset := 0, 0
+	for {
+		users, _, err := sm.storage.ListUsersByStatus(ctx, core.UserStatusAnonymous, anonymousPurgeBatch, offset)
+		if err != nil {
+			return purged, err
+		}
+		for _, user := range users {
+			// Users come oldest first, so the rest are younger too
+			if user.CreatedAt.After(createdBefore) {
+				return purged, nil
+			}
+			live, err := sm.hasLiveSession(ctx, user.ID, now)
+			if err != nil {
+				return purged, err
+			}
+			if live {
+				offset++
+				continue
+			}
+			if err := sm.storage.DeleteUser(ctx, user.ID); err != nil {
+				return purged, err
+			}
+			purged++
+		}
+		if len(users) < anonymousPurgeBatch {
+			return purged, nil
+		}
+	}
+}
+
+func (sm *SessionManager) hasLiveSession(ctx context.Context, userID string, now time.Time) (bool, error) {
+	sessions, err := sm.storage.GetUserSessions(ctx, userID)
+	if err != nil {
+		return false, err
+	}
+	for _, session := range sessions {
+		if session.ExpiresAt.After(now) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
@@ -0,0 +1,271 @@
+package services
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lborres/kuta/core"
+	"github.com/lborres/kuta/pkg/cache"
+	"github.com/lborres/kuta/pkg/crypto"
+)
+
+// newAnonymousManager returns a SessionManager with guest sessions and a
+// signed-up user, taken@example.com
+func newAnonymousManager(t *testing.T, storage *FakeStorageProvider, opts ...Option) *SessionManager {
+	t.Helper()
+	passwords := &crypto.Argon2{Memory: 1024, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32}
+	manager := NewSessionManager(core.SessionConfig{MaxAge: time.Hour}, storage, nil, passwords,
+		append([]Option{WithAnonymousSessions()}, opts...)...)
+	if _, err := manager.SignUp(t.Context(), core.SignUpInput{Email: "taken@example.com", Password: "CorrectPass123!"}, "", ""); err != nil {
+		t.Fatalf("SignUp error: %v", err)
+	}
+	return manager
+}
+
+// Requirement: Guest sessions belong to a placeholder anonymous user, get no
+// refresh token, and are refused when the feature is off or sessions are
+// stateless.
+func TestSessionManager_CreateAnonymous(t *testing.T) {
+	sealer, _ := crypto.NewSealer("this-is-a-test-secret-of-32-bytes!", StatelessSealerPurpose)
+
+	tests := []struct {
+		name    string
+		opts    []Option
+		wantErr error
+	}{
+		{name: "enabled", opts: []Option{WithAnonymousSessions()}},
+		{name: "disabled", wantErr: core.ErrNotImplemented},
+		{name: "stateless", opts: []Option{WithAnonymousSessions(), WithStatelessSessions(sealer)}, wantErr: core.ErrNotImplemented},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// Arrange
+			manager := NewSessionManager(core.SessionConfig{MaxAge: time.Hour}, NewFakeStorageProvider(), nil, crypto.NewArgon2(), test.opts...)
+
+			// Act
+			result, err := manager.CreateAnonymous(t.Context(), "127.0.0.1", "agent")
+
+			// Assert
+			if !errors.Is(err, test.wantErr) {
+				t.Fatalf("error = %v, want %v", err, test.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if !result.User.Anonymous() || !strings.HasSuffix(result.User.Email, "@"+core.AnonymousEmailDomain) {
+				t.Errorf("user = %+v, want an anonymous placeholder", result.User)
+			}
+			if result.Token == "" || result.RefreshToken != "" {
+				t.Errorf("token = %q, refresh token = %q, want only a session token", result.Token, result.RefreshToken)
+			}
+			session, err := manager.GetSession(t.Context(), result.Token)
+			if err != nil {
+				t.Fatalf("GetSession error: %v", err)
+			}
+			if session.Session.ID != result.Session.ID || session.User.ID != result.User.ID {
+				t.Errorf("session = %+v, want session %s of user %s", session.Session, result.Session.ID, result.User.ID)
+			}
+		})
+	}
+}
+
+// Requirement: Signing up with a live guest session's token turns its
+// anonymous user into the account and keeps the session ID under a new
+// token; any other token is ignored and the sign-up proceeds as usual.
+func TestSessionManager_SignUpFromGuestSession(t *testing.T) {
+	tests := []struct {
+		name         string
+		opts         []Option
+		email        string
+		token        func(t *testing.T, manager *SessionManager, guestToken string) string
+		wantErr      error
+		wantUpgraded bool
+	}{
+		{
+			name:         "guest session",
+			token:        func(_ *testing.T, _ *SessionManager, guestToken string) string { return guestToken },
+			wantUpgraded: true,
+		},
+		{
+			name:  "no token",
+			token: func(*testing.T, *SessionManager, string) string { return "" },
+		},
+		{
+			name: "session of a signed-up user",
+			token: func(t *testing.T, manager *SessionManager, _ string) string {
+				result, err := manager.SignIn(t.Context(), core.SignInInput{Email: "taken@example.com", Password: "CorrectPass123!"}, "", "")
+				if err != nil {
+					t.Fatalf("SignIn error: %v", err)
+				}
+				return result.Token
+			},
+		},
+		{
+			name:  "email taken",
+			email: "taken@example.com",
+			token: func(_ *testing.T, _ *SessionManager, guestToken string) string {
+				return guestToken
+			},
+			wantErr: core.ErrUserExists,
+		},
+		{
+			name:  "with enumeration protection",
+			opts:  []Option{WithEnumerationProtection(true)},
+			token: func(_ *testing.T, _ *SessionManager, guestToken string) string { return guestToken },
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// Arrange
+			storage := NewFakeStorageProvider()
+			manager := newAnonymousManager(t, storage, test.opts...)
+			guest, err := manager.CreateAnonymous(t.Context(), "127.0.0.1", "agent")
+			if err != nil {
+				t.Fatalf("CreateAnonymous error: %v", err)
+			}
+			email := test.email
+			if email == "" {
+				email = "guest@example.com"
+			}
+			input := core.SignUpInput{Email: email, Password: "CorrectPass123!", AnonymousToken: test.token(t, manager, guest.Token)}
+
+			// Act
+			result, err := manager.SignUp(t.Context(), input, "127.0.0.1", "agent")
+
+			// Assert
+			if !errors.Is(err, test.wantErr) {
+				t.Fatalf("error = %v, want %v", err, test.wantErr)
+			}
+			_, guestErr := manager.GetSession(t.Context(), guest.Token)
+			if guestLive := guestErr == nil; guestLive == test.wantUpgraded {
+				t.Errorf("guest token still live = %v, want %v", guestLive, !test.wantUpgraded)
+			}
+			guestUser, err := storage.GetUserByID(t.Context(), guest.User.ID)
+			if err != nil {
+				t.Fatalf("GetUserByID error: %v", err)
+			}
+			if guestUser.Anonymous() == test.wantUpgraded {
+				t.Errorf("guest user = %+v, want anonymous %v", guestUser, !test.wantUpgraded)
+			}
+			if !test.wantUpgraded {
+				return
+			}
+			if result.Session.ID != guest.Session.ID || result.User.ID != guest.User.ID || result.Token == guest.Token {
+				t.Errorf("result = %+v, want session %s of user %s under a new token", result, guest.Session.ID, guest.User.ID)
+			}
+			if result.RefreshToken == "" {
+				t.Error("upgraded session got no refresh token")
+			}
+			if _, err := manager.SignIn(t.Context(), core.SignInInput{Email: email, Password: "CorrectPass123!"}, "", ""); err != nil {
+				t.Errorf("SignIn after upgrade error: %v", err)
+			}
+		})
+	}
+}
+
+// Requirement: Cleanup deletes anonymous users older than a session's max
+// age once none of their sessions is live, and keeps everyone else.
+func TestSessionManager_PurgeAnonymousUsers(t *testing.T) {
+	tests := []struct {
+		name          string
+		age           time.Duration
+		sessionExpiry time.Duration
+		wantPurged    bool
+	}{
+		{name: "abandoned guest", age: 2 * time.Hour, sessionExpiry: -time.Hour, wantPurged: true},
+		{name: "guest with a live session", age: 2 * time.Hour, sessionExpiry: time.Hour},
+		{name: "recent guest", age: time.Minute, sessionExpiry: -time.Second},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// Arrange
+			storage := NewFakeStorageProvider()
+			manager := newAnonymousManager(t, storage)
+			guest, err := manager.CreateAnonymous(t.Context(), "127.0.0.1", "agent")
+			if err != nil {
+				t.Fatalf("CreateAnonymous error: %v", err)
+			}
+			now := time.Now()
+			storage.users[guest.User.ID].CreatedAt = now.Add(-test.age)
+			for _, session := range storage.sessions {
+				if session.UserID == guest.User.ID {
+					session.ExpiresAt = now.Add(test.sessionExpiry)
+				}
+			}
+
+			// Act
+			purged, err := manager.purgeAnonymousUsers(t.Context(), now)
+
+			// Assert
+			if err != nil {
+				t.Fatalf("purgeAnonymousUsers error: %v", err)
+			}
+			_, err = storage.GetUserByID(t.Context(), guest.User.ID)
+			if gone := errors.Is(err, core.ErrUserNotFound); gone != test.wantPurged || (purged == 1) != test.wantPurged {
+				t.Errorf("purged = %d, guest deleted = %v, want deleted %v", purged, gone, test.wantPurged)
+			}
+			if _, err := storage.GetUserByEmail(t.Context(), "taken@example.com"); err != nil {
+				t.Errorf("signed-up user lookup error: %v", err)
+			}
+		})
+	}
+}
+
+// Requirement: A guest can't link providers, create access tokens or set up
+// 2FA, which would be purged along with them; they sign up first.
+func TestSessionManager_GuestAccountManagement(t *testing.T) {
+	tests := []struct {
+		name string
+		act  func(manager *SessionManager, userID string) error
+	}{
+		{
+			name: "link provider",
+			act: func(manager *SessionManager, userID string) error {
+				_, err := manager.StartOAuthLink(t.Context(), userID, "test")
+				return err
+			},
+		},
+		{
+			name: "create access token",
+			act: func(manager *SessionManager, userID string) error {
+				_, err := manager.CreateAccessToken(t.Context(), userID, core.CreateAccessTokenInput{Name: "ci", Scopes: []string{"read"}})
+				return err
+			},
+		},
+		{
+			name: "set up 2FA",
+			act: func(manager *SessionManager, userID string) error {
+				_, err := manager.SetupTOTP(t.Context(), userID)
+				return err
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// Arrange
+			sealer, _ := crypto.NewSealer("this-is-a-test-secret-of-32-bytes!", TOTPSealerPurpose)
+			manager := newAnonymousManager(t, NewFakeStorageProvider(),
+				WithOAuth(cache.NewInMemoryOAuthStateStore(), "https://app.example/api/auth", &fakeOAuthProvider{}),
+				WithAccessTokens(cache.NewInMemoryAccessTokenStorage(), "read"),
+				WithTwoFactor(core.TwoFactorConfig{}, sealer))
+			guest, err := manager.CreateAnonymous(t.Context(), "", "")
+			if err != nil {
+				t.Fatalf("CreateAnonymous error: %v", err)
+			}
+
+			// Act
+			err = test.act(manager, guest.User.ID)
+
+			// Assert
+			if !errors.Is(err, core.ErrAnonymousUser) {
+				t.Errorf("error = %v, want %v", err, core.ErrAnonymousUser)
+			}
+		})
+	}
+}
@@ -0,0 +1,96 @@
+package services
+
+import (
+	"context"
+	"github.com/lborres/kuta/core"
+)
+
+// requiresApproval reports whether new users wait in the approval queue
+func (sm *SessionManager) requiresApproval() bool {
+	return sm.onboarding.RequireApproval
+}
+
+// checkUserStatus refuses sign-ins of users still in the approval queue or
+// rejected from it, and of guest users, who only have their guest session
+func checkUserStatus(user *core.User) error {
+	switch user.Status {
+	case core.UserStatusPending:
+		return core.ErrApprovalPending
+	case core.UserStatusRejected:
+		return core.ErrAccountRejected
+	case core.UserStatusAnonymous:
+		return core.ErrAnonymousUser
+	default:
+		return nil
+	}
+}
+
+func (sm *SessionManager) emitPendingApproval(user *core.User, ipAddress, userAgent string) {
+	sm.emit(core.Event{
+		Type:      core.EventUserPendingApproval,
+		UserID:    user.ID,
+		Email:     user.Email,
+		IPAddress: ipAddress,
+		UserAgent: userAgent,
+	})
+}
+
+// ListPendingUsers returns the users waiting for approval, oldest first
+func (sm *SessionManager) ListPendingUsers(ctx context.Context, limit, offset int) (*core.UserPage, error) {
+	if limit <= 0 {
+		limit = defaultSessionPageSize
+	}
+	if limit > maxSessionPageSize {
+		limit = maxSessionPageSize
+	}
+	if offset < 0 {
+		offset = 0
+	}
+
+	users, total, err := sm.storage.ListUsersByStatus(ctx, core.UserStatusPending, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	if users == nil {
+		users = []*core.User{}
+	}
+
+	return &core.UserPage{Users: users, Total: total, Limit: limit, Offset: offset}, nil
+}
+
+// ApproveUser lets a pending user sign in. actorUserID is the deciding admin,
+// reported with EventUserApproved. Returns ErrUserNotFound unless the user is
+// pending.
+func (sm *SessionManager) ApproveUser(ctx context.Context, actorUserID, userID string) error {
+	return sm.decideApproval(ctx, actorUserID, userID, core.UserStatusActive, core.EventUserApproved, "")
+}
+
+// RejectUser turns a pending user away for good; their email stays taken
+// until the user is deleted. reason is reported with EventUserRejected.
+// Returns ErrUserNotFound unless the user is pending.
+func (sm *SessionManager) RejectUser(ctx context.Context, actorUserID, userID, reason string) error {
+	return sm.decideApproval(ctx, actorUserID, userID, core.UserStatusRejected, core.EventUserRejected, reason)
+}
+
+func (sm *SessionManager) decideApproval(ctx context.Context, actorUserID, userID, status string, event core.EventType, reason string) error {
+	user, err := sm.storage.GetUserByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	if err := sm.storage.SetUserStatus(ctx, userID, core.UserStatusPending, status); err != nil {
+		return err
+	}
+
+	metadata := map[string]any{"actorUserId": actorUserID}
+	if reason != "" {
+		metadata["reason"] = reason
+	}
+	sm.emit(core.Event{
+		Type:     event,
+		UserID:   user.ID,
+		Email:    user.Email,
+		Metadata: metadata,
+	})
+	return nil
+}
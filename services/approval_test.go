@@ -0,0 +1,118 @@
+package services
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/lborres/kuta/core"
+	"github.com/lborres/kuta/pkg/crypto"
+)
+
+func newApprovalTestManager(storage *FakeStorageProvider, events *[]core.Event) *SessionManager {
+	passwords := &crypto.Argon2{Memory: 1024, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32}
+	return NewSessionManager(core.SessionConfig{MaxAge: time.Hour}, storage, nil, passwords,
+		WithOnboarding(core.OnboardingConfig{RequireApproval: true}),
+		WithEventHandler(core.EventHandlerFunc(func(e core.Event) {
+			*events = append(*events, e)
+		})),
+	)
+}
+
+// Requirement: With approval required, sign-up issues no session and the user
+// can't sign in until an admin approves; rejected users stay locked out. Each
+// step fires its event.
+func TestSessionManager_SignUpApproval(t *testing.T) {
+	tests := []struct {
+		name       string
+		decide     func(sm *SessionManager, userID string) error
+		wantSignIn error
+		wantEvent  core.EventType
+	}{
+		{name: "pending", wantSignIn: core.ErrApprovalPending, wantEvent: core.EventUserPendingApproval},
+		{
+			name:      "approved",
+			decide:    func(sm *SessionManager, userID string) error { return sm.ApproveUser(t.Context(), "admin", userID) },
+			wantEvent: core.EventUserApproved,
+		},
+		{
+			name: "rejected",
+			decide: func(sm *SessionManager, userID string) error {
+				return sm.RejectUser(t.Context(), "admin", userID, "spam")
+			},
+			wantSignIn: core.ErrAccountRejected,
+			wantEvent:  core.EventUserRejected,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// Arrange
+			var events []core.Event
+			manager := newApprovalTestManager(NewFakeStorageProvider(), &events)
+			signUp, err := manager.SignUp(t.Context(), core.SignUpInput{Email: "user@example.com", Password: "CorrectPass123!"}, "", "")
+			if err != nil {
+				t.Fatalf("SignUp error: %v", err)
+			}
+			if signUp.Session != nil || signUp.Token != "" || !signUp.RequiresApproval || signUp.NextStep != core.NextStepAwaitApproval {
+				t.Fatalf("SignUp result = %+v, want no session and the await approval step", signUp)
+			}
+
+			// Act
+			if test.decide != nil {
+				if err := test.decide(manager, signUp.User.ID); err != nil {
+					t.Fatalf("decision error: %v", err)
+				}
+			}
+			_, err = manager.SignIn(t.Context(), core.SignInInput{Email: "user@example.com", Password: "CorrectPass123!"}, "", "")
+
+			// Assert
+			if !errors.Is(err, test.wantSignIn) {
+				t.Errorf("SignIn() error = %v, want %v", err, test.wantSignIn)
+			}
+			var found *core.Event
+			for i := range events {
+				if events[i].Type == test.wantEvent {
+					found = &events[i]
+				}
+			}
+			if found == nil {
+				t.Fatalf("no %s event in %v", test.wantEvent, events)
+			}
+			if test.decide != nil && found.Metadata["actorUserId"] != "admin" {
+				t.Errorf("event metadata = %v, want actorUserId admin", found.Metadata)
+			}
+		})
+	}
+}
+
+// Requirement: The approval queue lists pending users oldest first, and only
+// pending users can be approved or rejected.
+func TestSessionManager_ListPendingUsers(t *testing.T) {
+	// Arrange
+	var events []core.Event
+	storage := NewFakeStorageProvider()
+	manager := newApprovalTestManager(storage, &events)
+	now := time.Now()
+	_ = storage.CreateUser(t.Context(), &core.User{ID: "u1", Email: "a@example.com", Status: core.UserStatusPending, CreatedAt: now.Add(-2 * time.Hour)})
+	_ = storage.CreateUser(t.Context(), &core.User{ID: "u2", Email: "b@example.com", Status: core.UserStatusPending, CreatedAt: now.Add(-time.Hour)})
+	_ = storage.CreateUser(t.Context(), &core.User{ID: "u3", Email: "c@example.com", CreatedAt: now})
+
+	// Act
+	page, err := manager.ListPendingUsers(t.Context(), 0, 0)
+	approveActive := manager.ApproveUser(t.Context(), "admin", "u3")
+
+	// Assert
+	if err != nil {
+		t.Fatalf("ListPendingUsers() error = %v", err)
+	}
+	if page.Total != 2 || len(page.Users) != 2 || page.Users[0].ID != "u1" || page.Users[1].ID != "u2" {
+		t.Errorf("page = %+v, want u1 then u2", page)
+	}
+	if page.Limit != defaultSessionPageSize {
+		t.Errorf("Limit = %d, want %d", page.Limit, defaultSessionPageSize)
+	}
+	if !errors.Is(approveActive, core.ErrUserNotFound) {
+		t.Errorf("ApproveUser() on an active user error = %v, want %v", approveActive, core.ErrUserNotFound)
+	}
+}
@@ -0,0 +1,89 @@
+package services
+
+import (
+	"context"
+	"time"
+
+	"github.com/lborres/kuta/core"
+	"github.com/lborres/kuta/pkg/crypto"
+)
+
+const (
+	maxVerifyBatch = 1000
+)
+
+// VerifyBatch verifies many tokens at once, returning one result per token in
+// the same order. Cache misses are resolved with a single storage query.
+// An error is returned only when the batch as a whole can't be processed.
+func (sm *SessionManager) VerifyBatch(ctx context.Context, tokens []string) ([]core.VerifyResult, error) {
+	if len(tokens) > maxVerifyBatch {
+		return nil, core.ErrBatchTooLarge
+	}
+
+	results := make([]core.VerifyResult, len(tokens))
+	now := time.Now()
+
+	// Resolve what we can without storage, remembering which slots still need a lookup
+	pending := make(map[string][]int)
+	for i, token := range tokens {
+		if token == "" {
+			results[i].Err = core.ErrInvalidToken
+			continue
+		}
+
+		if sm.sealer != nil {
+			results[i].Session, results[i].Err = sm.openSession(token)
+			continue
+		}
+
+		tokenHash := crypto.HashToken(token)
+		if sm.cache != nil {
+			if session, err := sm.cache.Get(tokenHash); err == nil {
+				results[i] = checkBatchExpiry(session, now)
+				continue
+			}
+		}
+		pending[tokenHash] = append(pending[tokenHash], i)
+	}
+
+	if len(pending) == 0 {
+		return results, nil
+	}
+
+	hashes := make([]string, 0, len(pending))
+	for hash := range pending {
+		hashes = append(hashes, hash)
+	}
+
+	sessions, err := sm.storage.GetSessionsByHashes(ctx, hashes)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, session := range sessions {
+		result := checkBatchExpiry(session, now)
+		if result.Err == nil && sm.cache != nil {
+			_ = sm.cache.Set(session.TokenHash, session)
+		}
+		for _, i := range pending[session.TokenHash] {
+			results[i] = result
+		}
+		delete(pending, session.TokenHash)
+	}
+
+	// Whatever storage didn't return doesn't exist
+	for _, slots := range pending {
+		for _, i := range slots {
+			results[i].Err = core.ErrSessionNotFound
+		}
+	}
+
+	return results, nil
+}
+
+func checkBatchExpiry(session *core.Session, now time.Time) core.VerifyResult {
+	if now.After(session.ExpiresAt) {
+		return core.VerifyResult{Err: core.ErrSessionExpired}
+	}
+	return core.VerifyResult{Session: session}
+}
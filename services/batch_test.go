@@ -0,0 +1,100 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/lborres/kuta/core"
+	"github.com/lborres/kuta/pkg/crypto"
+)
+
+// countingStorage records how sessions are looked up
+type countingStorage struct {
+	*FakeStorageProvider
+	singleLookups int
+	bulkLookups   int
+}
+
+func (c *countingStorage) GetSessionByHash(ctx context.Context, tokenHash string) (*core.Session, error) {
+	c.singleLookups++
+	return c.FakeStorageProvider.GetSessionByHash(ctx, tokenHash)
+}
+
+func (c *countingStorage) GetSessionsByHashes(ctx context.Context, tokenHashes []string) ([]*core.Session, error) {
+	c.bulkLookups++
+	return c.FakeStorageProvider.GetSessionsByHashes(ctx, tokenHashes)
+}
+
+// Requirement: VerifyBatch returns one result per token, in order, using a single storage query.
+func TestSessionManager_VerifyBatch(t *testing.T) {
+	// Arrange
+	storage := &countingStorage{FakeStorageProvider: NewFakeStorageProvider()}
+	manager := NewSessionManager(core.SessionConfig{MaxAge: time.Hour}, storage, nil, crypto.NewArgon2())
+	valid, err := manager.Create(t.Context(), "user-1", "", "")
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	other, _ := manager.Create(t.Context(), "user-2", "", "")
+	expiredManager := NewSessionManager(core.SessionConfig{MaxAge: -time.Hour}, storage, nil, crypto.NewArgon2())
+	expired, _ := expiredManager.Create(t.Context(), "user-3", "", "")
+
+	tokens := []string{valid.Token, "", "unknown-token", expired.Token, other.Token, valid.Token}
+	want := []struct {
+		userID string
+		err    error
+	}{
+		{userID: "user-1"},
+		{err: core.ErrInvalidToken},
+		{err: core.ErrSessionNotFound},
+		{err: core.ErrSessionExpired},
+		{userID: "user-2"},
+		{userID: "user-1"},
+	}
+
+	// Act
+	results, err := manager.VerifyBatch(t.Context(), tokens)
+
+	// Assert
+	if err != nil {
+		t.Fatalf("VerifyBatch() error = %v", err)
+	}
+	if len(results) != len(tokens) {
+		t.Fatalf("VerifyBatch() returned %d results, want %d", len(results), len(tokens))
+	}
+	for i, w := range want {
+		if !errors.Is(results[i].Err, w.err) {
+			t.Errorf("results[%d].Err = %v, want %v", i, results[i].Err, w.err)
+		}
+		if w.err == nil && (results[i].Session == nil || results[i].Session.UserID != w.userID) {
+			t.Errorf("results[%d].Session = %+v, want user %s", i, results[i].Session, w.userID)
+		}
+	}
+	if storage.bulkLookups != 1 || storage.singleLookups != 0 {
+		t.Errorf("lookups: bulk %d single %d, want 1 bulk and no single", storage.bulkLookups, storage.singleLookups)
+	}
+}
+
+// Requirement: VerifyBatch serves cached sessions without touching storage and rejects oversized batches.
+func TestSessionManager_VerifyBatch_CacheAndLimit(t *testing.T) {
+	// Arrange
+	storage := &countingStorage{FakeStorageProvider: NewFakeStorageProvider()}
+	manager := NewSessionManager(core.SessionConfig{MaxAge: time.Hour}, storage, NewFakeCache(), crypto.NewArgon2())
+	created, _ := manager.Create(t.Context(), "user-1", "", "")
+
+	// Act
+	results, err := manager.VerifyBatch(t.Context(), []string{created.Token})
+	_, tooLargeErr := manager.VerifyBatch(t.Context(), make([]string, maxVerifyBatch+1))
+
+	// Assert
+	if err != nil || results[0].Err != nil {
+		t.Fatalf("VerifyBatch() error = %v, result error = %v", err, results[0].Err)
+	}
+	if storage.bulkLookups != 0 {
+		t.Errorf("cached tokens should not hit storage; got %d bulk lookups", storage.bulkLookups)
+	}
+	if !errors.Is(tooLargeErr, core.ErrBatchTooLarge) {
+		t.Errorf("oversized batch error = %v, want %v", tooLargeErr, core.ErrBatchTooLarge)
+	}
+}
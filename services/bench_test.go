@@ -0,0 +1,104 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/lborres/kuta/core"
+	"github.com/lborres/kuta/pkg/cache"
+	"github.com/lborres/kuta/pkg/crypto"
+)
+
+// End-to-end benchmarks of the auth hot path against the in-memory storage
+// and cache, with allocations reported:
+//
+//	go test -run '^$' -bench . -benchmem ./services
+//
+// Compare runs with benchstat before and after a change that touches them.
+
+const (
+	benchEmail    = "bench@example.com"
+	benchPassword = "CorrectPass123!"
+)
+
+// newBenchSessionManager creates a manager with the default password hashing
+// parameters and a registered user
+func newBenchSessionManager(b *testing.B, sessionCache core.Cache) *SessionManager {
+	b.Helper()
+	manager := NewSessionManager(core.SessionConfig{MaxAge: time.Hour}, NewFakeStorageProvider(), sessionCache, crypto.NewArgon2())
+	if _, err := manager.SignUp(b.Context(), core.SignUpInput{Email: benchEmail, Password: benchPassword, Name: "Bench"}, "127.0.0.1", "bench"); err != nil {
+		b.Fatalf("SignUp error: %v", err)
+	}
+	return manager
+}
+
+func newBenchCache() core.Cache {
+	return cache.NewInMemoryCache(core.CacheConfig{TTL: time.Hour, MaxSize: 1000})
+}
+
+func BenchmarkSignIn(b *testing.B) {
+	manager := newBenchSessionManager(b, nil)
+	input := core.SignInInput{Email: benchEmail, Password: benchPassword}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := manager.SignIn(b.Context(), input, "127.0.0.1", "bench"); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkVerify(b *testing.B) {
+	b.Run("no cache", func(b *testing.B) {
+		benchmarkVerify(b, nil, false)
+	})
+	b.Run("warm cache", func(b *testing.B) {
+		benchmarkVerify(b, newBenchCache(), false)
+	})
+	b.Run("cold cache", func(b *testing.B) {
+		benchmarkVerify(b, newBenchCache(), true)
+	})
+}
+
+// benchmarkVerify verifies one session repeatedly. With evict set, the
+// cached entry is dropped before every call so each one misses, reads
+// storage and refills the cache.
+func benchmarkVerify(b *testing.B, sessionCache core.Cache, evict bool) {
+	manager := newBenchSessionManager(b, sessionCache)
+	result, err := manager.SignIn(b.Context(), core.SignInInput{Email: benchEmail, Password: benchPassword}, "127.0.0.1", "bench")
+	if err != nil {
+		b.Fatalf("SignIn error: %v", err)
+	}
+	tokenHash := crypto.HashToken(result.Token)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if evict {
+			_ = sessionCache.Delete(tokenHash)
+		}
+		if _, err := manager.Verify(b.Context(), result.Token); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkRefresh(b *testing.B) {
+	manager := newBenchSessionManager(b, newBenchCache())
+	result, err := manager.SignIn(b.Context(), core.SignInInput{Email: benchEmail, Password: benchPassword}, "127.0.0.1", "bench")
+	if err != nil {
+		b.Fatalf("SignIn error: %v", err)
+	}
+	refreshToken := result.RefreshToken
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		refreshed, err := manager.Refresh(b.Context(), refreshToken)
+		if err != nil {
+			b.Fatal(err)
+		}
+		refreshToken = refreshed.RefreshToken
+	}
+}
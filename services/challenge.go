@@ -0,0 +1,189 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/lborres/kuta/core"
+	"github.com/lborres/kuta/pkg/cache"
+	"github.com/lborres/kuta/pkg/crypto"
+	"github.com/lborres/kuta/pkg/fingerprint"
+)
+
+const (
+	defaultChallengeTTL = 5 * time.Minute
+	// maxChallengeAttempts is how many wrong responses a challenge accepts
+	// before the sign-in has to start over
+	maxChallengeAttempts = 5
+)
+
+// signInChallenges holds the steps a sign-in may have to pass after the
+// password check, in the order they run
+type signInChallenges struct {
+	store       core.PendingSignInStore
+	ttl         time.Duration
+	challengers []core.SignInChallenger
+	byType      map[string]core.SignInChallenger
+}
+
+func newSignInChallenges(store core.PendingSignInStore, ttl time.Duration, challengers []core.SignInChallenger) *signInChallenges {
+	if ttl <= 0 {
+		ttl = defaultChallengeTTL
+	}
+	byType := make(map[string]core.SignInChallenger, len(challengers))
+	for _, challenger := range challengers {
+		byType[challenger.Type()] = challenger
+	}
+	return &signInChallenges{store: store, ttl: ttl, challengers: challengers, byType: byType}
+}
+
+// newInMemorySignInChallenges keeps pending sign-ins in memory, for built-in
+// challengers such as WithTwoFactor enabled without WithSignInChallenges
+func newInMemorySignInChallenges() *signInChallenges {
+	return newSignInChallenges(cache.NewInMemoryPendingSignInStore(), 0, nil)
+}
+
+// required returns the types of the challenges user must pass for attempt
+func (c *signInChallenges) required(ctx context.Context, user *core.User, attempt core.SignInAttempt) ([]string, error) {
+	var types []string
+	for _, challenger := range c.challengers {
+		ok, err := challenger.Required(ctx, user, attempt)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			types = append(types, challenger.Type())
+		}
+	}
+	return types, nil
+}
+
+// startSignIn either issues the session for a user who signed in with
+// providerID or, when challenges apply, returns the first one
+func (sm *SessionManager) startSignIn(ctx context.Context, user *core.User, providerID, ipAddress, userAgent, publicKey string) (*core.SignInResult, error) {
+	if sm.challenges == nil {
+		return sm.completeSignIn(ctx, user, ipAddress, userAgent, publicKey)
+	}
+
+	required, err := sm.challenges.required(ctx, user, core.SignInAttempt{
+		IPAddress:   ipAddress,
+		UserAgent:   userAgent,
+		Fingerprint: fingerprint.Compute(fingerprint.Input{IPAddress: ipAddress, UserAgent: userAgent}),
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(required) == 0 {
+		return sm.completeSignIn(ctx, user, ipAddress, userAgent, publicKey)
+	}
+
+	return sm.issueChallenge(user, &core.PendingSignIn{
+		UserID:     user.ID,
+		ProviderID: providerID,
+		Challenges: required,
+		IPAddress:  ipAddress,
+		UserAgent:  userAgent,
+		PublicKey:  publicKey,
+	})
+}
+
+// issueChallenge stores pending under a fresh challenge token and returns
+// its current challenge. Every step gets a new token, so a token seen by a
+// step can't be replayed against the next one.
+func (sm *SessionManager) issueChallenge(user *core.User, pending *core.PendingSignIn) (*core.SignInResult, error) {
+	pair, err := crypto.GenerateHashedToken()
+	if err != nil {
+		return nil, err
+	}
+
+	pending.ExpiresAt = time.Now().Add(sm.challenges.ttl)
+	if err := sm.challenges.store.SavePendingSignIn(pair.Hash, pending); err != nil {
+		return nil, err
+	}
+
+	sm.emit(core.Event{
+		Type:      core.EventSignInChallenged,
+		UserID:    user.ID,
+		Email:     user.Email,
+		IPAddress: pending.IPAddress,
+		UserAgent: pending.UserAgent,
+		Metadata:  map[string]any{"challenge": pending.Challenges[0]},
+	})
+
+	return &core.SignInResult{
+		User: user,
+		Challenge: &core.AuthChallenge{
+			Type:      pending.Challenges[0],
+			Token:     pair.Token,
+			ExpiresAt: pending.ExpiresAt,
+		},
+	}, nil
+}
+
+// ContinueSignIn answers the current challenge of a pending sign-in. It
+// returns the next challenge, or the session once none are left.
+//
+// A wrong response can be retried with the same token a few times before the
+// sign-in has to start over.
+func (sm *SessionManager) ContinueSignIn(ctx context.Context, input core.ContinueSignInInput) (*core.SignInResult, error) {
+	record := &core.SignInRecord{}
+	result, err := sm.continueSignIn(ctx, input, record)
+	// Tokens that match no pending sign-in aren't attempts on anyone
+	if record.UserID != "" {
+		sm.recordSignIn(ctx, record, result, err)
+	}
+	return result, err
+}
+
+func (sm *SessionManager) continueSignIn(ctx context.Context, input core.ContinueSignInInput, record *core.SignInRecord) (*core.SignInResult, error) {
+	if sm.challenges == nil || input.ChallengeToken == "" {
+		return nil, core.ErrChallengeNotFound
+	}
+
+	tokenHash := crypto.HashToken(input.ChallengeToken)
+	pending, err := sm.challenges.store.TakePendingSignIn(tokenHash)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := sm.storage.GetUserByID(ctx, pending.UserID)
+	if err != nil {
+		if errors.Is(err, core.ErrUserNotFound) {
+			return nil, core.ErrChallengeNotFound
+		}
+		return nil, err
+	}
+	*record = core.SignInRecord{
+		UserID:     user.ID,
+		Email:      user.Email,
+		ProviderID: pending.ProviderID,
+		IPAddress:  pending.IPAddress,
+		UserAgent:  pending.UserAgent,
+	}
+
+	challenger, ok := sm.challenges.byType[pending.Challenges[0]]
+	if !ok {
+		// The challenger was removed from the config since the sign-in started
+		return nil, core.ErrChallengeNotFound
+	}
+
+	if err := challenger.Verify(ctx, user, input.Response); err != nil {
+		if errors.Is(err, core.ErrChallengeFailed) {
+			pending.Attempts++
+			sm.emitSignInFailed(user.Email, user.ID, pending.IPAddress, pending.UserAgent)
+		}
+		if pending.Attempts < maxChallengeAttempts {
+			_ = sm.challenges.store.SavePendingSignIn(tokenHash, pending)
+		}
+		return nil, err
+	}
+
+	pending.Challenges = pending.Challenges[1:]
+	pending.Attempts = 0
+	if len(pending.Challenges) > 0 {
+		return sm.issueChallenge(user, pending)
+	}
+
+	return sm.completeSignIn(ctx, user, pending.IPAddress, pending.UserAgent, pending.PublicKey)
+}
@@ -0,0 +1,217 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/lborres/kuta/core"
+	"github.com/lborres/kuta/pkg/cache"
+	"github.com/lborres/kuta/pkg/crypto"
+	"github.com/lborres/kuta/pkg/fingerprint"
+)
+
+// fakeChallenger requires a challenge of its type when required is set and
+// accepts answer as the only correct response
+type fakeChallenger struct {
+	kind     string
+	required bool
+	answer   string
+}
+
+func (c fakeChallenger) Type() string { return c.kind }
+
+func (c fakeChallenger) Required(context.Context, *core.User, core.SignInAttempt) (bool, error) {
+	return c.required, nil
+}
+
+func (c fakeChallenger) Verify(ctx context.Context, _ *core.User, response string) error {
+	if response != c.answer {
+		return core.ErrChallengeFailed
+	}
+	return nil
+}
+
+// Requirement: Sign-in walks the challenges that apply, in order, before
+// issuing a session; each step gets a fresh token and wrong answers can be
+// retried.
+func TestSessionManager_SignInChallenges(t *testing.T) {
+	twoFactor := fakeChallenger{kind: core.ChallengeTwoFactor, required: true, answer: "123456"}
+	deviceTrust := fakeChallenger{kind: core.ChallengeDeviceTrust, required: true, answer: "trust"}
+
+	tests := []struct {
+		name           string
+		challengers    []core.SignInChallenger
+		responses      []string
+		wantChallenges []string // challenge types seen, in order
+		wantErr        error    // error of the last response
+		wantSession    bool
+	}{
+		{
+			name:        "no challenge applies",
+			challengers: []core.SignInChallenger{fakeChallenger{kind: core.ChallengeCaptcha}},
+			wantSession: true,
+		},
+		{
+			name:           "two factor then device trust",
+			challengers:    []core.SignInChallenger{twoFactor, deviceTrust},
+			responses:      []string{"123456", "trust"},
+			wantChallenges: []string{core.ChallengeTwoFactor, core.ChallengeDeviceTrust},
+			wantSession:    true,
+		},
+		{
+			name:           "wrong answer is rejected",
+			challengers:    []core.SignInChallenger{twoFactor},
+			responses:      []string{"000000"},
+			wantChallenges: []string{core.ChallengeTwoFactor},
+			wantErr:        core.ErrChallengeFailed,
+		},
+		{
+			name:           "wrong answer can be retried",
+			challengers:    []core.SignInChallenger{twoFactor},
+			responses:      []string{"000000", "123456"},
+			wantChallenges: []string{core.ChallengeTwoFactor},
+			wantSession:    true,
+		},
+		{
+			name:           "too many wrong answers end the sign-in",
+			challengers:    []core.SignInChallenger{twoFactor},
+			responses:      []string{"0", "1", "2", "3", "4", "123456"},
+			wantChallenges: []string{core.ChallengeTwoFactor},
+			wantErr:        core.ErrChallengeNotFound,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// Arrange
+			storage := NewFakeStorageProvider()
+			passwords := &crypto.Argon2{Memory: 1024, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32}
+			manager := NewSessionManager(core.SessionConfig{MaxAge: time.Hour}, storage, nil, passwords,
+				WithSignInChallenges(cache.NewInMemoryPendingSignInStore(), 0, test.challengers...))
+			if _, err := manager.SignUp(t.Context(), core.SignUpInput{Email: "user@example.com", Password: "CorrectPass123!"}, "", ""); err != nil {
+				t.Fatalf("SignUp error: %v", err)
+			}
+
+			// Act
+			result, err := manager.SignIn(t.Context(), core.SignInInput{Email: "user@example.com", Password: "CorrectPass123!"}, "", "")
+			if err != nil {
+				t.Fatalf("SignIn error: %v", err)
+			}
+			var seen []string
+			for _, response := range test.responses {
+				if result.Challenge == nil {
+					t.Fatalf("expected a challenge before response %q", response)
+				}
+				if !slices.Contains(seen, result.Challenge.Type) {
+					seen = append(seen, result.Challenge.Type)
+				}
+				if result.Session != nil || result.Token != "" {
+					t.Fatal("challenged sign-in must not carry a session")
+				}
+				var next *core.SignInResult
+				next, err = manager.ContinueSignIn(t.Context(), core.ContinueSignInInput{ChallengeToken: result.Challenge.Token, Response: response})
+				if err == nil {
+					result = next
+				}
+			}
+
+			// Assert
+			if !errors.Is(err, test.wantErr) {
+				t.Fatalf("error = %v, want %v", err, test.wantErr)
+			}
+			if !slices.Equal(seen, test.wantChallenges) {
+				t.Errorf("challenges = %v, want %v", seen, test.wantChallenges)
+			}
+			if got := result.Session != nil && result.Token != ""; got != test.wantSession {
+				t.Errorf("session issued = %v, want %v", got, test.wantSession)
+			}
+		})
+	}
+}
+
+// Requirement: A challenge token can't be used again once its step passed.
+func TestSessionManager_ContinueSignIn_TokenSingleUse(t *testing.T) {
+	// Arrange
+	storage := NewFakeStorageProvider()
+	passwords := &crypto.Argon2{Memory: 1024, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32}
+	twoFactor := fakeChallenger{kind: core.ChallengeTwoFactor, required: true, answer: "123456"}
+	manager := NewSessionManager(core.SessionConfig{MaxAge: time.Hour}, storage, nil, passwords,
+		WithSignInChallenges(cache.NewInMemoryPendingSignInStore(), 0, twoFactor))
+	if _, err := manager.SignUp(t.Context(), core.SignUpInput{Email: "user@example.com", Password: "CorrectPass123!"}, "", ""); err != nil {
+		t.Fatalf("SignUp error: %v", err)
+	}
+	result, err := manager.SignIn(t.Context(), core.SignInInput{Email: "user@example.com", Password: "CorrectPass123!"}, "", "")
+	if err != nil {
+		t.Fatalf("SignIn error: %v", err)
+	}
+	input := core.ContinueSignInInput{ChallengeToken: result.Challenge.Token, Response: "123456"}
+	if _, err := manager.ContinueSignIn(t.Context(), input); err != nil {
+		t.Fatalf("ContinueSignIn error: %v", err)
+	}
+
+	// Act
+	_, err = manager.ContinueSignIn(t.Context(), input)
+
+	// Assert
+	if !errors.Is(err, core.ErrChallengeNotFound) {
+		t.Errorf("error = %v, want %v", err, core.ErrChallengeNotFound)
+	}
+}
+
+// trustedDeviceChallenger requires a challenge for fingerprints it hasn't seen
+type trustedDeviceChallenger struct {
+	trusted map[string]bool
+}
+
+func (c trustedDeviceChallenger) Type() string { return core.ChallengeDeviceTrust }
+
+func (c trustedDeviceChallenger) Required(ctx context.Context, _ *core.User, attempt core.SignInAttempt) (bool, error) {
+	return !c.trusted[attempt.Fingerprint], nil
+}
+
+func (c trustedDeviceChallenger) Verify(context.Context, *core.User, string) error { return nil }
+
+// Requirement: Challengers get the client fingerprint, which stays the same
+// for a device moving within its subnet.
+func TestSessionManager_SignInChallenges_Fingerprint(t *testing.T) {
+	trusted := fingerprint.Compute(fingerprint.Input{IPAddress: "203.0.113.10", UserAgent: "agent"})
+
+	tests := []struct {
+		name          string
+		ipAddress     string
+		userAgent     string
+		wantChallenge bool
+	}{
+		{name: "trusted device", ipAddress: "203.0.113.10", userAgent: "agent"},
+		{name: "trusted device, new address in subnet", ipAddress: "203.0.113.99", userAgent: "agent"},
+		{name: "other network", ipAddress: "198.51.100.10", userAgent: "agent", wantChallenge: true},
+		{name: "other browser", ipAddress: "203.0.113.10", userAgent: "other", wantChallenge: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// Arrange
+			passwords := &crypto.Argon2{Memory: 1024, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32}
+			challenger := trustedDeviceChallenger{trusted: map[string]bool{trusted: true}}
+			manager := NewSessionManager(core.SessionConfig{MaxAge: time.Hour}, NewFakeStorageProvider(), nil, passwords,
+				WithSignInChallenges(cache.NewInMemoryPendingSignInStore(), 0, challenger))
+			if _, err := manager.SignUp(t.Context(), core.SignUpInput{Email: "user@example.com", Password: "CorrectPass123!"}, "", ""); err != nil {
+				t.Fatalf("SignUp error: %v", err)
+			}
+
+			// Act
+			result, err := manager.SignIn(t.Context(), core.SignInInput{Email: "user@example.com", Password: "CorrectPass123!"}, test.ipAddress, test.userAgent)
+
+			// Assert
+			if err != nil {
+				t.Fatalf("SignIn error: %v", err)
+			}
+			if got := result.Challenge != nil; got != test.wantChallenge {
+				t.Errorf("challenged = %v, want %v", got, test.wantChallenge)
+			}
+		})
+	}
+}
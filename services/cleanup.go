@@ -0,0 +1,164 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/lborres/kuta/core"
+)
+
+const (
+	defaultCleanupInterval        = time.Hour
+	defaultConsumedTokenRetention = 24 * time.Hour
+)
+
+// cleanupJob purges one kind of stale data and returns how much it removed
+type cleanupJob struct {
+	name string
+	run  func(ctx context.Context, sm *SessionManager, now time.Time) (int, error)
+}
+
+// cleanupJobs are run in this order on every cleanup pass
+var cleanupJobs = []cleanupJob{
+	{core.CleanupExpiredSessions, func(ctx context.Context, sm *SessionManager, _ time.Time) (int, error) {
+		return sm.storage.DeleteExpiredSessions(ctx)
+	}},
+	{core.CleanupExpiredRefreshTokens, func(ctx context.Context, sm *SessionManager, _ time.Time) (int, error) {
+		return sm.storage.DeleteExpiredRefreshTokens(ctx)
+	}},
+	{core.CleanupVerificationTokens, func(ctx context.Context, sm *SessionManager, now time.Time) (int, error) {
+		return sm.storage.PurgeVerificationTokens(ctx, now, now.Add(-sm.cleanup.consumedRetention))
+	}},
+	{core.CleanupDeletedUsers, func(ctx context.Context, sm *SessionManager, _ time.Time) (int, error) {
+		return sm.PurgeDeletedUsers(ctx)
+	}},
+	{core.CleanupSignInRecords, func(ctx context.Context, sm *SessionManager, now time.Time) (int, error) {
+		return sm.purgeSignInRecords(ctx, now)
+	}},
+	{core.CleanupAnonymousUsers, func(ctx context.Context, sm *SessionManager, now time.Time) (int, error) {
+		return sm.purgeAnonymousUsers(ctx, now)
+	}},
+}
+
+// cleanupWorker holds the cleanup schedule and the stats of every job
+type cleanupWorker struct {
+	interval          time.Duration
+	consumedRetention time.Duration
+
+	mu    sync.Mutex
+	stats map[string]*core.CleanupStats
+}
+
+func newCleanupWorker(interval, consumedRetention time.Duration) *cleanupWorker {
+	if interval <= 0 {
+		interval = defaultCleanupInterval
+	}
+	if consumedRetention <= 0 {
+		consumedRetention = defaultConsumedTokenRetention
+	}
+	stats := make(map[string]*core.CleanupStats, len(cleanupJobs))
+	for _, job := range cleanupJobs {
+		stats[job.name] = &core.CleanupStats{Job: job.name}
+	}
+	return &cleanupWorker{interval: interval, consumedRetention: consumedRetention, stats: stats}
+}
+
+func (w *cleanupWorker) record(job string, now time.Time, purged int, err error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	stats := w.stats[job]
+	stats.Runs++
+	stats.LastRun = now
+	stats.Purged += int64(purged)
+	stats.LastError = ""
+	if err != nil {
+		stats.Failures++
+		stats.LastError = err.Error()
+	}
+}
+
+// RunCleanupOnce runs every cleanup job once: expired sessions and refresh
+// tokens, consumed or expired verification tokens, soft-deleted users and
+// logged sign-in attempts past their retention. A failing job doesn't stop the others; their errors are
+// joined.
+func (sm *SessionManager) RunCleanupOnce(ctx context.Context) error {
+	var errs []error
+	for _, job := range cleanupJobs {
+		now := time.Now()
+		purged, err := job.run(ctx, sm, now)
+		sm.cleanup.record(job.name, now, purged, err)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", job.name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// RunCleanup runs the cleanup jobs on the configured interval until ctx is
+// done, starting right away. Their storage calls run under ctx, so a purge
+// in progress stops at shutdown.
+func (sm *SessionManager) RunCleanup(ctx context.Context) {
+	ticker := time.NewTicker(sm.cleanup.interval)
+	defer ticker.Stop()
+
+	for {
+		_ = sm.RunCleanupOnce(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// CleanupStats returns the stats of every cleanup job, in the order they run
+func (sm *SessionManager) CleanupStats() []core.CleanupStats {
+	w := sm.cleanup
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	stats := make([]core.CleanupStats, 0, len(cleanupJobs))
+	for _, job := range cleanupJobs {
+		stats = append(stats, *w.stats[job.name])
+	}
+	return stats
+}
+
+// WriteCleanupMetrics writes the cleanup stats as kuta_cleanup_runs_total,
+// kuta_cleanup_failures_total and kuta_cleanup_purged_total counters,
+// labelled by job, in the Prometheus text exposition format
+func (sm *SessionManager) WriteCleanupMetrics(w io.Writer) error {
+	stats := sm.CleanupStats()
+
+	var err error
+	printf := func(format string, args ...any) {
+		if err == nil {
+			_, err = fmt.Fprintf(w, format, args...)
+		}
+	}
+
+	counters := []struct {
+		name  string
+		help  string
+		value func(core.CleanupStats) int64
+	}{
+		{"kuta_cleanup_runs_total", "Cleanup job runs.", func(s core.CleanupStats) int64 { return s.Runs }},
+		{"kuta_cleanup_failures_total", "Cleanup job runs that returned an error.", func(s core.CleanupStats) int64 { return s.Failures }},
+		{"kuta_cleanup_purged_total", "Rows removed by cleanup jobs.", func(s core.CleanupStats) int64 { return s.Purged }},
+	}
+	for _, counter := range counters {
+		printf("# HELP %s %s\n", counter.name, counter.help)
+		printf("# TYPE %s counter\n", counter.name)
+		for _, s := range stats {
+			printf("%s{job=%q} %d\n", counter.name, s.Job, counter.value(s))
+		}
+	}
+
+	return err
+}
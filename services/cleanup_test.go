@@ -0,0 +1,107 @@
+package services
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lborres/kuta/core"
+	"github.com/lborres/kuta/pkg/crypto"
+)
+
+// Requirement: RunCleanupOnce purges expired verification tokens and consumed
+// ones past the retention, keeping live and recently consumed tokens, and
+// records the purged counts per job.
+func TestSessionManager_RunCleanupOnce(t *testing.T) {
+	now := time.Now()
+	consumedLongAgo := now.Add(-2 * time.Hour)
+	consumedRecently := now.Add(-time.Minute)
+
+	tests := []struct {
+		name       string
+		token      core.VerificationToken
+		wantPurged bool
+	}{
+		{name: "live", token: core.VerificationToken{ExpiresAt: now.Add(time.Hour)}},
+		{name: "expired", token: core.VerificationToken{ExpiresAt: now.Add(-time.Minute)}, wantPurged: true},
+		{name: "consumed past retention", token: core.VerificationToken{ExpiresAt: now.Add(time.Hour), ConsumedAt: &consumedLongAgo}, wantPurged: true},
+		{name: "consumed within retention", token: core.VerificationToken{ExpiresAt: now.Add(time.Hour), ConsumedAt: &consumedRecently}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// Arrange
+			storage := NewFakeStorageProvider()
+			token := test.token
+			token.ID = "v1"
+			token.Identifier = "user@example.com"
+			token.Purpose = core.VerificationEmail
+			token.TokenHash = "hash-v1"
+			_ = storage.CreateVerificationToken(t.Context(), &token)
+			manager := NewSessionManager(core.SessionConfig{MaxAge: time.Hour}, storage, nil, crypto.NewArgon2(), WithCleanup(time.Minute, time.Hour))
+
+			// Act
+			err := manager.RunCleanupOnce(t.Context())
+
+			// Assert
+			if err != nil {
+				t.Fatalf("RunCleanupOnce() error = %v", err)
+			}
+			_, stillStored := storage.verifications["hash-v1"]
+			if stillStored == test.wantPurged {
+				t.Errorf("token stored = %v, want %v", stillStored, !test.wantPurged)
+			}
+			var stats core.CleanupStats
+			for _, s := range manager.CleanupStats() {
+				if s.Job == core.CleanupVerificationTokens {
+					stats = s
+				}
+			}
+			wantPurged := int64(0)
+			if test.wantPurged {
+				wantPurged = 1
+			}
+			if stats.Runs != 1 || stats.Failures != 0 || stats.Purged != wantPurged {
+				t.Errorf("stats = %+v, want 1 run, 0 failures, %d purged", stats, wantPurged)
+			}
+		})
+	}
+}
+
+// Requirement: WriteCleanupMetrics exposes runs, failures and purged counts
+// per job as Prometheus counters.
+func TestSessionManager_WriteCleanupMetrics(t *testing.T) {
+	// Arrange
+	storage := NewFakeStorageProvider()
+	_ = storage.CreateVerificationToken(t.Context(), &core.VerificationToken{
+		ID:         "v1",
+		Identifier: "user@example.com",
+		Purpose:    core.VerificationPasswordReset,
+		TokenHash:  "hash-v1",
+		ExpiresAt:  time.Now().Add(-time.Minute),
+	})
+	manager := newTestSessionManager(storage, nil)
+	_ = manager.RunCleanupOnce(t.Context())
+	_ = manager.RunCleanupOnce(t.Context())
+
+	// Act
+	var out bytes.Buffer
+	err := manager.WriteCleanupMetrics(&out)
+
+	// Assert
+	if err != nil {
+		t.Fatalf("WriteCleanupMetrics() error = %v", err)
+	}
+	for _, want := range []string{
+		"# TYPE kuta_cleanup_purged_total counter",
+		`kuta_cleanup_runs_total{job="verification_tokens"} 2`,
+		`kuta_cleanup_failures_total{job="expired_sessions"} 0`,
+		`kuta_cleanup_purged_total{job="verification_tokens"} 1`,
+		`kuta_cleanup_purged_total{job="deleted_users"} 0`,
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("metrics missing %q:\n%s", want, out.String())
+		}
+	}
+}
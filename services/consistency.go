@@ -0,0 +1,53 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+
+	"github.com/lborres/kuta/core"
+)
+
+// ConsistencyStats reports how many cache hits were checked against storage
+// and how many of them had to be repaired.
+func (sm *SessionManager) ConsistencyStats() core.ConsistencyStats {
+	return core.ConsistencyStats{
+		Checks:  sm.state.consistencyChecks.Load(),
+		Repairs: sm.state.consistencyRepairs.Load(),
+	}
+}
+
+// sampleConsistency decides whether this cache hit gets checked
+func (sm *SessionManager) sampleConsistency() bool {
+	if sm.consistencySampleRate <= 0 {
+		return false
+	}
+	return sm.consistencySampleRate >= 1 || rand.Float64() < sm.consistencySampleRate
+}
+
+// checkCachedSession compares a cached session with storage. Stale entries
+// are evicted (session gone) or overwritten (session changed) and the storage
+// version wins. Storage errors fall back to the cached copy so a flaky
+// database doesn't fail requests the cache could serve.
+func (sm *SessionManager) checkCachedSession(ctx context.Context, tokenHash string, cached *core.Session) (*core.Session, error) {
+	sm.state.consistencyChecks.Add(1)
+
+	stored, err := sm.storage.GetSessionByHash(ctx, tokenHash)
+	if errors.Is(err, core.ErrSessionNotFound) {
+		sm.state.consistencyRepairs.Add(1)
+		sm.evictCached(tokenHash)
+		return nil, core.ErrSessionNotFound
+	}
+	if err != nil {
+		return cached, nil
+	}
+
+	if stored.ID != cached.ID || stored.UserID != cached.UserID ||
+		stored.PublicKey != cached.PublicKey || !stored.ExpiresAt.Equal(cached.ExpiresAt) ||
+		stored.Draining() != cached.Draining() {
+		sm.state.consistencyRepairs.Add(1)
+		_ = sm.cache.Set(tokenHash, stored)
+	}
+
+	return stored, nil
+}
@@ -0,0 +1,94 @@
+package services
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/lborres/kuta/core"
+	"github.com/lborres/kuta/pkg/crypto"
+)
+
+// Requirement: Sampled cache hits are checked against storage and stale entries are repaired.
+func TestSessionManager_CacheConsistencyChecks(t *testing.T) {
+	tests := []struct {
+		name        string
+		sampleRate  float64
+		mutate      func(storage *FakeStorageProvider, tokenHash string)
+		wantErr     error
+		wantChecks  int64
+		wantRepairs int64
+		wantCached  bool
+	}{
+		{
+			name:       "consistent entry is left alone",
+			sampleRate: 1,
+			mutate:     func(*FakeStorageProvider, string) {},
+			wantChecks: 1,
+			wantCached: true,
+		},
+		{
+			name:       "session revoked behind the cache is evicted",
+			sampleRate: 1,
+			mutate: func(storage *FakeStorageProvider, tokenHash string) {
+				_ = storage.DeleteSessionByHash(t.Context(), tokenHash)
+			},
+			wantErr:     core.ErrSessionNotFound,
+			wantChecks:  1,
+			wantRepairs: 1,
+		},
+		{
+			name:       "changed session is refreshed in the cache",
+			sampleRate: 1,
+			mutate: func(storage *FakeStorageProvider, tokenHash string) {
+				session, _ := storage.GetSessionByHash(t.Context(), tokenHash)
+				updated := *session
+				updated.ExpiresAt = updated.ExpiresAt.Add(time.Hour)
+				_ = storage.UpdateSession(t.Context(), &updated)
+			},
+			wantChecks:  1,
+			wantRepairs: 1,
+			wantCached:  true,
+		},
+		{
+			name:       "checks disabled serves stale entry",
+			sampleRate: 0,
+			mutate: func(storage *FakeStorageProvider, tokenHash string) {
+				_ = storage.DeleteSessionByHash(t.Context(), tokenHash)
+			},
+			wantCached: true,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			// Arrange
+			storage := NewFakeStorageProvider()
+			cache := NewFakeCache()
+			config := core.SessionConfig{MaxAge: time.Hour}
+			manager := NewSessionManager(config, storage, cache, crypto.NewArgon2(), WithCacheConsistencyChecks(test.sampleRate))
+			created, err := manager.Create(t.Context(), "user-1", "", "")
+			if err != nil {
+				t.Fatalf("Create() error = %v", err)
+			}
+			tokenHash := crypto.HashToken(created.Token)
+			test.mutate(storage, tokenHash)
+
+			// Act
+			_, err = manager.Verify(t.Context(), created.Token)
+
+			// Assert
+			if !errors.Is(err, test.wantErr) {
+				t.Errorf("Verify() error = %v, want %v", err, test.wantErr)
+			}
+			stats := manager.ConsistencyStats()
+			if stats.Checks != test.wantChecks || stats.Repairs != test.wantRepairs {
+				t.Errorf("ConsistencyStats() = %+v, want checks %d repairs %d", stats, test.wantChecks, test.wantRepairs)
+			}
+			if _, err := cache.Get(tokenHash); (err == nil) != test.wantCached {
+				t.Errorf("cache entry present = %v, want %v", err == nil, test.wantCached)
+			}
+		})
+	}
+}
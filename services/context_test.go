@@ -0,0 +1,111 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/lborres/kuta/core"
+)
+
+// contextStorage is a FakeStorageProvider whose calls fail once the context
+// they are given is done, like a database driver would
+type contextStorage struct {
+	*FakeStorageProvider
+}
+
+func (s *contextStorage) GetSessionByHash(ctx context.Context, tokenHash string) (*core.Session, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return s.FakeStorageProvider.GetSessionByHash(ctx, tokenHash)
+}
+
+func (s *contextStorage) GetSessionWithUserByHash(ctx context.Context, tokenHash string) (*core.Session, *core.User, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, nil, err
+	}
+	return s.FakeStorageProvider.GetSessionWithUserByHash(ctx, tokenHash)
+}
+
+func (s *contextStorage) CreateSession(ctx context.Context, session *core.Session) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return s.FakeStorageProvider.CreateSession(ctx, session)
+}
+
+func (s *contextStorage) DeleteExpiredSessions(ctx context.Context) (int, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	return s.FakeStorageProvider.DeleteExpiredSessions(ctx)
+}
+
+func (s *contextStorage) SoftDeleteUser(ctx context.Context, id string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return s.FakeStorageProvider.SoftDeleteUser(ctx, id)
+}
+
+// Requirement: Manager operations run their storage calls under the context
+// they are given, request-scoped or the one stopping a background worker, so
+// a cancelled context stops them without touching stored data.
+func TestSessionManager_Context(t *testing.T) {
+	tests := []struct {
+		name string
+		act  func(manager *SessionManager, ctx context.Context, token string) error
+	}{
+		{
+			name: "GetSession",
+			act: func(manager *SessionManager, ctx context.Context, token string) error {
+				_, err := manager.GetSession(ctx, token)
+				return err
+			},
+		},
+		{
+			name: "DeleteUser",
+			act: func(manager *SessionManager, ctx context.Context, _ string) error {
+				return manager.DeleteUser(ctx, "user-1")
+			},
+		},
+		{
+			name: "RunCleanup",
+			act: func(manager *SessionManager, ctx context.Context, _ string) error {
+				manager.RunCleanup(ctx)
+				stats := manager.CleanupStats()
+				if stats[0].Job != core.CleanupExpiredSessions || stats[0].LastError == "" {
+					return nil
+				}
+				return errors.New(stats[0].LastError)
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// Arrange
+			storage := &contextStorage{FakeStorageProvider: NewFakeStorageProvider()}
+			manager := newTestSessionManager(storage, nil)
+			_ = storage.CreateUser(t.Context(), &core.User{ID: "user-1", Email: "a@example.com"})
+			created, err := manager.Create(t.Context(), "user-1", "", "")
+			if err != nil {
+				t.Fatalf("Create error: %v", err)
+			}
+			ctx, cancel := context.WithCancel(t.Context())
+			cancel()
+
+			// Act
+			err = test.act(manager, ctx, created.Token)
+
+			// Assert
+			if err == nil || err.Error() != context.Canceled.Error() {
+				t.Errorf("error = %v, want %v", err, context.Canceled)
+			}
+			if _, err := manager.GetSession(t.Context(), created.Token); err != nil {
+				t.Errorf("GetSession under a live context error: %v", err)
+			}
+		})
+	}
+}
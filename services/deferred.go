@@ -0,0 +1,480 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"maps"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/lborres/kuta/core"
+)
+
+// defaultSessionPersistInterval is how often RunSessionPersistence writes
+// queued sessions to storage when no interval is configured
+const defaultSessionPersistInterval = time.Second
+
+// sessionFlushTimeout bounds the last persistence run at shutdown
+const sessionFlushTimeout = 5 * time.Second
+
+// deferredSessionStorage issues sessions into a SessionQueue instead of the
+// storage, leaving the write to RunSessionPersistence. Until a session is
+// persisted the session methods serve it from the queue, so this instance
+// sees it at once; other instances only find it through a shared session
+// cache. SearchSessions only covers persisted sessions.
+type deferredSessionStorage struct {
+	core.StorageProvider
+	buffer *sessionBuffer
+}
+
+// sessionBuffer indexes the queued sessions. Sessions left queued by an
+// earlier run are loaded on first use; they may have been written to
+// storage just before it stopped, so deleting one deletes its row too.
+type sessionBuffer struct {
+	queue    core.SessionQueue
+	interval time.Duration
+
+	// mu is also held while a session is persisted, so it can't change or
+	// be deleted halfway
+	mu        sync.Mutex
+	loaded    bool
+	byID      map[string]*core.Session
+	byHash    map[string]string // token hash to session ID
+	recovered map[string]bool   // IDs loaded from an earlier run
+}
+
+func newSessionBuffer(queue core.SessionQueue, interval time.Duration) *sessionBuffer {
+	return &sessionBuffer{
+		queue:     queue,
+		interval:  interval,
+		byID:      make(map[string]*core.Session),
+		byHash:    make(map[string]string),
+		recovered: make(map[string]bool),
+	}
+}
+
+// lock locks the buffer, loading the queued sessions on first use. It
+// returns with the buffer unlocked only when loading fails.
+func (b *sessionBuffer) lock() error {
+	b.mu.Lock()
+	if b.loaded {
+		return nil
+	}
+	sessions, err := b.queue.Pending()
+	if err != nil {
+		b.mu.Unlock()
+		return err
+	}
+	for _, session := range sessions {
+		b.track(session)
+		b.recovered[session.ID] = true
+	}
+	b.loaded = true
+	return nil
+}
+
+// track indexes session. Callers hold mu.
+func (b *sessionBuffer) track(session *core.Session) {
+	if old, ok := b.byID[session.ID]; ok {
+		delete(b.byHash, old.TokenHash)
+	}
+	b.byID[session.ID] = session
+	b.byHash[session.TokenHash] = session.ID
+}
+
+// put queues a copy of session. Callers hold mu.
+func (b *sessionBuffer) put(session *core.Session) error {
+	queued := cloneSession(session)
+	if err := b.queue.Put(queued); err != nil {
+		return err
+	}
+	b.track(queued)
+	return nil
+}
+
+// forget drops the sessions with ids from the queue. Callers hold mu.
+func (b *sessionBuffer) forget(ids ...string) error {
+	for _, id := range ids {
+		if session, ok := b.byID[id]; ok {
+			delete(b.byHash, session.TokenHash)
+			delete(b.byID, id)
+			delete(b.recovered, id)
+		}
+	}
+	return b.queue.Remove(ids...)
+}
+
+// get returns a copy of the queued session with id, or nil
+func (b *sessionBuffer) get(id string) (*core.Session, error) {
+	if err := b.lock(); err != nil {
+		return nil, err
+	}
+	defer b.mu.Unlock()
+	if session, ok := b.byID[id]; ok {
+		return cloneSession(session), nil
+	}
+	return nil, nil
+}
+
+// getByHash returns a copy of the queued session for tokenHash, or nil
+func (b *sessionBuffer) getByHash(tokenHash string) (*core.Session, error) {
+	if err := b.lock(); err != nil {
+		return nil, err
+	}
+	defer b.mu.Unlock()
+	if id, ok := b.byHash[tokenHash]; ok {
+		return cloneSession(b.byID[id]), nil
+	}
+	return nil, nil
+}
+
+// update applies change to a copy of the queued session with id and queues
+// the result. It reports false when the session isn't queued.
+func (b *sessionBuffer) update(id string, change func(*core.Session)) (bool, error) {
+	if err := b.lock(); err != nil {
+		return false, err
+	}
+	defer b.mu.Unlock()
+	session, ok := b.byID[id]
+	if !ok {
+		return false, nil
+	}
+	updated := cloneSession(session)
+	change(updated)
+	return true, b.put(updated)
+}
+
+// take drops the queued sessions matching match, returning their IDs and
+// those of them recovered from an earlier run
+func (b *sessionBuffer) take(match func(*core.Session) bool) (taken, recovered []string, err error) {
+	if err := b.lock(); err != nil {
+		return nil, nil, err
+	}
+	defer b.mu.Unlock()
+	for id, session := range b.byID {
+		if match(session) {
+			taken = append(taken, id)
+			if b.recovered[id] {
+				recovered = append(recovered, id)
+			}
+		}
+	}
+	if len(taken) == 0 {
+		return nil, nil, nil
+	}
+	return taken, recovered, b.forget(taken...)
+}
+
+// takeIDs is take for the queued sessions among ids
+func (b *sessionBuffer) takeIDs(ids ...string) (taken, recovered []string, err error) {
+	if err := b.lock(); err != nil {
+		return nil, nil, err
+	}
+	defer b.mu.Unlock()
+	for _, id := range ids {
+		if _, ok := b.byID[id]; ok {
+			taken = append(taken, id)
+			if b.recovered[id] {
+				recovered = append(recovered, id)
+			}
+		}
+	}
+	if len(taken) == 0 {
+		return nil, nil, nil
+	}
+	return taken, recovered, b.forget(taken...)
+}
+
+func cloneSession(session *core.Session) *core.Session {
+	copied := *session
+	copied.Data = maps.Clone(session.Data)
+	return &copied
+}
+
+func (s *deferredSessionStorage) CreateSession(ctx context.Context, session *core.Session) error {
+	if err := s.buffer.lock(); err != nil {
+		return err
+	}
+	defer s.buffer.mu.Unlock()
+	return s.buffer.put(session)
+}
+
+func (s *deferredSessionStorage) GetSessionByHash(ctx context.Context, tokenHash string) (*core.Session, error) {
+	if session, err := s.buffer.getByHash(tokenHash); session != nil || err != nil {
+		return session, err
+	}
+	return s.StorageProvider.GetSessionByHash(ctx, tokenHash)
+}
+
+func (s *deferredSessionStorage) GetSessionsByHashes(ctx context.Context, tokenHashes []string) ([]*core.Session, error) {
+	var sessions []*core.Session
+	var stored []string
+	for _, hash := range tokenHashes {
+		session, err := s.buffer.getByHash(hash)
+		if err != nil {
+			return nil, err
+		}
+		if session != nil {
+			sessions = append(sessions, session)
+		} else {
+			stored = append(stored, hash)
+		}
+	}
+	if len(stored) == 0 {
+		return sessions, nil
+	}
+	found, err := s.StorageProvider.GetSessionsByHashes(ctx, stored)
+	if err != nil {
+		return nil, err
+	}
+	return append(sessions, found...), nil
+}
+
+func (s *deferredSessionStorage) GetSessionByID(ctx context.Context, id string) (*core.Session, error) {
+	if session, err := s.buffer.get(id); session != nil || err != nil {
+		return session, err
+	}
+	return s.StorageProvider.GetSessionByID(ctx, id)
+}
+
+func (s *deferredSessionStorage) GetSessionWithUserByHash(ctx context.Context, tokenHash string) (*core.Session, *core.User, error) {
+	session, err := s.buffer.getByHash(tokenHash)
+	if err != nil {
+		return nil, nil, err
+	}
+	if session == nil {
+		return s.StorageProvider.GetSessionWithUserByHash(ctx, tokenHash)
+	}
+	user, err := s.StorageProvider.GetUserByID(ctx, session.UserID)
+	if errors.Is(err, core.ErrUserNotFound) {
+		return session, nil, nil
+	}
+	return session, user, err
+}
+
+// GetUserSessions lists the user's queued sessions, newest first, ahead of
+// the stored ones
+func (s *deferredSessionStorage) GetUserSessions(ctx context.Context, userID string) ([]*core.Session, error) {
+	if err := s.buffer.lock(); err != nil {
+		return nil, err
+	}
+	var sessions []*core.Session
+	queued := make(map[string]bool)
+	for id, session := range s.buffer.byID {
+		if session.UserID == userID {
+			sessions = append(sessions, cloneSession(session))
+			queued[id] = true
+		}
+	}
+	s.buffer.mu.Unlock()
+	slices.SortFunc(sessions, func(a, b *core.Session) int { return b.CreatedAt.Compare(a.CreatedAt) })
+
+	stored, err := s.StorageProvider.GetUserSessions(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	for _, session := range stored {
+		// Persisted since the queue was read
+		if !queued[session.ID] {
+			sessions = append(sessions, session)
+		}
+	}
+	return sessions, nil
+}
+
+func (s *deferredSessionStorage) UpdateSession(ctx context.Context, session *core.Session) error {
+	queued, err := s.buffer.update(session.ID, func(queued *core.Session) { *queued = *cloneSession(session) })
+	if queued || err != nil {
+		return err
+	}
+	return s.StorageProvider.UpdateSession(ctx, session)
+}
+
+func (s *deferredSessionStorage) SetSessionValue(ctx context.Context, sessionID, key, value string) error {
+	queued, err := s.buffer.update(sessionID, func(session *core.Session) {
+		if session.Data == nil {
+			session.Data = make(map[string]string)
+		}
+		session.Data[key] = value
+	})
+	if queued || err != nil {
+		return err
+	}
+	return s.StorageProvider.SetSessionValue(ctx, sessionID, key, value)
+}
+
+func (s *deferredSessionStorage) DeleteSessionValue(ctx context.Context, sessionID, key string) error {
+	queued, err := s.buffer.update(sessionID, func(session *core.Session) { delete(session.Data, key) })
+	if queued || err != nil {
+		return err
+	}
+	return s.StorageProvider.DeleteSessionValue(ctx, sessionID, key)
+}
+
+// deleteRecovered deletes the rows an earlier run may have written for
+// recovered sessions it hadn't yet dropped from the queue. They are counted
+// as queued sessions.
+func (s *deferredSessionStorage) deleteRecovered(ctx context.Context, ids []string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	_, err := s.StorageProvider.DeleteSessionsByIDs(ctx, ids)
+	return err
+}
+
+func (s *deferredSessionStorage) DeleteSessionByID(ctx context.Context, id string) error {
+	taken, recovered, err := s.buffer.takeIDs(id)
+	if err != nil {
+		return err
+	}
+	if len(taken) == 0 {
+		return s.StorageProvider.DeleteSessionByID(ctx, id)
+	}
+	return s.deleteRecovered(ctx, recovered)
+}
+
+func (s *deferredSessionStorage) DeleteSessionByHash(ctx context.Context, tokenHash string) error {
+	taken, recovered, err := s.buffer.take(func(session *core.Session) bool { return session.TokenHash == tokenHash })
+	if err != nil {
+		return err
+	}
+	if len(taken) == 0 {
+		return s.StorageProvider.DeleteSessionByHash(ctx, tokenHash)
+	}
+	return s.deleteRecovered(ctx, recovered)
+}
+
+func (s *deferredSessionStorage) DeleteSessionsByIDs(ctx context.Context, ids []string) (int, error) {
+	taken, recovered, err := s.buffer.takeIDs(ids...)
+	if err != nil {
+		return 0, err
+	}
+	if err := s.deleteRecovered(ctx, recovered); err != nil {
+		return len(taken), err
+	}
+	stored := slices.DeleteFunc(slices.Clone(ids), func(id string) bool { return slices.Contains(taken, id) })
+	if len(stored) == 0 {
+		return len(taken), nil
+	}
+	deleted, err := s.StorageProvider.DeleteSessionsByIDs(ctx, stored)
+	return len(taken) + deleted, err
+}
+
+func (s *deferredSessionStorage) DeleteUserSessions(ctx context.Context, userID string) (int, error) {
+	taken, recovered, err := s.buffer.take(func(session *core.Session) bool { return session.UserID == userID })
+	if err != nil {
+		return 0, err
+	}
+	if err := s.deleteRecovered(ctx, recovered); err != nil {
+		return len(taken), err
+	}
+	deleted, err := s.StorageProvider.DeleteUserSessions(ctx, userID)
+	return len(taken) + deleted, err
+}
+
+func (s *deferredSessionStorage) DeleteExpiredSessions(ctx context.Context) (int, error) {
+	now := time.Now()
+	taken, recovered, err := s.buffer.take(func(session *core.Session) bool { return now.After(session.ExpiresAt) })
+	if err != nil {
+		return 0, err
+	}
+	if err := s.deleteRecovered(ctx, recovered); err != nil {
+		return len(taken), err
+	}
+	deleted, err := s.StorageProvider.DeleteExpiredSessions(ctx)
+	return len(taken) + deleted, err
+}
+
+// persist writes the queued sessions to storage, oldest first, and drops
+// them from the queue. It stops at the first failure, leaving the rest
+// queued for the next run.
+func (s *deferredSessionStorage) persist(ctx context.Context) (int, error) {
+	if err := s.buffer.lock(); err != nil {
+		return 0, err
+	}
+	pending, err := s.buffer.queue.Pending()
+	s.buffer.mu.Unlock()
+	if err != nil {
+		return 0, err
+	}
+
+	persisted := 0
+	for _, session := range pending {
+		written, err := s.persistOne(ctx, session.ID)
+		if err != nil {
+			return persisted, err
+		}
+		if written {
+			persisted++
+		}
+	}
+	return persisted, nil
+}
+
+// persistOne writes the queued session with id to storage, unless it was
+// deleted or expired in the meantime. A recovered session whose row was
+// written by an earlier run is updated instead, and one whose user was
+// deleted is dropped.
+func (s *deferredSessionStorage) persistOne(ctx context.Context, id string) (bool, error) {
+	if err := s.buffer.lock(); err != nil {
+		return false, err
+	}
+	defer s.buffer.mu.Unlock()
+
+	session, ok := s.buffer.byID[id]
+	if !ok {
+		return false, nil
+	}
+	if time.Now().After(session.ExpiresAt) {
+		return false, s.buffer.forget(id)
+	}
+
+	err := s.StorageProvider.CreateSession(ctx, cloneSession(session))
+	if err != nil {
+		if _, getErr := s.StorageProvider.GetSessionByID(ctx, id); getErr == nil {
+			err = s.StorageProvider.UpdateSession(ctx, cloneSession(session))
+		} else if _, userErr := s.StorageProvider.GetUserByID(ctx, session.UserID); errors.Is(userErr, core.ErrUserNotFound) {
+			return false, s.buffer.forget(id)
+		}
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, s.buffer.forget(id)
+}
+
+// PersistSessions writes the sessions queued in deferred persistence mode to
+// storage and returns how many were written. It does nothing when the mode
+// is off.
+func (sm *SessionManager) PersistSessions(ctx context.Context) (int, error) {
+	if sm.deferred == nil {
+		return 0, nil
+	}
+	return sm.deferred.persist(ctx)
+}
+
+// RunSessionPersistence persists queued sessions on the configured interval
+// until ctx is done, then once more so sessions issued before shutdown
+// aren't left for the next start. That last run outlives ctx, for up to
+// sessionFlushTimeout. It returns immediately when deferred persistence is
+// off.
+func (sm *SessionManager) RunSessionPersistence(ctx context.Context) {
+	if sm.deferred == nil {
+		return
+	}
+	ticker := time.NewTicker(sm.deferred.buffer.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			flushCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), sessionFlushTimeout)
+			_, _ = sm.PersistSessions(flushCtx)
+			cancel()
+			return
+		case <-ticker.C:
+			_, _ = sm.PersistSessions(ctx)
+		}
+	}
+}
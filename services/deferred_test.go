@@ -0,0 +1,186 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/lborres/kuta/core"
+	"github.com/lborres/kuta/pkg/cache"
+)
+
+// Requirement: In deferred persistence mode a new session is usable at once
+// but only reaches storage when queued sessions are persisted, with the
+// changes made to it meanwhile. Sessions signed out or whose user was
+// deleted meanwhile are never written.
+func TestSessionManager_DeferredSessions(t *testing.T) {
+	tests := []struct {
+		name          string
+		change        func(manager *SessionManager, storage *FakeStorageProvider, created *core.CreateSessionResult) error
+		wantPersisted int
+		wantData      string
+	}{
+		{
+			name:          "persisted",
+			wantPersisted: 1,
+		},
+		{
+			name: "value set before persisting",
+			change: func(manager *SessionManager, _ *FakeStorageProvider, created *core.CreateSessionResult) error {
+				return manager.SetValue(t.Context(), created.Session.ID, "step", "2")
+			},
+			wantPersisted: 1,
+			wantData:      "2",
+		},
+		{
+			name: "signed out before persisting",
+			change: func(manager *SessionManager, _ *FakeStorageProvider, created *core.CreateSessionResult) error {
+				return manager.Destroy(t.Context(), created.Token)
+			},
+		},
+		{
+			name: "user deleted before persisting",
+			change: func(_ *SessionManager, storage *FakeStorageProvider, _ *core.CreateSessionResult) error {
+				storage.createErr = core.ErrUserNotFound
+				return storage.DeleteUser(t.Context(), "user-1")
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// Arrange
+			storage := NewFakeStorageProvider()
+			_ = storage.CreateUser(t.Context(), &core.User{ID: "user-1", Email: "a@example.com"})
+			queue := cache.NewInMemorySessionQueue()
+			manager := NewSessionManager(core.SessionConfig{MaxAge: time.Hour}, storage, NewFakeCache(), nil,
+				WithDeferredSessions(queue, time.Minute))
+			created, err := manager.Create(t.Context(), "user-1", "127.0.0.1", "test")
+			if err != nil {
+				t.Fatalf("Create() error = %v", err)
+			}
+			if _, err := storage.GetSessionByID(t.Context(), created.Session.ID); err == nil {
+				t.Fatal("session stored before it was persisted")
+			}
+			if _, err := manager.Verify(t.Context(), created.Token); err != nil {
+				t.Fatalf("Verify() error = %v before persisting", err)
+			}
+
+			// Act
+			if test.change != nil {
+				if err := test.change(manager, storage, created); err != nil {
+					t.Fatalf("change error = %v", err)
+				}
+			}
+			persisted, err := manager.PersistSessions(t.Context())
+
+			// Assert
+			if err != nil || persisted != test.wantPersisted {
+				t.Fatalf("PersistSessions() = %d, %v; want %d", persisted, err, test.wantPersisted)
+			}
+			if pending, _ := queue.Pending(); len(pending) != 0 {
+				t.Errorf("queue holds %d sessions, want none", len(pending))
+			}
+			stored, err := storage.GetSessionByID(t.Context(), created.Session.ID)
+			if (err == nil) != (test.wantPersisted == 1) {
+				t.Fatalf("stored session = %+v, %v; want it stored: %v", stored, err, test.wantPersisted == 1)
+			}
+			if stored != nil && stored.Data["step"] != test.wantData {
+				t.Errorf("stored data = %v, want step %q", stored.Data, test.wantData)
+			}
+		})
+	}
+}
+
+// uniqueSessionStorage rejects sessions whose ID is taken, as a database does
+type uniqueSessionStorage struct {
+	*FakeStorageProvider
+}
+
+func (s *uniqueSessionStorage) CreateSession(ctx context.Context, session *core.Session) error {
+	if _, err := s.GetSessionByID(ctx, session.ID); err == nil {
+		return errors.New("duplicate session ID")
+	}
+	return s.FakeStorageProvider.CreateSession(ctx, session)
+}
+
+// Requirement: Sessions left queued by an earlier run are served and
+// persisted after a restart, updating the rows the run had already written,
+// and deleting one deletes such a row too.
+func TestSessionManager_DeferredSessionsRecovered(t *testing.T) {
+	tests := []struct {
+		name          string
+		deleteWritten bool
+		wantPersisted int
+	}{
+		{name: "persisted", wantPersisted: 2},
+		{name: "deleted", deleteWritten: true, wantPersisted: 1},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// Arrange
+			storage := &uniqueSessionStorage{FakeStorageProvider: NewFakeStorageProvider()}
+			_ = storage.CreateUser(t.Context(), &core.User{ID: "user-1", Email: "a@example.com"})
+			expiresAt := time.Now().Add(time.Hour)
+			_ = storage.CreateSession(t.Context(), &core.Session{ID: "session-1", UserID: "user-1", TokenHash: "hash-1", IPAddress: "10.0.0.1", ExpiresAt: expiresAt})
+
+			queue := cache.NewInMemorySessionQueue()
+			_ = queue.Put(&core.Session{ID: "session-1", UserID: "user-1", TokenHash: "hash-1", IPAddress: "10.0.0.2", ExpiresAt: expiresAt})
+			_ = queue.Put(&core.Session{ID: "session-2", UserID: "user-1", TokenHash: "hash-2", ExpiresAt: expiresAt})
+			manager := NewSessionManager(core.SessionConfig{MaxAge: time.Hour}, storage, NewFakeCache(), nil,
+				WithDeferredSessions(queue, time.Minute))
+			if found, err := manager.storage.GetSessionByHash(t.Context(), "hash-2"); err != nil || found.ID != "session-2" {
+				t.Fatalf("GetSessionByHash() = %+v, %v; want the queued session", found, err)
+			}
+
+			// Act
+			if test.deleteWritten {
+				if err := manager.DestroyBySessionID(t.Context(), "session-1"); err != nil {
+					t.Fatalf("DestroyBySessionID() error = %v", err)
+				}
+			}
+			persisted, err := manager.PersistSessions(t.Context())
+
+			// Assert
+			if err != nil || persisted != test.wantPersisted {
+				t.Fatalf("PersistSessions() = %d, %v; want %d", persisted, err, test.wantPersisted)
+			}
+			stored, err := storage.GetSessionByID(t.Context(), "session-1")
+			if test.deleteWritten {
+				if err == nil {
+					t.Error("session-1 still stored after it was deleted")
+				}
+				return
+			}
+			if err != nil || stored.IPAddress != "10.0.0.2" {
+				t.Errorf("session-1 = %+v, %v; want it updated to the queued copy", stored, err)
+			}
+		})
+	}
+}
+
+// Requirement: Stopping RunSessionPersistence still writes the queued
+// sessions, although its context is done.
+func TestSessionManager_RunSessionPersistenceFlushesOnShutdown(t *testing.T) {
+	// Arrange
+	storage := &contextStorage{FakeStorageProvider: NewFakeStorageProvider()}
+	_ = storage.CreateUser(t.Context(), &core.User{ID: "user-1", Email: "a@example.com"})
+	manager := NewSessionManager(core.SessionConfig{MaxAge: time.Hour}, storage, NewFakeCache(), nil,
+		WithDeferredSessions(cache.NewInMemorySessionQueue(), time.Hour))
+	created, err := manager.Create(t.Context(), "user-1", "127.0.0.1", "test")
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	ctx, cancel := context.WithCancel(t.Context())
+	cancel()
+
+	// Act
+	manager.RunSessionPersistence(ctx)
+
+	// Assert
+	if _, err := storage.GetSessionByID(t.Context(), created.Session.ID); err != nil {
+		t.Errorf("GetSessionByID() after shutdown error = %v, want the session stored", err)
+	}
+}
@@ -0,0 +1,85 @@
+package services
+
+import (
+	"context"
+	"time"
+
+	"github.com/lborres/kuta/core"
+)
+
+// softRevoke reports whether revocations drain sessions instead of deleting
+// them. Stateless sessions can't be updated, so they are never drained.
+func (sm *SessionManager) softRevoke() bool {
+	return sm.revocationGrace > 0 && sm.sealer == nil
+}
+
+// drainSession marks session as revoked and cuts its lifetime down to the
+// grace period. Draining it again never extends the deadline.
+func (sm *SessionManager) drainSession(ctx context.Context, session *core.Session) error {
+	now := time.Now()
+	if session.RevokedAt == nil {
+		session.RevokedAt = &now
+	}
+	if deadline := now.Add(sm.revocationGrace); session.ExpiresAt.After(deadline) {
+		session.ExpiresAt = deadline
+	}
+
+	if err := sm.storage.UpdateSession(ctx, session); err != nil {
+		return err
+	}
+
+	// Cached copies would still look fully valid
+	sm.evictCached(session.TokenHash)
+	return nil
+}
+
+// drainBySessionID is the soft counterpart of destroyBySessionID
+func (sm *SessionManager) drainBySessionID(ctx context.Context, sessionID string) (string, error) {
+	// Validate input
+	if sessionID == "" {
+		return "", core.ErrSessionNotFound
+	}
+
+	// Refresh tokens die right away; only the session itself drains
+	if _, err := sm.storage.RevokeSessionRefreshTokens(ctx, sessionID); err != nil {
+		return "", err
+	}
+
+	session, err := sm.storage.GetSessionByID(ctx, sessionID)
+	if err != nil {
+		return "", err
+	}
+
+	return session.UserID, sm.drainSession(ctx, session)
+}
+
+// drainAllUserSessions is the soft counterpart of destroyAllUserSessions. It
+// returns how many sessions started draining.
+func (sm *SessionManager) drainAllUserSessions(ctx context.Context, userID string) (int, error) {
+	// Validate input
+	if userID == "" {
+		return 0, core.ErrUserNotFound
+	}
+
+	if _, err := sm.storage.RevokeUserRefreshTokens(ctx, userID); err != nil {
+		return 0, err
+	}
+
+	sessions, err := sm.storage.GetUserSessions(ctx, userID)
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for _, session := range sessions {
+		if session.Draining() {
+			continue
+		}
+		if err := sm.drainSession(ctx, session); err != nil {
+			return count, err
+		}
+		count++
+	}
+
+	return count, nil
+}
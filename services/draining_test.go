@@ -0,0 +1,115 @@
+package services
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/lborres/kuta/core"
+	"github.com/lborres/kuta/pkg/crypto"
+)
+
+// Requirement: With a revocation grace period, revoked sessions keep
+// resolving as draining until the grace ends, while sign-out and user
+// deletion still end them at once.
+func TestSessionManager_RevocationGrace(t *testing.T) {
+	tests := []struct {
+		name         string
+		grace        time.Duration
+		revoke       func(sm *SessionManager, signUp *core.SignUpResult) error
+		wait         time.Duration
+		wantErr      error
+		wantDraining bool
+	}{
+		{
+			name:  "no grace destroys",
+			grace: 0,
+			revoke: func(sm *SessionManager, signUp *core.SignUpResult) error {
+				return sm.RevokeSession(t.Context(), signUp.Session.ID, core.RevokeReasonAdmin)
+			},
+			wantErr: core.ErrSessionNotFound,
+		},
+		{
+			name:  "revoked session drains",
+			grace: time.Minute,
+			revoke: func(sm *SessionManager, signUp *core.SignUpResult) error {
+				return sm.RevokeSession(t.Context(), signUp.Session.ID, core.RevokeReasonAdmin)
+			},
+			wantDraining: true,
+		},
+		{
+			name:  "user sessions drain",
+			grace: time.Minute,
+			revoke: func(sm *SessionManager, signUp *core.SignUpResult) error {
+				_, err := sm.RevokeUserSessions(t.Context(), signUp.User.ID, core.RevokeReasonPasswordChange)
+				return err
+			},
+			wantDraining: true,
+		},
+		{
+			name:  "admin revocation drains",
+			grace: time.Minute,
+			revoke: func(sm *SessionManager, signUp *core.SignUpResult) error {
+				_, err := sm.RevokeSessions(t.Context(), []string{signUp.Session.ID})
+				return err
+			},
+			wantDraining: true,
+		},
+		{
+			name:  "drained session expires after grace",
+			grace: 20 * time.Millisecond,
+			revoke: func(sm *SessionManager, signUp *core.SignUpResult) error {
+				return sm.RevokeSession(t.Context(), signUp.Session.ID, core.RevokeReasonAdmin)
+			},
+			wait:    50 * time.Millisecond,
+			wantErr: core.ErrSessionExpired,
+		},
+		{
+			name:  "sign out is immediate",
+			grace: time.Minute,
+			revoke: func(sm *SessionManager, signUp *core.SignUpResult) error {
+				return sm.SignOut(t.Context(), signUp.Token)
+			},
+			wantErr: core.ErrSessionNotFound,
+		},
+		{
+			name:  "user deletion is immediate",
+			grace: time.Minute,
+			revoke: func(sm *SessionManager, signUp *core.SignUpResult) error {
+				return sm.DeleteUser(t.Context(), signUp.User.ID)
+			},
+			wantErr: core.ErrSessionNotFound,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// Arrange
+			passwords := &crypto.Argon2{Memory: 1024, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32}
+			sm := NewSessionManager(core.SessionConfig{MaxAge: time.Hour}, NewFakeStorageProvider(), nil, passwords,
+				WithRevocationGrace(test.grace))
+			signUp, err := sm.SignUp(t.Context(), core.SignUpInput{Email: "alice@example.com", Password: "SecurePass123!"}, "", "")
+			if err != nil {
+				t.Fatalf("SignUp() error = %v", err)
+			}
+
+			// Act
+			if err := test.revoke(sm, signUp); err != nil {
+				t.Fatalf("revoke error = %v", err)
+			}
+			time.Sleep(test.wait)
+			session, err := sm.Verify(t.Context(), signUp.Token)
+
+			// Assert
+			if !errors.Is(err, test.wantErr) {
+				t.Fatalf("Verify() error = %v, want %v", err, test.wantErr)
+			}
+			if err == nil && session.Draining() != test.wantDraining {
+				t.Errorf("Draining() = %v, want %v", session.Draining(), test.wantDraining)
+			}
+			if _, err := sm.Refresh(t.Context(), signUp.RefreshToken); !errors.Is(err, core.ErrInvalidToken) {
+				t.Errorf("Refresh() error = %v, want refresh tokens revoked at once", err)
+			}
+		})
+	}
+}
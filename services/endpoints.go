@@ -2,6 +2,7 @@ package services
 
 import (
 	"fmt"
+	"strings"
 
 	"github.com/lborres/kuta/core"
 )
@@ -11,11 +12,11 @@ import (
 //
 // Each endpoint is a template:
 // - Path and Method are set
-// - Handler is nil (provided by adapters)
+// - Handler is nil (adapters mount BaseHandlers)
 // - Metadata contains OpenAPI information
 //
-// This allows multiple adapters (Fiber, Gin, Echo) to share the same
-// endpoint definitions while providing their own framework-specific handlers.
+// This allows multiple adapters (net/http, Fiber, Gin) to share the same
+// endpoint definitions and handlers.
 func BaseEndpoints() []core.Endpoint {
 	return []core.Endpoint{
 		{
@@ -23,8 +24,14 @@ func BaseEndpoints() []core.Endpoint {
 			Method:  "POST",
 			Handler: nil,
 			Metadata: core.EndpointMetadata{
-				OperationID: "signUpWithEmailAndPassword",
+				OperationID: core.OperationSignUp,
 				Description: "Sign up a user using email and password",
+				RequestBody: core.SignUpRequest{},
+				Responses: map[int]interface{}{
+					201: core.SignUpResult{},
+					400: core.ErrorResponse{},
+					409: core.ErrorResponse{},
+				},
 			},
 		},
 		{
@@ -32,8 +39,29 @@ func BaseEndpoints() []core.Endpoint {
 			Method:  "POST",
 			Handler: nil,
 			Metadata: core.EndpointMetadata{
-				OperationID: "signInWithEmailAndPassword",
+				OperationID: core.OperationSignIn,
 				Description: "Sign in a user using email and password",
+				RequestBody: core.SignInRequest{},
+				Responses: map[int]interface{}{
+					200: core.SignInResult{},
+					400: core.ErrorResponse{},
+					401: core.ErrorResponse{},
+				},
+			},
+		},
+		{
+			Path:    "/sign-in/continue",
+			Method:  "POST",
+			Handler: nil,
+			Metadata: core.EndpointMetadata{
+				OperationID: core.OperationContinueSignIn,
+				Description: "Answer a sign-in challenge to get the next one or the session",
+				RequestBody: core.ContinueSignInRequest{},
+				Responses: map[int]interface{}{
+					200: core.SignInResult{},
+					400: core.ErrorResponse{},
+					401: core.ErrorResponse{},
+				},
 			},
 		},
 		{
@@ -41,8 +69,12 @@ func BaseEndpoints() []core.Endpoint {
 			Method:  "POST",
 			Handler: nil,
 			Metadata: core.EndpointMetadata{
-				OperationID: "signOut",
+				OperationID: core.OperationSignOut,
 				Description: "Sign out the current user and invalidate the session",
+				Responses: map[int]interface{}{
+					200: core.MessageResponse{},
+					401: core.ErrorResponse{},
+				},
 			},
 		},
 		{
@@ -50,8 +82,12 @@ func BaseEndpoints() []core.Endpoint {
 			Method:  "GET",
 			Handler: nil,
 			Metadata: core.EndpointMetadata{
-				OperationID: "getSession",
+				OperationID: core.OperationGetSession,
 				Description: "Get the current user's session data",
+				Responses: map[int]interface{}{
+					200: core.SessionData{},
+					401: core.ErrorResponse{},
+				},
 			},
 		},
 		{
@@ -59,8 +95,306 @@ func BaseEndpoints() []core.Endpoint {
 			Method:  "POST",
 			Handler: nil,
 			Metadata: core.EndpointMetadata{
-				OperationID: "refreshToken",
+				OperationID: core.OperationRefreshToken,
 				Description: "Refresh an expired or expiring authentication token",
+				RequestBody: core.RefreshRequest{},
+				Responses: map[int]interface{}{
+					200: core.RefreshResult{},
+					400: core.ErrorResponse{},
+					401: core.ErrorResponse{},
+				},
+			},
+		},
+	}
+}
+
+// AdminEndpoints returns framework-agnostic endpoint specifications for the
+// admin API. They are opt-in: adapters only mount them when the auth provider
+// implements core.AdminProvider with admin enabled.
+func AdminEndpoints() []core.Endpoint {
+	return []core.Endpoint{
+		{
+			Path:    "/admin/sessions",
+			Method:  "GET",
+			Handler: nil,
+			Metadata: core.EndpointMetadata{
+				OperationID: core.OperationAdminListSessions,
+				Description: "List and search sessions across all users",
+			},
+		},
+		{
+			Path:    "/admin/sessions/revoke",
+			Method:  "POST",
+			Handler: nil,
+			Metadata: core.EndpointMetadata{
+				OperationID: core.OperationAdminRevokeSessions,
+				Description: "Revoke multiple sessions by ID",
+			},
+		},
+	}
+}
+
+// DiscoveryEndpoints returns framework-agnostic endpoint specifications for
+// provider discovery. Adapters mount them when the auth provider implements
+// core.ProviderDiscovery.
+func DiscoveryEndpoints() []core.Endpoint {
+	return []core.Endpoint{
+		{
+			Path:    "/providers",
+			Method:  "GET",
+			Handler: nil,
+			Metadata: core.EndpointMetadata{
+				OperationID: core.OperationListProviders,
+				Description: "List the enabled sign-in methods with display metadata",
+				Responses: map[int]interface{}{
+					200: core.ProvidersResponse{},
+				},
+			},
+		},
+	}
+}
+
+// ActivityEndpoints returns framework-agnostic endpoint specifications for
+// the signed-in user's recent activity. Adapters mount them when the auth
+// provider implements core.ActivityProvider with activity enabled.
+func ActivityEndpoints() []core.Endpoint {
+	return []core.Endpoint{
+		{
+			Path:    "/me/activity",
+			Method:  "GET",
+			Handler: nil,
+			Metadata: core.EndpointMetadata{
+				OperationID: core.OperationListActivity,
+				Description: "List the current user's recent sign-ins (limit, offset)",
+				Responses: map[int]interface{}{
+					200: core.ActivityPage{},
+					400: core.ErrorResponse{},
+					401: core.ErrorResponse{},
+				},
+			},
+		},
+	}
+}
+
+// OrganizationEndpoints returns framework-agnostic endpoint specifications
+// for switching the active organization of the current session and
+// accepting organization invites. Adapters mount them when the auth provider
+// implements core.OrganizationProvider with organizations enabled.
+func OrganizationEndpoints() []core.Endpoint {
+	return []core.Endpoint{
+		{
+			Path:    "/organizations/switch",
+			Method:  "POST",
+			Handler: nil,
+			Metadata: core.EndpointMetadata{
+				OperationID: core.OperationSwitchOrganization,
+				Description: "Switch the current session's active organization",
+				RequestBody: core.SwitchOrganizationRequest{},
+				Responses: map[int]interface{}{
+					200: core.SessionData{},
+					400: core.ErrorResponse{},
+					401: core.ErrorResponse{},
+					403: core.ErrorResponse{},
+				},
+			},
+		},
+		{
+			Path:    "/organizations/invites/accept",
+			Method:  "POST",
+			Handler: nil,
+			Metadata: core.EndpointMetadata{
+				OperationID: core.OperationAcceptOrganizationInvite,
+				Description: "Accept an organization invite, creating the account if needed",
+				RequestBody: core.AcceptOrganizationInviteRequest{},
+				Responses: map[int]interface{}{
+					200: core.AcceptOrganizationInviteResult{},
+					400: core.ErrorResponse{},
+					429: core.ErrorResponse{},
+				},
+			},
+		},
+	}
+}
+
+// AccessTokenEndpoints returns framework-agnostic endpoint specifications
+// for managing the signed-in user's personal access tokens. Adapters mount
+// them when the auth provider implements core.AccessTokenProvider with
+// access tokens enabled.
+func AccessTokenEndpoints() []core.Endpoint {
+	return []core.Endpoint{
+		{
+			Path:    "/me/tokens",
+			Method:  "GET",
+			Handler: nil,
+			Metadata: core.EndpointMetadata{
+				OperationID: core.OperationListAccessTokens,
+				Description: "List the current user's personal access tokens",
+				Responses: map[int]interface{}{
+					200: []core.AccessToken{},
+					401: core.ErrorResponse{},
+				},
+			},
+		},
+		{
+			Path:    "/me/tokens",
+			Method:  "POST",
+			Handler: nil,
+			Metadata: core.EndpointMetadata{
+				OperationID: core.OperationCreateAccessToken,
+				Description: "Create a personal access token; its value is only returned here",
+				RequestBody: core.CreateAccessTokenRequest{},
+				Responses: map[int]interface{}{
+					201: core.CreateAccessTokenResult{},
+					400: core.ErrorResponse{},
+					401: core.ErrorResponse{},
+				},
+			},
+		},
+		{
+			Path:    "/me/tokens/:id",
+			Method:  "DELETE",
+			Handler: nil,
+			Metadata: core.EndpointMetadata{
+				OperationID: core.OperationRevokeAccessToken,
+				Description: "Revoke one of the current user's personal access tokens",
+				Responses: map[int]interface{}{
+					200: core.MessageResponse{},
+					401: core.ErrorResponse{},
+					404: core.ErrorResponse{},
+				},
+			},
+		},
+	}
+}
+
+// PasswordEndpoints returns framework-agnostic endpoint specifications for
+// changing the signed-in user's password. Adapters mount them when the auth
+// provider implements core.PasswordChanger.
+func PasswordEndpoints() []core.Endpoint {
+	return []core.Endpoint{
+		{
+			Path:    "/change-password",
+			Method:  "POST",
+			Handler: nil,
+			Metadata: core.EndpointMetadata{
+				OperationID: core.OperationChangePassword,
+				Description: "Change the current user's password, signing out their other sessions",
+				RequestBody: core.ChangePasswordRequest{},
+				Responses: map[int]interface{}{
+					200: core.MessageResponse{},
+					400: core.ErrorResponse{},
+					401: core.ErrorResponse{},
+					403: core.ErrorResponse{},
+				},
+			},
+		},
+	}
+}
+
+// AccountEndpoints returns framework-agnostic endpoint specifications for
+// the signed-in user's accounts. Adapters mount them when the auth provider
+// implements core.AccountLinker.
+func AccountEndpoints() []core.Endpoint {
+	return []core.Endpoint{
+		{
+			Path:    "/accounts",
+			Method:  "GET",
+			Handler: nil,
+			Metadata: core.EndpointMetadata{
+				OperationID: core.OperationListAccounts,
+				Description: "List the accounts the current user signs in with",
+				Responses: map[int]interface{}{
+					200: []core.Account{},
+					401: core.ErrorResponse{},
+				},
+			},
+		},
+		{
+			Path:    "/accounts/:id/unlink",
+			Method:  "POST",
+			Handler: nil,
+			Metadata: core.EndpointMetadata{
+				OperationID: core.OperationUnlinkAccount,
+				Description: "Unlink one of the current user's accounts, unless it is the last one",
+				Responses: map[int]interface{}{
+					200: core.MessageResponse{},
+					401: core.ErrorResponse{},
+					404: core.ErrorResponse{},
+					409: core.ErrorResponse{},
+				},
+			},
+		},
+	}
+}
+
+// AccountLinkEndpoints returns framework-agnostic endpoint specifications
+// for linking OAuth providers to the signed-in user. Adapters mount them
+// when the auth provider implements core.AccountLinker and OAuth sign-in is
+// enabled. The provider redirects back to the OAuth callback endpoint.
+func AccountLinkEndpoints() []core.Endpoint {
+	return []core.Endpoint{
+		{
+			Path:    "/link/:provider",
+			Method:  "POST",
+			Handler: nil,
+			Metadata: core.EndpointMetadata{
+				OperationID: core.OperationLinkOAuthAccount,
+				Description: "Start linking an OAuth provider to the current user; send the user to the returned URL",
+				Responses: map[int]interface{}{
+					200: core.OAuthStart{},
+					401: core.ErrorResponse{},
+					404: core.ErrorResponse{},
+				},
+			},
+		},
+	}
+}
+
+// OAuthEndpoints returns framework-agnostic endpoint specifications for
+// OAuth sign-in. Adapters mount them when the auth provider implements
+// core.OAuthSignIn with OAuth enabled. The callback accepts POST for
+// providers using the form_post response mode, like Apple.
+func OAuthEndpoints() []core.Endpoint {
+	return []core.Endpoint{
+		{
+			Path:    "/sign-in/:provider",
+			Method:  "GET",
+			Handler: nil,
+			Metadata: core.EndpointMetadata{
+				OperationID: core.OperationOAuthSignIn,
+				Description: "Redirect to an OAuth provider to sign in",
+				Responses: map[int]interface{}{
+					302: nil,
+					404: core.ErrorResponse{},
+				},
+			},
+		},
+		{
+			Path:    "/callback/:provider",
+			Method:  "GET",
+			Handler: nil,
+			Metadata: core.EndpointMetadata{
+				OperationID: core.OperationOAuthCallback,
+				Description: "Complete sign-in with an OAuth provider",
+				Responses: map[int]interface{}{
+					200: core.SignInResult{},
+					400: core.ErrorResponse{},
+					401: core.ErrorResponse{},
+				},
+			},
+		},
+		{
+			Path:    "/callback/:provider",
+			Method:  "POST",
+			Handler: nil,
+			Metadata: core.EndpointMetadata{
+				OperationID: core.OperationOAuthCallbackPost,
+				Description: "Complete sign-in with an OAuth provider using the form_post response mode",
+				Responses: map[int]interface{}{
+					200: core.SignInResult{},
+					400: core.ErrorResponse{},
+					401: core.ErrorResponse{},
+				},
 			},
 		},
 	}
@@ -74,6 +408,9 @@ func BaseEndpoints() []core.Endpoint {
 type EndpointRegistry struct {
 	// endpoints stores all registered endpoints keyed by "METHOD:PATH"
 	endpoints map[string]*core.Endpoint
+
+	// groups are the prefixes endpoints are mounted under, in insertion order
+	groups []core.RouteGroup
 }
 
 // NewEndpointRegistry creates a new registry with all base authentication endpoints
@@ -151,3 +488,183 @@ func (r *EndpointRegistry) Endpoints() []*core.Endpoint {
 	}
 	return result
 }
+
+// AddGroup adds a route group that endpoints are mounted under.
+// Returns error if the prefix is malformed or already used by another group.
+func (r *EndpointRegistry) AddGroup(group core.RouteGroup) error {
+	if !strings.HasPrefix(group.Prefix, "/") {
+		return fmt.Errorf("route group prefix %q must start with /", group.Prefix)
+	}
+
+	for _, existing := range r.groups {
+		if existing.Prefix == group.Prefix {
+			return fmt.Errorf("route group conflict: prefix %s already registered", group.Prefix)
+		}
+	}
+
+	r.groups = append(r.groups, group)
+	return nil
+}
+
+// Groups returns the registered route groups in the order they were added
+func (r *EndpointRegistry) Groups() []core.RouteGroup {
+	return r.groups
+}
+
+// GroupEndpoints returns the registered endpoints that belong in group.
+// Returns error if group includes an OperationID that isn't registered.
+func (r *EndpointRegistry) GroupEndpoints(group core.RouteGroup) ([]*core.Endpoint, error) {
+	if len(group.Include) == 0 {
+		return r.Endpoints(), nil
+	}
+
+	byOperation := make(map[string]*core.Endpoint, len(r.endpoints))
+	for _, ep := range r.endpoints {
+		byOperation[ep.Metadata.OperationID] = ep
+	}
+
+	result := make([]*core.Endpoint, 0, len(group.Include))
+	for _, id := range group.Include {
+		ep, ok := byOperation[id]
+		if !ok {
+			return nil, fmt.Errorf("route group %s includes unknown operation %q", group.Prefix, id)
+		}
+		result = append(result, ep)
+	}
+
+	return result, nil
+}
+
+// TwoFactorEndpoints returns framework-agnostic endpoint specifications for
+// enrolling an authenticator app. Adapters mount them, with TwoFactorHandlers,
+// when the auth provider implements core.TwoFactorProvider with TOTP enabled.
+func TwoFactorEndpoints() []core.Endpoint {
+	return []core.Endpoint{
+		{
+			Path:    "/2fa/totp/setup",
+			Method:  "POST",
+			Handler: nil,
+			Metadata: core.EndpointMetadata{
+				OperationID: core.OperationSetupTOTP,
+				Description: "Start enrolling an authenticator app; returns its otpauth URI and backup codes",
+				Responses: map[int]interface{}{
+					200: core.TOTPSetup{},
+					401: core.ErrorResponse{},
+					409: core.ErrorResponse{},
+				},
+			},
+		},
+		{
+			Path:    "/2fa/totp/verify",
+			Method:  "POST",
+			Handler: nil,
+			Metadata: core.EndpointMetadata{
+				OperationID: core.OperationVerifyTOTP,
+				Description: "Turn on two-factor authentication with a code from the enrolled app",
+				RequestBody: core.VerifyTOTPRequest{},
+				Responses: map[int]interface{}{
+					200: core.MessageResponse{},
+					400: core.ErrorResponse{},
+					401: core.ErrorResponse{},
+					404: core.ErrorResponse{},
+				},
+			},
+		},
+	}
+}
+
+// AccountEmailEndpoints returns framework-agnostic endpoint specifications
+// for resetting a forgotten password and verifying an email address.
+// Adapters mount them, with AccountEmailHandlers, when the auth provider
+// implements core.AccountEmailProvider with account emails enabled. Under
+// enumeration protection the email requests answer 200 for every address.
+func AccountEmailEndpoints() []core.Endpoint {
+	return []core.Endpoint{
+		{
+			Path:    "/forgot-password",
+			Method:  "POST",
+			Handler: nil,
+			Metadata: core.EndpointMetadata{
+				OperationID: core.OperationForgotPassword,
+				Description: "Email a password reset link to the user registered with the email",
+				RequestBody: core.EmailRequest{},
+				Responses: map[int]interface{}{
+					200: core.MessageResponse{},
+					400: core.ErrorResponse{},
+					404: core.ErrorResponse{},
+					429: core.ErrorResponse{},
+				},
+			},
+		},
+		{
+			Path:    "/reset-password",
+			Method:  "POST",
+			Handler: nil,
+			Metadata: core.EndpointMetadata{
+				OperationID: core.OperationResetPassword,
+				Description: "Set a new password with a password reset token, signing out every session",
+				RequestBody: core.ResetPasswordRequest{},
+				Responses: map[int]interface{}{
+					200: core.MessageResponse{},
+					400: core.ErrorResponse{},
+					404: core.ErrorResponse{},
+					429: core.ErrorResponse{},
+				},
+			},
+		},
+		{
+			Path:    "/resend-verification",
+			Method:  "POST",
+			Handler: nil,
+			Metadata: core.EndpointMetadata{
+				OperationID: core.OperationResendVerification,
+				Description: "Email a verification link to the user registered with the email",
+				RequestBody: core.EmailRequest{},
+				Responses: map[int]interface{}{
+					200: core.MessageResponse{},
+					400: core.ErrorResponse{},
+					404: core.ErrorResponse{},
+					409: core.ErrorResponse{},
+					429: core.ErrorResponse{},
+				},
+			},
+		},
+		{
+			Path:    "/verify-email",
+			Method:  "POST",
+			Handler: nil,
+			Metadata: core.EndpointMetadata{
+				OperationID: core.OperationVerifyEmail,
+				Description: "Mark the user's email verified with a verification token",
+				RequestBody: core.VerifyEmailRequest{},
+				Responses: map[int]interface{}{
+					200: core.MessageResponse{},
+					400: core.ErrorResponse{},
+					429: core.ErrorResponse{},
+				},
+			},
+		},
+	}
+}
+
+// AnonymousEndpoints returns framework-agnostic endpoint specifications for
+// guest sessions. Adapters mount them, with AnonymousHandlers, when the auth
+// provider implements core.AnonymousSessionProvider with guest sessions
+// enabled. Signing up with the guest session's token upgrades it.
+func AnonymousEndpoints() []core.Endpoint {
+	return []core.Endpoint{
+		{
+			Path:    "/sign-in/anonymous",
+			Method:  "POST",
+			Handler: nil,
+			Metadata: core.EndpointMetadata{
+				OperationID: core.OperationSignInAnonymous,
+				Description: "Start a guest session for an anonymous user, upgraded to an account on sign-up",
+				Responses: map[int]interface{}{
+					201: core.SignUpResult{},
+					429: core.ErrorResponse{},
+				},
+			},
+		},
+	}
+}
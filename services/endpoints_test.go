@@ -1,6 +1,7 @@
 package services
 
 import (
+	"reflect"
 	"testing"
 
 	"github.com/lborres/kuta/core"
@@ -33,6 +34,14 @@ func TestBaseEndpoints(t *testing.T) {
 			wantDesc:       "Sign in a user using email and password",
 			wantHandlerNil: true,
 		},
+		{
+			name:           "returns sign-in continue endpoint with correct path and method",
+			wantPath:       "/sign-in/continue",
+			wantMethod:     "POST",
+			wantOpID:       "continueSignIn",
+			wantDesc:       "Answer a sign-in challenge to get the next one or the session",
+			wantHandlerNil: true,
+		},
 		{
 			name:           "returns sign-out endpoint with correct path and method",
 			wantPath:       "/sign-out",
@@ -116,6 +125,49 @@ func TestBaseEndpoints(t *testing.T) {
 	}
 }
 
+// Requirement: Base endpoints describe their request and response bodies with concrete types.
+func TestBaseEndpoints_Schemas(t *testing.T) {
+	tests := []struct {
+		path          string
+		wantRequest   interface{}
+		wantSuccess   int
+		wantResponse  interface{}
+		wantErrorCode int
+	}{
+		{path: "/sign-up", wantRequest: core.SignUpRequest{}, wantSuccess: 201, wantResponse: core.SignUpResult{}, wantErrorCode: 409},
+		{path: "/sign-in", wantRequest: core.SignInRequest{}, wantSuccess: 200, wantResponse: core.SignInResult{}, wantErrorCode: 401},
+		{path: "/sign-in/continue", wantRequest: core.ContinueSignInRequest{}, wantSuccess: 200, wantResponse: core.SignInResult{}, wantErrorCode: 401},
+		{path: "/sign-out", wantSuccess: 200, wantResponse: core.MessageResponse{}, wantErrorCode: 401},
+		{path: "/session", wantSuccess: 200, wantResponse: core.SessionData{}, wantErrorCode: 401},
+		{path: "/refresh", wantRequest: core.RefreshRequest{}, wantSuccess: 200, wantResponse: core.RefreshResult{}, wantErrorCode: 401},
+	}
+
+	// Arrange
+	byPath := make(map[string]core.Endpoint)
+	for _, ep := range BaseEndpoints() {
+		byPath[ep.Path] = ep
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.path, func(t *testing.T) {
+			// Act
+			meta := byPath[test.path].Metadata
+
+			// Assert
+			if reflect.TypeOf(meta.RequestBody) != reflect.TypeOf(test.wantRequest) {
+				t.Errorf("RequestBody = %T, want %T", meta.RequestBody, test.wantRequest)
+			}
+			if reflect.TypeOf(meta.Responses[test.wantSuccess]) != reflect.TypeOf(test.wantResponse) {
+				t.Errorf("Responses[%d] = %T, want %T", test.wantSuccess, meta.Responses[test.wantSuccess], test.wantResponse)
+			}
+			if _, ok := meta.Responses[test.wantErrorCode].(core.ErrorResponse); !ok {
+				t.Errorf("Responses[%d] = %T, want core.ErrorResponse", test.wantErrorCode, meta.Responses[test.wantErrorCode])
+			}
+		})
+	}
+}
+
 // Requirement: All endpoints must have unique OperationIDs.
 func TestBaseEndpoints_OperationIDsAreUnique(t *testing.T) {
 	// Arrange
@@ -155,16 +207,17 @@ func TestEndpointRegistry_RegistersBaseEndpoints(t *testing.T) {
 	// Assert
 	endpoints := registry.Endpoints()
 
-	if len(endpoints) != 5 {
-		t.Fatalf("EndpointRegistry should register 5 base endpoints; got %d", len(endpoints))
+	if len(endpoints) != 6 {
+		t.Fatalf("EndpointRegistry should register 6 base endpoints; got %d", len(endpoints))
 	}
 
 	expectedPaths := map[string]bool{
-		"/sign-up":  true,
-		"/sign-in":  true,
-		"/sign-out": true,
-		"/session":  true,
-		"/refresh":  true,
+		"/sign-up":          true,
+		"/sign-in":          true,
+		"/sign-in/continue": true,
+		"/sign-out":         true,
+		"/session":          true,
+		"/refresh":          true,
 	}
 
 	for _, ep := range endpoints {
@@ -258,7 +311,7 @@ func TestEndpointRegistry_RegistersPluginEndpoints(t *testing.T) {
 			}{
 				{Path: "/verify-email", OpID: "verifyEmail"},
 			},
-			wantTotalCount: 6,
+			wantTotalCount: 7,
 			wantErr:        false,
 		},
 		{
@@ -271,7 +324,7 @@ func TestEndpointRegistry_RegistersPluginEndpoints(t *testing.T) {
 				{Path: "/change-password", OpID: "changePassword"},
 				{Path: "/reset-password", OpID: "resetPassword"},
 			},
-			wantTotalCount: 8,
+			wantTotalCount: 9,
 			wantErr:        false,
 		},
 		{
@@ -283,7 +336,7 @@ func TestEndpointRegistry_RegistersPluginEndpoints(t *testing.T) {
 				{Path: "/verify-email", OpID: "verifyEmail"},
 				{Path: "/verify-email", OpID: "verifyEmailDuplicate"}, // duplicate path
 			},
-			wantTotalCount: 5, // unchanged, registration failed
+			wantTotalCount: 6, // unchanged, registration failed
 			wantErr:        true,
 		},
 	}
@@ -354,3 +407,73 @@ func makePluginEndpoints(data []struct {
 	}
 	return result
 }
+
+// Requirement: Route groups need distinct prefixes starting with "/".
+func TestEndpointRegistry_AddGroup(t *testing.T) {
+	tests := []struct {
+		name    string
+		groups  []core.RouteGroup
+		wantErr bool
+	}{
+		{name: "accepts distinct prefixes", groups: []core.RouteGroup{{Prefix: "/api/auth"}, {Prefix: "/internal/auth"}}},
+		{name: "rejects prefix without leading slash", groups: []core.RouteGroup{{Prefix: "api/auth"}}, wantErr: true},
+		{name: "rejects duplicate prefix", groups: []core.RouteGroup{{Prefix: "/api/auth"}, {Prefix: "/api/auth"}}, wantErr: true},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			// Arrange
+			registry := NewEndpointRegistry()
+
+			// Act
+			var err error
+			for _, group := range test.groups {
+				if err = registry.AddGroup(group); err != nil {
+					break
+				}
+			}
+
+			// Assert
+			if (err != nil) != test.wantErr {
+				t.Errorf("AddGroup() error = %v, wantErr %v", err, test.wantErr)
+			}
+			if !test.wantErr && len(registry.Groups()) != len(test.groups) {
+				t.Errorf("Groups() returned %d groups, want %d", len(registry.Groups()), len(test.groups))
+			}
+		})
+	}
+}
+
+// Requirement: A route group holds all endpoints unless it lists OperationIDs to include.
+func TestEndpointRegistry_GroupEndpoints(t *testing.T) {
+	tests := []struct {
+		name      string
+		include   []string
+		wantCount int
+		wantErr   bool
+	}{
+		{name: "all endpoints by default", wantCount: len(BaseEndpoints())},
+		{name: "only included operations", include: []string{"getSession", "refreshToken"}, wantCount: 2},
+		{name: "unknown operation", include: []string{"doesNotExist"}, wantErr: true},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			// Arrange
+			registry := NewEndpointRegistry()
+
+			// Act
+			endpoints, err := registry.GroupEndpoints(core.RouteGroup{Prefix: "/auth", Include: test.include})
+
+			// Assert
+			if (err != nil) != test.wantErr {
+				t.Fatalf("GroupEndpoints() error = %v, wantErr %v", err, test.wantErr)
+			}
+			if len(endpoints) != test.wantCount {
+				t.Errorf("GroupEndpoints() returned %d endpoints, want %d", len(endpoints), test.wantCount)
+			}
+		})
+	}
+}
@@ -0,0 +1,63 @@
+package services
+
+import (
+	"context"
+	"errors"
+
+	"github.com/lborres/kuta/core"
+)
+
+// resolveEntitlements sets session.Entitlements with the configured
+// resolver, looking user up when nil. It does nothing without a resolver.
+func (sm *SessionManager) resolveEntitlements(ctx context.Context, session *core.Session, user *core.User) error {
+	if sm.entitlements == nil {
+		return nil
+	}
+	if user == nil {
+		var err error
+		if user, err = sm.storage.GetUserByID(ctx, session.UserID); err != nil {
+			return err
+		}
+	}
+
+	entitlements, err := sm.entitlements.ResolveEntitlements(user, session)
+	if err != nil {
+		return err
+	}
+	session.Entitlements = entitlements
+	return nil
+}
+
+// RefreshEntitlements resolves the entitlements of the user's sessions
+// again, e.g. from a billing webhook after a plan change. Stateless sessions
+// keep theirs until they are refreshed.
+func (sm *SessionManager) RefreshEntitlements(ctx context.Context, userID string) error {
+	if sm.entitlements == nil {
+		return core.ErrNotImplemented
+	}
+
+	user, err := sm.storage.GetUserByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	sessions, err := sm.storage.GetUserSessions(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	for _, session := range sessions {
+		// Update a copy, not the one the cache may be handing out
+		updated := *session
+		if err := sm.resolveEntitlements(ctx, &updated, user); err != nil {
+			return err
+		}
+		if err := sm.storage.UpdateSession(ctx, &updated); err != nil {
+			if errors.Is(err, core.ErrSessionNotFound) {
+				continue
+			}
+			return err
+		}
+		sm.recacheSession(ctx, session.ID)
+	}
+	return nil
+}
@@ -0,0 +1,140 @@
+package services
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/lborres/kuta/core"
+	"github.com/lborres/kuta/pkg/cache"
+	"github.com/lborres/kuta/pkg/crypto"
+)
+
+// planResolver resolves every user to plans[userID], enabling "export" on
+// "pro", and fails for users without a plan
+type planResolver map[string]string
+
+func (p planResolver) ResolveEntitlements(user *core.User, session *core.Session) (*core.Entitlements, error) {
+	plan, ok := p[user.ID]
+	if !ok {
+		return nil, errors.New("billing unavailable")
+	}
+	entitlements := &core.Entitlements{Plan: plan}
+	if plan == "pro" {
+		entitlements.Features = []string{"export"}
+	}
+	return entitlements, nil
+}
+
+// Requirement: Sessions carry the entitlements resolved when they were
+// issued, reported in SessionData; a failing resolver refuses the session,
+// and managers without a resolver issue sessions without entitlements.
+func TestSessionManager_Entitlements(t *testing.T) {
+	tests := []struct {
+		name       string
+		resolver   core.EntitlementResolver
+		userID     string
+		wantErr    bool
+		wantPlan   string
+		wantExport bool
+	}{
+		{name: "pro plan", resolver: planResolver{"user-1": "pro"}, userID: "user-1", wantPlan: "pro", wantExport: true},
+		{name: "free plan", resolver: planResolver{"user-1": "free"}, userID: "user-1", wantPlan: "free"},
+		{name: "resolver fails", resolver: planResolver{}, userID: "user-1", wantErr: true},
+		{name: "no resolver", userID: "user-1"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// Arrange
+			storage := NewFakeStorageProvider()
+			_ = storage.CreateUser(t.Context(), &core.User{ID: "user-1", Email: "a@example.com"})
+			manager := NewSessionManager(core.SessionConfig{MaxAge: time.Hour}, storage,
+				cache.NewInMemoryCache(core.CacheConfig{}), nil, WithEntitlements(test.resolver))
+
+			// Act
+			created, err := manager.Create(t.Context(), test.userID, "127.0.0.1", "test-agent")
+
+			// Assert
+			if (err != nil) != test.wantErr {
+				t.Fatalf("Create() error = %v, want error %v", err, test.wantErr)
+			}
+			if test.wantErr {
+				return
+			}
+			data, err := manager.GetSession(t.Context(), created.Token)
+			if err != nil {
+				t.Fatalf("GetSession() error = %v", err)
+			}
+			if test.wantPlan == "" {
+				if data.Entitlements != nil {
+					t.Errorf("Entitlements = %+v, want none", data.Entitlements)
+				}
+				return
+			}
+			if data.Entitlements.Plan != test.wantPlan || data.Entitlements.HasFeature("export") != test.wantExport {
+				t.Errorf("Entitlements = %+v, want plan %s (export %v)", data.Entitlements, test.wantPlan, test.wantExport)
+			}
+		})
+	}
+}
+
+// Requirement: Refreshing a session and RefreshEntitlements both pick up a
+// plan change, the latter for sessions already issued.
+func TestSessionManager_Entitlements_PlanChange(t *testing.T) {
+	// Arrange
+	plans := planResolver{"user-1": "free"}
+	storage := NewFakeStorageProvider()
+	manager := NewSessionManager(core.SessionConfig{MaxAge: time.Hour}, storage,
+		cache.NewInMemoryCache(core.CacheConfig{}), nil, WithEntitlements(plans))
+	created, refreshToken := createRefreshableSession(t, manager, storage, "user-1", "")
+	other, err := manager.Create(t.Context(), "user-1", "127.0.0.1", "test-agent")
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	plans["user-1"] = "pro"
+
+	// Act
+	refreshed, refreshErr := manager.Refresh(t.Context(), refreshToken)
+	stale, _ := manager.GetSession(t.Context(), other.Token)
+	updateErr := manager.RefreshEntitlements(t.Context(), "user-1")
+	updated, _ := manager.GetSession(t.Context(), other.Token)
+
+	// Assert
+	if refreshErr != nil || updateErr != nil {
+		t.Fatalf("errors = %v, %v", refreshErr, updateErr)
+	}
+	if created.Session.Entitlements.Plan != "free" || refreshed.Session.Entitlements.Plan != "pro" {
+		t.Errorf("plan = %s before and %s after refresh, want free then pro", created.Session.Entitlements.Plan, refreshed.Session.Entitlements.Plan)
+	}
+	if stale.Entitlements.Plan != "free" || !updated.Entitlements.HasFeature("export") {
+		t.Errorf("plan = %s before and %+v after RefreshEntitlements, want free then pro", stale.Entitlements.Plan, updated.Entitlements)
+	}
+
+	disabled := newTestSessionManager(NewFakeStorageProvider(), nil)
+	if err := disabled.RefreshEntitlements(t.Context(), "user-1"); !errors.Is(err, core.ErrNotImplemented) {
+		t.Errorf("RefreshEntitlements() without resolver error = %v, want ErrNotImplemented", err)
+	}
+}
+
+// Requirement: Stateless sessions carry their entitlements inside the token.
+func TestSessionManager_Entitlements_Stateless(t *testing.T) {
+	// Arrange
+	storage := NewFakeStorageProvider()
+	_ = storage.CreateUser(t.Context(), &core.User{ID: "user-1", Email: "a@example.com"})
+	sealer, _ := crypto.NewSealer("this-is-a-test-secret-of-32-bytes!", StatelessSealerPurpose)
+	manager := NewSessionManager(core.SessionConfig{MaxAge: time.Hour}, storage, nil, nil,
+		WithStatelessSessions(sealer), WithEntitlements(planResolver{"user-1": "pro"}))
+	created, err := manager.Create(t.Context(), "user-1", "127.0.0.1", "test-agent")
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+
+	// Act
+	data, err := manager.GetSession(t.Context(), created.Token)
+
+	// Assert
+	if err != nil || data.Entitlements == nil || data.Entitlements.Plan != "pro" || !data.Entitlements.HasFeature("export") {
+		t.Errorf("GetSession() entitlements = %+v, %v; want the pro plan", data, err)
+	}
+}
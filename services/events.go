@@ -0,0 +1,46 @@
+package services
+
+import (
+	"time"
+
+	"github.com/lborres/kuta/core"
+)
+
+// emit delivers an event to the configured handler, if any.
+func (sm *SessionManager) emit(event core.Event) {
+	if sm.events == nil {
+		return
+	}
+	if event.OccurredAt.IsZero() {
+		event.OccurredAt = time.Now()
+	}
+	sm.events.HandleEvent(event)
+}
+
+func (sm *SessionManager) emitSignInFailed(email, userID, ipAddress, userAgent string) {
+	sm.emit(core.Event{
+		Type:      core.EventUserSignInFailed,
+		UserID:    userID,
+		Email:     email,
+		IPAddress: ipAddress,
+		UserAgent: userAgent,
+	})
+}
+
+func (sm *SessionManager) emitSessionRevoked(userID, sessionID, reason string) {
+	sm.emit(core.Event{
+		Type:      core.EventSessionRevoked,
+		UserID:    userID,
+		SessionID: sessionID,
+		Metadata:  map[string]any{"reason": reason},
+	})
+}
+
+// emitSessionsRevoked records a bulk revocation of a user's sessions
+func (sm *SessionManager) emitSessionsRevoked(userID string, count int, reason string) {
+	sm.emit(core.Event{
+		Type:     core.EventSessionRevoked,
+		UserID:   userID,
+		Metadata: map[string]any{"reason": reason, "count": count},
+	})
+}
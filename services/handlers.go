@@ -0,0 +1,455 @@
+package services
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/lborres/kuta/core"
+)
+
+// HandlerOptions configures the shared endpoint handlers. Adapters fill it in
+// from their own options.
+type HandlerOptions struct {
+	// CookieName is the cookie checked for the session token when no
+	// Authorization header is present
+	CookieName string
+
+	// SetCookie makes sign-up, sign-in and refresh set the session token as
+	// an HttpOnly cookie named CookieName, and sign-out clear it
+	SetCookie      bool
+	CookieDomain   string
+	CookieSameSite string // "Lax" (default), "Strict" or "None"
+	CookieInsecure bool
+
+	// TokenHeader, when set, carries the session token of sign-up, sign-in
+	// and refresh responses instead of the JSON body
+	TokenHeader string
+
+	// Envelope shapes response bodies. Defaults to core.BareEnvelope.
+	Envelope   core.ResponseEnvelope
+	Transforms core.PayloadTransforms
+
+	// Bind decodes the request body into out with the operation's decoder or
+	// the framework's own binding. Bodies over the adapter's size limit fail
+	// with core.ErrBodyTooLarge.
+	Bind func(ctx *core.RequestContext, operationID string, out any) error
+
+	// FormImage reads an image sent as a multipart form field. It returns
+	// nil when the request is not multipart or has no such field. Optional.
+	FormImage func(ctx *core.RequestContext, field string) (*core.ImageUpload, error)
+
+	// OAuthRedirectURL is where the browser is sent once an OAuth callback
+	// signed it in. It only applies with SetCookie, since the redirect
+	// can't carry the token otherwise.
+	OAuthRedirectURL string
+}
+
+// BaseHandlers returns the handlers of the BaseEndpoints, keyed by
+// OperationID. They only use RequestContext's Request, Response and Auth, so
+// every adapter mounts the same handlers; so do the other handler sets.
+func BaseHandlers(opts HandlerOptions) map[string]func(*core.RequestContext) error {
+	return map[string]func(*core.RequestContext) error{
+		core.OperationSignUp:         opts.handleSignUp,
+		core.OperationSignIn:         opts.handleSignIn,
+		core.OperationContinueSignIn: opts.handleContinueSignIn,
+		core.OperationSignOut:        opts.handleSignOut,
+		core.OperationGetSession:     opts.handleGetSession,
+		core.OperationRefreshToken:   opts.handleRefresh,
+	}
+}
+
+// TwoFactorHandlers returns the handlers of the TwoFactorEndpoints, keyed by
+// OperationID. twoFactor is used when the request's auth provider doesn't
+// implement core.TwoFactorProvider itself.
+func TwoFactorHandlers(opts HandlerOptions, twoFactor core.TwoFactorProvider) map[string]func(*core.RequestContext) error {
+	return map[string]func(*core.RequestContext) error{
+		core.OperationSetupTOTP: func(ctx *core.RequestContext) error {
+			session, err := opts.owner(ctx)
+			if err != nil {
+				return opts.authError(ctx, err)
+			}
+
+			setup, err := provider(ctx, twoFactor).SetupTOTP(ctx.Context, session.User.ID)
+			if err != nil {
+				return opts.authError(ctx, err)
+			}
+
+			return opts.respond(ctx, core.OperationSetupTOTP, http.StatusOK, setup)
+		},
+		core.OperationVerifyTOTP: func(ctx *core.RequestContext) error {
+			var req core.VerifyTOTPRequest
+			if err := opts.Bind(ctx, core.OperationVerifyTOTP, &req); err != nil {
+				return opts.bindError(ctx, err)
+			}
+
+			session, err := opts.owner(ctx)
+			if err != nil {
+				return opts.authError(ctx, err)
+			}
+
+			if err := provider(ctx, twoFactor).VerifyTOTP(ctx.Context, session.User.ID, req.Code); err != nil {
+				return opts.authError(ctx, err)
+			}
+
+			return opts.respond(ctx, core.OperationVerifyTOTP, http.StatusOK, core.MessageResponse{
+				Message: "two-factor authentication enabled",
+			})
+		},
+	}
+}
+
+// AccountEmailHandlers returns the handlers of the AccountEmailEndpoints,
+// keyed by OperationID. emails is used when the request's auth provider
+// doesn't implement core.AccountEmailProvider itself. The email requests
+// answer the same message whether or not the address is registered.
+func AccountEmailHandlers(opts HandlerOptions, emails core.AccountEmailProvider) map[string]func(*core.RequestContext) error {
+	return map[string]func(*core.RequestContext) error{
+		core.OperationForgotPassword: func(ctx *core.RequestContext) error {
+			var req core.EmailRequest
+			if err := opts.Bind(ctx, core.OperationForgotPassword, &req); err != nil {
+				return opts.bindError(ctx, err)
+			}
+
+			if err := provider(ctx, emails).ForgotPassword(ctx.Context, req.Email, ctx.Request.ClientIP()); err != nil {
+				return opts.authError(ctx, err)
+			}
+
+			return opts.respond(ctx, core.OperationForgotPassword, http.StatusOK, core.MessageResponse{
+				Message: "password reset email sent",
+			})
+		},
+		core.OperationResetPassword: func(ctx *core.RequestContext) error {
+			var req core.ResetPasswordRequest
+			if err := opts.Bind(ctx, core.OperationResetPassword, &req); err != nil {
+				return opts.bindError(ctx, err)
+			}
+
+			if err := provider(ctx, emails).ResetPassword(ctx.Context, req.Input(), ctx.Request.ClientIP()); err != nil {
+				return opts.authError(ctx, err)
+			}
+
+			return opts.respond(ctx, core.OperationResetPassword, http.StatusOK, core.MessageResponse{
+				Message: "password reset",
+			})
+		},
+		core.OperationResendVerification: func(ctx *core.RequestContext) error {
+			var req core.EmailRequest
+			if err := opts.Bind(ctx, core.OperationResendVerification, &req); err != nil {
+				return opts.bindError(ctx, err)
+			}
+
+			if err := provider(ctx, emails).ResendVerification(ctx.Context, req.Email, ctx.Request.ClientIP()); err != nil {
+				return opts.authError(ctx, err)
+			}
+
+			return opts.respond(ctx, core.OperationResendVerification, http.StatusOK, core.MessageResponse{
+				Message: "verification email sent",
+			})
+		},
+		core.OperationVerifyEmail: func(ctx *core.RequestContext) error {
+			var req core.VerifyEmailRequest
+			if err := opts.Bind(ctx, core.OperationVerifyEmail, &req); err != nil {
+				return opts.bindError(ctx, err)
+			}
+
+			if err := provider(ctx, emails).VerifyEmail(ctx.Context, req.Token, ctx.Request.ClientIP()); err != nil {
+				return opts.authError(ctx, err)
+			}
+
+			return opts.respond(ctx, core.OperationVerifyEmail, http.StatusOK, core.MessageResponse{
+				Message: "email verified",
+			})
+		},
+	}
+}
+
+// AnonymousHandlers returns the handlers of the AnonymousEndpoints, keyed by
+// OperationID. anonymous is used when the request's auth provider doesn't
+// implement core.AnonymousSessionProvider itself.
+func AnonymousHandlers(opts HandlerOptions, anonymous core.AnonymousSessionProvider) map[string]func(*core.RequestContext) error {
+	return map[string]func(*core.RequestContext) error{
+		core.OperationSignInAnonymous: func(ctx *core.RequestContext) error {
+			result, err := provider(ctx, anonymous).CreateAnonymous(ctx.Context, ctx.Request.ClientIP(), ctx.Request.UserAgent())
+			if err != nil {
+				return opts.authError(ctx, err)
+			}
+
+			opts.setSessionCookie(ctx, result.Token, result.Session.ExpiresAt)
+
+			return opts.respond(ctx, core.OperationSignInAnonymous, http.StatusCreated, result)
+		},
+	}
+}
+
+// provider returns the request's auth provider when it implements P, so
+// storage queries stop with the request, and fallback otherwise
+func provider[P any](ctx *core.RequestContext, fallback P) P {
+	if bound, ok := ctx.Auth.(P); ok {
+		return bound
+	}
+	return fallback
+}
+
+func (o HandlerOptions) handleSignUp(ctx *core.RequestContext) error {
+	var req core.SignUpRequest
+	if err := o.Bind(ctx, core.OperationSignUp, &req); err != nil {
+		return o.bindError(ctx, err)
+	}
+
+	if req.ImageUpload == nil && o.FormImage != nil {
+		upload, err := o.FormImage(ctx, "imageUpload")
+		if err != nil {
+			return o.fail(ctx, http.StatusBadRequest, "invalid image upload")
+		}
+		req.ImageUpload = upload
+	}
+
+	// A guest session signing up is upgraded into the account's session
+	input := req.Input()
+	input.AnonymousToken = o.token(ctx.Request)
+
+	result, err := ctx.Auth.SignUp(ctx.Context, input, ctx.Request.ClientIP(), ctx.Request.UserAgent())
+	if err != nil {
+		return o.authError(ctx, err)
+	}
+
+	if result.Session != nil {
+		o.setSessionCookie(ctx, result.Token, result.Session.ExpiresAt)
+	}
+
+	return o.respond(ctx, core.OperationSignUp, http.StatusCreated, result)
+}
+
+func (o HandlerOptions) handleSignIn(ctx *core.RequestContext) error {
+	var req core.SignInRequest
+	if err := o.Bind(ctx, core.OperationSignIn, &req); err != nil {
+		return o.bindError(ctx, err)
+	}
+
+	result, err := ctx.Auth.SignIn(ctx.Context, req.Input(), ctx.Request.ClientIP(), ctx.Request.UserAgent())
+	if err != nil {
+		return o.authError(ctx, err)
+	}
+
+	// A challenged sign-in has no session yet
+	if result.Session != nil {
+		o.setSessionCookie(ctx, result.Token, result.Session.ExpiresAt)
+	}
+
+	return o.respond(ctx, core.OperationSignIn, http.StatusOK, result)
+}
+
+func (o HandlerOptions) handleContinueSignIn(ctx *core.RequestContext) error {
+	var req core.ContinueSignInRequest
+	if err := o.Bind(ctx, core.OperationContinueSignIn, &req); err != nil {
+		return o.bindError(ctx, err)
+	}
+	if req.ChallengeToken == "" {
+		return o.fail(ctx, http.StatusUnauthorized, "missing challenge token")
+	}
+
+	result, err := ctx.Auth.ContinueSignIn(ctx.Context, req.Input())
+	if err != nil {
+		return o.authError(ctx, err)
+	}
+
+	if result.Session != nil {
+		o.setSessionCookie(ctx, result.Token, result.Session.ExpiresAt)
+	}
+
+	return o.respond(ctx, core.OperationContinueSignIn, http.StatusOK, result)
+}
+
+func (o HandlerOptions) handleSignOut(ctx *core.RequestContext) error {
+	token := o.token(ctx.Request)
+	if token == "" {
+		return o.fail(ctx, http.StatusUnauthorized, "missing token")
+	}
+
+	// A key-bound session is only signed out by the key's holder. Sessions
+	// that no longer verify have nothing left to protect.
+	if _, ok := ctx.Auth.(core.ProofVerifier); ok {
+		if session, err := ctx.Auth.GetSession(ctx.Context, token); err == nil {
+			if err := verifyRequestProof(ctx, session.Session); err != nil {
+				return o.authError(ctx, err)
+			}
+		}
+	}
+
+	if err := ctx.Auth.SignOut(ctx.Context, token); err != nil {
+		return o.authError(ctx, err)
+	}
+
+	o.clearSessionCookie(ctx)
+
+	return o.respond(ctx, core.OperationSignOut, http.StatusOK, core.MessageResponse{
+		Message: "signed out successfully",
+	})
+}
+
+func (o HandlerOptions) handleGetSession(ctx *core.RequestContext) error {
+	token := o.token(ctx.Request)
+	if token == "" {
+		return o.fail(ctx, http.StatusUnauthorized, "missing token")
+	}
+
+	session, err := ctx.Auth.GetSession(ctx.Context, token)
+	if err != nil {
+		return o.authError(ctx, err)
+	}
+
+	if err := verifyRequestProof(ctx, session.Session); err != nil {
+		return o.authError(ctx, err)
+	}
+
+	return o.respond(ctx, core.OperationGetSession, http.StatusOK, session)
+}
+
+func (o HandlerOptions) handleRefresh(ctx *core.RequestContext) error {
+	var req core.RefreshRequest
+	if err := o.Bind(ctx, core.OperationRefreshToken, &req); err != nil {
+		return o.bindError(ctx, err)
+	}
+	if req.RefreshToken == "" {
+		return o.fail(ctx, http.StatusUnauthorized, "missing refresh token")
+	}
+
+	result, err := ctx.Auth.Refresh(ctx.Context, req.RefreshToken)
+	if err != nil {
+		return o.authError(ctx, err)
+	}
+
+	o.setSessionCookie(ctx, result.Token, result.Session.ExpiresAt)
+
+	return o.respond(ctx, core.OperationRefreshToken, http.StatusOK, result)
+}
+
+// token returns the session token of the request: the Authorization header's
+// bearer token, or else the session cookie
+func (o HandlerOptions) token(r core.Request) string {
+	if token, ok := strings.CutPrefix(r.Header("Authorization"), "Bearer "); ok && token != "" {
+		return token
+	}
+	return r.Cookie(o.CookieName)
+}
+
+// owner authenticates the session changing the user's sign-in settings. It
+// must be a session, not an access token, and the user's own, not revoked
+// nor a guest's.
+func (o HandlerOptions) owner(ctx *core.RequestContext) (*core.SessionData, error) {
+	session, err := o.session(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if session.Session.Draining() {
+		return nil, core.ErrSessionDraining
+	}
+	if session.Impersonated() {
+		return nil, core.ErrImpersonating
+	}
+	if session.User != nil && session.User.Anonymous() {
+		return nil, core.ErrAnonymousUser
+	}
+	return session, nil
+}
+
+// verifyRequestProof enforces proof-of-possession for key-bound sessions
+// when the auth provider supports it
+func verifyRequestProof(ctx *core.RequestContext, session *core.Session) error {
+	verifier, ok := ctx.Auth.(core.ProofVerifier)
+	if !ok {
+		return nil
+	}
+
+	proof, err := core.ParseRequestProof(ctx.Request.Header(core.ProofHeader), ctx.Request.Method(), ctx.Request.Path())
+	if err != nil {
+		return err
+	}
+	return verifier.VerifyProof(session, proof)
+}
+
+func (o HandlerOptions) envelope() core.ResponseEnvelope {
+	if o.Envelope == nil {
+		return core.BareEnvelope{}
+	}
+	return o.Envelope
+}
+
+// respond sends a successful response through the operation's encoder and
+// the envelope, moving the session token to TokenHeader if configured
+func (o HandlerOptions) respond(ctx *core.RequestContext, operationID string, status int, data any) error {
+	if o.TokenHeader != "" {
+		var token string
+		if token, data = core.DetachSessionToken(data); token != "" {
+			ctx.Response.SetHeader(o.TokenHeader, token)
+		}
+	}
+
+	body, err := o.Transforms.Encode(operationID, data)
+	if err != nil {
+		return o.fail(ctx, http.StatusInternalServerError, "failed to encode response")
+	}
+	ctx.Response.Status(status)
+	return ctx.Response.JSON(o.envelope().Success(status, body))
+}
+
+// fail sends an error response through the envelope
+func (o HandlerOptions) fail(ctx *core.RequestContext, status int, message string) error {
+	ctx.Response.Status(status)
+	return ctx.Response.JSON(o.envelope().Failure(status, message))
+}
+
+// bindError answers a request whose body couldn't be bound: 413 when it
+// went over the adapter's body size limit, 400 otherwise
+func (o HandlerOptions) bindError(ctx *core.RequestContext, err error) error {
+	if errors.Is(err, core.ErrBodyTooLarge) {
+		return o.authError(ctx, err)
+	}
+	return o.fail(ctx, http.StatusBadRequest, "invalid request body")
+}
+
+// authError maps kuta errors to an enveloped error response
+func (o HandlerOptions) authError(ctx *core.RequestContext, err error) error {
+	status, body := core.ErrorBody(o.envelope(), err)
+	ctx.Response.Status(status)
+	return ctx.Response.JSON(body)
+}
+
+// setSessionCookie stores token in the session cookie until expiresAt
+func (o HandlerOptions) setSessionCookie(ctx *core.RequestContext, token string, expiresAt time.Time) {
+	if !o.SetCookie || token == "" {
+		return
+	}
+	ctx.Response.SetCookie(o.sessionCookie(token, expiresAt))
+}
+
+// clearSessionCookie expires the session cookie on the client
+func (o HandlerOptions) clearSessionCookie(ctx *core.RequestContext) {
+	if !o.SetCookie {
+		return
+	}
+	ctx.Response.SetCookie(o.sessionCookie("", time.Unix(0, 0)))
+}
+
+func (o HandlerOptions) sessionCookie(token string, expiresAt time.Time) *http.Cookie {
+	sameSite := http.SameSiteLaxMode
+	switch strings.ToLower(o.CookieSameSite) {
+	case "strict":
+		sameSite = http.SameSiteStrictMode
+	case "none":
+		sameSite = http.SameSiteNoneMode
+	}
+
+	return &http.Cookie{
+		Name:     o.CookieName,
+		Value:    token,
+		Path:     "/",
+		Domain:   o.CookieDomain,
+		Expires:  expiresAt,
+		Secure:   !o.CookieInsecure,
+		HttpOnly: true,
+		SameSite: sameSite,
+	}
+}
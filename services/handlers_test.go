@@ -0,0 +1,514 @@
+package services
+
+import (
+	"encoding/json"
+	"io"
+	"maps"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lborres/kuta/core"
+	"github.com/lborres/kuta/pkg/cache"
+	"github.com/lborres/kuta/pkg/crypto"
+)
+
+// fakeRequest is a core.Request with a JSON body and fixed headers
+type fakeRequest struct {
+	method, path string
+	headers      map[string]string
+	cookies      map[string]string
+	query        url.Values
+	params       map[string]string
+	body         string
+}
+
+func (r fakeRequest) Method() string               { return r.method }
+func (r fakeRequest) Path() string                 { return r.path }
+func (r fakeRequest) Query() url.Values            { return r.query }
+func (r fakeRequest) PathValue(name string) string { return r.params[name] }
+func (r fakeRequest) Header(name string) string    { return r.headers[name] }
+func (r fakeRequest) Body() io.Reader              { return strings.NewReader(r.body) }
+func (r fakeRequest) ClientIP() string             { return "127.0.0.1" }
+func (r fakeRequest) UserAgent() string            { return "agent" }
+func (r fakeRequest) Cookie(name string) string    { return r.cookies[name] }
+
+// fakeResponse records what a handler writes
+type fakeResponse struct {
+	status  int
+	headers map[string]string
+	cookies []*http.Cookie
+	body    string
+}
+
+func (r *fakeResponse) SetHeader(name, value string)  { r.headers[name] = value }
+func (r *fakeResponse) SetCookie(cookie *http.Cookie) { r.cookies = append(r.cookies, cookie) }
+func (r *fakeResponse) Status(code int)               { r.status = code }
+
+func (r *fakeResponse) JSON(v any) error {
+	data, err := json.Marshal(v)
+	r.body = string(data)
+	return err
+}
+
+// jsonBind decodes the request body as JSON, like the adapters' default binding
+func jsonBind(ctx *core.RequestContext, _ string, out any) error {
+	return json.NewDecoder(ctx.Request.Body()).Decode(out)
+}
+
+// Requirement: The shared base handlers read the request and write the
+// response only through RequestContext, with the same statuses, cookies and
+// bodies in every adapter.
+func TestBaseHandlers(t *testing.T) {
+	tests := []struct {
+		name        string
+		operationID string
+		opts        HandlerOptions
+		request     func(token string) fakeRequest
+		wantStatus  int
+		wantBody    string
+		wantHeader  string
+		wantCookie  string
+	}{
+		{
+			name:        "sign-up creates the session and sets the cookie",
+			operationID: core.OperationSignUp,
+			opts:        HandlerOptions{SetCookie: true},
+			request: func(string) fakeRequest {
+				return fakeRequest{body: `{"email":"bob@example.com","password":"SecurePass123!"}`}
+			},
+			wantStatus: http.StatusCreated,
+			wantBody:   `"email":"bob@example.com"`,
+			wantCookie: "auth_token",
+		},
+		{
+			name:        "sign-in moves the token to the token header",
+			operationID: core.OperationSignIn,
+			opts:        HandlerOptions{TokenHeader: core.DefaultTokenHeader},
+			request: func(string) fakeRequest {
+				return fakeRequest{body: `{"email":"alice@example.com","password":"SecurePass123!"}`}
+			},
+			wantStatus: http.StatusOK,
+			wantBody:   `"email":"alice@example.com"`,
+			wantHeader: core.DefaultTokenHeader,
+		},
+		{
+			name:        "sign-in error goes through the envelope",
+			operationID: core.OperationSignIn,
+			opts:        HandlerOptions{Envelope: core.DataEnvelope{}},
+			request: func(string) fakeRequest {
+				return fakeRequest{body: `{"email":"alice@example.com","password":"wrong"}`}
+			},
+			wantStatus: http.StatusUnauthorized,
+			wantBody:   `{"data":null,"error":{"message":"invalid email or password","code":401}}`,
+		},
+		{
+			name:        "malformed body",
+			operationID: core.OperationSignIn,
+			request:     func(string) fakeRequest { return fakeRequest{body: `{`} },
+			wantStatus:  http.StatusBadRequest,
+			wantBody:    `{"error":"invalid request body"}`,
+		},
+		{
+			name:        "body over the size limit",
+			operationID: core.OperationSignIn,
+			opts: HandlerOptions{Bind: func(*core.RequestContext, string, any) error {
+				return core.ErrBodyTooLarge
+			}},
+			request:    func(string) fakeRequest { return fakeRequest{} },
+			wantStatus: http.StatusRequestEntityTooLarge,
+			wantBody:   `{"error":"request body too large"}`,
+		},
+		{
+			name:        "get-session reads the bearer token",
+			operationID: core.OperationGetSession,
+			request: func(token string) fakeRequest {
+				return fakeRequest{method: "GET", path: "/session", headers: map[string]string{"Authorization": "Bearer " + token}}
+			},
+			wantStatus: http.StatusOK,
+			wantBody:   `"email":"alice@example.com"`,
+		},
+		{
+			name:        "sign-out reads the session cookie and clears it",
+			operationID: core.OperationSignOut,
+			opts:        HandlerOptions{SetCookie: true},
+			request: func(token string) fakeRequest {
+				return fakeRequest{cookies: map[string]string{"auth_token": token}}
+			},
+			wantStatus: http.StatusOK,
+			wantBody:   `{"message":"signed out successfully"}`,
+			wantCookie: "auth_token",
+		},
+		{
+			name:        "sign-out without token",
+			operationID: core.OperationSignOut,
+			request:     func(string) fakeRequest { return fakeRequest{} },
+			wantStatus:  http.StatusUnauthorized,
+			wantBody:    `{"error":"missing token"}`,
+		},
+		{
+			name:        "refresh without refresh token",
+			operationID: core.OperationRefreshToken,
+			request:     func(string) fakeRequest { return fakeRequest{body: `{}`} },
+			wantStatus:  http.StatusUnauthorized,
+			wantBody:    `{"error":"missing refresh token"}`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// Arrange
+			service := NewSessionManager(core.SessionConfig{MaxAge: time.Hour}, NewFakeStorageProvider(), nil, crypto.NewArgon2())
+			signUp, err := service.SignUp(t.Context(), core.SignUpInput{Email: "alice@example.com", Password: "SecurePass123!"}, "127.0.0.1", "agent")
+			if err != nil {
+				t.Fatalf("SignUp() error = %v", err)
+			}
+			opts := test.opts
+			opts.CookieName = "auth_token"
+			if opts.Bind == nil {
+				opts.Bind = jsonBind
+			}
+			res := &fakeResponse{headers: map[string]string{}}
+			ctx := &core.RequestContext{Request: test.request(signUp.Token), Response: res, Auth: service}
+
+			// Act
+			err = BaseHandlers(opts)[test.operationID](ctx)
+
+			// Assert
+			if err != nil {
+				t.Fatalf("handler error = %v", err)
+			}
+			if res.status != test.wantStatus {
+				t.Errorf("status = %d, want %d: %s", res.status, test.wantStatus, res.body)
+			}
+			if !strings.Contains(res.body, test.wantBody) {
+				t.Errorf("body = %s, want it to contain %s", res.body, test.wantBody)
+			}
+			if test.wantHeader != "" && (res.headers[test.wantHeader] == "" || strings.Contains(res.body, `"token"`)) {
+				t.Errorf("expected only the %s header to carry the token: %s", test.wantHeader, res.body)
+			}
+			if got := len(res.cookies) == 1 && res.cookies[0].Name == test.wantCookie; got != (test.wantCookie != "") {
+				t.Errorf("cookies = %v, want %q", res.cookies, test.wantCookie)
+			}
+		})
+	}
+}
+
+// Requirement: The 2FA handlers act for the signed-in user only.
+func TestTwoFactorHandlers(t *testing.T) {
+	tests := []struct {
+		name        string
+		operationID string
+		request     func(token string) fakeRequest
+		wantStatus  int
+		wantBody    string
+	}{
+		{
+			name:        "setup returns the enrollment URI",
+			operationID: core.OperationSetupTOTP,
+			request: func(token string) fakeRequest {
+				return fakeRequest{headers: map[string]string{"Authorization": "Bearer " + token}}
+			},
+			wantStatus: http.StatusOK,
+			wantBody:   `"uri":"otpauth://totp/`,
+		},
+		{
+			name:        "setup without token",
+			operationID: core.OperationSetupTOTP,
+			request:     func(string) fakeRequest { return fakeRequest{} },
+			wantStatus:  http.StatusUnauthorized,
+			wantBody:    `{"error":"missing authorization header"}`,
+		},
+		{
+			name:        "verify before setup",
+			operationID: core.OperationVerifyTOTP,
+			request: func(token string) fakeRequest {
+				return fakeRequest{headers: map[string]string{"Authorization": "Bearer " + token}, body: `{"code":"123456"}`}
+			},
+			wantStatus: http.StatusNotFound,
+			wantBody:   `{"error":"authenticator app is not set up"}`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// Arrange
+			sealer, _ := crypto.NewSealer("this-is-a-test-secret-of-32-bytes!", TOTPSealerPurpose)
+			service := NewSessionManager(core.SessionConfig{MaxAge: time.Hour}, NewFakeStorageProvider(), nil, crypto.NewArgon2(),
+				WithTwoFactor(core.TwoFactorConfig{}, sealer))
+			signUp, err := service.SignUp(t.Context(), core.SignUpInput{Email: "alice@example.com", Password: "SecurePass123!"}, "127.0.0.1", "agent")
+			if err != nil {
+				t.Fatalf("SignUp() error = %v", err)
+			}
+			opts := HandlerOptions{CookieName: "auth_token", Bind: jsonBind}
+			res := &fakeResponse{headers: map[string]string{}}
+			ctx := &core.RequestContext{Request: test.request(signUp.Token), Response: res, Auth: service}
+
+			// Act
+			err = TwoFactorHandlers(opts, nil)[test.operationID](ctx)
+
+			// Assert
+			if err != nil {
+				t.Fatalf("handler error = %v", err)
+			}
+			if res.status != test.wantStatus {
+				t.Errorf("status = %d, want %d: %s", res.status, test.wantStatus, res.body)
+			}
+			if !strings.Contains(res.body, test.wantBody) {
+				t.Errorf("body = %s, want it to contain %s", res.body, test.wantBody)
+			}
+		})
+	}
+}
+
+// Requirement: The guest session handler sets the session cookie, and
+// signing up with that cookie keeps the guest session's ID.
+func TestAnonymousHandlers(t *testing.T) {
+	tests := []struct {
+		name       string
+		opts       []Option
+		wantStatus int
+	}{
+		{name: "guest sessions on", opts: []Option{WithAnonymousSessions()}, wantStatus: http.StatusCreated},
+		{name: "guest sessions off", wantStatus: http.StatusNotImplemented},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// Arrange
+			service := NewSessionManager(core.SessionConfig{MaxAge: time.Hour}, NewFakeStorageProvider(), nil, crypto.NewArgon2(), test.opts...)
+			opts := HandlerOptions{CookieName: "auth_token", SetCookie: true, Bind: jsonBind}
+			res := &fakeResponse{headers: map[string]string{}}
+			ctx := &core.RequestContext{Request: fakeRequest{}, Response: res, Auth: service}
+
+			// Act
+			err := AnonymousHandlers(opts, nil)[core.OperationSignInAnonymous](ctx)
+
+			// Assert
+			if err != nil {
+				t.Fatalf("handler error = %v", err)
+			}
+			if res.status != test.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", res.status, test.wantStatus, res.body)
+			}
+			if test.wantStatus != http.StatusCreated {
+				return
+			}
+			if len(res.cookies) != 1 || res.cookies[0].Name != "auth_token" {
+				t.Fatalf("cookies = %v, want auth_token", res.cookies)
+			}
+			guest, err := service.GetSession(t.Context(), res.cookies[0].Value)
+			if err != nil {
+				t.Fatalf("GetSession error: %v", err)
+			}
+
+			signUp := &fakeResponse{headers: map[string]string{}}
+			ctx = &core.RequestContext{
+				Request: fakeRequest{
+					cookies: map[string]string{"auth_token": res.cookies[0].Value},
+					body:    `{"email":"guest@example.com","password":"SecurePass123!"}`,
+				},
+				Response: signUp,
+				Auth:     service,
+			}
+			if err := BaseHandlers(opts)[core.OperationSignUp](ctx); err != nil {
+				t.Fatalf("sign-up handler error = %v", err)
+			}
+			if signUp.status != http.StatusCreated || !strings.Contains(signUp.body, `"id":"`+guest.Session.ID+`"`) {
+				t.Errorf("sign-up = %d %s, want session %s", signUp.status, signUp.body, guest.Session.ID)
+			}
+		})
+	}
+}
+
+// Requirement: Sign-out and the admin endpoints refuse a key-bound session's
+// token sent without a proof from its key, like every other endpoint acting
+// on a session.
+func TestHandlers_RequireProofForKeyBoundSessions(t *testing.T) {
+	key, sign := newProofKey(t)
+
+	tests := []struct {
+		name        string
+		operationID string
+		method      string
+		path        string
+		body        string
+		withProof   bool
+		wantStatus  int
+	}{
+		{name: "sign-out without proof", operationID: core.OperationSignOut, method: http.MethodPost, path: "/sign-out", wantStatus: http.StatusUnauthorized},
+		{name: "sign-out with proof", operationID: core.OperationSignOut, method: http.MethodPost, path: "/sign-out", withProof: true, wantStatus: http.StatusOK},
+		{name: "admin list without proof", operationID: core.OperationAdminListSessions, method: http.MethodGet, path: "/admin/sessions", wantStatus: http.StatusUnauthorized},
+		{name: "admin list with proof", operationID: core.OperationAdminListSessions, method: http.MethodGet, path: "/admin/sessions", withProof: true, wantStatus: http.StatusOK},
+		{name: "admin revoke without proof", operationID: core.OperationAdminRevokeSessions, method: http.MethodPost, path: "/admin/sessions/revoke", body: `{"sessionIds":[]}`, wantStatus: http.StatusUnauthorized},
+		{name: "admin revoke with proof", operationID: core.OperationAdminRevokeSessions, method: http.MethodPost, path: "/admin/sessions/revoke", body: `{"sessionIds":[]}`, withProof: true, wantStatus: http.StatusOK},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// Arrange
+			service := NewSessionManager(core.SessionConfig{MaxAge: time.Hour}, NewFakeStorageProvider(), nil, crypto.NewArgon2(),
+				WithAdminAuthorizer(func(*core.SessionData) bool { return true }))
+			if _, err := service.SignUp(t.Context(), core.SignUpInput{Email: "alice@example.com", Password: "SecurePass123!"}, "127.0.0.1", "agent"); err != nil {
+				t.Fatalf("SignUp() error = %v", err)
+			}
+			signIn, err := service.SignIn(t.Context(), core.SignInInput{Email: "alice@example.com", Password: "SecurePass123!", PublicKey: key}, "127.0.0.1", "agent")
+			if err != nil {
+				t.Fatalf("SignIn() error = %v", err)
+			}
+			headers := map[string]string{"Authorization": "Bearer " + signIn.Token}
+			if test.withProof {
+				ts := time.Now().Unix()
+				headers[core.ProofHeader] = strconv.FormatInt(ts, 10) + "." + sign(test.method, test.path, ts)
+			}
+			req := fakeRequest{method: test.method, path: test.path, headers: headers, body: test.body}
+			res := &fakeResponse{headers: map[string]string{}}
+			ctx := &core.RequestContext{Request: req, Response: res, Auth: service}
+			handlers := BaseHandlers(HandlerOptions{CookieName: "auth_token", Bind: jsonBind})
+			maps.Copy(handlers, AdminHandlers(HandlerOptions{CookieName: "auth_token", Bind: jsonBind}, service))
+
+			// Act
+			err = handlers[test.operationID](ctx)
+
+			// Assert
+			if err != nil {
+				t.Fatalf("handler error = %v", err)
+			}
+			if res.status != test.wantStatus {
+				t.Errorf("status = %d, want %d: %s", res.status, test.wantStatus, res.body)
+			}
+			_, err = service.GetSession(t.Context(), signIn.Token)
+			signedOut := err != nil
+			if wantSignedOut := test.operationID == core.OperationSignOut && test.withProof; signedOut != wantSignedOut {
+				t.Errorf("signed out = %v, want %v", signedOut, wantSignedOut)
+			}
+		})
+	}
+}
+
+// Requirement: Under enumeration protection the forgot-password and
+// resend-verification handlers answer registered and unknown emails alike.
+func TestAccountEmailHandlers_SameAnswerForEveryEmail(t *testing.T) {
+	operations := []string{core.OperationForgotPassword, core.OperationResendVerification}
+
+	for _, operationID := range operations {
+		t.Run(operationID, func(t *testing.T) {
+			// Arrange
+			service, _, _ := newAccountEmailManager(t, &FakeMailer{}, WithEnumerationProtection(true))
+			opts := HandlerOptions{Bind: jsonBind}
+			handler := AccountEmailHandlers(opts, nil)[operationID]
+			var responses []*fakeResponse
+
+			// Act
+			for _, email := range []string{"user@example.com", "nobody@example.com", "nopass@example.com", "verified@example.com"} {
+				res := &fakeResponse{headers: map[string]string{}}
+				ctx := &core.RequestContext{Request: fakeRequest{body: `{"email":"` + email + `"}`}, Response: res, Auth: service}
+				if err := handler(ctx); err != nil {
+					t.Fatalf("handler error = %v", err)
+				}
+				responses = append(responses, res)
+			}
+
+			// Assert
+			for _, res := range responses {
+				if res.status != http.StatusOK || res.body != responses[0].body {
+					t.Errorf("response = %d %s, want %d %s", res.status, res.body, http.StatusOK, responses[0].body)
+				}
+			}
+		})
+	}
+}
+
+// Requirement: Starting OAuth sign-in gives the browser an HttpOnly state
+// cookie, and the callback only signs in the browser sending it back.
+func TestOAuthHandlers_StateCookie(t *testing.T) {
+	tests := []struct {
+		name       string
+		cookie     func(state string) map[string]string
+		wantStatus int
+	}{
+		{name: "same browser", cookie: func(state string) map[string]string { return map[string]string{"kuta_oauth_state": state} }, wantStatus: http.StatusOK},
+		{name: "missing cookie", cookie: func(string) map[string]string { return nil }, wantStatus: http.StatusBadRequest},
+		{name: "mismatched cookie", cookie: func(string) map[string]string { return map[string]string{"kuta_oauth_state": "other"} }, wantStatus: http.StatusBadRequest},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// Arrange
+			provider := &fakeOAuthProvider{identity: core.OAuthIdentity{AccountID: "sub", Email: "user@example.com", EmailVerified: true}}
+			service := NewSessionManager(core.SessionConfig{MaxAge: time.Hour}, NewFakeStorageProvider(), nil, crypto.NewArgon2(),
+				WithOAuth(cache.NewInMemoryOAuthStateStore(), "https://app.example/api/auth", provider))
+			handlers := OAuthHandlers(HandlerOptions{Bind: jsonBind}, nil)
+			started := &fakeResponse{headers: map[string]string{}}
+			ctx := &core.RequestContext{Request: fakeRequest{params: map[string]string{"provider": "test"}}, Response: started, Auth: service}
+			if err := handlers[core.OperationOAuthSignIn](ctx); err != nil {
+				t.Fatalf("sign-in handler error = %v", err)
+			}
+			location, _ := url.Parse(started.headers["Location"])
+			state := location.Query().Get("state")
+			if len(started.cookies) != 1 || started.cookies[0].Name != "kuta_oauth_state" || started.cookies[0].Value != state ||
+				!started.cookies[0].HttpOnly || started.cookies[0].SameSite != http.SameSiteLaxMode {
+				t.Fatalf("cookies = %+v, want an HttpOnly Lax state cookie", started.cookies)
+			}
+
+			res := &fakeResponse{headers: map[string]string{}}
+			ctx = &core.RequestContext{
+				Request: fakeRequest{
+					method:  http.MethodGet,
+					params:  map[string]string{"provider": "test"},
+					query:   url.Values{"code": {"code"}, "state": {state}},
+					cookies: test.cookie(state),
+				},
+				Response: res,
+				Auth:     service,
+			}
+
+			// Act
+			err := handlers[core.OperationOAuthCallback](ctx)
+
+			// Assert
+			if err != nil {
+				t.Fatalf("callback handler error = %v", err)
+			}
+			if res.status != test.wantStatus {
+				t.Errorf("status = %d, want %d: %s", res.status, test.wantStatus, res.body)
+			}
+			if len(res.cookies) == 0 || res.cookies[0].Name != "kuta_oauth_state" || res.cookies[0].MaxAge >= 0 {
+				t.Errorf("cookies = %+v, want the state cookie cleared", res.cookies)
+			}
+		})
+	}
+}
+
+// Requirement: The endpoints changing the user's sign-in settings refuse
+// guest sessions with 403.
+func TestHandlers_RefuseGuestOwners(t *testing.T) {
+	// Arrange
+	sealer, _ := crypto.NewSealer("this-is-a-test-secret-of-32-bytes!", TOTPSealerPurpose)
+	service := NewSessionManager(core.SessionConfig{MaxAge: time.Hour}, NewFakeStorageProvider(), nil, crypto.NewArgon2(),
+		WithAnonymousSessions(), WithTwoFactor(core.TwoFactorConfig{}, sealer))
+	guest, err := service.CreateAnonymous(t.Context(), "", "")
+	if err != nil {
+		t.Fatalf("CreateAnonymous error: %v", err)
+	}
+	res := &fakeResponse{headers: map[string]string{}}
+	ctx := &core.RequestContext{
+		Request:  fakeRequest{headers: map[string]string{"Authorization": "Bearer " + guest.Token}},
+		Response: res,
+		Auth:     service,
+	}
+
+	// Act
+	err = TwoFactorHandlers(HandlerOptions{Bind: jsonBind}, nil)[core.OperationSetupTOTP](ctx)
+
+	// Assert
+	if err != nil {
+		t.Fatalf("handler error = %v", err)
+	}
+	if res.status != http.StatusForbidden {
+		t.Errorf("status = %d, want %d: %s", res.status, http.StatusForbidden, res.body)
+	}
+}
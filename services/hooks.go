@@ -0,0 +1,13 @@
+package services
+
+import "github.com/lborres/kuta/core"
+
+// runAfterSignIn runs the AfterSignIn hooks, stopping at the first refusal
+func (sm *SessionManager) runAfterSignIn(input core.AfterSignInInput) error {
+	for _, hook := range sm.afterSignIn {
+		if err := hook(input); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,125 @@
+package services
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/lborres/kuta/core"
+	"github.com/lborres/kuta/pkg/cache"
+	"github.com/lborres/kuta/pkg/crypto"
+)
+
+// Requirement: AfterSignIn hooks see the provider's memberships before an
+// OAuth user is created, so a refused first sign-in leaves no user behind.
+func TestSessionManager_AfterSignIn_OAuth(t *testing.T) {
+	tests := []struct {
+		name        string
+		memberships []core.OAuthMembership
+		wantErr     error
+	}{
+		{
+			name:        "member signs in",
+			memberships: []core.OAuthMembership{{ID: "guild-1", Roles: []string{"admin"}}},
+		},
+		{
+			name:        "member without the role is refused",
+			memberships: []core.OAuthMembership{{ID: "guild-1", Roles: []string{"guest"}}},
+			wantErr:     core.ErrMembershipRequired,
+		},
+		{
+			name:        "non-member is refused",
+			memberships: []core.OAuthMembership{{ID: "guild-2", Roles: []string{"admin"}}},
+			wantErr:     core.ErrMembershipRequired,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// Arrange
+			storage := NewFakeStorageProvider()
+			provider := &fakeOAuthProvider{identity: core.OAuthIdentity{
+				AccountID:     "sub-1",
+				Email:         "member@example.com",
+				EmailVerified: true,
+				Memberships:   test.memberships,
+			}}
+			passwords := &crypto.Argon2{Memory: 1024, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32}
+			manager := NewSessionManager(core.SessionConfig{MaxAge: time.Hour}, storage, nil, passwords,
+				WithOAuth(cache.NewInMemoryOAuthStateStore(), "https://app.example/api/auth", provider),
+				WithAfterSignIn(core.RequireMembership("test", "guild-1"), core.RequireRole("test", "guild-1", "admin")))
+			start, err := manager.StartOAuth("test")
+			if err != nil {
+				t.Fatalf("StartOAuth() error = %v", err)
+			}
+
+			// Act
+			_, err = manager.CompleteOAuth(t.Context(), "test", core.OAuthCallback{Code: "code", State: start.State, StateCookie: start.State}, "", "")
+
+			// Assert
+			if !errors.Is(err, test.wantErr) {
+				t.Fatalf("CompleteOAuth() error = %v, want %v", err, test.wantErr)
+			}
+			_, lookupErr := storage.GetUserByEmail(t.Context(), "member@example.com")
+			if created := lookupErr == nil; created != (test.wantErr == nil) {
+				t.Errorf("user created = %v, want %v", created, test.wantErr == nil)
+			}
+		})
+	}
+}
+
+// Requirement: AfterSignIn hooks also run for password sign-ins, where
+// membership hooks for OAuth providers let them through.
+func TestSessionManager_AfterSignIn_Credential(t *testing.T) {
+	refused := errors.New("refused")
+
+	tests := []struct {
+		name      string
+		hook      core.AfterSignInHook
+		wantErr   error
+		wantInput bool
+	}{
+		{
+			name:      "hook sees the credential sign-in",
+			hook:      func(core.AfterSignInInput) error { return nil },
+			wantInput: true,
+		},
+		{
+			name:    "hook refuses the sign-in",
+			hook:    func(core.AfterSignInInput) error { return refused },
+			wantErr: refused,
+		},
+		{
+			name: "OAuth membership hook passes",
+			hook: core.RequireMembership("discord", "guild-1"),
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// Arrange
+			var seen core.AfterSignInInput
+			passwords := &crypto.Argon2{Memory: 1024, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32}
+			manager := NewSessionManager(core.SessionConfig{MaxAge: time.Hour}, NewFakeStorageProvider(), nil, passwords,
+				WithAfterSignIn(func(input core.AfterSignInInput) error {
+					seen = input
+					return nil
+				}, test.hook))
+			if _, err := manager.SignUp(t.Context(), core.SignUpInput{Email: "user@example.com", Password: "CorrectPass123!"}, "", ""); err != nil {
+				t.Fatalf("SignUp() error = %v", err)
+			}
+
+			// Act
+			_, err := manager.SignIn(t.Context(), core.SignInInput{Email: "user@example.com", Password: "CorrectPass123!"}, "10.0.0.1", "test-agent")
+
+			// Assert
+			if !errors.Is(err, test.wantErr) {
+				t.Fatalf("SignIn() error = %v, want %v", err, test.wantErr)
+			}
+			if test.wantInput && (seen.User == nil || seen.User.Email != "user@example.com" ||
+				seen.ProviderID != "credential" || seen.IPAddress != "10.0.0.1" || seen.Identity != nil) {
+				t.Errorf("hook input = %+v", seen)
+			}
+		})
+	}
+}
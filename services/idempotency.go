@@ -0,0 +1,237 @@
+package services
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/lborres/kuta/core"
+	"github.com/lborres/kuta/pkg/crypto"
+)
+
+// IdempotencySealerPurpose namespaces the key used to seal recorded
+// responses
+const IdempotencySealerPurpose = "idempotency"
+
+const (
+	defaultIdempotencyTTL = 24 * time.Hour
+	// idempotencyClaimTTL bounds how long a key stays claimed by a request
+	// that never finished, e.g. because the instance crashed
+	idempotencyClaimTTL = time.Minute
+)
+
+// idempotency replays the responses of retried requests. Responses carry
+// session and refresh tokens, so they are sealed before they are stored,
+// along with a fingerprint of the request body: a key is only replayed to
+// the request it was recorded for, and a fast hash of a password never
+// leaves the process.
+type idempotency struct {
+	store  core.IdempotencyStore
+	sealer *crypto.Sealer
+	ttl    time.Duration
+}
+
+// idempotencyRecord is what is sealed under a key
+type idempotencyRecord struct {
+	Key         string                 `json:"key"`
+	Fingerprint []byte                 `json:"fp"`
+	Response    *core.RecordedResponse `json:"response"`
+}
+
+var _ core.IdempotentRequests = (*SessionManager)(nil)
+
+// IdempotencyEnabled reports whether an idempotency store has been
+// configured
+func (sm *SessionManager) IdempotencyEnabled() bool {
+	return sm.idempotency != nil
+}
+
+// BeginIdempotent claims key for a request to operationID with body, or
+// returns the response recorded for it
+func (sm *SessionManager) BeginIdempotent(operationID, key string, body []byte) (*core.RecordedResponse, error) {
+	if sm.idempotency == nil {
+		return nil, core.ErrNotImplemented
+	}
+	if key == "" || len(key) > core.MaxIdempotencyKeyLength {
+		return nil, core.ErrInvalidIdempotencyKey
+	}
+
+	storeKey := idempotencyStoreKey(operationID, key)
+	sealed, err := sm.idempotency.store.Reserve(storeKey, idempotencyClaimTTL)
+	if err != nil || sealed == "" {
+		return nil, err
+	}
+
+	plaintext, err := sm.idempotency.sealer.Open(sealed)
+	if err != nil {
+		return nil, err
+	}
+	var record idempotencyRecord
+	if err := json.Unmarshal(plaintext, &record); err != nil {
+		return nil, err
+	}
+	if record.Key != storeKey || record.Response == nil {
+		return nil, errors.New("idempotency record does not belong to its key")
+	}
+	if subtle.ConstantTimeCompare(record.Fingerprint, bodyFingerprint(body)) != 1 {
+		return nil, core.ErrIdempotencyKeyReused
+	}
+	return record.Response, nil
+}
+
+// FinishIdempotent records response under key, or releases key when
+// response is nil
+func (sm *SessionManager) FinishIdempotent(operationID, key string, body []byte, response *core.RecordedResponse) error {
+	if sm.idempotency == nil {
+		return core.ErrNotImplemented
+	}
+
+	storeKey := idempotencyStoreKey(operationID, key)
+	if response == nil {
+		return sm.idempotency.store.Release(storeKey)
+	}
+
+	plaintext, err := json.Marshal(idempotencyRecord{
+		Key:         storeKey,
+		Fingerprint: bodyFingerprint(body),
+		Response:    response,
+	})
+	if err != nil {
+		return err
+	}
+	sealed, err := sm.idempotency.sealer.Seal(plaintext)
+	if err != nil {
+		return err
+	}
+	return sm.idempotency.store.Record(storeKey, sealed, sm.idempotency.ttl)
+}
+
+// idempotencyStoreKey scopes key to its operation, so a client reusing one
+// key across endpoints doesn't get one endpoint's response from another
+func idempotencyStoreKey(operationID, key string) string {
+	return crypto.HashToken(operationID + ":" + key)
+}
+
+func bodyFingerprint(body []byte) []byte {
+	sum := sha256.Sum256(body)
+	return sum[:]
+}
+
+// IdempotentHandler wraps the handler of one of core.IdempotentOperations so
+// that a request carrying an Idempotency-Key runs once: retries with the
+// same key and body get the recorded response. Only successful responses are
+// recorded; after a failure the key is released and a retry runs again.
+// The body is read up front, so adapters must bind it through ctx.Request.
+func IdempotentHandler(opts HandlerOptions, requests core.IdempotentRequests, operationID string, next func(*core.RequestContext) error) func(*core.RequestContext) error {
+	return func(ctx *core.RequestContext) error {
+		key := ctx.Request.Header(core.IdempotencyKeyHeader)
+		if key == "" {
+			return next(ctx)
+		}
+
+		body, err := io.ReadAll(ctx.Request.Body())
+		if err != nil {
+			return opts.bindError(ctx, err)
+		}
+
+		recorded, err := requests.BeginIdempotent(operationID, key, body)
+		if err != nil {
+			return opts.authError(ctx, err)
+		}
+		if recorded != nil {
+			return replayResponse(ctx, recorded)
+		}
+
+		recorder := &responseRecorder{Response: ctx.Response, tokenHeader: opts.TokenHeader, header: http.Header{}}
+		call := *ctx
+		call.Request = bufferedRequest{Request: ctx.Request, body: body}
+		call.Response = recorder
+		handlerErr := next(&call)
+
+		var response *core.RecordedResponse
+		if status := recorder.statusCode(); handlerErr == nil && status >= 200 && status < 300 {
+			response = &core.RecordedResponse{Status: status, Header: recorder.header, Body: recorder.body}
+		}
+		// The response stands either way; if it can't be recorded, a retry
+		// runs again once the claim expires
+		_ = requests.FinishIdempotent(operationID, key, body, response)
+		return handlerErr
+	}
+}
+
+// replayResponse sends a recorded response again
+func replayResponse(ctx *core.RequestContext, recorded *core.RecordedResponse) error {
+	for name, values := range recorded.Header {
+		for _, value := range values {
+			if name != "Set-Cookie" {
+				ctx.Response.SetHeader(name, value)
+			} else if cookie, err := http.ParseSetCookie(value); err == nil {
+				ctx.Response.SetCookie(cookie)
+			}
+		}
+	}
+	ctx.Response.Status(recorded.Status)
+	if len(recorded.Body) == 0 {
+		return nil
+	}
+	return ctx.Response.JSON(json.RawMessage(recorded.Body))
+}
+
+// bufferedRequest serves a body that was already read
+type bufferedRequest struct {
+	core.Request
+	body []byte
+}
+
+func (r bufferedRequest) Body() io.Reader {
+	return bytes.NewReader(r.body)
+}
+
+// responseRecorder copies what a handler writes that a replay repeats: the
+// status, the body, cookies and the token header
+type responseRecorder struct {
+	core.Response
+	tokenHeader string
+	status      int
+	header      http.Header
+	body        []byte
+}
+
+func (r *responseRecorder) SetHeader(name, value string) {
+	if r.tokenHeader != "" && http.CanonicalHeaderKey(name) == http.CanonicalHeaderKey(r.tokenHeader) {
+		r.header.Set(name, value)
+	}
+	r.Response.SetHeader(name, value)
+}
+
+func (r *responseRecorder) SetCookie(cookie *http.Cookie) {
+	r.header.Add("Set-Cookie", cookie.String())
+	r.Response.SetCookie(cookie)
+}
+
+func (r *responseRecorder) Status(code int) {
+	r.status = code
+	r.Response.Status(code)
+}
+
+// JSON encodes v once, so the recorded body is the one sent
+func (r *responseRecorder) JSON(v any) error {
+	body, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	r.body = body
+	return r.Response.JSON(json.RawMessage(body))
+}
+
+func (r *responseRecorder) statusCode() int {
+	if r.status == 0 {
+		return http.StatusOK
+	}
+	return r.status
+}
@@ -0,0 +1,238 @@
+package services
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/lborres/kuta/core"
+	"github.com/lborres/kuta/pkg/cache"
+	"github.com/lborres/kuta/pkg/crypto"
+)
+
+func newIdempotentSessionManager(t *testing.T, store core.IdempotencyStore) *SessionManager {
+	t.Helper()
+	sealer, err := crypto.NewSealer("this-is-a-test-secret-of-32-bytes!", IdempotencySealerPurpose)
+	if err != nil {
+		t.Fatalf("NewSealer error: %v", err)
+	}
+	return NewSessionManager(core.SessionConfig{MaxAge: time.Hour}, NewFakeStorageProvider(), nil, crypto.NewArgon2(),
+		WithIdempotency(store, sealer, time.Hour))
+}
+
+// Requirement: A key is replayed only to the operation and body it was
+// recorded for; another body is refused, and a retry while the first request
+// runs is told it is in progress.
+func TestSessionManager_BeginIdempotent(t *testing.T) {
+	recorded := &core.RecordedResponse{Status: 201, Body: []byte(`{"token":"abc"}`)}
+	tests := []struct {
+		name         string
+		operationID  string
+		key          string
+		body         string
+		finish       bool
+		wantResponse bool
+		wantErr      error
+	}{
+		{name: "replayed", operationID: core.OperationSignUp, key: "key-1", body: "body", finish: true, wantResponse: true},
+		{name: "other body", operationID: core.OperationSignUp, key: "key-1", body: "other", finish: true, wantErr: core.ErrIdempotencyKeyReused},
+		{name: "other operation", operationID: core.OperationSignIn, key: "key-1", body: "body", finish: true},
+		{name: "other key", operationID: core.OperationSignUp, key: "key-2", body: "body", finish: true},
+		{name: "in progress", operationID: core.OperationSignUp, key: "key-1", body: "body", wantErr: core.ErrIdempotencyInProgress},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// Arrange
+			manager := newIdempotentSessionManager(t, cache.NewInMemoryIdempotencyStore())
+			if response, err := manager.BeginIdempotent(core.OperationSignUp, "key-1", []byte("body")); response != nil || err != nil {
+				t.Fatalf("first BeginIdempotent() = %v, %v", response, err)
+			}
+			if test.finish {
+				if err := manager.FinishIdempotent(core.OperationSignUp, "key-1", []byte("body"), recorded); err != nil {
+					t.Fatalf("FinishIdempotent() error = %v", err)
+				}
+			}
+
+			// Act
+			response, err := manager.BeginIdempotent(test.operationID, test.key, []byte(test.body))
+
+			// Assert
+			if !errors.Is(err, test.wantErr) {
+				t.Fatalf("BeginIdempotent() error = %v, want %v", err, test.wantErr)
+			}
+			if (response != nil) != test.wantResponse {
+				t.Fatalf("BeginIdempotent() = %v, want a response: %v", response, test.wantResponse)
+			}
+			if test.wantResponse && (response.Status != recorded.Status || string(response.Body) != string(recorded.Body)) {
+				t.Errorf("BeginIdempotent() = %+v, want %+v", response, recorded)
+			}
+		})
+	}
+}
+
+// Requirement: Finishing without a response releases the key, so the retry
+// of a failed request runs again.
+func TestSessionManager_FinishIdempotent_Release(t *testing.T) {
+	// Arrange
+	manager := newIdempotentSessionManager(t, cache.NewInMemoryIdempotencyStore())
+	_, _ = manager.BeginIdempotent(core.OperationRefreshToken, "key-1", []byte("body"))
+
+	// Act
+	err := manager.FinishIdempotent(core.OperationRefreshToken, "key-1", []byte("body"), nil)
+
+	// Assert
+	if err != nil {
+		t.Fatalf("FinishIdempotent() error = %v", err)
+	}
+	if response, err := manager.BeginIdempotent(core.OperationRefreshToken, "key-1", []byte("body")); response != nil || err != nil {
+		t.Errorf("BeginIdempotent() after release = %v, %v, want a fresh claim", response, err)
+	}
+}
+
+// Requirement: Stored responses are sealed, so the store never holds the
+// tokens they carry, and keys are rejected when empty or too long.
+func TestSessionManager_Idempotency_Stored(t *testing.T) {
+	// Arrange
+	store := &recordingIdempotencyStore{InMemoryIdempotencyStore: cache.NewInMemoryIdempotencyStore()}
+	manager := newIdempotentSessionManager(t, store)
+	_, _ = manager.BeginIdempotent(core.OperationSignIn, "key-1", []byte("body"))
+
+	// Act
+	err := manager.FinishIdempotent(core.OperationSignIn, "key-1", []byte("body"), &core.RecordedResponse{Status: 200, Body: []byte("secret-token")})
+
+	// Assert
+	if err != nil {
+		t.Fatalf("FinishIdempotent() error = %v", err)
+	}
+	if store.value == "" || store.key == "key-1" || store.ttl != time.Hour {
+		t.Errorf("Record(%q, %q, %v), want a hashed key, a value and the configured ttl", store.key, store.value, store.ttl)
+	}
+	other, _ := crypto.NewSealer("this-is-a-test-secret-of-32-bytes!", StatelessSealerPurpose)
+	if _, err := other.Open(store.value); err == nil {
+		t.Error("recorded value opens with another purpose's key")
+	}
+	for _, key := range []string{"", string(make([]byte, core.MaxIdempotencyKeyLength+1))} {
+		if _, err := manager.BeginIdempotent(core.OperationSignIn, key, nil); !errors.Is(err, core.ErrInvalidIdempotencyKey) {
+			t.Errorf("BeginIdempotent(%d byte key) error = %v, want ErrInvalidIdempotencyKey", len(key), err)
+		}
+	}
+}
+
+// recordingIdempotencyStore remembers the last recorded entry
+type recordingIdempotencyStore struct {
+	*cache.InMemoryIdempotencyStore
+	key, value string
+	ttl        time.Duration
+}
+
+func (s *recordingIdempotencyStore) Record(key, value string, ttl time.Duration) error {
+	s.key, s.value, s.ttl = key, value, ttl
+	return s.InMemoryIdempotencyStore.Record(key, value, ttl)
+}
+
+// idempotentAuthProvider adds idempotency to the mock auth provider,
+// recording responses by operation and key
+type idempotentAuthProvider struct {
+	*mockAuthProvider
+	bodies    map[string]string
+	responses map[string]*core.RecordedResponse
+	released  int
+}
+
+func (p *idempotentAuthProvider) IdempotencyEnabled() bool { return true }
+
+func (p *idempotentAuthProvider) BeginIdempotent(operationID, key string, body []byte) (*core.RecordedResponse, error) {
+	id := operationID + ":" + key
+	if claimed, ok := p.bodies[id]; ok {
+		if claimed != string(body) {
+			return nil, core.ErrIdempotencyKeyReused
+		}
+		if p.responses[id] == nil {
+			return nil, core.ErrIdempotencyInProgress
+		}
+		return p.responses[id], nil
+	}
+	p.bodies[id] = string(body)
+	return nil, nil
+}
+
+func (p *idempotentAuthProvider) FinishIdempotent(operationID, key string, _ []byte, response *core.RecordedResponse) error {
+	id := operationID + ":" + key
+	if response == nil {
+		delete(p.bodies, id)
+		p.released++
+		return nil
+	}
+	p.responses[id] = response
+	return nil
+}
+
+// Requirement: A sign-up retried with the same Idempotency-Key and body gets
+// the first response, cookie included, without signing up again. Another
+// body is refused, requests without a key always run, and a failed request
+// releases its key so the retry runs.
+func TestIdempotentHandler_SignUp(t *testing.T) {
+	body := core.SignUpRequest{Email: "a@b.c", Password: "password123", Name: "A"}
+	tests := []struct {
+		name        string
+		key         string
+		retryBody   any
+		signUpErr   error
+		wantStatus  int
+		wantSignUp  bool
+		wantReplay  bool
+		wantRelease int
+	}{
+		{name: "replayed", key: "key-1", retryBody: body, wantStatus: http.StatusCreated, wantReplay: true},
+		{name: "other body", key: "key-1", retryBody: core.SignUpRequest{Email: "x@b.c", Password: "password123"}, wantStatus: http.StatusUnprocessableEntity},
+		{name: "no key", retryBody: body, wantStatus: http.StatusCreated, wantSignUp: true},
+		{name: "failure released", key: "key-1", retryBody: body, signUpErr: core.ErrUserExists, wantStatus: http.StatusConflict, wantSignUp: true, wantRelease: 2},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// Arrange
+			mock := &mockAuthProvider{
+				signUpResult: &core.SignUpResult{
+					User:    &core.User{ID: "u1", Email: "a@b.c"},
+					Session: &core.Session{ExpiresAt: time.Now().Add(time.Hour)},
+					Token:   "tok",
+				},
+				signUpErr: test.signUpErr,
+			}
+			auth := &idempotentAuthProvider{mockAuthProvider: mock, bodies: map[string]string{}, responses: map[string]*core.RecordedResponse{}}
+			server := newRouteServer(t, auth, HandlerOptions{SetCookie: true})
+			headers := map[string]string{}
+			if test.key != "" {
+				headers[core.IdempotencyKeyHeader] = test.key
+			}
+			first := server.do(routeRequest{method: http.MethodPost, path: "/sign-up", body: body, headers: headers})
+			mock.signUpCalled = false
+
+			// Act
+			retry := server.do(routeRequest{method: http.MethodPost, path: "/sign-up", body: test.retryBody, headers: headers})
+
+			// Assert
+			if retry.status != test.wantStatus {
+				t.Fatalf("retry status = %d, want %d (body %s)", retry.status, test.wantStatus, retry.body)
+			}
+			if mock.signUpCalled != test.wantSignUp {
+				t.Errorf("retry signed up = %v, want %v", mock.signUpCalled, test.wantSignUp)
+			}
+			if auth.released != test.wantRelease {
+				t.Errorf("keys released = %d, want %d", auth.released, test.wantRelease)
+			}
+			if !test.wantReplay {
+				return
+			}
+			if retry.body != first.body {
+				t.Errorf("retry = %s, want the first response %s", retry.body, first.body)
+			}
+			if cookie := retry.cookie("auth_token"); cookie == nil || cookie.Value != "tok" {
+				t.Errorf("retry cookie = %v, want the session cookie", cookie)
+			}
+		})
+	}
+}
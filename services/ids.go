@@ -0,0 +1,77 @@
+package services
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/lborres/kuta/core"
+	"github.com/lborres/kuta/pkg/crypto"
+)
+
+const (
+	defaultIDLength = 22
+	// minIDBits rejects configurations whose IDs would be guessable or
+	// likely to collide
+	minIDBits = 64
+)
+
+// idGenerator generates IDs of one configured shape
+type idGenerator struct {
+	nanoid *crypto.NanoIDGenerator
+	length int
+}
+
+func newIDGenerator(config core.IDConfig) (idGenerator, error) {
+	nanoid, err := crypto.NewNanoID(config.Alphabet)
+	if err != nil {
+		return idGenerator{}, fmt.Errorf("%w: %w", core.ErrInvalidIDConfig, err)
+	}
+
+	length := config.Length
+	if length <= 0 {
+		length = defaultIDLength
+	}
+	alphabetSize := len(config.Alphabet)
+	if alphabetSize == 0 {
+		alphabetSize = 64
+	}
+	if bits := float64(length) * math.Log2(float64(alphabetSize)); bits < minIDBits {
+		return idGenerator{}, fmt.Errorf("%w: %d characters from a %d-character alphabet carry %.0f bits, need at least %d",
+			core.ErrInvalidIDConfig, length, alphabetSize, bits, minIDBits)
+	}
+
+	return idGenerator{nanoid: nanoid, length: length}, nil
+}
+
+func (g idGenerator) generate() (string, error) {
+	return g.nanoid.Generate(g.length)
+}
+
+// IDGenerators produces IDs for users, sessions and accounts
+type IDGenerators struct {
+	users    idGenerator
+	sessions idGenerator
+	accounts idGenerator
+}
+
+// NewIDGenerators validates config and builds the per-entity generators
+func NewIDGenerators(config core.EntityIDConfig) (*IDGenerators, error) {
+	users, err := newIDGenerator(config.User)
+	if err != nil {
+		return nil, fmt.Errorf("user IDs: %w", err)
+	}
+	sessions, err := newIDGenerator(config.Session)
+	if err != nil {
+		return nil, fmt.Errorf("session IDs: %w", err)
+	}
+	accounts, err := newIDGenerator(config.Account)
+	if err != nil {
+		return nil, fmt.Errorf("account IDs: %w", err)
+	}
+
+	return &IDGenerators{
+		users:    users,
+		sessions: sessions,
+		accounts: accounts,
+	}, nil
+}
@@ -0,0 +1,74 @@
+package services
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lborres/kuta/core"
+	"github.com/lborres/kuta/pkg/crypto"
+)
+
+// Requirement: ID configurations are validated for alphabet and entropy.
+func TestNewIDGenerators_Validation(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  core.EntityIDConfig
+		wantErr error
+	}{
+		{name: "defaults"},
+		{name: "hex session IDs", config: core.EntityIDConfig{Session: core.IDConfig{Alphabet: "0123456789abcdef", Length: 32}}},
+		{name: "too short for entropy", config: core.EntityIDConfig{User: core.IDConfig{Alphabet: "0123456789abcdef", Length: 8}}, wantErr: core.ErrInvalidIDConfig},
+		{name: "alphabet too small", config: core.EntityIDConfig{Account: core.IDConfig{Alphabet: "abc"}}, wantErr: core.ErrInvalidIDConfig},
+		{name: "non-ASCII alphabet", config: core.EntityIDConfig{User: core.IDConfig{Alphabet: "abcdefghé"}}, wantErr: core.ErrInvalidIDConfig},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// Act
+			_, err := NewIDGenerators(test.config)
+
+			// Assert
+			if !errors.Is(err, test.wantErr) {
+				t.Errorf("expected %v, got %v", test.wantErr, err)
+			}
+		})
+	}
+}
+
+// Requirement: Each entity uses its own configured ID shape.
+func TestSessionManager_CustomIDs(t *testing.T) {
+	// Arrange
+	ids, err := NewIDGenerators(core.EntityIDConfig{
+		User:    core.IDConfig{Alphabet: "0123456789abcdef", Length: 32},
+		Session: core.IDConfig{Length: 30},
+	})
+	if err != nil {
+		t.Fatalf("NewIDGenerators error: %v", err)
+	}
+	storage := NewFakeStorageProvider()
+	passwords := &crypto.Argon2{Memory: 1024, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32}
+	manager := NewSessionManager(core.SessionConfig{MaxAge: time.Hour}, storage, nil, passwords, WithIDGenerators(ids))
+
+	// Act
+	result, err := manager.SignUp(t.Context(), core.SignUpInput{Email: "ids@example.com", Password: "SecurePass123!", Name: "IDs"}, "", "")
+
+	// Assert
+	if err != nil {
+		t.Fatalf("SignUp error: %v", err)
+	}
+	if len(result.User.ID) != 32 || strings.Trim(result.User.ID, "0123456789abcdef") != "" {
+		t.Errorf("expected a 32-character hex user ID, got %q", result.User.ID)
+	}
+	if len(result.Session.ID) != 30 {
+		t.Errorf("expected a 30-character session ID, got %q", result.Session.ID)
+	}
+	accounts, err := storage.GetAccountByUserAndProvider(t.Context(), result.User.ID, "credential")
+	if err != nil || len(accounts) != 1 {
+		t.Fatalf("GetAccountByUserAndProvider = %v, %v", accounts, err)
+	}
+	if len(accounts[0].ID) != defaultIDLength {
+		t.Errorf("expected a default-length account ID, got %q", accounts[0].ID)
+	}
+}
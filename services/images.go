@@ -0,0 +1,104 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/lborres/kuta/core"
+)
+
+// DefaultMaxImageSize caps user image uploads handed to the ImageStore
+const DefaultMaxImageSize = 5 << 20
+
+// storeImage validates an upload and hands it to the ImageStore. It returns
+// nil when the store kept nothing. The content type is sniffed from the data
+// rather than trusted from the client, which also keeps out SVGs and other
+// markup that browsers would execute.
+func (sm *SessionManager) storeImage(userID string, upload *core.ImageUpload) (*string, error) {
+	if len(upload.Data) == 0 || len(upload.Data) > sm.maxImageSize {
+		return nil, core.ErrInvalidImage
+	}
+	contentType := http.DetectContentType(upload.Data)
+	if !strings.HasPrefix(contentType, "image/") {
+		return nil, core.ErrInvalidImage
+	}
+
+	url, err := sm.images.StoreImage(userID, core.ImageUpload{ContentType: contentType, Data: upload.Data})
+	if err != nil || url == "" {
+		return nil, err
+	}
+	return &url, nil
+}
+
+// UpdateUser changes a user's name, image and email. An ImageUpload is
+// stored through the ImageStore and takes precedence over Image. A new email
+// must not belong to another user; it starts out unverified.
+func (sm *SessionManager) UpdateUser(ctx context.Context, userID string, input core.UpdateUserInput) (*core.User, error) {
+	user, err := sm.storage.GetUserByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	emailChanged := input.Email != nil && *input.Email != user.Email
+	if emailChanged {
+		if err := sm.checkNewEmail(ctx, user.ID, *input.Email); err != nil {
+			return nil, err
+		}
+		user.Email = *input.Email
+		user.EmailVerified = false
+	}
+
+	if input.Name != nil {
+		user.Name = *input.Name
+	}
+	if input.Image != nil {
+		user.Image = input.Image
+	}
+	if input.ImageUpload != nil {
+		url, err := sm.storeImage(userID, input.ImageUpload)
+		if err != nil {
+			return nil, err
+		}
+		if url != nil {
+			user.Image = url
+		}
+	}
+
+	user.UpdatedAt = time.Now()
+	if err := sm.storage.UpdateUser(ctx, user); err != nil {
+		return nil, err
+	}
+	if emailChanged {
+		if err := sm.voidVerificationTokens(ctx, user.ID, changeEmail); err != nil {
+			return nil, err
+		}
+	}
+	return user, nil
+}
+
+// checkNewEmail validates an email change and refuses addresses of other
+// users
+func (sm *SessionManager) checkNewEmail(ctx context.Context, userID, email string) error {
+	var v core.Validator
+	switch {
+	case email == "":
+		v.Add("email", core.CodeEmailRequired, core.ErrEmailRequired)
+	case !validEmail(email):
+		v.Add("email", core.CodeEmailInvalid, core.ErrInvalidEmail)
+	}
+	if err := v.Err(); err != nil {
+		return err
+	}
+
+	existing, err := sm.storage.GetUserByEmail(ctx, email)
+	switch {
+	case err == nil && existing.ID != userID:
+		return core.ErrUserExists
+	case err != nil && !errors.Is(err, core.ErrUserNotFound):
+		return err
+	}
+	return nil
+}
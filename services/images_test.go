@@ -0,0 +1,88 @@
+package services
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/lborres/kuta/core"
+	"github.com/lborres/kuta/pkg/crypto"
+)
+
+var pngHeader = []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+
+type fakeImageStore struct {
+	stored []core.ImageUpload
+}
+
+func (s *fakeImageStore) StoreImage(userID string, image core.ImageUpload) (string, error) {
+	s.stored = append(s.stored, image)
+	return "https://images.example.com/" + userID, nil
+}
+
+// Requirement: Image uploads on sign-up and profile update are stored through
+// the ImageStore and their URL saved in User.Image; non-images are rejected.
+func TestSessionManager_ImageUploads(t *testing.T) {
+	tests := []struct {
+		name    string
+		upload  *core.ImageUpload
+		wantErr error
+	}{
+		{
+			name:   "stores PNG upload",
+			upload: &core.ImageUpload{ContentType: "image/png", Data: pngHeader},
+		},
+		{
+			name:    "rejects SVG declared as image",
+			upload:  &core.ImageUpload{ContentType: "image/svg+xml", Data: []byte(`<svg onload="alert(1)"></svg>`)},
+			wantErr: core.ErrInvalidImage,
+		},
+		{
+			name:    "rejects oversized upload",
+			upload:  &core.ImageUpload{Data: append(append([]byte{}, pngHeader...), make([]byte, 64)...)},
+			wantErr: core.ErrInvalidImage,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// Arrange
+			storage := NewFakeStorageProvider()
+			images := &fakeImageStore{}
+			manager := NewSessionManager(core.SessionConfig{MaxAge: time.Hour}, storage, nil, crypto.NewArgon2(), WithImageStore(images, 64))
+
+			// Act
+			signUp, signUpErr := manager.SignUp(t.Context(), core.SignUpInput{
+				Email:       "alice@example.com",
+				Password:    "SecurePass123!",
+				ImageUpload: test.upload,
+			}, "127.0.0.1", "test-agent")
+
+			// Assert
+			if !errors.Is(signUpErr, test.wantErr) {
+				t.Fatalf("expected SignUp error %v, got %v", test.wantErr, signUpErr)
+			}
+			if test.wantErr != nil {
+				if len(images.stored) != 0 {
+					t.Error("expected rejected upload not to reach the ImageStore")
+				}
+				return
+			}
+			if signUp.User.Image == nil || *signUp.User.Image != "https://images.example.com/"+signUp.User.ID {
+				t.Errorf("expected image URL from store, got %v", signUp.User.Image)
+			}
+			if images.stored[0].ContentType != "image/png" {
+				t.Errorf("expected sniffed content type, got %q", images.stored[0].ContentType)
+			}
+
+			name := "Alice"
+			updated, err := manager.UpdateUser(t.Context(), signUp.User.ID, core.UpdateUserInput{Name: &name, ImageUpload: test.upload})
+			if err != nil {
+				t.Fatalf("UpdateUser error: %v", err)
+			}
+			if updated.Name != "Alice" || len(images.stored) != 2 {
+				t.Errorf("expected name and image updated, got %q with %d uploads", updated.Name, len(images.stored))
+			}
+		})
+	}
+}
@@ -0,0 +1,47 @@
+package services
+
+import (
+	"context"
+	"time"
+
+	"github.com/lborres/kuta/core"
+)
+
+// impersonationMaxAge caps how long an impersonated session lives. No
+// refresh token is issued for it, so it can't be extended.
+const impersonationMaxAge = time.Hour
+
+// Impersonate opens a session as userID on behalf of the admin in actor, who
+// must have passed AuthorizeAdmin. The session carries the admin's user ID so
+// GetSession can report it. Admins can't impersonate themselves or start
+// another impersonation from an impersonated session.
+func (sm *SessionManager) Impersonate(ctx context.Context, actor *core.SessionData, userID, ipAddress, userAgent string) (*core.CreateSessionResult, error) {
+	if actor == nil || actor.Impersonated() || actor.User.ID == userID {
+		return nil, core.ErrForbidden
+	}
+
+	user, err := sm.storage.GetUserByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := sm.createSession(ctx, user.ID, ipAddress, userAgent, "", &core.Impersonation{
+		ActorUserID: actor.User.ID,
+		StartedAt:   time.Now(),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sm.emit(core.Event{
+		Type:      core.EventImpersonationStarted,
+		UserID:    user.ID,
+		Email:     user.Email,
+		SessionID: result.Session.ID,
+		IPAddress: ipAddress,
+		UserAgent: userAgent,
+		Metadata:  map[string]any{"actorUserId": actor.User.ID},
+	})
+
+	return result, nil
+}
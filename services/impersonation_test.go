@@ -0,0 +1,88 @@
+package services
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/lborres/kuta/core"
+)
+
+// Requirement: Impersonate opens a short-lived session as the target user
+// whose SessionData reports the acting admin, and refuses self and nested
+// impersonation.
+func TestSessionManager_Impersonate(t *testing.T) {
+	tests := []struct {
+		name         string
+		targetUserID string
+		nested       bool
+		wantErr      error
+	}{
+		{name: "impersonates another user", targetUserID: "u1"},
+		{name: "forbidden for self", targetUserID: "admin", wantErr: core.ErrForbidden},
+		{name: "forbidden from an impersonated session", targetUserID: "u1", nested: true, wantErr: core.ErrForbidden},
+		{name: "unknown user", targetUserID: "missing", wantErr: core.ErrUserNotFound},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// Arrange
+			storage := NewFakeStorageProvider()
+			_ = storage.CreateUser(t.Context(), &core.User{ID: "admin", Email: "admin@example.com"})
+			_ = storage.CreateUser(t.Context(), &core.User{ID: "u1", Email: "user@example.com"})
+			manager := NewSessionManager(core.SessionConfig{MaxAge: 24 * time.Hour}, storage, NewFakeCache(), nil,
+				WithAdminAuthorizer(func(d *core.SessionData) bool { return d.User.ID == "admin" }))
+			created, _ := manager.Create(t.Context(), "admin", "127.0.0.1", "test-agent")
+			actor, _ := manager.AuthorizeAdmin(t.Context(), created.Token)
+			if test.nested {
+				actor.Impersonation = &core.Impersonation{ActorUserID: "someone", StartedAt: time.Now()}
+			}
+
+			// Act
+			result, err := manager.Impersonate(t.Context(), actor, test.targetUserID, "127.0.0.1", "test-agent")
+
+			// Assert
+			if !errors.Is(err, test.wantErr) {
+				t.Fatalf("Impersonate() error = %v, want %v", err, test.wantErr)
+			}
+			if test.wantErr != nil {
+				return
+			}
+			if ttl := time.Until(result.Session.ExpiresAt); ttl > impersonationMaxAge {
+				t.Errorf("session lives %v, want at most %v", ttl, impersonationMaxAge)
+			}
+			data, err := manager.GetSession(t.Context(), result.Token)
+			if err != nil {
+				t.Fatalf("GetSession() error = %v", err)
+			}
+			if data.User.ID != test.targetUserID || !data.Impersonated() || data.Impersonation.ActorUserID != "admin" || data.Impersonation.StartedAt.IsZero() {
+				t.Errorf("GetSession() = user %s, impersonation %+v, want %s impersonated by admin", data.User.ID, data.Impersonation, test.targetUserID)
+			}
+		})
+	}
+}
+
+// Requirement: Impersonation survives a round trip through a stateless token.
+func TestSessionManager_Impersonate_Stateless(t *testing.T) {
+	// Arrange
+	storage := NewFakeStorageProvider()
+	_ = storage.CreateUser(t.Context(), &core.User{ID: "admin", Email: "admin@example.com"})
+	_ = storage.CreateUser(t.Context(), &core.User{ID: "u1", Email: "user@example.com"})
+	manager := newStatelessSessionManager(t, storage, 24*time.Hour)
+	actor := &core.SessionData{User: &core.User{ID: "admin"}}
+
+	// Act
+	result, err := manager.Impersonate(t.Context(), actor, "u1", "127.0.0.1", "test-agent")
+
+	// Assert
+	if err != nil {
+		t.Fatalf("Impersonate() error = %v", err)
+	}
+	data, err := manager.GetSession(t.Context(), result.Token)
+	if err != nil {
+		t.Fatalf("GetSession() error = %v", err)
+	}
+	if !data.Impersonated() || data.Impersonation.ActorUserID != "admin" {
+		t.Errorf("Impersonation = %+v, want actor admin", data.Impersonation)
+	}
+}
@@ -0,0 +1,59 @@
+package services
+
+import (
+	"github.com/lborres/kuta/core"
+	"github.com/lborres/kuta/pkg/mailtemplate"
+)
+
+// emailSender renders emails and hands them to the configured Mailer
+type emailSender struct {
+	mailer   core.Mailer
+	renderer core.EmailRenderer
+	appName  string
+}
+
+// sendEmail renders messageType for user and sends it. It is a no-op when no
+// Mailer is configured.
+func (sm *SessionManager) sendEmail(messageType string, user *core.User, data core.EmailData) error {
+	if sm.email == nil {
+		return nil
+	}
+
+	message, err := sm.renderEmail(messageType, user, data)
+	if err != nil {
+		return err
+	}
+	return sm.email.mailer.SendEmail(message)
+}
+
+// renderEmail renders messageType for user, addressed to them. Callers check
+// a Mailer is configured.
+func (sm *SessionManager) renderEmail(messageType string, user *core.User, data core.EmailData) (*core.EmailMessage, error) {
+	data.User = user
+	if data.AppName == "" {
+		data.AppName = sm.email.appName
+	}
+	message, err := sm.email.renderer.RenderEmail(messageType, data)
+	if err != nil {
+		return nil, err
+	}
+	message.To = user.Email
+	return message, nil
+}
+
+// sendSecurityAlert emails user about a security anomaly. Delivery is best
+// effort: the anomaly event has already been emitted.
+func (sm *SessionManager) sendSecurityAlert(user *core.User, ipAddress, userAgent, reason string) {
+	_ = sm.sendEmail(core.EmailSecurityAlert, user, core.EmailData{
+		Details: map[string]string{
+			"ip_address": ipAddress,
+			"user_agent": userAgent,
+			"reason":     reason,
+		},
+	})
+}
+
+// defaultEmailRenderer is used when WithMailer is given no renderer
+func defaultEmailRenderer() core.EmailRenderer {
+	return mailtemplate.Default()
+}
@@ -0,0 +1,65 @@
+package services
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lborres/kuta/core"
+	"github.com/lborres/kuta/pkg/cache"
+	"github.com/lborres/kuta/pkg/crypto"
+)
+
+// Requirement: A sign-in velocity anomaly emails the user a security alert
+// rendered from the templates, only when a Mailer is configured.
+func TestSessionManager_SecurityAlertEmail(t *testing.T) {
+	tests := []struct {
+		name       string
+		withMailer bool
+		wantSent   int
+	}{
+		{name: "sends alert", withMailer: true, wantSent: 1},
+		{name: "no mailer", withMailer: false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// Arrange
+			mailer := &FakeMailer{}
+			passwords := &crypto.Argon2{Memory: 1024, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32}
+			opts := []Option{
+				WithSignInVelocity(cache.NewInMemoryOriginStore(), core.VelocityConfig{MaxDistinctIPs: 1}),
+			}
+			if test.withMailer {
+				opts = append(opts, WithMailer(mailer, nil, "Acme"))
+			}
+			manager := NewSessionManager(core.SessionConfig{MaxAge: time.Hour}, NewFakeStorageProvider(), nil, passwords, opts...)
+			if _, err := manager.SignUp(t.Context(), core.SignUpInput{Email: "user@example.com", Password: "CorrectPass123!"}, "", ""); err != nil {
+				t.Fatalf("SignUp error: %v", err)
+			}
+
+			// Act
+			for _, ip := range []string{"10.0.0.1", "10.0.0.2"} {
+				if _, err := manager.SignIn(t.Context(), core.SignInInput{Email: "user@example.com", Password: "CorrectPass123!"}, ip, "agent"); err != nil {
+					t.Fatalf("SignIn error: %v", err)
+				}
+			}
+
+			// Assert
+			messages := mailer.Messages()
+			if len(messages) != test.wantSent {
+				t.Fatalf("sent %d emails, want %d", len(messages), test.wantSent)
+			}
+			if test.wantSent == 0 {
+				return
+			}
+			message := messages[0]
+			if message.Type != core.EmailSecurityAlert || message.To != "user@example.com" {
+				t.Errorf("message = %s to %s, want security alert to user@example.com", message.Type, message.To)
+			}
+			if !strings.Contains(message.Subject, "Acme") || !strings.Contains(message.Text, "10.0.0.2") {
+				t.Errorf("unexpected content: subject %q, text %q", message.Subject, message.Text)
+			}
+		})
+	}
+}
@@ -0,0 +1,382 @@
+package services
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/lborres/kuta/core"
+	"github.com/lborres/kuta/pkg/crypto"
+)
+
+// oauthStateTTL is how long a user has to come back from the provider
+const oauthStateTTL = 10 * time.Minute
+
+// oauthStateCookieName is the cookie keeping the state of the OAuth flow a
+// browser started
+const oauthStateCookieName = "kuta_oauth_state"
+
+// oauthSignIn holds the configured OAuth providers and the store of
+// sign-ins in progress
+type oauthSignIn struct {
+	providers   map[string]core.OAuthProvider
+	order       []string // provider IDs in configuration order
+	callbackURL string
+	states      core.OAuthStateStore
+}
+
+func newOAuthSignIn(states core.OAuthStateStore, callbackURL string, providers []core.OAuthProvider) *oauthSignIn {
+	o := &oauthSignIn{
+		providers:   make(map[string]core.OAuthProvider, len(providers)),
+		callbackURL: strings.TrimSuffix(callbackURL, "/"),
+		states:      states,
+	}
+	for _, provider := range providers {
+		if _, dup := o.providers[provider.ID()]; !dup {
+			o.order = append(o.order, provider.ID())
+		}
+		o.providers[provider.ID()] = provider
+	}
+	return o
+}
+
+// redirectURI is the callback endpoint of providerID
+func (o *oauthSignIn) redirectURI(providerID string) string {
+	return o.callbackURL + "/callback/" + providerID
+}
+
+// Ensure SessionManager implements OAuthSignIn
+var _ core.OAuthSignIn = (*SessionManager)(nil)
+
+// OAuthEnabled reports whether any OAuth provider is configured
+func (sm *SessionManager) OAuthEnabled() bool {
+	return sm.oauth != nil && len(sm.oauth.providers) > 0
+}
+
+func (sm *SessionManager) oauthProvider(providerID string) (core.OAuthProvider, error) {
+	if sm.oauth == nil {
+		return nil, core.ErrUnknownProvider
+	}
+	provider, ok := sm.oauth.providers[providerID]
+	if !ok {
+		return nil, core.ErrUnknownProvider
+	}
+	return provider, sm.checkProviderEnabled(providerID)
+}
+
+// StartOAuth begins sign-in with providerID: the user is sent to the
+// returned URL and comes back to the callback endpoint with the state.
+func (sm *SessionManager) StartOAuth(providerID string) (*core.OAuthStart, error) {
+	return sm.startOAuth(providerID, "")
+}
+
+// startOAuth saves the state of a sign-in with providerID, or of linking it
+// to linkUserID, and returns where to send the user
+func (sm *SessionManager) startOAuth(providerID, linkUserID string) (*core.OAuthStart, error) {
+	provider, err := sm.oauthProvider(providerID)
+	if err != nil {
+		return nil, err
+	}
+
+	pair, err := crypto.GenerateHashedToken()
+	if err != nil {
+		return nil, err
+	}
+	verifier, err := crypto.GenerateCodeVerifier()
+	if err != nil {
+		return nil, err
+	}
+	if err := sm.oauth.states.SaveOAuthState(pair.Hash, &core.OAuthState{
+		ProviderID:   providerID,
+		CodeVerifier: verifier,
+		LinkUserID:   linkUserID,
+		ExpiresAt:    time.Now().Add(oauthStateTTL),
+	}); err != nil {
+		return nil, err
+	}
+
+	redirectURI := sm.oauth.redirectURI(providerID)
+	return &core.OAuthStart{
+		URL:   provider.AuthCodeURL(pair.Token, redirectURI, crypto.CodeChallengeS256(verifier)),
+		State: pair.Token,
+	}, nil
+}
+
+// CompleteOAuth handles the provider's callback. The user is found by their
+// provider account, or else by email, and is created on first sign-in.
+// An existing user is only linked to the provider when the provider has
+// verified the email; otherwise anyone could register the address there and
+// take over the account. The callback of StartOAuthLink links the provider
+// account to the user who started it whatever its email, then signs them in
+// like any other callback. The callback must come from the browser that was
+// given the state, which keeps it in a cookie and sends it as StateCookie.
+func (sm *SessionManager) CompleteOAuth(ctx context.Context, providerID string, callback core.OAuthCallback, ipAddress, userAgent string) (*core.SignInResult, error) {
+	record := &core.SignInRecord{ProviderID: providerID, IPAddress: ipAddress, UserAgent: userAgent}
+	result, err := sm.completeOAuth(ctx, providerID, callback, ipAddress, userAgent, record)
+	// Callbacks for unknown providers or without a sign-in we started are
+	// forged or replayed, not attempts on anyone
+	if !errors.Is(err, core.ErrUnknownProvider) && !errors.Is(err, core.ErrInvalidOAuthState) {
+		sm.recordSignIn(ctx, record, result, err)
+	}
+	return result, err
+}
+
+func (sm *SessionManager) completeOAuth(ctx context.Context, providerID string, callback core.OAuthCallback, ipAddress, userAgent string, record *core.SignInRecord) (*core.SignInResult, error) {
+	if err := sm.allowRequest("oauth", ipAddress); err != nil {
+		return nil, err
+	}
+	provider, err := sm.oauthProvider(providerID)
+	if err != nil {
+		return nil, err
+	}
+	if callback.State == "" {
+		return nil, core.ErrInvalidOAuthState
+	}
+	// Checked before the state is taken, so a callback forwarded to another
+	// browser doesn't use up the sign-in of the one that started it
+	if subtle.ConstantTimeCompare([]byte(callback.StateCookie), []byte(callback.State)) != 1 {
+		return nil, core.ErrInvalidOAuthState
+	}
+	// The state is consumed whatever happens next, so a callback can't be
+	// replayed
+	state, err := sm.oauth.states.TakeOAuthState(crypto.HashToken(callback.State))
+	if err != nil {
+		return nil, err
+	}
+	if state.ProviderID != providerID {
+		return nil, core.ErrInvalidOAuthState
+	}
+	if callback.Error != "" {
+		return nil, fmt.Errorf("%w: %s", core.ErrOAuthFailed, callback.Error)
+	}
+
+	callback.RedirectURI = sm.oauth.redirectURI(providerID)
+	callback.CodeVerifier = state.CodeVerifier
+	identity, err := provider.Exchange(callback)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", core.ErrOAuthFailed, err)
+	}
+	if identity.AccountID == "" {
+		return nil, fmt.Errorf("%w: provider returned no account ID", core.ErrOAuthFailed)
+	}
+	record.Email = identity.Email
+
+	var match oauthMatch
+	if state.LinkUserID != "" {
+		match, err = sm.findLinkUser(ctx, state.LinkUserID, providerID, identity)
+	} else {
+		match, err = sm.findOAuthUser(ctx, providerID, identity)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if match.user != nil {
+		record.UserID = match.user.ID
+		if err := checkUserStatus(match.user); err != nil {
+			return nil, err
+		}
+	}
+	if err := sm.runAfterSignIn(core.AfterSignInInput{
+		User:       match.user,
+		ProviderID: providerID,
+		Identity:   identity,
+		IPAddress:  ipAddress,
+		UserAgent:  userAgent,
+	}); err != nil {
+		return nil, err
+	}
+
+	user, err := sm.saveOAuthUser(ctx, providerID, identity, match, ipAddress, userAgent)
+	if err != nil {
+		return nil, err
+	}
+	// A new user may be waiting for approval
+	if err := checkUserStatus(user); err != nil {
+		return nil, err
+	}
+
+	record.UserID = user.ID
+	return sm.startSignIn(ctx, user, providerID, ipAddress, userAgent, "")
+}
+
+// oauthMatch is what an OAuth identity resolved to: the user and their
+// account with the provider, either of which may not exist yet
+type oauthMatch struct {
+	user    *core.User
+	account *core.Account
+}
+
+// findOAuthUser looks up the user identity belongs to without changing
+// anything, so AfterSignIn hooks can refuse before users are created or
+// linked
+func (sm *SessionManager) findOAuthUser(ctx context.Context, providerID string, identity *core.OAuthIdentity) (oauthMatch, error) {
+	account, err := sm.storage.GetAccountByProvider(ctx, providerID, identity.AccountID)
+	if err == nil {
+		user, err := sm.storage.GetUserByID(ctx, account.UserID)
+		if err != nil {
+			return oauthMatch{}, err
+		}
+		return oauthMatch{user: user, account: account}, nil
+	}
+	if !errors.Is(err, core.ErrUserNotFound) {
+		return oauthMatch{}, err
+	}
+
+	if identity.Email == "" {
+		return oauthMatch{}, core.ErrEmailRequired
+	}
+
+	user, err := sm.storage.GetUserByEmail(ctx, identity.Email)
+	switch {
+	case err == nil:
+		if !identity.EmailVerified {
+			return oauthMatch{}, core.ErrUserExists
+		}
+		return oauthMatch{user: user}, nil
+	case errors.Is(err, core.ErrUserNotFound):
+		return oauthMatch{}, nil
+	default:
+		return oauthMatch{}, err
+	}
+}
+
+// findLinkUser resolves the callback of StartOAuthLink to the user linking
+// the provider. The provider account may already be theirs, but not another
+// user's.
+func (sm *SessionManager) findLinkUser(ctx context.Context, userID, providerID string, identity *core.OAuthIdentity) (oauthMatch, error) {
+	user, err := sm.storage.GetUserByID(ctx, userID)
+	if err != nil {
+		return oauthMatch{}, err
+	}
+
+	account, err := sm.storage.GetAccountByProvider(ctx, providerID, identity.AccountID)
+	switch {
+	case err == nil:
+		if account.UserID != user.ID {
+			return oauthMatch{}, core.ErrAccountLinked
+		}
+		return oauthMatch{user: user, account: account}, nil
+	case errors.Is(err, core.ErrUserNotFound):
+		return oauthMatch{user: user}, nil
+	default:
+		return oauthMatch{}, err
+	}
+}
+
+// saveOAuthUser stores the outcome of the sign-in: fresh tokens for a known
+// account, a new account linking an existing user, or a new user
+func (sm *SessionManager) saveOAuthUser(ctx context.Context, providerID string, identity *core.OAuthIdentity, match oauthMatch, ipAddress, userAgent string) (*core.User, error) {
+	switch {
+	case match.account != nil:
+		applyOAuthTokens(match.account, identity)
+		match.account.UpdatedAt = time.Now()
+		if err := sm.storage.UpdateAccount(ctx, match.account); err != nil {
+			return nil, err
+		}
+		return match.user, nil
+	case match.user != nil:
+		if _, err := sm.createOAuthAccount(ctx, match.user.ID, providerID, identity); err != nil {
+			return nil, err
+		}
+		sm.emit(core.Event{
+			Type:      core.EventAccountLinked,
+			UserID:    match.user.ID,
+			Email:     match.user.Email,
+			IPAddress: ipAddress,
+			UserAgent: userAgent,
+			Metadata:  map[string]any{"providerId": providerID},
+		})
+		return match.user, nil
+	default:
+		return sm.createOAuthUser(ctx, providerID, identity, ipAddress, userAgent)
+	}
+}
+
+func (sm *SessionManager) createOAuthUser(ctx context.Context, providerID string, identity *core.OAuthIdentity, ipAddress, userAgent string) (*core.User, error) {
+	userID, err := sm.ids.users.generate()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	user := &core.User{
+		ID:            userID,
+		Email:         identity.Email,
+		EmailVerified: identity.EmailVerified,
+		Name:          identity.Name,
+		CreatedAt:     now,
+		UpdatedAt:     now,
+	}
+	if identity.Image != "" {
+		image := identity.Image
+		user.Image = &image
+	}
+	if sm.requiresApproval() {
+		user.Status = core.UserStatusPending
+	}
+
+	if err := sm.storage.CreateUserIfNotExists(ctx, user); err != nil {
+		return nil, err
+	}
+	if _, err := sm.createOAuthAccount(ctx, userID, providerID, identity); err != nil {
+		_ = sm.storage.DeleteUser(ctx, userID)
+		return nil, err
+	}
+
+	sm.emit(core.Event{
+		Type:      core.EventUserSignedUp,
+		UserID:    userID,
+		Email:     user.Email,
+		IPAddress: ipAddress,
+		UserAgent: userAgent,
+		Metadata:  map[string]any{"providerId": providerID},
+	})
+	if sm.requiresApproval() {
+		sm.emitPendingApproval(user, ipAddress, userAgent)
+	}
+
+	return user, nil
+}
+
+func (sm *SessionManager) createOAuthAccount(ctx context.Context, userID, providerID string, identity *core.OAuthIdentity) (*core.Account, error) {
+	accountID, err := sm.ids.accounts.generate()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	account := &core.Account{
+		ID:         accountID,
+		UserID:     userID,
+		ProviderID: providerID,
+		AccountID:  identity.AccountID,
+		CreatedAt:  now,
+		UpdatedAt:  now,
+	}
+	applyOAuthTokens(account, identity)
+
+	if err := sm.storage.CreateAccount(ctx, account); err != nil {
+		return nil, err
+	}
+	return account, nil
+}
+
+// applyOAuthTokens copies the tokens and profile of a sign-in to account,
+// keeping the stored refresh token and profile when the provider sent none
+func applyOAuthTokens(account *core.Account, identity *core.OAuthIdentity) {
+	if identity.AccessToken != "" {
+		accessToken := identity.AccessToken
+		account.AccessToken = &accessToken
+		account.ExpiresAt = identity.ExpiresAt
+	}
+	if identity.RefreshToken != "" {
+		refreshToken := identity.RefreshToken
+		account.RefreshToken = &refreshToken
+	}
+	if identity.Profile != nil {
+		account.ProfileData = identity.Profile
+	}
+}
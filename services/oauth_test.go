@@ -0,0 +1,251 @@
+package services
+
+import (
+	"errors"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/lborres/kuta/core"
+	"github.com/lborres/kuta/pkg/cache"
+	"github.com/lborres/kuta/pkg/crypto"
+)
+
+// fakeOAuthProvider returns identity for any code and records the PKCE
+// challenge and callback
+type fakeOAuthProvider struct {
+	identity      core.OAuthIdentity
+	err           error
+	codeChallenge string
+	callback      core.OAuthCallback
+}
+
+func (p *fakeOAuthProvider) ID() string { return "test" }
+
+func (p *fakeOAuthProvider) AuthCodeURL(state, redirectURI, codeChallenge string) string {
+	p.codeChallenge = codeChallenge
+	return "https://provider.example/auth?" + url.Values{"state": {state}, "redirect_uri": {redirectURI}}.Encode()
+}
+
+func (p *fakeOAuthProvider) Exchange(callback core.OAuthCallback) (*core.OAuthIdentity, error) {
+	p.callback = callback
+	if p.err != nil {
+		return nil, p.err
+	}
+	identity := p.identity
+	return &identity, nil
+}
+
+// revokingOAuthProvider is a fakeOAuthProvider that supports token
+// revocation and records the tokens revoked
+type revokingOAuthProvider struct {
+	fakeOAuthProvider
+	revokeErr error
+	revoked   []string
+}
+
+func (p *revokingOAuthProvider) RevokeToken(token, tokenTypeHint string) error {
+	if p.revokeErr != nil {
+		return p.revokeErr
+	}
+	p.revoked = append(p.revoked, tokenTypeHint+":"+token)
+	return nil
+}
+
+// Requirement: An OAuth callback with the state from StartOAuth signs the
+// provider's user in, creating the user on first sign-in, linking an
+// existing user only when the provider verified the email, and never
+// clearing a name the provider sends just once.
+func TestSessionManager_CompleteOAuth(t *testing.T) {
+	existing := &core.User{ID: "existing", Email: "taken@example.com", Name: "Existing"}
+
+	tests := []struct {
+		name         string
+		identity     core.OAuthIdentity
+		providerErr  error
+		signInBefore bool // sign in once with Name set, before the tested sign-in
+		wantErr      error
+		wantUserID   string
+		wantName     string
+	}{
+		{
+			name:     "creates user on first sign-in",
+			identity: core.OAuthIdentity{AccountID: "sub-1", Email: "new@example.com", EmailVerified: true, Name: "New User"},
+			wantName: "New User",
+		},
+		{
+			name:         "keeps name the provider only sent once",
+			identity:     core.OAuthIdentity{AccountID: "sub-1", Email: "new@example.com", EmailVerified: true},
+			signInBefore: true,
+			wantName:     "First Name",
+		},
+		{
+			name:       "links existing user with verified email",
+			identity:   core.OAuthIdentity{AccountID: "sub-2", Email: existing.Email, EmailVerified: true},
+			wantUserID: existing.ID,
+			wantName:   existing.Name,
+		},
+		{
+			name:     "refuses unverified email of existing user",
+			identity: core.OAuthIdentity{AccountID: "sub-2", Email: existing.Email},
+			wantErr:  core.ErrUserExists,
+		},
+		{
+			name:     "requires an email for new users",
+			identity: core.OAuthIdentity{AccountID: "sub-3"},
+			wantErr:  core.ErrEmailRequired,
+		},
+		{
+			name:        "provider failure",
+			providerErr: errors.New("invalid_grant"),
+			wantErr:     core.ErrOAuthFailed,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// Arrange
+			storage := NewFakeStorageProvider()
+			_ = storage.CreateUser(t.Context(), existing)
+			provider := &fakeOAuthProvider{identity: test.identity, err: test.providerErr}
+			passwords := &crypto.Argon2{Memory: 1024, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32}
+			manager := NewSessionManager(core.SessionConfig{MaxAge: time.Hour}, storage, nil, passwords,
+				WithOAuth(cache.NewInMemoryOAuthStateStore(), "https://app.example/api/auth/", provider))
+
+			signIn := func() (*core.SignInResult, error) {
+				start, err := manager.StartOAuth("test")
+				if err != nil {
+					t.Fatalf("StartOAuth() error = %v", err)
+				}
+				return manager.CompleteOAuth(t.Context(), "test", core.OAuthCallback{Code: "code", State: start.State, StateCookie: start.State}, "", "")
+			}
+			if test.signInBefore {
+				provider.identity.Name = "First Name"
+				if _, err := signIn(); err != nil {
+					t.Fatalf("first sign-in error = %v", err)
+				}
+				provider.identity.Name = test.identity.Name
+			}
+
+			// Act
+			result, err := signIn()
+
+			// Assert
+			if test.wantErr != nil {
+				if !errors.Is(err, test.wantErr) {
+					t.Fatalf("CompleteOAuth() error = %v, want %v", err, test.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("CompleteOAuth() error = %v", err)
+			}
+			if result.Session == nil || result.Token == "" || result.RefreshToken == "" {
+				t.Fatalf("CompleteOAuth() = %+v, want a session", result)
+			}
+			if test.wantUserID != "" && result.User.ID != test.wantUserID {
+				t.Errorf("user = %s, want %s", result.User.ID, test.wantUserID)
+			}
+			if result.User.Name != test.wantName {
+				t.Errorf("name = %q, want %q", result.User.Name, test.wantName)
+			}
+			if provider.callback.RedirectURI != "https://app.example/api/auth/callback/test" {
+				t.Errorf("redirect URI = %q", provider.callback.RedirectURI)
+			}
+			account, err := storage.GetAccountByProvider(t.Context(), "test", test.identity.AccountID)
+			if err != nil || account.UserID != result.User.ID {
+				t.Errorf("account = %+v, %v, want one for user %s", account, err, result.User.ID)
+			}
+		})
+	}
+}
+
+// Requirement: A callback is only accepted with a state issued by
+// StartOAuth for the same provider, from the browser holding it in its state
+// cookie, and each state works once. A callback from another browser doesn't
+// use up the state.
+func TestSessionManager_CompleteOAuth_State(t *testing.T) {
+	issued := func(issued string) string { return issued }
+
+	tests := []struct {
+		name       string
+		providerID string
+		state      func(issued string) string
+		cookie     func(issued string) string
+		replay     bool
+		wantErr    error
+		wantKept   bool // the issued state still signs in afterwards
+	}{
+		{name: "unknown state", providerID: "test", state: func(string) string { return "forged" }, cookie: func(string) string { return "forged" }, wantErr: core.ErrInvalidOAuthState, wantKept: true},
+		{name: "missing state", providerID: "test", state: func(string) string { return "" }, cookie: func(string) string { return "" }, wantErr: core.ErrInvalidOAuthState, wantKept: true},
+		{name: "replayed state", providerID: "test", state: issued, cookie: issued, replay: true, wantErr: core.ErrInvalidOAuthState},
+		{name: "unknown provider", providerID: "other", state: issued, cookie: issued, wantErr: core.ErrUnknownProvider, wantKept: true},
+		{name: "missing cookie", providerID: "test", state: issued, cookie: func(string) string { return "" }, wantErr: core.ErrInvalidOAuthState, wantKept: true},
+		{name: "cookie of another flow", providerID: "test", state: issued, cookie: func(string) string { return "attacker-state" }, wantErr: core.ErrInvalidOAuthState, wantKept: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// Arrange
+			provider := &fakeOAuthProvider{identity: core.OAuthIdentity{AccountID: "sub", Email: "user@example.com", EmailVerified: true}}
+			manager := NewSessionManager(core.SessionConfig{MaxAge: time.Hour}, NewFakeStorageProvider(), nil, crypto.NewArgon2(),
+				WithOAuth(cache.NewInMemoryOAuthStateStore(), "https://app.example/api/auth", provider))
+			start, err := manager.StartOAuth("test")
+			if err != nil {
+				t.Fatalf("StartOAuth() error = %v", err)
+			}
+			callback := core.OAuthCallback{Code: "code", State: test.state(start.State), StateCookie: test.cookie(start.State)}
+			if test.replay {
+				if _, err := manager.CompleteOAuth(t.Context(), "test", callback, "", ""); err != nil {
+					t.Fatalf("first CompleteOAuth() error = %v", err)
+				}
+			}
+
+			// Act
+			_, err = manager.CompleteOAuth(t.Context(), test.providerID, callback, "", "")
+
+			// Assert
+			if !errors.Is(err, test.wantErr) {
+				t.Errorf("CompleteOAuth() error = %v, want %v", err, test.wantErr)
+			}
+			_, err = manager.CompleteOAuth(t.Context(), "test", core.OAuthCallback{Code: "code", State: start.State, StateCookie: start.State}, "", "")
+			if kept := err == nil; kept != test.wantKept {
+				t.Errorf("CompleteOAuth() with the issued state afterwards error = %v, want kept = %v", err, test.wantKept)
+			}
+		})
+	}
+}
+
+// Requirement: Each OAuth sign-in gets its own PKCE verifier, whose S256
+// challenge goes to the provider with the authorization request and which
+// comes back to the provider for the token request.
+func TestSessionManager_CompleteOAuth_PKCE(t *testing.T) {
+	// Arrange
+	provider := &fakeOAuthProvider{identity: core.OAuthIdentity{AccountID: "sub", Email: "user@example.com", EmailVerified: true}}
+	passwords := &crypto.Argon2{Memory: 1024, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32}
+	manager := NewSessionManager(core.SessionConfig{MaxAge: time.Hour}, NewFakeStorageProvider(), nil, passwords,
+		WithOAuth(cache.NewInMemoryOAuthStateStore(), "https://app.example/api/auth", provider))
+	if _, err := manager.StartOAuth("test"); err != nil {
+		t.Fatalf("StartOAuth() error = %v", err)
+	}
+	otherChallenge := provider.codeChallenge
+	start, err := manager.StartOAuth("test")
+	if err != nil {
+		t.Fatalf("StartOAuth() error = %v", err)
+	}
+
+	// Act
+	_, err = manager.CompleteOAuth(t.Context(), "test", core.OAuthCallback{Code: "code", State: start.State, StateCookie: start.State}, "", "")
+
+	// Assert
+	if err != nil {
+		t.Fatalf("CompleteOAuth() error = %v", err)
+	}
+	verifier := provider.callback.CodeVerifier
+	if verifier == "" || crypto.CodeChallengeS256(verifier) != provider.codeChallenge {
+		t.Errorf("CodeVerifier %q doesn't match challenge %q", verifier, provider.codeChallenge)
+	}
+	if otherChallenge == provider.codeChallenge {
+		t.Error("sign-ins share a PKCE challenge")
+	}
+}
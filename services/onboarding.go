@@ -0,0 +1,23 @@
+package services
+
+import "github.com/lborres/kuta/core"
+
+// withOnboarding fills in the onboarding fields of a sign-up result. They
+// depend on configuration only, never on whether the email was already
+// registered, so they are safe under enumeration protection.
+func (sm *SessionManager) withOnboarding(result *core.SignUpResult) *core.SignUpResult {
+	result.RequiresEmailVerification = sm.onboarding.RequireEmailVerification
+	result.RequiresApproval = sm.onboarding.RequireApproval
+
+	switch {
+	case sm.onboarding.RequireEmailVerification:
+		result.NextStep = core.NextStepVerifyEmail
+	case sm.onboarding.RequireApproval:
+		result.NextStep = core.NextStepAwaitApproval
+	case result.Session == nil:
+		result.NextStep = core.NextStepSignIn
+	case sm.onboarding.RequireTwoFactorSetup:
+		result.NextStep = core.NextStepSetupTwoFactor
+	}
+	return result
+}
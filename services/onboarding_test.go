@@ -0,0 +1,83 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/lborres/kuta/core"
+	"github.com/lborres/kuta/pkg/crypto"
+)
+
+// Requirement: Sign-up responses tell the client which onboarding step comes
+// next, and duplicate sign-ups under enumeration protection look the same as
+// new ones.
+func TestSessionManager_SignUpOnboarding(t *testing.T) {
+	tests := []struct {
+		name             string
+		onboarding       core.OnboardingConfig
+		enumeration      bool
+		duplicate        bool
+		wantVerification bool
+		wantNextStep     string
+	}{
+		{
+			name:         "no onboarding",
+			wantNextStep: "",
+		},
+		{
+			name:             "email verification required",
+			onboarding:       core.OnboardingConfig{RequireEmailVerification: true, RequireTwoFactorSetup: true},
+			wantVerification: true,
+			wantNextStep:     core.NextStepVerifyEmail,
+		},
+		{
+			name:         "two factor setup required",
+			onboarding:   core.OnboardingConfig{RequireTwoFactorSetup: true},
+			wantNextStep: core.NextStepSetupTwoFactor,
+		},
+		{
+			name:         "no session issued under enumeration protection",
+			onboarding:   core.OnboardingConfig{RequireTwoFactorSetup: true},
+			enumeration:  true,
+			wantNextStep: core.NextStepSignIn,
+		},
+		{
+			name:             "duplicate email under enumeration protection",
+			onboarding:       core.OnboardingConfig{RequireEmailVerification: true},
+			enumeration:      true,
+			duplicate:        true,
+			wantVerification: true,
+			wantNextStep:     core.NextStepVerifyEmail,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// Arrange
+			storage := NewFakeStorageProvider()
+			passwords := &crypto.Argon2{Memory: 1024, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32}
+			manager := NewSessionManager(core.SessionConfig{MaxAge: time.Hour}, storage, nil, passwords,
+				WithOnboarding(test.onboarding), WithEnumerationProtection(test.enumeration))
+			input := core.SignUpInput{Email: "user@example.com", Password: "CorrectPass123!"}
+			if test.duplicate {
+				if _, err := manager.SignUp(t.Context(), input, "", ""); err != nil {
+					t.Fatalf("SignUp error: %v", err)
+				}
+			}
+
+			// Act
+			result, err := manager.SignUp(t.Context(), input, "", "")
+
+			// Assert
+			if err != nil {
+				t.Fatalf("SignUp error: %v", err)
+			}
+			if result.RequiresEmailVerification != test.wantVerification {
+				t.Errorf("RequiresEmailVerification = %v, want %v", result.RequiresEmailVerification, test.wantVerification)
+			}
+			if result.NextStep != test.wantNextStep {
+				t.Errorf("NextStep = %q, want %q", result.NextStep, test.wantNextStep)
+			}
+		})
+	}
+}
@@ -0,0 +1,427 @@
+package services
+
+import (
+	"time"
+
+	"github.com/lborres/kuta/core"
+	"github.com/lborres/kuta/pkg/crypto"
+)
+
+const (
+	defaultDeletedUserRetention = 30 * 24 * time.Hour
+)
+
+// Option configures optional SessionManager behavior.
+type Option func(*SessionManager)
+
+// WithDeletedUserRetention sets how long soft-deleted users can be restored
+// before they become eligible for purging.
+func WithDeletedUserRetention(d time.Duration) Option {
+	return func(sm *SessionManager) {
+		if d > 0 {
+			sm.deletedUserRetention = d
+		}
+	}
+}
+
+// WithPasswordLength bounds the length of new passwords, in characters, at
+// sign-up and password change. Zero leaves that side unbounded. Existing
+// passwords keep working at sign-in.
+func WithPasswordLength(minLength, maxLength int) Option {
+	return func(sm *SessionManager) {
+		sm.passwordRules = passwordRules{minLength: max(minLength, 0), maxLength: max(maxLength, 0)}
+	}
+}
+
+// WithEventHandler registers a handler that receives authentication events.
+func WithEventHandler(h core.EventHandler) Option {
+	return func(sm *SessionManager) {
+		sm.events = h
+	}
+}
+
+// WithEnumerationProtection makes flows that could reveal whether an email is
+// registered (sign-up, password reset, verification resend) respond identically
+// in both cases. The real outcome is only reported through events.
+func WithEnumerationProtection(enabled bool) Option {
+	return func(sm *SessionManager) {
+		sm.preventEnumeration = enabled
+	}
+}
+
+// WithAdminAuthorizer enables admin operations for users accepted by fn.
+func WithAdminAuthorizer(fn core.AdminAuthorizer) Option {
+	return func(sm *SessionManager) {
+		sm.adminAuthorizer = fn
+	}
+}
+
+// WithCacheConsistencyChecks re-reads a sampled fraction (0 to 1) of cache
+// hits from storage and repairs entries that disagree with it.
+func WithCacheConsistencyChecks(sampleRate float64) Option {
+	return func(sm *SessionManager) {
+		sm.consistencySampleRate = min(max(sampleRate, 0), 1)
+	}
+}
+
+// WithRevocationBus broadcasts session revocations over bus and evicts
+// sessions revoked by other instances from the local cache.
+func WithRevocationBus(bus core.RevocationBus) Option {
+	return func(sm *SessionManager) {
+		sm.revocations = bus
+	}
+}
+
+// WithStatelessSessions stores the whole session in a token sealed by sealer
+// instead of the session table. Sessions can't be revoked server-side in this
+// mode: sign-out only discards the client's copy and tokens stay valid until
+// they expire, unless their user is deleted, which every verification checks.
+func WithStatelessSessions(sealer *crypto.Sealer) Option {
+	return func(sm *SessionManager) {
+		sm.sealer = sealer
+	}
+}
+
+// WithLoginThrottle delays and eventually rejects repeated failed sign-ins
+// for the same email + IP address pair, counting failures in store.
+func WithLoginThrottle(store core.AttemptStore, config core.ThrottleConfig) Option {
+	return func(sm *SessionManager) {
+		if store != nil {
+			sm.throttle = newLoginThrottle(store, config)
+		}
+	}
+}
+
+// WithIdempotency honors Idempotency-Key on sign-up, sign-in and refresh:
+// retries with the same key and body get the first response back, sealed by
+// sealer while store keeps it for ttl (24 hours when zero).
+func WithIdempotency(store core.IdempotencyStore, sealer *crypto.Sealer, ttl time.Duration) Option {
+	return func(sm *SessionManager) {
+		if store == nil || sealer == nil {
+			return
+		}
+		if ttl <= 0 {
+			ttl = defaultIdempotencyTTL
+		}
+		sm.idempotency = &idempotency{store: store, sealer: sealer, ttl: ttl}
+	}
+}
+
+// WithPasswordChangeRevocation sets whether ChangePassword revokes the
+// user's other sessions. Enabled by default.
+func WithPasswordChangeRevocation(enabled bool) Option {
+	return func(sm *SessionManager) {
+		sm.keepSessionsOnPasswordChange = !enabled
+	}
+}
+
+// WithRateLimiter caps sign-up and sign-in requests per client IP address
+func WithRateLimiter(limiter core.RateLimiter) Option {
+	return func(sm *SessionManager) {
+		sm.rateLimiter = limiter
+	}
+}
+
+// WithSignInVelocity emits security.anomaly events when a user signs in from
+// more distinct IPs or networks within the window than the config allows.
+func WithSignInVelocity(store core.OriginStore, config core.VelocityConfig) Option {
+	return func(sm *SessionManager) {
+		if store != nil {
+			sm.velocity = newSignInVelocity(store, config)
+		}
+	}
+}
+
+// WithSignInLog records every sign-in attempt in log, for GetRecentAttempts
+// and ListActivity, and purges attempts older than retention during cleanup. Zero retention
+// defaults to 90 days.
+func WithSignInLog(log core.SignInLog, retention time.Duration) Option {
+	return func(sm *SessionManager) {
+		if log == nil {
+			return
+		}
+		if retention <= 0 {
+			retention = defaultSignInLogRetention
+		}
+		sm.signInLog = &signInLog{log: log, retention: retention}
+	}
+}
+
+// WithRoles keeps user roles and role permissions in store, caching lookups
+// for cacheTTL. Zero cacheTTL defaults to 1 minute.
+func WithRoles(store core.RoleStorage, cacheTTL time.Duration) Option {
+	return func(sm *SessionManager) {
+		if store == nil {
+			return
+		}
+		if cacheTTL <= 0 {
+			cacheTTL = defaultRoleCacheTTL
+		}
+		sm.roles = &roles{store: store, cache: newRoleCache(cacheTTL)}
+	}
+}
+
+// WithUserCache caches user lookups by ID for ttl, since every session
+// lookup loads the user. Users changed through another instance may be
+// served as they were for up to ttl. Zero leaves the cache off.
+func WithUserCache(ttl time.Duration) Option {
+	return func(sm *SessionManager) {
+		if ttl > 0 {
+			sm.storage = &cachedUserStorage{StorageProvider: sm.storage, cache: newUserCache(ttl)}
+		}
+	}
+}
+
+// WithDeferredSessions issues sessions into queue and leaves writing them
+// to storage to RunSessionPersistence, every interval (1 second when zero),
+// for sign-in rates the storage can't keep up with. Sessions issued since
+// the last run are lost with the queue, and other instances only find them
+// through a shared cache. A nil queue leaves sessions written at once.
+func WithDeferredSessions(queue core.SessionQueue, interval time.Duration) Option {
+	return func(sm *SessionManager) {
+		if queue == nil {
+			return
+		}
+		if interval <= 0 {
+			interval = defaultSessionPersistInterval
+		}
+		sm.deferred = &deferredSessionStorage{StorageProvider: sm.storage, buffer: newSessionBuffer(queue, interval)}
+		sm.storage = sm.deferred
+	}
+}
+
+// WithOrganizations keeps organization memberships in store, enabling
+// sessions to switch their active organization
+func WithOrganizations(store core.OrganizationStorage) Option {
+	return func(sm *SessionManager) {
+		sm.organizations = store
+	}
+}
+
+// WithOrganizationInvites links invite emails to acceptURL, with the invite
+// token as the "token" query parameter; without one the token is sent as a
+// code. Invites expire after ttl, which defaults to 7 days when zero.
+func WithOrganizationInvites(acceptURL string, ttl time.Duration) Option {
+	return func(sm *SessionManager) {
+		if ttl <= 0 {
+			ttl = defaultOrganizationInviteTTL
+		}
+		sm.organizationInvites = organizationInvites{acceptURL: acceptURL, ttl: ttl}
+	}
+}
+
+// WithAccessTokens keeps personal access tokens in store. When scopes are
+// given, tokens can only be granted those; otherwise any scope is accepted.
+func WithAccessTokens(store core.AccessTokenStorage, scopes ...string) Option {
+	return func(sm *SessionManager) {
+		if store == nil {
+			return
+		}
+		var allowed []string
+		if len(scopes) > 0 {
+			allowed = normalizeNames(scopes)
+		}
+		sm.accessTokens = &accessTokens{store: store, scopes: allowed}
+	}
+}
+
+// WithEntitlements resolves the entitlements of every session with resolver
+// when it is issued, refreshed or switches organization
+func WithEntitlements(resolver core.EntitlementResolver) Option {
+	return func(sm *SessionManager) {
+		sm.entitlements = resolver
+	}
+}
+
+// WithRevocationGrace makes revocations (RevokeSession, RevokeUserSessions,
+// their Destroy* counterparts and admin revocations) drain sessions instead
+// of deleting them: for grace they still work for idempotent reads (see
+// core.Session.Draining), then expire. Sign-out and user deletion always end
+// sessions at once.
+func WithRevocationGrace(grace time.Duration) Option {
+	return func(sm *SessionManager) {
+		sm.revocationGrace = grace
+	}
+}
+
+// WithPasswordUpgrade enables rehash-on-login: successful sign-ins replace
+// password hashes the policy considers outdated.
+func WithPasswordUpgrade(policy crypto.PasswordUpgradePolicy) Option {
+	return func(sm *SessionManager) {
+		sm.passwordUpgrade = newPasswordUpgrader(policy, sm.passwords)
+	}
+}
+
+// WithSignInChallenges makes sign-ins pass the challengers that apply, in
+// order, after the password check. Pending sign-ins live in store for ttl
+// per step (5 minutes when zero). Built-in challengers such as WithTwoFactor
+// use store too, so challengers may be empty.
+func WithSignInChallenges(store core.PendingSignInStore, ttl time.Duration, challengers ...core.SignInChallenger) Option {
+	return func(sm *SessionManager) {
+		if store != nil {
+			sm.challenges = newSignInChallenges(store, ttl, challengers)
+		}
+	}
+}
+
+// WithSecurityPosture adds the user's SecurityPosture to sign-in and session
+// responses, with upgrade prompts chosen by policy (DefaultUpgradePrompts
+// when nil). It costs two storage reads per response.
+func WithSecurityPosture(policy core.UpgradePromptPolicy) Option {
+	return func(sm *SessionManager) {
+		if policy == nil {
+			policy = core.DefaultUpgradePrompts
+		}
+		sm.upgradePrompts = policy
+	}
+}
+
+// WithOnboarding sets the steps reported to new users in SignUpResult
+func WithOnboarding(config core.OnboardingConfig) Option {
+	return func(sm *SessionManager) {
+		sm.onboarding = config
+	}
+}
+
+// WithIDGenerators replaces the default ID generators for users, sessions
+// and accounts.
+func WithIDGenerators(ids *IDGenerators) Option {
+	return func(sm *SessionManager) {
+		if ids != nil {
+			sm.ids = ids
+		}
+	}
+}
+
+// WithAccountTokenEncryption stores account provider tokens (OAuth access and
+// refresh tokens) envelope-encrypted with envelope.
+func WithAccountTokenEncryption(envelope *crypto.Envelope) Option {
+	return func(sm *SessionManager) {
+		if envelope != nil {
+			sm.storage = &encryptedAccountStorage{StorageProvider: sm.storage, envelope: envelope}
+		}
+	}
+}
+
+// WithCleanup sets how often RunCleanup purges stale data, and how long
+// consumed verification tokens are kept so reuse can still be detected. Zero
+// values default to 1 hour and 24 hours.
+func WithCleanup(interval, consumedTokenRetention time.Duration) Option {
+	return func(sm *SessionManager) {
+		sm.cleanup = newCleanupWorker(interval, consumedTokenRetention)
+	}
+}
+
+// WithProviderTokenRefresh enables refreshing OAuth provider access tokens
+// that expire within lead, using the refresher registered for each account's
+// provider. Zero interval and lead default to 1 and 5 minutes.
+func WithProviderTokenRefresh(refreshers map[string]core.TokenRefresher, interval, lead time.Duration) Option {
+	return func(sm *SessionManager) {
+		if len(refreshers) == 0 {
+			return
+		}
+		if interval <= 0 {
+			interval = defaultProviderRefreshInterval
+		}
+		if lead <= 0 {
+			lead = defaultProviderRefreshLead
+		}
+		sm.providerRefresh = &providerTokenRefresh{
+			refreshers: refreshers,
+			interval:   interval,
+			lead:       lead,
+		}
+	}
+}
+
+// WithOAuth enables sign-in with OAuth providers. states keeps sign-ins
+// between the redirect and the callback. callbackBaseURL is the public URL
+// the auth endpoints are mounted under, e.g. "https://example.com/api/auth";
+// providers redirect back to its /callback/{provider} endpoint, which must be
+// registered with them.
+func WithOAuth(states core.OAuthStateStore, callbackBaseURL string, providers ...core.OAuthProvider) Option {
+	return func(sm *SessionManager) {
+		if len(providers) == 0 {
+			return
+		}
+		sm.oauth = newOAuthSignIn(states, callbackBaseURL, providers)
+	}
+}
+
+// WithAfterSignIn adds hooks that may refuse authenticated sign-ins before a
+// session is issued, e.g. core.RequireMembership. They run in order and the
+// first error stops the sign-in.
+func WithAfterSignIn(hooks ...core.AfterSignInHook) Option {
+	return func(sm *SessionManager) {
+		sm.afterSignIn = append(sm.afterSignIn, hooks...)
+	}
+}
+
+// WithDisabledProviders starts with sign-in through the given providers
+// switched off; see SetProviderEnabled
+func WithDisabledProviders(providerIDs ...string) Option {
+	return func(sm *SessionManager) {
+		for _, id := range providerIDs {
+			sm.providers.disabled[id] = true
+		}
+	}
+}
+
+// WithProviderInfo sets the display metadata of sign-in providers, replacing
+// the defaults of built-in ones and adding methods the app serves itself
+// (e.g. passkeys or magic links through plugin endpoints). Missing Type and
+// Name are filled in from the defaults.
+func WithProviderInfo(providers ...core.ProviderInfo) Option {
+	return func(sm *SessionManager) {
+		for _, info := range providers {
+			defaults := defaultProviderInfo(info.ID)
+			if info.Type == "" {
+				info.Type = defaults.Type
+			}
+			if info.Name == "" {
+				info.Name = defaults.Name
+			}
+			if info.SignInPath == "" {
+				info.SignInPath = defaults.SignInPath
+			}
+			sm.providers.info = append(sm.providers.info, info)
+		}
+	}
+}
+
+// WithMailer enables verification, password reset and security alert emails.
+// renderer defaults to the mailtemplate defaults; appName is available to
+// templates as .AppName. A nil mailer leaves emails off.
+func WithMailer(mailer core.Mailer, renderer core.EmailRenderer, appName string) Option {
+	return func(sm *SessionManager) {
+		if mailer == nil {
+			return
+		}
+		if renderer == nil {
+			renderer = defaultEmailRenderer()
+		}
+		sm.email = &emailSender{mailer: mailer, renderer: renderer, appName: appName}
+	}
+}
+
+// WithAccountEmails links password reset emails to resetURL and email
+// verification emails to verifyURL, with the token as the "token" query
+// parameter. Emails for an empty URL carry the token as a code instead.
+func WithAccountEmails(resetURL, verifyURL string) Option {
+	return func(sm *SessionManager) {
+		sm.accountEmails = accountEmails{resetURL: resetURL, verifyURL: verifyURL}
+	}
+}
+
+// WithImageStore sets where user image uploads are stored. maxSize caps the
+// upload size in bytes; zero keeps DefaultMaxImageSize.
+func WithImageStore(store core.ImageStore, maxSize int) Option {
+	return func(sm *SessionManager) {
+		if store != nil {
+			sm.images = store
+		}
+		if maxSize > 0 {
+			sm.maxImageSize = maxSize
+		}
+	}
+}
@@ -0,0 +1,315 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/lborres/kuta/core"
+	"github.com/lborres/kuta/pkg/crypto"
+)
+
+const defaultOrganizationInviteTTL = 7 * 24 * time.Hour
+
+// organizationInvites configures the invites sent by InviteOrganizationMember
+type organizationInvites struct {
+	acceptURL string // the token is added as the "token" query parameter
+	ttl       time.Duration
+}
+
+// invitesEnabled reports whether organization invites are available.
+// Accepting one must activate it exactly once, which takes an
+// OrganizationStorage with transactions.
+func (sm *SessionManager) invitesEnabled() bool {
+	return sm.organizations != nil && core.CapabilitiesOf(sm.organizations).Transactions
+}
+
+// validateInvite checks an invite before anything is stored
+func validateInvite(input core.InviteOrganizationMemberInput) error {
+	var v core.Validator
+	if strings.TrimSpace(input.OrganizationID) == "" {
+		v.Add("organizationId", core.CodeOrganizationRequired, core.ErrOrganizationRequired)
+	}
+	switch {
+	case input.Email == "":
+		v.Add("email", core.CodeEmailRequired, core.ErrEmailRequired)
+	case !validEmail(input.Email):
+		v.Add("email", core.CodeEmailInvalid, core.ErrInvalidEmail)
+	}
+	return v.Err()
+}
+
+// InviteOrganizationMember stores a pending member for input.Email and
+// emails them a single-use invite token. Whoever accepts it with
+// AcceptOrganizationInvite becomes a member with input.Roles.
+func (sm *SessionManager) InviteOrganizationMember(ctx context.Context, input core.InviteOrganizationMemberInput) (*core.OrganizationInviteResult, error) {
+	if !sm.invitesEnabled() {
+		return nil, core.ErrNotImplemented
+	}
+	if err := validateInvite(input); err != nil {
+		return nil, err
+	}
+
+	// Invites to registered users are tied to them, so the membership
+	// follows the user even if they change their email before accepting
+	recipient, err := sm.storage.GetUserByEmail(ctx, input.Email)
+	var userID *string
+	switch {
+	case err == nil:
+		userID = &recipient.ID
+	case errors.Is(err, core.ErrUserNotFound):
+		recipient = &core.User{Email: input.Email}
+	default:
+		return nil, err
+	}
+
+	pair, err := crypto.GenerateHashedToken()
+	if err != nil {
+		return nil, err
+	}
+	inviteID, err := sm.nanoid.Generate()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	expiresAt := now.Add(sm.organizationInvites.ttl)
+	if err := sm.storage.CreateVerificationToken(ctx, &core.VerificationToken{
+		ID:         inviteID,
+		UserID:     userID,
+		Identifier: input.Email,
+		Purpose:    core.VerificationInvite,
+		TokenHash:  pair.Hash,
+		ExpiresAt:  expiresAt,
+		CreatedAt:  now,
+	}); err != nil {
+		return nil, err
+	}
+
+	pending := &core.PendingOrganizationMember{
+		InviteID:       inviteID,
+		OrganizationID: strings.TrimSpace(input.OrganizationID),
+		Email:          input.Email,
+		Roles:          normalizeNames(input.Roles),
+		InvitedBy:      input.InvitedBy,
+		ExpiresAt:      expiresAt,
+		CreatedAt:      now,
+	}
+	if err := sm.organizations.SavePendingOrganizationMember(ctx, pending); err != nil {
+		return nil, err
+	}
+
+	// Apps without an accept page send the token as a code instead
+	data := core.EmailData{
+		ExpiresAt: expiresAt,
+		Details:   map[string]string{"organization_id": pending.OrganizationID},
+	}
+	if sm.organizationInvites.acceptURL != "" {
+		data.URL = withQueryToken(sm.organizationInvites.acceptURL, pair.Token)
+	} else {
+		data.Code = pair.Token
+	}
+	if err := sm.sendEmail(core.EmailOrganizationInvite, recipient, data); err != nil {
+		// An invite nobody received can't be accepted; don't list it
+		_ = sm.organizations.DeletePendingOrganizationMember(ctx, inviteID)
+		return nil, err
+	}
+
+	return &core.OrganizationInviteResult{Pending: pending, Token: pair.Token}, nil
+}
+
+// withQueryToken appends token to link as the "token" query parameter
+func withQueryToken(link, token string) string {
+	parsed, err := url.Parse(link)
+	if err != nil {
+		return link
+	}
+	query := parsed.Query()
+	query.Set("token", token)
+	parsed.RawQuery = query.Encode()
+	return parsed.String()
+}
+
+// ListPendingOrganizationMembers returns the organization's unexpired
+// invites, oldest first
+func (sm *SessionManager) ListPendingOrganizationMembers(ctx context.Context, organizationID string) ([]*core.PendingOrganizationMember, error) {
+	if sm.organizations == nil {
+		return nil, core.ErrNotImplemented
+	}
+	if err := validateOrganization(organizationID); err != nil {
+		return nil, err
+	}
+	pending, err := sm.organizations.ListPendingOrganizationMembers(ctx, organizationID)
+	if err != nil {
+		return nil, err
+	}
+	if pending == nil {
+		pending = []*core.PendingOrganizationMember{}
+	}
+	return pending, nil
+}
+
+// RevokeOrganizationInvite withdraws the invite; accepting it afterwards
+// fails with ErrVerificationTokenNotFound
+func (sm *SessionManager) RevokeOrganizationInvite(ctx context.Context, inviteID string) error {
+	if sm.organizations == nil {
+		return core.ErrNotImplemented
+	}
+	return sm.organizations.DeletePendingOrganizationMember(ctx, inviteID)
+}
+
+// AcceptOrganizationInvite consumes the invite token and makes the invited
+// user a member. Invited emails without an account get one, marked as
+// verified since the token arrived at the address, and are signed in.
+func (sm *SessionManager) AcceptOrganizationInvite(ctx context.Context, input core.AcceptOrganizationInviteInput, ipAddress, userAgent string) (*core.AcceptOrganizationInviteResult, error) {
+	if !sm.invitesEnabled() {
+		return nil, core.ErrNotImplemented
+	}
+	if err := sm.allowRequest("accept_invite", ipAddress); err != nil {
+		return nil, err
+	}
+	if input.Token == "" {
+		return nil, core.ErrVerificationTokenNotFound
+	}
+
+	// Checked before the token is consumed, so a rejected password doesn't
+	// burn the invite
+	if input.Password != "" {
+		var v core.Validator
+		sm.passwordRules.check(&v, "password", input.Password)
+		if err := v.Err(); err != nil {
+			return nil, err
+		}
+	}
+
+	token, err := sm.storage.ConsumeVerificationToken(ctx, crypto.HashToken(input.Token), core.VerificationInvite)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := sm.invitedUser(ctx, token)
+	created := false
+	if errors.Is(err, core.ErrUserNotFound) {
+		user, created, err = sm.createInvitedUser(ctx, token.Identifier, input, ipAddress, userAgent)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	member, err := sm.organizations.ActivatePendingOrganizationMember(ctx, token.ID, user.ID)
+	if err != nil {
+		if created {
+			// Cleanup: the account only existed for this membership
+			_ = sm.storage.DeleteUser(ctx, user.ID)
+		}
+		return nil, err
+	}
+
+	result := &core.AcceptOrganizationInviteResult{Member: member, User: user}
+	if !created {
+		return result, nil
+	}
+	if sm.requiresApproval() {
+		sm.emitPendingApproval(user, ipAddress, userAgent)
+		return result, nil
+	}
+
+	sessionResult, err := sm.Create(ctx, user.ID, ipAddress, userAgent)
+	if err != nil {
+		return nil, err
+	}
+	refreshToken, err := sm.issueRefreshToken(ctx, sessionResult.Session, nil)
+	if err != nil {
+		return nil, err
+	}
+	result.Session = sessionResult.Session
+	result.Token = sessionResult.Token
+	result.RefreshToken = refreshToken
+	return result, nil
+}
+
+// invitedUser returns the user the invite token was issued to, or the one
+// registered with its email since. It returns ErrUserNotFound when there is
+// neither.
+func (sm *SessionManager) invitedUser(ctx context.Context, token *core.VerificationToken) (*core.User, error) {
+	if token.UserID != nil {
+		user, err := sm.storage.GetUserByID(ctx, *token.UserID)
+		if !errors.Is(err, core.ErrUserNotFound) {
+			return user, err
+		}
+	}
+	return sm.storage.GetUserByEmail(ctx, token.Identifier)
+}
+
+// createInvitedUser creates the account of an invited email, with a
+// credential account when input has a password. Losing a race with a
+// concurrent sign-up links the invite to that user instead, and created is
+// false.
+func (sm *SessionManager) createInvitedUser(ctx context.Context, email string, input core.AcceptOrganizationInviteInput, ipAddress, userAgent string) (user *core.User, created bool, err error) {
+	var hashedPassword string
+	if input.Password != "" {
+		hashed, hashErr := sm.passwords.Hash(input.Password)
+		if hashErr != nil {
+			return nil, false, passwordError(hashErr)
+		}
+		hashedPassword = hashed
+	}
+
+	userID, err := sm.ids.users.generate()
+	if err != nil {
+		return nil, false, err
+	}
+
+	now := time.Now()
+	user = &core.User{
+		ID:            userID,
+		Email:         email,
+		EmailVerified: true,
+		Name:          input.Name,
+		CreatedAt:     now,
+		UpdatedAt:     now,
+	}
+	if sm.requiresApproval() {
+		user.Status = core.UserStatusPending
+	}
+
+	if err := sm.storage.CreateUserIfNotExists(ctx, user); err != nil {
+		if errors.Is(err, core.ErrUserExists) {
+			user, err = sm.storage.GetUserByEmail(ctx, email)
+			return user, false, err
+		}
+		return nil, false, err
+	}
+
+	if hashedPassword != "" {
+		accountID, idErr := sm.ids.accounts.generate()
+		if idErr != nil {
+			_ = sm.storage.DeleteUser(ctx, userID)
+			return nil, false, idErr
+		}
+		if err := sm.storage.CreateAccount(ctx, &core.Account{
+			ID:         accountID,
+			UserID:     userID,
+			ProviderID: core.CredentialProviderID,
+			AccountID:  email,
+			Password:   &hashedPassword,
+			CreatedAt:  now,
+			UpdatedAt:  now,
+		}); err != nil {
+			// Cleanup: delete the user if account creation fails
+			_ = sm.storage.DeleteUser(ctx, userID)
+			return nil, false, err
+		}
+	}
+
+	sm.emit(core.Event{
+		Type:      core.EventUserSignedUp,
+		UserID:    userID,
+		Email:     email,
+		IPAddress: ipAddress,
+		UserAgent: userAgent,
+	})
+	return user, true, nil
+}
@@ -0,0 +1,186 @@
+package services
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lborres/kuta/core"
+	"github.com/lborres/kuta/pkg/cache"
+	"github.com/lborres/kuta/pkg/crypto"
+)
+
+// newInviteTestManager returns a manager sending organization invites
+// through mailer and requiring 8-character passwords, where user-1
+// (a@example.com) is already registered
+func newInviteTestManager(t *testing.T, mailer *FakeMailer) (*SessionManager, *FakeStorageProvider) {
+	t.Helper()
+	storage := NewFakeStorageProvider()
+	passwords := &crypto.Argon2{Memory: 1024, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32}
+	manager := NewSessionManager(core.SessionConfig{MaxAge: time.Hour}, storage, nil, passwords,
+		WithOrganizations(cache.NewInMemoryOrganizationStorage()),
+		WithOrganizationInvites("https://app.test/invites?source=email", 0),
+		WithMailer(mailer, nil, "Acme"),
+		WithPasswordLength(8, 0))
+
+	_ = storage.CreateUser(t.Context(), &core.User{ID: "user-1", Email: "a@example.com"})
+	return manager, storage
+}
+
+// nonTransactionalOrganizations is an OrganizationStorage that can't
+// activate invites atomically
+type nonTransactionalOrganizations struct {
+	core.OrganizationStorage
+}
+
+func (nonTransactionalOrganizations) Capabilities() core.StorageCapabilities {
+	return core.StorageCapabilities{SoftDelete: true, Search: true}
+}
+
+// Requirement: Inviting emails a link carrying the invite token and lists
+// the invitee as pending until the invite is accepted or revoked. Invites
+// are unavailable on organization storage without transactions.
+func TestSessionManager_InviteOrganizationMember(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   core.InviteOrganizationMemberInput
+		wantErr error
+	}{
+		{name: "new email", input: core.InviteOrganizationMemberInput{OrganizationID: "org-1", Email: "new@example.com", Roles: []string{"editor"}}},
+		{name: "registered email", input: core.InviteOrganizationMemberInput{OrganizationID: "org-1", Email: "a@example.com", InvitedBy: "user-2"}},
+		{name: "invalid email", input: core.InviteOrganizationMemberInput{OrganizationID: "org-1", Email: "nobody"}, wantErr: core.ErrInvalidEmail},
+		{name: "missing organization", input: core.InviteOrganizationMemberInput{Email: "new@example.com"}, wantErr: core.ErrOrganizationRequired},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// Arrange
+			mailer := &FakeMailer{}
+			manager, _ := newInviteTestManager(t, mailer)
+
+			// Act
+			invite, err := manager.InviteOrganizationMember(t.Context(), test.input)
+
+			// Assert
+			if !errors.Is(err, test.wantErr) {
+				t.Fatalf("InviteOrganizationMember() error = %v, want %v", err, test.wantErr)
+			}
+			pending, _ := manager.ListPendingOrganizationMembers(t.Context(), "org-1")
+			if test.wantErr != nil {
+				if len(pending) != 0 || len(mailer.Messages()) != 0 {
+					t.Errorf("rejected invite left %d pending, sent %d emails", len(pending), len(mailer.Messages()))
+				}
+				return
+			}
+			if len(pending) != 1 || pending[0].InviteID != invite.Pending.InviteID || pending[0].Email != test.input.Email {
+				t.Errorf("ListPendingOrganizationMembers() = %+v, want the invite", pending)
+			}
+			messages := mailer.Messages()
+			if len(messages) != 1 || messages[0].Type != core.EmailOrganizationInvite || messages[0].To != test.input.Email {
+				t.Fatalf("sent %+v, want one invite to %s", messages, test.input.Email)
+			}
+			if !strings.Contains(messages[0].HTML, "https://app.test/invites?source=email&amp;token="+invite.Token) {
+				t.Errorf("invite email doesn't link to the token: %s", messages[0].HTML)
+			}
+
+			_ = manager.RevokeOrganizationInvite(t.Context(), invite.Pending.InviteID)
+			if pending, _ := manager.ListPendingOrganizationMembers(t.Context(), "org-1"); len(pending) != 0 {
+				t.Errorf("ListPendingOrganizationMembers() after revoking = %+v, want none", pending)
+			}
+		})
+	}
+
+	disabled := newTestSessionManager(NewFakeStorageProvider(), nil)
+	if _, err := disabled.InviteOrganizationMember(t.Context(), tests[0].input); !errors.Is(err, core.ErrNotImplemented) {
+		t.Errorf("InviteOrganizationMember() without storage error = %v, want ErrNotImplemented", err)
+	}
+
+	nonTransactional := NewSessionManager(core.SessionConfig{MaxAge: time.Hour}, NewFakeStorageProvider(), nil, nil,
+		WithOrganizations(nonTransactionalOrganizations{cache.NewInMemoryOrganizationStorage()}))
+	if _, err := nonTransactional.InviteOrganizationMember(t.Context(), tests[0].input); !errors.Is(err, core.ErrNotImplemented) {
+		t.Errorf("InviteOrganizationMember() without transactions error = %v, want ErrNotImplemented", err)
+	}
+	if _, err := nonTransactional.AcceptOrganizationInvite(t.Context(), core.AcceptOrganizationInviteInput{Token: "token"}, "", ""); !errors.Is(err, core.ErrNotImplemented) {
+		t.Errorf("AcceptOrganizationInvite() without transactions error = %v, want ErrNotImplemented", err)
+	}
+}
+
+// Requirement: Accepting an invite makes the invitee a member with the
+// invited roles exactly once. Registered users are linked; new emails get a
+// verified account and a session, and a rejected password doesn't burn the
+// invite.
+func TestSessionManager_AcceptOrganizationInvite(t *testing.T) {
+	tests := []struct {
+		name        string
+		email       string
+		password    string
+		revoke      bool
+		wantErr     error
+		wantUserID  string
+		wantSession bool
+	}{
+		{name: "registered user", email: "a@example.com", wantUserID: "user-1"},
+		{name: "new user with password", email: "new@example.com", password: "CorrectPass123!", wantSession: true},
+		{name: "new user without password", email: "new@example.com", wantSession: true},
+		{name: "weak password", email: "new@example.com", password: "short", wantErr: core.ErrPasswordTooShort},
+		{name: "revoked invite", email: "new@example.com", revoke: true, wantErr: core.ErrVerificationTokenNotFound},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// Arrange
+			manager, storage := newInviteTestManager(t, &FakeMailer{})
+			invite, err := manager.InviteOrganizationMember(t.Context(), core.InviteOrganizationMemberInput{
+				OrganizationID: "org-1", Email: test.email, Roles: []string{"editor"},
+			})
+			if err != nil {
+				t.Fatalf("InviteOrganizationMember() error = %v", err)
+			}
+			if test.revoke {
+				_ = manager.RevokeOrganizationInvite(t.Context(), invite.Pending.InviteID)
+			}
+			input := core.AcceptOrganizationInviteInput{Token: invite.Token, Name: "Ada", Password: test.password}
+
+			// Act
+			result, err := manager.AcceptOrganizationInvite(t.Context(), input, "127.0.0.1", "test-agent")
+
+			// Assert
+			if !errors.Is(err, test.wantErr) {
+				t.Fatalf("AcceptOrganizationInvite() error = %v, want %v", err, test.wantErr)
+			}
+			if test.wantErr != nil {
+				if _, err := storage.GetUserByEmail(t.Context(), "new@example.com"); !errors.Is(err, core.ErrUserNotFound) {
+					t.Errorf("failed acceptance left a user behind: %v", err)
+				}
+				if errors.Is(test.wantErr, core.ErrPasswordTooShort) {
+					if _, err := manager.AcceptOrganizationInvite(t.Context(), core.AcceptOrganizationInviteInput{Token: invite.Token}, "", ""); err != nil {
+						t.Errorf("retry after a rejected password error = %v", err)
+					}
+				}
+				return
+			}
+			if test.wantUserID != "" && result.User.ID != test.wantUserID {
+				t.Errorf("accepted as %s, want %s", result.User.ID, test.wantUserID)
+			}
+			if (result.Session != nil) != test.wantSession || (test.wantSession && !result.User.EmailVerified) {
+				t.Errorf("session = %v, email verified = %v; want a session %v", result.Session, result.User.EmailVerified, test.wantSession)
+			}
+			member, err := manager.organizations.GetOrganizationMember(t.Context(), "org-1", result.User.ID)
+			if err != nil || len(member.Roles) != 1 || member.Roles[0] != "editor" {
+				t.Errorf("membership = %+v, %v; want editor of org-1", member, err)
+			}
+			if pending, _ := manager.ListPendingOrganizationMembers(t.Context(), "org-1"); len(pending) != 0 {
+				t.Errorf("pending after accepting = %+v, want none", pending)
+			}
+			if _, err := manager.AcceptOrganizationInvite(t.Context(), input, "", ""); !errors.Is(err, core.ErrVerificationTokenNotFound) {
+				t.Errorf("second AcceptOrganizationInvite() error = %v, want ErrVerificationTokenNotFound", err)
+			}
+			if test.password != "" {
+				if _, err := manager.SignIn(t.Context(), core.SignInInput{Email: test.email, Password: test.password}, "", ""); err != nil {
+					t.Errorf("SignIn() with the invite password error = %v", err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,222 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/lborres/kuta/core"
+)
+
+// Ensure SessionManager implements OrganizationProvider
+var _ core.OrganizationProvider = (*SessionManager)(nil)
+
+// validateOrganization checks an organization ID given to a membership
+// operation
+func validateOrganization(organizationID string) error {
+	var v core.Validator
+	if strings.TrimSpace(organizationID) == "" {
+		v.Add("organizationId", core.CodeOrganizationRequired, core.ErrOrganizationRequired)
+	}
+	return v.Err()
+}
+
+// AddOrganizationMember makes the user, who must exist, a member of the
+// organization with roles, or replaces their roles if they already are one
+func (sm *SessionManager) AddOrganizationMember(ctx context.Context, organizationID, userID string, roles []string) error {
+	if sm.organizations == nil {
+		return core.ErrNotImplemented
+	}
+	if err := validateOrganization(organizationID); err != nil {
+		return err
+	}
+	if _, err := sm.storage.GetUserByID(ctx, userID); err != nil {
+		return err
+	}
+
+	return sm.organizations.SaveOrganizationMember(ctx, &core.OrganizationMember{
+		OrganizationID: organizationID,
+		UserID:         userID,
+		Roles:          normalizeNames(roles),
+		CreatedAt:      time.Now(),
+	})
+}
+
+// RemoveOrganizationMember ends the user's membership. Their sessions acting
+// in the organization fall back to having no active organization.
+func (sm *SessionManager) RemoveOrganizationMember(ctx context.Context, organizationID, userID string) error {
+	if sm.organizations == nil {
+		return core.ErrNotImplemented
+	}
+	if err := validateOrganization(organizationID); err != nil {
+		return err
+	}
+
+	if err := sm.organizations.RemoveOrganizationMember(ctx, organizationID, userID); err != nil {
+		return err
+	}
+
+	sessions, err := sm.storage.GetUserSessions(ctx, userID)
+	if err != nil {
+		return err
+	}
+	for _, session := range sessions {
+		if session.ActiveOrganizationID != organizationID {
+			continue
+		}
+		if err := sm.setActiveOrganization(ctx, session, ""); err != nil && !errors.Is(err, core.ErrSessionNotFound) {
+			return err
+		}
+	}
+	return nil
+}
+
+// ListUserOrganizations returns the user's memberships, oldest first
+func (sm *SessionManager) ListUserOrganizations(ctx context.Context, userID string) ([]*core.OrganizationMember, error) {
+	if sm.organizations == nil {
+		return nil, core.ErrNotImplemented
+	}
+	members, err := sm.organizations.ListUserOrganizations(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if members == nil {
+		members = []*core.OrganizationMember{}
+	}
+	return members, nil
+}
+
+// OrganizationsEnabled reports whether an organization storage has been
+// configured, so sessions have organizations to switch between. Stateless
+// sessions can't be switched.
+func (sm *SessionManager) OrganizationsEnabled() bool {
+	return sm.organizations != nil && sm.sealer == nil
+}
+
+// SwitchOrganization makes organizationID the active organization of the
+// token's session, or clears it when organizationID is empty. The user must
+// be a member; draining sessions can't switch.
+func (sm *SessionManager) SwitchOrganization(ctx context.Context, token, organizationID string) (*core.SessionData, error) {
+	if !sm.OrganizationsEnabled() {
+		return nil, core.ErrNotImplemented
+	}
+
+	data, err := sm.GetSession(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+	if data.Session.Draining() {
+		return nil, core.ErrSessionDraining
+	}
+
+	organizationID = strings.TrimSpace(organizationID)
+	if organizationID != "" {
+		if _, err := sm.organizations.GetOrganizationMember(ctx, organizationID, data.User.ID); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := sm.setActiveOrganization(ctx, data.Session, organizationID); err != nil {
+		return nil, err
+	}
+
+	session, err := sm.storage.GetSessionByID(ctx, data.Session.ID)
+	if err != nil {
+		return nil, err
+	}
+	data.Session = session
+	data.ActiveOrganizationID = session.ActiveOrganizationID
+	data.Entitlements = session.Entitlements
+	return data, nil
+}
+
+// setActiveOrganization stores organizationID as the active organization of
+// session and refreshes the cached copy
+func (sm *SessionManager) setActiveOrganization(ctx context.Context, session *core.Session, organizationID string) error {
+	// Update a fresh copy, not the one the cache may be handing out
+	stored, err := sm.storage.GetSessionByID(ctx, session.ID)
+	if err != nil {
+		return err
+	}
+	updated := *stored
+	updated.ActiveOrganizationID = organizationID
+	if err := sm.resolveEntitlements(ctx, &updated, nil); err != nil {
+		return err
+	}
+	if err := sm.storage.UpdateSession(ctx, &updated); err != nil {
+		return err
+	}
+	sm.recacheSession(ctx, session.ID)
+	return nil
+}
+
+// carryActiveOrganization gives a rotated session the active organization of
+// the one it replaces, unless the user has left the organization since
+func (sm *SessionManager) carryActiveOrganization(ctx context.Context, session *core.Session, organizationID string) error {
+	if organizationID == "" || sm.organizations == nil {
+		return nil
+	}
+	if _, err := sm.organizations.GetOrganizationMember(ctx, organizationID, session.UserID); err != nil {
+		if errors.Is(err, core.ErrNotOrganizationMember) {
+			return nil
+		}
+		return err
+	}
+
+	updated := *session
+	updated.ActiveOrganizationID = organizationID
+	if err := sm.resolveEntitlements(ctx, &updated, nil); err != nil {
+		return err
+	}
+	if err := sm.storage.UpdateSession(ctx, &updated); err != nil {
+		return err
+	}
+	session.ActiveOrganizationID = organizationID
+	session.Entitlements = updated.Entitlements
+	if sm.cache != nil {
+		_ = sm.cache.Set(session.TokenHash, &updated)
+	}
+	return nil
+}
+
+// GetSessionPermissions returns the permissions of the user's roles plus
+// those of their roles in the session's active organization, sorted and
+// without duplicates
+func (sm *SessionManager) GetSessionPermissions(ctx context.Context, session *core.Session) ([]string, error) {
+	permissions, err := sm.GetUserPermissions(ctx, session.UserID)
+	if err != nil || session.ActiveOrganizationID == "" || sm.organizations == nil {
+		return permissions, err
+	}
+
+	// Membership is checked on every call, so removing a member takes
+	// effect at once even for sessions cached elsewhere
+	member, err := sm.organizations.GetOrganizationMember(ctx, session.ActiveOrganizationID, session.UserID)
+	if errors.Is(err, core.ErrNotOrganizationMember) {
+		return permissions, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	for _, role := range member.Roles {
+		rolePermissions, err := sm.GetRolePermissions(ctx, role)
+		if err != nil {
+			return nil, err
+		}
+		permissions = append(permissions, rolePermissions...)
+	}
+	return normalizeNames(permissions), nil
+}
+
+// SessionHasPermission reports whether the session's user has permission,
+// either through their own roles or their roles in the session's active
+// organization
+func (sm *SessionManager) SessionHasPermission(ctx context.Context, session *core.Session, permission string) (bool, error) {
+	permissions, err := sm.GetSessionPermissions(ctx, session)
+	if err != nil {
+		return false, err
+	}
+	_, found := slices.BinarySearch(permissions, permission)
+	return found, nil
+}
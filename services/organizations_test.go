@@ -0,0 +1,139 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/lborres/kuta/core"
+	"github.com/lborres/kuta/pkg/cache"
+)
+
+// newOrganizationTestManager returns a manager with roles and organizations,
+// where user-1 is an "editor" of org-1 and "viewer" is their own role
+func newOrganizationTestManager(t *testing.T, sessionCache core.Cache) (*SessionManager, *FakeStorageProvider) {
+	t.Helper()
+	storage := NewFakeStorageProvider()
+	manager := NewSessionManager(core.SessionConfig{MaxAge: time.Hour}, storage, sessionCache, nil,
+		WithRoles(cache.NewInMemoryRoleStorage(), 0),
+		WithOrganizations(cache.NewInMemoryOrganizationStorage()))
+
+	_ = storage.CreateUser(t.Context(), &core.User{ID: "user-1", Email: "a@example.com"})
+	_ = manager.SetRolePermissions(t.Context(), "viewer", []string{"posts.read"})
+	_ = manager.SetRolePermissions(t.Context(), "editor", []string{"posts.write"})
+	if err := manager.AssignRole(t.Context(), "user-1", "viewer"); err != nil {
+		t.Fatalf("AssignRole() error = %v", err)
+	}
+	if err := manager.AddOrganizationMember(t.Context(), "org-1", "user-1", []string{"editor"}); err != nil {
+		t.Fatalf("AddOrganizationMember() error = %v", err)
+	}
+	return manager, storage
+}
+
+// Requirement: Sessions switch to organizations their user belongs to, the
+// switch is visible in SessionData, and clearing it is always allowed.
+func TestSessionManager_SwitchOrganization(t *testing.T) {
+	tests := []struct {
+		name           string
+		organizationID string
+		wantErr        error
+		wantActive     string
+	}{
+		{name: "member", organizationID: "org-1", wantActive: "org-1"},
+		{name: "not a member", organizationID: "org-2", wantErr: core.ErrNotOrganizationMember},
+		{name: "clearing", organizationID: ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// Arrange
+			manager, _ := newOrganizationTestManager(t, cache.NewInMemoryCache(core.CacheConfig{}))
+			created, err := manager.Create(t.Context(), "user-1", "127.0.0.1", "test-agent")
+			if err != nil {
+				t.Fatalf("Create() error = %v", err)
+			}
+
+			// Act
+			switched, err := manager.SwitchOrganization(t.Context(), created.Token, test.organizationID)
+
+			// Assert
+			if !errors.Is(err, test.wantErr) {
+				t.Fatalf("SwitchOrganization() error = %v, want %v", err, test.wantErr)
+			}
+			if test.wantErr != nil {
+				return
+			}
+			if switched.ActiveOrganizationID != test.wantActive || switched.Session.ActiveOrganizationID != test.wantActive {
+				t.Errorf("SwitchOrganization() = %q (session %q), want %q", switched.ActiveOrganizationID, switched.Session.ActiveOrganizationID, test.wantActive)
+			}
+			// The cached session reflects the switch
+			data, err := manager.GetSession(t.Context(), created.Token)
+			if err != nil || data.ActiveOrganizationID != test.wantActive {
+				t.Errorf("GetSession() active organization = %q, %v; want %q", data.ActiveOrganizationID, err, test.wantActive)
+			}
+		})
+	}
+
+	disabled := newTestSessionManager(NewFakeStorageProvider(), nil)
+	if disabled.OrganizationsEnabled() {
+		t.Error("OrganizationsEnabled() without organization storage = true")
+	}
+	if _, err := disabled.SwitchOrganization(t.Context(), "token", "org-1"); !errors.Is(err, core.ErrNotImplemented) {
+		t.Errorf("SwitchOrganization() without storage error = %v, want ErrNotImplemented", err)
+	}
+}
+
+// Requirement: Session permission checks add the user's roles in the active
+// organization to their own, and stop doing so once they leave it.
+func TestSessionManager_SessionPermissions(t *testing.T) {
+	// Arrange
+	manager, storage := newOrganizationTestManager(t, nil)
+	created, _ := manager.Create(t.Context(), "user-1", "127.0.0.1", "test-agent")
+
+	// Act
+	before, _ := manager.GetSessionPermissions(t.Context(), created.Session)
+	switched, switchErr := manager.SwitchOrganization(t.Context(), created.Token, "org-1")
+	inOrganization, _ := manager.GetSessionPermissions(t.Context(), switched.Session)
+	canWrite, _ := manager.SessionHasPermission(t.Context(), switched.Session, "posts.write")
+	removeErr := manager.RemoveOrganizationMember(t.Context(), "org-1", "user-1")
+	afterLeaving, _ := manager.GetSessionPermissions(t.Context(), switched.Session)
+	stored, _ := storage.GetSessionByID(t.Context(), created.Session.ID)
+
+	// Assert
+	if switchErr != nil || removeErr != nil {
+		t.Fatalf("errors = %v, %v", switchErr, removeErr)
+	}
+	if fmt.Sprint(before) != "[posts.read]" || fmt.Sprint(inOrganization) != "[posts.read posts.write]" || !canWrite {
+		t.Errorf("permissions = %v before switching, %v in org-1 (posts.write %v)", before, inOrganization, canWrite)
+	}
+	if fmt.Sprint(afterLeaving) != "[posts.read]" {
+		t.Errorf("permissions after leaving = %v, want [posts.read]", afterLeaving)
+	}
+	if stored.ActiveOrganizationID != "" {
+		t.Errorf("active organization after leaving = %q, want it cleared", stored.ActiveOrganizationID)
+	}
+}
+
+// Requirement: Refresh keeps the active organization of the rotated session
+// while the user is still a member.
+func TestSessionManager_Refresh_KeepsActiveOrganization(t *testing.T) {
+	// Arrange
+	manager, storage := newOrganizationTestManager(t, nil)
+	created, refreshToken := createRefreshableSession(t, manager, storage, "user-1", "")
+	if _, err := manager.SwitchOrganization(t.Context(), created.Token, "org-1"); err != nil {
+		t.Fatalf("SwitchOrganization() error = %v", err)
+	}
+
+	// Act
+	refreshed, err := manager.Refresh(t.Context(), refreshToken)
+
+	// Assert
+	if err != nil {
+		t.Fatalf("Refresh() error = %v", err)
+	}
+	data, err := manager.GetSession(t.Context(), refreshed.Token)
+	if err != nil || data.ActiveOrganizationID != "org-1" {
+		t.Errorf("refreshed session active organization = %q, %v; want org-1", data.ActiveOrganizationID, err)
+	}
+}
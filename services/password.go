@@ -0,0 +1,115 @@
+package services
+
+import (
+	"context"
+	"github.com/lborres/kuta/core"
+)
+
+// Ensure SessionManager implements PasswordChanger
+var _ core.PasswordChanger = (*SessionManager)(nil)
+
+// ChangePassword replaces the password of userID's credential account once
+// input.CurrentPassword checks out. Password reset links sent out before
+// stop working.
+//
+// Credentials usually change because they may have leaked, so by default
+// every other session of the user is revoked with RevokeReasonPasswordChange,
+// keeping only input.KeepSessionID. Stateless sessions can't be told apart,
+// so with them all refresh tokens are revoked, including the kept session's.
+func (sm *SessionManager) ChangePassword(ctx context.Context, userID string, input core.ChangePasswordInput) error {
+	if err := sm.validateChangePassword(input); err != nil {
+		return err
+	}
+
+	user, err := sm.storage.GetUserByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	account, err := sm.credentialAccount(ctx, user.ID)
+	if err != nil {
+		return err
+	}
+	if account == nil {
+		return core.ErrInvalidCredentials
+	}
+
+	match, err := sm.passwords.Verify(input.CurrentPassword, *account.Password)
+	if err != nil {
+		return passwordError(err)
+	}
+	if !match {
+		return core.ErrInvalidCredentials
+	}
+
+	hash, err := sm.passwords.Hash(input.NewPassword)
+	if err != nil {
+		return passwordError(err)
+	}
+	account.Password = &hash
+	if err := sm.storage.UpdateAccount(ctx, account); err != nil {
+		return err
+	}
+	if err := sm.voidVerificationTokens(ctx, user.ID, changePassword); err != nil {
+		return err
+	}
+
+	sm.emit(core.Event{
+		Type:      core.EventPasswordChanged,
+		UserID:    user.ID,
+		Email:     user.Email,
+		SessionID: input.KeepSessionID,
+	})
+
+	if sm.keepSessionsOnPasswordChange {
+		return nil
+	}
+	_, err = sm.revokeOtherUserSessions(ctx, user.ID, input.KeepSessionID, core.RevokeReasonPasswordChange)
+	return err
+}
+
+// credentialAccount returns the user's account holding a password, or nil
+func (sm *SessionManager) credentialAccount(ctx context.Context, userID string) (*core.Account, error) {
+	accounts, err := sm.storage.GetAccountByUserAndProvider(ctx, userID, core.CredentialProviderID)
+	if err != nil {
+		return nil, err
+	}
+	for _, account := range accounts {
+		if account.Password != nil {
+			return account, nil
+		}
+	}
+	return nil, nil
+}
+
+// revokeOtherUserSessions revokes every session of userID except
+// keepSessionID, recording reason in a single EventSessionRevoked
+func (sm *SessionManager) revokeOtherUserSessions(ctx context.Context, userID, keepSessionID, reason string) (int, error) {
+	if keepSessionID == "" || sm.sealer != nil {
+		return sm.RevokeUserSessions(ctx, userID, reason)
+	}
+
+	revoke := sm.destroyBySessionID
+	if sm.softRevoke() {
+		revoke = sm.drainBySessionID
+	}
+
+	sessions, err := sm.storage.GetUserSessions(ctx, userID)
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for _, session := range sessions {
+		if session.ID == keepSessionID || session.Draining() {
+			continue
+		}
+		if _, err := revoke(ctx, session.ID); err != nil {
+			return count, err
+		}
+		count++
+	}
+
+	sm.emitSessionsRevoked(userID, count, reason)
+	return count, nil
+}
@@ -0,0 +1,81 @@
+package services
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/lborres/kuta/core"
+	"github.com/lborres/kuta/pkg/crypto"
+)
+
+// Requirement: ChangePassword checks the current password, stores the new
+// one and by default revokes the user's other sessions.
+func TestSessionManager_ChangePassword(t *testing.T) {
+	tests := []struct {
+		name          string
+		opts          []Option
+		current       string
+		keepCurrent   bool
+		wantErr       error
+		wantRemaining int // sessions left out of 2
+		wantReason    bool
+	}{
+		{name: "revokes all sessions", current: "CorrectPass123!", wantRemaining: 0, wantReason: true},
+		{name: "keeps current session", current: "CorrectPass123!", keepCurrent: true, wantRemaining: 1, wantReason: true},
+		{name: "revocation disabled", opts: []Option{WithPasswordChangeRevocation(false)}, current: "CorrectPass123!", wantRemaining: 2},
+		{name: "wrong current password", current: "WrongPass123!", wantErr: core.ErrInvalidCredentials, wantRemaining: 2},
+		{name: "missing current password", wantErr: core.ErrPasswordRequired, wantRemaining: 2},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// Arrange
+			storage := NewFakeStorageProvider()
+			var events []core.Event
+			passwords := &crypto.Argon2{Memory: 1024, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32}
+			opts := append([]Option{WithEventHandler(core.EventHandlerFunc(func(e core.Event) { events = append(events, e) }))}, test.opts...)
+			manager := NewSessionManager(core.SessionConfig{MaxAge: time.Hour}, storage, nil, passwords, opts...)
+			signedUp, err := manager.SignUp(t.Context(), core.SignUpInput{Email: "user@example.com", Password: "CorrectPass123!"}, "", "")
+			if err != nil {
+				t.Fatalf("SignUp error: %v", err)
+			}
+			signedIn, err := manager.SignIn(t.Context(), core.SignInInput{Email: "user@example.com", Password: "CorrectPass123!"}, "", "")
+			if err != nil {
+				t.Fatalf("SignIn error: %v", err)
+			}
+			input := core.ChangePasswordInput{CurrentPassword: test.current, NewPassword: "NewPass123!"}
+			if test.keepCurrent {
+				input.KeepSessionID = signedIn.Session.ID
+			}
+
+			// Act
+			err = manager.ChangePassword(t.Context(), signedUp.User.ID, input)
+
+			// Assert
+			if !errors.Is(err, test.wantErr) {
+				t.Fatalf("error = %v, want %v", err, test.wantErr)
+			}
+			sessions, _ := storage.GetUserSessions(t.Context(), signedUp.User.ID)
+			if len(sessions) != test.wantRemaining {
+				t.Errorf("sessions left = %d, want %d", len(sessions), test.wantRemaining)
+			}
+			if test.keepCurrent && (len(sessions) != 1 || sessions[0].ID != signedIn.Session.ID) {
+				t.Errorf("expected the current session to survive, got %v", sessions)
+			}
+			_, newErr := manager.SignIn(t.Context(), core.SignInInput{Email: "user@example.com", Password: "NewPass123!"}, "", "")
+			if changed := newErr == nil; changed != (test.wantErr == nil) {
+				t.Errorf("new password accepted = %v, want %v", changed, test.wantErr == nil)
+			}
+			revoked := false
+			for _, e := range events {
+				if e.Type == core.EventSessionRevoked && e.Metadata["reason"] == core.RevokeReasonPasswordChange {
+					revoked = true
+				}
+			}
+			if revoked != test.wantReason {
+				t.Errorf("password_change revocation event = %v, want %v", revoked, test.wantReason)
+			}
+		})
+	}
+}
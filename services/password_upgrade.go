@@ -0,0 +1,79 @@
+package services
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/lborres/kuta/core"
+	"github.com/lborres/kuta/pkg/crypto"
+)
+
+const defaultRehashWindow = time.Minute
+
+// passwordUpgrader rehashes outdated password hashes on sign-in, within the
+// policy's rate limit
+type passwordUpgrader struct {
+	policy crypto.PasswordUpgradePolicy
+	hasher crypto.PasswordHandler
+
+	mu          sync.Mutex
+	windowStart time.Time
+	rehashes    int
+}
+
+func newPasswordUpgrader(policy crypto.PasswordUpgradePolicy, fallback crypto.PasswordHandler) *passwordUpgrader {
+	if policy.RehashWindow <= 0 {
+		policy.RehashWindow = defaultRehashWindow
+	}
+	var hasher crypto.PasswordHandler = fallback
+	if policy.Target != nil {
+		hasher = policy.Target
+	}
+	return &passwordUpgrader{policy: policy, hasher: hasher}
+}
+
+// allow takes a rehash slot from the current window
+func (u *passwordUpgrader) allow() bool {
+	if u.policy.MaxRehashes <= 0 {
+		return true
+	}
+
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	now := time.Now()
+	if now.Sub(u.windowStart) >= u.policy.RehashWindow {
+		u.windowStart = now
+		u.rehashes = 0
+	}
+	if u.rehashes >= u.policy.MaxRehashes {
+		return false
+	}
+	u.rehashes++
+	return true
+}
+
+// upgradePassword replaces the hash of account with one made under the
+// upgrade policy, when it needs it. password has just been verified against
+// the stored hash. Failures are ignored: the old hash still works and the
+// next sign-in tries again.
+func (sm *SessionManager) upgradePassword(ctx context.Context, account *core.Account, password string) {
+	if sm.passwordUpgrade == nil || account.Password == nil {
+		return
+	}
+	if !sm.passwordUpgrade.policy.NeedsUpgrade(*account.Password) || !sm.passwordUpgrade.allow() {
+		return
+	}
+
+	hash, err := sm.passwordUpgrade.hasher.Hash(password)
+	if err != nil {
+		return
+	}
+
+	previous := account.Password
+	account.Password = &hash
+	if err := sm.storage.UpdateAccount(ctx, account); err != nil {
+		account.Password = previous
+	}
+}
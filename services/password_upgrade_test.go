@@ -0,0 +1,73 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/lborres/kuta/core"
+	"github.com/lborres/kuta/pkg/crypto"
+)
+
+// Requirement: Sign-in rehashes outdated password hashes to the policy's
+// target, no more often than the rate limit allows.
+func TestSessionManager_PasswordUpgradeOnSignIn(t *testing.T) {
+	legacy := &crypto.Argon2{Memory: 1024, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32}
+	target := &crypto.Argon2{Memory: 1024, Iterations: 2, Parallelism: 1, SaltLength: 16, KeyLength: 32}
+
+	tests := []struct {
+		name         string
+		policy       *crypto.PasswordUpgradePolicy
+		users        int
+		wantUpgraded int
+	}{
+		{name: "disabled", users: 2, wantUpgraded: 0},
+		{name: "upgrades every user", policy: &crypto.PasswordUpgradePolicy{Target: target}, users: 3, wantUpgraded: 3},
+		{name: "rate limited", policy: &crypto.PasswordUpgradePolicy{Target: target, MaxRehashes: 2, RehashWindow: time.Hour}, users: 3, wantUpgraded: 2},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// Arrange
+			storage := NewFakeStorageProvider()
+			var opts []Option
+			if test.policy != nil {
+				opts = append(opts, WithPasswordUpgrade(*test.policy))
+			}
+			legacyManager := NewSessionManager(core.SessionConfig{MaxAge: time.Hour}, storage, nil, legacy)
+			manager := NewSessionManager(core.SessionConfig{MaxAge: time.Hour}, storage, nil, legacy, opts...)
+			var userIDs []string
+			for i := range test.users {
+				email := string(rune('a'+i)) + "@example.com"
+				signUp, err := legacyManager.SignUp(t.Context(), core.SignUpInput{Email: email, Password: "CorrectPass123!"}, "", "")
+				if err != nil {
+					t.Fatalf("SignUp error: %v", err)
+				}
+				userIDs = append(userIDs, signUp.User.ID)
+			}
+
+			// Act
+			for i := range test.users {
+				email := string(rune('a'+i)) + "@example.com"
+				if _, err := manager.SignIn(t.Context(), core.SignInInput{Email: email, Password: "CorrectPass123!"}, "", ""); err != nil {
+					t.Fatalf("SignIn error: %v", err)
+				}
+			}
+
+			// Assert
+			upgraded := 0
+			policy := crypto.PasswordUpgradePolicy{Target: target}
+			for _, id := range userIDs {
+				accounts, _ := storage.GetAccountByUserAndProvider(t.Context(), id, "credential")
+				if !policy.NeedsUpgrade(*accounts[0].Password) {
+					upgraded++
+				}
+				if ok, _ := target.Verify("CorrectPass123!", *accounts[0].Password); !ok {
+					t.Errorf("password no longer verifies for %s", id)
+				}
+			}
+			if upgraded != test.wantUpgraded {
+				t.Errorf("upgraded = %d, want %d", upgraded, test.wantUpgraded)
+			}
+		})
+	}
+}
@@ -0,0 +1,506 @@
+package services
+
+import (
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/lborres/kuta/core"
+)
+
+// AdminHandlers returns the handlers of the AdminEndpoints, keyed by
+// OperationID. admin is used when the request's auth provider doesn't
+// implement core.AdminProvider itself.
+func AdminHandlers(opts HandlerOptions, admin core.AdminProvider) map[string]func(*core.RequestContext) error {
+	return map[string]func(*core.RequestContext) error{
+		core.OperationAdminListSessions: func(ctx *core.RequestContext) error {
+			token := opts.token(ctx.Request)
+			if token == "" {
+				return opts.fail(ctx, http.StatusUnauthorized, "missing token")
+			}
+
+			admin := provider(ctx, admin)
+			data, err := admin.AuthorizeAdmin(ctx.Context, token)
+			if err != nil {
+				return opts.authError(ctx, err)
+			}
+			if err := verifyRequestProof(ctx, data.Session); err != nil {
+				return opts.authError(ctx, err)
+			}
+
+			filter, err := sessionFilter(ctx.Request.Query())
+			if err != nil {
+				return opts.fail(ctx, http.StatusBadRequest, err.Error())
+			}
+
+			page, err := admin.ListSessions(ctx.Context, filter)
+			if err != nil {
+				return opts.authError(ctx, err)
+			}
+
+			return opts.respond(ctx, core.OperationAdminListSessions, http.StatusOK, page)
+		},
+		core.OperationAdminRevokeSessions: func(ctx *core.RequestContext) error {
+			token := opts.token(ctx.Request)
+			if token == "" {
+				return opts.fail(ctx, http.StatusUnauthorized, "missing token")
+			}
+
+			admin := provider(ctx, admin)
+			data, err := admin.AuthorizeAdmin(ctx.Context, token)
+			if err != nil {
+				return opts.authError(ctx, err)
+			}
+			if err := verifyRequestProof(ctx, data.Session); err != nil {
+				return opts.authError(ctx, err)
+			}
+			if data.Session.Draining() {
+				return opts.authError(ctx, core.ErrSessionDraining)
+			}
+
+			var input struct {
+				SessionIDs []string `json:"sessionIds"`
+			}
+			if err := opts.Bind(ctx, core.OperationAdminRevokeSessions, &input); err != nil {
+				return opts.bindError(ctx, err)
+			}
+
+			count, err := admin.RevokeSessions(ctx.Context, input.SessionIDs)
+			if err != nil {
+				return opts.authError(ctx, err)
+			}
+
+			return opts.respond(ctx, core.OperationAdminRevokeSessions, http.StatusOK, map[string]int{
+				"revoked": count,
+			})
+		},
+	}
+}
+
+// DiscoveryHandlers returns the handlers of the DiscoveryEndpoints, keyed by
+// OperationID
+func DiscoveryHandlers(opts HandlerOptions, discovery core.ProviderDiscovery) map[string]func(*core.RequestContext) error {
+	return map[string]func(*core.RequestContext) error{
+		core.OperationListProviders: func(ctx *core.RequestContext) error {
+			return opts.respond(ctx, core.OperationListProviders, http.StatusOK, core.ProvidersResponse{
+				Providers: provider(ctx, discovery).Providers(),
+			})
+		},
+	}
+}
+
+// ActivityHandlers returns the handlers of the ActivityEndpoints, keyed by
+// OperationID. activity is used when the request's auth provider doesn't
+// implement core.ActivityProvider itself.
+func ActivityHandlers(opts HandlerOptions, activity core.ActivityProvider) map[string]func(*core.RequestContext) error {
+	return map[string]func(*core.RequestContext) error{
+		core.OperationListActivity: func(ctx *core.RequestContext) error {
+			token := opts.token(ctx.Request)
+			if token == "" {
+				return opts.fail(ctx, http.StatusUnauthorized, "missing token")
+			}
+
+			session, err := ctx.Auth.GetSession(ctx.Context, token)
+			if err != nil {
+				return opts.authError(ctx, err)
+			}
+
+			if err := verifyRequestProof(ctx, session.Session); err != nil {
+				return opts.authError(ctx, err)
+			}
+
+			query := ctx.Request.Query()
+			limit, err := queryInt(query, "limit")
+			if err != nil {
+				return opts.fail(ctx, http.StatusBadRequest, "limit must be an integer")
+			}
+			offset, err := queryInt(query, "offset")
+			if err != nil {
+				return opts.fail(ctx, http.StatusBadRequest, "offset must be an integer")
+			}
+
+			page, err := provider(ctx, activity).ListActivity(ctx.Context, session.User.ID, limit, offset)
+			if err != nil {
+				return opts.authError(ctx, err)
+			}
+
+			return opts.respond(ctx, core.OperationListActivity, http.StatusOK, page)
+		},
+	}
+}
+
+// OrganizationHandlers returns the handlers of the OrganizationEndpoints,
+// keyed by OperationID. organizations is used when the request's auth
+// provider doesn't implement core.OrganizationProvider itself.
+func OrganizationHandlers(opts HandlerOptions, organizations core.OrganizationProvider) map[string]func(*core.RequestContext) error {
+	return map[string]func(*core.RequestContext) error{
+		core.OperationSwitchOrganization: func(ctx *core.RequestContext) error {
+			var req core.SwitchOrganizationRequest
+			if err := opts.Bind(ctx, core.OperationSwitchOrganization, &req); err != nil {
+				return opts.bindError(ctx, err)
+			}
+
+			token := opts.token(ctx.Request)
+			if token == "" {
+				return opts.fail(ctx, http.StatusUnauthorized, "missing token")
+			}
+
+			session, err := ctx.Auth.GetSession(ctx.Context, token)
+			if err != nil {
+				return opts.authError(ctx, err)
+			}
+
+			if err := verifyRequestProof(ctx, session.Session); err != nil {
+				return opts.authError(ctx, err)
+			}
+
+			switched, err := provider(ctx, organizations).SwitchOrganization(ctx.Context, token, req.OrganizationID)
+			if err != nil {
+				return opts.authError(ctx, err)
+			}
+
+			return opts.respond(ctx, core.OperationSwitchOrganization, http.StatusOK, switched)
+		},
+		// Invitees whose account the invite creates are signed in
+		core.OperationAcceptOrganizationInvite: func(ctx *core.RequestContext) error {
+			var req core.AcceptOrganizationInviteRequest
+			if err := opts.Bind(ctx, core.OperationAcceptOrganizationInvite, &req); err != nil {
+				return opts.bindError(ctx, err)
+			}
+
+			result, err := provider(ctx, organizations).AcceptOrganizationInvite(ctx.Context, req.Input(), ctx.Request.ClientIP(), ctx.Request.UserAgent())
+			if err != nil {
+				return opts.authError(ctx, err)
+			}
+
+			if result.Session != nil {
+				opts.setSessionCookie(ctx, result.Token, result.Session.ExpiresAt)
+			}
+
+			return opts.respond(ctx, core.OperationAcceptOrganizationInvite, http.StatusOK, result)
+		},
+	}
+}
+
+// AccessTokenHandlers returns the handlers of the AccessTokenEndpoints,
+// keyed by OperationID. tokens is used when the request's auth provider
+// doesn't implement core.AccessTokenProvider itself.
+func AccessTokenHandlers(opts HandlerOptions, tokens core.AccessTokenProvider) map[string]func(*core.RequestContext) error {
+	return map[string]func(*core.RequestContext) error{
+		core.OperationListAccessTokens: func(ctx *core.RequestContext) error {
+			session, err := opts.session(ctx)
+			if err != nil {
+				return opts.authError(ctx, err)
+			}
+
+			list, err := provider(ctx, tokens).ListAccessTokens(ctx.Context, session.User.ID)
+			if err != nil {
+				return opts.authError(ctx, err)
+			}
+
+			return opts.respond(ctx, core.OperationListAccessTokens, http.StatusOK, list)
+		},
+		core.OperationCreateAccessToken: func(ctx *core.RequestContext) error {
+			var req core.CreateAccessTokenRequest
+			if err := opts.Bind(ctx, core.OperationCreateAccessToken, &req); err != nil {
+				return opts.bindError(ctx, err)
+			}
+
+			session, err := opts.session(ctx)
+			if err != nil {
+				return opts.authError(ctx, err)
+			}
+			if session.Session.Draining() {
+				return opts.authError(ctx, core.ErrSessionDraining)
+			}
+
+			result, err := provider(ctx, tokens).CreateAccessToken(ctx.Context, session.User.ID, req.Input())
+			if err != nil {
+				return opts.authError(ctx, err)
+			}
+
+			return opts.respond(ctx, core.OperationCreateAccessToken, http.StatusCreated, result)
+		},
+		core.OperationRevokeAccessToken: func(ctx *core.RequestContext) error {
+			session, err := opts.session(ctx)
+			if err != nil {
+				return opts.authError(ctx, err)
+			}
+			if session.Session.Draining() {
+				return opts.authError(ctx, core.ErrSessionDraining)
+			}
+
+			if err := provider(ctx, tokens).RevokeAccessToken(ctx.Context, session.User.ID, ctx.Request.PathValue("id")); err != nil {
+				return opts.authError(ctx, err)
+			}
+
+			return opts.respond(ctx, core.OperationRevokeAccessToken, http.StatusOK, core.MessageResponse{
+				Message: "access token revoked",
+			})
+		},
+	}
+}
+
+// PasswordHandlers returns the handlers of the PasswordEndpoints, keyed by
+// OperationID. passwords is used when the request's auth provider doesn't
+// implement core.PasswordChanger itself.
+func PasswordHandlers(opts HandlerOptions, passwords core.PasswordChanger) map[string]func(*core.RequestContext) error {
+	return map[string]func(*core.RequestContext) error{
+		// The session making the change stays signed in
+		core.OperationChangePassword: func(ctx *core.RequestContext) error {
+			var req core.ChangePasswordRequest
+			if err := opts.Bind(ctx, core.OperationChangePassword, &req); err != nil {
+				return opts.bindError(ctx, err)
+			}
+
+			if opts.token(ctx.Request) == "" {
+				return opts.fail(ctx, http.StatusUnauthorized, "missing token")
+			}
+
+			session, err := opts.owner(ctx)
+			if err != nil {
+				return opts.authError(ctx, err)
+			}
+
+			input := req.Input()
+			input.KeepSessionID = session.Session.ID
+			if err := provider(ctx, passwords).ChangePassword(ctx.Context, session.User.ID, input); err != nil {
+				return opts.authError(ctx, err)
+			}
+
+			return opts.respond(ctx, core.OperationChangePassword, http.StatusOK, core.MessageResponse{
+				Message: "password changed",
+			})
+		},
+	}
+}
+
+// AccountHandlers returns the handlers of the AccountEndpoints and
+// AccountLinkEndpoints, keyed by OperationID. accounts is used when the
+// request's auth provider doesn't implement core.AccountLinker itself.
+func AccountHandlers(opts HandlerOptions, accounts core.AccountLinker) map[string]func(*core.RequestContext) error {
+	return map[string]func(*core.RequestContext) error{
+		core.OperationListAccounts: func(ctx *core.RequestContext) error {
+			session, err := opts.session(ctx)
+			if err != nil {
+				return opts.authError(ctx, err)
+			}
+
+			list, err := provider(ctx, accounts).ListAccounts(ctx.Context, session.User.ID)
+			if err != nil {
+				return opts.authError(ctx, err)
+			}
+
+			return opts.respond(ctx, core.OperationListAccounts, http.StatusOK, list)
+		},
+		core.OperationUnlinkAccount: func(ctx *core.RequestContext) error {
+			session, err := opts.owner(ctx)
+			if err != nil {
+				return opts.authError(ctx, err)
+			}
+
+			if err := provider(ctx, accounts).UnlinkAccount(ctx.Context, session.User.ID, ctx.Request.PathValue("id")); err != nil {
+				return opts.authError(ctx, err)
+			}
+
+			return opts.respond(ctx, core.OperationUnlinkAccount, http.StatusOK, core.MessageResponse{
+				Message: "account unlinked",
+			})
+		},
+		// The client sends the user to the returned URL, since a redirect
+		// couldn't carry a bearer token
+		core.OperationLinkOAuthAccount: func(ctx *core.RequestContext) error {
+			session, err := opts.owner(ctx)
+			if err != nil {
+				return opts.authError(ctx, err)
+			}
+
+			start, err := provider(ctx, accounts).StartOAuthLink(ctx.Context, session.User.ID, ctx.Request.PathValue("provider"))
+			if err != nil {
+				return opts.authError(ctx, err)
+			}
+			opts.setOAuthStateCookie(ctx, start)
+
+			return opts.respond(ctx, core.OperationLinkOAuthAccount, http.StatusOK, start)
+		},
+	}
+}
+
+// OAuthHandlers returns the handlers of the OAuthEndpoints, keyed by
+// OperationID. oauth is used when the request's auth provider doesn't
+// implement core.OAuthSignIn itself.
+func OAuthHandlers(opts HandlerOptions, oauth core.OAuthSignIn) map[string]func(*core.RequestContext) error {
+	return map[string]func(*core.RequestContext) error{
+		core.OperationOAuthSignIn: func(ctx *core.RequestContext) error {
+			start, err := provider(ctx, oauth).StartOAuth(ctx.Request.PathValue("provider"))
+			if err != nil {
+				return opts.authError(ctx, err)
+			}
+			opts.setOAuthStateCookie(ctx, start)
+
+			redirect(ctx, start.URL, http.StatusFound)
+			return nil
+		},
+		core.OperationOAuthCallback:     opts.oauthCallback(oauth, core.OperationOAuthCallback),
+		core.OperationOAuthCallbackPost: opts.oauthCallback(oauth, core.OperationOAuthCallbackPost),
+	}
+}
+
+// oauthCallback handles the provider's redirect back. Providers send the
+// code and state as query parameters, or as a form with the form_post
+// response mode.
+func (o HandlerOptions) oauthCallback(oauth core.OAuthSignIn, operationID string) func(*core.RequestContext) error {
+	return func(ctx *core.RequestContext) error {
+		values := ctx.Request.Query()
+		if ctx.Request.Method() == http.MethodPost {
+			var err error
+			if values, err = formValues(ctx.Request); err != nil {
+				return o.bindError(ctx, err)
+			}
+		}
+		params := make(map[string]string, len(values))
+		for key := range values {
+			params[key] = values.Get(key)
+		}
+
+		callback := core.OAuthCallback{
+			Code:        params["code"],
+			State:       params["state"],
+			StateCookie: ctx.Request.Cookie(oauthStateCookieName),
+			Error:       params["error"],
+			Params:      params,
+		}
+		o.clearOAuthStateCookie(ctx)
+
+		result, err := provider(ctx, oauth).CompleteOAuth(ctx.Context, ctx.Request.PathValue("provider"), callback, ctx.Request.ClientIP(), ctx.Request.UserAgent())
+		if err != nil {
+			return o.authError(ctx, err)
+		}
+
+		// A challenged sign-in has no session yet and the client needs the
+		// challenge from the body
+		if result.Session == nil {
+			return o.respond(ctx, operationID, http.StatusOK, result)
+		}
+
+		o.setSessionCookie(ctx, result.Token, result.Session.ExpiresAt)
+		if o.OAuthRedirectURL != "" && o.SetCookie {
+			// 303 turns the form_post POST into a GET
+			redirect(ctx, o.OAuthRedirectURL, http.StatusSeeOther)
+			return nil
+		}
+
+		return o.respond(ctx, operationID, http.StatusOK, result)
+	}
+}
+
+// setOAuthStateCookie gives the browser starting an OAuth flow its state,
+// for the callback to prove it comes back to the same browser. Lax lets the
+// cookie follow the provider's redirect back; a form_post callback is a
+// cross-site POST, which only sends SameSite=None cookies.
+func (o HandlerOptions) setOAuthStateCookie(ctx *core.RequestContext, start *core.OAuthStart) {
+	sameSite := http.SameSiteLaxMode
+	if u, err := url.Parse(start.URL); err == nil && u.Query().Get("response_mode") == "form_post" {
+		sameSite = http.SameSiteNoneMode
+	}
+	ctx.Response.SetCookie(o.oauthStateCookie(start.State, int(oauthStateTTL/time.Second), sameSite))
+}
+
+func (o HandlerOptions) clearOAuthStateCookie(ctx *core.RequestContext) {
+	ctx.Response.SetCookie(o.oauthStateCookie("", -1, http.SameSiteLaxMode))
+}
+
+func (o HandlerOptions) oauthStateCookie(state string, maxAge int, sameSite http.SameSite) *http.Cookie {
+	return &http.Cookie{
+		Name:     oauthStateCookieName,
+		Value:    state,
+		Path:     "/",
+		Domain:   o.CookieDomain,
+		MaxAge:   maxAge,
+		Secure:   !o.CookieInsecure,
+		HttpOnly: true,
+		SameSite: sameSite,
+	}
+}
+
+// session authenticates the session managing access tokens or accounts.
+// Only sessions can: an access token isn't one, so a leaked token can't be
+// used to create more or to take over the user's sign-in methods.
+func (o HandlerOptions) session(ctx *core.RequestContext) (*core.SessionData, error) {
+	token := o.token(ctx.Request)
+	if token == "" {
+		return nil, core.ErrMissingAuthHeader
+	}
+
+	session, err := ctx.Auth.GetSession(ctx.Context, token)
+	if err != nil {
+		return nil, err
+	}
+	if err := verifyRequestProof(ctx, session.Session); err != nil {
+		return nil, err
+	}
+	return session, nil
+}
+
+// redirect sends the client to url with a bodyless response
+func redirect(ctx *core.RequestContext, url string, status int) {
+	ctx.Response.SetHeader("Location", url)
+	ctx.Response.Status(status)
+}
+
+// formValues parses a URL-encoded form body. Other bodies have no fields,
+// as with net/http's PostForm.
+func formValues(r core.Request) (url.Values, error) {
+	contentType, _, _ := mime.ParseMediaType(r.Header("Content-Type"))
+	if contentType != "application/x-www-form-urlencoded" {
+		return url.Values{}, nil
+	}
+
+	body, err := io.ReadAll(r.Body())
+	if err != nil {
+		return nil, err
+	}
+	return url.ParseQuery(string(body))
+}
+
+// sessionFilter reads session search parameters from the query string
+func sessionFilter(query url.Values) (core.SessionFilter, error) {
+	filter := core.SessionFilter{
+		UserID:    query.Get("userId"),
+		IPAddress: query.Get("ipAddress"),
+		UserAgent: query.Get("userAgent"),
+	}
+
+	var err error
+	if v := query.Get("createdAfter"); v != "" {
+		if filter.CreatedAfter, err = time.Parse(time.RFC3339, v); err != nil {
+			return filter, errors.New("createdAfter must be an RFC 3339 timestamp")
+		}
+	}
+	if v := query.Get("createdBefore"); v != "" {
+		if filter.CreatedBefore, err = time.Parse(time.RFC3339, v); err != nil {
+			return filter, errors.New("createdBefore must be an RFC 3339 timestamp")
+		}
+	}
+	if filter.Limit, err = queryInt(query, "limit"); err != nil {
+		return filter, errors.New("limit must be an integer")
+	}
+	if filter.Offset, err = queryInt(query, "offset"); err != nil {
+		return filter, errors.New("offset must be an integer")
+	}
+
+	return filter, nil
+}
+
+// queryInt reads an optional integer query parameter, zero when absent
+func queryInt(query url.Values, name string) (int, error) {
+	v := query.Get(name)
+	if v == "" {
+		return 0, nil
+	}
+	return strconv.Atoi(v)
+}
@@ -0,0 +1,754 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/lborres/kuta/core"
+)
+
+// discoveryAuthProvider adds provider discovery to the mock auth provider
+type discoveryAuthProvider struct {
+	*mockAuthProvider
+	providers []core.ProviderInfo
+}
+
+func (d *discoveryAuthProvider) Providers() []core.ProviderInfo {
+	return d.providers
+}
+
+// Requirement: GET /providers lists the sign-in methods of providers that
+// support discovery, and isn't mounted for those that don't.
+func TestDiscoveryHandlers(t *testing.T) {
+	providers := []core.ProviderInfo{
+		{ID: core.CredentialProviderID, Type: core.ProviderTypeCredential, Name: "Email and password", SignInPath: "/sign-in"},
+		{ID: "github", Type: core.ProviderTypeOAuth, Name: "GitHub"},
+	}
+
+	tests := []struct {
+		name       string
+		auth       core.AuthProvider
+		wantStatus int
+		wantIDs    []string
+	}{
+		{
+			name:       "lists providers",
+			auth:       &discoveryAuthProvider{mockAuthProvider: &mockAuthProvider{}, providers: providers},
+			wantStatus: http.StatusOK,
+			wantIDs:    []string{core.CredentialProviderID, "github"},
+		},
+		{
+			name:       "not mounted without discovery",
+			auth:       &mockAuthProvider{},
+			wantStatus: http.StatusNotFound,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// Arrange
+			server := newRouteServer(t, test.auth, HandlerOptions{})
+
+			// Act
+			resp := server.do(routeRequest{method: http.MethodGet, path: "/providers"})
+
+			// Assert
+			if resp.status != test.wantStatus {
+				t.Fatalf("status = %d, want %d (body %s)", resp.status, test.wantStatus, resp.body)
+			}
+			if test.wantIDs == nil {
+				return
+			}
+			var body core.ProvidersResponse
+			resp.decode(t, &body)
+			if len(body.Providers) != len(test.wantIDs) {
+				t.Fatalf("providers = %+v, want %v", body.Providers, test.wantIDs)
+			}
+			for i, id := range test.wantIDs {
+				if body.Providers[i].ID != id {
+					t.Errorf("providers[%d] = %s, want %s", i, body.Providers[i].ID, id)
+				}
+			}
+			if body.Providers[1].Name != "GitHub" || body.Providers[0].SignInPath != "/sign-in" {
+				t.Errorf("display metadata lost: %+v", body.Providers)
+			}
+		})
+	}
+}
+
+// activityAuthProvider adds recent activity to the mock auth provider
+type activityAuthProvider struct {
+	*mockAuthProvider
+	enabled bool
+	userID  string
+	limit   int
+	offset  int
+}
+
+func (a *activityAuthProvider) ActivityEnabled() bool { return a.enabled }
+
+func (a *activityAuthProvider) ListActivity(ctx context.Context, userID string, limit, offset int) (*core.ActivityPage, error) {
+	a.userID, a.limit, a.offset = userID, limit, offset
+	return &core.ActivityPage{
+		SignIns: []*core.SignInRecord{{ID: "attempt-1", UserID: userID, Success: true}},
+		Total:   1,
+		Limit:   limit,
+		Offset:  offset,
+	}, nil
+}
+
+// Requirement: GET /me/activity lists the signed-in user's own sign-ins a
+// page at a time, and isn't mounted without an activity provider.
+func TestActivityHandlers(t *testing.T) {
+	signedIn := func() *mockAuthProvider {
+		return &mockAuthProvider{getSessionData: &core.SessionData{User: &core.User{ID: "u1"}, Session: &core.Session{ID: "s1"}}}
+	}
+
+	tests := []struct {
+		name       string
+		auth       core.AuthProvider
+		path       string
+		token      string
+		wantStatus int
+		wantLimit  int
+		wantOffset int
+	}{
+		{
+			name:       "lists the user's page",
+			auth:       &activityAuthProvider{mockAuthProvider: signedIn(), enabled: true},
+			path:       "/me/activity?limit=5&offset=10",
+			token:      "tok",
+			wantStatus: http.StatusOK,
+			wantLimit:  5,
+			wantOffset: 10,
+		},
+		{
+			name:       "missing token",
+			auth:       &activityAuthProvider{mockAuthProvider: signedIn(), enabled: true},
+			path:       "/me/activity",
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name:       "invalid limit",
+			auth:       &activityAuthProvider{mockAuthProvider: signedIn(), enabled: true},
+			path:       "/me/activity?limit=many",
+			token:      "tok",
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "not mounted when disabled",
+			auth:       &activityAuthProvider{mockAuthProvider: signedIn()},
+			path:       "/me/activity",
+			token:      "tok",
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name:       "not mounted without activity",
+			auth:       signedIn(),
+			path:       "/me/activity",
+			token:      "tok",
+			wantStatus: http.StatusNotFound,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// Arrange
+			server := newRouteServer(t, test.auth, HandlerOptions{})
+			headers := map[string]string{}
+			if test.token != "" {
+				headers["Authorization"] = "Bearer " + test.token
+			}
+
+			// Act
+			resp := server.do(routeRequest{method: http.MethodGet, path: test.path, headers: headers})
+
+			// Assert
+			if resp.status != test.wantStatus {
+				t.Fatalf("status = %d, want %d (body %s)", resp.status, test.wantStatus, resp.body)
+			}
+			if test.wantStatus != http.StatusOK {
+				return
+			}
+			activity := test.auth.(*activityAuthProvider)
+			if activity.userID != "u1" || activity.limit != test.wantLimit || activity.offset != test.wantOffset {
+				t.Errorf("ListActivity(%q, %d, %d), want (u1, %d, %d)", activity.userID, activity.limit, activity.offset, test.wantLimit, test.wantOffset)
+			}
+			var body core.ActivityPage
+			resp.decode(t, &body)
+			if len(body.SignIns) != 1 || body.SignIns[0].ID != "attempt-1" || body.Total != 1 {
+				t.Errorf("unexpected body %s", resp.body)
+			}
+		})
+	}
+}
+
+// organizationAuthProvider adds organizations to the mock auth provider,
+// allowing only org-1 and accepting the invite tokens "new" (creating the
+// account) and "member"
+type organizationAuthProvider struct {
+	*mockAuthProvider
+	enabled        bool
+	token          string
+	organizationID string
+}
+
+func (o *organizationAuthProvider) OrganizationsEnabled() bool { return o.enabled }
+
+func (o *organizationAuthProvider) SwitchOrganization(ctx context.Context, token, organizationID string) (*core.SessionData, error) {
+	o.token, o.organizationID = token, organizationID
+	if organizationID != "org-1" {
+		return nil, core.ErrNotOrganizationMember
+	}
+	return &core.SessionData{
+		User:                 &core.User{ID: "u1"},
+		Session:              &core.Session{ID: "s1", ActiveOrganizationID: organizationID},
+		ActiveOrganizationID: organizationID,
+	}, nil
+}
+
+func (o *organizationAuthProvider) AcceptOrganizationInvite(ctx context.Context, input core.AcceptOrganizationInviteInput, ipAddress, userAgent string) (*core.AcceptOrganizationInviteResult, error) {
+	member := &core.OrganizationMember{OrganizationID: "org-1", UserID: "u1"}
+	switch input.Token {
+	case "new":
+		return &core.AcceptOrganizationInviteResult{
+			Member:  member,
+			User:    &core.User{ID: "u1"},
+			Session: &core.Session{ID: "s1", ExpiresAt: time.Now().Add(time.Hour)},
+			Token:   "session-token",
+		}, nil
+	case "member":
+		return &core.AcceptOrganizationInviteResult{Member: member, User: &core.User{ID: "u1"}}, nil
+	}
+	return nil, core.ErrVerificationTokenNotFound
+}
+
+// Requirement: POST /organizations/switch switches the current session's
+// active organization, refuses organizations the user isn't a member of, and
+// isn't mounted without an organization switcher.
+func TestOrganizationHandlers_Switch(t *testing.T) {
+	signedIn := func() *mockAuthProvider {
+		return &mockAuthProvider{getSessionData: &core.SessionData{User: &core.User{ID: "u1"}, Session: &core.Session{ID: "s1"}}}
+	}
+
+	tests := []struct {
+		name           string
+		auth           core.AuthProvider
+		organizationID string
+		token          string
+		wantStatus     int
+	}{
+		{
+			name:           "switches",
+			auth:           &organizationAuthProvider{mockAuthProvider: signedIn(), enabled: true},
+			organizationID: "org-1",
+			token:          "tok",
+			wantStatus:     http.StatusOK,
+		},
+		{
+			name:           "not a member",
+			auth:           &organizationAuthProvider{mockAuthProvider: signedIn(), enabled: true},
+			organizationID: "org-2",
+			token:          "tok",
+			wantStatus:     http.StatusForbidden,
+		},
+		{
+			name:           "missing token",
+			auth:           &organizationAuthProvider{mockAuthProvider: signedIn(), enabled: true},
+			organizationID: "org-1",
+			wantStatus:     http.StatusUnauthorized,
+		},
+		{
+			name:           "not mounted when disabled",
+			auth:           &organizationAuthProvider{mockAuthProvider: signedIn()},
+			organizationID: "org-1",
+			token:          "tok",
+			wantStatus:     http.StatusNotFound,
+		},
+		{
+			name:           "not mounted without organizations",
+			auth:           signedIn(),
+			organizationID: "org-1",
+			token:          "tok",
+			wantStatus:     http.StatusNotFound,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// Arrange
+			server := newRouteServer(t, test.auth, HandlerOptions{})
+			headers := map[string]string{}
+			if test.token != "" {
+				headers["Authorization"] = "Bearer " + test.token
+			}
+
+			// Act
+			resp := server.do(routeRequest{
+				method:  http.MethodPost,
+				path:    "/organizations/switch",
+				body:    core.SwitchOrganizationRequest{OrganizationID: test.organizationID},
+				headers: headers,
+			})
+
+			// Assert
+			if resp.status != test.wantStatus {
+				t.Fatalf("status = %d, want %d (body %s)", resp.status, test.wantStatus, resp.body)
+			}
+			if test.wantStatus != http.StatusOK {
+				return
+			}
+			switcher := test.auth.(*organizationAuthProvider)
+			if switcher.token != "tok" || switcher.organizationID != "org-1" {
+				t.Errorf("SwitchOrganization(%q, %q), want (tok, org-1)", switcher.token, switcher.organizationID)
+			}
+			var body core.SessionData
+			resp.decode(t, &body)
+			if body.ActiveOrganizationID != "org-1" || body.Session.ActiveOrganizationID != "org-1" {
+				t.Errorf("unexpected body %s", resp.body)
+			}
+		})
+	}
+}
+
+// Requirement: POST /organizations/invites/accept activates the invite,
+// signs in invitees whose account it created, and rejects used or unknown
+// tokens.
+func TestOrganizationHandlers_AcceptInvite(t *testing.T) {
+	tests := []struct {
+		name       string
+		token      string
+		wantStatus int
+		wantCookie bool
+	}{
+		{name: "new account", token: "new", wantStatus: http.StatusOK, wantCookie: true},
+		{name: "existing account", token: "member", wantStatus: http.StatusOK},
+		{name: "unknown token", token: "used", wantStatus: http.StatusBadRequest},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// Arrange
+			auth := &organizationAuthProvider{mockAuthProvider: &mockAuthProvider{}, enabled: true}
+			server := newRouteServer(t, auth, HandlerOptions{SetCookie: true})
+
+			// Act
+			resp := server.do(routeRequest{
+				method: http.MethodPost,
+				path:   "/organizations/invites/accept",
+				body:   core.AcceptOrganizationInviteRequest{Token: test.token},
+			})
+
+			// Assert
+			if resp.status != test.wantStatus {
+				t.Fatalf("status = %d, want %d (body %s)", resp.status, test.wantStatus, resp.body)
+			}
+			if gotCookie := len(resp.cookies) > 0; gotCookie != test.wantCookie {
+				t.Errorf("session cookie set = %v, want %v", gotCookie, test.wantCookie)
+			}
+			if test.wantStatus != http.StatusOK {
+				return
+			}
+			var body core.AcceptOrganizationInviteResult
+			resp.decode(t, &body)
+			if body.Member == nil || body.Member.OrganizationID != "org-1" {
+				t.Errorf("unexpected body %s", resp.body)
+			}
+		})
+	}
+}
+
+// passwordAuthProvider adds password changes to the mock auth provider.
+// "old-password" is u1's current password.
+type passwordAuthProvider struct {
+	*mockAuthProvider
+	userID string
+	input  core.ChangePasswordInput
+}
+
+func (p *passwordAuthProvider) ChangePassword(ctx context.Context, userID string, input core.ChangePasswordInput) error {
+	p.userID, p.input = userID, input
+	if input.CurrentPassword != "old-password" {
+		return core.ErrInvalidCredentials
+	}
+	if len(input.NewPassword) < 8 {
+		return core.ErrPasswordTooShort
+	}
+	return nil
+}
+
+// Requirement: POST /change-password changes the signed-in user's password,
+// keeping the session making the change. Draining and impersonated sessions
+// can't, and the endpoint isn't mounted without a password changer.
+func TestPasswordHandlers(t *testing.T) {
+	revokedAt := time.Now()
+	sessionData := func(session *core.Session, impersonation *core.Impersonation) *core.SessionData {
+		return &core.SessionData{User: &core.User{ID: "u1"}, Session: session, Impersonation: impersonation}
+	}
+
+	tests := []struct {
+		name       string
+		data       *core.SessionData
+		plain      bool
+		body       any
+		token      string
+		wantStatus int
+	}{
+		{
+			name:       "changes the password",
+			data:       sessionData(&core.Session{ID: "s1"}, nil),
+			body:       core.ChangePasswordRequest{CurrentPassword: "old-password", NewPassword: "new-password"},
+			token:      "tok",
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "wrong current password",
+			data:       sessionData(&core.Session{ID: "s1"}, nil),
+			body:       core.ChangePasswordRequest{CurrentPassword: "guess", NewPassword: "new-password"},
+			token:      "tok",
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name:       "weak new password",
+			data:       sessionData(&core.Session{ID: "s1"}, nil),
+			body:       core.ChangePasswordRequest{CurrentPassword: "old-password", NewPassword: "short"},
+			token:      "tok",
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "invalid body",
+			data:       sessionData(&core.Session{ID: "s1"}, nil),
+			body:       `{"currentPassword":`,
+			token:      "tok",
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "missing token",
+			data:       sessionData(&core.Session{ID: "s1"}, nil),
+			body:       core.ChangePasswordRequest{CurrentPassword: "old-password", NewPassword: "new-password"},
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name:       "draining session",
+			data:       sessionData(&core.Session{ID: "s1", RevokedAt: &revokedAt}, nil),
+			body:       core.ChangePasswordRequest{CurrentPassword: "old-password", NewPassword: "new-password"},
+			token:      "tok",
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name:       "impersonated session",
+			data:       sessionData(&core.Session{ID: "s1"}, &core.Impersonation{ActorUserID: "admin"}),
+			body:       core.ChangePasswordRequest{CurrentPassword: "old-password", NewPassword: "new-password"},
+			token:      "tok",
+			wantStatus: http.StatusForbidden,
+		},
+		{
+			name:       "not mounted without a password changer",
+			data:       sessionData(&core.Session{ID: "s1"}, nil),
+			plain:      true,
+			body:       core.ChangePasswordRequest{CurrentPassword: "old-password", NewPassword: "new-password"},
+			token:      "tok",
+			wantStatus: http.StatusNotFound,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// Arrange
+			mock := &mockAuthProvider{getSessionData: test.data}
+			passwords := &passwordAuthProvider{mockAuthProvider: mock}
+			var auth core.AuthProvider = passwords
+			if test.plain {
+				auth = mock
+			}
+			server := newRouteServer(t, auth, HandlerOptions{})
+			headers := map[string]string{}
+			if test.token != "" {
+				headers["Authorization"] = "Bearer " + test.token
+			}
+
+			// Act
+			resp := server.do(routeRequest{method: http.MethodPost, path: "/change-password", body: test.body, headers: headers})
+
+			// Assert
+			if resp.status != test.wantStatus {
+				t.Fatalf("status = %d, want %d (body %s)", resp.status, test.wantStatus, resp.body)
+			}
+			if test.wantStatus != http.StatusOK {
+				return
+			}
+			if passwords.userID != "u1" || passwords.input.KeepSessionID != "s1" || passwords.input.NewPassword != "new-password" {
+				t.Errorf("ChangePassword(%q, %+v), want u1's password changed keeping s1", passwords.userID, passwords.input)
+			}
+		})
+	}
+}
+
+// accountAuthProvider adds account linking to the mock auth provider. u1
+// signs in with a password ("acc-1") and, when twoAccounts is set, Google
+// ("acc-2"). OAuth sign-in is enabled with oauth.
+type accountAuthProvider struct {
+	*mockAuthProvider
+	oauth       bool
+	twoAccounts bool
+	unlinked    string
+	linkUserID  string
+}
+
+func (p *accountAuthProvider) OAuthEnabled() bool { return p.oauth }
+
+func (p *accountAuthProvider) StartOAuth(string) (*core.OAuthStart, error) {
+	return nil, core.ErrUnknownProvider
+}
+
+func (p *accountAuthProvider) CompleteOAuth(context.Context, string, core.OAuthCallback, string, string) (*core.SignInResult, error) {
+	return nil, core.ErrUnknownProvider
+}
+
+func (p *accountAuthProvider) ListAccounts(ctx context.Context, userID string) ([]*core.Account, error) {
+	accounts := []*core.Account{{ID: "acc-1", UserID: userID, ProviderID: core.CredentialProviderID}}
+	if p.twoAccounts {
+		accounts = append(accounts, &core.Account{ID: "acc-2", UserID: userID, ProviderID: "google"})
+	}
+	return accounts, nil
+}
+
+func (p *accountAuthProvider) StartOAuthLink(ctx context.Context, userID, providerID string) (*core.OAuthStart, error) {
+	if providerID != "google" {
+		return nil, core.ErrUnknownProvider
+	}
+	p.linkUserID = userID
+	return &core.OAuthStart{URL: "https://accounts.google.com/o/oauth2/v2/auth?state=s1", State: "s1"}, nil
+}
+
+func (p *accountAuthProvider) UnlinkAccount(ctx context.Context, userID, accountID string) error {
+	accounts, _ := p.ListAccounts(ctx, userID)
+	for _, account := range accounts {
+		if account.ID != accountID {
+			continue
+		}
+		if len(accounts) == 1 {
+			return core.ErrLastAccount
+		}
+		p.unlinked = accountID
+		return nil
+	}
+	return core.ErrAccountNotFound
+}
+
+// Requirement: Signed-in users list their accounts, unlink all but the last
+// one and start linking OAuth providers. Draining and impersonated sessions
+// can't change accounts, and the link endpoint is only mounted with OAuth
+// sign-in enabled.
+func TestAccountHandlers(t *testing.T) {
+	session := &core.SessionData{User: &core.User{ID: "u1"}, Session: &core.Session{ID: "s1"}}
+	impersonated := &core.SessionData{User: &core.User{ID: "u1"}, Session: &core.Session{ID: "s1"}, Impersonation: &core.Impersonation{ActorUserID: "admin"}}
+
+	tests := []struct {
+		name         string
+		method       string
+		path         string
+		data         *core.SessionData
+		noToken      bool
+		noOAuth      bool
+		twoAccounts  bool
+		wantStatus   int
+		wantAccounts int
+		wantUnlinked string
+		wantLinkUser string
+	}{
+		{name: "lists accounts", method: http.MethodGet, path: "/accounts", data: session, twoAccounts: true, wantStatus: http.StatusOK, wantAccounts: 2},
+		{name: "list requires a session", method: http.MethodGet, path: "/accounts", data: session, noToken: true, wantStatus: http.StatusUnauthorized},
+		{name: "unlinks an account", method: http.MethodPost, path: "/accounts/acc-2/unlink", data: session, twoAccounts: true, wantStatus: http.StatusOK, wantUnlinked: "acc-2"},
+		{name: "refuses the last account", method: http.MethodPost, path: "/accounts/acc-1/unlink", data: session, wantStatus: http.StatusConflict},
+		{name: "unknown account", method: http.MethodPost, path: "/accounts/acc-9/unlink", data: session, twoAccounts: true, wantStatus: http.StatusNotFound},
+		{name: "impersonated session can't unlink", method: http.MethodPost, path: "/accounts/acc-2/unlink", data: impersonated, twoAccounts: true, wantStatus: http.StatusForbidden},
+		{name: "starts linking a provider", method: http.MethodPost, path: "/link/google", data: session, wantStatus: http.StatusOK, wantLinkUser: "u1"},
+		{name: "unknown provider", method: http.MethodPost, path: "/link/myspace", data: session, wantStatus: http.StatusNotFound},
+		{name: "link not mounted without OAuth", method: http.MethodPost, path: "/link/google", data: session, noOAuth: true, wantStatus: http.StatusNotFound},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// Arrange
+			auth := &accountAuthProvider{mockAuthProvider: &mockAuthProvider{getSessionData: test.data}, oauth: !test.noOAuth, twoAccounts: test.twoAccounts}
+			server := newRouteServer(t, auth, HandlerOptions{})
+			headers := map[string]string{}
+			if !test.noToken {
+				headers["Authorization"] = "Bearer tok"
+			}
+
+			// Act
+			resp := server.do(routeRequest{method: test.method, path: test.path, headers: headers})
+
+			// Assert
+			if resp.status != test.wantStatus {
+				t.Fatalf("status = %d, want %d (body %s)", resp.status, test.wantStatus, resp.body)
+			}
+			if test.wantAccounts > 0 {
+				var accounts []map[string]any
+				if err := json.Unmarshal([]byte(resp.body), &accounts); err != nil || len(accounts) != test.wantAccounts {
+					t.Errorf("accounts = %s, want %d accounts", resp.body, test.wantAccounts)
+				}
+			}
+			if auth.unlinked != test.wantUnlinked {
+				t.Errorf("unlinked %q, want %q", auth.unlinked, test.wantUnlinked)
+			}
+			if auth.linkUserID != test.wantLinkUser {
+				t.Errorf("linking user %q, want %q", auth.linkUserID, test.wantLinkUser)
+			}
+		})
+	}
+}
+
+// oauthAuthProvider adds OAuth sign-in to the mock auth provider
+type oauthAuthProvider struct {
+	*mockAuthProvider
+	providerID string
+	callback   core.OAuthCallback
+}
+
+func (o *oauthAuthProvider) OAuthEnabled() bool { return true }
+
+func (o *oauthAuthProvider) StartOAuth(providerID string) (*core.OAuthStart, error) {
+	if providerID != "apple" {
+		return nil, core.ErrUnknownProvider
+	}
+	return &core.OAuthStart{URL: "https://appleid.apple.com/auth/authorize?state=s1", State: "s1"}, nil
+}
+
+func (o *oauthAuthProvider) CompleteOAuth(ctx context.Context, providerID string, callback core.OAuthCallback, ipAddress, userAgent string) (*core.SignInResult, error) {
+	o.providerID = providerID
+	o.callback = callback
+	return &core.SignInResult{
+		User:    &core.User{ID: "user-1"},
+		Session: &core.Session{ID: "session-1", ExpiresAt: time.Now().Add(time.Hour)},
+		Token:   "session-token",
+	}, nil
+}
+
+// Requirement: The OAuth callback reads the code, state and extra fields
+// from the query, or from the form for form_post providers like Apple, sets
+// the session cookie and redirects to OAuthRedirectURL when configured.
+func TestOAuthHandlers_Callback(t *testing.T) {
+	tests := []struct {
+		name         string
+		request      routeRequest
+		opts         HandlerOptions
+		wantStatus   int
+		wantLocation string
+		wantUser     string
+	}{
+		{
+			name: "form post",
+			request: routeRequest{
+				method:  http.MethodPost,
+				path:    "/callback/apple",
+				body:    `code=c1&state=s1&user=%7B%22name%22%3A%7B%22firstName%22%3A%22Jane%22%7D%7D`,
+				headers: map[string]string{"Content-Type": "application/x-www-form-urlencoded"},
+			},
+			opts:       HandlerOptions{SetCookie: true},
+			wantStatus: http.StatusOK,
+			wantUser:   `{"name":{"firstName":"Jane"}}`,
+		},
+		{
+			name:       "query",
+			request:    routeRequest{method: http.MethodGet, path: "/callback/apple?code=c1&state=s1"},
+			opts:       HandlerOptions{SetCookie: true},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name: "redirects after sign-in",
+			request: routeRequest{
+				method:  http.MethodPost,
+				path:    "/callback/apple",
+				body:    `code=c1&state=s1`,
+				headers: map[string]string{"Content-Type": "application/x-www-form-urlencoded"},
+			},
+			opts:         HandlerOptions{SetCookie: true, OAuthRedirectURL: "https://app.example/"},
+			wantStatus:   http.StatusSeeOther,
+			wantLocation: "https://app.example/",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// Arrange
+			auth := &oauthAuthProvider{mockAuthProvider: &mockAuthProvider{}}
+			server := newRouteServer(t, auth, test.opts)
+
+			// Act
+			resp := server.do(test.request)
+
+			// Assert
+			if resp.status != test.wantStatus {
+				t.Fatalf("status = %d, want %d (body %s)", resp.status, test.wantStatus, resp.body)
+			}
+			if auth.providerID != "apple" || auth.callback.Code != "c1" || auth.callback.State != "s1" {
+				t.Errorf("CompleteOAuth() got %s, %+v", auth.providerID, auth.callback)
+			}
+			if auth.callback.Params["user"] != test.wantUser {
+				t.Errorf("user param = %q, want %q", auth.callback.Params["user"], test.wantUser)
+			}
+			if cookie := resp.cookie("auth_token"); cookie == nil || cookie.Value != "session-token" {
+				t.Errorf("session cookie = %v", cookie)
+			}
+			if location := resp.headers["Location"]; location != test.wantLocation {
+				t.Errorf("Location = %q, want %q", location, test.wantLocation)
+			}
+		})
+	}
+}
+
+// Requirement: GET /sign-in/:provider redirects to the provider, and the
+// OAuth endpoints aren't mounted for auth providers without OAuth.
+func TestOAuthHandlers_SignIn(t *testing.T) {
+	tests := []struct {
+		name         string
+		auth         core.AuthProvider
+		path         string
+		wantStatus   int
+		wantLocation string
+	}{
+		{
+			name:         "redirects to provider",
+			auth:         &oauthAuthProvider{mockAuthProvider: &mockAuthProvider{}},
+			path:         "/sign-in/apple",
+			wantStatus:   http.StatusFound,
+			wantLocation: "https://appleid.apple.com/auth/authorize?state=s1",
+		},
+		{
+			name:       "unknown provider",
+			auth:       &oauthAuthProvider{mockAuthProvider: &mockAuthProvider{}},
+			path:       "/sign-in/nope",
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name:       "not mounted without OAuth",
+			auth:       &mockAuthProvider{},
+			path:       "/sign-in/apple",
+			wantStatus: http.StatusNotFound,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// Arrange
+			server := newRouteServer(t, test.auth, HandlerOptions{})
+
+			// Act
+			resp := server.do(routeRequest{method: http.MethodGet, path: test.path})
+
+			// Assert
+			if resp.status != test.wantStatus {
+				t.Fatalf("status = %d, want %d (body %s)", resp.status, test.wantStatus, resp.body)
+			}
+			if location := resp.headers["Location"]; location != test.wantLocation {
+				t.Errorf("Location = %q, want %q", location, test.wantLocation)
+			}
+		})
+	}
+}
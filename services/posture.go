@@ -0,0 +1,34 @@
+package services
+
+import (
+	"context"
+	"errors"
+
+	"github.com/lborres/kuta/core"
+)
+
+// securityPosture reports what the user has enrolled and which upgrades the
+// policy suggests. It is the one place sign-in and session responses get
+// this from. Returns nil when posture reporting is off; lookup errors also
+// yield nil so a storage hiccup never fails an otherwise valid sign-in.
+func (sm *SessionManager) securityPosture(ctx context.Context, user *core.User) *core.SecurityPosture {
+	if sm.upgradePrompts == nil {
+		return nil
+	}
+
+	passkeys, err := sm.storage.GetAccountByUserAndProvider(ctx, user.ID, core.PasskeyProviderID)
+	if err != nil {
+		return nil
+	}
+	settings, err := sm.storage.GetUserSecurity(ctx, user.ID)
+	if err != nil && !errors.Is(err, core.ErrUserSecurityNotFound) {
+		return nil
+	}
+
+	posture := &core.SecurityPosture{
+		HasPasskey:       len(passkeys) > 0,
+		TwoFactorEnabled: settings != nil && settings.TwoFactorEnabled,
+	}
+	posture.UpgradePrompts = sm.upgradePrompts(user, *posture)
+	return posture
+}
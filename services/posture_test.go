@@ -0,0 +1,76 @@
+package services
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/lborres/kuta/core"
+	"github.com/lborres/kuta/pkg/crypto"
+)
+
+// Requirement: With posture reporting on, sign-in and session responses say
+// what the user has enrolled and which upgrades to suggest.
+func TestSessionManager_SecurityPosture(t *testing.T) {
+	tests := []struct {
+		name        string
+		setup       func(storage *FakeStorageProvider, userID string)
+		wantPasskey bool
+		wantPrompts []string
+	}{
+		{
+			name:        "nothing enrolled",
+			wantPrompts: []string{core.UpgradeAddPasskey, core.UpgradeEnableTwoFactor},
+		},
+		{
+			name: "two factor enrolled",
+			setup: func(storage *FakeStorageProvider, userID string) {
+				_ = storage.UpsertUserSecurity(t.Context(), &core.UserSecurity{UserID: userID, TwoFactorEnabled: true})
+			},
+			wantPrompts: []string{core.UpgradeAddPasskey},
+		},
+		{
+			name: "passkey enrolled",
+			setup: func(storage *FakeStorageProvider, userID string) {
+				_ = storage.CreateAccount(t.Context(), &core.Account{ID: "pk-1", UserID: userID, ProviderID: core.PasskeyProviderID})
+			},
+			wantPasskey: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// Arrange
+			storage := NewFakeStorageProvider()
+			passwords := &crypto.Argon2{Memory: 1024, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32}
+			manager := NewSessionManager(core.SessionConfig{MaxAge: time.Hour}, storage, nil, passwords, WithSecurityPosture(nil))
+			signUp, err := manager.SignUp(t.Context(), core.SignUpInput{Email: "user@example.com", Password: "CorrectPass123!"}, "", "")
+			if err != nil {
+				t.Fatalf("SignUp error: %v", err)
+			}
+			if test.setup != nil {
+				test.setup(storage, signUp.User.ID)
+			}
+
+			// Act
+			signIn, err := manager.SignIn(t.Context(), core.SignInInput{Email: "user@example.com", Password: "CorrectPass123!"}, "", "")
+			if err != nil {
+				t.Fatalf("SignIn error: %v", err)
+			}
+			session, err := manager.GetSession(t.Context(), signIn.Token)
+			if err != nil {
+				t.Fatalf("GetSession error: %v", err)
+			}
+
+			// Assert
+			for _, posture := range []*core.SecurityPosture{signIn.Security, session.Security} {
+				if posture == nil {
+					t.Fatal("expected security posture to be reported")
+				}
+				if posture.HasPasskey != test.wantPasskey || !slices.Equal(posture.UpgradePrompts, test.wantPrompts) {
+					t.Errorf("got %+v, want passkey %v and prompts %v", posture, test.wantPasskey, test.wantPrompts)
+				}
+			}
+		})
+	}
+}
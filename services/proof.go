@@ -0,0 +1,46 @@
+package services
+
+import (
+	"errors"
+	"time"
+
+	"github.com/lborres/kuta/core"
+	"github.com/lborres/kuta/pkg/crypto"
+)
+
+const (
+	defaultProofMaxSkew = time.Minute
+)
+
+// Ensure SessionManager implements ProofVerifier
+var _ core.ProofVerifier = (*SessionManager)(nil)
+
+// VerifyProof checks a request's proof-of-possession against the key bound to
+// the session. Sessions created without a key don't require a proof. Replays
+// of a proof within ProofMaxSkew are accepted (see core.RequestProof).
+func (sm *SessionManager) VerifyProof(session *core.Session, proof *core.RequestProof) error {
+	if session == nil {
+		return core.ErrSessionNotFound
+	}
+	if session.PublicKey == "" {
+		return nil
+	}
+	if proof == nil || proof.Signature == "" {
+		return core.ErrProofRequired
+	}
+
+	maxSkew := sm.config.ProofMaxSkew
+	if maxSkew <= 0 {
+		maxSkew = defaultProofMaxSkew
+	}
+
+	err := crypto.VerifyProof(session.PublicKey, proof.Method, proof.Path, proof.Timestamp, proof.Signature, maxSkew)
+	if err != nil {
+		if errors.Is(err, crypto.ErrStaleProof) || errors.Is(err, crypto.ErrInvalidProof) {
+			return core.ErrInvalidProof
+		}
+		return err
+	}
+
+	return nil
+}
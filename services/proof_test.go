@@ -0,0 +1,145 @@
+package services
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/lborres/kuta/core"
+	"github.com/lborres/kuta/pkg/crypto"
+)
+
+// newProofKey returns an encoded public key and a signer for request proofs
+func newProofKey(t *testing.T) (string, func(method, path string, ts int64) string) {
+	t.Helper()
+	pub, priv, _ := ed25519.GenerateKey(rand.Reader)
+	der, err := x509.MarshalPKIXPublicKey(pub)
+	if err != nil {
+		t.Fatalf("MarshalPKIXPublicKey() error = %v", err)
+	}
+	sign := func(method, path string, ts int64) string {
+		return base64.RawURLEncoding.EncodeToString(ed25519.Sign(priv, crypto.ProofMessage(method, path, ts)))
+	}
+	return base64.RawURLEncoding.EncodeToString(der), sign
+}
+
+// Requirement: SignIn binds the session to a valid public key and rejects malformed keys early.
+func TestSessionManager_SignIn_BindsPublicKey(t *testing.T) {
+	key, _ := newProofKey(t)
+
+	tests := []struct {
+		name      string
+		publicKey string
+		wantErr   error
+	}{
+		{name: "binds valid key", publicKey: key, wantErr: nil},
+		{name: "rejects malformed key", publicKey: "not-a-key", wantErr: core.ErrInvalidPublicKey},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// Arrange
+			storage := NewFakeStorageProvider()
+			passwords := crypto.NewArgon2()
+			service := NewSessionManager(core.SessionConfig{MaxAge: time.Hour}, storage, nil, passwords)
+			_, _ = service.SignUp(t.Context(), core.SignUpInput{Email: "alice@example.com", Password: "SecurePass123!"}, "127.0.0.1", "agent")
+
+			// Act
+			result, err := service.SignIn(t.Context(), core.SignInInput{
+				Email:     "alice@example.com",
+				Password:  "SecurePass123!",
+				PublicKey: test.publicKey,
+			}, "127.0.0.1", "agent")
+
+			// Assert
+			if !errors.Is(err, test.wantErr) {
+				t.Fatalf("SignIn() error = %v, want %v", err, test.wantErr)
+			}
+			if err == nil && result.Session.PublicKey != test.publicKey {
+				t.Errorf("Session.PublicKey = %q, want %q", result.Session.PublicKey, test.publicKey)
+			}
+		})
+	}
+}
+
+// Requirement: VerifyProof requires a valid, fresh signature only for key-bound sessions,
+// and, keeping no state, accepts a proof again while it is fresh.
+func TestSessionManager_VerifyProof(t *testing.T) {
+	key, sign := newProofKey(t)
+	now := time.Now().Unix()
+
+	tests := []struct {
+		name    string
+		session *core.Session
+		proof   *core.RequestProof
+		replay  bool
+		wantErr error
+	}{
+		{name: "unbound session needs no proof", session: &core.Session{}, proof: nil, wantErr: nil},
+		{name: "bound session without proof", session: &core.Session{PublicKey: key}, proof: nil, wantErr: core.ErrProofRequired},
+		{
+			name:    "bound session with valid proof",
+			session: &core.Session{PublicKey: key},
+			proof:   &core.RequestProof{Method: "GET", Path: "/data", Timestamp: now, Signature: sign("GET", "/data", now)},
+			wantErr: nil,
+		},
+		{
+			name:    "proof replayed on another path",
+			session: &core.Session{PublicKey: key},
+			proof:   &core.RequestProof{Method: "GET", Path: "/admin", Timestamp: now, Signature: sign("GET", "/data", now)},
+			wantErr: core.ErrInvalidProof,
+		},
+		{
+			name:    "proof replayed while fresh",
+			session: &core.Session{PublicKey: key},
+			proof:   &core.RequestProof{Method: "GET", Path: "/data", Timestamp: now, Signature: sign("GET", "/data", now)},
+			replay:  true,
+			wantErr: nil,
+		},
+		{
+			name:    "stale proof",
+			session: &core.Session{PublicKey: key},
+			proof:   &core.RequestProof{Method: "GET", Path: "/data", Timestamp: now - 3600, Signature: sign("GET", "/data", now-3600)},
+			wantErr: core.ErrInvalidProof,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			manager := newTestSessionManager(NewFakeStorageProvider(), nil)
+			if test.replay {
+				_ = manager.VerifyProof(test.session, test.proof)
+			}
+
+			err := manager.VerifyProof(test.session, test.proof)
+
+			if !errors.Is(err, test.wantErr) {
+				t.Errorf("VerifyProof() error = %v, want %v", err, test.wantErr)
+			}
+		})
+	}
+}
+
+// Requirement: Refresh keeps the session bound to the same key.
+func TestSessionManager_Refresh_KeepsPublicKey(t *testing.T) {
+	// Arrange
+	key, _ := newProofKey(t)
+	storage := NewFakeStorageProvider()
+	manager := newTestSessionManager(storage, nil)
+	_, refreshToken := createRefreshableSession(t, manager, storage, "user123", key)
+
+	// Act
+	refreshed, err := manager.Refresh(t.Context(), refreshToken)
+
+	// Assert
+	if err != nil {
+		t.Fatalf("Refresh() error = %v", err)
+	}
+	if refreshed.Session.PublicKey != key {
+		t.Errorf("refreshed Session.PublicKey = %q, want %q", refreshed.Session.PublicKey, key)
+	}
+}
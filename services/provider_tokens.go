@@ -0,0 +1,155 @@
+package services
+
+import (
+	"context"
+	"time"
+
+	"github.com/lborres/kuta/core"
+)
+
+const (
+	defaultProviderRefreshInterval = time.Minute
+	defaultProviderRefreshLead     = 5 * time.Minute
+	providerRefreshBatchSize       = 100
+)
+
+// providerTokenRefresh holds the provider token refresh worker settings
+type providerTokenRefresh struct {
+	refreshers map[string]core.TokenRefresher
+	interval   time.Duration
+	lead       time.Duration
+}
+
+// RefreshExpiringProviderTokens refreshes provider access tokens that expire
+// within the configured lead time and returns how many were refreshed.
+// Accounts whose refresh fails are reported through an event and retried on
+// the next pass; accounts of providers without a refresher are skipped.
+func (sm *SessionManager) RefreshExpiringProviderTokens(ctx context.Context) (int, error) {
+	if sm.providerRefresh == nil {
+		return 0, nil
+	}
+	cfg := sm.providerRefresh
+
+	accounts, err := sm.storage.GetExpiringAccounts(ctx, time.Now().Add(cfg.lead), providerRefreshBatchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	refreshed := 0
+	for _, account := range accounts {
+		refresher, ok := cfg.refreshers[account.ProviderID]
+		if !ok || account.RefreshToken == nil {
+			continue
+		}
+
+		tokens, err := refresher.RefreshToken(*account.RefreshToken)
+		if err == nil {
+			account.AccessToken = &tokens.AccessToken
+			if tokens.RefreshToken != "" {
+				account.RefreshToken = &tokens.RefreshToken
+			}
+			account.ExpiresAt = &tokens.ExpiresAt
+			err = sm.storage.UpdateAccount(ctx, account)
+		}
+		if err != nil {
+			sm.emit(core.Event{
+				Type:     core.EventProviderTokenRefreshFailed,
+				UserID:   account.UserID,
+				Metadata: map[string]any{"providerId": account.ProviderID, "error": err.Error()},
+			})
+			continue
+		}
+		refreshed++
+	}
+
+	return refreshed, nil
+}
+
+// RunProviderTokenRefresher calls RefreshExpiringProviderTokens every
+// interval until ctx is done, with storage calls running under ctx. Run it in
+// its own goroutine on one instance:
+// providers that rotate refresh tokens invalidate the old one on use, so
+// concurrent workers would race each other.
+func (sm *SessionManager) RunProviderTokenRefresher(ctx context.Context) {
+	if sm.providerRefresh == nil {
+		return
+	}
+
+	ticker := time.NewTicker(sm.providerRefresh.interval)
+	defer ticker.Stop()
+
+	for {
+		_, _ = sm.RefreshExpiringProviderTokens(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// revokeUserProviderTokens revokes the provider tokens of every account of
+// userID with a provider that supports revocation
+func (sm *SessionManager) revokeUserProviderTokens(ctx context.Context, userID string) {
+	if sm.oauth == nil {
+		return
+	}
+	for _, providerID := range sm.oauth.order {
+		if _, ok := sm.oauth.providers[providerID].(core.OAuthTokenRevoker); !ok {
+			continue
+		}
+		accounts, err := sm.storage.GetAccountByUserAndProvider(ctx, userID, providerID)
+		if err != nil {
+			sm.emitRevokeFailed(userID, providerID, err)
+			continue
+		}
+		for _, account := range accounts {
+			sm.revokeProviderTokens(ctx, account)
+		}
+	}
+}
+
+// revokeProviderTokens ends the provider grant behind account and clears its
+// tokens, so the refresh worker stops using them. Revoking the refresh token
+// ends the whole grant at providers following RFC 7009, so the access token
+// is only revoked when there is no refresh token. A failure is reported
+// through an event rather than returned: the account goes away regardless,
+// and the provider may simply be unreachable.
+func (sm *SessionManager) revokeProviderTokens(ctx context.Context, account *core.Account) {
+	if sm.oauth == nil {
+		return
+	}
+	revoker, ok := sm.oauth.providers[account.ProviderID].(core.OAuthTokenRevoker)
+	if !ok {
+		return
+	}
+
+	var err error
+	switch {
+	case account.RefreshToken != nil:
+		err = revoker.RevokeToken(*account.RefreshToken, "refresh_token")
+	case account.AccessToken != nil:
+		err = revoker.RevokeToken(*account.AccessToken, "access_token")
+	default:
+		return
+	}
+	if err != nil {
+		sm.emitRevokeFailed(account.UserID, account.ProviderID, err)
+		return
+	}
+
+	account.AccessToken = nil
+	account.RefreshToken = nil
+	account.ExpiresAt = nil
+	account.UpdatedAt = time.Now()
+	_ = sm.storage.UpdateAccount(ctx, account)
+}
+
+func (sm *SessionManager) emitRevokeFailed(userID, providerID string, err error) {
+	sm.emit(core.Event{
+		Type:     core.EventProviderTokenRevokeFailed,
+		UserID:   userID,
+		Metadata: map[string]any{"providerId": providerID, "error": err.Error()},
+	})
+}
@@ -0,0 +1,68 @@
+package services
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/lborres/kuta/core"
+	"github.com/lborres/kuta/pkg/crypto"
+)
+
+// Requirement: Provider tokens expiring within the lead time are refreshed;
+// a failing refresh emits an event and does not stop the other accounts.
+func TestRefreshExpiringProviderTokens(t *testing.T) {
+	// Arrange
+	storage := NewFakeStorageProvider()
+	var events []core.Event
+	refreshers := map[string]core.TokenRefresher{
+		"github": core.TokenRefresherFunc(func(refreshToken string) (*core.ProviderTokens, error) {
+			return &core.ProviderTokens{AccessToken: "new-" + refreshToken, RefreshToken: "rotated", ExpiresAt: time.Now().Add(time.Hour)}, nil
+		}),
+		"google": core.TokenRefresherFunc(func(string) (*core.ProviderTokens, error) {
+			return nil, errors.New("invalid_grant")
+		}),
+	}
+	manager := NewSessionManager(core.SessionConfig{MaxAge: time.Hour}, storage, nil, crypto.NewArgon2(),
+		WithProviderTokenRefresh(refreshers, time.Minute, 5*time.Minute),
+		WithEventHandler(core.EventHandlerFunc(func(e core.Event) { events = append(events, e) })),
+	)
+
+	soon, later := time.Now().Add(time.Minute), time.Now().Add(time.Hour)
+	accounts := []*core.Account{
+		{ID: "acc-1", UserID: "user-1", ProviderID: "github", RefreshToken: strPtr("r1"), ExpiresAt: &soon},
+		{ID: "acc-2", UserID: "user-2", ProviderID: "google", RefreshToken: strPtr("r2"), ExpiresAt: &soon},
+		{ID: "acc-3", UserID: "user-3", ProviderID: "github", RefreshToken: strPtr("r3"), ExpiresAt: &later},
+	}
+	for _, a := range accounts {
+		if err := storage.CreateAccount(t.Context(), a); err != nil {
+			t.Fatalf("CreateAccount error: %v", err)
+		}
+	}
+
+	// Act
+	refreshed, err := manager.RefreshExpiringProviderTokens(t.Context())
+
+	// Assert
+	if err != nil {
+		t.Fatalf("RefreshExpiringProviderTokens error: %v", err)
+	}
+	if refreshed != 1 {
+		t.Errorf("expected 1 refreshed account, got %d", refreshed)
+	}
+	got, _ := storage.GetAccountByID(t.Context(), "acc-1")
+	if got.AccessToken == nil || *got.AccessToken != "new-r1" || *got.RefreshToken != "rotated" {
+		t.Errorf("expected acc-1 tokens to be refreshed, got %+v", got)
+	}
+	untouched, _ := storage.GetAccountByID(t.Context(), "acc-3")
+	if untouched.AccessToken != nil {
+		t.Error("expected token outside the lead time to be left alone")
+	}
+	if len(events) != 1 || events[0].Type != core.EventProviderTokenRefreshFailed || events[0].UserID != "user-2" {
+		t.Errorf("expected one refresh failure event for user-2, got %+v", events)
+	}
+}
+
+func strPtr(s string) *string {
+	return &s
+}
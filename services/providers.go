@@ -0,0 +1,159 @@
+package services
+
+import (
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/lborres/kuta/core"
+)
+
+// providerSwitches turns sign-in with individual providers on and off at
+// runtime
+type providerSwitches struct {
+	mu       sync.RWMutex
+	disabled map[string]bool
+
+	// info holds display metadata set with WithProviderInfo, in order
+	info []core.ProviderInfo
+}
+
+func newProviderSwitches() *providerSwitches {
+	return &providerSwitches{disabled: make(map[string]bool)}
+}
+
+// Ensure SessionManager implements ProviderDiscovery
+var _ core.ProviderDiscovery = (*SessionManager)(nil)
+
+// providerNames are the display names of well-known OAuth providers
+var providerNames = map[string]string{
+	"apple":     "Apple",
+	"discord":   "Discord",
+	"facebook":  "Facebook",
+	"github":    "GitHub",
+	"gitlab":    "GitLab",
+	"google":    "Google",
+	"microsoft": "Microsoft",
+	"slack":     "Slack",
+}
+
+// defaultProviderInfo describes a provider no metadata was configured for
+func defaultProviderInfo(id string) core.ProviderInfo {
+	switch id {
+	case core.CredentialProviderID:
+		return core.ProviderInfo{ID: id, Type: core.ProviderTypeCredential, Name: "Email and password", SignInPath: "/sign-in"}
+	case core.PasskeyProviderID:
+		return core.ProviderInfo{ID: id, Type: core.ProviderTypePasskey, Name: "Passkey"}
+	}
+	name, ok := providerNames[id]
+	if !ok && id != "" {
+		name = strings.ToUpper(id[:1]) + id[1:]
+	}
+	return core.ProviderInfo{ID: id, Type: core.ProviderTypeOAuth, Name: name}
+}
+
+// Providers lists the enabled sign-in methods for the discovery endpoint:
+// email and password, OAuth sign-in providers, other OAuth providers with
+// token refreshers and the methods described with WithProviderInfo, in that
+// order.
+func (sm *SessionManager) Providers() []core.ProviderInfo {
+	sm.providers.mu.RLock()
+	configured := slices.Clone(sm.providers.info)
+	sm.providers.mu.RUnlock()
+
+	infos := []core.ProviderInfo{defaultProviderInfo(core.CredentialProviderID)}
+	if sm.oauth != nil {
+		for _, id := range sm.oauth.order {
+			info := defaultProviderInfo(id)
+			info.SignInPath = "/sign-in/" + id
+			infos = append(infos, info)
+		}
+	}
+	if sm.providerRefresh != nil {
+		for _, id := range slices.Sorted(maps.Keys(sm.providerRefresh.refreshers)) {
+			if slices.ContainsFunc(infos, func(p core.ProviderInfo) bool { return p.ID == id }) {
+				continue
+			}
+			infos = append(infos, defaultProviderInfo(id))
+		}
+	}
+	for _, info := range configured {
+		i := slices.IndexFunc(infos, func(p core.ProviderInfo) bool { return p.ID == info.ID })
+		if i < 0 {
+			infos = append(infos, info)
+			continue
+		}
+		if info.SignInPath == "" {
+			info.SignInPath = infos[i].SignInPath
+		}
+		infos[i] = info
+	}
+
+	enabled := make([]core.ProviderInfo, 0, len(infos))
+	for _, info := range infos {
+		if sm.ProviderEnabled(info.ID) {
+			enabled = append(enabled, info)
+		}
+	}
+	return enabled
+}
+
+// SetProviderEnabled turns sign-in (and sign-up) with providerID on or off,
+// e.g. to take a misbehaving OAuth provider offline. Existing sessions are
+// not affected.
+func (sm *SessionManager) SetProviderEnabled(providerID string, enabled bool) {
+	sm.providers.mu.Lock()
+	defer sm.providers.mu.Unlock()
+	sm.providers.disabled[providerID] = !enabled
+}
+
+// ProviderEnabled reports whether sign-in with providerID is allowed
+func (sm *SessionManager) ProviderEnabled(providerID string) bool {
+	sm.providers.mu.RLock()
+	defer sm.providers.mu.RUnlock()
+	return !sm.providers.disabled[providerID]
+}
+
+// ProviderStatuses lists the known providers and whether each is enabled:
+// credential first, then OAuth providers with token refreshers and any other
+// provider that was switched, by ID. OAuth sign-in providers are listed
+// among them.
+func (sm *SessionManager) ProviderStatuses() []core.ProviderStatus {
+	sm.providers.mu.RLock()
+	defer sm.providers.mu.RUnlock()
+
+	ids := make(map[string]struct{})
+	for id := range sm.providers.disabled {
+		ids[id] = struct{}{}
+	}
+	if sm.providerRefresh != nil {
+		for id := range sm.providerRefresh.refreshers {
+			ids[id] = struct{}{}
+		}
+	}
+	if sm.oauth != nil {
+		for id := range sm.oauth.providers {
+			ids[id] = struct{}{}
+		}
+	}
+	delete(ids, core.CredentialProviderID)
+
+	statuses := []core.ProviderStatus{{
+		ID:      core.CredentialProviderID,
+		Enabled: !sm.providers.disabled[core.CredentialProviderID],
+	}}
+	for _, id := range slices.Sorted(maps.Keys(ids)) {
+		statuses = append(statuses, core.ProviderStatus{ID: id, Enabled: !sm.providers.disabled[id]})
+	}
+	return statuses
+}
+
+// checkProviderEnabled returns ErrProviderDisabled when providerID is
+// switched off
+func (sm *SessionManager) checkProviderEnabled(providerID string) error {
+	if !sm.ProviderEnabled(providerID) {
+		return core.ErrProviderDisabled
+	}
+	return nil
+}
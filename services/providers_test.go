@@ -0,0 +1,147 @@
+package services
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/lborres/kuta/core"
+	"github.com/lborres/kuta/pkg/crypto"
+)
+
+// Requirement: Switching the credential provider off blocks sign-up and
+// sign-in until it is switched back on, and the change shows in
+// ProviderStatuses.
+func TestSessionManager_ProviderSwitches(t *testing.T) {
+	tests := []struct {
+		name         string
+		disabled     []string
+		toggle       func(sm *SessionManager)
+		wantErr      error
+		wantStatuses []core.ProviderStatus
+	}{
+		{
+			name:         "enabled by default",
+			wantStatuses: []core.ProviderStatus{{ID: core.CredentialProviderID, Enabled: true}},
+		},
+		{
+			name:         "disabled by option",
+			disabled:     []string{core.CredentialProviderID},
+			wantErr:      core.ErrProviderDisabled,
+			wantStatuses: []core.ProviderStatus{{ID: core.CredentialProviderID, Enabled: false}},
+		},
+		{
+			name:     "re-enabled at runtime",
+			disabled: []string{core.CredentialProviderID},
+			toggle:   func(sm *SessionManager) { sm.SetProviderEnabled(core.CredentialProviderID, true) },
+			wantStatuses: []core.ProviderStatus{
+				{ID: core.CredentialProviderID, Enabled: true},
+			},
+		},
+		{
+			name:   "other provider switched off",
+			toggle: func(sm *SessionManager) { sm.SetProviderEnabled("github", false) },
+			wantStatuses: []core.ProviderStatus{
+				{ID: core.CredentialProviderID, Enabled: true},
+				{ID: "github", Enabled: false},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// Arrange
+			passwords := &crypto.Argon2{Memory: 1024, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32}
+			manager := NewSessionManager(core.SessionConfig{MaxAge: time.Hour}, NewFakeStorageProvider(), nil, passwords,
+				WithDisabledProviders(test.disabled...))
+			if test.toggle != nil {
+				test.toggle(manager)
+			}
+
+			// Act
+			_, signUpErr := manager.SignUp(t.Context(), core.SignUpInput{Email: "user@example.com", Password: "CorrectPass123!"}, "", "")
+			_, signInErr := manager.SignIn(t.Context(), core.SignInInput{Email: "user@example.com", Password: "CorrectPass123!"}, "", "")
+
+			// Assert
+			if !errors.Is(signUpErr, test.wantErr) || !errors.Is(signInErr, test.wantErr) {
+				t.Errorf("SignUp() error = %v, SignIn() error = %v, want %v", signUpErr, signInErr, test.wantErr)
+			}
+			statuses := manager.ProviderStatuses()
+			if len(statuses) != len(test.wantStatuses) {
+				t.Fatalf("ProviderStatuses() = %+v, want %+v", statuses, test.wantStatuses)
+			}
+			for i, want := range test.wantStatuses {
+				if statuses[i] != want {
+					t.Errorf("ProviderStatuses()[%d] = %+v, want %+v", i, statuses[i], want)
+				}
+			}
+		})
+	}
+}
+
+// Requirement: Providers lists the enabled sign-in methods with display
+// metadata: credential first, then OAuth providers with token refreshers,
+// then methods described by the app, which may also replace the defaults.
+func TestSessionManager_Providers(t *testing.T) {
+	refreshers := map[string]core.TokenRefresher{
+		"google": core.TokenRefresherFunc(func(string) (*core.ProviderTokens, error) { return nil, nil }),
+		"github": core.TokenRefresherFunc(func(string) (*core.ProviderTokens, error) { return nil, nil }),
+	}
+
+	tests := []struct {
+		name      string
+		opts      []Option
+		wantIDs   []string
+		wantNames []string
+	}{
+		{
+			name:      "credential only",
+			wantIDs:   []string{core.CredentialProviderID},
+			wantNames: []string{"Email and password"},
+		},
+		{
+			name:      "OAuth providers from refreshers",
+			opts:      []Option{WithProviderTokenRefresh(refreshers, 0, 0)},
+			wantIDs:   []string{core.CredentialProviderID, "github", "google"},
+			wantNames: []string{"Email and password", "GitHub", "Google"},
+		},
+		{
+			name: "app-described methods and overrides",
+			opts: []Option{WithProviderInfo(
+				core.ProviderInfo{ID: core.CredentialProviderID, Name: "Password"},
+				core.ProviderInfo{ID: "magic", Type: core.ProviderTypeMagicLink, Name: "Email link", SignInPath: "/magic-link"},
+			)},
+			wantIDs:   []string{core.CredentialProviderID, "magic"},
+			wantNames: []string{"Password", "Email link"},
+		},
+		{
+			name:      "disabled providers are hidden",
+			opts:      []Option{WithProviderTokenRefresh(refreshers, 0, 0), WithDisabledProviders(core.CredentialProviderID, "google")},
+			wantIDs:   []string{"github"},
+			wantNames: []string{"GitHub"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// Arrange
+			manager := NewSessionManager(core.SessionConfig{MaxAge: time.Hour}, NewFakeStorageProvider(), nil, nil, test.opts...)
+
+			// Act
+			providers := manager.Providers()
+
+			// Assert
+			if len(providers) != len(test.wantIDs) {
+				t.Fatalf("Providers() = %+v, want %v", providers, test.wantIDs)
+			}
+			for i := range providers {
+				if providers[i].ID != test.wantIDs[i] || providers[i].Name != test.wantNames[i] {
+					t.Errorf("Providers()[%d] = %s %q, want %s %q", i, providers[i].ID, providers[i].Name, test.wantIDs[i], test.wantNames[i])
+				}
+				if providers[i].Type == "" {
+					t.Errorf("Providers()[%d] has no type", i)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,17 @@
+package services
+
+import "github.com/lborres/kuta/core"
+
+// allowRequest spends one request of the client IP's quota for op. Limiter
+// errors fail open, like throttle store errors: rate limiting sheds abuse,
+// it shouldn't take sign-in down with the limiter's backend.
+func (sm *SessionManager) allowRequest(op, ipAddress string) error {
+	if sm.rateLimiter == nil || ipAddress == "" {
+		return nil
+	}
+	decision, err := sm.rateLimiter.Allow(op + ":" + ipAddress)
+	if err != nil || decision.Allowed {
+		return nil
+	}
+	return core.ErrRateLimited
+}
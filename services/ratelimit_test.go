@@ -0,0 +1,67 @@
+package services
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/lborres/kuta/core"
+	"github.com/lborres/kuta/pkg/crypto"
+)
+
+type fakeRateLimiter struct {
+	allowed int
+	err     error
+	keys    []string
+}
+
+func (f *fakeRateLimiter) Allow(key string) (core.RateLimitDecision, error) {
+	f.keys = append(f.keys, key)
+	if f.err != nil {
+		return core.RateLimitDecision{}, f.err
+	}
+	if len(f.keys) > f.allowed {
+		return core.RateLimitDecision{RetryAfter: time.Second}, nil
+	}
+	return core.RateLimitDecision{Allowed: true}, nil
+}
+
+// Requirement: Sign-up and sign-in are refused with ErrRateLimited once the
+// client IP's quota is spent; limiter errors fail open.
+func TestSessionManager_RateLimit(t *testing.T) {
+	tests := []struct {
+		name    string
+		limiter *fakeRateLimiter
+		ip      string
+		wantErr error
+	}{
+		{name: "within quota", limiter: &fakeRateLimiter{allowed: 2}, ip: "10.0.0.1"},
+		{name: "quota spent", limiter: &fakeRateLimiter{allowed: 1}, ip: "10.0.0.1", wantErr: core.ErrRateLimited},
+		{name: "limiter down", limiter: &fakeRateLimiter{err: errors.New("redis down")}, ip: "10.0.0.1"},
+		{name: "no client ip", limiter: &fakeRateLimiter{}, ip: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			passwords := &crypto.Argon2{Memory: 1024, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32}
+			manager := NewSessionManager(core.SessionConfig{MaxAge: time.Hour}, NewFakeStorageProvider(), nil, passwords,
+				WithRateLimiter(tt.limiter))
+
+			// Act
+			_, signUpErr := manager.SignUp(t.Context(), core.SignUpInput{Email: "user@example.com", Password: "CorrectPass123!", Name: "User"}, tt.ip, "agent")
+			_, signInErr := manager.SignIn(t.Context(), core.SignInInput{Email: "user@example.com", Password: "CorrectPass123!"}, tt.ip, "agent")
+
+			// Assert
+			if signUpErr != nil {
+				t.Fatalf("SignUp error: %v", signUpErr)
+			}
+			if !errors.Is(signInErr, tt.wantErr) {
+				t.Errorf("expected %v, got %v", tt.wantErr, signInErr)
+			}
+			if tt.ip != "" && (len(tt.limiter.keys) != 2 || tt.limiter.keys[0] != "signup:"+tt.ip || tt.limiter.keys[1] != "signin:"+tt.ip) {
+				t.Errorf("expected signup and signin keys for %s, got %v", tt.ip, tt.limiter.keys)
+			}
+		})
+	}
+}
@@ -0,0 +1,188 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/lborres/kuta/core"
+	"github.com/lborres/kuta/pkg/crypto"
+)
+
+const (
+	defaultRefreshMaxAge = 30 * 24 * time.Hour
+)
+
+// issueRefreshToken creates a refresh token for session. parent is the token
+// it replaces, if any; without one a new chain starts, subject to
+// MaxRefreshDevices.
+func (sm *SessionManager) issueRefreshToken(ctx context.Context, session *core.Session, parent *core.RefreshToken) (string, error) {
+	pair, err := crypto.GenerateHashedToken()
+	if err != nil {
+		return "", err
+	}
+
+	id, err := sm.nanoid.Generate()
+	if err != nil {
+		return "", err
+	}
+
+	maxAge := sm.config.RefreshMaxAge
+	if maxAge == 0 {
+		maxAge = defaultRefreshMaxAge
+	}
+
+	now := time.Now()
+	refresh := &core.RefreshToken{
+		ID:              id,
+		UserID:          session.UserID,
+		SessionID:       session.ID,
+		TokenHash:       pair.Hash,
+		IPAddress:       session.IPAddress,
+		UserAgent:       session.UserAgent,
+		PublicKey:       session.PublicKey,
+		ExpiresAt:       now.Add(maxAge),
+		AuthenticatedAt: now,
+		CreatedAt:       now,
+	}
+	if parent != nil {
+		refresh.ParentID = &parent.ID
+		refresh.AuthenticatedAt = parent.AuthenticatedAt
+	} else if err := sm.enforceRefreshDeviceLimit(ctx, session.UserID); err != nil {
+		return "", err
+	}
+
+	// Never outlive the chain's absolute lifetime
+	if absolute := sm.config.RefreshAbsoluteMaxAge; absolute > 0 {
+		if end := refresh.AuthenticatedAt.Add(absolute); end.Before(refresh.ExpiresAt) {
+			refresh.ExpiresAt = end
+		}
+	}
+
+	if err := sm.storage.CreateRefreshToken(ctx, refresh); err != nil {
+		return "", err
+	}
+
+	return pair.Token, nil
+}
+
+// enforceRefreshDeviceLimit makes room for a new refresh token chain by
+// signing out the user's oldest devices once MaxRefreshDevices is reached
+func (sm *SessionManager) enforceRefreshDeviceLimit(ctx context.Context, userID string) error {
+	limit := sm.config.MaxRefreshDevices
+	if limit <= 0 {
+		return nil
+	}
+
+	active, err := sm.storage.GetActiveUserRefreshTokens(ctx, userID)
+	if err != nil {
+		return err
+	}
+	for _, oldest := range active[:max(len(active)-limit+1, 0)] {
+		err := sm.RevokeSession(ctx, oldest.SessionID, core.RevokeReasonDeviceLimit)
+		if errors.Is(err, core.ErrSessionNotFound) {
+			// The session is gone already; retire the token by itself
+			err = sm.storage.RevokeRefreshToken(ctx, oldest.ID)
+			if errors.Is(err, core.ErrRefreshTokenNotFound) {
+				err = nil
+			}
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Refresh exchanges a refresh token for a new session and a new refresh token.
+// The presented token is rotated; presenting it again ends every session and
+// revokes every refresh token of the user, since it has most likely leaked.
+// Tokens revoked otherwise, by sign-out or an admin, are just refused.
+func (sm *SessionManager) Refresh(ctx context.Context, refreshToken string) (*core.RefreshResult, error) {
+	// Validate input
+	if refreshToken == "" {
+		return nil, core.ErrInvalidToken
+	}
+
+	stored, err := sm.storage.GetRefreshTokenByHash(ctx, crypto.HashToken(refreshToken))
+	if err != nil {
+		if errors.Is(err, core.ErrRefreshTokenNotFound) {
+			return nil, core.ErrInvalidToken
+		}
+		return nil, err
+	}
+
+	if stored.RotatedAt != nil {
+		// Sessions end at once, not drained: whoever holds the leaked chain
+		// may be using one of them
+		if count, err := sm.destroyAllUserSessions(ctx, stored.UserID); err == nil {
+			sm.emitSessionsRevoked(stored.UserID, count, core.RevokeReasonRefreshReuse)
+		}
+		return nil, core.ErrInvalidToken
+	}
+	if stored.RevokedAt != nil {
+		return nil, core.ErrInvalidToken
+	}
+
+	if time.Now().After(stored.ExpiresAt) {
+		return nil, core.ErrSessionExpired
+	}
+	// Past the absolute lifetime the user has to sign in again, however
+	// regularly the chain was rotated
+	if absolute := sm.config.RefreshAbsoluteMaxAge; absolute > 0 && time.Since(stored.AuthenticatedAt) > absolute {
+		return nil, core.ErrSessionExpired
+	}
+
+	// Claim the token; a concurrent refresh that got here first wins
+	if err := sm.storage.RotateRefreshToken(ctx, stored.ID); err != nil {
+		if errors.Is(err, core.ErrRefreshTokenNotFound) {
+			return nil, core.ErrInvalidToken
+		}
+		return nil, err
+	}
+
+	// The user may have been deleted since the token was issued
+	if _, err := sm.storage.GetUserByID(ctx, stored.UserID); err != nil {
+		if errors.Is(err, core.ErrUserNotFound) {
+			return nil, core.ErrInvalidToken
+		}
+		return nil, err
+	}
+
+	// Retire the session issued alongside the old token, keeping its values
+	// for the new one. Rotation isn't a revocation, so no event is recorded.
+	var values map[string]string
+	var activeOrganizationID string
+	if sm.sealer == nil {
+		if old, err := sm.storage.GetSessionByID(ctx, stored.SessionID); err == nil {
+			values = old.Data
+			activeOrganizationID = old.ActiveOrganizationID
+		}
+		if _, err := sm.destroyBySessionID(ctx, stored.SessionID); err != nil && !errors.Is(err, core.ErrSessionNotFound) {
+			return nil, err
+		}
+	}
+
+	// Create new session with same userID, IP, UserAgent and bound key
+	sessionResult, err := sm.createSession(ctx, stored.UserID, stored.IPAddress, stored.UserAgent, stored.PublicKey, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := sm.carrySessionValues(ctx, sessionResult.Session, values); err != nil {
+		return nil, err
+	}
+	if err := sm.carryActiveOrganization(ctx, sessionResult.Session, activeOrganizationID); err != nil {
+		return nil, err
+	}
+
+	newRefreshToken, err := sm.issueRefreshToken(ctx, sessionResult.Session, stored)
+	if err != nil {
+		return nil, err
+	}
+
+	return &core.RefreshResult{
+		Session:      sessionResult.Session,
+		Token:        sessionResult.Token,
+		RefreshToken: newRefreshToken,
+	}, nil
+}
@@ -0,0 +1,280 @@
+package services
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/lborres/kuta/core"
+	"github.com/lborres/kuta/pkg/crypto"
+)
+
+// createRefreshableSession creates a user, a session bound to publicKey and a
+// refresh token for it, returning the session result and the raw refresh token.
+func createRefreshableSession(t *testing.T, manager *SessionManager, storage *FakeStorageProvider, userID, publicKey string) (*core.CreateSessionResult, string) {
+	t.Helper()
+	_ = storage.CreateUser(t.Context(), &core.User{ID: userID, Email: userID + "@example.com"})
+
+	created, err := manager.createSession(t.Context(), userID, "127.0.0.1", "test-agent", publicKey, nil)
+	if err != nil {
+		t.Fatalf("createSession() error = %v", err)
+	}
+	refreshToken, err := manager.issueRefreshToken(t.Context(), created.Session, nil)
+	if err != nil {
+		t.Fatalf("issueRefreshToken() error = %v", err)
+	}
+	return created, refreshToken
+}
+
+// Requirement: Refresh tokens are stored apart from sessions and each rotation
+// records its parent.
+func TestSessionManager_Refresh_RotationLineage(t *testing.T) {
+	// Arrange
+	storage := NewFakeStorageProvider()
+	manager := newTestSessionManager(storage, nil)
+	created, first := createRefreshableSession(t, manager, storage, "user-1", "")
+
+	// Act
+	second, err := manager.Refresh(t.Context(), first)
+	if err != nil {
+		t.Fatalf("first Refresh() error = %v", err)
+	}
+	third, err := manager.Refresh(t.Context(), second.RefreshToken)
+	if err != nil {
+		t.Fatalf("second Refresh() error = %v", err)
+	}
+
+	// Assert
+	if len(storage.refreshTokens) != 3 {
+		t.Fatalf("expected 3 stored refresh tokens, got %d", len(storage.refreshTokens))
+	}
+	var root, middle, leaf *core.RefreshToken
+	for _, token := range storage.refreshTokens {
+		switch token.SessionID {
+		case created.Session.ID:
+			root = token
+		case second.Session.ID:
+			middle = token
+		case third.Session.ID:
+			leaf = token
+		}
+	}
+	if root == nil || middle == nil || leaf == nil {
+		t.Fatal("expected one refresh token per issued session")
+	}
+	if root.ParentID != nil {
+		t.Errorf("root ParentID = %v, want nil", *root.ParentID)
+	}
+	if middle.ParentID == nil || *middle.ParentID != root.ID {
+		t.Errorf("middle token should point at root %q", root.ID)
+	}
+	if leaf.ParentID == nil || *leaf.ParentID != middle.ID {
+		t.Errorf("leaf token should point at middle %q", middle.ID)
+	}
+	if root.RevokedAt == nil || middle.RevokedAt == nil || leaf.RevokedAt != nil {
+		t.Error("only the latest refresh token should remain active")
+	}
+}
+
+// Requirement: Presenting a rotated refresh token revokes all of the user's
+// refresh tokens and ends their sessions, reporting how many were ended.
+func TestSessionManager_Refresh_ReuseRevokesUserTokens(t *testing.T) {
+	// Arrange
+	storage := NewFakeStorageProvider()
+	var events []core.Event
+	manager := NewSessionManager(core.SessionConfig{MaxAge: 24 * time.Hour}, storage, nil, crypto.NewArgon2(),
+		WithEventHandler(core.EventHandlerFunc(func(e core.Event) { events = append(events, e) })))
+	_, first := createRefreshableSession(t, manager, storage, "user-1", "")
+	rotated, err := manager.Refresh(t.Context(), first)
+	if err != nil {
+		t.Fatalf("Refresh() error = %v", err)
+	}
+	other, err := manager.Create(t.Context(), "user-1", "", "")
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+
+	// Act
+	_, reuseErr := manager.Refresh(t.Context(), first)
+	_, latestErr := manager.Refresh(t.Context(), rotated.RefreshToken)
+
+	// Assert
+	if !errors.Is(reuseErr, core.ErrInvalidToken) {
+		t.Errorf("reused token error = %v, want %v", reuseErr, core.ErrInvalidToken)
+	}
+	if !errors.Is(latestErr, core.ErrInvalidToken) {
+		t.Errorf("latest token after reuse error = %v, want %v", latestErr, core.ErrInvalidToken)
+	}
+	for _, token := range []string{rotated.Token, other.Token} {
+		if _, err := manager.GetSession(t.Context(), token); !errors.Is(err, core.ErrSessionNotFound) {
+			t.Errorf("GetSession() after reuse error = %v, want %v", err, core.ErrSessionNotFound)
+		}
+	}
+	if len(events) == 0 || events[0].Metadata["reason"] != core.RevokeReasonRefreshReuse || events[0].Metadata["count"] != 2 {
+		t.Errorf("events = %+v, want a %s revocation of 2 sessions first", events, core.RevokeReasonRefreshReuse)
+	}
+}
+
+// Requirement: Refresh tokens stop working once their session is signed out or the user is removed.
+// Presenting such a token is refused without ending the user's other sessions,
+// which only a rotated token presented again does.
+func TestSessionManager_Refresh_RevokedWithSession(t *testing.T) {
+	tests := []struct {
+		name       string
+		revoke     func(*SessionManager, *core.CreateSessionResult) error
+		endsOthers bool
+	}{
+		{
+			name: "sign out",
+			revoke: func(manager *SessionManager, created *core.CreateSessionResult) error {
+				return manager.SignOut(t.Context(), created.Token)
+			},
+		},
+		{
+			name: "destroy by session id",
+			revoke: func(manager *SessionManager, created *core.CreateSessionResult) error {
+				return manager.DestroyBySessionID(t.Context(), created.Session.ID)
+			},
+		},
+		{
+			name: "destroy all user sessions",
+			revoke: func(manager *SessionManager, created *core.CreateSessionResult) error {
+				_, err := manager.DestroyAllUserSessions(t.Context(), created.Session.UserID)
+				return err
+			},
+			endsOthers: true,
+		},
+		{
+			name: "admin revoke",
+			revoke: func(manager *SessionManager, created *core.CreateSessionResult) error {
+				_, err := manager.RevokeSessions(t.Context(), []string{created.Session.ID})
+				return err
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// Arrange
+			storage := NewFakeStorageProvider()
+			manager := newTestSessionManager(storage, nil)
+			created, refreshToken := createRefreshableSession(t, manager, storage, "user-1", "")
+			other, err := manager.Create(t.Context(), "user-1", "", "")
+			if err != nil {
+				t.Fatalf("Create() error = %v", err)
+			}
+
+			// Act
+			if err := test.revoke(manager, created); err != nil {
+				t.Fatalf("revoke error = %v", err)
+			}
+			_, err = manager.Refresh(t.Context(), refreshToken)
+
+			// Assert
+			if !errors.Is(err, core.ErrInvalidToken) {
+				t.Errorf("Refresh() error = %v, want %v", err, core.ErrInvalidToken)
+			}
+			_, err = manager.GetSession(t.Context(), other.Token)
+			if ended := errors.Is(err, core.ErrSessionNotFound); ended != test.endsOthers {
+				t.Errorf("other session GetSession() error = %v, want ended = %v", err, test.endsOthers)
+			}
+		})
+	}
+}
+
+// Requirement: Expired refresh tokens are rejected.
+func TestSessionManager_Refresh_Expired(t *testing.T) {
+	// Arrange
+	storage := NewFakeStorageProvider()
+	config := core.SessionConfig{MaxAge: time.Hour, RefreshMaxAge: -time.Minute}
+	manager := NewSessionManager(config, storage, nil, nil)
+	_, refreshToken := createRefreshableSession(t, manager, storage, "user-1", "")
+
+	// Act
+	_, err := manager.Refresh(t.Context(), refreshToken)
+
+	// Assert
+	if !errors.Is(err, core.ErrSessionExpired) {
+		t.Errorf("Refresh() error = %v, want %v", err, core.ErrSessionExpired)
+	}
+}
+
+// Requirement: A rotation chain keeps the time of its sign-in, and refreshing
+// past RefreshAbsoluteMaxAge fails even though every rotation was on time.
+func TestSessionManager_Refresh_AbsoluteLifetime(t *testing.T) {
+	// Arrange
+	storage := NewFakeStorageProvider()
+	config := core.SessionConfig{MaxAge: time.Hour, RefreshAbsoluteMaxAge: 80 * time.Millisecond}
+	manager := NewSessionManager(config, storage, nil, crypto.NewArgon2())
+	_, first := createRefreshableSession(t, manager, storage, "user-1", "")
+
+	// Act
+	second, err := manager.Refresh(t.Context(), first)
+	if err != nil {
+		t.Fatalf("first Refresh() error = %v", err)
+	}
+	time.Sleep(100 * time.Millisecond)
+	_, err = manager.Refresh(t.Context(), second.RefreshToken)
+
+	// Assert
+	if !errors.Is(err, core.ErrSessionExpired) {
+		t.Errorf("Refresh() past absolute lifetime error = %v, want ErrSessionExpired", err)
+	}
+	var authenticatedAt []time.Time
+	for _, token := range storage.refreshTokens {
+		authenticatedAt = append(authenticatedAt, token.AuthenticatedAt)
+		if token.ExpiresAt.After(token.AuthenticatedAt.Add(config.RefreshAbsoluteMaxAge)) {
+			t.Errorf("token expires at %v, after the chain's absolute end", token.ExpiresAt)
+		}
+	}
+	if len(authenticatedAt) != 2 || !authenticatedAt[0].Equal(authenticatedAt[1]) {
+		t.Errorf("expected rotation to keep AuthenticatedAt, got %v", authenticatedAt)
+	}
+}
+
+// Requirement: Starting a refresh chain beyond MaxRefreshDevices signs out
+// the user's oldest devices, sessions included.
+func TestSessionManager_Refresh_DeviceLimit(t *testing.T) {
+	tests := []struct {
+		name        string
+		limit       int
+		chains      int
+		wantRevoked int
+	}{
+		{name: "no limit", limit: 0, chains: 3, wantRevoked: 0},
+		{name: "under limit", limit: 3, chains: 3, wantRevoked: 0},
+		{name: "over limit revokes oldest", limit: 2, chains: 4, wantRevoked: 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			storage := NewFakeStorageProvider()
+			config := core.SessionConfig{MaxAge: time.Hour, MaxRefreshDevices: tt.limit}
+			manager := NewSessionManager(config, storage, nil, crypto.NewArgon2())
+
+			// Act
+			var created []*core.CreateSessionResult
+			for range tt.chains {
+				session, _ := createRefreshableSession(t, manager, storage, "user-1", "")
+				created = append(created, session)
+				time.Sleep(time.Millisecond) // keep CreatedAt ordered
+			}
+
+			// Assert
+			active, err := storage.GetActiveUserRefreshTokens(t.Context(), "user-1")
+			if err != nil {
+				t.Fatalf("GetActiveUserRefreshTokens() error = %v", err)
+			}
+			if len(active) != tt.chains-tt.wantRevoked {
+				t.Errorf("active refresh tokens = %d, want %d", len(active), tt.chains-tt.wantRevoked)
+			}
+			for i, session := range created {
+				_, err := storage.GetSessionByID(t.Context(), session.Session.ID)
+				if revoked := i < tt.wantRevoked; revoked != errors.Is(err, core.ErrSessionNotFound) {
+					t.Errorf("session %d: revoked = %v, lookup error = %v", i, revoked, err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,45 @@
+package services
+
+import "github.com/lborres/kuta/core"
+
+// evictCached drops sessions from the local cache and tells other instances
+// to do the same.
+func (sm *SessionManager) evictCached(tokenHashes ...string) {
+	if sm.cache != nil {
+		for _, hash := range tokenHashes {
+			_ = sm.cache.Delete(hash)
+		}
+	}
+	sm.publishRevocation(core.Revocation{TokenHashes: tokenHashes})
+}
+
+// evictAllCached clears the local cache and tells other instances to do the same.
+func (sm *SessionManager) evictAllCached() {
+	if sm.cache != nil {
+		_ = sm.cache.Clear()
+	}
+	sm.publishRevocation(core.Revocation{All: true})
+}
+
+func (sm *SessionManager) publishRevocation(revocation core.Revocation) {
+	if sm.revocations == nil {
+		return
+	}
+	revocation.Origin = sm.instanceID
+	// Best effort: the cache TTL still bounds how long others serve it
+	_ = sm.revocations.Publish(revocation)
+}
+
+// handleRevocation applies revocations published by other instances
+func (sm *SessionManager) handleRevocation(revocation core.Revocation) {
+	if sm.cache == nil || revocation.Origin == sm.instanceID {
+		return
+	}
+	if revocation.All {
+		_ = sm.cache.Clear()
+		return
+	}
+	for _, hash := range revocation.TokenHashes {
+		_ = sm.cache.Delete(hash)
+	}
+}
@@ -0,0 +1,72 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/lborres/kuta/core"
+	"github.com/lborres/kuta/pkg/crypto"
+	"github.com/lborres/kuta/pkg/revocation"
+)
+
+// Requirement: Revoking a session on one instance evicts it from every instance's cache.
+func TestSessionManager_RevocationBus(t *testing.T) {
+	tests := []struct {
+		name   string
+		revoke func(origin *SessionManager, created *core.CreateSessionResult) error
+	}{
+		{
+			name: "destroy by token",
+			revoke: func(origin *SessionManager, created *core.CreateSessionResult) error {
+				return origin.Destroy(t.Context(), created.Token)
+			},
+		},
+		{
+			name: "destroy by session id",
+			revoke: func(origin *SessionManager, created *core.CreateSessionResult) error {
+				return origin.DestroyBySessionID(t.Context(), created.Session.ID)
+			},
+		},
+		{
+			name: "destroy all user sessions",
+			revoke: func(origin *SessionManager, created *core.CreateSessionResult) error {
+				_, err := origin.DestroyAllUserSessions(t.Context(), created.Session.UserID)
+				return err
+			},
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			// Arrange: two instances sharing storage and a bus, each with its own cache
+			storage := NewFakeStorageProvider()
+			bus := revocation.NewLocalBus()
+			config := core.SessionConfig{MaxAge: time.Hour}
+			originCache, peerCache := NewFakeCache(), NewFakeCache()
+			origin := NewSessionManager(config, storage, originCache, crypto.NewArgon2(), WithRevocationBus(bus))
+			peer := NewSessionManager(config, storage, peerCache, crypto.NewArgon2(), WithRevocationBus(bus))
+
+			created, err := origin.Create(t.Context(), "user-1", "", "")
+			if err != nil {
+				t.Fatalf("Create() error = %v", err)
+			}
+			if _, err := peer.Verify(t.Context(), created.Token); err != nil {
+				t.Fatalf("peer Verify() error = %v", err)
+			}
+
+			// Act
+			if err := test.revoke(origin, created); err != nil {
+				t.Fatalf("revoke error = %v", err)
+			}
+
+			// Assert
+			if peerCache.Len() != 0 {
+				t.Errorf("peer cache still holds %d sessions", peerCache.Len())
+			}
+			if _, err := peer.Verify(t.Context(), created.Token); err == nil {
+				t.Error("peer should reject the revoked session")
+			}
+		})
+	}
+}
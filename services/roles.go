@@ -0,0 +1,200 @@
+package services
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lborres/kuta/core"
+)
+
+const defaultRoleCacheTTL = time.Minute
+
+// roles is where user roles and role permissions are kept, with a cache of
+// lookups in front of the storage
+type roles struct {
+	store core.RoleStorage
+	cache *roleCache
+}
+
+// roleCache caches GetUserRoles and GetRolePermissions results for ttl.
+// Writes through the manager drop the entries they change; writes from
+// other instances show up once the entries expire.
+type roleCache struct {
+	mu          sync.Mutex
+	ttl         time.Duration
+	userRoles   map[string]cachedList // user ID -> roles
+	permissions map[string]cachedList // role -> permissions
+}
+
+type cachedList struct {
+	values   []string
+	cachedAt time.Time
+}
+
+func newRoleCache(ttl time.Duration) *roleCache {
+	return &roleCache{
+		ttl:         ttl,
+		userRoles:   make(map[string]cachedList),
+		permissions: make(map[string]cachedList),
+	}
+}
+
+// lookup returns a copy of the cached list for key in entries, loading and
+// caching it on a miss or once it has expired
+func (c *roleCache) lookup(entries map[string]cachedList, key string, load func() ([]string, error)) ([]string, error) {
+	c.mu.Lock()
+	entry, ok := entries[key]
+	c.mu.Unlock()
+	if ok && time.Since(entry.cachedAt) <= c.ttl {
+		return slices.Clone(entry.values), nil
+	}
+
+	values, err := load()
+	if err != nil {
+		return nil, err
+	}
+	values = normalizeNames(values)
+
+	c.mu.Lock()
+	entries[key] = cachedList{values: values, cachedAt: time.Now()}
+	c.mu.Unlock()
+	return slices.Clone(values), nil
+}
+
+// forget drops the cached list for key in entries
+func (c *roleCache) forget(entries map[string]cachedList, key string) {
+	c.mu.Lock()
+	delete(entries, key)
+	c.mu.Unlock()
+}
+
+// normalizeNames sorts names and drops empty and duplicate ones
+func normalizeNames(names []string) []string {
+	normalized := make([]string, 0, len(names))
+	for _, name := range names {
+		if name = strings.TrimSpace(name); name != "" {
+			normalized = append(normalized, name)
+		}
+	}
+	slices.Sort(normalized)
+	return slices.Compact(normalized)
+}
+
+// validateRole checks a role name given to a role operation
+func validateRole(role string) error {
+	var v core.Validator
+	if strings.TrimSpace(role) == "" {
+		v.Add("role", core.CodeRoleRequired, core.ErrRoleRequired)
+	}
+	return v.Err()
+}
+
+// AssignRole grants role to the user, who must exist
+func (sm *SessionManager) AssignRole(ctx context.Context, userID, role string) error {
+	if sm.roles == nil {
+		return core.ErrNotImplemented
+	}
+	if err := validateRole(role); err != nil {
+		return err
+	}
+	if _, err := sm.storage.GetUserByID(ctx, userID); err != nil {
+		return err
+	}
+
+	if err := sm.roles.store.AssignRole(ctx, userID, strings.TrimSpace(role)); err != nil {
+		return err
+	}
+	sm.roles.cache.forget(sm.roles.cache.userRoles, userID)
+	return nil
+}
+
+// RevokeRole takes role away from the user
+func (sm *SessionManager) RevokeRole(ctx context.Context, userID, role string) error {
+	if sm.roles == nil {
+		return core.ErrNotImplemented
+	}
+	if err := validateRole(role); err != nil {
+		return err
+	}
+
+	if err := sm.roles.store.RevokeRole(ctx, userID, strings.TrimSpace(role)); err != nil {
+		return err
+	}
+	sm.roles.cache.forget(sm.roles.cache.userRoles, userID)
+	return nil
+}
+
+// GetUserRoles returns the user's roles, sorted
+func (sm *SessionManager) GetUserRoles(ctx context.Context, userID string) ([]string, error) {
+	if sm.roles == nil {
+		return nil, core.ErrNotImplemented
+	}
+	return sm.roles.cache.lookup(sm.roles.cache.userRoles, userID, func() ([]string, error) {
+		return sm.roles.store.GetUserRoles(ctx, userID)
+	})
+}
+
+// SetRolePermissions replaces the permissions of role. Empty and duplicate
+// permissions are dropped.
+func (sm *SessionManager) SetRolePermissions(ctx context.Context, role string, permissions []string) error {
+	if sm.roles == nil {
+		return core.ErrNotImplemented
+	}
+	if err := validateRole(role); err != nil {
+		return err
+	}
+
+	role = strings.TrimSpace(role)
+	if err := sm.roles.store.SetRolePermissions(ctx, role, normalizeNames(permissions)); err != nil {
+		return err
+	}
+	sm.roles.cache.forget(sm.roles.cache.permissions, role)
+	return nil
+}
+
+// GetRolePermissions returns the permissions of role, sorted
+func (sm *SessionManager) GetRolePermissions(ctx context.Context, role string) ([]string, error) {
+	if sm.roles == nil {
+		return nil, core.ErrNotImplemented
+	}
+	if err := validateRole(role); err != nil {
+		return nil, err
+	}
+
+	role = strings.TrimSpace(role)
+	return sm.roles.cache.lookup(sm.roles.cache.permissions, role, func() ([]string, error) {
+		return sm.roles.store.GetRolePermissions(ctx, role)
+	})
+}
+
+// GetUserPermissions returns the permissions of all the user's roles, sorted
+// and without duplicates
+func (sm *SessionManager) GetUserPermissions(ctx context.Context, userID string) ([]string, error) {
+	userRoles, err := sm.GetUserRoles(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	var permissions []string
+	for _, role := range userRoles {
+		rolePermissions, err := sm.GetRolePermissions(ctx, role)
+		if err != nil {
+			return nil, err
+		}
+		permissions = append(permissions, rolePermissions...)
+	}
+	return normalizeNames(permissions), nil
+}
+
+// HasPermission reports whether any of the user's roles grants permission
+func (sm *SessionManager) HasPermission(ctx context.Context, userID, permission string) (bool, error) {
+	permissions, err := sm.GetUserPermissions(ctx, userID)
+	if err != nil {
+		return false, err
+	}
+	_, found := slices.BinarySearch(permissions, permission)
+	return found, nil
+}
@@ -0,0 +1,162 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/lborres/kuta/core"
+	"github.com/lborres/kuta/pkg/cache"
+)
+
+// countingRoleStorage counts the lookups that reach the storage
+type countingRoleStorage struct {
+	*cache.InMemoryRoleStorage
+	userLookups       int
+	permissionLookups int
+}
+
+func (s *countingRoleStorage) GetUserRoles(ctx context.Context, userID string) ([]string, error) {
+	s.userLookups++
+	return s.InMemoryRoleStorage.GetUserRoles(ctx, userID)
+}
+
+func (s *countingRoleStorage) GetRolePermissions(ctx context.Context, role string) ([]string, error) {
+	s.permissionLookups++
+	return s.InMemoryRoleStorage.GetRolePermissions(ctx, role)
+}
+
+// Requirement: Permissions of all the user's roles are combined, role
+// assignments only apply to existing users, and role names are required.
+func TestSessionManager_Roles(t *testing.T) {
+	tests := []struct {
+		name            string
+		userID          string
+		assign          []string
+		revoke          []string
+		wantErr         error
+		wantRoles       []string
+		wantPermissions []string
+	}{
+		{
+			name:            "permissions of every role",
+			userID:          "user-1",
+			assign:          []string{"editor", "viewer"},
+			wantRoles:       []string{"editor", "viewer"},
+			wantPermissions: []string{"posts.read", "posts.write"},
+		},
+		{
+			name:            "assigning twice",
+			userID:          "user-1",
+			assign:          []string{"viewer", "viewer"},
+			wantRoles:       []string{"viewer"},
+			wantPermissions: []string{"posts.read"},
+		},
+		{
+			name:            "revoked role",
+			userID:          "user-1",
+			assign:          []string{"editor", "viewer"},
+			revoke:          []string{"editor", "admin"},
+			wantRoles:       []string{"viewer"},
+			wantPermissions: []string{"posts.read"},
+		},
+		{
+			name:    "unknown user",
+			userID:  "missing",
+			assign:  []string{"viewer"},
+			wantErr: core.ErrUserNotFound,
+		},
+		{
+			name:    "empty role",
+			userID:  "user-1",
+			assign:  []string{" "},
+			wantErr: core.ErrRoleRequired,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// Arrange
+			storage := NewFakeStorageProvider()
+			_ = storage.CreateUser(t.Context(), &core.User{ID: "user-1", Email: "a@example.com"})
+			manager := NewSessionManager(core.SessionConfig{MaxAge: time.Hour}, storage, nil, nil,
+				WithRoles(cache.NewInMemoryRoleStorage(), 0))
+			_ = manager.SetRolePermissions(t.Context(), "editor", []string{"posts.write", "posts.read"})
+			_ = manager.SetRolePermissions(t.Context(), "viewer", []string{"posts.read", "", "posts.read"})
+
+			// Act
+			var err error
+			for _, role := range test.assign {
+				if err = manager.AssignRole(t.Context(), test.userID, role); err != nil {
+					break
+				}
+			}
+			for _, role := range test.revoke {
+				if err == nil {
+					err = manager.RevokeRole(t.Context(), test.userID, role)
+				}
+			}
+
+			// Assert
+			if !errors.Is(err, test.wantErr) {
+				t.Fatalf("role changes error = %v, want %v", err, test.wantErr)
+			}
+			if test.wantErr != nil {
+				return
+			}
+			roles, _ := manager.GetUserRoles(t.Context(), test.userID)
+			permissions, _ := manager.GetUserPermissions(t.Context(), test.userID)
+			if fmt.Sprint(roles) != fmt.Sprint(test.wantRoles) || fmt.Sprint(permissions) != fmt.Sprint(test.wantPermissions) {
+				t.Errorf("roles = %v, permissions = %v; want %v, %v", roles, permissions, test.wantRoles, test.wantPermissions)
+			}
+			canWrite, _ := manager.HasPermission(t.Context(), test.userID, "posts.write")
+			if want := fmt.Sprint(test.wantPermissions) == "[posts.read posts.write]"; canWrite != want {
+				t.Errorf("HasPermission(posts.write) = %v, want %v", canWrite, want)
+			}
+		})
+	}
+
+	disabled := newTestSessionManager(NewFakeStorageProvider(), nil)
+	if _, err := disabled.HasPermission(t.Context(), "user-1", "posts.read"); !errors.Is(err, core.ErrNotImplemented) {
+		t.Errorf("HasPermission() without role storage error = %v, want ErrNotImplemented", err)
+	}
+}
+
+// Requirement: Role and permission lookups are served from the cache until
+// it expires or the manager changes them, including through request-scoped
+// copies.
+func TestSessionManager_RoleCache(t *testing.T) {
+	// Arrange
+	storage := NewFakeStorageProvider()
+	_ = storage.CreateUser(t.Context(), &core.User{ID: "user-1", Email: "a@example.com"})
+	roles := &countingRoleStorage{InMemoryRoleStorage: cache.NewInMemoryRoleStorage()}
+	manager := NewSessionManager(core.SessionConfig{MaxAge: time.Hour}, storage, nil, nil,
+		WithRoles(roles, time.Hour))
+	_ = manager.AssignRole(t.Context(), "user-1", "viewer")
+	_ = manager.SetRolePermissions(t.Context(), "viewer", []string{"posts.read"})
+
+	// Act
+	for range 3 {
+		_, _ = manager.HasPermission(t.Context(), "user-1", "posts.read")
+	}
+	cachedLookups := roles.userLookups + roles.permissionLookups
+
+	_ = roles.InMemoryRoleStorage.AssignRole(t.Context(), "user-1", "editor") // behind the cache's back
+	staleRoles, _ := manager.GetUserRoles(t.Context(), "user-1")
+
+	_ = manager.RevokeRole(t.Context(), "user-1", "viewer")
+	freshRoles, _ := manager.GetUserRoles(t.Context(), "user-1")
+
+	// Assert
+	if cachedLookups != 2 {
+		t.Errorf("storage lookups for repeated checks = %d, want 2", cachedLookups)
+	}
+	if fmt.Sprint(staleRoles) != "[viewer]" {
+		t.Errorf("GetUserRoles() before expiry = %v, want the cached [viewer]", staleRoles)
+	}
+	if fmt.Sprint(freshRoles) != "[editor]" {
+		t.Errorf("GetUserRoles() after RevokeRole = %v, want [editor]", freshRoles)
+	}
+}
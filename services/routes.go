@@ -0,0 +1,108 @@
+package services
+
+import (
+	"fmt"
+	"maps"
+
+	"github.com/lborres/kuta/core"
+)
+
+// BuiltinRoutes returns the registry of the endpoints service supports, with
+// their handlers, and the plugin endpoints of a core.EndpointProvider, split
+// into groups. Endpoint sets are registered for the capabilities service
+// implements and has enabled, and the core.IdempotentOperations are wrapped
+// with IdempotentHandler when idempotency is enabled. Adapters only mount
+// the groups of the registry onto their router.
+func BuiltinRoutes(service core.AuthProvider, opts HandlerOptions, groups []core.RouteGroup) (*EndpointRegistry, error) {
+	registry := NewEndpointRegistry()
+
+	admin, adminEnabled := service.(core.AdminProvider)
+	adminEnabled = adminEnabled && admin.AdminEnabled()
+	discovery, discoveryEnabled := service.(core.ProviderDiscovery)
+	activity, activityEnabled := service.(core.ActivityProvider)
+	activityEnabled = activityEnabled && activity.ActivityEnabled()
+	organizations, organizationsEnabled := service.(core.OrganizationProvider)
+	organizationsEnabled = organizationsEnabled && organizations.OrganizationsEnabled()
+	tokens, tokensEnabled := service.(core.AccessTokenProvider)
+	tokensEnabled = tokensEnabled && tokens.AccessTokensEnabled()
+	passwords, passwordsEnabled := service.(core.PasswordChanger)
+	oauth, oauthEnabled := service.(core.OAuthSignIn)
+	oauthEnabled = oauthEnabled && oauth.OAuthEnabled()
+	accounts, accountsEnabled := service.(core.AccountLinker)
+	twoFactor, twoFactorEnabled := service.(core.TwoFactorProvider)
+	twoFactorEnabled = twoFactorEnabled && twoFactor.TOTPEnabled()
+	anonymous, anonymousEnabled := service.(core.AnonymousSessionProvider)
+	anonymousEnabled = anonymousEnabled && anonymous.AnonymousEnabled()
+	emails, emailsEnabled := service.(core.AccountEmailProvider)
+	emailsEnabled = emailsEnabled && emails.AccountEmailsEnabled()
+
+	sets := []struct {
+		enabled   bool
+		endpoints func() []core.Endpoint
+	}{
+		{adminEnabled, AdminEndpoints},
+		{discoveryEnabled, DiscoveryEndpoints},
+		{activityEnabled, ActivityEndpoints},
+		{organizationsEnabled, OrganizationEndpoints},
+		{tokensEnabled, AccessTokenEndpoints},
+		{passwordsEnabled, PasswordEndpoints},
+		{oauthEnabled, OAuthEndpoints},
+		{accountsEnabled, AccountEndpoints},
+		{accountsEnabled && oauthEnabled, AccountLinkEndpoints},
+		{twoFactorEnabled, TwoFactorEndpoints},
+		{anonymousEnabled, AnonymousEndpoints},
+		{emailsEnabled, AccountEmailEndpoints},
+	}
+	for _, set := range sets {
+		if !set.enabled {
+			continue
+		}
+		if err := registry.RegisterPlugin(set.endpoints()); err != nil {
+			return nil, err
+		}
+	}
+
+	// The handlers of disabled sets are never called, their endpoints not
+	// being in the registry
+	handlers := BaseHandlers(opts)
+	maps.Copy(handlers, AdminHandlers(opts, admin))
+	maps.Copy(handlers, DiscoveryHandlers(opts, discovery))
+	maps.Copy(handlers, ActivityHandlers(opts, activity))
+	maps.Copy(handlers, OrganizationHandlers(opts, organizations))
+	maps.Copy(handlers, AccessTokenHandlers(opts, tokens))
+	maps.Copy(handlers, PasswordHandlers(opts, passwords))
+	maps.Copy(handlers, OAuthHandlers(opts, oauth))
+	maps.Copy(handlers, AccountHandlers(opts, accounts))
+	maps.Copy(handlers, TwoFactorHandlers(opts, twoFactor))
+	maps.Copy(handlers, AnonymousHandlers(opts, anonymous))
+	maps.Copy(handlers, AccountEmailHandlers(opts, emails))
+	if requests, ok := service.(core.IdempotentRequests); ok && requests.IdempotencyEnabled() {
+		for _, operationID := range core.IdempotentOperations {
+			handlers[operationID] = IdempotentHandler(opts, requests, operationID, handlers[operationID])
+		}
+	}
+
+	// Every built-in endpoint must have a handler, so an endpoint added to
+	// the registry can't silently go unmounted
+	for _, endpoint := range registry.Endpoints() {
+		handler, ok := handlers[endpoint.Metadata.OperationID]
+		if !ok {
+			return nil, fmt.Errorf("no handler for endpoint %s %s (%s)", endpoint.Method, endpoint.Path, endpoint.Metadata.OperationID)
+		}
+		endpoint.Handler = handler
+	}
+
+	// Plugin endpoints come with their own handlers
+	if provider, ok := service.(core.EndpointProvider); ok {
+		if err := registry.RegisterPlugin(provider.GetEndpoints()); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, group := range groups {
+		if err := registry.AddGroup(group); err != nil {
+			return nil, err
+		}
+	}
+	return registry, nil
+}
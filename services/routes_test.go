@@ -0,0 +1,305 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lborres/kuta/core"
+	"github.com/lborres/kuta/pkg/cache"
+	"github.com/lborres/kuta/pkg/crypto"
+)
+
+// mockAuthProvider is a core.AuthProvider returning getSessionData for
+// every token, for handler tests that don't need a SessionManager
+type mockAuthProvider struct {
+	signUpCalled   bool
+	signUpErr      error
+	signUpResult   *core.SignUpResult
+	getSessionData *core.SessionData
+}
+
+func (m *mockAuthProvider) SignUp(ctx context.Context, input core.SignUpInput, ipAddress, userAgent string) (*core.SignUpResult, error) {
+	m.signUpCalled = true
+	if m.signUpErr != nil {
+		return nil, m.signUpErr
+	}
+	return m.signUpResult, nil
+}
+
+func (m *mockAuthProvider) SignIn(context.Context, core.SignInInput, string, string) (*core.SignInResult, error) {
+	return nil, core.ErrInvalidCredentials
+}
+
+func (m *mockAuthProvider) ContinueSignIn(context.Context, core.ContinueSignInInput) (*core.SignInResult, error) {
+	return nil, core.ErrInvalidToken
+}
+
+func (m *mockAuthProvider) SignOut(context.Context, string) error {
+	return nil
+}
+
+func (m *mockAuthProvider) GetSession(context.Context, string) (*core.SessionData, error) {
+	if m.getSessionData == nil {
+		return nil, core.ErrSessionNotFound
+	}
+	return m.getSessionData, nil
+}
+
+func (m *mockAuthProvider) Refresh(context.Context, string) (*core.RefreshResult, error) {
+	return nil, core.ErrInvalidToken
+}
+
+// routeServer serves requests with the endpoints BuiltinRoutes mounts,
+// routing ":name" path parameters the way adapters do, so the shared
+// handlers are tested once instead of through every adapter
+type routeServer struct {
+	t        *testing.T
+	auth     core.AuthProvider
+	registry *EndpointRegistry
+}
+
+// routeRequest is a request to a routeServer. Body is sent as JSON unless it
+// is already a string.
+type routeRequest struct {
+	method  string
+	path    string
+	body    any
+	headers map[string]string
+	cookies map[string]string
+}
+
+// routeResponse is what the handler of a routeServer wrote. Requests no
+// endpoint matches get a 404, as adapters answer them.
+type routeResponse struct {
+	status  int
+	headers map[string]string
+	cookies []*http.Cookie
+	body    string
+}
+
+// newRouteServer mounts the routes of auth, binding JSON bodies and naming
+// the session cookie "auth_token" unless opts says otherwise
+func newRouteServer(t *testing.T, auth core.AuthProvider, opts HandlerOptions) *routeServer {
+	t.Helper()
+	if opts.Bind == nil {
+		opts.Bind = jsonBind
+	}
+	if opts.CookieName == "" {
+		opts.CookieName = "auth_token"
+	}
+	registry, err := BuiltinRoutes(auth, opts, []core.RouteGroup{{Prefix: "/"}})
+	if err != nil {
+		t.Fatalf("BuiltinRoutes error: %v", err)
+	}
+	return &routeServer{t: t, auth: auth, registry: registry}
+}
+
+func (s *routeServer) do(r routeRequest) routeResponse {
+	s.t.Helper()
+
+	path, rawQuery, _ := strings.Cut(r.path, "?")
+	query, err := url.ParseQuery(rawQuery)
+	if err != nil {
+		s.t.Fatalf("parse query: %v", err)
+	}
+	headers := map[string]string{}
+	var body string
+	switch b := r.body.(type) {
+	case nil:
+	case string:
+		body = b
+		headers["Content-Type"] = "application/json"
+	default:
+		data, err := json.Marshal(b)
+		if err != nil {
+			s.t.Fatalf("marshal request body: %v", err)
+		}
+		body = string(data)
+		headers["Content-Type"] = "application/json"
+	}
+	for name, value := range r.headers {
+		headers[name] = value
+	}
+
+	for _, endpoint := range s.registry.Endpoints() {
+		params, ok := matchPath(endpoint.Path, path)
+		if !ok || endpoint.Method != r.method {
+			continue
+		}
+		res := &fakeResponse{headers: map[string]string{}}
+		ctx := &core.RequestContext{
+			Request:  fakeRequest{method: r.method, path: path, headers: headers, cookies: r.cookies, query: query, params: params, body: body},
+			Response: res,
+			Auth:     s.auth,
+			Context:  s.t.Context(),
+		}
+		if err := endpoint.Handler(ctx); err != nil {
+			s.t.Fatalf("%s %s handler error: %v", r.method, r.path, err)
+		}
+		if res.status == 0 {
+			res.status = http.StatusOK
+		}
+		return routeResponse{status: res.status, headers: res.headers, cookies: res.cookies, body: res.body}
+	}
+	return routeResponse{status: http.StatusNotFound}
+}
+
+// decode unmarshals the JSON body into out
+func (r routeResponse) decode(t *testing.T, out any) {
+	t.Helper()
+	if err := json.Unmarshal([]byte(r.body), out); err != nil {
+		t.Fatalf("decode response body %s: %v", r.body, err)
+	}
+}
+
+// cookie returns the cookie set by the response, or nil
+func (r routeResponse) cookie(name string) *http.Cookie {
+	for _, cookie := range r.cookies {
+		if cookie.Name == name {
+			return cookie
+		}
+	}
+	return nil
+}
+
+// matchPath reports whether path matches the endpoint path pattern, and
+// the values of its ":name" parameters
+func matchPath(pattern, path string) (map[string]string, bool) {
+	patternSegments := strings.Split(pattern, "/")
+	pathSegments := strings.Split(path, "/")
+	if len(patternSegments) != len(pathSegments) {
+		return nil, false
+	}
+	params := map[string]string{}
+	for i, segment := range patternSegments {
+		if name, ok := strings.CutPrefix(segment, ":"); ok {
+			params[name] = pathSegments[i]
+		} else if segment != pathSegments[i] {
+			return nil, false
+		}
+	}
+	return params, true
+}
+
+// pluginManager adds plugin endpoints to a SessionManager
+type pluginManager struct {
+	*SessionManager
+	endpoints []core.Endpoint
+}
+
+func (p pluginManager) GetEndpoints() []core.Endpoint {
+	return p.endpoints
+}
+
+// Requirement: BuiltinRoutes registers the endpoints of the capabilities
+// the service has enabled, every one with its handler, then the plugin
+// endpoints and the route groups, failing on conflicts.
+func TestBuiltinRoutes(t *testing.T) {
+	totpSealer, _ := crypto.NewSealer("this-is-a-test-secret-of-32-bytes!", TOTPSealerPurpose)
+	idempotencySealer, _ := crypto.NewSealer("this-is-a-test-secret-of-32-bytes!", IdempotencySealerPurpose)
+	everything := []Option{
+		WithAdminAuthorizer(func(*core.SessionData) bool { return true }),
+		WithSignInLog(cache.NewInMemorySignInLog(), 0),
+		WithOrganizations(cache.NewInMemoryOrganizationStorage()),
+		WithAccessTokens(cache.NewInMemoryAccessTokenStorage(), "read"),
+		WithOAuth(cache.NewInMemoryOAuthStateStore(), "https://app.example/api/auth", &fakeOAuthProvider{}),
+		WithTwoFactor(core.TwoFactorConfig{}, totpSealer),
+		WithAnonymousSessions(),
+		WithMailer(&FakeMailer{}, nil, "Acme"),
+		WithAccountEmails("https://app.test/reset", "https://app.test/verify"),
+		WithIdempotency(cache.NewInMemoryIdempotencyStore(), idempotencySealer, time.Hour),
+	}
+	pluginEndpoint := core.Endpoint{Method: "GET", Path: "/plugin", Handler: func(*core.RequestContext) error { return nil }}
+
+	tests := []struct {
+		name     string
+		opts     []Option
+		plugin   []core.Endpoint
+		groups   []core.RouteGroup
+		wantOps  []string
+		wantNone []string
+		wantErr  bool
+	}{
+		{
+			name:     "nothing enabled",
+			groups:   []core.RouteGroup{{Prefix: "/api/auth"}},
+			wantOps:  []string{core.OperationSignUp, core.OperationChangePassword, core.OperationListAccounts, core.OperationListProviders},
+			wantNone: []string{core.OperationAdminListSessions, core.OperationOAuthSignIn, core.OperationLinkOAuthAccount, core.OperationSetupTOTP, core.OperationForgotPassword},
+		},
+		{
+			name:   "everything enabled",
+			opts:   everything,
+			groups: []core.RouteGroup{{Prefix: "/api/auth"}},
+			wantOps: []string{
+				core.OperationAdminListSessions, core.OperationListActivity, core.OperationSwitchOrganization,
+				core.OperationCreateAccessToken, core.OperationOAuthCallback, core.OperationLinkOAuthAccount,
+				core.OperationSetupTOTP, core.OperationSignInAnonymous, core.OperationResetPassword,
+			},
+		},
+		{
+			name:   "plugin endpoints",
+			plugin: []core.Endpoint{pluginEndpoint},
+			groups: []core.RouteGroup{{Prefix: "/api/auth"}},
+		},
+		{
+			name:    "plugin endpoint conflict",
+			plugin:  []core.Endpoint{{Method: "POST", Path: "/sign-up", Handler: pluginEndpoint.Handler}},
+			groups:  []core.RouteGroup{{Prefix: "/api/auth"}},
+			wantErr: true,
+		},
+		{
+			name:    "malformed group",
+			groups:  []core.RouteGroup{{Prefix: "api"}},
+			wantErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// Arrange
+			var service core.AuthProvider = NewSessionManager(core.SessionConfig{MaxAge: time.Hour}, NewFakeStorageProvider(), nil, nil, test.opts...)
+			if test.plugin != nil {
+				service = pluginManager{SessionManager: service.(*SessionManager), endpoints: test.plugin}
+			}
+
+			// Act
+			registry, err := BuiltinRoutes(service, HandlerOptions{CookieName: "kuta_session"}, test.groups)
+
+			// Assert
+			if test.wantErr {
+				if err == nil {
+					t.Fatal("expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("BuiltinRoutes error: %v", err)
+			}
+			mounted := make(map[string]bool)
+			for _, endpoint := range registry.Endpoints() {
+				if endpoint.Handler == nil {
+					t.Errorf("no handler for %s %s", endpoint.Method, endpoint.Path)
+				}
+				mounted[endpoint.Metadata.OperationID] = true
+			}
+			for _, operationID := range test.wantOps {
+				if !mounted[operationID] {
+					t.Errorf("%s not registered", operationID)
+				}
+			}
+			for _, operationID := range test.wantNone {
+				if mounted[operationID] {
+					t.Errorf("%s registered while disabled", operationID)
+				}
+			}
+			if len(registry.Groups()) != len(test.groups) {
+				t.Errorf("groups = %v, want %v", registry.Groups(), test.groups)
+			}
+		})
+	}
+}
@@ -0,0 +1,32 @@
+package services
+
+import (
+	"context"
+	"errors"
+
+	"github.com/lborres/kuta/core"
+)
+
+// GetUserSecurity returns a user's security settings, or the defaults when
+// the user never changed them
+func (sm *SessionManager) GetUserSecurity(ctx context.Context, userID string) (*core.UserSecurity, error) {
+	settings, err := sm.storage.GetUserSecurity(ctx, userID)
+	if errors.Is(err, core.ErrUserSecurityNotFound) {
+		if _, err := sm.storage.GetUserByID(ctx, userID); err != nil {
+			return nil, err
+		}
+		return core.DefaultUserSecurity(userID), nil
+	}
+	return settings, err
+}
+
+// UpdateUserSecurity stores a user's security settings. The user must exist.
+func (sm *SessionManager) UpdateUserSecurity(ctx context.Context, settings *core.UserSecurity) error {
+	if _, err := sm.storage.GetUserByID(ctx, settings.UserID); err != nil {
+		return err
+	}
+	if settings.MaxSessions < 0 {
+		settings.MaxSessions = 0
+	}
+	return sm.storage.UpsertUserSecurity(ctx, settings)
+}
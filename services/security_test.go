@@ -0,0 +1,41 @@
+package services
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/lborres/kuta/core"
+)
+
+// Requirement: Security settings default until changed, round-trip through
+// storage, and are only stored for existing users.
+func TestSessionManager_UserSecurity(t *testing.T) {
+	// Arrange
+	storage := NewFakeStorageProvider()
+	manager := newTestSessionManager(storage, nil)
+	_ = storage.CreateUser(t.Context(), &core.User{ID: "user-1", Email: "a@example.com"})
+
+	// Act
+	defaults, defaultsErr := manager.GetUserSecurity(t.Context(), "user-1")
+	updateErr := manager.UpdateUserSecurity(t.Context(), &core.UserSecurity{UserID: "user-1", TwoFactorEnabled: true, AllowedProviders: []string{"google"}})
+	updated, _ := manager.GetUserSecurity(t.Context(), "user-1")
+	_, missingErr := manager.GetUserSecurity(t.Context(), "missing")
+	unknownErr := manager.UpdateUserSecurity(t.Context(), &core.UserSecurity{UserID: "missing"})
+
+	// Assert
+	if defaultsErr != nil || !defaults.Notifications.NewSignIn || defaults.TwoFactorEnabled {
+		t.Errorf("expected default settings, got %+v, %v", defaults, defaultsErr)
+	}
+	if updateErr != nil {
+		t.Fatalf("UpdateUserSecurity error: %v", updateErr)
+	}
+	if !updated.TwoFactorEnabled || updated.AllowsProvider("credential") || !updated.AllowsProvider("google") {
+		t.Errorf("expected stored settings, got %+v", updated)
+	}
+	if missingErr == nil || unknownErr == nil {
+		t.Errorf("expected errors for unknown user, got %v / %v", missingErr, unknownErr)
+	}
+	if errors.Is(missingErr, core.ErrUserSecurityNotFound) {
+		t.Error("expected unknown user to be reported as a user lookup failure")
+	}
+}
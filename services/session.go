@@ -1,6 +1,10 @@
 package services
 
 import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/lborres/kuta/core"
@@ -16,28 +20,122 @@ type SessionManager struct {
 	storage   core.StorageProvider
 	cache     core.Cache // optional, can be nil if caching is disabled
 	nanoid    *crypto.NanoIDGenerator
+	ids       *IDGenerators
 	passwords crypto.PasswordHandler
+
+	capabilities         core.StorageCapabilities // what storage supports, fixed at creation
+	deletedUserRetention time.Duration
+	preventEnumeration   bool
+	events               core.EventHandler
+	adminAuthorizer      core.AdminAuthorizer
+	sealer               *crypto.Sealer   // non-nil in stateless mode
+	throttle             *loginThrottle   // nil when sign-in throttling is off
+	rateLimiter          core.RateLimiter // nil when rate limiting is off
+
+	keepSessionsOnPasswordChange bool
+	velocity                     *signInVelocity
+	challenges                   *signInChallenges        // nil when sign-in is single-step
+	twoFactor                    *twoFactor               // nil when 2FA is off
+	anonymous                    bool                     // guest sessions can be created
+	revocationGrace              time.Duration            // revoked sessions drain for this long when > 0
+	passwordUpgrade              *passwordUpgrader        // nil when rehash-on-login is off
+	upgradePrompts               core.UpgradePromptPolicy // nil when posture reporting is off
+	onboarding                   core.OnboardingConfig
+	providerRefresh              *providerTokenRefresh
+	afterSignIn                  []core.AfterSignInHook
+	oauth                        *oauthSignIn             // nil when OAuth sign-in is off
+	signInLog                    *signInLog               // nil when attempts aren't logged
+	roles                        *roles                   // nil when no RoleStorage is configured
+	organizations                core.OrganizationStorage // nil when organizations are off
+	organizationInvites          organizationInvites      // where invite emails link and how long they last
+	accountEmails                accountEmails            // where password reset and verification emails link
+	entitlements                 core.EntitlementResolver // nil when sessions carry no entitlements
+	accessTokens                 *accessTokens            // nil when personal access tokens are off
+	deferred                     *deferredSessionStorage  // nil unless sessions are persisted in the background
+	idempotency                  *idempotency             // nil when Idempotency-Key is ignored
+	cleanup                      *cleanupWorker
+	providers                    *providerSwitches
+	images                       core.ImageStore
+	email                        *emailSender // nil when no Mailer is configured
+	maxImageSize                 int
+	passwordRules                passwordRules
+
+	revocations core.RevocationBus
+	instanceID  string // tags this manager's revocations
+
+	consistencySampleRate float64
+
+	state *managerState
+}
+
+// managerState is the mutable state of a SessionManager
+type managerState struct {
+	consistencyChecks  atomic.Int64
+	consistencyRepairs atomic.Int64
+
+	dummyHashMu sync.Mutex
+	dummyHash   string
 }
 
-func NewSessionManager(config core.SessionConfig, storage core.StorageProvider, cache core.Cache, passwords crypto.PasswordHandler) *SessionManager {
+// Ensure SessionManager implements AuthService
+var _ core.AuthService = (*SessionManager)(nil)
+
+func NewSessionManager(config core.SessionConfig, storage core.StorageProvider, cache core.Cache, passwords crypto.PasswordHandler, opts ...Option) *SessionManager {
 	nanoid, _ := crypto.NewNanoID()
-	return &SessionManager{
+	ids, _ := NewIDGenerators(core.EntityIDConfig{})
+	sm := &SessionManager{
 		config:    config,
 		storage:   storage,
 		cache:     cache,
 		nanoid:    nanoid,
+		ids:       ids,
 		passwords: passwords,
+		images:    core.NoopImageStore{},
+		state:     &managerState{},
+		cleanup:   newCleanupWorker(0, 0),
+		providers: newProviderSwitches(),
+
+		deletedUserRetention: defaultDeletedUserRetention,
+		maxImageSize:         DefaultMaxImageSize,
+		organizationInvites:  organizationInvites{ttl: defaultOrganizationInviteTTL},
+	}
+
+	for _, opt := range opts {
+		opt(sm)
+	}
+	sm.capabilities = core.CapabilitiesOf(storage)
+
+	// The second factor is asked for before any other sign-in challenge
+	if sm.twoFactor != nil {
+		if sm.challenges == nil {
+			sm.challenges = newInMemorySignInChallenges()
+		}
+		challengers := append([]core.SignInChallenger{totpChallenger{sm: sm}}, sm.challenges.challengers...)
+		sm.challenges = newSignInChallenges(sm.challenges.store, sm.challenges.ttl, challengers)
 	}
+
+	if sm.revocations != nil {
+		sm.instanceID, _ = nanoid.Generate()
+		sm.revocations.Subscribe(sm.handleRevocation)
+	}
+
+	return sm
+}
+
+func (sm *SessionManager) Create(ctx context.Context, userID, ip, userAgent string) (*core.CreateSessionResult, error) {
+	return sm.createSession(ctx, userID, ip, userAgent, "", nil)
 }
 
-func (sm *SessionManager) Create(userID, ip, userAgent string) (*core.CreateSessionResult, error) {
+// createSession creates a session, optionally bound to a proof-of-possession
+// key or opened by an impersonating admin.
+func (sm *SessionManager) createSession(ctx context.Context, userID, ip, userAgent, publicKey string, impersonation *core.Impersonation) (*core.CreateSessionResult, error) {
 	// Generate cryptographic material
 	pair, err := crypto.GenerateHashedToken()
 	if err != nil {
 		return nil, err
 	}
 
-	sessionID, err := sm.nanoid.Generate()
+	sessionID, err := sm.ids.sessions.generate()
 	if err != nil {
 		return nil, err
 	}
@@ -50,13 +148,32 @@ func (sm *SessionManager) Create(userID, ip, userAgent string) (*core.CreateSess
 		TokenHash: pair.Hash,
 		IPAddress: ip,
 		UserAgent: userAgent,
+		PublicKey: publicKey,
 		CreatedAt: now,
 		UpdatedAt: now,
 		ExpiresAt: now.Add(sm.config.MaxAge),
+
+		Impersonation: impersonation,
+	}
+	if impersonation != nil {
+		session.ExpiresAt = now.Add(min(sm.config.MaxAge, impersonationMaxAge))
+	}
+	if err := sm.resolveEntitlements(ctx, session, nil); err != nil {
+		return nil, err
+	}
+
+	// Stateless mode: the sealed session is the token, nothing is stored
+	if sm.sealer != nil {
+		session.TokenHash = ""
+		token, err := sm.sealSession(session)
+		if err != nil {
+			return nil, err
+		}
+		return &core.CreateSessionResult{Session: session, Token: token}, nil
 	}
 
 	// Persist session
-	if err := sm.storage.CreateSession(session); err != nil {
+	if err := sm.storage.CreateSession(ctx, session); err != nil {
 		return nil, err
 	}
 
@@ -69,10 +186,34 @@ func (sm *SessionManager) Create(userID, ip, userAgent string) (*core.CreateSess
 	return &core.CreateSessionResult{Session: session, Token: pair.Token}, nil
 }
 
-func (sm *SessionManager) Verify(token string) (*core.Session, error) {
+func (sm *SessionManager) Verify(ctx context.Context, token string) (*core.Session, error) {
+	session, _, err := sm.verify(ctx, token, false)
+	return session, err
+}
+
+// verify looks up the session for token. With withUser, a session read from
+// storage comes with its user, fetched in the same round trip, and a deleted
+// user is reported as ErrUserNotFound. Sessions served from the cache come
+// without one. Sessions carried by the token itself always come with their
+// user, which is read to reject the sessions of deleted users.
+func (sm *SessionManager) verify(ctx context.Context, token string, withUser bool) (*core.Session, *core.User, error) {
 	// Validate input
 	if token == "" {
-		return nil, core.ErrInvalidToken
+		return nil, nil, core.ErrInvalidToken
+	}
+
+	if sm.sealer != nil {
+		session, err := sm.openSession(token)
+		if err != nil {
+			return nil, nil, err
+		}
+		// DeleteUser has no stored session to remove, so a sealed session
+		// only stops working once its user is gone
+		user, err := sm.storage.GetUserByID(ctx, session.UserID)
+		if err != nil {
+			return nil, nil, err
+		}
+		return session, user, nil
 	}
 
 	tokenHash := crypto.HashToken(token)
@@ -80,29 +221,43 @@ func (sm *SessionManager) Verify(token string) (*core.Session, error) {
 	// Try cache first if caching is enabled
 	if sm.cache != nil {
 		if session, err := sm.cache.Get(tokenHash); err == nil {
+			if sm.sampleConsistency() {
+				session, err = sm.checkCachedSession(ctx, tokenHash, session)
+				if err != nil {
+					return nil, nil, err
+				}
+			}
+
 			// Cache hit - validate expiry
 			if time.Now().After(session.ExpiresAt) {
 				// Remove expired session from cache
 				_ = sm.cache.Delete(tokenHash)
-				return nil, core.ErrSessionExpired
+				return nil, nil, core.ErrSessionExpired
 			}
-			return session, nil
+			return session, nil, nil
 		}
 		// Cache miss - fall through to storage
 	}
 
 	// Get from storage
-	session, err := sm.storage.GetSessionByHash(tokenHash)
+	var session *core.Session
+	var user *core.User
+	var err error
+	if withUser {
+		session, user, err = sm.storage.GetSessionWithUserByHash(ctx, tokenHash)
+	} else {
+		session, err = sm.storage.GetSessionByHash(ctx, tokenHash)
+	}
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	if session == nil {
-		return nil, core.ErrSessionNotFound
+		return nil, nil, core.ErrSessionNotFound
 	}
 
 	// Validate session hasn't expired
 	if time.Now().After(session.ExpiresAt) {
-		return nil, core.ErrSessionExpired
+		return nil, nil, core.ErrSessionExpired
 	}
 
 	// Cache the session for future requests if caching is enabled
@@ -110,59 +265,148 @@ func (sm *SessionManager) Verify(token string) (*core.Session, error) {
 		_ = sm.cache.Set(tokenHash, session)
 	}
 
-	return session, nil
+	if withUser && user == nil {
+		return nil, nil, core.ErrUserNotFound
+	}
+	return session, user, nil
 }
 
-func (sm *SessionManager) Destroy(token string) error {
+func (sm *SessionManager) Destroy(ctx context.Context, token string) error {
 	// Validate input
 	if token == "" {
 		return core.ErrInvalidToken
 	}
 
+	// Stateless sessions can't be revoked; the client just drops the token.
+	// Its refresh tokens are stored, though, and must not outlive sign-out.
+	if sm.sealer != nil {
+		session, err := sm.openSession(token)
+		if err != nil {
+			return err
+		}
+		if _, err = sm.storage.RevokeSessionRefreshTokens(ctx, session.ID); err != nil {
+			return err
+		}
+		sm.emitSessionRevoked(session.UserID, session.ID, core.RevokeReasonSignOut)
+		return nil
+	}
+
 	// Hash token to find session
 	tokenHash := crypto.HashToken(token)
 
+	// Revoke refresh tokens issued with the session
+	session, lookupErr := sm.storage.GetSessionByHash(ctx, tokenHash)
+	if lookupErr == nil {
+		if _, err := sm.storage.RevokeSessionRefreshTokens(ctx, session.ID); err != nil {
+			return err
+		}
+	}
+
 	// Delete session from storage by hash
-	err := sm.storage.DeleteSessionByHash(tokenHash)
+	err := sm.storage.DeleteSessionByHash(ctx, tokenHash)
 	if err != nil {
 		return err
 	}
 
-	// Remove from cache if caching is enabled
-	if sm.cache != nil {
-		_ = sm.cache.Delete(tokenHash)
+	// Remove from cache on this and other instances
+	sm.evictCached(tokenHash)
+
+	if lookupErr == nil {
+		sm.emitSessionRevoked(session.UserID, session.ID, core.RevokeReasonSignOut)
+	}
+
+	return nil
+}
+
+func (sm *SessionManager) DestroyBySessionID(ctx context.Context, sessionID string) error {
+	return sm.RevokeSession(ctx, sessionID, core.RevokeReasonUnspecified)
+}
+
+// RevokeSession destroys a session by ID and records reason in an
+// EventSessionRevoked.
+func (sm *SessionManager) RevokeSession(ctx context.Context, sessionID, reason string) error {
+	revoke := sm.destroyBySessionID
+	if sm.softRevoke() {
+		revoke = sm.drainBySessionID
 	}
 
+	userID, err := revoke(ctx, sessionID)
+	if err != nil {
+		return err
+	}
+	sm.emitSessionRevoked(userID, sessionID, reason)
 	return nil
 }
 
-func (sm *SessionManager) DestroyBySessionID(sessionID string) error {
+// destroyBySessionID destroys a session without recording why, returning its
+// owner when known
+func (sm *SessionManager) destroyBySessionID(ctx context.Context, sessionID string) (string, error) {
 	// Validate input
 	if sessionID == "" {
-		return core.ErrSessionNotFound
+		return "", core.ErrSessionNotFound
 	}
 
-	// Get session first to obtain tokenHash for cache invalidation
-	if sm.cache != nil {
-		session, err := sm.storage.GetSessionByID(sessionID)
+	// Revoke refresh tokens issued with the session
+	if _, err := sm.storage.RevokeSessionRefreshTokens(ctx, sessionID); err != nil {
+		return "", err
+	}
+
+	if sm.sealer != nil {
+		return "", nil
+	}
+
+	// Get session first to obtain tokenHash for cache invalidation and the
+	// owner for the revocation event
+	var userID string
+	if sm.cache != nil || sm.revocations != nil || sm.events != nil {
+		session, err := sm.storage.GetSessionByID(ctx, sessionID)
 		if err == nil && session != nil {
-			// Remove from cache (ignore errors)
-			_ = sm.cache.Delete(session.TokenHash)
+			userID = session.UserID
+			sm.evictCached(session.TokenHash)
 		}
 	}
 
 	// Delete session from storage by ID
-	return sm.storage.DeleteSessionByID(sessionID)
+	return userID, sm.storage.DeleteSessionByID(ctx, sessionID)
+}
+
+func (sm *SessionManager) DestroyAllUserSessions(ctx context.Context, userID string) (int, error) {
+	return sm.RevokeUserSessions(ctx, userID, core.RevokeReasonUnspecified)
 }
 
-func (sm *SessionManager) DestroyAllUserSessions(userID string) (int, error) {
+// RevokeUserSessions destroys all sessions of a user and records reason in an
+// EventSessionRevoked, e.g. RevokeReasonPasswordChange after a password reset.
+func (sm *SessionManager) RevokeUserSessions(ctx context.Context, userID, reason string) (int, error) {
+	revoke := sm.destroyAllUserSessions
+	if sm.softRevoke() {
+		revoke = sm.drainAllUserSessions
+	}
+
+	count, err := revoke(ctx, userID)
+	if err != nil {
+		return 0, err
+	}
+	sm.emitSessionsRevoked(userID, count, reason)
+	return count, nil
+}
+
+func (sm *SessionManager) destroyAllUserSessions(ctx context.Context, userID string) (int, error) {
 	// Validate input
 	if userID == "" {
 		return 0, core.ErrUserNotFound
 	}
 
+	if _, err := sm.storage.RevokeUserRefreshTokens(ctx, userID); err != nil {
+		return 0, err
+	}
+
+	// Stateless sessions aren't stored, so there is nothing to delete
+	if sm.sealer != nil {
+		return 0, nil
+	}
+
 	// Delete all user sessions from storage
-	count, err := sm.storage.DeleteUserSessions(userID)
+	count, err := sm.storage.DeleteUserSessions(ctx, userID)
 	if err != nil {
 		return 0, err
 	}
@@ -170,65 +414,92 @@ func (sm *SessionManager) DestroyAllUserSessions(userID string) (int, error) {
 	// Clear entire cache when destroying all user sessions if caching is enabled
 	// This is a conservative approach - we could be more selective but would need
 	// to fetch all user sessions first, which defeats the performance benefit
-	if sm.cache != nil && count > 0 {
-		_ = sm.cache.Clear()
+	if count > 0 {
+		sm.evictAllCached()
 	}
 
 	return count, nil
 }
 
 // SignUp creates a new user account and session.
-func (sm *SessionManager) SignUp(input core.SignUpInput, ipAddress, userAgent string) (*core.SignUpResult, error) {
-	// Validate email
-	if input.Email == "" {
-		return nil, core.ErrEmailRequired
+func (sm *SessionManager) SignUp(ctx context.Context, input core.SignUpInput, ipAddress, userAgent string) (*core.SignUpResult, error) {
+	if err := sm.allowRequest("signup", ipAddress); err != nil {
+		return nil, err
+	}
+	if err := sm.checkProviderEnabled(core.CredentialProviderID); err != nil {
+		return nil, err
 	}
 
-	// Validate password
-	if input.Password == "" {
-		return nil, core.ErrPasswordRequired
+	if err := sm.validateSignUp(input); err != nil {
+		return nil, err
 	}
 
-	// Check if user already exists
-	_, err := sm.storage.GetUserByEmail(input.Email)
+	// Check if user already exists. This is only a fast path to skip hashing;
+	// CreateUserIfNotExists below is what guarantees uniqueness under concurrency.
+	existing, err := sm.storage.GetUserByEmail(ctx, input.Email)
 	if err == nil {
 		// User exists
-		return nil, core.ErrUserExists
+		return sm.signUpEmailTaken(input, existing.ID, ipAddress, userAgent)
 	}
-	if err != core.ErrUserNotFound {
+	if !errors.Is(err, core.ErrUserNotFound) {
 		// Some other error occurred
 		return nil, err
 	}
 
+	// Signing up from a guest session turns it into the account's session
+	if session, user := sm.guestSession(ctx, input.AnonymousToken); session != nil {
+		return sm.upgradeAnonymous(ctx, input, session, user, ipAddress, userAgent)
+	}
+
 	// Hash password
 	hashedPassword, err := sm.passwords.Hash(input.Password)
 	if err != nil {
-		return nil, err
+		return nil, passwordError(err)
 	}
 
 	// Generate user ID
-	userID, err := sm.nanoid.Generate()
+	userID, err := sm.ids.users.generate()
 	if err != nil {
 		return nil, err
 	}
 
+	// Store the uploaded image. If the user insert below loses a race the
+	// stored image is orphaned, which is cheaper than a second user write.
+	image := input.Image
+	if input.ImageUpload != nil {
+		url, err := sm.storeImage(userID, input.ImageUpload)
+		if err != nil {
+			return nil, err
+		}
+		if url != nil {
+			image = url
+		}
+	}
+
 	// Create user
 	now := time.Now()
 	user := &core.User{
 		ID:        userID,
 		Email:     input.Email,
 		Name:      input.Name,
-		Image:     input.Image,
+		Image:     image,
 		CreatedAt: now,
 		UpdatedAt: now,
 	}
+	if sm.requiresApproval() {
+		user.Status = core.UserStatusPending
+	}
 
-	if err := sm.storage.CreateUser(user); err != nil {
+	if err := sm.storage.CreateUserIfNotExists(ctx, user); err != nil {
+		if errors.Is(err, core.ErrUserExists) {
+			// Lost a race with a concurrent sign-up for the same email
+			return sm.signUpEmailTaken(input, "", ipAddress, userAgent)
+		}
 		return nil, err
 	}
 
 	// Create account with hashed password
-	accountID, err := sm.nanoid.Generate()
+	accountID, err := sm.ids.accounts.generate()
 	if err != nil {
 		return nil, err
 	}
@@ -236,156 +507,276 @@ func (sm *SessionManager) SignUp(input core.SignUpInput, ipAddress, userAgent st
 	account := &core.Account{
 		ID:         accountID,
 		UserID:     userID,
-		ProviderID: "credential", // Default credential provider
-		AccountID:  input.Email,  // Store email as account identifier
+		ProviderID: core.CredentialProviderID,
+		AccountID:  input.Email, // Store email as account identifier
 		Password:   &hashedPassword,
 		CreatedAt:  now,
 		UpdatedAt:  now,
 	}
 
-	if err := sm.storage.CreateAccount(account); err != nil {
+	if err := sm.storage.CreateAccount(ctx, account); err != nil {
 		// Cleanup: delete the user if account creation fails
-		_ = sm.storage.DeleteUser(userID)
+		_ = sm.storage.DeleteUser(ctx, userID)
 		return nil, err
 	}
 
+	sm.emit(core.Event{
+		Type:      core.EventUserSignedUp,
+		UserID:    userID,
+		Email:     user.Email,
+		IPAddress: ipAddress,
+		UserAgent: userAgent,
+	})
+
+	// With enumeration protection, a new registration must look exactly like
+	// a duplicate one, so no session is issued; the user signs in afterwards.
+	if sm.preventEnumeration {
+		if sm.requiresApproval() {
+			sm.emitPendingApproval(user, ipAddress, userAgent)
+		}
+		return sm.withOnboarding(&core.SignUpResult{}), nil
+	}
+
+	// Users in the approval queue get no session until an admin approves
+	if sm.requiresApproval() {
+		sm.emitPendingApproval(user, ipAddress, userAgent)
+		return sm.withOnboarding(&core.SignUpResult{User: user}), nil
+	}
+
 	// Create session
-	sessionResult, err := sm.Create(userID, ipAddress, userAgent)
+	sessionResult, err := sm.Create(ctx, userID, ipAddress, userAgent)
 	if err != nil {
 		// Cleanup: delete user and account if session creation fails
-		_ = sm.storage.DeleteUser(userID)
-		_ = sm.storage.DeleteAccount(accountID)
+		_ = sm.storage.DeleteUser(ctx, userID)
+		_ = sm.storage.DeleteAccount(ctx, accountID)
 		return nil, err
 	}
 
-	return &core.SignUpResult{
-		User:    user,
-		Session: sessionResult.Session,
-		Token:   sessionResult.Token,
-	}, nil
+	refreshToken, err := sm.issueRefreshToken(ctx, sessionResult.Session, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return sm.withOnboarding(&core.SignUpResult{
+		User:         user,
+		Session:      sessionResult.Session,
+		Token:        sessionResult.Token,
+		RefreshToken: refreshToken,
+	}), nil
+}
+
+// signUpEmailTaken reports a sign-up for an already registered email. With
+// enumeration protection it burns the same hashing work as a real sign-up
+// and returns the generic result; otherwise it returns ErrUserExists.
+func (sm *SessionManager) signUpEmailTaken(input core.SignUpInput, userID, ipAddress, userAgent string) (*core.SignUpResult, error) {
+	sm.emit(core.Event{
+		Type:      core.EventSignUpEmailTaken,
+		UserID:    userID,
+		Email:     input.Email,
+		IPAddress: ipAddress,
+		UserAgent: userAgent,
+	})
+
+	if !sm.preventEnumeration {
+		return nil, core.ErrUserExists
+	}
+
+	_, _ = sm.passwords.Hash(input.Password)
+	return sm.withOnboarding(&core.SignUpResult{}), nil
 }
 
 // SignIn authenticates a user and creates a session.
-func (sm *SessionManager) SignIn(input core.SignInInput, ipAddress, userAgent string) (*core.SignInResult, error) {
-	// Validate email
-	if input.Email == "" {
-		return nil, core.ErrEmailRequired
+func (sm *SessionManager) SignIn(ctx context.Context, input core.SignInInput, ipAddress, userAgent string) (*core.SignInResult, error) {
+	record := &core.SignInRecord{
+		Email:      input.Email,
+		ProviderID: core.CredentialProviderID,
+		IPAddress:  ipAddress,
+		UserAgent:  userAgent,
+	}
+	result, err := sm.throttledSignIn(ctx, input, ipAddress, userAgent, record)
+	sm.recordSignIn(ctx, record, result, err)
+	return result, err
+}
+
+// throttledSignIn runs the sign-in behind the rate limiter and login
+// throttle, filling in record as the user is found
+func (sm *SessionManager) throttledSignIn(ctx context.Context, input core.SignInInput, ipAddress, userAgent string, record *core.SignInRecord) (*core.SignInResult, error) {
+	if err := sm.allowRequest("signin", ipAddress); err != nil {
+		return nil, err
+	}
+	if err := sm.checkProviderEnabled(core.CredentialProviderID); err != nil {
+		return nil, err
+	}
+
+	if sm.throttle == nil || input.Email == "" {
+		return sm.signIn(ctx, input, ipAddress, userAgent, record)
 	}
 
-	// Validate password
-	if input.Password == "" {
-		return nil, core.ErrPasswordRequired
+	key := throttleKey(input.Email, ipAddress)
+	if err := sm.throttle.before(key); err != nil {
+		sm.emit(core.Event{
+			Type:      core.EventSignInLockedOut,
+			Email:     input.Email,
+			IPAddress: ipAddress,
+			UserAgent: userAgent,
+		})
+		return nil, err
+	}
+
+	result, err := sm.signIn(ctx, input, ipAddress, userAgent, record)
+	sm.throttle.after(key, err)
+	return result, err
+}
+
+func (sm *SessionManager) signIn(ctx context.Context, input core.SignInInput, ipAddress, userAgent string, record *core.SignInRecord) (*core.SignInResult, error) {
+	if err := validateSignIn(input); err != nil {
+		return nil, err
 	}
 
 	// Get user by email
-	user, err := sm.storage.GetUserByEmail(input.Email)
+	user, err := sm.storage.GetUserByEmail(ctx, input.Email)
 	if err != nil {
-		if err == core.ErrUserNotFound {
-			return nil, core.ErrUserNotFound
+		if errors.Is(err, core.ErrUserNotFound) {
+			// Don't reveal whether the account exists
+			sm.verifyDummyPassword(input.Password)
+			sm.emitSignInFailed(input.Email, "", ipAddress, userAgent)
+			return nil, core.ErrInvalidCredentials
 		}
 		return nil, err
 	}
+	record.UserID = user.ID
 
-	// Get account(s) for this user with credential provider
-	accounts, err := sm.storage.GetAccountByUserAndProvider(user.ID, "credential")
+	// Find the credential account holding the password
+	account, err := sm.credentialAccount(ctx, user.ID)
 	if err != nil {
 		return nil, err
 	}
-	if len(accounts) == 0 {
-		return nil, core.ErrInvalidCredentials
-	}
-
-	// Find account with password and verify
-	var account *core.Account
-	for _, acc := range accounts {
-		if acc.Password != nil {
-			account = acc
-			break
-		}
-	}
 	if account == nil {
+		sm.verifyDummyPassword(input.Password)
+		sm.emitSignInFailed(input.Email, user.ID, ipAddress, userAgent)
 		return nil, core.ErrInvalidCredentials
 	}
 
 	// Verify password
 	match, err := sm.passwords.Verify(input.Password, *account.Password)
 	if err != nil {
-		return nil, err
+		return nil, passwordError(err)
 	}
 	if !match {
+		sm.emitSignInFailed(input.Email, user.ID, ipAddress, userAgent)
 		return nil, core.ErrInvalidCredentials
 	}
 
-	// Create session
-	sessionResult, err := sm.Create(user.ID, ipAddress, userAgent)
+	// Only after the password check, so the status of an account doesn't
+	// leak to anyone who knows the email
+	if err := checkUserStatus(user); err != nil {
+		return nil, err
+	}
+	if err := sm.runAfterSignIn(core.AfterSignInInput{
+		User:       user,
+		ProviderID: core.CredentialProviderID,
+		IPAddress:  ipAddress,
+		UserAgent:  userAgent,
+	}); err != nil {
+		return nil, err
+	}
+
+	sm.upgradePassword(ctx, account, input.Password)
+
+	return sm.startSignIn(ctx, user, core.CredentialProviderID, ipAddress, userAgent, input.PublicKey)
+}
+
+// completeSignIn issues the session for a sign-in that passed every check
+func (sm *SessionManager) completeSignIn(ctx context.Context, user *core.User, ipAddress, userAgent, publicKey string) (*core.SignInResult, error) {
+	sessionResult, err := sm.createSession(ctx, user.ID, ipAddress, userAgent, publicKey, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	refreshToken, err := sm.issueRefreshToken(ctx, sessionResult.Session, nil)
 	if err != nil {
 		return nil, err
 	}
 
+	sm.emit(core.Event{
+		Type:      core.EventUserSignedIn,
+		UserID:    user.ID,
+		Email:     user.Email,
+		SessionID: sessionResult.Session.ID,
+		IPAddress: ipAddress,
+		UserAgent: userAgent,
+	})
+	sm.checkSignInVelocity(user, sessionResult.Session.ID, ipAddress, userAgent)
+
 	return &core.SignInResult{
-		User:    user,
-		Session: sessionResult.Session,
-		Token:   sessionResult.Token,
+		User:         user,
+		Session:      sessionResult.Session,
+		Token:        sessionResult.Token,
+		RefreshToken: refreshToken,
+		Security:     sm.securityPosture(ctx, user),
 	}, nil
 }
 
 // SignOut destroys a session (alias for Destroy for clearer API naming).
-func (sm *SessionManager) SignOut(token string) error {
-	return sm.Destroy(token)
+func (sm *SessionManager) SignOut(ctx context.Context, token string) error {
+	return sm.Destroy(ctx, token)
 }
 
 // GetSession retrieves session data by token and returns user information.
-func (sm *SessionManager) GetSession(token string) (*core.SessionData, error) {
+func (sm *SessionManager) GetSession(ctx context.Context, token string) (*core.SessionData, error) {
 	// Validate input
 	if token == "" {
 		return nil, core.ErrInvalidToken
 	}
 
-	// Verify session by token
-	session, err := sm.Verify(token)
+	// Verify session by token, along with its user when it isn't cached
+	session, user, err := sm.verify(ctx, token, true)
 	if err != nil {
 		return nil, err
 	}
 
 	// Get user
-	user, err := sm.storage.GetUserByID(session.UserID)
-	if err != nil {
-		return nil, err
+	if user == nil {
+		user, err = sm.storage.GetUserByID(ctx, session.UserID)
+		if err != nil {
+			return nil, err
+		}
 	}
 
 	return &core.SessionData{
-		Session: session,
-		User:    user,
+		Session:       session,
+		User:          user,
+		Security:      sm.securityPosture(ctx, user),
+		Impersonation: session.Impersonation,
+
+		ActiveOrganizationID: session.ActiveOrganizationID,
+		Entitlements:         session.Entitlements,
 	}, nil
 }
 
-// Refresh extends a session's expiry time and returns a new session and token.
-// The old token becomes invalid immediately.
-func (sm *SessionManager) Refresh(token string) (*core.RefreshResult, error) {
-	// Validate input
-	if token == "" {
-		return nil, core.ErrInvalidToken
-	}
-
-	// Verify current session by token
-	oldSession, err := sm.Verify(token)
-	if err != nil {
-		return nil, err
+// verifyDummyPassword runs a password verification against a throwaway hash so
+// that sign-in attempts for unknown users take as long as those for real ones.
+func (sm *SessionManager) verifyDummyPassword(password string) {
+	// Retried until it succeeds: a hash that failed once (e.g. the hashing
+	// queue was full) must not disable the timing protection for good
+	state := sm.state
+	state.dummyHashMu.Lock()
+	if state.dummyHash == "" {
+		state.dummyHash, _ = sm.passwords.Hash("kuta-dummy-password")
 	}
+	dummyHash := state.dummyHash
+	state.dummyHashMu.Unlock()
 
-	// Destroy old session
-	if err := sm.Destroy(token); err != nil {
-		return nil, err
+	if dummyHash == "" {
+		return
 	}
+	_, _ = sm.passwords.Verify(password, dummyHash)
+}
 
-	// Create new session with same userID, IP, and UserAgent
-	newSessionResult, err := sm.Create(oldSession.UserID, oldSession.IPAddress, oldSession.UserAgent)
-	if err != nil {
-		return nil, err
+// passwordError maps password handler failures to kuta errors
+func passwordError(err error) error {
+	if errors.Is(err, crypto.ErrHashQueueTimeout) {
+		return core.ErrServiceBusy
 	}
-
-	return &core.RefreshResult{
-		Session: newSessionResult.Session,
-		Token:   newSessionResult.Token,
-	}, nil
+	return err
 }
@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"sync"
 	"testing"
 	"time"
 
@@ -40,7 +41,7 @@ func TestSessionManager_Create(t *testing.T) {
 			manager := newTestSessionManager(storage, nil)
 
 			// Act
-			result, err := manager.Create(test.userID, test.ip, test.userAgent)
+			result, err := manager.Create(t.Context(), test.userID, test.ip, test.userAgent)
 
 			// Debug output
 			if !test.wantErr {
@@ -116,7 +117,7 @@ func TestSessionManager_Create_TokenHashNotExposed(t *testing.T) {
 			manager := NewSessionManager(config, storage, nil, passwords)
 
 			// Act
-			result, err := manager.Create("user123", "192.168.1.1", "Mozilla/5.0")
+			result, err := manager.Create(t.Context(), "user123", "192.168.1.1", "Mozilla/5.0")
 
 			// Assert
 			if err != nil {
@@ -153,7 +154,7 @@ func TestSessionManager_Verify(t *testing.T) {
 			name: "returns session for valid token",
 			setupSession: func(storage *FakeStorageProvider) string {
 				manager := newTestSessionManager(storage, nil)
-				result, _ := manager.Create("user123", "192.168.1.1", "Mozilla/5.0")
+				result, _ := manager.Create(t.Context(), "user123", "192.168.1.1", "Mozilla/5.0")
 				return result.Token
 			},
 			wantErr:     false,
@@ -171,7 +172,7 @@ func TestSessionManager_Verify(t *testing.T) {
 			name: "returns error for invalid token",
 			setupSession: func(storage *FakeStorageProvider) string {
 				manager := newTestSessionManager(storage, nil)
-				manager.Create("user123", "192.168.1.1", "Mozilla/5.0")
+				manager.Create(t.Context(), "user123", "192.168.1.1", "Mozilla/5.0")
 				return "invalid_token_xyz"
 			},
 			wantErr:     true,
@@ -183,7 +184,7 @@ func TestSessionManager_Verify(t *testing.T) {
 				config := core.SessionConfig{MaxAge: -1 * time.Hour}
 				passwords := crypto.NewArgon2()
 				manager := NewSessionManager(config, storage, nil, passwords)
-				result, _ := manager.Create("user123", "192.168.1.1", "Mozilla/5.0")
+				result, _ := manager.Create(t.Context(), "user123", "192.168.1.1", "Mozilla/5.0")
 				return result.Token
 			},
 			wantErr:     true,
@@ -193,8 +194,8 @@ func TestSessionManager_Verify(t *testing.T) {
 			name: "returns error when token not found in storage",
 			setupSession: func(storage *FakeStorageProvider) string {
 				manager := newTestSessionManager(storage, nil)
-				result, _ := manager.Create("user123", "192.168.1.1", "Mozilla/5.0")
-				storage.DeleteSessionByID(result.Session.ID) // delete it
+				result, _ := manager.Create(t.Context(), "user123", "192.168.1.1", "Mozilla/5.0")
+				storage.DeleteSessionByID(t.Context(), result.Session.ID) // delete it
 				return result.Token
 			},
 			wantErr:     true,
@@ -211,7 +212,7 @@ func TestSessionManager_Verify(t *testing.T) {
 			manager := newTestSessionManager(storage, nil)
 
 			// Act
-			session, err := manager.Verify(token)
+			session, err := manager.Verify(t.Context(), token)
 
 			// Assert
 			if (err != nil) != test.wantErr {
@@ -243,7 +244,7 @@ func TestSessionManager_Destroy(t *testing.T) {
 			name: "successfully destroys session by token",
 			setupSession: func(storage *FakeStorageProvider) string {
 				manager := newTestSessionManager(storage, nil)
-				result, _ := manager.Create("user123", "192.168.1.1", "Mozilla/5.0")
+				result, _ := manager.Create(t.Context(), "user123", "192.168.1.1", "Mozilla/5.0")
 				return result.Token
 			},
 			wantErr: false,
@@ -266,7 +267,7 @@ func TestSessionManager_Destroy(t *testing.T) {
 			name: "prevents session use after destruction",
 			setupSession: func(storage *FakeStorageProvider) string {
 				manager := newTestSessionManager(storage, nil)
-				result, _ := manager.Create("user123", "192.168.1.1", "Mozilla/5.0")
+				result, _ := manager.Create(t.Context(), "user123", "192.168.1.1", "Mozilla/5.0")
 				return result.Token
 			},
 			wantErr: false,
@@ -282,7 +283,7 @@ func TestSessionManager_Destroy(t *testing.T) {
 			token := test.setupSession(storage)
 
 			// Act
-			err := manager.Destroy(token)
+			err := manager.Destroy(t.Context(), token)
 
 			// Assert
 			if (err != nil) != test.wantErr {
@@ -291,7 +292,7 @@ func TestSessionManager_Destroy(t *testing.T) {
 
 			// If destroy succeeded, verify token can't be used
 			if !test.wantErr && test.name == "prevents session use after destruction" {
-				_, err := manager.Verify(token)
+				_, err := manager.Verify(t.Context(), token)
 				if err == nil {
 					t.Error("Verify() should fail after Destroy()")
 				}
@@ -311,7 +312,7 @@ func TestSessionManager_DestroyBySessionID(t *testing.T) {
 			name: "successfully destroys session by ID",
 			setupSession: func(storage *FakeStorageProvider) string {
 				manager := newTestSessionManager(storage, nil)
-				result, _ := manager.Create("user123", "192.168.1.1", "Mozilla/5.0")
+				result, _ := manager.Create(t.Context(), "user123", "192.168.1.1", "Mozilla/5.0")
 				return result.Session.ID
 			},
 			wantErr: false,
@@ -341,7 +342,7 @@ func TestSessionManager_DestroyBySessionID(t *testing.T) {
 			sessionID := test.setupSession(storage)
 
 			// Act
-			err := manager.DestroyBySessionID(sessionID)
+			err := manager.DestroyBySessionID(t.Context(), sessionID)
 
 			// Assert
 			if (err != nil) != test.wantErr {
@@ -365,9 +366,9 @@ func TestSessionManager_DestroyAllUserSessions(t *testing.T) {
 			userID: "user123",
 			setupSessions: func(storage *FakeStorageProvider) int {
 				manager := newTestSessionManager(storage, nil)
-				manager.Create("user123", "192.168.1.1", "Mozilla/5.0")
-				manager.Create("user123", "192.168.1.2", "Chrome/5.0")
-				manager.Create("user123", "192.168.1.3", "Safari/5.0")
+				manager.Create(t.Context(), "user123", "192.168.1.1", "Mozilla/5.0")
+				manager.Create(t.Context(), "user123", "192.168.1.2", "Chrome/5.0")
+				manager.Create(t.Context(), "user123", "192.168.1.3", "Safari/5.0")
 				return 3
 			},
 			wantErr:       false,
@@ -396,9 +397,9 @@ func TestSessionManager_DestroyAllUserSessions(t *testing.T) {
 			userID: "user123",
 			setupSessions: func(storage *FakeStorageProvider) int {
 				manager := newTestSessionManager(storage, nil)
-				manager.Create("user123", "192.168.1.1", "Mozilla/5.0")
-				manager.Create("user123", "192.168.1.2", "Chrome/5.0")
-				manager.Create("user456", "192.168.1.3", "Safari/5.0")
+				manager.Create(t.Context(), "user123", "192.168.1.1", "Mozilla/5.0")
+				manager.Create(t.Context(), "user123", "192.168.1.2", "Chrome/5.0")
+				manager.Create(t.Context(), "user456", "192.168.1.3", "Safari/5.0")
 				return 2 // only user123's sessions
 			},
 			wantErr:       false,
@@ -415,7 +416,7 @@ func TestSessionManager_DestroyAllUserSessions(t *testing.T) {
 			_ = test.setupSessions(storage)
 
 			// Act
-			destroyed, err := manager.DestroyAllUserSessions(test.userID)
+			destroyed, err := manager.DestroyAllUserSessions(t.Context(), test.userID)
 
 			// Assert
 			if (err != nil) != test.wantErr {
@@ -465,7 +466,7 @@ func TestSessionManager_Create_CacheBehavior(t *testing.T) {
 			config := core.SessionConfig{MaxAge: 24 * time.Hour}
 			passwords := crypto.NewArgon2()
 			manager := NewSessionManager(config, storage, test.cache, passwords)
-			result, err := manager.Create("user123", "192.168.1.1", "Mozilla/5.0")
+			result, err := manager.Create(t.Context(), "user123", "192.168.1.1", "Mozilla/5.0")
 
 			// Assert
 			if err != nil {
@@ -477,7 +478,7 @@ func TestSessionManager_Create_CacheBehavior(t *testing.T) {
 
 			// Verify in storage
 			tokenHash := crypto.HashToken(result.Token)
-			stored, err := storage.GetSessionByHash(tokenHash)
+			stored, err := storage.GetSessionByHash(t.Context(), tokenHash)
 			if err != nil || stored.UserID != "user123" {
 				t.Error("Session not properly stored")
 			}
@@ -538,7 +539,7 @@ func TestSessionManager_Verify_CachePattern(t *testing.T) {
 			passwords := crypto.NewArgon2()
 			manager := NewSessionManager(config, storage, cache, passwords)
 
-			result, _ := manager.Create("user123", "192.168.1.1", "Mozilla/5.0")
+			result, _ := manager.Create(t.Context(), "user123", "192.168.1.1", "Mozilla/5.0")
 			token := result.Token
 
 			// Clear cache to force first verify to miss (all test cases need this)
@@ -548,7 +549,7 @@ func TestSessionManager_Verify_CachePattern(t *testing.T) {
 
 			// Act: Verify session multiple times
 			for i := 0; i < 2; i++ {
-				_, err := manager.Verify(token)
+				_, err := manager.Verify(t.Context(), token)
 				if err != nil {
 					t.Fatalf("Verify iteration %d failed: %v", i+1, err)
 				}
@@ -605,12 +606,12 @@ func TestSessionManager_Verify_ExpiredSessionHandling(t *testing.T) {
 			passwords := crypto.NewArgon2()
 			manager := NewSessionManager(config, storage, cache, passwords)
 
-			result, _ := manager.Create("user123", "192.168.1.1", "Mozilla/5.0")
+			result, _ := manager.Create(t.Context(), "user123", "192.168.1.1", "Mozilla/5.0")
 			token := result.Token
 			tokenHash := crypto.HashToken(token)
 
 			// Act
-			_, err := manager.Verify(token)
+			_, err := manager.Verify(t.Context(), token)
 
 			// Assert
 			if (err != nil) != test.wantErr {
@@ -662,12 +663,12 @@ func TestSessionManager_Destroy_CacheInvalidation(t *testing.T) {
 			passwords := crypto.NewArgon2()
 			manager := NewSessionManager(config, storage, cache, passwords)
 
-			result, _ := manager.Create("user123", "192.168.1.1", "Mozilla/5.0")
+			result, _ := manager.Create(t.Context(), "user123", "192.168.1.1", "Mozilla/5.0")
 			token := result.Token
 			tokenHash := crypto.HashToken(token)
 
 			// Act
-			err := manager.Destroy(token)
+			err := manager.Destroy(t.Context(), token)
 
 			// Assert
 			if err != nil {
@@ -675,7 +676,7 @@ func TestSessionManager_Destroy_CacheInvalidation(t *testing.T) {
 			}
 
 			// Verify removed from storage
-			_, err = storage.GetSessionByHash(tokenHash)
+			_, err = storage.GetSessionByHash(t.Context(), tokenHash)
 			if err == nil {
 				t.Error("Session should be removed from storage")
 			}
@@ -720,12 +721,12 @@ func TestSessionManager_DestroyBySessionID_CacheInvalidation(t *testing.T) {
 			passwords := crypto.NewArgon2()
 			manager := NewSessionManager(config, storage, cache, passwords)
 
-			result, _ := manager.Create("user123", "192.168.1.1", "Mozilla/5.0")
+			result, _ := manager.Create(t.Context(), "user123", "192.168.1.1", "Mozilla/5.0")
 			sessionID := result.Session.ID
 			tokenHash := crypto.HashToken(result.Token)
 
 			// Act
-			err := manager.DestroyBySessionID(sessionID)
+			err := manager.DestroyBySessionID(t.Context(), sessionID)
 
 			// Assert
 			if err != nil {
@@ -733,7 +734,7 @@ func TestSessionManager_DestroyBySessionID_CacheInvalidation(t *testing.T) {
 			}
 
 			// Verify removed from storage
-			_, err = storage.GetSessionByID(sessionID)
+			_, err = storage.GetSessionByID(t.Context(), sessionID)
 			if err == nil {
 				t.Error("Session should be removed from storage")
 			}
@@ -783,7 +784,7 @@ func TestSessionManager_DestroyAllUserSessions_CacheClearing(t *testing.T) {
 
 			// Create multiple sessions
 			for i := 0; i < test.sessionCount; i++ {
-				manager.Create("user123", "192.168.1.1", "Mozilla/5.0")
+				manager.Create(t.Context(), "user123", "192.168.1.1", "Mozilla/5.0")
 			}
 
 			if test.withCache {
@@ -794,7 +795,7 @@ func TestSessionManager_DestroyAllUserSessions_CacheClearing(t *testing.T) {
 			}
 
 			// Act
-			destroyed, err := manager.DestroyAllUserSessions("user123")
+			destroyed, err := manager.DestroyAllUserSessions(t.Context(), "user123")
 
 			// Assert
 			if err != nil {
@@ -815,20 +816,20 @@ func TestSessionManager_DestroyAllUserSessions_CacheClearing(t *testing.T) {
 	}
 }
 
-// Requirement: Refresh extends a session's expiry time and returns a new token.
-// The old token becomes invalid immediately.
+// Requirement: Refresh exchanges a refresh token for a new session and refresh token.
+// The old session token becomes invalid immediately.
 func TestSessionManager_Refresh(t *testing.T) {
 	tests := []struct {
 		name      string
-		setupAuth func(*FakeStorageProvider, crypto.PasswordHandler) string // returns token to refresh
+		setupAuth func(*FakeStorageProvider, crypto.PasswordHandler) (string, string) // returns session and refresh tokens
 		wantErr   bool
 		wantToken bool
 	}{
 		{
 			name: "successfully refreshes valid token",
-			setupAuth: func(storage *FakeStorageProvider, passwords crypto.PasswordHandler) string {
+			setupAuth: func(storage *FakeStorageProvider, passwords crypto.PasswordHandler) (string, string) {
 				user := &core.User{ID: "user-alice", Email: "alice@example.com"}
-				_ = storage.CreateUser(user)
+				_ = storage.CreateUser(t.Context(), user)
 				hashedPassword, _ := passwords.Hash("SecurePass123!")
 				account := &core.Account{
 					ID:         "account-alice",
@@ -837,40 +838,40 @@ func TestSessionManager_Refresh(t *testing.T) {
 					AccountID:  "alice@example.com",
 					Password:   &hashedPassword,
 				}
-				_ = storage.CreateAccount(account)
+				_ = storage.CreateAccount(t.Context(), account)
 
 				config := core.SessionConfig{MaxAge: 24 * time.Hour}
 				service := NewSessionManager(config, storage, nil, passwords)
-				result, _ := service.SignIn(core.SignInInput{
+				result, _ := service.SignIn(t.Context(), core.SignInInput{
 					Email:    "alice@example.com",
 					Password: "SecurePass123!",
 				}, "127.0.0.1", "test-agent")
-				return result.Token
+				return result.Token, result.RefreshToken
 			},
 			wantErr:   false,
 			wantToken: true,
 		},
 		{
 			name: "returns error for empty token",
-			setupAuth: func(storage *FakeStorageProvider, passwords crypto.PasswordHandler) string {
-				return ""
+			setupAuth: func(storage *FakeStorageProvider, passwords crypto.PasswordHandler) (string, string) {
+				return "", ""
 			},
 			wantErr:   true,
 			wantToken: false,
 		},
 		{
 			name: "returns error for invalid token",
-			setupAuth: func(storage *FakeStorageProvider, passwords crypto.PasswordHandler) string {
-				return "invalid_token_xyz"
+			setupAuth: func(storage *FakeStorageProvider, passwords crypto.PasswordHandler) (string, string) {
+				return "", "invalid_token_xyz"
 			},
 			wantErr:   true,
 			wantToken: false,
 		},
 		{
-			name: "returns error for expired session",
-			setupAuth: func(storage *FakeStorageProvider, passwords crypto.PasswordHandler) string {
+			name: "returns error for expired refresh token",
+			setupAuth: func(storage *FakeStorageProvider, passwords crypto.PasswordHandler) (string, string) {
 				user := &core.User{ID: "user-charlie", Email: "charlie@example.com"}
-				_ = storage.CreateUser(user)
+				_ = storage.CreateUser(t.Context(), user)
 				hashedPassword, _ := passwords.Hash("SecurePass123!")
 				account := &core.Account{
 					ID:         "account-charlie",
@@ -879,16 +880,16 @@ func TestSessionManager_Refresh(t *testing.T) {
 					AccountID:  "charlie@example.com",
 					Password:   &hashedPassword,
 				}
-				_ = storage.CreateAccount(account)
+				_ = storage.CreateAccount(t.Context(), account)
 
-				// Create with expired session config
-				config := core.SessionConfig{MaxAge: -1 * time.Hour}
+				// Create with expired refresh token config
+				config := core.SessionConfig{MaxAge: 24 * time.Hour, RefreshMaxAge: -1 * time.Hour}
 				service := NewSessionManager(config, storage, nil, passwords)
-				result, _ := service.SignIn(core.SignInInput{
+				result, _ := service.SignIn(t.Context(), core.SignInInput{
 					Email:    "charlie@example.com",
 					Password: "SecurePass123!",
 				}, "127.0.0.1", "test-agent")
-				return result.Token
+				return result.Token, result.RefreshToken
 			},
 			wantErr:   true,
 			wantToken: false,
@@ -904,17 +905,17 @@ func TestSessionManager_Refresh(t *testing.T) {
 			config := core.SessionConfig{MaxAge: 24 * time.Hour}
 			service := NewSessionManager(config, storage, nil, passwords)
 
-			token := test.setupAuth(storage, passwords)
+			token, refreshToken := test.setupAuth(storage, passwords)
 
 			// Act
-			result, err := service.Refresh(token)
+			result, err := service.Refresh(t.Context(), refreshToken)
 
 			// Assert
 			if (err != nil) != test.wantErr {
 				t.Fatalf("Refresh() error = %v, wantErr %v", err, test.wantErr)
 			}
-			if test.wantToken && result != nil && result.Token == "" {
-				t.Error("Refresh() should return token")
+			if test.wantToken && result != nil && (result.Token == "" || result.RefreshToken == "") {
+				t.Error("Refresh() should return token and refresh token")
 			}
 			if !test.wantErr && result != nil {
 				if result.Session == nil {
@@ -924,8 +925,11 @@ func TestSessionManager_Refresh(t *testing.T) {
 				if result.Token == token {
 					t.Error("Refresh() should return a new token, not the old one")
 				}
+				if result.RefreshToken == refreshToken {
+					t.Error("Refresh() should rotate the refresh token")
+				}
 				// Verify old token can't be used anymore
-				_, err := service.Verify(token)
+				_, err := service.Verify(t.Context(), token)
 				if err == nil {
 					t.Error("Old token should be invalid after refresh")
 				}
@@ -964,19 +968,16 @@ func TestSessionManager_Refresh_CacheBehavior(t *testing.T) {
 			service := NewSessionManager(config, storage, cache, passwords)
 
 			// Create initial session
-			result, err := service.Create("user123", "192.168.1.1", "Mozilla/5.0")
-			if err != nil {
-				t.Fatalf("Create() failed: %v", err)
-			}
+			result, refreshToken := createRefreshableSession(t, service, storage, "user123", "")
 			oldToken := result.Token
 
 			// Warm cache by verifying the session
 			if test.withCache {
-				service.Verify(oldToken)
+				service.Verify(t.Context(), oldToken)
 			}
 
 			// Act: Refresh the token
-			refreshResult, err := service.Refresh(oldToken)
+			refreshResult, err := service.Refresh(t.Context(), refreshToken)
 			if err != nil {
 				t.Fatalf("Refresh() failed: %v", err)
 			}
@@ -987,13 +988,13 @@ func TestSessionManager_Refresh_CacheBehavior(t *testing.T) {
 			}
 
 			// Verify old token is completely removed
-			_, err = service.Verify(oldToken)
+			_, err = service.Verify(t.Context(), oldToken)
 			if err == nil {
 				t.Error("Old token should be invalid after refresh")
 			}
 
 			// Verify new token works
-			newSession, err := service.Verify(refreshResult.Token)
+			newSession, err := service.Verify(t.Context(), refreshResult.Token)
 			if err != nil {
 				t.Fatalf("New token should be valid: %v", err)
 			}
@@ -1046,7 +1047,7 @@ func TestSessionManager_SignUp(t *testing.T) {
 			password: "SecurePass123!",
 			setup: func(storage *FakeStorageProvider) {
 				// Create a user with this email first
-				_ = storage.CreateUser(&core.User{
+				_ = storage.CreateUser(t.Context(), &core.User{
 					ID:    "existing-user",
 					Email: "alice@example.com",
 				})
@@ -1068,7 +1069,7 @@ func TestSessionManager_SignUp(t *testing.T) {
 			service := NewSessionManager(config, storage, nil, passwords)
 
 			// Act
-			result, err := service.SignUp(core.SignUpInput{
+			result, err := service.SignUp(t.Context(), core.SignUpInput{
 				Email:    test.email,
 				Password: test.password,
 			}, "127.0.0.1", "test-agent")
@@ -1087,6 +1088,90 @@ func TestSessionManager_SignUp(t *testing.T) {
 	}
 }
 
+// Requirement: Concurrent sign-ups with the same email resolve to exactly one user; the rest get ErrUserExists.
+func TestSessionManager_SignUp_ConcurrentDuplicateEmail(t *testing.T) {
+	// Arrange
+	const attempts = 4
+	storage := NewFakeStorageProvider()
+	service := newTestSessionManager(storage, nil)
+
+	var wg sync.WaitGroup
+	errs := make(chan error, attempts)
+
+	// Act
+	for i := 0; i < attempts; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := service.SignUp(t.Context(), core.SignUpInput{
+				Email:    "race@example.com",
+				Password: "SecurePass123!",
+			}, "127.0.0.1", "test-agent")
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	// Assert
+	succeeded := 0
+	for err := range errs {
+		switch {
+		case err == nil:
+			succeeded++
+		case !errors.Is(err, core.ErrUserExists):
+			t.Errorf("SignUp() error = %v, want ErrUserExists", err)
+		}
+	}
+	if succeeded != 1 {
+		t.Errorf("exactly one SignUp() should succeed; got %d", succeeded)
+	}
+}
+
+// Requirement: With enumeration protection, sign-up responds identically for new and
+// registered emails, and reports the real outcome only through events.
+func TestSessionManager_SignUp_EnumerationProtection(t *testing.T) {
+	tests := []struct {
+		name          string
+		email         string
+		wantEventType core.EventType
+	}{
+		{name: "new email returns generic result", email: "new@example.com", wantEventType: core.EventUserSignedUp},
+		{name: "registered email returns generic result", email: "alice@example.com", wantEventType: core.EventSignUpEmailTaken},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// Arrange
+			storage := NewFakeStorageProvider()
+			_ = storage.CreateUser(t.Context(), &core.User{ID: "user-alice", Email: "alice@example.com"})
+			var events []core.Event
+			handler := core.EventHandlerFunc(func(e core.Event) { events = append(events, e) })
+			service := NewSessionManager(core.SessionConfig{MaxAge: time.Hour}, storage, nil, crypto.NewArgon2(),
+				WithEnumerationProtection(true),
+				WithEventHandler(handler),
+			)
+
+			// Act
+			result, err := service.SignUp(t.Context(), core.SignUpInput{
+				Email:    test.email,
+				Password: "SecurePass123!",
+			}, "127.0.0.1", "test-agent")
+
+			// Assert
+			if err != nil {
+				t.Fatalf("SignUp() error = %v, want nil", err)
+			}
+			if result == nil || result.User != nil || result.Session != nil || result.Token != "" {
+				t.Errorf("SignUp() should return an empty generic result; got %+v", result)
+			}
+			if len(events) != 1 || events[0].Type != test.wantEventType {
+				t.Errorf("SignUp() events = %+v, want one %q event", events, test.wantEventType)
+			}
+		})
+	}
+}
+
 // Requirement: SignIn authenticates a user by email and password, creates a session, and returns user + token.
 func TestSessionManager_SignIn(t *testing.T) {
 	tests := []struct {
@@ -1108,7 +1193,7 @@ func TestSessionManager_SignIn(t *testing.T) {
 					ID:    "user-alice",
 					Email: "alice@example.com",
 				}
-				_ = storage.CreateUser(user)
+				_ = storage.CreateUser(t.Context(), user)
 				// Create account with hashed password
 				hashedPassword, _ := passwords.Hash("SecurePass123!")
 				account := &core.Account{
@@ -1118,7 +1203,7 @@ func TestSessionManager_SignIn(t *testing.T) {
 					AccountID:  "alice@example.com",
 					Password:   &hashedPassword,
 				}
-				_ = storage.CreateAccount(account)
+				_ = storage.CreateAccount(t.Context(), account)
 			},
 			wantErr:   false,
 			wantUser:  true,
@@ -1152,7 +1237,7 @@ func TestSessionManager_SignIn(t *testing.T) {
 					ID:    "user-alice",
 					Email: "alice@example.com",
 				}
-				_ = storage.CreateUser(user)
+				_ = storage.CreateUser(t.Context(), user)
 				// Create account with correct hashed password
 				hashedPassword, _ := passwords.Hash("CorrectPassword123!")
 				account := &core.Account{
@@ -1162,7 +1247,7 @@ func TestSessionManager_SignIn(t *testing.T) {
 					AccountID:  "alice@example.com",
 					Password:   &hashedPassword,
 				}
-				_ = storage.CreateAccount(account)
+				_ = storage.CreateAccount(t.Context(), account)
 			},
 			wantErr: true,
 		},
@@ -1181,7 +1266,7 @@ func TestSessionManager_SignIn(t *testing.T) {
 			}
 
 			// Act
-			result, err := service.SignIn(core.SignInInput{
+			result, err := service.SignIn(t.Context(), core.SignInInput{
 				Email:    test.email,
 				Password: test.password,
 			}, "127.0.0.1", "test-agent")
@@ -1203,6 +1288,49 @@ func TestSessionManager_SignIn(t *testing.T) {
 	}
 }
 
+// Requirement: SignIn must not reveal whether an account exists; unknown users and
+// users without a credential account get the same error as a wrong password.
+func TestSessionManager_SignIn_DoesNotLeakAccountExistence(t *testing.T) {
+	tests := []struct {
+		name  string
+		email string
+	}{
+		{name: "unknown email returns ErrInvalidCredentials", email: "nobody@example.com"},
+		{name: "user without credential account returns ErrInvalidCredentials", email: "oauth-only@example.com"},
+		{name: "wrong password returns ErrInvalidCredentials", email: "alice@example.com"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// Arrange
+			storage := NewFakeStorageProvider()
+			passwords := crypto.NewArgon2()
+			service := NewSessionManager(core.SessionConfig{MaxAge: time.Hour}, storage, nil, passwords)
+			_ = storage.CreateUser(t.Context(), &core.User{ID: "user-oauth", Email: "oauth-only@example.com"})
+			_ = storage.CreateUser(t.Context(), &core.User{ID: "user-alice", Email: "alice@example.com"})
+			hashedPassword, _ := passwords.Hash("CorrectPassword123!")
+			_ = storage.CreateAccount(t.Context(), &core.Account{
+				ID:         "account-alice",
+				UserID:     "user-alice",
+				ProviderID: "credential",
+				AccountID:  "alice@example.com",
+				Password:   &hashedPassword,
+			})
+
+			// Act
+			_, err := service.SignIn(t.Context(), core.SignInInput{
+				Email:    test.email,
+				Password: "WrongPassword123!",
+			}, "127.0.0.1", "test-agent")
+
+			// Assert
+			if !errors.Is(err, core.ErrInvalidCredentials) {
+				t.Errorf("SignIn() error = %v, want ErrInvalidCredentials", err)
+			}
+		})
+	}
+}
+
 // Requirement: SignOut destroys a session and prevents further use of the token.
 func TestSessionManager_SignOut(t *testing.T) {
 	tests := []struct {
@@ -1215,7 +1343,7 @@ func TestSessionManager_SignOut(t *testing.T) {
 			name: "successfully signs out user",
 			setupAuth: func(storage *FakeStorageProvider, passwords crypto.PasswordHandler) string {
 				user := &core.User{ID: "user-alice", Email: "alice@example.com"}
-				_ = storage.CreateUser(user)
+				_ = storage.CreateUser(t.Context(), user)
 				hashedPassword, _ := passwords.Hash("SecurePass123!")
 				account := &core.Account{
 					ID:         "account-alice",
@@ -1224,11 +1352,11 @@ func TestSessionManager_SignOut(t *testing.T) {
 					AccountID:  "alice@example.com",
 					Password:   &hashedPassword,
 				}
-				_ = storage.CreateAccount(account)
+				_ = storage.CreateAccount(t.Context(), account)
 
 				config := core.SessionConfig{MaxAge: 24 * time.Hour}
 				service := NewSessionManager(config, storage, nil, passwords)
-				result, _ := service.SignIn(core.SignInInput{
+				result, _ := service.SignIn(t.Context(), core.SignInInput{
 					Email:    "alice@example.com",
 					Password: "SecurePass123!",
 				}, "127.0.0.1", "test-agent")
@@ -1250,7 +1378,7 @@ func TestSessionManager_SignOut(t *testing.T) {
 			name: "prevents token use after signout",
 			setupAuth: func(storage *FakeStorageProvider, passwords crypto.PasswordHandler) string {
 				user := &core.User{ID: "user-bob", Email: "bob@example.com"}
-				_ = storage.CreateUser(user)
+				_ = storage.CreateUser(t.Context(), user)
 				hashedPassword, _ := passwords.Hash("SecurePass123!")
 				account := &core.Account{
 					ID:         "account-bob",
@@ -1259,11 +1387,11 @@ func TestSessionManager_SignOut(t *testing.T) {
 					AccountID:  "bob@example.com",
 					Password:   &hashedPassword,
 				}
-				_ = storage.CreateAccount(account)
+				_ = storage.CreateAccount(t.Context(), account)
 
 				config := core.SessionConfig{MaxAge: 24 * time.Hour}
 				service := NewSessionManager(config, storage, nil, passwords)
-				result, _ := service.SignIn(core.SignInInput{
+				result, _ := service.SignIn(t.Context(), core.SignInInput{
 					Email:    "bob@example.com",
 					Password: "SecurePass123!",
 				}, "127.0.0.1", "test-agent")
@@ -1288,7 +1416,7 @@ func TestSessionManager_SignOut(t *testing.T) {
 			}
 
 			// Act
-			err := service.SignOut(token)
+			err := service.SignOut(t.Context(), token)
 
 			// Assert
 			if (err != nil) != test.wantErr {
@@ -1297,7 +1425,7 @@ func TestSessionManager_SignOut(t *testing.T) {
 
 			// If signout succeeded, verify token can't be used
 			if !test.wantErr && test.name == "prevents token use after signout" {
-				_, err := service.GetSession(token)
+				_, err := service.GetSession(t.Context(), token)
 				if err == nil {
 					t.Error("GetSession() should fail after SignOut()")
 				}
@@ -1320,7 +1448,7 @@ func TestSessionManager_GetSession(t *testing.T) {
 			name: "returns session data for valid token",
 			setupAuth: func(storage *FakeStorageProvider, passwords crypto.PasswordHandler) string {
 				user := &core.User{ID: "user-alice", Email: "alice@example.com"}
-				_ = storage.CreateUser(user)
+				_ = storage.CreateUser(t.Context(), user)
 				hashedPassword, _ := passwords.Hash("SecurePass123!")
 				account := &core.Account{
 					ID:         "account-alice",
@@ -1329,11 +1457,11 @@ func TestSessionManager_GetSession(t *testing.T) {
 					AccountID:  "alice@example.com",
 					Password:   &hashedPassword,
 				}
-				_ = storage.CreateAccount(account)
+				_ = storage.CreateAccount(t.Context(), account)
 
 				config := core.SessionConfig{MaxAge: 24 * time.Hour}
 				service := NewSessionManager(config, storage, nil, passwords)
-				result, _ := service.SignIn(core.SignInInput{
+				result, _ := service.SignIn(t.Context(), core.SignInInput{
 					Email:    "alice@example.com",
 					Password: "SecurePass123!",
 				}, "127.0.0.1", "test-agent")
@@ -1358,7 +1486,7 @@ func TestSessionManager_GetSession(t *testing.T) {
 			name: "returns error for expired session",
 			setupAuth: func(storage *FakeStorageProvider, passwords crypto.PasswordHandler) string {
 				user := &core.User{ID: "user-charlie", Email: "charlie@example.com"}
-				_ = storage.CreateUser(user)
+				_ = storage.CreateUser(t.Context(), user)
 				hashedPassword, _ := passwords.Hash("SecurePass123!")
 				account := &core.Account{
 					ID:         "account-charlie",
@@ -1367,12 +1495,12 @@ func TestSessionManager_GetSession(t *testing.T) {
 					AccountID:  "charlie@example.com",
 					Password:   &hashedPassword,
 				}
-				_ = storage.CreateAccount(account)
+				_ = storage.CreateAccount(t.Context(), account)
 
 				// Create with expired session config
 				config := core.SessionConfig{MaxAge: -1 * time.Hour}
 				service := NewSessionManager(config, storage, nil, passwords)
-				result, _ := service.SignIn(core.SignInInput{
+				result, _ := service.SignIn(t.Context(), core.SignInInput{
 					Email:    "charlie@example.com",
 					Password: "SecurePass123!",
 				}, "127.0.0.1", "test-agent")
@@ -1398,7 +1526,7 @@ func TestSessionManager_GetSession(t *testing.T) {
 			}
 
 			// Act
-			sessionData, err := service.GetSession(token)
+			sessionData, err := service.GetSession(t.Context(), token)
 
 			// Assert
 			if (err != nil) != test.wantErr {
@@ -1421,3 +1549,158 @@ func TestSessionManager_GetSession(t *testing.T) {
 		})
 	}
 }
+
+// Requirement: GetSession reads a session missing from the cache together
+// with its user, looking the user up on its own only for cached sessions,
+// and reports a deleted user as ErrUserNotFound.
+func TestSessionManager_GetSession_JoinedUser(t *testing.T) {
+	tests := []struct {
+		name        string
+		cached      bool
+		deleteUser  bool
+		wantLookups int
+		wantErr     error
+	}{
+		{name: "from storage", wantLookups: 0},
+		{name: "from cache", cached: true, wantLookups: 1},
+		{name: "deleted user", deleteUser: true, wantErr: core.ErrUserNotFound},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// Arrange
+			storage := &countingUserStorage{FakeStorageProvider: NewFakeStorageProvider()}
+			_ = storage.CreateUser(t.Context(), &core.User{ID: "user-1", Email: "a@example.com"})
+			var cache core.Cache
+			if test.cached {
+				cache = NewFakeCache()
+			}
+			manager := newTestSessionManager(storage, cache)
+			created, err := manager.Create(t.Context(), "user-1", "", "")
+			if err != nil {
+				t.Fatalf("Create error: %v", err)
+			}
+			if test.deleteUser {
+				_ = storage.SoftDeleteUser(t.Context(), "user-1")
+			}
+
+			// Act
+			data, err := manager.GetSession(t.Context(), created.Token)
+
+			// Assert
+			if !errors.Is(err, test.wantErr) {
+				t.Fatalf("GetSession() error = %v, want %v", err, test.wantErr)
+			}
+			if storage.lookups != test.wantLookups {
+				t.Errorf("user lookups = %d, want %d", storage.lookups, test.wantLookups)
+			}
+			if test.wantErr == nil && (data.User == nil || data.User.ID != "user-1") {
+				t.Errorf("GetSession() user = %+v, want user-1", data.User)
+			}
+		})
+	}
+}
+
+// Requirement: Destroying sessions records why in a session.revoked event.
+func TestSessionManager_RevocationReasons(t *testing.T) {
+	tests := []struct {
+		name       string
+		revoke     func(sm *SessionManager, signUp *core.SignUpResult) error
+		wantReason string
+		wantCount  bool // bulk revocation reports a count instead of a session
+	}{
+		{
+			name: "sign out",
+			revoke: func(sm *SessionManager, signUp *core.SignUpResult) error {
+				return sm.SignOut(t.Context(), signUp.Token)
+			},
+			wantReason: core.RevokeReasonSignOut,
+		},
+		{
+			name: "admin revoke",
+			revoke: func(sm *SessionManager, signUp *core.SignUpResult) error {
+				_, err := sm.RevokeSessions(t.Context(), []string{signUp.Session.ID})
+				return err
+			},
+			wantReason: core.RevokeReasonAdmin,
+		},
+		{
+			name: "password change",
+			revoke: func(sm *SessionManager, signUp *core.SignUpResult) error {
+				_, err := sm.RevokeUserSessions(t.Context(), signUp.User.ID, core.RevokeReasonPasswordChange)
+				return err
+			},
+			wantReason: core.RevokeReasonPasswordChange,
+			wantCount:  true,
+		},
+		{
+			name: "destroy without reason",
+			revoke: func(sm *SessionManager, signUp *core.SignUpResult) error {
+				return sm.DestroyBySessionID(t.Context(), signUp.Session.ID)
+			},
+			wantReason: core.RevokeReasonUnspecified,
+		},
+		{
+			name: "user deleted",
+			revoke: func(sm *SessionManager, signUp *core.SignUpResult) error {
+				return sm.DeleteUser(t.Context(), signUp.User.ID)
+			},
+			wantReason: core.RevokeReasonUserDeleted,
+			wantCount:  true,
+		},
+		{
+			name: "refresh token reuse",
+			revoke: func(sm *SessionManager, signUp *core.SignUpResult) error {
+				if _, err := sm.Refresh(t.Context(), signUp.RefreshToken); err != nil {
+					return err
+				}
+				_, _ = sm.Refresh(t.Context(), signUp.RefreshToken)
+				return nil
+			},
+			wantReason: core.RevokeReasonRefreshReuse,
+			wantCount:  true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// Arrange
+			var revoked []core.Event
+			handler := core.EventHandlerFunc(func(e core.Event) {
+				if e.Type == core.EventSessionRevoked {
+					revoked = append(revoked, e)
+				}
+			})
+			passwords := &crypto.Argon2{Memory: 1024, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32}
+			sm := NewSessionManager(core.SessionConfig{MaxAge: time.Hour}, NewFakeStorageProvider(), nil, passwords,
+				WithEventHandler(handler))
+			signUp, err := sm.SignUp(t.Context(), core.SignUpInput{Email: "alice@example.com", Password: "SecurePass123!"}, "", "")
+			if err != nil {
+				t.Fatalf("SignUp() error = %v", err)
+			}
+
+			// Act
+			if err := test.revoke(sm, signUp); err != nil {
+				t.Fatalf("revoke error = %v", err)
+			}
+
+			// Assert
+			if len(revoked) != 1 {
+				t.Fatalf("session.revoked events = %+v, want exactly one", revoked)
+			}
+			event := revoked[0]
+			if event.Metadata["reason"] != test.wantReason {
+				t.Errorf("reason = %v, want %q", event.Metadata["reason"], test.wantReason)
+			}
+			if event.UserID != signUp.User.ID {
+				t.Errorf("UserID = %q, want %q", event.UserID, signUp.User.ID)
+			}
+			if _, hasCount := event.Metadata["count"]; hasCount != test.wantCount {
+				t.Errorf("count reported = %v, want %v", hasCount, test.wantCount)
+			}
+			if !test.wantCount && event.SessionID != signUp.Session.ID {
+				t.Errorf("SessionID = %q, want %q", event.SessionID, signUp.Session.ID)
+			}
+		})
+	}
+}
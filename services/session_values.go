@@ -0,0 +1,110 @@
+package services
+
+import (
+	"context"
+	"maps"
+
+	"github.com/lborres/kuta/core"
+)
+
+const (
+	maxSessionValueKeyLength = 128
+	maxSessionValueLength    = 4096
+)
+
+// SetValue stores value under key in the session, e.g. a CSRF secret or the
+// current onboarding step. Values live and die with the session, survive
+// refresh rotation and are never included in session responses.
+//
+// The cached copy of the session is updated too (write-through), and other
+// instances are told to drop theirs. Stateless sessions can't hold values and
+// return ErrNotImplemented.
+func (sm *SessionManager) SetValue(ctx context.Context, sessionID, key, value string) error {
+	if err := sm.checkSessionValue(sessionID, key); err != nil {
+		return err
+	}
+	if len(value) > maxSessionValueLength {
+		return core.ErrInvalidSessionValue
+	}
+
+	if err := sm.storage.SetSessionValue(ctx, sessionID, key, value); err != nil {
+		return err
+	}
+	sm.recacheSession(ctx, sessionID)
+	return nil
+}
+
+// GetValue returns the value stored under key in the session, and whether
+// one was set
+func (sm *SessionManager) GetValue(ctx context.Context, sessionID, key string) (string, bool, error) {
+	if err := sm.checkSessionValue(sessionID, key); err != nil {
+		return "", false, err
+	}
+
+	session, err := sm.storage.GetSessionByID(ctx, sessionID)
+	if err != nil {
+		return "", false, err
+	}
+	value, ok := session.Data[key]
+	return value, ok, nil
+}
+
+// DeleteValue removes key from the session. Removing a key that isn't set is
+// not an error.
+func (sm *SessionManager) DeleteValue(ctx context.Context, sessionID, key string) error {
+	if err := sm.checkSessionValue(sessionID, key); err != nil {
+		return err
+	}
+
+	if err := sm.storage.DeleteSessionValue(ctx, sessionID, key); err != nil {
+		return err
+	}
+	sm.recacheSession(ctx, sessionID)
+	return nil
+}
+
+func (sm *SessionManager) checkSessionValue(sessionID, key string) error {
+	if sm.sealer != nil {
+		return core.ErrNotImplemented
+	}
+	if sessionID == "" {
+		return core.ErrSessionNotFound
+	}
+	if key == "" || len(key) > maxSessionValueKeyLength {
+		return core.ErrInvalidSessionValue
+	}
+	return nil
+}
+
+// recacheSession replaces the cached copy of a session after its values
+// changed. Failures are ignored: the cache TTL bounds how long a stale copy
+// is served.
+func (sm *SessionManager) recacheSession(ctx context.Context, sessionID string) {
+	if sm.cache == nil {
+		return
+	}
+	session, err := sm.storage.GetSessionByID(ctx, sessionID)
+	if err != nil {
+		return
+	}
+	_ = sm.cache.Set(session.TokenHash, session)
+	sm.publishRevocation(core.Revocation{TokenHashes: []string{session.TokenHash}})
+}
+
+// carrySessionValues copies the values of a rotated-out session to its
+// replacement
+func (sm *SessionManager) carrySessionValues(ctx context.Context, session *core.Session, values map[string]string) error {
+	if len(values) == 0 {
+		return nil
+	}
+	for key, value := range values {
+		if err := sm.storage.SetSessionValue(ctx, session.ID, key, value); err != nil {
+			return err
+		}
+	}
+	session.Data = maps.Clone(values)
+	if sm.cache != nil {
+		_ = sm.cache.Set(session.TokenHash, session)
+	}
+	return nil
+}
@@ -0,0 +1,112 @@
+package services
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lborres/kuta/core"
+)
+
+// Requirement: Session values can be set, read and deleted by session ID,
+// with keys and values checked for size.
+func TestSessionManager_SessionValues(t *testing.T) {
+	tests := []struct {
+		name      string
+		key       string
+		value     string
+		sessionID string // empty uses the created session
+		wantErr   error
+	}{
+		{name: "stores value", key: "csrf", value: "secret"},
+		{name: "empty value is allowed", key: "step", value: ""},
+		{name: "empty key", key: "", value: "x", wantErr: core.ErrInvalidSessionValue},
+		{name: "key too long", key: strings.Repeat("k", maxSessionValueKeyLength+1), value: "x", wantErr: core.ErrInvalidSessionValue},
+		{name: "value too long", key: "big", value: strings.Repeat("v", maxSessionValueLength+1), wantErr: core.ErrInvalidSessionValue},
+		{name: "unknown session", key: "csrf", value: "secret", sessionID: "missing", wantErr: core.ErrSessionNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			storage := NewFakeStorageProvider()
+			manager := newTestSessionManager(storage, nil)
+			created, err := manager.Create(t.Context(), "user-1", "127.0.0.1", "test-agent")
+			if err != nil {
+				t.Fatalf("Create() error = %v", err)
+			}
+			sessionID := tt.sessionID
+			if sessionID == "" {
+				sessionID = created.Session.ID
+			}
+
+			// Act
+			err = manager.SetValue(t.Context(), sessionID, tt.key, tt.value)
+
+			// Assert
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("SetValue() error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil {
+				return
+			}
+			got, ok, err := manager.GetValue(t.Context(), sessionID, tt.key)
+			if err != nil || !ok || got != tt.value {
+				t.Fatalf("GetValue() = %q, %v, %v, want %q, true, nil", got, ok, err, tt.value)
+			}
+			if err := manager.DeleteValue(t.Context(), sessionID, tt.key); err != nil {
+				t.Fatalf("DeleteValue() error = %v", err)
+			}
+			if _, ok, _ := manager.GetValue(t.Context(), sessionID, tt.key); ok {
+				t.Error("expected value to be gone after DeleteValue")
+			}
+		})
+	}
+}
+
+// Requirement: Values are written through to the cache so Verify sees them,
+// and carried over to the new session when a refresh token rotates it.
+func TestSessionManager_SessionValues_CacheAndRefresh(t *testing.T) {
+	// Arrange
+	storage := NewFakeStorageProvider()
+	cache := NewFakeCache()
+	manager := newTestSessionManager(storage, cache)
+	created, refreshToken := createRefreshableSession(t, manager, storage, "user-1", "")
+
+	// Act
+	if err := manager.SetValue(t.Context(), created.Session.ID, "step", "profile"); err != nil {
+		t.Fatalf("SetValue() error = %v", err)
+	}
+	verified, verifyErr := manager.Verify(t.Context(), created.Token)
+	refreshed, refreshErr := manager.Refresh(t.Context(), refreshToken)
+
+	// Assert
+	if verifyErr != nil {
+		t.Fatalf("Verify() error = %v", verifyErr)
+	}
+	if verified.Data["step"] != "profile" {
+		t.Errorf("cached session Data = %v, want step=profile", verified.Data)
+	}
+	if refreshErr != nil {
+		t.Fatalf("Refresh() error = %v", refreshErr)
+	}
+	got, ok, err := manager.GetValue(t.Context(), refreshed.Session.ID, "step")
+	if err != nil || !ok || got != "profile" {
+		t.Errorf("GetValue() after refresh = %q, %v, %v, want profile, true, nil", got, ok, err)
+	}
+}
+
+// Requirement: Stateless sessions have nowhere to keep values and say so.
+func TestSessionManager_SessionValues_Stateless(t *testing.T) {
+	// Arrange
+	manager := newStatelessSessionManager(t, NewFakeStorageProvider(), time.Hour)
+
+	// Act
+	err := manager.SetValue(t.Context(), "session-1", "csrf", "secret")
+
+	// Assert
+	if !errors.Is(err, core.ErrNotImplemented) {
+		t.Errorf("SetValue() error = %v, want ErrNotImplemented", err)
+	}
+}
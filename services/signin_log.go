@@ -0,0 +1,106 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/lborres/kuta/core"
+)
+
+const (
+	defaultSignInLogRetention = 90 * 24 * time.Hour
+	defaultRecentAttempts     = 20
+	maxRecentAttempts         = 100
+)
+
+// signInLog is where sign-in attempts are recorded, and for how long they
+// are kept
+type signInLog struct {
+	log       core.SignInLog
+	retention time.Duration
+}
+
+// recordSignIn logs the outcome of the attempt record describes. Attempts
+// waiting on a challenge are left for ContinueSignIn to log, and malformed
+// requests aren't logged at all. A failure to log doesn't fail the sign-in.
+func (sm *SessionManager) recordSignIn(ctx context.Context, record *core.SignInRecord, result *core.SignInResult, err error) {
+	if sm.signInLog == nil {
+		return
+	}
+	switch {
+	case errors.Is(err, core.ErrValidationFailed):
+		return
+	case err != nil:
+		record.Reason = err.Error()
+	case result.Session == nil:
+		return
+	default:
+		record.Success = true
+		record.UserID = result.User.ID
+		record.Email = result.User.Email
+	}
+
+	id, idErr := sm.nanoid.Generate()
+	if idErr != nil {
+		return
+	}
+	record.ID = id
+	record.CreatedAt = time.Now()
+	_ = sm.signInLog.log.RecordSignIn(ctx, record)
+}
+
+// Ensure SessionManager implements ActivityProvider
+var _ core.ActivityProvider = (*SessionManager)(nil)
+
+// GetRecentAttempts returns up to limit of the user's logged sign-in
+// attempts, newest first. limit defaults to 20 and is capped at 100.
+func (sm *SessionManager) GetRecentAttempts(ctx context.Context, userID string, limit int) ([]*core.SignInRecord, error) {
+	page, err := sm.ListActivity(ctx, userID, limit, 0)
+	if err != nil {
+		return nil, err
+	}
+	return page.SignIns, nil
+}
+
+// ActivityEnabled reports whether a sign-in log has been configured, so the
+// activity endpoint has something to show
+func (sm *SessionManager) ActivityEnabled() bool {
+	return sm.signInLog != nil
+}
+
+// ListActivity returns a page of the user's logged sign-in attempts, newest
+// first. limit defaults to 20 and is capped at 100.
+func (sm *SessionManager) ListActivity(ctx context.Context, userID string, limit, offset int) (*core.ActivityPage, error) {
+	if sm.signInLog == nil {
+		return nil, core.ErrNotImplemented
+	}
+	if limit <= 0 {
+		limit = defaultRecentAttempts
+	}
+	limit = min(limit, maxRecentAttempts)
+	offset = max(offset, 0)
+
+	records, total, err := sm.signInLog.log.ListSignInRecords(ctx, userID, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	if records == nil {
+		records = []*core.SignInRecord{}
+	}
+
+	return &core.ActivityPage{
+		SignIns: records,
+		Total:   total,
+		Limit:   limit,
+		Offset:  offset,
+	}, nil
+}
+
+// purgeSignInRecords removes logged attempts past their retention
+func (sm *SessionManager) purgeSignInRecords(ctx context.Context, now time.Time) (int, error) {
+	if sm.signInLog == nil {
+		return 0, nil
+	}
+	return sm.signInLog.log.PurgeSignInRecords(ctx, now.Add(-sm.signInLog.retention))
+}
@@ -0,0 +1,270 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/lborres/kuta/core"
+	"github.com/lborres/kuta/pkg/cache"
+	"github.com/lborres/kuta/pkg/crypto"
+)
+
+// capturingSignInLog keeps every record, including those the in-memory log
+// drops for matching no user
+type capturingSignInLog struct {
+	*cache.InMemorySignInLog
+	records []*core.SignInRecord
+}
+
+func (l *capturingSignInLog) RecordSignIn(ctx context.Context, record *core.SignInRecord) error {
+	stored := *record
+	l.records = append(l.records, &stored)
+	return l.InMemorySignInLog.RecordSignIn(ctx, record)
+}
+
+// Requirement: Every credential sign-in attempt is logged with its outcome,
+// reason and origin; malformed requests and attempts still waiting on a
+// challenge are not.
+func TestSessionManager_SignInLog_Credential(t *testing.T) {
+	tests := []struct {
+		name        string
+		input       core.SignInInput
+		challenge   *fakeChallenger
+		response    string
+		wantRecords int
+		wantSuccess bool
+		wantReason  string
+		wantUser    bool
+	}{
+		{
+			name:        "success",
+			input:       core.SignInInput{Email: "user@example.com", Password: "CorrectPass123!"},
+			wantRecords: 1,
+			wantSuccess: true,
+			wantUser:    true,
+		},
+		{
+			name:        "wrong password",
+			input:       core.SignInInput{Email: "user@example.com", Password: "WrongPass123!"},
+			wantRecords: 1,
+			wantReason:  core.ErrInvalidCredentials.Error(),
+			wantUser:    true,
+		},
+		{
+			name:        "unknown email",
+			input:       core.SignInInput{Email: "nobody@example.com", Password: "WrongPass123!"},
+			wantRecords: 1,
+			wantReason:  core.ErrInvalidCredentials.Error(),
+		},
+		{
+			name:  "missing password",
+			input: core.SignInInput{Email: "user@example.com"},
+		},
+		{
+			name:        "challenge answered",
+			input:       core.SignInInput{Email: "user@example.com", Password: "CorrectPass123!"},
+			challenge:   &fakeChallenger{kind: core.ChallengeTwoFactor, required: true, answer: "123456"},
+			response:    "123456",
+			wantRecords: 1,
+			wantSuccess: true,
+			wantUser:    true,
+		},
+		{
+			name:        "challenge failed",
+			input:       core.SignInInput{Email: "user@example.com", Password: "CorrectPass123!"},
+			challenge:   &fakeChallenger{kind: core.ChallengeTwoFactor, required: true, answer: "123456"},
+			response:    "000000",
+			wantRecords: 1,
+			wantReason:  core.ErrChallengeFailed.Error(),
+			wantUser:    true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// Arrange
+			log := &capturingSignInLog{InMemorySignInLog: cache.NewInMemorySignInLog()}
+			passwords := &crypto.Argon2{Memory: 1024, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32}
+			opts := []Option{WithSignInLog(log, 0)}
+			if test.challenge != nil {
+				opts = append(opts, WithSignInChallenges(cache.NewInMemoryPendingSignInStore(), 0, *test.challenge))
+			}
+			manager := NewSessionManager(core.SessionConfig{MaxAge: time.Hour}, NewFakeStorageProvider(), nil, passwords, opts...)
+			signUp, err := manager.SignUp(t.Context(), core.SignUpInput{Email: "user@example.com", Password: "CorrectPass123!"}, "", "")
+			if err != nil {
+				t.Fatalf("SignUp() error = %v", err)
+			}
+
+			// Act
+			result, _ := manager.SignIn(t.Context(), test.input, "10.0.0.1", "test-agent")
+			if test.challenge != nil {
+				if len(log.records) != 0 {
+					t.Fatalf("records before the challenge = %+v, want none", log.records)
+				}
+				_, _ = manager.ContinueSignIn(t.Context(), core.ContinueSignInInput{ChallengeToken: result.Challenge.Token, Response: test.response})
+			}
+
+			// Assert
+			if len(log.records) != test.wantRecords {
+				t.Fatalf("records = %d, want %d", len(log.records), test.wantRecords)
+			}
+			if test.wantRecords == 0 {
+				return
+			}
+			record := log.records[0]
+			if record.Success != test.wantSuccess || record.Reason != test.wantReason ||
+				record.ProviderID != core.CredentialProviderID || record.Email != test.input.Email ||
+				record.IPAddress != "10.0.0.1" || record.UserAgent != "test-agent" ||
+				record.ID == "" || record.CreatedAt.IsZero() {
+				t.Errorf("record = %+v", record)
+			}
+			wantUserID := ""
+			if test.wantUser {
+				wantUserID = signUp.User.ID
+			}
+			if record.UserID != wantUserID {
+				t.Errorf("record.UserID = %q, want %q", record.UserID, wantUserID)
+			}
+		})
+	}
+}
+
+// Requirement: OAuth sign-ins are logged under their provider, while
+// callbacks carrying no state we issued are not attempts on anyone.
+func TestSessionManager_SignInLog_OAuth(t *testing.T) {
+	tests := []struct {
+		name        string
+		forgeState  bool
+		wantRecords int
+	}{
+		{name: "callback completes", wantRecords: 1},
+		{name: "forged state", forgeState: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// Arrange
+			log := &capturingSignInLog{InMemorySignInLog: cache.NewInMemorySignInLog()}
+			provider := &fakeOAuthProvider{identity: core.OAuthIdentity{AccountID: "sub-1", Email: "oauth@example.com", EmailVerified: true}}
+			manager := NewSessionManager(core.SessionConfig{MaxAge: time.Hour}, NewFakeStorageProvider(), nil, nil,
+				WithOAuth(cache.NewInMemoryOAuthStateStore(), "https://app.example/api/auth", provider),
+				WithSignInLog(log, 0))
+			start, err := manager.StartOAuth("test")
+			if err != nil {
+				t.Fatalf("StartOAuth() error = %v", err)
+			}
+			state := start.State
+			if test.forgeState {
+				state = "forged"
+			}
+
+			// Act
+			result, _ := manager.CompleteOAuth(t.Context(), "test", core.OAuthCallback{Code: "code", State: state, StateCookie: state}, "10.0.0.1", "test-agent")
+
+			// Assert
+			if len(log.records) != test.wantRecords {
+				t.Fatalf("records = %d, want %d", len(log.records), test.wantRecords)
+			}
+			if test.wantRecords == 0 {
+				return
+			}
+			recent, err := manager.GetRecentAttempts(t.Context(), result.User.ID, 0)
+			if err != nil {
+				t.Fatalf("GetRecentAttempts() error = %v", err)
+			}
+			if len(recent) != 1 || !recent[0].Success || recent[0].ProviderID != "test" || recent[0].Email != "oauth@example.com" {
+				t.Errorf("GetRecentAttempts() = %+v", recent)
+			}
+		})
+	}
+}
+
+// Requirement: Recent attempts are unavailable without a sign-in log, and
+// cleanup purges logged attempts past their retention.
+func TestSessionManager_SignInLog_Retention(t *testing.T) {
+	// Arrange
+	log := cache.NewInMemorySignInLog()
+	now := time.Now()
+	for _, at := range []time.Time{now.Add(-48 * time.Hour), now.Add(-time.Hour)} {
+		_ = log.RecordSignIn(t.Context(), &core.SignInRecord{ID: at.String(), UserID: "user-1", Success: true, CreatedAt: at})
+	}
+	manager := newTestSessionManager(NewFakeStorageProvider(), nil)
+	logged := NewSessionManager(core.SessionConfig{MaxAge: time.Hour}, NewFakeStorageProvider(), nil, nil,
+		WithSignInLog(log, 24*time.Hour))
+
+	// Act
+	_, disabledErr := manager.GetRecentAttempts(t.Context(), "user-1", 10)
+	cleanupErr := logged.RunCleanupOnce(t.Context())
+	recent, err := logged.GetRecentAttempts(t.Context(), "user-1", 10)
+
+	// Assert
+	if !errors.Is(disabledErr, core.ErrNotImplemented) {
+		t.Errorf("GetRecentAttempts() without a log error = %v, want ErrNotImplemented", disabledErr)
+	}
+	if cleanupErr != nil || err != nil {
+		t.Fatalf("RunCleanupOnce() error = %v, GetRecentAttempts() error = %v", cleanupErr, err)
+	}
+	if len(recent) != 1 || !recent[0].CreatedAt.Equal(now.Add(-time.Hour)) {
+		t.Errorf("GetRecentAttempts() after cleanup = %+v, want the attempt within retention", recent)
+	}
+}
+
+// Requirement: Activity is listed a page at a time, with the page size
+// defaulted and capped, and is disabled without a sign-in log.
+func TestSessionManager_ListActivity(t *testing.T) {
+	tests := []struct {
+		name       string
+		limit      int
+		offset     int
+		wantIDs    []string
+		wantLimit  int
+		wantOffset int
+	}{
+		{name: "first page", limit: 2, wantIDs: []string{"2", "1"}, wantLimit: 2},
+		{name: "second page", limit: 2, offset: 2, wantIDs: []string{"0"}, wantLimit: 2, wantOffset: 2},
+		{name: "default page size", wantIDs: []string{"2", "1", "0"}, wantLimit: defaultRecentAttempts},
+		{name: "capped page size", limit: 1000, wantIDs: []string{"2", "1", "0"}, wantLimit: maxRecentAttempts},
+		{name: "negative offset", limit: 1, offset: -5, wantIDs: []string{"2"}, wantLimit: 1},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// Arrange
+			log := cache.NewInMemorySignInLog()
+			start := time.Now()
+			for i := range 3 {
+				_ = log.RecordSignIn(t.Context(), &core.SignInRecord{ID: fmt.Sprint(i), UserID: "user-1", CreatedAt: start.Add(time.Duration(i) * time.Second)})
+			}
+			manager := NewSessionManager(core.SessionConfig{MaxAge: time.Hour}, NewFakeStorageProvider(), nil, nil,
+				WithSignInLog(log, 0))
+
+			// Act
+			page, err := manager.ListActivity(t.Context(), "user-1", test.limit, test.offset)
+
+			// Assert
+			if err != nil {
+				t.Fatalf("ListActivity() error = %v", err)
+			}
+			var ids []string
+			for _, record := range page.SignIns {
+				ids = append(ids, record.ID)
+			}
+			if fmt.Sprint(ids) != fmt.Sprint(test.wantIDs) || page.Total != 3 ||
+				page.Limit != test.wantLimit || page.Offset != test.wantOffset {
+				t.Errorf("ListActivity() = %v (total %d, limit %d, offset %d), want %v (total 3, limit %d, offset %d)",
+					ids, page.Total, page.Limit, page.Offset, test.wantIDs, test.wantLimit, test.wantOffset)
+			}
+		})
+	}
+
+	disabled := newTestSessionManager(NewFakeStorageProvider(), nil)
+	if disabled.ActivityEnabled() {
+		t.Error("ActivityEnabled() without a sign-in log = true")
+	}
+	if _, err := disabled.ListActivity(t.Context(), "user-1", 10, 0); !errors.Is(err, core.ErrNotImplemented) {
+		t.Errorf("ListActivity() without a log error = %v, want ErrNotImplemented", err)
+	}
+}
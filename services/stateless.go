@@ -0,0 +1,97 @@
+package services
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/lborres/kuta/core"
+)
+
+// StatelessSealerPurpose namespaces the key used to seal stateless sessions
+const StatelessSealerPurpose = "stateless-session"
+
+// statelessVersion is bumped whenever statelessPayload changes shape
+const statelessVersion = 1
+
+// statelessPayload is the session as carried inside a sealed token
+type statelessPayload struct {
+	Version   int    `json:"v"`
+	ID        string `json:"id"`
+	UserID    string `json:"uid"`
+	IPAddress string `json:"ip,omitempty"`
+	UserAgent string `json:"ua,omitempty"`
+	PublicKey string `json:"pk,omitempty"`
+	ExpiresAt int64  `json:"exp"`
+	CreatedAt int64  `json:"iat"`
+	// Actor and ActorAt are the impersonating admin and when the
+	// impersonation started. Omitted otherwise, so older tokens still open.
+	Actor   string `json:"act,omitempty"`
+	ActorAt int64  `json:"act_at,omitempty"`
+	// Entitlements are omitted without an EntitlementResolver, likewise
+	Entitlements *core.Entitlements `json:"ent,omitempty"`
+}
+
+// sealSession encodes session into an encrypted token
+func (sm *SessionManager) sealSession(session *core.Session) (string, error) {
+	payload := statelessPayload{
+		Version:   statelessVersion,
+		ID:        session.ID,
+		UserID:    session.UserID,
+		IPAddress: session.IPAddress,
+		UserAgent: session.UserAgent,
+		PublicKey: session.PublicKey,
+		ExpiresAt: session.ExpiresAt.Unix(),
+		CreatedAt: session.CreatedAt.Unix(),
+
+		Entitlements: session.Entitlements,
+	}
+	if session.Impersonation != nil {
+		payload.Actor = session.Impersonation.ActorUserID
+		payload.ActorAt = session.Impersonation.StartedAt.Unix()
+	}
+
+	plaintext, err := json.Marshal(payload)
+	if err != nil {
+		return "", err
+	}
+
+	return sm.sealer.Seal(plaintext)
+}
+
+// openSession decodes and validates a sealed token
+func (sm *SessionManager) openSession(token string) (*core.Session, error) {
+	plaintext, err := sm.sealer.Open(token)
+	if err != nil {
+		return nil, core.ErrInvalidToken
+	}
+
+	var payload statelessPayload
+	if err := json.Unmarshal(plaintext, &payload); err != nil || payload.Version != statelessVersion {
+		return nil, core.ErrInvalidToken
+	}
+
+	session := &core.Session{
+		ID:        payload.ID,
+		UserID:    payload.UserID,
+		IPAddress: payload.IPAddress,
+		UserAgent: payload.UserAgent,
+		PublicKey: payload.PublicKey,
+		ExpiresAt: time.Unix(payload.ExpiresAt, 0),
+		CreatedAt: time.Unix(payload.CreatedAt, 0),
+		UpdatedAt: time.Unix(payload.CreatedAt, 0),
+
+		Entitlements: payload.Entitlements,
+	}
+	if payload.Actor != "" {
+		session.Impersonation = &core.Impersonation{
+			ActorUserID: payload.Actor,
+			StartedAt:   time.Unix(payload.ActorAt, 0),
+		}
+	}
+
+	if time.Now().After(session.ExpiresAt) {
+		return nil, core.ErrSessionExpired
+	}
+
+	return session, nil
+}
@@ -0,0 +1,192 @@
+package services
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/lborres/kuta/core"
+	"github.com/lborres/kuta/pkg/crypto"
+)
+
+func newStatelessSessionManager(t *testing.T, storage core.StorageProvider, maxAge time.Duration) *SessionManager {
+	t.Helper()
+	sealer, err := crypto.NewSealer("this-is-a-test-secret-of-32-bytes!", StatelessSealerPurpose)
+	if err != nil {
+		t.Fatalf("NewSealer error: %v", err)
+	}
+	config := core.SessionConfig{MaxAge: maxAge}
+	return NewSessionManager(config, storage, nil, crypto.NewArgon2(), WithStatelessSessions(sealer))
+}
+
+// Requirement: Stateless sessions are carried in the token and never stored.
+func TestSessionManager_Stateless_CreateAndVerify(t *testing.T) {
+	// Arrange
+	storage := NewFakeStorageProvider()
+	_ = storage.CreateUser(t.Context(), &core.User{ID: "user-1", Email: "a@example.com"})
+	manager := newStatelessSessionManager(t, storage, time.Hour)
+
+	// Act
+	result, err := manager.Create(t.Context(), "user-1", "10.0.0.1", "test-agent")
+	if err != nil {
+		t.Fatalf("Create error: %v", err)
+	}
+
+	// Session lookups failing must not matter: Verify should not make any
+	storage.getErr = errors.New("session storage should not be called")
+	session, err := manager.Verify(t.Context(), result.Token)
+
+	// Assert
+	if err != nil {
+		t.Fatalf("Verify error: %v", err)
+	}
+	if len(storage.sessions) != 0 {
+		t.Errorf("expected no stored sessions, got %d", len(storage.sessions))
+	}
+	if session.ID != result.Session.ID || session.UserID != "user-1" {
+		t.Errorf("unexpected session: %+v", session)
+	}
+	if session.IPAddress != "10.0.0.1" || session.UserAgent != "test-agent" {
+		t.Errorf("client metadata not preserved: %+v", session)
+	}
+}
+
+// Requirement: Tampered, foreign and expired stateless tokens are rejected.
+func TestSessionManager_Stateless_VerifyRejects(t *testing.T) {
+	tests := []struct {
+		name      string
+		makeToken func(t *testing.T) string
+		wantErr   error
+	}{
+		{
+			name: "tampered token",
+			makeToken: func(t *testing.T) string {
+				manager := newStatelessSessionManager(t, NewFakeStorageProvider(), time.Hour)
+				result, err := manager.Create(t.Context(), "user-1", "", "")
+				if err != nil {
+					t.Fatalf("Create error: %v", err)
+				}
+				b := []byte(result.Token)
+				if b[len(b)-2] == 'A' {
+					b[len(b)-2] = 'B'
+				} else {
+					b[len(b)-2] = 'A'
+				}
+				return string(b)
+			},
+			wantErr: core.ErrInvalidToken,
+		},
+		{
+			name: "plain random token",
+			makeToken: func(t *testing.T) string {
+				pair, err := crypto.GenerateHashedToken()
+				if err != nil {
+					t.Fatalf("GenerateHashedToken error: %v", err)
+				}
+				return pair.Token
+			},
+			wantErr: core.ErrInvalidToken,
+		},
+		{
+			name: "expired token",
+			makeToken: func(t *testing.T) string {
+				manager := newStatelessSessionManager(t, NewFakeStorageProvider(), -time.Minute)
+				result, err := manager.Create(t.Context(), "user-1", "", "")
+				if err != nil {
+					t.Fatalf("Create error: %v", err)
+				}
+				return result.Token
+			},
+			wantErr: core.ErrSessionExpired,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// Arrange
+			manager := newStatelessSessionManager(t, NewFakeStorageProvider(), time.Hour)
+			token := test.makeToken(t)
+
+			// Act
+			_, err := manager.Verify(t.Context(), token)
+
+			// Assert
+			if !errors.Is(err, test.wantErr) {
+				t.Errorf("expected %v, got %v", test.wantErr, err)
+			}
+		})
+	}
+}
+
+// Requirement: Deleting a user stops their stateless sessions at once, with
+// or without soft deletes.
+func TestSessionManager_Stateless_DeletedUser(t *testing.T) {
+	tests := []struct {
+		name       string
+		softDelete bool
+	}{
+		{name: "soft delete", softDelete: true},
+		{name: "hard delete"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// Arrange
+			var storage core.StorageProvider = NewFakeStorageProvider()
+			if !test.softDelete {
+				storage = FakeLimitedStorage{FakeStorageProvider: storage.(*FakeStorageProvider), Supported: core.StorageCapabilities{Transactions: true, Search: true}}
+			}
+			_ = storage.CreateUser(t.Context(), &core.User{ID: "user-1", Email: "a@example.com"})
+			manager := newStatelessSessionManager(t, storage, time.Hour)
+			result, err := manager.Create(t.Context(), "user-1", "", "")
+			if err != nil {
+				t.Fatalf("Create error: %v", err)
+			}
+
+			// Act
+			if err := manager.DeleteUser(t.Context(), "user-1"); err != nil {
+				t.Fatalf("DeleteUser error: %v", err)
+			}
+			_, verifyErr := manager.Verify(t.Context(), result.Token)
+			_, getErr := manager.GetSession(t.Context(), result.Token)
+
+			// Assert
+			if !errors.Is(verifyErr, core.ErrUserNotFound) || !errors.Is(getErr, core.ErrUserNotFound) {
+				t.Errorf("Verify error = %v, GetSession error = %v, want %v", verifyErr, getErr, core.ErrUserNotFound)
+			}
+		})
+	}
+}
+
+// Requirement: Refresh issues a new stateless token for the same user.
+// Refresh tokens are still stored, so signing out revokes them.
+func TestSessionManager_Stateless_Refresh(t *testing.T) {
+	// Arrange
+	storage := NewFakeStorageProvider()
+	manager := newStatelessSessionManager(t, storage, time.Hour)
+	created, refreshToken := createRefreshableSession(t, manager, storage, "user-1", "")
+
+	// Act
+	refreshed, err := manager.Refresh(t.Context(), refreshToken)
+
+	// Assert
+	if err != nil {
+		t.Fatalf("Refresh error: %v", err)
+	}
+	if refreshed.Token == created.Token {
+		t.Error("expected a new token")
+	}
+	session, err := manager.Verify(t.Context(), refreshed.Token)
+	if err != nil {
+		t.Fatalf("Verify refreshed token error: %v", err)
+	}
+	if session.UserID != "user-1" {
+		t.Errorf("expected user-1, got %s", session.UserID)
+	}
+	if err := manager.SignOut(t.Context(), refreshed.Token); err != nil {
+		t.Fatalf("SignOut error: %v", err)
+	}
+	if _, err := manager.Refresh(t.Context(), refreshed.RefreshToken); !errors.Is(err, core.ErrInvalidToken) {
+		t.Errorf("expected %v after sign-out, got %v", core.ErrInvalidToken, err)
+	}
+}
@@ -1,8 +1,14 @@
 package services
 
 import (
+	"context"
 	"errors"
+	"maps"
+	"slices"
+	"sort"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/lborres/kuta/core"
 )
@@ -23,7 +29,7 @@ func NewFakeSessionStorage() *FakeSessionStorage {
 	}
 }
 
-func (f *FakeSessionStorage) CreateSession(s *core.Session) error {
+func (f *FakeSessionStorage) CreateSession(ctx context.Context, s *core.Session) error {
 	f.mu.Lock()
 	defer f.mu.Unlock()
 
@@ -35,7 +41,7 @@ func (f *FakeSessionStorage) CreateSession(s *core.Session) error {
 	return nil
 }
 
-func (f *FakeSessionStorage) GetSessionByHash(tokenHash string) (*core.Session, error) {
+func (f *FakeSessionStorage) GetSessionByHash(ctx context.Context, tokenHash string) (*core.Session, error) {
 	f.mu.RLock()
 	defer f.mu.RUnlock()
 	if f.getErr != nil {
@@ -43,12 +49,27 @@ func (f *FakeSessionStorage) GetSessionByHash(tokenHash string) (*core.Session,
 	}
 	s, ok := f.sessions[tokenHash]
 	if !ok {
-		return nil, errors.New("session not found")
+		return nil, core.ErrSessionNotFound
 	}
 	return s, nil
 }
 
-func (f *FakeSessionStorage) GetSessionByID(id string) (*core.Session, error) {
+func (f *FakeSessionStorage) GetSessionsByHashes(ctx context.Context, tokenHashes []string) ([]*core.Session, error) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	if f.getErr != nil {
+		return nil, f.getErr
+	}
+	var sessions []*core.Session
+	for _, hash := range tokenHashes {
+		if s, ok := f.sessions[hash]; ok {
+			sessions = append(sessions, s)
+		}
+	}
+	return sessions, nil
+}
+
+func (f *FakeSessionStorage) GetSessionByID(ctx context.Context, id string) (*core.Session, error) {
 	f.mu.RLock()
 	defer f.mu.RUnlock()
 	for _, s := range f.sessions {
@@ -56,10 +77,10 @@ func (f *FakeSessionStorage) GetSessionByID(id string) (*core.Session, error) {
 			return s, nil
 		}
 	}
-	return nil, errors.New("session not found")
+	return nil, core.ErrSessionNotFound
 }
 
-func (f *FakeSessionStorage) DeleteSessionByHash(tokenHash string) error {
+func (f *FakeSessionStorage) DeleteSessionByHash(ctx context.Context, tokenHash string) error {
 	f.mu.Lock()
 	defer f.mu.Unlock()
 	if f.deleteErr != nil {
@@ -72,7 +93,7 @@ func (f *FakeSessionStorage) DeleteSessionByHash(tokenHash string) error {
 	return nil
 }
 
-func (f *FakeSessionStorage) DeleteSessionByID(id string) error {
+func (f *FakeSessionStorage) DeleteSessionByID(ctx context.Context, id string) error {
 	f.mu.Lock()
 	defer f.mu.Unlock()
 	if f.deleteErr != nil {
@@ -87,7 +108,7 @@ func (f *FakeSessionStorage) DeleteSessionByID(id string) error {
 	return core.ErrSessionNotFound
 }
 
-func (f *FakeSessionStorage) GetUserSessions(userID string) ([]*core.Session, error) {
+func (f *FakeSessionStorage) GetUserSessions(ctx context.Context, userID string) ([]*core.Session, error) {
 	f.mu.RLock()
 	defer f.mu.RUnlock()
 	var sessions []*core.Session
@@ -98,10 +119,20 @@ func (f *FakeSessionStorage) GetUserSessions(userID string) ([]*core.Session, er
 	}
 	return sessions, nil
 }
-func (f *FakeSessionStorage) UpdateSession(s *core.Session) error {
-	panic("not implemented")
+func (f *FakeSessionStorage) UpdateSession(ctx context.Context, s *core.Session) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	// Sessions are updated by ID, which lets their token hash rotate
+	for k, existing := range f.sessions {
+		if existing.ID == s.ID {
+			delete(f.sessions, k)
+			f.sessions[s.TokenHash] = s
+			return nil
+		}
+	}
+	return core.ErrSessionNotFound
 }
-func (f *FakeSessionStorage) DeleteUserSessions(userID string) (int, error) {
+func (f *FakeSessionStorage) DeleteUserSessions(ctx context.Context, userID string) (int, error) {
 	f.mu.Lock()
 	defer f.mu.Unlock()
 	count := 0
@@ -113,16 +144,114 @@ func (f *FakeSessionStorage) DeleteUserSessions(userID string) (int, error) {
 	}
 	return count, nil
 }
-func (f *FakeSessionStorage) DeleteExpiredSessions() (int, error) {
-	panic("not implemented")
+func (f *FakeSessionStorage) DeleteExpiredSessions(ctx context.Context) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	now := time.Now()
+	count := 0
+	for k, s := range f.sessions {
+		if now.After(s.ExpiresAt) {
+			delete(f.sessions, k)
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (f *FakeSessionStorage) SearchSessions(ctx context.Context, filter core.SessionFilter) ([]*core.Session, int, error) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	var matches []*core.Session
+	for _, s := range f.sessions {
+		if filter.UserID != "" && s.UserID != filter.UserID {
+			continue
+		}
+		if filter.IPAddress != "" && s.IPAddress != filter.IPAddress {
+			continue
+		}
+		if filter.UserAgent != "" && !strings.Contains(strings.ToLower(s.UserAgent), strings.ToLower(filter.UserAgent)) {
+			continue
+		}
+		if !filter.CreatedAfter.IsZero() && s.CreatedAt.Before(filter.CreatedAfter) {
+			continue
+		}
+		if !filter.CreatedBefore.IsZero() && !s.CreatedAt.Before(filter.CreatedBefore) {
+			continue
+		}
+		matches = append(matches, s)
+	}
+	sort.Slice(matches, func(i, j int) bool { return matches[i].CreatedAt.After(matches[j].CreatedAt) })
+
+	total := len(matches)
+	if filter.Offset >= total {
+		return nil, total, nil
+	}
+	matches = matches[filter.Offset:]
+	if filter.Limit > 0 && filter.Limit < len(matches) {
+		matches = matches[:filter.Limit]
+	}
+	return matches, total, nil
+}
+
+func (f *FakeSessionStorage) DeleteSessionsByIDs(ctx context.Context, ids []string) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.deleteErr != nil {
+		return 0, f.deleteErr
+	}
+	wanted := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		wanted[id] = true
+	}
+	count := 0
+	for k, s := range f.sessions {
+		if wanted[s.ID] {
+			delete(f.sessions, k)
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (f *FakeSessionStorage) SetSessionValue(ctx context.Context, sessionID, key, value string) error {
+	return f.updateSessionData(sessionID, func(data map[string]string) { data[key] = value })
+}
+
+func (f *FakeSessionStorage) DeleteSessionValue(ctx context.Context, sessionID, key string) error {
+	return f.updateSessionData(sessionID, func(data map[string]string) { delete(data, key) })
+}
+
+// updateSessionData replaces the session's Data with an updated copy, so
+// sessions handed out earlier keep the values they were read with
+func (f *FakeSessionStorage) updateSessionData(sessionID string, update func(map[string]string)) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for k, s := range f.sessions {
+		if s.ID != sessionID {
+			continue
+		}
+		data := maps.Clone(s.Data)
+		if data == nil {
+			data = make(map[string]string)
+		}
+		update(data)
+		updated := *s
+		updated.Data = data
+		f.sessions[k] = &updated
+		return nil
+	}
+	return core.ErrSessionNotFound
 }
 
 // FakeStorageProvider is a test-only fake implementing core.StorageProvider.
 // It combines session, user, and account storage fakes.
 type FakeStorageProvider struct {
 	*FakeSessionStorage
-	users    map[string]*core.User
-	accounts map[string]*core.Account
+	users         map[string]*core.User
+	accounts      map[string]*core.Account
+	refreshTokens map[string]*core.RefreshToken
+	userSecurity  map[string]*core.UserSecurity
+	verifications map[string]*core.VerificationToken
 }
 
 func NewFakeStorageProvider() *FakeStorageProvider {
@@ -130,13 +259,43 @@ func NewFakeStorageProvider() *FakeStorageProvider {
 		FakeSessionStorage: NewFakeSessionStorage(),
 		users:              make(map[string]*core.User),
 		accounts:           make(map[string]*core.Account),
+		refreshTokens:      make(map[string]*core.RefreshToken),
+		userSecurity:       make(map[string]*core.UserSecurity),
+		verifications:      make(map[string]*core.VerificationToken),
 	}
 }
 
+// FakeLimitedStorage is a test-only FakeStorageProvider that reports
+// Supported as its capabilities, for testing storage lacking some of them.
+type FakeLimitedStorage struct {
+	*FakeStorageProvider
+	Supported core.StorageCapabilities
+}
+
+func (f FakeLimitedStorage) Capabilities() core.StorageCapabilities {
+	return f.Supported
+}
+
 // UserStorage implementation
-func (f *FakeStorageProvider) CreateUser(u *core.User) error {
+func (f *FakeStorageProvider) CreateUser(ctx context.Context, u *core.User) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if _, exists := f.users[u.ID]; exists {
+		return core.ErrUserExists
+	}
+	f.users[u.ID] = u
+	return nil
+}
+
+func (f *FakeStorageProvider) CreateUserIfNotExists(ctx context.Context, u *core.User) error {
 	f.mu.Lock()
 	defer f.mu.Unlock()
+	for _, existing := range f.users {
+		// Mirrors the database unique index, which skips soft-deleted rows
+		if existing.Email == u.Email && existing.DeletedAt == nil {
+			return core.ErrUserExists
+		}
+	}
 	if _, exists := f.users[u.ID]; exists {
 		return core.ErrUserExists
 	}
@@ -144,7 +303,16 @@ func (f *FakeStorageProvider) CreateUser(u *core.User) error {
 	return nil
 }
 
-func (f *FakeStorageProvider) GetUserByID(id string) (*core.User, error) {
+func (f *FakeStorageProvider) GetUserByID(ctx context.Context, id string) (*core.User, error) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	if u, ok := f.users[id]; ok && u.DeletedAt == nil {
+		return u, nil
+	}
+	return nil, core.ErrUserNotFound
+}
+
+func (f *FakeStorageProvider) GetUserByIDIncludingDeleted(ctx context.Context, id string) (*core.User, error) {
 	f.mu.RLock()
 	defer f.mu.RUnlock()
 	if u, ok := f.users[id]; ok {
@@ -153,28 +321,40 @@ func (f *FakeStorageProvider) GetUserByID(id string) (*core.User, error) {
 	return nil, core.ErrUserNotFound
 }
 
-func (f *FakeStorageProvider) GetUserByEmail(email string) (*core.User, error) {
+func (f *FakeStorageProvider) GetSessionWithUserByHash(ctx context.Context, tokenHash string) (*core.Session, *core.User, error) {
+	session, err := f.GetSessionByHash(ctx, tokenHash)
+	if err != nil {
+		return nil, nil, err
+	}
+	user, err := f.GetUserByID(ctx, session.UserID)
+	if errors.Is(err, core.ErrUserNotFound) {
+		return session, nil, nil
+	}
+	return session, user, err
+}
+
+func (f *FakeStorageProvider) GetUserByEmail(ctx context.Context, email string) (*core.User, error) {
 	f.mu.RLock()
 	defer f.mu.RUnlock()
 	for _, u := range f.users {
-		if u.Email == email {
+		if u.Email == email && u.DeletedAt == nil {
 			return u, nil
 		}
 	}
 	return nil, core.ErrUserNotFound
 }
 
-func (f *FakeStorageProvider) UpdateUser(u *core.User) error {
+func (f *FakeStorageProvider) UpdateUser(ctx context.Context, u *core.User) error {
 	f.mu.Lock()
 	defer f.mu.Unlock()
-	if _, exists := f.users[u.ID]; !exists {
+	if existing, exists := f.users[u.ID]; !exists || existing.DeletedAt != nil {
 		return core.ErrUserNotFound
 	}
 	f.users[u.ID] = u
 	return nil
 }
 
-func (f *FakeStorageProvider) DeleteUser(id string) error {
+func (f *FakeStorageProvider) DeleteUser(ctx context.Context, id string) error {
 	f.mu.Lock()
 	defer f.mu.Unlock()
 	if _, exists := f.users[id]; !exists {
@@ -184,15 +364,98 @@ func (f *FakeStorageProvider) DeleteUser(id string) error {
 	return nil
 }
 
+func (f *FakeStorageProvider) SoftDeleteUser(ctx context.Context, id string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	u, exists := f.users[id]
+	if !exists || u.DeletedAt != nil {
+		return core.ErrUserNotFound
+	}
+	now := time.Now()
+	u.DeletedAt = &now
+	return nil
+}
+
+func (f *FakeStorageProvider) RestoreUser(ctx context.Context, id string, deletedAfter time.Time) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	u, exists := f.users[id]
+	if !exists || u.DeletedAt == nil || u.DeletedAt.Before(deletedAfter) {
+		return core.ErrUserNotFound
+	}
+	for _, existing := range f.users {
+		if existing.Email == u.Email && existing.DeletedAt == nil {
+			return core.ErrUserExists
+		}
+	}
+	u.DeletedAt = nil
+	return nil
+}
+
+// fakeUserStatus treats an empty status as active, like the database default
+func fakeUserStatus(u *core.User) string {
+	if u.Status == "" {
+		return core.UserStatusActive
+	}
+	return u.Status
+}
+
+func (f *FakeStorageProvider) ListUsersByStatus(ctx context.Context, status string, limit, offset int) ([]*core.User, int, error) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	var matches []*core.User
+	for _, u := range f.users {
+		if u.DeletedAt == nil && fakeUserStatus(u) == status {
+			matches = append(matches, u)
+		}
+	}
+	sort.Slice(matches, func(i, j int) bool {
+		return matches[i].CreatedAt.Before(matches[j].CreatedAt)
+	})
+	total := len(matches)
+	if offset >= total {
+		return nil, total, nil
+	}
+	matches = matches[offset:]
+	if limit > 0 && len(matches) > limit {
+		matches = matches[:limit]
+	}
+	return matches, total, nil
+}
+
+func (f *FakeStorageProvider) SetUserStatus(ctx context.Context, id, from, to string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	u, exists := f.users[id]
+	if !exists || u.DeletedAt != nil || fakeUserStatus(u) != from {
+		return core.ErrUserNotFound
+	}
+	u.Status = to
+	return nil
+}
+
+func (f *FakeStorageProvider) PurgeDeletedUsers(ctx context.Context, deletedBefore time.Time) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	count := 0
+	for id, u := range f.users {
+		if u.DeletedAt != nil && u.DeletedAt.Before(deletedBefore) {
+			delete(f.users, id)
+			count++
+		}
+	}
+	return count, nil
+}
+
 // AccountStorage implementation
-func (f *FakeStorageProvider) CreateAccount(a *core.Account) error {
+func (f *FakeStorageProvider) CreateAccount(ctx context.Context, a *core.Account) error {
 	f.mu.Lock()
 	defer f.mu.Unlock()
 	f.accounts[a.ID] = a
 	return nil
 }
 
-func (f *FakeStorageProvider) GetAccountByID(id string) (*core.Account, error) {
+func (f *FakeStorageProvider) GetAccountByID(ctx context.Context, id string) (*core.Account, error) {
 	f.mu.RLock()
 	defer f.mu.RUnlock()
 	if a, ok := f.accounts[id]; ok {
@@ -201,7 +464,7 @@ func (f *FakeStorageProvider) GetAccountByID(id string) (*core.Account, error) {
 	return nil, errors.New("account not found")
 }
 
-func (f *FakeStorageProvider) GetAccountByUserAndProvider(userID, providerID string) ([]*core.Account, error) {
+func (f *FakeStorageProvider) GetAccountByUserAndProvider(ctx context.Context, userID, providerID string) ([]*core.Account, error) {
 	f.mu.RLock()
 	defer f.mu.RUnlock()
 	var accounts []*core.Account
@@ -213,7 +476,18 @@ func (f *FakeStorageProvider) GetAccountByUserAndProvider(userID, providerID str
 	return accounts, nil
 }
 
-func (f *FakeStorageProvider) UpdateAccount(a *core.Account) error {
+func (f *FakeStorageProvider) GetAccountByProvider(ctx context.Context, providerID, accountID string) (*core.Account, error) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	for _, a := range f.accounts {
+		if a.ProviderID == providerID && a.AccountID == accountID {
+			return a, nil
+		}
+	}
+	return nil, core.ErrUserNotFound
+}
+
+func (f *FakeStorageProvider) UpdateAccount(ctx context.Context, a *core.Account) error {
 	f.mu.Lock()
 	defer f.mu.Unlock()
 	if _, exists := f.accounts[a.ID]; !exists {
@@ -223,7 +497,25 @@ func (f *FakeStorageProvider) UpdateAccount(a *core.Account) error {
 	return nil
 }
 
-func (f *FakeStorageProvider) DeleteAccount(id string) error {
+func (f *FakeStorageProvider) GetExpiringAccounts(ctx context.Context, before time.Time, limit int) ([]*core.Account, error) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	var accounts []*core.Account
+	for _, a := range f.accounts {
+		if a.RefreshToken != nil && a.ExpiresAt != nil && a.ExpiresAt.Before(before) {
+			accounts = append(accounts, a)
+		}
+	}
+	sort.Slice(accounts, func(i, j int) bool {
+		return accounts[i].ExpiresAt.Before(*accounts[j].ExpiresAt)
+	})
+	if len(accounts) > limit {
+		accounts = accounts[:limit]
+	}
+	return accounts, nil
+}
+
+func (f *FakeStorageProvider) DeleteAccount(ctx context.Context, id string) error {
 	f.mu.Lock()
 	defer f.mu.Unlock()
 	if _, exists := f.accounts[id]; !exists {
@@ -233,6 +525,172 @@ func (f *FakeStorageProvider) DeleteAccount(id string) error {
 	return nil
 }
 
+// RefreshTokenStorage implementation
+func (f *FakeStorageProvider) CreateRefreshToken(ctx context.Context, t *core.RefreshToken) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.refreshTokens[t.ID] = t
+	return nil
+}
+
+func (f *FakeStorageProvider) GetRefreshTokenByHash(ctx context.Context, tokenHash string) (*core.RefreshToken, error) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	for _, t := range f.refreshTokens {
+		if t.TokenHash == tokenHash {
+			return t, nil
+		}
+	}
+	return nil, core.ErrRefreshTokenNotFound
+}
+
+func (f *FakeStorageProvider) RevokeRefreshToken(ctx context.Context, id string) error {
+	return f.retireRefreshToken(id, false)
+}
+
+func (f *FakeStorageProvider) RotateRefreshToken(ctx context.Context, id string) error {
+	return f.retireRefreshToken(id, true)
+}
+
+func (f *FakeStorageProvider) retireRefreshToken(id string, rotated bool) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	t, ok := f.refreshTokens[id]
+	if !ok || t.RevokedAt != nil {
+		return core.ErrRefreshTokenNotFound
+	}
+	now := time.Now()
+	t.RevokedAt = &now
+	if rotated {
+		t.RotatedAt = &now
+	}
+	return nil
+}
+
+func (f *FakeStorageProvider) RevokeSessionRefreshTokens(ctx context.Context, sessionID string) (int, error) {
+	return f.revokeRefreshTokensWhere(func(t *core.RefreshToken) bool { return t.SessionID == sessionID })
+}
+
+func (f *FakeStorageProvider) RevokeUserRefreshTokens(ctx context.Context, userID string) (int, error) {
+	return f.revokeRefreshTokensWhere(func(t *core.RefreshToken) bool { return t.UserID == userID })
+}
+
+func (f *FakeStorageProvider) revokeRefreshTokensWhere(match func(*core.RefreshToken) bool) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	now := time.Now()
+	count := 0
+	for _, t := range f.refreshTokens {
+		if t.RevokedAt == nil && match(t) {
+			t.RevokedAt = &now
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (f *FakeStorageProvider) GetActiveUserRefreshTokens(ctx context.Context, userID string) ([]*core.RefreshToken, error) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	now := time.Now()
+	var tokens []*core.RefreshToken
+	for _, t := range f.refreshTokens {
+		if t.UserID == userID && t.RevokedAt == nil && now.Before(t.ExpiresAt) {
+			tokens = append(tokens, t)
+		}
+	}
+	sort.Slice(tokens, func(i, j int) bool { return tokens[i].CreatedAt.Before(tokens[j].CreatedAt) })
+	return tokens, nil
+}
+
+func (f *FakeStorageProvider) DeleteExpiredRefreshTokens(ctx context.Context) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	now := time.Now()
+	count := 0
+	for id, t := range f.refreshTokens {
+		if now.After(t.ExpiresAt) {
+			delete(f.refreshTokens, id)
+			count++
+		}
+	}
+	return count, nil
+}
+
+// UserSecurityStorage implementation
+func (f *FakeStorageProvider) GetUserSecurity(ctx context.Context, userID string) (*core.UserSecurity, error) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	s, ok := f.userSecurity[userID]
+	if !ok {
+		return nil, core.ErrUserSecurityNotFound
+	}
+	stored := *s
+	return &stored, nil
+}
+
+func (f *FakeStorageProvider) UpsertUserSecurity(ctx context.Context, s *core.UserSecurity) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	s.UpdatedAt = time.Now()
+	stored := *s
+	f.userSecurity[s.UserID] = &stored
+	return nil
+}
+
+// VerificationTokenStorage implementation
+func (f *FakeStorageProvider) CreateVerificationToken(ctx context.Context, token *core.VerificationToken) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	token.CreatedAt = time.Now()
+	stored := *token
+	f.verifications[token.TokenHash] = &stored
+	return nil
+}
+
+func (f *FakeStorageProvider) ConsumeVerificationToken(ctx context.Context, tokenHash, purpose string) (*core.VerificationToken, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	token, ok := f.verifications[tokenHash]
+	now := time.Now()
+	if !ok || token.Purpose != purpose || token.ConsumedAt != nil || !token.ExpiresAt.After(now) {
+		return nil, core.ErrVerificationTokenNotFound
+	}
+	token.ConsumedAt = &now
+	consumed := *token
+	return &consumed, nil
+}
+
+func (f *FakeStorageProvider) RevokeUserVerificationTokens(ctx context.Context, userID string, purposes ...string) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	now := time.Now()
+	count := 0
+	for _, token := range f.verifications {
+		if token.UserID == nil || *token.UserID != userID || !slices.Contains(purposes, token.Purpose) ||
+			token.ConsumedAt != nil || !token.ExpiresAt.After(now) {
+			continue
+		}
+		consumedAt := now
+		token.ConsumedAt = &consumedAt
+		count++
+	}
+	return count, nil
+}
+
+func (f *FakeStorageProvider) PurgeVerificationTokens(ctx context.Context, expiredBefore, consumedBefore time.Time) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	count := 0
+	for hash, token := range f.verifications {
+		if token.ExpiresAt.Before(expiredBefore) || (token.ConsumedAt != nil && token.ConsumedAt.Before(consumedBefore)) {
+			delete(f.verifications, hash)
+			count++
+		}
+	}
+	return count, nil
+}
+
 // FakeCache is a test-only fake implementing core.Cache.
 // It stores sessions in a map and exposes error fields for behavior injection.
 type FakeCache struct {
@@ -360,3 +818,24 @@ func (f *fakeFailingCache) Clear() error {
 func (f *fakeFailingCache) Stats() core.CacheStats {
 	return core.CacheStats{}
 }
+
+// FakeMailer is a test-only fake implementing core.Mailer. It records every
+// message sent.
+type FakeMailer struct {
+	mu       sync.Mutex
+	messages []*core.EmailMessage
+}
+
+func (f *FakeMailer) SendEmail(message *core.EmailMessage) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.messages = append(f.messages, message)
+	return nil
+}
+
+// Messages returns the messages sent so far
+func (f *FakeMailer) Messages() []*core.EmailMessage {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]*core.EmailMessage(nil), f.messages...)
+}
@@ -0,0 +1,96 @@
+package services
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/lborres/kuta/core"
+)
+
+const (
+	defaultThrottleWindow          = 15 * time.Minute
+	defaultThrottleFreeAttempts    = 3
+	defaultThrottleBaseDelay       = time.Second
+	defaultThrottleMaxDelay        = 30 * time.Second
+	defaultThrottleLockoutAttempts = 10
+)
+
+// loginThrottle slows and eventually blocks repeated failed sign-ins for the
+// same email + IP address pair.
+type loginThrottle struct {
+	store  core.AttemptStore
+	config core.ThrottleConfig
+	sleep  func(time.Duration)
+}
+
+func newLoginThrottle(store core.AttemptStore, config core.ThrottleConfig) *loginThrottle {
+	if config.Window <= 0 {
+		config.Window = defaultThrottleWindow
+	}
+	if config.FreeAttempts <= 0 {
+		config.FreeAttempts = defaultThrottleFreeAttempts
+	}
+	if config.BaseDelay <= 0 {
+		config.BaseDelay = defaultThrottleBaseDelay
+	}
+	if config.MaxDelay <= 0 {
+		config.MaxDelay = defaultThrottleMaxDelay
+	}
+	if config.LockoutAttempts <= 0 {
+		config.LockoutAttempts = defaultThrottleLockoutAttempts
+	}
+
+	return &loginThrottle{
+		store:  store,
+		config: config,
+		sleep:  time.Sleep,
+	}
+}
+
+// throttleKey identifies an email + IP pair. Emails are case-folded so the
+// throttle can't be sidestepped by changing case.
+func throttleKey(email, ipAddress string) string {
+	return "signin:" + strings.ToLower(strings.TrimSpace(email)) + "|" + ipAddress
+}
+
+// delay returns how long to hold a sign-in that follows the given number of
+// recent failures
+func (t *loginThrottle) delay(failures int) time.Duration {
+	excess := failures - t.config.FreeAttempts
+	if excess < 0 {
+		return 0
+	}
+	d := t.config.BaseDelay
+	for i := 0; i < excess && d < t.config.MaxDelay; i++ {
+		d *= 2
+	}
+	return min(d, t.config.MaxDelay)
+}
+
+// before enforces the lockout and progressive delay for key. Store errors
+// fail open, like cache errors: throttling is a defence in depth, not a
+// reason to refuse every sign-in while the store is down.
+func (t *loginThrottle) before(key string) error {
+	failures, err := t.store.Failures(key, t.config.Window)
+	if err != nil {
+		return nil
+	}
+	if failures >= t.config.LockoutAttempts {
+		return core.ErrTooManyAttempts
+	}
+	if d := t.delay(failures); d > 0 {
+		t.sleep(d)
+	}
+	return nil
+}
+
+// after records the outcome of a sign-in attempt for key
+func (t *loginThrottle) after(key string, err error) {
+	switch {
+	case err == nil:
+		_ = t.store.Reset(key)
+	case errors.Is(err, core.ErrInvalidCredentials):
+		_, _ = t.store.RecordFailure(key, t.config.Window)
+	}
+}
@@ -0,0 +1,106 @@
+package services
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/lborres/kuta/core"
+	"github.com/lborres/kuta/pkg/cache"
+	"github.com/lborres/kuta/pkg/crypto"
+)
+
+func newThrottledSessionManager(t *testing.T, config core.ThrottleConfig) (*SessionManager, *[]time.Duration) {
+	t.Helper()
+	// Cheap hashing parameters keep repeated sign-ins fast
+	passwords := &crypto.Argon2{Memory: 1024, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32}
+	manager := NewSessionManager(core.SessionConfig{MaxAge: time.Hour}, NewFakeStorageProvider(), nil, passwords,
+		WithLoginThrottle(cache.NewInMemoryAttemptStore(), config))
+
+	var delays []time.Duration
+	manager.throttle.sleep = func(d time.Duration) { delays = append(delays, d) }
+
+	if _, err := manager.SignUp(t.Context(), core.SignUpInput{Email: "user@example.com", Password: "CorrectPass123!", Name: "User"}, "", ""); err != nil {
+		t.Fatalf("SignUp error: %v", err)
+	}
+	return manager, &delays
+}
+
+// Requirement: Failed sign-ins for an email + IP pair get progressively
+// delayed after the free attempts, then locked out.
+func TestSessionManager_SignIn_Throttle(t *testing.T) {
+	// Arrange
+	manager, delays := newThrottledSessionManager(t, core.ThrottleConfig{
+		FreeAttempts:    2,
+		BaseDelay:       time.Second,
+		MaxDelay:        3 * time.Second,
+		LockoutAttempts: 5,
+	})
+	wrong := core.SignInInput{Email: "user@example.com", Password: "WrongPass123!"}
+
+	// Act
+	var errs []error
+	for i := 0; i < 6; i++ {
+		_, err := manager.SignIn(t.Context(), wrong, "10.0.0.1", "agent")
+		errs = append(errs, err)
+	}
+
+	// Assert
+	for i, err := range errs[:5] {
+		if !errors.Is(err, core.ErrInvalidCredentials) {
+			t.Errorf("attempt %d: expected %v, got %v", i+1, core.ErrInvalidCredentials, err)
+		}
+	}
+	if !errors.Is(errs[5], core.ErrTooManyAttempts) {
+		t.Errorf("attempt 6: expected %v, got %v", core.ErrTooManyAttempts, errs[5])
+	}
+	want := []time.Duration{time.Second, 2 * time.Second, 3 * time.Second}
+	if len(*delays) != len(want) {
+		t.Fatalf("expected delays %v, got %v", want, *delays)
+	}
+	for i := range want {
+		if (*delays)[i] != want[i] {
+			t.Errorf("delay %d: expected %v, got %v", i, want[i], (*delays)[i])
+		}
+	}
+}
+
+// Requirement: The throttle is per email + IP pair, and a successful sign-in
+// clears it.
+func TestSessionManager_SignIn_ThrottleScope(t *testing.T) {
+	tests := []struct {
+		name    string
+		email   string
+		ip      string
+		reset   bool
+		wantErr error
+	}{
+		{name: "same pair is locked", email: "user@example.com", ip: "10.0.0.1", wantErr: core.ErrTooManyAttempts},
+		{name: "email case does not matter", email: "USER@example.com", ip: "10.0.0.1", wantErr: core.ErrTooManyAttempts},
+		{name: "other IP behind the same account", email: "user@example.com", ip: "10.0.0.2"},
+		{name: "other account behind the same IP", email: "other@example.com", ip: "10.0.0.1", wantErr: core.ErrInvalidCredentials},
+		{name: "success resets the counter", email: "user@example.com", ip: "10.0.0.1", reset: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// Arrange
+			manager, _ := newThrottledSessionManager(t, core.ThrottleConfig{LockoutAttempts: 2})
+			wrong := core.SignInInput{Email: "user@example.com", Password: "WrongPass123!"}
+			for i := 0; i < 2; i++ {
+				_, _ = manager.SignIn(t.Context(), wrong, "10.0.0.1", "agent")
+			}
+			if test.reset {
+				manager.throttle.after(throttleKey("user@example.com", "10.0.0.1"), nil)
+			}
+
+			// Act
+			_, err := manager.SignIn(t.Context(), core.SignInInput{Email: test.email, Password: "CorrectPass123!"}, test.ip, "agent")
+
+			// Assert
+			if !errors.Is(err, test.wantErr) {
+				t.Errorf("expected %v, got %v", test.wantErr, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,326 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/lborres/kuta/core"
+	"github.com/lborres/kuta/pkg/crypto"
+	"github.com/lborres/kuta/pkg/totp"
+)
+
+const (
+	defaultTOTPIssuer  = "kuta"
+	defaultBackupCodes = 10
+	// backupCodeBytes is the entropy of a backup code: 10 characters
+	backupCodeBytes = 6
+)
+
+// TOTPSealerPurpose namespaces the key used to seal TOTP secrets
+const TOTPSealerPurpose = "totp-secret"
+
+// backupCodeEncoding spells backup codes in lowercase letters and digits
+var backupCodeEncoding = base32.NewEncoding("abcdefghijklmnopqrstuvwxyz234567").WithPadding(base32.NoPadding)
+
+// Ensure SessionManager implements TwoFactorProvider
+var _ core.TwoFactorProvider = (*SessionManager)(nil)
+
+// twoFactor holds the TOTP settings
+type twoFactor struct {
+	issuer      string
+	backupCodes int
+	sealer      *crypto.Sealer
+}
+
+// totpState is what a TOTP account keeps in its AccessToken, sealed: the
+// secret, and the last step a code was accepted for so no code works twice
+type totpState struct {
+	Secret   string `json:"secret"`
+	LastStep uint64 `json:"lastStep,omitempty"`
+}
+
+// WithTwoFactor enables TOTP two-factor authentication, with secrets stored
+// sealed by sealer. Enrolled users are challenged at sign-in ahead of any
+// other challenger, their pending sign-ins kept by WithSignInChallenges'
+// store, or in memory without one. Nothing is enabled when sealer is nil.
+func WithTwoFactor(config core.TwoFactorConfig, sealer *crypto.Sealer) Option {
+	return func(sm *SessionManager) {
+		if sealer == nil {
+			return
+		}
+		tf := &twoFactor{issuer: config.Issuer, backupCodes: config.BackupCodes, sealer: sealer}
+		if tf.issuer == "" {
+			tf.issuer = defaultTOTPIssuer
+		}
+		if tf.backupCodes <= 0 {
+			tf.backupCodes = defaultBackupCodes
+		}
+		sm.twoFactor = tf
+	}
+}
+
+// TOTPEnabled reports whether users can enroll authenticator apps
+func (sm *SessionManager) TOTPEnabled() bool {
+	return sm.twoFactor != nil
+}
+
+// SetupTOTP generates a secret and backup codes for userID. 2FA stays off
+// until VerifyTOTP confirms the app produces matching codes.
+func (sm *SessionManager) SetupTOTP(ctx context.Context, userID string) (*core.TOTPSetup, error) {
+	if sm.twoFactor == nil {
+		return nil, core.ErrNotImplemented
+	}
+
+	settings, err := sm.GetUserSecurity(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if settings.TwoFactorEnabled {
+		return nil, core.ErrTwoFactorEnabled
+	}
+	user, err := sm.accountOwner(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		return nil, err
+	}
+	sealed, err := sm.twoFactor.seal(totpState{Secret: secret})
+	if err != nil {
+		return nil, err
+	}
+	codes, hashes, err := generateBackupCodes(sm.twoFactor.backupCodes)
+	if err != nil {
+		return nil, err
+	}
+
+	// An enrollment that was never verified is replaced
+	account, err := sm.totpAccount(ctx, userID)
+	switch {
+	case err == nil:
+		account.AccessToken = &sealed
+		account.Password = &hashes
+		account.UpdatedAt = time.Now()
+		err = sm.storage.UpdateAccount(ctx, account)
+	case errors.Is(err, core.ErrTOTPNotSetUp):
+		err = sm.createTOTPAccount(ctx, userID, sealed, hashes)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &core.TOTPSetup{
+		URI:         totp.URI(sm.twoFactor.issuer, user.Email, secret),
+		Secret:      secret,
+		BackupCodes: codes,
+	}, nil
+}
+
+// VerifyTOTP turns on 2FA for userID when code matches their authenticator
+// app. Verifying again once it is on is a no-op.
+func (sm *SessionManager) VerifyTOTP(ctx context.Context, userID, code string) error {
+	if sm.twoFactor == nil {
+		return core.ErrNotImplemented
+	}
+
+	settings, err := sm.GetUserSecurity(ctx, userID)
+	if err != nil {
+		return err
+	}
+	account, err := sm.totpAccount(ctx, userID)
+	if err != nil {
+		return err
+	}
+	ok, err := sm.useTOTP(ctx, account, code)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return core.ErrInvalidTwoFactorCode
+	}
+	if settings.TwoFactorEnabled {
+		return nil
+	}
+
+	settings.TwoFactorEnabled = true
+	settings.UpdatedAt = time.Now()
+	if err := sm.storage.UpsertUserSecurity(ctx, settings); err != nil {
+		return err
+	}
+
+	sm.emit(core.Event{Type: core.EventTwoFactorEnabled, UserID: userID})
+	return nil
+}
+
+// totpAccount returns the user's TOTP enrollment, or ErrTOTPNotSetUp
+func (sm *SessionManager) totpAccount(ctx context.Context, userID string) (*core.Account, error) {
+	accounts, err := sm.storage.GetAccountByUserAndProvider(ctx, userID, core.TOTPProviderID)
+	if err != nil {
+		return nil, err
+	}
+	if len(accounts) == 0 || accounts[0].AccessToken == nil {
+		return nil, core.ErrTOTPNotSetUp
+	}
+	return accounts[0], nil
+}
+
+func (sm *SessionManager) createTOTPAccount(ctx context.Context, userID, sealed, hashes string) error {
+	accountID, err := sm.ids.accounts.generate()
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	return sm.storage.CreateAccount(ctx, &core.Account{
+		ID:          accountID,
+		UserID:      userID,
+		ProviderID:  core.TOTPProviderID,
+		AccountID:   userID,
+		AccessToken: &sealed,
+		Password:    &hashes,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	})
+}
+
+// totpChallenger is the ChallengeTwoFactor step of users who turned on 2FA
+type totpChallenger struct {
+	sm *SessionManager
+}
+
+func (c totpChallenger) Type() string {
+	return core.ChallengeTwoFactor
+}
+
+func (c totpChallenger) Required(ctx context.Context, user *core.User, _ core.SignInAttempt) (bool, error) {
+	settings, err := c.sm.storage.GetUserSecurity(ctx, user.ID)
+	if errors.Is(err, core.ErrUserSecurityNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return settings.TwoFactorEnabled, nil
+}
+
+// Verify accepts a current code from the app or an unused backup code,
+// which is then used up
+func (c totpChallenger) Verify(ctx context.Context, user *core.User, response string) error {
+	account, err := c.sm.totpAccount(ctx, user.ID)
+	if err != nil {
+		return err
+	}
+	ok, err := c.sm.useTOTP(ctx, account, response)
+	if err != nil || ok {
+		return err
+	}
+
+	remaining, ok := useBackupCode(account, response)
+	if !ok {
+		return core.ErrChallengeFailed
+	}
+	account.Password = &remaining
+	account.UpdatedAt = time.Now()
+	if err := c.sm.storage.UpdateAccount(ctx, account); err != nil {
+		return err
+	}
+
+	left := 0
+	if remaining != "" {
+		left = strings.Count(remaining, "\n") + 1
+	}
+	c.sm.emit(core.Event{
+		Type:     core.EventBackupCodeUsed,
+		UserID:   user.ID,
+		Email:    user.Email,
+		Metadata: map[string]any{"remaining": left},
+	})
+	return nil
+}
+
+// useTOTP reports whether code is a current code from the account's app
+// that wasn't accepted before, recording its step when it is
+func (sm *SessionManager) useTOTP(ctx context.Context, account *core.Account, code string) (bool, error) {
+	state, err := sm.twoFactor.open(*account.AccessToken)
+	if err != nil {
+		return false, err
+	}
+	step, ok := totp.Match(state.Secret, strings.TrimSpace(code), time.Now())
+	if !ok || step <= state.LastStep {
+		return false, nil
+	}
+
+	state.LastStep = step
+	sealed, err := sm.twoFactor.seal(state)
+	if err != nil {
+		return false, err
+	}
+	account.AccessToken = &sealed
+	account.UpdatedAt = time.Now()
+	if err := sm.storage.UpdateAccount(ctx, account); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (tf *twoFactor) seal(state totpState) (string, error) {
+	plaintext, err := json.Marshal(state)
+	if err != nil {
+		return "", err
+	}
+	return tf.sealer.Seal(plaintext)
+}
+
+func (tf *twoFactor) open(sealed string) (totpState, error) {
+	var state totpState
+	plaintext, err := tf.sealer.Open(sealed)
+	if err != nil {
+		return state, err
+	}
+	err = json.Unmarshal(plaintext, &state)
+	return state, err
+}
+
+// generateBackupCodes returns n backup codes formatted for display, and
+// their hashes as stored in the TOTP account, one per line
+func generateBackupCodes(n int) ([]string, string, error) {
+	codes := make([]string, n)
+	hashes := make([]string, n)
+	for i := range codes {
+		raw := make([]byte, backupCodeBytes)
+		if _, err := rand.Read(raw); err != nil {
+			return nil, "", err
+		}
+		code := backupCodeEncoding.EncodeToString(raw)
+		codes[i] = code[:5] + "-" + code[5:]
+		hashes[i] = crypto.HashToken(code)
+	}
+	return codes, strings.Join(hashes, "\n"), nil
+}
+
+// useBackupCode returns the account's backup code hashes without the one
+// matching code, and whether there was one
+func useBackupCode(account *core.Account, code string) (string, bool) {
+	if account.Password == nil || *account.Password == "" {
+		return "", false
+	}
+	code = strings.ToLower(strings.ReplaceAll(strings.TrimSpace(code), "-", ""))
+	hash := crypto.HashToken(code)
+
+	hashes := strings.Split(*account.Password, "\n")
+	for i, stored := range hashes {
+		if subtle.ConstantTimeCompare([]byte(stored), []byte(hash)) == 1 {
+			remaining := append(hashes[:i:i], hashes[i+1:]...)
+			return strings.Join(remaining, "\n"), true
+		}
+	}
+	return "", false
+}
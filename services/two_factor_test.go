@@ -0,0 +1,178 @@
+package services
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lborres/kuta/core"
+	"github.com/lborres/kuta/pkg/cache"
+	"github.com/lborres/kuta/pkg/crypto"
+	"github.com/lborres/kuta/pkg/totp"
+)
+
+// newTwoFactorManager returns a SessionManager with TOTP and a signed-up user
+func newTwoFactorManager(t *testing.T, storage core.StorageProvider, opts ...Option) (*SessionManager, string) {
+	t.Helper()
+	passwords := &crypto.Argon2{Memory: 1024, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32}
+	sealer, _ := crypto.NewSealer("this-is-a-test-secret-of-32-bytes!", TOTPSealerPurpose)
+	opts = append(opts, WithTwoFactor(core.TwoFactorConfig{Issuer: "Example", BackupCodes: 2}, sealer))
+	manager := NewSessionManager(core.SessionConfig{MaxAge: time.Hour}, storage, nil, passwords, opts...)
+	result, err := manager.SignUp(t.Context(), core.SignUpInput{Email: "user@example.com", Password: "CorrectPass123!"}, "", "")
+	if err != nil {
+		t.Fatalf("SignUp error: %v", err)
+	}
+	return manager, result.User.ID
+}
+
+// Requirement: 2FA is only turned on once a code from the enrolled app is
+// verified, and can't be set up again while on. The secret is stored
+// sealed.
+func TestSessionManager_SetupTOTP(t *testing.T) {
+	tests := []struct {
+		name        string
+		code        func(secret string) string
+		wantErr     error
+		wantEnabled bool
+	}{
+		{
+			name: "current code",
+			code: func(secret string) string {
+				code, _ := totp.Code(secret, time.Now())
+				return code
+			},
+			wantEnabled: true,
+		},
+		{
+			name:    "wrong code",
+			code:    func(string) string { return "000000" },
+			wantErr: core.ErrInvalidTwoFactorCode,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// Arrange
+			storage := NewFakeStorageProvider()
+			manager, userID := newTwoFactorManager(t, storage)
+			setup, err := manager.SetupTOTP(t.Context(), userID)
+			if err != nil {
+				t.Fatalf("SetupTOTP error: %v", err)
+			}
+
+			// Act
+			err = manager.VerifyTOTP(t.Context(), userID, test.code(setup.Secret))
+
+			// Assert
+			if !errors.Is(err, test.wantErr) {
+				t.Fatalf("error = %v, want %v", err, test.wantErr)
+			}
+			if !strings.HasPrefix(setup.URI, "otpauth://totp/Example:user@example.com?") || len(setup.BackupCodes) != 2 {
+				t.Errorf("setup = %+v", setup)
+			}
+			accounts, _ := storage.GetAccountByUserAndProvider(t.Context(), userID, core.TOTPProviderID)
+			if len(accounts) != 1 || strings.Contains(*accounts[0].AccessToken, setup.Secret) {
+				t.Errorf("stored TOTP accounts = %+v, want the secret sealed", accounts)
+			}
+			settings, err := manager.GetUserSecurity(t.Context(), userID)
+			if err != nil {
+				t.Fatalf("GetUserSecurity error: %v", err)
+			}
+			if settings.TwoFactorEnabled != test.wantEnabled {
+				t.Errorf("TwoFactorEnabled = %v, want %v", settings.TwoFactorEnabled, test.wantEnabled)
+			}
+			_, err = manager.SetupTOTP(t.Context(), userID)
+			if enabled := errors.Is(err, core.ErrTwoFactorEnabled); enabled != test.wantEnabled {
+				t.Errorf("SetupTOTP again error = %v", err)
+			}
+		})
+	}
+}
+
+// Requirement: Users with 2FA on are challenged at sign-in, answered by a
+// code from their app or a backup code, each code working once. Without a
+// pending sign-in store of their own, challenges are kept in memory.
+func TestSessionManager_TwoFactorSignIn(t *testing.T) {
+	tests := []struct {
+		name     string
+		opts     []Option
+		response func(setup *core.TOTPSetup) []string
+		wantErrs []error
+	}{
+		{
+			name: "app code, used once",
+			opts: []Option{WithSignInChallenges(cache.NewInMemoryPendingSignInStore(), 0)},
+			response: func(setup *core.TOTPSetup) []string {
+				code, _ := totp.Code(setup.Secret, time.Now().Add(totp.Period))
+				return []string{code, code}
+			},
+			wantErrs: []error{nil, core.ErrChallengeFailed},
+		},
+		{
+			name: "app code used to enroll",
+			opts: []Option{WithSignInChallenges(cache.NewInMemoryPendingSignInStore(), 0)},
+			response: func(setup *core.TOTPSetup) []string {
+				code, _ := totp.Code(setup.Secret, time.Now())
+				return []string{code}
+			},
+			wantErrs: []error{core.ErrChallengeFailed},
+		},
+		{
+			name: "app code without a pending sign-in store",
+			response: func(setup *core.TOTPSetup) []string {
+				code, _ := totp.Code(setup.Secret, time.Now().Add(totp.Period))
+				return []string{code}
+			},
+			wantErrs: []error{nil},
+		},
+		{
+			name: "backup code, used once",
+			response: func(setup *core.TOTPSetup) []string {
+				return []string{strings.ToUpper(setup.BackupCodes[1]), setup.BackupCodes[1]}
+			},
+			wantErrs: []error{nil, core.ErrChallengeFailed},
+		},
+		{
+			name:     "wrong code",
+			response: func(*core.TOTPSetup) []string { return []string{"abcde-fghij"} },
+			wantErrs: []error{core.ErrChallengeFailed},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// Arrange
+			manager, userID := newTwoFactorManager(t, NewFakeStorageProvider(), test.opts...)
+			setup, err := manager.SetupTOTP(t.Context(), userID)
+			if err != nil {
+				t.Fatalf("SetupTOTP error: %v", err)
+			}
+			code, _ := totp.Code(setup.Secret, time.Now())
+			if err := manager.VerifyTOTP(t.Context(), userID, code); err != nil {
+				t.Fatalf("VerifyTOTP error: %v", err)
+			}
+
+			for i, response := range test.response(setup) {
+				result, err := manager.SignIn(t.Context(), core.SignInInput{Email: "user@example.com", Password: "CorrectPass123!"}, "", "")
+				if err != nil {
+					t.Fatalf("SignIn error: %v", err)
+				}
+				if result.Challenge == nil || result.Challenge.Type != core.ChallengeTwoFactor {
+					t.Fatalf("challenge = %+v, want %s", result.Challenge, core.ChallengeTwoFactor)
+				}
+
+				// Act
+				result, err = manager.ContinueSignIn(t.Context(), core.ContinueSignInInput{ChallengeToken: result.Challenge.Token, Response: response})
+
+				// Assert
+				if !errors.Is(err, test.wantErrs[i]) {
+					t.Fatalf("response %d error = %v, want %v", i, err, test.wantErrs[i])
+				}
+				if err == nil && result.Session == nil {
+					t.Errorf("response %d issued no session", i)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,68 @@
+package services
+
+import (
+	"context"
+	"time"
+
+	"github.com/lborres/kuta/core"
+)
+
+// DeleteUser soft-deletes a user, destroys all of their sessions and revokes
+// their OAuth provider tokens where the provider supports it.
+// The user can be restored with RestoreUser until the retention window passes,
+// but has to sign in with their providers again. Storage without soft
+// deletes removes the user for good.
+func (sm *SessionManager) DeleteUser(ctx context.Context, userID string) error {
+	// Validate input
+	if userID == "" {
+		return core.ErrUserNotFound
+	}
+
+	if sm.capabilities.SoftDelete {
+		if err := sm.storage.SoftDeleteUser(ctx, userID); err != nil {
+			return err
+		}
+	} else if _, err := sm.storage.GetUserByID(ctx, userID); err != nil {
+		return err
+	}
+
+	// Sessions must stop working immediately, not when they expire, so
+	// they are destroyed even when revocations normally drain
+	count, err := sm.destroyAllUserSessions(ctx, userID)
+	if err != nil {
+		return err
+	}
+	sm.emitSessionsRevoked(userID, count, core.RevokeReasonUserDeleted)
+
+	sm.revokeUserProviderTokens(ctx, userID)
+
+	// Deleted last, as it takes the user's accounts with it
+	if !sm.capabilities.SoftDelete {
+		return sm.storage.DeleteUser(ctx, userID)
+	}
+	return nil
+}
+
+// RestoreUser reverses a soft delete performed within the retention window.
+// Sessions destroyed by DeleteUser are not restored; the user must sign in again.
+// It returns ErrNotImplemented when storage doesn't support soft deletes.
+func (sm *SessionManager) RestoreUser(ctx context.Context, userID string) error {
+	if !sm.capabilities.SoftDelete {
+		return core.ErrNotImplemented
+	}
+	// Validate input
+	if userID == "" {
+		return core.ErrUserNotFound
+	}
+
+	return sm.storage.RestoreUser(ctx, userID, time.Now().Add(-sm.deletedUserRetention))
+}
+
+// PurgeDeletedUsers permanently removes users whose retention window has passed.
+func (sm *SessionManager) PurgeDeletedUsers(ctx context.Context) (int, error) {
+	// Without soft deletes, deleted users are already gone
+	if !sm.capabilities.SoftDelete {
+		return 0, nil
+	}
+	return sm.storage.PurgeDeletedUsers(ctx, time.Now().Add(-sm.deletedUserRetention))
+}
@@ -0,0 +1,118 @@
+package services
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/lborres/kuta/core"
+)
+
+// maxCachedUsers bounds the user cache; past it, expired entries are
+// dropped first and then arbitrary ones
+const maxCachedUsers = 10000
+
+// cachedUserStorage serves GetUserByID from a cache in front of the
+// storage, since every session lookup loads the session's user. Writes
+// through it drop the user's entry; writes from other instances show up
+// once the entry expires.
+type cachedUserStorage struct {
+	core.StorageProvider
+	cache *userCache
+}
+
+type userCache struct {
+	mu    sync.Mutex
+	ttl   time.Duration
+	users map[string]cachedUser
+}
+
+type cachedUser struct {
+	user     core.User
+	cachedAt time.Time
+}
+
+func newUserCache(ttl time.Duration) *userCache {
+	return &userCache{ttl: ttl, users: make(map[string]cachedUser)}
+}
+
+// get returns a copy of the cached user, so callers may modify it
+func (c *userCache) get(id string) (*core.User, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.users[id]
+	if !ok || time.Since(entry.cachedAt) > c.ttl {
+		return nil, false
+	}
+	user := entry.user
+	return &user, true
+}
+
+func (c *userCache) put(user *core.User) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.users) >= maxCachedUsers {
+		c.evict()
+	}
+	c.users[user.ID] = cachedUser{user: *user, cachedAt: time.Now()}
+}
+
+// evict makes room for an entry. Callers hold mu.
+func (c *userCache) evict() {
+	for id, entry := range c.users {
+		if time.Since(entry.cachedAt) > c.ttl {
+			delete(c.users, id)
+		}
+	}
+	for id := range c.users {
+		if len(c.users) < maxCachedUsers {
+			return
+		}
+		delete(c.users, id)
+	}
+}
+
+func (c *userCache) forget(id string) {
+	c.mu.Lock()
+	delete(c.users, id)
+	c.mu.Unlock()
+}
+
+func (s *cachedUserStorage) GetUserByID(ctx context.Context, id string) (*core.User, error) {
+	if user, ok := s.cache.get(id); ok {
+		return user, nil
+	}
+	user, err := s.StorageProvider.GetUserByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	s.cache.put(user)
+	return user, nil
+}
+
+// UpdateUser, like the other writes, drops the entry once the storage has
+// the new row, so a read racing with it doesn't cache the old one again
+func (s *cachedUserStorage) UpdateUser(ctx context.Context, u *core.User) error {
+	defer s.cache.forget(u.ID)
+	return s.StorageProvider.UpdateUser(ctx, u)
+}
+
+func (s *cachedUserStorage) DeleteUser(ctx context.Context, id string) error {
+	defer s.cache.forget(id)
+	return s.StorageProvider.DeleteUser(ctx, id)
+}
+
+func (s *cachedUserStorage) SoftDeleteUser(ctx context.Context, id string) error {
+	defer s.cache.forget(id)
+	return s.StorageProvider.SoftDeleteUser(ctx, id)
+}
+
+func (s *cachedUserStorage) RestoreUser(ctx context.Context, id string, deletedAfter time.Time) error {
+	defer s.cache.forget(id)
+	return s.StorageProvider.RestoreUser(ctx, id, deletedAfter)
+}
+
+func (s *cachedUserStorage) SetUserStatus(ctx context.Context, id, from, to string) error {
+	defer s.cache.forget(id)
+	return s.StorageProvider.SetUserStatus(ctx, id, from, to)
+}
@@ -0,0 +1,134 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/lborres/kuta/core"
+)
+
+// countingUserStorage counts the user lookups that reach the storage
+type countingUserStorage struct {
+	*FakeStorageProvider
+	lookups int
+}
+
+func (s *countingUserStorage) GetUserByID(ctx context.Context, id string) (*core.User, error) {
+	s.lookups++
+	return s.FakeStorageProvider.GetUserByID(ctx, id)
+}
+
+// Requirement: With a user cache, repeated lookups of a user reach the
+// storage once until the entry expires, and changing, disabling or deleting
+// the user drops the cached copy.
+func TestSessionManager_UserCache(t *testing.T) {
+	tests := []struct {
+		name        string
+		ttl         time.Duration
+		change      func(storage core.StorageProvider) error
+		wantLookups int
+		wantName    string
+		wantStatus  string
+		wantErr     error
+	}{
+		{
+			name:        "cached",
+			ttl:         time.Minute,
+			wantLookups: 1,
+			wantName:    "Ada",
+		},
+		{
+			name:        "expired",
+			ttl:         time.Nanosecond,
+			wantLookups: 2,
+			wantName:    "Ada",
+		},
+		{
+			name: "updated",
+			ttl:  time.Minute,
+			change: func(storage core.StorageProvider) error {
+				return storage.UpdateUser(t.Context(), &core.User{ID: "user-1", Email: "a@example.com", Name: "Grace"})
+			},
+			wantLookups: 2,
+			wantName:    "Grace",
+		},
+		{
+			name: "status changed",
+			ttl:  time.Minute,
+			change: func(storage core.StorageProvider) error {
+				return storage.SetUserStatus(t.Context(), "user-1", core.UserStatusActive, core.UserStatusRejected)
+			},
+			wantLookups: 2,
+			wantName:    "Ada",
+			wantStatus:  core.UserStatusRejected,
+		},
+		{
+			name: "deleted",
+			ttl:  time.Minute,
+			change: func(storage core.StorageProvider) error {
+				return storage.DeleteUser(t.Context(), "user-1")
+			},
+			wantLookups: 2,
+			wantErr:     core.ErrUserNotFound,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// Arrange
+			storage := &countingUserStorage{FakeStorageProvider: NewFakeStorageProvider()}
+			_ = storage.CreateUser(t.Context(), &core.User{ID: "user-1", Email: "a@example.com", Name: "Ada"})
+			manager := NewSessionManager(core.SessionConfig{MaxAge: time.Hour}, storage, nil, nil,
+				WithUserCache(test.ttl))
+			if _, err := manager.storage.GetUserByID(t.Context(), "user-1"); err != nil {
+				t.Fatalf("GetUserByID() error = %v", err)
+			}
+			time.Sleep(time.Millisecond)
+
+			// Act
+			if test.change != nil {
+				if err := test.change(manager.storage); err != nil {
+					t.Fatalf("change error = %v", err)
+				}
+			}
+			user, err := manager.storage.GetUserByID(t.Context(), "user-1")
+
+			// Assert
+			if !errors.Is(err, test.wantErr) {
+				t.Fatalf("GetUserByID() error = %v, want %v", err, test.wantErr)
+			}
+			if storage.lookups != test.wantLookups {
+				t.Errorf("storage lookups = %d, want %d", storage.lookups, test.wantLookups)
+			}
+			if test.wantErr != nil {
+				return
+			}
+			if user.Name != test.wantName || (test.wantStatus != "" && user.Status != test.wantStatus) {
+				t.Errorf("user = %+v, want name %q and status %q", user, test.wantName, test.wantStatus)
+			}
+		})
+	}
+}
+
+// Requirement: Callers modifying a user they got from the cache don't
+// change what later lookups return.
+func TestSessionManager_UserCacheCopies(t *testing.T) {
+	// Arrange
+	storage := NewFakeStorageProvider()
+	_ = storage.CreateUser(t.Context(), &core.User{ID: "user-1", Email: "a@example.com", Name: "Ada"})
+	manager := NewSessionManager(core.SessionConfig{MaxAge: time.Hour}, storage, nil, nil,
+		WithUserCache(time.Minute))
+	first, _ := manager.storage.GetUserByID(t.Context(), "user-1")
+	cached, _ := manager.storage.GetUserByID(t.Context(), "user-1")
+
+	// Act
+	cached.Name = "Mallory"
+	again, err := manager.storage.GetUserByID(t.Context(), "user-1")
+
+	// Assert
+	if err != nil || again.Name != "Ada" || first.Name != "Ada" {
+		t.Errorf("GetUserByID() = %+v, %v; want the cached user unchanged", again, err)
+	}
+}
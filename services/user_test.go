@@ -0,0 +1,251 @@
+package services
+
+import (
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/lborres/kuta/core"
+	"github.com/lborres/kuta/pkg/cache"
+	"github.com/lborres/kuta/pkg/crypto"
+)
+
+// Requirement: DeleteUser soft-deletes the user and immediately invalidates their sessions.
+func TestSessionManager_DeleteUser(t *testing.T) {
+	tests := []struct {
+		name    string
+		userID  string
+		wantErr error
+	}{
+		{name: "soft-deletes existing user", userID: "user-alice", wantErr: nil},
+		{name: "returns error for empty userID", userID: "", wantErr: core.ErrUserNotFound},
+		{name: "returns error for unknown user", userID: "missing", wantErr: core.ErrUserNotFound},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// Arrange
+			storage := NewFakeStorageProvider()
+			cache := NewFakeCache()
+			_ = storage.CreateUser(t.Context(), &core.User{ID: "user-alice", Email: "alice@example.com"})
+			manager := newTestSessionManager(storage, cache)
+			created, _ := manager.Create(t.Context(), "user-alice", "127.0.0.1", "test-agent")
+
+			// Act
+			err := manager.DeleteUser(t.Context(), test.userID)
+
+			// Assert
+			if !errors.Is(err, test.wantErr) {
+				t.Fatalf("DeleteUser() error = %v, want %v", err, test.wantErr)
+			}
+			if test.wantErr != nil {
+				return
+			}
+			if _, err := storage.GetUserByID(t.Context(), "user-alice"); !errors.Is(err, core.ErrUserNotFound) {
+				t.Errorf("GetUserByID() after delete error = %v, want ErrUserNotFound", err)
+			}
+			if _, err := manager.GetSession(t.Context(), created.Token); err == nil {
+				t.Error("GetSession() should fail after user is deleted")
+			}
+			if cache.Len() != 0 {
+				t.Errorf("cache should be cleared after delete; got %d entries", cache.Len())
+			}
+		})
+	}
+}
+
+// Requirement: DeleteUser revokes the user's provider tokens, the refresh
+// token when there is one, and clears them from the account. A provider
+// that fails to revoke is reported through an event and doesn't stop the
+// deletion.
+func TestSessionManager_DeleteUser_RevokesProviderTokens(t *testing.T) {
+	tests := []struct {
+		name         string
+		refreshToken string
+		revokeErr    error
+		wantRevoked  string
+		wantEvent    bool
+	}{
+		{name: "revokes refresh token", refreshToken: "refresh-1", wantRevoked: "refresh_token:refresh-1"},
+		{name: "revokes access token without refresh token", wantRevoked: "access_token:access-1"},
+		{name: "reports failed revocation", refreshToken: "refresh-1", revokeErr: errors.New("provider down"), wantEvent: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// Arrange
+			storage := NewFakeStorageProvider()
+			provider := &revokingOAuthProvider{revokeErr: test.revokeErr}
+			provider.identity = core.OAuthIdentity{
+				AccountID:     "sub-1",
+				Email:         "user@example.com",
+				EmailVerified: true,
+				AccessToken:   "access-1",
+				RefreshToken:  test.refreshToken,
+			}
+			var events []core.Event
+			passwords := &crypto.Argon2{Memory: 1024, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32}
+			manager := NewSessionManager(core.SessionConfig{MaxAge: time.Hour}, storage, nil, passwords,
+				WithOAuth(cache.NewInMemoryOAuthStateStore(), "https://app.example/api/auth", provider),
+				WithEventHandler(core.EventHandlerFunc(func(e core.Event) { events = append(events, e) })))
+			start, _ := manager.StartOAuth("test")
+			result, err := manager.CompleteOAuth(t.Context(), "test", core.OAuthCallback{Code: "code", State: start.State, StateCookie: start.State}, "", "")
+			if err != nil {
+				t.Fatalf("CompleteOAuth() error = %v", err)
+			}
+
+			// Act
+			err = manager.DeleteUser(t.Context(), result.User.ID)
+
+			// Assert
+			if err != nil {
+				t.Fatalf("DeleteUser() error = %v", err)
+			}
+			accounts, _ := storage.GetAccountByUserAndProvider(t.Context(), result.User.ID, "test")
+			if len(accounts) != 1 {
+				t.Fatalf("accounts = %d, want 1", len(accounts))
+			}
+			if test.wantEvent {
+				if len(provider.revoked) != 0 || accounts[0].RefreshToken == nil {
+					t.Errorf("revoked = %v, refresh token = %v; want tokens kept", provider.revoked, accounts[0].RefreshToken)
+				}
+				if !slices.ContainsFunc(events, func(e core.Event) bool { return e.Type == core.EventProviderTokenRevokeFailed }) {
+					t.Errorf("events = %v, want %s", events, core.EventProviderTokenRevokeFailed)
+				}
+				return
+			}
+			if len(provider.revoked) != 1 || provider.revoked[0] != test.wantRevoked {
+				t.Errorf("revoked = %v, want [%s]", provider.revoked, test.wantRevoked)
+			}
+			if accounts[0].AccessToken != nil || accounts[0].RefreshToken != nil {
+				t.Errorf("account tokens not cleared: %+v", accounts[0])
+			}
+		})
+	}
+}
+
+// Requirement: RestoreUser only succeeds within the retention window.
+func TestSessionManager_RestoreUser(t *testing.T) {
+	tests := []struct {
+		name      string
+		deletedAt time.Duration // how long ago the user was deleted
+		retention time.Duration
+		wantErr   error
+	}{
+		{name: "restores user within retention window", deletedAt: time.Hour, retention: 24 * time.Hour, wantErr: nil},
+		{name: "refuses restore after retention window", deletedAt: 48 * time.Hour, retention: 24 * time.Hour, wantErr: core.ErrUserNotFound},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// Arrange
+			storage := NewFakeStorageProvider()
+			deletedAt := time.Now().Add(-test.deletedAt)
+			_ = storage.CreateUser(t.Context(), &core.User{ID: "user-alice", Email: "alice@example.com", DeletedAt: &deletedAt})
+			manager := NewSessionManager(core.SessionConfig{MaxAge: time.Hour}, storage, nil, nil, WithDeletedUserRetention(test.retention))
+
+			// Act
+			err := manager.RestoreUser(t.Context(), "user-alice")
+
+			// Assert
+			if !errors.Is(err, test.wantErr) {
+				t.Fatalf("RestoreUser() error = %v, want %v", err, test.wantErr)
+			}
+			_, getErr := storage.GetUserByID(t.Context(), "user-alice")
+			if (getErr == nil) != (test.wantErr == nil) {
+				t.Errorf("GetUserByID() after restore error = %v", getErr)
+			}
+		})
+	}
+}
+
+// Requirement: A soft-deleted user's email is free to sign up with again;
+// the deleted user can then no longer be restored over the new one.
+func TestSessionManager_DeleteUser_FreesEmail(t *testing.T) {
+	// Arrange
+	storage := NewFakeStorageProvider()
+	manager := newTestSessionManager(storage, nil)
+	first, err := manager.SignUp(t.Context(), core.SignUpInput{Email: "alice@example.com", Password: "CorrectPass123!"}, "", "")
+	if err != nil {
+		t.Fatalf("SignUp() error = %v", err)
+	}
+	if err := manager.DeleteUser(t.Context(), first.User.ID); err != nil {
+		t.Fatalf("DeleteUser() error = %v", err)
+	}
+
+	// Act
+	second, err := manager.SignUp(t.Context(), core.SignUpInput{Email: "alice@example.com", Password: "CorrectPass123!"}, "", "")
+
+	// Assert
+	if err != nil {
+		t.Fatalf("SignUp() with a deleted user's email error = %v", err)
+	}
+	if second.User.ID == first.User.ID {
+		t.Errorf("SignUp() reused the deleted user %s", first.User.ID)
+	}
+	if err := manager.RestoreUser(t.Context(), first.User.ID); !errors.Is(err, core.ErrUserExists) {
+		t.Errorf("RestoreUser() error = %v, want ErrUserExists", err)
+	}
+}
+
+// Requirement: PurgeDeletedUsers removes only users past the retention window.
+func TestSessionManager_PurgeDeletedUsers(t *testing.T) {
+	// Arrange
+	storage := NewFakeStorageProvider()
+	recent := time.Now().Add(-time.Hour)
+	old := time.Now().Add(-72 * time.Hour)
+	_ = storage.CreateUser(t.Context(), &core.User{ID: "active", Email: "active@example.com"})
+	_ = storage.CreateUser(t.Context(), &core.User{ID: "recent", Email: "recent@example.com", DeletedAt: &recent})
+	_ = storage.CreateUser(t.Context(), &core.User{ID: "old", Email: "old@example.com", DeletedAt: &old})
+	manager := NewSessionManager(core.SessionConfig{MaxAge: time.Hour}, storage, nil, nil, WithDeletedUserRetention(24*time.Hour))
+
+	// Act
+	count, err := manager.PurgeDeletedUsers(t.Context())
+
+	// Assert
+	if err != nil {
+		t.Fatalf("PurgeDeletedUsers() error = %v", err)
+	}
+	if count != 1 {
+		t.Errorf("PurgeDeletedUsers() count = %d, want 1", count)
+	}
+	if err := manager.RestoreUser(t.Context(), "recent"); err != nil {
+		t.Errorf("recently deleted user should still be restorable: %v", err)
+	}
+}
+
+// Requirement: Storage without soft deletes removes deleted users for good,
+// after their sessions; they can't be restored and there is nothing to
+// purge.
+func TestSessionManager_DeleteUser_WithoutSoftDelete(t *testing.T) {
+	// Arrange
+	fake := NewFakeStorageProvider()
+	storage := FakeLimitedStorage{FakeStorageProvider: fake, Supported: core.StorageCapabilities{Transactions: true, Search: true}}
+	_ = storage.CreateUser(t.Context(), &core.User{ID: "user-alice", Email: "alice@example.com"})
+	manager := newTestSessionManager(storage, NewFakeCache())
+	created, _ := manager.Create(t.Context(), "user-alice", "127.0.0.1", "test-agent")
+
+	// Act
+	err := manager.DeleteUser(t.Context(), "user-alice")
+
+	// Assert
+	if err != nil {
+		t.Fatalf("DeleteUser() error = %v", err)
+	}
+	if _, err := manager.GetSession(t.Context(), created.Token); err == nil {
+		t.Error("GetSession() should fail after user is deleted")
+	}
+	if err := fake.RestoreUser(t.Context(), "user-alice", time.Time{}); !errors.Is(err, core.ErrUserNotFound) {
+		t.Errorf("storage RestoreUser() error = %v, want ErrUserNotFound for a removed row", err)
+	}
+	if err := manager.RestoreUser(t.Context(), "user-alice"); !errors.Is(err, core.ErrNotImplemented) {
+		t.Errorf("RestoreUser() error = %v, want ErrNotImplemented", err)
+	}
+	if count, err := manager.PurgeDeletedUsers(t.Context()); count != 0 || err != nil {
+		t.Errorf("PurgeDeletedUsers() = %d, %v; want 0, nil", count, err)
+	}
+	if err := manager.DeleteUser(t.Context(), "missing"); !errors.Is(err, core.ErrUserNotFound) {
+		t.Errorf("DeleteUser() of unknown user error = %v, want ErrUserNotFound", err)
+	}
+}
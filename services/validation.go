@@ -0,0 +1,97 @@
+package services
+
+import (
+	"strings"
+
+	"github.com/lborres/kuta/core"
+	"github.com/lborres/kuta/pkg/crypto"
+)
+
+// passwordRules bounds the length of new passwords. Zero means no bound.
+type passwordRules struct {
+	minLength int
+	maxLength int
+}
+
+// check records problems with a new password sent as field
+func (r passwordRules) check(v *core.Validator, field, password string) {
+	switch {
+	case password == "":
+		v.Add(field, core.CodePasswordRequired, core.ErrPasswordRequired)
+	case r.minLength > 0 && len([]rune(password)) < r.minLength:
+		v.Add(field, core.CodePasswordTooShort, core.ErrPasswordTooShort)
+	case r.maxLength > 0 && len([]rune(password)) > r.maxLength:
+		v.Add(field, core.CodePasswordTooLong, core.ErrPasswordTooLong)
+	}
+}
+
+func (sm *SessionManager) validateSignUp(input core.SignUpInput) error {
+	var v core.Validator
+	switch {
+	case input.Email == "":
+		v.Add("email", core.CodeEmailRequired, core.ErrEmailRequired)
+	case !validEmail(input.Email):
+		v.Add("email", core.CodeEmailInvalid, core.ErrInvalidEmail)
+	}
+	sm.passwordRules.check(&v, "password", input.Password)
+	return v.Err()
+}
+
+// validateEmailRequest checks the address a password reset or verification
+// email is asked for. The answer is the same whether or not it is registered.
+func validateEmailRequest(email string) error {
+	var v core.Validator
+	switch {
+	case email == "":
+		v.Add("email", core.CodeEmailRequired, core.ErrEmailRequired)
+	case !validEmail(email):
+		v.Add("email", core.CodeEmailInvalid, core.ErrInvalidEmail)
+	}
+	return v.Err()
+}
+
+func (sm *SessionManager) validateResetPassword(input core.ResetPasswordInput) error {
+	var v core.Validator
+	sm.passwordRules.check(&v, "newPassword", input.NewPassword)
+	return v.Err()
+}
+
+// validateSignIn only checks what is needed to attempt the sign-in; password
+// rules apply to new passwords, not to ones set before they changed
+func validateSignIn(input core.SignInInput) error {
+	var v core.Validator
+	if input.Email == "" {
+		v.Add("email", core.CodeEmailRequired, core.ErrEmailRequired)
+	}
+	if input.Password == "" {
+		v.Add("password", core.CodePasswordRequired, core.ErrPasswordRequired)
+	}
+	// Checked before any expensive work
+	if input.PublicKey != "" {
+		if _, err := crypto.ParseProofKey(input.PublicKey); err != nil {
+			v.Add("publicKey", core.CodePublicKeyInvalid, core.ErrInvalidPublicKey)
+		}
+	}
+	return v.Err()
+}
+
+func (sm *SessionManager) validateChangePassword(input core.ChangePasswordInput) error {
+	var v core.Validator
+	if input.CurrentPassword == "" {
+		v.Add("currentPassword", core.CodePasswordRequired, core.ErrPasswordRequired)
+	}
+	sm.passwordRules.check(&v, "newPassword", input.NewPassword)
+	return v.Err()
+}
+
+// validEmail is a deliberately loose shape check: a local part and a domain
+// around a single @, without whitespace. Whether the address exists is for
+// email verification to find out. The placeholder domain of anonymous users
+// is refused so nobody can take over their emails.
+func validEmail(email string) bool {
+	local, domain, ok := strings.Cut(email, "@")
+	return ok && local != "" && domain != "" &&
+		!strings.Contains(domain, "@") &&
+		!strings.ContainsAny(email, " \t\r\n") &&
+		!strings.EqualFold(domain, core.AnonymousEmailDomain)
+}
@@ -0,0 +1,58 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/lborres/kuta/core"
+	"github.com/lborres/kuta/pkg/crypto"
+)
+
+// Requirement: Sign-up reports every invalid field at once, with a code per
+// field, and applies the configured password length bounds.
+func TestSessionManager_SignUp_Validation(t *testing.T) {
+	tests := []struct {
+		name      string
+		email     string
+		password  string
+		wantCodes map[string]string // field -> code
+	}{
+		{name: "valid", email: "a@example.com", password: "long enough"},
+		{
+			name:      "both missing",
+			wantCodes: map[string]string{"email": core.CodeEmailRequired, "password": core.CodePasswordRequired},
+		},
+		{name: "invalid email", email: "not-an-email", password: "long enough", wantCodes: map[string]string{"email": core.CodeEmailInvalid}},
+		{name: "email with space", email: "a b@example.com", password: "long enough", wantCodes: map[string]string{"email": core.CodeEmailInvalid}},
+		{name: "password too short", email: "a@example.com", password: "short", wantCodes: map[string]string{"password": core.CodePasswordTooShort}},
+		{name: "password too long", email: "a@example.com", password: "far too long a password", wantCodes: map[string]string{"password": core.CodePasswordTooLong}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			passwords := &crypto.Argon2{Memory: 1024, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32}
+			manager := NewSessionManager(core.SessionConfig{MaxAge: time.Hour}, NewFakeStorageProvider(), nil, passwords, WithPasswordLength(8, 16))
+
+			// Act
+			_, err := manager.SignUp(t.Context(), core.SignUpInput{Email: tt.email, Password: tt.password}, "127.0.0.1", "test-agent")
+
+			// Assert
+			fields := core.FieldErrors(err)
+			if len(tt.wantCodes) == 0 {
+				if err != nil {
+					t.Fatalf("SignUp() error = %v", err)
+				}
+				return
+			}
+			if len(fields) != len(tt.wantCodes) {
+				t.Fatalf("field errors = %+v, want %v", fields, tt.wantCodes)
+			}
+			for _, field := range fields {
+				if tt.wantCodes[field.Field] != field.Code {
+					t.Errorf("field %q code = %q, want %q", field.Field, field.Code, tt.wantCodes[field.Field])
+				}
+			}
+		})
+	}
+}
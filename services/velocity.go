@@ -0,0 +1,90 @@
+package services
+
+import (
+	"time"
+
+	"github.com/lborres/kuta/core"
+)
+
+const (
+	defaultVelocityWindow          = time.Hour
+	defaultVelocityMaxDistinctIPs  = 5
+	defaultVelocityMaxDistinctASNs = 3
+)
+
+// signInVelocity tracks how many places each user signs in from and flags
+// bursts that suggest a taken-over account
+type signInVelocity struct {
+	store  core.OriginStore
+	config core.VelocityConfig
+}
+
+func newSignInVelocity(store core.OriginStore, config core.VelocityConfig) *signInVelocity {
+	if config.Window <= 0 {
+		config.Window = defaultVelocityWindow
+	}
+	if config.MaxDistinctIPs <= 0 {
+		config.MaxDistinctIPs = defaultVelocityMaxDistinctIPs
+	}
+	if config.MaxDistinctASNs <= 0 {
+		config.MaxDistinctASNs = defaultVelocityMaxDistinctASNs
+	}
+	return &signInVelocity{store: store, config: config}
+}
+
+// check records a successful sign-in and returns the anomaly metadata when a
+// threshold is exceeded, or nil. Store errors are ignored: velocity tracking
+// only feeds monitoring and must never block a sign-in.
+func (v *signInVelocity) check(userID, ipAddress string) map[string]any {
+	if ipAddress == "" {
+		return nil
+	}
+
+	var anomaly map[string]any
+	ips, err := v.store.RecordOrigin("velocity:ip:"+userID, ipAddress, v.config.Window)
+	if err == nil && ips > v.config.MaxDistinctIPs {
+		anomaly = map[string]any{"distinctIps": ips}
+	}
+
+	if v.config.ASNResolver != nil {
+		if asn := v.config.ASNResolver(ipAddress); asn != "" {
+			asns, err := v.store.RecordOrigin("velocity:asn:"+userID, asn, v.config.Window)
+			if err == nil && asns > v.config.MaxDistinctASNs {
+				if anomaly == nil {
+					anomaly = map[string]any{}
+				}
+				anomaly["distinctAsns"] = asns
+				anomaly["asn"] = asn
+			}
+		}
+	}
+
+	if anomaly != nil {
+		anomaly["reason"] = "sign_in_velocity"
+		anomaly["window"] = v.config.Window.String()
+	}
+	return anomaly
+}
+
+// checkSignInVelocity emits EventSecurityAnomaly when the user signs in from
+// unusually many places within the velocity window
+func (sm *SessionManager) checkSignInVelocity(user *core.User, sessionID, ipAddress, userAgent string) {
+	if sm.velocity == nil {
+		return
+	}
+	anomaly := sm.velocity.check(user.ID, ipAddress)
+	if anomaly == nil {
+		return
+	}
+	sm.emit(core.Event{
+		Type:      core.EventSecurityAnomaly,
+		UserID:    user.ID,
+		Email:     user.Email,
+		SessionID: sessionID,
+		IPAddress: ipAddress,
+		UserAgent: userAgent,
+		Metadata:  anomaly,
+	})
+	reason, _ := anomaly["reason"].(string)
+	sm.sendSecurityAlert(user, ipAddress, userAgent, reason)
+}
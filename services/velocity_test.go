@@ -0,0 +1,69 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/lborres/kuta/core"
+	"github.com/lborres/kuta/pkg/cache"
+	"github.com/lborres/kuta/pkg/crypto"
+)
+
+// Requirement: Signing in from more distinct IPs or networks than allowed
+// within the window emits a security.anomaly event.
+func TestSessionManager_SignIn_Velocity(t *testing.T) {
+	tests := []struct {
+		name       string
+		ips        []string
+		asn        core.ASNResolver
+		wantEvents int
+		wantKey    string
+	}{
+		{name: "few IPs stay quiet", ips: []string{"10.0.0.1", "10.0.0.2", "10.0.0.1"}},
+		{name: "too many IPs", ips: []string{"10.0.0.1", "10.0.0.2", "10.0.0.3"}, wantEvents: 1, wantKey: "distinctIps"},
+		{
+			name:       "too many networks",
+			ips:        []string{"10.0.0.1", "10.1.0.1"},
+			asn:        func(ip string) string { return "AS" + ip[3:4] },
+			wantEvents: 1,
+			wantKey:    "distinctAsns",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// Arrange
+			var anomalies []core.Event
+			passwords := &crypto.Argon2{Memory: 1024, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32}
+			manager := NewSessionManager(core.SessionConfig{MaxAge: time.Hour}, NewFakeStorageProvider(), nil, passwords,
+				WithSignInVelocity(cache.NewInMemoryOriginStore(), core.VelocityConfig{MaxDistinctIPs: 2, MaxDistinctASNs: 1, ASNResolver: test.asn}),
+				WithEventHandler(core.EventHandlerFunc(func(e core.Event) {
+					if e.Type == core.EventSecurityAnomaly {
+						anomalies = append(anomalies, e)
+					}
+				})),
+			)
+			if _, err := manager.SignUp(t.Context(), core.SignUpInput{Email: "user@example.com", Password: "CorrectPass123!"}, "", ""); err != nil {
+				t.Fatalf("SignUp error: %v", err)
+			}
+
+			// Act
+			for _, ip := range test.ips {
+				if _, err := manager.SignIn(t.Context(), core.SignInInput{Email: "user@example.com", Password: "CorrectPass123!"}, ip, "agent"); err != nil {
+					t.Fatalf("SignIn error: %v", err)
+				}
+			}
+
+			// Assert
+			if len(anomalies) != test.wantEvents {
+				t.Fatalf("expected %d anomaly events, got %d", test.wantEvents, len(anomalies))
+			}
+			if test.wantEvents > 0 {
+				metadata := anomalies[0].Metadata
+				if _, ok := metadata[test.wantKey]; !ok || metadata["reason"] != "sign_in_velocity" {
+					t.Errorf("unexpected metadata: %v", metadata)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,27 @@
+package services
+
+import (
+	"context"
+	"github.com/lborres/kuta/core"
+)
+
+// Changes to a user that void outstanding verification tokens
+const (
+	changePassword = "password"
+	changeEmail    = "email"
+)
+
+// voidedVerifications lists the purposes of outstanding tokens each change
+// voids. A reset link mailed before a password change must not undo it, and
+// links sent to an old address must not act on the account once it moved.
+var voidedVerifications = map[string][]string{
+	changePassword: {core.VerificationPasswordReset},
+	changeEmail:    {core.VerificationEmail, core.VerificationPasswordReset},
+}
+
+// voidVerificationTokens revokes the user's outstanding verification tokens
+// that change voids
+func (sm *SessionManager) voidVerificationTokens(ctx context.Context, userID, change string) error {
+	_, err := sm.storage.RevokeUserVerificationTokens(ctx, userID, voidedVerifications[change]...)
+	return err
+}
@@ -0,0 +1,137 @@
+package services
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/lborres/kuta/core"
+	"github.com/lborres/kuta/pkg/crypto"
+)
+
+// Requirement: Changing the password voids the user's outstanding password
+// reset tokens, and changing the email also voids their email verification
+// tokens. Other users' tokens and other purposes are left alone.
+func TestSessionManager_VoidVerificationTokens(t *testing.T) {
+	tests := []struct {
+		name        string
+		change      func(manager *SessionManager, userID string) error
+		wantVoided  []string
+		wantUsable  []string
+		wantChanged bool
+	}{
+		{
+			name: "password change",
+			change: func(manager *SessionManager, userID string) error {
+				return manager.ChangePassword(t.Context(), userID, core.ChangePasswordInput{CurrentPassword: "CorrectPass123!", NewPassword: "NewPass456!"})
+			},
+			wantVoided: []string{"reset"},
+			wantUsable: []string{"verify", "invite", "other-reset"},
+		},
+		{
+			name: "email change",
+			change: func(manager *SessionManager, userID string) error {
+				email := "new@example.com"
+				_, err := manager.UpdateUser(t.Context(), userID, core.UpdateUserInput{Email: &email})
+				return err
+			},
+			wantVoided: []string{"reset", "verify"},
+			wantUsable: []string{"invite", "other-reset"},
+		},
+		{
+			name: "profile change without email",
+			change: func(manager *SessionManager, userID string) error {
+				name := "Renamed"
+				_, err := manager.UpdateUser(t.Context(), userID, core.UpdateUserInput{Name: &name})
+				return err
+			},
+			wantUsable: []string{"reset", "verify", "invite", "other-reset"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// Arrange
+			storage := NewFakeStorageProvider()
+			passwords := &crypto.Argon2{Memory: 1024, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32}
+			manager := NewSessionManager(core.SessionConfig{MaxAge: time.Hour}, storage, nil, passwords)
+			signUp, err := manager.SignUp(t.Context(), core.SignUpInput{Email: "user@example.com", Password: "CorrectPass123!"}, "", "")
+			if err != nil {
+				t.Fatalf("SignUp() error = %v", err)
+			}
+			userID, otherUserID := signUp.User.ID, "other-user"
+			purposes := map[string]string{}
+			addToken := func(hash, purpose string, owner *string) {
+				purposes[hash] = purpose
+				_ = storage.CreateVerificationToken(t.Context(), &core.VerificationToken{
+					ID: hash, UserID: owner, Identifier: "user@example.com", Purpose: purpose,
+					TokenHash: hash, ExpiresAt: time.Now().Add(time.Hour),
+				})
+			}
+			addToken("reset", core.VerificationPasswordReset, &userID)
+			addToken("verify", core.VerificationEmail, &userID)
+			addToken("invite", core.VerificationInvite, &userID)
+			addToken("other-reset", core.VerificationPasswordReset, &otherUserID)
+
+			// Act
+			err = test.change(manager, userID)
+
+			// Assert
+			if err != nil {
+				t.Fatalf("change error = %v", err)
+			}
+			for _, hash := range test.wantVoided {
+				if _, err := storage.ConsumeVerificationToken(t.Context(), hash, purposes[hash]); !errors.Is(err, core.ErrVerificationTokenNotFound) {
+					t.Errorf("token %s still usable: %v", hash, err)
+				}
+			}
+			for _, hash := range test.wantUsable {
+				if _, err := storage.ConsumeVerificationToken(t.Context(), hash, purposes[hash]); err != nil {
+					t.Errorf("token %s voided: %v", hash, err)
+				}
+			}
+		})
+	}
+}
+
+// Requirement: An email change must be a valid address not used by another
+// user, and leaves the new address unverified.
+func TestSessionManager_UpdateUser_Email(t *testing.T) {
+	tests := []struct {
+		name    string
+		email   string
+		wantErr error
+	}{
+		{name: "new address", email: "new@example.com"},
+		{name: "address of another user", email: "taken@example.com", wantErr: core.ErrUserExists},
+		{name: "invalid address", email: "not-an-email", wantErr: core.ErrInvalidEmail},
+		{name: "empty address", email: "", wantErr: core.ErrEmailRequired},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// Arrange
+			storage := NewFakeStorageProvider()
+			_ = storage.CreateUser(t.Context(), &core.User{ID: "user-1", Email: "user@example.com", EmailVerified: true})
+			_ = storage.CreateUser(t.Context(), &core.User{ID: "user-2", Email: "taken@example.com"})
+			manager := newTestSessionManager(storage, nil)
+
+			// Act
+			user, err := manager.UpdateUser(t.Context(), "user-1", core.UpdateUserInput{Email: &test.email})
+
+			// Assert
+			if !errors.Is(err, test.wantErr) {
+				t.Fatalf("UpdateUser() error = %v, want %v", err, test.wantErr)
+			}
+			if test.wantErr != nil {
+				return
+			}
+			if user.Email != test.email || user.EmailVerified {
+				t.Errorf("UpdateUser() = %+v, want unverified %s", user, test.email)
+			}
+			if stored, _ := storage.GetUserByEmail(t.Context(), test.email); stored == nil || stored.ID != "user-1" {
+				t.Errorf("stored user for %s = %+v", test.email, stored)
+			}
+		})
+	}
+}
@@ -0,0 +1,56 @@
+package services
+
+import (
+	"context"
+	"time"
+
+	"github.com/lborres/kuta/core"
+)
+
+// warmupPageSize is how many sessions WarmCache loads per storage query
+const warmupPageSize = 500
+
+// WarmCache preloads up to limit of the newest sessions from storage into the
+// cache, so the first requests after a deploy don't all miss it. Sessions
+// don't record when they were last used; the newest ones are the best proxy
+// for the active ones. Expired and draining sessions are skipped.
+//
+// It returns how many sessions were cached. It does nothing without a cache
+// or with stateless sessions. A cache smaller than limit keeps only as many
+// sessions as it holds. Storage without session search returns
+// ErrNotImplemented.
+func (sm *SessionManager) WarmCache(ctx context.Context, limit int) (int, error) {
+	if sm.cache == nil || sm.sealer != nil || limit <= 0 {
+		return 0, nil
+	}
+	if !sm.capabilities.Search {
+		return 0, core.ErrNotImplemented
+	}
+
+	now := time.Now()
+	warmed := 0
+	for offset := 0; warmed < limit; offset += warmupPageSize {
+		sessions, _, err := sm.storage.SearchSessions(ctx, core.SessionFilter{Limit: warmupPageSize, Offset: offset})
+		if err != nil {
+			return warmed, err
+		}
+
+		for _, session := range sessions {
+			if !session.ExpiresAt.After(now) || session.Draining() {
+				continue
+			}
+			if err := sm.cache.Set(session.TokenHash, session); err != nil {
+				return warmed, err
+			}
+			warmed++
+			if warmed == limit {
+				break
+			}
+		}
+
+		if len(sessions) < warmupPageSize {
+			break
+		}
+	}
+	return warmed, nil
+}
@@ -0,0 +1,90 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/lborres/kuta/core"
+)
+
+// Requirement: WarmCache loads the newest live sessions into the cache,
+// skipping expired and draining ones, up to the limit. Storage without
+// session search can't be warmed from.
+func TestSessionManager_WarmCache(t *testing.T) {
+	tests := []struct {
+		name       string
+		sessions   int
+		limit      int
+		withCache  bool
+		wantWarmed int
+	}{
+		{name: "fewer sessions than limit", sessions: 5, limit: 10, withCache: true, wantWarmed: 3},
+		{name: "limit reached", sessions: 5, limit: 2, withCache: true, wantWarmed: 2},
+		{name: "more than one page", sessions: warmupPageSize + 20, limit: warmupPageSize + 10, withCache: true, wantWarmed: warmupPageSize + 10},
+		{name: "no cache", sessions: 5, limit: 10},
+		{name: "disabled", sessions: 5, limit: 0, withCache: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// Arrange
+			storage := NewFakeStorageProvider()
+			now := time.Now()
+			revokedAt := now
+			for i := 0; i < test.sessions; i++ {
+				session := &core.Session{
+					ID:        fmt.Sprintf("s%d", i),
+					UserID:    "u1",
+					TokenHash: fmt.Sprintf("hash-%d", i),
+					ExpiresAt: now.Add(time.Hour),
+					CreatedAt: now.Add(time.Duration(i) * time.Second),
+				}
+				switch {
+				case test.sessions < warmupPageSize && i == 0:
+					session.ExpiresAt = now.Add(-time.Minute)
+				case test.sessions < warmupPageSize && i == 1:
+					session.RevokedAt = &revokedAt
+				}
+				_ = storage.CreateSession(t.Context(), session)
+			}
+			var sessionCache *FakeCache
+			var manager *SessionManager
+			if test.withCache {
+				sessionCache = NewFakeCache()
+				manager = newTestSessionManager(storage, sessionCache)
+			} else {
+				manager = newTestSessionManager(storage, nil)
+			}
+
+			// Act
+			warmed, err := manager.WarmCache(t.Context(), test.limit)
+
+			// Assert
+			if err != nil {
+				t.Fatalf("WarmCache() error = %v", err)
+			}
+			if warmed != test.wantWarmed {
+				t.Errorf("warmed = %d, want %d", warmed, test.wantWarmed)
+			}
+			if sessionCache == nil || warmed == 0 {
+				return
+			}
+			newest := fmt.Sprintf("hash-%d", test.sessions-1)
+			if _, err := sessionCache.Get(newest); err != nil {
+				t.Errorf("expected newest session to be cached: %v", err)
+			}
+			for _, skipped := range []string{"hash-0", "hash-1"} {
+				if _, err := sessionCache.Get(skipped); !errors.Is(err, core.ErrCacheNotFound) {
+					t.Errorf("expected %s not to be cached", skipped)
+				}
+			}
+		})
+	}
+
+	noSearch := newTestSessionManager(FakeLimitedStorage{FakeStorageProvider: NewFakeStorageProvider()}, NewFakeCache())
+	if _, err := noSearch.WarmCache(t.Context(), 10); !errors.Is(err, core.ErrNotImplemented) {
+		t.Errorf("WarmCache() without search error = %v, want ErrNotImplemented", err)
+	}
+}